@@ -22,6 +22,20 @@ const (
 	LicenseSecretKey  = "key"
 )
 
+// VersionSkewPolicy is the skew allowed between the version of the Consul
+// servers and the version of the Consul clients and dataplanes.
+type VersionSkewPolicy string
+
+const (
+	// VersionSkewNone requires the servers to be on the same minor version as the clients.
+	VersionSkewNone VersionSkewPolicy = "none"
+	// VersionSkewServersAhead allows the servers to be one minor version ahead of
+	// the clients, which is the state of a datacenter during an upgrade.
+	VersionSkewServersAhead VersionSkewPolicy = "servers-ahead"
+	// VersionSkewAny allows any versions.
+	VersionSkewAny VersionSkewPolicy = "any"
+)
+
 // TestConfig holds configuration for the test suite.
 type TestConfig struct {
 	Kubeconfig    string
@@ -42,10 +56,14 @@ type TestConfig struct {
 
 	EnableCNI bool
 
+	EnableIPv6 bool
+
 	EnableTransparentProxy bool
 
 	DisablePeering bool
 
+	EnableLoadTests bool
+
 	HelmChartVersion       string
 	ConsulImage            string
 	ConsulK8SImage         string
@@ -55,6 +73,17 @@ type TestConfig struct {
 	EnvoyImage             string
 	ConsulCollectorImage   string
 
+	// KubernetesVersion overrides the Kubernetes version detected from the
+	// API server when checking per-test version constraints.
+	KubernetesVersion *version.Version
+
+	// ConsulServerImage and ConsulServerVersion are the image and version of
+	// the Consul servers when they differ from the clients and dataplanes,
+	// which use ConsulImage and ConsulVersion.
+	ConsulServerImage   string
+	ConsulServerVersion *version.Version
+	VersionSkewPolicy   VersionSkewPolicy
+
 	HCPResourceID string
 
 	VaultHelmChartVersion string
@@ -107,16 +136,58 @@ func (t *TestConfig) HelmValuesFromConfig() (map[string]string, error) {
 		}
 	}
 
+	if t.EnableIPv6 {
+		setIfNotEmpty(helmValues, "global.enableIPv6", "true")
+	}
+
 	setIfNotEmpty(helmValues, "connectInject.transparentProxy.defaultEnabled", strconv.FormatBool(t.EnableTransparentProxy))
 
 	setIfNotEmpty(helmValues, "global.image", t.ConsulImage)
 	setIfNotEmpty(helmValues, "global.imageK8S", t.ConsulK8SImage)
 	setIfNotEmpty(helmValues, "global.imageEnvoy", t.EnvoyImage)
 	setIfNotEmpty(helmValues, "global.imageConsulDataplane", t.ConsulDataplaneImage)
+	setIfNotEmpty(helmValues, "server.image", t.ConsulServerImage)
 
 	return helmValues, nil
 }
 
+// HasVersionSkew returns true if the servers use a different image than the
+// clients and dataplanes.
+func (t *TestConfig) HasVersionSkew() bool {
+	return t.ConsulServerImage != "" && t.ConsulServerImage != t.ConsulImage
+}
+
+// ValidateVersionSkew returns an error if the versions of the servers and the
+// clients are not allowed by the skew policy, which defaults to
+// VersionSkewServersAhead. The versions are only compared if both are set.
+func (t *TestConfig) ValidateVersionSkew() error {
+	switch t.VersionSkewPolicy {
+	case VersionSkewNone, VersionSkewServersAhead, VersionSkewAny, "":
+	default:
+		return fmt.Errorf("unknown version skew policy %q", t.VersionSkewPolicy)
+	}
+	if t.ConsulServerVersion == nil || t.ConsulVersion == nil {
+		return nil
+	}
+	server, client := t.ConsulServerVersion.Segments(), t.ConsulVersion.Segments()
+	sameMajor := server[0] == client[0]
+	skew := server[1] - client[1]
+
+	switch t.VersionSkewPolicy {
+	case VersionSkewNone:
+		if !sameMajor || skew != 0 {
+			return fmt.Errorf("server version %s and client version %s must be on the same minor version with version skew policy %q",
+				t.ConsulServerVersion, t.ConsulVersion, VersionSkewNone)
+		}
+	case VersionSkewServersAhead, "":
+		if !sameMajor || skew < 0 || skew > 1 {
+			return fmt.Errorf("server version %s must be on the same or the next minor version of client version %s with version skew policy %q",
+				t.ConsulServerVersion, t.ConsulVersion, VersionSkewServersAhead)
+		}
+	}
+	return nil
+}
+
 type values struct {
 	Global globalValues `yaml:"global"`
 }
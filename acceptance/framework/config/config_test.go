@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/hashicorp/go-version"
 	"github.com/stretchr/testify/require"
 )
 
@@ -119,6 +120,28 @@ func TestConfig_HelmValuesFromConfig(t *testing.T) {
 				"connectInject.transparentProxy.defaultEnabled": "false",
 			},
 		},
+		{
+			"sets global.enableIPv6 helm value to true when -enable-ipv6 is set",
+			TestConfig{
+				EnableIPv6: true,
+			},
+			map[string]string{
+				"global.enableIPv6":                             "true",
+				"connectInject.transparentProxy.defaultEnabled": "false",
+			},
+		},
+		{
+			"sets server image when -consul-server-image is set",
+			TestConfig{
+				ConsulImage:       "consul:1.15.0",
+				ConsulServerImage: "consul:1.16.0",
+			},
+			map[string]string{
+				"global.image": "consul:1.15.0",
+				"server.image": "consul:1.16.0",
+				"connectInject.transparentProxy.defaultEnabled": "false",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -181,3 +204,72 @@ func TestConfig_HelmValuesFromConfig_EntImage(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_ValidateVersionSkew(t *testing.T) {
+	cases := map[string]struct {
+		serverVersion string
+		clientVersion string
+		policy        VersionSkewPolicy
+		expErr        string
+	}{
+		"no versions": {},
+		"same version with no skew": {
+			serverVersion: "1.16.1",
+			clientVersion: "1.16.0",
+			policy:        VersionSkewNone,
+		},
+		"servers ahead with no skew": {
+			serverVersion: "1.16.0",
+			clientVersion: "1.15.3",
+			policy:        VersionSkewNone,
+			expErr:        `server version 1.16.0 and client version 1.15.3 must be on the same minor version with version skew policy "none"`,
+		},
+		"servers one minor version ahead": {
+			serverVersion: "1.16.0",
+			clientVersion: "1.15.3",
+			policy:        VersionSkewServersAhead,
+		},
+		"servers ahead is the default": {
+			serverVersion: "1.16.0",
+			clientVersion: "1.15.3",
+		},
+		"servers two minor versions ahead": {
+			serverVersion: "1.17.0",
+			clientVersion: "1.15.3",
+			policy:        VersionSkewServersAhead,
+			expErr:        `server version 1.17.0 must be on the same or the next minor version of client version 1.15.3 with version skew policy "servers-ahead"`,
+		},
+		"servers behind": {
+			serverVersion: "1.15.0",
+			clientVersion: "1.16.0",
+			policy:        VersionSkewServersAhead,
+			expErr:        `server version 1.15.0 must be on the same or the next minor version of client version 1.16.0 with version skew policy "servers-ahead"`,
+		},
+		"any skew": {
+			serverVersion: "1.15.0",
+			clientVersion: "1.17.0",
+			policy:        VersionSkewAny,
+		},
+		"unknown policy": {
+			policy: "servers-behind",
+			expErr: `unknown version skew policy "servers-behind"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := TestConfig{VersionSkewPolicy: c.policy}
+			if c.serverVersion != "" {
+				cfg.ConsulServerVersion = version.Must(version.NewVersion(c.serverVersion))
+			}
+			if c.clientVersion != "" {
+				cfg.ConsulVersion = version.Must(version.NewVersion(c.clientVersion))
+			}
+			err := cfg.ValidateVersionSkew()
+			if c.expErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, c.expErr)
+			}
+		})
+	}
+}
@@ -72,6 +72,17 @@ func (c *ConnectHelper) Install(t *testing.T) {
 	c.ConsulClient, _ = c.consulCluster.SetupConsulClient(t, c.Secure)
 }
 
+// InstallWithVersionSkew installs Consul onto the Kubernetes cluster with the
+// servers on a newer version than the clients and dataplanes. It requires a Helm
+// cluster and skips the test unless -consul-server-image is set.
+func (c *ConnectHelper) InstallWithVersionSkew(t *testing.T) {
+	helmCluster, ok := c.consulCluster.(*consul.HelmCluster)
+	require.True(t, ok, "InstallWithVersionSkew requires a Helm cluster")
+	logger.Log(t, "Installing Consul cluster with version skew")
+	helmCluster.CreateWithVersionSkew(t, c.Cfg)
+	c.ConsulClient, _ = c.consulCluster.SetupConsulClient(t, c.Secure)
+}
+
 // Upgrade uses the existing Consul cluster and upgrades it using Helm values
 // set by the Secure, AutoEncrypt, and HelmValues fields.
 func (c *ConnectHelper) Upgrade(t *testing.T) {
@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/acceptance/framework/config"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/logger"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+// SkipUnlessVersionSkew skips the test unless the servers are configured to use
+// a different image than the clients and dataplanes with -consul-server-image.
+func SkipUnlessVersionSkew(t *testing.T, cfg *config.TestConfig) {
+	t.Helper()
+
+	if !cfg.HasVersionSkew() {
+		t.Skip("skipping this test because -consul-server-image is not set")
+	}
+}
+
+// CreateWithVersionSkew installs Consul with the servers on the same image as
+// the clients and dataplanes and then upgrades only the servers to the
+// -consul-server-image. This is the order that a datacenter is upgraded in, so
+// the clients and dataplanes of the installation are on version N while the
+// servers are on version N+1. The test is skipped unless -consul-server-image
+// is set.
+func (h *HelmCluster) CreateWithVersionSkew(t *testing.T, cfg *config.TestConfig) {
+	t.Helper()
+	SkipUnlessVersionSkew(t, cfg)
+
+	serverImage := h.helmOptions.SetValues["server.image"]
+	delete(h.helmOptions.SetValues, "server.image")
+	h.Create(t)
+
+	logger.Logf(t, "upgrading the Consul servers to %s", serverImage)
+	h.Upgrade(t, map[string]string{"server.image": serverImage})
+
+	if cfg.ConsulServerVersion == nil {
+		return
+	}
+	client, _ := h.SetupConsulClient(t, h.helmOptions.SetValues["global.tls.enabled"] == "true")
+	retry.Run(t, func(r *retry.R) {
+		members, err := client.Agent().Members(false)
+		require.NoError(r, err)
+		for _, member := range members {
+			if member.Tags["role"] != "consul" {
+				continue
+			}
+			require.True(r, strings.HasPrefix(member.Tags["build"], cfg.ConsulServerVersion.Core().String()),
+				"server %s is on build %s instead of %s", member.Name, member.Tags["build"], cfg.ConsulServerVersion)
+		}
+	})
+}
@@ -17,6 +17,7 @@ type TestFlags struct {
 	flagKubeconfig  string
 	flagKubecontext string
 	flagNamespace   string
+	flagKubeVersion string
 
 	flagEnableMultiCluster   bool
 	flagSecondaryKubeconfig  string
@@ -32,6 +33,8 @@ type TestFlags struct {
 
 	flagEnableCNI bool
 
+	flagEnableIPv6 bool
+
 	flagEnableTransparentProxy bool
 
 	flagHelmChartVersion       string
@@ -40,6 +43,9 @@ type TestFlags struct {
 	flagConsulDataplaneImage   string
 	flagConsulVersion          string
 	flagConsulDataplaneVersion string
+	flagConsulServerImage      string
+	flagConsulServerVersion    string
+	flagVersionSkewPolicy      string
 	flagEnvoyImage             string
 	flagConsulCollectorImage   string
 	flagVaultHelmChartVersion  string
@@ -58,6 +64,8 @@ type TestFlags struct {
 
 	flagDisablePeering bool
 
+	flagEnableLoadTests bool
+
 	once sync.Once
 }
 
@@ -74,12 +82,19 @@ func (t *TestFlags) init() {
 	flag.StringVar(&t.flagKubecontext, "kubecontext", "", "The name of the Kubernetes context to use. If this is blank, "+
 		"the context set as the current context will be used by default.")
 	flag.StringVar(&t.flagNamespace, "namespace", "", "The Kubernetes namespace to use for tests.")
+	flag.StringVar(&t.flagKubeVersion, "kube-version", "", "The Kubernetes version of the cluster(s) under test. "+
+		"If this is blank, the version will be detected from the Kubernetes API server.")
 
 	flag.StringVar(&t.flagConsulImage, "consul-image", "", "The Consul image to use for all tests.")
 	flag.StringVar(&t.flagConsulK8sImage, "consul-k8s-image", "", "The consul-k8s image to use for all tests.")
 	flag.StringVar(&t.flagConsulDataplaneImage, "consul-dataplane-image", "", "The consul-dataplane image to use for all tests.")
 	flag.StringVar(&t.flagConsulVersion, "consul-version", "", "The consul version used for all tests.")
 	flag.StringVar(&t.flagConsulDataplaneVersion, "consul-dataplane-version", "", "The consul-dataplane version used for all tests.")
+	flag.StringVar(&t.flagConsulServerImage, "consul-server-image", "", "The Consul image to use for the servers in all tests. "+
+		"If this is blank, the servers use the -consul-image. Set it to test clients and dataplanes against servers on a different version.")
+	flag.StringVar(&t.flagConsulServerVersion, "consul-server-version", "", "The consul version of the -consul-server-image.")
+	flag.StringVar(&t.flagVersionSkewPolicy, "version-skew-policy", string(config.VersionSkewServersAhead),
+		"The allowed skew between -consul-server-version and -consul-version. One of none, servers-ahead or any.")
 	flag.StringVar(&t.flagHelmChartVersion, "helm-chart-version", config.HelmChartPath, "The helm chart used for all tests.")
 	flag.StringVar(&t.flagEnvoyImage, "envoy-image", "", "The Envoy image to use for all tests.")
 	flag.StringVar(&t.flagConsulCollectorImage, "consul-collector-image", "", "The consul collector image to use for all tests.")
@@ -113,6 +128,10 @@ func (t *TestFlags) init() {
 		"If true, the test suite will run tests with consul-cni plugin enabled. "+
 			"In general, this will only run against tests that are mesh related (connect, mesh-gateway, peering, etc")
 
+	flag.BoolVar(&t.flagEnableIPv6, "enable-ipv6", false,
+		"If true, the test suite will install Consul configured for an IPv6-only cluster. "+
+			"The Kubernetes clusters under test must be IPv6-only, e.g. kind clusters created with networking.ipFamily set to ipv6.")
+
 	flag.BoolVar(&t.flagEnableTransparentProxy, "enable-transparent-proxy", false,
 		"If true, the test suite will run tests with transparent proxy enabled. "+
 			"This applies only to tests that enable connectInject.")
@@ -136,6 +155,9 @@ func (t *TestFlags) init() {
 	flag.BoolVar(&t.flagDisablePeering, "disable-peering", false,
 		"If true, the peering tests will not run.")
 
+	flag.BoolVar(&t.flagEnableLoadTests, "enable-load-tests", false,
+		"If true, the load tests will run. Their results are written to the load-tests directory in the debug directory.")
+
 	if t.flagEnterpriseLicense == "" {
 		t.flagEnterpriseLicense = os.Getenv("CONSUL_ENT_LICENSE")
 	}
@@ -151,6 +173,13 @@ func (t *TestFlags) Validate() error {
 	if t.flagEnableEnterprise && t.flagEnterpriseLicense == "" {
 		return errors.New("-enable-enterprise provided without setting env var CONSUL_ENT_LICENSE with consul license")
 	}
+
+	if t.flagConsulServerVersion != "" && t.flagConsulServerImage == "" {
+		return errors.New("-consul-server-version provided without -consul-server-image")
+	}
+	if err := t.TestConfigFromFlags().ValidateVersionSkew(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -160,6 +189,8 @@ func (t *TestFlags) TestConfigFromFlags() *config.TestConfig {
 	// if the Version is empty consulVersion will be nil
 	consulVersion, _ := version.NewVersion(t.flagConsulVersion)
 	consulDataplaneVersion, _ := version.NewVersion(t.flagConsulDataplaneVersion)
+	kubeVersion, _ := version.NewVersion(t.flagKubeVersion)
+	consulServerVersion, _ := version.NewVersion(t.flagConsulServerVersion)
 	//vaultserverVersion, _ := version.NewVersion(t.flagVaultServerVersion)
 
 	return &config.TestConfig{
@@ -181,10 +212,14 @@ func (t *TestFlags) TestConfigFromFlags() *config.TestConfig {
 
 		EnableCNI: t.flagEnableCNI,
 
+		EnableIPv6: t.flagEnableIPv6,
+
 		EnableTransparentProxy: t.flagEnableTransparentProxy,
 
 		DisablePeering: t.flagDisablePeering,
 
+		EnableLoadTests: t.flagEnableLoadTests,
+
 		HelmChartVersion:       t.flagHelmChartVersion,
 		ConsulImage:            t.flagConsulImage,
 		ConsulK8SImage:         t.flagConsulK8sImage,
@@ -193,6 +228,10 @@ func (t *TestFlags) TestConfigFromFlags() *config.TestConfig {
 		ConsulDataplaneVersion: consulDataplaneVersion,
 		EnvoyImage:             t.flagEnvoyImage,
 		ConsulCollectorImage:   t.flagConsulCollectorImage,
+		KubernetesVersion:      kubeVersion,
+		ConsulServerImage:      t.flagConsulServerImage,
+		ConsulServerVersion:    consulServerVersion,
+		VersionSkewPolicy:      config.VersionSkewPolicy(t.flagVersionSkewPolicy),
 		VaultHelmChartVersion:  t.flagVaultHelmChartVersion,
 		VaultServerVersion:     t.flagVaultServerVersion,
 
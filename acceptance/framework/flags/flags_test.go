@@ -17,6 +17,10 @@ func TestFlags_validate(t *testing.T) {
 
 		flagEnableEnt  bool
 		flagEntLicense string
+
+		flagConsulVersion       string
+		flagConsulServerImage   string
+		flagConsulServerVersion string
 	}
 	tests := []struct {
 		name       string
@@ -101,6 +105,34 @@ func TestFlags_validate(t *testing.T) {
 			false,
 			"",
 		},
+		{
+			"version skew: error when -consul-server-version is provided without -consul-server-image",
+			fields{
+				flagConsulServerVersion: "1.16.0",
+			},
+			true,
+			"-consul-server-version provided without -consul-server-image",
+		},
+		{
+			"version skew: no error when servers are one minor version ahead",
+			fields{
+				flagConsulVersion:       "1.15.3",
+				flagConsulServerImage:   "hashicorp/consul:1.16.0",
+				flagConsulServerVersion: "1.16.0",
+			},
+			false,
+			"",
+		},
+		{
+			"version skew: error when servers are behind",
+			fields{
+				flagConsulVersion:       "1.16.0",
+				flagConsulServerImage:   "hashicorp/consul:1.15.3",
+				flagConsulServerVersion: "1.15.3",
+			},
+			true,
+			`server version 1.15.3 must be on the same or the next minor version of client version 1.16.0 with version skew policy "servers-ahead"`,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -110,6 +142,9 @@ func TestFlags_validate(t *testing.T) {
 				flagSecondaryKubecontext: tt.fields.flagSecondaryKubecontext,
 				flagEnableEnterprise:     tt.fields.flagEnableEnt,
 				flagEnterpriseLicense:    tt.fields.flagEntLicense,
+				flagConsulVersion:        tt.fields.flagConsulVersion,
+				flagConsulServerImage:    tt.fields.flagConsulServerImage,
+				flagConsulServerVersion:  tt.fields.flagConsulServerVersion,
 			}
 			err := tf.Validate()
 			if tt.wantErr {
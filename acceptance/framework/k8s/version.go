@@ -0,0 +1,56 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package k8s
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/acceptance/framework/config"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/environment"
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/require"
+)
+
+// KubernetesVersion returns the version of the Kubernetes cluster for the given context.
+// If -kube-version is set, that version is returned instead of querying the API server.
+func KubernetesVersion(t *testing.T, cfg *config.TestConfig, ctx environment.TestContext) *version.Version {
+	t.Helper()
+
+	if cfg.KubernetesVersion != nil {
+		return cfg.KubernetesVersion
+	}
+
+	info, err := ctx.KubernetesClient(t).Discovery().ServerVersion()
+	require.NoError(t, err)
+
+	v, err := ParseKubernetesVersion(info.GitVersion)
+	require.NoError(t, err)
+
+	return v
+}
+
+// ParseKubernetesVersion parses the version reported by a Kubernetes API server.
+// Any pre-release or metadata suffix added by the distribution (e.g. "-gke.1200" or "+k3s1")
+// is dropped so that the version compares as its core release.
+func ParseKubernetesVersion(v string) (*version.Version, error) {
+	parsed, err := version.NewVersion(v)
+	if err != nil {
+		return nil, err
+	}
+	return parsed.Core(), nil
+}
+
+// SkipUnlessKubernetesVersion skips the test if the Kubernetes version of the cluster
+// does not satisfy the given constraints, e.g. ">= 1.28" or ">= 1.24, < 1.29".
+func SkipUnlessKubernetesVersion(t *testing.T, cfg *config.TestConfig, ctx environment.TestContext, constraints string) {
+	t.Helper()
+
+	c, err := version.NewConstraint(constraints)
+	require.NoError(t, err)
+
+	v := KubernetesVersion(t, cfg, ctx)
+	if !c.Check(v) {
+		t.Skipf("skipping this test because Kubernetes version %s does not satisfy %q", v, constraints)
+	}
+}
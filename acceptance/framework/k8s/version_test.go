@@ -0,0 +1,51 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKubernetesVersion(t *testing.T) {
+	cases := map[string]struct {
+		version  string
+		expected string
+		expErr   bool
+	}{
+		"plain version": {
+			version:  "v1.27.3",
+			expected: "1.27.3",
+		},
+		"gke version": {
+			version:  "v1.26.5-gke.1200",
+			expected: "1.26.5",
+		},
+		"eks version": {
+			version:  "v1.25.11-eks-a5565ad",
+			expected: "1.25.11",
+		},
+		"k3s version": {
+			version:  "v1.27.4+k3s1",
+			expected: "1.27.4",
+		},
+		"invalid version": {
+			version: "not-a-version",
+			expErr:  true,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			v, err := ParseKubernetesVersion(c.version)
+			if c.expErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expected, v.String())
+		})
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package loadtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/config"
+	k8sHelpers "github.com/hashicorp/consul-k8s/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/logger"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// TrafficGenerator is the name of the deployment, container and Consul service
+	// of the fortio client in the fortio-client fixtures.
+	TrafficGenerator = "fortio-client"
+
+	// resultsDirectory is the directory in the debug directory that results are written to.
+	resultsDirectory = "load-tests"
+)
+
+// Options configure a load test.
+type Options struct {
+	// URL is the URL that requests are sent to, e.g. the local address of an
+	// upstream such as http://localhost:1234/. URLs starting with tcp:// run a
+	// TCP echo test instead.
+	URL string
+	// QPS is the number of requests per second across all connections. Zero
+	// sends requests as fast as possible.
+	QPS int
+	// Connections is the number of parallel connections. Defaults to 8.
+	Connections int
+	// Duration is how long requests are sent for. Defaults to 30 seconds.
+	Duration time.Duration
+}
+
+// Budget is the performance that a load test must meet.
+type Budget struct {
+	// P99 is the maximum 99th percentile latency of requests. Zero doesn't check the latency.
+	P99 time.Duration `json:"p99"`
+	// MaxErrorRate is the maximum fraction of requests that may fail, e.g. 0.001 for 0.1%.
+	MaxErrorRate float64 `json:"maxErrorRate"`
+}
+
+// Result is the result of a fortio load test.
+type Result struct {
+	RunType           string           `json:"RunType"`
+	URL               string           `json:"URL"`
+	ActualQPS         float64          `json:"ActualQPS"`
+	NumThreads        int              `json:"NumThreads"`
+	DurationHistogram Histogram        `json:"DurationHistogram"`
+	RetCodes          map[string]int64 `json:"RetCodes"`
+
+	// raw is the JSON result of fortio.
+	raw json.RawMessage
+}
+
+// Histogram is the latency histogram of a fortio load test. Values are in seconds.
+type Histogram struct {
+	Count       int64        `json:"Count"`
+	Avg         float64      `json:"Avg"`
+	Max         float64      `json:"Max"`
+	Percentiles []Percentile `json:"Percentiles"`
+}
+
+// Percentile is a latency percentile of a fortio load test.
+type Percentile struct {
+	Percentile float64 `json:"Percentile"`
+	Value      float64 `json:"Value"`
+}
+
+// SkipUnlessEnabled skips the test unless load tests are enabled with -enable-load-tests.
+func SkipUnlessEnabled(t *testing.T, cfg *config.TestConfig) {
+	t.Helper()
+
+	if !cfg.EnableLoadTests {
+		t.Skip("skipping this test because -enable-load-tests is not set")
+	}
+}
+
+// WaitForTarget waits until the traffic generator can send a request to the URL,
+// e.g. until its sidecar has the configuration of the upstream.
+func WaitForTarget(t *testing.T, options *k8s.KubectlOptions, url string) {
+	t.Helper()
+
+	retrier := &retry.Timer{Timeout: 2 * time.Minute, Wait: 2 * time.Second}
+	retry.RunWith(retrier, t, func(r *retry.R) {
+		output, err := k8sHelpers.RunKubectlAndGetOutputE(t, options,
+			"exec", "deploy/"+TrafficGenerator, "-c", TrafficGenerator, "--", "fortio", "curl", url)
+		require.NoError(r, err, output)
+	})
+}
+
+// Run runs a fortio load test from the traffic generator deployed in the
+// namespace of the options and returns its result.
+func Run(t *testing.T, options *k8s.KubectlOptions, opts Options) *Result {
+	t.Helper()
+
+	connections := opts.Connections
+	if connections == 0 {
+		connections = 8
+	}
+	duration := opts.Duration
+	if duration == 0 {
+		duration = 30 * time.Second
+	}
+	qps := "0"
+	if opts.QPS > 0 {
+		qps = strconv.Itoa(opts.QPS)
+	}
+
+	logger.Logf(t, "running load test against %s for %s with %d connections", opts.URL, duration, connections)
+	output, err := k8sHelpers.RunKubectlAndGetOutputE(t, options,
+		"exec", "deploy/"+TrafficGenerator, "-c", TrafficGenerator, "--",
+		"fortio", "load", "-json", "-", "-p", "50,90,99,99.9",
+		"-qps", qps, "-c", strconv.Itoa(connections), "-t", duration.String(), opts.URL)
+	require.NoError(t, err, output)
+
+	result, err := ParseResult(output)
+	require.NoError(t, err)
+	return result
+}
+
+// ParseResult parses the result of fortio from its output. The output may
+// contain log lines before and after the result.
+func ParseResult(output string) (*Result, error) {
+	for i := strings.Index(output, "{"); i >= 0; {
+		var raw json.RawMessage
+		if err := json.NewDecoder(strings.NewReader(output[i:])).Decode(&raw); err == nil {
+			var result Result
+			if err := json.Unmarshal(raw, &result); err == nil && result.RunType != "" {
+				result.raw = raw
+				return &result, nil
+			}
+		}
+		next := strings.Index(output[i+1:], "{")
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return nil, errors.New("no fortio result in output")
+}
+
+// Percentile returns the latency of the percentile, e.g. 99 for the 99th percentile.
+// It returns false if fortio didn't record the percentile.
+func (r *Result) Percentile(p float64) (time.Duration, bool) {
+	for _, percentile := range r.DurationHistogram.Percentiles {
+		if percentile.Percentile == p {
+			return time.Duration(percentile.Value * float64(time.Second)), true
+		}
+	}
+	return 0, false
+}
+
+// Errors returns the number of requests that failed. HTTP requests fail unless
+// they return a 2xx status code and TCP requests fail unless they're OK.
+func (r *Result) Errors() int64 {
+	var errs int64
+	for code, count := range r.RetCodes {
+		if code == "OK" || (len(code) == 3 && code[0] == '2') {
+			continue
+		}
+		errs += count
+	}
+	return errs
+}
+
+// ErrorRate returns the fraction of requests that failed.
+func (r *Result) ErrorRate() float64 {
+	if r.DurationHistogram.Count == 0 {
+		return 0
+	}
+	return float64(r.Errors()) / float64(r.DurationHistogram.Count)
+}
+
+// Check returns an error if the result doesn't meet the budget.
+func (r *Result) Check(budget Budget) error {
+	if r.DurationHistogram.Count == 0 {
+		return errors.New("load test sent no requests")
+	}
+	var errs []string
+	if budget.P99 > 0 {
+		p99, ok := r.Percentile(99)
+		if !ok {
+			errs = append(errs, "p99 latency was not recorded")
+		} else if p99 > budget.P99 {
+			errs = append(errs, fmt.Sprintf("p99 latency %s exceeds budget of %s", p99, budget.P99))
+		}
+	}
+	if rate := r.ErrorRate(); rate > budget.MaxErrorRate {
+		errs = append(errs, fmt.Sprintf("error rate %.4f (%d of %d requests) exceeds budget of %.4f",
+			rate, r.Errors(), r.DurationHistogram.Count, budget.MaxErrorRate))
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Summary is the summary of a load test that is exported with its result.
+type Summary struct {
+	Name                 string  `json:"name"`
+	URL                  string  `json:"url"`
+	ConsulImage          string  `json:"consulImage,omitempty"`
+	ConsulK8SImage       string  `json:"consulK8sImage,omitempty"`
+	ConsulDataplaneImage string  `json:"consulDataplaneImage,omitempty"`
+	Requests             int64   `json:"requests"`
+	QPS                  float64 `json:"qps"`
+	P50                  string  `json:"p50"`
+	P90                  string  `json:"p90"`
+	P99                  string  `json:"p99"`
+	ErrorRate            float64 `json:"errorRate"`
+	Budget               Budget  `json:"budget"`
+	Passed               bool    `json:"passed"`
+}
+
+// Summarize returns the summary of the result with the images under test so
+// that results can be compared across releases.
+func (r *Result) Summarize(cfg *config.TestConfig, name string, budget Budget) Summary {
+	percentile := func(p float64) string {
+		if d, ok := r.Percentile(p); ok {
+			return d.String()
+		}
+		return ""
+	}
+	return Summary{
+		Name:                 name,
+		URL:                  r.URL,
+		ConsulImage:          cfg.ConsulImage,
+		ConsulK8SImage:       cfg.ConsulK8SImage,
+		ConsulDataplaneImage: cfg.ConsulDataplaneImage,
+		Requests:             r.DurationHistogram.Count,
+		QPS:                  r.ActualQPS,
+		P50:                  percentile(50),
+		P90:                  percentile(90),
+		P99:                  percentile(99),
+		ErrorRate:            r.ErrorRate(),
+		Budget:               budget,
+		Passed:               r.Check(budget) == nil,
+	}
+}
+
+// AssertBudget writes the result to the debug directory and fails the test if
+// it doesn't meet the budget. The summary is written to <name>.json and the
+// result of fortio to <name>-fortio.json in the load-tests/<test name>
+// directory so that CI can collect them as artifacts.
+func AssertBudget(t *testing.T, cfg *config.TestConfig, name string, result *Result, budget Budget) {
+	t.Helper()
+
+	summary := result.Summarize(cfg, name, budget)
+	path, err := WriteResult(cfg.DebugDirectory, t.Name(), summary, result)
+	require.NoError(t, err)
+	logger.Logf(t, "load test %s: %d requests at %.1f qps, p99 %s, error rate %.4f, results written to %s",
+		name, summary.Requests, summary.QPS, summary.P99, summary.ErrorRate, path)
+
+	require.NoError(t, result.Check(budget), "load test %s did not meet its budget", name)
+}
+
+// WriteResult writes the summary and the fortio result to the directory of the
+// test in the debug directory and returns the path of the summary.
+func WriteResult(debugDirectory, testName string, summary Summary, result *Result) (string, error) {
+	dir := filepath.Join(debugDirectory, resultsDirectory, regexp.MustCompile("[^A-Za-z0-9/_-]+").ReplaceAllString(testName, "_"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	summaryJSON, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, summary.Name+".json")
+	if err := os.WriteFile(path, summaryJSON, 0600); err != nil {
+		return "", err
+	}
+	if len(result.raw) > 0 {
+		if err := os.WriteFile(filepath.Join(dir, summary.Name+"-fortio.json"), result.raw, 0600); err != nil {
+			return "", err
+		}
+	}
+	return path, nil
+}
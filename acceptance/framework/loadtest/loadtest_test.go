@@ -0,0 +1,134 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package loadtest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/acceptance/framework/config"
+	"github.com/stretchr/testify/require"
+)
+
+const fortioOutput = `{"level":"info","msg":"Starting Φορτίο 1.54.2"}
+Fortio 1.54.2 running at 500 queries per second, 8->8 procs, for 1m0s: http://localhost:1234/
+{
+  "RunType": "HTTP",
+  "Labels": "",
+  "ActualQPS": 499.8,
+  "NumThreads": 8,
+  "DurationHistogram": {
+    "Count": 2000,
+    "Avg": 0.0021,
+    "Max": 0.09,
+    "Percentiles": [
+      {"Percentile": 50, "Value": 0.0015},
+      {"Percentile": 90, "Value": 0.004},
+      {"Percentile": 99, "Value": 0.012}
+    ]
+  },
+  "RetCodes": {"200": 1998, "503": 2},
+  "URL": "http://localhost:1234/"
+}
+{"level":"info","msg":"All done 2000 calls"}
+`
+
+func TestParseResult(t *testing.T) {
+	result, err := ParseResult(fortioOutput)
+	require.NoError(t, err)
+	require.Equal(t, "HTTP", result.RunType)
+	require.Equal(t, "http://localhost:1234/", result.URL)
+	require.Equal(t, int64(2000), result.DurationHistogram.Count)
+	require.Equal(t, int64(2), result.Errors())
+	require.Equal(t, 0.001, result.ErrorRate())
+
+	p99, ok := result.Percentile(99)
+	require.True(t, ok)
+	require.Equal(t, 12*time.Millisecond, p99)
+	_, ok = result.Percentile(99.9)
+	require.False(t, ok)
+
+	_, err = ParseResult(`{"level":"error","msg":"unable to connect"}`)
+	require.EqualError(t, err, "no fortio result in output")
+}
+
+func TestResult_Check(t *testing.T) {
+	result, err := ParseResult(fortioOutput)
+	require.NoError(t, err)
+
+	cases := map[string]struct {
+		budget Budget
+		expErr string
+	}{
+		"within budget": {
+			budget: Budget{P99: 20 * time.Millisecond, MaxErrorRate: 0.001},
+		},
+		"latency not checked": {
+			budget: Budget{MaxErrorRate: 0.01},
+		},
+		"p99 over budget": {
+			budget: Budget{P99: 10 * time.Millisecond, MaxErrorRate: 0.01},
+			expErr: "p99 latency 12ms exceeds budget of 10ms",
+		},
+		"error rate over budget": {
+			budget: Budget{P99: 20 * time.Millisecond},
+			expErr: "error rate 0.0010 (2 of 2000 requests) exceeds budget of 0.0000",
+		},
+		"both over budget": {
+			budget: Budget{P99: time.Millisecond},
+			expErr: "p99 latency 12ms exceeds budget of 1ms; error rate 0.0010 (2 of 2000 requests) exceeds budget of 0.0000",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := result.Check(c.budget)
+			if c.expErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, c.expErr)
+			}
+		})
+	}
+
+	require.EqualError(t, (&Result{}).Check(Budget{}), "load test sent no requests")
+}
+
+func TestWriteResult(t *testing.T) {
+	result, err := ParseResult(fortioOutput)
+	require.NoError(t, err)
+	cfg := &config.TestConfig{ConsulDataplaneImage: "hashicorp/consul-dataplane:1.2.0"}
+	budget := Budget{P99: 20 * time.Millisecond, MaxErrorRate: 0.001}
+
+	dir := t.TempDir()
+	path, err := WriteResult(dir, "TestConnectInject_Load/fixed rate", result.Summarize(cfg, "sidecar", budget), result)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "load-tests", "TestConnectInject_Load/fixed_rate", "sidecar.json"), path)
+
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var summary Summary
+	require.NoError(t, json.Unmarshal(contents, &summary))
+	require.Equal(t, Summary{
+		Name:                 "sidecar",
+		URL:                  "http://localhost:1234/",
+		ConsulDataplaneImage: "hashicorp/consul-dataplane:1.2.0",
+		Requests:             2000,
+		QPS:                  499.8,
+		P50:                  "1.5ms",
+		P90:                  "4ms",
+		P99:                  "12ms",
+		ErrorRate:            0.001,
+		Budget:               budget,
+		Passed:               true,
+	}, summary)
+
+	raw, err := os.ReadFile(filepath.Join(filepath.Dir(path), "sidecar-fortio.json"))
+	require.NoError(t, err)
+	var fortioResult Result
+	require.NoError(t, json.Unmarshal(raw, &fortioResult))
+	require.Equal(t, "HTTP", fortioResult.RunType)
+}
@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package connect
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	terratestk8s "github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/connhelper"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/consul"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/logger"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+const restrictedNamespace = "restricted"
+
+// Test that Connect works in a namespace that enforces the restricted Pod Security Standard
+// when global.restrictedPodSecurity is set. The pods are only admitted if the injected
+// containers comply with the standard.
+func TestConnectInject_RestrictedPodSecurity(t *testing.T) {
+	cfg := suite.Config()
+	if !cfg.EnableCNI || !cfg.EnableTransparentProxy {
+		t.Skipf("skipping this test because -enable-cni and -enable-transparent-proxy are not set")
+	}
+	// The pod-security.kubernetes.io labels are enforced by the Pod Security admission controller,
+	// which is stable and enabled by default since Kubernetes 1.25.
+	k8s.SkipUnlessKubernetesVersion(t, cfg, suite.Environment().DefaultContext(t), ">= 1.25")
+
+	for _, secure := range []bool{false, true} {
+		name := fmt.Sprintf("secure: %t", secure)
+		t.Run(name, func(t *testing.T) {
+			ctx := suite.Environment().DefaultContext(t)
+
+			helmValues := map[string]string{
+				"connectInject.enabled":        "true",
+				"connectInject.cni.enabled":    "true",
+				"global.restrictedPodSecurity": "true",
+				"global.tls.enabled":           strconv.FormatBool(secure),
+				"global.acls.manageSystemACLs": strconv.FormatBool(secure),
+			}
+
+			releaseName := helpers.RandomName()
+			consulCluster := consul.NewHelmCluster(t, helmValues, ctx, cfg, releaseName)
+			consulCluster.Create(t)
+			consulClient, _ := consulCluster.SetupConsulClient(t, secure)
+
+			logger.Logf(t, "creating namespace %s that enforces the restricted Pod Security Standard", restrictedNamespace)
+			k8s.RunKubectl(t, ctx.KubectlOptions(t), "create", "ns", restrictedNamespace)
+			helpers.Cleanup(t, cfg.NoCleanupOnFailure, func() {
+				k8s.RunKubectl(t, ctx.KubectlOptions(t), "delete", "ns", restrictedNamespace)
+			})
+			k8s.RunKubectl(t, ctx.KubectlOptions(t), "label", "ns", restrictedNamespace,
+				"pod-security.kubernetes.io/enforce=restricted",
+				"pod-security.kubernetes.io/enforce-version=latest")
+
+			appOpts := &terratestk8s.KubectlOptions{
+				ContextName: ctx.KubectlOptions(t).ContextName,
+				ConfigPath:  ctx.KubectlOptions(t).ConfigPath,
+				Namespace:   restrictedNamespace,
+			}
+
+			logger.Log(t, "creating static-server and static-client deployments")
+			k8s.DeployKustomize(t, appOpts, cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/cases/static-server-restricted")
+			k8s.DeployKustomize(t, appOpts, cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/cases/static-client-restricted")
+
+			if secure {
+				logger.Log(t, "checking that the connection is not successful because there's no intention")
+				k8s.CheckStaticServerConnectionFailing(t, appOpts, connhelper.StaticClientName, "http://static-server")
+
+				logger.Log(t, "creating intention")
+				_, _, err := consulClient.ConfigEntries().Set(&api.ServiceIntentionsConfigEntry{
+					Kind: api.ServiceIntentions,
+					Name: connhelper.StaticServerName,
+					Sources: []*api.SourceIntention{
+						{
+							Name:   connhelper.StaticClientName,
+							Action: api.IntentionActionAllow,
+						},
+					},
+				}, nil)
+				require.NoError(t, err)
+			}
+
+			logger.Log(t, "checking that connection is successful")
+			k8s.CheckStaticServerConnectionSuccessful(t, appOpts, connhelper.StaticClientName, "http://static-server")
+		})
+	}
+}
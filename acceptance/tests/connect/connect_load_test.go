@@ -0,0 +1,60 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package connect
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/acceptance/framework/connhelper"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/consul"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/helpers"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/k8s"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/loadtest"
+	"github.com/hashicorp/consul-k8s/acceptance/framework/logger"
+)
+
+// TestConnectInject_Load tests the latency and errors of requests between two sidecars under load.
+func TestConnectInject_Load(t *testing.T) {
+	cfg := suite.Config()
+	loadtest.SkipUnlessEnabled(t, cfg)
+	if cfg.EnableTransparentProxy {
+		t.Skip("skipping this test because it sends requests through an explicit upstream")
+	}
+	ctx := suite.Environment().DefaultContext(t)
+
+	connHelper := connhelper.ConnectHelper{
+		ClusterKind: consul.Helm,
+		ReleaseName: helpers.RandomName(),
+		Ctx:         ctx,
+		Cfg:         cfg,
+	}
+	connHelper.Setup(t)
+	connHelper.Install(t)
+
+	logger.Log(t, "creating static-server and fortio-client deployments")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/cases/static-server-inject")
+	k8s.DeployKustomize(t, ctx.KubectlOptions(t), cfg.NoCleanupOnFailure, cfg.DebugDirectory, "../fixtures/cases/fortio-client-inject")
+	loadtest.WaitForTarget(t, ctx.KubectlOptions(t), "http://localhost:1234/")
+
+	cases := map[string]struct {
+		options loadtest.Options
+		budget  loadtest.Budget
+	}{
+		"fixed-rate": {
+			options: loadtest.Options{URL: "http://localhost:1234/", QPS: 500, Connections: 8, Duration: time.Minute},
+			budget:  loadtest.Budget{P99: 50 * time.Millisecond, MaxErrorRate: 0.001},
+		},
+		"max-rate": {
+			options: loadtest.Options{URL: "http://localhost:1234/", Connections: 32, Duration: time.Minute},
+			budget:  loadtest.Budget{MaxErrorRate: 0.001},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			result := loadtest.Run(t, ctx.KubectlOptions(t), c.options)
+			loadtest.AssertBudget(t, cfg, "sidecar-"+name, result, c.budget)
+		})
+	}
+}
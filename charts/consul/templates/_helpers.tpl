@@ -306,6 +306,8 @@ Consul server environment variables for consul-k8s commands.
   value: {{ .Values.global.datacenter }}
 - name: CONSUL_API_TIMEOUT
   value: {{ .Values.global.consulAPITimeout }}
+- name: CONSUL_ALLOW_STALE
+  value: {{ .Values.global.consulAPIAllowStale | quote }}
 {{- if .Values.global.adminPartitions.enabled }}
 - name: CONSUL_PARTITION
   value: {{ .Values.global.adminPartitions.name }}
@@ -339,6 +341,66 @@ Consul server environment variables for consul-k8s commands.
 {{- end }}
 {{- end -}}
 
+{{/*
+Fails when externalServers.hardened is true and the installation doesn't only run dataplane
+components that connect to the external servers with TLS and ACLs, i.e. when:
+- externalServers.enabled is false
+- Consul servers or clients are enabled
+- global.tls.enabled or global.acls.manageSystemACLs is false
+- apiGateway.enabled is true, because the API gateway controller requires Consul clients
+
+Usage: {{ template "consul.validateExternalServersHardened" . }}
+
+*/}}
+{{- define "consul.validateExternalServersHardened" -}}
+{{- if .Values.externalServers.hardened }}
+{{- if not .Values.externalServers.enabled }}{{ fail "externalServers.enabled must be true if externalServers.hardened is true" }}{{ end }}
+{{- if (or (and (ne (.Values.server.enabled | toString) "-") .Values.server.enabled) (and (eq (.Values.server.enabled | toString) "-") .Values.global.enabled)) }}{{ fail "server.enabled must be false if externalServers.hardened is true" }}{{ end }}
+{{- if (or (and (ne (.Values.client.enabled | toString) "-") .Values.client.enabled) (and (eq (.Values.client.enabled | toString) "-") .Values.global.enabled)) }}{{ fail "client.enabled must be false if externalServers.hardened is true" }}{{ end }}
+{{- if not .Values.global.tls.enabled }}{{ fail "global.tls.enabled must be true if externalServers.hardened is true" }}{{ end }}
+{{- if not .Values.global.acls.manageSystemACLs }}{{ fail "global.acls.manageSystemACLs must be true if externalServers.hardened is true" }}{{ end }}
+{{- if .Values.apiGateway.enabled }}{{ fail "apiGateway.enabled must be false if externalServers.hardened is true" }}{{ end }}
+{{- end }}
+{{- end -}}
+
+{{/*
+Renders the fields of a container security context that complies with the "restricted" Pod Security Standard.
+
+Usage: {{ include "consul.restrictedSecurityContext" . | nindent 12 }}
+
+*/}}
+{{- define "consul.restrictedSecurityContext" -}}
+allowPrivilegeEscalation: false
+capabilities:
+  drop:
+  - ALL
+runAsNonRoot: true
+seccompProfile:
+  type: RuntimeDefault
+{{- end -}}
+
+{{/*
+Fails if global.fips is true and any of the following images is not a FIPS variant, i.e. doesn't
+contain "fips" in its name or tag.
+- global.image
+- global.imageK8S
+- global.imageConsulDataplane
+- connectInject.image
+- connectInject.imageConsul
+
+Usage: {{ template "consul.validateFIPSImages" . }}
+
+*/}}
+{{- define "consul.validateFIPSImages" -}}
+{{- if .Values.global.fips }}
+{{- if not (contains "fips" .Values.global.image) }}{{ fail "global.image must be a FIPS variant if global.fips is true" }}{{ end }}
+{{- if not (contains "fips" .Values.global.imageK8S) }}{{ fail "global.imageK8S must be a FIPS variant if global.fips is true" }}{{ end }}
+{{- if not (contains "fips" .Values.global.imageConsulDataplane) }}{{ fail "global.imageConsulDataplane must be a FIPS variant if global.fips is true" }}{{ end }}
+{{- if (and .Values.connectInject.image (not (contains "fips" .Values.connectInject.image))) }}{{ fail "connectInject.image must be a FIPS variant if global.fips is true" }}{{ end }}
+{{- if (and .Values.connectInject.imageConsul (not (contains "fips" .Values.connectInject.imageConsul))) }}{{ fail "connectInject.imageConsul must be a FIPS variant if global.fips is true" }}{{ end }}
+{{- end }}
+{{- end -}}
+
 {{/*
 Fails global.cloud.enabled is true and one of the following secrets is nil or empty.
 - global.cloud.resourceId.secretName
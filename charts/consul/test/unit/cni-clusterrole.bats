@@ -52,3 +52,13 @@ load _helpers
       .
 }
 
+@test "cni/ClusterRole: sets get and patch access to nodes" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/cni-clusterrole.yaml  \
+      --set 'connectInject.cni.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r -c '.rules[] | select(.resources[0] == "nodes") | .verbs' | tee /dev/stderr)
+  [[ "${actual}" == '["get","patch"]' ]]
+}
@@ -92,6 +92,53 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+#--------------------------------------------------------------------
+# chainedPluginType
+
+@test "cni/DaemonSet: chained plugin type is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/cni-daemonset.yaml  \
+      --set 'connectInject.cni.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("chained-plugin-type"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "cni/DaemonSet: chained plugin type can be set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/cni-daemonset.yaml  \
+      --set 'connectInject.cni.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.cni.chainedPluginType=cilium-cni' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-chained-plugin-type=cilium-cni"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# node-name
+
+@test "cni/DaemonSet: node name is passed so that the node is labeled with the consul-cni version" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/cni-daemonset.yaml  \
+      --set 'connectInject.cni.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0]' | tee /dev/stderr)
+
+  local actual=$(echo "$object" |
+    yq '.command | any(contains("-node-name=$(NODE_NAME)"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  actual=$(echo "$object" |
+    yq -r '.env[] | select(.name == "NODE_NAME") | .valueFrom.fieldRef.fieldPath' | tee /dev/stderr)
+  [ "${actual}" = "spec.nodeName" ]
+}
+
 #--------------------------------------------------------------------
 # updateStrategy
 
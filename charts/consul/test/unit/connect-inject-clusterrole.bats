@@ -69,7 +69,7 @@ load _helpers
   [ "${actual}" != null ]
 }
 
-@test "connectInject/ClusterRole: sets get, list, watch and update access to pods in all api groups" {
+@test "connectInject/ClusterRole: sets get, list, watch, update and patch access to pods in all api groups" {
   cd `chart_dir`
   local object=$(helm template \
       -s templates/connect-inject-clusterrole.yaml  \
@@ -96,6 +96,9 @@ load _helpers
 
   local actual=$(echo $object | yq -r '.verbs | index("update")' | tee /dev/stderr)
   [ "${actual}" != null ]
+
+  local actual=$(echo $object | yq -r '.verbs | index("patch")' | tee /dev/stderr)
+  [ "${actual}" != null ]
 }
 
 @test "connectInject/ClusterRole: sets create, get, list, and update access to leases in the coordination.k8s.io api group" {
@@ -151,6 +154,150 @@ load _helpers
   [ "${actual}" != null ]
 }
 
+@test "connectInject/ClusterRole: sets create and patch access to events by default" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r -c '.rules[] | select(.resources[0] == "events")' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.verbs | index("create")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $object | yq -r '.verbs | index("patch")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+}
+
+@test "connectInject/ClusterRole: sets create and patch access to events when connectInject.cni.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.cni.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r -c '.rules[] | select(.resources[0] == "events")' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.verbs | index("create")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $object | yq -r '.verbs | index("patch")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+}
+
+@test "connectInject/ClusterRole: does not set access to pods/status by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "pods/status")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/ClusterRole: sets patch access to pods/status when connectInject.meshReadyCondition.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.meshReadyCondition.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r -c '.rules[] | select(.resources[0] == "pods/status")' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.verbs | index("patch")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+}
+
+#--------------------------------------------------------------------
+# argoRollouts
+
+@test "connectInject/ClusterRole: does not set access to rollouts by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "rollouts")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/ClusterRole: sets read access to rollouts when connectInject.argoRollouts.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.argoRollouts.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r -c '.rules[] | select(.resources[0] == "rollouts")' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.apiGroups[0]' | tee /dev/stderr)
+  [ "${actual}" = "argoproj.io" ]
+
+  local actual=$(echo $object | yq -r '.verbs | index("watch")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+}
+
+#--------------------------------------------------------------------
+# intentionsNetworkPolicies
+
+@test "connectInject/ClusterRole: does not set access to networkpolicies by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "networkpolicies")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/ClusterRole: sets access to networkpolicies when connectInject.intentionsNetworkPolicies.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.intentionsNetworkPolicies.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r -c '.rules[] | select(.resources[0] == "networkpolicies")' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.apiGroups[0]' | tee /dev/stderr)
+  [ "${actual}" = "networking.k8s.io" ]
+
+  local actual=$(echo $object | yq -r '.verbs | index("create")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $object | yq -r '.verbs | index("delete")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+}
+
+#--------------------------------------------------------------------
+# global.metrics.podMonitors
+
+@test "connectInject/ClusterRole: does not set access to podmonitors by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "podmonitors")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/ClusterRole: sets access to podmonitors when global.metrics.podMonitors.enabled=true" {
+  cd `chart_dir`
+  local rules=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.podMonitors.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r -c '.rules' | tee /dev/stderr)
+
+  local actual=$(echo $rules | yq -r '.[] | select(.resources[0] == "podmonitors") | .verbs | index("create")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $rules | yq -r '.[] | select(.resources[0] == "deployments") | .verbs | index("get")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+}
+
 #--------------------------------------------------------------------
 # global.enablePodSecurityPolicies
 
@@ -217,3 +364,111 @@ load _helpers
   local actual=$(echo $object | yq -r '.verbs | index("watch")' | tee /dev/stderr)
   [ "${actual}" != null ]
 }
+
+#--------------------------------------------------------------------
+# rolloutRestarts
+
+@test "connectInject/ClusterRole: no statefulsets access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '[.rules[] | select(.resources[0] == "statefulsets")] | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/ClusterRole: allows statefulsets and configmaps access with connectInject.rolloutRestarts.enabled=true" {
+  cd `chart_dir`
+  local rules=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.rolloutRestarts.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules' | tee /dev/stderr)
+
+  local actual=$(echo $rules | yq -r '.[] | select(.resources[0] == "statefulsets") | .verbs | index("update")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  actual=$(echo $rules | yq -r '.[] | select(.resources[0] == "configmaps") | .verbs | index("create")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+}
+
+#--------------------------------------------------------------------
+# namespaceUpstreams
+
+@test "connectInject/ClusterRole: allows configmaps get access with connectInject.namespaceUpstreams.configMapName" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.namespaceUpstreams.configMapName=consul-upstreams' \
+      . | tee /dev/stderr |
+      yq -r '.rules[] | select(.resources[0] == "configmaps") | .verbs | index("get")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+}
+
+#--------------------------------------------------------------------
+# licenseController
+
+@test "connectInject/ClusterRole: allows events access with connectInject.licenseController.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.licenseController.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules[] | select(.resources[0] == "events") | .verbs | index("create")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+}
+
+#--------------------------------------------------------------------
+# ui.expose
+
+@test "connectInject/ClusterRole: does not set access to ingresses or routes when ui.expose.type is set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'ui.expose.type=ingress' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "ingresses" or .resources[0] == "routes")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+#--------------------------------------------------------------------
+# connectInject.authMethod
+
+@test "connectInject/ClusterRole: sets access to create reviewer tokens when connectInject.authMethod.reviewerTokenRotation.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'connectInject.authMethod.reviewerTokenRotation.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r -c '.rules[] | select(.resources[0] == "serviceaccounts/token")' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.resourceNames[0]' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-auth-method" ]
+
+  local actual=$(echo $object | yq -r '.verbs[0]' | tee /dev/stderr)
+  [ "${actual}" = "create" ]
+}
+
+@test "connectInject/ClusterRole: sets access to create token reviews when connectInject.authMethod.tokenReviewCache.enabled=true" {
+  cd `chart_dir`
+  local rules=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'connectInject.authMethod.tokenReviewCache.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r -c '.rules' | tee /dev/stderr)
+
+  local actual=$(echo $rules | yq -r '.[] | select(.resources[0] == "tokenreviews") | .verbs[0]' | tee /dev/stderr)
+  [ "${actual}" = "create" ]
+
+  local actual=$(echo $rules | yq -r '.[] | select(.resources[0] == "mutatingwebhookconfigurations" and .resourceNames != null) | .resourceNames[0]' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-connect-injector" ]
+}
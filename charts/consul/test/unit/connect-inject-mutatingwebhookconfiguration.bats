@@ -72,3 +72,78 @@ load _helpers
       yq '.webhooks[13].name | contains("peeringdialers.consul.hashicorp.com")' | tee /dev/stderr)
   [ "${actual}" = "true" ]
 }
+
+#--------------------------------------------------------------------
+# debugContainers
+
+@test "connectInject/MutatingWebhookConfiguration: ephemeral containers are not mutated by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-mutatingwebhookconfiguration.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '[.webhooks[].rules[].resources[] | select(. == "pods/ephemeralcontainers")] | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/MutatingWebhookConfiguration: ephemeral containers are mutated when connectInject.debugContainers.bypassMesh=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-mutatingwebhookconfiguration.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.debugContainers.bypassMesh=true' \
+      . | tee /dev/stderr |
+      yq -r '.webhooks[] | select(.clientConfig.service.path == "/mutate") | .rules[] | select(.resources[0] == "pods/ephemeralcontainers") | .operations[0]' | tee /dev/stderr)
+  [ "${actual}" = "UPDATE" ]
+}
+
+#--------------------------------------------------------------------
+# server.extraConfigValidation
+
+@test "connectInject/MutatingWebhookConfiguration: server config is not validated by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-mutatingwebhookconfiguration.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '[.webhooks[] | select(.clientConfig.service.path == "/validate-server-config")] | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/MutatingWebhookConfiguration: server config is validated when server.extraConfigValidation.enabled=true" {
+  cd `chart_dir`
+  local webhook=$(helm template \
+      -s templates/connect-inject-mutatingwebhookconfiguration.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'server.extraConfigValidation.enabled=true' \
+      --namespace foo \
+      . | tee /dev/stderr |
+      yq '.webhooks[] | select(.clientConfig.service.path == "/validate-server-config")' | tee /dev/stderr)
+
+  local actual=$(echo "$webhook" | yq -r '.failurePolicy' | tee /dev/stderr)
+  [ "${actual}" = "Ignore" ]
+
+  actual=$(echo "$webhook" | yq -r '.objectSelector.matchLabels.component' | tee /dev/stderr)
+  [ "${actual}" = "server" ]
+
+  actual=$(echo "$webhook" | yq -r '.objectSelector.matchLabels.release' | tee /dev/stderr)
+  [ "${actual}" = "release-name" ]
+
+  actual=$(echo "$webhook" | yq -r '.namespaceSelector.matchLabels["kubernetes.io/metadata.name"]' | tee /dev/stderr)
+  [ "${actual}" = "foo" ]
+
+  actual=$(echo "$webhook" | yq -r '.rules[0].resources[0]' | tee /dev/stderr)
+  [ "${actual}" = "configmaps" ]
+}
+
+@test "connectInject/MutatingWebhookConfiguration: server config is not validated when servers are disabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-mutatingwebhookconfiguration.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'server.enabled=false' \
+      --set 'server.extraConfigValidation.enabled=true' \
+      . | tee /dev/stderr |
+      yq '[.webhooks[] | select(.clientConfig.service.path == "/validate-server-config")] | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
@@ -0,0 +1,42 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "connectInject/UIExposureRole: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/connect-inject-ui-exposure-role.yaml  \
+      .
+}
+
+@test "connectInject/UIExposureRole: disabled with connectInject disabled" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/connect-inject-ui-exposure-role.yaml  \
+      --set 'connectInject.enabled=false' \
+      --set 'ui.expose.type=ingress' \
+      .
+}
+
+@test "connectInject/UIExposureRole: sets access to ingresses and routes in the release namespace when ui.expose.type is set" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-ui-exposure-role.yaml  \
+      --namespace 'consul' \
+      --set 'connectInject.enabled=true' \
+      --set 'ui.expose.type=ingress' \
+      . | tee /dev/stderr |
+      yq -r -c '.' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.metadata.namespace' | tee /dev/stderr)
+  [ "${actual}" = "consul" ]
+
+  local actual=$(echo $object | yq -r '.rules[] | select(.resources[0] == "ingresses") | .apiGroups[0]' | tee /dev/stderr)
+  [ "${actual}" = "networking.k8s.io" ]
+
+  local actual=$(echo $object | yq -r '.rules[] | select(.resources[0] == "routes") | .apiGroups[0]' | tee /dev/stderr)
+  [ "${actual}" = "route.openshift.io" ]
+
+  local actual=$(echo $object | yq -r '.rules[] | select(.resources[0] == "ingresses") | .verbs | index("delete")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+}
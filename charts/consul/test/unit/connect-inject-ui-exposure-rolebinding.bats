@@ -0,0 +1,26 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "connectInject/UIExposureRoleBinding: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/connect-inject-ui-exposure-rolebinding.yaml  \
+      .
+}
+
+@test "connectInject/UIExposureRoleBinding: binds the connect injector service account when ui.expose.type is set" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-ui-exposure-rolebinding.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'ui.expose.type=ingress' \
+      . | tee /dev/stderr |
+      yq -r -c '.' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.roleRef.name' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-connect-inject-ui-exposure" ]
+
+  local actual=$(echo $object | yq -r '.subjects[0].name' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-connect-injector" ]
+}
@@ -418,3 +418,35 @@ load _helpers
   [ "${actualTemplateFoo}" = "bar" ]
   [ "${actualTemplateBaz}" = "qux" ]
 }
+
+#--------------------------------------------------------------------
+# global.federation.secondaryKubeconfigSecret
+
+@test "createFederationSecret/Job: -secondary-kubeconfig-secret is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/create-federation-secret-job.yaml  \
+      --set 'global.federation.enabled=true' \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.federation.createFederationSecret=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-secondary-kubeconfig-secret"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "createFederationSecret/Job: -secondary-kubeconfig-secret is set when global.federation.secondaryKubeconfigSecret is set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/create-federation-secret-job.yaml  \
+      --set 'global.federation.enabled=true' \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.federation.createFederationSecret=true' \
+      --set 'global.federation.secondaryKubeconfigSecret=secondary-kubeconfigs' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-secondary-kubeconfig-secret=secondary-kubeconfigs"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
@@ -42,6 +42,45 @@ load _helpers
   [ "${actual}" = "1" ]
 }
 
+#--------------------------------------------------------------------
+# global.federation.secondaryKubeconfigSecret
+
+@test "createFederationSecret/Role: allows reading the secondary kubeconfig secret when global.federation.secondaryKubeconfigSecret is set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/create-federation-secret-role.yaml  \
+      --set 'global.federation.createFederationSecret=true' \
+      --set 'global.federation.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.federation.secondaryKubeconfigSecret=secondary-kubeconfigs' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resourceNames[0] == "secondary-kubeconfigs")) | length' | tee /dev/stderr)
+  [ "${actual}" = "1" ]
+}
+
+#--------------------------------------------------------------------
+# global.acls.replicationTokenRotationPeriod
+
+@test "createFederationSecret/Role: allows updating the replication token secret when global.acls.replicationTokenRotationPeriod is set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/create-federation-secret-role.yaml  \
+      --set 'global.federation.createFederationSecret=true' \
+      --set 'global.federation.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.createReplicationToken=true' \
+      --set 'global.acls.replicationTokenRotationPeriod=720h' \
+      --set 'global.federation.secondaryKubeconfigSecret=secondary-kubeconfigs' \
+      . | tee /dev/stderr |
+      yq -r '[.rules[] | select(.resourceNames[0] == "release-name-consul-acl-replication-acl-token") | .verbs[]] | sort | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "get,update" ]
+}
+
 #--------------------------------------------------------------------
 # global.enablePodSecurityPolicies
 
@@ -0,0 +1,36 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+target=templates/dns-coredns-cleanup-job.yaml
+
+@test "dnsCoreDNSCleanup/Job: disabled by default" {
+    cd `chart_dir`
+    assert_empty helm template \
+        -s $target \
+        .
+}
+
+@test "dnsCoreDNSCleanup/Job: enabled with dns.coreDNS.enabled=true" {
+    cd `chart_dir`
+    local actual=$(helm template \
+        -s $target \
+        --set 'dns.coreDNS.enabled=true' \
+        . | tee /dev/stderr |
+        yq 'length > 0' | tee /dev/stderr)
+    [ "$actual" = "true" ]
+}
+
+@test "dnsCoreDNSCleanup/Job: runs before delete with -cleanup" {
+    cd `chart_dir`
+    local object=$(helm template \
+        -s $target \
+        --set 'dns.coreDNS.enabled=true' \
+        . | tee /dev/stderr)
+
+    local actual=$(echo "$object" | yq -r '.metadata.annotations."helm.sh/hook"' | tee /dev/stderr)
+    [ "${actual}" = "pre-delete" ]
+
+    actual=$(echo "$object" | yq -r '.spec.template.spec.containers[0].args | index("-cleanup")' | tee /dev/stderr)
+    [ "${actual}" != null ]
+}
@@ -0,0 +1,38 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+target=templates/dns-coredns-config-clusterrole.yaml
+
+@test "dnsCoreDNSConfig/ClusterRole: disabled by default" {
+    cd `chart_dir`
+    assert_empty helm template \
+        -s $target \
+        .
+}
+
+@test "dnsCoreDNSConfig/ClusterRole: enabled with dns.coreDNS.enabled=true" {
+    cd `chart_dir`
+    local actual=$(helm template \
+        -s $target \
+        --set 'dns.coreDNS.enabled=true' \
+        . | tee /dev/stderr |
+        yq 'length > 0' | tee /dev/stderr)
+    [ "$actual" = "true" ]
+}
+
+@test "dnsCoreDNSConfig/ClusterRole: can update the configured CoreDNS ConfigMap" {
+    cd `chart_dir`
+    local object=$(helm template \
+        -s $target \
+        --set 'dns.coreDNS.enabled=true' \
+        --set 'dns.coreDNS.configMapName=custom-coredns' \
+        . | tee /dev/stderr |
+        yq -r '.rules[0]' | tee /dev/stderr)
+
+    local actual=$(echo $object | yq -r '.resourceNames[0]' | tee /dev/stderr)
+    [ "${actual}" = "custom-coredns" ]
+
+    actual=$(echo $object | yq -r '.verbs | index("update")' | tee /dev/stderr)
+    [ "${actual}" != null ]
+}
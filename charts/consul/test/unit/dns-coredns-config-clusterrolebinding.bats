@@ -0,0 +1,22 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+target=templates/dns-coredns-config-clusterrolebinding.yaml
+
+@test "dnsCoreDNSConfig/ClusterRoleBinding: disabled by default" {
+    cd `chart_dir`
+    assert_empty helm template \
+        -s $target \
+        .
+}
+
+@test "dnsCoreDNSConfig/ClusterRoleBinding: enabled with dns.coreDNS.enabled=true" {
+    cd `chart_dir`
+    local actual=$(helm template \
+        -s $target \
+        --set 'dns.coreDNS.enabled=true' \
+        . | tee /dev/stderr |
+        yq 'length > 0' | tee /dev/stderr)
+    [ "$actual" = "true" ]
+}
@@ -0,0 +1,71 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+target=templates/dns-coredns-config-job.yaml
+
+@test "dnsCoreDNSConfig/Job: disabled by default" {
+    cd `chart_dir`
+    assert_empty helm template \
+        -s $target \
+        .
+}
+
+@test "dnsCoreDNSConfig/Job: enabled with dns.coreDNS.enabled=true" {
+    cd `chart_dir`
+    local actual=$(helm template \
+        -s $target \
+        --set 'dns.coreDNS.enabled=true' \
+        . | tee /dev/stderr |
+        yq 'length > 0' | tee /dev/stderr)
+    [ "$actual" = "true" ]
+}
+
+@test "dnsCoreDNSConfig/Job: fails if the DNS service is disabled" {
+    cd `chart_dir`
+    run helm template \
+        -s $target \
+        --set 'dns.coreDNS.enabled=true' \
+        --set 'dns.enabled=false' \
+        .
+    [ "$status" -eq 1 ]
+    [[ "$output" =~ "dns.enabled must be true when dns.coreDNS.enabled is true" ]]
+}
+
+@test "dnsCoreDNSConfig/Job: runs after install and upgrade" {
+    cd `chart_dir`
+    local actual=$(helm template \
+        -s $target \
+        --set 'dns.coreDNS.enabled=true' \
+        . | tee /dev/stderr |
+        yq -r '.metadata.annotations."helm.sh/hook"' | tee /dev/stderr)
+    [ "${actual}" = "post-install,post-upgrade" ]
+}
+
+@test "dnsCoreDNSConfig/Job: sets the DNS service, domain and CoreDNS ConfigMap" {
+    cd `chart_dir`
+    local object=$(helm template \
+        -s $target \
+        --set 'dns.coreDNS.enabled=true' \
+        --set 'dns.coreDNS.configMapName=custom-coredns' \
+        --set 'dns.coreDNS.configMapNamespace=dns' \
+        --set 'global.domain=example' \
+        --namespace foo \
+        . | tee /dev/stderr |
+        yq -r '.spec.template.spec.containers[0].args' | tee /dev/stderr)
+
+    local actual=$(echo $object | yq -r '. | index("-dns-service-name=release-name-consul-dns")' | tee /dev/stderr)
+    [ "${actual}" != null ]
+
+    actual=$(echo $object | yq -r '. | index("-dns-service-namespace=foo")' | tee /dev/stderr)
+    [ "${actual}" != null ]
+
+    actual=$(echo $object | yq -r '. | index("-domain=example")' | tee /dev/stderr)
+    [ "${actual}" != null ]
+
+    actual=$(echo $object | yq -r '. | index("-coredns-configmap-name=custom-coredns")' | tee /dev/stderr)
+    [ "${actual}" != null ]
+
+    actual=$(echo $object | yq -r '. | index("-coredns-configmap-namespace=dns")' | tee /dev/stderr)
+    [ "${actual}" != null ]
+}
@@ -0,0 +1,22 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+target=templates/dns-coredns-config-serviceaccount.yaml
+
+@test "dnsCoreDNSConfig/ServiceAccount: disabled by default" {
+    cd `chart_dir`
+    assert_empty helm template \
+        -s $target \
+        .
+}
+
+@test "dnsCoreDNSConfig/ServiceAccount: enabled with dns.coreDNS.enabled=true" {
+    cd `chart_dir`
+    local actual=$(helm template \
+        -s $target \
+        --set 'dns.coreDNS.enabled=true' \
+        . | tee /dev/stderr |
+        yq 'length > 0' | tee /dev/stderr)
+    [ "$actual" = "true" ]
+}
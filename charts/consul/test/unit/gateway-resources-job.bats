@@ -116,3 +116,68 @@ target=templates/gateway-resources-job.yaml
   local actual=$(echo "$spec" | jq '.[14]')
   [ "${actual}" = "\"-service-annotations=- bingo\"" ]
 }
+
+@test "gatewayresources/Job: scheduling settings can be set" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s $target  \
+      --set 'connectInject.apiGateway.managedGatewayClass.topologySpreadConstraints=- maxSkew: 1' \
+      --set 'connectInject.apiGateway.managedGatewayClass.affinity=nodeAffinity: {}' \
+      --set 'connectInject.apiGateway.managedGatewayClass.priorityClassName=gateway-critical' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].args' | tee /dev/stderr)
+
+  local actual=$(echo "$spec" | jq 'any(index("-topology-spread-constraints=- maxSkew: 1"))')
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$spec" | jq 'any(index("-affinity=nodeAffinity: {}"))')
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$spec" | jq 'any(index("-priority-class-name=gateway-critical"))')
+  [ "${actual}" = "true" ]
+}
+
+@test "gatewayresources/Job: service settings can be set" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s $target  \
+      --set 'connectInject.apiGateway.managedGatewayClass.service.annotations=foo: bar' \
+      --set 'connectInject.apiGateway.managedGatewayClass.service.loadBalancerClass=service.k8s.aws/nlb' \
+      --set 'connectInject.apiGateway.managedGatewayClass.service.externalTrafficPolicy=Local' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].args' | tee /dev/stderr)
+
+  local actual=$(echo "$spec" | jq 'any(index("-service-extra-annotations=foo: bar"))')
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$spec" | jq 'any(index("-service-load-balancer-class=service.k8s.aws/nlb"))')
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$spec" | jq 'any(index("-service-external-traffic-policy=Local"))')
+  [ "${actual}" = "true" ]
+}
+
+@test "gatewayresources/Job: autoscaling is not configured by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s $target  \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].args | any(contains("-autoscaling-target"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "gatewayresources/Job: autoscaling targets can be set" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s $target  \
+      --set 'connectInject.apiGateway.managedGatewayClass.autoscaling.targetDownstreamConnections=100' \
+      --set 'connectInject.apiGateway.managedGatewayClass.autoscaling.targetRequestsPerSecond=50' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].args' | tee /dev/stderr)
+
+  local actual=$(echo "$spec" | jq 'any(index("-autoscaling-target-downstream-connections=100"))')
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$spec" | jq 'any(index("-autoscaling-target-requests-per-second=50"))')
+  [ "${actual}" = "true" ]
+}
@@ -322,6 +322,77 @@ key2: value2' \
   [ "${actual}" = "value2" ]
 }
 
+#--------------------------------------------------------------------
+# load balancer settings
+
+@test "ingressGateways/Service: load balancer settings are not set by default" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/ingress-gateways-service.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'ingressGateways.defaults.service.type=LoadBalancer' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec' | tee /dev/stderr)
+
+  [ "$(echo "$spec" | yq -r '.loadBalancerIP')" = "null" ]
+  [ "$(echo "$spec" | yq -r '.loadBalancerClass')" = "null" ]
+  [ "$(echo "$spec" | yq -r '.externalTrafficPolicy')" = "null" ]
+}
+
+@test "ingressGateways/Service: can set load balancer settings through defaults" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/ingress-gateways-service.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'ingressGateways.defaults.service.type=LoadBalancer' \
+      --set 'ingressGateways.defaults.service.loadBalancerIP=10.0.0.10' \
+      --set 'ingressGateways.defaults.service.loadBalancerClass=service.k8s.aws/nlb' \
+      --set 'ingressGateways.defaults.service.externalTrafficPolicy=Local' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec' | tee /dev/stderr)
+
+  [ "$(echo "$spec" | yq -r '.loadBalancerIP')" = "10.0.0.10" ]
+  [ "$(echo "$spec" | yq -r '.loadBalancerClass')" = "service.k8s.aws/nlb" ]
+  [ "$(echo "$spec" | yq -r '.externalTrafficPolicy')" = "Local" ]
+}
+
+@test "ingressGateways/Service: can set load balancer IP through specific gateway overriding defaults" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ingress-gateways-service.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'ingressGateways.defaults.service.type=LoadBalancer' \
+      --set 'ingressGateways.defaults.service.loadBalancerIP=10.0.0.10' \
+      --set 'ingressGateways.gateways[0].name=gateway1' \
+      --set 'ingressGateways.gateways[0].service.loadBalancerIP=10.0.0.11' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.loadBalancerIP' | tee /dev/stderr)
+  [ "${actual}" = "10.0.0.11" ]
+}
+
+@test "ingressGateways/Service: load balancer IP and class are only set for LoadBalancer services" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/ingress-gateways-service.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'ingressGateways.defaults.service.type=NodePort' \
+      --set 'ingressGateways.defaults.service.ports[0].port=8080' \
+      --set 'ingressGateways.defaults.service.ports[0].nodePort=30000' \
+      --set 'ingressGateways.defaults.service.loadBalancerIP=10.0.0.10' \
+      --set 'ingressGateways.defaults.service.loadBalancerClass=service.k8s.aws/nlb' \
+      --set 'ingressGateways.defaults.service.externalTrafficPolicy=Local' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec' | tee /dev/stderr)
+
+  [ "$(echo "$spec" | yq -r '.loadBalancerIP')" = "null" ]
+  [ "$(echo "$spec" | yq -r '.loadBalancerClass')" = "null" ]
+  [ "$(echo "$spec" | yq -r '.externalTrafficPolicy')" = "Local" ]
+}
+
 #--------------------------------------------------------------------
 # selectors
 
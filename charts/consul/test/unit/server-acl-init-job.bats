@@ -280,6 +280,29 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+@test "serverACLInit/Job: sync catalog node shards not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'syncCatalog.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-sync-consul-node-shards"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "serverACLInit/Job: sync catalog node shards can be set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'syncCatalog.enabled=true' \
+      --set 'syncCatalog.consulNodeShards=4' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-sync-consul-node-shards=4"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
 #--------------------------------------------------------------------
 # meshGateway.enabled
 
@@ -1531,6 +1554,56 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+#--------------------------------------------------------------------
+# global.acls.replicationTokenRotationPeriod
+
+@test "serverACLInit/Job: -acl-replication-token-rotation-period is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.createReplicationToken=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-acl-replication-token-rotation-period"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "serverACLInit/Job: -acl-replication-token-rotation-period is set when global.acls.replicationTokenRotationPeriod is set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.createReplicationToken=true' \
+      --set 'global.acls.replicationTokenRotationPeriod=720h' \
+      --set 'global.federation.createFederationSecret=true' \
+      --set 'global.federation.secondaryKubeconfigSecret=secondary-kubeconfigs' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-acl-replication-token-rotation-period=720h"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "serverACLInit/Job: fails if global.acls.replicationTokenRotationPeriod is set without global.acls.createReplicationToken" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.replicationTokenRotationPeriod=720h' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "if global.acls.replicationTokenRotationPeriod is set, global.acls.createReplicationToken must be true" ]]
+}
+
+@test "serverACLInit/Job: fails if global.acls.replicationTokenRotationPeriod is set without global.federation.secondaryKubeconfigSecret" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.createReplicationToken=true' \
+      --set 'global.acls.replicationTokenRotationPeriod=720h' \
+      --set 'global.federation.createFederationSecret=true' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "if global.acls.replicationTokenRotationPeriod is set, global.federation.createFederationSecret and global.federation.secondaryKubeconfigSecret must be set so that secondary datacenters are switched to the rotated token" ]]
+}
+
 #--------------------------------------------------------------------
 # global.acls.replicationToken
 
@@ -2226,3 +2299,17 @@ load _helpers
       yq -r '.spec.template.spec.containers[0].resources.foo' | tee /dev/stderr)
   [ "${actual}" = "bar" ]
 }
+
+#--------------------------------------------------------------------
+# global.restrictedPodSecurity
+
+@test "serverACLInit/Job: restricted securityContext is set on the container when global.restrictedPodSecurity is true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-job.yaml \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.restrictedPodSecurity=true' \
+      . | tee /dev/stderr |
+      yq -c '.spec.template.spec.containers[0].securityContext' | tee /dev/stderr)
+  [ "${actual}" = '{"allowPrivilegeEscalation":false,"capabilities":{"drop":["ALL"]},"runAsNonRoot":true,"seccompProfile":{"type":"RuntimeDefault"}}' ]
+}
@@ -65,6 +65,31 @@ load _helpers
   [ "${actual}" = "1" ]
 }
 
+#--------------------------------------------------------------------
+# global.acls.replicationTokenRotationPeriod
+
+@test "serverACLInit/Role: does not allow updating the replication token secret by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-role.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resourceNames[0] == "release-name-consul-acl-replication-acl-token")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "serverACLInit/Role: allows updating the replication token secret when global.acls.replicationTokenRotationPeriod is set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-role.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.createReplicationToken=true' \
+      --set 'global.acls.replicationTokenRotationPeriod=720h' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resourceNames[0] == "release-name-consul-acl-replication-acl-token")) | .[0].verbs[0]' | tee /dev/stderr)
+  [ "${actual}" = "update" ]
+}
+
 #--------------------------------------------------------------------
 # global.enablePodSecurityPolicies
 
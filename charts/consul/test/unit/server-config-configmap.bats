@@ -128,6 +128,35 @@ load _helpers
   [ "${actual}" = "release-name-consul-server.default.svc:9301" ]
 }
 
+@test "server/ConfigMap: bind and client addresses are IPv4 by default" {
+  cd `chart_dir`
+  local config=$(helm template \
+      -s templates/server-config-configmap.yaml  \
+      . | tee /dev/stderr |
+      yq -r '.data["server.json"]' | tee /dev/stderr)
+
+  local actual=$(echo "$config" | jq -r .bind_addr | tee /dev/stderr)
+  [ "${actual}" = "0.0.0.0" ]
+
+  local actual=$(echo "$config" | jq -r .client_addr | tee /dev/stderr)
+  [ "${actual}" = "0.0.0.0" ]
+}
+
+@test "server/ConfigMap: bind and client addresses are IPv6 with global.enableIPv6=true" {
+  cd `chart_dir`
+  local config=$(helm template \
+      -s templates/server-config-configmap.yaml  \
+      --set 'global.enableIPv6=true' \
+      . | tee /dev/stderr |
+      yq -r '.data["server.json"]' | tee /dev/stderr)
+
+  local actual=$(echo "$config" | jq -r .bind_addr | tee /dev/stderr)
+  [ "${actual}" = "::" ]
+
+  local actual=$(echo "$config" | jq -r .client_addr | tee /dev/stderr)
+  [ "${actual}" = "::" ]
+}
+
 @test "server/ConfigMap: recursors can be set by global.recursors" {
   cd `chart_dir`
   local actual=$(helm template \
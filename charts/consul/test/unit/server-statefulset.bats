@@ -2832,3 +2832,82 @@ MIICFjCCAZsCCQCdwLtdjbzlYzAKBggqhkjOPQQDAjB0MQswCQYDVQQGEwJDQTEL' \
       yq -r '.spec.template.spec.containers[1].command[2] | contains("-interval=10h34m5s")' | tee /dev/stderr)
   [ "${actual}" = "true" ]
 }
+
+#--------------------------------------------------------------------
+# raftAwareProbes
+
+@test "server/StatefulSet: raft aware probes are not used by default" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/server-statefulset.yaml  \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq '.initContainers | map(select(.name == "server-probe-init")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+
+  actual=$(echo "$object" | yq '.containers[0].livenessProbe' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+
+  actual=$(echo "$object" | yq '.containers[0].readinessProbe.exec.command | join(" ") | contains("/v1/status/leader")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "server/StatefulSet: raft aware probes are used when server.raftAwareProbes.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'server.raftAwareProbes.enabled=true' \
+      --set 'server.replicas=5' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.initContainers[] | select(.name == "server-probe-init") | .command | join(" ") | contains("cp /bin/consul-k8s-control-plane /consul/probe/")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  actual=$(echo "$object" | yq -r '.containers[0].volumeMounts[] | select(.name == "probe") | .mountPath' | tee /dev/stderr)
+  [ "${actual}" = "/consul/probe" ]
+
+  actual=$(echo "$object" | yq -r '.volumes[] | select(.name == "probe") | .emptyDir' | tee /dev/stderr)
+  [ "${actual}" = "{}" ]
+
+  actual=$(echo "$object" | yq '.containers[0].startupProbe.exec.command | join(" ") | contains("server-probe -probe=startup")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  actual=$(echo "$object" | yq '.containers[0].startupProbe.failureThreshold' | tee /dev/stderr)
+  [ "${actual}" = "180" ]
+
+  actual=$(echo "$object" | yq '.containers[0].livenessProbe.exec.command | join(" ") | contains("server-probe -probe=liveness")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  actual=$(echo "$object" | yq '.containers[0].readinessProbe.exec.command | join(" ") | contains("server-probe -probe=readiness")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  actual=$(echo "$object" | yq '.containers[0].readinessProbe.exec.command | join(" ") | contains("-bootstrap-expect=5")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "server/StatefulSet: raft aware startup probe timeout can be set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'server.raftAwareProbes.enabled=true' \
+      --set 'server.raftAwareProbes.startupTimeoutSeconds=600' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].startupProbe.failureThreshold' | tee /dev/stderr)
+  [ "${actual}" = "60" ]
+}
+
+#--------------------------------------------------------------------
+# global.fips
+
+@test "server/StatefulSet: fails if FIPS is enabled with a non-FIPS Consul image" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'global.fips=true' \
+      --set 'global.image=hashicorp/consul-enterprise:1.16.0-ent' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.image must be a FIPS variant if global.fips is true" ]]
+}
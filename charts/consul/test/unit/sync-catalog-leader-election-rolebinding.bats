@@ -0,0 +1,38 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "syncCatalog/LeaderElectionRoleBinding: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/sync-catalog-leader-election-rolebinding.yaml  \
+      .
+}
+
+@test "syncCatalog/LeaderElectionRoleBinding: disabled with a single replica" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/sync-catalog-leader-election-rolebinding.yaml  \
+      --set 'syncCatalog.enabled=true' \
+      .
+}
+
+@test "syncCatalog/LeaderElectionRoleBinding: disabled with sync disabled" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/sync-catalog-leader-election-rolebinding.yaml  \
+      --set 'syncCatalog.enabled=false' \
+      --set 'syncCatalog.replicas=2' \
+      .
+}
+
+@test "syncCatalog/LeaderElectionRoleBinding: enabled with multiple replicas" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/sync-catalog-leader-election-rolebinding.yaml  \
+      --set 'syncCatalog.enabled=true' \
+      --set 'syncCatalog.replicas=2' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
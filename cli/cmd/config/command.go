@@ -10,7 +10,7 @@ import (
 	"github.com/mitchellh/cli"
 )
 
-// ConfigCommand  provides a synopsis for the config subcommands (e.g. read).
+// ConfigCommand  provides a synopsis for the config subcommands (e.g. read, list).
 type ConfigCommand struct {
 	*common.BaseCommand
 }
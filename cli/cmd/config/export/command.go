@@ -0,0 +1,322 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package export
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/posener/complete"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	"k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/configentry"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+)
+
+const (
+	flagNameNamespace   = "namespace"
+	flagNameKind        = "kind"
+	flagNameK8sNS       = "k8s-namespace"
+	flagNameOutputDir   = "output-dir"
+	flagNameToken       = "token"
+	flagNameKubeConfig  = "kubeconfig"
+	flagNameKubeContext = "context"
+)
+
+// ExportCommand exports the config entries in Consul that aren't managed by custom resources as custom resources.
+type ExportCommand struct {
+	*common.BaseCommand
+
+	helmActionsRunner helm.HelmActionsRunner
+
+	kubernetes   kubernetes.Interface
+	dynamic      dynamic.Interface
+	restConfig   *rest.Config
+	consulClient *api.Client
+
+	// portForward is the port forward to the Consul server that consulClient uses, if the command opened one.
+	portForward common.PortForwarder
+
+	set *flag.Sets
+
+	flagNamespace   string
+	flagKind        string
+	flagK8sNS       string
+	flagOutputDir   string
+	flagToken       string
+	flagKubeConfig  string
+	flagKubeContext string
+
+	once sync.Once
+	help string
+}
+
+// init sets up flags and help text for the command.
+func (c *ExportCommand) init() {
+	c.set = flag.NewSets()
+
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameNamespace,
+		Target:  &c.flagNamespace,
+		Usage:   "The namespace Consul is installed in. If not set, the namespace of the Consul installation is detected.",
+		Aliases: []string{"n"},
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameKind,
+		Target: &c.flagKind,
+		Usage:  "Only export config entries of this kind, e.g. service-defaults.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameK8sNS,
+		Target:  &c.flagK8sNS,
+		Default: "default",
+		Usage:   "The Kubernetes namespace of the custom resources.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameOutputDir,
+		Target: &c.flagOutputDir,
+		Usage:  "The directory to write a file for each custom resource to. If not set, the custom resources are printed.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameToken,
+		Target: &c.flagToken,
+		Usage:  "The ACL token to read config entries with. If not set, CONSUL_HTTP_TOKEN or the bootstrap token of the installation is used.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameKubeConfig,
+		Aliases: []string{"c"},
+		Target:  &c.flagKubeConfig,
+		Default: "",
+		Usage:   "Set the path to kubeconfig file.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameKubeContext,
+		Target:  &c.flagKubeContext,
+		Default: "",
+		Usage:   "Set the Kubernetes context to use.",
+	})
+
+	c.help = c.set.Help()
+}
+
+// Run exports the unmanaged config entries as custom resources.
+func (c *ExportCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	if c.helmActionsRunner == nil {
+		c.helmActionsRunner = &helm.ActionRunner{}
+	}
+
+	c.Log.ResetNamed("config export")
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.set.Parse(args); err != nil {
+		c.UI.Output("Error parsing arguments: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if err := c.validateFlags(); err != nil {
+		c.UI.Output("Invalid argument: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if c.consulClient == nil || c.dynamic == nil {
+		if err := c.initClients(); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	}
+	if c.portForward != nil {
+		defer c.portForward.Close()
+	}
+
+	kinds := configentry.Kinds
+	if c.flagKind != "" {
+		kind, _ := configentry.KindByName(c.flagKind)
+		kinds = []configentry.Kind{kind}
+	}
+	entries, err := configentry.Fetch(c.Ctx, c.consulClient, c.dynamic, kinds)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	var documents []string
+	written := 0
+	for _, entry := range entries {
+		if entry.Managed {
+			continue
+		}
+		resource, err := configentry.ToCustomResource(entry.ConfigEntry, c.flagK8sNS)
+		if err != nil {
+			c.UI.Output("Error converting %s %s: %v", entry.GetKind(), entry.GetName(), err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		out, err := yaml.Marshal(resource.Object)
+		if err != nil {
+			c.UI.Output("Error converting %s %s: %v", entry.GetKind(), entry.GetName(), err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		if c.flagOutputDir == "" {
+			documents = append(documents, string(out))
+			continue
+		}
+		path := filepath.Join(c.flagOutputDir, fmt.Sprintf("%s-%s.yaml", entry.GetKind(), resource.GetName()))
+		if err := os.WriteFile(path, out, 0644); err != nil {
+			c.UI.Output("Error writing %s: %v", path, err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		written++
+	}
+
+	switch {
+	case len(documents) > 0:
+		c.UI.Output("%s", strings.TrimSuffix(strings.Join(documents, "---\n"), "\n"))
+	case written > 0:
+		c.UI.Output("Wrote %d custom resources to %s", written, c.flagOutputDir, terminal.WithSuccessStyle())
+	default:
+		c.UI.Output("No unmanaged config entries found.", terminal.WithSuccessStyle())
+	}
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *ExportCommand) validateFlags() error {
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if errs := validation.ValidateNamespaceName(c.flagNamespace, false); c.flagNamespace != "" && len(errs) > 0 {
+		return fmt.Errorf("invalid namespace name passed for -namespace/-n: %v", strings.Join(errs, "; "))
+	}
+	if _, ok := configentry.KindByName(c.flagKind); c.flagKind != "" && !ok {
+		return fmt.Errorf("-%s=%s is not a kind of config entry that can be managed by custom resources", flagNameKind, c.flagKind)
+	}
+	if errs := validation.ValidateNamespaceName(c.flagK8sNS, false); len(errs) > 0 {
+		return fmt.Errorf("invalid namespace name passed for -%s: %v", flagNameK8sNS, strings.Join(errs, "; "))
+	}
+	if c.flagOutputDir != "" {
+		if info, err := os.Stat(c.flagOutputDir); err != nil || !info.IsDir() {
+			return fmt.Errorf("-%s=%s is not a directory", flagNameOutputDir, c.flagOutputDir)
+		}
+	}
+	return nil
+}
+
+// initClients initializes the Kubernetes clients and connects to a Consul server of the installation.
+func (c *ExportCommand) initClients() error {
+	settings := helmCLI.New()
+	if c.flagKubeConfig != "" {
+		settings.KubeConfig = c.flagKubeConfig
+	}
+	if c.flagKubeContext != "" {
+		settings.KubeContext = c.flagKubeContext
+	}
+
+	var err error
+	if c.restConfig == nil {
+		if c.restConfig, err = settings.RESTClientGetter().ToRESTConfig(); err != nil {
+			return fmt.Errorf("error retrieving Kubernetes authentication %v", err)
+		}
+	}
+	if c.kubernetes == nil {
+		if c.kubernetes, err = kubernetes.NewForConfig(c.restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client %v", err)
+		}
+	}
+	if c.dynamic == nil {
+		if c.dynamic, err = dynamic.NewForConfig(c.restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client %v", err)
+		}
+	}
+
+	if c.consulClient == nil {
+		namespace := c.flagNamespace
+		if namespace == "" {
+			_, _, namespace, err = c.helmActionsRunner.CheckForInstallations(&helm.CheckForInstallationsOptions{
+				Settings:    settings,
+				ReleaseName: common.DefaultReleaseName,
+				DebugLog:    func(string, ...interface{}) {},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		consulClient, pf, err := configentry.ConnectToServer(c.Ctx, c.kubernetes, c.restConfig, namespace, c.flagToken)
+		if err != nil {
+			return err
+		}
+		c.consulClient, c.portForward = consulClient, pf
+	}
+	return nil
+}
+
+// Help returns a description of the command and how it is used.
+func (c *ExportCommand) Help() string {
+	c.once.Do(c.init)
+	return fmt.Sprintf("%s\n%s", help, c.help)
+}
+
+// Synopsis returns a one-line command summary.
+func (c *ExportCommand) Synopsis() string {
+	return synopsis
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command. The map key for the Flags map should be the
+// complete flag such as "-foo" or "--foo".
+func (c *ExportCommand) AutocompleteFlags() complete.Flags {
+	kinds := make([]string, 0, len(configentry.Kinds))
+	for _, kind := range configentry.Kinds {
+		kinds = append(kinds, kind.Name)
+	}
+	return complete.Flags{
+		fmt.Sprintf("-%s", flagNameNamespace):   complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameKind):        complete.PredictSet(kinds...),
+		fmt.Sprintf("-%s", flagNameK8sNS):       complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameOutputDir):   complete.PredictDirs("*"),
+		fmt.Sprintf("-%s", flagNameToken):       complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameKubeConfig):  complete.PredictFiles("*"),
+		fmt.Sprintf("-%s", flagNameKubeContext): complete.PredictNothing,
+	}
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+// Since argument completion is not supported, this will return
+// complete.PredictNothing.
+func (c *ExportCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+const (
+	synopsis = "Export unmanaged Consul config entries as Kubernetes custom resources."
+	help     = `
+Usage: consul-k8s config export [options]
+
+  Converts the config entries in Consul that aren't managed by Kubernetes
+  custom resources to custom resources, so that they can be managed from
+  Kubernetes. The custom resources are printed as YAML, or written to a file
+  per config entry with -output-dir. The conversion is best effort, so review
+  the custom resources before applying them, or create them in the cluster
+  with "consul-k8s config write".
+
+  Examples:
+    $ consul-k8s config export -kind service-defaults > service-defaults.yaml
+    $ consul-k8s config export -k8s-namespace consul -output-dir ./config-entries
+`
+)
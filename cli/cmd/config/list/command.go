@@ -0,0 +1,311 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package list
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/posener/complete"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	"k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/configentry"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+)
+
+const (
+	flagNameNamespace   = "namespace"
+	flagNameKind        = "kind"
+	flagNameOrphaned    = "orphaned"
+	flagNameToken       = "token"
+	flagNameKubeConfig  = "kubeconfig"
+	flagNameKubeContext = "context"
+)
+
+// ListCommand lists the config entries in Consul and the custom resources that manage them.
+type ListCommand struct {
+	*common.BaseCommand
+
+	helmActionsRunner helm.HelmActionsRunner
+
+	kubernetes   kubernetes.Interface
+	dynamic      dynamic.Interface
+	restConfig   *rest.Config
+	consulClient *api.Client
+
+	// portForward is the port forward to the Consul server that consulClient uses, if the command opened one.
+	portForward common.PortForwarder
+
+	set *flag.Sets
+
+	flagNamespace   string
+	flagKind        string
+	flagOrphaned    bool
+	flagToken       string
+	flagKubeConfig  string
+	flagKubeContext string
+
+	once sync.Once
+	help string
+}
+
+// init sets up flags and help text for the command.
+func (c *ListCommand) init() {
+	c.set = flag.NewSets()
+
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameNamespace,
+		Target:  &c.flagNamespace,
+		Usage:   "The namespace Consul is installed in. If not set, the namespace of the Consul installation is detected.",
+		Aliases: []string{"n"},
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameKind,
+		Target: &c.flagKind,
+		Usage:  "Only list config entries of this kind, e.g. service-defaults.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameOrphaned,
+		Target:  &c.flagOrphaned,
+		Default: false,
+		Usage:   "Only list config entries written for custom resources that no longer exist.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameToken,
+		Target: &c.flagToken,
+		Usage:  "The ACL token to read config entries with. If not set, CONSUL_HTTP_TOKEN or the bootstrap token of the installation is used.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameKubeConfig,
+		Aliases: []string{"c"},
+		Target:  &c.flagKubeConfig,
+		Default: "",
+		Usage:   "Set the path to kubeconfig file.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameKubeContext,
+		Target:  &c.flagKubeContext,
+		Default: "",
+		Usage:   "Set the Kubernetes context to use.",
+	})
+
+	c.help = c.set.Help()
+}
+
+// Run lists the config entries.
+func (c *ListCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	if c.helmActionsRunner == nil {
+		c.helmActionsRunner = &helm.ActionRunner{}
+	}
+
+	c.Log.ResetNamed("config list")
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.set.Parse(args); err != nil {
+		c.UI.Output("Error parsing arguments: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if err := c.validateFlags(); err != nil {
+		c.UI.Output("Invalid argument: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if c.consulClient == nil || c.dynamic == nil {
+		if err := c.initClients(); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	}
+	if c.portForward != nil {
+		defer c.portForward.Close()
+	}
+
+	kinds := configentry.Kinds
+	if c.flagKind != "" {
+		kind, _ := configentry.KindByName(c.flagKind)
+		kinds = []configentry.Kind{kind}
+	}
+	entries, err := configentry.Fetch(c.Ctx, c.consulClient, c.dynamic, kinds)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	datacenter, err := configentry.Datacenter(c.consulClient)
+	if err != nil {
+		c.UI.Output("Error reading the Consul datacenter: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	table := terminal.NewTable("Kind", "Name", "Status", "Custom Resources")
+	rows := 0
+	for _, entry := range entries {
+		if c.flagOrphaned && !entry.Orphaned(datacenter) {
+			continue
+		}
+		resources := "-"
+		if len(entry.Resources) > 0 {
+			resources = strings.Join(entry.Resources, ", ")
+		}
+		table.AddRow([]string{entry.GetKind(), entry.GetName(), status(entry, datacenter), resources}, []string{})
+		rows++
+	}
+
+	if rows == 0 {
+		if c.flagOrphaned {
+			c.UI.Output("No orphaned config entries found.", terminal.WithSuccessStyle())
+		} else {
+			c.UI.Output("No config entries found.")
+		}
+		return 0
+	}
+	c.UI.Output("Config entries in datacenter %s", datacenter, terminal.WithHeaderStyle())
+	c.UI.Table(table)
+	return 0
+}
+
+// status describes whether the config entry is managed by custom resources.
+func status(entry configentry.Entry, datacenter string) string {
+	switch {
+	case !entry.Managed:
+		return "unmanaged"
+	case entry.Orphaned(datacenter):
+		return "orphaned"
+	case entry.Datacenter != "" && entry.Datacenter != datacenter:
+		return fmt.Sprintf("managed from %s", entry.Datacenter)
+	default:
+		return "managed"
+	}
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *ListCommand) validateFlags() error {
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if errs := validation.ValidateNamespaceName(c.flagNamespace, false); c.flagNamespace != "" && len(errs) > 0 {
+		return fmt.Errorf("invalid namespace name passed for -namespace/-n: %v", strings.Join(errs, "; "))
+	}
+	if _, ok := configentry.KindByName(c.flagKind); c.flagKind != "" && !ok {
+		return fmt.Errorf("-%s=%s is not a kind of config entry that can be managed by custom resources", flagNameKind, c.flagKind)
+	}
+	return nil
+}
+
+// initClients initializes the Kubernetes clients and connects to a Consul server of the installation.
+func (c *ListCommand) initClients() error {
+	settings := helmCLI.New()
+	if c.flagKubeConfig != "" {
+		settings.KubeConfig = c.flagKubeConfig
+	}
+	if c.flagKubeContext != "" {
+		settings.KubeContext = c.flagKubeContext
+	}
+
+	var err error
+	if c.restConfig == nil {
+		if c.restConfig, err = settings.RESTClientGetter().ToRESTConfig(); err != nil {
+			return fmt.Errorf("error retrieving Kubernetes authentication %v", err)
+		}
+	}
+	if c.kubernetes == nil {
+		if c.kubernetes, err = kubernetes.NewForConfig(c.restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client %v", err)
+		}
+	}
+	if c.dynamic == nil {
+		if c.dynamic, err = dynamic.NewForConfig(c.restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client %v", err)
+		}
+	}
+
+	if c.consulClient == nil {
+		namespace := c.flagNamespace
+		if namespace == "" {
+			_, _, namespace, err = c.helmActionsRunner.CheckForInstallations(&helm.CheckForInstallationsOptions{
+				Settings:    settings,
+				ReleaseName: common.DefaultReleaseName,
+				DebugLog:    func(string, ...interface{}) {},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		consulClient, pf, err := configentry.ConnectToServer(c.Ctx, c.kubernetes, c.restConfig, namespace, c.flagToken)
+		if err != nil {
+			return err
+		}
+		c.consulClient, c.portForward = consulClient, pf
+	}
+	return nil
+}
+
+// Help returns a description of the command and how it is used.
+func (c *ListCommand) Help() string {
+	c.once.Do(c.init)
+	return fmt.Sprintf("%s\n%s", help, c.help)
+}
+
+// Synopsis returns a one-line command summary.
+func (c *ListCommand) Synopsis() string {
+	return synopsis
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command. The map key for the Flags map should be the
+// complete flag such as "-foo" or "--foo".
+func (c *ListCommand) AutocompleteFlags() complete.Flags {
+	kinds := make([]string, 0, len(configentry.Kinds))
+	for _, kind := range configentry.Kinds {
+		kinds = append(kinds, kind.Name)
+	}
+	return complete.Flags{
+		fmt.Sprintf("-%s", flagNameNamespace):   complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameKind):        complete.PredictSet(kinds...),
+		fmt.Sprintf("-%s", flagNameOrphaned):    complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameToken):       complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameKubeConfig):  complete.PredictFiles("*"),
+		fmt.Sprintf("-%s", flagNameKubeContext): complete.PredictNothing,
+	}
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+// Since argument completion is not supported, this will return
+// complete.PredictNothing.
+func (c *ListCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+const (
+	synopsis = "List Consul config entries and the custom resources that manage them."
+	help     = `
+Usage: consul-k8s config list [options]
+
+  Lists the config entries in Consul with the Kubernetes custom resources that
+  manage them. Config entries that were written for custom resources that no
+  longer exist in this cluster are shown as orphaned, and config entries that
+  weren't written by Kubernetes are shown as unmanaged. Unmanaged config
+  entries can be exported as custom resources with "consul-k8s config export",
+  or taken over by custom resources with "consul-k8s config write".
+
+  Examples:
+    $ consul-k8s config list
+    $ consul-k8s config list -kind service-defaults
+    $ consul-k8s config list -orphaned
+`
+)
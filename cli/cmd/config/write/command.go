@@ -0,0 +1,329 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package write
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/posener/complete"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/configentry"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+)
+
+const (
+	flagNameNamespace   = "namespace"
+	flagNameKind        = "kind"
+	flagNameK8sNS       = "k8s-namespace"
+	flagNameDryRun      = "dry-run"
+	flagNameToken       = "token"
+	flagNameKubeConfig  = "kubeconfig"
+	flagNameKubeContext = "context"
+)
+
+// WriteCommand writes custom resources to Kubernetes for the config entries in Consul that aren't managed
+// by custom resources, so that the consul-k8s controller takes them over.
+type WriteCommand struct {
+	*common.BaseCommand
+
+	helmActionsRunner helm.HelmActionsRunner
+
+	kubernetes   kubernetes.Interface
+	dynamic      dynamic.Interface
+	restConfig   *rest.Config
+	consulClient *api.Client
+
+	// portForward is the port forward to the Consul server that consulClient uses, if the command opened one.
+	portForward common.PortForwarder
+
+	set *flag.Sets
+
+	flagNamespace   string
+	flagKind        string
+	flagK8sNS       string
+	flagDryRun      bool
+	flagToken       string
+	flagKubeConfig  string
+	flagKubeContext string
+
+	once sync.Once
+	help string
+}
+
+// init sets up flags and help text for the command.
+func (c *WriteCommand) init() {
+	c.set = flag.NewSets()
+
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameNamespace,
+		Target:  &c.flagNamespace,
+		Usage:   "The namespace Consul is installed in. If not set, the namespace of the Consul installation is detected.",
+		Aliases: []string{"n"},
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameKind,
+		Target: &c.flagKind,
+		Usage:  "Only write custom resources for config entries of this kind, e.g. service-defaults.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameK8sNS,
+		Target:  &c.flagK8sNS,
+		Default: "default",
+		Usage:   "The Kubernetes namespace to create the custom resources in.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameDryRun,
+		Target:  &c.flagDryRun,
+		Default: false,
+		Usage:   "Print the custom resources that would be created without creating them.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameToken,
+		Target: &c.flagToken,
+		Usage:  "The ACL token to read config entries with. If not set, CONSUL_HTTP_TOKEN or the bootstrap token of the installation is used.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameKubeConfig,
+		Aliases: []string{"c"},
+		Target:  &c.flagKubeConfig,
+		Default: "",
+		Usage:   "Set the path to kubeconfig file.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameKubeContext,
+		Target:  &c.flagKubeContext,
+		Default: "",
+		Usage:   "Set the Kubernetes context to use.",
+	})
+
+	c.help = c.set.Help()
+}
+
+// Run creates custom resources for the unmanaged config entries.
+func (c *WriteCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	if c.helmActionsRunner == nil {
+		c.helmActionsRunner = &helm.ActionRunner{}
+	}
+
+	c.Log.ResetNamed("config write")
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.set.Parse(args); err != nil {
+		c.UI.Output("Error parsing arguments: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if err := c.validateFlags(); err != nil {
+		c.UI.Output("Invalid argument: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if c.consulClient == nil || c.dynamic == nil {
+		if err := c.initClients(); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	}
+	if c.portForward != nil {
+		defer c.portForward.Close()
+	}
+
+	kinds := configentry.Kinds
+	if c.flagKind != "" {
+		kind, _ := configentry.KindByName(c.flagKind)
+		kinds = []configentry.Kind{kind}
+	}
+	entries, err := configentry.Fetch(c.Ctx, c.consulClient, c.dynamic, kinds)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	created, failed := 0, false
+	for _, entry := range entries {
+		if entry.Managed {
+			continue
+		}
+		// A custom resource that failed to sync because the entry already existed must be
+		// migrated by the user, since its spec may differ from the entry.
+		if len(entry.Resources) > 0 {
+			c.UI.Output("Skipping %s %s: it already has custom resources %s", entry.GetKind(), entry.GetName(),
+				strings.Join(entry.Resources, ", "), terminal.WithWarningStyle())
+			continue
+		}
+		resource, err := configentry.ToCustomResource(entry.ConfigEntry, c.flagK8sNS)
+		if err != nil {
+			c.UI.Output("Error converting %s %s: %v", entry.GetKind(), entry.GetName(), err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		resource.SetAnnotations(map[string]string{configentry.MigrateEntryAnnotation: "true"})
+		if c.flagDryRun {
+			c.UI.Output("Would create %s %s/%s", resource.GetKind(), resource.GetNamespace(), resource.GetName(), terminal.WithInfoStyle())
+			continue
+		}
+
+		kind, _ := configentry.KindByName(entry.GetKind())
+		_, err = c.dynamic.Resource(kind.GroupVersionResource()).Namespace(c.flagK8sNS).Create(c.Ctx, resource, metav1.CreateOptions{})
+		switch {
+		case k8serrors.IsAlreadyExists(err):
+			c.UI.Output("Skipping %s %s/%s: a custom resource with that name already exists", resource.GetKind(),
+				resource.GetNamespace(), resource.GetName(), terminal.WithWarningStyle())
+		case err != nil:
+			c.UI.Output("Error creating %s %s/%s: %v", resource.GetKind(), resource.GetNamespace(), resource.GetName(),
+				err.Error(), terminal.WithErrorStyle())
+			failed = true
+		default:
+			c.UI.Output("Created %s %s/%s", resource.GetKind(), resource.GetNamespace(), resource.GetName(), terminal.WithSuccessStyle())
+			created++
+		}
+	}
+
+	if failed {
+		return 1
+	}
+	if created == 0 && !c.flagDryRun {
+		c.UI.Output("No custom resources created.", terminal.WithSuccessStyle())
+	}
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *WriteCommand) validateFlags() error {
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if errs := validation.ValidateNamespaceName(c.flagNamespace, false); c.flagNamespace != "" && len(errs) > 0 {
+		return fmt.Errorf("invalid namespace name passed for -namespace/-n: %v", strings.Join(errs, "; "))
+	}
+	if _, ok := configentry.KindByName(c.flagKind); c.flagKind != "" && !ok {
+		return fmt.Errorf("-%s=%s is not a kind of config entry that can be managed by custom resources", flagNameKind, c.flagKind)
+	}
+	if errs := validation.ValidateNamespaceName(c.flagK8sNS, false); len(errs) > 0 {
+		return fmt.Errorf("invalid namespace name passed for -%s: %v", flagNameK8sNS, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// initClients initializes the Kubernetes clients and connects to a Consul server of the installation.
+func (c *WriteCommand) initClients() error {
+	settings := helmCLI.New()
+	if c.flagKubeConfig != "" {
+		settings.KubeConfig = c.flagKubeConfig
+	}
+	if c.flagKubeContext != "" {
+		settings.KubeContext = c.flagKubeContext
+	}
+
+	var err error
+	if c.restConfig == nil {
+		if c.restConfig, err = settings.RESTClientGetter().ToRESTConfig(); err != nil {
+			return fmt.Errorf("error retrieving Kubernetes authentication %v", err)
+		}
+	}
+	if c.kubernetes == nil {
+		if c.kubernetes, err = kubernetes.NewForConfig(c.restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client %v", err)
+		}
+	}
+	if c.dynamic == nil {
+		if c.dynamic, err = dynamic.NewForConfig(c.restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client %v", err)
+		}
+	}
+
+	if c.consulClient == nil {
+		namespace := c.flagNamespace
+		if namespace == "" {
+			_, _, namespace, err = c.helmActionsRunner.CheckForInstallations(&helm.CheckForInstallationsOptions{
+				Settings:    settings,
+				ReleaseName: common.DefaultReleaseName,
+				DebugLog:    func(string, ...interface{}) {},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		consulClient, pf, err := configentry.ConnectToServer(c.Ctx, c.kubernetes, c.restConfig, namespace, c.flagToken)
+		if err != nil {
+			return err
+		}
+		c.consulClient, c.portForward = consulClient, pf
+	}
+	return nil
+}
+
+// Help returns a description of the command and how it is used.
+func (c *WriteCommand) Help() string {
+	c.once.Do(c.init)
+	return fmt.Sprintf("%s\n%s", help, c.help)
+}
+
+// Synopsis returns a one-line command summary.
+func (c *WriteCommand) Synopsis() string {
+	return synopsis
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command. The map key for the Flags map should be the
+// complete flag such as "-foo" or "--foo".
+func (c *WriteCommand) AutocompleteFlags() complete.Flags {
+	kinds := make([]string, 0, len(configentry.Kinds))
+	for _, kind := range configentry.Kinds {
+		kinds = append(kinds, kind.Name)
+	}
+	return complete.Flags{
+		fmt.Sprintf("-%s", flagNameNamespace):   complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameKind):        complete.PredictSet(kinds...),
+		fmt.Sprintf("-%s", flagNameK8sNS):       complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameDryRun):      complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameToken):       complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameKubeConfig):  complete.PredictFiles("*"),
+		fmt.Sprintf("-%s", flagNameKubeContext): complete.PredictNothing,
+	}
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+// Since argument completion is not supported, this will return
+// complete.PredictNothing.
+func (c *WriteCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+const (
+	synopsis = "Write Kubernetes custom resources for unmanaged Consul config entries."
+	help     = `
+Usage: consul-k8s config write [options]
+
+  Creates a Kubernetes custom resource for each config entry in Consul that
+  isn't managed by custom resources. The custom resources are annotated with
+  consul.hashicorp.com/migrate-entry so that the consul-k8s controller takes
+  over the existing config entries, which are from then on managed from
+  Kubernetes. Custom resources that already exist are never changed.
+
+  The conversion is best effort. Use -dry-run to see which custom resources
+  would be created, and "consul-k8s config export" to review them first.
+
+  Examples:
+    $ consul-k8s config write -kind service-defaults -dry-run
+    $ consul-k8s config write -k8s-namespace consul
+`
+)
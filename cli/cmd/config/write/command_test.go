@@ -0,0 +1,186 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package write
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+)
+
+var (
+	serviceDefaultsGVR  = schema.GroupVersionResource{Group: "consul.hashicorp.com", Version: "v1alpha1", Resource: "servicedefaults"}
+	serviceResolversGVR = schema.GroupVersionResource{Group: "consul.hashicorp.com", Version: "v1alpha1", Resource: "serviceresolvers"}
+)
+
+func TestRun(t *testing.T) {
+	buf := new(bytes.Buffer)
+	cmd := getInitializedCommand(t, buf)
+	cmd.consulClient = testConsulServer(t, testEntries)
+	k8sClient := testDynamicClient(t)
+	cmd.dynamic = k8sClient
+
+	require.Equal(t, 0, cmd.Run([]string{"-k8s-namespace", "apps"}))
+	require.Contains(t, buf.String(), "Created ServiceDefaults apps/cache")
+	require.Contains(t, buf.String(), "Created ServiceResolver apps/db")
+
+	cache, err := k8sClient.Resource(serviceDefaultsGVR).Namespace("apps").Get(context.Background(), "cache", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"consul.hashicorp.com/migrate-entry": "true"}, cache.GetAnnotations())
+	protocol, _, err := unstructured.NestedString(cache.Object, "spec", "protocol")
+	require.NoError(t, err)
+	require.Equal(t, "http", protocol)
+	_, err = k8sClient.Resource(serviceResolversGVR).Namespace("apps").Get(context.Background(), "db", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	// Managed config entries don't get another custom resource.
+	_, err = k8sClient.Resource(serviceDefaultsGVR).Namespace("apps").Get(context.Background(), "web", metav1.GetOptions{})
+	require.Error(t, err)
+
+	// Existing custom resources are left as they are.
+	buf.Reset()
+	require.Equal(t, 0, cmd.Run([]string{"-k8s-namespace", "apps"}))
+	require.Contains(t, buf.String(), "Skipping service-defaults cache: it already has custom resources apps/cache")
+	require.Contains(t, buf.String(), "No custom resources created.")
+}
+
+func TestRun_DryRun(t *testing.T) {
+	buf := new(bytes.Buffer)
+	cmd := getInitializedCommand(t, buf)
+	cmd.consulClient = testConsulServer(t, testEntries)
+	k8sClient := testDynamicClient(t)
+	cmd.dynamic = k8sClient
+
+	require.Equal(t, 0, cmd.Run([]string{"-kind", "service-defaults", "-dry-run"}))
+	require.Contains(t, buf.String(), "Would create ServiceDefaults default/cache")
+	require.NotContains(t, buf.String(), "ServiceResolver")
+
+	_, err := k8sClient.Resource(serviceDefaultsGVR).Namespace("default").Get(context.Background(), "cache", metav1.GetOptions{})
+	require.Error(t, err)
+}
+
+func TestRun_FlagValidation(t *testing.T) {
+	cases := map[string]struct {
+		args   []string
+		expErr string
+	}{
+		"non-flag arguments": {
+			args:   []string{"foo"},
+			expErr: "should have no non-flag arguments",
+		},
+		"unknown kind": {
+			args:   []string{"-kind", "api-gateway"},
+			expErr: "-kind=api-gateway is not a kind of config entry that can be managed by custom resources",
+		},
+		"invalid namespace": {
+			args:   []string{"-namespace", "Invalid_Namespace"},
+			expErr: "invalid namespace name passed for -namespace/-n",
+		},
+		"invalid Kubernetes namespace": {
+			args:   []string{"-k8s-namespace", "Invalid_Namespace"},
+			expErr: "invalid namespace name passed for -k8s-namespace",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			cmd := getInitializedCommand(t, buf)
+			require.Equal(t, 1, cmd.Run(c.args))
+			require.Contains(t, buf.String(), c.expErr)
+		})
+	}
+}
+
+func getInitializedCommand(t *testing.T, buf *bytes.Buffer) *WriteCommand {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+		UI:  terminal.NewUI(context.Background(), buf),
+	}
+
+	c := &WriteCommand{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
+
+// testEntries are config entries where only web is managed by a custom resource.
+var testEntries = map[string][]api.ConfigEntry{
+	api.ServiceDefaults: {
+		&api.ServiceConfigEntry{
+			Kind: api.ServiceDefaults,
+			Name: "web",
+			Meta: map[string]string{"external-source": "kubernetes", "consul.hashicorp.com/source-datacenter": "dc1"},
+		},
+		&api.ServiceConfigEntry{Kind: api.ServiceDefaults, Name: "cache", Protocol: "http"},
+	},
+	api.ServiceResolver: {
+		&api.ServiceResolverConfigEntry{Kind: api.ServiceResolver, Name: "db", ConnectTimeout: 5 * time.Second},
+	},
+}
+
+// testConsulServer returns a Consul client for a fake Consul server in dc1 with the config entries.
+func testConsulServer(t *testing.T, entries map[string][]api.ConfigEntry) *api.Client {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/config/", func(w http.ResponseWriter, r *http.Request) {
+		list := entries[strings.TrimPrefix(r.URL.Path, "/v1/config/")]
+		if list == nil {
+			list = []api.ConfigEntry{}
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(list))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	consulClient, err := api.NewClient(&api.Config{Address: strings.TrimPrefix(server.URL, "http://")})
+	require.NoError(t, err)
+	return consulClient
+}
+
+// testDynamicClient returns a fake Kubernetes client with a ServiceDefaults resource for web.
+func testDynamicClient(t *testing.T) *dynamicFake.FakeDynamicClient {
+	listKinds := map[schema.GroupVersionResource]string{}
+	for _, resource := range []string{
+		"servicedefaults", "proxydefaults", "servicerouters", "servicesplitters", "serviceresolvers",
+		"ingressgateways", "terminatinggateways", "serviceintentions", "meshes", "exportedservices",
+		"samenessgroups", "jwtproviders", "controlplanerequestlimits",
+	} {
+		listKinds[schema.GroupVersionResource{Group: "consul.hashicorp.com", Version: "v1alpha1", Resource: resource}] = "UnstructuredList"
+	}
+	k8sClient := dynamicFake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds)
+
+	web := &unstructured.Unstructured{}
+	web.SetAPIVersion("consul.hashicorp.com/v1alpha1")
+	web.SetKind("ServiceDefaults")
+	web.SetName("web")
+	web.SetNamespace("default")
+	_, err := k8sClient.Resource(serviceDefaultsGVR).Namespace("default").Create(context.Background(), web, metav1.CreateOptions{})
+	require.NoError(t, err)
+	return k8sClient
+}
@@ -0,0 +1,29 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package crd
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/mitchellh/cli"
+)
+
+// CRDCommand provides a synopsis for the crd subcommands (e.g. migrate).
+type CRDCommand struct {
+	*common.BaseCommand
+}
+
+// Run prints out information about the subcommands.
+func (c *CRDCommand) Run([]string) int {
+	return cli.RunResultHelp
+}
+
+func (c *CRDCommand) Help() string {
+	return fmt.Sprintf("%s\n\nUsage: consul-k8s crd <subcommand>", c.Synopsis())
+}
+
+func (c *CRDCommand) Synopsis() string {
+	return "Manage the Consul custom resource definitions."
+}
@@ -0,0 +1,291 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package migrate
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/posener/complete"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiext "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// consulGroup is the API group of the Consul custom resource definitions.
+	consulGroup = "consul.hashicorp.com"
+
+	flagNameDryRun      = "dry-run"
+	flagNameKubeConfig  = "kubeconfig"
+	flagNameKubeContext = "context"
+)
+
+// MigrateCommand re-stores every Consul custom resource in the storage version
+// of its CRD and then removes the old versions from the CRD's stored versions
+// so they can be dropped from the CRD in a later release.
+type MigrateCommand struct {
+	*common.BaseCommand
+
+	apiextK8sClient  apiext.Interface
+	dynamicK8sClient dynamic.Interface
+
+	set *flag.Sets
+
+	flagDryRun      bool
+	flagKubeConfig  string
+	flagKubeContext string
+
+	once sync.Once
+	help string
+}
+
+// init sets up flags and help text for the command.
+func (c *MigrateCommand) init() {
+	c.set = flag.NewSets()
+	f := c.set.NewSet("Command Options")
+
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameDryRun,
+		Target:  &c.flagDryRun,
+		Default: false,
+		Usage:   "List the custom resource definitions that need to be migrated without migrating them.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameKubeConfig,
+		Aliases: []string{"c"},
+		Target:  &c.flagKubeConfig,
+		Default: "",
+		Usage:   "Set the path to kubeconfig file.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameKubeContext,
+		Target:  &c.flagKubeContext,
+		Default: "",
+		Usage:   "Set the Kubernetes context to use.",
+	})
+
+	c.help = c.set.Help()
+}
+
+// Run executes the migrate command.
+func (c *MigrateCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("migrate")
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.set.Parse(args); err != nil {
+		c.UI.Output("Error parsing arguments: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	if len(c.set.Args()) > 0 {
+		c.UI.Output("Should have no non-flag arguments.", terminal.WithErrorStyle())
+		return 1
+	}
+
+	if c.apiextK8sClient == nil || c.dynamicK8sClient == nil {
+		if err := c.initKubernetes(); err != nil {
+			c.UI.Output("Error initializing Kubernetes client: %v", err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	}
+
+	crds, err := c.apiextK8sClient.ApiextensionsV1().CustomResourceDefinitions().List(c.Ctx, metav1.ListOptions{})
+	if err != nil {
+		c.UI.Output("Error listing custom resource definitions: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	var toMigrate []apiextv1.CustomResourceDefinition
+	for _, crd := range crds.Items {
+		if crd.Spec.Group == consulGroup && needsMigration(crd) {
+			toMigrate = append(toMigrate, crd)
+		}
+	}
+	sort.Slice(toMigrate, func(i, j int) bool { return toMigrate[i].Name < toMigrate[j].Name })
+
+	if len(toMigrate) == 0 {
+		c.UI.Output("All Consul custom resources are stored in the storage version of their CRD.", terminal.WithSuccessStyle())
+		return 0
+	}
+
+	table := terminal.NewTable("CRD", "Stored Versions", "Storage Version", "Resources")
+	for _, crd := range toMigrate {
+		storedVersions := strings.Join(crd.Status.StoredVersions, ", ")
+		count := "-"
+		if !c.flagDryRun {
+			migrated, err := c.migrate(crd)
+			if err != nil {
+				c.UI.Output("Error migrating %s: %v", crd.Name, err.Error(), terminal.WithErrorStyle())
+				return 1
+			}
+			count = strconv.Itoa(migrated)
+		}
+		table.AddRow([]string{crd.Name, storedVersions, storageVersion(crd), count}, []string{})
+	}
+
+	if c.flagDryRun {
+		c.UI.Output("Custom resource definitions to migrate", terminal.WithHeaderStyle())
+	} else {
+		c.UI.Output("Migrated custom resource definitions", terminal.WithHeaderStyle())
+	}
+	c.UI.Table(table)
+	return 0
+}
+
+// migrate updates every custom resource of the CRD without changes so that the
+// API server writes it in the storage version, then sets the CRD's stored
+// versions to only the storage version. It returns the number of resources
+// that were updated.
+func (c *MigrateCommand) migrate(crd apiextv1.CustomResourceDefinition) (int, error) {
+	storage := storageVersion(crd)
+	gvr := schema.GroupVersionResource{
+		Group:    crd.Spec.Group,
+		Version:  storage,
+		Resource: crd.Spec.Names.Plural,
+	}
+
+	list, err := c.dynamicK8sClient.Resource(gvr).List(c.Ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for _, item := range list.Items {
+		resource := c.dynamicK8sClient.Resource(gvr).Namespace(item.GetNamespace())
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			current, err := resource.Get(c.Ctx, item.GetName(), metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			_, err = resource.Update(c.Ctx, current, metav1.UpdateOptions{})
+			return err
+		})
+		if k8serrors.IsNotFound(err) {
+			// The resource was deleted since it was listed so there's nothing to migrate.
+			continue
+		}
+		if err != nil {
+			return migrated, fmt.Errorf("updating %s/%s: %w", item.GetNamespace(), item.GetName(), err)
+		}
+		migrated++
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := c.apiextK8sClient.ApiextensionsV1().CustomResourceDefinitions().Get(c.Ctx, crd.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		current.Status.StoredVersions = []string{storage}
+		_, err = c.apiextK8sClient.ApiextensionsV1().CustomResourceDefinitions().UpdateStatus(c.Ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return migrated, fmt.Errorf("updating stored versions: %w", err)
+	}
+	return migrated, nil
+}
+
+// initKubernetes initializes the Kubernetes clients.
+func (c *MigrateCommand) initKubernetes() error {
+	settings := helmCLI.New()
+	if c.flagKubeConfig != "" {
+		settings.KubeConfig = c.flagKubeConfig
+	}
+	if c.flagKubeContext != "" {
+		settings.KubeContext = c.flagKubeContext
+	}
+
+	restConfig, err := settings.RESTClientGetter().ToRESTConfig()
+	if err != nil {
+		return fmt.Errorf("error creating Kubernetes REST config %v", err)
+	}
+	if c.apiextK8sClient == nil {
+		if c.apiextK8sClient, err = apiext.NewForConfig(restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client %v", err)
+		}
+	}
+	if c.dynamicK8sClient == nil {
+		if c.dynamicK8sClient, err = dynamic.NewForConfig(restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client %v", err)
+		}
+	}
+	return nil
+}
+
+// storageVersion returns the version of the CRD that resources are persisted in.
+func storageVersion(crd apiextv1.CustomResourceDefinition) string {
+	for _, version := range crd.Spec.Versions {
+		if version.Storage {
+			return version.Name
+		}
+	}
+	return ""
+}
+
+// needsMigration returns true if resources of the CRD may be persisted in a
+// version other than its storage version.
+func needsMigration(crd apiextv1.CustomResourceDefinition) bool {
+	storage := storageVersion(crd)
+	if storage == "" {
+		return false
+	}
+	stored := crd.Status.StoredVersions
+	return len(stored) != 1 || stored[0] != storage
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command. The map key for the Flags map should be the
+// complete flag such as "-foo" or "--foo".
+func (c *MigrateCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		fmt.Sprintf("-%s", flagNameDryRun):      complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameKubeConfig):  complete.PredictFiles("*"),
+		fmt.Sprintf("-%s", flagNameKubeContext): complete.PredictNothing,
+	}
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+// Since argument completion is not supported, this will return
+// complete.PredictNothing.
+func (c *MigrateCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *MigrateCommand) Synopsis() string {
+	return synopsis
+}
+
+func (c *MigrateCommand) Help() string {
+	c.once.Do(c.init)
+	return fmt.Sprintf("%s\n%s", help, c.help)
+}
+
+const (
+	synopsis = "Migrate stored Consul custom resources to the storage version of their CRD."
+	help     = `
+Usage: consul-k8s crd migrate [options]
+
+  Rewrites every Consul custom resource in the storage version of its custom
+  resource definition and removes older versions from the definition's stored
+  versions. Run this after upgrading to a release that adds a new API version
+  so that the old version can be removed from the CRDs in a later upgrade.
+
+  Examples:
+    $ consul-k8s crd migrate -dry-run
+    $ consul-k8s crd migrate
+`
+)
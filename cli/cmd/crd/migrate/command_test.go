@@ -0,0 +1,182 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextFake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+)
+
+var serviceDefaultsGVR = schema.GroupVersionResource{
+	Group:    consulGroup,
+	Version:  "v1",
+	Resource: "servicedefaults",
+}
+
+func TestRun(t *testing.T) {
+	cases := map[string]struct {
+		dryRun            bool
+		storedVersions    []string
+		expOutput         []string
+		expStoredVersions []string
+		expUpdates        int
+	}{
+		"migrates resources stored in old versions": {
+			storedVersions:    []string{"v1alpha1", "v1"},
+			expOutput:         []string{"Migrated custom resource definitions", "servicedefaults.consul.hashicorp.com", "v1alpha1, v1"},
+			expStoredVersions: []string{"v1"},
+			expUpdates:        2,
+		},
+		"dry run does not migrate resources": {
+			dryRun:            true,
+			storedVersions:    []string{"v1alpha1", "v1"},
+			expOutput:         []string{"Custom resource definitions to migrate", "servicedefaults.consul.hashicorp.com"},
+			expStoredVersions: []string{"v1alpha1", "v1"},
+		},
+		"nothing to migrate": {
+			storedVersions:    []string{"v1"},
+			expOutput:         []string{"All Consul custom resources are stored in the storage version of their CRD."},
+			expStoredVersions: []string{"v1"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			cmd := getInitializedCommand(t, buf)
+
+			crds := []runtime.Object{
+				testCRD("servicedefaults.consul.hashicorp.com", consulGroup, "servicedefaults", c.storedVersions),
+				// CRDs of other groups are never migrated.
+				testCRD("examples.example.com", "example.com", "examples", []string{"v1alpha1", "v1"}),
+			}
+			cmd.apiextK8sClient = apiextFake.NewSimpleClientset(crds...)
+			dynamicClient := dynamicFake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{serviceDefaultsGVR: "ServiceDefaultsList"})
+			for _, sd := range []*unstructured.Unstructured{testServiceDefaults("web", "default"), testServiceDefaults("api", "other")} {
+				_, err := dynamicClient.Resource(serviceDefaultsGVR).Namespace(sd.GetNamespace()).Create(context.Background(), sd, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+			cmd.dynamicK8sClient = dynamicClient
+
+			args := []string{}
+			if c.dryRun {
+				args = append(args, "-dry-run")
+			}
+			require.Equal(t, 0, cmd.Run(args))
+
+			output := buf.String()
+			for _, s := range c.expOutput {
+				require.Contains(t, output, s)
+			}
+			require.NotContains(t, output, "examples.example.com")
+
+			crd, err := cmd.apiextK8sClient.ApiextensionsV1().CustomResourceDefinitions().
+				Get(context.Background(), "servicedefaults.consul.hashicorp.com", metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, c.expStoredVersions, crd.Status.StoredVersions)
+
+			other, err := cmd.apiextK8sClient.ApiextensionsV1().CustomResourceDefinitions().
+				Get(context.Background(), "examples.example.com", metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, []string{"v1alpha1", "v1"}, other.Status.StoredVersions)
+
+			updates := 0
+			for _, action := range dynamicClient.Actions() {
+				if action.GetVerb() == "update" {
+					updates++
+				}
+			}
+			require.Equal(t, c.expUpdates, updates)
+		})
+	}
+}
+
+func TestRun_FailsWithArguments(t *testing.T) {
+	buf := new(bytes.Buffer)
+	cmd := getInitializedCommand(t, buf)
+	require.Equal(t, 1, cmd.Run([]string{"foo"}))
+	require.Contains(t, buf.String(), "Should have no non-flag arguments.")
+}
+
+func TestNeedsMigration(t *testing.T) {
+	cases := map[string]struct {
+		storedVersions []string
+		exp            bool
+	}{
+		"only storage version": {storedVersions: []string{"v1"}, exp: false},
+		"old version":          {storedVersions: []string{"v1alpha1"}, exp: true},
+		"multiple versions":    {storedVersions: []string{"v1alpha1", "v1"}, exp: true},
+		"no stored versions":   {storedVersions: nil, exp: true},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			crd := testCRD("servicedefaults.consul.hashicorp.com", consulGroup, "servicedefaults", c.storedVersions)
+			require.Equal(t, c.exp, needsMigration(*crd))
+		})
+	}
+}
+
+func getInitializedCommand(t *testing.T, buf *bytes.Buffer) *MigrateCommand {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+		UI:  terminal.NewUI(context.Background(), buf),
+	}
+
+	c := &MigrateCommand{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
+
+func testCRD(name, group, plural string, storedVersions []string) *apiextv1.CustomResourceDefinition {
+	return &apiextv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: apiextv1.CustomResourceDefinitionSpec{
+			Group: group,
+			Names: apiextv1.CustomResourceDefinitionNames{Plural: plural},
+			Scope: apiextv1.NamespaceScoped,
+			Versions: []apiextv1.CustomResourceDefinitionVersion{
+				{Name: "v1alpha1", Served: true},
+				{Name: "v1", Served: true, Storage: true},
+			},
+		},
+		Status: apiextv1.CustomResourceDefinitionStatus{StoredVersions: storedVersions},
+	}
+}
+
+func testServiceDefaults(name, namespace string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "consul.hashicorp.com/v1",
+			"kind":       "ServiceDefaults",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": namespace,
+			},
+		},
+	}
+}
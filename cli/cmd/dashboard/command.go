@@ -0,0 +1,306 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dashboard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/posener/complete"
+	"golang.org/x/term"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	"k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+)
+
+const (
+	flagNameNamespace       = "namespace"
+	flagNameRefreshInterval = "refresh-interval"
+	flagNameKubeConfig      = "kubeconfig"
+	flagNameKubeContext     = "context"
+
+	defaultRefreshInterval = 5 * time.Second
+
+	// ANSI escape sequences for managing the terminal screen.
+	ansiAltScreen     = "\033[?1049h"
+	ansiMainScreen    = "\033[?1049l"
+	ansiHideCursor    = "\033[?25l"
+	ansiShowCursor    = "\033[?25h"
+	ansiClearAndHome  = "\033[H\033[2J"
+	terminalLineBreak = "\r\n"
+)
+
+// Command is the command struct for the dashboard command.
+type Command struct {
+	*common.BaseCommand
+
+	helmActionsRunner helm.HelmActionsRunner
+
+	kubernetes kubernetes.Interface
+
+	set *flag.Sets
+
+	flagNamespace       string
+	flagRefreshInterval time.Duration
+	flagKubeConfig      string
+	flagKubeContext     string
+
+	once sync.Once
+	help string
+}
+
+// init sets up flags and help text for the command.
+func (c *Command) init() {
+	c.set = flag.NewSets()
+
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameNamespace,
+		Target:  &c.flagNamespace,
+		Usage:   "The namespace Consul is installed in. If not set, the namespace of the Consul installation is detected.",
+		Aliases: []string{"n"},
+	})
+	f.DurationVar(&flag.DurationVar{
+		Name:    flagNameRefreshInterval,
+		Target:  &c.flagRefreshInterval,
+		Default: defaultRefreshInterval,
+		Usage:   "How often the dashboard is refreshed.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameKubeConfig,
+		Aliases: []string{"c"},
+		Target:  &c.flagKubeConfig,
+		Default: "",
+		Usage:   "Set the path to kubeconfig file.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameKubeContext,
+		Target:  &c.flagKubeContext,
+		Default: "",
+		Usage:   "Set the Kubernetes context to use.",
+	})
+
+	c.help = c.set.Help()
+}
+
+// Run shows the dashboard until the user quits. When not attached to a
+// terminal, a single snapshot is printed instead.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	if c.helmActionsRunner == nil {
+		c.helmActionsRunner = &helm.ActionRunner{}
+	}
+
+	c.Log.ResetNamed("dashboard")
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.set.Parse(args); err != nil {
+		c.UI.Output("Error parsing arguments: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if err := c.validateFlags(); err != nil {
+		c.UI.Output("Invalid argument: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	settings := helmCLI.New()
+	if c.flagKubeConfig != "" {
+		settings.KubeConfig = c.flagKubeConfig
+	}
+	if c.flagKubeContext != "" {
+		settings.KubeContext = c.flagKubeContext
+	}
+
+	if c.kubernetes == nil {
+		if err := c.initKubernetes(settings); err != nil {
+			c.UI.Output("Error initializing Kubernetes client: %v", err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	}
+
+	namespace, err := c.namespace(settings)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	v := &view{namespace: namespace}
+	if !c.UI.Interactive() || !isatty.IsTerminal(os.Stdout.Fd()) {
+		s, err := collectSnapshot(c.Ctx, c.kubernetes, namespace)
+		if err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		v.update(s, nil)
+		c.UI.Output("%s", strings.TrimSuffix(v.render(false), "\n"))
+		return 0
+	}
+
+	stdout, _, err := c.UI.OutputWriters()
+	if err != nil {
+		c.UI.Output("Error getting output writer: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	if err := c.runInteractive(stdout, v); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	return 0
+}
+
+// runInteractive redraws the dashboard each refresh interval and on every
+// key press until the user quits or the command's context is cancelled.
+func (c *Command) runInteractive(out io.Writer, v *view) error {
+	fd := int(os.Stdin.Fd())
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("error configuring terminal: %w", err)
+	}
+	fmt.Fprint(out, ansiAltScreen+ansiHideCursor)
+	defer func() {
+		fmt.Fprint(out, ansiShowCursor+ansiMainScreen)
+		_ = term.Restore(fd, oldState)
+	}()
+
+	// Key presses are read in the background since reads from stdin block.
+	keys := make(chan key)
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			for _, k := range parseKeys(buf[:n]) {
+				select {
+				case keys <- k:
+				case <-c.Ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	draw := func() {
+		if width, height, err := term.GetSize(fd); err == nil {
+			v.width, v.height = width, height
+		}
+		screen := strings.ReplaceAll(v.render(true), "\n", terminalLineBreak)
+		fmt.Fprint(out, ansiClearAndHome+screen)
+	}
+	refresh := func() {
+		v.update(collectSnapshot(c.Ctx, c.kubernetes, v.namespace))
+		draw()
+	}
+
+	draw()
+	refresh()
+	ticker := time.NewTicker(c.flagRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Ctx.Done():
+			return nil
+		case <-ticker.C:
+			refresh()
+		case k := <-keys:
+			quit, refreshNow := v.handleKey(k)
+			if quit {
+				return nil
+			}
+			if refreshNow {
+				refresh()
+			} else {
+				draw()
+			}
+		}
+	}
+}
+
+// validateFlags ensures that the flags passed in by the user can be used.
+func (c *Command) validateFlags() error {
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if errs := validation.ValidateNamespaceName(c.flagNamespace, false); c.flagNamespace != "" && len(errs) > 0 {
+		return fmt.Errorf("invalid namespace name passed for -namespace/-n: %v", strings.Join(errs, "; "))
+	}
+	if c.flagRefreshInterval <= 0 {
+		return fmt.Errorf("-%s must be greater than 0", flagNameRefreshInterval)
+	}
+	return nil
+}
+
+// namespace returns the namespace of the Consul installation, either from the
+// -namespace flag or by looking up the Helm release.
+func (c *Command) namespace(settings *helmCLI.EnvSettings) (string, error) {
+	if c.flagNamespace != "" {
+		return c.flagNamespace, nil
+	}
+	_, _, namespace, err := c.helmActionsRunner.CheckForInstallations(&helm.CheckForInstallationsOptions{
+		Settings:    settings,
+		ReleaseName: common.DefaultReleaseName,
+		DebugLog:    func(string, ...interface{}) {},
+	})
+	if err != nil {
+		return "", err
+	}
+	return namespace, nil
+}
+
+// initKubernetes initializes the Kubernetes client.
+func (c *Command) initKubernetes(settings *helmCLI.EnvSettings) error {
+	restConfig, err := settings.RESTClientGetter().ToRESTConfig()
+	if err != nil {
+		return fmt.Errorf("error retrieving Kubernetes authentication %v", err)
+	}
+	if c.kubernetes, err = kubernetes.NewForConfig(restConfig); err != nil {
+		return fmt.Errorf("error creating Kubernetes client %v", err)
+	}
+	return nil
+}
+
+// Help returns a description of the command and how it is used.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return fmt.Sprintf("%s\n\nUsage: consul-k8s dashboard [flags]\n\n%s", c.Synopsis(), c.help)
+}
+
+// Synopsis returns a one-line command summary.
+func (c *Command) Synopsis() string {
+	return "Show a live dashboard of a Consul installation on Kubernetes."
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command. The map key for the Flags map should be the
+// complete flag such as "-foo" or "--foo".
+func (c *Command) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		fmt.Sprintf("-%s", flagNameNamespace):       complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameRefreshInterval): complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameKubeConfig):      complete.PredictFiles("*"),
+		fmt.Sprintf("-%s", flagNameKubeContext):     complete.PredictNothing,
+	}
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+// Since argument completion is not supported, this will return
+// complete.PredictNothing.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
@@ -0,0 +1,250 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dashboard
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	cmnFlag "github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/go-hclog"
+	"github.com/posener/complete"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFlagParsing(t *testing.T) {
+	cases := map[string]struct {
+		args []string
+		out  int
+	}{
+		"Namespace set": {
+			args: []string{"-namespace", "consul"},
+			out:  0,
+		},
+		"Nonexistent flag passed, -foo bar": {
+			args: []string{"-foo", "bar"},
+			out:  1,
+		},
+		"Invalid argument passed, -namespace YOLO": {
+			args: []string{"-namespace", "YOLO"},
+			out:  1,
+		},
+		"Invalid refresh interval, -refresh-interval 0s": {
+			args: []string{"-namespace", "consul", "-refresh-interval", "0s"},
+			out:  1,
+		},
+		"Non-flag argument passed": {
+			args: []string{"-namespace", "consul", "foo"},
+			out:  1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := setupCommand(new(bytes.Buffer))
+			c.kubernetes = fake.NewSimpleClientset()
+			out := c.Run(tc.args)
+			require.Equal(t, tc.out, out)
+		})
+	}
+}
+
+func TestCollectSnapshot(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	createDashboardObjects(t, client)
+
+	s, err := collectSnapshot(context.Background(), client, "consul")
+	require.NoError(t, err)
+
+	require.Len(t, s.components, 2)
+	require.Equal(t, "connect-injector", s.components[0].name)
+	require.Equal(t, 1, s.components[0].ready)
+	require.Equal(t, "server", s.components[1].name)
+	require.Equal(t, 1, s.components[1].ready)
+	require.Len(t, s.components[1].pods, 2)
+
+	require.Equal(t, []serviceCount{
+		{
+			namespace: "default",
+			services:  2,
+			instances: 3,
+			healthy:   2,
+			pods: []podStatus{
+				{name: "api-1", phase: "Running", ready: true},
+				{name: "web-1", phase: "Running", ready: true},
+				{name: "web-2", phase: "Pending"},
+			},
+		},
+	}, s.services)
+
+	require.Len(t, s.gateways, 2)
+	require.Equal(t, "mesh-gateway", s.gateways[0].name)
+	require.Equal(t, "Mesh Gateway", s.gateways[0].kind)
+	require.Equal(t, 1, s.gateways[0].ready)
+	require.Equal(t, 2, s.gateways[0].desired)
+	require.Equal(t, []string{"10.0.0.10"}, s.gateways[0].addresses)
+	require.Len(t, s.gateways[0].pods, 1)
+	require.Equal(t, "api-gateway", s.gateways[1].name)
+	require.Equal(t, "API Gateway", s.gateways[1].kind)
+
+	// The fake clientset's logs don't contain any errors.
+	require.Empty(t, s.errors)
+}
+
+func TestDashboardSnapshotOutput(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := setupCommand(buf)
+	client := fake.NewSimpleClientset()
+	createDashboardObjects(t, client)
+	c.kubernetes = client
+
+	out := c.Run([]string{"-namespace", "consul"})
+	require.Equal(t, 0, out)
+
+	actual := buf.String()
+	for _, expected := range []string{
+		"Consul on Kubernetes dashboard - namespace consul",
+		"connect-injector  1/1 ready",
+		"server            1/2 ready",
+		"default    2         3          2",
+		"mesh-gateway  consul     Mesh Gateway  1/2    10.0.0.10",
+		"No errors found.",
+	} {
+		require.Contains(t, actual, expected)
+	}
+}
+
+func TestTaskCreateCommand_AutocompleteFlags(t *testing.T) {
+	t.Parallel()
+	buf := new(bytes.Buffer)
+	cmd := setupCommand(buf)
+
+	predictor := cmd.AutocompleteFlags()
+
+	// Test that we get the expected number of predictions
+	args := complete.Args{Last: "-"}
+	res := predictor.Predict(args)
+
+	// Grab the list of flags from the Flag object
+	flags := make([]string, 0)
+	cmd.set.VisitSets(func(name string, set *cmnFlag.Set) {
+		set.VisitAll(func(flag *flag.Flag) {
+			flags = append(flags, fmt.Sprintf("-%s", flag.Name))
+		})
+	})
+
+	// Verify that there is a prediction for each flag associated with the command
+	assert.Equal(t, len(flags), len(res))
+	assert.ElementsMatch(t, flags, res, "flags and predictions didn't match, make sure to add "+
+		"new flags to the command AutoCompleteFlags function")
+}
+
+func TestTaskCreateCommand_AutocompleteArgs(t *testing.T) {
+	buf := new(bytes.Buffer)
+	cmd := setupCommand(buf)
+	c := cmd.AutocompleteArgs()
+	assert.Equal(t, complete.PredictNothing, c)
+}
+
+func setupCommand(buf io.Writer) *Command {
+	// Log at a test level to standard out.
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "test",
+		Level:  hclog.Debug,
+		Output: os.Stdout,
+	})
+
+	// Setup and initialize the command struct
+	command := &Command{
+		BaseCommand: &common.BaseCommand{
+			Ctx: context.Background(),
+			Log: log,
+			UI:  terminal.NewUI(context.Background(), buf),
+		},
+	}
+	command.init()
+
+	return command
+}
+
+// createDashboardObjects creates a Consul installation in the consul
+// namespace with two servers, one of which is not ready, a mesh gateway and
+// three injected pods in the default namespace.
+func createDashboardObjects(t *testing.T, client kubernetes.Interface) {
+	t.Helper()
+
+	consulLabels := func(component string) map[string]string {
+		return map[string]string{"app": "consul", "chart": "consul-helm", "component": component}
+	}
+	pods := []v1.Pod{
+		testPod("consul-server-0", "consul", consulLabels("server"), true),
+		testPod("consul-server-1", "consul", consulLabels("server"), false),
+		testPod("consul-connect-injector-abc", "consul", consulLabels("connect-injector"), true),
+		testPod("mesh-gateway-abc", "consul", consulLabels("mesh-gateway"), true),
+		testPod("web-1", "default", map[string]string{"app": "web", "consul.hashicorp.com/connect-inject-status": "injected"}, true),
+		testPod("web-2", "default", map[string]string{"app": "web", "consul.hashicorp.com/connect-inject-status": "injected"}, false),
+		testPod("api-1", "default", map[string]string{"app": "api", "consul.hashicorp.com/connect-inject-status": "injected"}, true),
+	}
+	for _, pod := range pods {
+		_, err := client.CoreV1().Pods(pod.Namespace).Create(context.Background(), &pod, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	replicas := int32(2)
+	deployments := []appsv1.Deployment{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "mesh-gateway", Namespace: "consul", Labels: consulLabels("mesh-gateway")},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: consulLabels("mesh-gateway")},
+			},
+			Status: appsv1.DeploymentStatus{ReadyReplicas: 1},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "api-gateway",
+				Namespace: "default",
+				Labels:    map[string]string{"api-gateway.consul.hashicorp.com/managed": "true"},
+			},
+		},
+	}
+	for _, deployment := range deployments {
+		_, err := client.AppsV1().Deployments(deployment.Namespace).Create(context.Background(), &deployment, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	_, err := client.CoreV1().Services("consul").Create(context.Background(), &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "mesh-gateway", Namespace: "consul"},
+		Spec:       v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer, ClusterIP: "10.96.0.10"},
+		Status: v1.ServiceStatus{
+			LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "10.0.0.10"}}},
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+}
+
+func testPod(name, namespace string, labels map[string]string, ready bool) v1.Pod {
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Status:     v1.PodStatus{Phase: v1.PodPending},
+	}
+	if ready {
+		pod.Status.Phase = v1.PodRunning
+		pod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}
+	}
+	return pod
+}
@@ -0,0 +1,375 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dashboard
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// consulComponentsSelector selects the pods of the Consul installation.
+	consulComponentsSelector = "app=consul,chart=consul-helm"
+
+	// gatewaysSelector selects the mesh, ingress and terminating gateway
+	// deployments of the Consul installation.
+	gatewaysSelector = "component in (ingress-gateway, mesh-gateway, terminating-gateway), chart=consul-helm"
+
+	// apiGatewaysSelector selects the deployments of API gateways which may
+	// be in any namespace.
+	apiGatewaysSelector = "api-gateway.consul.hashicorp.com/managed=true"
+
+	// injectedPodsSelector selects the pods that are part of the service mesh.
+	injectedPodsSelector = "consul.hashicorp.com/connect-inject-status=injected"
+
+	// connectInjectorSelector selects the connect-injector pods whose logs are
+	// searched for reconcile errors.
+	connectInjectorSelector = "app=consul,chart=consul-helm,component=connect-injector"
+
+	// logTailLines is how many lines of each connect-injector pod's logs are
+	// searched for reconcile errors.
+	logTailLines = 500
+
+	// maxRecentErrors is the number of reconcile errors to show.
+	maxRecentErrors = 20
+)
+
+// gatewayComponents are the values of the component label used by gateways.
+// Their pods are reported under gateways rather than components.
+var gatewayComponents = map[string]string{
+	"ingress-gateway":     "Ingress Gateway",
+	"mesh-gateway":        "Mesh Gateway",
+	"terminating-gateway": "Terminating Gateway",
+}
+
+// snapshot is the state of a Consul installation at a point in time.
+type snapshot struct {
+	components []componentStatus
+	services   []serviceCount
+	errors     []reconcileError
+	gateways   []gatewayStatus
+	collected  time.Time
+}
+
+// componentStatus is the health of one component of the Consul installation,
+// e.g. the servers or the connect-injector.
+type componentStatus struct {
+	name  string
+	ready int
+	pods  []podStatus
+}
+
+// serviceCount is the number of service instances registered from the
+// injected pods in a Kubernetes namespace.
+type serviceCount struct {
+	namespace string
+	services  int
+	instances int
+	healthy   int
+	pods      []podStatus
+}
+
+// reconcileError is an error logged by the connect-injector.
+type reconcileError struct {
+	pod     string
+	message string
+}
+
+// gatewayStatus is the status of a gateway deployment.
+type gatewayStatus struct {
+	name      string
+	namespace string
+	kind      string
+	ready     int
+	desired   int
+	addresses []string
+	pods      []podStatus
+}
+
+// podStatus is the summary of a pod shown when drilling down.
+type podStatus struct {
+	name     string
+	phase    string
+	ready    bool
+	restarts int32
+	node     string
+}
+
+// collectSnapshot reads the state of the Consul installation in namespace
+// from Kubernetes.
+func collectSnapshot(ctx context.Context, client kubernetes.Interface, namespace string) (*snapshot, error) {
+	s := &snapshot{collected: time.Now()}
+
+	var err error
+	if s.components, err = collectComponents(ctx, client, namespace); err != nil {
+		return nil, fmt.Errorf("error fetching Consul components: %w", err)
+	}
+	if s.services, err = collectServices(ctx, client); err != nil {
+		return nil, fmt.Errorf("error fetching injected pods: %w", err)
+	}
+	if s.errors, err = collectReconcileErrors(ctx, client, namespace); err != nil {
+		return nil, fmt.Errorf("error fetching connect-injector logs: %w", err)
+	}
+	if s.gateways, err = collectGateways(ctx, client, namespace); err != nil {
+		return nil, fmt.Errorf("error fetching gateways: %w", err)
+	}
+
+	return s, nil
+}
+
+// collectComponents groups the pods of the Consul installation by their
+// component label.
+func collectComponents(ctx context.Context, client kubernetes.Interface, namespace string) ([]componentStatus, error) {
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: consulComponentsSelector})
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*componentStatus)
+	for _, pod := range pods.Items {
+		name := pod.Labels["component"]
+		if _, ok := gatewayComponents[name]; ok || name == "" {
+			continue
+		}
+		component, ok := byName[name]
+		if !ok {
+			component = &componentStatus{name: name}
+			byName[name] = component
+		}
+		status := newPodStatus(pod)
+		if status.ready {
+			component.ready++
+		}
+		component.pods = append(component.pods, status)
+	}
+
+	components := make([]componentStatus, 0, len(byName))
+	for _, component := range byName {
+		sortPods(component.pods)
+		components = append(components, *component)
+	}
+	sort.Slice(components, func(i, j int) bool { return components[i].name < components[j].name })
+	return components, nil
+}
+
+// collectServices counts the injected pods in each Kubernetes namespace. The
+// endpoints controller registers a service instance for each of them.
+func collectServices(ctx context.Context, client kubernetes.Interface) ([]serviceCount, error) {
+	pods, err := client.CoreV1().Pods("").List(ctx, metav1.ListOptions{LabelSelector: injectedPodsSelector})
+	if err != nil {
+		return nil, err
+	}
+
+	byNamespace := make(map[string]*serviceCount)
+	servicesByNamespace := make(map[string]map[string]struct{})
+	for _, pod := range pods.Items {
+		count, ok := byNamespace[pod.Namespace]
+		if !ok {
+			count = &serviceCount{namespace: pod.Namespace}
+			byNamespace[pod.Namespace] = count
+			servicesByNamespace[pod.Namespace] = make(map[string]struct{})
+		}
+		status := newPodStatus(pod)
+		count.instances++
+		if status.ready {
+			count.healthy++
+		}
+		count.pods = append(count.pods, status)
+		if name := serviceName(pod); name != "" {
+			servicesByNamespace[pod.Namespace][name] = struct{}{}
+		}
+	}
+
+	counts := make([]serviceCount, 0, len(byNamespace))
+	for ns, count := range byNamespace {
+		count.services = len(servicesByNamespace[ns])
+		sortPods(count.pods)
+		counts = append(counts, *count)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].namespace < counts[j].namespace })
+	return counts, nil
+}
+
+// serviceName returns the name of the Consul service a pod is registered as.
+// Pods without an explicit service annotation are named after their app label,
+// falling back to the pod's owner.
+func serviceName(pod v1.Pod) string {
+	if name := pod.Annotations["consul.hashicorp.com/connect-service"]; name != "" {
+		return name
+	}
+	if name := pod.Labels["app"]; name != "" {
+		return name
+	}
+	if len(pod.OwnerReferences) > 0 {
+		return pod.OwnerReferences[0].Name
+	}
+	return pod.Name
+}
+
+// collectReconcileErrors returns the most recent errors logged by the
+// connect-injector pods, newest first.
+func collectReconcileErrors(ctx context.Context, client kubernetes.Interface, namespace string) ([]reconcileError, error) {
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: connectInjectorSelector})
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []reconcileError
+	tailLines := int64(logTailLines)
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != v1.PodRunning {
+			continue
+		}
+		logs, err := client.CoreV1().Pods(namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+			Container: "sidecar-injector",
+			TailLines: &tailLines,
+		}).DoRaw(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range errorLines(logs) {
+			errs = append(errs, reconcileError{pod: pod.Name, message: line})
+		}
+	}
+
+	// Log lines start with a timestamp, so sorting them in reverse puts the
+	// most recent errors first across all pods.
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].message > errs[j].message })
+	if len(errs) > maxRecentErrors {
+		errs = errs[:maxRecentErrors]
+	}
+	return errs, nil
+}
+
+// errorLines returns the error level lines from connect-injector logs in
+// either the text or JSON log format.
+func errorLines(logs []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(logs))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.Contains(line, "\tERROR\t") || strings.Contains(line, `"level":"error"`) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// collectGateways returns the status of the gateway deployments of the Consul
+// installation and of API gateways in any namespace.
+func collectGateways(ctx context.Context, client kubernetes.Interface, namespace string) ([]gatewayStatus, error) {
+	deployments, err := client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{LabelSelector: gatewaysSelector})
+	if err != nil {
+		return nil, err
+	}
+	apiGateways, err := client.AppsV1().Deployments("").List(ctx, metav1.ListOptions{LabelSelector: apiGatewaysSelector})
+	if err != nil {
+		return nil, err
+	}
+
+	var gateways []gatewayStatus
+	for _, deployment := range append(deployments.Items, apiGateways.Items...) {
+		kind := gatewayComponents[deployment.Labels["component"]]
+		if deployment.Labels["api-gateway.consul.hashicorp.com/managed"] == "true" {
+			kind = "API Gateway"
+		}
+		gateway, err := newGatewayStatus(ctx, client, deployment, kind)
+		if err != nil {
+			return nil, err
+		}
+		gateways = append(gateways, gateway)
+	}
+
+	sort.Slice(gateways, func(i, j int) bool {
+		if gateways[i].namespace != gateways[j].namespace {
+			return gateways[i].namespace < gateways[j].namespace
+		}
+		return gateways[i].name < gateways[j].name
+	})
+	return gateways, nil
+}
+
+func newGatewayStatus(ctx context.Context, client kubernetes.Interface, deployment appsv1.Deployment, kind string) (gatewayStatus, error) {
+	gateway := gatewayStatus{
+		name:      deployment.Name,
+		namespace: deployment.Namespace,
+		kind:      kind,
+		ready:     int(deployment.Status.ReadyReplicas),
+		desired:   1,
+	}
+	if deployment.Spec.Replicas != nil {
+		gateway.desired = int(*deployment.Spec.Replicas)
+	}
+
+	if deployment.Spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+		if err != nil {
+			return gatewayStatus{}, err
+		}
+		pods, err := client.CoreV1().Pods(deployment.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return gatewayStatus{}, err
+		}
+		for _, pod := range pods.Items {
+			gateway.pods = append(gateway.pods, newPodStatus(pod))
+		}
+		sortPods(gateway.pods)
+	}
+
+	// Gateways are exposed by a Service of the same name.
+	svc, err := client.CoreV1().Services(deployment.Namespace).Get(ctx, deployment.Name, metav1.GetOptions{})
+	if err == nil {
+		gateway.addresses = serviceAddresses(svc)
+	}
+
+	return gateway, nil
+}
+
+// serviceAddresses returns the addresses a gateway can be reached on.
+func serviceAddresses(svc *v1.Service) []string {
+	var addresses []string
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			addresses = append(addresses, ingress.IP)
+		}
+		if ingress.Hostname != "" {
+			addresses = append(addresses, ingress.Hostname)
+		}
+	}
+	if len(addresses) == 0 && svc.Spec.ClusterIP != "" && svc.Spec.ClusterIP != v1.ClusterIPNone {
+		addresses = append(addresses, svc.Spec.ClusterIP)
+	}
+	return addresses
+}
+
+func newPodStatus(pod v1.Pod) podStatus {
+	status := podStatus{
+		name:  pod.Name,
+		phase: string(pod.Status.Phase),
+		node:  pod.Spec.NodeName,
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == v1.PodReady && cond.Status == v1.ConditionTrue {
+			status.ready = true
+		}
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		status.restarts += cs.RestartCount
+	}
+	return status
+}
+
+func sortPods(pods []podStatus) {
+	sort.Slice(pods, func(i, j int) bool { return pods[i].name < pods[j].name })
+}
@@ -0,0 +1,456 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dashboard
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// ANSI escape sequences used when rendering to a terminal.
+const (
+	ansiReset   = "\033[0m"
+	ansiBold    = "\033[1m"
+	ansiReverse = "\033[7m"
+	ansiRed     = "\033[31m"
+	ansiGreen   = "\033[32m"
+	ansiYellow  = "\033[33m"
+)
+
+// key is a key press the dashboard responds to.
+type key int
+
+const (
+	keyNone key = iota
+	keyUp
+	keyDown
+	keyEnter
+	keyBack
+	keyRefresh
+	keyQuit
+)
+
+// itemKind is the section of the dashboard an item belongs to.
+type itemKind int
+
+const (
+	itemComponent itemKind = iota
+	itemService
+	itemGateway
+	itemError
+)
+
+// item is a selectable row of the dashboard.
+type item struct {
+	kind  itemKind
+	index int
+}
+
+// style is how a rendered line is highlighted.
+type style int
+
+const (
+	stylePlain style = iota
+	styleHeader
+	styleHealthy
+	styleUnhealthy
+	styleWarning
+)
+
+// line is a rendered line of the dashboard before styling.
+type line struct {
+	text     string
+	style    style
+	selected bool
+}
+
+// view is the state of the dashboard: the latest snapshot and what the user
+// has selected.
+type view struct {
+	namespace string
+	snapshot  *snapshot
+	// err is the error from the latest refresh, if it failed. The previous
+	// snapshot is still shown.
+	err error
+
+	selected int
+	detail   bool
+
+	width  int
+	height int
+}
+
+// update replaces the snapshot, keeping the selection in range.
+func (v *view) update(s *snapshot, err error) {
+	v.err = err
+	if s == nil {
+		return
+	}
+	v.snapshot = s
+	if n := len(v.items()); v.selected >= n {
+		v.selected = n - 1
+	}
+	if v.selected < 0 {
+		v.selected = 0
+	}
+	if len(v.items()) == 0 {
+		v.detail = false
+	}
+}
+
+// handleKey updates the view for a key press. It returns whether the
+// dashboard should quit and whether it should refresh immediately.
+func (v *view) handleKey(k key) (quit, refresh bool) {
+	switch k {
+	case keyQuit:
+		return true, false
+	case keyRefresh:
+		return false, true
+	case keyUp:
+		if !v.detail && v.selected > 0 {
+			v.selected--
+		}
+	case keyDown:
+		if !v.detail && v.selected < len(v.items())-1 {
+			v.selected++
+		}
+	case keyEnter:
+		if len(v.items()) > 0 {
+			v.detail = true
+		}
+	case keyBack:
+		v.detail = false
+	}
+	return false, false
+}
+
+// items returns the selectable rows in the order they are rendered.
+func (v *view) items() []item {
+	if v.snapshot == nil {
+		return nil
+	}
+	var items []item
+	for i := range v.snapshot.components {
+		items = append(items, item{kind: itemComponent, index: i})
+	}
+	for i := range v.snapshot.services {
+		items = append(items, item{kind: itemService, index: i})
+	}
+	for i := range v.snapshot.gateways {
+		items = append(items, item{kind: itemGateway, index: i})
+	}
+	for i := range v.snapshot.errors {
+		items = append(items, item{kind: itemError, index: i})
+	}
+	return items
+}
+
+// render returns the dashboard as text. When color is true, lines are
+// highlighted with ANSI escape sequences and the selected row is shown.
+func (v *view) render(color bool) string {
+	var lines []line
+	if v.detail {
+		lines = v.detailLines()
+	} else {
+		lines = v.summaryLines(color)
+	}
+
+	header := []line{{text: v.title(), style: styleHeader}}
+	if v.err != nil {
+		header = append(header, line{text: "Refresh failed: " + v.err.Error(), style: styleUnhealthy})
+	}
+	header = append(header, line{})
+
+	var footer []line
+	if color {
+		help := "up/down: select  enter: details  r: refresh  q: quit"
+		if v.detail {
+			help = "esc: back  r: refresh  q: quit"
+		}
+		footer = []line{{}, {text: help, style: styleHeader}}
+	}
+
+	lines = append(header, v.scroll(lines, len(header)+len(footer))...)
+	lines = append(lines, footer...)
+
+	var buf bytes.Buffer
+	for _, l := range lines {
+		buf.WriteString(v.format(l, color))
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+func (v *view) title() string {
+	title := fmt.Sprintf("Consul on Kubernetes dashboard - namespace %s", v.namespace)
+	if v.snapshot != nil {
+		title += " - updated " + v.snapshot.collected.Format("15:04:05")
+	}
+	return title
+}
+
+// scroll returns the window of body lines that fits on the screen alongside
+// reserved lines of header and footer, keeping the selected line visible.
+func (v *view) scroll(body []line, reserved int) []line {
+	if v.height <= 0 || len(body)+reserved <= v.height {
+		return body
+	}
+	rows := v.height - reserved
+	if rows < 1 {
+		rows = 1
+	}
+	selected := 0
+	for i, l := range body {
+		if l.selected {
+			selected = i
+		}
+	}
+	start := selected - rows + 1
+	if start < 0 {
+		start = 0
+	}
+	return body[start : start+rows]
+}
+
+func (v *view) format(l line, color bool) string {
+	text := l.text
+	if v.width > 0 && len([]rune(text)) > v.width {
+		text = string([]rune(text)[:v.width])
+	}
+	if !color {
+		return text
+	}
+	var prefix string
+	switch l.style {
+	case styleHeader:
+		prefix = ansiBold
+	case styleHealthy:
+		prefix = ansiGreen
+	case styleUnhealthy:
+		prefix = ansiRed
+	case styleWarning:
+		prefix = ansiYellow
+	}
+	if l.selected {
+		prefix += ansiReverse
+	}
+	if prefix == "" {
+		return text
+	}
+	return prefix + text + ansiReset
+}
+
+// summaryLines renders every section of the dashboard.
+func (v *view) summaryLines(color bool) []line {
+	if v.snapshot == nil {
+		return []line{{text: "Loading..."}}
+	}
+	s := v.snapshot
+	selected := -1
+	if color {
+		selected = v.selected
+	}
+	offset := 0
+
+	var lines []line
+	lines = append(lines, line{text: "Components", style: styleHeader})
+	if len(s.components) == 0 {
+		lines = append(lines, line{text: "  No Consul components found."})
+	} else {
+		var rows [][]string
+		var styles []style
+		for _, c := range s.components {
+			rows = append(rows, []string{c.name, fmt.Sprintf("%d/%d ready", c.ready, len(c.pods))})
+			styles = append(styles, healthStyle(c.ready, len(c.pods)))
+		}
+		lines = append(lines, table(nil, rows, styles, selected-offset)...)
+	}
+	offset += len(s.components)
+
+	lines = append(lines, line{}, line{text: "Service Instances", style: styleHeader})
+	if len(s.services) == 0 {
+		lines = append(lines, line{text: "  No injected pods found."})
+	} else {
+		var rows [][]string
+		var styles []style
+		for _, c := range s.services {
+			rows = append(rows, []string{c.namespace, strconv.Itoa(c.services), strconv.Itoa(c.instances), strconv.Itoa(c.healthy)})
+			styles = append(styles, healthStyle(c.healthy, c.instances))
+		}
+		lines = append(lines, table([]string{"NAMESPACE", "SERVICES", "INSTANCES", "HEALTHY"}, rows, styles, selected-offset)...)
+	}
+	offset += len(s.services)
+
+	lines = append(lines, line{}, line{text: "Gateways", style: styleHeader})
+	if len(s.gateways) == 0 {
+		lines = append(lines, line{text: "  No gateways found."})
+	} else {
+		var rows [][]string
+		var styles []style
+		for _, g := range s.gateways {
+			addresses := strings.Join(g.addresses, ",")
+			if addresses == "" {
+				addresses = "-"
+			}
+			rows = append(rows, []string{g.name, g.namespace, g.kind, fmt.Sprintf("%d/%d", g.ready, g.desired), addresses})
+			styles = append(styles, healthStyle(g.ready, g.desired))
+		}
+		lines = append(lines, table([]string{"NAME", "NAMESPACE", "KIND", "READY", "ADDRESSES"}, rows, styles, selected-offset)...)
+	}
+	offset += len(s.gateways)
+
+	lines = append(lines, line{}, line{text: "Recent Reconcile Errors", style: styleHeader})
+	if len(s.errors) == 0 {
+		lines = append(lines, line{text: "  No errors found.", style: styleHealthy})
+	} else {
+		for i, e := range s.errors {
+			lines = append(lines, line{
+				text:     fmt.Sprintf("  %s: %s", e.pod, e.message),
+				style:    styleWarning,
+				selected: selected-offset == i,
+			})
+		}
+	}
+
+	return lines
+}
+
+// detailLines renders the selected item.
+func (v *view) detailLines() []line {
+	items := v.items()
+	if v.selected >= len(items) {
+		return nil
+	}
+	s := v.snapshot
+	it := items[v.selected]
+
+	switch it.kind {
+	case itemComponent:
+		c := s.components[it.index]
+		return append([]line{{text: "Component " + c.name, style: styleHeader}}, podLines(c.pods)...)
+	case itemService:
+		c := s.services[it.index]
+		return append([]line{{text: "Injected pods in namespace " + c.namespace, style: styleHeader}}, podLines(c.pods)...)
+	case itemGateway:
+		g := s.gateways[it.index]
+		lines := []line{
+			{text: fmt.Sprintf("%s %s/%s", g.kind, g.namespace, g.name), style: styleHeader},
+			{text: "  Addresses: " + strings.Join(g.addresses, ", ")},
+			{},
+		}
+		return append(lines, podLines(g.pods)...)
+	case itemError:
+		e := s.errors[it.index]
+		lines := []line{{text: "Error logged by " + e.pod, style: styleHeader}, {}}
+		for _, l := range wrap(e.message, v.width) {
+			lines = append(lines, line{text: l, style: styleWarning})
+		}
+		return lines
+	}
+	return nil
+}
+
+func podLines(pods []podStatus) []line {
+	if len(pods) == 0 {
+		return []line{{text: "  No pods found."}}
+	}
+	var rows [][]string
+	var styles []style
+	for _, p := range pods {
+		rows = append(rows, []string{p.name, p.phase, strconv.FormatBool(p.ready), strconv.Itoa(int(p.restarts)), p.node})
+		if p.ready {
+			styles = append(styles, styleHealthy)
+		} else {
+			styles = append(styles, styleUnhealthy)
+		}
+	}
+	return table([]string{"NAME", "PHASE", "READY", "RESTARTS", "NODE"}, rows, styles, -1)
+}
+
+// table aligns rows into columns. The row at index selected is marked as
+// selected.
+func table(headers []string, rows [][]string, styles []style, selected int) []line {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	if headers != nil {
+		fmt.Fprintln(tw, "  "+strings.Join(headers, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(tw, "  "+strings.Join(row, "\t"))
+	}
+	tw.Flush()
+
+	texts := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	var lines []line
+	if headers != nil {
+		lines = append(lines, line{text: texts[0]})
+		texts = texts[1:]
+	}
+	for i, text := range texts {
+		lines = append(lines, line{text: strings.TrimRight(text, " "), style: styles[i], selected: i == selected})
+	}
+	return lines
+}
+
+func healthStyle(ready, total int) style {
+	if ready < total || total == 0 {
+		return styleUnhealthy
+	}
+	return styleHealthy
+}
+
+// wrap splits text into lines of at most width runes.
+func wrap(text string, width int) []string {
+	runes := []rune(text)
+	if width <= 0 || len(runes) <= width {
+		return []string{text}
+	}
+	var lines []string
+	for len(runes) > width {
+		lines = append(lines, string(runes[:width]))
+		runes = runes[width:]
+	}
+	return append(lines, string(runes))
+}
+
+// parseKeys converts raw terminal input into key presses.
+func parseKeys(input []byte) []key {
+	var keys []key
+	for i := 0; i < len(input); i++ {
+		switch b := input[i]; b {
+		case 'q', 3: // 3 is Ctrl-C.
+			keys = append(keys, keyQuit)
+		case 'k':
+			keys = append(keys, keyUp)
+		case 'j':
+			keys = append(keys, keyDown)
+		case 'r':
+			keys = append(keys, keyRefresh)
+		case '\r', '\n':
+			keys = append(keys, keyEnter)
+		case 127, 'h':
+			keys = append(keys, keyBack)
+		case 27: // Escape, which may start an arrow key sequence.
+			if i+2 < len(input) && input[i+1] == '[' {
+				switch input[i+2] {
+				case 'A':
+					keys = append(keys, keyUp)
+				case 'B':
+					keys = append(keys, keyDown)
+				case 'C':
+					keys = append(keys, keyEnter)
+				case 'D':
+					keys = append(keys, keyBack)
+				}
+				i += 2
+				continue
+			}
+			keys = append(keys, keyBack)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,190 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dashboard
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeys(t *testing.T) {
+	cases := map[string]struct {
+		input []byte
+		exp   []key
+	}{
+		"quit":         {input: []byte("q"), exp: []key{keyQuit}},
+		"ctrl-c":       {input: []byte{3}, exp: []key{keyQuit}},
+		"vim keys":     {input: []byte("jkh"), exp: []key{keyDown, keyUp, keyBack}},
+		"arrow keys":   {input: []byte("\033[A\033[B\033[C\033[D"), exp: []key{keyUp, keyDown, keyEnter, keyBack}},
+		"enter":        {input: []byte("\r"), exp: []key{keyEnter}},
+		"escape":       {input: []byte{27}, exp: []key{keyBack}},
+		"refresh":      {input: []byte("r"), exp: []key{keyRefresh}},
+		"unknown keys": {input: []byte("xyz"), exp: nil},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.exp, parseKeys(c.input))
+		})
+	}
+}
+
+func TestView_handleKey(t *testing.T) {
+	v := &view{}
+	v.update(testSnapshot(), nil)
+	require.Len(t, v.items(), 4)
+
+	// Selection stays within the items.
+	v.handleKey(keyUp)
+	require.Equal(t, 0, v.selected)
+	for i := 0; i < 10; i++ {
+		v.handleKey(keyDown)
+	}
+	require.Equal(t, 3, v.selected)
+
+	// Drilling down into the selected item doesn't move the selection.
+	v.handleKey(keyEnter)
+	require.True(t, v.detail)
+	v.handleKey(keyUp)
+	require.Equal(t, 3, v.selected)
+	v.handleKey(keyBack)
+	require.False(t, v.detail)
+
+	quit, refresh := v.handleKey(keyRefresh)
+	require.False(t, quit)
+	require.True(t, refresh)
+	quit, _ = v.handleKey(keyQuit)
+	require.True(t, quit)
+
+	// The selection is kept in range when the number of items shrinks.
+	v.update(&snapshot{components: testSnapshot().components}, nil)
+	require.Equal(t, 0, v.selected)
+}
+
+func TestView_update(t *testing.T) {
+	v := &view{}
+	v.update(testSnapshot(), nil)
+
+	// A failed refresh keeps showing the previous snapshot.
+	v.update(nil, errors.New("connection refused"))
+	require.NotNil(t, v.snapshot)
+	out := v.render(false)
+	require.Contains(t, out, "Refresh failed: connection refused")
+	require.Contains(t, out, "server  3/3 ready")
+}
+
+func TestView_render(t *testing.T) {
+	v := &view{namespace: "consul"}
+	v.update(testSnapshot(), nil)
+
+	out := v.render(false)
+	require.Equal(t, `Consul on Kubernetes dashboard - namespace consul - updated 10:30:00
+
+Components
+  server  3/3 ready
+
+Service Instances
+  NAMESPACE  SERVICES  INSTANCES  HEALTHY
+  default    1         2          1
+
+Gateways
+  NAME          NAMESPACE  KIND          READY  ADDRESSES
+  mesh-gateway  consul     Mesh Gateway  0/1    -
+
+Recent Reconcile Errors
+  consul-connect-injector-abc: 2023-06-01T10:29:00.000Z	ERROR	Reconciler error
+`, out)
+	require.NotContains(t, out, ansiReset)
+
+	// In the terminal the selected row is highlighted.
+	v.selected = 2
+	out = v.render(true)
+	require.Contains(t, out, ansiRed+ansiReverse+"  mesh-gateway  consul     Mesh Gateway  0/1    -"+ansiReset)
+	require.Contains(t, out, "up/down: select")
+}
+
+func TestView_renderDetail(t *testing.T) {
+	v := &view{namespace: "consul", width: 20}
+	v.update(testSnapshot(), nil)
+
+	v.handleKey(keyDown)
+	v.handleKey(keyEnter)
+	out := v.render(false)
+	require.Contains(t, out, "Injected pods in na")
+	require.Contains(t, out, "  web-1  Running  tr")
+
+	// Errors are wrapped rather than truncated.
+	v.handleKey(keyBack)
+	v.handleKey(keyDown)
+	v.handleKey(keyDown)
+	v.handleKey(keyEnter)
+	out = v.render(false)
+	require.Contains(t, out, "2023-06-01T10:29:00.\n000Z\tERROR\tReconcile\nr error\n")
+}
+
+func TestView_scroll(t *testing.T) {
+	v := &view{namespace: "consul", height: 6}
+	s := testSnapshot()
+	for i := 0; i < 10; i++ {
+		s.errors = append(s.errors, reconcileError{pod: "pod", message: strings.Repeat("x", i)})
+	}
+	v.update(s, nil)
+	v.selected = len(v.items()) - 1
+
+	lines := strings.Split(strings.TrimSuffix(v.render(true), "\n"), "\n")
+	require.Len(t, lines, 6)
+	require.Contains(t, lines[len(lines)-3], "pod: xxxxxxxxx")
+}
+
+func TestErrorLines(t *testing.T) {
+	logs := strings.Join([]string{
+		"2023-06-01T10:28:00.000Z\tINFO\tcontroller.endpoints\tretrieved",
+		"2023-06-01T10:29:00.000Z\tERROR\tReconciler error\t{\"controller\": \"endpoints\"}",
+		`{"level":"error","ts":"2023-06-01T10:30:00.000Z","msg":"Reconciler error"}`,
+		`{"level":"info","ts":"2023-06-01T10:31:00.000Z","msg":"done"}`,
+	}, "\n")
+
+	require.Equal(t, []string{
+		"2023-06-01T10:29:00.000Z\tERROR\tReconciler error\t{\"controller\": \"endpoints\"}",
+		`{"level":"error","ts":"2023-06-01T10:30:00.000Z","msg":"Reconciler error"}`,
+	}, errorLines([]byte(logs)))
+}
+
+func testSnapshot() *snapshot {
+	return &snapshot{
+		collected: time.Date(2023, 6, 1, 10, 30, 0, 0, time.UTC),
+		components: []componentStatus{
+			{
+				name:  "server",
+				ready: 3,
+				pods: []podStatus{
+					{name: "consul-server-0", phase: "Running", ready: true},
+					{name: "consul-server-1", phase: "Running", ready: true},
+					{name: "consul-server-2", phase: "Running", ready: true},
+				},
+			},
+		},
+		services: []serviceCount{
+			{
+				namespace: "default",
+				services:  1,
+				instances: 2,
+				healthy:   1,
+				pods: []podStatus{
+					{name: "web-1", phase: "Running", ready: true},
+					{name: "web-2", phase: "Pending"},
+				},
+			},
+		},
+		gateways: []gatewayStatus{
+			{name: "mesh-gateway", namespace: "consul", kind: "Mesh Gateway", desired: 1},
+		},
+		errors: []reconcileError{
+			{pod: "consul-connect-injector-abc", message: "2023-06-01T10:29:00.000Z\tERROR\tReconciler error"},
+		},
+	}
+}
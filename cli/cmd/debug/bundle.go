@@ -0,0 +1,118 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package debug
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// redacted replaces sensitive values in the bundle.
+const redacted = "[redacted]"
+
+var (
+	// privateKeyPattern matches PEM encoded private keys.
+	privateKeyPattern = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)
+
+	// sensitiveValuePattern matches the values of keys that hold credentials in JSON, YAML,
+	// and key=value log fields, e.g. "token": "...", "encrypt": "..." or secret_id=....
+	sensitiveValuePattern = regexp.MustCompile(`(?i)("?[a-z_\-.]*(?:token|secret_?id|password|license|encrypt|encrypt(?:ion)?_?key|private_?key)"?\s*[:=]\s*"?)([^"\s,}]+)`)
+
+	// tokensBlockPattern matches the tokens block of a Consul agent's config in JSON or HCL,
+	// e.g. "tokens": {"agent": "..."}, whose keys are named after the token's purpose.
+	tokensBlockPattern = regexp.MustCompile(`(?i)"?tokens"?\s*[:=]?\s*\{[^}]*\}`)
+
+	// tokensBlockValuePattern matches the values in a tokens block.
+	tokensBlockValuePattern = regexp.MustCompile(`(?i)("?[a-z_]+"?\s*[:=]\s*"?)([^"\s,{}]+)`)
+
+	// bearerTokenPattern matches bearer tokens in HTTP headers.
+	bearerTokenPattern = regexp.MustCompile(`(?i)(bearer\s+)[a-z0-9\-._~+/]+=*`)
+)
+
+// redact replaces private keys, tokens and other credentials in the data so that the
+// bundle can be attached to support tickets.
+func redact(data []byte) []byte {
+	data = privateKeyPattern.ReplaceAll(data, []byte(redacted))
+	data = tokensBlockPattern.ReplaceAllFunc(data, func(block []byte) []byte {
+		return tokensBlockValuePattern.ReplaceAll(block, []byte("${1}"+redacted))
+	})
+	data = sensitiveValuePattern.ReplaceAll(data, []byte("${1}"+redacted))
+	return bearerTokenPattern.ReplaceAll(data, []byte("${1}"+redacted))
+}
+
+// bundle holds the files of a support bundle and the errors that occurred while
+// gathering them. Collection is best effort, so errors are added to the bundle
+// rather than stopping the command.
+type bundle struct {
+	files  map[string][]byte
+	errors []string
+}
+
+func newBundle() *bundle {
+	return &bundle{files: make(map[string][]byte)}
+}
+
+// add redacts the data and adds it to the bundle at the path.
+func (b *bundle) add(name string, data []byte) {
+	b.files[name] = redact(data)
+}
+
+// addError records an error that occurred while gathering the bundle.
+func (b *bundle) addError(format string, args ...interface{}) {
+	b.errors = append(b.errors, fmt.Sprintf(format, args...))
+}
+
+// writeTarball writes the files of the bundle, and the errors if there are any, to a
+// gzipped tarball at the path. The files are put in a directory named after the tarball.
+func (b *bundle) writeTarball(filename string, modTime time.Time) error {
+	if len(b.errors) > 0 {
+		b.files["errors.txt"] = []byte(strings.Join(b.errors, "\n") + "\n")
+	}
+
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	dir := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(filename), ".gz"), ".tar")
+
+	names := make([]string, 0, len(b.files))
+	for name := range b.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data := b.files[name]
+		header := &tar.Header{
+			Name:    path.Join(dir, name),
+			Mode:    0600,
+			Size:    int64(len(data)),
+			ModTime: modTime,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
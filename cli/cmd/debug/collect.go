@@ -0,0 +1,196 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package debug
+
+import (
+	"encoding/json"
+	"io"
+	"path"
+	"sort"
+
+	"helm.sh/helm/v3/pkg/action"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/configentry"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+)
+
+const (
+	// componentSelector selects the pods of the Consul components installed by the Helm chart.
+	componentSelector = "app=consul"
+
+	// injectedSelector selects the pods that Consul injected a sidecar proxy into.
+	injectedSelector = "consul.hashicorp.com/connect-inject-status=injected"
+
+	// envoyAdminPort is the port of the Envoy admin API of sidecar proxies.
+	envoyAdminPort = 19000
+)
+
+// collectHelmValues adds the values of the Helm release to the bundle.
+func (c *Command) collectHelmValues(b *bundle, settings *helmCLI.EnvSettings, uiLogger action.DebugLog, releaseName, namespace string) {
+	values, err := helm.FetchChartValues(c.helmActionsRunner, namespace, releaseName, settings, uiLogger)
+	if err != nil {
+		b.addError("error fetching Helm values: %s", err)
+		return
+	}
+	out, err := yaml.Marshal(values)
+	if err != nil {
+		b.addError("error marshaling Helm values: %s", err)
+		return
+	}
+	b.add("helm/values.yaml", out)
+}
+
+// collectLogs adds the logs of every container of the Consul components to the bundle.
+func (c *Command) collectLogs(b *bundle, namespace string) {
+	pods, err := c.kubernetes.CoreV1().Pods(namespace).List(c.Ctx, metav1.ListOptions{LabelSelector: componentSelector})
+	if err != nil {
+		b.addError("error listing Consul pods: %s", err)
+		return
+	}
+	since := int64(c.flagSince.Seconds())
+	for _, pod := range pods.Items {
+		containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+		for _, container := range containers {
+			logs, err := c.podLogs(namespace, pod.Name, &corev1.PodLogOptions{Container: container.Name, SinceSeconds: &since})
+			if err != nil {
+				b.addError("error fetching logs of %s/%s: %s", pod.Name, container.Name, err)
+				continue
+			}
+			b.add(path.Join("logs", pod.Name, container.Name+".log"), logs)
+		}
+	}
+}
+
+// podLogs returns the logs of a container.
+func (c *Command) podLogs(namespace, pod string, opts *corev1.PodLogOptions) ([]byte, error) {
+	stream, err := c.kubernetes.CoreV1().Pods(namespace).GetLogs(pod, opts).Stream(c.Ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	return io.ReadAll(stream)
+}
+
+// collectCRDs adds the Consul custom resource definitions and their custom resources to the bundle.
+func (c *Command) collectCRDs(b *bundle) {
+	crds, err := c.apiext.ApiextensionsV1().CustomResourceDefinitions().List(c.Ctx, metav1.ListOptions{LabelSelector: componentSelector})
+	if err != nil {
+		b.addError("error listing custom resource definitions: %s", err)
+		return
+	}
+	for _, crd := range crds.Items {
+		out, err := yaml.Marshal(crd)
+		if err != nil {
+			b.addError("error marshaling %s: %s", crd.Name, err)
+			continue
+		}
+		b.add(path.Join("crds", crd.Name+".yaml"), out)
+
+		for _, version := range crd.Spec.Versions {
+			if !version.Storage {
+				continue
+			}
+			gvr := schema.GroupVersionResource{Group: crd.Spec.Group, Version: version.Name, Resource: crd.Spec.Names.Plural}
+			list, err := c.dynamic.Resource(gvr).List(c.Ctx, metav1.ListOptions{})
+			if err != nil {
+				b.addError("error listing %s: %s", crd.Name, err)
+				continue
+			}
+			out, err := yaml.Marshal(list.UnstructuredContent())
+			if err != nil {
+				b.addError("error marshaling %s: %s", crd.Name, err)
+				continue
+			}
+			b.add(path.Join("custom-resources", crd.Spec.Names.Plural+".yaml"), out)
+		}
+	}
+}
+
+// collectEnvoyConfigs adds the Envoy config dumps of a sample of the injected pods to the bundle.
+// The pods are sampled in order of namespace and name so that repeated bundles include the same pods.
+func (c *Command) collectEnvoyConfigs(b *bundle) {
+	if c.flagProxies == 0 {
+		return
+	}
+	pods, err := c.kubernetes.CoreV1().Pods("").List(c.Ctx, metav1.ListOptions{LabelSelector: injectedSelector})
+	if err != nil {
+		b.addError("error listing injected pods: %s", err)
+		return
+	}
+	var running []corev1.Pod
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			running = append(running, pod)
+		}
+	}
+	sort.Slice(running, func(i, j int) bool {
+		if running[i].Namespace != running[j].Namespace {
+			return running[i].Namespace < running[j].Namespace
+		}
+		return running[i].Name < running[j].Name
+	})
+	if len(running) > c.flagProxies {
+		running = running[:c.flagProxies]
+	}
+
+	for _, pod := range running {
+		pf := &common.PortForward{
+			Namespace:  pod.Namespace,
+			PodName:    pod.Name,
+			RemotePort: envoyAdminPort,
+			KubeClient: c.kubernetes,
+			RestConfig: c.restConfig,
+		}
+		config, err := c.fetchConfig(pf)
+		if err != nil {
+			b.addError("error fetching the Envoy config of %s/%s: %s", pod.Namespace, pod.Name, err)
+			continue
+		}
+		b.add(path.Join("envoy", pod.Namespace, pod.Name+".json"), config.JSON())
+	}
+}
+
+// collectConsulState adds the Raft configuration and the autopilot health and state of the
+// Consul servers to the bundle.
+func (c *Command) collectConsulState(b *bundle, namespace string) {
+	consulClient := c.consulClient
+	if consulClient == nil {
+		client, pf, err := configentry.ConnectToServer(c.Ctx, c.kubernetes, c.restConfig, namespace, c.flagToken)
+		if err != nil {
+			b.addError("error connecting to a Consul server: %s", err)
+			return
+		}
+		defer pf.Close()
+		consulClient = client
+	}
+
+	operator := consulClient.Operator()
+	requests := []struct {
+		name  string
+		fetch func() (interface{}, error)
+	}{
+		{"raft-configuration.json", func() (interface{}, error) { return operator.RaftGetConfiguration(nil) }},
+		{"autopilot-health.json", func() (interface{}, error) { return operator.AutopilotServerHealth(nil) }},
+		{"autopilot-state.json", func() (interface{}, error) { return operator.AutopilotState(nil) }},
+	}
+	for _, request := range requests {
+		result, err := request.fetch()
+		if err != nil {
+			b.addError("error fetching %s: %s", request.name, err)
+			continue
+		}
+		out, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			b.addError("error marshaling %s: %s", request.name, err)
+			continue
+		}
+		b.add(path.Join("consul", request.name), out)
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package debug
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/posener/complete"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	apiext "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/envoy"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+)
+
+const (
+	flagNameNamespace   = "namespace"
+	flagNameOutput      = "output"
+	flagNameSince       = "since"
+	flagNameProxies     = "proxies"
+	flagNameToken       = "token"
+	flagNameKubeConfig  = "kubeconfig"
+	flagNameKubeContext = "context"
+
+	defaultSince   = time.Hour
+	defaultProxies = 3
+)
+
+// Command gathers a support bundle of a Consul installation on Kubernetes.
+type Command struct {
+	*common.BaseCommand
+
+	helmActionsRunner helm.HelmActionsRunner
+
+	kubernetes   kubernetes.Interface
+	apiext       apiext.Interface
+	dynamic      dynamic.Interface
+	restConfig   *rest.Config
+	consulClient *api.Client
+
+	fetchConfig func(*common.PortForward) (*envoy.EnvoyConfig, error)
+
+	set *flag.Sets
+
+	flagNamespace   string
+	flagOutput      string
+	flagSince       time.Duration
+	flagProxies     int
+	flagToken       string
+	flagKubeConfig  string
+	flagKubeContext string
+
+	once sync.Once
+	help string
+}
+
+// init sets up flags and help text for the command.
+func (c *Command) init() {
+	c.set = flag.NewSets()
+
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameNamespace,
+		Target:  &c.flagNamespace,
+		Usage:   "The namespace Consul is installed in. If not set, the namespace of the Consul installation is detected.",
+		Aliases: []string{"n"},
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameOutput,
+		Target:  &c.flagOutput,
+		Usage:   "The path of the tarball to write the bundle to. Defaults to consul-k8s-debug-<timestamp>.tar.gz in the current directory.",
+		Aliases: []string{"o"},
+	})
+	f.DurationVar(&flag.DurationVar{
+		Name:    flagNameSince,
+		Target:  &c.flagSince,
+		Default: defaultSince,
+		Usage:   "How far back to gather the logs of Consul components.",
+	})
+	f.IntVar(&flag.IntVar{
+		Name:    flagNameProxies,
+		Target:  &c.flagProxies,
+		Default: defaultProxies,
+		Usage:   "The number of injected pods to gather the Envoy config dumps of.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameToken,
+		Target: &c.flagToken,
+		Usage:  "The ACL token to read the Raft and autopilot state with. If not set, CONSUL_HTTP_TOKEN or the bootstrap token of the installation is used.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameKubeConfig,
+		Aliases: []string{"c"},
+		Target:  &c.flagKubeConfig,
+		Default: "",
+		Usage:   "Set the path to kubeconfig file.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameKubeContext,
+		Target:  &c.flagKubeContext,
+		Default: "",
+		Usage:   "Set the Kubernetes context to use.",
+	})
+
+	c.help = c.set.Help()
+}
+
+// Run gathers the bundle and writes it to a tarball.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	if c.helmActionsRunner == nil {
+		c.helmActionsRunner = &helm.ActionRunner{}
+	}
+	if c.fetchConfig == nil {
+		c.fetchConfig = func(pf *common.PortForward) (*envoy.EnvoyConfig, error) {
+			return envoy.FetchConfig(c.Ctx, pf)
+		}
+	}
+
+	c.Log.ResetNamed("debug")
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.set.Parse(args); err != nil {
+		c.UI.Output("Error parsing arguments: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if err := c.validateFlags(); err != nil {
+		c.UI.Output("Invalid argument: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	settings := helmCLI.New()
+	if c.flagKubeConfig != "" {
+		settings.KubeConfig = c.flagKubeConfig
+	}
+	if c.flagKubeContext != "" {
+		settings.KubeContext = c.flagKubeContext
+	}
+
+	if err := c.initKubernetes(settings); err != nil {
+		c.UI.Output("Error initializing Kubernetes client: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	// Setup logger to stream Helm library logs.
+	var uiLogger = func(s string, args ...interface{}) {
+		logMsg := fmt.Sprintf(s, args...)
+		c.UI.Output(logMsg, terminal.WithLibraryStyle())
+	}
+
+	_, releaseName, namespace, err := c.helmActionsRunner.CheckForInstallations(&helm.CheckForInstallationsOptions{
+		Settings:    settings,
+		ReleaseName: common.DefaultReleaseName,
+		DebugLog:    uiLogger,
+	})
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	if c.flagNamespace != "" {
+		namespace = c.flagNamespace
+	}
+
+	now := time.Now().UTC()
+	output := c.flagOutput
+	if output == "" {
+		output = fmt.Sprintf("consul-k8s-debug-%s.tar.gz", now.Format("20060102T150405Z"))
+	}
+
+	b := newBundle()
+	c.UI.Output("Gathering debug bundle for Consul in namespace %s", namespace, terminal.WithHeaderStyle())
+	steps := []struct {
+		description string
+		collect     func(*bundle)
+	}{
+		{"Helm values", func(b *bundle) { c.collectHelmValues(b, settings, uiLogger, releaseName, namespace) }},
+		{"component logs", func(b *bundle) { c.collectLogs(b, namespace) }},
+		{"custom resource definitions", c.collectCRDs},
+		{"Envoy config dumps", c.collectEnvoyConfigs},
+		{"Consul Raft and autopilot state", func(b *bundle) { c.collectConsulState(b, namespace) }},
+	}
+	for _, step := range steps {
+		errs := len(b.errors)
+		step.collect(b)
+		if len(b.errors) > errs {
+			c.UI.Output("Gathered %s with errors", step.description, terminal.WithWarningStyle())
+		} else {
+			c.UI.Output("Gathered %s", step.description, terminal.WithSuccessStyle())
+		}
+	}
+
+	if err := b.writeTarball(output, now); err != nil {
+		c.UI.Output("Error writing the debug bundle: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	if len(b.errors) > 0 {
+		c.UI.Output("Some data couldn't be gathered, see errors.txt in the bundle.", terminal.WithWarningStyle())
+	}
+	c.UI.Output("Wrote debug bundle to %s", output, terminal.WithSuccessStyle())
+	return 0
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *Command) validateFlags() error {
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if errs := validation.ValidateNamespaceName(c.flagNamespace, false); c.flagNamespace != "" && len(errs) > 0 {
+		return fmt.Errorf("invalid namespace name passed for -namespace/-n: %v", strings.Join(errs, "; "))
+	}
+	if c.flagSince <= 0 {
+		return fmt.Errorf("-%s must be greater than 0", flagNameSince)
+	}
+	if c.flagProxies < 0 {
+		return fmt.Errorf("-%s must not be negative", flagNameProxies)
+	}
+	return nil
+}
+
+// initKubernetes initializes the Kubernetes clients.
+func (c *Command) initKubernetes(settings *helmCLI.EnvSettings) error {
+	if c.kubernetes != nil && c.apiext != nil && c.dynamic != nil {
+		return nil
+	}
+	var err error
+	if c.restConfig == nil {
+		if c.restConfig, err = settings.RESTClientGetter().ToRESTConfig(); err != nil {
+			return fmt.Errorf("error retrieving Kubernetes authentication %v", err)
+		}
+	}
+	if c.kubernetes == nil {
+		if c.kubernetes, err = kubernetes.NewForConfig(c.restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client %v", err)
+		}
+	}
+	if c.apiext == nil {
+		if c.apiext, err = apiext.NewForConfig(c.restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client %v", err)
+		}
+	}
+	if c.dynamic == nil {
+		if c.dynamic, err = dynamic.NewForConfig(c.restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client %v", err)
+		}
+	}
+	return nil
+}
+
+// Help returns a description of the command and how it is used.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return fmt.Sprintf("%s\n%s", help, c.help)
+}
+
+// Synopsis returns a one-line command summary.
+func (c *Command) Synopsis() string {
+	return synopsis
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command. The map key for the Flags map should be the
+// complete flag such as "-foo" or "--foo".
+func (c *Command) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		fmt.Sprintf("-%s", flagNameNamespace):   complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameOutput):      complete.PredictFiles("*.tar.gz"),
+		fmt.Sprintf("-%s", flagNameSince):       complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameProxies):     complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameToken):       complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameKubeConfig):  complete.PredictFiles("*"),
+		fmt.Sprintf("-%s", flagNameKubeContext): complete.PredictNothing,
+	}
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+// Since argument completion is not supported, this will return
+// complete.PredictNothing.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+const (
+	synopsis = "Gather a support bundle of a Consul installation."
+	help     = `
+Usage: consul-k8s debug [options]
+
+  Gathers the information needed to debug a Consul installation on Kubernetes
+  into a tarball that can be attached to support tickets. The bundle contains:
+
+    - the logs of the Consul components
+    - the Helm values of the installation
+    - the Consul custom resource definitions and custom resources
+    - the Envoy config dumps of a sample of injected pods
+    - the Raft configuration and autopilot state of the Consul servers
+
+  Tokens, private keys and other credentials are redacted from the bundle,
+  but review it before sharing it.
+
+  Examples:
+    $ consul-k8s debug
+    $ consul-k8s debug -since 30m -proxies 10 -output bundle.tar.gz
+`
+)
@@ -0,0 +1,194 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package debug
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/envoy"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextFake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRun(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	buf := new(bytes.Buffer)
+	c := getInitializedCommand(t, buf)
+	c.helmActionsRunner = &helm.MockActionRunner{
+		CheckForInstallationsFunc: func(options *helm.CheckForInstallationsOptions) (bool, string, string, error) {
+			return true, "consul", "consul", nil
+		},
+		GetStatusFunc: func(status *action.Status, name string) (*release.Release, error) {
+			return &release.Release{Config: map[string]interface{}{
+				"global": map[string]interface{}{"acls": map[string]interface{}{"bootstrapToken": "abc123"}},
+			}}, nil
+		},
+	}
+	c.kubernetes = fake.NewSimpleClientset(
+		testPod("consul", "consul-server-0", map[string]string{"app": "consul"}),
+		testPod("default", "web", map[string]string{"consul.hashicorp.com/connect-inject-status": "injected"}),
+		testPod("default", "api", map[string]string{"consul.hashicorp.com/connect-inject-status": "injected"}),
+	)
+	c.apiext = apiextFake.NewSimpleClientset(&apiextv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "servicedefaults.consul.hashicorp.com", Labels: map[string]string{"app": "consul"}},
+		Spec: apiextv1.CustomResourceDefinitionSpec{
+			Group:    "consul.hashicorp.com",
+			Names:    apiextv1.CustomResourceDefinitionNames{Plural: "servicedefaults", Kind: "ServiceDefaults"},
+			Versions: []apiextv1.CustomResourceDefinitionVersion{{Name: "v1alpha1", Storage: true}},
+		},
+	})
+	c.dynamic = dynamicFake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		{Group: "consul.hashicorp.com", Version: "v1alpha1", Resource: "servicedefaults"}: "ServiceDefaultsList",
+	})
+	c.consulClient = testConsulServer(t)
+	c.fetchConfig = func(pf *common.PortForward) (*envoy.EnvoyConfig, error) {
+		if pf.PodName == "api" {
+			return nil, errors.New("connection refused")
+		}
+		return &envoy.EnvoyConfig{RawCfg: []byte(`{"token": "abc123"}`)}, nil
+	}
+
+	require.Equal(t, 0, c.Run([]string{"-output", output, "-proxies", "2"}), buf.String())
+	require.Contains(t, buf.String(), "Wrote debug bundle to "+output)
+	require.Contains(t, buf.String(), "Gathered Envoy config dumps with errors")
+
+	files := readTarball(t, output)
+	require.Equal(t, "global:\n  acls:\n    bootstrapToken: [redacted]\n", files["bundle/helm/values.yaml"])
+	require.Equal(t, "fake logs", files["bundle/logs/consul-server-0/consul.log"])
+	require.Contains(t, files, "bundle/crds/servicedefaults.consul.hashicorp.com.yaml")
+	require.Contains(t, files, "bundle/custom-resources/servicedefaults.yaml")
+	require.Equal(t, `{"token": "[redacted]"}`, files["bundle/envoy/default/web.json"])
+	require.Contains(t, files["bundle/consul/raft-configuration.json"], "consul-server-0")
+	require.Contains(t, files, "bundle/consul/autopilot-health.json")
+	require.Contains(t, files, "bundle/consul/autopilot-state.json")
+	require.Equal(t, "error fetching the Envoy config of default/api: connection refused\n", files["bundle/errors.txt"])
+}
+
+func TestRun_FlagValidation(t *testing.T) {
+	cases := map[string]struct {
+		args   []string
+		expErr string
+	}{
+		"non-flag arguments": {
+			args:   []string{"foo"},
+			expErr: "should have no non-flag arguments",
+		},
+		"invalid since": {
+			args:   []string{"-since", "0s"},
+			expErr: "-since must be greater than 0",
+		},
+		"negative proxies": {
+			args:   []string{"-proxies", "-1"},
+			expErr: "-proxies must not be negative",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := getInitializedCommand(t, buf)
+			require.Equal(t, 1, c.Run(tc.args))
+			require.Contains(t, buf.String(), tc.expErr)
+		})
+	}
+}
+
+func getInitializedCommand(t *testing.T, buf *bytes.Buffer) *Command {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+		UI:  terminal.NewUI(context.Background(), buf),
+	}
+
+	c := &Command{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
+
+func testPod(namespace, name string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "consul"}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+// testConsulServer returns a Consul client for a fake Consul server with one Raft peer.
+func testConsulServer(t *testing.T) *api.Client {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/operator/raft/configuration", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewEncoder(w).Encode(api.RaftConfiguration{
+			Servers: []*api.RaftServer{{ID: "1", Node: "consul-server-0", Leader: true, Voter: true}},
+		}))
+	})
+	mux.HandleFunc("/v1/operator/autopilot/health", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewEncoder(w).Encode(api.OperatorHealthReply{Healthy: true}))
+	})
+	mux.HandleFunc("/v1/operator/autopilot/state", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewEncoder(w).Encode(api.AutopilotState{Healthy: true}))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	consulClient, err := api.NewClient(&api.Config{Address: strings.TrimPrefix(server.URL, "http://")})
+	require.NoError(t, err)
+	return consulClient
+}
+
+// readTarball returns the contents of the files in a gzipped tarball keyed by their names.
+func readTarball(t *testing.T, filename string) map[string]string {
+	f, err := os.Open(filename)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	files := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = string(data)
+	}
+	return files
+}
@@ -0,0 +1,29 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package federation
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/mitchellh/cli"
+)
+
+// FederationCommand provides a synopsis for the federation subcommands (e.g. join).
+type FederationCommand struct {
+	*common.BaseCommand
+}
+
+// Run prints out information about the subcommands.
+func (c *FederationCommand) Run([]string) int {
+	return cli.RunResultHelp
+}
+
+func (c *FederationCommand) Help() string {
+	return fmt.Sprintf("%s\n\nUsage: consul-k8s federation <subcommand>", c.Synopsis())
+}
+
+func (c *FederationCommand) Synopsis() string {
+	return "Federate Consul datacenters over mesh gateways."
+}
@@ -0,0 +1,570 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package join
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/posener/complete"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/configentry"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+)
+
+const (
+	flagNamePrimaryContext     = "primary-context"
+	flagNamePrimaryNamespace   = "primary-namespace"
+	flagNameSecondaryContext   = "secondary-context"
+	flagNameSecondaryNamespace = "secondary-namespace"
+	flagNameSecretName         = "secret-name"
+	flagNamePrimaryGateways    = "primary-gateways"
+	flagNameTimeout            = "timeout"
+	flagNameToken              = "token"
+	flagNameKubeConfig         = "kubeconfig"
+
+	defaultSecretName = "consul-federation"
+	defaultTimeout    = 5 * time.Minute
+
+	// Keys of the federation secret created by the primary datacenter.
+	secretKeyCACert           = "caCert"
+	secretKeyCAKey            = "caKey"
+	secretKeyGossipKey        = "gossipEncryptionKey"
+	secretKeyReplicationToken = "replicationToken"
+	secretKeyServerConfig     = "serverConfigJSON"
+
+	// serverSelector selects the pods of the Consul servers.
+	serverSelector = "app=consul,component=server"
+
+	// memberStatusAlive is the Serf status of members that are alive.
+	memberStatusAlive = 1
+)
+
+// pollInterval is how often the WAN members are checked while waiting for the
+// datacenters to converge.
+var pollInterval = 2 * time.Second
+
+// serverConfig is the server configuration stored in the federation secret.
+type serverConfig struct {
+	PrimaryDatacenter string   `json:"primary_datacenter"`
+	PrimaryGateways   []string `json:"primary_gateways"`
+}
+
+// JoinCommand copies the federation secret of a primary datacenter to a
+// secondary datacenter and waits for the servers of both to join over the WAN.
+type JoinCommand struct {
+	*common.BaseCommand
+
+	primaryKubernetes   kubernetes.Interface
+	primaryRestConfig   *rest.Config
+	secondaryKubernetes kubernetes.Interface
+	secondaryRestConfig *rest.Config
+
+	// primaryConsul and secondaryConsul are the clients for the Consul servers of
+	// each datacenter. They are created when they are needed if they are not set.
+	primaryConsul   *api.Client
+	secondaryConsul *api.Client
+
+	// portForwards are the port forwards to the Consul servers that the clients use, if the command opened them.
+	portForwards []common.PortForwarder
+
+	set *flag.Sets
+
+	flagPrimaryContext     string
+	flagPrimaryNamespace   string
+	flagSecondaryContext   string
+	flagSecondaryNamespace string
+	flagSecretName         string
+	flagPrimaryGateways    []string
+	flagTimeout            time.Duration
+	flagToken              string
+	flagKubeConfig         string
+
+	once sync.Once
+	help string
+}
+
+// init sets up flags and help text for the command.
+func (c *JoinCommand) init() {
+	c.set = flag.NewSets()
+
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:   flagNamePrimaryContext,
+		Target: &c.flagPrimaryContext,
+		Usage:  "The Kubernetes context of the cluster that runs the primary datacenter.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNamePrimaryNamespace,
+		Target:  &c.flagPrimaryNamespace,
+		Default: common.DefaultReleaseNamespace,
+		Usage:   "The namespace of the Consul installation in the primary datacenter.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameSecondaryContext,
+		Target: &c.flagSecondaryContext,
+		Usage:  "The Kubernetes context of the cluster that runs the secondary datacenter.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameSecondaryNamespace,
+		Target:  &c.flagSecondaryNamespace,
+		Default: common.DefaultReleaseNamespace,
+		Usage:   "The namespace of the Consul installation in the secondary datacenter.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameSecretName,
+		Target:  &c.flagSecretName,
+		Default: defaultSecretName,
+		Usage:   "The name of the federation secret in both datacenters.",
+	})
+	f.StringSliceVar(&flag.StringSliceVar{
+		Name:   flagNamePrimaryGateways,
+		Target: &c.flagPrimaryGateways,
+		Usage: "The addresses of the mesh gateways of the primary datacenter in the form <ip>:<port>. " +
+			"If not set, the addresses in the federation secret are used. Can be specified multiple times.",
+	})
+	f.DurationVar(&flag.DurationVar{
+		Name:    flagNameTimeout,
+		Target:  &c.flagTimeout,
+		Default: defaultTimeout,
+		Usage:   "How long to wait for the servers of the secondary datacenter to join the primary datacenter.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameToken,
+		Target: &c.flagToken,
+		Usage:  "The ACL token to read the WAN members of the primary datacenter with. If not set, CONSUL_HTTP_TOKEN or the bootstrap token of the installation is used.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameKubeConfig,
+		Aliases: []string{"c"},
+		Target:  &c.flagKubeConfig,
+		Default: "",
+		Usage:   "Set the path to kubeconfig file.",
+	})
+
+	c.help = c.set.Help()
+}
+
+// Run joins the secondary datacenter to the primary datacenter.
+func (c *JoinCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("federation join")
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.set.Parse(args); err != nil {
+		c.UI.Output("Error parsing arguments: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	if err := c.validateFlags(); err != nil {
+		c.UI.Output("Invalid argument: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	if err := c.initKubernetes(); err != nil {
+		c.UI.Output("Error initializing Kubernetes client: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	primarySecret, err := c.primaryKubernetes.CoreV1().Secrets(c.flagPrimaryNamespace).Get(c.Ctx, c.flagSecretName, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		c.UI.Output("Federation secret %s not found in namespace %s of the primary datacenter. Install the primary datacenter with global.federation.createFederationSecret=true.",
+			c.flagSecretName, c.flagPrimaryNamespace, terminal.WithErrorStyle())
+		return 1
+	}
+	if err != nil {
+		c.UI.Output("Error reading the federation secret of the primary datacenter: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	data, cfg, err := c.federationData(primarySecret)
+	if err != nil {
+		c.UI.Output("Invalid federation secret: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	c.UI.Output("Read the federation secret of primary datacenter %s", cfg.PrimaryDatacenter, terminal.WithSuccessStyle())
+
+	changed, err := c.writeSecondarySecret(data)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	if changed {
+		c.UI.Output("Wrote federation secret %s to namespace %s of the secondary datacenter", c.flagSecretName, c.flagSecondaryNamespace, terminal.WithSuccessStyle())
+	} else {
+		c.UI.Output("Federation secret of the secondary datacenter is up to date", terminal.WithSuccessStyle())
+	}
+
+	installed, err := c.secondaryInstalled()
+	if err != nil {
+		c.UI.Output("Error checking for Consul servers in the secondary datacenter: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	if !installed {
+		c.UI.Output("Next steps", terminal.WithHeaderStyle())
+		c.UI.Output("Install Consul in the secondary datacenter with these values and then run this command again to verify that the datacenters are federated:")
+		c.UI.Output(secondaryValues(c.flagSecretName, cfg.PrimaryDatacenter, data), terminal.WithLibraryStyle())
+		return 0
+	}
+	if changed {
+		// Servers only read the federation secret when they start.
+		c.UI.Output("Consul is already installed in the secondary datacenter. Restart its servers to apply the federation secret and then run this command again to verify that the datacenters are federated.",
+			terminal.WithWarningStyle())
+		return 0
+	}
+
+	err = c.initConsulClients()
+	for _, pf := range c.portForwards {
+		defer pf.Close()
+	}
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	secondaryDatacenter, err := configentry.Datacenter(c.secondaryConsul)
+	if err != nil {
+		c.UI.Output("Error reading the datacenter of the secondary servers: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	c.UI.Output("Waiting for the servers of %s to join %s over the WAN", secondaryDatacenter, cfg.PrimaryDatacenter, terminal.WithInfoStyle())
+	if err := c.waitForWANMembers(secondaryDatacenter); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	c.UI.Output("Datacenters %s and %s are federated", cfg.PrimaryDatacenter, secondaryDatacenter, terminal.WithSuccessStyle())
+	return 0
+}
+
+// validateFlags checks that the flags are valid.
+func (c *JoinCommand) validateFlags() error {
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if c.flagPrimaryContext == "" {
+		return fmt.Errorf("-%s must be set", flagNamePrimaryContext)
+	}
+	if c.flagSecondaryContext == "" {
+		return fmt.Errorf("-%s must be set", flagNameSecondaryContext)
+	}
+	if c.flagPrimaryContext == c.flagSecondaryContext && c.flagPrimaryNamespace == c.flagSecondaryNamespace {
+		return errors.New("the primary and secondary datacenters must use different contexts or namespaces")
+	}
+	if c.flagTimeout <= 0 {
+		return fmt.Errorf("-%s must be greater than 0", flagNameTimeout)
+	}
+	return nil
+}
+
+// federationData returns the data of the federation secret for the secondary
+// datacenter and its server configuration. The primary gateways are replaced
+// with the gateways from the flags if they are set.
+func (c *JoinCommand) federationData(secret *corev1.Secret) (map[string][]byte, serverConfig, error) {
+	var cfg serverConfig
+	for _, key := range []string{secretKeyCACert, secretKeyCAKey, secretKeyServerConfig} {
+		if len(secret.Data[key]) == 0 {
+			return nil, cfg, fmt.Errorf("key %q is not set", key)
+		}
+	}
+	if err := json.Unmarshal(secret.Data[secretKeyServerConfig], &cfg); err != nil {
+		return nil, cfg, fmt.Errorf("error parsing %s: %w", secretKeyServerConfig, err)
+	}
+	if cfg.PrimaryDatacenter == "" {
+		return nil, cfg, fmt.Errorf("%s does not set primary_datacenter", secretKeyServerConfig)
+	}
+	if len(c.flagPrimaryGateways) > 0 {
+		cfg.PrimaryGateways = c.flagPrimaryGateways
+	}
+	if len(cfg.PrimaryGateways) == 0 {
+		return nil, cfg, fmt.Errorf("the primary datacenter has no mesh gateway addresses, set -%s", flagNamePrimaryGateways)
+	}
+	serverCfg, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, cfg, err
+	}
+
+	data := make(map[string][]byte, len(secret.Data))
+	for key, value := range secret.Data {
+		data[key] = value
+	}
+	data[secretKeyServerConfig] = serverCfg
+	return data, cfg, nil
+}
+
+// writeSecondarySecret creates or updates the federation secret of the
+// secondary datacenter and returns true if its data changed. It fails if the
+// secondary datacenter already has a federation secret with a different CA or
+// gossip encryption key since its servers couldn't join the primary datacenter.
+func (c *JoinCommand) writeSecondarySecret(data map[string][]byte) (bool, error) {
+	secrets := c.secondaryKubernetes.CoreV1().Secrets(c.flagSecondaryNamespace)
+	existing, err := secrets.Get(c.Ctx, c.flagSecretName, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		_, err := secrets.Create(c.Ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      c.flagSecretName,
+				Namespace: c.flagSecondaryNamespace,
+				Labels:    map[string]string{common.CLILabelKey: common.CLILabelValue},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: data,
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return false, fmt.Errorf("error creating the federation secret of the secondary datacenter: %w", err)
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error reading the federation secret of the secondary datacenter: %w", err)
+	}
+
+	for key, name := range map[string]string{
+		secretKeyCACert:    "CA certificate",
+		secretKeyCAKey:     "CA key",
+		secretKeyGossipKey: "gossip encryption key",
+	} {
+		if len(existing.Data[key]) > 0 && !bytes.Equal(existing.Data[key], data[key]) {
+			return false, fmt.Errorf("the %s in the federation secret of the secondary datacenter doesn't match the primary datacenter. "+
+				"Uninstall Consul from the secondary datacenter and delete secret %s before joining it", name, c.flagSecretName)
+		}
+	}
+	if equalData(existing.Data, data) {
+		return false, nil
+	}
+	existing.Data = data
+	if _, err := secrets.Update(c.Ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return false, fmt.Errorf("error updating the federation secret of the secondary datacenter: %w", err)
+	}
+	return true, nil
+}
+
+// secondaryInstalled returns true if Consul servers are running in the secondary datacenter.
+func (c *JoinCommand) secondaryInstalled() (bool, error) {
+	pods, err := c.secondaryKubernetes.CoreV1().Pods(c.flagSecondaryNamespace).List(c.Ctx, metav1.ListOptions{LabelSelector: serverSelector})
+	if err != nil {
+		return false, err
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// waitForWANMembers waits until a server of the datacenter is an alive WAN
+// member of the primary datacenter.
+func (c *JoinCommand) waitForWANMembers(datacenter string) error {
+	timer := time.NewTimer(c.flagTimeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	var members []*api.AgentMember
+	var err error
+	for {
+		members, err = c.primaryConsul.Agent().Members(true)
+		if err == nil && datacenterAlive(members, datacenter) {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			if err != nil {
+				return fmt.Errorf("timed out waiting for the WAN members of the primary datacenter: %w", err)
+			}
+			return fmt.Errorf("timed out waiting for the servers of %s to join the primary datacenter, WAN members:\n%s", datacenter, formatMembers(members))
+		case <-c.Ctx.Done():
+			return c.Ctx.Err()
+		}
+	}
+}
+
+// initKubernetes initializes the Kubernetes clients of both datacenters.
+func (c *JoinCommand) initKubernetes() error {
+	var err error
+	if c.primaryKubernetes == nil {
+		if c.primaryKubernetes, c.primaryRestConfig, err = c.kubernetesClient(c.flagPrimaryContext); err != nil {
+			return err
+		}
+	}
+	if c.secondaryKubernetes == nil {
+		if c.secondaryKubernetes, c.secondaryRestConfig, err = c.kubernetesClient(c.flagSecondaryContext); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kubernetesClient returns a Kubernetes client for the context.
+func (c *JoinCommand) kubernetesClient(kubeContext string) (kubernetes.Interface, *rest.Config, error) {
+	settings := helmCLI.New()
+	if c.flagKubeConfig != "" {
+		settings.KubeConfig = c.flagKubeConfig
+	}
+	settings.KubeContext = kubeContext
+
+	restConfig, err := settings.RESTClientGetter().ToRESTConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating Kubernetes REST config for context %s: %v", kubeContext, err)
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating Kubernetes client for context %s: %v", kubeContext, err)
+	}
+	return client, restConfig, nil
+}
+
+// initConsulClients connects to the Consul servers of both datacenters. The
+// port forwards it opens must be closed when the clients are no longer used.
+func (c *JoinCommand) initConsulClients() error {
+	if c.primaryConsul == nil {
+		client, pf, err := configentry.ConnectToServer(c.Ctx, c.primaryKubernetes, c.primaryRestConfig, c.flagPrimaryNamespace, c.flagToken)
+		if err != nil {
+			return fmt.Errorf("error connecting to the primary datacenter: %w", err)
+		}
+		c.portForwards = append(c.portForwards, pf)
+		c.primaryConsul = client
+	}
+	if c.secondaryConsul == nil {
+		client, pf, err := configentry.ConnectToServer(c.Ctx, c.secondaryKubernetes, c.secondaryRestConfig, c.flagSecondaryNamespace, "")
+		if err != nil {
+			return fmt.Errorf("error connecting to the secondary datacenter: %w", err)
+		}
+		c.portForwards = append(c.portForwards, pf)
+		c.secondaryConsul = client
+	}
+	return nil
+}
+
+// datacenterAlive returns true if a server of the datacenter is an alive member.
+func datacenterAlive(members []*api.AgentMember, datacenter string) bool {
+	for _, member := range members {
+		if member.Tags["dc"] == datacenter && member.Tags["role"] == "consul" && member.Status == memberStatusAlive {
+			return true
+		}
+	}
+	return false
+}
+
+// formatMembers returns the members in the format of `consul members -wan`.
+func formatMembers(members []*api.AgentMember) string {
+	table := make([]string, 0, len(members))
+	for _, member := range members {
+		status := "alive"
+		if member.Status != memberStatusAlive {
+			status = fmt.Sprintf("status %d", member.Status)
+		}
+		table = append(table, fmt.Sprintf("  %s  %s:%d  %s  %s", member.Name, member.Addr, member.Port, status, member.Tags["dc"]))
+	}
+	sort.Strings(table)
+	return strings.Join(table, "\n")
+}
+
+// equalData returns true if the secret data are the same.
+func equalData(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if !bytes.Equal(value, b[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// secondaryValues returns the Helm values that install a secondary datacenter
+// from the federation secret.
+func secondaryValues(secretName, primaryDatacenter string, data map[string][]byte) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "global:\n")
+	fmt.Fprintf(&b, "  datacenter: <secondary datacenter>\n")
+	fmt.Fprintf(&b, "  tls:\n")
+	fmt.Fprintf(&b, "    enabled: true\n")
+	fmt.Fprintf(&b, "    caCert:\n      secretName: %s\n      secretKey: %s\n", secretName, secretKeyCACert)
+	fmt.Fprintf(&b, "    caKey:\n      secretName: %s\n      secretKey: %s\n", secretName, secretKeyCAKey)
+	if len(data[secretKeyReplicationToken]) > 0 {
+		fmt.Fprintf(&b, "  acls:\n")
+		fmt.Fprintf(&b, "    manageSystemACLs: true\n")
+		fmt.Fprintf(&b, "    replicationToken:\n      secretName: %s\n      secretKey: %s\n", secretName, secretKeyReplicationToken)
+	}
+	if len(data[secretKeyGossipKey]) > 0 {
+		fmt.Fprintf(&b, "  gossipEncryption:\n    secretName: %s\n    secretKey: %s\n", secretName, secretKeyGossipKey)
+	}
+	fmt.Fprintf(&b, "  federation:\n")
+	fmt.Fprintf(&b, "    enabled: true\n")
+	fmt.Fprintf(&b, "    primaryDatacenter: %s\n", primaryDatacenter)
+	fmt.Fprintf(&b, "server:\n")
+	fmt.Fprintf(&b, "  extraVolumes:\n")
+	fmt.Fprintf(&b, "    - type: secret\n      name: %s\n      items:\n        - key: %s\n          path: config.json\n      load: true\n", secretName, secretKeyServerConfig)
+	fmt.Fprintf(&b, "connectInject:\n  enabled: true\n")
+	fmt.Fprintf(&b, "meshGateway:\n  enabled: true\n")
+	return b.String()
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command. The map key for the Flags map should be the
+// complete flag such as "-foo" or "--foo".
+func (c *JoinCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		fmt.Sprintf("-%s", flagNamePrimaryContext):     complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNamePrimaryNamespace):   complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameSecondaryContext):   complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameSecondaryNamespace): complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameSecretName):         complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNamePrimaryGateways):    complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameTimeout):            complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameToken):              complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameKubeConfig):         complete.PredictFiles("*"),
+	}
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+// Since argument completion is not supported, this will return
+// complete.PredictNothing.
+func (c *JoinCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *JoinCommand) Synopsis() string {
+	return synopsis
+}
+
+func (c *JoinCommand) Help() string {
+	c.once.Do(c.init)
+	return fmt.Sprintf("%s\n%s", help, c.help)
+}
+
+const (
+	synopsis = "Federate a secondary datacenter with a primary datacenter over mesh gateways."
+	help     = `
+Usage: consul-k8s federation join [options]
+
+  Copies the federation secret of the primary datacenter to the secondary
+  datacenter. The command fails if the secondary datacenter already has a
+  federation secret with a different CA or gossip encryption key.
+
+  If Consul isn't installed in the secondary datacenter yet, the command prints
+  the Helm values to install it with. Otherwise it waits until the servers of
+  the secondary datacenter are alive WAN members of the primary datacenter.
+
+  Examples:
+    $ consul-k8s federation join -primary-context dc1 -secondary-context dc2
+    $ consul-k8s federation join -primary-context dc1 -secondary-context dc2 \
+        -primary-gateways 10.0.0.10:443 -primary-gateways 10.0.0.11:443
+`
+)
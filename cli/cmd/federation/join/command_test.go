@@ -0,0 +1,265 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package join
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRun(t *testing.T) {
+	primaryData := map[string][]byte{
+		secretKeyCACert:       []byte("ca-cert"),
+		secretKeyCAKey:        []byte("ca-key"),
+		secretKeyGossipKey:    []byte("gossip-key"),
+		secretKeyServerConfig: []byte(`{"primary_datacenter":"dc1","primary_gateways":["10.0.0.10:443"]}`),
+	}
+	joinedMembers := []*api.AgentMember{
+		{Name: "consul-server-0.dc1", Tags: map[string]string{"dc": "dc1", "role": "consul"}, Status: memberStatusAlive},
+		{Name: "consul-server-0.dc2", Tags: map[string]string{"dc": "dc2", "role": "consul"}, Status: memberStatusAlive},
+	}
+
+	cases := map[string]struct {
+		args             []string
+		secondarySecret  map[string][]byte
+		secondaryServers bool
+		wanMembers       []*api.AgentMember
+		expCode          int
+		expOutput        []string
+		expGateways      []string
+	}{
+		"creates the secret and prints the values for a new datacenter": {
+			expOutput: []string{
+				"Wrote federation secret consul-federation",
+				"Next steps",
+				"primaryDatacenter: dc1",
+				"secretKey: gossipEncryptionKey",
+			},
+			expGateways: []string{"10.0.0.10:443"},
+		},
+		"overrides the primary gateways": {
+			args:        []string{"-primary-gateways", "10.0.0.20:443", "-primary-gateways", "10.0.0.21:443"},
+			expOutput:   []string{"Wrote federation secret consul-federation"},
+			expGateways: []string{"10.0.0.20:443", "10.0.0.21:443"},
+		},
+		"asks for a restart when the secret of an installed datacenter changes": {
+			secondaryServers: true,
+			expOutput:        []string{"Restart its servers"},
+			expGateways:      []string{"10.0.0.10:443"},
+		},
+		"waits for the datacenters to converge": {
+			secondarySecret:  primaryData,
+			secondaryServers: true,
+			wanMembers:       joinedMembers,
+			expOutput:        []string{"Federation secret of the secondary datacenter is up to date", "Datacenters dc1 and dc2 are federated"},
+			expGateways:      []string{"10.0.0.10:443"},
+		},
+		"times out when the secondary servers do not join": {
+			args:             []string{"-timeout", "10ms"},
+			secondarySecret:  primaryData,
+			secondaryServers: true,
+			wanMembers:       joinedMembers[:1],
+			expCode:          1,
+			expOutput:        []string{"timed out waiting for the servers of dc2", "consul-server-0.dc1"},
+			expGateways:      []string{"10.0.0.10:443"},
+		},
+		"fails when the CA of the secondary datacenter differs": {
+			secondarySecret: map[string][]byte{
+				secretKeyCACert:       []byte("other-ca-cert"),
+				secretKeyCAKey:        []byte("ca-key"),
+				secretKeyServerConfig: primaryData[secretKeyServerConfig],
+			},
+			expCode:   1,
+			expOutput: []string{"the CA certificate in the federation secret of the secondary datacenter doesn't match"},
+		},
+		"fails when the gossip key of the secondary datacenter differs": {
+			secondarySecret: map[string][]byte{
+				secretKeyCACert:       []byte("ca-cert"),
+				secretKeyCAKey:        []byte("ca-key"),
+				secretKeyGossipKey:    []byte("other-gossip-key"),
+				secretKeyServerConfig: primaryData[secretKeyServerConfig],
+			},
+			expCode:   1,
+			expOutput: []string{"the gossip encryption key in the federation secret of the secondary datacenter doesn't match"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			cmd := getInitializedCommand(t, buf)
+			cmd.primaryKubernetes = fake.NewSimpleClientset(federationSecret(primaryData))
+
+			var secondaryObjects []runtime.Object
+			if c.secondarySecret != nil {
+				secondaryObjects = append(secondaryObjects, federationSecret(c.secondarySecret))
+			}
+			if c.secondaryServers {
+				secondaryObjects = append(secondaryObjects, &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "consul-server-0",
+						Namespace: "consul",
+						Labels:    map[string]string{"app": "consul", "component": "server"},
+					},
+					Status: corev1.PodStatus{Phase: corev1.PodRunning},
+				})
+			}
+			cmd.secondaryKubernetes = fake.NewSimpleClientset(secondaryObjects...)
+			cmd.primaryConsul = testConsulServer(t, "dc1", c.wanMembers)
+			cmd.secondaryConsul = testConsulServer(t, "dc2", nil)
+
+			args := append([]string{"-primary-context", "dc1", "-secondary-context", "dc2"}, c.args...)
+			require.Equal(t, c.expCode, cmd.Run(args), buf.String())
+			output := buf.String()
+			for _, s := range c.expOutput {
+				require.Contains(t, output, s)
+			}
+
+			if c.expGateways == nil {
+				return
+			}
+			secret, err := cmd.secondaryKubernetes.CoreV1().Secrets("consul").Get(context.Background(), defaultSecretName, metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, primaryData[secretKeyCACert], secret.Data[secretKeyCACert])
+			require.Equal(t, primaryData[secretKeyGossipKey], secret.Data[secretKeyGossipKey])
+			var cfg serverConfig
+			require.NoError(t, json.Unmarshal(secret.Data[secretKeyServerConfig], &cfg))
+			require.Equal(t, serverConfig{PrimaryDatacenter: "dc1", PrimaryGateways: c.expGateways}, cfg)
+		})
+	}
+}
+
+func TestRun_InvalidPrimarySecret(t *testing.T) {
+	cases := map[string]struct {
+		data   map[string][]byte
+		expErr string
+	}{
+		"missing CA key": {
+			data: map[string][]byte{
+				secretKeyCACert:       []byte("ca-cert"),
+				secretKeyServerConfig: []byte(`{"primary_datacenter":"dc1","primary_gateways":["10.0.0.10:443"]}`),
+			},
+			expErr: `key "caKey" is not set`,
+		},
+		"no primary gateways": {
+			data: map[string][]byte{
+				secretKeyCACert:       []byte("ca-cert"),
+				secretKeyCAKey:        []byte("ca-key"),
+				secretKeyServerConfig: []byte(`{"primary_datacenter":"dc1"}`),
+			},
+			expErr: "the primary datacenter has no mesh gateway addresses, set -primary-gateways",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			cmd := getInitializedCommand(t, buf)
+			cmd.primaryKubernetes = fake.NewSimpleClientset(federationSecret(c.data))
+			cmd.secondaryKubernetes = fake.NewSimpleClientset()
+
+			require.Equal(t, 1, cmd.Run([]string{"-primary-context", "dc1", "-secondary-context", "dc2"}))
+			require.Contains(t, buf.String(), c.expErr)
+			secrets, err := cmd.secondaryKubernetes.CoreV1().Secrets("consul").List(context.Background(), metav1.ListOptions{})
+			require.NoError(t, err)
+			require.Empty(t, secrets.Items)
+		})
+	}
+}
+
+func TestRun_FlagValidation(t *testing.T) {
+	cases := map[string]struct {
+		args   []string
+		expErr string
+	}{
+		"arguments": {
+			args:   []string{"-primary-context", "dc1", "-secondary-context", "dc2", "foo"},
+			expErr: "should have no non-flag arguments",
+		},
+		"no primary context": {
+			args:   []string{"-secondary-context", "dc2"},
+			expErr: "-primary-context must be set",
+		},
+		"no secondary context": {
+			args:   []string{"-primary-context", "dc1"},
+			expErr: "-secondary-context must be set",
+		},
+		"same datacenter": {
+			args:   []string{"-primary-context", "dc1", "-secondary-context", "dc1"},
+			expErr: "the primary and secondary datacenters must use different contexts or namespaces",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			cmd := getInitializedCommand(t, buf)
+			require.Equal(t, 1, cmd.Run(c.args))
+			require.Contains(t, buf.String(), c.expErr)
+		})
+	}
+}
+
+func getInitializedCommand(t *testing.T, buf *bytes.Buffer) *JoinCommand {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+		UI:  terminal.NewUI(context.Background(), buf),
+	}
+
+	c := &JoinCommand{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	pollInterval = time.Millisecond
+	return c
+}
+
+func federationSecret(data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultSecretName, Namespace: "consul"},
+		Data:       data,
+	}
+}
+
+// testConsulServer returns a Consul client for a fake Consul server in the datacenter with the WAN members.
+func testConsulServer(t *testing.T, datacenter string, wanMembers []*api.AgentMember) *api.Client {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/agent/members", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "1", r.URL.Query().Get("wan"))
+		require.NoError(t, json.NewEncoder(w).Encode(wanMembers))
+	})
+	mux.HandleFunc("/v1/agent/self", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"Config": map[string]interface{}{"Datacenter": datacenter},
+		}))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	consulClient, err := api.NewClient(&api.Config{Address: strings.TrimPrefix(server.URL, "http://")})
+	require.NoError(t, err)
+	return consulClient
+}
@@ -32,7 +32,6 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
-	"k8s.io/utils/strings/slices"
 	"sigs.k8s.io/yaml"
 )
 
@@ -69,6 +68,10 @@ const (
 
 	flagNameDemo = "demo"
 	defaultDemo  = false
+
+	flagNameExport  = "export"
+	exportTerraform = "terraform"
+	exportManifests = "manifests"
 )
 
 type Command struct {
@@ -96,6 +99,7 @@ type Command struct {
 	flagWait              bool
 	flagDemo              bool
 	flagNameHCPResourceID string
+	flagExport            string
 
 	flagKubeConfig  string
 	flagKubeContext string
@@ -119,6 +123,12 @@ func (c *Command) init() {
 		Default: defaultDryRun,
 		Usage:   "Perform pre-install checks and display a summary of the installation.",
 	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameExport,
+		Target: &c.flagExport,
+		Usage: fmt.Sprintf("Instead of installing Consul, validate the configuration and output it as a Terraform helm_release "+
+			"resource or as Kubernetes manifests, one of %s, %s. The Kubernetes cluster is not contacted.", exportTerraform, exportManifests),
+	})
 	f.StringSliceVar(&flag.StringSliceVar{
 		Name:    flagNameConfigFile,
 		Aliases: []string{"f"},
@@ -135,7 +145,9 @@ func (c *Command) init() {
 		Name:    flagNamePreset,
 		Target:  &c.flagPreset,
 		Default: defaultPreset,
-		Usage:   fmt.Sprintf("Use an installation preset, one of %s. Defaults to none", strings.Join(preset.Presets, ", ")),
+		Usage: fmt.Sprintf("Use an installation preset, one of %s, or the path to a YAML preset file. "+
+			"Values from -%s and -%s flags are merged over the preset. Defaults to none",
+			strings.Join(preset.Presets, ", "), flagNameConfigFile, flagNameSetValues),
 	})
 	f.StringSliceVar(&flag.StringSliceVar{
 		Name:   flagNameSetValues,
@@ -183,7 +195,7 @@ func (c *Command) init() {
 		Name:    flagNameHCPResourceID,
 		Target:  &c.flagNameHCPResourceID,
 		Default: "",
-		Usage:   "Set the HCP resource_id when using the 'cloud' preset.",
+		Usage:   "Set the HCP resource_id when using the 'cloud' or 'hcp-dataplane' preset.",
 	})
 
 	f = c.set.NewSet("Global Options")
@@ -221,6 +233,10 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
+	if c.flagExport != "" {
+		return c.export(helmCLI.New())
+	}
+
 	if c.flagDryRun {
 		c.UI.Output("Performing dry run install. No changes will be made to the cluster.", terminal.WithHeaderStyle())
 	}
@@ -441,6 +457,50 @@ func (c *Command) installConsul(valuesYaml []byte, vals map[string]interface{},
 	return nil
 }
 
+// export validates the configuration and outputs it as a Terraform helm_release resource or as Kubernetes
+// manifests without contacting the Kubernetes cluster, so that Consul can be installed by tools such as
+// Terraform or GitOps controllers.
+func (c *Command) export(settings *helmCLI.EnvSettings) int {
+	vals, err := c.mergeValuesFlagsWithPrecedence(settings)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	valuesYaml, err := yaml.Marshal(vals)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	// Unmarshalling into the Helm values struct validates the types of the values.
+	var helmVals helm.Values
+	if err := yaml.Unmarshal(valuesYaml, &helmVals); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	options := &helm.ExportOptions{
+		ReleaseName:       common.DefaultReleaseName,
+		Namespace:         c.flagNamespace,
+		Values:            common.MergeMaps(config.ConvertToMap(config.GlobalNameConsul), vals),
+		EmbeddedChart:     consulChart.ConsulHelmChart,
+		ChartDirName:      common.TopLevelChartDirName,
+		HelmActionsRunner: c.helmActionsRunner,
+	}
+	var out string
+	switch c.flagExport {
+	case exportTerraform:
+		out, err = helm.RenderTerraformHelmRelease(options)
+	case exportManifests:
+		out, err = helm.RenderManifests(options)
+	}
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	c.UI.Output(out)
+	return 0
+}
+
 // Help returns a description of the command and how it is used.
 func (c *Command) Help() string {
 	c.once.Do(c.init)
@@ -458,6 +518,7 @@ func (c *Command) Synopsis() string {
 func (c *Command) AutocompleteFlags() complete.Flags {
 	return complete.Flags{
 		fmt.Sprintf("-%s", flagNamePreset):          complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameExport):          complete.PredictSet(exportTerraform, exportManifests),
 		fmt.Sprintf("-%s", flagNameNamespace):       complete.PredictNothing,
 		fmt.Sprintf("-%s", flagNameDryRun):          complete.PredictNothing,
 		fmt.Sprintf("-%s", flagNameAutoApprove):     complete.PredictNothing,
@@ -588,29 +649,40 @@ func (c *Command) validateFlags(args []string) error {
 	if len(c.set.Args()) > 0 {
 		return errors.New("should have no non-flag arguments")
 	}
-	if len(c.flagValueFiles) != 0 && c.flagPreset != defaultPreset {
-		return fmt.Errorf("cannot set both -%s and -%s", flagNameConfigFile, flagNamePreset)
-	}
-	if ok := slices.Contains(preset.Presets, c.flagPreset); c.flagPreset != defaultPreset && !ok {
-		return fmt.Errorf("'%s' is not a valid preset (valid presets: %s)", c.flagPreset, strings.Join(preset.Presets, ", "))
+	if c.flagPreset != defaultPreset {
+		if err := preset.Validate(c.flagPreset); err != nil {
+			return err
+		}
 	}
 	if !common.IsValidLabel(c.flagNamespace) {
 		return fmt.Errorf("'%s' is an invalid namespace. Namespaces follow the RFC 1123 label convention and must "+
 			"consist of a lower case alphanumeric character or '-' and must start/end with an alphanumeric character", c.flagNamespace)
 	}
 
-	if c.flagPreset == preset.PresetCloud {
+	if preset.RequiresHCPConfig(c.flagPreset) {
 		clientID := os.Getenv(preset.EnvHCPClientID)
 		clientSecret := os.Getenv(preset.EnvHCPClientSecret)
 		if clientID == "" {
-			return fmt.Errorf("When '%s' is specified as the preset, the '%s' environment variable must also be set", preset.PresetCloud, preset.EnvHCPClientID)
+			return fmt.Errorf("When '%s' is specified as the preset, the '%s' environment variable must also be set", c.flagPreset, preset.EnvHCPClientID)
 		} else if clientSecret == "" {
-			return fmt.Errorf("When '%s' is specified as the preset, the '%s' environment variable must also be set", preset.PresetCloud, preset.EnvHCPClientSecret)
+			return fmt.Errorf("When '%s' is specified as the preset, the '%s' environment variable must also be set", c.flagPreset, preset.EnvHCPClientSecret)
 		} else if c.flagNameHCPResourceID == "" {
-			return fmt.Errorf("When '%s' is specified as the preset, the '%s' flag must also be provided", preset.PresetCloud, flagNameHCPResourceID)
+			return fmt.Errorf("When '%s' is specified as the preset, the '%s' flag must also be provided", c.flagPreset, flagNameHCPResourceID)
 		}
 	} else if c.flagNameHCPResourceID != "" {
-		return fmt.Errorf("The '%s' flag can only be used with the '%s' preset", flagNameHCPResourceID, preset.PresetCloud)
+		return fmt.Errorf("The '%s' flag can only be used with the '%s' or '%s' presets", flagNameHCPResourceID, preset.PresetCloud, preset.PresetHCPDataplane)
+	}
+
+	if c.flagExport != "" {
+		if c.flagExport != exportTerraform && c.flagExport != exportManifests {
+			return fmt.Errorf("'%s' is not a valid export format (valid formats: %s, %s)", c.flagExport, exportTerraform, exportManifests)
+		}
+		if preset.RequiresHCPConfig(c.flagPreset) {
+			return fmt.Errorf("cannot set -%s with the '%s' preset since it saves secrets to the Kubernetes cluster", flagNameExport, c.flagPreset)
+		}
+		if c.flagDemo {
+			return fmt.Errorf("cannot set both -%s and -%s", flagNameExport, flagNameDemo)
+		}
 	}
 
 	duration, err := time.ParseDuration(c.flagTimeout)
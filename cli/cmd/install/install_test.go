@@ -172,15 +172,15 @@ func TestValidateFlags(t *testing.T) {
 			[]string{"foo", "-auto-approve"},
 			"should have no non-flag arguments",
 		},
-		{
-			"Should disallow specifying both values file AND presets.",
-			[]string{"-f='f.txt'", "-preset=demo"},
-			"cannot set both -config-file and -preset",
-		},
 		{
 			"Should error on invalid presets.",
 			[]string{"-preset=foo"},
-			"'foo' is not a valid preset (valid presets: cloud, quickstart, secure)",
+			"'foo' is not a valid preset (valid presets: api-gateway-edge, cloud, hcp-dataplane, multi-cluster-primary, observability, quickstart, secure, or a path to a .yaml preset file)",
+		},
+		{
+			"Should error on a non-existent preset file.",
+			[]string{"-preset=does_not_exist.yaml"},
+			"reading preset file: open does_not_exist.yaml: no such file or directory",
 		},
 		{
 			"Should error on invalid timeout.",
@@ -197,6 +197,16 @@ func TestValidateFlags(t *testing.T) {
 			[]string{"-f=\"does_not_exist.txt\""},
 			"file '\"does_not_exist.txt\"' does not exist",
 		},
+		{
+			"Should error on an invalid export format.",
+			[]string{"-export=foo"},
+			"'foo' is not a valid export format (valid formats: terraform, manifests)",
+		},
+		{
+			"Should disallow specifying both export AND demo.",
+			[]string{"-export=manifests", "-demo"},
+			"cannot set both -export and -demo",
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -356,6 +366,32 @@ func TestValidateCloudPresets(t *testing.T) {
 			},
 			true,
 		},
+		{
+			"Should not error on hcp-dataplane preset when HCP_CLIENT_ID and HCP_CLIENT_SECRET envvars are present and hcp-resource-id parameter is provided.",
+			[]string{"-preset=hcp-dataplane", "-hcp-resource-id=foobar"},
+			func() {
+				os.Setenv("HCP_CLIENT_ID", "foo")
+				os.Setenv("HCP_CLIENT_SECRET", "bar")
+			},
+			func() {
+				os.Unsetenv("HCP_CLIENT_ID")
+				os.Unsetenv("HCP_CLIENT_SECRET")
+			},
+			false,
+		},
+		{
+			"Should error on hcp-dataplane preset when -hcp-resource-id flag is not provided.",
+			[]string{"-preset=hcp-dataplane"},
+			func() {
+				os.Setenv("HCP_CLIENT_ID", "foo")
+				os.Setenv("HCP_CLIENT_SECRET", "bar")
+			},
+			func() {
+				os.Unsetenv("HCP_CLIENT_ID")
+				os.Unsetenv("HCP_CLIENT_SECRET")
+			},
+			true,
+		},
 		{
 			"Should error when -hcp-resource-id flag is provided but cloud preset is not specified.",
 			[]string{"-hcp-resource-id=foobar"},
@@ -403,6 +439,22 @@ func TestGetPreset(t *testing.T) {
 			"'secure' should return a SecurePreset'.",
 			preset.PresetSecure,
 		},
+		{
+			"'observability' should return an ObservabilityPreset'.",
+			preset.PresetObservability,
+		},
+		{
+			"'multi-cluster-primary' should return a MultiClusterPrimaryPreset'.",
+			preset.PresetMultiClusterPrimary,
+		},
+		{
+			"'api-gateway-edge' should return an APIGatewayEdgePreset'.",
+			preset.PresetAPIGatewayEdge,
+		},
+		{
+			"A .yaml file should return a FilePreset'.",
+			"preset.yaml",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -417,7 +469,56 @@ func TestGetPreset(t *testing.T) {
 				require.Equal(t, preset.PresetQuickstart, tc.presetName)
 			case *preset.SecurePreset:
 				require.Equal(t, preset.PresetSecure, tc.presetName)
+			case *preset.ObservabilityPreset:
+				require.Equal(t, preset.PresetObservability, tc.presetName)
+			case *preset.MultiClusterPrimaryPreset:
+				require.Equal(t, preset.PresetMultiClusterPrimary, tc.presetName)
+			case *preset.APIGatewayEdgePreset:
+				require.Equal(t, preset.PresetAPIGatewayEdge, tc.presetName)
+			case *preset.FilePreset:
+				require.Equal(t, "preset.yaml", tc.presetName)
+			default:
+				t.Fatalf("unexpected preset type %T", p)
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	cases := map[string]struct {
+		input            []string
+		expectedContains []string
+	}{
+		"terraform": {
+			input: []string{"-export=terraform", "-set=server.replicas=3"},
+			expectedContains: []string{
+				`resource "helm_release" "consul" {`,
+				`  namespace        = "consul"`,
+				`  repository       = "https://helm.releases.hashicorp.com"`,
+				"    server:\n      replicas: 3\n",
+			},
+		},
+		"manifests": {
+			input: []string{"-export=manifests", "-set=server.replicas=3"},
+			expectedContains: []string{
+				"# Source: consul/templates/server-statefulset.yaml",
+				"replicas: 3",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			c := getInitializedCommand(t, buf)
+			c.helmActionsRunner = &helm.MockActionRunner{
+				LoadChartFunc: helm.LoadChart,
+			}
+			// No Kubernetes client is set, so the export must not contact the cluster.
+			require.Equal(t, 0, c.Run(tc.input))
+			for _, expected := range tc.expectedContains {
+				require.Contains(t, buf.String(), expected)
 			}
+			require.False(t, c.helmActionsRunner.(*helm.MockActionRunner).ConsulInstalled)
 		})
 	}
 }
@@ -0,0 +1,29 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package peering
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/mitchellh/cli"
+)
+
+// PeeringCommand provides a synopsis for the peering subcommands (e.g. establish).
+type PeeringCommand struct {
+	*common.BaseCommand
+}
+
+// Run prints out information about the subcommands.
+func (c *PeeringCommand) Run([]string) int {
+	return cli.RunResultHelp
+}
+
+func (c *PeeringCommand) Help() string {
+	return fmt.Sprintf("%s\n\nUsage: consul-k8s peering <subcommand>", c.Synopsis())
+}
+
+func (c *PeeringCommand) Synopsis() string {
+	return "Manage cluster peerings between Consul installations."
+}
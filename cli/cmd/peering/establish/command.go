@@ -0,0 +1,515 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package establish
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/posener/complete"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/configentry"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+)
+
+const (
+	flagNameSourceContext   = "source-context"
+	flagNameSourceNamespace = "source-namespace"
+	flagNameSourceName      = "source-name"
+	flagNameTargetContext   = "target-context"
+	flagNameTargetNamespace = "target-namespace"
+	flagNameTargetName      = "target-name"
+	flagNameTimeout         = "timeout"
+	flagNameKubeConfig      = "kubeconfig"
+
+	defaultTimeout = 5 * time.Minute
+
+	// tokenSecretKey is the key of the peering token in the token secret.
+	tokenSecretKey = "data"
+	// tokenSecretBackend is the backend that the peering token is stored in.
+	tokenSecretBackend = "kubernetes"
+)
+
+var (
+	peeringAcceptorGVR = schema.GroupVersionResource{Group: "consul.hashicorp.com", Version: "v1alpha1", Resource: "peeringacceptors"}
+	peeringDialerGVR   = schema.GroupVersionResource{Group: "consul.hashicorp.com", Version: "v1alpha1", Resource: "peeringdialers"}
+)
+
+// pollInterval is how often the peering token and the peering state are checked.
+var pollInterval = 2 * time.Second
+
+// cluster is a Kubernetes cluster that runs one side of the peering.
+type cluster struct {
+	// name is the name of the peer that the other cluster knows this cluster as.
+	name      string
+	namespace string
+
+	kubernetes kubernetes.Interface
+	dynamic    dynamic.Interface
+	restConfig *rest.Config
+
+	// consul is the client for the Consul servers of the cluster. It is created
+	// when it is needed if it is not set.
+	consul *api.Client
+}
+
+// EstablishCommand peers the Consul installations of two Kubernetes clusters by
+// creating a PeeringAcceptor in the source cluster and a PeeringDialer in the
+// target cluster.
+type EstablishCommand struct {
+	*common.BaseCommand
+
+	source cluster
+	target cluster
+
+	// portForwards are the port forwards to the Consul servers that the clients use, if the command opened them.
+	portForwards []common.PortForwarder
+
+	set *flag.Sets
+
+	flagSourceContext   string
+	flagSourceNamespace string
+	flagSourceName      string
+	flagTargetContext   string
+	flagTargetNamespace string
+	flagTargetName      string
+	flagTimeout         time.Duration
+	flagKubeConfig      string
+
+	once sync.Once
+	help string
+}
+
+// init sets up flags and help text for the command.
+func (c *EstablishCommand) init() {
+	c.set = flag.NewSets()
+
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameSourceContext,
+		Target: &c.flagSourceContext,
+		Usage:  "The Kubernetes context of the cluster that accepts the peering and generates the peering token.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameSourceNamespace,
+		Target:  &c.flagSourceNamespace,
+		Default: common.DefaultReleaseNamespace,
+		Usage:   "The namespace of the Consul installation in the source cluster. The PeeringAcceptor is created in this namespace.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameSourceName,
+		Target: &c.flagSourceName,
+		Usage:  "The name of the peer that the target cluster knows the source cluster as. Defaults to the source context.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameTargetContext,
+		Target: &c.flagTargetContext,
+		Usage:  "The Kubernetes context of the cluster that dials the source cluster with the peering token.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameTargetNamespace,
+		Target:  &c.flagTargetNamespace,
+		Default: common.DefaultReleaseNamespace,
+		Usage:   "The namespace of the Consul installation in the target cluster. The PeeringDialer and the peering token are created in this namespace.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:   flagNameTargetName,
+		Target: &c.flagTargetName,
+		Usage:  "The name of the peer that the source cluster knows the target cluster as. Defaults to the target context.",
+	})
+	f.DurationVar(&flag.DurationVar{
+		Name:    flagNameTimeout,
+		Target:  &c.flagTimeout,
+		Default: defaultTimeout,
+		Usage:   "How long to wait for the peering token and for the peering to become active.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameKubeConfig,
+		Aliases: []string{"c"},
+		Target:  &c.flagKubeConfig,
+		Default: "",
+		Usage:   "Set the path to kubeconfig file.",
+	})
+
+	c.help = c.set.Help()
+}
+
+// Run establishes the peering.
+func (c *EstablishCommand) Run(args []string) int {
+	c.once.Do(c.init)
+	c.Log.ResetNamed("peering establish")
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.set.Parse(args); err != nil {
+		c.UI.Output("Error parsing arguments: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	if err := c.validateFlags(); err != nil {
+		c.UI.Output("Invalid argument: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	c.source.name, c.source.namespace = c.flagSourceName, c.flagSourceNamespace
+	c.target.name, c.target.namespace = c.flagTargetName, c.flagTargetNamespace
+	if err := c.initKubernetes(); err != nil {
+		c.UI.Output("Error initializing Kubernetes client: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	// The acceptor is named after the peer it accepts, i.e. the target cluster,
+	// and the dialer after the peer it dials, i.e. the source cluster.
+	tokenSecret := fmt.Sprintf("%s-peering-token", c.target.name)
+	created, err := c.ensurePeeringResource(c.source, peeringAcceptorGVR, "PeeringAcceptor", c.target.name, tokenSecret)
+	if err != nil {
+		c.UI.Output("Error creating the PeeringAcceptor in the source cluster: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	c.outputResource("PeeringAcceptor", c.target.name, "source", created)
+
+	token, err := c.waitForToken(tokenSecret)
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	changed, err := c.copyToken(tokenSecret, token)
+	if err != nil {
+		c.UI.Output("Error copying the peering token to the target cluster: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	if changed {
+		c.UI.Output("Copied peering token secret %s to the target cluster", tokenSecret, terminal.WithSuccessStyle())
+	} else {
+		c.UI.Output("Peering token secret %s in the target cluster is up to date", tokenSecret, terminal.WithSuccessStyle())
+	}
+
+	created, err = c.ensurePeeringResource(c.target, peeringDialerGVR, "PeeringDialer", c.source.name, tokenSecret)
+	if err != nil {
+		c.UI.Output("Error creating the PeeringDialer in the target cluster: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	c.outputResource("PeeringDialer", c.source.name, "target", created)
+
+	err = c.initConsulClients()
+	for _, pf := range c.portForwards {
+		defer pf.Close()
+	}
+	if err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	c.UI.Output("Waiting for the peering to become active", terminal.WithInfoStyle())
+	if err := c.waitForActive(); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+	c.UI.Output("Peering between %s and %s is active", c.source.name, c.target.name, terminal.WithSuccessStyle())
+	return 0
+}
+
+// validateFlags checks that the flags are valid and defaults the peer names
+// to the contexts.
+func (c *EstablishCommand) validateFlags() error {
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if c.flagSourceContext == "" {
+		return fmt.Errorf("-%s must be set", flagNameSourceContext)
+	}
+	if c.flagTargetContext == "" {
+		return fmt.Errorf("-%s must be set", flagNameTargetContext)
+	}
+	if c.flagSourceName == "" {
+		c.flagSourceName = c.flagSourceContext
+	}
+	if c.flagTargetName == "" {
+		c.flagTargetName = c.flagTargetContext
+	}
+	for flagName, name := range map[string]string{flagNameSourceName: c.flagSourceName, flagNameTargetName: c.flagTargetName} {
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return fmt.Errorf("%q is not a valid peer name, set -%s: %s", name, flagName, errs[0])
+		}
+	}
+	if c.flagSourceName == c.flagTargetName {
+		return errors.New("the source and target clusters must have different peer names")
+	}
+	if c.flagTimeout <= 0 {
+		return fmt.Errorf("-%s must be greater than 0", flagNameTimeout)
+	}
+	return nil
+}
+
+// ensurePeeringResource creates the PeeringAcceptor or PeeringDialer in the
+// cluster if it doesn't exist and returns true if it was created. Existing
+// resources are left as they are so that re-running the command doesn't
+// generate a new peering token.
+func (c *EstablishCommand) ensurePeeringResource(cl cluster, gvr schema.GroupVersionResource, kind, name, tokenSecret string) (bool, error) {
+	resources := cl.dynamic.Resource(gvr).Namespace(cl.namespace)
+	_, err := resources.Get(c.Ctx, name, metav1.GetOptions{})
+	if err == nil {
+		return false, nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		return false, err
+	}
+
+	resource := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": gvr.GroupVersion().String(),
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": cl.namespace,
+		},
+		"spec": map[string]interface{}{
+			"peer": map[string]interface{}{
+				"secret": map[string]interface{}{
+					"name":    tokenSecret,
+					"key":     tokenSecretKey,
+					"backend": tokenSecretBackend,
+				},
+			},
+		},
+	}}
+	if _, err := resources.Create(c.Ctx, resource, metav1.CreateOptions{}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// waitForToken waits until the PeeringAcceptor has written the peering token
+// to its secret in the source cluster and returns the token.
+func (c *EstablishCommand) waitForToken(tokenSecret string) ([]byte, error) {
+	var token []byte
+	err := c.poll(func() (bool, error) {
+		secret, err := c.source.kubernetes.CoreV1().Secrets(c.source.namespace).Get(c.Ctx, tokenSecret, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		token = secret.Data[tokenSecretKey]
+		return len(token) > 0, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error waiting for the peering token in the source cluster: %w", err)
+	}
+	return token, nil
+}
+
+// copyToken creates or updates the peering token secret in the target cluster
+// and returns true if its token changed.
+func (c *EstablishCommand) copyToken(tokenSecret string, token []byte) (bool, error) {
+	secrets := c.target.kubernetes.CoreV1().Secrets(c.target.namespace)
+	existing, err := secrets.Get(c.Ctx, tokenSecret, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		_, err := secrets.Create(c.Ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      tokenSecret,
+				Namespace: c.target.namespace,
+				Labels:    map[string]string{common.CLILabelKey: common.CLILabelValue},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{tokenSecretKey: token},
+		}, metav1.CreateOptions{})
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(existing.Data[tokenSecretKey], token) {
+		return false, nil
+	}
+	if existing.Data == nil {
+		existing.Data = make(map[string][]byte)
+	}
+	existing.Data[tokenSecretKey] = token
+	if _, err := secrets.Update(c.Ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// waitForActive waits until the peering is active in both clusters.
+func (c *EstablishCommand) waitForActive() error {
+	var states [2]api.PeeringState
+	err := c.poll(func() (bool, error) {
+		for i, side := range []struct {
+			consul *api.Client
+			peer   string
+		}{
+			{c.source.consul, c.target.name},
+			{c.target.consul, c.source.name},
+		} {
+			peering, _, err := side.consul.Peerings().Read(c.Ctx, side.peer, nil)
+			if err != nil {
+				return false, err
+			}
+			states[i] = api.PeeringStateUndefined
+			if peering != nil {
+				states[i] = peering.State
+			}
+		}
+		return states[0] == api.PeeringStateActive && states[1] == api.PeeringStateActive, nil
+	})
+	if err != nil {
+		return fmt.Errorf("error waiting for the peering to become active (source: %s, target: %s): %w", states[0], states[1], err)
+	}
+	return nil
+}
+
+// poll calls the condition until it returns true or an error, or the timeout
+// is reached.
+func (c *EstablishCommand) poll(condition func() (bool, error)) error {
+	timer := time.NewTimer(c.flagTimeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		done, err := condition()
+		if err != nil || done {
+			return err
+		}
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			return errors.New("timed out")
+		case <-c.Ctx.Done():
+			return c.Ctx.Err()
+		}
+	}
+}
+
+// outputResource outputs whether the peering resource was created or already existed.
+func (c *EstablishCommand) outputResource(kind, name, side string, created bool) {
+	if created {
+		c.UI.Output("Created %s %s in the %s cluster", kind, name, side, terminal.WithSuccessStyle())
+		return
+	}
+	c.UI.Output("%s %s already exists in the %s cluster", kind, name, side, terminal.WithSuccessStyle())
+}
+
+// initKubernetes initializes the Kubernetes clients of both clusters.
+func (c *EstablishCommand) initKubernetes() error {
+	for _, side := range []struct {
+		cluster     *cluster
+		kubeContext string
+	}{
+		{&c.source, c.flagSourceContext},
+		{&c.target, c.flagTargetContext},
+	} {
+		if side.cluster.kubernetes != nil && side.cluster.dynamic != nil {
+			continue
+		}
+		settings := helmCLI.New()
+		if c.flagKubeConfig != "" {
+			settings.KubeConfig = c.flagKubeConfig
+		}
+		settings.KubeContext = side.kubeContext
+
+		restConfig, err := settings.RESTClientGetter().ToRESTConfig()
+		if err != nil {
+			return fmt.Errorf("error creating Kubernetes REST config for context %s: %v", side.kubeContext, err)
+		}
+		side.cluster.restConfig = restConfig
+		if side.cluster.kubernetes, err = kubernetes.NewForConfig(restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client for context %s: %v", side.kubeContext, err)
+		}
+		if side.cluster.dynamic, err = dynamic.NewForConfig(restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client for context %s: %v", side.kubeContext, err)
+		}
+	}
+	return nil
+}
+
+// initConsulClients connects to the Consul servers of both clusters. The port
+// forwards it opens must be closed when the clients are no longer used.
+func (c *EstablishCommand) initConsulClients() error {
+	for _, side := range []struct {
+		cluster *cluster
+		name    string
+	}{
+		{&c.source, "source"},
+		{&c.target, "target"},
+	} {
+		if side.cluster.consul != nil {
+			continue
+		}
+		client, pf, err := configentry.ConnectToServer(c.Ctx, side.cluster.kubernetes, side.cluster.restConfig, side.cluster.namespace, "")
+		if err != nil {
+			return fmt.Errorf("error connecting to the Consul servers of the %s cluster: %w", side.name, err)
+		}
+		c.portForwards = append(c.portForwards, pf)
+		side.cluster.consul = client
+	}
+	return nil
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command. The map key for the Flags map should be the
+// complete flag such as "-foo" or "--foo".
+func (c *EstablishCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		fmt.Sprintf("-%s", flagNameSourceContext):   complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameSourceNamespace): complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameSourceName):      complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameTargetContext):   complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameTargetNamespace): complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameTargetName):      complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameTimeout):         complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameKubeConfig):      complete.PredictFiles("*"),
+	}
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+// Since argument completion is not supported, this will return
+// complete.PredictNothing.
+func (c *EstablishCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *EstablishCommand) Synopsis() string {
+	return synopsis
+}
+
+func (c *EstablishCommand) Help() string {
+	c.once.Do(c.init)
+	return fmt.Sprintf("%s\n%s", help, c.help)
+}
+
+const (
+	synopsis = "Establish a cluster peering between the Consul installations of two Kubernetes clusters."
+	help     = `
+Usage: consul-k8s peering establish [options]
+
+  Creates a PeeringAcceptor in the source cluster, copies the peering token it
+  generates to the target cluster and creates a PeeringDialer there. Then waits
+  until the peering is active in both clusters.
+
+  The command can be run again to wait for an existing peering. Existing
+  PeeringAcceptors and PeeringDialers are not changed, so no new peering token
+  is generated.
+
+  Examples:
+    $ consul-k8s peering establish -source-context cluster-1 -target-context cluster-2
+    $ consul-k8s peering establish -source-context arn:aws:eks:us-east-1:123:cluster/east \
+        -source-name east -target-context gke_project_us-west1_west -target-name west
+`
+)
@@ -0,0 +1,237 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package establish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRun(t *testing.T) {
+	cases := map[string]struct {
+		sourceToken    string
+		sourceObjects  []runtime.Object
+		targetObjects  []runtime.Object
+		targetToken    string
+		peeringState   api.PeeringState
+		expCode        int
+		expOutput      []string
+		expTargetToken string
+	}{
+		"establishes a new peering": {
+			sourceToken:  "token",
+			peeringState: api.PeeringStateActive,
+			expOutput: []string{
+				"Created PeeringAcceptor cluster-2 in the source cluster",
+				"Copied peering token secret cluster-2-peering-token to the target cluster",
+				"Created PeeringDialer cluster-1 in the target cluster",
+				"Peering between cluster-1 and cluster-2 is active",
+			},
+			expTargetToken: "token",
+		},
+		"re-running does not change an existing peering": {
+			sourceToken:   "token",
+			sourceObjects: []runtime.Object{peeringResource("PeeringAcceptor", "cluster-2")},
+			targetObjects: []runtime.Object{peeringResource("PeeringDialer", "cluster-1")},
+			targetToken:   "token",
+			peeringState:  api.PeeringStateActive,
+			expOutput: []string{
+				"PeeringAcceptor cluster-2 already exists in the source cluster",
+				"Peering token secret cluster-2-peering-token in the target cluster is up to date",
+				"PeeringDialer cluster-1 already exists in the target cluster",
+				"Peering between cluster-1 and cluster-2 is active",
+			},
+			expTargetToken: "token",
+		},
+		"updates the token in the target cluster": {
+			sourceToken:   "new-token",
+			sourceObjects: []runtime.Object{peeringResource("PeeringAcceptor", "cluster-2")},
+			targetObjects: []runtime.Object{peeringResource("PeeringDialer", "cluster-1")},
+			targetToken:   "old-token",
+			peeringState:  api.PeeringStateActive,
+			expOutput: []string{
+				"Copied peering token secret cluster-2-peering-token to the target cluster",
+				"Peering between cluster-1 and cluster-2 is active",
+			},
+			expTargetToken: "new-token",
+		},
+		"times out waiting for the token": {
+			expCode:   1,
+			expOutput: []string{"error waiting for the peering token in the source cluster: timed out"},
+		},
+		"times out waiting for the peering to become active": {
+			sourceToken:    "token",
+			peeringState:   api.PeeringStatePending,
+			expCode:        1,
+			expOutput:      []string{"error waiting for the peering to become active (source: PENDING, target: PENDING): timed out"},
+			expTargetToken: "token",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			cmd := getInitializedCommand(t, buf)
+
+			sourceObjects := []runtime.Object{}
+			if c.sourceToken != "" {
+				sourceObjects = append(sourceObjects, tokenSecret(c.sourceToken))
+			}
+			targetObjects := []runtime.Object{}
+			if c.targetToken != "" {
+				targetObjects = append(targetObjects, tokenSecret(c.targetToken))
+			}
+			cmd.source.kubernetes = fake.NewSimpleClientset(sourceObjects...)
+			cmd.source.dynamic = dynamicFake.NewSimpleDynamicClient(runtime.NewScheme(), c.sourceObjects...)
+			cmd.source.consul = testConsulServer(t, "cluster-2", c.peeringState)
+			cmd.target.kubernetes = fake.NewSimpleClientset(targetObjects...)
+			cmd.target.dynamic = dynamicFake.NewSimpleDynamicClient(runtime.NewScheme(), c.targetObjects...)
+			cmd.target.consul = testConsulServer(t, "cluster-1", c.peeringState)
+
+			require.Equal(t, c.expCode, cmd.Run([]string{"-source-context", "cluster-1", "-target-context", "cluster-2", "-timeout", "50ms"}), buf.String())
+			output := buf.String()
+			for _, s := range c.expOutput {
+				require.Contains(t, output, s)
+			}
+
+			acceptor, err := cmd.source.dynamic.Resource(peeringAcceptorGVR).Namespace("consul").Get(context.Background(), "cluster-2", metav1.GetOptions{})
+			require.NoError(t, err)
+			secretName, _, _ := unstructured.NestedString(acceptor.Object, "spec", "peer", "secret", "name")
+			require.Equal(t, "cluster-2-peering-token", secretName)
+
+			if c.expTargetToken == "" {
+				return
+			}
+			secret, err := cmd.target.kubernetes.CoreV1().Secrets("consul").Get(context.Background(), "cluster-2-peering-token", metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, c.expTargetToken, string(secret.Data[tokenSecretKey]))
+
+			dialer, err := cmd.target.dynamic.Resource(peeringDialerGVR).Namespace("consul").Get(context.Background(), "cluster-1", metav1.GetOptions{})
+			require.NoError(t, err)
+			secretName, _, _ = unstructured.NestedString(dialer.Object, "spec", "peer", "secret", "name")
+			require.Equal(t, "cluster-2-peering-token", secretName)
+		})
+	}
+}
+
+func TestRun_FlagValidation(t *testing.T) {
+	cases := map[string]struct {
+		args   []string
+		expErr string
+	}{
+		"arguments": {
+			args:   []string{"-source-context", "cluster-1", "-target-context", "cluster-2", "foo"},
+			expErr: "should have no non-flag arguments",
+		},
+		"no source context": {
+			args:   []string{"-target-context", "cluster-2"},
+			expErr: "-source-context must be set",
+		},
+		"no target context": {
+			args:   []string{"-source-context", "cluster-1"},
+			expErr: "-target-context must be set",
+		},
+		"context is not a valid peer name": {
+			args:   []string{"-source-context", "arn:aws:eks:us-east-1:123:cluster/east", "-target-context", "cluster-2"},
+			expErr: `"arn:aws:eks:us-east-1:123:cluster/east" is not a valid peer name, set -source-name`,
+		},
+		"same peer names": {
+			args:   []string{"-source-context", "cluster-1", "-target-context", "cluster-2", "-source-name", "east", "-target-name", "east"},
+			expErr: "the source and target clusters must have different peer names",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			cmd := getInitializedCommand(t, buf)
+			require.Equal(t, 1, cmd.Run(c.args))
+			require.Contains(t, buf.String(), c.expErr)
+		})
+	}
+}
+
+func getInitializedCommand(t *testing.T, buf *bytes.Buffer) *EstablishCommand {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+		UI:  terminal.NewUI(context.Background(), buf),
+	}
+
+	c := &EstablishCommand{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	pollInterval = time.Millisecond
+	return c
+}
+
+func tokenSecret(token string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-2-peering-token", Namespace: "consul"},
+		Data:       map[string][]byte{tokenSecretKey: []byte(token)},
+	}
+}
+
+func peeringResource(kind, name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "consul.hashicorp.com/v1alpha1",
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "consul",
+		},
+		"spec": map[string]interface{}{
+			"peer": map[string]interface{}{
+				"secret": map[string]interface{}{
+					"name":    "cluster-2-peering-token",
+					"key":     tokenSecretKey,
+					"backend": tokenSecretBackend,
+				},
+			},
+		},
+	}}
+}
+
+// testConsulServer returns a Consul client for a fake Consul server with a peering in the state.
+func testConsulServer(t *testing.T, peer string, state api.PeeringState) *api.Client {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/peering/", func(w http.ResponseWriter, r *http.Request) {
+		if state == "" || r.URL.Path != "/v1/peering/"+peer {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(api.Peering{Name: peer, State: state}))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	consulClient, err := api.NewClient(&api.Config{Address: strings.TrimPrefix(server.URL, "http://")})
+	require.NoError(t, err)
+	return consulClient
+}
@@ -0,0 +1,569 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package restoreresync
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/posener/complete"
+	"helm.sh/helm/v3/pkg/action"
+	helmCLI "helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiext "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/flag"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+)
+
+const (
+	// consulGroup is the API group of the Consul custom resource definitions.
+	consulGroup = "consul.hashicorp.com"
+
+	// annotationResyncedAt is set on every Consul custom resource so that the
+	// config entry controllers reconcile it and write it back to Consul.
+	annotationResyncedAt = "consul.hashicorp.com/resynced-at"
+
+	// aclInitSelector selects the server-acl-init Job of the Helm chart.
+	aclInitSelector = "app=consul,component=server-acl-init"
+
+	// connectInjectorSelector and syncCatalogSelector select the pods that serve
+	// the /full-sync endpoint, which connectInjectorPort and syncCatalogPort are
+	// the ports of.
+	connectInjectorSelector = "app=consul,component=connect-injector"
+	connectInjectorPort     = 9444
+	syncCatalogSelector     = "app=consul,component=sync-catalog"
+	syncCatalogPort         = 8080
+
+	flagNameNamespace   = "namespace"
+	flagNameTimeout     = "timeout"
+	flagNameDryRun      = "dry-run"
+	flagNameKubeConfig  = "kubeconfig"
+	flagNameKubeContext = "context"
+
+	defaultTimeout = 10 * time.Minute
+)
+
+// pollInterval is how often the status of the server-acl-init Job is checked.
+var pollInterval = 2 * time.Second
+
+// Command re-registers the state that Consul on Kubernetes derives from
+// Kubernetes after the Consul servers are restored from a snapshot, rather
+// than waiting for it to be repaired by the periodic syncs.
+type Command struct {
+	*common.BaseCommand
+
+	helmActionsRunner helm.HelmActionsRunner
+	settings          *helmCLI.EnvSettings
+
+	kubernetes kubernetes.Interface
+	apiext     apiext.Interface
+	dynamic    dynamic.Interface
+	restConfig *rest.Config
+
+	// requestFullSync requests a full sync from the component that the port
+	// forward is opened to.
+	requestFullSync func(common.PortForwarder) error
+
+	set *flag.Sets
+
+	flagNamespace   string
+	flagTimeout     time.Duration
+	flagDryRun      bool
+	flagKubeConfig  string
+	flagKubeContext string
+
+	once sync.Once
+	help string
+}
+
+// init sets up flags and help text for the command.
+func (c *Command) init() {
+	c.set = flag.NewSets()
+
+	f := c.set.NewSet("Command Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameNamespace,
+		Target:  &c.flagNamespace,
+		Usage:   "The namespace Consul is installed in. If not set, the namespace of the Consul installation is detected.",
+		Aliases: []string{"n"},
+	})
+	f.DurationVar(&flag.DurationVar{
+		Name:    flagNameTimeout,
+		Target:  &c.flagTimeout,
+		Default: defaultTimeout,
+		Usage:   "How long to wait for the ACL resources to be re-created.",
+	})
+	f.BoolVar(&flag.BoolVar{
+		Name:    flagNameDryRun,
+		Target:  &c.flagDryRun,
+		Default: false,
+		Usage:   "List the state that would be re-registered without re-registering it.",
+	})
+
+	f = c.set.NewSet("Global Options")
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameKubeConfig,
+		Aliases: []string{"c"},
+		Target:  &c.flagKubeConfig,
+		Default: "",
+		Usage:   "Set the path to kubeconfig file.",
+	})
+	f.StringVar(&flag.StringVar{
+		Name:    flagNameKubeContext,
+		Target:  &c.flagKubeContext,
+		Default: "",
+		Usage:   "Set the Kubernetes context to use.",
+	})
+
+	c.help = c.set.Help()
+}
+
+// Run re-registers the ACL resources, config entries and services in order.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	if c.helmActionsRunner == nil {
+		c.helmActionsRunner = &helm.ActionRunner{}
+	}
+	if c.requestFullSync == nil {
+		c.requestFullSync = c.postFullSync
+	}
+
+	c.Log.ResetNamed("restore-resync")
+	defer common.CloseWithError(c.BaseCommand)
+
+	if err := c.set.Parse(args); err != nil {
+		c.UI.Output("Error parsing arguments: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	if err := c.validateFlags(); err != nil {
+		c.UI.Output("Invalid argument: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	settings := helmCLI.New()
+	if c.flagKubeConfig != "" {
+		settings.KubeConfig = c.flagKubeConfig
+	}
+	if c.flagKubeContext != "" {
+		settings.KubeContext = c.flagKubeContext
+	}
+	c.settings = settings
+
+	if err := c.initKubernetes(settings); err != nil {
+		c.UI.Output("Error initializing Kubernetes client: %v", err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
+	namespace := c.flagNamespace
+	if namespace == "" {
+		_, _, ns, err := c.helmActionsRunner.CheckForInstallations(&helm.CheckForInstallationsOptions{
+			Settings:    settings,
+			ReleaseName: common.DefaultReleaseName,
+			DebugLog:    c.uiLogger,
+		})
+		if err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+		namespace = ns
+	}
+
+	c.UI.Output("Re-registering Kubernetes state with Consul in namespace %s", namespace, terminal.WithHeaderStyle())
+
+	// The steps are run in order since each depends on the previous ones: the
+	// controllers need their ACL tokens to write config entries, and services
+	// can't be registered until their service defaults exist.
+	steps := []struct {
+		description string
+		run         func(namespace string) error
+	}{
+		{"ACL resources", c.resyncACLs},
+		{"config entries", c.resyncConfigEntries},
+		{"services", c.resyncServices},
+	}
+	for _, step := range steps {
+		if err := step.run(namespace); err != nil {
+			c.UI.Output("Error re-registering %s: %v", step.description, err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	}
+
+	if c.flagDryRun {
+		c.UI.Output("Dry run complete. No state was re-registered.", terminal.WithSuccessStyle())
+	} else {
+		c.UI.Output("Re-registered Kubernetes state with Consul.", terminal.WithSuccessStyle())
+	}
+	return 0
+}
+
+// resyncACLs re-runs the server-acl-init Job so that it re-creates the ACL
+// policies, roles, auth methods and tokens of the installation. The chart's
+// cleanup hook deletes the Job after every successful install or upgrade, so
+// it's usually re-created from the manifest of the Helm release. The Job is
+// skipped if the release doesn't have one, i.e. if it doesn't manage ACLs.
+func (c *Command) resyncACLs(namespace string) error {
+	jobs, err := c.kubernetes.BatchV1().Jobs(namespace).List(c.Ctx, metav1.ListOptions{LabelSelector: aclInitSelector})
+	if err != nil {
+		return err
+	}
+	fromRelease := len(jobs.Items) == 0
+	if fromRelease {
+		job, err := c.aclInitJobFromRelease(namespace)
+		if err != nil {
+			return fmt.Errorf("reading the server-acl-init Job from the Helm release: %w", err)
+		}
+		if job == nil {
+			c.UI.Output("The Helm release has no server-acl-init Job, skipping ACL resources", terminal.WithInfoStyle())
+			return nil
+		}
+		jobs.Items = append(jobs.Items, *job)
+	}
+	for _, job := range jobs.Items {
+		if c.flagDryRun {
+			c.UI.Output("Would re-run Job %s", job.Name, terminal.WithInfoStyle())
+			continue
+		}
+		if err := c.rerunJob(job); err != nil {
+			return fmt.Errorf("re-running Job %s: %w", job.Name, err)
+		}
+		c.UI.Output("Re-ran Job %s", job.Name, terminal.WithSuccessStyle())
+		if fromRelease {
+			// Clean up the Job like the chart's cleanup hook does, since Helm
+			// can't upgrade it in place if its template changes.
+			propagation := metav1.DeletePropagationBackground
+			err := c.kubernetes.BatchV1().Jobs(job.Namespace).Delete(c.Ctx, job.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+			if err != nil && !k8serrors.IsNotFound(err) {
+				return fmt.Errorf("deleting Job %s: %w", job.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// aclInitJobFromRelease returns the server-acl-init Job in the manifest of the
+// Consul Helm release in the namespace, or nil if the release doesn't have one.
+func (c *Command) aclInitJobFromRelease(namespace string) (*batchv1.Job, error) {
+	_, releaseName, _, err := c.helmActionsRunner.CheckForInstallations(&helm.CheckForInstallationsOptions{
+		Settings:    c.settings,
+		ReleaseName: common.DefaultReleaseName,
+		DebugLog:    c.uiLogger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	statusConfig, err := helm.InitActionConfig(new(action.Configuration), namespace, c.settings, c.uiLogger)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := c.helmActionsRunner.GetStatus(action.NewStatus(statusConfig), releaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, manifest := range releaseutil.SplitManifests(rel.Manifest) {
+		var job batchv1.Job
+		if err := yaml.Unmarshal([]byte(manifest), &job); err != nil {
+			return nil, err
+		}
+		if job.Kind != "Job" || job.Labels["component"] != "server-acl-init" {
+			continue
+		}
+		if job.Namespace == "" {
+			job.Namespace = namespace
+		}
+		return &job, nil
+	}
+	return nil, nil
+}
+
+// uiLogger streams the logs of the Helm library.
+func (c *Command) uiLogger(s string, args ...interface{}) {
+	c.UI.Output(fmt.Sprintf(s, args...), terminal.WithLibraryStyle())
+}
+
+// rerunJob replaces the Job with a copy of it and waits for the copy to complete.
+func (c *Command) rerunJob(job batchv1.Job) error {
+	rerun := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        job.Name,
+			Namespace:   job.Namespace,
+			Labels:      job.Labels,
+			Annotations: job.Annotations,
+		},
+		Spec: *job.Spec.DeepCopy(),
+	}
+	// The selector and its labels are generated by Kubernetes for each Job, so
+	// they're removed for new ones to be generated for the copy.
+	rerun.Spec.Selector = nil
+	for _, label := range []string{"controller-uid", "job-name", "batch.kubernetes.io/controller-uid", "batch.kubernetes.io/job-name"} {
+		delete(rerun.Spec.Template.Labels, label)
+	}
+
+	jobs := c.kubernetes.BatchV1().Jobs(job.Namespace)
+	propagation := metav1.DeletePropagationBackground
+	err := jobs.Delete(c.Ctx, job.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	if _, err := jobs.Create(c.Ctx, rerun, metav1.CreateOptions{}); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	timeout := time.After(c.flagTimeout)
+	for {
+		current, err := jobs.Get(c.Ctx, job.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		for _, cond := range current.Status.Conditions {
+			if cond.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch cond.Type {
+			case batchv1.JobComplete:
+				return nil
+			case batchv1.JobFailed:
+				return fmt.Errorf("job failed: %s", cond.Message)
+			}
+		}
+		select {
+		case <-c.Ctx.Done():
+			return c.Ctx.Err()
+		case <-timeout:
+			return fmt.Errorf("timed out after %s waiting for the job to complete", c.flagTimeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// resyncConfigEntries annotates every Consul custom resource so that the
+// controllers reconcile it and write its config entry back to Consul.
+func (c *Command) resyncConfigEntries(_ string) error {
+	crds, err := c.apiext.ApiextensionsV1().CustomResourceDefinitions().List(c.Ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, annotationResyncedAt, time.Now().UTC().Format(time.RFC3339)))
+	for _, crd := range crds.Items {
+		if crd.Spec.Group != consulGroup {
+			continue
+		}
+		var version string
+		for _, v := range crd.Spec.Versions {
+			if v.Storage {
+				version = v.Name
+			}
+		}
+		gvr := schema.GroupVersionResource{Group: crd.Spec.Group, Version: version, Resource: crd.Spec.Names.Plural}
+		list, err := c.dynamic.Resource(gvr).List(c.Ctx, metav1.ListOptions{})
+		if err != nil {
+			return fmt.Errorf("listing %s: %w", crd.Name, err)
+		}
+		if len(list.Items) == 0 {
+			continue
+		}
+		if c.flagDryRun {
+			c.UI.Output("Would re-register %d %s", len(list.Items), crd.Spec.Names.Plural, terminal.WithInfoStyle())
+			continue
+		}
+		for _, item := range list.Items {
+			_, err := c.dynamic.Resource(gvr).Namespace(item.GetNamespace()).
+				Patch(c.Ctx, item.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+			if k8serrors.IsNotFound(err) {
+				// The resource was deleted since it was listed so it doesn't need to be re-registered.
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("annotating %s %s/%s: %w", crd.Spec.Names.Kind, item.GetNamespace(), item.GetName(), err)
+			}
+		}
+		c.UI.Output("Re-registered %d %s", len(list.Items), crd.Spec.Names.Plural, terminal.WithSuccessStyle())
+	}
+	return nil
+}
+
+// resyncServices requests a full sync from the connect injector, which
+// re-registers the services of injected pods, and from catalog sync, which
+// re-registers the Kubernetes services that it syncs to Consul.
+func (c *Command) resyncServices(namespace string) error {
+	components := []struct {
+		name     string
+		selector string
+		port     int
+	}{
+		{"connect-injector", connectInjectorSelector, connectInjectorPort},
+		{"sync-catalog", syncCatalogSelector, syncCatalogPort},
+	}
+	for _, component := range components {
+		pods, err := c.kubernetes.CoreV1().Pods(namespace).List(c.Ctx, metav1.ListOptions{LabelSelector: component.selector})
+		if err != nil {
+			return err
+		}
+		var names []string
+		for _, pod := range pods.Items {
+			if pod.Status.Phase == corev1.PodRunning {
+				names = append(names, pod.Name)
+			}
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			c.UI.Output("No running %s pods found, skipping", component.name, terminal.WithInfoStyle())
+			continue
+		}
+		for _, name := range names {
+			if c.flagDryRun {
+				c.UI.Output("Would request a full sync from %s", name, terminal.WithInfoStyle())
+				continue
+			}
+			pf := &common.PortForward{
+				Namespace:  namespace,
+				PodName:    name,
+				RemotePort: component.port,
+				KubeClient: c.kubernetes,
+				RestConfig: c.restConfig,
+			}
+			if err := c.requestFullSync(pf); err != nil {
+				return fmt.Errorf("requesting a full sync from %s: %w", name, err)
+			}
+			c.UI.Output("Requested a full sync from %s", name, terminal.WithSuccessStyle())
+		}
+	}
+	return nil
+}
+
+// postFullSync requests a full sync with a POST to the /full-sync endpoint.
+func (c *Command) postFullSync(pf common.PortForwarder) error {
+	endpoint, err := pf.Open(c.Ctx)
+	if err != nil {
+		return err
+	}
+	defer pf.Close()
+
+	resp, err := http.Post(fmt.Sprintf("http://%s/full-sync", endpoint), "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// validateFlags checks the command line flags and values for errors.
+func (c *Command) validateFlags() error {
+	if len(c.set.Args()) > 0 {
+		return errors.New("should have no non-flag arguments")
+	}
+	if errs := validation.ValidateNamespaceName(c.flagNamespace, false); c.flagNamespace != "" && len(errs) > 0 {
+		return fmt.Errorf("invalid namespace name passed for -namespace/-n: %v", strings.Join(errs, "; "))
+	}
+	if c.flagTimeout <= 0 {
+		return fmt.Errorf("-%s must be greater than 0", flagNameTimeout)
+	}
+	return nil
+}
+
+// initKubernetes initializes the Kubernetes clients.
+func (c *Command) initKubernetes(settings *helmCLI.EnvSettings) error {
+	if c.kubernetes != nil && c.apiext != nil && c.dynamic != nil {
+		return nil
+	}
+	var err error
+	if c.restConfig == nil {
+		if c.restConfig, err = settings.RESTClientGetter().ToRESTConfig(); err != nil {
+			return fmt.Errorf("error retrieving Kubernetes authentication %v", err)
+		}
+	}
+	if c.kubernetes == nil {
+		if c.kubernetes, err = kubernetes.NewForConfig(c.restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client %v", err)
+		}
+	}
+	if c.apiext == nil {
+		if c.apiext, err = apiext.NewForConfig(c.restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client %v", err)
+		}
+	}
+	if c.dynamic == nil {
+		if c.dynamic, err = dynamic.NewForConfig(c.restConfig); err != nil {
+			return fmt.Errorf("error creating Kubernetes client %v", err)
+		}
+	}
+	return nil
+}
+
+// Help returns a description of the command and how it is used.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return fmt.Sprintf("%s\n%s", help, c.help)
+}
+
+// Synopsis returns a one-line command summary.
+func (c *Command) Synopsis() string {
+	return synopsis
+}
+
+// AutocompleteFlags returns a mapping of supported flags and autocomplete
+// options for this command. The map key for the Flags map should be the
+// complete flag such as "-foo" or "--foo".
+func (c *Command) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		fmt.Sprintf("-%s", flagNameNamespace):   complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameTimeout):     complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameDryRun):      complete.PredictNothing,
+		fmt.Sprintf("-%s", flagNameKubeConfig):  complete.PredictFiles("*"),
+		fmt.Sprintf("-%s", flagNameKubeContext): complete.PredictNothing,
+	}
+}
+
+// AutocompleteArgs returns the argument predictor for this command.
+// Since argument completion is not supported, this will return
+// complete.PredictNothing.
+func (c *Command) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+const (
+	synopsis = "Re-register Kubernetes state with Consul after a snapshot restore."
+	help     = `
+Usage: consul-k8s restore-resync [options]
+
+  Re-registers the state that Consul on Kubernetes creates in Consul after the
+  Consul servers are restored from a snapshot, so that it doesn't have to be
+  repaired by the periodic syncs. The state is re-registered in order:
+
+    1. the ACL resources, by re-running the server-acl-init Job, which is
+       re-created from the Helm release if it was cleaned up
+    2. the config entries of the Consul custom resources
+    3. the services of injected pods and of catalog sync, by requesting
+       a full sync from the connect injector and catalog sync
+
+  Examples:
+    $ consul-k8s restore-resync -dry-run
+    $ consul-k8s restore-resync
+`
+)
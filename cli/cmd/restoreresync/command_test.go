@@ -0,0 +1,412 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package restoreresync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/consul-k8s/cli/helm"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextFake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var serviceDefaultsGVR = schema.GroupVersionResource{
+	Group:    consulGroup,
+	Version:  "v1",
+	Resource: "servicedefaults",
+}
+
+func TestRun(t *testing.T) {
+	pollInterval = 10 * time.Millisecond
+
+	cases := map[string]struct {
+		dryRun      bool
+		expOutput   []string
+		expResynced bool
+	}{
+		"re-registers state": {
+			expOutput: []string{
+				"Re-ran Job consul-server-acl-init",
+				"Re-registered 2 servicedefaults",
+				"Requested a full sync from consul-connect-injector-0",
+				"Requested a full sync from consul-sync-catalog-0",
+				"Re-registered Kubernetes state with Consul.",
+			},
+			expResynced: true,
+		},
+		"dry run does not re-register state": {
+			dryRun: true,
+			expOutput: []string{
+				"Would re-run Job consul-server-acl-init",
+				"Would re-register 2 servicedefaults",
+				"Would request a full sync from consul-connect-injector-0",
+				"Would request a full sync from consul-sync-catalog-0",
+				"Dry run complete. No state was re-registered.",
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			cmd := getInitializedCommand(t, buf)
+
+			k8s := fake.NewSimpleClientset(
+				&batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "consul-server-acl-init",
+						Namespace: "consul",
+						Labels:    map[string]string{"app": "consul", "component": "server-acl-init"},
+						UID:       "old",
+					},
+					Spec: batchv1.JobSpec{
+						Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"controller-uid": "old"}},
+						Template: corev1.PodTemplateSpec{
+							ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"component": "server-acl-init", "controller-uid": "old", "job-name": "consul-server-acl-init"}},
+						},
+					},
+				},
+				testPod("consul-connect-injector-0", "connect-injector", corev1.PodRunning),
+				testPod("consul-connect-injector-1", "connect-injector", corev1.PodPending),
+				testPod("consul-sync-catalog-0", "sync-catalog", corev1.PodRunning),
+			)
+			// Kubernetes runs the re-created Job, which completes straight away.
+			k8s.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				job := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+				job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+				return false, nil, nil
+			})
+			cmd.kubernetes = k8s
+			cmd.apiext = apiextFake.NewSimpleClientset(
+				testCRD("servicedefaults.consul.hashicorp.com", consulGroup, "servicedefaults"),
+				testCRD("examples.example.com", "example.com", "examples"),
+			)
+			dynamicClient := dynamicFake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{serviceDefaultsGVR: "ServiceDefaultsList"})
+			for _, sd := range []*unstructured.Unstructured{testServiceDefaults("web", "default"), testServiceDefaults("api", "other")} {
+				_, err := dynamicClient.Resource(serviceDefaultsGVR).Namespace(sd.GetNamespace()).Create(context.Background(), sd, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+			cmd.dynamic = dynamicClient
+
+			var synced []string
+			cmd.requestFullSync = func(pf common.PortForwarder) error {
+				p := pf.(*common.PortForward)
+				synced = append(synced, p.PodName)
+				require.Equal(t, "consul", p.Namespace)
+				switch {
+				case strings.Contains(p.PodName, "connect-injector"):
+					require.Equal(t, connectInjectorPort, p.RemotePort)
+				default:
+					require.Equal(t, syncCatalogPort, p.RemotePort)
+				}
+				return nil
+			}
+
+			args := []string{"-namespace", "consul"}
+			if c.dryRun {
+				args = append(args, "-dry-run")
+			}
+			require.Equal(t, 0, cmd.Run(args), buf.String())
+
+			output := buf.String()
+			for _, s := range c.expOutput {
+				require.Contains(t, output, s)
+			}
+
+			job, err := k8s.BatchV1().Jobs("consul").Get(context.Background(), "consul-server-acl-init", metav1.GetOptions{})
+			require.NoError(t, err)
+			sd, err := dynamicClient.Resource(serviceDefaultsGVR).Namespace("default").Get(context.Background(), "web", metav1.GetOptions{})
+			require.NoError(t, err)
+			if c.expResynced {
+				require.Nil(t, job.Spec.Selector)
+				require.Equal(t, map[string]string{"component": "server-acl-init"}, job.Spec.Template.Labels)
+				require.Contains(t, sd.GetAnnotations(), annotationResyncedAt)
+				require.Equal(t, []string{"consul-connect-injector-0", "consul-sync-catalog-0"}, synced)
+			} else {
+				require.NotNil(t, job.Spec.Selector)
+				require.NotContains(t, sd.GetAnnotations(), annotationResyncedAt)
+				require.Empty(t, synced)
+			}
+		})
+	}
+}
+
+func TestRun_SkipsMissingComponents(t *testing.T) {
+	buf := new(bytes.Buffer)
+	cmd := getInitializedCommand(t, buf)
+	cmd.helmActionsRunner = &helm.MockActionRunner{
+		CheckForInstallationsFunc: func(options *helm.CheckForInstallationsOptions) (bool, string, string, error) {
+			return true, "consul", "consul", nil
+		},
+	}
+	cmd.kubernetes = fake.NewSimpleClientset()
+	cmd.apiext = apiextFake.NewSimpleClientset()
+	cmd.dynamic = dynamicFake.NewSimpleDynamicClient(runtime.NewScheme())
+	cmd.requestFullSync = func(common.PortForwarder) error {
+		t.Fatal("no full sync should be requested")
+		return nil
+	}
+
+	require.Equal(t, 0, cmd.Run(nil), buf.String())
+	output := buf.String()
+	require.Contains(t, output, "in namespace consul")
+	require.Contains(t, output, "The Helm release has no server-acl-init Job, skipping ACL resources")
+	require.Contains(t, output, "No running connect-injector pods found, skipping")
+	require.Contains(t, output, "No running sync-catalog pods found, skipping")
+}
+
+func TestRun_RecreatesACLInitJobFromRelease(t *testing.T) {
+	pollInterval = 10 * time.Millisecond
+
+	manifest := `---
+# Source: consul/templates/server-acl-init-job.yaml
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: consul-server-acl-init
+  namespace: consul
+  labels:
+    app: consul
+    component: server-acl-init
+spec:
+  template:
+    metadata:
+      labels:
+        component: server-acl-init
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: server-acl-init-job
+        image: hashicorp/consul-k8s-control-plane:latest
+---
+# Source: consul/templates/server-service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: consul-server
+  labels:
+    component: server
+`
+	cases := map[string]struct {
+		getStatus func(*action.Status, string) (*release.Release, error)
+		expCode   int
+		expOutput string
+		expJob    bool
+	}{
+		"job in the release": {
+			getStatus: func(*action.Status, string) (*release.Release, error) {
+				return &release.Release{Manifest: manifest}, nil
+			},
+			expOutput: "Re-ran Job consul-server-acl-init",
+			expJob:    true,
+		},
+		"release can't be read": {
+			getStatus: func(*action.Status, string) (*release.Release, error) {
+				return nil, errors.New("release not found")
+			},
+			expCode:   1,
+			expOutput: "reading the server-acl-init Job from the Helm release: release not found",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			cmd := getInitializedCommand(t, buf)
+			cmd.helmActionsRunner = &helm.MockActionRunner{
+				CheckForInstallationsFunc: func(options *helm.CheckForInstallationsOptions) (bool, string, string, error) {
+					return true, "consul", "consul", nil
+				},
+				GetStatusFunc: c.getStatus,
+			}
+			k8s := fake.NewSimpleClientset()
+			k8s.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				job := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+				job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+				return false, nil, nil
+			})
+			cmd.kubernetes = k8s
+			cmd.apiext = apiextFake.NewSimpleClientset()
+			cmd.dynamic = dynamicFake.NewSimpleDynamicClient(runtime.NewScheme())
+
+			require.Equal(t, c.expCode, cmd.Run([]string{"-namespace", "consul"}), buf.String())
+			require.Contains(t, buf.String(), c.expOutput)
+
+			var created *batchv1.Job
+			for _, a := range k8s.Actions() {
+				if create, ok := a.(k8stesting.CreateAction); ok && a.GetResource().Resource == "jobs" {
+					created = create.GetObject().(*batchv1.Job)
+				}
+			}
+			if !c.expJob {
+				require.Nil(t, created)
+				return
+			}
+			require.NotNil(t, created)
+			require.Equal(t, "hashicorp/consul-k8s-control-plane:latest", created.Spec.Template.Spec.Containers[0].Image)
+			// The re-created Job is cleaned up once it completes.
+			_, err := k8s.BatchV1().Jobs("consul").Get(context.Background(), "consul-server-acl-init", metav1.GetOptions{})
+			require.True(t, k8serrors.IsNotFound(err))
+		})
+	}
+}
+
+func TestRun_JobFails(t *testing.T) {
+	pollInterval = 10 * time.Millisecond
+
+	buf := new(bytes.Buffer)
+	cmd := getInitializedCommand(t, buf)
+	k8s := fake.NewSimpleClientset(&batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "consul-server-acl-init",
+			Namespace: "consul",
+			Labels:    map[string]string{"app": "consul", "component": "server-acl-init"},
+		},
+	})
+	k8s.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		job := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "backoff limit exceeded"}}
+		return false, nil, nil
+	})
+	cmd.kubernetes = k8s
+	cmd.apiext = apiextFake.NewSimpleClientset()
+	cmd.dynamic = dynamicFake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	require.Equal(t, 1, cmd.Run([]string{"-namespace", "consul"}))
+	require.Contains(t, buf.String(), "job failed: backoff limit exceeded")
+}
+
+func TestValidateFlags(t *testing.T) {
+	cases := map[string]struct {
+		args   []string
+		expErr string
+	}{
+		"arguments": {
+			args:   []string{"foo"},
+			expErr: "should have no non-flag arguments",
+		},
+		"invalid namespace": {
+			args:   []string{"-namespace", "Invalid_Namespace"},
+			expErr: "invalid namespace name passed for -namespace/-n",
+		},
+		"zero timeout": {
+			args:   []string{"-timeout", "0s"},
+			expErr: "-timeout must be greater than 0",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			cmd := getInitializedCommand(t, new(bytes.Buffer))
+			require.NoError(t, cmd.set.Parse(c.args))
+			err := cmd.validateFlags()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), c.expErr)
+		})
+	}
+}
+
+func TestPostFullSync(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+
+	cmd := getInitializedCommand(t, new(bytes.Buffer))
+	pf := &mockPortForwarder{endpoint: strings.TrimPrefix(server.URL, "http://")}
+	require.NoError(t, cmd.postFullSync(pf))
+	require.Equal(t, []string{"POST /full-sync"}, requests)
+	require.True(t, pf.closed)
+}
+
+type mockPortForwarder struct {
+	endpoint string
+	closed   bool
+}
+
+func (m *mockPortForwarder) Open(context.Context) (string, error) { return m.endpoint, nil }
+func (m *mockPortForwarder) Close()                               { m.closed = true }
+
+func getInitializedCommand(t *testing.T, buf *bytes.Buffer) *Command {
+	t.Helper()
+	log := hclog.New(&hclog.LoggerOptions{
+		Name:   "cli",
+		Level:  hclog.Info,
+		Output: os.Stdout,
+	})
+
+	baseCommand := &common.BaseCommand{
+		Ctx: context.Background(),
+		Log: log,
+		UI:  terminal.NewUI(context.Background(), buf),
+	}
+
+	c := &Command{
+		BaseCommand: baseCommand,
+	}
+	c.init()
+	return c
+}
+
+func testPod(name, component string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "consul",
+			Labels:    map[string]string{"app": "consul", "component": component},
+		},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+}
+
+func testCRD(name, group, plural string) *apiextv1.CustomResourceDefinition {
+	return &apiextv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: apiextv1.CustomResourceDefinitionSpec{
+			Group: group,
+			Names: apiextv1.CustomResourceDefinitionNames{Plural: plural},
+			Scope: apiextv1.NamespaceScoped,
+			Versions: []apiextv1.CustomResourceDefinitionVersion{
+				{Name: "v1", Served: true, Storage: true},
+			},
+		},
+	}
+}
+
+func testServiceDefaults(name, namespace string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "consul.hashicorp.com/v1",
+			"kind":       "ServiceDefaults",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": namespace,
+			},
+		},
+	}
+}
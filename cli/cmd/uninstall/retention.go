@@ -0,0 +1,193 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package uninstall
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"helm.sh/helm/v3/pkg/action"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Categories of data that can be retained or purged with the -retain-<category>
+// and -purge-<category> flags.
+const (
+	categoryPVCs          = "pvcs"
+	categorySecrets       = "secrets"
+	categoryACLTokens     = "acl-tokens"
+	categoryCRDs          = "crds"
+	categoryConfigEntries = "config-entries"
+)
+
+var dataCategories = []string{categoryPVCs, categorySecrets, categoryACLTokens, categoryCRDs, categoryConfigEntries}
+
+// categoryDescriptions are the descriptions of the categories in flag usage and the report.
+var categoryDescriptions = map[string]string{
+	categoryPVCs:          "PVCs",
+	categorySecrets:       "Secrets",
+	categoryACLTokens:     "ACL token Secrets",
+	categoryCRDs:          "CRDs",
+	categoryConfigEntries: "config entries (custom resources)",
+}
+
+// aclTokenSecretSuffix is the suffix of the names of the Secrets that server-acl-init
+// stores ACL tokens in, e.g. consul-bootstrap-acl-token.
+const aclTokenSecretSuffix = "-acl-token"
+
+// helmKeepPolicy is the annotation that stops Helm from deleting a resource on uninstall.
+const helmKeepPolicy = `{"metadata":{"annotations":{"helm.sh/resource-policy":"keep"}}}`
+
+// validateRetention checks that the retain and purge flags don't conflict.
+func (c *Command) validateRetention() error {
+	for _, category := range dataCategories {
+		if *c.flagRetain[category] && *c.flagPurge[category] {
+			return fmt.Errorf("can't set both -retain-%s and -purge-%s", category, category)
+		}
+	}
+	if *c.flagRetain[categoryConfigEntries] && *c.flagPurge[categoryCRDs] {
+		return fmt.Errorf("can't set -retain-%s with -purge-%s since custom resources are deleted with their CRDs", categoryConfigEntries, categoryCRDs)
+	}
+	return nil
+}
+
+// purges returns true if the data in the category is deleted. PVCs and Secrets are deleted
+// when uninstalling interactively or with -wipe-data, and CRDs and custom resources are
+// always deleted with the Helm release, unless the flags of the category say otherwise.
+func (c *Command) purges(category string) bool {
+	switch {
+	case *c.flagRetain[category]:
+		return false
+	case *c.flagPurge[category]:
+		return true
+	case category == categoryCRDs:
+		// Custom resources can only be kept if their CRDs are.
+		return !*c.flagRetain[categoryConfigEntries]
+	case category == categoryConfigEntries:
+		return true
+	default:
+		return c.flagWipeData || !c.flagAutoApprove
+	}
+}
+
+// purgesData returns true if any of the data that's stored outside of the Helm release is deleted.
+func (c *Command) purgesData() bool {
+	return c.purges(categoryPVCs) || c.purges(categorySecrets) || c.purges(categoryACLTokens)
+}
+
+// outputRetentionReport lists the data of the installation in each category and whether it
+// will be deleted or retained.
+func (c *Command) outputRetentionReport(releaseName, namespace string) error {
+	resources := make(map[string][]string)
+
+	pvcs, err := c.k8sClient.CoreV1().PersistentVolumeClaims(namespace).List(c.Ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("release=%s", releaseName),
+	})
+	if err != nil {
+		return fmt.Errorf("error listing PVCs: %s", err)
+	}
+	for _, pvc := range pvcs.Items {
+		resources[categoryPVCs] = append(resources[categoryPVCs], pvc.Name)
+	}
+
+	secrets, err := c.k8sClient.CoreV1().Secrets(namespace).List(c.Ctx, metav1.ListOptions{
+		LabelSelector: common.CLILabelKey + "=" + common.CLILabelValue,
+	})
+	if err != nil {
+		return fmt.Errorf("error listing Secrets: %s", err)
+	}
+	for _, secret := range secrets.Items {
+		category := secretCategory(secret.Name)
+		resources[category] = append(resources[category], secret.Name)
+	}
+
+	crds, err := c.fetchCustomResourceDefinitions()
+	if err != nil {
+		return fmt.Errorf("error listing CRDs: %s", err)
+	}
+	for _, crd := range crds.Items {
+		resources[categoryCRDs] = append(resources[categoryCRDs], crd.Name)
+	}
+	crs, err := c.fetchCustomResources(crds)
+	if err != nil {
+		return fmt.Errorf("error listing custom resources: %s", err)
+	}
+	for _, cr := range crs {
+		resources[categoryConfigEntries] = append(resources[categoryConfigEntries],
+			fmt.Sprintf("%s %s/%s", cr.GetKind(), cr.GetNamespace(), cr.GetName()))
+	}
+
+	c.UI.Output("Data Retention Report", terminal.WithHeaderStyle())
+	table := terminal.NewTable("Category", "Resource", "Action")
+	rows := 0
+	for _, category := range dataCategories {
+		action := "retain"
+		if c.purges(category) {
+			action = "delete"
+		}
+		for _, resource := range resources[category] {
+			table.AddRow([]string{category, resource, action}, []string{})
+			rows++
+		}
+	}
+	if rows == 0 {
+		c.UI.Output("No Consul data found.", terminal.WithInfoStyle())
+		return nil
+	}
+	c.UI.Table(table)
+	return nil
+}
+
+// retainCRDs stops Helm from deleting the Consul CRDs when the release is uninstalled.
+func (c *Command) retainCRDs() error {
+	crds, err := c.fetchCustomResourceDefinitions()
+	if err != nil {
+		return fmt.Errorf("unable to fetch Custom Resource Definitions for Consul deployment: %v", err)
+	}
+	for _, crd := range crds.Items {
+		_, err := c.apiextK8sClient.ApiextensionsV1().CustomResourceDefinitions().
+			Patch(c.Ctx, crd.Name, types.MergePatchType, []byte(helmKeepPolicy), metav1.PatchOptions{})
+		if err != nil {
+			return fmt.Errorf("error retaining CRD %q: %v", crd.Name, err)
+		}
+	}
+	c.UI.Output("Retaining Consul CRDs.", terminal.WithInfoStyle())
+	return nil
+}
+
+// retainCustomResources removes the finalizers from the custom resources managed by Consul. The
+// controllers that remove the finalizers are uninstalled with the release, so the resources
+// could otherwise never be deleted afterwards. Their config entries are kept in Consul.
+func (c *Command) retainCustomResources(uiLogger action.DebugLog) error {
+	crds, err := c.fetchCustomResourceDefinitions()
+	if err != nil {
+		return fmt.Errorf("unable to fetch Custom Resource Definitions for Consul deployment: %v", err)
+	}
+	crs, err := c.fetchCustomResources(crds)
+	if err != nil {
+		return fmt.Errorf("error listing custom resources: %s", err)
+	}
+	var finalized []unstructured.Unstructured
+	for _, cr := range crs {
+		if len(cr.GetFinalizers()) > 0 {
+			finalized = append(finalized, cr)
+		}
+	}
+	if err := c.patchCustomResources(finalized, mapCRKindToResourceName(crds), uiLogger); err != nil {
+		return fmt.Errorf("error removing finalizers from custom resources: %v", err)
+	}
+	return nil
+}
+
+// secretCategory returns the category of a Secret created by Consul.
+func secretCategory(name string) string {
+	if strings.HasSuffix(name, aclTokenSecretSuffix) {
+		return categoryACLTokens
+	}
+	return categorySecrets
+}
@@ -68,6 +68,10 @@ type Command struct {
 	flagWipeData    bool
 	flagTimeout     time.Duration
 
+	// flagRetain and flagPurge are the -retain-<category> and -purge-<category> flags, keyed by category.
+	flagRetain map[string]*bool
+	flagPurge  map[string]*bool
+
 	flagKubeConfig  string
 	flagKubeContext string
 
@@ -90,6 +94,24 @@ func (c *Command) init() {
 		Default: defaultWipeData,
 		Usage:   "When used in combination with -auto-approve, all persisted data (PVCs and Secrets) from previous installations will be deleted. Only set this to true when data from previous installations is no longer necessary.",
 	})
+	c.flagRetain = make(map[string]*bool)
+	c.flagPurge = make(map[string]*bool)
+	for _, category := range dataCategories {
+		c.flagRetain[category] = new(bool)
+		c.flagPurge[category] = new(bool)
+		f.BoolVar(&flag.BoolVar{
+			Name:    "retain-" + category,
+			Target:  c.flagRetain[category],
+			Default: false,
+			Usage:   fmt.Sprintf("Keep the %s of the installation, even when other data is deleted.", categoryDescriptions[category]),
+		})
+		f.BoolVar(&flag.BoolVar{
+			Name:    "purge-" + category,
+			Target:  c.flagPurge[category],
+			Default: false,
+			Usage:   fmt.Sprintf("Delete the %s of the installation, even with -auto-approve and without -wipe-data.", categoryDescriptions[category]),
+		})
+	}
 	f.StringVar(&flag.StringVar{
 		Name:    flagNamespace,
 		Target:  &c.flagNamespace,
@@ -156,6 +178,10 @@ func (c *Command) Run(args []string) int {
 		c.UI.Output("Can't set -wipe-data alone. Omit this flag to interactively uninstall, or use it with -auto-approve to wipe all data during the uninstall.", terminal.WithErrorStyle())
 		return 1
 	}
+	if err := c.validateRetention(); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
 
 	// helmCLI.New() will create a settings object which is used by the Helm Go SDK calls.
 	settings := helmCLI.New()
@@ -208,6 +234,21 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
+	// Even if no Helm release is found and uninstalled, there could
+	// still be PVCs, Secrets, and Service Accounts left behind from a previous installation.
+	// If there isn't a foundReleaseName and foundReleaseNamespace, we'll use the values of the
+	// flags c.flagReleaseName and c.flagNamespace. If those are empty we'll fall back to defaults "consul" for the
+	// installation name and "consul" for the namespace.
+	if !found {
+		if c.flagReleaseName == "" || c.flagNamespace == "" {
+			foundReleaseName = common.DefaultReleaseName
+			foundReleaseNamespace = common.DefaultReleaseNamespace
+		} else {
+			foundReleaseName = c.flagReleaseName
+			foundReleaseNamespace = c.flagNamespace
+		}
+	}
+
 	if foundConsulDemo {
 		err = c.uninstallHelmRelease(foundDemoReleaseName, foundDemoReleaseNamespace, common.ReleaseTypeConsulDemo, settings, uiLogger, actionConfig)
 		if err != nil {
@@ -216,6 +257,11 @@ func (c *Command) Run(args []string) int {
 		}
 	}
 
+	if err := c.outputRetentionReport(foundReleaseName, foundReleaseNamespace); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	}
+
 	c.UI.Output("Checking if Consul can be uninstalled", terminal.WithHeaderStyle())
 	if found {
 		err = c.uninstallHelmRelease(foundReleaseName, foundReleaseNamespace, common.ReleaseTypeConsul, settings, uiLogger, actionConfig)
@@ -225,27 +271,13 @@ func (c *Command) Run(args []string) int {
 		}
 	}
 
-	// If -auto-approve=true and -wipe-data=false, we should only uninstall the release, and skip deleting resources.
-	if c.flagAutoApprove && !c.flagWipeData {
+	// If -auto-approve=true and -wipe-data=false, we should only uninstall the release, and skip deleting resources
+	// unless a -purge-<category> flag is set.
+	if c.flagAutoApprove && !c.flagWipeData && !c.purgesData() {
 		c.UI.Output("Skipping deleting PVCs, secrets, and service accounts.", terminal.WithSuccessStyle())
 		return 0
 	}
 
-	// At this point, even if no Helm release was found and uninstalled, there could
-	// still be PVCs, Secrets, and Service Accounts left behind from a previous installation.
-	// If there isn't a foundReleaseName and foundReleaseNamespace, we'll use the values of the
-	// flags c.flagReleaseName and c.flagNamespace. If those are empty we'll fall back to defaults "consul" for the
-	// installation name and "consul" for the namespace.
-	if !found {
-		if c.flagReleaseName == "" || c.flagNamespace == "" {
-			foundReleaseName = common.DefaultReleaseName
-			foundReleaseNamespace = common.DefaultReleaseNamespace
-		} else {
-			foundReleaseName = c.flagReleaseName
-			foundReleaseNamespace = c.flagNamespace
-		}
-	}
-
 	c.UI.Output("Other Consul Resources", terminal.WithHeaderStyle())
 	if c.flagAutoApprove {
 		c.UI.Output("Deleting data for installation: ", terminal.WithInfoStyle())
@@ -270,14 +302,28 @@ func (c *Command) Run(args []string) int {
 		}
 	}
 
-	if err := c.deletePVCs(foundReleaseName, foundReleaseNamespace); err != nil {
-		c.UI.Output(err.Error(), terminal.WithErrorStyle())
-		return 1
+	if c.purges(categoryPVCs) {
+		if err := c.deletePVCs(foundReleaseName, foundReleaseNamespace); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	} else {
+		c.UI.Output("Retaining PVCs.", terminal.WithInfoStyle())
 	}
 
-	if err := c.deleteSecrets(foundReleaseNamespace); err != nil {
-		c.UI.Output(err.Error(), terminal.WithErrorStyle())
-		return 1
+	if c.purges(categorySecrets) || c.purges(categoryACLTokens) {
+		if err := c.deleteSecrets(foundReleaseNamespace, c.purges(categorySecrets), c.purges(categoryACLTokens)); err != nil {
+			c.UI.Output(err.Error(), terminal.WithErrorStyle())
+			return 1
+		}
+	} else {
+		c.UI.Output("Retaining Consul secrets.", terminal.WithInfoStyle())
+	}
+
+	// Only the data selected with -purge-<category> flags is deleted when -wipe-data isn't set,
+	// so the rest of the installation's resources are left as they are.
+	if c.flagAutoApprove && !c.flagWipeData {
+		return 0
 	}
 
 	if err := c.deleteServiceAccounts(foundReleaseName, foundReleaseNamespace); err != nil {
@@ -370,8 +416,20 @@ func (c *Command) uninstallHelmRelease(releaseName, namespace, releaseType strin
 	// Delete any custom resources managed by Consul. If they cannot be deleted,
 	// patch the finalizers to be empty on each one.
 	if releaseType == common.ReleaseTypeConsul {
-		if err := c.removeCustomResources(uiLogger); err != nil {
-			c.UI.Output("Error removing custom resources: %v", err.Error(), terminal.WithErrorStyle())
+		if c.purges(categoryConfigEntries) {
+			if err := c.removeCustomResources(uiLogger); err != nil {
+				c.UI.Output("Error removing custom resources: %v", err.Error(), terminal.WithErrorStyle())
+			}
+		} else {
+			c.UI.Output("Retaining custom resources managed by Consul.", terminal.WithInfoStyle())
+			if err := c.retainCustomResources(uiLogger); err != nil {
+				return err
+			}
+		}
+		if !c.purges(categoryCRDs) {
+			if err := c.retainCRDs(); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -569,7 +627,7 @@ func (c *Command) Synopsis() string {
 // options for this command. The map key for the Flags map should be the
 // complete flag such as "-foo" or "--foo".
 func (c *Command) AutocompleteFlags() complete.Flags {
-	return complete.Flags{
+	flags := complete.Flags{
 		fmt.Sprintf("-%s", flagAutoApprove): complete.PredictNothing,
 		fmt.Sprintf("-%s", flagNamespace):   complete.PredictNothing,
 		fmt.Sprintf("-%s", flagReleaseName): complete.PredictNothing,
@@ -578,6 +636,11 @@ func (c *Command) AutocompleteFlags() complete.Flags {
 		fmt.Sprintf("-%s", flagContext):     complete.PredictNothing,
 		fmt.Sprintf("-%s", flagKubeconfig):  complete.PredictFiles("*"),
 	}
+	for _, category := range dataCategories {
+		flags["-retain-"+category] = complete.PredictNothing
+		flags["-purge-"+category] = complete.PredictNothing
+	}
+	return flags
 }
 
 // AutocompleteArgs returns the argument predictor for this command.
@@ -643,8 +706,9 @@ func (c *Command) deletePVCs(foundReleaseName, foundReleaseNamespace string) err
 	return nil
 }
 
-// deleteSecrets deletes any secrets that have the label "managed-by" set to "consul-k8s".
-func (c *Command) deleteSecrets(foundReleaseNamespace string) error {
+// deleteSecrets deletes secrets that have the label "managed-by" set to "consul-k8s". ACL token
+// secrets are only deleted if purgeACLTokens is true and other secrets only if purgeSecrets is true.
+func (c *Command) deleteSecrets(foundReleaseNamespace string, purgeSecrets, purgeACLTokens bool) error {
 	secrets, err := c.k8sClient.CoreV1().Secrets(foundReleaseNamespace).List(c.Ctx, metav1.ListOptions{
 		LabelSelector: common.CLILabelKey + "=" + common.CLILabelValue,
 	})
@@ -657,6 +721,9 @@ func (c *Command) deleteSecrets(foundReleaseNamespace string) error {
 	}
 	var secretNames []string
 	for _, secret := range secrets.Items {
+		if category := secretCategory(secret.Name); (category == categoryACLTokens && !purgeACLTokens) || (category == categorySecrets && !purgeSecrets) {
+			continue
+		}
 		err := c.k8sClient.CoreV1().Secrets(foundReleaseNamespace).Delete(c.Ctx, secret.Name, metav1.DeleteOptions{})
 		if err != nil {
 			return fmt.Errorf("deleteSecrets: error deleting Secret %q: %s", secret.Name, err)
@@ -126,7 +126,7 @@ func TestDeleteSecrets(t *testing.T) {
 	require.NoError(t, err)
 	_, err = c.k8sClient.CoreV1().Secrets("default").Create(context.Background(), secret3, metav1.CreateOptions{})
 	require.NoError(t, err)
-	err = c.deleteSecrets("default")
+	err = c.deleteSecrets("default", true, true)
 	require.NoError(t, err)
 	secrets, err := c.k8sClient.CoreV1().Secrets("default").List(context.Background(), metav1.ListOptions{})
 	require.NoError(t, err)
@@ -135,6 +135,119 @@ func TestDeleteSecrets(t *testing.T) {
 	require.Len(t, secrets.Items, 2)
 }
 
+func TestDeleteSecrets_ACLTokens(t *testing.T) {
+	cases := map[string]struct {
+		purgeSecrets   bool
+		purgeACLTokens bool
+		expSecrets     []string
+	}{
+		"only ACL tokens": {
+			purgeACLTokens: true,
+			expSecrets:     []string{"consul-gossip-encryption-key"},
+		},
+		"only other secrets": {
+			purgeSecrets: true,
+			expSecrets:   []string{"consul-bootstrap-acl-token"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := getInitializedCommand(t, nil)
+			c.k8sClient = fake.NewSimpleClientset()
+			for _, name := range []string{"consul-bootstrap-acl-token", "consul-gossip-encryption-key"} {
+				secret := &v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:   name,
+						Labels: map[string]string{common.CLILabelKey: common.CLILabelValue},
+					},
+				}
+				_, err := c.k8sClient.CoreV1().Secrets("default").Create(context.Background(), secret, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+
+			require.NoError(t, c.deleteSecrets("default", tc.purgeSecrets, tc.purgeACLTokens))
+			secrets, err := c.k8sClient.CoreV1().Secrets("default").List(context.Background(), metav1.ListOptions{})
+			require.NoError(t, err)
+			var names []string
+			for _, secret := range secrets.Items {
+				names = append(names, secret.Name)
+			}
+			require.Equal(t, tc.expSecrets, names)
+		})
+	}
+}
+
+func TestPurges(t *testing.T) {
+	cases := map[string]struct {
+		input    []string
+		expPurge map[string]bool
+	}{
+		"interactive": {
+			input: []string{},
+			expPurge: map[string]bool{
+				categoryPVCs: true, categorySecrets: true, categoryACLTokens: true, categoryCRDs: true, categoryConfigEntries: true,
+			},
+		},
+		"auto-approve": {
+			input: []string{"-auto-approve"},
+			expPurge: map[string]bool{
+				categoryPVCs: false, categorySecrets: false, categoryACLTokens: false, categoryCRDs: true, categoryConfigEntries: true,
+			},
+		},
+		"auto-approve with purge flags": {
+			input: []string{"-auto-approve", "-purge-pvcs", "-purge-acl-tokens"},
+			expPurge: map[string]bool{
+				categoryPVCs: true, categorySecrets: false, categoryACLTokens: true, categoryCRDs: true, categoryConfigEntries: true,
+			},
+		},
+		"wipe-data with retain flags": {
+			input: []string{"-auto-approve", "-wipe-data", "-retain-pvcs", "-retain-crds"},
+			expPurge: map[string]bool{
+				categoryPVCs: false, categorySecrets: true, categoryACLTokens: true, categoryCRDs: false, categoryConfigEntries: true,
+			},
+		},
+		"retaining config entries retains CRDs": {
+			input: []string{"-retain-config-entries"},
+			expPurge: map[string]bool{
+				categoryPVCs: true, categorySecrets: true, categoryACLTokens: true, categoryCRDs: false, categoryConfigEntries: false,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := getInitializedCommand(t, nil)
+			require.NoError(t, c.set.Parse(tc.input))
+			require.NoError(t, c.validateRetention())
+			for category, exp := range tc.expPurge {
+				require.Equal(t, exp, c.purges(category), category)
+			}
+		})
+	}
+}
+
+func TestValidateRetention(t *testing.T) {
+	cases := map[string]struct {
+		input  []string
+		expErr string
+	}{
+		"retain and purge the same category": {
+			input:  []string{"-retain-secrets", "-purge-secrets"},
+			expErr: "can't set both -retain-secrets and -purge-secrets",
+		},
+		"retain config entries and purge CRDs": {
+			input:  []string{"-retain-config-entries", "-purge-crds"},
+			expErr: "can't set -retain-config-entries with -purge-crds since custom resources are deleted with their CRDs",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := getInitializedCommand(t, nil)
+			require.NoError(t, c.set.Parse(tc.input))
+			require.EqualError(t, c.validateRetention(), tc.expErr)
+		})
+	}
+}
+
 func TestDeleteServiceAccounts(t *testing.T) {
 	c := getInitializedCommand(t, nil)
 	c.k8sClient = fake.NewSimpleClientset()
@@ -620,6 +733,7 @@ func TestUninstall(t *testing.T) {
 		expectCheckedForConsulDemoInstallations bool
 		expectConsulUninstalled                 bool
 		expectConsulDemoUninstalled             bool
+		expectCustomResourcesRetained           bool
 	}{
 		"uninstall when consul installation exists returns success": {
 			input: []string{},
@@ -688,6 +802,62 @@ func TestUninstall(t *testing.T) {
 			expectConsulUninstalled:                 true,
 			expectConsulDemoUninstalled:             false,
 		},
+		"uninstall with -purge-acl-tokens deletes only ACL tokens and returns success": {
+			input: []string{
+				"-purge-acl-tokens",
+			},
+			messages: []string{
+				"\n==> Data Retention Report\n",
+				"\n ✓ Successfully uninstalled Consul Helm release.\n\n==> Other Consul Resources\n    Deleting data for installation: \n    Name: consul\n    Namespace consul\n    Retaining PVCs.\n ✓ No Consul secrets found.\n",
+			},
+			helmActionsRunner: &helm.MockActionRunner{
+				CheckForInstallationsFunc: func(options *helm.CheckForInstallationsOptions) (bool, string, string, error) {
+					if options.ReleaseName == "consul" {
+						return true, "consul", "consul", nil
+					} else {
+						return false, "", "", nil
+					}
+				},
+			},
+			expectedReturnCode:                      0,
+			expectCheckedForConsulInstallations:     true,
+			expectCheckedForConsulDemoInstallations: true,
+			expectConsulUninstalled:                 true,
+			expectConsulDemoUninstalled:             false,
+		},
+		"uninstall with -retain-config-entries keeps custom resources and returns success": {
+			input: []string{
+				"-retain-config-entries",
+			},
+			messages: []string{
+				"\n==> Consul Uninstall Summary\n    Name: consul\n    Namespace: consul\n    Retaining custom resources managed by Consul.\n --> Patching finalizers for \"server\" ServiceDefaults\n    Retaining Consul CRDs.\n ✓ Successfully uninstalled Consul Helm release.\n",
+			},
+			helmActionsRunner: &helm.MockActionRunner{
+				CheckForInstallationsFunc: func(options *helm.CheckForInstallationsOptions) (bool, string, string, error) {
+					if options.ReleaseName == "consul" {
+						return true, "consul", "consul", nil
+					} else {
+						return false, "", "", nil
+					}
+				},
+			},
+			expectedReturnCode:                      0,
+			expectCheckedForConsulInstallations:     true,
+			expectCheckedForConsulDemoInstallations: true,
+			expectConsulUninstalled:                 true,
+			expectConsulDemoUninstalled:             false,
+			expectCustomResourcesRetained:           true,
+		},
+		"uninstall with conflicting retention flags returns error": {
+			input: []string{
+				"-retain-pvcs", "-purge-pvcs",
+			},
+			messages: []string{
+				"can't set both -retain-pvcs and -purge-pvcs",
+			},
+			helmActionsRunner:  &helm.MockActionRunner{},
+			expectedReturnCode: 1,
+		},
 		"uninstall when both consul and consul demo installations exist returns success": {
 			input: []string{},
 			messages: []string{
@@ -815,7 +985,15 @@ func TestUninstall(t *testing.T) {
 				require.NoError(t, err)
 				crs, err := c.fetchCustomResources(crds)
 				require.NoError(t, err)
-				require.Len(t, crs, 0)
+				if tc.expectCustomResourcesRetained {
+					require.Len(t, crs, 1)
+					// The finalizers are removed so that the resources can still be deleted
+					// once the controllers are gone.
+					require.Empty(t, crs[0].GetFinalizers())
+					require.Equal(t, "keep", crds.Items[0].Annotations["helm.sh/resource-policy"])
+				} else {
+					require.Len(t, crs, 0)
+				}
 			}
 		})
 	}
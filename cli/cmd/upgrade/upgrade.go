@@ -24,7 +24,6 @@ import (
 	"helm.sh/helm/v3/pkg/cli/values"
 	"helm.sh/helm/v3/pkg/getter"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/utils/strings/slices"
 )
 
 const (
@@ -119,7 +118,9 @@ func (c *Command) init() {
 		Name:    flagNamePreset,
 		Target:  &c.flagPreset,
 		Default: defaultPreset,
-		Usage:   fmt.Sprintf("Use an upgrade preset, one of %s. Defaults to none", strings.Join(preset.Presets, ", ")),
+		Usage: fmt.Sprintf("Use an upgrade preset, one of %s, or the path to a YAML preset file. "+
+			"Values from -%s and -%s flags are merged over the preset. Defaults to none",
+			strings.Join(preset.Presets, ", "), flagNameConfigFile, flagNameSetValues),
 	})
 	f.StringSliceVar(&flag.StringSliceVar{
 		Name:   flagNameSetValues,
@@ -175,7 +176,7 @@ func (c *Command) init() {
 		Name:    flagNameHCPResourceID,
 		Target:  &c.flagNameHCPResourceID,
 		Default: "",
-		Usage:   "Set the HCP resource_id when using the 'cloud' preset.",
+		Usage:   "Set the HCP resource_id when using the 'cloud' or 'hcp-dataplane' preset.",
 	})
 	f.BoolVar(&flag.BoolVar{
 		Name:    flagNameDemo,
@@ -423,11 +424,10 @@ func (c *Command) validateFlags(args []string) error {
 	if len(c.set.Args()) > 0 {
 		return errors.New("should have no non-flag arguments")
 	}
-	if len(c.flagValueFiles) != 0 && c.flagPreset != defaultPreset {
-		return fmt.Errorf("cannot set both -%s and -%s", flagNameConfigFile, flagNamePreset)
-	}
-	if ok := slices.Contains(preset.Presets, c.flagPreset); c.flagPreset != defaultPreset && !ok {
-		return fmt.Errorf("'%s' is not a valid preset (valid presets: %s)", c.flagPreset, strings.Join(preset.Presets, ", "))
+	if c.flagPreset != defaultPreset {
+		if err := preset.Validate(c.flagPreset); err != nil {
+			return err
+		}
 	}
 	if _, err := time.ParseDuration(c.flagTimeout); err != nil {
 		return fmt.Errorf("unable to parse -%s: %s", flagNameTimeout, err)
@@ -440,18 +440,18 @@ func (c *Command) validateFlags(args []string) error {
 		}
 	}
 
-	if c.flagPreset == preset.PresetCloud {
+	if preset.RequiresHCPConfig(c.flagPreset) {
 		clientID := os.Getenv(preset.EnvHCPClientID)
 		clientSecret := os.Getenv(preset.EnvHCPClientSecret)
 		if clientID == "" {
-			return fmt.Errorf("When '%s' is specified as the preset, the '%s' environment variable must also be set", preset.PresetCloud, preset.EnvHCPClientID)
+			return fmt.Errorf("When '%s' is specified as the preset, the '%s' environment variable must also be set", c.flagPreset, preset.EnvHCPClientID)
 		} else if clientSecret == "" {
-			return fmt.Errorf("When '%s' is specified as the preset, the '%s' environment variable must also be set", preset.PresetCloud, preset.EnvHCPClientSecret)
+			return fmt.Errorf("When '%s' is specified as the preset, the '%s' environment variable must also be set", c.flagPreset, preset.EnvHCPClientSecret)
 		} else if c.flagNameHCPResourceID == "" {
-			return fmt.Errorf("When '%s' is specified as the preset, the '%s' flag must also be provided", preset.PresetCloud, flagNameHCPResourceID)
+			return fmt.Errorf("When '%s' is specified as the preset, the '%s' flag must also be provided", c.flagPreset, flagNameHCPResourceID)
 		}
 	} else if c.flagNameHCPResourceID != "" {
-		return fmt.Errorf("The '%s' flag can only be used with the '%s' preset", flagNameHCPResourceID, preset.PresetCloud)
+		return fmt.Errorf("The '%s' flag can only be used with the '%s' or '%s' presets", flagNameHCPResourceID, preset.PresetCloud, preset.PresetHCPDataplane)
 	}
 
 	return nil
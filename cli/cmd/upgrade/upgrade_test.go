@@ -41,8 +41,8 @@ func TestValidateFlags(t *testing.T) {
 			[]string{"foo", "-auto-approve"},
 		},
 		{
-			"Should disallow specifying both values file AND presets.",
-			[]string{"-f='f.txt'", "-preset=demo"},
+			"Should error on a non-existent preset file.",
+			[]string{"-preset=does_not_exist.yaml"},
 		},
 		{
 			"Should error on invalid presets.",
@@ -141,6 +141,22 @@ func TestGetPreset(t *testing.T) {
 			"'secure' should return a SecurePreset'.",
 			preset.PresetSecure,
 		},
+		{
+			"'observability' should return an ObservabilityPreset'.",
+			preset.PresetObservability,
+		},
+		{
+			"'multi-cluster-primary' should return a MultiClusterPrimaryPreset'.",
+			preset.PresetMultiClusterPrimary,
+		},
+		{
+			"'api-gateway-edge' should return an APIGatewayEdgePreset'.",
+			preset.PresetAPIGatewayEdge,
+		},
+		{
+			"A .yaml file should return a FilePreset'.",
+			"preset.yaml",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -155,6 +171,16 @@ func TestGetPreset(t *testing.T) {
 				require.Equal(t, preset.PresetQuickstart, tc.presetName)
 			case *preset.SecurePreset:
 				require.Equal(t, preset.PresetSecure, tc.presetName)
+			case *preset.ObservabilityPreset:
+				require.Equal(t, preset.PresetObservability, tc.presetName)
+			case *preset.MultiClusterPrimaryPreset:
+				require.Equal(t, preset.PresetMultiClusterPrimary, tc.presetName)
+			case *preset.APIGatewayEdgePreset:
+				require.Equal(t, preset.PresetAPIGatewayEdge, tc.presetName)
+			case *preset.FilePreset:
+				require.Equal(t, "preset.yaml", tc.presetName)
+			default:
+				t.Fatalf("unexpected preset type %T", p)
 			}
 		})
 	}
@@ -7,12 +7,24 @@ import (
 	"context"
 
 	"github.com/hashicorp/consul-k8s/cli/cmd/config"
+	config_export "github.com/hashicorp/consul-k8s/cli/cmd/config/export"
+	config_list "github.com/hashicorp/consul-k8s/cli/cmd/config/list"
 	config_read "github.com/hashicorp/consul-k8s/cli/cmd/config/read"
+	config_write "github.com/hashicorp/consul-k8s/cli/cmd/config/write"
+	"github.com/hashicorp/consul-k8s/cli/cmd/crd"
+	crd_migrate "github.com/hashicorp/consul-k8s/cli/cmd/crd/migrate"
+	"github.com/hashicorp/consul-k8s/cli/cmd/dashboard"
+	"github.com/hashicorp/consul-k8s/cli/cmd/debug"
+	"github.com/hashicorp/consul-k8s/cli/cmd/federation"
+	federation_join "github.com/hashicorp/consul-k8s/cli/cmd/federation/join"
 	"github.com/hashicorp/consul-k8s/cli/cmd/install"
+	"github.com/hashicorp/consul-k8s/cli/cmd/peering"
+	peering_establish "github.com/hashicorp/consul-k8s/cli/cmd/peering/establish"
 	"github.com/hashicorp/consul-k8s/cli/cmd/proxy"
 	"github.com/hashicorp/consul-k8s/cli/cmd/proxy/list"
 	"github.com/hashicorp/consul-k8s/cli/cmd/proxy/loglevel"
 	"github.com/hashicorp/consul-k8s/cli/cmd/proxy/read"
+	"github.com/hashicorp/consul-k8s/cli/cmd/restoreresync"
 	"github.com/hashicorp/consul-k8s/cli/cmd/status"
 	"github.com/hashicorp/consul-k8s/cli/cmd/troubleshoot"
 	troubleshoot_proxy "github.com/hashicorp/consul-k8s/cli/cmd/troubleshoot/proxy"
@@ -50,6 +62,16 @@ func initializeCommands(ctx context.Context, log hclog.Logger) (*common.BaseComm
 				BaseCommand: baseCommand,
 			}, nil
 		},
+		"debug": func() (cli.Command, error) {
+			return &debug.Command{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"dashboard": func() (cli.Command, error) {
+			return &dashboard.Command{
+				BaseCommand: baseCommand,
+			}, nil
+		},
 		"upgrade": func() (cli.Command, error) {
 			return &upgrade.Command{
 				BaseCommand: baseCommand,
@@ -86,11 +108,26 @@ func initializeCommands(ctx context.Context, log hclog.Logger) (*common.BaseComm
 				BaseCommand: baseCommand,
 			}, nil
 		},
+		"config list": func() (cli.Command, error) {
+			return &config_list.ListCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
 		"config read": func() (cli.Command, error) {
 			return &config_read.ReadCommand{
 				BaseCommand: baseCommand,
 			}, nil
 		},
+		"config export": func() (cli.Command, error) {
+			return &config_export.ExportCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"config write": func() (cli.Command, error) {
+			return &config_write.WriteCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
 		"troubleshoot": func() (cli.Command, error) {
 			return &troubleshoot.TroubleshootCommand{
 				BaseCommand: baseCommand,
@@ -106,6 +143,41 @@ func initializeCommands(ctx context.Context, log hclog.Logger) (*common.BaseComm
 				BaseCommand: baseCommand,
 			}, nil
 		},
+		"crd": func() (cli.Command, error) {
+			return &crd.CRDCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"crd migrate": func() (cli.Command, error) {
+			return &crd_migrate.MigrateCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"restore-resync": func() (cli.Command, error) {
+			return &restoreresync.Command{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"federation": func() (cli.Command, error) {
+			return &federation.FederationCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"federation join": func() (cli.Command, error) {
+			return &federation_join.JoinCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"peering": func() (cli.Command, error) {
+			return &peering.PeeringCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
+		"peering establish": func() (cli.Command, error) {
+			return &peering_establish.EstablishCommand{
+				BaseCommand: baseCommand,
+			}, nil
+		},
 	}
 
 	return baseCommand, commands
@@ -0,0 +1,281 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package configentry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/consul/api"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// group and version are the API group and version of the Consul custom resources.
+	group   = "consul.hashicorp.com"
+	version = "v1alpha1"
+
+	// sourceKey and sourceValue are the meta that the consul-k8s controller adds to the config
+	// entries that it writes for custom resources, and datacenterKey is the datacenter it wrote them from.
+	sourceKey     = "external-source"
+	sourceValue   = "kubernetes"
+	datacenterKey = "consul.hashicorp.com/source-datacenter"
+
+	// MigrateEntryAnnotation lets the consul-k8s controller take over a config entry that already exists
+	// in Consul for a custom resource, rather than failing to sync it.
+	MigrateEntryAnnotation = "consul.hashicorp.com/migrate-entry"
+)
+
+// Kind is a kind of config entry and the custom resource that manages it.
+type Kind struct {
+	// Name is the kind of the config entry in Consul, e.g. service-defaults.
+	Name string
+	// CRDKind is the kind of the custom resource, e.g. ServiceDefaults.
+	CRDKind string
+	// Resource is the plural resource name of the custom resource, e.g. servicedefaults.
+	Resource string
+}
+
+// Kinds are the kinds of config entries that can be managed by custom resources.
+var Kinds = []Kind{
+	{Name: api.ServiceDefaults, CRDKind: "ServiceDefaults", Resource: "servicedefaults"},
+	{Name: api.ProxyDefaults, CRDKind: "ProxyDefaults", Resource: "proxydefaults"},
+	{Name: api.ServiceRouter, CRDKind: "ServiceRouter", Resource: "servicerouters"},
+	{Name: api.ServiceSplitter, CRDKind: "ServiceSplitter", Resource: "servicesplitters"},
+	{Name: api.ServiceResolver, CRDKind: "ServiceResolver", Resource: "serviceresolvers"},
+	{Name: api.IngressGateway, CRDKind: "IngressGateway", Resource: "ingressgateways"},
+	{Name: api.TerminatingGateway, CRDKind: "TerminatingGateway", Resource: "terminatinggateways"},
+	{Name: api.ServiceIntentions, CRDKind: "ServiceIntentions", Resource: "serviceintentions"},
+	{Name: api.MeshConfig, CRDKind: "Mesh", Resource: "meshes"},
+	{Name: api.ExportedServices, CRDKind: "ExportedServices", Resource: "exportedservices"},
+	{Name: api.SamenessGroup, CRDKind: "SamenessGroup", Resource: "samenessgroups"},
+	{Name: api.JWTProvider, CRDKind: "JWTProvider", Resource: "jwtproviders"},
+	{Name: api.RateLimitIPConfig, CRDKind: "ControlPlaneRequestLimit", Resource: "controlplanerequestlimits"},
+}
+
+// GroupVersionResource returns the resource of the kind's custom resources.
+func (k Kind) GroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: group, Version: version, Resource: k.Resource}
+}
+
+// KindByName returns the kind with the given config entry kind name.
+func KindByName(name string) (Kind, bool) {
+	for _, kind := range Kinds {
+		if kind.Name == name {
+			return kind, true
+		}
+	}
+	return Kind{}, false
+}
+
+// Entry is a config entry in Consul and the custom resources that manage it.
+type Entry struct {
+	api.ConfigEntry
+
+	// Managed is true if the config entry was written by the consul-k8s controller for a custom resource.
+	Managed bool
+	// Datacenter is the datacenter that the controller wrote a managed config entry from.
+	Datacenter string
+	// Resources are the custom resources, formatted as namespace/name, that manage the config entry.
+	Resources []string
+}
+
+// Orphaned returns true if the config entry was written by the controller of the given datacenter for a
+// custom resource that no longer exists. Entries written from other datacenters are managed by the custom
+// resources of other Kubernetes clusters, so they're never orphaned.
+func (e Entry) Orphaned(datacenter string) bool {
+	return e.Managed && e.Datacenter == datacenter && len(e.Resources) == 0
+}
+
+// Fetch returns the config entries of the kinds in Consul, sorted by kind and name, with the custom
+// resources that manage them. Custom resources of kinds whose CRD isn't installed are skipped.
+func Fetch(ctx context.Context, consulClient *api.Client, k8sClient dynamic.Interface, kinds []Kind) ([]Entry, error) {
+	var entries []Entry
+	for _, kind := range kinds {
+		configEntries, _, err := consulClient.ConfigEntries().List(kind.Name, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error listing %s config entries: %w", kind.Name, err)
+		}
+		resources, err := customResources(ctx, k8sClient, kind)
+		if err != nil {
+			return nil, fmt.Errorf("error listing %s resources: %w", kind.CRDKind, err)
+		}
+		for _, configEntry := range configEntries {
+			meta := configEntry.GetMeta()
+			entries = append(entries, Entry{
+				ConfigEntry: configEntry,
+				Managed:     meta[sourceKey] == sourceValue,
+				Datacenter:  meta[datacenterKey],
+				Resources:   resources[configEntry.GetName()],
+			})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].GetKind() != entries[j].GetKind() {
+			return entries[i].GetKind() < entries[j].GetKind()
+		}
+		return entries[i].GetName() < entries[j].GetName()
+	})
+	return entries, nil
+}
+
+// customResources returns the custom resources of the kind, formatted as namespace/name, keyed
+// by the name of the config entry that they manage.
+func customResources(ctx context.Context, k8sClient dynamic.Interface, kind Kind) (map[string][]string, error) {
+	list, err := k8sClient.Resource(kind.GroupVersionResource()).List(ctx, metav1.ListOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	resources := make(map[string][]string)
+	for _, item := range list.Items {
+		name := item.GetName()
+		// Service intentions are named after their destination rather than the custom resource.
+		if kind.Name == api.ServiceIntentions {
+			name, _, _ = unstructured.NestedString(item.Object, "spec", "destination", "name")
+		}
+		resources[name] = append(resources[name], fmt.Sprintf("%s/%s", item.GetNamespace(), item.GetName()))
+	}
+	return resources, nil
+}
+
+// configEntryFields are the fields of config entries that are part of a custom resource's
+// metadata rather than its spec.
+var configEntryFields = []string{"Kind", "Name", "Namespace", "Partition", "Meta", "CreateIndex", "ModifyIndex"}
+
+// verbatimFields are fields whose values are free-form, so their keys are kept as they are.
+var verbatimFields = map[string]bool{"Config": true, "Meta": true, "Add": true, "Set": true, "Arguments": true}
+
+// namedFields are maps keyed by user-defined names, so their keys are kept but their values are converted.
+var namedFields = map[string]bool{"Subsets": true, "Failover": true}
+
+// invalidNameChars matches the characters that aren't allowed in the names of Kubernetes resources.
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// ToCustomResource converts a config entry to a custom resource in the given Kubernetes namespace. The
+// fields of the config entry are converted to the camel case fields of the custom resource's spec.
+// The conversion is best effort, so the custom resource should be reviewed before it's applied.
+func ToCustomResource(configEntry api.ConfigEntry, namespace string) (*unstructured.Unstructured, error) {
+	kind, ok := KindByName(configEntry.GetKind())
+	if !ok {
+		return nil, fmt.Errorf("config entries of kind %s can't be managed by custom resources", configEntry.GetKind())
+	}
+
+	raw, err := json.Marshal(configEntry)
+	if err != nil {
+		return nil, err
+	}
+	var spec map[string]interface{}
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return nil, err
+	}
+	for _, field := range configEntryFields {
+		delete(spec, field)
+	}
+	spec = camelCaseKeys(spec).(map[string]interface{})
+	if kind.Name == api.ServiceIntentions {
+		spec["destination"] = map[string]interface{}{"name": configEntry.GetName()}
+	}
+
+	resource := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	resource.SetAPIVersion(group + "/" + version)
+	resource.SetKind(kind.CRDKind)
+	resource.SetName(resourceName(configEntry.GetName()))
+	resource.SetNamespace(namespace)
+	return resource, nil
+}
+
+// camelCaseKeys converts the keys of the maps in the value to camel case. Fields with zero values
+// are dropped since the Consul API returns structs that are unset in full.
+func camelCaseKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			switch {
+			case verbatimFields[key]:
+			case namedFields[key]:
+				named := make(map[string]interface{})
+				if m, ok := field.(map[string]interface{}); ok {
+					for name, nested := range m {
+						named[name] = camelCaseKeys(nested)
+					}
+				}
+				field = named
+			default:
+				field = camelCaseKeys(field)
+			}
+			if !isZero(field) {
+				converted[camelCase(key)] = field
+			}
+		}
+		return converted
+	case []interface{}:
+		for i, item := range v {
+			v[i] = camelCaseKeys(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// isZero returns true if the JSON value is null, empty, or the zero value of its type.
+func isZero(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	case string:
+		return v == ""
+	case float64:
+		return v == 0
+	case bool:
+		return !v
+	default:
+		return false
+	}
+}
+
+// camelCase lowercases the leading word of a field name, including acronyms,
+// e.g. MaxConnections to maxConnections and TLSConfig to tlsConfig.
+func camelCase(key string) string {
+	runes := []rune(key)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	// Keep the first letter of the next word uppercase after an acronym.
+	if upper > 1 && upper < len(runes) {
+		upper--
+	}
+	for i := 0; i < upper; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// resourceName returns a valid Kubernetes resource name for the config entry name, e.g. for the
+// wildcard destination of service intentions.
+func resourceName(name string) string {
+	name = strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(name), "-"), "-.")
+	if name == "" {
+		return "wildcard"
+	}
+	return name
+}
@@ -0,0 +1,247 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package configentry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestFetch(t *testing.T) {
+	consulClient := testConsulServer(t, "dc1", map[string][]api.ConfigEntry{
+		api.ServiceDefaults: {
+			&api.ServiceConfigEntry{Kind: api.ServiceDefaults, Name: "web", Meta: map[string]string{sourceKey: sourceValue, datacenterKey: "dc1"}},
+			&api.ServiceConfigEntry{Kind: api.ServiceDefaults, Name: "api", Meta: map[string]string{sourceKey: sourceValue, datacenterKey: "dc1"}},
+			&api.ServiceConfigEntry{Kind: api.ServiceDefaults, Name: "db", Meta: map[string]string{sourceKey: sourceValue, datacenterKey: "dc2"}},
+			&api.ServiceConfigEntry{Kind: api.ServiceDefaults, Name: "cache"},
+		},
+		api.ServiceIntentions: {
+			&api.ServiceIntentionsConfigEntry{Kind: api.ServiceIntentions, Name: "web", Meta: map[string]string{sourceKey: sourceValue, datacenterKey: "dc1"}},
+		},
+	})
+	k8sClient := testDynamicClient(t,
+		testCustomResource("ServiceDefaults", "web", "default", nil),
+		testCustomResource("ServiceIntentions", "web-intentions", "apps", map[string]interface{}{
+			"destination": map[string]interface{}{"name": "web"},
+		}),
+	)
+
+	kinds := []Kind{{Name: api.ServiceDefaults, CRDKind: "ServiceDefaults", Resource: "servicedefaults"}}
+	kind, _ := KindByName(api.ServiceIntentions)
+	kinds = append(kinds, kind)
+	entries, err := Fetch(context.Background(), consulClient, k8sClient, kinds)
+	require.NoError(t, err)
+
+	type result struct {
+		kind, name string
+		managed    bool
+		resources  []string
+		orphaned   bool
+	}
+	var results []result
+	for _, entry := range entries {
+		results = append(results, result{entry.GetKind(), entry.GetName(), entry.Managed, entry.Resources, entry.Orphaned("dc1")})
+	}
+	require.Equal(t, []result{
+		{kind: api.ServiceDefaults, name: "api", managed: true, orphaned: true},
+		{kind: api.ServiceDefaults, name: "cache"},
+		{kind: api.ServiceDefaults, name: "db", managed: true},
+		{kind: api.ServiceDefaults, name: "web", managed: true, resources: []string{"default/web"}},
+		{kind: api.ServiceIntentions, name: "web", managed: true, resources: []string{"apps/web-intentions"}},
+	}, results)
+}
+
+func TestFetch_CRDNotInstalled(t *testing.T) {
+	consulClient := testConsulServer(t, "dc1", map[string][]api.ConfigEntry{
+		api.JWTProvider: {&api.JWTProviderConfigEntry{Kind: api.JWTProvider, Name: "okta"}},
+	})
+	k8sClient := testDynamicClient(t)
+	k8sClient.PrependReactor("list", "jwtproviders", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewNotFound(schema.GroupResource{Group: group, Resource: "jwtproviders"}, "")
+	})
+
+	kind, _ := KindByName(api.JWTProvider)
+	entries, err := Fetch(context.Background(), consulClient, k8sClient, []Kind{kind})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "okta", entries[0].GetName())
+	require.Empty(t, entries[0].Resources)
+}
+
+func TestToCustomResource(t *testing.T) {
+	cases := map[string]struct {
+		entry  api.ConfigEntry
+		expErr string
+		exp    map[string]interface{}
+	}{
+		"service defaults": {
+			entry: &api.ServiceConfigEntry{
+				Kind:        api.ServiceDefaults,
+				Name:        "Web",
+				Protocol:    "http",
+				Meta:        map[string]string{"Owner": "team"},
+				MeshGateway: api.MeshGatewayConfig{Mode: api.MeshGatewayModeLocal},
+				UpstreamConfig: &api.UpstreamConfiguration{
+					Defaults: &api.UpstreamConfig{ConnectTimeoutMs: 500},
+				},
+			},
+			exp: map[string]interface{}{
+				"apiVersion": "consul.hashicorp.com/v1alpha1",
+				"kind":       "ServiceDefaults",
+				"metadata":   map[string]interface{}{"name": "web", "namespace": "apps"},
+				"spec": map[string]interface{}{
+					"protocol":    "http",
+					"meshGateway": map[string]interface{}{"mode": "local"},
+					"upstreamConfig": map[string]interface{}{
+						"defaults": map[string]interface{}{"connectTimeoutMs": float64(500)},
+					},
+				},
+			},
+		},
+		"proxy defaults keep their config": {
+			entry: &api.ProxyConfigEntry{
+				Kind:   api.ProxyDefaults,
+				Name:   api.ProxyConfigGlobal,
+				Config: map[string]interface{}{"Protocol": "http", "envoy_prometheus_bind_addr": "0.0.0.0:9102"},
+			},
+			exp: map[string]interface{}{
+				"apiVersion": "consul.hashicorp.com/v1alpha1",
+				"kind":       "ProxyDefaults",
+				"metadata":   map[string]interface{}{"name": "global", "namespace": "apps"},
+				"spec": map[string]interface{}{
+					"config": map[string]interface{}{"Protocol": "http", "envoy_prometheus_bind_addr": "0.0.0.0:9102"},
+				},
+			},
+		},
+		"service resolvers keep their subset names": {
+			entry: &api.ServiceResolverConfigEntry{
+				Kind:    api.ServiceResolver,
+				Name:    "web",
+				Subsets: map[string]api.ServiceResolverSubset{"V1": {Filter: "Service.Meta.version == v1"}},
+			},
+			exp: map[string]interface{}{
+				"apiVersion": "consul.hashicorp.com/v1alpha1",
+				"kind":       "ServiceResolver",
+				"metadata":   map[string]interface{}{"name": "web", "namespace": "apps"},
+				"spec": map[string]interface{}{
+					"subsets": map[string]interface{}{"V1": map[string]interface{}{"filter": "Service.Meta.version == v1"}},
+				},
+			},
+		},
+		"wildcard service intentions": {
+			entry: &api.ServiceIntentionsConfigEntry{
+				Kind:    api.ServiceIntentions,
+				Name:    "*",
+				Sources: []*api.SourceIntention{{Name: "*", Action: api.IntentionActionDeny}},
+			},
+			exp: map[string]interface{}{
+				"apiVersion": "consul.hashicorp.com/v1alpha1",
+				"kind":       "ServiceIntentions",
+				"metadata":   map[string]interface{}{"name": "wildcard", "namespace": "apps"},
+				"spec": map[string]interface{}{
+					"destination": map[string]interface{}{"name": "*"},
+					"sources":     []interface{}{map[string]interface{}{"name": "*", "action": "deny"}},
+				},
+			},
+		},
+		"unsupported kind": {
+			entry:  &api.APIGatewayConfigEntry{Kind: api.APIGateway, Name: "gateway"},
+			expErr: "config entries of kind api-gateway can't be managed by custom resources",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			resource, err := ToCustomResource(c.entry, "apps")
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, resource.Object)
+		})
+	}
+}
+
+func TestCamelCase(t *testing.T) {
+	for key, exp := range map[string]string{
+		"Protocol":            "protocol",
+		"MaxConnections":      "maxConnections",
+		"TLS":                 "tls",
+		"TLSConfig":           "tlsConfig",
+		"HTTPHeaderModifiers": "httpHeaderModifiers",
+		"ID":                  "id",
+		"envoy_extensions":    "envoy_extensions",
+	} {
+		require.Equal(t, exp, camelCase(key), key)
+	}
+}
+
+// testConsulServer returns a Consul client for a fake Consul server in the datacenter with the config entries.
+func testConsulServer(t *testing.T, datacenter string, entries map[string][]api.ConfigEntry) *api.Client {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/config/", func(w http.ResponseWriter, r *http.Request) {
+		kind := strings.TrimPrefix(r.URL.Path, "/v1/config/")
+		list := entries[kind]
+		if list == nil {
+			list = []api.ConfigEntry{}
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(list))
+	})
+	mux.HandleFunc("/v1/agent/self", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"Config": map[string]interface{}{"Datacenter": datacenter},
+		}))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	consulClient, err := api.NewClient(&api.Config{Address: strings.TrimPrefix(server.URL, "http://")})
+	require.NoError(t, err)
+	return consulClient
+}
+
+// testDynamicClient returns a fake Kubernetes client with the custom resources. The resources are created
+// rather than passed to the fake since it can't guess the plural names of kinds like ServiceDefaults.
+func testDynamicClient(t *testing.T, resources ...*unstructured.Unstructured) *dynamicFake.FakeDynamicClient {
+	listKinds := make(map[schema.GroupVersionResource]string)
+	for _, kind := range Kinds {
+		listKinds[schema.GroupVersionResource{Group: group, Version: version, Resource: kind.Resource}] = kind.CRDKind + "List"
+	}
+	k8sClient := dynamicFake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds)
+	for _, resource := range resources {
+		for _, kind := range Kinds {
+			if kind.CRDKind != resource.GetKind() {
+				continue
+			}
+			gvr := schema.GroupVersionResource{Group: group, Version: version, Resource: kind.Resource}
+			_, err := k8sClient.Resource(gvr).Namespace(resource.GetNamespace()).Create(context.Background(), resource, metav1.CreateOptions{})
+			require.NoError(t, err)
+		}
+	}
+	return k8sClient
+}
+
+// testCustomResource returns a custom resource of the kind with the spec.
+func testCustomResource(kind, name, namespace string, spec map[string]interface{}) *unstructured.Unstructured {
+	resource := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	resource.SetAPIVersion(group + "/" + version)
+	resource.SetKind(kind)
+	resource.SetName(name)
+	resource.SetNamespace(namespace)
+	return resource
+}
@@ -0,0 +1,122 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package configentry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/cli/common"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// serverSelector selects the pods of the Consul servers.
+const serverSelector = "app=consul,component=server"
+
+// ConnectToServer port forwards to the HTTP API of a Consul server in the namespace and returns a Consul
+// client for it. The client uses the token if set, and otherwise the bootstrap ACL token of the installation
+// if there is one. The port forward must be closed when the client is no longer used.
+func ConnectToServer(ctx context.Context, k8sClient kubernetes.Interface, restConfig *rest.Config, namespace, token string) (*api.Client, common.PortForwarder, error) {
+	pods, err := k8sClient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: serverSelector})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error listing Consul servers: %w", err)
+	}
+	var server *corev1.Pod
+	for i, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			server = &pods.Items[i]
+			break
+		}
+	}
+	if server == nil {
+		return nil, nil, fmt.Errorf("no running Consul servers found in namespace %s", namespace)
+	}
+	// Server pods are named after the full name of the installation, e.g. consul-consul-server-0.
+	fullName := server.Name
+	if i := strings.LastIndex(fullName, "-server-"); i >= 0 {
+		fullName = fullName[:i]
+	}
+
+	config := api.DefaultConfig()
+	if token != "" {
+		config.Token = token
+	} else if config.Token == "" {
+		secret, err := k8sClient.CoreV1().Secrets(namespace).Get(ctx, fullName+"-bootstrap-acl-token", metav1.GetOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return nil, nil, fmt.Errorf("error reading the bootstrap ACL token: %w", err)
+		}
+		if err == nil {
+			config.Token = string(secret.Data["token"])
+		}
+	}
+
+	remotePort, tls := serverHTTPPort(*server)
+	if remotePort == 0 {
+		return nil, nil, errors.New("Consul server doesn't expose its HTTP API")
+	}
+	if tls {
+		secret, err := k8sClient.CoreV1().Secrets(namespace).Get(ctx, fullName+"-ca-cert", metav1.GetOptions{})
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading the Consul CA certificate: %w", err)
+		}
+		config.Scheme = "https"
+		config.TLSConfig.CAPem = secret.Data[corev1.TLSCertKey]
+		// Server certificates are valid for localhost, which the port forward listens on.
+		config.TLSConfig.Address = "localhost"
+	}
+
+	pf := &common.PortForward{
+		Namespace:  namespace,
+		PodName:    server.Name,
+		RemotePort: remotePort,
+		KubeClient: k8sClient,
+		RestConfig: restConfig,
+	}
+	address, err := pf.Open(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error port forwarding to the Consul server: %w", err)
+	}
+	config.Address = address
+
+	consulClient, err := api.NewClient(config)
+	if err != nil {
+		pf.Close()
+		return nil, nil, fmt.Errorf("error creating Consul client: %w", err)
+	}
+	return consulClient, pf, nil
+}
+
+// Datacenter returns the datacenter of the Consul server that the client is connected to.
+func Datacenter(consulClient *api.Client) (string, error) {
+	self, err := consulClient.Agent().Self()
+	if err != nil {
+		return "", err
+	}
+	datacenter, _ := self["Config"]["Datacenter"].(string)
+	return datacenter, nil
+}
+
+// serverHTTPPort returns the port of the Consul server's HTTP API and whether it uses TLS.
+// HTTPS is preferred since the HTTP port may be disabled when TLS is enabled.
+func serverHTTPPort(pod corev1.Pod) (int, bool) {
+	var httpPort int
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			switch port.Name {
+			case "https":
+				return int(port.ContainerPort), true
+			case "http":
+				httpPort = int(port.ContainerPort)
+			}
+		}
+	}
+	return httpPort, false
+}
@@ -0,0 +1,117 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package helm
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// HashiCorpHelmRepository is the Helm repository the Consul Helm chart is
+	// published to.
+	HashiCorpHelmRepository = "https://helm.releases.hashicorp.com"
+
+	// ExportKubeVersion is the Kubernetes version manifests are rendered for.
+	// It is the minimum version supported by the Consul Helm chart.
+	ExportKubeVersion = "v1.22.0"
+)
+
+// ExportOptions is used when calling RenderManifests and
+// RenderTerraformHelmRelease.
+type ExportOptions struct {
+	// ReleaseName is the name of the Helm release.
+	ReleaseName string
+	// Namespace is the Kubernetes namespace the release is installed in.
+	Namespace string
+	// Values the Helm chart values in a map form.
+	Values map[string]interface{}
+	// Embedded chart specifies the Consul Helm chart that has been embedded
+	// into the consul-k8s CLI.
+	EmbeddedChart embed.FS
+	// ChartDirName is the top level directory name fo the EmbeddedChart.
+	ChartDirName string
+	// HelmActionsRunner is a thin interface around Helm actions for install,
+	// upgrade, and uninstall.
+	HelmActionsRunner HelmActionsRunner
+}
+
+// RenderManifests renders the Kubernetes manifests of the release without
+// contacting a Kubernetes cluster, the same as `helm template` would. Hooks
+// are included in the output with their Helm hook annotations.
+func RenderManifests(options *ExportOptions) (string, error) {
+	chart, err := options.HelmActionsRunner.LoadChart(options.EmbeddedChart, options.ChartDirName)
+	if err != nil {
+		return "", err
+	}
+	kubeVersion, err := chartutil.ParseKubeVersion(ExportKubeVersion)
+	if err != nil {
+		return "", err
+	}
+
+	install := action.NewInstall(&action.Configuration{Log: func(string, ...interface{}) {}})
+	install.ReleaseName = options.ReleaseName
+	install.Namespace = options.Namespace
+	install.DryRun = true
+	install.ClientOnly = true
+	install.Replace = true
+	install.IncludeCRDs = true
+	install.KubeVersion = kubeVersion
+	rel, err := install.Run(chart, options.Values)
+	if err != nil {
+		return "", fmt.Errorf("rendering manifests: %w", err)
+	}
+
+	var manifests strings.Builder
+	manifests.WriteString(strings.TrimSpace(rel.Manifest))
+	manifests.WriteString("\n")
+	for _, hook := range rel.Hooks {
+		fmt.Fprintf(&manifests, "---\n# Source: %s\n%s\n", hook.Path, strings.TrimSpace(hook.Manifest))
+	}
+	return manifests.String(), nil
+}
+
+// RenderTerraformHelmRelease renders a Terraform helm_release resource that
+// installs the release from the HashiCorp Helm repository with the version of
+// the embedded chart and the given values.
+func RenderTerraformHelmRelease(options *ExportOptions) (string, error) {
+	chart, err := options.HelmActionsRunner.LoadChart(options.EmbeddedChart, options.ChartDirName)
+	if err != nil {
+		return "", err
+	}
+
+	var resource strings.Builder
+	fmt.Fprintf(&resource, "resource \"helm_release\" %q {\n", options.ReleaseName)
+	fmt.Fprintf(&resource, "  name             = %q\n", options.ReleaseName)
+	fmt.Fprintf(&resource, "  namespace        = %q\n", options.Namespace)
+	fmt.Fprintf(&resource, "  create_namespace = true\n")
+	fmt.Fprintf(&resource, "  repository       = %q\n", HashiCorpHelmRepository)
+	fmt.Fprintf(&resource, "  chart            = %q\n", chart.Metadata.Name)
+	fmt.Fprintf(&resource, "  version          = %q\n", chart.Metadata.Version)
+
+	if len(options.Values) != 0 {
+		valuesYaml, err := yaml.Marshal(options.Values)
+		if err != nil {
+			return "", err
+		}
+		// Escape Terraform template sequences so values are passed to Helm as is.
+		escaped := strings.NewReplacer("${", "$${", "%{", "%%{").Replace(strings.TrimSpace(string(valuesYaml)))
+		resource.WriteString("\n  values = [<<-EOT\n")
+		for _, line := range strings.Split(escaped, "\n") {
+			if line == "" {
+				resource.WriteString("\n")
+				continue
+			}
+			fmt.Fprintf(&resource, "    %s\n", line)
+		}
+		resource.WriteString("  EOT\n  ]\n")
+	}
+	resource.WriteString("}\n")
+	return resource.String(), nil
+}
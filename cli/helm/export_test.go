@@ -0,0 +1,74 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package helm
+
+import (
+	"embed"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func TestRenderTerraformHelmRelease(t *testing.T) {
+	runner := &MockActionRunner{
+		LoadChartFunc: func(chrt embed.FS, chartDirName string) (*chart.Chart, error) {
+			return &chart.Chart{Metadata: &chart.Metadata{Name: "consul", Version: "1.2.0"}}, nil
+		},
+	}
+	cases := map[string]struct {
+		values   map[string]interface{}
+		expected string
+	}{
+		"without values": {
+			expected: `resource "helm_release" "consul" {
+  name             = "consul"
+  namespace        = "consul"
+  create_namespace = true
+  repository       = "https://helm.releases.hashicorp.com"
+  chart            = "consul"
+  version          = "1.2.0"
+}
+`,
+		},
+		"template sequences in values are escaped": {
+			values: map[string]interface{}{
+				"global": map[string]interface{}{
+					"name":             "consul",
+					"consulAPITimeout": "${timeout}",
+					"imageK8S":         "%{image}",
+				},
+			},
+			expected: `resource "helm_release" "consul" {
+  name             = "consul"
+  namespace        = "consul"
+  create_namespace = true
+  repository       = "https://helm.releases.hashicorp.com"
+  chart            = "consul"
+  version          = "1.2.0"
+
+  values = [<<-EOT
+    global:
+      consulAPITimeout: $${timeout}
+      imageK8S: '%%{image}'
+      name: consul
+  EOT
+  ]
+}
+`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			out, err := RenderTerraformHelmRelease(&ExportOptions{
+				ReleaseName:       "consul",
+				Namespace:         "consul",
+				Values:            tc.values,
+				HelmActionsRunner: runner,
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, out)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package preset
+
+import "github.com/hashicorp/consul-k8s/cli/config"
+
+// APIGatewayEdgePreset struct is an implementation of the Preset interface that provides
+// a Helm values map that is used during installation and represents the
+// configuration for exposing services at the edge of the mesh through API gateways.
+type APIGatewayEdgePreset struct{}
+
+// GetValueMap returns the Helm value map representing the configuration for
+// exposing services at the edge of the mesh through API gateways. It does the
+// following:
+//   - server replicas equal to 1.
+//   - enables the service mesh.
+//   - enables tls.
+//   - enables ACLs.
+//   - installs the Gateway API CRDs.
+//   - exposes gateways of the managed gateway class through LoadBalancer
+//     services.
+func (i *APIGatewayEdgePreset) GetValueMap() (map[string]interface{}, error) {
+	values := `
+global:
+  name: consul
+  tls:
+    enabled: true
+    enableAutoEncrypt: true
+  acls:
+    manageSystemACLs: true
+server:
+  replicas: 1
+connectInject:
+  enabled: true
+  apiGateway:
+    manageExternalCRDs: true
+    managedGatewayClass:
+      serviceType: LoadBalancer
+`
+
+	return config.ConvertToMap(values), nil
+}
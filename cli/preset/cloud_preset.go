@@ -67,7 +67,8 @@ type HCPConfig struct {
 // ConsulConfig represents 'cluster.consul_config' in the response
 // fetched from the agent bootstrap config endpoint in HCP.
 type ConsulConfig struct {
-	ACL ACL `json:"acl"`
+	ACL       ACL      `json:"acl"`
+	RetryJoin []string `json:"retry_join"`
 }
 
 // ACL represents 'cluster.consul_config.acl' in the response
@@ -305,15 +306,8 @@ func (c *CloudPreset) saveSecretsFromBootstrapConfig(config *CloudBootstrapConfi
 	}
 
 	// bootstrap token
-	if config.ConsulConfig.ACL.Tokens.InitialManagement != "" {
-		data := map[string][]byte{
-			secretKeyBootstrapToken: []byte(config.ConsulConfig.ACL.Tokens.InitialManagement),
-		}
-		if err := c.saveSecret(secretNameBootstrapToken, data, corev1.SecretTypeOpaque); err != nil {
-			return err
-		}
-		c.UI.Output(fmt.Sprintf("ACL bootstrap token saved as '%s' key in '%s' secret in namespace '%s'.",
-			secretKeyBootstrapToken, secretNameBootstrapToken, c.KubernetesNamespace), terminal.WithSuccessStyle())
+	if err := c.saveBootstrapTokenSecret(config); err != nil {
+		return err
 	}
 
 	// gossip key
@@ -342,16 +336,8 @@ func (c *CloudPreset) saveSecretsFromBootstrapConfig(config *CloudBootstrapConfi
 	}
 
 	// server CA
-	if len(config.BootstrapResponse.Bootstrap.ServerTLS.CertificateAuthorities) > 0 &&
-		config.BootstrapResponse.Bootstrap.ServerTLS.CertificateAuthorities[0] != "" {
-		data := map[string][]byte{
-			corev1.TLSCertKey: []byte(config.BootstrapResponse.Bootstrap.ServerTLS.CertificateAuthorities[0]),
-		}
-		if err := c.saveSecret(secretNameServerCA, data, corev1.SecretTypeOpaque); err != nil {
-			return err
-		}
-		c.UI.Output(fmt.Sprintf("Server TLS CA saved as '%s' key in '%s' secret in namespace '%s'.",
-			corev1.TLSCertKey, secretNameServerCA, c.KubernetesNamespace), terminal.WithSuccessStyle())
+	if err := c.saveServerCASecret(config); err != nil {
+		return err
 	}
 	// Optional secrets
 	// HCP auth url
@@ -393,6 +379,41 @@ func (c *CloudPreset) saveSecretsFromBootstrapConfig(config *CloudBootstrapConfi
 	return nil
 }
 
+// saveBootstrapTokenSecret saves the ACL bootstrap token of the agent
+// bootstrap config, if it has one.
+func (c *CloudPreset) saveBootstrapTokenSecret(config *CloudBootstrapConfig) error {
+	if config.ConsulConfig.ACL.Tokens.InitialManagement == "" {
+		return nil
+	}
+	data := map[string][]byte{
+		secretKeyBootstrapToken: []byte(config.ConsulConfig.ACL.Tokens.InitialManagement),
+	}
+	if err := c.saveSecret(secretNameBootstrapToken, data, corev1.SecretTypeOpaque); err != nil {
+		return err
+	}
+	c.UI.Output(fmt.Sprintf("ACL bootstrap token saved as '%s' key in '%s' secret in namespace '%s'.",
+		secretKeyBootstrapToken, secretNameBootstrapToken, c.KubernetesNamespace), terminal.WithSuccessStyle())
+	return nil
+}
+
+// saveServerCASecret saves the server CA of the agent bootstrap config, if it
+// has one.
+func (c *CloudPreset) saveServerCASecret(config *CloudBootstrapConfig) error {
+	if len(config.BootstrapResponse.Bootstrap.ServerTLS.CertificateAuthorities) == 0 ||
+		config.BootstrapResponse.Bootstrap.ServerTLS.CertificateAuthorities[0] == "" {
+		return nil
+	}
+	data := map[string][]byte{
+		corev1.TLSCertKey: []byte(config.BootstrapResponse.Bootstrap.ServerTLS.CertificateAuthorities[0]),
+	}
+	if err := c.saveSecret(secretNameServerCA, data, corev1.SecretTypeOpaque); err != nil {
+		return err
+	}
+	c.UI.Output(fmt.Sprintf("Server TLS CA saved as '%s' key in '%s' secret in namespace '%s'.",
+		corev1.TLSCertKey, secretNameServerCA, c.KubernetesNamespace), terminal.WithSuccessStyle())
+	return nil
+}
+
 // createNamespaceIfNotExists checks to see if a given namespace exists and if
 // it does not will create it.  This function is needed to ensure a namespace
 // exists before HCP config secrets are saved.
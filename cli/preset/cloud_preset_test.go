@@ -96,6 +96,7 @@ var validBootstrapConfig *CloudBootstrapConfig = &CloudBootstrapConfig{
 				InitialManagement: "74044c72-03c8-42b0-b57f-728bb22ca7fb",
 			},
 		},
+		RetryJoin: []string{},
 	},
 	BootstrapResponse: validBootstrapReponse,
 }
@@ -0,0 +1,43 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package preset
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/yaml"
+)
+
+// FilePreset struct is an implementation of the Preset interface that provides
+// a Helm values map read from a user-defined preset file.
+type FilePreset struct {
+	// Path is the path to the YAML preset file.
+	Path string
+}
+
+// GetValueMap returns the Helm value map in the preset file. An error is
+// returned if the file can't be read or doesn't contain a YAML map of values.
+func (f *FilePreset) GetValueMap() (map[string]interface{}, error) {
+	b, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading preset file: %w", err)
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(b, &values); err != nil {
+		return nil, fmt.Errorf("parsing preset file '%s': %w", f.Path, err)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("preset file '%s' contains no values", f.Path)
+	}
+	return values, nil
+}
+
+// IsPresetFile returns whether the preset name refers to a user-defined
+// preset file rather than one of the maintained presets.
+func IsPresetFile(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
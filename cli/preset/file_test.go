@@ -0,0 +1,69 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package preset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilePresetGetValueMap(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+		return path
+	}
+
+	testCases := map[string]struct {
+		path           string
+		expectedValues map[string]interface{}
+		expectedErr    string
+	}{
+		"values are read from the file": {
+			path: writeFile("valid.yaml", "global:\n  name: consul\n"),
+			expectedValues: map[string]interface{}{
+				"global": map[string]interface{}{"name": "consul"},
+			},
+		},
+		"empty file": {
+			path:        writeFile("empty.yaml", ""),
+			expectedErr: "contains no values",
+		},
+		"file is not a map": {
+			path:        writeFile("list.yaml", "- foo\n- bar\n"),
+			expectedErr: "parsing preset file",
+		},
+		"file does not exist": {
+			path:        filepath.Join(dir, "missing.yaml"),
+			expectedErr: "reading preset file",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			values, err := (&FilePreset{Path: tc.path}).GetValueMap()
+			if tc.expectedErr != "" {
+				require.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedValues, values)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "preset.yml")
+	require.NoError(t, os.WriteFile(path, []byte("server:\n  replicas: 3\n"), 0600))
+
+	for _, name := range Presets {
+		require.NoError(t, Validate(name))
+	}
+	require.NoError(t, Validate(path))
+	require.ErrorContains(t, Validate("foo"), "'foo' is not a valid preset")
+	require.ErrorContains(t, Validate("missing.yaml"), "reading preset file")
+}
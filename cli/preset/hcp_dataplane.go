@@ -0,0 +1,88 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package preset
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/consul-k8s/cli/config"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// HCPDataplanePreset struct is an implementation of the Preset interface that
+// fetches the agent bootstrap config of an HCP Consul cluster and provides a
+// Helm values map that installs only the dataplane components. No Consul
+// servers or clients run in the Kubernetes cluster; all components connect to
+// the HCP servers with TLS and ACLs.
+type HCPDataplanePreset struct {
+	*CloudPreset
+}
+
+// GetValueMap fetches the agent bootstrap config from HCP, saves the ACL
+// bootstrap token and the server CA into secrets, and maps the secret names
+// and the server addresses into the returned value map. Unlike the cloud
+// preset, the gossip key and the server certificate aren't saved because no
+// agents run in the cluster.
+func (h *HCPDataplanePreset) GetValueMap() (map[string]interface{}, error) {
+	bootstrapConfig, err := h.fetchAgentBootstrapConfig()
+	if err != nil {
+		return nil, err
+	}
+	if len(bootstrapConfig.ConsulConfig.RetryJoin) == 0 {
+		return nil, errors.New("the HCP agent bootstrap config doesn't include the addresses of the Consul servers")
+	}
+
+	if !h.SkipSavingSecrets {
+		if err := h.createNamespaceIfNotExists(); err != nil {
+			return nil, err
+		}
+		if err := h.saveBootstrapTokenSecret(bootstrapConfig); err != nil {
+			return nil, err
+		}
+		if err := h.saveServerCASecret(bootstrapConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	return h.getHelmConfig(bootstrapConfig), nil
+}
+
+// getHelmConfig maps the secret names and the first server address of the
+// agent bootstrap config into the Helm values template for the hcp-dataplane
+// preset, and returns the value map.
+func (h *HCPDataplanePreset) getHelmConfig(cfg *CloudBootstrapConfig) map[string]interface{} {
+	datacenter := cfg.BootstrapResponse.Cluster.ID
+
+	// Need to make sure the below has strict spaces and no tabs
+	values := fmt.Sprintf(`
+global:
+  name: consul
+  datacenter: %s
+  tls:
+    enabled: true
+    caCert:
+      secretName: %s
+      secretKey: %s
+  acls:
+    manageSystemACLs: true
+    bootstrapToken:
+      secretName: %s
+      secretKey: %s
+externalServers:
+  enabled: true
+  hardened: true
+  hosts: [%q]
+  tlsServerName: server.%s.consul
+server:
+  enabled: false
+client:
+  enabled: false
+connectInject:
+  enabled: true
+`, datacenter, secretNameServerCA, corev1.TLSCertKey,
+		secretNameBootstrapToken, secretKeyBootstrapToken,
+		cfg.ConsulConfig.RetryJoin[0], datacenter)
+	return config.ConvertToMap(values)
+}
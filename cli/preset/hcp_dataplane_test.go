@@ -0,0 +1,137 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package preset
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/cli/common/terminal"
+	"github.com/hashicorp/hcp-sdk-go/clients/cloud-global-network-manager-service/preview/2022-02-15/models"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
+)
+
+func TestHCPDataplanePresetGetValueMap(t *testing.T) {
+	testCases := []struct {
+		description string
+		retryJoin   string
+		expErr      string
+	}{
+		{
+			"Should save the bootstrap token and server CA secrets.",
+			`[\"consul.private.hashicorp.cloud\"]`,
+			"",
+		},
+		{
+			"Should error when the bootstrap config has no server addresses.",
+			`[]`,
+			"the HCP agent bootstrap config doesn't include the addresses of the Consul servers",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			response := strings.Replace(validResponse, `\"retry_join\":[]`, `\"retry_join\":`+tc.retryJoin, 1)
+			hcpMockServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("content-type", "application/json")
+				if r.URL.Path == "/global-network-manager/2022-02-15/organizations/ccbdd191-5dc3-4a73-9e05-6ac30ca67992/projects/36019e0d-ed59-4df6-9990-05bb7fc793b6/clusters/prod-on-prem/agent/bootstrap_config" &&
+					r.Method == "GET" {
+					w.Write([]byte(response))
+				} else {
+					w.Write([]byte(`{"access_token": "dummy-token"}`))
+				}
+			}))
+			hcpMockServer.StartTLS()
+			t.Cleanup(hcpMockServer.Close)
+			mockServerURL, err := url.Parse(hcpMockServer.URL)
+			require.NoError(t, err)
+			os.Setenv("HCP_AUTH_URL", hcpMockServer.URL)
+			os.Setenv("HCP_API_HOST", mockServerURL.Host)
+			os.Setenv("HCP_CLIENT_ID", hcpClientID)
+			os.Setenv("HCP_CLIENT_SECRET", hcpClientSecret)
+			t.Cleanup(func() {
+				os.Unsetenv("HCP_AUTH_URL")
+				os.Unsetenv("HCP_API_HOST")
+				os.Unsetenv("HCP_CLIENT_ID")
+				os.Unsetenv("HCP_CLIENT_SECRET")
+			})
+
+			k8s := fake.NewSimpleClientset()
+			p := &HCPDataplanePreset{CloudPreset: &CloudPreset{
+				HCPConfig:           &HCPConfig{ResourceID: hcpResourceID, ClientID: hcpClientID, ClientSecret: hcpClientSecret},
+				KubernetesClient:    k8s,
+				KubernetesNamespace: namespace,
+				UI:                  terminal.NewBasicUI(context.Background()),
+				HTTPClient:          hcpMockServer.Client(),
+				Context:             context.Background(),
+			}}
+			values, err := p.GetValueMap()
+			if tc.expErr != "" {
+				require.EqualError(t, err, tc.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, values)
+
+			ensureSecretKeyValueMatchesExpected(t, k8s, secretNameBootstrapToken, secretKeyBootstrapToken,
+				validBootstrapConfig.ConsulConfig.ACL.Tokens.InitialManagement, corev1.SecretTypeOpaque)
+			ensureSecretKeyValueMatchesExpected(t, k8s, secretNameServerCA, corev1.TLSCertKey,
+				validBootstrapReponse.Bootstrap.ServerTLS.CertificateAuthorities[0], corev1.SecretTypeOpaque)
+
+			// No agents run in the cluster, so the gossip key, the server cert and the HCP credentials aren't saved.
+			for _, name := range []string{secretNameGossipKey, secretNameServerCert, secretNameHCPClientID, secretNameHCPClientSecret, secretNameHCPResourceID} {
+				_, err := k8s.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+				require.Error(t, err, name)
+			}
+		})
+	}
+}
+
+func TestHCPDataplanePresetGetHelmConfig(t *testing.T) {
+	const expected = `client:
+  enabled: false
+connectInject:
+  enabled: true
+externalServers:
+  enabled: true
+  hardened: true
+  hosts:
+  - consul.private.hashicorp.cloud
+  tlsServerName: server.dc1.consul
+global:
+  acls:
+    bootstrapToken:
+      secretKey: token
+      secretName: consul-bootstrap-token
+    manageSystemACLs: true
+  datacenter: dc1
+  name: consul
+  tls:
+    caCert:
+      secretKey: tls.crt
+      secretName: consul-server-ca
+    enabled: true
+server:
+  enabled: false
+`
+	p := &HCPDataplanePreset{CloudPreset: &CloudPreset{}}
+	values := p.getHelmConfig(&CloudBootstrapConfig{
+		BootstrapResponse: &models.HashicorpCloudGlobalNetworkManager20220215AgentBootstrapResponse{
+			Cluster: &models.HashicorpCloudGlobalNetworkManager20220215Cluster{ID: "dc1"},
+		},
+		ConsulConfig: ConsulConfig{RetryJoin: []string{"consul.private.hashicorp.cloud"}},
+	})
+	valuesYaml, err := yaml.Marshal(values)
+	require.NoError(t, err)
+	require.Equal(t, expected, string(valuesYaml))
+}
@@ -0,0 +1,47 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package preset
+
+import "github.com/hashicorp/consul-k8s/cli/config"
+
+// MultiClusterPrimaryPreset struct is an implementation of the Preset interface that provides
+// a Helm values map that is used during installation and represents the
+// configuration of the primary datacenter of a federated Consul on Kubernetes deployment.
+type MultiClusterPrimaryPreset struct{}
+
+// GetValueMap returns the Helm value map representing the configuration of
+// the primary datacenter of a federated Consul on Kubernetes deployment. It
+// does the following:
+// - server replicas equal to 1.
+// - enables the service mesh.
+// - enables tls.
+// - enables gossip encryption.
+// - enables ACLs and creates the ACL replication token.
+// - enables federation and creates the federation secret.
+// - enables mesh gateways.
+func (i *MultiClusterPrimaryPreset) GetValueMap() (map[string]interface{}, error) {
+	values := `
+global:
+  name: consul
+  gossipEncryption:
+    autoGenerate: true
+  tls:
+    enabled: true
+  acls:
+    manageSystemACLs: true
+    createReplicationToken: true
+  federation:
+    enabled: true
+    createFederationSecret: true
+server:
+  replicas: 1
+connectInject:
+  enabled: true
+meshGateway:
+  enabled: true
+  replicas: 1
+`
+
+	return config.ConvertToMap(values), nil
+}
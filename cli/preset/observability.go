@@ -0,0 +1,48 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package preset
+
+import "github.com/hashicorp/consul-k8s/cli/config"
+
+// ObservabilityPreset struct is an implementation of the Preset interface that provides
+// a Helm values map that is used during installation and represents the
+// observability configuration for Consul on Kubernetes.
+type ObservabilityPreset struct{}
+
+// GetValueMap returns the Helm value map representing the observability
+// configuration for Consul on Kubernetes. It does the following:
+// - server replicas equal to 1.
+// - enables the service mesh.
+// - enables agent, gateway and service metrics with metrics merging.
+// - enables Prometheus.
+// - enables the ui with metrics from Prometheus.
+func (i *ObservabilityPreset) GetValueMap() (map[string]interface{}, error) {
+	values := `
+global:
+  name: consul
+  metrics:
+    enabled: true
+    enableAgentMetrics: true
+    enableGatewayMetrics: true
+connectInject:
+  enabled: true
+  metrics:
+    defaultEnabled: true
+    defaultEnableMerging: true
+server:
+  replicas: 1
+ui:
+  enabled: true
+  service:
+    enabled: true
+  metrics:
+    enabled: true
+    provider: prometheus
+    baseURL: http://prometheus-server
+prometheus:
+  enabled: true
+`
+
+	return config.ConvertToMap(values), nil
+}
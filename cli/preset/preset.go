@@ -6,12 +6,27 @@ package preset
 import (
 	"fmt"
 	"os"
+	"strings"
+
+	"k8s.io/utils/strings/slices"
 )
 
 const (
 	PresetSecure     = "secure"
 	PresetQuickstart = "quickstart"
 	PresetCloud      = "cloud"
+	// PresetHCPDataplane installs only the Consul dataplane components and
+	// joins the servers of an HCP Consul cluster.
+	PresetHCPDataplane = "hcp-dataplane"
+	// PresetObservability enables metrics for Consul and the service mesh
+	// and installs Prometheus.
+	PresetObservability = "observability"
+	// PresetMultiClusterPrimary installs the primary datacenter of a
+	// federated deployment.
+	PresetMultiClusterPrimary = "multi-cluster-primary"
+	// PresetAPIGatewayEdge exposes services at the edge of the mesh
+	// through API gateways.
+	PresetAPIGatewayEdge = "api-gateway-edge"
 
 	EnvHCPClientID     = "HCP_CLIENT_ID"
 	EnvHCPClientSecret = "HCP_CLIENT_SECRET"
@@ -21,8 +36,17 @@ const (
 )
 
 // Presets is a list of all the available presets for use with CLI's install
-// and uninstall commands.
-var Presets = []string{PresetCloud, PresetQuickstart, PresetSecure}
+// and uninstall commands. A path to a user-defined YAML preset file may be
+// used in place of one of these presets.
+var Presets = []string{
+	PresetAPIGatewayEdge,
+	PresetCloud,
+	PresetHCPDataplane,
+	PresetMultiClusterPrimary,
+	PresetObservability,
+	PresetQuickstart,
+	PresetSecure,
+}
 
 // Preset is the interface that each instance must implement.  For demo and
 // secure presets, they merely return a pre-configred value map.  For cloud,
@@ -45,14 +69,46 @@ func GetPreset(config *GetPresetConfig) (Preset, error) {
 	switch config.Name {
 	case PresetCloud:
 		return config.CloudPreset, nil
+	case PresetHCPDataplane:
+		return &HCPDataplanePreset{CloudPreset: config.CloudPreset}, nil
 	case PresetQuickstart:
 		return &QuickstartPreset{}, nil
 	case PresetSecure:
 		return &SecurePreset{}, nil
+	case PresetObservability:
+		return &ObservabilityPreset{}, nil
+	case PresetMultiClusterPrimary:
+		return &MultiClusterPrimaryPreset{}, nil
+	case PresetAPIGatewayEdge:
+		return &APIGatewayEdgePreset{}, nil
+	}
+	if IsPresetFile(config.Name) {
+		return &FilePreset{Path: config.Name}, nil
 	}
 	return nil, fmt.Errorf("'%s' is not a valid preset", config.Name)
 }
 
+// Validate returns an error if the name is neither one of the maintained
+// presets nor a readable preset file containing a YAML map of values. It is
+// used by the cli install and upgrade commands to validate the preset before
+// making any changes to the cluster.
+func Validate(name string) error {
+	if slices.Contains(Presets, name) {
+		return nil
+	}
+	if !IsPresetFile(name) {
+		return fmt.Errorf("'%s' is not a valid preset (valid presets: %s, or a path to a .yaml preset file)", name, strings.Join(Presets, ", "))
+	}
+	_, err := (&FilePreset{Path: name}).GetValueMap()
+	return err
+}
+
+// RequiresHCPConfig returns whether the preset fetches its configuration from
+// HCP and therefore requires HCP credentials and a resource id.
+func RequiresHCPConfig(name string) bool {
+	return name == PresetCloud || name == PresetHCPDataplane
+}
+
 func GetHCPPresetFromEnv(resourceID string) *HCPConfig {
 	hcpConfig := &HCPConfig{
 		ResourceID: resourceID,
@@ -0,0 +1,131 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package gatekeeper
+
+import (
+	"context"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api-gateway/common"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	gwv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+const (
+	// downstreamConnectionsMetric is the Envoy gauge of active downstream connections.
+	downstreamConnectionsMetric = "envoy_http_downstream_cx_active"
+	// requestsPerSecondMetric is the per-second rate of the Envoy downstream request counter,
+	// as served by the custom metrics API.
+	requestsPerSecondMetric = "envoy_http_downstream_rq"
+
+	defaultMaxInstances int32 = 8
+)
+
+func (g *Gatekeeper) upsertHorizontalPodAutoscaler(ctx context.Context, gateway gwv1beta1.Gateway, gcc v1alpha1.GatewayClassConfig) error {
+	autoscaler := g.horizontalPodAutoscaler(gateway, gcc)
+	if autoscaler == nil {
+		return g.deleteHorizontalPodAutoscaler(ctx, g.namespacedName(gateway))
+	}
+
+	mutated := autoscaler.DeepCopy()
+	mutator := func() error {
+		if !equality.Semantic.DeepEqual(autoscaler.Spec, mutated.Spec) {
+			mutated.Spec = autoscaler.Spec
+		}
+		return ctrl.SetControllerReference(&gateway, mutated, g.Client.Scheme())
+	}
+
+	result, err := controllerutil.CreateOrUpdate(ctx, g.Client, mutated, mutator)
+	if err != nil {
+		return err
+	}
+
+	switch result {
+	case controllerutil.OperationResultCreated:
+		g.Log.Info("Created HorizontalPodAutoscaler")
+	case controllerutil.OperationResultUpdated:
+		g.Log.Info("Updated HorizontalPodAutoscaler")
+	case controllerutil.OperationResultNone:
+		g.Log.Info("No change to HorizontalPodAutoscaler")
+	}
+
+	return nil
+}
+
+func (g *Gatekeeper) deleteHorizontalPodAutoscaler(ctx context.Context, gwName types.NamespacedName) error {
+	err := g.Client.Delete(ctx, &autoscalingv2.HorizontalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: gwName.Name, Namespace: gwName.Namespace}})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+
+	return err
+}
+
+// horizontalPodAutoscaler returns the HorizontalPodAutoscaler that scales the gateway's deployment
+// on the average Envoy metrics of its pods. It returns nil if autoscaling isn't configured.
+func (g *Gatekeeper) horizontalPodAutoscaler(gateway gwv1beta1.Gateway, gcc v1alpha1.GatewayClassConfig) *autoscalingv2.HorizontalPodAutoscaler {
+	autoscaling := gcc.Spec.DeploymentSpec.Autoscaling
+	if autoscaling == nil {
+		return nil
+	}
+
+	var metrics []autoscalingv2.MetricSpec
+	if autoscaling.TargetDownstreamConnections != nil {
+		metrics = append(metrics, podsMetric(downstreamConnectionsMetric, *autoscaling.TargetDownstreamConnections))
+	}
+	if autoscaling.TargetRequestsPerSecond != nil {
+		metrics = append(metrics, podsMetric(requestsPerSecondMetric, *autoscaling.TargetRequestsPerSecond))
+	}
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	minReplicas := defaultInstances
+	if gcc.Spec.DeploymentSpec.MinInstances != nil {
+		minReplicas = *gcc.Spec.DeploymentSpec.MinInstances
+	}
+	maxReplicas := defaultMaxInstances
+	if gcc.Spec.DeploymentSpec.MaxInstances != nil {
+		maxReplicas = *gcc.Spec.DeploymentSpec.MaxInstances
+	}
+
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      gateway.Name,
+			Namespace: gateway.Namespace,
+			Labels:    common.LabelsForGateway(&gateway),
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       gateway.Name,
+			},
+			MinReplicas: &minReplicas,
+			MaxReplicas: maxReplicas,
+			Metrics:     metrics,
+		},
+	}
+}
+
+func podsMetric(name string, target int32) autoscalingv2.MetricSpec {
+	return autoscalingv2.MetricSpec{
+		Type: autoscalingv2.PodsMetricSourceType,
+		Pods: &autoscalingv2.PodsMetricSource{
+			Metric: autoscalingv2.MetricIdentifier{Name: name},
+			Target: autoscalingv2.MetricTarget{
+				Type:         autoscalingv2.AverageValueMetricType,
+				AverageValue: resource.NewQuantity(int64(target), resource.DecimalSI),
+			},
+		},
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -128,28 +129,57 @@ func (g *Gatekeeper) deployment(gateway gwv1beta1.Gateway, gcc v1alpha1.GatewayC
 					Containers: []corev1.Container{
 						container,
 					},
-					Affinity: &corev1.Affinity{
-						PodAntiAffinity: &corev1.PodAntiAffinity{
-							PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
-								{
-									Weight: 1,
-									PodAffinityTerm: corev1.PodAffinityTerm{
-										LabelSelector: &metav1.LabelSelector{
-											MatchLabels: common.LabelsForGateway(&gateway),
-										},
-										TopologyKey: "kubernetes.io/hostname",
-									},
-								},
-							},
+					Affinity:                  deploymentAffinity(gateway, gcc),
+					TopologySpreadConstraints: topologySpreadConstraints(gateway, gcc),
+					PriorityClassName:         gcc.Spec.PriorityClassName,
+					NodeSelector:              gcc.Spec.NodeSelector,
+					Tolerations:               gcc.Spec.Tolerations,
+					ServiceAccountName:        g.serviceAccountName(gateway, config),
+				},
+			},
+		},
+	}, nil
+}
+
+// deploymentAffinity returns the affinity of the GatewayClassConfig, or a preferred pod
+// anti-affinity that spreads the pods of the gateway across nodes if it isn't set.
+func deploymentAffinity(gateway gwv1beta1.Gateway, gcc v1alpha1.GatewayClassConfig) *corev1.Affinity {
+	if gcc.Spec.Affinity != nil {
+		return gcc.Spec.Affinity.DeepCopy()
+	}
+
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+				{
+					Weight: 1,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: common.LabelsForGateway(&gateway),
 						},
+						TopologyKey: "kubernetes.io/hostname",
 					},
-					NodeSelector:       gcc.Spec.NodeSelector,
-					Tolerations:        gcc.Spec.Tolerations,
-					ServiceAccountName: g.serviceAccountName(gateway, config),
 				},
 			},
 		},
-	}, nil
+	}
+}
+
+// topologySpreadConstraints returns the topology spread constraints of the GatewayClassConfig.
+// Constraints without a label selector select the pods of the gateway, since its labels aren't
+// known when the GatewayClassConfig is written.
+func topologySpreadConstraints(gateway gwv1beta1.Gateway, gcc v1alpha1.GatewayClassConfig) []corev1.TopologySpreadConstraint {
+	var constraints []corev1.TopologySpreadConstraint
+	for _, constraint := range gcc.Spec.TopologySpreadConstraints {
+		constraint := *constraint.DeepCopy()
+		if constraint.LabelSelector == nil {
+			constraint.LabelSelector = &metav1.LabelSelector{
+				MatchLabels: common.LabelsForGateway(&gateway),
+			}
+		}
+		constraints = append(constraints, constraint)
+	}
+	return constraints
 }
 
 func mergeDeployments(gcc v1alpha1.GatewayClassConfig, a, b *appsv1.Deployment) *appsv1.Deployment {
@@ -184,6 +214,10 @@ func compareDeployments(a, b *appsv1.Deployment) bool {
 		}
 	}
 
+	if !compareScheduling(a.Spec.Template.Spec, b.Spec.Template.Spec) {
+		return false
+	}
+
 	if b.Spec.Replicas == nil && a.Spec.Replicas == nil {
 		return true
 	} else if b.Spec.Replicas == nil {
@@ -195,6 +229,16 @@ func compareDeployments(a, b *appsv1.Deployment) bool {
 	return *b.Spec.Replicas == *a.Spec.Replicas
 }
 
+// compareScheduling checks that the pod specs don't differ by the scheduling settings
+// of the GatewayClassConfig, so that changes to them are rolled out to existing gateways.
+func compareScheduling(a, b corev1.PodSpec) bool {
+	return equality.Semantic.DeepEqual(a.NodeSelector, b.NodeSelector) &&
+		equality.Semantic.DeepEqual(a.Tolerations, b.Tolerations) &&
+		equality.Semantic.DeepEqual(a.Affinity, b.Affinity) &&
+		equality.Semantic.DeepEqual(a.TopologySpreadConstraints, b.TopologySpreadConstraints) &&
+		a.PriorityClassName == b.PriorityClassName
+}
+
 func newDeploymentMutator(deployment, mutated *appsv1.Deployment, gcc v1alpha1.GatewayClassConfig, gateway gwv1beta1.Gateway, scheme *runtime.Scheme) resourceMutator {
 	return func() error {
 		mutated = mergeDeployments(gcc, deployment, mutated)
@@ -54,6 +54,10 @@ func (g *Gatekeeper) Upsert(ctx context.Context, gateway gwv1beta1.Gateway, gcc
 		return err
 	}
 
+	if err := g.upsertHorizontalPodAutoscaler(ctx, gateway, gcc); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -62,6 +66,10 @@ func (g *Gatekeeper) Upsert(ctx context.Context, gateway gwv1beta1.Gateway, gcc
 func (g *Gatekeeper) Delete(ctx context.Context, gatewayName types.NamespacedName) error {
 	g.Log.Info(fmt.Sprintf("Delete Gateway Deployment %s/%s", gatewayName.Namespace, gatewayName.Name))
 
+	if err := g.deleteHorizontalPodAutoscaler(ctx, gatewayName); err != nil {
+		return err
+	}
+
 	if err := g.deleteDeployment(ctx, gatewayName); err != nil {
 		return err
 	}
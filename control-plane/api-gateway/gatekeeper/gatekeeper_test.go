@@ -13,8 +13,10 @@ import (
 	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
 	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -64,6 +66,7 @@ type resources struct {
 	roleBindings    []*rbac.RoleBinding
 	services        []*corev1.Service
 	serviceAccounts []*corev1.ServiceAccount
+	autoscalers     []*autoscalingv2.HorizontalPodAutoscaler
 }
 
 func TestUpsert(t *testing.T) {
@@ -280,6 +283,78 @@ func TestUpsert(t *testing.T) {
 				serviceAccounts: []*corev1.ServiceAccount{},
 			},
 		},
+		"create a new gateway deployment with a LoadBalancer Service configured by the GatewayClassConfig": {
+			gateway: gwv1beta1.Gateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+					Annotations: map[string]string{
+						"external-dns.alpha.kubernetes.io/hostname": "gateway.example.com",
+					},
+				},
+				Spec: gwv1beta1.GatewaySpec{
+					Listeners: listeners,
+					Addresses: []gwv1beta1.GatewayAddress{
+						{Type: common.PointerTo(gwv1beta1.HostnameAddressType), Value: "gateway.example.com"},
+						{Value: "10.0.0.10"},
+					},
+				},
+			},
+			gatewayClassConfig: v1alpha1.GatewayClassConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "consul-gatewayclassconfig",
+				},
+				Spec: v1alpha1.GatewayClassConfigSpec{
+					DeploymentSpec: v1alpha1.DeploymentSpec{
+						DefaultInstances: common.PointerTo(int32(3)),
+						MaxInstances:     common.PointerTo(int32(3)),
+						MinInstances:     common.PointerTo(int32(1)),
+					},
+					CopyAnnotations: v1alpha1.CopyAnnotationsSpec{},
+					ServiceType:     (*corev1.ServiceType)(common.PointerTo("LoadBalancer")),
+					Service: v1alpha1.ServiceSpec{
+						Annotations: map[string]string{
+							"service.beta.kubernetes.io/aws-load-balancer-scheme": "internal",
+							"external-dns.alpha.kubernetes.io/hostname":           "default.example.com",
+						},
+						LoadBalancerClass:     common.PointerTo("service.k8s.aws/nlb"),
+						ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
+					},
+				},
+			},
+			helmConfig:       common.HelmConfig{},
+			initialResources: resources{},
+			finalResources: resources{
+				deployments: []*appsv1.Deployment{
+					configureDeployment(name, namespace, labels, 3, nil, nil, "", "1"),
+				},
+				roles: []*rbac.Role{},
+				services: []*corev1.Service{
+					func() *corev1.Service {
+						service := configureService(name, namespace, labels, map[string]string{
+							"service.beta.kubernetes.io/aws-load-balancer-scheme": "internal",
+							"external-dns.alpha.kubernetes.io/hostname":           "gateway.example.com",
+						}, (corev1.ServiceType)("LoadBalancer"), []corev1.ServicePort{
+							{
+								Name:     "Listener 1",
+								Protocol: "TCP",
+								Port:     8080,
+							},
+							{
+								Name:     "Listener 2",
+								Protocol: "TCP",
+								Port:     8081,
+							},
+						}, "1")
+						service.Spec.LoadBalancerClass = common.PointerTo("service.k8s.aws/nlb")
+						service.Spec.LoadBalancerIP = "10.0.0.10"
+						service.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeLocal
+						return service
+					}(),
+				},
+				serviceAccounts: []*corev1.ServiceAccount{},
+			},
+		},
 		"update a gateway, adding a listener to a service": {
 			gateway: gwv1beta1.Gateway{
 				ObjectMeta: metav1.ObjectMeta{
@@ -596,6 +671,97 @@ func TestUpsert(t *testing.T) {
 				serviceAccounts: []*corev1.ServiceAccount{},
 			},
 		},
+		"create a new gateway deployment with a HorizontalPodAutoscaler": {
+			gateway: gwv1beta1.Gateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+				Spec: gwv1beta1.GatewaySpec{
+					Listeners: listeners,
+				},
+			},
+			gatewayClassConfig: v1alpha1.GatewayClassConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "consul-gatewayclassconfig",
+				},
+				Spec: v1alpha1.GatewayClassConfigSpec{
+					DeploymentSpec: v1alpha1.DeploymentSpec{
+						DefaultInstances: common.PointerTo(int32(3)),
+						MaxInstances:     common.PointerTo(int32(5)),
+						MinInstances:     common.PointerTo(int32(2)),
+						Autoscaling: &v1alpha1.AutoscalingSpec{
+							TargetDownstreamConnections: common.PointerTo(int32(100)),
+							TargetRequestsPerSecond:     common.PointerTo(int32(50)),
+						},
+					},
+					CopyAnnotations: v1alpha1.CopyAnnotationsSpec{},
+					ServiceType:     (*corev1.ServiceType)(common.PointerTo("NodePort")),
+				},
+			},
+			helmConfig:       common.HelmConfig{},
+			initialResources: resources{},
+			finalResources: resources{
+				deployments: []*appsv1.Deployment{
+					configureDeployment(name, namespace, labels, 3, nil, nil, "", "1"),
+				},
+				roles:           []*rbac.Role{},
+				services:        []*corev1.Service{},
+				serviceAccounts: []*corev1.ServiceAccount{},
+				autoscalers: []*autoscalingv2.HorizontalPodAutoscaler{
+					configureHorizontalPodAutoscaler(name, namespace, labels, 2, 5, 100, 50, "1"),
+				},
+			},
+		},
+		"update a gateway deployment with a HorizontalPodAutoscaler keeps the number of replicas it has set": {
+			gateway: gwv1beta1.Gateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+				Spec: gwv1beta1.GatewaySpec{
+					Listeners: listeners,
+				},
+			},
+			gatewayClassConfig: v1alpha1.GatewayClassConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "consul-gatewayclassconfig",
+				},
+				Spec: v1alpha1.GatewayClassConfigSpec{
+					DeploymentSpec: v1alpha1.DeploymentSpec{
+						DefaultInstances: common.PointerTo(int32(3)),
+						MaxInstances:     common.PointerTo(int32(5)),
+						MinInstances:     common.PointerTo(int32(2)),
+						Autoscaling: &v1alpha1.AutoscalingSpec{
+							TargetDownstreamConnections: common.PointerTo(int32(100)),
+							TargetRequestsPerSecond:     common.PointerTo(int32(50)),
+						},
+					},
+					CopyAnnotations: v1alpha1.CopyAnnotationsSpec{},
+					ServiceType:     (*corev1.ServiceType)(common.PointerTo("NodePort")),
+				},
+			},
+			helmConfig: common.HelmConfig{},
+			initialResources: resources{
+				deployments: []*appsv1.Deployment{
+					configureDeployment(name, namespace, labels, 4, nil, nil, "", "1"),
+				},
+				autoscalers: []*autoscalingv2.HorizontalPodAutoscaler{
+					configureHorizontalPodAutoscaler(name, namespace, labels, 1, 8, 10, 10, "1"),
+				},
+			},
+			finalResources: resources{
+				deployments: []*appsv1.Deployment{
+					configureDeployment(name, namespace, labels, 4, nil, nil, "", "1"),
+				},
+				roles:           []*rbac.Role{},
+				services:        []*corev1.Service{},
+				serviceAccounts: []*corev1.ServiceAccount{},
+				autoscalers: []*autoscalingv2.HorizontalPodAutoscaler{
+					configureHorizontalPodAutoscaler(name, namespace, labels, 2, 5, 100, 50, "2"),
+				},
+			},
+		},
 	}
 
 	for name, tc := range cases {
@@ -606,6 +772,7 @@ func TestUpsert(t *testing.T) {
 			require.NoError(t, rbac.AddToScheme(s))
 			require.NoError(t, corev1.AddToScheme(s))
 			require.NoError(t, appsv1.AddToScheme(s))
+			require.NoError(t, autoscalingv2.AddToScheme(s))
 
 			log := logrtest.New(t)
 
@@ -777,6 +944,51 @@ func TestDelete(t *testing.T) {
 				serviceAccounts: []*corev1.ServiceAccount{},
 			},
 		},
+		"delete a gateway deployment with a HorizontalPodAutoscaler": {
+			gateway: gwv1beta1.Gateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+				Spec: gwv1beta1.GatewaySpec{
+					Listeners: listeners,
+				},
+			},
+			gatewayClassConfig: v1alpha1.GatewayClassConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "consul-gatewayclassconfig",
+				},
+				Spec: v1alpha1.GatewayClassConfigSpec{
+					DeploymentSpec: v1alpha1.DeploymentSpec{
+						DefaultInstances: common.PointerTo(int32(3)),
+						MaxInstances:     common.PointerTo(int32(5)),
+						MinInstances:     common.PointerTo(int32(2)),
+						Autoscaling: &v1alpha1.AutoscalingSpec{
+							TargetDownstreamConnections: common.PointerTo(int32(100)),
+							TargetRequestsPerSecond:     common.PointerTo(int32(50)),
+						},
+					},
+					CopyAnnotations: v1alpha1.CopyAnnotationsSpec{},
+					ServiceType:     (*corev1.ServiceType)(common.PointerTo("NodePort")),
+				},
+			},
+			helmConfig: common.HelmConfig{},
+			initialResources: resources{
+				deployments: []*appsv1.Deployment{
+					configureDeployment(name, namespace, labels, 3, nil, nil, "", "1"),
+				},
+				autoscalers: []*autoscalingv2.HorizontalPodAutoscaler{
+					configureHorizontalPodAutoscaler(name, namespace, labels, 2, 5, 100, 50, "1"),
+				},
+			},
+			finalResources: resources{
+				deployments:     []*appsv1.Deployment{},
+				roles:           []*rbac.Role{},
+				services:        []*corev1.Service{},
+				serviceAccounts: []*corev1.ServiceAccount{},
+				autoscalers:     []*autoscalingv2.HorizontalPodAutoscaler{},
+			},
+		},
 	}
 
 	for name, tc := range cases {
@@ -787,6 +999,7 @@ func TestDelete(t *testing.T) {
 			require.NoError(t, rbac.AddToScheme(s))
 			require.NoError(t, corev1.AddToScheme(s))
 			require.NoError(t, appsv1.AddToScheme(s))
+			require.NoError(t, autoscalingv2.AddToScheme(s))
 
 			log := logrtest.New(t)
 
@@ -806,6 +1019,114 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestUpsert_Scheduling(t *testing.T) {
+	t.Parallel()
+
+	gateway := gwv1beta1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: gwv1beta1.GatewaySpec{
+			Listeners: listeners,
+		},
+	}
+	zoneSpread := corev1.TopologySpreadConstraint{
+		MaxSkew:           1,
+		TopologyKey:       "topology.kubernetes.io/zone",
+		WhenUnsatisfiable: corev1.ScheduleAnyway,
+	}
+	nodeAffinity := &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+					MatchExpressions: []corev1.NodeSelectorRequirement{{
+						Key:      "node-pool",
+						Operator: corev1.NodeSelectorOpIn,
+						Values:   []string{"edge"},
+					}},
+				}},
+			},
+		},
+	}
+
+	cases := map[string]struct {
+		spec                    v1alpha1.GatewayClassConfigSpec
+		expectedAffinity        *corev1.Affinity
+		expectedSpread          []corev1.TopologySpreadConstraint
+		expectedPriorityClass   string
+		defaultPodAntiAffinity  bool
+		existingDeploymentNodes map[string]string
+	}{
+		"defaults to pod anti-affinity across nodes": {
+			spec:                   v1alpha1.GatewayClassConfigSpec{},
+			defaultPodAntiAffinity: true,
+		},
+		"sets the affinity, topology spread constraints and priority class": {
+			spec: v1alpha1.GatewayClassConfigSpec{
+				Affinity:                  nodeAffinity,
+				TopologySpreadConstraints: []corev1.TopologySpreadConstraint{zoneSpread},
+				PriorityClassName:         "gateway-critical",
+			},
+			expectedAffinity: nodeAffinity,
+			expectedSpread: []corev1.TopologySpreadConstraint{{
+				MaxSkew:           1,
+				TopologyKey:       "topology.kubernetes.io/zone",
+				WhenUnsatisfiable: corev1.ScheduleAnyway,
+				LabelSelector:     &metav1.LabelSelector{MatchLabels: common.LabelsForGateway(&gateway)},
+			}},
+			expectedPriorityClass: "gateway-critical",
+		},
+		"updates the scheduling settings of an existing deployment": {
+			spec: v1alpha1.GatewayClassConfigSpec{
+				NodeSelector:      map[string]string{"node-pool": "edge"},
+				PriorityClassName: "gateway-critical",
+			},
+			defaultPodAntiAffinity:  true,
+			expectedPriorityClass:   "gateway-critical",
+			existingDeploymentNodes: map[string]string{"node-pool": "default"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := runtime.NewScheme()
+			require.NoError(t, gwv1beta1.Install(s))
+			require.NoError(t, v1alpha1.AddToScheme(s))
+			require.NoError(t, rbac.AddToScheme(s))
+			require.NoError(t, corev1.AddToScheme(s))
+			require.NoError(t, appsv1.AddToScheme(s))
+			require.NoError(t, autoscalingv2.AddToScheme(s))
+
+			gcc := v1alpha1.GatewayClassConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "consul-gatewayclassconfig"},
+				Spec:       tc.spec,
+			}
+			objs := []client.Object{&gateway, &gcc}
+			if tc.existingDeploymentNodes != nil {
+				objs = append(objs, configureDeployment(gateway.Name, gateway.Namespace, labels, 1, tc.existingDeploymentNodes, nil, "", "1"))
+			}
+			client := fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).Build()
+
+			gatekeeper := New(logrtest.New(t), client)
+			require.NoError(t, gatekeeper.Upsert(context.Background(), gateway, gcc, common.HelmConfig{}))
+
+			deployment := &appsv1.Deployment{}
+			require.NoError(t, client.Get(context.Background(), types.NamespacedName{Name: gateway.Name, Namespace: gateway.Namespace}, deployment))
+			podSpec := deployment.Spec.Template.Spec
+			if tc.defaultPodAntiAffinity {
+				require.NotNil(t, podSpec.Affinity.PodAntiAffinity)
+				require.Nil(t, podSpec.Affinity.NodeAffinity)
+			} else {
+				require.Equal(t, tc.expectedAffinity, podSpec.Affinity)
+			}
+			require.Equal(t, tc.expectedSpread, podSpec.TopologySpreadConstraints)
+			require.Equal(t, tc.expectedPriorityClass, podSpec.PriorityClassName)
+			require.Equal(t, tc.spec.NodeSelector, podSpec.NodeSelector)
+		})
+	}
+}
+
 func joinResources(resources resources) (objs []client.Object) {
 	for _, deployment := range resources.deployments {
 		objs = append(objs, deployment)
@@ -827,6 +1148,10 @@ func joinResources(resources resources) (objs []client.Object) {
 		objs = append(objs, serviceAccount)
 	}
 
+	for _, autoscaler := range resources.autoscalers {
+		objs = append(objs, autoscaler)
+	}
+
 	return objs
 }
 
@@ -923,6 +1248,23 @@ func validateResourcesExist(t *testing.T, client client.Client, resources resour
 		require.Equal(t, expected, actual)
 	}
 
+	for _, expected := range resources.autoscalers {
+		actual := &autoscalingv2.HorizontalPodAutoscaler{}
+		err := client.Get(context.Background(), types.NamespacedName{
+			Name:      expected.Name,
+			Namespace: expected.Namespace,
+		}, actual)
+		if err != nil {
+			return err
+		}
+
+		// Patch the createdAt label
+		actual.Labels[createdAtLabelKey] = createdAtLabelValue
+
+		require.Equal(t, expected.Labels, actual.Labels)
+		require.True(t, equality.Semantic.DeepEqual(expected.Spec, actual.Spec), "unexpected horizontal pod autoscaler spec: %+v", actual.Spec)
+	}
+
 	return nil
 }
 
@@ -989,6 +1331,18 @@ func validateResourcesAreDeleted(t *testing.T, k8sClient client.Client, resource
 		require.Error(t, err)
 	}
 
+	for _, expected := range resources.autoscalers {
+		actual := &autoscalingv2.HorizontalPodAutoscaler{}
+		err := k8sClient.Get(context.Background(), types.NamespacedName{
+			Name:      expected.Name,
+			Namespace: expected.Namespace,
+		}, actual)
+		if !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("expected horizontal pod autoscaler %s to be deleted", expected.Name)
+		}
+		require.Error(t, err)
+	}
+
 	return nil
 }
 
@@ -1164,3 +1518,40 @@ func configureServiceAccount(name, namespace string, labels map[string]string, r
 		},
 	}
 }
+
+func configureHorizontalPodAutoscaler(name, namespace string, labels map[string]string, minReplicas, maxReplicas, targetConnections, targetRequests int32, resourceVersion string) *autoscalingv2.HorizontalPodAutoscaler {
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "autoscaling/v2",
+			Kind:       "HorizontalPodAutoscaler",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       namespace,
+			Labels:          labels,
+			ResourceVersion: resourceVersion,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         "gateway.networking.k8s.io/v1beta1",
+					Kind:               "Gateway",
+					Name:               name,
+					Controller:         common.PointerTo(true),
+					BlockOwnerDeletion: common.PointerTo(true),
+				},
+			},
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       name,
+			},
+			MinReplicas: common.PointerTo(minReplicas),
+			MaxReplicas: maxReplicas,
+			Metrics: []autoscalingv2.MetricSpec{
+				podsMetric(downstreamConnectionsMetric, targetConnections),
+				podsMetric(requestsPerSecondMetric, targetRequests),
+			},
+		},
+	}
+}
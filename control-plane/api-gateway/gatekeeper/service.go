@@ -75,19 +75,23 @@ func (g *Gatekeeper) service(gateway gwv1beta1.Gateway, gcc v1alpha1.GatewayClas
 		})
 	}
 
-	// Copy annotations from the Gateway, filtered by those allowed by the GatewayClassConfig.
+	// Copy annotations from the Gateway, filtered by those allowed by the GatewayClassConfig,
+	// on top of the annotations set on the GatewayClassConfig.
 	allowedAnnotations := gcc.Spec.CopyAnnotations.Service
 	if allowedAnnotations == nil {
 		allowedAnnotations = defaultServiceAnnotations
 	}
 	annotations := make(map[string]string)
+	for key, value := range gcc.Spec.Service.Annotations {
+		annotations[key] = value
+	}
 	for _, allowedAnnotation := range allowedAnnotations {
 		if value, found := gateway.Annotations[allowedAnnotation]; found {
 			annotations[allowedAnnotation] = value
 		}
 	}
 
-	return &corev1.Service{
+	service := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        gateway.Name,
 			Namespace:   gateway.Namespace,
@@ -100,6 +104,28 @@ func (g *Gatekeeper) service(gateway gwv1beta1.Gateway, gcc v1alpha1.GatewayClas
 			Ports:    ports,
 		},
 	}
+
+	// Load balancer settings are rejected by Kubernetes for other types of Services.
+	switch service.Spec.Type {
+	case corev1.ServiceTypeLoadBalancer:
+		service.Spec.LoadBalancerClass = gcc.Spec.Service.LoadBalancerClass
+		service.Spec.LoadBalancerIP = loadBalancerIP(gateway)
+		service.Spec.ExternalTrafficPolicy = gcc.Spec.Service.ExternalTrafficPolicy
+	case corev1.ServiceTypeNodePort:
+		service.Spec.ExternalTrafficPolicy = gcc.Spec.Service.ExternalTrafficPolicy
+	}
+
+	return service
+}
+
+// loadBalancerIP returns the first IP address requested in the spec.addresses of the Gateway.
+func loadBalancerIP(gateway gwv1beta1.Gateway) string {
+	for _, address := range gateway.Spec.Addresses {
+		if address.Type == nil || *address.Type == gwv1beta1.IPAddressType {
+			return address.Value
+		}
+	}
+	return ""
 }
 
 // mergeService is used to keep annotations and ports from the `from` Service
@@ -112,6 +138,10 @@ func mergeService(from, to *corev1.Service) *corev1.Service {
 
 	to.Annotations = from.Annotations
 	to.Spec.Ports = from.Spec.Ports
+	to.Spec.LoadBalancerIP = from.Spec.LoadBalancerIP
+	if from.Spec.ExternalTrafficPolicy != "" {
+		to.Spec.ExternalTrafficPolicy = from.Spec.ExternalTrafficPolicy
+	}
 
 	return to
 }
@@ -123,6 +153,13 @@ func areServicesEqual(a, b *corev1.Service) bool {
 	if len(b.Spec.Ports) != len(a.Spec.Ports) {
 		return false
 	}
+	if a.Spec.LoadBalancerIP != b.Spec.LoadBalancerIP {
+		return false
+	}
+	// Kubernetes defaults the external traffic policy, so it's only compared if it's set.
+	if a.Spec.ExternalTrafficPolicy != "" && a.Spec.ExternalTrafficPolicy != b.Spec.ExternalTrafficPolicy {
+		return false
+	}
 
 	for i, port := range a.Spec.Ports {
 		otherPort := b.Spec.Ports[i]
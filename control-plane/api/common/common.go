@@ -29,4 +29,11 @@ const (
 	MigrateEntryKey  string = "consul.hashicorp.com/migrate-entry"
 	MigrateEntryTrue string = "true"
 	SourceValue      string = "kubernetes"
+
+	// DeletionPolicyKey is the annotation that sets whether the config entry is deleted from Consul when its
+	// custom resource is deleted. DeletionPolicyAbandon leaves the config entry in Consul, e.g. to migrate it
+	// to another cluster.
+	DeletionPolicyKey     string = "consul.hashicorp.com/deletion-policy"
+	DeletionPolicyDelete  string = "delete"
+	DeletionPolicyAbandon string = "abandon"
 )
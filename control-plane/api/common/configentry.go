@@ -99,4 +99,9 @@ type ConsulMeta struct {
 	// service in the k8s `staging` namespace will be registered into the
 	// `k8s-staging` Consul namespace.
 	Prefix string
+
+	// FIPSEnabled indicates that consul-k8s and Consul are running in FIPS
+	// 140-2 mode, so config entries may only use FIPS approved TLS versions
+	// and cipher suites.
+	FIPSEnabled bool
 }
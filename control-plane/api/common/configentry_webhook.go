@@ -61,6 +61,10 @@ func ValidateConfigEntry(
 			}
 		}
 	}
+	if policy, ok := cfgEntry.GetObjectMeta().Annotations[DeletionPolicyKey]; ok && policy != DeletionPolicyDelete && policy != DeletionPolicyAbandon {
+		return admission.Errored(http.StatusBadRequest,
+			fmt.Errorf("%s annotation must be %q or %q, got %q", DeletionPolicyKey, DeletionPolicyDelete, DeletionPolicyAbandon, policy))
+	}
 	if err := cfgEntry.Validate(consulMeta); err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
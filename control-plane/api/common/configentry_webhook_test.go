@@ -95,6 +95,25 @@ func TestValidateConfigEntry(t *testing.T) {
 			nsMirroring:      true,
 			expAllow:         true,
 		},
+		"abandon deletion policy": {
+			newResource: &mockConfigEntry{
+				MockName:        "foo",
+				MockNamespace:   otherNS,
+				MockAnnotations: map[string]string{DeletionPolicyKey: DeletionPolicyAbandon},
+				Valid:           true,
+			},
+			expAllow: true,
+		},
+		"invalid deletion policy": {
+			newResource: &mockConfigEntry{
+				MockName:        "foo",
+				MockNamespace:   otherNS,
+				MockAnnotations: map[string]string{DeletionPolicyKey: "retain"},
+				Valid:           true,
+			},
+			expAllow:      false,
+			expErrMessage: `consul.hashicorp.com/deletion-policy annotation must be "delete" or "abandon", got "retain"`,
+		},
 	}
 	for name, c := range cases {
 		t.Run(name, func(t *testing.T) {
@@ -160,9 +179,10 @@ func (in *mockConfigEntryLister) List(_ context.Context) ([]ConfigEntryResource,
 }
 
 type mockConfigEntry struct {
-	MockName      string
-	MockNamespace string
-	Valid         bool
+	MockName        string
+	MockNamespace   string
+	MockAnnotations map[string]string
+	Valid           bool
 }
 
 func (in *mockConfigEntry) GetNamespace() string {
@@ -274,7 +294,7 @@ func (in *mockConfigEntry) ConsulMirroringNS() string {
 }
 
 func (in *mockConfigEntry) GetObjectMeta() metav1.ObjectMeta {
-	return metav1.ObjectMeta{}
+	return metav1.ObjectMeta{Annotations: in.MockAnnotations}
 }
 
 func (in *mockConfigEntry) GetObjectKind() schema.ObjectKind {
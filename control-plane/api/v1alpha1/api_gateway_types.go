@@ -42,6 +42,9 @@ type GatewayClassConfigSpec struct {
 	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
 	ServiceType *corev1.ServiceType `json:"serviceType,omitempty"`
 
+	// Service defines additional settings of the Service created for each gateway.
+	Service ServiceSpec `json:"service,omitempty"`
+
 	// NodeSelector is a selector which must be true for the pod to fit on a node.
 	// Selector which must match a node's labels for the pod to be scheduled on that node.
 	// More info: https://kubernetes.io/docs/concepts/configuration/assign-pod-node/
@@ -51,6 +54,22 @@ type GatewayClassConfigSpec struct {
 	// More Info: https://kubernetes.io/docs/concepts/scheduling-eviction/taint-and-toleration/
 	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
 
+	// TopologySpreadConstraints control how gateway pods are spread across failure domains
+	// such as zones. Constraints without a label selector select the pods of the same gateway.
+	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/topology-spread-constraints/
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// Affinity is the scheduling affinity of gateway pods. It replaces the default
+	// preferred pod anti-affinity that spreads the pods of a gateway across nodes.
+	// More info: https://kubernetes.io/docs/concepts/scheduling-eviction/assign-pod-node/#affinity-and-anti-affinity
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// PriorityClassName is the name of the PriorityClass of gateway pods.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
 	// Deployment defines the deployment configuration for the gateway.
 	DeploymentSpec DeploymentSpec `json:"deployment,omitempty"`
 
@@ -79,6 +98,44 @@ type DeploymentSpec struct {
 	// +kubebuilder:validation:Minimum=1
 	// Minimum allowed number of gateway instances
 	MinInstances *int32 `json:"minInstances,omitempty"`
+	// Autoscaling configures a HorizontalPodAutoscaler that scales each gateway between
+	// MinInstances and MaxInstances based on the metrics of its Envoy proxies.
+	Autoscaling *AutoscalingSpec `json:"autoscaling,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+
+// AutoscalingSpec defines the Envoy metric targets that gateway instances are scaled on.
+// The metrics are read from the custom metrics API, so an adapter such as prometheus-adapter
+// must serve the gateway pods' Envoy metrics. At least one target must be set.
+type AutoscalingSpec struct {
+	// +kubebuilder:validation:Minimum=1
+	// Average number of active downstream connections per gateway instance,
+	// read from the envoy_http_downstream_cx_active metric.
+	TargetDownstreamConnections *int32 `json:"targetDownstreamConnections,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// Average number of downstream requests per second per gateway instance,
+	// read from the envoy_http_downstream_rq metric.
+	TargetRequestsPerSecond *int32 `json:"targetRequestsPerSecond,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+
+// ServiceSpec defines settings of the gateway Service that are passed through to its spec.
+// The load balancer IP of a gateway is set from the first IPAddress in its spec.addresses.
+type ServiceSpec struct {
+	// Annotations are added to the gateway Service, e.g. to configure the cloud provider's load balancer.
+	// Annotations that are copied from the Gateway take precedence.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// LoadBalancerClass is the class of the load balancer implementation of LoadBalancer Services.
+	// Kubernetes doesn't allow it to be changed once the Service is created.
+	LoadBalancerClass *string `json:"loadBalancerClass,omitempty"`
+
+	// +kubebuilder:validation:Enum=Cluster;Local
+	// ExternalTrafficPolicy describes how nodes distribute the external traffic of LoadBalancer
+	// and NodePort Services.
+	ExternalTrafficPolicy corev1.ServiceExternalTrafficPolicyType `json:"externalTrafficPolicy,omitempty"`
 }
 
 //+kubebuilder:object:generate=true
@@ -0,0 +1,65 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package v1alpha1
+
+import "sigs.k8s.io/controller-runtime/pkg/conversion"
+
+// v1alpha1 is the conversion hub for the config entry CRDs. When a new API
+// version is added, its types implement conversion.Convertible by converting
+// to and from these types, and the conversion webhook served by the
+// connect-injector at /convert translates objects between versions.
+var (
+	_ conversion.Hub = &ServiceDefaults{}
+	_ conversion.Hub = &ServiceResolver{}
+	_ conversion.Hub = &ProxyDefaults{}
+	_ conversion.Hub = &Mesh{}
+	_ conversion.Hub = &ExportedServices{}
+	_ conversion.Hub = &ServiceRouter{}
+	_ conversion.Hub = &ServiceSplitter{}
+	_ conversion.Hub = &ServiceIntentions{}
+	_ conversion.Hub = &IngressGateway{}
+	_ conversion.Hub = &TerminatingGateway{}
+	_ conversion.Hub = &SamenessGroup{}
+	_ conversion.Hub = &JWTProvider{}
+	_ conversion.Hub = &ControlPlaneRequestLimit{}
+)
+
+// Hub marks this type as a conversion hub.
+func (*ServiceDefaults) Hub() {}
+
+// Hub marks this type as a conversion hub.
+func (*ServiceResolver) Hub() {}
+
+// Hub marks this type as a conversion hub.
+func (*ProxyDefaults) Hub() {}
+
+// Hub marks this type as a conversion hub.
+func (*Mesh) Hub() {}
+
+// Hub marks this type as a conversion hub.
+func (*ExportedServices) Hub() {}
+
+// Hub marks this type as a conversion hub.
+func (*ServiceRouter) Hub() {}
+
+// Hub marks this type as a conversion hub.
+func (*ServiceSplitter) Hub() {}
+
+// Hub marks this type as a conversion hub.
+func (*ServiceIntentions) Hub() {}
+
+// Hub marks this type as a conversion hub.
+func (*IngressGateway) Hub() {}
+
+// Hub marks this type as a conversion hub.
+func (*TerminatingGateway) Hub() {}
+
+// Hub marks this type as a conversion hub.
+func (*SamenessGroup) Hub() {}
+
+// Hub marks this type as a conversion hub.
+func (*JWTProvider) Hub() {}
+
+// Hub marks this type as a conversion hub.
+func (*ControlPlaneRequestLimit) Hub() {}
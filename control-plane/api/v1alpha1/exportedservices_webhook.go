@@ -7,10 +7,15 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/quota"
 	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
@@ -22,6 +27,9 @@ type ExportedServicesWebhook struct {
 	Logger     logr.Logger
 	decoder    *admission.Decoder
 	ConsulMeta common.ConsulMeta
+	// QuotaPolicy limits the number of exported services of each namespace. Quotas aren't
+	// enforced if it's nil.
+	QuotaPolicy *quota.Policy
 }
 
 // NOTE: The path value in the below line is the path to the webhook.
@@ -59,9 +67,63 @@ func (v *ExportedServicesWebhook) Handle(ctx context.Context, req admission.Requ
 		return admission.Errored(http.StatusBadRequest, err)
 	}
 
+	var prevExports ExportedServices
+	if req.Operation == admissionv1.Update {
+		if err := v.decoder.DecodeRaw(req.OldObject, &prevExports); err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+	}
+	if err := v.checkQuota(ctx, prevExports, exports); err != nil {
+		return admission.Errored(http.StatusForbidden, err)
+	}
+
 	return admission.Allowed(fmt.Sprintf("valid %s request", exports.KubeKind()))
 }
 
+// checkQuota returns an error if a Consul namespace would have more exported services than
+// its quota allows. With namespace mirroring, the quota of a Consul namespace is the quota
+// of the Kubernetes namespace that it mirrors. Otherwise, Kubernetes namespaces don't map to
+// Consul namespaces and each Consul namespace has the default quota.
+func (v *ExportedServicesWebhook) checkQuota(ctx context.Context, prevExports, exports ExportedServices) error {
+	if v.QuotaPolicy == nil {
+		return nil
+	}
+	previous := exportedServicesPerNamespace(prevExports)
+	for consulNS, count := range exportedServicesPerNamespace(exports) {
+		var ns *corev1.Namespace
+		if v.ConsulMeta.NamespacesEnabled && v.ConsulMeta.Mirroring {
+			ns = &corev1.Namespace{}
+			err := v.Client.Get(ctx, types.NamespacedName{Name: strings.TrimPrefix(consulNS, v.ConsulMeta.Prefix)}, ns)
+			if k8serrors.IsNotFound(err) {
+				ns = nil
+			} else if err != nil {
+				return err
+			}
+		}
+		limits, err := v.QuotaPolicy.Limits(ns)
+		if err != nil {
+			return err
+		}
+		if err := quota.Check(consulNS, "exported services", limits.MaxExportedServices, previous[consulNS], count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportedServicesPerNamespace returns the number of exported services in each Consul namespace.
+func exportedServicesPerNamespace(exports ExportedServices) map[string]int {
+	counts := make(map[string]int)
+	for _, svc := range exports.Spec.Services {
+		ns := svc.Namespace
+		if ns == "" {
+			ns = "default"
+		}
+		counts[ns]++
+	}
+	return counts
+}
+
 func (v *ExportedServicesWebhook) InjectDecoder(d *admission.Decoder) error {
 	v.decoder = d
 	return nil
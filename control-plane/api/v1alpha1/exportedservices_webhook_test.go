@@ -10,8 +10,10 @@ import (
 
 	logrtest "github.com/go-logr/logr/testr"
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/quota"
 	"github.com/stretchr/testify/require"
 	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -202,3 +204,86 @@ func TestValidateExportedServices(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateExportedServices_Quota(t *testing.T) {
+	exports := func(services ...ExportedService) *ExportedServices {
+		return &ExportedServices{
+			ObjectMeta: metav1.ObjectMeta{Name: "default"},
+			Spec:       ExportedServicesSpec{Services: services},
+		}
+	}
+	exported := func(name, namespace string) ExportedService {
+		return ExportedService{Name: name, Namespace: namespace, Consumers: []ServiceConsumer{{Peer: "other"}}}
+	}
+
+	cases := map[string]struct {
+		oldResource   *ExportedServices
+		newResource   *ExportedServices
+		expAllow      bool
+		expErrMessage string
+	}{
+		"within the quota": {
+			newResource: exports(exported("web", "k8s-team-a"), exported("api", "k8s-team-b"), exported("db", "k8s-team-b")),
+			expAllow:    true,
+		},
+		"exceeds the quota of the mirrored namespace": {
+			newResource:   exports(exported("web", "k8s-team-a"), exported("api", "k8s-team-a")),
+			expAllow:      false,
+			expErrMessage: "namespace k8s-team-a would have 2 exported services, which exceeds its quota of 1",
+		},
+		"namespace that was already over the quota": {
+			oldResource: exports(exported("web", "k8s-team-a"), exported("api", "k8s-team-a"), exported("db", "k8s-team-a")),
+			newResource: exports(exported("web", "k8s-team-a"), exported("api", "k8s-team-a")),
+			expAllow:    true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			marshalledRequestObject, err := json.Marshal(c.newResource)
+			require.NoError(t, err)
+			s := runtime.NewScheme()
+			s.AddKnownTypes(GroupVersion, &ExportedServices{}, &ExportedServicesList{})
+			require.NoError(t, corev1.AddToScheme(s))
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "team-a",
+				Annotations: map[string]string{quota.AnnotationMaxExportedServices: "1"},
+			}}
+			client := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(namespace).Build()
+			decoder, err := admission.NewDecoder(s)
+			require.NoError(t, err)
+
+			validator := &ExportedServicesWebhook{
+				Client:  client,
+				Logger:  logrtest.New(t),
+				decoder: decoder,
+				ConsulMeta: common.ConsulMeta{
+					NamespacesEnabled: true,
+					Mirroring:         true,
+					Prefix:            "k8s-",
+				},
+				QuotaPolicy: &quota.Policy{},
+			}
+			req := admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Name:      c.newResource.KubernetesName(),
+					Namespace: "consul",
+					Operation: admissionv1.Create,
+					Object:    runtime.RawExtension{Raw: marshalledRequestObject},
+				},
+			}
+			if c.oldResource != nil {
+				marshalledOldObject, err := json.Marshal(c.oldResource)
+				require.NoError(t, err)
+				req.Operation = admissionv1.Update
+				req.OldObject = runtime.RawExtension{Raw: marshalledOldObject}
+			}
+			response := validator.Handle(ctx, req)
+
+			require.Equal(t, c.expAllow, response.Allowed, response.AdmissionResponse.Result)
+			if c.expErrMessage != "" {
+				require.Equal(t, c.expErrMessage, response.AdmissionResponse.Result.Message)
+			}
+		})
+	}
+}
@@ -274,7 +274,7 @@ func (in *IngressGateway) Validate(consulMeta common.ConsulMeta) error {
 	var errs field.ErrorList
 	path := field.NewPath("spec")
 
-	errs = append(errs, in.Spec.TLS.validate(path.Child("tls"))...)
+	errs = append(errs, in.Spec.TLS.validate(path.Child("tls"), consulMeta.FIPSEnabled)...)
 
 	for i, v := range in.Spec.Listeners {
 		errs = append(errs, v.validate(path.Child("listeners").Index(i), consulMeta)...)
@@ -322,7 +322,7 @@ func (in *GatewayTLSConfig) toConsul() *capi.GatewayTLSConfig {
 	}
 }
 
-func (in *GatewayTLSConfig) validate(path *field.Path) field.ErrorList {
+func (in *GatewayTLSConfig) validate(path *field.Path, fipsEnabled bool) field.ErrorList {
 	if in == nil {
 		return nil
 	}
@@ -335,6 +335,9 @@ func (in *GatewayTLSConfig) validate(path *field.Path) field.ErrorList {
 	if !sliceContains(versions, in.TLSMinVersion) {
 		errs = append(errs, field.Invalid(path.Child("tlsMinVersion"), in.TLSMinVersion, notInSliceMessage(versions)))
 	}
+	if fipsEnabled {
+		errs = append(errs, validateFIPSTLS(path, in.TLSMinVersion, in.TLSMaxVersion, in.CipherSuites)...)
+	}
 	return errs
 }
 
@@ -402,7 +405,7 @@ func (in IngressListener) validate(path *field.Path, consulMeta common.ConsulMet
 			fmt.Sprintf("if protocol is \"tcp\", only a single service is allowed, found %d", len(in.Services))))
 	}
 
-	errs = append(errs, in.TLS.validate(path.Child("tls"))...)
+	errs = append(errs, in.TLS.validate(path.Child("tls"), consulMeta.FIPSEnabled)...)
 
 	for i, svc := range in.Services {
 		if svc.Name == wildcardServiceName && in.Protocol != "http" {
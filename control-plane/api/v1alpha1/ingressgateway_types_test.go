@@ -534,6 +534,7 @@ func TestIngressGateway_Validate(t *testing.T) {
 		input             *IngressGateway
 		namespacesEnabled bool
 		partitionEnabled  bool
+		fipsEnabled       bool
 		expectedErrMsgs   []string
 	}{
 		"tls.minTLSVersion invalid": {
@@ -552,6 +553,33 @@ func TestIngressGateway_Validate(t *testing.T) {
 				`spec.tls.tlsMinVersion: Invalid value: "foo": must be one of "TLS_AUTO", "TLSv1_0", "TLSv1_1", "TLSv1_2", "TLSv1_3", ""`,
 			},
 		},
+		"tls not allowed in FIPS mode": {
+			input: &IngressGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: IngressGatewaySpec{
+					TLS: GatewayTLSConfig{
+						TLSMinVersion: "TLSv1_0",
+						CipherSuites:  []string{"TLS_RSA_WITH_AES_128_CBC_SHA"},
+					},
+					Listeners: []IngressListener{
+						{
+							Protocol: "tcp",
+							TLS: &GatewayTLSConfig{
+								TLSMaxVersion: "TLSv1_1",
+							},
+						},
+					},
+				},
+			},
+			fipsEnabled: true,
+			expectedErrMsgs: []string{
+				`spec.tls.tlsMinVersion: Invalid value: "TLSv1_0": must be at least TLSv1_2 in FIPS mode`,
+				`spec.tls.cipherSuites[0]: Invalid value: "TLS_RSA_WITH_AES_128_CBC_SHA": must be one of`,
+				`spec.listeners[0].tls.tlsMaxVersion: Invalid value: "TLSv1_1": must be at least TLSv1_2 in FIPS mode`,
+			},
+		},
 		"tls.maxTLSVersion invalid": {
 			input: &IngressGateway{
 				ObjectMeta: metav1.ObjectMeta{
@@ -993,7 +1021,7 @@ func TestIngressGateway_Validate(t *testing.T) {
 
 	for name, testCase := range cases {
 		t.Run(name, func(t *testing.T) {
-			err := testCase.input.Validate(common.ConsulMeta{NamespacesEnabled: testCase.namespacesEnabled, PartitionsEnabled: testCase.partitionEnabled})
+			err := testCase.input.Validate(common.ConsulMeta{NamespacesEnabled: testCase.namespacesEnabled, PartitionsEnabled: testCase.partitionEnabled, FIPSEnabled: testCase.fipsEnabled})
 			if len(testCase.expectedErrMsgs) != 0 {
 				require.Error(t, err)
 				for _, s := range testCase.expectedErrMsgs {
@@ -0,0 +1,132 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package v1alpha1
+
+import (
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	IntentionRequestKubeKind = "intentionrequest"
+
+	// AnnotationIntentionRequestSources is set on the ServiceIntentions resources that IntentionRequests
+	// are merged into. Its value is the comma-separated keys of the sources that were added from
+	// IntentionRequests, so that they can be updated without changing the sources written by users.
+	AnnotationIntentionRequestSources = "consul.hashicorp.com/intention-request-sources"
+)
+
+func init() {
+	SchemeBuilder.Register(&IntentionRequest{}, &IntentionRequestList{})
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// IntentionRequest is the Schema for the intentionrequests API. It lets the owners of a namespace
+// grant sources access to the services in their namespace without access to ServiceIntentions,
+// whose destination can be any service. Its sources are merged into the ServiceIntentions of
+// the destination.
+// +kubebuilder:printcolumn:name="Synced",type="string",JSONPath=".status.conditions[?(@.type==\"Synced\")].status",description="The sync status of the resource with Consul"
+// +kubebuilder:printcolumn:name="Last Synced",type="date",JSONPath=".status.lastSyncedTime",description="The last successful synced time of the resource with Consul"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="The age of the resource"
+// +kubebuilder:resource:shortName="intention-request"
+type IntentionRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   IntentionRequestSpec `json:"spec,omitempty"`
+	Status `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// IntentionRequestList contains a list of IntentionRequest.
+type IntentionRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IntentionRequest `json:"items"`
+}
+
+// IntentionRequestSpec defines the desired state of IntentionRequest.
+type IntentionRequestSpec struct {
+	// Destination is the name of the service that the sources are granted access to. It must be
+	// the name of a Kubernetes service in the namespace of the IntentionRequest.
+	Destination string `json:"destination,omitempty"`
+	// Sources is the list of intention sources and the authorization granted to those sources.
+	Sources SourceIntentions `json:"sources,omitempty"`
+}
+
+func (in *IntentionRequest) KubeKind() string {
+	return IntentionRequestKubeKind
+}
+
+func (in *IntentionRequest) KubernetesName() string {
+	return in.ObjectMeta.Name
+}
+
+func (in *IntentionRequest) Validate(consulMeta common.ConsulMeta) error {
+	var errs field.ErrorList
+	path := field.NewPath("spec")
+
+	if in.Spec.Destination == "" {
+		errs = append(errs, field.Required(path.Child("destination"), "destination is required"))
+	} else if strings.Contains(in.Spec.Destination, WildcardSpecifier) {
+		errs = append(errs, field.Invalid(path.Child("destination"), in.Spec.Destination, "destination cannot use or contain wildcard '*'"))
+	}
+	if len(in.Spec.Sources) == 0 {
+		errs = append(errs, field.Required(path.Child("sources"), `at least one source must be specified`))
+	}
+	errs = append(errs, in.Spec.Sources.validate(path.Child("sources"), consulMeta.PartitionsEnabled)...)
+	if !consulMeta.NamespacesEnabled {
+		for i, source := range in.Spec.Sources {
+			if source.Namespace != "" {
+				errs = append(errs, field.Invalid(path.Child("sources").Index(i).Child("namespace"), source.Namespace, `Consul Enterprise namespaces must be enabled to set source.namespace`))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(
+			schema.GroupKind{Group: ConsulHashicorpGroup, Kind: IntentionRequestKubeKind},
+			in.KubernetesName(), errs)
+	}
+	return nil
+}
+
+// SyncedCondition returns the status, reason and message of the Synced condition.
+func (in *IntentionRequest) SyncedCondition() (status corev1.ConditionStatus, reason, message string) {
+	cond := in.Status.GetCondition(ConditionSynced)
+	if cond == nil {
+		return corev1.ConditionUnknown, "", ""
+	}
+	return cond.Status, cond.Reason, cond.Message
+}
+
+func (in *IntentionRequest) SetSyncedCondition(status corev1.ConditionStatus, reason string, message string) {
+	in.Status.Conditions = Conditions{
+		{
+			Type:               ConditionSynced,
+			Status:             status,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		},
+	}
+	if status == corev1.ConditionTrue {
+		now := metav1.Now()
+		in.Status.LastSyncedTime = &now
+	}
+}
+
+// IntentionSourceKey returns the key that identifies the source in the sources of a ServiceIntentions.
+func IntentionSourceKey(source *SourceIntention) string {
+	return strings.Join([]string{source.Peer, source.SamenessGroup, source.Partition, source.Namespace, source.Name}, "/")
+}
@@ -0,0 +1,118 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIntentionRequest_Validate(t *testing.T) {
+	cases := map[string]struct {
+		request          *IntentionRequest
+		namespaceEnabled bool
+		expectedErrMsgs  []string
+	}{
+		"valid": {
+			request: &IntentionRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "frontend"},
+				Spec: IntentionRequestSpec{
+					Destination: "api",
+					Sources: SourceIntentions{
+						{Name: "frontend", Action: "allow"},
+						{Name: "batch", Action: "deny"},
+					},
+				},
+			},
+		},
+		"valid with source namespace": {
+			request: &IntentionRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "frontend"},
+				Spec: IntentionRequestSpec{
+					Destination: "api",
+					Sources:     SourceIntentions{{Name: "frontend", Namespace: "web", Action: "allow"}},
+				},
+			},
+			namespaceEnabled: true,
+		},
+		"no destination": {
+			request: &IntentionRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "frontend"},
+				Spec: IntentionRequestSpec{
+					Sources: SourceIntentions{{Name: "frontend", Action: "allow"}},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.destination: Required value: destination is required`,
+			},
+		},
+		"wildcard destination": {
+			request: &IntentionRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "frontend"},
+				Spec: IntentionRequestSpec{
+					Destination: "*",
+					Sources:     SourceIntentions{{Name: "frontend", Action: "allow"}},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.destination: Invalid value: "*": destination cannot use or contain wildcard '*'`,
+			},
+		},
+		"no sources": {
+			request: &IntentionRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "frontend"},
+				Spec:       IntentionRequestSpec{Destination: "api"},
+			},
+			expectedErrMsgs: []string{
+				`spec.sources: Required value: at least one source must be specified`,
+			},
+		},
+		"invalid action": {
+			request: &IntentionRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "frontend"},
+				Spec: IntentionRequestSpec{
+					Destination: "api",
+					Sources:     SourceIntentions{{Name: "frontend", Action: "maybe"}},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.sources[0].action: Invalid value: "maybe"`,
+			},
+		},
+		"source namespace without namespaces": {
+			request: &IntentionRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "frontend"},
+				Spec: IntentionRequestSpec{
+					Destination: "api",
+					Sources:     SourceIntentions{{Name: "frontend", Namespace: "web", Action: "allow"}},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.sources[0].namespace: Invalid value: "web": Consul Enterprise namespaces must be enabled to set source.namespace`,
+			},
+		},
+	}
+
+	for name, testCase := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := testCase.request.Validate(common.ConsulMeta{NamespacesEnabled: testCase.namespaceEnabled})
+			if len(testCase.expectedErrMsgs) != 0 {
+				require.Error(t, err)
+				for _, s := range testCase.expectedErrMsgs {
+					require.Contains(t, err.Error(), s)
+				}
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestIntentionSourceKey(t *testing.T) {
+	require.Equal(t, "////web", IntentionSourceKey(&SourceIntention{Name: "web"}))
+	require.Equal(t, "peer1///ns/web", IntentionSourceKey(&SourceIntention{Name: "web", Namespace: "ns", Peer: "peer1"}))
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
@@ -369,9 +370,16 @@ func (l *LocalJWKS) validate(path *field.Path) field.ErrorList {
 		return append(errs, field.Invalid(path, string(asJSON), "Exactly one of 'jwks' or 'filename' is required"))
 	}
 	if l.JWKS != "" {
-		if _, err := base64.StdEncoding.DecodeString(l.JWKS); err != nil {
+		decoded, err := base64.StdEncoding.DecodeString(l.JWKS)
+		if err != nil {
 			return append(errs, field.Invalid(path.Child("jwks"), l.JWKS, "JWKS must be a valid base64-encoded string"))
 		}
+		var keySet struct {
+			Keys []json.RawMessage `json:"keys"`
+		}
+		if err := json.Unmarshal(decoded, &keySet); err != nil || len(keySet.Keys) == 0 {
+			return append(errs, field.Invalid(path.Child("jwks"), l.JWKS, "JWKS must be a JSON Web Key Set with at least one key"))
+		}
 	}
 	return errs
 }
@@ -427,8 +435,10 @@ func (r *RemoteJWKS) validate(path *field.Path) field.ErrorList {
 
 	if r.URI == "" {
 		errs = append(errs, field.Invalid(path.Child("uri"), r.URI, "remote JWKS URI is required"))
-	} else if _, err := url.ParseRequestURI(r.URI); err != nil {
+	} else if u, err := url.ParseRequestURI(r.URI); err != nil {
 		errs = append(errs, field.Invalid(path.Child("uri"), r.URI, "remote JWKS URI is invalid"))
+	} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, field.Invalid(path.Child("uri"), r.URI, "remote JWKS URI must be an absolute http or https URI"))
 	}
 
 	errs = append(errs, r.RetryPolicy.validate(path.Child("retryPolicy"))...)
@@ -636,6 +646,7 @@ func (j *JWTProvider) Validate(consulMeta common.ConsulMeta) error {
 	path := field.NewPath("spec")
 
 	errs = append(errs, j.Spec.JSONWebKeySet.validate(path.Child("jsonWebKeySet"))...)
+	errs = append(errs, validateIssuer(j.Spec.Issuer, path.Child("issuer"))...)
 	errs = append(errs, JWTLocations(j.Spec.Locations).validate(path.Child("locations"))...)
 	errs = append(errs, j.Spec.Forwarding.validate(path.Child("forwarding"))...)
 	if len(errs) > 0 {
@@ -646,6 +657,24 @@ func (j *JWTProvider) Validate(consulMeta common.ConsulMeta) error {
 	return nil
 }
 
+// validateIssuer checks that the issuer is a StringOrURI as the "iss" claim is
+// defined in RFC 7519, i.e. that values that contain a colon are URIs.
+func validateIssuer(issuer string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if issuer == "" {
+		return errs
+	}
+	if strings.TrimSpace(issuer) != issuer {
+		return append(errs, field.Invalid(path, issuer, "issuer must not have leading or trailing whitespace"))
+	}
+	if strings.Contains(issuer, ":") {
+		if u, err := url.Parse(issuer); err != nil || u.Scheme == "" {
+			return append(errs, field.Invalid(path, issuer, "issuer containing ':' must be a valid URI"))
+		}
+	}
+	return errs
+}
+
 // DefaultNamespaceFields sets Consul namespace fields on the config entry
 // spec to their default values if namespaces are enabled.
 func (j *JWTProvider) DefaultNamespaceFields(_ common.ConsulMeta) {}
@@ -467,6 +467,93 @@ func TestJWTProvider_Validate(t *testing.T) {
 			},
 		},
 
+		"valid - local jwks with key set": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-jwks-key-set",
+				},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Local: &LocalJWKS{
+							JWKS: "eyJrZXlzIjpbeyJrdHkiOiJvY3QiLCJrIjoiYzJWamNtVjAifV19",
+						},
+					},
+					Issuer: "https://issuer.example.com",
+				},
+			},
+			expectedErrMsgs: nil,
+		},
+
+		"invalid - local jwks without keys": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-jwks-no-keys",
+				},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Local: &LocalJWKS{
+							JWKS: "eyJrZXlzIjpbXX0=",
+						},
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`jwtprovider.consul.hashicorp.com "test-jwks-no-keys" is invalid: spec.jsonWebKeySet.local.jwks: Invalid value: "eyJrZXlzIjpbXX0=": JWKS must be a JSON Web Key Set with at least one key`,
+			},
+		},
+
+		"invalid - remote jwks uri is not http": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-jwks-scheme",
+				},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Remote: &RemoteJWKS{
+							URI: "/.well-known/jwks.json",
+						},
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`jwtprovider.consul.hashicorp.com "test-jwks-scheme" is invalid: spec.jsonWebKeySet.remote.uri: Invalid value: "/.well-known/jwks.json": remote JWKS URI must be an absolute http or https URI`,
+			},
+		},
+
+		"invalid - issuer with colon is not a URI": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-issuer-uri",
+				},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Local: &LocalJWKS{Filename: "jwks.txt"},
+					},
+					Issuer: ":issuer",
+				},
+			},
+			expectedErrMsgs: []string{
+				`jwtprovider.consul.hashicorp.com "test-issuer-uri" is invalid: spec.issuer: Invalid value: ":issuer": issuer containing ':' must be a valid URI`,
+			},
+		},
+
+		"invalid - issuer with whitespace": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-issuer-whitespace",
+				},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Local: &LocalJWKS{Filename: "jwks.txt"},
+					},
+					Issuer: "issuer ",
+				},
+			},
+			expectedErrMsgs: []string{
+				`jwtprovider.consul.hashicorp.com "test-issuer-whitespace" is invalid: spec.issuer: Invalid value: "issuer ": issuer must not have leading or trailing whitespace`,
+			},
+		},
+
 		"invalid - both local and remote jwks set": {
 			input: &JWTProvider{
 				ObjectMeta: metav1.ObjectMeta{
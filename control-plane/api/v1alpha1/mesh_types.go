@@ -217,7 +217,7 @@ func (in *Mesh) Validate(consulMeta common.ConsulMeta) error {
 	var errs field.ErrorList
 	path := field.NewPath("spec")
 
-	errs = append(errs, in.Spec.TLS.validate(path.Child("tls"))...)
+	errs = append(errs, in.Spec.TLS.validate(path.Child("tls"), consulMeta.FIPSEnabled)...)
 	errs = append(errs, in.Spec.Peering.validate(path.Child("peering"), consulMeta.PartitionsEnabled, consulMeta.Partition)...)
 
 	if len(errs) > 0 {
@@ -247,18 +247,18 @@ func (in *MeshHTTPConfig) toConsul() *capi.MeshHTTPConfig {
 	}
 }
 
-func (in *MeshTLSConfig) validate(path *field.Path) field.ErrorList {
+func (in *MeshTLSConfig) validate(path *field.Path, fipsEnabled bool) field.ErrorList {
 	if in == nil {
 		return nil
 	}
 
 	var errs field.ErrorList
-	errs = append(errs, in.Incoming.validate(path.Child("incoming"))...)
-	errs = append(errs, in.Outgoing.validate(path.Child("outgoing"))...)
+	errs = append(errs, in.Incoming.validate(path.Child("incoming"), fipsEnabled)...)
+	errs = append(errs, in.Outgoing.validate(path.Child("outgoing"), fipsEnabled)...)
 	return errs
 }
 
-func (in *MeshDirectionalTLSConfig) validate(path *field.Path) field.ErrorList {
+func (in *MeshDirectionalTLSConfig) validate(path *field.Path, fipsEnabled bool) field.ErrorList {
 	if in == nil {
 		return nil
 	}
@@ -272,6 +272,9 @@ func (in *MeshDirectionalTLSConfig) validate(path *field.Path) field.ErrorList {
 	if !sliceContains(versions, in.TLSMinVersion) {
 		errs = append(errs, field.Invalid(path.Child("tlsMinVersion"), in.TLSMinVersion, notInSliceMessage(versions)))
 	}
+	if fipsEnabled {
+		errs = append(errs, validateFIPSTLS(path, in.TLSMinVersion, in.TLSMaxVersion, in.CipherSuites)...)
+	}
 	return errs
 }
 
@@ -316,6 +316,50 @@ func TestMesh_Validate(t *testing.T) {
 				},
 			},
 		},
+		"tls versions and cipher suites not allowed in FIPS mode": {
+			input: &Mesh{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: MeshSpec{
+					TLS: &MeshTLSConfig{
+						Incoming: &MeshDirectionalTLSConfig{
+							TLSMinVersion: "TLSv1_1",
+							CipherSuites:  []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305"},
+						},
+						Outgoing: &MeshDirectionalTLSConfig{
+							TLSMaxVersion: "TLSv1_0",
+						},
+					},
+				},
+			},
+			consulMeta: common.ConsulMeta{
+				FIPSEnabled: true,
+			},
+			expectedErrMsgs: []string{
+				`spec.tls.incoming.tlsMinVersion: Invalid value: "TLSv1_1": must be at least TLSv1_2 in FIPS mode`,
+				`spec.tls.incoming.cipherSuites[1]: Invalid value: "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305": must be one of "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384" in FIPS mode`,
+				`spec.tls.outgoing.tlsMaxVersion: Invalid value: "TLSv1_0": must be at least TLSv1_2 in FIPS mode`,
+			},
+		},
+		"tls valid in FIPS mode": {
+			input: &Mesh{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: MeshSpec{
+					TLS: &MeshTLSConfig{
+						Incoming: &MeshDirectionalTLSConfig{
+							TLSMinVersion: "TLSv1_2",
+							CipherSuites:  []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"},
+						},
+					},
+				},
+			},
+			consulMeta: common.ConsulMeta{
+				FIPSEnabled: true,
+			},
+		},
 		"peering.peerThroughMeshGateways in invalid partition": {
 			input: &Mesh{
 				ObjectMeta: metav1.ObjectMeta{
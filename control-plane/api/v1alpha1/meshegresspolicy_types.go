@@ -0,0 +1,206 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package v1alpha1
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	MeshEgressPolicyKubeKind = "meshegresspolicy"
+
+	// LabelMeshEgressPolicy is the label added to the ServiceDefaults and Mesh resources created
+	// from a MeshEgressPolicy. Its value is the name of the MeshEgressPolicy.
+	LabelMeshEgressPolicy = "consul.hashicorp.com/mesh-egress-policy"
+
+	// AnnotationManagedEgressDestinations is set on TerminatingGateway resources to the services
+	// that were added to them from the destinations of MeshEgressPolicy resources.
+	AnnotationManagedEgressDestinations = "consul.hashicorp.com/managed-egress-destinations"
+
+	// DefaultEgressTerminatingGateway is the terminating gateway that destinations egress through
+	// if the policy doesn't set one. It's the name of the first gateway in the Helm chart.
+	DefaultEgressTerminatingGateway = "terminating-gateway"
+
+	// maxCIDRAddresses is the maximum number of addresses that a CIDR of a destination may contain.
+	maxCIDRAddresses = 256
+)
+
+func init() {
+	SchemeBuilder.Register(&MeshEgressPolicy{}, &MeshEgressPolicyList{})
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// MeshEgressPolicy is the Schema for the meshegresspolicies API. It allows injected pods to reach
+// a list of approved external destinations through a terminating gateway and denies all other
+// outbound traffic to destinations outside of the mesh.
+// +kubebuilder:printcolumn:name="Synced",type="string",JSONPath=".status.conditions[?(@.type==\"Synced\")].status",description="The sync status of the resource with Consul"
+// +kubebuilder:printcolumn:name="Last Synced",type="date",JSONPath=".status.lastSyncedTime",description="The last successful synced time of the resource with Consul"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="The age of the resource"
+// +kubebuilder:resource:shortName="mesh-egress-policy"
+type MeshEgressPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MeshEgressPolicySpec `json:"spec,omitempty"`
+	Status `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MeshEgressPolicyList contains a list of MeshEgressPolicy.
+type MeshEgressPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MeshEgressPolicy `json:"items"`
+}
+
+// MeshEgressPolicySpec defines the desired state of MeshEgressPolicy.
+type MeshEgressPolicySpec struct {
+	// TerminatingGateway is the name of the TerminatingGateway resource that traffic to the
+	// destinations egresses through. Defaults to "terminating-gateway".
+	TerminatingGateway string `json:"terminatingGateway,omitempty"`
+	// Destinations are the external destinations that injected pods are allowed to reach.
+	Destinations []MeshEgressDestination `json:"destinations,omitempty"`
+}
+
+// MeshEgressDestination is an external destination that injected pods are allowed to reach.
+type MeshEgressDestination struct {
+	// Name is the name of the Consul service that represents the destination. Upstreams need
+	// intentions to this service to reach the destination.
+	Name string `json:"name"`
+	// Addresses are the hostnames, IPs and CIDRs of the destination. CIDRs are expanded into
+	// the IPs that they contain and may contain at most 256 addresses.
+	Addresses []string `json:"addresses"`
+	// Port is the port of the destination.
+	Port uint32 `json:"port"`
+	// Protocol is the protocol of the destination. Defaults to "tcp".
+	Protocol string `json:"protocol,omitempty"`
+}
+
+func (in *MeshEgressPolicy) KubeKind() string {
+	return MeshEgressPolicyKubeKind
+}
+
+func (in *MeshEgressPolicy) KubernetesName() string {
+	return in.ObjectMeta.Name
+}
+
+// TerminatingGatewayName returns the name of the TerminatingGateway resource that traffic to the
+// destinations egresses through.
+func (in *MeshEgressPolicy) TerminatingGatewayName() string {
+	if in.Spec.TerminatingGateway == "" {
+		return DefaultEgressTerminatingGateway
+	}
+	return in.Spec.TerminatingGateway
+}
+
+// ServiceDefaultsSpec returns the spec of the ServiceDefaults created for the destination. The
+// spec must be valid.
+func (in *MeshEgressDestination) ServiceDefaultsSpec() ServiceDefaultsSpec {
+	protocol := in.Protocol
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	var addresses []string
+	for _, address := range in.Addresses {
+		if _, ipNet, err := net.ParseCIDR(address); err == nil {
+			addresses = append(addresses, cidrAddresses(ipNet)...)
+			continue
+		}
+		addresses = append(addresses, address)
+	}
+	return ServiceDefaultsSpec{
+		Protocol: protocol,
+		Destination: &ServiceDefaultsDestination{
+			Addresses: addresses,
+			Port:      in.Port,
+		},
+	}
+}
+
+func (in *MeshEgressPolicy) Validate() error {
+	var errs field.ErrorList
+	path := field.NewPath("spec").Child("destinations")
+
+	names := make(map[string]bool)
+	for i, destination := range in.Spec.Destinations {
+		destinationPath := path.Index(i)
+		numErrs := len(errs)
+		if destination.Name == "" {
+			errs = append(errs, field.Required(destinationPath.Child("name"), "name is required"))
+		} else if names[destination.Name] {
+			errs = append(errs, field.Duplicate(destinationPath.Child("name"), destination.Name))
+		}
+		names[destination.Name] = true
+
+		validProtocols := []string{"tcp", "http", "http2", "grpc"}
+		if destination.Protocol != "" && !sliceContains(validProtocols, destination.Protocol) {
+			errs = append(errs, field.Invalid(destinationPath.Child("protocol"), destination.Protocol, notInSliceMessage(validProtocols)))
+		}
+		for j, address := range destination.Addresses {
+			_, ipNet, err := net.ParseCIDR(address)
+			if err != nil {
+				continue
+			}
+			ones, bits := ipNet.Mask.Size()
+			if bits-ones > 8 {
+				errs = append(errs, field.Invalid(destinationPath.Child("addresses").Index(j), address,
+					fmt.Sprintf("CIDR must not contain more than %d addresses", maxCIDRAddresses)))
+			}
+		}
+		if len(errs) > numErrs {
+			continue
+		}
+		spec := destination.ServiceDefaultsSpec()
+		errs = append(errs, spec.Destination.validate(destinationPath)...)
+	}
+
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(
+			schema.GroupKind{Group: ConsulHashicorpGroup, Kind: MeshEgressPolicyKubeKind},
+			in.KubernetesName(), errs)
+	}
+	return nil
+}
+
+func (in *MeshEgressPolicy) SetSyncedCondition(status corev1.ConditionStatus, reason string, message string) {
+	in.Status.Conditions = Conditions{
+		{
+			Type:               ConditionSynced,
+			Status:             status,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		},
+	}
+	if status == corev1.ConditionTrue {
+		now := metav1.Now()
+		in.Status.LastSyncedTime = &now
+	}
+}
+
+// cidrAddresses returns the IPs in the CIDR.
+func cidrAddresses(ipNet *net.IPNet) []string {
+	ones, bits := ipNet.Mask.Size()
+	count := 1 << (bits - ones)
+	start := new(big.Int).SetBytes(ipNet.IP)
+	addresses := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		b := new(big.Int).Add(start, big.NewInt(int64(i))).Bytes()
+		ip := make(net.IP, len(ipNet.IP))
+		copy(ip[len(ip)-len(b):], b)
+		addresses = append(addresses, ip.String())
+	}
+	return addresses
+}
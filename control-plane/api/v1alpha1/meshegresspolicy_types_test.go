@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMeshEgressPolicy_Validate(t *testing.T) {
+	cases := map[string]struct {
+		destinations    []MeshEgressDestination
+		expectedErrMsgs []string
+	}{
+		"valid": {
+			destinations: []MeshEgressDestination{
+				{Name: "payments", Addresses: []string{"api.payments.example.com"}, Port: 443},
+				{Name: "partners", Addresses: []string{"10.0.0.1", "10.1.0.0/24"}, Port: 8080, Protocol: "http"},
+			},
+		},
+		"duplicate names": {
+			destinations: []MeshEgressDestination{
+				{Name: "payments", Addresses: []string{"api.payments.example.com"}, Port: 443},
+				{Name: "payments", Addresses: []string{"10.0.0.1"}, Port: 443},
+			},
+			expectedErrMsgs: []string{`spec.destinations[1].name: Duplicate value: "payments"`},
+		},
+		"missing name and port": {
+			destinations: []MeshEgressDestination{
+				{Addresses: []string{"api.payments.example.com"}},
+				{Name: "partners", Addresses: []string{"10.0.0.1"}},
+			},
+			expectedErrMsgs: []string{
+				`spec.destinations[0].name: Required value: name is required`,
+				`spec.destinations[1].port: Invalid value: 0x0: invalid port number`,
+			},
+		},
+		"invalid protocol": {
+			destinations: []MeshEgressDestination{
+				{Name: "payments", Addresses: []string{"api.payments.example.com"}, Port: 443, Protocol: "udp"},
+			},
+			expectedErrMsgs: []string{`spec.destinations[0].protocol: Invalid value: "udp": must be one of "tcp", "http", "http2", "grpc"`},
+		},
+		"CIDR too large": {
+			destinations: []MeshEgressDestination{
+				{Name: "partners", Addresses: []string{"10.0.0.0/23"}, Port: 443},
+			},
+			expectedErrMsgs: []string{`spec.destinations[0].addresses[0]: Invalid value: "10.0.0.0/23": CIDR must not contain more than 256 addresses`},
+		},
+		"invalid address": {
+			destinations: []MeshEgressDestination{
+				{Name: "payments", Addresses: []string{"*.example.com"}, Port: 443},
+			},
+			expectedErrMsgs: []string{`address *.example.com is not a valid IP or hostname`},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			policy := &MeshEgressPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "egress"},
+				Spec:       MeshEgressPolicySpec{Destinations: c.destinations},
+			}
+			err := policy.Validate()
+			if len(c.expectedErrMsgs) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, msg := range c.expectedErrMsgs {
+				require.Contains(t, err.Error(), msg)
+			}
+		})
+	}
+}
+
+func TestMeshEgressDestination_ServiceDefaultsSpec(t *testing.T) {
+	destination := MeshEgressDestination{
+		Name:      "partners",
+		Addresses: []string{"api.partners.example.com", "10.0.0.4/30", "2001:db8::/127"},
+		Port:      443,
+	}
+	require.Equal(t, ServiceDefaultsSpec{
+		Protocol: "tcp",
+		Destination: &ServiceDefaultsDestination{
+			Addresses: []string{
+				"api.partners.example.com",
+				"10.0.0.4", "10.0.0.5", "10.0.0.6", "10.0.0.7",
+				"2001:db8::", "2001:db8::1",
+			},
+			Port: 443,
+		},
+	}, destination.ServiceDefaultsSpec())
+}
+
+func TestMeshEgressPolicy_TerminatingGatewayName(t *testing.T) {
+	policy := &MeshEgressPolicy{}
+	require.Equal(t, "terminating-gateway", policy.TerminatingGatewayName())
+	policy.Spec.TerminatingGateway = "egress-gateway"
+	require.Equal(t, "egress-gateway", policy.TerminatingGatewayName())
+}
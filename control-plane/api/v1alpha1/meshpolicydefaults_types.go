@@ -0,0 +1,195 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	MeshPolicyDefaultsKubeKind = "meshpolicydefaults"
+
+	// LabelMeshPolicyDefaults is the label added to the ServiceDefaults and ServiceRouter
+	// resources created from a MeshPolicyDefaults. Its value is the name of the MeshPolicyDefaults.
+	LabelMeshPolicyDefaults = "consul.hashicorp.com/mesh-policy-defaults"
+)
+
+func init() {
+	SchemeBuilder.Register(&MeshPolicyDefaults{}, &MeshPolicyDefaultsList{})
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// MeshPolicyDefaults is the Schema for the meshpolicydefaults API. It sets default retries,
+// timeouts and circuit breaking for every mesh service in its namespace by creating a
+// ServiceDefaults and ServiceRouter resource for each of them.
+// +kubebuilder:printcolumn:name="Synced",type="string",JSONPath=".status.conditions[?(@.type==\"Synced\")].status",description="The sync status of the resource with Consul"
+// +kubebuilder:printcolumn:name="Last Synced",type="date",JSONPath=".status.lastSyncedTime",description="The last successful synced time of the resource with Consul"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="The age of the resource"
+// +kubebuilder:resource:shortName="mesh-policy-defaults"
+type MeshPolicyDefaults struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MeshPolicyDefaultsSpec `json:"spec,omitempty"`
+	Status `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MeshPolicyDefaultsList contains a list of MeshPolicyDefaults.
+type MeshPolicyDefaultsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MeshPolicyDefaults `json:"items"`
+}
+
+// MeshPolicyDefaultsSpec defines the desired state of MeshPolicyDefaults.
+type MeshPolicyDefaultsSpec struct {
+	// Protocol sets the protocol of each service. It must be "http", "http2" or "grpc"
+	// when RequestTimeout or Retries are set since they are only supported for L7 protocols.
+	Protocol string `json:"protocol,omitempty"`
+	// RequestTimeout is the total amount of time permitted for the entire
+	// downstream request (and retries) to be processed.
+	RequestTimeout metav1.Duration `json:"requestTimeout,omitempty"`
+	// Retries configures when requests to each service are retried.
+	Retries *MeshPolicyRetries `json:"retries,omitempty"`
+	// UpstreamLimits are the connection and request limits that each service's
+	// proxy applies to all of its upstreams.
+	UpstreamLimits *UpstreamLimits `json:"upstreamLimits,omitempty"`
+	// PassiveHealthCheck configures how each service's proxy ejects unhealthy
+	// upstream instances from the load balancing pool, i.e. circuit breaking.
+	PassiveHealthCheck *PassiveHealthCheck `json:"passiveHealthCheck,omitempty"`
+}
+
+// MeshPolicyRetries configures when requests are retried.
+type MeshPolicyRetries struct {
+	// NumRetries is the number of times to retry the request when a retryable result occurs.
+	NumRetries uint32 `json:"numRetries,omitempty"`
+	// RetryOnConnectFailure allows for connection failure errors to trigger a retry.
+	RetryOnConnectFailure bool `json:"retryOnConnectFailure,omitempty"`
+	// RetryOnStatusCodes is a flat list of http response status codes that are eligible for retry.
+	RetryOnStatusCodes []uint32 `json:"retryOnStatusCodes,omitempty"`
+}
+
+func (in *MeshPolicyDefaults) KubeKind() string {
+	return MeshPolicyDefaultsKubeKind
+}
+
+func (in *MeshPolicyDefaults) KubernetesName() string {
+	return in.ObjectMeta.Name
+}
+
+// HasRoutes returns true if a ServiceRouter is needed for each service to apply the defaults.
+func (in *MeshPolicyDefaults) HasRoutes() bool {
+	return in.Spec.RequestTimeout.Duration != 0 || in.Spec.Retries != nil
+}
+
+// ServiceDefaultsSpec returns the spec of the ServiceDefaults created for each service.
+func (in *MeshPolicyDefaults) ServiceDefaultsSpec() ServiceDefaultsSpec {
+	spec := ServiceDefaultsSpec{
+		Protocol: in.Spec.Protocol,
+	}
+	if in.Spec.UpstreamLimits != nil || in.Spec.PassiveHealthCheck != nil {
+		spec.UpstreamConfig = &Upstreams{
+			Defaults: &Upstream{
+				Limits:             in.Spec.UpstreamLimits.DeepCopy(),
+				PassiveHealthCheck: in.Spec.PassiveHealthCheck.DeepCopy(),
+			},
+		}
+	}
+	return spec
+}
+
+// ServiceRouterSpec returns the spec of the ServiceRouter created for each service. It has a
+// single catch-all route so that the timeout and retries apply to all requests.
+func (in *MeshPolicyDefaults) ServiceRouterSpec() ServiceRouterSpec {
+	destination := &ServiceRouteDestination{
+		RequestTimeout: in.Spec.RequestTimeout,
+	}
+	if in.Spec.Retries != nil {
+		destination.NumRetries = in.Spec.Retries.NumRetries
+		destination.RetryOnConnectFailure = in.Spec.Retries.RetryOnConnectFailure
+		destination.RetryOnStatusCodes = in.Spec.Retries.RetryOnStatusCodes
+	}
+	return ServiceRouterSpec{
+		Routes: []ServiceRoute{
+			{
+				Match: &ServiceRouteMatch{
+					HTTP: &ServiceRouteHTTPMatch{PathPrefix: "/"},
+				},
+				Destination: destination,
+			},
+		},
+	}
+}
+
+func (in *MeshPolicyDefaults) Validate() error {
+	var errs field.ErrorList
+	path := field.NewPath("spec")
+
+	validProtocols := []string{"tcp", "http", "http2", "grpc"}
+	if in.Spec.Protocol != "" && !sliceContains(validProtocols, in.Spec.Protocol) {
+		errs = append(errs, field.Invalid(path.Child("protocol"), in.Spec.Protocol, notInSliceMessage(validProtocols)))
+	}
+	if in.HasRoutes() && !sliceContains([]string{"http", "http2", "grpc"}, in.Spec.Protocol) {
+		errs = append(errs, field.Invalid(path.Child("protocol"), in.Spec.Protocol,
+			`must be one of "http", "http2" or "grpc" when requestTimeout or retries are set`))
+	}
+	if in.Spec.RequestTimeout.Duration < 0 {
+		errs = append(errs, field.Invalid(path.Child("requestTimeout"), in.Spec.RequestTimeout.Duration.String(), "must be greater than or equal to 0"))
+	}
+	if in.Spec.Retries != nil {
+		for i, code := range in.Spec.Retries.RetryOnStatusCodes {
+			if code < 100 || code > 599 {
+				errs = append(errs, field.Invalid(path.Child("retries").Child("retryOnStatusCodes").Index(i), code, "must be a valid HTTP status code"))
+			}
+		}
+	}
+	if in.Spec.UpstreamLimits != nil {
+		errs = append(errs, in.Spec.UpstreamLimits.validate(path.Child("upstreamLimits"))...)
+	}
+
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(
+			schema.GroupKind{Group: ConsulHashicorpGroup, Kind: MeshPolicyDefaultsKubeKind},
+			in.KubernetesName(), errs)
+	}
+	return nil
+}
+
+func (in *MeshPolicyDefaults) SetSyncedCondition(status corev1.ConditionStatus, reason string, message string) {
+	in.Status.Conditions = Conditions{
+		{
+			Type:               ConditionSynced,
+			Status:             status,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		},
+	}
+	if status == corev1.ConditionTrue {
+		now := metav1.Now()
+		in.Status.LastSyncedTime = &now
+	}
+}
+
+func (in *UpstreamLimits) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for name, limit := range map[string]*int{
+		"maxConnections":        in.MaxConnections,
+		"maxPendingRequests":    in.MaxPendingRequests,
+		"maxConcurrentRequests": in.MaxConcurrentRequests,
+	} {
+		if limit != nil && *limit < 0 {
+			errs = append(errs, field.Invalid(path.Child(name), *limit, "must be greater than or equal to 0"))
+		}
+	}
+	return errs
+}
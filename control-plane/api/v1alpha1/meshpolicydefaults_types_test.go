@@ -0,0 +1,167 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMeshPolicyDefaults_Validate(t *testing.T) {
+	cases := map[string]struct {
+		policy          *MeshPolicyDefaults
+		expectedErrMsgs []string
+	}{
+		"valid": {
+			policy: &MeshPolicyDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "defaults"},
+				Spec: MeshPolicyDefaultsSpec{
+					Protocol:       "http",
+					RequestTimeout: metav1.Duration{Duration: 5 * time.Second},
+					Retries: &MeshPolicyRetries{
+						NumRetries:         3,
+						RetryOnStatusCodes: []uint32{503},
+					},
+					UpstreamLimits: &UpstreamLimits{MaxConnections: intPointer(100)},
+				},
+			},
+		},
+		"tcp with only circuit breaking": {
+			policy: &MeshPolicyDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "defaults"},
+				Spec: MeshPolicyDefaultsSpec{
+					Protocol:           "tcp",
+					PassiveHealthCheck: &PassiveHealthCheck{MaxFailures: 5},
+				},
+			},
+		},
+		"invalid protocol": {
+			policy: &MeshPolicyDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "defaults"},
+				Spec:       MeshPolicyDefaultsSpec{Protocol: "udp"},
+			},
+			expectedErrMsgs: []string{
+				`spec.protocol: Invalid value: "udp": must be one of "tcp", "http", "http2", "grpc"`,
+			},
+		},
+		"retries without an L7 protocol": {
+			policy: &MeshPolicyDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "defaults"},
+				Spec: MeshPolicyDefaultsSpec{
+					Retries: &MeshPolicyRetries{NumRetries: 3},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.protocol: Invalid value: "": must be one of "http", "http2" or "grpc" when requestTimeout or retries are set`,
+			},
+		},
+		"negative request timeout": {
+			policy: &MeshPolicyDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "defaults"},
+				Spec: MeshPolicyDefaultsSpec{
+					Protocol:       "http",
+					RequestTimeout: metav1.Duration{Duration: -time.Second},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.requestTimeout: Invalid value: "-1s": must be greater than or equal to 0`,
+			},
+		},
+		"invalid status code": {
+			policy: &MeshPolicyDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "defaults"},
+				Spec: MeshPolicyDefaultsSpec{
+					Protocol: "http",
+					Retries:  &MeshPolicyRetries{RetryOnStatusCodes: []uint32{503, 999}},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.retries.retryOnStatusCodes[1]: Invalid value: 0x3e7: must be a valid HTTP status code`,
+			},
+		},
+		"negative limit": {
+			policy: &MeshPolicyDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "defaults"},
+				Spec: MeshPolicyDefaultsSpec{
+					UpstreamLimits: &UpstreamLimits{MaxPendingRequests: intPointer(-1)},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.upstreamLimits.maxPendingRequests: Invalid value: -1: must be greater than or equal to 0`,
+			},
+		},
+	}
+
+	for name, testCase := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := testCase.policy.Validate()
+			if len(testCase.expectedErrMsgs) != 0 {
+				require.Error(t, err)
+				for _, s := range testCase.expectedErrMsgs {
+					require.Contains(t, err.Error(), s)
+				}
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMeshPolicyDefaults_ServiceDefaultsSpec(t *testing.T) {
+	policy := &MeshPolicyDefaults{
+		Spec: MeshPolicyDefaultsSpec{
+			Protocol:           "http",
+			UpstreamLimits:     &UpstreamLimits{MaxConnections: intPointer(100)},
+			PassiveHealthCheck: &PassiveHealthCheck{MaxFailures: 5},
+		},
+	}
+	require.Equal(t, ServiceDefaultsSpec{
+		Protocol: "http",
+		UpstreamConfig: &Upstreams{
+			Defaults: &Upstream{
+				Limits:             &UpstreamLimits{MaxConnections: intPointer(100)},
+				PassiveHealthCheck: &PassiveHealthCheck{MaxFailures: 5},
+			},
+		},
+	}, policy.ServiceDefaultsSpec())
+
+	policy.Spec.UpstreamLimits = nil
+	policy.Spec.PassiveHealthCheck = nil
+	require.Equal(t, ServiceDefaultsSpec{Protocol: "http"}, policy.ServiceDefaultsSpec())
+}
+
+func TestMeshPolicyDefaults_ServiceRouterSpec(t *testing.T) {
+	policy := &MeshPolicyDefaults{
+		Spec: MeshPolicyDefaultsSpec{
+			Protocol:       "http",
+			RequestTimeout: metav1.Duration{Duration: 5 * time.Second},
+			Retries: &MeshPolicyRetries{
+				NumRetries:            3,
+				RetryOnConnectFailure: true,
+				RetryOnStatusCodes:    []uint32{503},
+			},
+		},
+	}
+	require.True(t, policy.HasRoutes())
+	require.Equal(t, ServiceRouterSpec{
+		Routes: []ServiceRoute{
+			{
+				Match: &ServiceRouteMatch{HTTP: &ServiceRouteHTTPMatch{PathPrefix: "/"}},
+				Destination: &ServiceRouteDestination{
+					RequestTimeout:        metav1.Duration{Duration: 5 * time.Second},
+					NumRetries:            3,
+					RetryOnConnectFailure: true,
+					RetryOnStatusCodes:    []uint32{503},
+				},
+			},
+		},
+	}, policy.ServiceRouterSpec())
+
+	policy.Spec.RequestTimeout = metav1.Duration{}
+	policy.Spec.Retries = nil
+	require.False(t, policy.HasRoutes())
+}
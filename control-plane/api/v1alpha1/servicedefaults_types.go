@@ -26,6 +26,9 @@ const (
 	ServiceDefaultsKubeKind = "servicedefaults"
 	defaultUpstream         = "default"
 	overrideUpstream        = "override"
+
+	// extAuthzExtensionName is the name of the Envoy extension that ExtAuthz is rendered as.
+	extAuthzExtensionName = "builtin/ext-authz"
 )
 
 func init() {
@@ -117,6 +120,10 @@ type ServiceDefaultsSpec struct {
 	BalanceInboundConnections string `json:"balanceInboundConnections,omitempty"`
 	// EnvoyExtensions are a list of extensions to modify Envoy proxy configuration.
 	EnvoyExtensions EnvoyExtensions `json:"envoyExtensions,omitempty"`
+	// ExtAuthz configures the service's sidecar proxies to authorize incoming requests
+	// with an external authorization service. It's rendered as the builtin/ext-authz
+	// Envoy extension, so it can't be combined with that extension in EnvoyExtensions.
+	ExtAuthz *ExtAuthz `json:"extAuthz,omitempty"`
 }
 
 type Upstreams struct {
@@ -214,6 +221,45 @@ type ServiceDefaultsDestination struct {
 	Port uint32 `json:"port,omitempty"`
 }
 
+// ExtAuthz configures an external authorization service that is called for every
+// incoming request. Exactly one of GRPCService or HTTPService must be set.
+type ExtAuthz struct {
+	// GRPCService is the external authorization service that is called over gRPC.
+	GRPCService *ExtAuthzService `json:"grpcService,omitempty"`
+	// HTTPService is the external authorization service that is called over HTTP.
+	HTTPService *ExtAuthzService `json:"httpService,omitempty"`
+	// FailureModeAllow allows requests when the authorization service fails or
+	// can't be reached. By default such requests are denied.
+	FailureModeAllow bool `json:"failureModeAllow,omitempty"`
+	// Required sets whether the proxies fail to configure if the extension
+	// can't be applied, rather than running without it.
+	Required bool `json:"required,omitempty"`
+}
+
+// ExtAuthzService is the location of an external authorization service. Exactly one
+// of Service or URI must be set.
+type ExtAuthzService struct {
+	// Service is the Consul service of the authorization service. Requests to it go
+	// through the mesh as an upstream of the proxy.
+	Service *ExtAuthzServiceRef `json:"service,omitempty"`
+	// URI is the address of an authorization service that isn't in the mesh,
+	// e.g. "127.0.0.1:9191".
+	URI string `json:"uri,omitempty"`
+	// PathPrefix is prepended to the path of authorization requests. It can only
+	// be set for HTTP services.
+	PathPrefix string `json:"pathPrefix,omitempty"`
+}
+
+// ExtAuthzServiceRef references the Consul service of an authorization service.
+type ExtAuthzServiceRef struct {
+	// Name is the name of the Consul service.
+	Name string `json:"name,omitempty"`
+	// Namespace is the Consul namespace of the service.
+	Namespace string `json:"namespace,omitempty"`
+	// Partition is the Consul admin partition of the service.
+	Partition string `json:"partition,omitempty"`
+}
+
 func (in *ServiceDefaults) ConsulKind() string {
 	return capi.ServiceDefaults
 }
@@ -306,7 +352,7 @@ func (in *ServiceDefaults) ToConsul(datacenter string) capi.ConfigEntry {
 		LocalConnectTimeoutMs:     in.Spec.LocalConnectTimeoutMs,
 		LocalRequestTimeoutMs:     in.Spec.LocalRequestTimeoutMs,
 		BalanceInboundConnections: in.Spec.BalanceInboundConnections,
-		EnvoyExtensions:           in.Spec.EnvoyExtensions.toConsul(),
+		EnvoyExtensions:           in.Spec.ExtAuthz.appendToConsul(in.Spec.EnvoyExtensions.toConsul()),
 	}
 }
 
@@ -355,6 +401,7 @@ func (in *ServiceDefaults) Validate(consulMeta common.ConsulMeta) error {
 	allErrs = append(allErrs, in.Spec.UpstreamConfig.validate(path.Child("upstreamConfig"), consulMeta.PartitionsEnabled)...)
 	allErrs = append(allErrs, in.Spec.Expose.validate(path.Child("expose"))...)
 	allErrs = append(allErrs, in.Spec.EnvoyExtensions.validate(path.Child("envoyExtensions"))...)
+	allErrs = append(allErrs, in.Spec.ExtAuthz.validate(path.Child("extAuthz"), in.Spec.EnvoyExtensions, consulMeta)...)
 
 	if len(allErrs) > 0 {
 		return apierrors.NewInvalid(
@@ -541,6 +588,100 @@ func (in *ServiceDefaultsDestination) toConsul() *capi.DestinationConfig {
 	}
 }
 
+func (in *ExtAuthz) validate(path *field.Path, extensions EnvoyExtensions, consulMeta common.ConsulMeta) field.ErrorList {
+	if in == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	for _, e := range extensions {
+		if e.Name == extAuthzExtensionName {
+			errs = append(errs, field.Invalid(path, "", fmt.Sprintf("cannot be set when envoyExtensions includes %s", extAuthzExtensionName)))
+			break
+		}
+	}
+	if (in.GRPCService == nil) == (in.HTTPService == nil) {
+		return append(errs, field.Required(path, "exactly one of grpcService or httpService must be set"))
+	}
+	if in.GRPCService != nil {
+		errs = append(errs, in.GRPCService.validate(path.Child("grpcService"), false, consulMeta)...)
+	} else {
+		errs = append(errs, in.HTTPService.validate(path.Child("httpService"), true, consulMeta)...)
+	}
+	return errs
+}
+
+func (in *ExtAuthzService) validate(path *field.Path, http bool, consulMeta common.ConsulMeta) field.ErrorList {
+	var errs field.ErrorList
+	if (in.Service == nil) == (in.URI == "") {
+		errs = append(errs, field.Required(path, "exactly one of service or uri must be set"))
+	}
+	if in.Service != nil {
+		if in.Service.Name == "" {
+			errs = append(errs, field.Required(path.Child("service", "name"), "name must be set"))
+		}
+		if in.Service.Namespace != "" && !consulMeta.NamespacesEnabled {
+			errs = append(errs, field.Invalid(path.Child("service", "namespace"), in.Service.Namespace, "Consul Enterprise namespaces must be enabled to set service.namespace"))
+		}
+		if in.Service.Partition != "" && !consulMeta.PartitionsEnabled {
+			errs = append(errs, field.Invalid(path.Child("service", "partition"), in.Service.Partition, "Consul Enterprise Admin Partitions must be enabled to set service.partition"))
+		}
+	}
+	if in.PathPrefix != "" {
+		if !http {
+			errs = append(errs, field.Invalid(path.Child("pathPrefix"), in.PathPrefix, "can only be set for httpService"))
+		} else if invalidPathPrefix(in.PathPrefix) {
+			errs = append(errs, field.Invalid(path.Child("pathPrefix"), in.PathPrefix, "must begin with a '/'"))
+		}
+	}
+	return errs
+}
+
+// appendToConsul appends the builtin/ext-authz extension that the configuration is
+// rendered as to extensions.
+func (in *ExtAuthz) appendToConsul(extensions []capi.EnvoyExtension) []capi.EnvoyExtension {
+	if in == nil {
+		return extensions
+	}
+	config := map[string]interface{}{}
+	if in.GRPCService != nil {
+		config["GrpcService"] = in.GRPCService.toConsul()
+	} else if in.HTTPService != nil {
+		config["HttpService"] = in.HTTPService.toConsul()
+	}
+	if in.FailureModeAllow {
+		config["FailureModeAllow"] = true
+	}
+	return append(extensions, capi.EnvoyExtension{
+		Name:     extAuthzExtensionName,
+		Required: in.Required,
+		Arguments: map[string]interface{}{
+			"ProxyType": string(capi.ServiceKindConnectProxy),
+			"Config":    config,
+		},
+	})
+}
+
+func (in *ExtAuthzService) toConsul() map[string]interface{} {
+	target := map[string]interface{}{}
+	if in.Service != nil {
+		service := map[string]interface{}{"Name": in.Service.Name}
+		if in.Service.Namespace != "" {
+			service["Namespace"] = in.Service.Namespace
+		}
+		if in.Service.Partition != "" {
+			service["Partition"] = in.Service.Partition
+		}
+		target["Service"] = service
+	} else {
+		target["URI"] = in.URI
+	}
+	svc := map[string]interface{}{"Target": target}
+	if in.PathPrefix != "" {
+		svc["PathPrefix"] = in.PathPrefix
+	}
+	return svc
+}
+
 // DefaultNamespaceFields has no behaviour here as service-defaults have no namespace specific fields.
 func (in *ServiceDefaults) DefaultNamespaceFields(_ common.ConsulMeta) {
 }
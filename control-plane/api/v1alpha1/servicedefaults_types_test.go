@@ -320,6 +320,62 @@ func TestServiceDefaults_ToConsul(t *testing.T) {
 				},
 			},
 		},
+		"ext authz": {
+			&ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: ServiceDefaultsSpec{
+					EnvoyExtensions: EnvoyExtensions{
+						EnvoyExtension{
+							Name:      "builtin/lua",
+							Arguments: json.RawMessage(`{"Script": "function envoy_on_request(h) end"}`),
+						},
+					},
+					ExtAuthz: &ExtAuthz{
+						HTTPService: &ExtAuthzService{
+							Service:    &ExtAuthzServiceRef{Name: "authz", Namespace: "security"},
+							PathPrefix: "/check",
+						},
+						FailureModeAllow: true,
+						Required:         true,
+					},
+				},
+			},
+			&capi.ServiceConfigEntry{
+				Name: "foo",
+				Kind: capi.ServiceDefaults,
+				EnvoyExtensions: []capi.EnvoyExtension{
+					{
+						Name:      "builtin/lua",
+						Arguments: map[string]interface{}{"Script": "function envoy_on_request(h) end"},
+					},
+					{
+						Name:     "builtin/ext-authz",
+						Required: true,
+						Arguments: map[string]interface{}{
+							"ProxyType": "connect-proxy",
+							"Config": map[string]interface{}{
+								"HttpService": map[string]interface{}{
+									"Target": map[string]interface{}{
+										"Service": map[string]interface{}{
+											"Name":      "authz",
+											"Namespace": "security",
+										},
+									},
+									"PathPrefix": "/check",
+								},
+								"FailureModeAllow": true,
+							},
+						},
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+		},
 	}
 
 	for name, testCase := range cases {
@@ -781,6 +837,66 @@ func TestServiceDefaults_MatchesConsul(t *testing.T) {
 			},
 			matches: true,
 		},
+		"ext authz matches the extension read from Consul": {
+			internal: &ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-test-service",
+				},
+				Spec: ServiceDefaultsSpec{
+					ExtAuthz: &ExtAuthz{
+						GRPCService: &ExtAuthzService{URI: "127.0.0.1:9191"},
+					},
+				},
+			},
+			consul: &capi.ServiceConfigEntry{
+				Kind: capi.ServiceDefaults,
+				Name: "my-test-service",
+				EnvoyExtensions: []capi.EnvoyExtension{
+					{
+						Name: "builtin/ext-authz",
+						Arguments: map[string]interface{}{
+							"ProxyType": "connect-proxy",
+							"Config": map[string]interface{}{
+								"GrpcService": map[string]interface{}{
+									"Target": map[string]interface{}{"URI": "127.0.0.1:9191"},
+								},
+							},
+						},
+					},
+				},
+			},
+			matches: true,
+		},
+		"ext authz does not match a different target": {
+			internal: &ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-test-service",
+				},
+				Spec: ServiceDefaultsSpec{
+					ExtAuthz: &ExtAuthz{
+						GRPCService: &ExtAuthzService{Service: &ExtAuthzServiceRef{Name: "authz"}},
+					},
+				},
+			},
+			consul: &capi.ServiceConfigEntry{
+				Kind: capi.ServiceDefaults,
+				Name: "my-test-service",
+				EnvoyExtensions: []capi.EnvoyExtension{
+					{
+						Name: "builtin/ext-authz",
+						Arguments: map[string]interface{}{
+							"ProxyType": "connect-proxy",
+							"Config": map[string]interface{}{
+								"GrpcService": map[string]interface{}{
+									"Target": map[string]interface{}{"URI": "127.0.0.1:9191"},
+								},
+							},
+						},
+					},
+				},
+			},
+			matches: false,
+		},
 	}
 
 	for name, testCase := range cases {
@@ -1329,6 +1445,104 @@ func TestServiceDefaults_Validate(t *testing.T) {
 			},
 			expectedErrMsg: `servicedefaults.consul.hashicorp.com "my-service" is invalid: spec.envoyExtensions.envoyExtension[0].arguments: Invalid value: "{\"SOME_INVALID_JSON\"}": must be valid map value: invalid character '}' after object key`,
 		},
+		"extAuthz valid grpc service": {
+			input: &ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-service",
+				},
+				Spec: ServiceDefaultsSpec{
+					ExtAuthz: &ExtAuthz{
+						GRPCService: &ExtAuthzService{Service: &ExtAuthzServiceRef{Name: "authz"}},
+					},
+				},
+			},
+			expectedErrMsg: "",
+		},
+		"extAuthz neither or both services": {
+			input: &ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-service",
+				},
+				Spec: ServiceDefaultsSpec{
+					ExtAuthz: &ExtAuthz{
+						GRPCService: &ExtAuthzService{URI: "127.0.0.1:9191"},
+						HTTPService: &ExtAuthzService{URI: "127.0.0.1:9191"},
+					},
+				},
+			},
+			expectedErrMsg: `servicedefaults.consul.hashicorp.com "my-service" is invalid: spec.extAuthz: Required value: exactly one of grpcService or httpService must be set`,
+		},
+		"extAuthz target": {
+			input: &ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-service",
+				},
+				Spec: ServiceDefaultsSpec{
+					ExtAuthz: &ExtAuthz{
+						HTTPService: &ExtAuthzService{Service: &ExtAuthzServiceRef{}, URI: "127.0.0.1:9191"},
+					},
+				},
+			},
+			expectedErrMsg: `servicedefaults.consul.hashicorp.com "my-service" is invalid: [spec.extAuthz.httpService: Required value: exactly one of service or uri must be set, spec.extAuthz.httpService.service.name: Required value: name must be set]`,
+		},
+		"extAuthz service namespace and partition without enterprise": {
+			input: &ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-service",
+				},
+				Spec: ServiceDefaultsSpec{
+					ExtAuthz: &ExtAuthz{
+						GRPCService: &ExtAuthzService{Service: &ExtAuthzServiceRef{Name: "authz", Namespace: "ns", Partition: "ap"}},
+					},
+				},
+			},
+			expectedErrMsg: `servicedefaults.consul.hashicorp.com "my-service" is invalid: [spec.extAuthz.grpcService.service.namespace: Invalid value: "ns": Consul Enterprise namespaces must be enabled to set service.namespace, spec.extAuthz.grpcService.service.partition: Invalid value: "ap": Consul Enterprise Admin Partitions must be enabled to set service.partition]`,
+		},
+		"extAuthz pathPrefix": {
+			input: &ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-service",
+				},
+				Spec: ServiceDefaultsSpec{
+					ExtAuthz: &ExtAuthz{
+						GRPCService: &ExtAuthzService{URI: "127.0.0.1:9191", PathPrefix: "/check"},
+					},
+				},
+			},
+			expectedErrMsg: `servicedefaults.consul.hashicorp.com "my-service" is invalid: spec.extAuthz.grpcService.pathPrefix: Invalid value: "/check": can only be set for httpService`,
+		},
+		"extAuthz http pathPrefix": {
+			input: &ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-service",
+				},
+				Spec: ServiceDefaultsSpec{
+					ExtAuthz: &ExtAuthz{
+						HTTPService: &ExtAuthzService{URI: "127.0.0.1:9191", PathPrefix: "check"},
+					},
+				},
+			},
+			expectedErrMsg: `servicedefaults.consul.hashicorp.com "my-service" is invalid: spec.extAuthz.httpService.pathPrefix: Invalid value: "check": must begin with a '/'`,
+		},
+		"extAuthz with ext-authz envoy extension": {
+			input: &ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-service",
+				},
+				Spec: ServiceDefaultsSpec{
+					EnvoyExtensions: EnvoyExtensions{
+						EnvoyExtension{
+							Name:      "builtin/ext-authz",
+							Arguments: json.RawMessage(`{"ProxyType": "connect-proxy"}`),
+						},
+					},
+					ExtAuthz: &ExtAuthz{
+						GRPCService: &ExtAuthzService{URI: "127.0.0.1:9191"},
+					},
+				},
+			},
+			expectedErrMsg: `servicedefaults.consul.hashicorp.com "my-service" is invalid: spec.extAuthz: Invalid value: "": cannot be set when envoyExtensions includes builtin/ext-authz`,
+		},
 	}
 
 	for name, testCase := range cases {
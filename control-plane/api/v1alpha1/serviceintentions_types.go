@@ -310,19 +310,7 @@ func (in *ServiceIntentions) Validate(consulMeta common.ConsulMeta) error {
 	if len(in.Spec.Sources) == 0 {
 		errs = append(errs, field.Required(path.Child("sources"), `at least one source must be specified`))
 	}
-	for i, source := range in.Spec.Sources {
-		if len(source.Permissions) > 0 && source.Action != "" {
-			asJSON, _ := json.Marshal(source)
-			errs = append(errs, field.Invalid(path.Child("sources").Index(i), string(asJSON), `action and permissions are mutually exclusive and only one of them can be specified`))
-		} else if len(source.Permissions) == 0 {
-			if err := source.Action.validate(path.Child("sources").Index(i)); err != nil {
-				errs = append(errs, err)
-			}
-		} else {
-			errs = append(errs, source.Permissions.validate(path.Child("sources").Index(i))...)
-		}
-		errs = append(errs, source.validate(path.Child("sources").Index(i), consulMeta.PartitionsEnabled)...)
-	}
+	errs = append(errs, in.Spec.Sources.validate(path.Child("sources"), consulMeta.PartitionsEnabled)...)
 
 	errs = append(errs, in.validateNamespaces(consulMeta.NamespacesEnabled)...)
 
@@ -336,6 +324,24 @@ func (in *ServiceIntentions) Validate(consulMeta common.ConsulMeta) error {
 	return nil
 }
 
+func (in SourceIntentions) validate(path *field.Path, partitionsEnabled bool) field.ErrorList {
+	var errs field.ErrorList
+	for i, source := range in {
+		if len(source.Permissions) > 0 && source.Action != "" {
+			asJSON, _ := json.Marshal(source)
+			errs = append(errs, field.Invalid(path.Index(i), string(asJSON), `action and permissions are mutually exclusive and only one of them can be specified`))
+		} else if len(source.Permissions) == 0 {
+			if err := source.Action.validate(path.Index(i)); err != nil {
+				errs = append(errs, err)
+			}
+		} else {
+			errs = append(errs, source.Permissions.validate(path.Index(i))...)
+		}
+		errs = append(errs, source.validate(path.Index(i), partitionsEnabled)...)
+	}
+	return errs
+}
+
 func (in *SourceIntention) validate(path *field.Path, partitionsEnabled bool) field.ErrorList {
 	var errs field.ErrorList
 
@@ -11,7 +11,10 @@ import (
 
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/quota"
 	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
@@ -23,6 +26,9 @@ type ServiceIntentionsWebhook struct {
 	Logger     logr.Logger
 	decoder    *admission.Decoder
 	ConsulMeta common.ConsulMeta
+	// QuotaPolicy limits the number of intentions in a namespace. Quotas aren't enforced if
+	// it's nil.
+	QuotaPolicy *quota.Policy
 }
 
 // NOTE: The path value in the below line is the path to the webhook.
@@ -91,12 +97,48 @@ func (v *ServiceIntentionsWebhook) Handle(ctx context.Context, req admission.Req
 		return admission.Errored(http.StatusBadRequest, err)
 	}
 
+	if err := v.checkQuota(ctx, req.Namespace, svcIntentions); err != nil {
+		return admission.Errored(http.StatusForbidden, err)
+	}
+
 	// We always return an admission.Patched() response, even if there are no patches, since
 	// admission.Patched() with no patches is equal to admission.Allowed() under
 	// the hood.
 	return admission.Patched(fmt.Sprintf("valid %s request", svcIntentions.KubeKind()), defaultingPatches...)
 }
 
+// checkQuota returns an error if the namespace would have more intentions than its quota
+// allows. Each source of a ServiceIntentions resource is an intention.
+func (v *ServiceIntentionsWebhook) checkQuota(ctx context.Context, namespace string, svcIntentions ServiceIntentions) error {
+	if v.QuotaPolicy == nil {
+		return nil
+	}
+	var ns corev1.Namespace
+	if err := v.Client.Get(ctx, types.NamespacedName{Name: namespace}, &ns); err != nil {
+		return err
+	}
+	limits, err := v.QuotaPolicy.Limits(&ns)
+	if err != nil {
+		return err
+	}
+	if limits.MaxIntentions == 0 {
+		return nil
+	}
+
+	var svcIntentionsList ServiceIntentionsList
+	if err := v.Client.List(ctx, &svcIntentionsList, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	previous, count := 0, len(svcIntentions.Spec.Sources)
+	for _, item := range svcIntentionsList.Items {
+		previous += len(item.Spec.Sources)
+		if item.Name != svcIntentions.Name {
+			count += len(item.Spec.Sources)
+		}
+	}
+	return quota.Check(namespace, "intentions", limits.MaxIntentions, previous, count)
+}
+
 func (v *ServiceIntentionsWebhook) InjectDecoder(d *admission.Decoder) error {
 	v.decoder = d
 	return nil
@@ -11,9 +11,11 @@ import (
 
 	logrtest "github.com/go-logr/logr/testr"
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/quota"
 	"github.com/stretchr/testify/require"
 	"gomodules.xyz/jsonpatch/v2"
 	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -474,6 +476,90 @@ func TestHandle_ServiceIntentions_Update(t *testing.T) {
 // Test that we return patches to set Consul namespace fields to their defaults.
 // This test also tests OSS where we expect no patches since OSS has no
 // Consul namespaces.
+func TestHandle_ServiceIntentions_Quota(t *testing.T) {
+	intentions := func(name, destination string, sources ...string) *ServiceIntentions {
+		svcIntentions := &ServiceIntentions{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a"},
+			Spec:       ServiceIntentionsSpec{Destination: IntentionDestination{Name: destination}},
+		}
+		for _, source := range sources {
+			svcIntentions.Spec.Sources = append(svcIntentions.Spec.Sources, &SourceIntention{Name: source, Action: "allow"})
+		}
+		return svcIntentions
+	}
+	existing := intentions("web", "web", "api", "frontend")
+
+	cases := map[string]struct {
+		operation     admissionv1.Operation
+		newResource   *ServiceIntentions
+		expAllow      bool
+		expErrMessage string
+	}{
+		"create within the quota": {
+			operation:   admissionv1.Create,
+			newResource: intentions("db", "db", "web"),
+			expAllow:    true,
+		},
+		"create exceeding the quota": {
+			operation:     admissionv1.Create,
+			newResource:   intentions("db", "db", "web", "api"),
+			expAllow:      false,
+			expErrMessage: "namespace team-a would have 4 intentions, which exceeds its quota of 3",
+		},
+		"update exceeding the quota": {
+			operation:     admissionv1.Update,
+			newResource:   intentions("web", "web", "api", "frontend", "admin", "batch"),
+			expAllow:      false,
+			expErrMessage: "namespace team-a would have 4 intentions, which exceeds its quota of 3",
+		},
+		"update removing intentions": {
+			operation:   admissionv1.Update,
+			newResource: intentions("web", "web", "api"),
+			expAllow:    true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			marshalledRequestObject, err := json.Marshal(c.newResource)
+			require.NoError(t, err)
+			marshalledOldObject, err := json.Marshal(existing)
+			require.NoError(t, err)
+			s := runtime.NewScheme()
+			s.AddKnownTypes(GroupVersion, &ServiceIntentions{}, &ServiceIntentionsList{})
+			require.NoError(t, corev1.AddToScheme(s))
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "team-a",
+				Annotations: map[string]string{quota.AnnotationMaxIntentions: "3"},
+			}}
+			client := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(existing, namespace).Build()
+			decoder, err := admission.NewDecoder(s)
+			require.NoError(t, err)
+
+			validator := &ServiceIntentionsWebhook{
+				Client:      client,
+				Logger:      logrtest.New(t),
+				decoder:     decoder,
+				QuotaPolicy: &quota.Policy{},
+			}
+			response := validator.Handle(ctx, admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Name:      c.newResource.KubernetesName(),
+					Namespace: "team-a",
+					Operation: c.operation,
+					Object:    runtime.RawExtension{Raw: marshalledRequestObject},
+					OldObject: runtime.RawExtension{Raw: marshalledOldObject},
+				},
+			})
+
+			require.Equal(t, c.expAllow, response.Allowed)
+			if c.expErrMessage != "" {
+				require.Equal(t, c.expErrMessage, response.AdmissionResponse.Result.Message)
+			}
+		})
+	}
+}
+
 func TestHandle_ServiceIntentions_Patches(t *testing.T) {
 	otherNS := "other"
 
@@ -79,6 +79,10 @@ type ServiceResolverSpec struct {
 	// LoadBalancer determines the load balancing policy and configuration for services
 	// issuing requests to this upstream service.
 	LoadBalancer *LoadBalancer `json:"loadBalancer,omitempty"`
+	// StickySessions routes the requests of each client to the same instance of the
+	// service by hashing on a header, a cookie or the client's IP. It's a shorthand
+	// for a loadBalancer with a hash-based policy, so it can't be set with loadBalancer.
+	StickySessions *StickySessions `json:"stickySessions,omitempty"`
 	// PrioritizeByLocality controls whether the locality of services within the
 	// local partition will be used to prioritize connectivity.
 	PrioritizeByLocality *ServiceResolverPrioritizeByLocality `json:"prioritizeByLocality,omitempty"`
@@ -185,6 +189,32 @@ type LoadBalancer struct {
 	HashPolicies []HashPolicy `json:"hashPolicies,omitempty"`
 }
 
+// StickySessions configures session affinity. Exactly one of Header, Cookie or
+// SourceIP must be set.
+type StickySessions struct {
+	// Policy is the hash-based load balancing policy, either "ring_hash" or "maglev".
+	// Defaults to "ring_hash".
+	Policy string `json:"policy,omitempty"`
+	// Header is the name of the request header to hash on.
+	Header string `json:"header,omitempty"`
+	// Cookie hashes on a cookie, which the proxy sets on the responses to clients
+	// that don't have it yet.
+	Cookie *StickySessionsCookie `json:"cookie,omitempty"`
+	// SourceIP hashes on the IP address of the client.
+	SourceIP bool `json:"sourceIP,omitempty"`
+}
+
+// StickySessionsCookie is the cookie that sticky sessions hash on.
+type StickySessionsCookie struct {
+	// Name is the name of the cookie.
+	Name string `json:"name,omitempty"`
+	// TTL is the ttl of the cookie. If it's not set, a session cookie with no
+	// expiration is generated.
+	TTL metav1.Duration `json:"ttl,omitempty"`
+	// Path is the path to set for the cookie.
+	Path string `json:"path,omitempty"`
+}
+
 type RingHashConfig struct {
 	// MinimumRingSize determines the minimum number of entries in the hash ring.
 	MinimumRingSize uint64 `json:"minimumRingSize,omitempty"`
@@ -317,7 +347,7 @@ func (in *ServiceResolver) ToConsul(datacenter string) capi.ConfigEntry {
 		Redirect:             in.Spec.Redirect.toConsul(),
 		Failover:             in.Spec.Failover.toConsul(),
 		ConnectTimeout:       in.Spec.ConnectTimeout.Duration,
-		LoadBalancer:         in.Spec.LoadBalancer.toConsul(),
+		LoadBalancer:         in.Spec.StickySessions.loadBalancer(in.Spec.LoadBalancer).toConsul(),
 		PrioritizeByLocality: in.Spec.PrioritizeByLocality.toConsul(),
 		Meta:                 meta(datacenter),
 	}
@@ -352,6 +382,10 @@ func (in *ServiceResolver) Validate(consulMeta common.ConsulMeta) error {
 	errs = append(errs, in.Spec.PrioritizeByLocality.validate(path.Child("prioritizeByLocality"))...)
 	errs = append(errs, in.Spec.Subsets.validate(path.Child("subsets"))...)
 	errs = append(errs, in.Spec.LoadBalancer.validate(path.Child("loadBalancer"))...)
+	errs = append(errs, in.Spec.StickySessions.validate(path.Child("stickySessions"))...)
+	if in.Spec.StickySessions != nil && in.Spec.LoadBalancer != nil {
+		errs = append(errs, field.Invalid(path.Child("stickySessions"), "", "stickySessions cannot be set with loadBalancer"))
+	}
 	errs = append(errs, in.validateEnterprise(consulMeta)...)
 
 	if len(errs) > 0 {
@@ -747,12 +781,82 @@ func (in *LoadBalancer) validate(path *field.Path) field.ErrorList {
 		return nil
 	}
 	var errs field.ErrorList
+	validPolicies := []string{"", "random", "round_robin", "least_request", "ring_hash", "maglev"}
+	if !sliceContains(validPolicies, in.Policy) {
+		errs = append(errs, field.Invalid(path.Child("policy"), in.Policy, notInSliceMessage(validPolicies)))
+	}
+	if in.RingHashConfig != nil {
+		if in.Policy != "ring_hash" {
+			errs = append(errs, field.Invalid(path.Child("ringHashConfig"), in.Policy, `ringHashConfig can only be set with policy "ring_hash"`))
+		}
+		if in.RingHashConfig.MaximumRingSize != 0 && in.RingHashConfig.MinimumRingSize > in.RingHashConfig.MaximumRingSize {
+			errs = append(errs, field.Invalid(path.Child("ringHashConfig", "minimumRingSize"), int64(in.RingHashConfig.MinimumRingSize), "minimumRingSize cannot be greater than maximumRingSize"))
+		}
+	}
+	if in.LeastRequestConfig != nil && in.Policy != "least_request" {
+		errs = append(errs, field.Invalid(path.Child("leastRequestConfig"), in.Policy, `leastRequestConfig can only be set with policy "least_request"`))
+	}
+	if len(in.HashPolicies) > 0 && in.Policy != "" && !hashBasedPolicy(in.Policy) {
+		errs = append(errs, field.Invalid(path.Child("hashPolicies"), in.Policy, `hashPolicies can only be set with policy "ring_hash" or "maglev"`))
+	}
 	for i, p := range in.HashPolicies {
 		errs = append(errs, p.validate(path.Child("hashPolicies").Index(i))...)
 	}
 	return errs
 }
 
+func hashBasedPolicy(policy string) bool {
+	return policy == "ring_hash" || policy == "maglev"
+}
+
+func (in *StickySessions) validate(path *field.Path) field.ErrorList {
+	if in == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	if in.Policy != "" && !hashBasedPolicy(in.Policy) {
+		errs = append(errs, field.Invalid(path.Child("policy"), in.Policy, notInSliceMessage([]string{"ring_hash", "maglev"})))
+	}
+	if countTrue(in.Header != "", in.Cookie != nil, in.SourceIP) != 1 {
+		asJSON, _ := json.Marshal(in)
+		errs = append(errs, field.Invalid(path, string(asJSON), "exactly one of header, cookie or sourceIP must be set"))
+	}
+	if in.Cookie != nil && in.Cookie.Name == "" {
+		errs = append(errs, field.Required(path.Child("cookie", "name"), "name must be set"))
+	}
+	return errs
+}
+
+// loadBalancer returns the load balancer that the sticky sessions are a shorthand
+// for, or lb if sticky sessions aren't configured.
+func (in *StickySessions) loadBalancer(lb *LoadBalancer) *LoadBalancer {
+	if in == nil {
+		return lb
+	}
+	policy := in.Policy
+	if policy == "" {
+		policy = "ring_hash"
+	}
+	var hash HashPolicy
+	switch {
+	case in.Header != "":
+		hash = HashPolicy{Field: "header", FieldValue: in.Header}
+	case in.Cookie != nil:
+		hash = HashPolicy{
+			Field:      "cookie",
+			FieldValue: in.Cookie.Name,
+			CookieConfig: &CookieConfig{
+				Session: in.Cookie.TTL.Duration == 0,
+				TTL:     in.Cookie.TTL,
+				Path:    in.Cookie.Path,
+			},
+		}
+	default:
+		hash = HashPolicy{SourceIP: true}
+	}
+	return &LoadBalancer{Policy: policy, HashPolicies: []HashPolicy{hash}}
+}
+
 func (in HashPolicy) validate(path *field.Path) field.ErrorList {
 	var errs field.ErrorList
 	if in.Field != "" {
@@ -772,6 +876,9 @@ func (in HashPolicy) validate(path *field.Path) field.ErrorList {
 		}
 	}
 
+	if in.CookieConfig != nil && in.Field != "cookie" {
+		errs = append(errs, field.Invalid(path.Child("cookieConfig"), in.Field, `cookieConfig can only be set with field "cookie"`))
+	}
 	if err := in.CookieConfig.validate(path.Child("cookieConfig")); err != nil {
 		errs = append(errs, err)
 	}
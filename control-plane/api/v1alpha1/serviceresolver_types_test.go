@@ -434,6 +434,117 @@ func TestServiceResolver_ToConsul(t *testing.T) {
 				},
 			},
 		},
+		"sticky sessions on a header": {
+			Ours: ServiceResolver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: ServiceResolverSpec{
+					StickySessions: &StickySessions{
+						Header: "x-user-id",
+					},
+				},
+			},
+			Exp: &capi.ServiceResolverConfigEntry{
+				Name: "name",
+				Kind: capi.ServiceResolver,
+				LoadBalancer: &capi.LoadBalancer{
+					Policy: "ring_hash",
+					HashPolicies: []capi.HashPolicy{
+						{Field: "header", FieldValue: "x-user-id"},
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+		},
+		"sticky sessions on a session cookie": {
+			Ours: ServiceResolver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: ServiceResolverSpec{
+					StickySessions: &StickySessions{
+						Policy: "maglev",
+						Cookie: &StickySessionsCookie{Name: "session", Path: "/"},
+					},
+				},
+			},
+			Exp: &capi.ServiceResolverConfigEntry{
+				Name: "name",
+				Kind: capi.ServiceResolver,
+				LoadBalancer: &capi.LoadBalancer{
+					Policy: "maglev",
+					HashPolicies: []capi.HashPolicy{
+						{
+							Field:        "cookie",
+							FieldValue:   "session",
+							CookieConfig: &capi.CookieConfig{Session: true, Path: "/"},
+						},
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+		},
+		"sticky sessions on a cookie with ttl": {
+			Ours: ServiceResolver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: ServiceResolverSpec{
+					StickySessions: &StickySessions{
+						Cookie: &StickySessionsCookie{Name: "session", TTL: metav1.Duration{Duration: time.Hour}},
+					},
+				},
+			},
+			Exp: &capi.ServiceResolverConfigEntry{
+				Name: "name",
+				Kind: capi.ServiceResolver,
+				LoadBalancer: &capi.LoadBalancer{
+					Policy: "ring_hash",
+					HashPolicies: []capi.HashPolicy{
+						{
+							Field:        "cookie",
+							FieldValue:   "session",
+							CookieConfig: &capi.CookieConfig{TTL: time.Hour},
+						},
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+		},
+		"sticky sessions on the source IP": {
+			Ours: ServiceResolver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: ServiceResolverSpec{
+					StickySessions: &StickySessions{
+						SourceIP: true,
+					},
+				},
+			},
+			Exp: &capi.ServiceResolverConfigEntry{
+				Name: "name",
+				Kind: capi.ServiceResolver,
+				LoadBalancer: &capi.LoadBalancer{
+					Policy:       "ring_hash",
+					HashPolicies: []capi.HashPolicy{{SourceIP: true}},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+		},
 	}
 	for name, c := range cases {
 		t.Run(name, func(t *testing.T) {
@@ -909,6 +1020,113 @@ func TestServiceResolver_Validate(t *testing.T) {
 			expectedErrMsgs: []string{
 				"mode must be one of '', 'none', or 'failover'",
 			},
+		}, "loadBalancer policy invalid": {
+			input: &ServiceResolver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: ServiceResolverSpec{
+					LoadBalancer: &LoadBalancer{
+						Policy: "sticky",
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.loadBalancer.policy: Invalid value: "sticky": must be one of "", "random", "round_robin", "least_request", "ring_hash", "maglev"`,
+			},
+		},
+		"loadBalancer config for another policy": {
+			input: &ServiceResolver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: ServiceResolverSpec{
+					LoadBalancer: &LoadBalancer{
+						Policy:             "round_robin",
+						RingHashConfig:     &RingHashConfig{MinimumRingSize: 10, MaximumRingSize: 5},
+						LeastRequestConfig: &LeastRequestConfig{ChoiceCount: 2},
+						HashPolicies:       []HashPolicy{{SourceIP: true}},
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.loadBalancer.ringHashConfig: Invalid value: "round_robin": ringHashConfig can only be set with policy "ring_hash"`,
+				`spec.loadBalancer.ringHashConfig.minimumRingSize: Invalid value: 10: minimumRingSize cannot be greater than maximumRingSize`,
+				`spec.loadBalancer.leastRequestConfig: Invalid value: "round_robin": leastRequestConfig can only be set with policy "least_request"`,
+				`spec.loadBalancer.hashPolicies: Invalid value: "round_robin": hashPolicies can only be set with policy "ring_hash" or "maglev"`,
+			},
+		},
+		"hashPolicy cookieConfig without cookie field": {
+			input: &ServiceResolver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: ServiceResolverSpec{
+					LoadBalancer: &LoadBalancer{
+						Policy: "maglev",
+						HashPolicies: []HashPolicy{
+							{
+								Field:        "header",
+								FieldValue:   "x-user-id",
+								CookieConfig: &CookieConfig{Session: true},
+							},
+						},
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.loadBalancer.hashPolicies[0].cookieConfig: Invalid value: "header": cookieConfig can only be set with field "cookie"`,
+			},
+		},
+		"stickySessions valid": {
+			input: &ServiceResolver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: ServiceResolverSpec{
+					StickySessions: &StickySessions{
+						Cookie: &StickySessionsCookie{Name: "session"},
+					},
+				},
+			},
+			expectedErrMsgs: nil,
+		},
+		"stickySessions invalid": {
+			input: &ServiceResolver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: ServiceResolverSpec{
+					LoadBalancer: &LoadBalancer{
+						Policy: "ring_hash",
+					},
+					StickySessions: &StickySessions{
+						Policy:   "round_robin",
+						Header:   "x-user-id",
+						SourceIP: true,
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.stickySessions.policy: Invalid value: "round_robin": must be one of "ring_hash", "maglev"`,
+				`spec.stickySessions: Invalid value: "{\"policy\":\"round_robin\",\"header\":\"x-user-id\",\"sourceIP\":true}": exactly one of header, cookie or sourceIP must be set`,
+				`spec.stickySessions: Invalid value: "": stickySessions cannot be set with loadBalancer`,
+			},
+		},
+		"stickySessions cookie without name": {
+			input: &ServiceResolver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: ServiceResolverSpec{
+					StickySessions: &StickySessions{
+						Cookie: &StickySessionsCookie{},
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.stickySessions.cookie.name: Required value: name must be set`,
+			},
 		},
 	}
 	for name, testCase := range cases {
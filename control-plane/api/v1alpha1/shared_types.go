@@ -310,6 +310,35 @@ func (in *FailoverPolicy) validate(path *field.Path) field.ErrorList {
 	return errs
 }
 
+// fipsTLSCipherSuites are the TLS 1.2 cipher suites that are approved for use
+// in FIPS 140-2 mode.
+var fipsTLSCipherSuites = []string{
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+}
+
+// validateFIPSTLS returns errors for TLS versions below TLS 1.2 and for
+// cipher suites that are not approved in FIPS 140-2 mode.
+func validateFIPSTLS(path *field.Path, minVersion, maxVersion string, cipherSuites []string) field.ErrorList {
+	var errs field.ErrorList
+	disallowedVersions := []string{"TLSv1_0", "TLSv1_1"}
+	if sliceContains(disallowedVersions, minVersion) {
+		errs = append(errs, field.Invalid(path.Child("tlsMinVersion"), minVersion, "must be at least TLSv1_2 in FIPS mode"))
+	}
+	if sliceContains(disallowedVersions, maxVersion) {
+		errs = append(errs, field.Invalid(path.Child("tlsMaxVersion"), maxVersion, "must be at least TLSv1_2 in FIPS mode"))
+	}
+	for i, cipherSuite := range cipherSuites {
+		if !sliceContains(fipsTLSCipherSuites, cipherSuite) {
+			errs = append(errs, field.Invalid(path.Child("cipherSuites").Index(i), cipherSuite,
+				notInSliceMessage(fipsTLSCipherSuites)+" in FIPS mode"))
+		}
+	}
+	return errs
+}
+
 func notInSliceMessage(slice []string) string {
 	return fmt.Sprintf(`must be one of "%s"`, strings.Join(slice, `", "`))
 }
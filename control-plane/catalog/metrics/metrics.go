@@ -0,0 +1,85 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package metrics contains the Prometheus metrics exported by the catalog
+// sync process for both sync directions.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const (
+	// DirectionToConsul is the direction label value for Kubernetes to Consul sync.
+	DirectionToConsul = "to-consul"
+	// DirectionToK8s is the direction label value for Consul to Kubernetes sync.
+	DirectionToK8s = "to-k8s"
+
+	namespace = "consul_sync_catalog"
+)
+
+var (
+	// Registry is the registry that all catalog sync metrics are registered with.
+	// It is served by the sync-catalog command when metrics are enabled.
+	Registry = prometheus.NewRegistry()
+
+	// ServicesRegistered counts the successful registrations that created or changed a service,
+	// per service. Re-registering an unchanged service isn't counted.
+	ServicesRegistered = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "services_registered_total",
+		Help:      "Number of successful registrations that created or changed a service, by sync direction and service name.",
+	}, []string{"direction", "service"})
+
+	// ServicesDeregistered counts the successful service deregistrations per service.
+	ServicesDeregistered = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "services_deregistered_total",
+		Help:      "Number of successful service deregistrations, by sync direction and service name.",
+	}, []string{"direction", "service"})
+
+	// SyncDuration observes how long each sync loop iteration takes.
+	SyncDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sync_duration_seconds",
+		Help:      "Duration of a single sync loop iteration, by sync direction.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"direction"})
+
+	// ConsulAPIErrors counts failed Consul API calls by operation.
+	ConsulAPIErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "consul_api_errors_total",
+		Help:      "Number of failed Consul API calls, by sync direction and operation.",
+	}, []string{"direction", "operation"})
+
+	// Backlog is the number of changes that are waiting to be written.
+	Backlog = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "backlog",
+		Help:      "Number of registrations and deregistrations that have not yet been applied, by sync direction.",
+	}, []string{"direction"})
+
+	// OldestServiceSync is the time of the least recent successful sync of any
+	// service that is still managed. Alerting on `time() - value` finds a
+	// syncer that has stopped updating some or all of its services.
+	OldestServiceSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "oldest_service_sync_timestamp_seconds",
+		Help:      "Unix time of the least recent successful sync of a managed service, by sync direction.",
+	}, []string{"direction"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		ServicesRegistered,
+		ServicesDeregistered,
+		SyncDuration,
+		ConsulAPIErrors,
+		Backlog,
+		OldestServiceSync,
+	)
+}
@@ -32,4 +32,14 @@ const (
 	// e.g. Service `backend` in k8s cluster `A` receives 25% of the traffic
 	// compared to same `backend` service in k8s cluster `B`.
 	annotationServiceWeight = "consul.hashicorp.com/service-weight"
+
+	// annotationServiceSyncMinReadyEndpoints is the key of the annotation that
+	// overrides the minimum number of ready endpoints the Service must have
+	// before it's registered in Consul.
+	annotationServiceSyncMinReadyEndpoints = "consul.hashicorp.com/service-sync-min-ready-endpoints"
+
+	// annotationServiceSyncExcludePorts specifies the ports of the Service
+	// that aren't synced. Multiple ports should be comma separated and can be
+	// port names or port numbers.
+	annotationServiceSyncExcludePorts = "consul.hashicorp.com/service-sync-exclude-ports"
 )
@@ -0,0 +1,19 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package catalog
+
+import "github.com/hashicorp/consul/api"
+
+// MultiSyncer is a Syncer that passes the registrations to each of its
+// Syncers. It's used to register Kubernetes services in more than one Consul
+// datacenter or admin partition at the same time. The Syncers must not modify
+// the registrations since they are shared.
+type MultiSyncer []Syncer
+
+// Sync implements Syncer.
+func (m MultiSyncer) Sync(rs []*api.CatalogRegistration) {
+	for _, s := range m {
+		s.Sync(rs)
+	}
+}
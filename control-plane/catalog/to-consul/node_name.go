@@ -0,0 +1,52 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package catalog
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// ConsulNodeNames returns the names of the Consul nodes that services are
+// registered with. With more than one shard, each shard is a node named after
+// nodeName with the index of the shard as a suffix, e.g. k8s-sync-0.
+func ConsulNodeNames(nodeName string, shards int) []string {
+	if shards <= 1 {
+		return []string{nodeName}
+	}
+	names := make([]string, 0, shards)
+	for i := 0; i < shards; i++ {
+		names = append(names, shardNodeName(nodeName, i))
+	}
+	return names
+}
+
+// consulNodeName returns the name of the Consul node that the services of the
+// K8s namespace are registered with. Namespaces are spread across the shards
+// by the hash of their name so that a namespace always stays on the same node.
+func consulNodeName(nodeName string, shards int, k8sNS string) string {
+	if shards <= 1 {
+		return nodeName
+	}
+	h := fnv.New32a()
+	h.Write([]byte(k8sNS))
+	return shardNodeName(nodeName, int(h.Sum32()%uint32(shards)))
+}
+
+func shardNodeName(nodeName string, shard int) string {
+	return fmt.Sprintf("%s-%d", nodeName, shard)
+}
+
+// isShardNodeName returns true if name is the name of a shard node of
+// nodeName for any number of shards.
+func isShardNodeName(nodeName, name string) bool {
+	suffix, ok := strings.CutPrefix(name, nodeName+"-")
+	if !ok {
+		return false
+	}
+	shard, err := strconv.Atoi(suffix)
+	return err == nil && shard >= 0 && suffix == strconv.Itoa(shard)
+}
@@ -0,0 +1,41 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package catalog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsulNodeNames(t *testing.T) {
+	require.Equal(t, []string{"k8s-sync"}, ConsulNodeNames("k8s-sync", 0))
+	require.Equal(t, []string{"k8s-sync"}, ConsulNodeNames("k8s-sync", 1))
+	require.Equal(t, []string{"k8s-sync-0", "k8s-sync-1", "k8s-sync-2"}, ConsulNodeNames("k8s-sync", 3))
+}
+
+func TestConsulNodeName(t *testing.T) {
+	require.Equal(t, "k8s-sync", consulNodeName("k8s-sync", 1, "default"))
+
+	// Namespaces always map to the same shard and are spread across them.
+	shards := make(map[string]bool)
+	for _, ns := range []string{"default", "kube-system", "team-a", "team-b", "team-c", "team-d"} {
+		name := consulNodeName("k8s-sync", 3, ns)
+		require.Equal(t, name, consulNodeName("k8s-sync", 3, ns))
+		require.Contains(t, ConsulNodeNames("k8s-sync", 3), name)
+		shards[name] = true
+	}
+	require.Greater(t, len(shards), 1)
+}
+
+func TestIsShardNodeName(t *testing.T) {
+	require.True(t, isShardNodeName("k8s-sync", "k8s-sync-0"))
+	require.True(t, isShardNodeName("k8s-sync", "k8s-sync-12"))
+	require.False(t, isShardNodeName("k8s-sync", "k8s-sync"))
+	require.False(t, isShardNodeName("k8s-sync", "k8s-sync-"))
+	require.False(t, isShardNodeName("k8s-sync", "k8s-sync-01"))
+	require.False(t, isShardNodeName("k8s-sync", "k8s-sync--1"))
+	require.False(t, isShardNodeName("k8s-sync", "k8s-sync-other"))
+	require.False(t, isShardNodeName("k8s-sync", "other-k8s-sync-0"))
+}
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
@@ -38,6 +39,18 @@ const (
 	ConsulK8SRefValue = "external-k8s-ref-name"
 	ConsulK8SNodeName = "external-k8s-node-name"
 
+	// ConsulK8SPodName and ConsulK8SPodIP are the keys used in the meta to
+	// record the pod behind an instance of a headless service.
+	ConsulK8SPodName = "external-k8s-pod-name"
+	ConsulK8SPodIP   = "external-k8s-pod-ip"
+
+	// ConsulK8SResourceVersion is the key used in the meta to record the
+	// resourceVersion of the Kubernetes service the registration was
+	// generated from. ConsulK8SLastSync records when the registration last
+	// changed in Consul, formatted as RFC 3339.
+	ConsulK8SResourceVersion = "external-k8s-resource-version"
+	ConsulK8SLastSync        = "external-k8s-last-sync"
+
 	// consulKubernetesCheckType is the type of health check in Consul for Kubernetes readiness status.
 	consulKubernetesCheckType = "kubernetes-readiness"
 	// consulKubernetesCheckName is the name of health check in Consul for Kubernetes readiness status.
@@ -60,6 +73,26 @@ const (
 	InternalOnly NodePortSyncType = "InternalOnly"
 )
 
+type LoadBalancerAddressType string
+
+const (
+	// Sync LoadBalancer services with the IP of an ingress entry if it has
+	// one, otherwise with its hostname.
+	IPFirst LoadBalancerAddressType = "IPFirst"
+
+	// Sync LoadBalancer services with the hostname of an ingress entry if
+	// it has one, otherwise with its IP.
+	HostnameFirst LoadBalancerAddressType = "HostnameFirst"
+)
+
+const (
+	// taggedAddressLoadBalancerIP and taggedAddressLoadBalancerHostname are
+	// the prefixes of the tagged addresses of the ingress entries of
+	// LoadBalancer services. The index of the entry follows the prefix.
+	taggedAddressLoadBalancerIP       = "loadbalancer_ip_"
+	taggedAddressLoadBalancerHostname = "loadbalancer_hostname_"
+)
+
 // ServiceResource implements controller.Resource to sync Service resource
 // types from K8S.
 type ServiceResource struct {
@@ -85,6 +118,16 @@ type ServiceResource struct {
 	// ConsulK8STag is the tag value for services registered.
 	ConsulK8STag string
 
+	// ServiceTagTemplates are templates for additional tags of the services
+	// registered. They're rendered with the name, namespace, labels and
+	// annotations of the K8s service. Tags that render empty are skipped.
+	ServiceTagTemplates []*template.Template
+
+	// ServiceMetaTemplates are templates for additional meta of the services
+	// registered, rendered like ServiceTagTemplates. Meta set with the
+	// consul.hashicorp.com/service-meta- annotations takes precedence.
+	ServiceMetaTemplates []ServiceMetaTemplate
+
 	//ConsulServicePrefix prepends K8s services in Consul with a prefix
 	ConsulServicePrefix string
 
@@ -100,6 +143,17 @@ type ServiceResource struct {
 	// LoadBalancerEndpointsSync set to true (default false) will sync ServiceTypeLoadBalancer endpoints.
 	LoadBalancerEndpointsSync bool
 
+	// LoadBalancerAddressType is whether the IP or the hostname of ingress
+	// entries of LoadBalancer services that have both is synced. Defaults
+	// to IPFirst.
+	LoadBalancerAddressType LoadBalancerAddressType
+
+	// LoadBalancerTaggedAddresses set to true registers a single instance of
+	// LoadBalancer services with the address of their first ingress entry,
+	// and the IPs and hostnames of all their ingress entries as tagged
+	// addresses, instead of an instance for each ingress entry.
+	LoadBalancerTaggedAddresses bool
+
 	// NodeExternalIPSync set to true (the default) syncs NodePort services
 	// using the node's external ip address. When false, the node's internal
 	// ip address will be used instead.
@@ -136,6 +190,24 @@ type ServiceResource struct {
 	// The Consul node name to register service with.
 	ConsulNodeName string
 
+	// ConsulNodeShards is the number of Consul nodes to spread the services
+	// across, by their K8s namespace, so that large syncs aren't all on a
+	// single node. See ConsulNodeNames for how the nodes are named. Zero or
+	// one registers every service with ConsulNodeName.
+	ConsulNodeShards int
+
+	// ConsulNodeMeta is additional meta of the Consul nodes that services
+	// are registered with. It's only set when a node is created.
+	ConsulNodeMeta map[string]string
+
+	// MinReadyEndpoints is the minimum number of ready endpoints a service
+	// must have before it's registered in Consul. Services are deregistered
+	// again when they fall below it. It only applies to services whose
+	// endpoints are tracked and can be overridden per service with the
+	// consul.hashicorp.com/service-sync-min-ready-endpoints annotation.
+	// Zero disables the threshold.
+	MinReadyEndpoints int
+
 	// serviceLock must be held for any read/write to these maps.
 	serviceLock sync.RWMutex
 
@@ -381,17 +453,29 @@ func (t *ServiceResource) generateRegistrations(key string) {
 	// a new one if there is one.
 	delete(t.consulMap, key)
 
+	// Don't register services that don't have enough ready endpoints yet so
+	// that partially deployed services aren't discovered and sent traffic.
+	if minReady := t.minReadyEndpoints(svc); minReady > 0 && t.shouldTrackEndpoints(key) {
+		if ready := t.readyEndpoints(key); ready < minReady {
+			t.Log.Debug("[generateRegistrations] not enough ready endpoints to register service",
+				"key", key, "ready", ready, "min-ready", minReady)
+			return
+		}
+	}
+
 	// baseNode and baseService are the base that should be modified with
 	// service-type specific changes. These are not pointers, they should be
 	// shallow copied for each instance.
 	baseNode := consulapi.CatalogRegistration{
 		SkipNodeUpdate: true,
-		Node:           t.ConsulNodeName,
+		Node:           consulNodeName(t.ConsulNodeName, t.ConsulNodeShards, svc.Namespace),
 		Address:        "127.0.0.1",
-		NodeMeta: map[string]string{
-			ConsulSourceKey: ConsulSourceValue,
-		},
+		NodeMeta:       make(map[string]string, len(t.ConsulNodeMeta)+1),
+	}
+	for k, v := range t.ConsulNodeMeta {
+		baseNode.NodeMeta[k] = v
 	}
+	baseNode.NodeMeta[ConsulSourceKey] = ConsulSourceValue
 
 	baseService := consulapi.AgentService{
 		Service: t.addPrefixAndK8SNamespace(svc.Name, svc.Namespace),
@@ -401,6 +485,9 @@ func (t *ServiceResource) generateRegistrations(key string) {
 			ConsulK8SNS:     svc.Namespace,
 		},
 	}
+	if svc.ResourceVersion != "" {
+		baseService.Meta[ConsulK8SResourceVersion] = svc.ResourceVersion
+	}
 
 	// If the name is explicitly annotated, adopt that name
 	if v, ok := svc.Annotations[annotationServiceName]; ok {
@@ -418,10 +505,24 @@ func (t *ServiceResource) generateRegistrations(key string) {
 		baseService.Namespace = consulNS
 	}
 
+	// Leave out the ports that are excluded from the sync. Services whose
+	// ports are all excluded aren't registered.
+	excluded := excludedPorts(svc)
+	var ports []corev1.ServicePort
+	for _, p := range svc.Spec.Ports {
+		if !excluded[p.Name] {
+			ports = append(ports, p)
+		}
+	}
+	if len(svc.Spec.Ports) > 0 && len(ports) == 0 {
+		t.Log.Debug("[generateRegistrations] all ports of service are excluded from sync", "key", key)
+		return
+	}
+
 	// Determine the default port and set port annotations
 	var overridePortName string
 	var overridePortNumber int
-	if len(svc.Spec.Ports) > 0 {
+	if len(ports) > 0 {
 		var port int
 		isNodePort := svc.Spec.Type == corev1.ServiceTypeNodePort
 
@@ -439,7 +540,7 @@ func (t *ServiceResource) generateRegistrations(key string) {
 		// For when the port was a name instead of an int
 		if overridePortName != "" {
 			// Find the named port
-			for _, p := range svc.Spec.Ports {
+			for _, p := range ports {
 				if p.Name == overridePortName {
 					if isNodePort && p.NodePort > 0 {
 						port = int(p.NodePort)
@@ -458,14 +559,14 @@ func (t *ServiceResource) generateRegistrations(key string) {
 		if port == 0 {
 			if isNodePort {
 				// Find first defined NodePort
-				for _, p := range svc.Spec.Ports {
+				for _, p := range ports {
 					if p.NodePort > 0 {
 						port = int(p.NodePort)
 						break
 					}
 				}
 			} else {
-				port = int(svc.Spec.Ports[0].Port)
+				port = int(ports[0].Port)
 				// NOTE: for cluster IP services we always use the endpoint
 				// ports so this will be overridden.
 			}
@@ -474,12 +575,34 @@ func (t *ServiceResource) generateRegistrations(key string) {
 		baseService.Port = port
 
 		// Add all the ports as annotations
-		for _, p := range svc.Spec.Ports {
+		for _, p := range ports {
 			// Set the tag
 			baseService.Meta["port-"+p.Name] = strconv.FormatInt(int64(p.Port), 10)
 		}
 	}
 
+	// Render the tag and meta templates
+	for _, tmpl := range t.ServiceTagTemplates {
+		tag, err := renderServiceTemplate(tmpl, svc)
+		if err != nil {
+			t.Log.Warn("error rendering service tag template", "key", key, "err", err)
+			continue
+		}
+		if tag != "" {
+			baseService.Tags = append(baseService.Tags, tag)
+		}
+	}
+	for _, meta := range t.ServiceMetaTemplates {
+		value, err := renderServiceTemplate(meta.Template, svc)
+		if err != nil {
+			t.Log.Warn("error rendering service meta template", "key", key, "meta", meta.Key, "err", err)
+			continue
+		}
+		if value != "" {
+			baseService.Meta[meta.Key] = value
+		}
+	}
+
 	// Parse any additional tags
 	if rawTags, ok := svc.Annotations[annotationServiceTags]; ok {
 		baseService.Tags = append(baseService.Tags, parsetags.ParseTags(rawTags)...)
@@ -511,18 +634,7 @@ func (t *ServiceResource) generateRegistrations(key string) {
 			r.Service = &rs
 			r.Service.ID = serviceID(r.Service.Service, ip)
 			r.Service.Address = ip
-			// Adding information about service weight.
-			// Overrides the existing weight if present.
-			if weight, ok := svc.Annotations[annotationServiceWeight]; ok && weight != "" {
-				weightI, err := getServiceWeight(weight)
-				if err == nil {
-					r.Service.Weights = consulapi.AgentWeights{
-						Passing: weightI,
-					}
-				} else {
-					t.Log.Debug("[generateRegistrations] service weight err: ", err)
-				}
-			}
+			t.setServiceWeight(svc, r.Service)
 
 			t.consulMap[key] = append(t.consulMap[key], &r)
 		}
@@ -532,19 +644,19 @@ func (t *ServiceResource) generateRegistrations(key string) {
 
 	switch svc.Spec.Type {
 	// For LoadBalancer type services, we create a service instance for
-	// each LoadBalancer entry. We only support entries that have an IP
-	// address assigned (not hostnames).
+	// each LoadBalancer entry, with its IP or hostname depending on
+	// LoadBalancerAddressType, or a single instance with the addresses of
+	// all entries as tagged addresses if LoadBalancerTaggedAddresses is true.
 	// If LoadBalancerEndpointsSync is true sync LB endpoints instead of loadbalancer ingress.
 	case corev1.ServiceTypeLoadBalancer:
 		if t.LoadBalancerEndpointsSync {
 			t.registerServiceInstance(baseNode, baseService, key, overridePortName, overridePortNumber, false)
+		} else if t.LoadBalancerTaggedAddresses {
+			t.registerLoadBalancerTaggedAddresses(svc, baseNode, baseService, key)
 		} else {
 			seen := map[string]struct{}{}
 			for _, ingress := range svc.Status.LoadBalancer.Ingress {
-				addr := ingress.IP
-				if addr == "" {
-					addr = ingress.Hostname
-				}
+				addr := t.loadBalancerIngressAddress(ingress)
 				if addr == "" {
 					continue
 				}
@@ -560,18 +672,7 @@ func (t *ServiceResource) generateRegistrations(key string) {
 				r.Service.ID = serviceID(r.Service.Service, addr)
 				r.Service.Address = addr
 
-				// Adding information about service weight.
-				// Overrides the existing weight if present.
-				if weight, ok := svc.Annotations[annotationServiceWeight]; ok && weight != "" {
-					weightI, err := getServiceWeight(weight)
-					if err == nil {
-						r.Service.Weights = consulapi.AgentWeights{
-							Passing: weightI,
-						}
-					} else {
-						t.Log.Debug("[generateRegistrations] service weight err: ", err)
-					}
-				}
+				t.setServiceWeight(svc, r.Service)
 
 				t.consulMap[key] = append(t.consulMap[key], &r)
 			}
@@ -667,6 +768,124 @@ func (t *ServiceResource) generateRegistrations(key string) {
 	}
 }
 
+// minReadyEndpoints returns the minimum number of ready endpoints the service
+// must have to be registered, taking the annotation override into account.
+func (t *ServiceResource) minReadyEndpoints(svc *corev1.Service) int {
+	raw, ok := svc.Annotations[annotationServiceSyncMinReadyEndpoints]
+	if !ok {
+		return t.MinReadyEndpoints
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || v < 0 {
+		t.Log.Warn("error parsing service-sync-min-ready-endpoints annotation, it must be a non-negative integer",
+			"service-name", t.addPrefixAndK8SNamespace(svc.Name, svc.Namespace),
+			"value", raw)
+		return t.MinReadyEndpoints
+	}
+	return v
+}
+
+// isHeadless returns true if the service is a headless ClusterIP service,
+// i.e. its endpoints are the IPs of its pods rather than a virtual IP.
+func isHeadless(svc *corev1.Service) bool {
+	return svc != nil && svc.Spec.Type == corev1.ServiceTypeClusterIP && svc.Spec.ClusterIP == corev1.ClusterIPNone
+}
+
+// excludedPorts returns the names of the service's ports that are excluded
+// from the sync with the service-sync-exclude-ports annotation. Ports can be
+// excluded by name or by port number. An unnamed port is excluded as "".
+func excludedPorts(svc *corev1.Service) map[string]bool {
+	raw, ok := svc.Annotations[annotationServiceSyncExcludePorts]
+	if !ok {
+		return nil
+	}
+	excluded := make(map[string]bool)
+	for _, v := range strings.Split(raw, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		for _, p := range svc.Spec.Ports {
+			if p.Name == v || strconv.Itoa(int(p.Port)) == v {
+				excluded[p.Name] = true
+			}
+		}
+	}
+	return excluded
+}
+
+// readyEndpoints returns the number of unique ready addresses of the
+// endpoints for the given key.
+//
+// Precondition: the lock t.lock is held.
+func (t *ServiceResource) readyEndpoints(key string) int {
+	endpoints := t.endpointsMap[key]
+	if endpoints == nil {
+		return 0
+	}
+	seen := make(map[string]struct{})
+	for _, subset := range endpoints.Subsets {
+		for _, addr := range subset.Addresses {
+			id := addr.IP
+			if id == "" {
+				id = addr.Hostname
+			}
+			seen[id] = struct{}{}
+		}
+	}
+	return len(seen)
+}
+
+// loadBalancerIngressAddress returns the IP or hostname of a LoadBalancer
+// ingress entry depending on LoadBalancerAddressType.
+func (t *ServiceResource) loadBalancerIngressAddress(ingress corev1.LoadBalancerIngress) string {
+	if t.LoadBalancerAddressType == HostnameFirst && ingress.Hostname != "" {
+		return ingress.Hostname
+	}
+	if ingress.IP != "" {
+		return ingress.IP
+	}
+	return ingress.Hostname
+}
+
+// registerLoadBalancerTaggedAddresses registers a single instance of a
+// LoadBalancer service with the address of its first ingress entry and the
+// IPs and hostnames of all its ingress entries as tagged addresses.
+func (t *ServiceResource) registerLoadBalancerTaggedAddresses(
+	svc *corev1.Service,
+	baseNode consulapi.CatalogRegistration,
+	baseService consulapi.AgentService,
+	key string) {
+
+	var addr string
+	taggedAddresses := make(map[string]consulapi.ServiceAddress)
+	for i, ingress := range svc.Status.LoadBalancer.Ingress {
+		if addr == "" {
+			addr = t.loadBalancerIngressAddress(ingress)
+		}
+		if ingress.IP != "" {
+			taggedAddresses[taggedAddressLoadBalancerIP+strconv.Itoa(i)] = consulapi.ServiceAddress{Address: ingress.IP, Port: baseService.Port}
+		}
+		if ingress.Hostname != "" {
+			taggedAddresses[taggedAddressLoadBalancerHostname+strconv.Itoa(i)] = consulapi.ServiceAddress{Address: ingress.Hostname, Port: baseService.Port}
+		}
+	}
+	if addr == "" {
+		return
+	}
+
+	r := baseNode
+	rs := baseService
+	r.Service = &rs
+	r.Service.ID = serviceID(r.Service.Service, addr)
+	r.Service.Address = addr
+	r.Service.TaggedAddresses = taggedAddresses
+
+	t.setServiceWeight(svc, r.Service)
+
+	t.consulMap[key] = append(t.consulMap[key], &r)
+}
+
 func (t *ServiceResource) registerServiceInstance(
 	baseNode consulapi.CatalogRegistration,
 	baseService consulapi.AgentService,
@@ -684,6 +903,8 @@ func (t *ServiceResource) registerServiceInstance(
 		return
 	}
 
+	excluded := excludedPorts(t.serviceMap[key])
+	headless := isHeadless(t.serviceMap[key])
 	seen := map[string]struct{}{}
 	for _, subset := range endpoints.Subsets {
 		// For ClusterIP services and if LoadBalancerEndpointsSync is true, we use the endpoint port instead
@@ -699,9 +920,13 @@ func (t *ServiceResource) registerServiceInstance(
 				}
 			}
 		} else if overridePortNumber == 0 {
-			// Otherwise we'll just use the first port in the list
-			// (unless the port number was overridden by an annotation).
+			// Otherwise we'll just use the first port in the list that
+			// isn't excluded (unless the port number was overridden by an
+			// annotation).
 			for _, p := range subset.Ports {
+				if excluded[p.Name] {
+					continue
+				}
 				epPort = int(p.Port)
 				break
 			}
@@ -751,6 +976,24 @@ func (t *ServiceResource) registerServiceInstance(
 			if subsetAddr.NodeName != nil {
 				r.Service.Meta[ConsulK8SNodeName] = *subsetAddr.NodeName
 			}
+			// Each pod of a headless service is addressable on its own, so
+			// record the pod and tag the instance with the pod's hostname.
+			// This makes e.g. web-0.web.service.consul resolve to a single
+			// StatefulSet member.
+			if headless {
+				hostname := subsetAddr.Hostname
+				if subsetAddr.TargetRef != nil && subsetAddr.TargetRef.Kind == "Pod" {
+					r.Service.Meta[ConsulK8SPodName] = subsetAddr.TargetRef.Name
+					if hostname == "" {
+						hostname = subsetAddr.TargetRef.Name
+					}
+				}
+				r.Service.Meta[ConsulK8SPodIP] = subsetAddr.IP
+				if hostname != "" {
+					// Copy the tags as baseService is shared between all instances.
+					r.Service.Tags = append(append([]string{}, baseService.Tags...), hostname)
+				}
+			}
 
 			r.Check = &consulapi.AgentCheck{
 				CheckID:   consulHealthCheckID(endpoints.Namespace, serviceID(r.Service.Service, addr)),
@@ -1026,6 +1269,24 @@ func consulHealthCheckID(k8sNS string, serviceID string) string {
 	return fmt.Sprintf("%s/%s", k8sNS, serviceID)
 }
 
+// setServiceWeight sets the passing weight of the service instance from the
+// service weight annotation of the Kubernetes service, if it's set and valid.
+// It overrides the existing weight.
+func (t *ServiceResource) setServiceWeight(svc *corev1.Service, service *consulapi.AgentService) {
+	weight, ok := svc.Annotations[annotationServiceWeight]
+	if !ok || weight == "" {
+		return
+	}
+	weightI, err := getServiceWeight(weight)
+	if err != nil {
+		t.Log.Debug("[generateRegistrations] service weight err: ", err)
+		return
+	}
+	service.Weights = consulapi.AgentWeights{
+		Passing: weightI,
+	}
+}
+
 // Calculates the passing service weight.
 func getServiceWeight(weight string) (int, error) {
 	// error validation if the input param is a number.
@@ -214,6 +214,33 @@ func TestServiceResource_defaultEnable(t *testing.T) {
 	})
 }
 
+// Test that the service's resourceVersion is recorded in the meta.
+func TestServiceResource_resourceVersionMeta(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert an LB service
+	svc := lbService("foo", metav1.NamespaceDefault, "1.2.3.4")
+	svc.ResourceVersion = "42"
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+		require.Equal(r, "42", actual[0].Service.Meta[ConsulK8SResourceVersion])
+	})
+}
+
 // Test that we can explicitly disable.
 func TestServiceResource_defaultEnableDisable(t *testing.T) {
 	t.Parallel()
@@ -418,6 +445,41 @@ func TestServiceResource_ConsulNodeName(t *testing.T) {
 	})
 }
 
+// Test that with node shards, services are synced to the node of their
+// namespace's shard with the configured node meta.
+func TestServiceResource_ConsulNodeShards(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ConsulNodeName = "test-node"
+	serviceResource.ConsulNodeShards = 4
+	serviceResource.ConsulNodeMeta = map[string]string{"team": "platform"}
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert LB services with the sync=true in two namespaces
+	for _, ns := range []string{"namespace", "other"} {
+		svc := lbService("foo", ns, "1.2.3.4")
+		_, err := client.CoreV1().Services(ns).Create(context.Background(), svc, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		for _, reg := range actual {
+			require.Equal(r, consulNodeName("test-node", 4, reg.Service.Meta[ConsulK8SNS]), reg.Node)
+			require.Contains(r, ConsulNodeNames("test-node", 4), reg.Node)
+			require.Equal(r, map[string]string{ConsulSourceKey: ConsulSourceValue, "team": "platform"}, reg.NodeMeta)
+		}
+	})
+}
+
 // Test k8s namespace suffix is not appended
 // when the service name annotation is provided.
 func TestServiceResource_addK8SNamespaceWithNameAnnotation(t *testing.T) {
@@ -598,6 +660,107 @@ func TestServiceResource_lbMultiEndpoint(t *testing.T) {
 	})
 }
 
+// Test that the IP or hostname of LoadBalancer ingress entries is synced
+// depending on the address type.
+func TestServiceResource_lbAddressType(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		addressType  LoadBalancerAddressType
+		expAddresses []string
+	}{
+		"default": {
+			expAddresses: []string{"1.2.3.4", "lb.example.com"},
+		},
+		"IPFirst": {
+			addressType:  IPFirst,
+			expAddresses: []string{"1.2.3.4", "lb.example.com"},
+		},
+		"HostnameFirst": {
+			addressType:  HostnameFirst,
+			expAddresses: []string{"lb-1.example.com", "lb.example.com"},
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			client := fake.NewSimpleClientset()
+			syncer := newTestSyncer()
+			serviceResource := defaultServiceResource(client, syncer)
+			serviceResource.LoadBalancerAddressType = c.addressType
+
+			// Start the controller
+			closer := controller.TestControllerRun(&serviceResource)
+			defer closer()
+
+			// Insert an LB service with an entry that has both an IP and a hostname
+			// and one that only has a hostname.
+			svc := lbService("foo", metav1.NamespaceDefault, "1.2.3.4")
+			svc.Status.LoadBalancer.Ingress[0].Hostname = "lb-1.example.com"
+			svc.Status.LoadBalancer.Ingress = append(
+				svc.Status.LoadBalancer.Ingress,
+				corev1.LoadBalancerIngress{Hostname: "lb.example.com"},
+			)
+			_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+			require.NoError(t, err)
+
+			// Verify what we got
+			retry.Run(t, func(r *retry.R) {
+				syncer.Lock()
+				defer syncer.Unlock()
+				actual := syncer.Registrations
+				require.Len(r, actual, 2)
+				require.Equal(r, c.expAddresses[0], actual[0].Service.Address)
+				require.Equal(r, c.expAddresses[1], actual[1].Service.Address)
+				require.Empty(r, actual[0].Service.TaggedAddresses)
+			})
+		})
+	}
+}
+
+// Test that a single instance with the addresses of all ingress entries as
+// tagged addresses is registered for a LoadBalancer if tagged addresses are
+// enabled.
+func TestServiceResource_lbTaggedAddresses(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.LoadBalancerTaggedAddresses = true
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert an LB service
+	svc := lbService("foo", metav1.NamespaceDefault, "1.2.3.4")
+	svc.Spec.Ports = []corev1.ServicePort{{Name: "http", Port: 80}}
+	svc.Status.LoadBalancer.Ingress[0].Hostname = "lb-1.example.com"
+	svc.Status.LoadBalancer.Ingress = append(
+		svc.Status.LoadBalancer.Ingress,
+		corev1.LoadBalancerIngress{IP: "2.3.4.5"},
+		corev1.LoadBalancerIngress{Hostname: "lb.example.com"},
+	)
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+		require.Equal(r, "foo", actual[0].Service.Service)
+		require.Equal(r, "1.2.3.4", actual[0].Service.Address)
+		require.Equal(r, map[string]consulapi.ServiceAddress{
+			"loadbalancer_ip_0":       {Address: "1.2.3.4", Port: 80},
+			"loadbalancer_hostname_0": {Address: "lb-1.example.com", Port: 80},
+			"loadbalancer_ip_1":       {Address: "2.3.4.5", Port: 80},
+			"loadbalancer_hostname_2": {Address: "lb.example.com", Port: 80},
+		}, actual[0].Service.TaggedAddresses)
+	})
+}
+
 // Test explicit name annotation.
 func TestServiceResource_lbAnnotatedName(t *testing.T) {
 	t.Parallel()
@@ -745,6 +908,81 @@ func TestServiceResource_lbAnnotatedMeta(t *testing.T) {
 	})
 }
 
+// Test templated tags and service meta.
+func TestServiceResource_tagAndMetaTemplates(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ConsulK8STag = TestConsulK8STag
+	for _, raw := range []string{"team-{{ .Labels.team }}", "{{ .Labels.missing }}", "{{ .Namespace }}/{{ .Name }}"} {
+		tmpl, err := ParseServiceTagTemplate(raw)
+		require.NoError(t, err)
+		serviceResource.ServiceTagTemplates = append(serviceResource.ServiceTagTemplates, tmpl)
+	}
+	for _, raw := range []string{"team={{ .Labels.team }}", "owner={{ index .Annotations \"example.com/owner\" }}", "tier=web", "missing={{ .Labels.missing }}"} {
+		meta, err := ParseServiceMetaTemplate(raw)
+		require.NoError(t, err)
+		serviceResource.ServiceMetaTemplates = append(serviceResource.ServiceMetaTemplates, meta)
+	}
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert an LB service
+	svc := lbService("foo", metav1.NamespaceDefault, "1.2.3.4")
+	svc.Labels = map[string]string{"team": "payments"}
+	svc.Annotations["example.com/owner"] = "alice"
+	svc.Annotations[annotationServiceMetaPrefix+"tier"] = "backend"
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+		require.Equal(r, []string{"k8s", "team-payments", "default/foo"}, actual[0].Service.Tags)
+		require.Equal(r, "payments", actual[0].Service.Meta["team"])
+		require.Equal(r, "alice", actual[0].Service.Meta["owner"])
+		// The annotation takes precedence over the template.
+		require.Equal(r, "backend", actual[0].Service.Meta["tier"])
+		require.NotContains(r, actual[0].Service.Meta, "missing")
+	})
+}
+
+func TestParseServiceMetaTemplate(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		raw    string
+		expKey string
+		expErr string
+	}{
+		"valid":          {raw: "team={{ .Labels.team }}", expKey: "team"},
+		"missing key":    {raw: "={{ .Labels.team }}", expErr: `"={{ .Labels.team }}" must be formatted as key=template`},
+		"missing equals": {raw: "team", expErr: `"team" must be formatted as key=template`},
+		"invalid template": {
+			raw:    "team={{ .Labels.team",
+			expErr: "template: :1: unclosed action",
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			meta, err := ParseServiceMetaTemplate(c.raw)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expKey, meta.Key)
+		})
+	}
+}
+
 // Test that with LoadBalancerEndpointsSync set to true we track the IP of the endpoints not the LB IP/name.
 func TestServiceResource_lbRegisterEndpoints(t *testing.T) {
 	t.Parallel()
@@ -1143,6 +1381,99 @@ func TestServiceResource_clusterIP(t *testing.T) {
 	})
 }
 
+// Test that services are only registered once they have the minimum number of
+// ready endpoints and are deregistered when they fall below it.
+func TestServiceResource_minReadyEndpoints(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+	serviceResource.MinReadyEndpoints = 3
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the service and two of the three endpoints that are required.
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+
+	// Another service without endpoints has to be registered so that we know
+	// the first one was processed when it shows up.
+	bar := lbService("bar", metav1.NamespaceDefault, "3.3.3.3")
+	_, err = client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), bar, metav1.CreateOptions{})
+	require.NoError(t, err)
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+		require.Equal(r, "bar", actual[0].Service.Service)
+	})
+
+	// Add a third ready endpoint. A not ready address doesn't count.
+	endpoints, err := client.CoreV1().Endpoints(metav1.NamespaceDefault).Get(context.Background(), "foo", metav1.GetOptions{})
+	require.NoError(t, err)
+	endpoints.Subsets[1].NotReadyAddresses = []corev1.EndpointAddress{{IP: "4.4.4.4"}}
+	_, err = client.CoreV1().Endpoints(metav1.NamespaceDefault).Update(context.Background(), endpoints, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	endpoints.Subsets[1].Addresses = append(endpoints.Subsets[1].Addresses, corev1.EndpointAddress{IP: "3.3.3.3"})
+	_, err = client.CoreV1().Endpoints(metav1.NamespaceDefault).Update(context.Background(), endpoints, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		var foo int
+		for _, reg := range syncer.Registrations {
+			if reg.Service.Service == "foo" {
+				foo++
+			}
+		}
+		require.Equal(r, 3, foo)
+	})
+
+	// Remove an endpoint so the service falls below the threshold again.
+	endpoints.Subsets = endpoints.Subsets[1:]
+	_, err = client.CoreV1().Endpoints(metav1.NamespaceDefault).Update(context.Background(), endpoints, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+		require.Equal(r, "bar", actual[0].Service.Service)
+	})
+}
+
+// Test that the annotation overrides the minimum number of ready endpoints.
+func TestServiceResource_minReadyEndpointsAnnotation(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+	serviceResource.MinReadyEndpoints = 3
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	svc.Annotations[annotationServiceSyncMinReadyEndpoints] = "2"
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		require.Len(r, syncer.Registrations, 2)
+	})
+}
+
 // Test that the proper registrations with health checks are generated for a ClusterIP type.
 func TestServiceResource_clusterIP_healthCheck(t *testing.T) {
 	t.Parallel()
@@ -1255,6 +1586,162 @@ func TestServiceResource_clusterIPAnnotatedPortName(t *testing.T) {
 	})
 }
 
+// Test that each pod of a headless service is registered as an instance
+// that's tagged with its hostname.
+func TestServiceResource_headless(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+	serviceResource.ConsulK8STag = TestConsulK8STag
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the service
+	svc := clusterIPService("web", metav1.NamespaceDefault)
+	svc.Spec.ClusterIP = corev1.ClusterIPNone
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the endpoints of a StatefulSet, whose pods have a hostname.
+	_, err = client.CoreV1().Endpoints(metav1.NamespaceDefault).Create(context.Background(), &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault},
+		Subsets: []corev1.EndpointSubset{{
+			Addresses: []corev1.EndpointAddress{
+				{IP: "1.1.1.1", Hostname: "web-0", TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "web-0"}},
+				{IP: "2.2.2.2", TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "web-1"}},
+			},
+			Ports: []corev1.EndpointPort{{Name: "http", Port: 8080}},
+		}},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
+		require.Equal(r, []string{"k8s", "web-0"}, actual[0].Service.Tags)
+		require.Equal(r, "web-0", actual[0].Service.Meta[ConsulK8SPodName])
+		require.Equal(r, "1.1.1.1", actual[0].Service.Meta[ConsulK8SPodIP])
+		require.Equal(r, "2.2.2.2", actual[1].Service.Address)
+		require.Equal(r, []string{"k8s", "web-1"}, actual[1].Service.Tags)
+		require.Equal(r, "web-1", actual[1].Service.Meta[ConsulK8SPodName])
+		require.Equal(r, "2.2.2.2", actual[1].Service.Meta[ConsulK8SPodIP])
+	})
+}
+
+// Test that instances of services that aren't headless don't get pod tags.
+func TestServiceResource_notHeadless(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+	serviceResource.ConsulK8STag = TestConsulK8STag
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the service
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the endpoints
+	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		for _, reg := range actual {
+			require.Equal(r, []string{"k8s"}, reg.Service.Tags)
+			require.NotContains(r, reg.Service.Meta, ConsulK8SPodName)
+			require.NotContains(r, reg.Service.Meta, ConsulK8SPodIP)
+		}
+	})
+}
+
+// Test that ports excluded by name or number aren't synced for a ClusterIP type.
+func TestServiceResource_clusterIPExcludedPorts(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		excludePorts string
+		expRegs      int
+		expPort      int
+		expMeta      map[string]bool
+	}{
+		"by name": {
+			excludePorts: "http",
+			expRegs:      2,
+			expPort:      2000,
+			expMeta:      map[string]bool{"port-http": false, "port-rpc": true},
+		},
+		"by port number": {
+			excludePorts: "80",
+			expRegs:      2,
+			expPort:      2000,
+			expMeta:      map[string]bool{"port-http": false, "port-rpc": true},
+		},
+		"unknown ports are ignored": {
+			excludePorts: "grpc, 9090",
+			expRegs:      2,
+			expPort:      8080,
+			expMeta:      map[string]bool{"port-http": true, "port-rpc": true},
+		},
+		"service isn't synced when all ports are excluded": {
+			excludePorts: "http,8500",
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			client := fake.NewSimpleClientset()
+			syncer := newTestSyncer()
+			serviceResource := defaultServiceResource(client, syncer)
+			serviceResource.ClusterIPSync = true
+
+			// Start the controller
+			closer := controller.TestControllerRun(&serviceResource)
+			defer closer()
+
+			// Insert the service
+			svc := clusterIPService("foo", metav1.NamespaceDefault)
+			svc.Annotations[annotationServiceSyncExcludePorts] = c.excludePorts
+			_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+			require.NoError(t, err)
+
+			// Insert the endpoints
+			createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+
+			// Verify what we got
+			retry.Run(t, func(r *retry.R) {
+				syncer.Lock()
+				defer syncer.Unlock()
+				actual := syncer.Registrations
+				require.Len(r, actual, c.expRegs)
+				for _, reg := range actual {
+					require.Equal(r, c.expPort, reg.Service.Port)
+					for k, exp := range c.expMeta {
+						_, ok := reg.Service.Meta[k]
+						require.Equal(r, exp, ok, k)
+					}
+				}
+			})
+		})
+	}
+}
+
 // Test that the proper registrations are generated for a ClusterIP type with
 // annotated port number override.
 func TestServiceResource_clusterIPAnnotatedPortNumber(t *testing.T) {
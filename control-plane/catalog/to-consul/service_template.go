@@ -0,0 +1,70 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package catalog
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ServiceMetaTemplate is a template for the value of a meta key of synced services.
+type ServiceMetaTemplate struct {
+	Key      string
+	Template *template.Template
+}
+
+// serviceTemplateData is the data that tag and meta templates are rendered with.
+type serviceTemplateData struct {
+	Name        string
+	Namespace   string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// ParseServiceTagTemplate parses a template for a tag of synced services,
+// e.g. "team-{{ .Labels.team }}".
+func ParseServiceTagTemplate(raw string) (*template.Template, error) {
+	return parseServiceTemplate(raw)
+}
+
+// ParseServiceMetaTemplate parses a template for a meta key of synced services
+// formatted as key=template, e.g. "team={{ .Labels.team }}".
+func ParseServiceMetaTemplate(raw string) (ServiceMetaTemplate, error) {
+	key, value, ok := strings.Cut(raw, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return ServiceMetaTemplate{}, fmt.Errorf("%q must be formatted as key=template", raw)
+	}
+	tmpl, err := parseServiceTemplate(value)
+	if err != nil {
+		return ServiceMetaTemplate{}, err
+	}
+	return ServiceMetaTemplate{Key: key, Template: tmpl}, nil
+}
+
+// parseServiceTemplate parses a template. Labels and annotations that a service
+// doesn't have render as empty strings.
+func parseServiceTemplate(raw string) (*template.Template, error) {
+	return template.New("").Option("missingkey=zero").Parse(raw)
+}
+
+// renderServiceTemplate renders the template with the service's name, namespace,
+// labels and annotations.
+func renderServiceTemplate(tmpl *template.Template, svc *corev1.Service) (string, error) {
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, serviceTemplateData{
+		Name:        svc.Name,
+		Namespace:   svc.Namespace,
+		Labels:      svc.Labels,
+		Annotations: svc.Annotations,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
@@ -6,12 +6,15 @@ package catalog
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff"
 	mapset "github.com/deckarep/golang-set"
+	"github.com/hashicorp/consul-k8s/control-plane/catalog/metrics"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/correlation"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-hclog"
@@ -59,6 +62,12 @@ type ConsulSyncer struct {
 	// Only necessary if ACLs are enabled.
 	CrossNamespaceACLPolicy string
 
+	// DestinationNamespace, if set, is the Consul namespace that all services
+	// are registered into instead of the namespace of their registration. It
+	// maps services to a different namespace when syncing to an additional
+	// datacenter or partition. Only used if EnableNamespaces is true.
+	DestinationNamespace string
+
 	// SyncPeriod is the interval between full catalog syncs. These will
 	// re-register all services to prevent overwrites of data. This should
 	// happen relatively infrequently and default to 30 seconds.
@@ -72,15 +81,31 @@ type ConsulSyncer struct {
 	SyncPeriod        time.Duration
 	ServicePollPeriod time.Duration
 
+	// FullSyncPeriod is the interval between full reconciles, which scan
+	// the Consul nodes for invalid services instead of relying on blocking
+	// queries before re-registering all services. Full reconciles can also
+	// be triggered with TriggerFullSync. Zero disables the periodic ones.
+	FullSyncPeriod time.Duration
+
 	// ConsulK8STag is the tag value for services registered.
 	ConsulK8STag string
 
 	// The Consul node name to register services with.
 	ConsulNodeName string
 
+	// ConsulNodeShards is the number of Consul nodes that the services are
+	// spread across, as ServiceResource.ConsulNodeShards. Services are reaped
+	// from all of them and from ConsulNodeName, so that the services of an
+	// unsharded sync are cleaned up when sharding is enabled, and from the
+	// shard nodes that were dropped when the number of shards was lowered.
+	ConsulNodeShards int
+
 	lock sync.Mutex
 	once sync.Once
 
+	// fullSyncCh holds a pending full reconcile triggered by TriggerFullSync.
+	fullSyncCh chan struct{}
+
 	// initialSync is used to ensure that we have received our initial list
 	// of services before we start reaping services. When it is closed,
 	// the initial sync is complete.
@@ -98,6 +123,19 @@ type ConsulSyncer struct {
 	namespaces map[string]map[string]*api.CatalogRegistration
 	deregs     map[string]*api.CatalogDeregistration
 
+	// deregNames maps the service IDs in deregs to their service names
+	// so that deregistrations can be reported per service.
+	deregNames map[string]string
+
+	// lastSynced is all namespaces mapped to a map of Consul service
+	// ids mapped to the time they were last successfully registered.
+	lastSynced map[string]map[string]time.Time
+
+	// registered is all namespaces mapped to a map of Consul service ids
+	// mapped to the registration last successfully written to Consul,
+	// including its last sync meta.
+	registered map[string]map[string]*api.CatalogRegistration
+
 	// watchers is all namespaces mapped to a map of Consul service
 	// names mapped to a cancel function for watcher routines
 	watchers map[string]map[string]context.CancelFunc
@@ -113,6 +151,10 @@ func (s *ConsulSyncer) Sync(rs []*api.CatalogRegistration) {
 	s.namespaces = make(map[string]map[string]*api.CatalogRegistration)
 
 	for _, r := range rs {
+		if s.EnableNamespaces && s.DestinationNamespace != "" {
+			r = withNamespace(r, s.DestinationNamespace)
+		}
+
 		// Determine the namespace the service is in to use for indexing
 		// against the s.serviceNames and s.namespaces maps.
 		// This will be "" for OSS.
@@ -149,6 +191,14 @@ func (s *ConsulSyncer) Run(ctx context.Context) {
 	reconcileTimer := time.NewTimer(s.SyncPeriod)
 	defer reconcileTimer.Stop()
 
+	// fullSyncTickCh is nil, and never ready, if periodic full reconciles are disabled.
+	var fullSyncTickCh <-chan time.Time
+	if s.FullSyncPeriod > 0 {
+		fullSyncTicker := time.NewTicker(s.FullSyncPeriod)
+		defer fullSyncTicker.Stop()
+		fullSyncTickCh = fullSyncTicker.C
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -158,31 +208,162 @@ func (s *ConsulSyncer) Run(ctx context.Context) {
 		case <-reconcileTimer.C:
 			s.syncFull(ctx)
 			reconcileTimer.Reset(s.SyncPeriod)
+
+		case <-fullSyncTickCh:
+			s.reconcileFull(ctx)
+
+		case <-s.fullSyncCh:
+			s.reconcileFull(ctx)
 		}
 	}
 }
 
-// watchReapableServices is a long-running task started by Run that
-// holds blocking queries to the Consul server to watch for any services
-// tagged with k8s that are no longer valid and need to be deleted.
-// This task only marks them for deletion but doesn't perform the actual
-// deletion.
-func (s *ConsulSyncer) watchReapableServices(ctx context.Context) {
-	// We must wait for the initial sync to be complete and our maps to be
-	// populated. If we don't wait, we will reap all services tagged with k8s
-	// because we have no tracked services in our maps yet.
-	<-s.initialSync
+// TriggerFullSync schedules a full reconcile, e.g. after Consul was restored
+// from a snapshot and the incremental state of the syncer is stale. It
+// doesn't block, and triggers while a full reconcile is pending are merged.
+func (s *ConsulSyncer) TriggerFullSync() {
+	s.once.Do(s.init)
+	select {
+	case s.fullSyncCh <- struct{}{}:
+	default:
+	}
+}
 
+// reconcileFull schedules every invalid service on the Consul nodes of the
+// sync for deregistration, reading the nodes directly rather than waiting
+// on the blocking queries of watchReapableServices, and then registers all
+// services.
+func (s *ConsulSyncer) reconcileFull(ctx context.Context) {
+	s.Log.Info("starting full reconcile")
+	s.lock.Lock()
+	select {
+	case <-s.initialSync:
+		s.reapNodesLocked(ctx)
+	default:
+		// Without the initial sync every service would be invalid.
+		s.Log.Info("initial sync not complete, not reaping services in full reconcile")
+	}
+	s.lock.Unlock()
+
+	s.syncFull(ctx)
+}
+
+// reapNodesLocked schedules the invalid services on all of the Consul nodes
+// of the sync for deregistration.
+//
+// Precondition: lock must be held.
+func (s *ConsulSyncer) reapNodesLocked(ctx context.Context) {
+	consulClient, err := consul.NewClientFromConnMgr(s.ConsulClientConfig, s.ConsulServerConnMgr)
+	if err != nil {
+		s.Log.Error("failed to create Consul API client", "err", err)
+		return
+	}
+	nodes, err := s.nodeNames(ctx, consulClient)
+	if err != nil {
+		s.Log.Warn("error querying sync nodes, only reaping the nodes of the current shards", "err", err)
+	}
+	for _, node := range nodes {
+		services, _, err := consulClient.Catalog().NodeServiceList(node, s.reapableQueryOptions().WithContext(ctx))
+		if err != nil {
+			metrics.ConsulAPIErrors.WithLabelValues(metrics.DirectionToConsul, "node_service_list").Inc()
+			s.Log.Warn("error querying services", "node-name", node, "err", err)
+			continue
+		}
+		s.scheduleReapInvalidServicesLocked(services)
+	}
+}
+
+// nodeNames returns the names of the Consul nodes to reap services from. These
+// are the nodes of the current shards and ConsulNodeName, and any other node
+// with the meta of sync nodes that is a shard node of ConsulNodeName. The
+// latter are left behind when ConsulNodeShards is lowered, and their services
+// would otherwise never be deregistered. If the nodes can't be queried, the
+// nodes of the current shards are returned with the error.
+func (s *ConsulSyncer) nodeNames(ctx context.Context, consulClient *api.Client) ([]string, error) {
+	nodes := ConsulNodeNames(s.ConsulNodeName, s.ConsulNodeShards)
+	if s.ConsulNodeShards > 1 {
+		nodes = append(nodes, s.ConsulNodeName)
+	}
 	opts := &api.QueryOptions{
-		AllowStale: true,
-		WaitIndex:  1,
-		WaitTime:   1 * time.Minute,
-		Filter:     fmt.Sprintf("\"%s\" in Tags", s.ConsulK8STag),
+		AllowStale: s.ConsulClientConfig.AllowStale,
+		NodeMeta:   map[string]string{ConsulSourceKey: ConsulSourceValue},
+	}
+	catalogNodes, _, err := consulClient.Catalog().Nodes(opts.WithContext(ctx))
+	if err != nil {
+		metrics.ConsulAPIErrors.WithLabelValues(metrics.DirectionToConsul, "nodes").Inc()
+		return nodes, err
 	}
+	current := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		current[node] = true
+	}
+	for _, node := range catalogNodes {
+		if !current[node.Node] && isShardNodeName(s.ConsulNodeName, node.Node) {
+			nodes = append(nodes, node.Node)
+		}
+	}
+	return nodes, nil
+}
 
+// reapableQueryOptions returns the options to query the services of a node
+// that were synced from K8s.
+func (s *ConsulSyncer) reapableQueryOptions() *api.QueryOptions {
+	opts := &api.QueryOptions{
+		AllowStale: s.ConsulClientConfig.AllowStale,
+		Filter:     fmt.Sprintf("\"%s\" in Tags", s.ConsulK8STag),
+	}
 	if s.EnableNamespaces {
 		opts.Namespace = "*"
 	}
+	return opts
+}
+
+// watchReapableServices is a long-running task started by Run that
+// watches the services of every Consul node that services are registered
+// with for services that are no longer valid.
+func (s *ConsulSyncer) watchReapableServices(ctx context.Context) {
+	consulClient, err := consul.NewClientFromConnMgr(s.ConsulClientConfig, s.ConsulServerConnMgr)
+	if err != nil {
+		s.Log.Error("failed to create Consul API client", "err", err)
+		return
+	}
+	var nodes []string
+	err = backoff.Retry(func() error {
+		nodes, err = s.nodeNames(ctx, consulClient)
+		return err
+	}, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		s.Log.Warn("error querying sync nodes, only watching the nodes of the current shards", "err", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, node := range nodes {
+		node := node
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.watchReapableNodeServices(ctx, node)
+		}()
+	}
+	wg.Wait()
+}
+
+// watchReapableNodeServices holds blocking queries to the Consul server
+// to watch for any services of the node tagged with k8s that are no longer
+// valid and need to be deleted. This task only marks them for deletion but
+// doesn't perform the actual deletion.
+func (s *ConsulSyncer) watchReapableNodeServices(ctx context.Context, node string) {
+	// We must wait for the initial sync to be complete and our maps to be
+	// populated. If we don't wait, we will reap all services tagged with k8s
+	// because we have no tracked services in our maps yet.
+	<-s.initialSync
+
+	opts := s.reapableQueryOptions()
+	opts.WaitIndex = 1
+	opts.WaitTime = 1 * time.Minute
 
 	// minWait is the minimum time to wait between scheduling service deletes.
 	// This prevents a lot of churn in services causing high CPU usage.
@@ -199,12 +380,15 @@ func (s *ConsulSyncer) watchReapableServices(ctx context.Context) {
 		var services *api.CatalogNodeServiceList
 		var meta *api.QueryMeta
 		err = backoff.Retry(func() error {
-			services, meta, err = consulClient.Catalog().NodeServiceList(s.ConsulNodeName, opts)
+			services, meta, err = consulClient.Catalog().NodeServiceList(node, opts)
+			if err != nil {
+				metrics.ConsulAPIErrors.WithLabelValues(metrics.DirectionToConsul, "node_service_list").Inc()
+			}
 			return err
 		}, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
 
 		if err != nil {
-			s.Log.Warn("error querying services, will retry", "err", err)
+			s.Log.Warn("error querying services, will retry", "node-name", node, "err", err)
 		} else {
 			s.Log.Debug("[watchReapableServices] services returned from catalog",
 				"services", services)
@@ -227,37 +411,43 @@ func (s *ConsulSyncer) watchReapableServices(ctx context.Context) {
 
 		// Lock so we can modify the stored state
 		s.lock.Lock()
+		s.scheduleReapInvalidServicesLocked(services)
+		s.lock.Unlock()
+	}
+}
 
-		// Go through the service array and find services that should be reaped
-		for _, service := range services.Services {
-			// Check that the namespace exists in the valid service names map
-			// before checking whether it contains the service
-			svcNs := service.Namespace
-			if !s.EnableNamespaces {
-				// Set namespace to empty when namespaces are not enabled.
-				svcNs = ""
-			}
-			if _, ok := s.serviceNames[svcNs]; ok {
-				// We only care if we don't know about this service at all.
-				if s.serviceNames[svcNs].Contains(service.Service) {
-					s.Log.Debug("[watchReapableServices] serviceNames contains service",
-						"namespace", svcNs,
-						"service-name", service.Service)
-					continue
-				}
-			}
-
-			s.Log.Info("invalid service found, scheduling for delete",
-				"service-name", service.Service, "service-id", service.ID, "service-consul-namespace", svcNs)
-			if err = s.scheduleReapServiceLocked(service.Service, svcNs); err != nil {
-				s.Log.Info("error querying service for delete",
-					"service-name", service.Service,
-					"service-consul-namespace", svcNs,
-					"err", err)
+// scheduleReapInvalidServicesLocked schedules the services of the node that
+// aren't valid anymore for deregistration.
+//
+// Precondition: lock must be held.
+func (s *ConsulSyncer) scheduleReapInvalidServicesLocked(services *api.CatalogNodeServiceList) {
+	// Go through the service array and find services that should be reaped
+	for _, service := range services.Services {
+		// Check that the namespace exists in the valid service names map
+		// before checking whether it contains the service
+		svcNs := service.Namespace
+		if !s.EnableNamespaces {
+			// Set namespace to empty when namespaces are not enabled.
+			svcNs = ""
+		}
+		if _, ok := s.serviceNames[svcNs]; ok {
+			// We only care if we don't know about this service at all.
+			if s.serviceNames[svcNs].Contains(service.Service) {
+				s.Log.Debug("[watchReapableServices] serviceNames contains service",
+					"namespace", svcNs,
+					"service-name", service.Service)
+				continue
 			}
 		}
 
-		s.lock.Unlock()
+		s.Log.Info("invalid service found, scheduling for delete",
+			"service-name", service.Service, "service-id", service.ID, "service-consul-namespace", svcNs)
+		if err := s.scheduleReapServiceLocked(service.Service, svcNs); err != nil {
+			s.Log.Info("error querying service for delete",
+				"service-name", service.Service,
+				"service-consul-namespace", svcNs,
+				"err", err)
+		}
 	}
 }
 
@@ -279,7 +469,7 @@ func (s *ConsulSyncer) watchService(ctx context.Context, name, namespace string)
 
 		// Set up query options
 		queryOpts := &api.QueryOptions{
-			AllowStale: true,
+			AllowStale: s.ConsulClientConfig.AllowStale,
 		}
 		if s.EnableNamespaces {
 			// Sets the Consul namespace to query the catalog
@@ -296,6 +486,9 @@ func (s *ConsulSyncer) watchService(ctx context.Context, name, namespace string)
 		var services []*api.CatalogService
 		err = backoff.Retry(func() error {
 			services, _, err = consulClient.Catalog().Service(name, s.ConsulK8STag, queryOpts)
+			if err != nil {
+				metrics.ConsulAPIErrors.WithLabelValues(metrics.DirectionToConsul, "catalog_service").Inc()
+			}
 			return err
 		}, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
 		if err != nil {
@@ -312,8 +505,11 @@ func (s *ConsulSyncer) watchService(ctx context.Context, name, namespace string)
 		for _, svc := range services {
 			// Make sure the namespace exists before we run checks against it
 			if _, ok := s.serviceNames[namespace]; ok {
-				// If the service is valid and its info isn't nil, we don't deregister it
-				if s.serviceNames[namespace].Contains(svc.ServiceName) && s.namespaces[namespace][svc.ServiceID] != nil {
+				// If the service is valid, its info isn't nil and it's registered
+				// with the expected node, we don't deregister it. Instances on
+				// other nodes are left over from a change of the node sharding.
+				r := s.namespaces[namespace][svc.ServiceID]
+				if s.serviceNames[namespace].Contains(svc.ServiceName) && r != nil && r.Node == svc.Node {
 					continue
 				}
 			}
@@ -325,6 +521,7 @@ func (s *ConsulSyncer) watchService(ctx context.Context, name, namespace string)
 			if s.EnableNamespaces {
 				s.deregs[svc.ServiceID].Namespace = namespace
 			}
+			s.deregNames[svc.ServiceID] = svc.ServiceName
 			s.Log.Debug("[watchService] service being scheduled for deregistration",
 				"namespace", namespace,
 				"service name", svc.ServiceName,
@@ -342,7 +539,7 @@ func (s *ConsulSyncer) watchService(ctx context.Context, name, namespace string)
 // Precondition: lock must be held.
 func (s *ConsulSyncer) scheduleReapServiceLocked(name, namespace string) error {
 	// Set up query options
-	opts := api.QueryOptions{AllowStale: true}
+	opts := api.QueryOptions{AllowStale: s.ConsulClientConfig.AllowStale}
 	if s.EnableNamespaces {
 		opts.Namespace = namespace
 	}
@@ -357,6 +554,7 @@ func (s *ConsulSyncer) scheduleReapServiceLocked(name, namespace string) error {
 	// Only consider services that are tagged from k8s
 	services, _, err := consulClient.Catalog().Service(name, s.ConsulK8STag, &opts)
 	if err != nil {
+		metrics.ConsulAPIErrors.WithLabelValues(metrics.DirectionToConsul, "catalog_service").Inc()
 		return err
 	}
 
@@ -369,6 +567,7 @@ func (s *ConsulSyncer) scheduleReapServiceLocked(name, namespace string) error {
 		if s.EnableNamespaces {
 			s.deregs[svc.ServiceID].Namespace = namespace
 		}
+		s.deregNames[svc.ServiceID] = svc.ServiceName
 		s.Log.Debug("[scheduleReapServiceLocked] service being scheduled for deregistration",
 			"namespace", namespace,
 			"service name", svc.ServiceName,
@@ -386,14 +585,25 @@ func (s *ConsulSyncer) syncFull(ctx context.Context) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
+	start := time.Now()
+	defer func() {
+		metrics.SyncDuration.WithLabelValues(metrics.DirectionToConsul).Observe(time.Since(start).Seconds())
+	}()
+
+	// Tag logs and Consul requests from this sync with a correlation ID so
+	// they can be traced back to the sync that made them.
+	correlationID := correlation.NewID()
+	log := s.Log.With(correlation.LogKey, correlationID)
+
 	// Create a new consul client.
 	consulClient, err := consul.NewClientFromConnMgr(s.ConsulClientConfig, s.ConsulServerConnMgr)
 	if err != nil {
-		s.Log.Error("failed to create Consul API client", "err", err)
+		log.Error("failed to create Consul API client", "err", err)
 		return
 	}
+	correlation.SetConsulHeader(consulClient, correlationID)
 
-	s.Log.Info("registering services")
+	log.Info("registering services")
 
 	// Update the service watchers
 	for ns, watchers := range s.watchers {
@@ -403,7 +613,7 @@ func (s *ConsulSyncer) syncFull(ctx context.Context) {
 			if s.serviceNames[ns] == nil || !s.serviceNames[ns].Contains(svc) {
 				cf()
 				delete(s.watchers[ns], svc)
-				s.Log.Debug("[syncFull] deleting service watcher", "namespace", ns, "service", svc)
+				log.Debug("[syncFull] deleting service watcher", "namespace", ns, "service", svc)
 			}
 		}
 	}
@@ -414,7 +624,7 @@ func (s *ConsulSyncer) syncFull(ctx context.Context) {
 			if _, ok := s.watchers[ns][svc.(string)]; !ok {
 				svcCtx, cancelF := context.WithCancel(ctx)
 				go s.watchService(svcCtx, svc.(string), ns)
-				s.Log.Debug("[syncFull] starting watchService routine", "namespace", ns, "service", svc)
+				log.Debug("[syncFull] starting watchService routine", "namespace", ns, "service", svc)
 
 				// Create watcher map if it doesn't exist for this namespace
 				if s.watchers[ns] == nil {
@@ -427,33 +637,47 @@ func (s *ConsulSyncer) syncFull(ctx context.Context) {
 		}
 	}
 
+	// Track every write we are about to make as backlog until it succeeds.
+	backlog := metrics.Backlog.WithLabelValues(metrics.DirectionToConsul)
+	pending := len(s.deregs)
+	for _, services := range s.namespaces {
+		pending += len(services)
+	}
+	backlog.Set(float64(pending))
+
 	// Do all deregistrations first.
 	for _, r := range s.deregs {
-		s.Log.Info("deregistering service",
+		log.Info("deregistering service",
 			"node-name", r.Node,
 			"service-id", r.ServiceID,
 			"service-consul-namespace", r.Namespace)
 		_, err = consulClient.Catalog().Deregister(r, nil)
 		if err != nil {
-			s.Log.Warn("error deregistering service",
+			metrics.ConsulAPIErrors.WithLabelValues(metrics.DirectionToConsul, "deregister").Inc()
+			log.Warn("error deregistering service",
 				"node-name", r.Node,
 				"service-id", r.ServiceID,
 				"service-consul-namespace", r.Namespace,
 				"err", err)
+			continue
 		}
+		metrics.ServicesDeregistered.WithLabelValues(metrics.DirectionToConsul, s.deregNames[r.ServiceID]).Inc()
+		backlog.Dec()
 	}
 
 	// Always clear deregistrations, they'll repopulate if we had errors
 	s.deregs = make(map[string]*api.CatalogDeregistration)
+	s.deregNames = make(map[string]string)
 
 	// Register all the services. This will overwrite any changes that
 	// may have been made to the registered services.
-	for _, services := range s.namespaces {
-		for _, r := range services {
+	for ns, services := range s.namespaces {
+		for id, r := range services {
 			if s.EnableNamespaces {
 				_, err = namespaces.EnsureExists(consulClient, r.Service.Namespace, s.CrossNamespaceACLPolicy)
 				if err != nil {
-					s.Log.Warn("error checking and creating Consul namespace",
+					metrics.ConsulAPIErrors.WithLabelValues(metrics.DirectionToConsul, "ensure_namespace").Inc()
+					log.Warn("error checking and creating Consul namespace",
 						"node-name", r.Node,
 						"service-name", r.Service.Service,
 						"consul-namespace-name", r.Service.Namespace,
@@ -462,24 +686,138 @@ func (s *ConsulSyncer) syncFull(ctx context.Context) {
 				}
 			}
 
-			// Register the service.
-			_, err = consulClient.Catalog().Register(r, nil)
+			// Register the service, recording when it was synced.
+			now := time.Now()
+			reg := s.stampedRegistrationLocked(ns, id, r, now)
+			changed := reg != s.registered[ns][id]
+			_, err = consulClient.Catalog().Register(reg, nil)
 			if err != nil {
-				s.Log.Warn("error registering service",
+				metrics.ConsulAPIErrors.WithLabelValues(metrics.DirectionToConsul, "register").Inc()
+				log.Warn("error registering service",
 					"node-name", r.Node,
 					"service-name", r.Service.Service,
 					"service", r.Service,
 					"err", err)
 				continue
 			}
+			// Periodic re-registrations of unchanged services don't count,
+			// only registrations that create or change a service.
+			if changed {
+				metrics.ServicesRegistered.WithLabelValues(metrics.DirectionToConsul, r.Service.Service).Inc()
+			}
+			backlog.Dec()
+			if s.lastSynced[ns] == nil {
+				s.lastSynced[ns] = make(map[string]time.Time)
+			}
+			s.lastSynced[ns][id] = now
+			if s.registered[ns] == nil {
+				s.registered[ns] = make(map[string]*api.CatalogRegistration)
+			}
+			s.registered[ns][id] = reg
 
-			s.Log.Debug("registered service instance",
+			log.Debug("registered service instance",
 				"node-name", r.Node,
 				"service-name", r.Service.Service,
 				"consul-namespace-name", r.Service.Namespace,
 				"service", r.Service)
 		}
 	}
+
+	s.updateOldestSyncLocked()
+}
+
+// updateOldestSyncLocked drops the last sync times and registrations of
+// services that are no longer managed and reports the oldest remaining sync time.
+//
+// Precondition: lock must be held.
+func (s *ConsulSyncer) updateOldestSyncLocked() {
+	for ns, services := range s.registered {
+		for id := range services {
+			if s.namespaces[ns][id] == nil {
+				delete(services, id)
+			}
+		}
+		if len(services) == 0 {
+			delete(s.registered, ns)
+		}
+	}
+
+	var oldest time.Time
+	for ns, services := range s.lastSynced {
+		for id, t := range services {
+			if s.namespaces[ns][id] == nil {
+				delete(services, id)
+				continue
+			}
+			if oldest.IsZero() || t.Before(oldest) {
+				oldest = t
+			}
+		}
+		if len(services) == 0 {
+			delete(s.lastSynced, ns)
+		}
+	}
+
+	gauge := metrics.OldestServiceSync.WithLabelValues(metrics.DirectionToConsul)
+	if oldest.IsZero() {
+		gauge.Set(0)
+		return
+	}
+	gauge.Set(float64(oldest.Unix()))
+}
+
+// stampedRegistrationLocked returns the registration to write for r. If r
+// hasn't changed since it was last written, the registration last written is
+// returned as is, so that its last sync meta doesn't turn an otherwise
+// unchanged registration into a modification of the catalog. Otherwise r is
+// stamped with t.
+//
+// Precondition: lock must be held.
+func (s *ConsulSyncer) stampedRegistrationLocked(ns, id string, r *api.CatalogRegistration, t time.Time) *api.CatalogRegistration {
+	if prev := s.registered[ns][id]; prev != nil {
+		if reflect.DeepEqual(withLastSyncMeta(r, prev.Service.Meta[ConsulK8SLastSync]), prev) {
+			return prev
+		}
+	}
+	return withLastSync(r, t)
+}
+
+// withLastSync returns a copy of r with the sync time recorded in the
+// service meta. A copy is made so that the registration held by the
+// syncer, which is shared with the resource that generated it, isn't modified.
+func withLastSync(r *api.CatalogRegistration, t time.Time) *api.CatalogRegistration {
+	return withLastSyncMeta(r, t.UTC().Format(time.RFC3339))
+}
+
+// withLastSyncMeta returns a copy of r with the given last sync meta value.
+func withLastSyncMeta(r *api.CatalogRegistration, value string) *api.CatalogRegistration {
+	service := *r.Service
+	service.Meta = make(map[string]string, len(r.Service.Meta)+1)
+	for k, v := range r.Service.Meta {
+		service.Meta[k] = v
+	}
+	service.Meta[ConsulK8SLastSync] = value
+
+	reg := *r
+	reg.Service = &service
+	return &reg
+}
+
+// withNamespace returns a copy of r with the service and its health check
+// in the given Consul namespace. A copy is made so that registrations shared
+// with other syncers aren't modified.
+func withNamespace(r *api.CatalogRegistration, namespace string) *api.CatalogRegistration {
+	service := *r.Service
+	service.Namespace = namespace
+
+	reg := *r
+	reg.Service = &service
+	if r.Check != nil {
+		check := *r.Check
+		check.Namespace = namespace
+		reg.Check = &check
+	}
+	return &reg
 }
 
 func (s *ConsulSyncer) init() {
@@ -494,6 +832,15 @@ func (s *ConsulSyncer) init() {
 	if s.deregs == nil {
 		s.deregs = make(map[string]*api.CatalogDeregistration)
 	}
+	if s.deregNames == nil {
+		s.deregNames = make(map[string]string)
+	}
+	if s.lastSynced == nil {
+		s.lastSynced = make(map[string]map[string]time.Time)
+	}
+	if s.registered == nil {
+		s.registered = make(map[string]map[string]*api.CatalogRegistration)
+	}
 	if s.watchers == nil {
 		s.watchers = make(map[string]map[string]context.CancelFunc)
 	}
@@ -506,4 +853,7 @@ func (s *ConsulSyncer) init() {
 	if s.initialSync == nil {
 		s.initialSync = make(chan bool)
 	}
+	if s.fullSyncCh == nil {
+		s.fullSyncCh = make(chan struct{}, 1)
+	}
 }
@@ -5,19 +5,23 @@ package catalog
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/hashicorp/consul-k8s/control-plane/catalog/metrics"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/test"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/hashicorp/go-hclog"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -60,9 +64,99 @@ func TestConsulSyncer_register(t *testing.T) {
 	require.Equal(t, "k8s-sync", service.Node)
 	require.Equal(t, "bar", service.ServiceName)
 	require.Equal(t, "127.0.0.1", service.Address)
+	require.NotEmpty(t, service.ServiceMeta[ConsulK8SLastSync])
+}
+
+// Test that the oldest sync time only considers services that are still managed
+// and that registrations held by the syncer are not modified.
+func TestConsulSyncer_updateOldestSync(t *testing.T) {
+	t.Parallel()
+
+	s := &ConsulSyncer{}
+	s.init()
+
+	reg := testRegistration(ConsulSyncNodeName, "bar", "default")
+	s.namespaces[""] = map[string]*api.CatalogRegistration{reg.Service.ID: reg}
+
+	older := time.Unix(1000, 0)
+	newer := time.Unix(2000, 0)
+	s.lastSynced[""] = map[string]time.Time{
+		reg.Service.ID: newer,
+		"removed":      older,
+	}
+
+	s.updateOldestSyncLocked()
+	require.Equal(t, float64(newer.Unix()),
+		testutil.ToFloat64(metrics.OldestServiceSync.WithLabelValues(metrics.DirectionToConsul)))
+	require.NotContains(t, s.lastSynced[""], "removed")
+
+	synced := withLastSync(reg, newer)
+	require.Equal(t, newer.UTC().Format(time.RFC3339), synced.Service.Meta[ConsulK8SLastSync])
+	require.NotContains(t, reg.Service.Meta, ConsulK8SLastSync)
+}
+
+// Test that an unchanged registration keeps the last sync meta it was
+// written with, so that re-registering it doesn't modify the catalog.
+func TestConsulSyncer_stampedRegistration(t *testing.T) {
+	t.Parallel()
+
+	s := &ConsulSyncer{}
+	s.init()
+
+	reg := testRegistration(ConsulSyncNodeName, "bar", "default")
+	first := time.Unix(1000, 0)
+	written := s.stampedRegistrationLocked("", reg.Service.ID, reg, first)
+	require.Equal(t, first.UTC().Format(time.RFC3339), written.Service.Meta[ConsulK8SLastSync])
+	s.registered[""] = map[string]*api.CatalogRegistration{reg.Service.ID: written}
+
+	// An equal registration, e.g. regenerated from the same Kubernetes
+	// service, is written with the same meta.
+	second := time.Unix(2000, 0)
+	regenerated := testRegistration(ConsulSyncNodeName, "bar", "default")
+	require.Same(t, written, s.stampedRegistrationLocked("", reg.Service.ID, regenerated, second))
+
+	// A changed registration is stamped with the new sync time.
+	changed := testRegistration(ConsulSyncNodeName, "bar", "default")
+	changed.Service.Port = 8080
+	require.Equal(t, second.UTC().Format(time.RFC3339),
+		s.stampedRegistrationLocked("", reg.Service.ID, changed, second).Service.Meta[ConsulK8SLastSync])
+
+	// Registrations of services that are no longer managed are dropped.
+	s.updateOldestSyncLocked()
+	require.Empty(t, s.registered)
 }
 
 // Test that the syncer reaps individual invalid service instances.
+// Test that a MultiSyncer passes the registrations to every syncer and that
+// a syncer with a destination namespace registers services in that namespace
+// without modifying the registrations shared with the other syncers.
+func TestConsulSyncer_MultiSyncerWithDestinationNamespace(t *testing.T) {
+	t.Parallel()
+
+	primary := &ConsulSyncer{Log: hclog.NewNullLogger(), EnableNamespaces: true}
+	primary.init()
+	destination := &ConsulSyncer{Log: hclog.NewNullLogger(), EnableNamespaces: true, DestinationNamespace: "k8s"}
+	destination.init()
+
+	reg := testRegistration(ConsulSyncNodeName, "bar", "default")
+	reg.Service.Namespace = "default"
+	reg.Check = &api.AgentCheck{CheckID: "bar-check", Namespace: "default"}
+	MultiSyncer{primary, destination}.Sync([]*api.CatalogRegistration{reg})
+
+	require.Same(t, reg, primary.namespaces["default"][reg.Service.ID])
+	require.True(t, primary.serviceNames["default"].Contains("bar"))
+
+	require.Empty(t, destination.namespaces["default"])
+	synced := destination.namespaces["k8s"][reg.Service.ID]
+	require.NotNil(t, synced)
+	require.Equal(t, "k8s", synced.Service.Namespace)
+	require.Equal(t, "k8s", synced.Check.Namespace)
+	require.True(t, destination.serviceNames["k8s"].Contains("bar"))
+
+	require.Equal(t, "default", reg.Service.Namespace)
+	require.Equal(t, "default", reg.Check.Namespace)
+}
+
 func TestConsulSyncer_reapServiceInstance(t *testing.T) {
 	t.Parallel()
 
@@ -207,10 +301,175 @@ func TestConsulSyncer_noReapingUntilInitialSync(t *testing.T) {
 	})
 }
 
+// Test that a triggered full reconcile reaps invalid services from every
+// node and registers all services without waiting for the blocking queries.
+func TestConsulSyncer_triggerFullSync(t *testing.T) {
+	t.Parallel()
+
+	var lock sync.Mutex
+	var deregistered, registered []string
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch {
+		case r.URL.Query().Get("index") != "":
+			// Blocking queries don't see any changes until the test ends.
+			w.WriteHeader(500)
+		case r.URL.Path == "/v1/catalog/node-services/k8s-sync-0" || r.URL.Path == "/v1/catalog/node-services/k8s-sync-1":
+			require.NoError(t, json.NewEncoder(w).Encode(api.CatalogNodeServiceList{
+				Services: []*api.AgentService{{ID: "foo-1", Service: "foo", Tags: []string{TestConsulK8STag}}},
+			}))
+		case r.URL.Path == "/v1/catalog/node-services/k8s-sync":
+			require.NoError(t, json.NewEncoder(w).Encode(api.CatalogNodeServiceList{}))
+		case r.URL.Path == "/v1/catalog/service/foo":
+			require.NoError(t, json.NewEncoder(w).Encode([]*api.CatalogService{{Node: "k8s-sync-1", ServiceID: "foo-1", ServiceName: "foo"}}))
+		case r.URL.Path == "/v1/catalog/deregister":
+			var dereg api.CatalogDeregistration
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&dereg))
+			deregistered = append(deregistered, dereg.Node+"/"+dereg.ServiceID)
+		case r.URL.Path == "/v1/catalog/register":
+			var reg api.CatalogRegistration
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&reg))
+			registered = append(registered, reg.Node+"/"+reg.Service.ID)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer consulServer.Close()
+
+	parsedURL, err := url.Parse(consulServer.URL)
+	require.NoError(t, err)
+
+	port, err := strconv.Atoi(parsedURL.Port())
+	require.NoError(t, err)
+
+	testClient := &test.TestServerClient{
+		Cfg:     &consul.Config{APIClientConfig: &api.Config{}, HTTPPort: port},
+		Watcher: test.MockConnMgrForIPAndPort(parsedURL.Hostname(), port),
+	}
+
+	// Start the syncer without periodic syncs.
+	s, closer := testConsulSyncerWithConfig(testClient, func(s *ConsulSyncer) {
+		s.SyncPeriod = time.Hour
+		s.ConsulNodeShards = 2
+	})
+	defer closer()
+
+	reg := testRegistration("k8s-sync-0", "bar", "default")
+	s.Sync([]*api.CatalogRegistration{reg})
+	s.TriggerFullSync()
+
+	retry.Run(t, func(r *retry.R) {
+		lock.Lock()
+		defer lock.Unlock()
+		require.Equal(r, []string{"k8s-sync-1/foo-1"}, deregistered)
+		require.Equal(r, []string{"k8s-sync-0/" + reg.Service.ID}, registered)
+	})
+}
+
+// Test that only registrations that create or change a service are counted
+// as registered, not the periodic re-registrations of unchanged services.
+func TestConsulSyncer_servicesRegisteredMetric(t *testing.T) {
+	t.Parallel()
+
+	var lock sync.Mutex
+	registers := 0
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch {
+		case r.URL.Query().Get("index") != "":
+			// Blocking queries don't see any changes until the test ends.
+			w.WriteHeader(500)
+		case r.URL.Path == "/v1/catalog/node-services/k8s-sync":
+			require.NoError(t, json.NewEncoder(w).Encode(api.CatalogNodeServiceList{}))
+		case r.URL.Path == "/v1/catalog/register":
+			registers++
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer consulServer.Close()
+
+	parsedURL, err := url.Parse(consulServer.URL)
+	require.NoError(t, err)
+
+	port, err := strconv.Atoi(parsedURL.Port())
+	require.NoError(t, err)
+
+	testClient := &test.TestServerClient{
+		Cfg:     &consul.Config{APIClientConfig: &api.Config{}, HTTPPort: port},
+		Watcher: test.MockConnMgrForIPAndPort(parsedURL.Hostname(), port),
+	}
+
+	// Start the syncer without periodic syncs.
+	s, closer := testConsulSyncerWithConfig(testClient, func(s *ConsulSyncer) {
+		s.SyncPeriod = time.Hour
+	})
+	defer closer()
+
+	registered := metrics.ServicesRegistered.WithLabelValues(metrics.DirectionToConsul, "metrics-bar")
+	initial := testutil.ToFloat64(registered)
+	fullSync := func(expRegisters int) {
+		s.TriggerFullSync()
+		retry.Run(t, func(r *retry.R) {
+			lock.Lock()
+			defer lock.Unlock()
+			require.Equal(r, expRegisters, registers)
+		})
+	}
+
+	reg := testRegistration(ConsulSyncNodeName, "metrics-bar", "default")
+	s.Sync([]*api.CatalogRegistration{reg})
+	fullSync(1)
+	require.Equal(t, initial+1, testutil.ToFloat64(registered))
+
+	// Re-registering the unchanged service isn't counted.
+	fullSync(2)
+	require.Equal(t, initial+1, testutil.ToFloat64(registered))
+
+	// Registering a change is.
+	changed := testRegistration(ConsulSyncNodeName, "metrics-bar", "default")
+	changed.Service.Port = 8080
+	s.Sync([]*api.CatalogRegistration{changed})
+	fullSync(3)
+	require.Equal(t, initial+2, testutil.ToFloat64(registered))
+}
+
 // Test that when the syncer is stopped, we don't continue to call the Consul
 // API. This test was added as a regression test after a bug was discovered
 // that after the context was cancelled, we would continue to make API calls
 // to the Consul API in a tight loop.
+// Test that services are reaped from the shard nodes that were dropped by
+// lowering the number of shards.
+func TestConsulSyncer_nodeNames(t *testing.T) {
+	t.Parallel()
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/catalog/nodes", r.URL.Path)
+		require.Equal(t, ConsulSourceKey+":"+ConsulSourceValue, r.URL.Query().Get("node-meta"))
+		require.NoError(t, json.NewEncoder(w).Encode([]*api.Node{
+			{Node: "k8s-sync"},
+			{Node: "k8s-sync-0"},
+			{Node: "k8s-sync-1"},
+			{Node: "k8s-sync-2"},
+			{Node: "k8s-sync-3"},
+			{Node: "other-sync-0"},
+		}))
+	}))
+	t.Cleanup(consulServer.Close)
+	consulClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+
+	s := &ConsulSyncer{
+		ConsulClientConfig: &consul.Config{APIClientConfig: &api.Config{}},
+		ConsulNodeName:     "k8s-sync",
+		ConsulNodeShards:   2,
+	}
+	nodes, err := s.nodeNames(context.Background(), consulClient)
+	require.NoError(t, err)
+	require.Equal(t, []string{"k8s-sync-0", "k8s-sync-1", "k8s-sync", "k8s-sync-2", "k8s-sync-3"}, nodes)
+}
+
 func TestConsulSyncer_stopsGracefully(t *testing.T) {
 	t.Parallel()
 
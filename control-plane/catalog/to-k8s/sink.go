@@ -9,7 +9,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/hashicorp/consul-k8s/control-plane/catalog/metrics"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/coalesce"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/correlation"
 	"github.com/hashicorp/go-hclog"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -26,8 +28,45 @@ const (
 	// K8SMaxPeriod is the maximum time to wait before forcing a sync, even
 	// if there are active changes going on.
 	K8SMaxPeriod = 5 * time.Second
+
+	// AnnotationSourceDatacenter, AnnotationSourceNamespace, AnnotationSourcePartition
+	// and AnnotationSourceNodes are the annotations on synced services with the
+	// Consul datacenter, namespace and partition that the service came from and
+	// the sorted, comma-separated names of the Consul nodes of its instances.
+	AnnotationSourceDatacenter = "consul.hashicorp.com/source-datacenter"
+	AnnotationSourceNamespace  = "consul.hashicorp.com/source-namespace"
+	AnnotationSourcePartition  = "consul.hashicorp.com/source-partition"
+	AnnotationSourceNodes      = "consul.hashicorp.com/source-nodes"
+
+	// LabelSyncedBy is the label on synced services with the write namespace
+	// of the sink that created them. Sinks that watch all namespaces only
+	// manage the services with their label, so that they don't delete the
+	// services of other installations.
+	LabelSyncedBy = "consul.hashicorp.com/synced-by"
 )
 
+// Lineage is where a synced service came from in Consul.
+type Lineage struct {
+	Datacenter string
+	Namespace  string
+	Partition  string
+	// Nodes are the sorted names of the Consul nodes of the service's instances.
+	Nodes []string
+}
+
+// annotations returns the lineage annotations of a synced service.
+func (l Lineage) annotations() map[string]string {
+	annotations := map[string]string{
+		AnnotationSourceDatacenter: l.Datacenter,
+		AnnotationSourceNamespace:  l.Namespace,
+		AnnotationSourceNodes:      strings.Join(l.Nodes, ","),
+	}
+	if l.Partition != "" {
+		annotations[AnnotationSourcePartition] = l.Partition
+	}
+	return annotations
+}
+
 // Sink is the destination where services are registered.
 //
 // While in practice we only have one sink (K8S), the interface abstraction
@@ -35,8 +74,11 @@ const (
 type Sink interface {
 	// SetServices is called with the services that should be created.
 	// The key is the service name and the destination is the external DNS
-	// entry to point to.
-	SetServices(map[string]string)
+	// entry to point to. The name can be prefixed with the Kubernetes
+	// namespace to create the service in, e.g. web/foo. The lineage of the
+	// services has the same keys, and services without lineage aren't
+	// annotated with it.
+	SetServices(services map[string]string, lineage map[string]Lineage)
 }
 
 // K8SSink is a Sink implementation that registers services with Kubernetes.
@@ -49,6 +91,11 @@ type K8SSink struct {
 	Namespace string               // Namespace is the namespace to sync to
 	Log       hclog.Logger         // Logger
 
+	// AllNamespaces is true if services can be synced to any namespace. The
+	// sink then watches services in all namespaces, and services that aren't
+	// prefixed with a namespace are synced to Namespace.
+	AllNamespaces bool
+
 	// SyncPeriod is the duration to wait between registering or deregistering
 	// services in Kubernetes. This can be fairly short since no work will be
 	// done if there are no changes.
@@ -61,32 +108,31 @@ type K8SSink struct {
 	lock sync.Mutex
 
 	// sourceServices holds Consul services that should be synced to Kube.
-	// It maps from Consul service names to Consul DNS entry, e.g.
-	// foo => foo.service.consul. It's populated from the Consul API.
-	// We lowercase the Consul service names and DNS entries
+	// It maps from Kube controller keys to Consul DNS entry, e.g.
+	// default/foo => foo.service.consul. It's populated from the Consul API.
+	// Controller keys are in the form <kube namespace>/<kube svc name>
+	// e.g. default/foo, and are the keys Kube uses to inform that something
+	// changed. We lowercase the Consul service names and DNS entries
 	// because Kube names must be lowercase.
 	sourceServices map[string]string
 
-	// keyToName maps from Kube controller keys to Kube service names.
-	// Controller keys are in the form <kube namespace>/<kube svc name>
-	// e.g. default/foo, and are the keys Kube uses to inform that something
-	// changed.
-	keyToName map[string]string
+	// sourceLineage holds the lineage of the Consul services in
+	// sourceServices, with the same keys.
+	sourceLineage map[string]Lineage
 
-	// serviceMap holds all Kubernetes service names in the namespaces
-	// we're watching. The keys are Kubernetes service names and there are no
-	// values.
+	// serviceMap holds all Kubernetes services in the namespaces we're
+	// watching. The keys are controller keys and there are no values.
 	serviceMap map[string]struct{}
 
 	// serviceMapConsul is a subset of serviceMap. It holds all Kube services
-	// that were created by this sync process. Keys are Kube service names.
+	// that were created by this sync process. Keys are controller keys.
 	// It's populated from Kubernetes data.
 	serviceMapConsul map[string]*apiv1.Service
 	triggerCh        chan struct{}
 }
 
 // SetServices implements Sink.
-func (s *K8SSink) SetServices(svcs map[string]string) {
+func (s *K8SSink) SetServices(svcs map[string]string, lineage map[string]Lineage) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
@@ -97,25 +143,46 @@ func (s *K8SSink) SetServices(svcs map[string]string) {
 	// case insensitive, i.e. there won't be two services with the same name
 	// but different cases, and so svcs will be unique even after lowercasing.
 	lowercasedSvcs := make(map[string]string)
+	lowercasedLineage := make(map[string]Lineage)
 	for consulName, consulDNS := range svcs {
-		lowercasedSvcs[strings.ToLower(consulName)] = strings.ToLower(consulDNS)
+		key := strings.ToLower(consulName)
+		if !strings.Contains(key, "/") {
+			key = s.namespace() + "/" + key
+		} else if ns, _, _ := strings.Cut(key, "/"); !s.AllNamespaces && ns != s.namespace() {
+			s.Log.Warn("service is in a namespace that isn't synced to, not registering", "name", consulName)
+			continue
+		}
+		lowercasedSvcs[key] = strings.ToLower(consulDNS)
+		if l, ok := lineage[consulName]; ok {
+			lowercasedLineage[key] = l
+		}
 	}
 
 	s.sourceServices = lowercasedSvcs
+	s.sourceLineage = lowercasedLineage
 	s.trigger() // Any service change probably requires syncing
 }
 
+// TriggerFullSync requests a sync of all services to Kubernetes, which retries
+// the creates, updates and deletes that failed. Requests are merged while one
+// is pending.
+func (s *K8SSink) TriggerFullSync() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.trigger()
+}
+
 // Informer implements the controller.Resource interface.
 // It tells Kubernetes that we want to watch for changes to Services.
 func (s *K8SSink) Informer() cache.SharedIndexInformer {
 	return cache.NewSharedIndexInformer(
 		&cache.ListWatch{
 			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-				return s.Client.CoreV1().Services(s.namespace()).List(s.Ctx, options)
+				return s.Client.CoreV1().Services(s.watchNamespace()).List(s.Ctx, options)
 			},
 
 			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-				return s.Client.CoreV1().Services(s.namespace()).Watch(s.Ctx, options)
+				return s.Client.CoreV1().Services(s.watchNamespace()).Watch(s.Ctx, options)
 			},
 		},
 		&apiv1.Service{},
@@ -136,26 +203,19 @@ func (s *K8SSink) Upsert(key string, raw interface{}) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	// Store all the key to name mappings. We need this because the key
-	// is opaque but we want to do all the lookups by service name.
-	if s.keyToName == nil {
-		s.keyToName = make(map[string]string)
-	}
-	s.keyToName[key] = service.Name
-
 	if s.serviceMap == nil {
 		s.serviceMap = make(map[string]struct{})
 	}
-	s.serviceMap[service.Name] = struct{}{}
+	s.serviceMap[key] = struct{}{}
 
 	// If the service is a Consul-sourced service, then keep track of it
 	// separately for a quick lookup.
-	if service.Labels != nil && service.Labels["consul"] == "true" {
+	if s.owns(service) {
 		if s.serviceMapConsul == nil {
 			s.serviceMapConsul = make(map[string]*apiv1.Service)
 		}
 
-		s.serviceMapConsul[service.Name] = service
+		s.serviceMapConsul[key] = service
 		s.trigger() // Always trigger sync
 	}
 
@@ -163,13 +223,26 @@ func (s *K8SSink) Upsert(key string, raw interface{}) error {
 	return nil
 }
 
+// owns returns true if the service was created by this sink. When the sink
+// watches all namespaces, services outside of its namespace must also have
+// its LabelSyncedBy label, since other installations may sync services to
+// the same namespaces.
+func (s *K8SSink) owns(service *apiv1.Service) bool {
+	if service.Labels["consul"] != "true" {
+		return false
+	}
+	if !s.AllNamespaces || service.Namespace == s.namespace() {
+		return true
+	}
+	return service.Labels[LabelSyncedBy] == s.namespace()
+}
+
 // Delete implements the controller.Resource interface.
 func (s *K8SSink) Delete(key string, _ interface{}) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	name, ok := s.keyToName[key]
-	if !ok {
+	if _, ok := s.serviceMap[key]; !ok {
 		// This is a weird scenario, but in unit tests we've seen this happen
 		// in cases where the delete happens very quickly after the create.
 		// Just to be sure, lets trigger a sync. This is cheap cause it'll
@@ -178,17 +251,16 @@ func (s *K8SSink) Delete(key string, _ interface{}) error {
 		return nil
 	}
 
-	delete(s.keyToName, key)
-	delete(s.serviceMap, name)
-	delete(s.serviceMapConsul, name)
+	delete(s.serviceMap, key)
+	delete(s.serviceMapConsul, key)
 
 	// If the service that is deleted is part of Consul services, then
 	// we need to trigger a sync to recreate it.
-	if _, ok := s.sourceServices[name]; ok {
+	if _, ok := s.sourceServices[key]; ok {
 		s.trigger()
 	}
 
-	s.Log.Info("delete", "key", key, "name", name)
+	s.Log.Info("delete", "key", key)
 	return nil
 }
 
@@ -226,28 +298,44 @@ func (s *K8SSink) Run(ch <-chan struct{}) {
 		s.lock.Lock()
 		create, update, delete := s.crudList()
 		s.lock.Unlock()
-		s.Log.Debug("sync triggered", "create", len(create), "update", len(update), "delete", len(delete))
+		log := s.Log.With(correlation.LogKey, correlation.NewID())
+		log.Debug("sync triggered", "create", len(create), "update", len(update), "delete", len(delete))
 
-		svcClient := s.Client.CoreV1().Services(s.namespace())
-		for _, name := range delete {
-			if err := svcClient.Delete(s.Ctx, name, metav1.DeleteOptions{}); err != nil {
-				s.Log.Warn("error deleting service", "name", name, "error", err)
+		start := time.Now()
+		backlog := metrics.Backlog.WithLabelValues(metrics.DirectionToK8s)
+		backlog.Set(float64(len(create) + len(update) + len(delete)))
+
+		for _, key := range delete {
+			namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+			if err := s.Client.CoreV1().Services(namespace).Delete(s.Ctx, name, metav1.DeleteOptions{}); err != nil {
+				log.Warn("error deleting service", "name", name, "namespace", namespace, "error", err)
+				continue
 			}
+			metrics.ServicesDeregistered.WithLabelValues(metrics.DirectionToK8s, name).Inc()
+			backlog.Dec()
 		}
 
 		for _, svc := range update {
-			_, err := svcClient.Update(s.Ctx, svc, metav1.UpdateOptions{})
+			_, err := s.Client.CoreV1().Services(svc.Namespace).Update(s.Ctx, svc, metav1.UpdateOptions{})
 			if err != nil {
-				s.Log.Warn("error updating service", "name", svc.Name, "error", err)
+				log.Warn("error updating service", "name", svc.Name, "namespace", svc.Namespace, "error", err)
+				continue
 			}
+			metrics.ServicesRegistered.WithLabelValues(metrics.DirectionToK8s, svc.Name).Inc()
+			backlog.Dec()
 		}
 
 		for _, svc := range create {
-			_, err := svcClient.Create(s.Ctx, svc, metav1.CreateOptions{})
+			_, err := s.Client.CoreV1().Services(svc.Namespace).Create(s.Ctx, svc, metav1.CreateOptions{})
 			if err != nil {
-				s.Log.Warn("error creating service", "name", svc.Name, "error", err)
+				log.Warn("error creating service", "name", svc.Name, "namespace", svc.Namespace, "error", err)
+				continue
 			}
+			metrics.ServicesRegistered.WithLabelValues(metrics.DirectionToK8s, svc.Name).Inc()
+			backlog.Dec()
 		}
+
+		metrics.SyncDuration.WithLabelValues(metrics.DirectionToK8s).Observe(time.Since(start).Seconds())
 	}
 }
 
@@ -257,19 +345,26 @@ func (s *K8SSink) crudList() ([]*apiv1.Service, []*apiv1.Service, []string) {
 	var delete []string
 
 	// Determine what needs to be created or updated
-	for consulName, consulDNS := range s.sourceServices {
+	for key, consulDNS := range s.sourceServices {
 		// If this is an already registered service, then update it
+		lineage, hasLineage := s.sourceLineage[key]
 		if s.serviceMapConsul != nil {
-			if svc, ok := s.serviceMapConsul[consulName]; ok {
-				if svc.Spec.ExternalName == consulDNS {
+			if svc, ok := s.serviceMapConsul[key]; ok {
+				lineageChanged := hasLineage && !lineageMatches(svc, lineage)
+				if svc.Spec.ExternalName == consulDNS && !lineageChanged {
 					// Matching service, no update required.
 					continue
 				}
 
+				svc = svc.DeepCopy()
+				svc.Labels[LabelSyncedBy] = s.namespace()
 				svc.Spec = apiv1.ServiceSpec{
 					Type:         apiv1.ServiceTypeExternalName,
 					ExternalName: consulDNS,
 				}
+				if hasLineage {
+					setLineage(svc, lineage)
+				}
 
 				update = append(update, svc)
 				continue
@@ -277,16 +372,18 @@ func (s *K8SSink) crudList() ([]*apiv1.Service, []*apiv1.Service, []string) {
 		}
 
 		// If this is a registered K8S service, ignore.
-		if _, ok := s.serviceMap[consulName]; ok {
-			s.Log.Warn("service already registered in K8S, not registering", "name", consulName)
+		if _, ok := s.serviceMap[key]; ok {
+			s.Log.Warn("service already registered in K8S, not registering", "key", key)
 			continue
 		}
 
 		// Register!
-		create = append(create, &apiv1.Service{
+		namespace, consulName, _ := cache.SplitMetaNamespaceKey(key)
+		svc := &apiv1.Service{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:   consulName,
-				Labels: map[string]string{"consul": "true"},
+				Name:      consulName,
+				Namespace: namespace,
+				Labels:    map[string]string{"consul": "true", LabelSyncedBy: s.namespace()},
 				Annotations: map[string]string{
 					// Ensure we don't sync the service back to Consul
 					"consul.hashicorp.com/service-sync": "false",
@@ -297,7 +394,11 @@ func (s *K8SSink) crudList() ([]*apiv1.Service, []*apiv1.Service, []string) {
 				Type:         apiv1.ServiceTypeExternalName,
 				ExternalName: consulDNS,
 			},
-		})
+		}
+		if hasLineage {
+			setLineage(svc, lineage)
+		}
+		create = append(create, svc)
 	}
 
 	// Determine what needs to be deleted
@@ -310,6 +411,28 @@ func (s *K8SSink) crudList() ([]*apiv1.Service, []*apiv1.Service, []string) {
 	return create, update, delete
 }
 
+// lineageMatches returns true if the service is annotated with the lineage.
+func lineageMatches(svc *apiv1.Service, lineage Lineage) bool {
+	expected := lineage.annotations()
+	for _, key := range []string{AnnotationSourceDatacenter, AnnotationSourceNamespace, AnnotationSourcePartition, AnnotationSourceNodes} {
+		if svc.Annotations[key] != expected[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// setLineage replaces the lineage annotations of the service.
+func setLineage(svc *apiv1.Service, lineage Lineage) {
+	if svc.Annotations == nil {
+		svc.Annotations = make(map[string]string)
+	}
+	delete(svc.Annotations, AnnotationSourcePartition)
+	for k, v := range lineage.annotations() {
+		svc.Annotations[k] = v
+	}
+}
+
 // namespace returns the K8S namespace to setup the resource watchers in.
 func (s *K8SSink) namespace() string {
 	if s.Namespace != "" {
@@ -321,6 +444,14 @@ func (s *K8SSink) namespace() string {
 	return metav1.NamespaceDefault
 }
 
+// watchNamespace returns the K8S namespace to watch services in.
+func (s *K8SSink) watchNamespace() string {
+	if s.AllNamespaces {
+		return metav1.NamespaceAll
+	}
+	return s.namespace()
+}
+
 // trigger will notify a sync should occur. lock must be held.
 //
 // This is not synchronous and does not guarantee a sync will happen. This
@@ -5,16 +5,22 @@ package catalog
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
 	"testing"
 
+	"github.com/hashicorp/consul-k8s/control-plane/catalog/metrics"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/hashicorp/go-hclog"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func init() {
@@ -38,7 +44,7 @@ func TestK8SSink_create(t *testing.T) {
 	defer closer()
 
 	// Set a service
-	sink.SetServices(map[string]string{"web": "web.service.local."})
+	sink.SetServices(map[string]string{"web": "web.service.local."}, nil)
 
 	// Verify service gets registered
 	var actual *apiv1.ServiceList
@@ -65,6 +71,55 @@ func TestK8SSink_create(t *testing.T) {
 	require.True(found, "found service")
 }
 
+// Test that service creation is reported in the sync metrics.
+func TestK8SSink_createMetrics(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+
+	// Start the controller
+	sink, closer := testSink(t, client)
+	defer closer()
+
+	// Set a service
+	sink.SetServices(map[string]string{"metrics-web": "metrics-web.service.local."}, nil)
+
+	// Verify the registration is counted for the service.
+	retry.Run(t, func(r *retry.R) {
+		registered := testutil.ToFloat64(metrics.ServicesRegistered.WithLabelValues(metrics.DirectionToK8s, "metrics-web"))
+		require.Equal(r, float64(1), registered)
+	})
+}
+
+// Test that a full sync retries the writes that failed.
+func TestK8SSink_triggerFullSync(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	var creates int32
+	client.PrependReactor("create", "services", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if atomic.AddInt32(&creates, 1) == 1 {
+			return true, nil, errors.New("create failed")
+		}
+		return false, nil, nil
+	})
+
+	// Start the controller
+	sink, closer := testSink(t, client)
+	defer closer()
+
+	// Set a service, whose first create fails.
+	sink.SetServices(map[string]string{"retried-web": "retried-web.service.local."}, nil)
+	retry.Run(t, func(r *retry.R) {
+		require.Equal(r, int32(1), atomic.LoadInt32(&creates))
+	})
+
+	// Verify the service is created by the full sync.
+	sink.TriggerFullSync()
+	retry.Run(t, func(r *retry.R) {
+		_, err := client.CoreV1().Services(metav1.NamespaceDefault).Get(context.Background(), "retried-web", metav1.GetOptions{})
+		require.NoError(r, err)
+	})
+}
+
 // Test that we lowercase service names.
 func TestK8SSink_createUppercase(t *testing.T) {
 	t.Parallel()
@@ -76,7 +131,7 @@ func TestK8SSink_createUppercase(t *testing.T) {
 	defer closer()
 
 	// Set a service
-	sink.SetServices(map[string]string{"UPPERCASE": "UPPERCASE.service.local."})
+	sink.SetServices(map[string]string{"UPPERCASE": "UPPERCASE.service.local."}, nil)
 
 	// Verify service gets registered
 	var actual *apiv1.ServiceList
@@ -131,7 +186,7 @@ func TestK8SSink_createExists(t *testing.T) {
 	defer closer()
 
 	// Set a service
-	sink.SetServices(map[string]string{"web": "web.service.local."})
+	sink.SetServices(map[string]string{"web": "web.service.local."}, nil)
 
 	// Verify service gets registered
 	retry.Run(t, func(r *retry.R) {
@@ -170,7 +225,7 @@ func TestK8SSink_updateReconcile(t *testing.T) {
 	defer closer()
 
 	// Set a service
-	sink.SetServices(map[string]string{"web": "web.service.local."})
+	sink.SetServices(map[string]string{"web": "web.service.local."}, nil)
 
 	// Verify service gets registered
 	var actual *apiv1.Service
@@ -234,7 +289,7 @@ func TestK8SSink_updateService(t *testing.T) {
 	defer closer()
 
 	// Set a service
-	sink.SetServices(map[string]string{"web": "web.service.local."})
+	sink.SetServices(map[string]string{"web": "web.service.local."}, nil)
 
 	// Verify service gets registered
 	var actual *apiv1.Service
@@ -258,7 +313,7 @@ func TestK8SSink_updateService(t *testing.T) {
 	})
 
 	// Update a service
-	sink.SetServices(map[string]string{"web": "web2.service.local."})
+	sink.SetServices(map[string]string{"web": "web2.service.local."}, nil)
 
 	// Verify service gets fixed
 	retry.Run(t, func(r *retry.R) {
@@ -287,6 +342,50 @@ func TestK8SSink_updateService(t *testing.T) {
 	})
 }
 
+// Test that the lineage of services is annotated and kept up to date.
+func TestK8SSink_lineage(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+
+	// Start the controller
+	sink, closer := testSink(t, client)
+	defer closer()
+
+	// Set a service with its lineage
+	sink.SetServices(map[string]string{"web": "web.service.local."}, map[string]Lineage{
+		"web": {Datacenter: "dc1", Namespace: "default", Partition: "ap1", Nodes: []string{"node-a", "node-b"}},
+	})
+
+	// Verify service gets registered with the lineage annotations
+	retry.Run(t, func(r *retry.R) {
+		actual, err := client.CoreV1().Services(metav1.NamespaceDefault).Get(context.Background(), "web", metav1.GetOptions{})
+		if err != nil {
+			r.Fatalf("err: %s", err)
+		}
+		require.Equal(r, "dc1", actual.Annotations[AnnotationSourceDatacenter])
+		require.Equal(r, "default", actual.Annotations[AnnotationSourceNamespace])
+		require.Equal(r, "ap1", actual.Annotations[AnnotationSourcePartition])
+		require.Equal(r, "node-a,node-b", actual.Annotations[AnnotationSourceNodes])
+	})
+
+	// Update the lineage without changing the service
+	sink.SetServices(map[string]string{"web": "web.service.local."}, map[string]Lineage{
+		"web": {Datacenter: "dc1", Namespace: "default", Nodes: []string{"node-c"}},
+	})
+
+	// Verify the annotations get updated
+	retry.Run(t, func(r *retry.R) {
+		actual, err := client.CoreV1().Services(metav1.NamespaceDefault).Get(context.Background(), "web", metav1.GetOptions{})
+		if err != nil {
+			r.Fatalf("err: %s", err)
+		}
+		require.Equal(r, "node-c", actual.Annotations[AnnotationSourceNodes])
+		require.NotContains(r, actual.Annotations, AnnotationSourcePartition)
+		require.Equal(r, "false", actual.Annotations["consul.hashicorp.com/service-sync"])
+		require.Equal(r, "web.service.local.", actual.Spec.ExternalName)
+	})
+}
+
 // Test that if the service is deleted remotely, it is recreated.
 func TestK8SSink_deleteReconcileRemote(t *testing.T) {
 	t.Parallel()
@@ -297,7 +396,7 @@ func TestK8SSink_deleteReconcileRemote(t *testing.T) {
 	defer closer()
 
 	// Set a service
-	sink.SetServices(map[string]string{"web": "web.service.local."})
+	sink.SetServices(map[string]string{"web": "web.service.local."}, nil)
 
 	// Verify service gets registered
 	var actual *apiv1.Service
@@ -360,7 +459,7 @@ func TestK8SSink_deleteReconcileLocal(t *testing.T) {
 	defer closer()
 
 	// Set a service
-	sink.SetServices(map[string]string{"web": "web.service.local."})
+	sink.SetServices(map[string]string{"web": "web.service.local."}, nil)
 
 	// Verify service gets registered
 	retry.Run(t, func(r *retry.R) {
@@ -382,7 +481,7 @@ func TestK8SSink_deleteReconcileLocal(t *testing.T) {
 	})
 
 	// Clear
-	sink.SetServices(map[string]string{})
+	sink.SetServices(map[string]string{}, nil)
 
 	// Verify services get cleared
 	retry.Run(t, func(r *retry.R) {
@@ -396,6 +495,51 @@ func TestK8SSink_deleteReconcileLocal(t *testing.T) {
 	})
 }
 
+// Test that a sink that watches all namespaces doesn't delete the synced
+// services of other installations.
+func TestK8SSink_deleteAllNamespacesOwned(t *testing.T) {
+	t.Parallel()
+	foreign := &apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foreign",
+			Namespace: "apps",
+			Labels:    map[string]string{"consul": "true", LabelSyncedBy: "other"},
+		},
+	}
+	client := fake.NewSimpleClientset(foreign)
+
+	sink := &K8SSink{
+		Client:        client,
+		Namespace:     metav1.NamespaceDefault,
+		AllNamespaces: true,
+		Log:           hclog.Default(),
+		Ctx:           context.Background(),
+	}
+	closer := controller.TestControllerRun(sink)
+	defer closer()
+
+	sink.SetServices(map[string]string{"apps/web": "web.service.local."}, nil)
+	retry.Run(t, func(r *retry.R) {
+		svc, err := client.CoreV1().Services("apps").Get(context.Background(), "web", metav1.GetOptions{})
+		if err != nil {
+			r.Fatalf("err: %s", err)
+		}
+		if svc.Labels[LabelSyncedBy] != metav1.NamespaceDefault {
+			r.Fatalf("service isn't labeled with its sink: %v", svc.Labels)
+		}
+	})
+
+	sink.SetServices(map[string]string{}, nil)
+	retry.Run(t, func(r *retry.R) {
+		_, err := client.CoreV1().Services("apps").Get(context.Background(), "web", metav1.GetOptions{})
+		if err == nil {
+			r.Fatal("service not deleted")
+		}
+	})
+	_, err := client.CoreV1().Services("apps").Get(context.Background(), "foreign", metav1.GetOptions{})
+	require.NoError(t, err)
+}
+
 func testSink(t *testing.T, client kubernetes.Interface) (*K8SSink, func()) {
 	sink := &K8SSink{
 		Client: client,
@@ -6,10 +6,14 @@ package catalog
 import (
 	"context"
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff"
+	"github.com/hashicorp/consul-k8s/control-plane/catalog/metrics"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-hclog"
 )
@@ -26,13 +30,70 @@ type Source struct {
 	Prefix              string       // Prefix is a prefix to prepend to services
 	Log                 hclog.Logger // Logger
 	ConsulK8STag        string       // The tag value for services registered
+
+	// EnableNamespaces watches the services in all Consul namespaces instead
+	// of only the default namespace. Services are synced to the K8s namespace
+	// that their Consul namespace maps to with NamespaceMapping or
+	// EnableNSMirroring. Services in the default Consul namespace are synced
+	// to the sink's namespace unless it's mapped. Services in other Consul
+	// namespaces that don't map to a K8s namespace aren't synced.
+	EnableNamespaces bool
+
+	// NamespaceMapping maps Consul namespaces to the K8s namespaces that
+	// their services are synced to.
+	NamespaceMapping map[string]string
+
+	// EnableNSMirroring syncs the services of Consul namespaces that aren't
+	// in NamespaceMapping to the K8s namespace with the same name.
+	EnableNSMirroring bool
+
+	// EnableLineage looks up the instances of each synced service to pass
+	// the datacenter, namespace, partition and nodes that it came from to
+	// the Sink, which annotates the synced services with them.
+	EnableLineage bool
 }
 
 // Run is the long-running runloop for watching Consul services and
 // updating the Sink.
 func (s *Source) Run(ctx context.Context) {
+	if !s.EnableNamespaces {
+		s.watchServices(ctx, s.ConsulClientConfig, "", s.Sink.SetServices)
+		return
+	}
+	s.watchNamespaces(ctx)
+}
+
+// watchNamespaces watches the Consul namespaces and the services in each of
+// them, and updates the Sink with the services of all namespaces.
+func (s *Source) watchNamespaces(ctx context.Context) {
+	var lock sync.Mutex
+	nsServices := make(map[string]map[string]string)
+	nsLineage := make(map[string]map[string]Lineage)
+	update := func() {
+		services := make(map[string]string)
+		lineage := make(map[string]Lineage)
+		for ns, svcs := range nsServices {
+			for k, v := range svcs {
+				services[k] = v
+			}
+			for k, v := range nsLineage[ns] {
+				lineage[k] = v
+			}
+		}
+		s.Sink.SetServices(services, lineage)
+	}
+
+	// cancelWatches holds the cancel functions of the service watches of
+	// the Consul namespaces that are synced.
+	cancelWatches := make(map[string]context.CancelFunc)
+	defer func() {
+		for _, cancel := range cancelWatches {
+			cancel()
+		}
+	}()
+
 	opts := (&api.QueryOptions{
-		AllowStale: true,
+		AllowStale: s.ConsulClientConfig.AllowStale,
 		WaitIndex:  1,
 		WaitTime:   1 * time.Minute,
 	}).WithContext(ctx)
@@ -43,11 +104,148 @@ func (s *Source) Run(ctx context.Context) {
 			return
 		}
 
+		var nsList []*api.Namespace
+		var meta *api.QueryMeta
+		err = backoff.Retry(func() error {
+			nsList, meta, err = consulClient.Namespaces().List(opts)
+			if err != nil && ctx.Err() == nil {
+				metrics.ConsulAPIErrors.WithLabelValues(metrics.DirectionToK8s, "namespaces_list").Inc()
+			}
+			return err
+		}, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
+
+		// If the context is ended, then we end
+		if ctx.Err() != nil {
+			return
+		}
+
+		// If there was an error, handle that
+		if err != nil {
+			s.Log.Warn("error querying namespaces, will retry", "err", err)
+			continue
+		}
+
+		// Update our blocking index
+		opts.WaitIndex = meta.LastIndex
+
+		// Start watching the services of new namespaces and stop watching
+		// the services of deleted namespaces.
+		current := make(map[string]bool, len(nsList))
+		for _, name := range s.syncedNamespaces(nsList) {
+			name := name
+			current[name] = true
+			if _, ok := cancelWatches[name]; ok {
+				continue
+			}
+			s.Log.Info("watching services in Consul namespace", "namespace", name)
+			watchCtx, cancel := context.WithCancel(ctx)
+			cancelWatches[name] = cancel
+			go s.watchServices(watchCtx, s.watchConfig(), name, func(services map[string]string, lineage map[string]Lineage) {
+				lock.Lock()
+				defer lock.Unlock()
+				if watchCtx.Err() != nil {
+					return
+				}
+				nsServices[name] = services
+				nsLineage[name] = lineage
+				update()
+			})
+		}
+		for name, cancel := range cancelWatches {
+			if current[name] {
+				continue
+			}
+			s.Log.Info("no longer watching services in Consul namespace", "namespace", name)
+			lock.Lock()
+			cancel()
+			delete(cancelWatches, name)
+			delete(nsServices, name)
+			delete(nsLineage, name)
+			update()
+			lock.Unlock()
+		}
+	}
+}
+
+// syncedNamespaces returns the names of the Consul namespaces whose services
+// are synced. Each K8s namespace is synced from at most one Consul namespace,
+// since services with the same name would overwrite each other. Mapped
+// namespaces take precedence over the default namespace, which takes
+// precedence over mirrored namespaces.
+func (s *Source) syncedNamespaces(nsList []*api.Namespace) []string {
+	names := make([]string, 0, len(nsList))
+	for _, ns := range nsList {
+		names = append(names, ns.Name)
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		return s.namespacePrecedence(names[i]) < s.namespacePrecedence(names[j])
+	})
+
+	var synced []string
+	claimedBy := make(map[string]string)
+	for _, name := range names {
+		k8sNS, ok := s.k8sNamespace(name)
+		if !ok {
+			continue
+		}
+		if other, ok := claimedBy[k8sNS]; ok {
+			s.Log.Warn("Consul namespace maps to the same K8s namespace as another Consul namespace, not syncing its services",
+				"namespace", name, "k8s-namespace", k8sNS, "other-namespace", other)
+			continue
+		}
+		claimedBy[k8sNS] = name
+		synced = append(synced, name)
+	}
+	return synced
+}
+
+// namespacePrecedence returns the precedence of the Consul namespace when
+// several namespaces map to the same K8s namespace. Lower values win.
+func (s *Source) namespacePrecedence(consulNS string) int {
+	if _, ok := s.NamespaceMapping[consulNS]; ok {
+		return 0
+	}
+	if consulNS == "" || consulNS == namespaces.DefaultNamespace {
+		return 1
+	}
+	return 2
+}
+
+// watchConfig returns a copy of the Consul client config for a service
+// watch. Creating a client updates its config, so the watches of each
+// namespace need their own.
+func (s *Source) watchConfig() *consul.Config {
+	apiConfig := *s.ConsulClientConfig.APIClientConfig
+	apiConfig.HttpClient = nil
+	cfg := *s.ConsulClientConfig
+	cfg.APIClientConfig = &apiConfig
+	return &cfg
+}
+
+// watchServices watches the services in the Consul namespace and calls
+// update with the services to sync and their lineage whenever they change.
+func (s *Source) watchServices(ctx context.Context, cfg *consul.Config, consulNS string, update func(map[string]string, map[string]Lineage)) {
+	opts := (&api.QueryOptions{
+		AllowStale: cfg.AllowStale,
+		WaitIndex:  1,
+		WaitTime:   1 * time.Minute,
+		Namespace:  consulNS,
+	}).WithContext(ctx)
+	for {
+		consulClient, err := consul.NewClientFromConnMgr(cfg, s.ConsulServerConnMgr)
+		if err != nil {
+			s.Log.Error("failed to create Consul API client", "err", err)
+			return
+		}
+
 		// Get all services with tags.
 		var serviceMap map[string][]string
 		var meta *api.QueryMeta
 		err = backoff.Retry(func() error {
 			serviceMap, meta, err = consulClient.Catalog().Services(opts)
+			if err != nil && ctx.Err() == nil {
+				metrics.ConsulAPIErrors.WithLabelValues(metrics.DirectionToK8s, "catalog_services").Inc()
+			}
 			return err
 		}, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
 
@@ -67,6 +265,15 @@ func (s *Source) Run(ctx context.Context) {
 
 		// Setup the services
 		services := make(map[string]string, len(serviceMap))
+		var lineage map[string]Lineage
+		var serviceLineage map[string]Lineage
+		if s.EnableLineage {
+			lineage = make(map[string]Lineage, len(serviceMap))
+			serviceLineage, err = s.servicesLineage(ctx, consulClient, consulNS)
+			if err != nil {
+				s.Log.Warn("error querying service summaries, not updating the lineage of services", "err", err)
+			}
+		}
 		for name, tags := range serviceMap {
 			// We ignore services that are synced from k8s so we can avoid
 			// circular syncing. Realistically this shouldn't happen since
@@ -80,12 +287,105 @@ func (s *Source) Run(ctx context.Context) {
 				}
 			}
 
-			if !k8s {
-				services[s.Prefix+name] = fmt.Sprintf("%s.service.%s", name, s.Domain)
+			if k8s {
+				continue
 			}
+			key := s.serviceKey(consulNS, name)
+			services[key] = s.serviceDNS(consulNS, name)
+			if l, ok := serviceLineage[name]; ok {
+				lineage[key] = l
+			}
+		}
+		s.Log.Info("received services from Consul", "count", len(services), "namespace", consulNS)
+
+		update(services, lineage)
+	}
+}
+
+// serviceSummary is the part of a service summary of Consul's UI endpoint
+// that is needed for the lineage of the service.
+type serviceSummary struct {
+	Name       string
+	Datacenter string
+	Namespace  string
+	Partition  string
+	Nodes      []string
+}
+
+// servicesLineage returns the datacenter, namespace, partition and nodes of
+// the instances of every service in the Consul namespace, keyed by service
+// name. They're read with a single query of the service summaries that the
+// Consul UI uses, rather than one query for the instances of each service.
+// Sidecar proxies aren't summarized, so they're synced without lineage.
+func (s *Source) servicesLineage(ctx context.Context, consulClient *api.Client, consulNS string) (map[string]Lineage, error) {
+	opts := (&api.QueryOptions{
+		AllowStale: s.ConsulClientConfig.AllowStale,
+		Namespace:  consulNS,
+	}).WithContext(ctx)
+	var summaries []serviceSummary
+	if _, err := consulClient.Raw().Query("/v1/internal/ui/services", &summaries, opts); err != nil {
+		if ctx.Err() == nil {
+			metrics.ConsulAPIErrors.WithLabelValues(metrics.DirectionToK8s, "ui_services").Inc()
+		}
+		return nil, err
+	}
+
+	lineage := make(map[string]Lineage, len(summaries))
+	for _, summary := range summaries {
+		l := Lineage{
+			Datacenter: summary.Datacenter,
+			Namespace:  summary.Namespace,
+			Partition:  summary.Partition,
+		}
+		if l.Namespace == "" {
+			l.Namespace = consulNS
+		}
+		if l.Namespace == "" {
+			l.Namespace = namespaces.DefaultNamespace
 		}
-		s.Log.Info("received services from Consul", "count", len(services))
+		nodes := make(map[string]struct{})
+		for _, node := range summary.Nodes {
+			nodes[node] = struct{}{}
+		}
+		for node := range nodes {
+			l.Nodes = append(l.Nodes, node)
+		}
+		sort.Strings(l.Nodes)
+		lineage[summary.Name] = l
+	}
+	return lineage, nil
+}
+
+// k8sNamespace returns the K8s namespace that the services of the Consul
+// namespace are synced to, and false if they aren't synced. An empty
+// namespace is the sink's namespace.
+func (s *Source) k8sNamespace(consulNS string) (string, bool) {
+	if ns, ok := s.NamespaceMapping[consulNS]; ok {
+		return ns, true
+	}
+	if consulNS == "" || consulNS == namespaces.DefaultNamespace {
+		return "", true
+	}
+	if s.EnableNSMirroring {
+		return consulNS, true
+	}
+	return "", false
+}
+
+// serviceKey returns the key of the service in the services passed to the
+// Sink, i.e. the service name prefixed with its K8s namespace if it isn't
+// synced to the sink's namespace.
+func (s *Source) serviceKey(consulNS, name string) string {
+	if ns, _ := s.k8sNamespace(consulNS); ns != "" {
+		return ns + "/" + s.Prefix + name
+	}
+	return s.Prefix + name
+}
 
-		s.Sink.SetServices(services)
+// serviceDNS returns the Consul DNS name of the service.
+func (s *Source) serviceDNS(consulNS, name string) string {
+	if consulNS == "" || consulNS == namespaces.DefaultNamespace {
+		return fmt.Sprintf("%s.service.%s", name, s.Domain)
 	}
+	return fmt.Sprintf("%s.service.%s.ns.%s", name, consulNS, s.Domain)
 }
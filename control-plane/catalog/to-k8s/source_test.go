@@ -5,8 +5,15 @@ package catalog
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"reflect"
+	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	toconsul "github.com/hashicorp/consul-k8s/control-plane/catalog/to-consul"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
@@ -228,6 +235,162 @@ func TestSource_deleteServiceInstance(t *testing.T) {
 	})
 }
 
+// Test that services in Consul namespaces are synced to the K8s namespaces
+// that their Consul namespaces map to.
+func TestSource_namespaces(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		mapping   map[string]string
+		mirroring bool
+		expected  map[string]string
+	}{
+		"only the default namespace without mapping or mirroring": {
+			expected: map[string]string{
+				"svcA": "svcA.service.test",
+			},
+		},
+		"mapped namespaces": {
+			mapping: map[string]string{"team-b": "b"},
+			expected: map[string]string{
+				"svcA":   "svcA.service.test",
+				"b/svcB": "svcB.service.team-b.ns.test",
+			},
+		},
+		"mirrored namespaces": {
+			mirroring: true,
+			expected: map[string]string{
+				"svcA":        "svcA.service.test",
+				"team-b/svcB": "svcB.service.team-b.ns.test",
+				"team-c/svcC": "svcC.service.team-c.ns.test",
+			},
+		},
+		"mapping takes precedence over mirroring": {
+			mapping:   map[string]string{"team-b": "b", "default": "apps"},
+			mirroring: true,
+			expected: map[string]string{
+				"apps/svcA":   "svcA.service.test",
+				"b/svcB":      "svcB.service.team-b.ns.test",
+				"team-c/svcC": "svcC.service.team-c.ns.test",
+			},
+		},
+		"mapped namespace takes precedence over a mirrored namespace with the same K8s namespace": {
+			mapping:   map[string]string{"team-b": "team-c"},
+			mirroring: true,
+			expected: map[string]string{
+				"svcA":        "svcA.service.test",
+				"team-c/svcB": "svcB.service.team-b.ns.test",
+			},
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			services := map[string]map[string][]string{
+				"default": {"svcA": nil, "k8s-svc": {toconsul.TestConsulK8STag}},
+				"team-b":  {"svcB": nil},
+				"team-c":  {"svcC": nil},
+			}
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Emulate blocking queries that don't see any changes.
+				if r.URL.Query().Get("index") == "10" {
+					time.Sleep(50 * time.Millisecond)
+				}
+				w.Header().Set("X-Consul-Index", "10")
+				switch r.URL.Path {
+				case "/v1/namespaces":
+					var list []*api.Namespace
+					for ns := range services {
+						list = append(list, &api.Namespace{Name: ns})
+					}
+					require.NoError(t, json.NewEncoder(w).Encode(list))
+				case "/v1/catalog/services":
+					require.NoError(t, json.NewEncoder(w).Encode(services[r.URL.Query().Get("ns")]))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			t.Cleanup(consulServer.Close)
+			serverURL, err := url.Parse(consulServer.URL)
+			require.NoError(t, err)
+			port, err := strconv.Atoi(serverURL.Port())
+			require.NoError(t, err)
+
+			_, sink, closer := testSourceWithConfig(
+				&consul.Config{APIClientConfig: &api.Config{}, HTTPPort: port},
+				test.MockConnMgrForIPAndPort(serverURL.Hostname(), 0),
+				func(s *Source) {
+					s.EnableNamespaces = true
+					s.NamespaceMapping = c.mapping
+					s.EnableNSMirroring = c.mirroring
+				})
+			defer closer()
+
+			retry.Run(t, func(r *retry.R) {
+				sink.Lock()
+				defer sink.Unlock()
+				require.Equal(r, c.expected, sink.Services)
+			})
+		})
+	}
+}
+
+// Test that the lineage of services is passed to the sink with lineage enabled.
+func TestSource_lineage(t *testing.T) {
+	t.Parallel()
+	summaries := []serviceSummary{
+		{Name: "svcA", Datacenter: "dc2", Namespace: "default", Partition: "ap1", Nodes: []string{"node-b", "node-a", "node-a"}},
+	}
+	var servicesQueries, summaryQueries int32
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Emulate blocking queries that don't see any changes.
+		if r.URL.Query().Get("index") == "10" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.Header().Set("X-Consul-Index", "10")
+		switch r.URL.Path {
+		case "/v1/catalog/services":
+			atomic.AddInt32(&servicesQueries, 1)
+			require.NoError(t, json.NewEncoder(w).Encode(map[string][]string{"svcA": nil, "svcB": nil}))
+		case "/v1/internal/ui/services":
+			atomic.AddInt32(&summaryQueries, 1)
+			// Services that aren't summarized are synced without lineage.
+			require.NoError(t, json.NewEncoder(w).Encode(summaries))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(consulServer.Close)
+	serverURL, err := url.Parse(consulServer.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(serverURL.Port())
+	require.NoError(t, err)
+
+	_, sink, closer := testSourceWithConfig(
+		&consul.Config{APIClientConfig: &api.Config{}, HTTPPort: port},
+		test.MockConnMgrForIPAndPort(serverURL.Hostname(), 0),
+		func(s *Source) {
+			s.EnableLineage = true
+		})
+	defer closer()
+
+	retry.Run(t, func(r *retry.R) {
+		sink.Lock()
+		defer sink.Unlock()
+		require.Equal(r, map[string]string{
+			"svcA": "svcA.service.test",
+			"svcB": "svcB.service.test",
+		}, sink.Services)
+		require.Equal(r, map[string]Lineage{
+			"svcA": {Datacenter: "dc2", Namespace: "default", Partition: "ap1", Nodes: []string{"node-a", "node-b"}},
+		}, sink.Lineage)
+		// The lineage of all services is read with one query per update of the services.
+		queries := atomic.LoadInt32(&summaryQueries)
+		require.Positive(r, queries)
+		require.LessOrEqual(r, queries, atomic.LoadInt32(&servicesQueries))
+	})
+}
+
 // testRegistration creates a Consul test registration.
 func testRegistration(node, service string, tags []string) *api.CatalogRegistration {
 	return &api.CatalogRegistration{
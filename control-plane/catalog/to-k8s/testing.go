@@ -12,10 +12,12 @@ import (
 type TestSink struct {
 	sync.Mutex
 	Services map[string]string
+	Lineage  map[string]Lineage
 }
 
-func (s *TestSink) SetServices(raw map[string]string) {
+func (s *TestSink) SetServices(raw map[string]string, lineage map[string]Lineage) {
 	s.Lock()
 	defer s.Unlock()
 	s.Services = raw
+	s.Lineage = lineage
 }
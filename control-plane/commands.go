@@ -9,6 +9,7 @@ import (
 	cmdACLInit "github.com/hashicorp/consul-k8s/control-plane/subcommand/acl-init"
 	cmdConnectInit "github.com/hashicorp/consul-k8s/control-plane/subcommand/connect-init"
 	cmdConsulLogout "github.com/hashicorp/consul-k8s/control-plane/subcommand/consul-logout"
+	cmdCoreDNSConfig "github.com/hashicorp/consul-k8s/control-plane/subcommand/coredns-config"
 	cmdCreateFederationSecret "github.com/hashicorp/consul-k8s/control-plane/subcommand/create-federation-secret"
 	cmdDeleteCompletedJob "github.com/hashicorp/consul-k8s/control-plane/subcommand/delete-completed-job"
 	cmdFetchServerRegion "github.com/hashicorp/consul-k8s/control-plane/subcommand/fetch-server-region"
@@ -20,6 +21,7 @@ import (
 	cmdInstallCNI "github.com/hashicorp/consul-k8s/control-plane/subcommand/install-cni"
 	cmdPartitionInit "github.com/hashicorp/consul-k8s/control-plane/subcommand/partition-init"
 	cmdServerACLInit "github.com/hashicorp/consul-k8s/control-plane/subcommand/server-acl-init"
+	cmdServerProbe "github.com/hashicorp/consul-k8s/control-plane/subcommand/server-probe"
 	cmdSyncCatalog "github.com/hashicorp/consul-k8s/control-plane/subcommand/sync-catalog"
 	cmdTLSInit "github.com/hashicorp/consul-k8s/control-plane/subcommand/tls-init"
 	cmdVersion "github.com/hashicorp/consul-k8s/control-plane/subcommand/version"
@@ -51,6 +53,10 @@ func init() {
 			return &cmdConsulLogout.Command{UI: ui}, nil
 		},
 
+		"coredns-config": func() (cli.Command, error) {
+			return &cmdCoreDNSConfig.Command{UI: ui}, nil
+		},
+
 		"gateway-cleanup": func() (cli.Command, error) {
 			return &cmdGatewayCleanup.Command{UI: ui}, nil
 		},
@@ -63,6 +69,10 @@ func init() {
 			return &cmdServerACLInit.Command{UI: ui}, nil
 		},
 
+		"server-probe": func() (cli.Command, error) {
+			return &cmdServerProbe.Command{UI: ui}, nil
+		},
+
 		"partition-init": func() (cli.Command, error) {
 			return &cmdPartitionInit.Command{UI: ui}, nil
 		},
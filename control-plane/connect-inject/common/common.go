@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul/api"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -80,6 +81,31 @@ func TransparentProxyEnabled(namespace corev1.Namespace, pod corev1.Pod, globalE
 	return globalEnabled, nil
 }
 
+// MeshGatewayMode returns the mesh gateway mode set by the annotation on the pod. It returns an empty mode
+// if the annotation isn't set, and an error if its value isn't local, remote or none.
+func MeshGatewayMode(pod corev1.Pod) (api.MeshGatewayMode, error) {
+	raw, ok := pod.Annotations[constants.AnnotationMeshGatewayMode]
+	if !ok || raw == "" {
+		return api.MeshGatewayModeDefault, nil
+	}
+	switch mode := api.MeshGatewayMode(raw); mode {
+	case api.MeshGatewayModeLocal, api.MeshGatewayModeRemote, api.MeshGatewayModeNone:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%s annotation value of %s was invalid: must be one of %s, %s or %s", constants.AnnotationMeshGatewayMode,
+			raw, api.MeshGatewayModeLocal, api.MeshGatewayModeRemote, api.MeshGatewayModeNone)
+	}
+}
+
+// UsesNodeProxy returns true if the pod opted into the node proxy mode with the annotation.
+// It returns an error when the annotation value cannot be parsed by strconv.ParseBool.
+func UsesNodeProxy(pod corev1.Pod) (bool, error) {
+	if raw, ok := pod.Annotations[constants.AnnotationNodeProxy]; ok {
+		return strconv.ParseBool(raw)
+	}
+	return false, nil
+}
+
 // ShouldOverwriteProbes returns true if we need to overwrite readiness/liveness probes for this pod.
 // It returns an error when the annotation value cannot be parsed by strconv.ParseBool.
 func ShouldOverwriteProbes(pod corev1.Pod, globalOverwrite bool) (bool, error) {
@@ -93,3 +119,46 @@ func ShouldOverwriteProbes(pod corev1.Pod, globalOverwrite bool) (bool, error) {
 func ConsulNodeNameFromK8sNode(nodeName string) string {
 	return fmt.Sprintf("%s-virtual", nodeName)
 }
+
+// LocalhostAddress returns the loopback address used for traffic between
+// containers in a pod. Pods in IPv6-only clusters use the IPv6 loopback.
+func LocalhostAddress(enableIPv6 bool) string {
+	if enableIPv6 {
+		return "::1"
+	}
+	return "127.0.0.1"
+}
+
+// UnspecifiedAddress returns the address used to listen on all interfaces
+// of a pod. Pods in IPv6-only clusters use the IPv6 unspecified address.
+func UnspecifiedAddress(enableIPv6 bool) string {
+	if enableIPv6 {
+		return "::"
+	}
+	return "0.0.0.0"
+}
+
+// NodeLabelMetaKey returns the Consul node meta key that the Kubernetes node
+// label is synced to. Node meta keys may only contain letters, digits, dashes
+// and underscores, so any other character is replaced with a dash, e.g.
+// topology.kubernetes.io/zone is synced to topology-kubernetes-io-zone.
+func NodeLabelMetaKey(label string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, label)
+}
+
+// NodeLabelMeta returns the Consul node meta for the given labels of the
+// Kubernetes node. Labels that aren't set on the node are skipped.
+func NodeLabelMeta(node corev1.Node, labels []string) map[string]string {
+	meta := make(map[string]string)
+	for _, label := range labels {
+		if value, ok := node.Labels[label]; ok {
+			meta[NodeLabelMetaKey(label)] = value
+		}
+	}
+	return meta
+}
@@ -259,3 +259,28 @@ func minimal() *corev1.Pod {
 		},
 	}
 }
+
+func TestNodeLabelMeta(t *testing.T) {
+	node := corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node1",
+			Labels: map[string]string{
+				corev1.LabelTopologyZone:       "us-east-1a",
+				corev1.LabelInstanceTypeStable: "m5.large",
+				"example.com/rack_id":          "r1",
+				"kubernetes.io/hostname":       "node1",
+			},
+		},
+	}
+	meta := NodeLabelMeta(node, []string{
+		corev1.LabelTopologyZone,
+		corev1.LabelTopologyRegion,
+		corev1.LabelInstanceTypeStable,
+		"example.com/rack_id",
+	})
+	require.Equal(t, map[string]string{
+		"topology-kubernetes-io-zone":      "us-east-1a",
+		"node-kubernetes-io-instance-type": "m5.large",
+		"example-com-rack_id":              "r1",
+	}, meta)
+}
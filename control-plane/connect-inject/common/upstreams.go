@@ -0,0 +1,270 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// preparedQueryUpstream is the first part of prepared query upstreams,
+	// e.g. prepared_query:[query name]:[port].
+	preparedQueryUpstream = "prepared_query"
+
+	// upstreamOptionSeparator separates the options of an upstream from the upstream
+	// and from each other, e.g. web:1234;mesh-gateway=local.
+	upstreamOptionSeparator = ";"
+
+	// upstreamOptionMeshGateway sets the mesh gateway mode of an upstream.
+	upstreamOptionMeshGateway = "mesh-gateway"
+)
+
+// ParseUpstreams parses the upstreams of the consul.hashicorp.com/connect-service-upstreams annotation
+// of the pod. It is a comma-separated list of upstreams in one of the formats:
+//
+//	[service-name].[service-namespace].[service-partition].[service-peer]:[port]:[optional datacenter]
+//	[service-name].svc.[service-namespace].ns.[service-peer].peer:[port]
+//	[service-name].svc.[service-namespace].ns.[service-partition].ap:[port]
+//	[service-name].svc.[service-namespace].ns.[service-datacenter].dc:[port]
+//	prepared_query:[query name]:[port]
+//
+// The namespace, partition and peer of the unlabeled format are only parsed when namespaces or
+// partitions are enabled, and can be left empty, e.g. web..part1 or web...peer1. Each upstream can
+// be followed by options, e.g. web:1234;mesh-gateway=local. It returns an error describing the first
+// upstream that is invalid so that the pod can be rejected before it's created.
+func ParseUpstreams(pod corev1.Pod, enableNamespaces, enablePartitions bool) ([]api.Upstream, error) {
+	raw, ok := pod.Annotations[constants.AnnotationUpstreams]
+	if !ok || raw == "" {
+		return nil, nil
+	}
+
+	var upstreams []api.Upstream
+	for _, rawUpstream := range strings.Split(raw, ",") {
+		rawUpstream = strings.TrimSpace(rawUpstream)
+		rawUpstream, options := SplitUpstreamOptions(rawUpstream)
+
+		// parts separates out the port, and determines whether it's a prepared query or not, since parts[0] would
+		// be "prepared_query" if it is.
+		parts := strings.SplitN(rawUpstream, ":", 3)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("upstream structured incorrectly: %s", rawUpstream)
+		}
+
+		// serviceParts helps determine which format of upstream we're processing.
+		labeledFormat := false
+		serviceParts := strings.Split(parts[0], ".")
+		if len(serviceParts) >= 2 && serviceParts[1] == "svc" {
+			labeledFormat = true
+		}
+
+		var upstream api.Upstream
+		var err error
+		if strings.TrimSpace(parts[0]) == preparedQueryUpstream {
+			upstream, err = parsePreparedQueryUpstream(pod, rawUpstream)
+		} else if labeledFormat {
+			upstream, err = parseLabeledUpstream(pod, rawUpstream, enableNamespaces || enablePartitions)
+		} else {
+			upstream, err = parseUnlabeledUpstream(pod, rawUpstream, enableNamespaces || enablePartitions)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := applyUpstreamOptions(&upstream, rawUpstream, options); err != nil {
+			return nil, err
+		}
+		upstreams = append(upstreams, upstream)
+	}
+	return upstreams, nil
+}
+
+// SplitUpstreamOptions splits the options that follow an upstream of the annotation from the upstream.
+func SplitUpstreamOptions(rawUpstream string) (string, []string) {
+	parts := strings.Split(rawUpstream, upstreamOptionSeparator)
+	return strings.TrimSpace(parts[0]), parts[1:]
+}
+
+// parsePreparedQueryUpstream parses an upstream in the format:
+// prepared_query:[query name]:[port].
+func parsePreparedQueryUpstream(pod corev1.Pod, rawUpstream string) (api.Upstream, error) {
+	parts := strings.SplitN(rawUpstream, ":", 3)
+	if len(parts) != 3 || strings.TrimSpace(parts[1]) == "" {
+		return api.Upstream{}, fmt.Errorf("prepared query upstream structured incorrectly: %s", rawUpstream)
+	}
+	port, err := upstreamPort(pod, rawUpstream, parts[2])
+	if err != nil {
+		return api.Upstream{}, err
+	}
+	return api.Upstream{
+		DestinationType: api.UpstreamDestTypePreparedQuery,
+		DestinationName: strings.TrimSpace(parts[1]),
+		LocalBindPort:   port,
+	}, nil
+}
+
+// parseUnlabeledUpstream parses an upstream in the format:
+// [service-name].[service-namespace].[service-partition].[service-peer]:[port]:[optional datacenter].
+func parseUnlabeledUpstream(pod corev1.Pod, rawUpstream string, parseNamespace bool) (api.Upstream, error) {
+	var datacenter, svcName, namespace, partition, peer string
+
+	parts := strings.SplitN(rawUpstream, ":", 3)
+	port, err := upstreamPort(pod, rawUpstream, parts[1])
+	if err != nil {
+		return api.Upstream{}, err
+	}
+
+	// If Consul Namespaces or Admin Partitions are enabled, attempt to parse the
+	// upstream for a namespace, partition and peer.
+	if parseNamespace {
+		pieces := strings.SplitN(parts[0], ".", 4)
+		switch len(pieces) {
+		case 4:
+			peer = strings.TrimSpace(pieces[3])
+			fallthrough
+		case 3:
+			partition = strings.TrimSpace(pieces[2])
+			fallthrough
+		case 2:
+			namespace = strings.TrimSpace(pieces[1])
+			fallthrough
+		default:
+			svcName = strings.TrimSpace(pieces[0])
+		}
+	} else {
+		svcName = strings.TrimSpace(parts[0])
+	}
+
+	// parse the optional datacenter
+	if len(parts) > 2 {
+		datacenter = strings.TrimSpace(parts[2])
+	}
+
+	if svcName == "" {
+		return api.Upstream{}, fmt.Errorf("upstream structured incorrectly: %s", rawUpstream)
+	}
+	if peer != "" && (partition != "" || datacenter != "") {
+		return api.Upstream{}, fmt.Errorf("upstream %s is invalid: a peer can't be used with a partition or datacenter", rawUpstream)
+	}
+	return api.Upstream{
+		DestinationType:      api.UpstreamDestTypeService,
+		DestinationPartition: partition,
+		DestinationPeer:      peer,
+		DestinationNamespace: namespace,
+		DestinationName:      svcName,
+		Datacenter:           datacenter,
+		LocalBindPort:        port,
+	}, nil
+}
+
+// parseLabeledUpstream parses an upstream in the format:
+// [service-name].svc.[service-namespace].ns.[service-peer].peer:[port]
+// [service-name].svc.[service-namespace].ns.[service-partition].ap:[port]
+// [service-name].svc.[service-namespace].ns.[service-datacenter].dc:[port].
+func parseLabeledUpstream(pod corev1.Pod, rawUpstream string, parseNamespace bool) (api.Upstream, error) {
+	var datacenter, svcName, namespace, partition, peer string
+
+	parts := strings.SplitN(rawUpstream, ":", 3)
+	port, err := upstreamPort(pod, rawUpstream, parts[1])
+	if err != nil {
+		return api.Upstream{}, err
+	}
+
+	pieces := strings.Split(parts[0], ".")
+
+	if parseNamespace {
+		switch len(pieces) {
+		case 6:
+			end := strings.TrimSpace(pieces[5])
+			switch end {
+			case "peer":
+				peer = strings.TrimSpace(pieces[4])
+			case "ap":
+				partition = strings.TrimSpace(pieces[4])
+			case "dc":
+				datacenter = strings.TrimSpace(pieces[4])
+			default:
+				return api.Upstream{}, fmt.Errorf("upstream structured incorrectly: %s", rawUpstream)
+			}
+			fallthrough
+		case 4:
+			if strings.TrimSpace(pieces[3]) == "ns" {
+				namespace = strings.TrimSpace(pieces[2])
+			} else {
+				return api.Upstream{}, fmt.Errorf("upstream structured incorrectly: %s", rawUpstream)
+			}
+			fallthrough
+		case 2:
+			if strings.TrimSpace(pieces[1]) == "svc" {
+				svcName = strings.TrimSpace(pieces[0])
+			}
+		default:
+			return api.Upstream{}, fmt.Errorf("upstream structured incorrectly: %s", rawUpstream)
+		}
+	} else {
+		switch len(pieces) {
+		case 4:
+			end := strings.TrimSpace(pieces[3])
+			switch end {
+			case "peer":
+				peer = strings.TrimSpace(pieces[2])
+			case "dc":
+				datacenter = strings.TrimSpace(pieces[2])
+			default:
+				return api.Upstream{}, fmt.Errorf("upstream structured incorrectly: %s", rawUpstream)
+			}
+			fallthrough
+		case 2:
+			svcName = strings.TrimSpace(pieces[0])
+		default:
+			return api.Upstream{}, fmt.Errorf("upstream structured incorrectly: %s", rawUpstream)
+		}
+	}
+
+	if svcName == "" {
+		return api.Upstream{}, fmt.Errorf("upstream structured incorrectly: %s", rawUpstream)
+	}
+	return api.Upstream{
+		DestinationType:      api.UpstreamDestTypeService,
+		DestinationPartition: partition,
+		DestinationPeer:      peer,
+		DestinationNamespace: namespace,
+		DestinationName:      svcName,
+		Datacenter:           datacenter,
+		LocalBindPort:        port,
+	}, nil
+}
+
+// upstreamPort returns the local port of an upstream, which can be a named port of the pod.
+func upstreamPort(pod corev1.Pod, rawUpstream, rawPort string) (int, error) {
+	port, err := PortValue(pod, strings.TrimSpace(rawPort))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("upstream %s is invalid: port %q must be a port number or a named port of the pod", rawUpstream, strings.TrimSpace(rawPort))
+	}
+	return int(port), nil
+}
+
+// applyUpstreamOptions sets the options that follow an upstream on the upstream.
+func applyUpstreamOptions(upstream *api.Upstream, rawUpstream string, options []string) error {
+	for _, option := range options {
+		key, value, _ := strings.Cut(option, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case upstreamOptionMeshGateway:
+			switch mode := api.MeshGatewayMode(value); mode {
+			case api.MeshGatewayModeLocal, api.MeshGatewayModeRemote, api.MeshGatewayModeNone:
+				upstream.MeshGateway.Mode = mode
+			default:
+				return fmt.Errorf("upstream %s is invalid: %s option value of %s must be one of %s, %s or %s", rawUpstream,
+					upstreamOptionMeshGateway, value, api.MeshGatewayModeLocal, api.MeshGatewayModeRemote, api.MeshGatewayModeNone)
+			}
+		default:
+			return fmt.Errorf("upstream %s is invalid: unknown option %q", rawUpstream, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseUpstreams(t *testing.T) {
+	cases := map[string]struct {
+		upstreams        string
+		enableNamespaces bool
+		expected         []api.Upstream
+		expErr           string
+	}{
+		"no upstreams": {},
+		"named port": {
+			upstreams: "web:web-port",
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "web", LocalBindPort: 9090},
+			},
+		},
+		"unlabeled upstream with peer": {
+			upstreams:        "web.ns1..peer1:1234",
+			enableNamespaces: true,
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "web", DestinationNamespace: "ns1", DestinationPeer: "peer1", LocalBindPort: 1234},
+			},
+		},
+		"unlabeled upstream with partition and datacenter": {
+			upstreams:        "web.ns1.part1:1234:dc2",
+			enableNamespaces: true,
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "web", DestinationNamespace: "ns1", DestinationPartition: "part1", Datacenter: "dc2", LocalBindPort: 1234},
+			},
+		},
+		"mesh gateway option": {
+			upstreams: "web.svc.peer1.peer:1234;mesh-gateway=local, prepared_query:query:2234; mesh-gateway=remote",
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "web", DestinationPeer: "peer1", LocalBindPort: 1234, MeshGateway: api.MeshGatewayConfig{Mode: api.MeshGatewayModeLocal}},
+				{DestinationType: api.UpstreamDestTypePreparedQuery, DestinationName: "query", LocalBindPort: 2234, MeshGateway: api.MeshGatewayConfig{Mode: api.MeshGatewayModeRemote}},
+			},
+		},
+		"error: missing port": {
+			upstreams: "web",
+			expErr:    "upstream structured incorrectly: web",
+		},
+		"error: invalid port": {
+			upstreams: "web:http",
+			expErr:    `upstream web:http is invalid: port "http" must be a port number or a named port of the pod`,
+		},
+		"error: missing service name": {
+			upstreams: ":1234",
+			expErr:    "upstream structured incorrectly: :1234",
+		},
+		"error: prepared query without port": {
+			upstreams: "prepared_query:query",
+			expErr:    "prepared query upstream structured incorrectly: prepared_query:query",
+		},
+		"error: peer and datacenter": {
+			upstreams:        "web.ns1..peer1:1234:dc2",
+			enableNamespaces: true,
+			expErr:           "upstream web.ns1..peer1:1234:dc2 is invalid: a peer can't be used with a partition or datacenter",
+		},
+		"error: invalid mesh gateway mode": {
+			upstreams: "web:1234;mesh-gateway=sideways",
+			expErr:    "upstream web:1234 is invalid: mesh-gateway option value of sideways must be one of local, remote or none",
+		},
+		"error: unknown option": {
+			upstreams: "web:1234;timeout=5s",
+			expErr:    `upstream web:1234 is invalid: unknown option "timeout"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{constants.AnnotationUpstreams: c.upstreams},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "web",
+							Ports: []corev1.ContainerPort{{Name: "web-port", ContainerPort: 9090}},
+						},
+					},
+				},
+			}
+			upstreams, err := ParseUpstreams(pod, c.enableNamespaces, false)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expected, upstreams)
+		})
+	}
+}
@@ -8,10 +8,24 @@ const (
 	// a pod after an injection is done.
 	KeyInjectStatus = "consul.hashicorp.com/connect-inject-status"
 
+	// KeyInjectDecision is the key of the annotation that is added to a pod in dry-run
+	// mode with the injection decision, either "inject" or "skip".
+	KeyInjectDecision = "consul.hashicorp.com/connect-inject-decision"
+
+	// KeyInjectDecisionReason is the key of the annotation that is added to a pod in
+	// dry-run mode with the reason for the injection decision.
+	KeyInjectDecisionReason = "consul.hashicorp.com/connect-inject-decision-reason"
+
 	// KeyTransparentProxyStatus is the key of the annotation that is added to
 	// a pod when transparent proxy is done.
 	KeyTransparentProxyStatus = "consul.hashicorp.com/transparent-proxy-status"
 
+	// KeyMutualTLSMode is the key of the annotation that is added to a pod with the
+	// mutual TLS mode of its service in Consul. Pods with the value "permissive" still
+	// accept incoming traffic that isn't using mTLS. The annotation is removed
+	// if neither the service-defaults of the service nor the global proxy-defaults set a mode.
+	KeyMutualTLSMode = "consul.hashicorp.com/mutual-tls-mode"
+
 	// KeyManagedBy is the key of the label that is added to pods managed
 	// by the Endpoints controller. This is to support upgrading from consul-k8s
 	// without Endpoints controller to consul-k8s with Endpoints controller
@@ -77,7 +91,8 @@ const (
 	// proxy in the format of `<service-name>:<local-port>,...`. The
 	// service name should map to a Consul service namd and the local port
 	// is the local port in the pod that the listener will bind to. It can
-	// be a named port.
+	// be a named port. Each upstream can be followed by options, e.g.
+	// `<service-name>:<local-port>;mesh-gateway=local`.
 	AnnotationUpstreams = "consul.hashicorp.com/connect-service-upstreams"
 
 	// AnnotationTags is a list of tags to register with the service
@@ -89,6 +104,11 @@ const (
 	// e.g. consul.hashicorp.com/service-meta-foo:bar.
 	AnnotationMeta = "consul.hashicorp.com/service-meta-"
 
+	// AnnotationProbeHealthChecks mirrors the liveness and readiness probes of the pod's application containers
+	// into Consul health checks of its service instance when set to true. It overrides the default of the
+	// endpoints controller. This annotation takes a boolean value (true/false).
+	AnnotationProbeHealthChecks = "consul.hashicorp.com/probe-health-checks"
+
 	// AnnotationUseProxyHealthCheck creates a readiness listener on the sidecar proxy and
 	// queries this instead of the application health check for the status of the application.
 	// Enable this only if the application does not support health checks.
@@ -106,11 +126,21 @@ const (
 	AnnotationSidecarProxyLifecycleShutdownGracePeriodSeconds   = "consul.hashicorp.com/sidecar-proxy-lifecycle-shutdown-grace-period-seconds"
 	AnnotationSidecarProxyLifecycleGracefulPort                 = "consul.hashicorp.com/sidecar-proxy-lifecycle-graceful-port"
 	AnnotationSidecarProxyLifecycleGracefulShutdownPath         = "consul.hashicorp.com/sidecar-proxy-lifecycle-graceful-shutdown-path"
+	AnnotationSidecarProxyLifecycleStartupGracePeriodSeconds    = "consul.hashicorp.com/sidecar-proxy-lifecycle-startup-grace-period-seconds"
+	AnnotationSidecarProxyLifecycleGracefulStartupPath          = "consul.hashicorp.com/sidecar-proxy-lifecycle-graceful-startup-path"
 
 	// annotations for sidecar volumes.
 	AnnotationConsulSidecarUserVolume      = "consul.hashicorp.com/consul-sidecar-user-volume"
 	AnnotationConsulSidecarUserVolumeMount = "consul.hashicorp.com/consul-sidecar-user-volume-mount"
 
+	// annotations for the security profiles of the injected init and sidecar containers, e.g. for hardened node
+	// images whose default profiles block iptables or Envoy's hot restart. The seccomp and AppArmor profiles
+	// are runtime/default, unconfined or localhost/<profile>. The SELinux options are a comma-separated list
+	// of user=<user>, role=<role>, type=<type> and level=<level>, e.g. type=spc_t.
+	AnnotationSeccompProfile  = "consul.hashicorp.com/seccomp-profile"
+	AnnotationAppArmorProfile = "consul.hashicorp.com/apparmor-profile"
+	AnnotationSELinuxOptions  = "consul.hashicorp.com/selinux-options"
+
 	// annotations for sidecar concurrency.
 	AnnotationEnvoyProxyConcurrency = "consul.hashicorp.com/consul-envoy-proxy-concurrency"
 
@@ -125,6 +155,16 @@ const (
 	AnnotationServiceMetricsPort   = "consul.hashicorp.com/service-metrics-port"
 	AnnotationServiceMetricsPath   = "consul.hashicorp.com/service-metrics-path"
 
+	// annotations for configuring StatsD and DogStatsD sinks for Envoy metrics.
+	// The stats tags are a comma-separated list of name=value tags.
+	AnnotationStatsdURL    = "consul.hashicorp.com/statsd-url"
+	AnnotationDogstatsdURL = "consul.hashicorp.com/dogstatsd-url"
+	AnnotationStatsTags    = "consul.hashicorp.com/stats-tags"
+
+	// annotations for configuring Envoy tracing.
+	AnnotationEnableTracing             = "consul.hashicorp.com/enable-tracing"
+	AnnotationTracingSamplingPercentage = "consul.hashicorp.com/tracing-sampling-percentage"
+
 	// annotations for configuring TLS for Prometheus.
 	AnnotationPrometheusCAFile   = "consul.hashicorp.com/prometheus-ca-file"
 	AnnotationPrometheusCAPath   = "consul.hashicorp.com/prometheus-ca-path"
@@ -153,6 +193,19 @@ const (
 	// This annotation/label takes a boolean value (true/false).
 	KeyTransparentProxy = "consul.hashicorp.com/transparent-proxy"
 
+	// AnnotationMeshGatewayMode is the mesh gateway mode of the pod's proxy: local, remote or none. It can also be set
+	// as an annotation on a namespace to define the default mode for connect-injected pods which do not otherwise
+	// override this setting with their own annotation. If it's not set, the mode of the proxy-defaults and
+	// service-defaults config entries applies.
+	AnnotationMeshGatewayMode = "consul.hashicorp.com/mesh-gateway-mode"
+
+	// AnnotationConsulDataplaneImage and AnnotationConsulK8sImage can be set on a namespace to override the
+	// consul-dataplane and consul-k8s-control-plane images that its pods are injected with, e.g. to try out a
+	// new version in one namespace before rolling it out to the cluster. They're only used when the injector
+	// allows namespace image overrides.
+	AnnotationConsulDataplaneImage = "consul.hashicorp.com/consul-dataplane-image"
+	AnnotationConsulK8sImage       = "consul.hashicorp.com/consul-k8s-image"
+
 	// AnnotationTProxyExcludeInboundPorts is a comma-separated list of inbound ports to exclude from traffic redirection.
 	AnnotationTProxyExcludeInboundPorts = "consul.hashicorp.com/transparent-proxy-exclude-inbound-ports"
 
@@ -160,11 +213,36 @@ const (
 	AnnotationTProxyExcludeOutboundPorts = "consul.hashicorp.com/transparent-proxy-exclude-outbound-ports"
 
 	// AnnotationTProxyExcludeOutboundCIDRs is a comma-separated list of outbound CIDRs to exclude from traffic redirection.
+	// Entries may be CIDR blocks or single IP addresses, e.g. "169.254.169.254,10.0.0.0/8", and must match the
+	// IP family of the cluster.
 	AnnotationTProxyExcludeOutboundCIDRs = "consul.hashicorp.com/transparent-proxy-exclude-outbound-cidrs"
 
 	// AnnotationTProxyExcludeUIDs is a comma-separated list of additional user IDs to exclude from traffic redirection.
 	AnnotationTProxyExcludeUIDs = "consul.hashicorp.com/transparent-proxy-exclude-uids"
 
+	// AnnotationTProxyInboundOnly redirects only the inbound traffic of the pod to its proxy when set to "true".
+	// Outbound traffic isn't captured, so the pod can reach destinations outside the mesh directly while still
+	// serving mTLS to other services in the mesh.
+	AnnotationTProxyInboundOnly = "consul.hashicorp.com/transparent-proxy-inbound-only"
+
+	// AnnotationNodeProxy, when set to "true", opts the pod into the experimental node proxy mode. The pod isn't
+	// injected with a sidecar proxy. Instead, its proxy service instance is registered with a listener on the
+	// proxy of its Kubernetes node, which is shared by all pods on the node that opt in.
+	AnnotationNodeProxy = "consul.hashicorp.com/node-proxy"
+
+	// AnnotationDebugContainerUID is the user ID that ephemeral debug containers of the pod run as. It's
+	// excluded from traffic redirection so that debug containers bypass the mesh.
+	AnnotationDebugContainerUID = "consul.hashicorp.com/debug-container-uid"
+
+	// AnnotationACLTokenSecret is the name of a Secret in the pod's namespace that holds an ACL token for the pod,
+	// e.g. one that's pre-provisioned from Vault. The init container and consul-dataplane use this token
+	// instead of logging in with the Kubernetes auth method.
+	AnnotationACLTokenSecret = "consul.hashicorp.com/acl-token-secret"
+
+	// AnnotationACLTokenSecretKey is the key of the ACL token in the Secret named by AnnotationACLTokenSecret.
+	// Defaults to "token".
+	AnnotationACLTokenSecretKey = "consul.hashicorp.com/acl-token-secret-key"
+
 	// AnnotationTransparentProxyOverwriteProbes controls whether the Kubernetes probes should be overwritten
 	// to point to the Envoy proxy when running in Transparent Proxy mode.
 	AnnotationTransparentProxyOverwriteProbes = "consul.hashicorp.com/transparent-proxy-overwrite-probes"
@@ -177,10 +255,42 @@ const (
 	// webhook/meshWebhook.
 	AnnotationOriginalPod = "consul.hashicorp.com/original-pod"
 
+	// AnnotationInjectionCorrelationID is the correlation ID of the admission request that injected
+	// the pod. The endpoints controller tags the logs and events of the pod's registration with it.
+	AnnotationInjectionCorrelationID = "consul.hashicorp.com/injection-correlation-id"
+
 	// AnnotationPeeringVersion is the version of the peering resource and can be utilized
 	// to explicitly perform the peering operation again.
 	AnnotationPeeringVersion = "consul.hashicorp.com/peering-version"
 
+	// AnnotationExportToPeers is a comma-separated list of the cluster peers that the Consul
+	// service of a Kubernetes service is exported to, e.g. "peer1,peer2". It's set on the
+	// Kubernetes service and aggregated into the partition's ExportedServices resource.
+	AnnotationExportToPeers = "consul.hashicorp.com/export-to-peers"
+
+	// AnnotationManagedExports is set on the ExportedServices resource to the services that were
+	// added to it from the export-to-peers annotations of Kubernetes services.
+	AnnotationManagedExports = "consul.hashicorp.com/managed-exports"
+
+	// AnnotationMeshEgressGateway is set on a Kubernetes service of type ExternalName to the name of the
+	// TerminatingGateway resource that mesh traffic to the external host egresses through. The host is
+	// registered as a Consul service and linked to the gateway. The Consul service has the name of the
+	// Kubernetes service with Consul namespace mirroring, and <name>-<namespace> otherwise.
+	AnnotationMeshEgressGateway = "consul.hashicorp.com/mesh-egress-gateway"
+
+	// AnnotationMeshEgressProtocol is the protocol of the external service, e.g. "http". It's set in
+	// the ServiceDefaults resource that is created for the service. Defaults to "tcp".
+	AnnotationMeshEgressProtocol = "consul.hashicorp.com/mesh-egress-protocol"
+
+	// AnnotationMeshEgressCAFile is the path to the CA certificate in the terminating gateway pods
+	// that the gateway verifies the external host with. When it's set, the gateway originates TLS
+	// connections to the host, with the external name of the service as the SNI.
+	AnnotationMeshEgressCAFile = "consul.hashicorp.com/mesh-egress-ca-file"
+
+	// AnnotationManagedLinkedServices is set on TerminatingGateway resources to the services that
+	// were added to them from the mesh-egress-gateway annotations of Kubernetes services.
+	AnnotationManagedLinkedServices = "consul.hashicorp.com/managed-linked-services"
+
 	// AnnotationConsulK8sVersion is the current version of this binary.
 	AnnotationConsulK8sVersion = "consul.hashicorp.com/connect-k8s-version"
 
@@ -188,10 +298,22 @@ const (
 	// registered with Consul.
 	LabelServiceIgnore = "consul.hashicorp.com/service-ignore"
 
+	// LabelConnectInject is a label that can be added to a namespace to enable or disable
+	// injection of its pods. It should be set to "enabled" or "disabled". The
+	// consul.hashicorp.com/connect-inject annotation of a pod takes precedence over it.
+	LabelConnectInject = "consul.hashicorp.com/connect-inject"
+	LabelValueEnabled  = "enabled"
+	LabelValueDisabled = "disabled"
+
 	// LabelPeeringToken is a label that can be added to a secret to allow it to be watched
 	// by the peering controllers.
 	LabelPeeringToken = "consul.hashicorp.com/peering-token"
 
+	// LabelConsulCNIVersion is the label that the CNI installer adds to its node with the
+	// version of the consul-cni plugin installed on it. It is compared with the version of
+	// the connect-inject webhook to detect version skew during upgrades.
+	LabelConsulCNIVersion = "consul.hashicorp.com/consul-cni-version"
+
 	// Injected is used as the annotation value for keyInjectStatus and annotationInjected.
 	Injected = "injected"
 
@@ -33,4 +33,12 @@ const (
 
 	// DefaultGracefulShutdownPath is the default path that consul-dataplane uses for graceful shutdown.
 	DefaultGracefulShutdownPath = "/graceful_shutdown"
+
+	// DefaultGracefulStartupPath is the default path that consul-dataplane uses for graceful startup.
+	DefaultGracefulStartupPath = "/graceful_startup"
+
+	// PodConditionMeshReady is the type of the pod condition that reports whether the pod's
+	// sidecar proxy is registered with Consul and passing its health checks. Pods can list it
+	// as a readiness gate so that rollouts wait for the proxy to be ready.
+	PodConditionMeshReady = "consul.hashicorp.com/mesh-ready"
 )
@@ -0,0 +1,187 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package argorollouts
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// AnnotationServiceSplitter is the annotation on a Rollout that names the ServiceSplitter in its
+	// namespace whose weights follow the rollout.
+	AnnotationServiceSplitter = "consul.hashicorp.com/service-splitter"
+)
+
+// RolloutGVK is the group version kind of Argo Rollouts. Rollouts are read as unstructured objects
+// so that the Argo Rollouts API isn't a dependency.
+var RolloutGVK = schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}
+
+// Controller shifts mesh traffic between the stable and canary versions of Argo Rollouts. Rollouts
+// that are annotated with a ServiceSplitter must use the canary strategy with a stableService and a
+// canaryService, and the ServiceSplitter must only have splits for those services. The controller
+// sets the weights of the splits from the weight of the current step of the rollout, so that Consul,
+// rather than the number of replicas, controls the share of traffic that the canary receives.
+//
+// The ServiceSplitter itself is written to Consul by the config entry controllers.
+type Controller struct {
+	client.Client
+	// Log is the logger for this controller.
+	Log logr.Logger
+}
+
+// Reconcile sets the weights of the rollout's ServiceSplitter.
+func (r *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	rollout := newRollout()
+	if err := r.Client.Get(ctx, req.NamespacedName, rollout); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		r.Log.Error(err, "failed to get rollout", "name", req.Name, "ns", req.Namespace)
+		return ctrl.Result{}, err
+	}
+	splitterName := rollout.GetAnnotations()[AnnotationServiceSplitter]
+	if splitterName == "" {
+		return ctrl.Result{}, nil
+	}
+
+	stableService, canaryService, err := rolloutServices(rollout)
+	if err != nil {
+		// The rollout has to be fixed before it can be reconciled, so it's not requeued.
+		r.Log.Error(err, "rollout can't be used with a service splitter", "name", req.Name, "ns", req.Namespace)
+		return ctrl.Result{}, nil
+	}
+	weight, err := canaryWeight(rollout)
+	if err != nil {
+		r.Log.Error(err, "failed to get canary weight of rollout", "name", req.Name, "ns", req.Namespace)
+		return ctrl.Result{}, nil
+	}
+
+	var splitter v1alpha1.ServiceSplitter
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: splitterName, Namespace: req.Namespace}, &splitter); err != nil {
+		r.Log.Error(err, "failed to get service splitter", "name", splitterName, "ns", req.Namespace)
+		return ctrl.Result{}, err
+	}
+
+	var foundStable, foundCanary, foundOther, changed bool
+	for i := range splitter.Spec.Splits {
+		split := &splitter.Spec.Splits[i]
+		var w float32
+		switch split.Service {
+		case stableService:
+			foundStable, w = true, float32(100-weight)
+		case canaryService:
+			foundCanary, w = true, float32(weight)
+		default:
+			foundOther = true
+			continue
+		}
+		if split.Weight != w {
+			split.Weight = w
+			changed = true
+		}
+	}
+	// The weights of the splits must add up to 100, which they only do if there are no other splits.
+	if !foundStable || !foundCanary || foundOther {
+		r.Log.Error(fmt.Errorf("service splitter must only have splits for services %q and %q", stableService, canaryService),
+			"failed to update service splitter", "name", splitterName, "ns", req.Namespace)
+		return ctrl.Result{}, nil
+	}
+	if !changed {
+		return ctrl.Result{}, nil
+	}
+	if err := r.Client.Update(ctx, &splitter); err != nil {
+		r.Log.Error(err, "failed to update service splitter", "name", splitterName, "ns", req.Namespace)
+		return ctrl.Result{}, err
+	}
+	r.Log.Info("shifted traffic to canary", "rollout", req.Name, "ns", req.Namespace, "splitter", splitterName, "weight", weight)
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *Controller) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("argo-rollouts").
+		For(newRollout(), builder.WithPredicates(predicate.NewPredicateFuncs(func(object client.Object) bool {
+			return object.GetAnnotations()[AnnotationServiceSplitter] != ""
+		}))).
+		Complete(r)
+}
+
+func newRollout() *unstructured.Unstructured {
+	rollout := &unstructured.Unstructured{}
+	rollout.SetGroupVersionKind(RolloutGVK)
+	return rollout
+}
+
+// rolloutServices returns the stable and canary services of the rollout's canary strategy.
+func rolloutServices(rollout *unstructured.Unstructured) (string, string, error) {
+	stable, _, err := unstructured.NestedString(rollout.Object, "spec", "strategy", "canary", "stableService")
+	if err != nil {
+		return "", "", err
+	}
+	canary, _, err := unstructured.NestedString(rollout.Object, "spec", "strategy", "canary", "canaryService")
+	if err != nil {
+		return "", "", err
+	}
+	if stable == "" || canary == "" {
+		return "", "", fmt.Errorf("rollout must use the canary strategy with a stableService and a canaryService")
+	}
+	return stable, canary, nil
+}
+
+// canaryWeight returns the percentage of traffic that should go to the canary of the rollout. Like
+// Argo Rollouts itself, it's the weight of the last setWeight step up to the current step, or 100
+// once all the steps are complete. All traffic goes to the stable service once the rollout is
+// aborted or fully promoted, at which point the stable service selects the new pods.
+func canaryWeight(rollout *unstructured.Unstructured) (int64, error) {
+	if aborted, _, _ := unstructured.NestedBool(rollout.Object, "status", "abort"); aborted {
+		return 0, nil
+	}
+	if weight, found, err := unstructured.NestedInt64(rollout.Object, "status", "canary", "weights", "canary", "weight"); err != nil {
+		return 0, err
+	} else if found {
+		return weight, nil
+	}
+	stableRS, _, _ := unstructured.NestedString(rollout.Object, "status", "stableRS")
+	currentPodHash, _, _ := unstructured.NestedString(rollout.Object, "status", "currentPodHash")
+	if stableRS == "" || stableRS == currentPodHash {
+		return 0, nil
+	}
+
+	steps, _, err := unstructured.NestedSlice(rollout.Object, "spec", "strategy", "canary", "steps")
+	if err != nil {
+		return 0, err
+	}
+	index, _, err := unstructured.NestedInt64(rollout.Object, "status", "currentStepIndex")
+	if err != nil {
+		return 0, err
+	}
+	if index >= int64(len(steps)) {
+		return 100, nil
+	}
+	for i := index; i >= 0; i-- {
+		step, ok := steps[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if weight, found, err := unstructured.NestedInt64(step, "setWeight"); err != nil {
+			return 0, err
+		} else if found {
+			return weight, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,135 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package argorollouts
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcile(t *testing.T) {
+	steps := []interface{}{
+		map[string]interface{}{"setWeight": int64(20)},
+		map[string]interface{}{"pause": map[string]interface{}{}},
+		map[string]interface{}{"setWeight": int64(50)},
+		map[string]interface{}{"pause": map[string]interface{}{"duration": "10m"}},
+	}
+	cases := map[string]struct {
+		status     map[string]interface{}
+		splits     []v1alpha1.ServiceSplit
+		expWeights []float32
+	}{
+		"all traffic goes to stable before a rollout": {
+			status:     map[string]interface{}{"stableRS": "abc", "currentPodHash": "abc", "currentStepIndex": int64(4)},
+			expWeights: []float32{100, 0},
+		},
+		"first step": {
+			status:     map[string]interface{}{"stableRS": "abc", "currentPodHash": "def", "currentStepIndex": int64(0)},
+			expWeights: []float32{80, 20},
+		},
+		"paused after a step keeps its weight": {
+			status:     map[string]interface{}{"stableRS": "abc", "currentPodHash": "def", "currentStepIndex": int64(3)},
+			expWeights: []float32{50, 50},
+		},
+		"all steps complete": {
+			status:     map[string]interface{}{"stableRS": "abc", "currentPodHash": "def", "currentStepIndex": int64(4)},
+			expWeights: []float32{0, 100},
+		},
+		"aborted rollout": {
+			status:     map[string]interface{}{"stableRS": "abc", "currentPodHash": "def", "currentStepIndex": int64(2), "abort": true},
+			expWeights: []float32{100, 0},
+		},
+		"weight set by Argo Rollouts": {
+			status: map[string]interface{}{
+				"stableRS": "abc", "currentPodHash": "def", "currentStepIndex": int64(0),
+				"canary": map[string]interface{}{"weights": map[string]interface{}{"canary": map[string]interface{}{"weight": int64(35)}}},
+			},
+			expWeights: []float32{65, 35},
+		},
+		"splitter with splits of other services is unchanged": {
+			status: map[string]interface{}{"stableRS": "abc", "currentPodHash": "def", "currentStepIndex": int64(0)},
+			splits: []v1alpha1.ServiceSplit{
+				{Service: "web-stable", Weight: 90},
+				{Service: "web-canary"},
+				{Service: "legacy", Weight: 10},
+			},
+			expWeights: []float32{90, 0, 10},
+		},
+		"splitter without the rollout's services is unchanged": {
+			status:     map[string]interface{}{"stableRS": "abc", "currentPodHash": "def", "currentStepIndex": int64(0)},
+			splits:     []v1alpha1.ServiceSplit{{Service: "web-stable", Weight: 100}},
+			expWeights: []float32{100},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			rollout := newRollout()
+			rollout.SetName("web")
+			rollout.SetNamespace("default")
+			rollout.SetAnnotations(map[string]string{AnnotationServiceSplitter: "web"})
+			rollout.Object["spec"] = map[string]interface{}{
+				"strategy": map[string]interface{}{
+					"canary": map[string]interface{}{
+						"stableService": "web-stable",
+						"canaryService": "web-canary",
+						"steps":         steps,
+					},
+				},
+			}
+			rollout.Object["status"] = c.status
+
+			splits := c.splits
+			if splits == nil {
+				splits = []v1alpha1.ServiceSplit{{Service: "web-stable", Weight: 100}, {Service: "web-canary"}}
+			}
+			splitter := &v1alpha1.ServiceSplitter{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec:       v1alpha1.ServiceSplitterSpec{Splits: splits},
+			}
+
+			s := scheme.Scheme
+			s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.ServiceSplitter{})
+			fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(rollout, splitter).Build()
+
+			ctrlr := &Controller{
+				Client: fakeClient,
+				Log:    logrtest.New(t),
+			}
+			_, err := ctrlr.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: "web", Namespace: "default"},
+			})
+			require.NoError(t, err)
+
+			var updated v1alpha1.ServiceSplitter
+			require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "web", Namespace: "default"}, &updated))
+			var weights []float32
+			for _, split := range updated.Spec.Splits {
+				weights = append(weights, split.Weight)
+			}
+			require.Equal(t, c.expWeights, weights)
+		})
+	}
+}
+
+func TestRolloutServices(t *testing.T) {
+	rollout := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"strategy": map[string]interface{}{
+				"blueGreen": map[string]interface{}{"activeService": "web"},
+			},
+		},
+	}}
+	_, _, err := rolloutServices(rollout)
+	require.EqualError(t, err, "rollout must use the canary strategy with a stableService and a canaryService")
+}
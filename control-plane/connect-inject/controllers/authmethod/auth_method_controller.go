@@ -0,0 +1,219 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package authmethod
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul/api"
+	authv1 "k8s.io/api/authentication/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// minTokenTTL is the minimum lifetime of tokens that the Kubernetes API issues.
+	minTokenTTL = 10 * time.Minute
+
+	// defaultResyncPeriod is how often the auth method is checked if the reviewer token isn't
+	// rotated.
+	defaultResyncPeriod = time.Minute
+
+	// retryPeriod is how long to wait before trying again after the auth method couldn't be
+	// updated.
+	retryPeriod = 10 * time.Second
+
+	// stopTimeout is how long the controller tries to point the auth method back at the
+	// Kubernetes API server when it stops.
+	stopTimeout = 10 * time.Second
+)
+
+// Controller keeps the config of Consul's Kubernetes auth method up to date.
+//
+// If TokenTTL is set, it rotates the ServiceAccountJWT of the auth method, which Consul uses to
+// create TokenReviews, with a short-lived token of the reviewer service account. The token is
+// replaced when half of its lifetime has passed, so that Consul doesn't rely on the long-lived
+// token in the auth method secret that server-acl-init configures.
+//
+// If ReviewHost is set, it points the auth method at the token review cache that the connect
+// injector serves, with the CA of the injector's webhook certificate. The auth method is pointed
+// back at the Kubernetes API server while ReviewServiceName has no ready endpoints and when the
+// controller stops, so that logins don't fail while the connect injector is down. The token review
+// cache can only be used if the Consul servers run in the cluster, since they can't reach the
+// connect injector's service otherwise.
+//
+// Controller is a manager.Runnable.
+type Controller struct {
+	// Clientset creates tokens for the reviewer service account and reads the CA of the webhook
+	// configuration.
+	Clientset kubernetes.Interface
+	// ConsulClientConfig is the config to create a Consul API client.
+	ConsulClientConfig *consul.Config
+	// ConsulServerConnMgr is the watcher for the Consul server addresses.
+	ConsulServerConnMgr consul.ServerConnectionManager
+	// AuthMethodName is the name of the auth method.
+	AuthMethodName string
+	// AuthMethodNamespace is the Consul namespace of the auth method.
+	AuthMethodNamespace string
+	// Namespace is the Kubernetes namespace of the reviewer service account.
+	Namespace string
+	// ReviewerServiceAccount is the name of the service account that Consul reviews tokens with.
+	ReviewerServiceAccount string
+	// TokenTTL is the lifetime of the reviewer tokens. The reviewer token isn't rotated if zero.
+	TokenTTL time.Duration
+	// ReviewHost is the URL of the token review cache, e.g. https://consul-connect-injector.consul.svc.
+	// The host of the auth method isn't changed if empty.
+	ReviewHost string
+	// ReviewServiceName is the name of the service in Namespace that serves the token review cache.
+	ReviewServiceName string
+	// APIServerHost and APIServerCACert are the URL and CA of the Kubernetes API server that the
+	// auth method is pointed back at if the token review cache isn't available.
+	APIServerHost   string
+	APIServerCACert string
+	// WebhookConfigName is the name of the MutatingWebhookConfiguration whose CA bundle is the CA of
+	// the token review cache.
+	WebhookConfigName string
+	// Log is the logger for this controller.
+	Log logr.Logger
+}
+
+// Start updates the auth method until the context is cancelled.
+func (c *Controller) Start(ctx context.Context) error {
+	period := defaultResyncPeriod
+	if c.TokenTTL > 0 {
+		period = c.tokenTTL() / 2
+	}
+	for {
+		wait := period
+		if err := c.apply(ctx, false); err != nil {
+			c.Log.Error(err, "failed to update auth method", "name", c.AuthMethodName)
+			wait = retryPeriod
+		}
+		select {
+		case <-ctx.Done():
+			if c.ReviewHost != "" {
+				stopCtx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+				defer cancel()
+				if err := c.apply(stopCtx, true); err != nil {
+					c.Log.Error(err, "failed to point auth method back at the Kubernetes API server", "name", c.AuthMethodName)
+				}
+			}
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+// apply updates the auth method with a new reviewer token and the host of the token review cache.
+// If stopping is true or the token review cache has no ready endpoints, the auth method is pointed
+// at the Kubernetes API server instead.
+func (c *Controller) apply(ctx context.Context, stopping bool) error {
+	serverState, err := c.ConsulServerConnMgr.State()
+	if err != nil {
+		return err
+	}
+	consulClient, err := consul.NewClientFromConnMgrState(c.ConsulClientConfig, serverState)
+	if err != nil {
+		return err
+	}
+	authMethod, _, err := consulClient.ACL().AuthMethodRead(c.AuthMethodName, &api.QueryOptions{Namespace: c.AuthMethodNamespace})
+	if err != nil {
+		return err
+	}
+	if authMethod == nil {
+		return fmt.Errorf("auth method %s does not exist", c.AuthMethodName)
+	}
+	if authMethod.Config == nil {
+		authMethod.Config = map[string]interface{}{}
+	}
+
+	changed := false
+	if c.ReviewHost != "" {
+		host, caCert, err := c.reviewHost(ctx, stopping)
+		if err != nil {
+			return err
+		}
+		if authMethod.Config["Host"] != host || authMethod.Config["CACert"] != caCert {
+			authMethod.Config["Host"] = host
+			authMethod.Config["CACert"] = caCert
+			changed = true
+		}
+	}
+	if c.TokenTTL > 0 && !stopping {
+		expirationSeconds := int64(c.tokenTTL().Seconds())
+		token, err := c.Clientset.CoreV1().ServiceAccounts(c.Namespace).CreateToken(ctx, c.ReviewerServiceAccount, &authv1.TokenRequest{
+			Spec: authv1.TokenRequestSpec{ExpirationSeconds: &expirationSeconds},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("creating token for service account %s: %w", c.ReviewerServiceAccount, err)
+		}
+		authMethod.Config["ServiceAccountJWT"] = token.Status.Token
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	if _, _, err := consulClient.ACL().AuthMethodUpdate(authMethod, &api.WriteOptions{Namespace: c.AuthMethodNamespace}); err != nil {
+		return err
+	}
+	c.Log.Info("updated auth method", "name", c.AuthMethodName, "host", authMethod.Config["Host"], "rotated-token", c.TokenTTL > 0 && !stopping)
+	return nil
+}
+
+// reviewHost returns the host and CA that Consul should review tokens with: the token review cache
+// if it has ready endpoints, or the Kubernetes API server otherwise.
+func (c *Controller) reviewHost(ctx context.Context, stopping bool) (string, string, error) {
+	if stopping {
+		return c.APIServerHost, c.APIServerCACert, nil
+	}
+	endpoints, err := c.Clientset.CoreV1().Endpoints(c.Namespace).Get(ctx, c.ReviewServiceName, metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return "", "", err
+	}
+	ready := false
+	if err == nil {
+		for _, subset := range endpoints.Subsets {
+			if len(subset.Addresses) > 0 {
+				ready = true
+			}
+		}
+	}
+	if !ready {
+		c.Log.Info("token review cache has no ready endpoints, using the Kubernetes API server", "service", c.ReviewServiceName)
+		return c.APIServerHost, c.APIServerCACert, nil
+	}
+	caCert, err := c.webhookCACert(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	return c.ReviewHost, caCert, nil
+}
+
+// webhookCACert returns the CA bundle of the webhook configuration, which is the CA of the
+// certificate that the connect injector serves the token review cache with.
+func (c *Controller) webhookCACert(ctx context.Context) (string, error) {
+	webhookConfig, err := c.Clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, c.WebhookConfigName, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	for _, webhook := range webhookConfig.Webhooks {
+		if len(webhook.ClientConfig.CABundle) > 0 {
+			return string(webhook.ClientConfig.CABundle), nil
+		}
+	}
+	return "", fmt.Errorf("webhook configuration %s has no CA bundle yet", c.WebhookConfigName)
+}
+
+func (c *Controller) tokenTTL() time.Duration {
+	if c.TokenTTL < minTokenTTL {
+		return minTokenTTL
+	}
+	return c.TokenTTL
+}
@@ -0,0 +1,225 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package authmethod
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/test"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	authv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestApply(t *testing.T) {
+	webhookConfig := &admissionv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-connect-injector"},
+		Webhooks: []admissionv1.MutatingWebhook{{
+			Name:         "consul-connect-injector.consul.hashicorp.com",
+			ClientConfig: admissionv1.WebhookClientConfig{CABundle: []byte("webhook-ca")},
+		}},
+	}
+
+	readyEndpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-connect-injector", Namespace: "consul"},
+		Subsets:    []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}},
+	}
+
+	cases := map[string]struct {
+		tokenTTL      time.Duration
+		reviewHost    string
+		endpoints     *corev1.Endpoints
+		stopping      bool
+		existing      map[string]interface{}
+		expUpdate     bool
+		expConfig     map[string]interface{}
+		expExpiration int64
+	}{
+		"rotates the reviewer token": {
+			tokenTTL:  time.Hour,
+			existing:  map[string]interface{}{"Host": "https://kubernetes.default.svc", "CACert": "k8s-ca", "ServiceAccountJWT": "long-lived"},
+			expUpdate: true,
+			expConfig: map[string]interface{}{
+				"Host":              "https://kubernetes.default.svc",
+				"CACert":            "k8s-ca",
+				"ServiceAccountJWT": "rotated-token",
+			},
+			expExpiration: 3600,
+		},
+		"requests tokens for at least the minimum lifetime": {
+			tokenTTL:  time.Minute,
+			existing:  map[string]interface{}{"ServiceAccountJWT": "long-lived"},
+			expUpdate: true,
+			expConfig: map[string]interface{}{
+				"ServiceAccountJWT": "rotated-token",
+			},
+			expExpiration: 600,
+		},
+		"points the auth method at the token review cache": {
+			reviewHost: "https://consul-connect-injector.consul.svc",
+			endpoints:  readyEndpoints,
+			existing:   map[string]interface{}{"Host": "https://kubernetes.default.svc", "CACert": "k8s-ca", "ServiceAccountJWT": "long-lived"},
+			expUpdate:  true,
+			expConfig: map[string]interface{}{
+				"Host":              "https://consul-connect-injector.consul.svc",
+				"CACert":            "webhook-ca",
+				"ServiceAccountJWT": "long-lived",
+			},
+		},
+		"does not update an auth method that points at the token review cache": {
+			reviewHost: "https://consul-connect-injector.consul.svc",
+			endpoints:  readyEndpoints,
+			existing:   map[string]interface{}{"Host": "https://consul-connect-injector.consul.svc", "CACert": "webhook-ca", "ServiceAccountJWT": "long-lived"},
+		},
+		"points the auth method back at the API server if the token review cache has no ready endpoints": {
+			reviewHost: "https://consul-connect-injector.consul.svc",
+			endpoints: &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Name: "consul-connect-injector", Namespace: "consul"},
+				Subsets:    []corev1.EndpointSubset{{NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}},
+			},
+			existing:  map[string]interface{}{"Host": "https://consul-connect-injector.consul.svc", "CACert": "webhook-ca", "ServiceAccountJWT": "long-lived"},
+			expUpdate: true,
+			expConfig: map[string]interface{}{
+				"Host":              "https://kubernetes.default.svc",
+				"CACert":            "k8s-ca",
+				"ServiceAccountJWT": "long-lived",
+			},
+		},
+		"points the auth method back at the API server if the token review cache has no endpoints": {
+			reviewHost: "https://consul-connect-injector.consul.svc",
+			existing:   map[string]interface{}{"Host": "https://consul-connect-injector.consul.svc", "CACert": "webhook-ca", "ServiceAccountJWT": "long-lived"},
+			expUpdate:  true,
+			expConfig: map[string]interface{}{
+				"Host":              "https://kubernetes.default.svc",
+				"CACert":            "k8s-ca",
+				"ServiceAccountJWT": "long-lived",
+			},
+		},
+		"points the auth method back at the API server and doesn't rotate the token when stopping": {
+			tokenTTL:   time.Hour,
+			reviewHost: "https://consul-connect-injector.consul.svc",
+			endpoints:  readyEndpoints,
+			stopping:   true,
+			existing:   map[string]interface{}{"Host": "https://consul-connect-injector.consul.svc", "CACert": "webhook-ca", "ServiceAccountJWT": "rotated-token"},
+			expUpdate:  true,
+			expConfig: map[string]interface{}{
+				"Host":              "https://kubernetes.default.svc",
+				"CACert":            "k8s-ca",
+				"ServiceAccountJWT": "rotated-token",
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var updated *api.ACLAuthMethod
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "/v1/acl/auth-method/consul-k8s-auth-method", r.URL.Path)
+				switch r.Method {
+				case http.MethodGet:
+					require.NoError(t, json.NewEncoder(w).Encode(api.ACLAuthMethod{
+						Name:   "consul-k8s-auth-method",
+						Type:   "kubernetes",
+						Config: c.existing,
+					}))
+				case http.MethodPut:
+					updated = &api.ACLAuthMethod{}
+					require.NoError(t, json.NewDecoder(r.Body).Decode(updated))
+					require.NoError(t, json.NewEncoder(w).Encode(updated))
+				}
+			}))
+			t.Cleanup(consulServer.Close)
+			serverURL, err := url.Parse(consulServer.URL)
+			require.NoError(t, err)
+			port, err := strconv.Atoi(serverURL.Port())
+			require.NoError(t, err)
+
+			var expiration int64
+			objects := []runtime.Object{webhookConfig}
+			if c.endpoints != nil {
+				objects = append(objects, c.endpoints)
+			}
+			clientset := fake.NewSimpleClientset(objects...)
+			clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				createAction := action.(k8stesting.CreateAction)
+				require.Equal(t, "token", createAction.GetSubresource())
+				tokenRequest := createAction.GetObject().(*authv1.TokenRequest)
+				expiration = *tokenRequest.Spec.ExpirationSeconds
+				tokenRequest.Status.Token = "rotated-token"
+				return true, tokenRequest, nil
+			})
+
+			controller := &Controller{
+				Clientset:              clientset,
+				ConsulClientConfig:     &consul.Config{APIClientConfig: &api.Config{}, HTTPPort: port},
+				ConsulServerConnMgr:    test.MockConnMgrForIPAndPort(serverURL.Hostname(), 0),
+				AuthMethodName:         "consul-k8s-auth-method",
+				Namespace:              "consul",
+				ReviewerServiceAccount: "consul-auth-method",
+				TokenTTL:               c.tokenTTL,
+				ReviewHost:             c.reviewHost,
+				ReviewServiceName:      "consul-connect-injector",
+				APIServerHost:          "https://kubernetes.default.svc",
+				APIServerCACert:        "k8s-ca",
+				WebhookConfigName:      "consul-connect-injector",
+				Log:                    logrtest.New(t),
+			}
+			require.NoError(t, controller.apply(context.Background(), c.stopping))
+
+			require.Equal(t, c.expExpiration, expiration)
+			if !c.expUpdate {
+				require.Nil(t, updated)
+				return
+			}
+			require.NotNil(t, updated)
+			require.Equal(t, c.expConfig, updated.Config)
+		})
+	}
+}
+
+func TestApply_WebhookWithoutCABundle(t *testing.T) {
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewEncoder(w).Encode(api.ACLAuthMethod{Name: "consul-k8s-auth-method", Type: "kubernetes"}))
+	}))
+	t.Cleanup(consulServer.Close)
+	serverURL, err := url.Parse(consulServer.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(serverURL.Port())
+	require.NoError(t, err)
+
+	controller := &Controller{
+		Clientset: fake.NewSimpleClientset(
+			&admissionv1.MutatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: "consul-connect-injector"},
+			},
+			&corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Name: "consul-connect-injector", Namespace: "consul"},
+				Subsets:    []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}},
+			},
+		),
+		ConsulClientConfig:  &consul.Config{APIClientConfig: &api.Config{}, HTTPPort: port},
+		ConsulServerConnMgr: test.MockConnMgrForIPAndPort(serverURL.Hostname(), 0),
+		AuthMethodName:      "consul-k8s-auth-method",
+		Namespace:           "consul",
+		ReviewHost:          "https://consul-connect-injector.consul.svc",
+		ReviewServiceName:   "consul-connect-injector",
+		WebhookConfigName:   "consul-connect-injector",
+		Log:                 logrtest.New(t),
+	}
+	require.EqualError(t, controller.apply(context.Background(), false), "webhook configuration consul-connect-injector has no CA bundle yet")
+}
@@ -0,0 +1,321 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package certmetrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// certExpiryMetric is the Envoy stat with the number of days until the first certificate
+	// that the proxy serves or validates with expires. Consul rotates leaf certificates well
+	// before they expire, so a proxy whose stat keeps dropping isn't getting new certificates.
+	certExpiryMetric = "envoy_server_days_until_first_cert_expiring"
+
+	// defaultResyncPeriod is how often the proxies are scraped.
+	defaultResyncPeriod = time.Minute
+	// defaultScrapeTimeout is how long to wait for the metrics of a single proxy.
+	defaultScrapeTimeout = 5 * time.Second
+	// scrapeConcurrency is the number of proxies that are scraped at once.
+	scrapeConcurrency = 16
+
+	defaultConsulNamespace = "default"
+	defaultConsulPartition = "default"
+)
+
+var labels = []string{"service", "namespace", "partition"}
+
+var (
+	// daysUntilExpiration is the smallest number of days until the certificates of a proxy of the
+	// service expire. It's served on the manager's metrics endpoint like the other metrics below.
+	daysUntilExpiration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "consul_connect_inject",
+		Name:      "leaf_cert_days_until_expiration",
+		Help:      "Smallest number of days until the leaf or CA certificates of a proxy of the service expire.",
+	}, labels)
+	scrapedProxies = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "consul_connect_inject",
+		Name:      "leaf_cert_proxies",
+		Help:      "Number of proxies of the service whose certificate expiration was read.",
+	}, labels)
+	scrapeErrors = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "consul_connect_inject",
+		Name:      "leaf_cert_scrape_errors",
+		Help:      "Number of proxies of the service whose certificate expiration couldn't be read.",
+	}, labels)
+)
+
+func init() {
+	metrics.Registry.MustRegister(daysUntilExpiration, scrapedProxies, scrapeErrors)
+}
+
+// Controller aggregates the certificate expiration of all injected sidecar proxies and gateways
+// into metrics on the manager's metrics endpoint, labeled with the Consul service, namespace and
+// partition, so that a failure to rotate certificates can be alerted on centrally instead of
+// scraping every proxy.
+//
+// The expiration is read from the Envoy stats that consul-dataplane serves on the port and path
+// of the prometheus.io annotations. Pods without metrics enabled aren't included.
+//
+// Controller is a manager.Runnable that scrapes the proxies on every resync.
+type Controller struct {
+	// Client lists pods and endpoints. When it is the manager's client they are read from the
+	// informer cache.
+	Client client.Reader
+	// HTTPClient scrapes the proxies. Defaults to a client with a five second timeout.
+	HTTPClient *http.Client
+	// Partition is the Consul admin partition that the services are registered in.
+	Partition string
+	// ResyncPeriod is how often the proxies are scraped. Defaults to one minute.
+	ResyncPeriod time.Duration
+	// Log is the logger for this controller.
+	Log logr.Logger
+}
+
+// serviceKey identifies a Consul service by the values of its metric labels.
+type serviceKey struct {
+	service   string
+	namespace string
+	partition string
+}
+
+// proxyTarget is a proxy to scrape and the services that it's a proxy for.
+type proxyTarget struct {
+	pod      string
+	url      string
+	services []serviceKey
+}
+
+// serviceStats are the aggregated certificate stats of the proxies of a service.
+type serviceStats struct {
+	minDays float64
+	proxies int
+	errors  int
+}
+
+// Start scrapes the proxies until the context is cancelled.
+func (c *Controller) Start(ctx context.Context) error {
+	period := c.ResyncPeriod
+	if period == 0 {
+		period = defaultResyncPeriod
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		if err := c.sync(ctx); err != nil {
+			c.Log.Error(err, "failed to update certificate metrics")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync scrapes the certificate expiration of every proxy and replaces the metrics with the
+// aggregated stats of each service.
+func (c *Controller) sync(ctx context.Context) error {
+	targets, err := c.targets(ctx)
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	stats := make(map[serviceKey]*serviceStats)
+	sem := make(chan struct{}, scrapeConcurrency)
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		target := target
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			days, err := c.scrape(ctx, target.url)
+			if err != nil {
+				c.Log.V(1).Info("failed to read certificate expiration", "pod", target.pod, "error", err.Error())
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, key := range target.services {
+				s, ok := stats[key]
+				if !ok {
+					s = &serviceStats{minDays: -1}
+					stats[key] = s
+				}
+				if err != nil {
+					s.errors++
+					continue
+				}
+				s.proxies++
+				if s.minDays < 0 || days < s.minDays {
+					s.minDays = days
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Reset the metrics so that services without proxies anymore aren't reported.
+	daysUntilExpiration.Reset()
+	scrapedProxies.Reset()
+	scrapeErrors.Reset()
+	for key, s := range stats {
+		if s.proxies > 0 {
+			daysUntilExpiration.WithLabelValues(key.service, key.namespace, key.partition).Set(s.minDays)
+		}
+		scrapedProxies.WithLabelValues(key.service, key.namespace, key.partition).Set(float64(s.proxies))
+		scrapeErrors.WithLabelValues(key.service, key.namespace, key.partition).Set(float64(s.errors))
+	}
+	return nil
+}
+
+// targets returns the running injected pods and gateways that serve metrics. The services of
+// injected pods are the services in their annotation or, like the endpoints controller
+// registers them, the Kubernetes services whose endpoints they are.
+func (c *Controller) targets(ctx context.Context) ([]proxyTarget, error) {
+	var pods corev1.PodList
+	if err := c.Client.List(ctx, &pods); err != nil {
+		return nil, err
+	}
+	var endpointsList corev1.EndpointsList
+	if err := c.Client.List(ctx, &endpointsList); err != nil {
+		return nil, err
+	}
+	endpointServices := make(map[string][]string)
+	for _, endpoints := range endpointsList.Items {
+		for _, subset := range endpoints.Subsets {
+			for _, address := range append(subset.Addresses, subset.NotReadyAddresses...) {
+				if address.TargetRef == nil || address.TargetRef.Kind != "Pod" {
+					continue
+				}
+				pod := endpoints.Namespace + "/" + address.TargetRef.Name
+				endpointServices[pod] = append(endpointServices[pod], endpoints.Name)
+			}
+		}
+	}
+
+	partition := c.Partition
+	if partition == "" {
+		partition = defaultConsulPartition
+	}
+
+	var targets []proxyTarget
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+			continue
+		}
+		if pod.Annotations[constants.AnnotationPrometheusScrape] != "true" || pod.Annotations[constants.AnnotationPrometheusPort] == "" {
+			continue
+		}
+
+		var names []string
+		namespace := defaultConsulNamespace
+		switch {
+		case pod.Annotations[constants.AnnotationGatewayKind] != "":
+			if name := pod.Annotations[constants.AnnotationGatewayConsulServiceName]; name != "" {
+				names = []string{name}
+			}
+			if ns := pod.Annotations[constants.AnnotationGatewayNamespace]; ns != "" {
+				namespace = ns
+			}
+		case pod.Labels[constants.KeyInjectStatus] == constants.Injected:
+			if raw := pod.Annotations[constants.AnnotationService]; raw != "" {
+				names = strings.Split(raw, ",")
+			} else {
+				names = endpointServices[pod.Namespace+"/"+pod.Name]
+			}
+			if ns := pod.Annotations[constants.AnnotationConsulNamespace]; ns != "" {
+				namespace = ns
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+
+		target := proxyTarget{
+			pod: pod.Namespace + "/" + pod.Name,
+			url: c.metricsURL(pod),
+		}
+		for _, name := range names {
+			target.services = append(target.services, serviceKey{
+				service:   strings.TrimSpace(name),
+				namespace: namespace,
+				partition: partition,
+			})
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// metricsURL returns the URL of the metrics of the pod from its prometheus.io annotations.
+func (c *Controller) metricsURL(pod corev1.Pod) string {
+	path := pod.Annotations[constants.AnnotationPrometheusPath]
+	if path == "" {
+		path = "/metrics"
+	}
+	return fmt.Sprintf("http://%s%s", net.JoinHostPort(pod.Status.PodIP, pod.Annotations[constants.AnnotationPrometheusPort]), path)
+}
+
+// scrape returns the number of days until the first certificate of the proxy expires. The
+// metrics are scanned line by line rather than parsed since the merged metrics of
+// consul-dataplane can repeat metric families.
+func (c *Controller) scrape(ctx context.Context, url string) (float64, error) {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultScrapeTimeout}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, certExpiryMetric) {
+			continue
+		}
+		rest := line[len(certExpiryMetric):]
+		if !strings.HasPrefix(rest, " ") && !strings.HasPrefix(rest, "{") {
+			continue
+		}
+		fields := strings.Fields(rest[strings.LastIndex(rest, "}")+1:])
+		if len(fields) == 0 {
+			continue
+		}
+		return strconv.ParseFloat(fields[0], 64)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("%s not found in the metrics of %s", certExpiryMetric, url)
+}
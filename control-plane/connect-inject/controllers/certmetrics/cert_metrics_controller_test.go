@@ -0,0 +1,112 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package certmetrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSync(t *testing.T) {
+	// Each proxy serves the days until its certificates expire on its own path.
+	proxies := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/web-1":
+			fmt.Fprintln(w, "# TYPE envoy_server_days_until_first_cert_expiring gauge")
+			fmt.Fprintln(w, "envoy_server_days_until_first_cert_expiring{} 2")
+		case "/web-2":
+			fmt.Fprintln(w, "envoy_server_days_until_first_cert_expiring_total 9")
+			fmt.Fprintln(w, "envoy_server_days_until_first_cert_expiring 0")
+		case "/gateway":
+			fmt.Fprintln(w, "envoy_server_days_until_first_cert_expiring 1")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(proxies.Close)
+	serverURL, err := url.Parse(proxies.URL)
+	require.NoError(t, err)
+
+	objs := []client.Object{
+		injectedPod("web-1", serverURL, "/web-1", map[string]string{constants.AnnotationConsulNamespace: "ns1"}),
+		injectedPod("web-2", serverURL, "/web-2", map[string]string{constants.AnnotationConsulNamespace: "ns1"}),
+		injectedPod("api", serverURL, "/api", map[string]string{constants.AnnotationService: "api"}),
+		injectedPod("no-metrics", nil, "", map[string]string{constants.AnnotationService: "no-metrics"}),
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "mesh-gateway",
+				Namespace: "consul",
+				Annotations: map[string]string{
+					constants.AnnotationGatewayKind:              "mesh-gateway",
+					constants.AnnotationGatewayConsulServiceName: "mesh-gateway",
+					constants.AnnotationPrometheusScrape:         "true",
+					constants.AnnotationPrometheusPort:           serverURL.Port(),
+					constants.AnnotationPrometheusPath:           "/gateway",
+				},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: serverURL.Hostname()},
+		},
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Subsets: []corev1.EndpointSubset{{
+				Addresses:         []corev1.EndpointAddress{{IP: "10.0.0.1", TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "web-1"}}},
+				NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.2", TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "web-2"}}},
+			}},
+		},
+	}
+	controller := &Controller{
+		Client:    fake.NewClientBuilder().WithObjects(objs...).Build(),
+		Partition: "ap1",
+		Log:       logrtest.New(t),
+	}
+	require.NoError(t, controller.sync(context.Background()))
+
+	require.Equal(t, float64(0), testutil.ToFloat64(daysUntilExpiration.WithLabelValues("web", "ns1", "ap1")))
+	require.Equal(t, float64(2), testutil.ToFloat64(scrapedProxies.WithLabelValues("web", "ns1", "ap1")))
+	require.Equal(t, float64(0), testutil.ToFloat64(scrapeErrors.WithLabelValues("web", "ns1", "ap1")))
+
+	require.Equal(t, float64(1), testutil.ToFloat64(daysUntilExpiration.WithLabelValues("mesh-gateway", "default", "ap1")))
+	require.Equal(t, float64(1), testutil.ToFloat64(scrapedProxies.WithLabelValues("mesh-gateway", "default", "ap1")))
+
+	require.Equal(t, float64(0), testutil.ToFloat64(scrapedProxies.WithLabelValues("api", "default", "ap1")))
+	require.Equal(t, float64(1), testutil.ToFloat64(scrapeErrors.WithLabelValues("api", "default", "ap1")))
+
+	// Services are only reported while they have proxies that serve metrics.
+	require.Equal(t, 3, testutil.CollectAndCount(scrapedProxies))
+	require.Equal(t, 2, testutil.CollectAndCount(daysUntilExpiration))
+}
+
+// injectedPod returns a running injected pod that serves its metrics on the path of the server.
+// If server is nil the pod doesn't have metrics enabled.
+func injectedPod(name string, server *url.URL, path string, annotations map[string]string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Labels:      map[string]string{constants.KeyInjectStatus: constants.Injected},
+			Annotations: annotations,
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "127.0.0.1"},
+	}
+	if server != nil {
+		pod.Status.PodIP = server.Hostname()
+		pod.Annotations[constants.AnnotationPrometheusScrape] = "true"
+		pod.Annotations[constants.AnnotationPrometheusPort] = server.Port()
+		pod.Annotations[constants.AnnotationPrometheusPath] = path
+	}
+	return pod
+}
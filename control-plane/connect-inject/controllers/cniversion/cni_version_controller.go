@@ -0,0 +1,134 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package cniversion
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// EventReasonVersionSkew is the reason of the event recorded on a node when the
+	// consul-cni plugin installed on it is a different version than the webhook.
+	EventReasonVersionSkew = "ConsulCNIVersionSkew"
+)
+
+// skewedNodes is the number of nodes where the installed consul-cni plugin is a different
+// version than the connect-inject webhook. It is served on the manager's metrics endpoint.
+var skewedNodes = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "consul_connect_inject",
+	Name:      "cni_version_skew_nodes",
+	Help:      "Number of nodes where the installed consul-cni plugin version differs from the connect-inject version.",
+})
+
+func init() {
+	metrics.Registry.MustRegister(skewedNodes)
+}
+
+// Checker compares the consul-cni version that the CNI installer labels each node with
+// against the version of the connect-inject webhook.
+type Checker struct {
+	// Client is used to list the nodes. When it is the manager's client the nodes are
+	// read from the informer cache.
+	Client client.Reader
+	// Version is the version that the consul-cni plugin is expected to be.
+	Version string
+}
+
+// SkewedNodes returns the sorted names of the nodes where the installed consul-cni plugin is a
+// different version. Nodes without the version label, e.g. nodes that the CNI daemonset has not
+// been scheduled on yet, are not considered skewed since their version is unknown.
+func (c *Checker) SkewedNodes(ctx context.Context) ([]string, error) {
+	var nodes corev1.NodeList
+	if err := c.Client.List(ctx, &nodes, client.HasLabels{constants.LabelConsulCNIVersion}); err != nil {
+		return nil, err
+	}
+	var skewed []string
+	for _, node := range nodes.Items {
+		if c.IsSkewed(node) {
+			skewed = append(skewed, node.Name)
+		}
+	}
+	sort.Strings(skewed)
+	return skewed, nil
+}
+
+// NodeSkewed returns true if the consul-cni plugin on the named node is a different version.
+func (c *Checker) NodeSkewed(ctx context.Context, nodeName string) (bool, error) {
+	var node corev1.Node
+	if err := c.Client.Get(ctx, types.NamespacedName{Name: nodeName}, &node); err != nil {
+		return false, err
+	}
+	return c.IsSkewed(node), nil
+}
+
+// IsSkewed returns true if the node is labeled with a consul-cni version that is different
+// from the expected version.
+func (c *Checker) IsSkewed(node corev1.Node) bool {
+	version, ok := node.Labels[constants.LabelConsulCNIVersion]
+	return ok && version != c.Version
+}
+
+// Controller watches the consul-cni version label on nodes. It records an event on the nodes
+// where the plugin is a different version than the webhook and exports the number of those
+// nodes as a metric.
+type Controller struct {
+	client.Client
+	// Checker compares the node versions with the webhook's version.
+	Checker *Checker
+	// Recorder records the version skew events on the nodes.
+	Recorder record.EventRecorder
+	// Log is the logger for this controller.
+	Log logr.Logger
+}
+
+// Reconcile records an event if the node's consul-cni version is skewed and updates the skew metric.
+func (r *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var node corev1.Node
+	err := r.Client.Get(ctx, req.NamespacedName, &node)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		r.Log.Error(err, "failed to get node", "name", req.Name)
+		return ctrl.Result{}, err
+	}
+
+	if err == nil && r.Checker.IsSkewed(node) {
+		version := node.Labels[constants.LabelConsulCNIVersion]
+		r.Log.Info("consul-cni version differs from connect-inject version", "node", node.Name,
+			"consul-cni-version", version, "connect-inject-version", r.Checker.Version)
+		r.Recorder.Event(&node, corev1.EventTypeWarning, EventReasonVersionSkew,
+			fmt.Sprintf("consul-cni version %s differs from connect-inject version %s", version, r.Checker.Version))
+	}
+
+	// Recount rather than tracking nodes individually so that deleted nodes are accounted for.
+	skewed, err := r.Checker.SkewedNodes(ctx)
+	if err != nil {
+		r.Log.Error(err, "failed to list nodes")
+		return ctrl.Result{}, err
+	}
+	skewedNodes.Set(float64(len(skewed)))
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager. Only changes to node labels are
+// reconciled since nodes are updated frequently with status heartbeats.
+func (r *Controller) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("cni-version").
+		For(&corev1.Node{}, builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		Complete(r)
+}
@@ -0,0 +1,114 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package cniversion
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestChecker(t *testing.T) {
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(
+		testNode("node-c", "1.1.0"),
+		testNode("node-b", "1.2.0-dev"),
+		testNode("node-a", "1.1.0"),
+		testNode("node-unlabeled", ""),
+	).Build()
+	checker := &Checker{Client: fakeClient, Version: "1.2.0-dev"}
+
+	skewed, err := checker.SkewedNodes(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"node-a", "node-c"}, skewed)
+
+	cases := map[string]bool{
+		"node-a":         true,
+		"node-b":         false,
+		"node-unlabeled": false,
+	}
+	for name, exp := range cases {
+		t.Run(name, func(t *testing.T) {
+			isSkewed, err := checker.NodeSkewed(context.Background(), name)
+			require.NoError(t, err)
+			require.Equal(t, exp, isSkewed)
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	cases := map[string]struct {
+		node      string
+		nodes     []runtime.Object
+		expEvents []string
+		expSkewed float64
+	}{
+		"node with the same version": {
+			node:      "node-a",
+			nodes:     []runtime.Object{testNode("node-a", "1.2.0-dev")},
+			expSkewed: 0,
+		},
+		"node without the version label": {
+			node:      "node-a",
+			nodes:     []runtime.Object{testNode("node-a", "")},
+			expSkewed: 0,
+		},
+		"node with a different version": {
+			node:      "node-a",
+			nodes:     []runtime.Object{testNode("node-a", "1.1.0"), testNode("node-b", "1.1.0")},
+			expEvents: []string{"Warning ConsulCNIVersionSkew consul-cni version 1.1.0 differs from connect-inject version 1.2.0-dev"},
+			expSkewed: 2,
+		},
+		"deleted node": {
+			node:      "node-a",
+			nodes:     []runtime.Object{testNode("node-b", "1.1.0")},
+			expSkewed: 1,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(c.nodes...).Build()
+			recorder := record.NewFakeRecorder(10)
+			controller := &Controller{
+				Client:   fakeClient,
+				Checker:  &Checker{Client: fakeClient, Version: "1.2.0-dev"},
+				Recorder: recorder,
+				Log:      logrtest.New(t),
+			}
+
+			resp, err := controller.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: c.node},
+			})
+			require.NoError(t, err)
+			require.False(t, resp.Requeue)
+
+			close(recorder.Events)
+			var events []string
+			for event := range recorder.Events {
+				events = append(events, event)
+			}
+			require.Equal(t, c.expEvents, events)
+			require.Equal(t, c.expSkewed, testutil.ToFloat64(skewedNodes))
+		})
+	}
+}
+
+func testNode(name, version string) *corev1.Node {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if version != "" {
+		node.Labels = map[string]string{constants.LabelConsulCNIVersion: version}
+	}
+	return node
+}
@@ -5,6 +5,8 @@ package endpoints
 
 import (
 	"fmt"
+	"net"
+	"strconv"
 
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
@@ -50,7 +52,7 @@ func (r *Controller) consulClientCfgForNodeAgent(serverClient *api.Client, pod c
 	if r.consulClientHttpPort != 0 {
 		consulClientHttpPort = r.consulClientHttpPort
 	}
-	ccCfg.Address = fmt.Sprintf("%s:%d", pod.Status.HostIP, consulClientHttpPort)
+	ccCfg.Address = net.JoinHostPort(pod.Status.HostIP, strconv.Itoa(consulClientHttpPort))
 
 	ccCfg.Token = state.Token
 
@@ -90,7 +92,7 @@ func (r *Controller) updateHealthCheckOnConsulClient(consulClientCfg *api.Config
 	if err != nil {
 		return err
 	}
-	filter := fmt.Sprintf(`Name == "Kubernetes Health Check" and ServiceID == %q`, serviceID(pod, endpoints))
+	filter := fmt.Sprintf(`Name == "Kubernetes Health Check" and ServiceID == %q`, r.serviceID(pod, endpoints))
 	checks, err := consulClient.Agent().ChecksWithFilter(filter)
 	if err != nil {
 		return err
@@ -99,7 +101,7 @@ func (r *Controller) updateHealthCheckOnConsulClient(consulClientCfg *api.Config
 		return fmt.Errorf("more than one Kubernetes health check found")
 	}
 	if len(checks) == 0 {
-		r.Log.Info("detected no health checks to update", "name", endpoints.Name, "ns", endpoints.Namespace, "service-id", serviceID(pod, endpoints))
+		r.Log.Info("detected no health checks to update", "name", endpoints.Name, "ns", endpoints.Namespace, "service-id", r.serviceID(pod, endpoints))
 		return nil
 	}
 	for checkID := range checks {
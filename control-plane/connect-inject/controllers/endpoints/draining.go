@@ -0,0 +1,98 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// drainingCheckOutput is the output of the health check of service instances whose pod is terminating.
+const drainingCheckOutput = "Pod is terminating and its connections are draining"
+
+// drainingWeights are the weights of service instances whose pod is terminating. Their health check
+// is in the warning state, so upstreams stop sending new requests to them.
+var drainingWeights = api.AgentWeights{Passing: 1, Warning: 0}
+
+// terminatingPod returns the pod of the service instance if it's terminating, or nil if it isn't
+// or no longer exists.
+func (r *Controller) terminatingPod(svc *api.AgentService, k8sNamespace string) (*corev1.Pod, error) {
+	podName := svc.Meta[constants.MetaKeyPodName]
+	if podName == "" {
+		return nil, nil
+	}
+	var pod corev1.Pod
+	err := r.Client.Get(context.Background(), types.NamespacedName{Name: podName, Namespace: k8sNamespace}, &pod)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if pod.DeletionTimestamp == nil {
+		return nil, nil
+	}
+	return &pod, nil
+}
+
+// drainRemaining returns how much of the drain window of a terminating pod is left. The window
+// starts when the pod's deletion was requested.
+func (r *Controller) drainRemaining(pod corev1.Pod, now time.Time) time.Duration {
+	deletionRequested := pod.DeletionTimestamp.Time
+	if pod.DeletionGracePeriodSeconds != nil {
+		deletionRequested = deletionRequested.Add(-time.Duration(*pod.DeletionGracePeriodSeconds) * time.Second)
+	}
+	return deletionRequested.Add(r.TerminatingPodDrainWindow).Sub(now)
+}
+
+// drainInstance keeps the service instance of a terminating pod registered until its drain window
+// has passed, with a warning health check and a warning weight of 0 so that upstreams stop sending
+// it new requests while in-flight requests complete. It returns how long is left of the window, or
+// 0 if the instance should be deregistered. Gateways are deregistered right away.
+func (r *Controller) drainInstance(apiClient *api.Client, node *api.Node, svc *api.AgentService, k8sNamespace string) (time.Duration, error) {
+	if r.TerminatingPodDrainWindow <= 0 || (svc.Kind != api.ServiceKindTypical && svc.Kind != api.ServiceKindConnectProxy) {
+		return 0, nil
+	}
+	pod, err := r.terminatingPod(svc, k8sNamespace)
+	if err != nil || pod == nil {
+		return 0, err
+	}
+	remaining := r.drainRemaining(*pod, time.Now())
+	if remaining <= 0 {
+		return 0, nil
+	}
+	if svc.Weights == drainingWeights {
+		// The instance is already draining.
+		return remaining, nil
+	}
+
+	r.Log.Info("draining service instance of terminating pod", "svc", svc.ID, "remaining", remaining)
+	svc.Weights = drainingWeights
+	registration := &api.CatalogRegistration{
+		Node:    node.Node,
+		Address: node.Address,
+		Service: svc,
+		Check: &api.AgentCheck{
+			CheckID:   consulHealthCheckID(k8sNamespace, svc.ID),
+			Name:      consulKubernetesCheckName,
+			Type:      consulKubernetesCheckType,
+			Status:    api.HealthWarning,
+			ServiceID: svc.ID,
+			Output:    drainingCheckOutput,
+			Namespace: svc.Namespace,
+		},
+		SkipNodeUpdate: true,
+	}
+	if _, err := apiClient.Catalog().Register(registration, nil); err != nil {
+		r.Log.Error(err, "failed to drain service instance", "id", svc.ID)
+		return 0, err
+	}
+	r.cacheAdd(registration)
+	return remaining, nil
+}
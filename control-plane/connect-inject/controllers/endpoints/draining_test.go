@@ -0,0 +1,185 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDrainRemaining(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	ep := &Controller{TerminatingPodDrainWindow: 10 * time.Second}
+
+	cases := map[string]struct {
+		deletionTimestamp  time.Time
+		gracePeriodSeconds *int64
+		expRemaining       time.Duration
+	}{
+		"deletion requested now": {
+			deletionTimestamp:  now.Add(30 * time.Second),
+			gracePeriodSeconds: pointer.Int64(30),
+			expRemaining:       10 * time.Second,
+		},
+		"deletion requested earlier": {
+			deletionTimestamp:  now.Add(26 * time.Second),
+			gracePeriodSeconds: pointer.Int64(30),
+			expRemaining:       6 * time.Second,
+		},
+		"window has passed": {
+			deletionTimestamp:  now.Add(15 * time.Second),
+			gracePeriodSeconds: pointer.Int64(30),
+			expRemaining:       -5 * time.Second,
+		},
+		"no grace period": {
+			deletionTimestamp: now,
+			expRemaining:      10 * time.Second,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					DeletionTimestamp:          &metav1.Time{Time: c.deletionTimestamp},
+					DeletionGracePeriodSeconds: c.gracePeriodSeconds,
+				},
+			}
+			require.Equal(t, c.expRemaining, ep.drainRemaining(pod, now))
+		})
+	}
+}
+
+func TestDrainInstance(t *testing.T) {
+	t.Parallel()
+
+	terminating := func(requestedAgo time.Duration) *corev1.Pod {
+		pod := createServicePod("pod1", "1.2.3.4", true, true)
+		pod.Finalizers = []string{"test"}
+		pod.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(30*time.Second - requestedAgo)}
+		pod.DeletionGracePeriodSeconds = pointer.Int64(30)
+		return pod
+	}
+	instance := func(kind api.ServiceKind, weights api.AgentWeights) *api.AgentService {
+		return &api.AgentService{
+			ID:      "pod1-service-created",
+			Service: "service-created",
+			Kind:    kind,
+			Address: "1.2.3.4",
+			Meta:    map[string]string{constants.MetaKeyPodName: "pod1", constants.MetaKeyKubeNS: "default"},
+			Weights: weights,
+		}
+	}
+	defaultWeights := api.AgentWeights{Passing: 1, Warning: 1}
+
+	cases := map[string]struct {
+		window      time.Duration
+		pod         *corev1.Pod
+		svc         *api.AgentService
+		expDraining bool
+		expRegister bool
+	}{
+		"window not set": {
+			pod: terminating(time.Second),
+			svc: instance(api.ServiceKindTypical, defaultWeights),
+		},
+		"pod doesn't exist": {
+			window: 10 * time.Second,
+			svc:    instance(api.ServiceKindTypical, defaultWeights),
+		},
+		"pod isn't terminating": {
+			window: 10 * time.Second,
+			pod:    createServicePod("pod1", "1.2.3.4", true, true),
+			svc:    instance(api.ServiceKindTypical, defaultWeights),
+		},
+		"pod is terminating": {
+			window:      10 * time.Second,
+			pod:         terminating(time.Second),
+			svc:         instance(api.ServiceKindTypical, defaultWeights),
+			expDraining: true,
+			expRegister: true,
+		},
+		"proxy of terminating pod": {
+			window:      10 * time.Second,
+			pod:         terminating(time.Second),
+			svc:         instance(api.ServiceKindConnectProxy, defaultWeights),
+			expDraining: true,
+			expRegister: true,
+		},
+		"instance is already draining": {
+			window:      10 * time.Second,
+			pod:         terminating(time.Second),
+			svc:         instance(api.ServiceKindTypical, drainingWeights),
+			expDraining: true,
+		},
+		"window has passed": {
+			window: 10 * time.Second,
+			pod:    terminating(20 * time.Second),
+			svc:    instance(api.ServiceKindTypical, drainingWeights),
+		},
+		"gateways aren't drained": {
+			window: 10 * time.Second,
+			pod:    terminating(time.Second),
+			svc:    instance(api.ServiceKindMeshGateway, defaultWeights),
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var registrations []api.CatalogRegistration
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/v1/catalog/register" {
+					var registration api.CatalogRegistration
+					require.NoError(t, json.NewDecoder(r.Body).Decode(&registration))
+					registrations = append(registrations, registration)
+				}
+				w.Write([]byte("true"))
+			}))
+			t.Cleanup(consulServer.Close)
+			apiClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+			require.NoError(t, err)
+
+			builder := fake.NewClientBuilder()
+			if c.pod != nil {
+				builder = builder.WithRuntimeObjects(c.pod)
+			}
+			ep := &Controller{
+				Client:                    builder.Build(),
+				Log:                       logrtest.New(t),
+				TerminatingPodDrainWindow: c.window,
+			}
+			node := &api.Node{Node: "k8s-node-1-virtual", Address: "127.0.0.1"}
+
+			remaining, err := ep.drainInstance(apiClient, node, c.svc, "default")
+			require.NoError(t, err)
+			if c.expDraining {
+				require.Greater(t, remaining, time.Duration(0))
+			} else {
+				require.Zero(t, remaining)
+			}
+			if !c.expRegister {
+				require.Empty(t, registrations)
+				return
+			}
+			require.Len(t, registrations, 1)
+			require.Equal(t, "k8s-node-1-virtual", registrations[0].Node)
+			require.Equal(t, drainingWeights, registrations[0].Service.Weights)
+			require.Equal(t, api.HealthWarning, registrations[0].Check.Status)
+			require.Equal(t, consulHealthCheckID("default", c.svc.ID), registrations[0].Check.CheckID)
+		})
+	}
+}
@@ -10,13 +10,17 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/common"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/metrics"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/tracing"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/correlation"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/parsetags"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	"github.com/hashicorp/consul/api"
@@ -27,8 +31,10 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 )
 
 const (
@@ -37,6 +43,7 @@ const (
 	metaKeyManagedBy           = "managed-by"
 	metaKeySyntheticNode       = "synthetic-node"
 	metaKeyConsulWANFederation = "consul-wan-federation"
+	metaKeyNetwork             = "network"
 	tokenMetaPodNameKey        = "pod"
 
 	// Gateway types for registration.
@@ -47,12 +54,20 @@ const (
 	kubernetesSuccessReasonMsg           = "Kubernetes health checks passing"
 	envoyPrometheusBindAddr              = "envoy_prometheus_bind_addr"
 	envoyTelemetryCollectorBindSocketDir = "envoy_telemetry_collector_bind_socket_dir"
+	envoyStatsdURL                       = "envoy_statsd_url"
+	envoyDogstatsdURL                    = "envoy_dogstatsd_url"
+	envoyStatsTags                       = "envoy_stats_tags"
 	defaultNS                            = "default"
 
 	// clusterIPTaggedAddressName is the key for the tagged address to store the service's cluster IP and service port
 	// in Consul. Note: This value should not be changed without a corresponding change in Consul.
 	clusterIPTaggedAddressName = "virtual"
 
+	// lanTaggedAddressName and wanTaggedAddressName are the keys for the tagged addresses of services
+	// on a network whose pod IPs aren't routable from other networks.
+	lanTaggedAddressName = "lan"
+	wanTaggedAddressName = "wan"
+
 	// consulNodeAddress is the address of the consul node (defined by ConsulNodeName).
 	// This address does not need to be routable as this node is ephemeral, and we're only providing it because
 	// Consul's API currently requires node address to be provided when registering a node.
@@ -63,6 +78,9 @@ const (
 
 	// consulKubernetesCheckName is the name of health check in Consul for Kubernetes readiness status.
 	consulKubernetesCheckName = "Kubernetes Readiness Check"
+
+	// grpcHealthCheckPath is the HTTP/2 path of the Check method of the grpc.health.v1.Health service.
+	grpcHealthCheckPath = "/grpc.health.v1.Health/Check"
 )
 
 type Controller struct {
@@ -107,7 +125,7 @@ type Controller struct {
 	// EnableTransparentProxy controls whether transparent proxy should be enabled
 	// for all proxy service registrations.
 	EnableTransparentProxy bool
-	// TProxyOverwriteProbes controls whether the endpoints controller should expose pod's HTTP probes
+	// TProxyOverwriteProbes controls whether the endpoints controller should expose pod's HTTP and gRPC probes
 	// via Envoy proxy.
 	TProxyOverwriteProbes bool
 	// AuthMethod is the name of the Kubernetes Auth Method that
@@ -124,7 +142,63 @@ type Controller struct {
 	// with config to enable telemetry forwarding.
 	EnableTelemetryCollector bool
 
+	// EnableIPv6 indicates that pods only have IPv6 addresses, so proxies
+	// are registered with IPv6 loopback and bind addresses.
+	EnableIPv6 bool
+
+	// EnableLocality controls whether service and proxy service instances are
+	// registered with the locality of their Kubernetes node, which is read from
+	// the topology.kubernetes.io/region and topology.kubernetes.io/zone labels.
+	EnableLocality bool
+
+	// EnableProbeHealthChecks mirrors the liveness and readiness probes of the application containers of pods
+	// into Consul health checks of their service instances, unless pods override it with the
+	// consul.hashicorp.com/probe-health-checks annotation.
+	EnableProbeHealthChecks bool
+
+	// Network is the name of the network that pods in this cluster are on. If set, it's added to the
+	// meta of service instances so that instances on different networks can be told apart.
+	Network string
+	// NetworkGatewayAddress and NetworkGatewayPort are the address of the mesh gateway that other
+	// networks reach this cluster's services through. If set, service instances are registered
+	// with it as their wan tagged address and with their pod IP as their lan tagged address, so
+	// that clients on other networks don't dial pod IPs that aren't routable from them.
+	NetworkGatewayAddress string
+	NetworkGatewayPort    int
+
+	// ServiceNameTemplate, if set, is rendered to name the Consul services of pods without
+	// the consul.hashicorp.com/connect-service annotation instead of the Kubernetes service name.
+	ServiceNameTemplate *template.Template
+
+	// NodeProxyPorts, if set, enables the experimental node proxy mode. The proxy service instances of
+	// pods annotated with consul.hashicorp.com/node-proxy are registered as listeners on the proxy of
+	// their Kubernetes node, on the node's address and a port assigned by NodeProxyPorts.
+	NodeProxyPorts *NodeProxyPorts
+
+	// TerminatingPodDrainWindow, if set, keeps the service instances of terminating pods registered for this
+	// long after their deletion is requested, with a warning health check and a warning weight of 0, so that
+	// upstreams stop sending them new requests while in-flight requests complete.
+	TerminatingPodDrainWindow time.Duration
+
+	// ServiceInstanceCache, if set, is used to look up the service instances
+	// registered in Consul instead of querying every node on each reconcile.
+	ServiceInstanceCache *ServiceInstanceCache
+
+	// MutualTLSModes, if set, is used to look up the mutual TLS modes that pods are annotated with
+	// instead of reading the config entries of their services from Consul on each reconcile.
+	MutualTLSModes *MutualTLSModes
+
+	// FullSync, if set, reconciles every Endpoints object when it runs a full sync.
+	FullSync *FullSync
+
+	// Recorder, if set, records events on pods when their service instances are registered or fail to
+	// be registered, and on services when their service instances are deregistered.
+	Recorder record.EventRecorder
+	// registeredInstances holds what the controller remembers about the service instances it registered.
+	registeredInstances registeredInstances
+
 	MetricsConfig metrics.Config
+	TracingConfig tracing.Config
 	Log           logr.Logger
 
 	Scheme *runtime.Scheme
@@ -133,6 +207,10 @@ type Controller struct {
 	// consulClientHttpPort is only used in tests.
 	consulClientHttpPort int
 	NodeMeta             map[string]string
+
+	// NodeLabels are the labels of the Kubernetes node that are synced into
+	// the meta of the Consul node when it is created.
+	NodeLabels []string
 }
 
 // Reconcile reads the state of an Endpoints object for a Kubernetes Service and reconciles Consul services which
@@ -146,17 +224,23 @@ func (r *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return ctrl.Result{}, nil
 	}
 
+	// Tag logs and Consul requests from this reconcile with a correlation ID so they can be
+	// traced back to the reconcile that made them.
+	correlationID := correlation.FromContext(ctx)
+	log := r.Log.WithValues(correlation.LogKey, correlationID)
+
 	// Create Consul client for this reconcile.
 	serverState, err := r.ConsulServerConnMgr.State()
 	if err != nil {
-		r.Log.Error(err, "failed to get Consul server state", "name", req.Name, "ns", req.Namespace)
+		log.Error(err, "failed to get Consul server state", "name", req.Name, "ns", req.Namespace)
 		return ctrl.Result{}, err
 	}
 	apiClient, err := consul.NewClientFromConnMgrState(r.ConsulClientConfig, serverState)
 	if err != nil {
-		r.Log.Error(err, "failed to create Consul API client", "name", req.Name, "ns", req.Namespace)
+		log.Error(err, "failed to create Consul API client", "name", req.Name, "ns", req.Namespace)
 		return ctrl.Result{}, err
 	}
+	correlation.SetConsulHeader(apiClient, correlationID)
 
 	err = r.Client.Get(ctx, req.NamespacedName, &serviceEndpoints)
 
@@ -170,21 +254,23 @@ func (r *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	if k8serrors.IsNotFound(err) {
 		// Deregister all instances in Consul for this service. The function deregisterService handles
 		// the case where the Consul service name is different from the Kubernetes service name.
-		err = r.deregisterService(apiClient, req.Name, req.Namespace, nil)
+		_, err = r.deregisterService(apiClient, req.Name, req.Namespace, nil)
+		r.registeredInstances.retain(req.NamespacedName, endpointPods)
 		return ctrl.Result{}, err
 	} else if err != nil {
-		r.Log.Error(err, "failed to get Endpoints", "name", req.Name, "ns", req.Namespace)
+		log.Error(err, "failed to get Endpoints", "name", req.Name, "ns", req.Namespace)
 		return ctrl.Result{}, err
 	}
 
-	r.Log.Info("retrieved", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+	log.Info("retrieved", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
 
 	// If the endpoints object has the label "consul.hashicorp.com/service-ignore" set to true, deregister all instances in Consul for this service.
 	// It is possible that the endpoints object has never been registered, in which case deregistration is a no-op.
 	if isLabeledIgnore(serviceEndpoints.Labels) {
 		// We always deregister the service to handle the case where a user has registered the service, then added the label later.
-		r.Log.Info("Ignoring endpoint labeled with `consul.hashicorp.com/service-ignore: \"true\"`", "name", req.Name, "namespace", req.Namespace)
-		err = r.deregisterService(apiClient, req.Name, req.Namespace, nil)
+		log.Info("Ignoring endpoint labeled with `consul.hashicorp.com/service-ignore: \"true\"`", "name", req.Name, "namespace", req.Namespace)
+		_, err = r.deregisterService(apiClient, req.Name, req.Namespace, nil)
+		r.registeredInstances.retain(req.NamespacedName, endpointPods)
 		return ctrl.Result{}, err
 	}
 
@@ -192,6 +278,9 @@ func (r *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	// against service instances in Consul to deregister them if they are not in the map.
 	endpointAddressMap := map[string]bool{}
 
+	// mutualTLSModes caches the mutual TLS mode of each Consul service that the pods are annotated with.
+	mutualTLSModes := make(map[string]api.MutualTLSMode)
+
 	// Register all addresses of this Endpoints object as service instances in Consul.
 	for _, subset := range serviceEndpoints.Subsets {
 		for address, healthStatus := range mapAddresses(subset) {
@@ -199,37 +288,47 @@ func (r *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 				var pod corev1.Pod
 				objectKey := types.NamespacedName{Name: address.TargetRef.Name, Namespace: address.TargetRef.Namespace}
 				if err = r.Client.Get(ctx, objectKey, &pod); err != nil {
-					r.Log.Error(err, "failed to get pod", "name", address.TargetRef.Name)
+					log.Error(err, "failed to get pod", "name", address.TargetRef.Name)
 					errs = multierror.Append(errs, err)
 					continue
 				}
 
 				svcName, ok := pod.Annotations[constants.AnnotationKubernetesService]
 				if ok && serviceEndpoints.Name != svcName {
-					r.Log.Info("ignoring endpoint because it doesn't match explicit service annotation", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+					log.Info("ignoring endpoint because it doesn't match explicit service annotation", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
 					// deregistration for service instances that don't match the annotation happens
 					// later because we don't add this pod to the endpointAddressMap.
 					continue
 				}
 
+				// Services that publish not ready addresses keep terminating pods in their Endpoints. Their
+				// instances are drained rather than registered again.
+				if r.TerminatingPodDrainWindow > 0 && pod.DeletionTimestamp != nil {
+					continue
+				}
+
 				if hasBeenInjected(pod) {
 					endpointPods.Add(address.TargetRef.Name)
 					if isConsulDataplaneSupported(pod) {
 						if err = r.registerServicesAndHealthCheck(apiClient, pod, serviceEndpoints, healthStatus, endpointAddressMap); err != nil {
-							r.Log.Error(err, "failed to register services or health check", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+							log.Error(err, "failed to register services or health check", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+							r.recordRegistrationFailed(pod, err)
+							errs = multierror.Append(errs, err)
+						} else if err = r.updateMutualTLSModeAnnotation(ctx, apiClient, pod, serviceEndpoints, mutualTLSModes); err != nil {
+							log.Error(err, "failed to update mutual TLS mode annotation", "name", pod.Name, "ns", pod.Namespace)
 							errs = multierror.Append(errs, err)
 						}
 					} else {
-						r.Log.Info("detected an update to pre-consul-dataplane service", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+						log.Info("detected an update to pre-consul-dataplane service", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
 						nodeAgentClientCfg, err := r.consulClientCfgForNodeAgent(apiClient, pod, serverState)
 						if err != nil {
-							r.Log.Error(err, "failed to create node-local Consul API client", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+							log.Error(err, "failed to create node-local Consul API client", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
 							errs = multierror.Append(errs, err)
 							continue
 						}
-						r.Log.Info("updating health check on the Consul client", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+						log.Info("updating health check on the Consul client", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
 						if err = r.updateHealthCheckOnConsulClient(nodeAgentClientCfg, pod, serviceEndpoints, healthStatus); err != nil {
-							r.Log.Error(err, "failed to update health check on Consul client", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace, "consul-client-ip", pod.Status.HostIP)
+							log.Error(err, "failed to update health check on Consul client", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace, "consul-client-ip", pod.Status.HostIP)
 							errs = multierror.Append(errs, err)
 						}
 						// We want to skip the rest of the reconciliation because we only care about updating health checks for existing services
@@ -242,7 +341,8 @@ func (r *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 				if isGateway(pod) {
 					endpointPods.Add(address.TargetRef.Name)
 					if err = r.registerGateway(apiClient, pod, serviceEndpoints, healthStatus, endpointAddressMap); err != nil {
-						r.Log.Error(err, "failed to register gateway or health check", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+						log.Error(err, "failed to register gateway or health check", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+						r.recordRegistrationFailed(pod, err)
 						errs = multierror.Append(errs, err)
 					}
 				}
@@ -253,12 +353,16 @@ func (r *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	// Compare service instances in Consul with addresses in Endpoints. If an address is not in Endpoints, deregister
 	// from Consul. This uses endpointAddressMap which is populated with the addresses in the Endpoints object during
 	// the registration codepath.
-	if err = r.deregisterService(apiClient, serviceEndpoints.Name, serviceEndpoints.Namespace, endpointAddressMap); err != nil {
-		r.Log.Error(err, "failed to deregister endpoints", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+	// Service instances of terminating pods that are draining are deregistered once their drain window has passed.
+	drainRemaining, err := r.deregisterService(apiClient, serviceEndpoints.Name, serviceEndpoints.Namespace, endpointAddressMap)
+	if err != nil {
+		log.Error(err, "failed to deregister endpoints", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
 		errs = multierror.Append(errs, err)
 	}
+	// Forget the instances of pods that left the Endpoints, however they were deregistered.
+	r.registeredInstances.retain(req.NamespacedName, endpointPods)
 
-	return ctrl.Result{}, errs
+	return ctrl.Result{RequeueAfter: drainRemaining}, errs
 }
 
 func (r *Controller) Logger(name types.NamespacedName) logr.Logger {
@@ -266,9 +370,12 @@ func (r *Controller) Logger(name types.NamespacedName) logr.Logger {
 }
 
 func (r *Controller) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.Endpoints{}).
-		Complete(r)
+	builder := ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Endpoints{})
+	if r.FullSync != nil {
+		builder = builder.Watches(r.FullSync.Source(), &handler.EnqueueRequestForObject{})
+	}
+	return builder.Complete(r)
 }
 
 // registerServicesAndHealthCheck creates Consul registrations for the service and proxy and registers them with Consul.
@@ -283,6 +390,17 @@ func (r *Controller) registerServicesAndHealthCheck(apiClient *api.Client, pod c
 	}
 	// For pods managed by this controller, create and register the service instance.
 	if managedByEndpointsController {
+		nodeProxy, err := r.usesNodeProxy(pod)
+		if err != nil {
+			return err
+		}
+		if nodeProxy {
+			if err := r.reserveNodeProxyPorts(apiClient, pod); err != nil {
+				r.Log.Error(err, "failed to get node proxy port", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+				return err
+			}
+		}
+
 		// Get information from the pod to create service instance registrations.
 		serviceRegistration, proxyServiceRegistration, err := r.createServiceRegistrations(pod, serviceEndpoints, healthStatus)
 		if err != nil {
@@ -292,12 +410,17 @@ func (r *Controller) registerServicesAndHealthCheck(apiClient *api.Client, pod c
 
 		// Register the service instance with Consul.
 		r.Log.Info("registering service with Consul", "name", serviceRegistration.Service.Service,
-			"id", serviceRegistration.ID)
+			"id", serviceRegistration.ID, "injection-"+correlation.LogKey, pod.Annotations[constants.AnnotationInjectionCorrelationID])
 		_, err = apiClient.Catalog().Register(serviceRegistration, nil)
 		if err != nil {
 			r.Log.Error(err, "failed to register service", "name", serviceRegistration.Service.Service)
 			return err
 		}
+		r.cacheAdd(serviceRegistration)
+		if err = r.deregisterStaleProbeHealthChecks(apiClient, pod, serviceEndpoints, serviceRegistration); err != nil {
+			r.Log.Error(err, "failed to deregister stale probe health checks", "name", serviceRegistration.Service.Service)
+			return err
+		}
 
 		// Add manual ip to the VIP table
 		r.Log.Info("adding manual ip to virtual ip table in Consul", "name", serviceRegistration.Service.Service,
@@ -314,6 +437,8 @@ func (r *Controller) registerServicesAndHealthCheck(apiClient *api.Client, pod c
 			r.Log.Error(err, "failed to register proxy service", "name", proxyServiceRegistration.Service.Service)
 			return err
 		}
+		r.cacheAdd(proxyServiceRegistration)
+		r.recordRegistered(pod, serviceEndpoints, serviceRegistration)
 	}
 	return nil
 }
@@ -366,35 +491,47 @@ func (r *Controller) registerGateway(apiClient *api.Client, pod corev1.Pod, serv
 			r.Log.Error(err, "failed to register gateway", "name", serviceRegistration.Service.Service)
 			return err
 		}
+		r.cacheAdd(serviceRegistration)
+		r.recordRegistered(pod, serviceEndpoints, serviceRegistration)
 	}
 
 	return nil
 }
 
 // serviceName computes the service name to register with Consul from the pod and endpoints object. In a single port
-// service, it defaults to the endpoints name, but can be overridden by a pod annotation. In a multi port service, the
-// endpoints name is always used since the pod annotation will have multiple service names listed (one per port).
+// service, it defaults to the endpoints name, or the rendered ServiceNameTemplate if set, but can be overridden by a
+// pod annotation. In a multi port service, the endpoints name is always used since the pod annotation will have
+// multiple service names listed (one per port).
 // Changing the Consul service name via annotations is not supported for multi port services.
-func serviceName(pod corev1.Pod, serviceEndpoints corev1.Endpoints) string {
+func (r *Controller) serviceName(pod corev1.Pod, serviceEndpoints corev1.Endpoints) string {
 	svcName := serviceEndpoints.Name
+	serviceNameFromAnnotation := pod.Annotations[constants.AnnotationService]
 	// If the annotation has a comma, it is a multi port Pod. In that case we always use the name of the endpoint.
-	if serviceNameFromAnnotation, ok := pod.Annotations[constants.AnnotationService]; ok && serviceNameFromAnnotation != "" && !strings.Contains(serviceNameFromAnnotation, ",") {
+	if serviceNameFromAnnotation != "" && !strings.Contains(serviceNameFromAnnotation, ",") {
 		svcName = serviceNameFromAnnotation
+	} else if serviceNameFromAnnotation == "" && r.ServiceNameTemplate != nil {
+		// Fall back to the endpoints name if the template can't be rendered so that the pod is still registered.
+		rendered, err := renderServiceName(r.ServiceNameTemplate, pod, serviceEndpoints)
+		if err != nil {
+			r.Log.Error(err, "failed to render service name template", "name", pod.Name, "ns", pod.Namespace)
+		} else if rendered != "" {
+			svcName = rendered
+		}
 	}
 	return svcName
 }
 
-func serviceID(pod corev1.Pod, serviceEndpoints corev1.Endpoints) string {
-	return fmt.Sprintf("%s-%s", pod.Name, serviceName(pod, serviceEndpoints))
+func (r *Controller) serviceID(pod corev1.Pod, serviceEndpoints corev1.Endpoints) string {
+	return fmt.Sprintf("%s-%s", pod.Name, r.serviceName(pod, serviceEndpoints))
 }
 
-func proxyServiceName(pod corev1.Pod, serviceEndpoints corev1.Endpoints) string {
-	svcName := serviceName(pod, serviceEndpoints)
+func (r *Controller) proxyServiceName(pod corev1.Pod, serviceEndpoints corev1.Endpoints) string {
+	svcName := r.serviceName(pod, serviceEndpoints)
 	return fmt.Sprintf("%s-sidecar-proxy", svcName)
 }
 
-func proxyServiceID(pod corev1.Pod, serviceEndpoints corev1.Endpoints) string {
-	proxySvcName := proxyServiceName(pod, serviceEndpoints)
+func (r *Controller) proxyServiceID(pod corev1.Pod, serviceEndpoints corev1.Endpoints) string {
+	proxySvcName := r.proxyServiceName(pod, serviceEndpoints)
 	return fmt.Sprintf("%s-%s", pod.Name, proxySvcName)
 }
 
@@ -409,7 +546,7 @@ func (r *Controller) createServiceRegistrations(pod corev1.Pod, serviceEndpoints
 		if multiPort := strings.Split(raw, ","); len(multiPort) > 1 {
 			// Figure out which index of the ports annotation to use by
 			// finding the index of the service names annotation.
-			raw = multiPort[getMultiPortIdx(pod, serviceEndpoints)]
+			raw = multiPort[r.getMultiPortIdx(pod, serviceEndpoints)]
 		}
 		if port, err := common.PortValue(pod, raw); port > 0 {
 			if err != nil {
@@ -419,18 +556,21 @@ func (r *Controller) createServiceRegistrations(pod corev1.Pod, serviceEndpoints
 		}
 	}
 
-	var node corev1.Node
-	// Ignore errors because we don't want failures to block running services.
-	_ = r.Client.Get(context.Background(), types.NamespacedName{Name: pod.Spec.NodeName, Namespace: pod.Namespace}, &node)
-	locality := parseLocality(node)
+	var locality *api.Locality
+	if r.EnableLocality {
+		var node corev1.Node
+		// Ignore errors because we don't want failures to block running services.
+		_ = r.Client.Get(context.Background(), types.NamespacedName{Name: pod.Spec.NodeName}, &node)
+		locality = parseLocality(node)
+	}
 
 	// We only want that annotation to be present when explicitly overriding the consul svc name
 	// Otherwise, the Consul service name should equal the Kubernetes Service name.
 	// The service name in Consul defaults to the Endpoints object name, and is overridden by the pod
 	// annotation consul.hashicorp.com/connect-service..
-	svcName := serviceName(pod, serviceEndpoints)
+	svcName := r.serviceName(pod, serviceEndpoints)
 
-	svcID := serviceID(pod, serviceEndpoints)
+	svcID := r.serviceID(pod, serviceEndpoints)
 
 	meta := map[string]string{
 		constants.MetaKeyPodName: pod.Name,
@@ -439,6 +579,9 @@ func (r *Controller) createServiceRegistrations(pod corev1.Pod, serviceEndpoints
 		metaKeyManagedBy:         constants.ManagedByValue,
 		metaKeySyntheticNode:     "true",
 	}
+	if r.Network != "" {
+		meta[metaKeyNetwork] = r.Network
+	}
 	for k, v := range pod.Annotations {
 		if strings.HasPrefix(k, constants.AnnotationMeta) && strings.TrimPrefix(k, constants.AnnotationMeta) != "" {
 			if v == "$POD_NAME" {
@@ -479,10 +622,18 @@ func (r *Controller) createServiceRegistrations(pod corev1.Pod, serviceEndpoints
 		},
 		SkipNodeUpdate: true,
 	}
-	r.appendNodeMeta(serviceRegistration)
+	r.appendNodeMeta(serviceRegistration, pod.Spec.NodeName)
+
+	mirrorProbes, err := r.probeHealthChecksEnabled(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mirrorProbes {
+		serviceRegistration.Checks = probeHealthChecks(pod, svcID, consulNS, consulServicePort)
+	}
 
-	proxySvcName := proxyServiceName(pod, serviceEndpoints)
-	proxySvcID := proxyServiceID(pod, serviceEndpoints)
+	proxySvcName := r.proxyServiceName(pod, serviceEndpoints)
+	proxySvcID := r.proxyServiceID(pod, serviceEndpoints)
 	proxyConfig := &api.AgentServiceConnectProxyConfig{
 		DestinationServiceName: svcName,
 		DestinationServiceID:   svcID,
@@ -503,7 +654,7 @@ func (r *Controller) createServiceRegistrations(pod corev1.Pod, serviceEndpoints
 		if err != nil {
 			return nil, nil, err
 		}
-		prometheusScrapeListener := fmt.Sprintf("0.0.0.0:%s", prometheusScrapePort)
+		prometheusScrapeListener := net.JoinHostPort(common.UnspecifiedAddress(r.EnableIPv6), prometheusScrapePort)
 		proxyConfig.Config[envoyPrometheusBindAddr] = prometheusScrapeListener
 	}
 
@@ -511,8 +662,34 @@ func (r *Controller) createServiceRegistrations(pod corev1.Pod, serviceEndpoints
 		proxyConfig.Config[envoyTelemetryCollectorBindSocketDir] = "/consul/connect-inject"
 	}
 
+	// If StatsD or DogStatsD sinks are configured, Envoy sends its metrics to them tagged with the pod's
+	// namespace, name and labels so that they can be attributed to the pod.
+	statsdURL := r.MetricsConfig.StatsdURL(pod)
+	dogstatsdURL := r.MetricsConfig.DogstatsdURL(pod)
+	if statsdURL != "" {
+		proxyConfig.Config[envoyStatsdURL] = statsdURL
+	}
+	if dogstatsdURL != "" {
+		proxyConfig.Config[envoyDogstatsdURL] = dogstatsdURL
+	}
+	if statsdURL != "" || dogstatsdURL != "" {
+		statsTags, err := r.MetricsConfig.StatsTags(pod)
+		if err != nil {
+			return nil, nil, err
+		}
+		proxyConfig.Config[envoyStatsTags] = statsTags
+	}
+
+	tracingConfig, err := r.TracingConfig.ProxyConfig(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range tracingConfig {
+		proxyConfig.Config[k] = v
+	}
+
 	if consulServicePort > 0 {
-		proxyConfig.LocalServiceAddress = "127.0.0.1"
+		proxyConfig.LocalServiceAddress = common.LocalhostAddress(r.EnableIPv6)
 		proxyConfig.LocalServicePort = consulServicePort
 	}
 
@@ -522,8 +699,14 @@ func (r *Controller) createServiceRegistrations(pod corev1.Pod, serviceEndpoints
 	}
 	proxyConfig.Upstreams = upstreams
 
+	meshGatewayMode, err := common.MeshGatewayMode(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	proxyConfig.MeshGateway.Mode = meshGatewayMode
+
 	proxyPort := constants.ProxyDefaultInboundPort
-	if idx := getMultiPortIdx(pod, serviceEndpoints); idx >= 0 {
+	if idx := r.getMultiPortIdx(pod, serviceEndpoints); idx >= 0 {
 		proxyPort += idx
 	}
 	proxyService := &api.AgentService{
@@ -536,6 +719,29 @@ func (r *Controller) createServiceRegistrations(pod corev1.Pod, serviceEndpoints
 		Namespace: consulNS,
 		Proxy:     proxyConfig,
 		Tags:      tags,
+		Locality:  locality,
+	}
+
+	// Pods that use the proxy of their node are reached through a listener of the node proxy, which
+	// forwards traffic to the pod IP. Their traffic isn't redirected, so transparent proxy doesn't apply.
+	nodeProxy, err := r.usesNodeProxy(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	if nodeProxy {
+		port, err := r.NodeProxyPorts.Assign(common.ConsulNodeNameFromK8sNode(pod.Spec.NodeName), r.nodeProxyKey(r.consulNamespace(pod.Namespace), proxySvcID))
+		if err != nil {
+			return nil, nil, err
+		}
+		proxyMeta := make(map[string]string, len(meta)+1)
+		for k, v := range meta {
+			proxyMeta[k] = v
+		}
+		proxyMeta[metaKeyNodeProxy] = "true"
+		proxyService.Meta = proxyMeta
+		proxyService.Address = pod.Status.HostIP
+		proxyService.Port = port
+		proxyConfig.LocalServiceAddress = pod.Status.PodIP
 	}
 
 	// A user can enable/disable tproxy for an entire namespace.
@@ -549,6 +755,7 @@ func (r *Controller) createServiceRegistrations(pod corev1.Pod, serviceEndpoints
 	if err != nil {
 		return nil, nil, err
 	}
+	tproxyEnabled = tproxyEnabled && !nodeProxy
 
 	if tproxyEnabled {
 		var k8sService corev1.Service
@@ -615,38 +822,18 @@ func (r *Controller) createServiceRegistrations(pod corev1.Pod, serviceEndpoints
 			for _, mutatedContainer := range pod.Spec.Containers {
 				for _, originalContainer := range originalPod.Spec.Containers {
 					if originalContainer.Name == mutatedContainer.Name {
-						if mutatedContainer.LivenessProbe != nil && mutatedContainer.LivenessProbe.HTTPGet != nil {
-							originalLivenessPort, err := portValueFromIntOrString(originalPod, originalContainer.LivenessProbe.HTTPGet.Port)
+						for _, probes := range [][2]*corev1.Probe{
+							{originalContainer.LivenessProbe, mutatedContainer.LivenessProbe},
+							{originalContainer.ReadinessProbe, mutatedContainer.ReadinessProbe},
+							{originalContainer.StartupProbe, mutatedContainer.StartupProbe},
+						} {
+							exposePath, err := probeExposePath(originalPod, probes[0], probes[1])
 							if err != nil {
 								return nil, nil, err
 							}
-							proxyConfig.Expose.Paths = append(proxyConfig.Expose.Paths, api.ExposePath{
-								ListenerPort:  mutatedContainer.LivenessProbe.HTTPGet.Port.IntValue(),
-								LocalPathPort: originalLivenessPort,
-								Path:          mutatedContainer.LivenessProbe.HTTPGet.Path,
-							})
-						}
-						if mutatedContainer.ReadinessProbe != nil && mutatedContainer.ReadinessProbe.HTTPGet != nil {
-							originalReadinessPort, err := portValueFromIntOrString(originalPod, originalContainer.ReadinessProbe.HTTPGet.Port)
-							if err != nil {
-								return nil, nil, err
-							}
-							proxyConfig.Expose.Paths = append(proxyConfig.Expose.Paths, api.ExposePath{
-								ListenerPort:  mutatedContainer.ReadinessProbe.HTTPGet.Port.IntValue(),
-								LocalPathPort: originalReadinessPort,
-								Path:          mutatedContainer.ReadinessProbe.HTTPGet.Path,
-							})
-						}
-						if mutatedContainer.StartupProbe != nil && mutatedContainer.StartupProbe.HTTPGet != nil {
-							originalStartupPort, err := portValueFromIntOrString(originalPod, originalContainer.StartupProbe.HTTPGet.Port)
-							if err != nil {
-								return nil, nil, err
+							if exposePath != nil {
+								proxyConfig.Expose.Paths = append(proxyConfig.Expose.Paths, *exposePath)
 							}
-							proxyConfig.Expose.Paths = append(proxyConfig.Expose.Paths, api.ExposePath{
-								ListenerPort:  mutatedContainer.StartupProbe.HTTPGet.Port.IntValue(),
-								LocalPathPort: originalStartupPort,
-								Path:          mutatedContainer.StartupProbe.HTTPGet.Path,
-							})
 						}
 					}
 				}
@@ -654,6 +841,9 @@ func (r *Controller) createServiceRegistrations(pod corev1.Pod, serviceEndpoints
 		}
 	}
 
+	r.setNetworkTaggedAddresses(service)
+	r.setNetworkTaggedAddresses(proxyService)
+
 	proxyServiceRegistration := &api.CatalogRegistration{
 		Node:    common.ConsulNodeNameFromK8sNode(pod.Spec.NodeName),
 		Address: pod.Status.HostIP,
@@ -672,11 +862,35 @@ func (r *Controller) createServiceRegistrations(pod corev1.Pod, serviceEndpoints
 		},
 		SkipNodeUpdate: true,
 	}
-	r.appendNodeMeta(proxyServiceRegistration)
+	r.appendNodeMeta(proxyServiceRegistration, pod.Spec.NodeName)
 
 	return serviceRegistration, proxyServiceRegistration, nil
 }
 
+// setNetworkTaggedAddresses adds the lan and wan tagged addresses of a service instance when the cluster's
+// network has a gateway. The lan address is the instance's own address and the wan address is the gateway's,
+// which Consul gives to clients on other networks instead of the pod IP.
+func (r *Controller) setNetworkTaggedAddresses(service *api.AgentService) {
+	if r.NetworkGatewayAddress == "" {
+		return
+	}
+	// The service and its proxy can share the map of tagged addresses, so it's copied
+	// before the addresses with their own ports are added.
+	taggedAddresses := make(map[string]api.ServiceAddress, len(service.TaggedAddresses)+2)
+	for name, addr := range service.TaggedAddresses {
+		taggedAddresses[name] = addr
+	}
+	taggedAddresses[lanTaggedAddressName] = api.ServiceAddress{
+		Address: service.Address,
+		Port:    service.Port,
+	}
+	taggedAddresses[wanTaggedAddressName] = api.ServiceAddress{
+		Address: r.NetworkGatewayAddress,
+		Port:    r.NetworkGatewayPort,
+	}
+	service.TaggedAddresses = taggedAddresses
+}
+
 // createGatewayRegistrations creates the gateway service registrations with the information from the Pod.
 func (r *Controller) createGatewayRegistrations(pod corev1.Pod, serviceEndpoints corev1.Endpoints, healthStatus string) (*api.CatalogRegistration, error) {
 	meta := map[string]string{
@@ -771,7 +985,7 @@ func (r *Controller) createGatewayRegistrations(pod corev1.Pod, serviceEndpoints
 				"envoy_gateway_no_default_bind": true,
 				"envoy_gateway_bind_addresses": map[string]interface{}{
 					"all-interfaces": map[string]interface{}{
-						"address": "0.0.0.0",
+						"address": common.UnspecifiedAddress(r.EnableIPv6),
 					},
 				},
 			},
@@ -783,11 +997,11 @@ func (r *Controller) createGatewayRegistrations(pod corev1.Pod, serviceEndpoints
 
 	if r.MetricsConfig.DefaultEnableMetrics && r.MetricsConfig.EnableGatewayMetrics {
 		if pod.Annotations[constants.AnnotationGatewayKind] == ingressGateway {
-			service.Proxy.Config["envoy_prometheus_bind_addr"] = fmt.Sprintf("%s:20200", pod.Status.PodIP)
+			service.Proxy.Config["envoy_prometheus_bind_addr"] = net.JoinHostPort(pod.Status.PodIP, "20200")
 		} else {
 			service.Proxy = &api.AgentServiceConnectProxyConfig{
 				Config: map[string]interface{}{
-					"envoy_prometheus_bind_addr": fmt.Sprintf("%s:20200", pod.Status.PodIP),
+					"envoy_prometheus_bind_addr": net.JoinHostPort(pod.Status.PodIP, "20200"),
 				},
 			}
 		}
@@ -815,7 +1029,7 @@ func (r *Controller) createGatewayRegistrations(pod corev1.Pod, serviceEndpoints
 		},
 		SkipNodeUpdate: true,
 	}
-	r.appendNodeMeta(serviceRegistration)
+	r.appendNodeMeta(serviceRegistration, pod.Spec.NodeName)
 
 	return serviceRegistration, nil
 }
@@ -890,6 +1104,36 @@ func portValueFromIntOrString(pod corev1.Pod, port intstr.IntOrString) (int, err
 	return int(portVal), nil
 }
 
+// probeExposePath returns the exposed path of the sidecar proxy that serves a probe overwritten by the mesh
+// webhook, or nil if the probe isn't an HTTP or gRPC probe. The original probe is the probe before it was
+// overwritten, whose port is the port of the application that the exposed path proxies to.
+func probeExposePath(originalPod corev1.Pod, original, mutated *corev1.Probe) (*api.ExposePath, error) {
+	switch {
+	case mutated == nil || original == nil:
+		return nil, nil
+	case mutated.HTTPGet != nil && original.HTTPGet != nil:
+		originalPort, err := portValueFromIntOrString(originalPod, original.HTTPGet.Port)
+		if err != nil {
+			return nil, err
+		}
+		return &api.ExposePath{
+			ListenerPort:  mutated.HTTPGet.Port.IntValue(),
+			LocalPathPort: originalPort,
+			Path:          mutated.HTTPGet.Path,
+		}, nil
+	case mutated.GRPC != nil && original.GRPC != nil:
+		// The kubelet's gRPC probes call the Check method of the grpc.health.v1.Health service over HTTP/2
+		// without TLS, whatever the probe's service is, so they're all served by the same path.
+		return &api.ExposePath{
+			ListenerPort:  int(mutated.GRPC.Port),
+			LocalPathPort: int(original.GRPC.Port),
+			Path:          grpcHealthCheckPath,
+			Protocol:      "http2",
+		}, nil
+	}
+	return nil, nil
+}
+
 // consulHealthCheckID deterministically generates a health check ID based on service ID and Kubernetes namespace.
 func consulHealthCheckID(k8sNS string, serviceID string) string {
 	return fmt.Sprintf("%s/%s", k8sNS, serviceID)
@@ -914,14 +1158,18 @@ func getHealthCheckStatusReason(healthCheckStatus, podName, podNamespace string)
 // The argument endpointsAddressesMap decides whether to deregister *all* service instances or selectively deregister
 // them only if they are not in endpointsAddressesMap. If the map is nil, it will deregister all instances. If the map
 // has addresses, it will only deregister instances not in the map.
-func (r *Controller) deregisterService(apiClient *api.Client, k8sSvcName, k8sSvcNamespace string, endpointsAddressesMap map[string]bool) error {
+// The instances of terminating pods are drained instead of being deregistered, and the shortest time left until
+// one of them should be deregistered is returned.
+func (r *Controller) deregisterService(apiClient *api.Client, k8sSvcName, k8sSvcNamespace string, endpointsAddressesMap map[string]bool) (time.Duration, error) {
 	// Get services matching metadata.
 	nodesWithSvcs, err := r.serviceInstancesForK8sNodes(apiClient, k8sSvcName, k8sSvcNamespace)
 	if err != nil {
 		r.Log.Error(err, "failed to get service instances", "name", k8sSvcName)
-		return err
+		return 0, err
 	}
 
+	var drainRemaining time.Duration
+
 	// Deregister each service instance that matches the metadata.
 	for _, nodeSvcs := range nodesWithSvcs {
 		for _, svc := range nodeSvcs.Services {
@@ -930,7 +1178,20 @@ func (r *Controller) deregisterService(apiClient *api.Client, k8sSvcName, k8sSvc
 			// every service instance.
 			var serviceDeregistered bool
 			if endpointsAddressesMap != nil {
-				if _, ok := endpointsAddressesMap[svc.Address]; !ok {
+				if _, ok := endpointsAddressesMap[instanceAddress(svc)]; !ok {
+					// Terminating pods are no longer in the Endpoints addresses, but their instances are kept
+					// registered while they drain.
+					remaining, err := r.drainInstance(apiClient, nodeSvcs.Node, svc, k8sSvcNamespace)
+					if err != nil {
+						return 0, err
+					}
+					if remaining > 0 {
+						if drainRemaining == 0 || remaining < drainRemaining {
+							drainRemaining = remaining
+						}
+						continue
+					}
+
 					// If the service address is not in the Endpoints addresses, deregister it.
 					r.Log.Info("deregistering service from consul", "svc", svc.ID)
 					_, err = apiClient.Catalog().Deregister(&api.CatalogDeregistration{
@@ -940,8 +1201,10 @@ func (r *Controller) deregisterService(apiClient *api.Client, k8sSvcName, k8sSvc
 					}, nil)
 					if err != nil {
 						r.Log.Error(err, "failed to deregister service instance", "id", svc.ID)
-						return err
+						return 0, err
 					}
+					r.cacheRemove(nodeSvcs.Node.Node, svc)
+					r.recordDeregistered(k8sSvcName, k8sSvcNamespace, svc)
 					serviceDeregistered = true
 				}
 			} else {
@@ -952,23 +1215,29 @@ func (r *Controller) deregisterService(apiClient *api.Client, k8sSvcName, k8sSvc
 					Namespace: svc.Namespace,
 				}, nil); err != nil {
 					r.Log.Error(err, "failed to deregister service instance", "id", svc.ID)
-					return err
+					return 0, err
 				}
+				r.cacheRemove(nodeSvcs.Node.Node, svc)
+				r.recordDeregistered(k8sSvcName, k8sSvcNamespace, svc)
 				serviceDeregistered = true
 			}
 
+			if r.NodeProxyPorts != nil && serviceDeregistered {
+				r.NodeProxyPorts.Release(nodeSvcs.Node.Node, r.nodeProxyKey(svc.Namespace, svc.ID))
+			}
+
 			if r.AuthMethod != "" && serviceDeregistered {
 				r.Log.Info("reconciling ACL tokens for service", "svc", svc.Service)
 				err = r.deleteACLTokensForServiceInstance(apiClient, svc, k8sSvcNamespace, svc.Meta[constants.MetaKeyPodName])
 				if err != nil {
 					r.Log.Error(err, "failed to reconcile ACL tokens for service", "svc", svc.Service)
-					return err
+					return 0, err
 				}
 			}
 		}
 	}
 
-	return nil
+	return drainRemaining, nil
 }
 
 // deleteACLTokensForServiceInstance finds the ACL tokens that belongs to the service instance and deletes it from Consul.
@@ -1018,55 +1287,12 @@ func (r *Controller) deleteACLTokensForServiceInstance(apiClient *api.Client, sv
 func (r *Controller) processUpstreams(pod corev1.Pod, endpoints corev1.Endpoints) ([]api.Upstream, error) {
 	// In a multiport pod, only the first service's proxy should have upstreams configured. This skips configuring
 	// upstreams on additional services on the pod.
-	mpIdx := getMultiPortIdx(pod, endpoints)
+	mpIdx := r.getMultiPortIdx(pod, endpoints)
 	if mpIdx > 0 {
 		return []api.Upstream{}, nil
 	}
 
-	var upstreams []api.Upstream
-	if raw, ok := pod.Annotations[constants.AnnotationUpstreams]; ok && raw != "" {
-		for _, raw := range strings.Split(raw, ",") {
-			var upstream api.Upstream
-
-			// parts separates out the port, and determines whether it's a prepared query or not, since parts[0] would
-			// be "prepared_query" if it is.
-			parts := strings.SplitN(raw, ":", 3)
-
-			// serviceParts helps determine which format of upstream we're processing,
-			// [service-name].[service-namespace].[service-partition]:[port]:[optional datacenter]
-			// or
-			// [service-name].svc.[service-namespace].ns.[service-peer].peer:[port]
-			// [service-name].svc.[service-namespace].ns.[service-partition].ap:[port]
-			// [service-name].svc.[service-namespace].ns.[service-datacenter].dc:[port]
-			labeledFormat := false
-			serviceParts := strings.Split(parts[0], ".")
-			if len(serviceParts) >= 2 {
-				if serviceParts[1] == "svc" {
-					labeledFormat = true
-				}
-			}
-
-			if strings.TrimSpace(parts[0]) == "prepared_query" {
-				upstream = processPreparedQueryUpstream(pod, raw)
-			} else if labeledFormat {
-				var err error
-				upstream, err = r.processLabeledUpstream(pod, raw)
-				if err != nil {
-					return []api.Upstream{}, err
-				}
-			} else {
-				var err error
-				upstream, err = r.processUnlabeledUpstream(pod, raw)
-				if err != nil {
-					return []api.Upstream{}, err
-				}
-			}
-
-			upstreams = append(upstreams, upstream)
-		}
-	}
-
-	return upstreams, nil
+	return common.ParseUpstreams(pod, r.EnableConsulNamespaces, r.EnableConsulPartitions)
 }
 
 // getTokenMetaFromDescription parses JSON metadata from token's description.
@@ -1096,17 +1322,67 @@ func (r *Controller) serviceInstancesForK8sNodes(apiClient *api.Client, k8sServi
 	if err != nil {
 		return nil, err
 	}
+	if r.ServiceInstanceCache != nil {
+		nodeNames := make([]string, 0, len(nodeList.Items))
+		for _, node := range nodeList.Items {
+			nodeNames = append(nodeNames, common.ConsulNodeNameFromK8sNode(node.Name))
+		}
+		r.ServiceInstanceCache.Watch(r.Context, nodeNames)
+	}
 	for _, node := range nodeList.Items {
+		nodeName := common.ConsulNodeNameFromK8sNode(node.Name)
+		if r.ServiceInstanceCache != nil {
+			if nodeServices, ok := r.ServiceInstanceCache.Get(nodeName, k8sServiceName, k8sServiceNamespace, r.consulPartition()); ok {
+				serviceList = append(serviceList, nodeServices)
+				continue
+			}
+		}
 		var nodeServices *api.CatalogNodeServiceList
-		nodeServices, err = r.serviceInstancesForK8SServiceNameAndNamespace(apiClient, k8sServiceName, k8sServiceNamespace, common.ConsulNodeNameFromK8sNode(node.Name))
+		nodeServices, err = r.serviceInstancesForK8SServiceNameAndNamespace(apiClient, k8sServiceName, k8sServiceNamespace, nodeName)
 		serviceList = append(serviceList, nodeServices)
 	}
 
 	return serviceList, err
 }
 
+// cacheAdd records a registration in the service instance cache, if enabled.
+func (r *Controller) cacheAdd(registration *api.CatalogRegistration) {
+	if r.ServiceInstanceCache == nil {
+		return
+	}
+	// Registrations are made in the client's partition, which isn't set on the
+	// service itself, so set it here to match the instances read from Consul.
+	svc := *registration.Service
+	if svc.Partition == "" {
+		svc.Partition = r.consulPartition()
+	}
+	r.ServiceInstanceCache.Add(registration.Node, &svc)
+}
+
+// cacheRemove drops a deregistered service instance from the service instance cache, if enabled.
+func (r *Controller) cacheRemove(nodeName string, svc *api.AgentService) {
+	if r.ServiceInstanceCache != nil {
+		r.ServiceInstanceCache.Remove(nodeName, svc)
+	}
+}
+
+// consulPartition returns the admin partition this controller registers services in.
+func (r *Controller) consulPartition() string {
+	if r.ConsulClientConfig == nil || r.ConsulClientConfig.APIClientConfig == nil {
+		return ""
+	}
+	return r.ConsulClientConfig.APIClientConfig.Partition
+}
+
+// consulAllowStale returns true if reads from Consul can be answered by any Consul server.
+// Reads that decide whether to make a write must not use it.
+func (r *Controller) consulAllowStale() bool {
+	return r.ConsulClientConfig != nil && r.ConsulClientConfig.AllowStale
+}
+
 // serviceInstancesForK8SServiceNameAndNamespace calls Consul's ServicesWithFilter to get the list
 // of services instances that have the provided k8sServiceName and k8sServiceNamespace in their metadata.
+// The list decides which instances are deregistered, so it's read consistently.
 func (r *Controller) serviceInstancesForK8SServiceNameAndNamespace(apiClient *api.Client, k8sServiceName, k8sServiceNamespace, nodeName string) (*api.CatalogNodeServiceList, error) {
 	var (
 		serviceList *api.CatalogNodeServiceList
@@ -1115,160 +1391,13 @@ func (r *Controller) serviceInstancesForK8SServiceNameAndNamespace(apiClient *ap
 	filter := fmt.Sprintf(`Meta[%q] == %q and Meta[%q] == %q and Meta[%q] == %q`,
 		metaKeyKubeServiceName, k8sServiceName, constants.MetaKeyKubeNS, k8sServiceNamespace, metaKeyManagedBy, constants.ManagedByValue)
 	if r.EnableConsulNamespaces {
-		serviceList, _, err = apiClient.Catalog().NodeServiceList(nodeName, &api.QueryOptions{Filter: filter, Namespace: namespaces.WildcardNamespace})
+		serviceList, _, err = apiClient.Catalog().NodeServiceList(nodeName, &api.QueryOptions{Filter: filter, Namespace: namespaces.WildcardNamespace, RequireConsistent: true})
 	} else {
-		serviceList, _, err = apiClient.Catalog().NodeServiceList(nodeName, &api.QueryOptions{Filter: filter})
+		serviceList, _, err = apiClient.Catalog().NodeServiceList(nodeName, &api.QueryOptions{Filter: filter, RequireConsistent: true})
 	}
 	return serviceList, err
 }
 
-// processPreparedQueryUpstream processes an upstream in the format:
-// prepared_query:[query name]:[port].
-func processPreparedQueryUpstream(pod corev1.Pod, rawUpstream string) api.Upstream {
-	var preparedQuery string
-	var port int32
-	parts := strings.SplitN(rawUpstream, ":", 3)
-
-	port, _ = common.PortValue(pod, strings.TrimSpace(parts[2]))
-	preparedQuery = strings.TrimSpace(parts[1])
-	var upstream api.Upstream
-	if port > 0 {
-		upstream = api.Upstream{
-			DestinationType: api.UpstreamDestTypePreparedQuery,
-			DestinationName: preparedQuery,
-			LocalBindPort:   int(port),
-		}
-	}
-	return upstream
-}
-
-// processUnlabeledUpstream processes an upstream in the format:
-// [service-name].[service-namespace].[service-partition]:[port]:[optional datacenter].
-func (r *Controller) processUnlabeledUpstream(pod corev1.Pod, rawUpstream string) (api.Upstream, error) {
-	var datacenter, svcName, namespace, partition, peer string
-	var port int32
-	var upstream api.Upstream
-
-	parts := strings.SplitN(rawUpstream, ":", 3)
-
-	port, _ = common.PortValue(pod, strings.TrimSpace(parts[1]))
-
-	// If Consul Namespaces or Admin Partitions are enabled, attempt to parse the
-	// upstream for a namespace.
-	if r.EnableConsulNamespaces || r.EnableConsulPartitions {
-		pieces := strings.SplitN(parts[0], ".", 3)
-		switch len(pieces) {
-		case 3:
-			partition = strings.TrimSpace(pieces[2])
-			fallthrough
-		case 2:
-			namespace = strings.TrimSpace(pieces[1])
-			fallthrough
-		default:
-			svcName = strings.TrimSpace(pieces[0])
-		}
-	} else {
-		svcName = strings.TrimSpace(parts[0])
-	}
-
-	// parse the optional datacenter
-	if len(parts) > 2 {
-		datacenter = strings.TrimSpace(parts[2])
-	}
-	if port > 0 {
-		upstream = api.Upstream{
-			DestinationType:      api.UpstreamDestTypeService,
-			DestinationPartition: partition,
-			DestinationPeer:      peer,
-			DestinationNamespace: namespace,
-			DestinationName:      svcName,
-			Datacenter:           datacenter,
-			LocalBindPort:        int(port),
-		}
-	}
-	return upstream, nil
-}
-
-// processLabeledUpstream processes an upstream in the format:
-// [service-name].svc.[service-namespace].ns.[service-peer].peer:[port]
-// [service-name].svc.[service-namespace].ns.[service-partition].ap:[port]
-// [service-name].svc.[service-namespace].ns.[service-datacenter].dc:[port].
-func (r *Controller) processLabeledUpstream(pod corev1.Pod, rawUpstream string) (api.Upstream, error) {
-	var datacenter, svcName, namespace, partition, peer string
-	var port int32
-	var upstream api.Upstream
-
-	parts := strings.SplitN(rawUpstream, ":", 3)
-
-	port, _ = common.PortValue(pod, strings.TrimSpace(parts[1]))
-
-	service := parts[0]
-
-	pieces := strings.Split(service, ".")
-
-	if r.EnableConsulNamespaces || r.EnableConsulPartitions {
-		switch len(pieces) {
-		case 6:
-			end := strings.TrimSpace(pieces[5])
-			switch end {
-			case "peer":
-				peer = strings.TrimSpace(pieces[4])
-			case "ap":
-				partition = strings.TrimSpace(pieces[4])
-			case "dc":
-				datacenter = strings.TrimSpace(pieces[4])
-			default:
-				return api.Upstream{}, fmt.Errorf("upstream structured incorrectly: %s", rawUpstream)
-			}
-			fallthrough
-		case 4:
-			if strings.TrimSpace(pieces[3]) == "ns" {
-				namespace = strings.TrimSpace(pieces[2])
-			} else {
-				return api.Upstream{}, fmt.Errorf("upstream structured incorrectly: %s", rawUpstream)
-			}
-			fallthrough
-		case 2:
-			if strings.TrimSpace(pieces[1]) == "svc" {
-				svcName = strings.TrimSpace(pieces[0])
-			}
-		default:
-			return api.Upstream{}, fmt.Errorf("upstream structured incorrectly: %s", rawUpstream)
-		}
-	} else {
-		switch len(pieces) {
-		case 4:
-			end := strings.TrimSpace(pieces[3])
-			switch end {
-			case "peer":
-				peer = strings.TrimSpace(pieces[2])
-			case "dc":
-				datacenter = strings.TrimSpace(pieces[2])
-			default:
-				return api.Upstream{}, fmt.Errorf("upstream structured incorrectly: %s", rawUpstream)
-			}
-			fallthrough
-		case 2:
-			svcName = strings.TrimSpace(pieces[0])
-		default:
-			return api.Upstream{}, fmt.Errorf("upstream structured incorrectly: %s", rawUpstream)
-		}
-	}
-
-	if port > 0 {
-		upstream = api.Upstream{
-			DestinationType:      api.UpstreamDestTypeService,
-			DestinationPartition: partition,
-			DestinationPeer:      peer,
-			DestinationNamespace: namespace,
-			DestinationName:      svcName,
-			Datacenter:           datacenter,
-			LocalBindPort:        int(port),
-		}
-	}
-	return upstream, nil
-}
-
 // shouldIgnore ignores namespaces where we don't connect-inject.
 func shouldIgnore(namespace string, denySet, allowSet mapset.Set) bool {
 	// Ignores system namespaces.
@@ -1295,10 +1424,20 @@ func (r *Controller) consulNamespace(namespace string) string {
 	return namespaces.ConsulNamespace(namespace, r.EnableConsulNamespaces, r.ConsulDestinationNamespace, r.EnableNSMirroring, r.NSMirroringPrefix)
 }
 
-func (r *Controller) appendNodeMeta(registration *api.CatalogRegistration) {
+func (r *Controller) appendNodeMeta(registration *api.CatalogRegistration, nodeName string) {
 	for k, v := range r.NodeMeta {
 		registration.NodeMeta[k] = v
 	}
+	if len(r.NodeLabels) == 0 {
+		return
+	}
+	var node corev1.Node
+	// Ignore errors because we don't want failures to block running services. The node meta
+	// controller syncs the labels once the node can be read.
+	_ = r.Client.Get(context.Background(), types.NamespacedName{Name: nodeName}, &node)
+	for k, v := range common.NodeLabelMeta(node, r.NodeLabels) {
+		registration.NodeMeta[k] = v
+	}
 }
 
 // assignServiceVirtualIPs manually assigns the ClusterIP to the virtual IP table so that transparent proxy routing works.
@@ -1380,9 +1519,9 @@ func consulTags(pod corev1.Pod) []string {
 	return interpolatedTags
 }
 
-func getMultiPortIdx(pod corev1.Pod, serviceEndpoints corev1.Endpoints) int {
+func (r *Controller) getMultiPortIdx(pod corev1.Pod, serviceEndpoints corev1.Endpoints) int {
 	for i, name := range strings.Split(pod.Annotations[constants.AnnotationService], ",") {
-		if name == serviceName(pod, serviceEndpoints) {
+		if name == r.serviceName(pod, serviceEndpoints) {
 			return i
 		}
 	}
@@ -19,11 +19,13 @@ import (
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/sdk/testutil"
 	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -634,6 +636,7 @@ func TestGetServiceName(t *testing.T) {
 		name       string
 		pod        func() *corev1.Pod
 		endpoint   *corev1.Endpoints
+		template   string
 		expSvcName string
 	}{
 		{
@@ -680,11 +683,80 @@ func TestGetServiceName(t *testing.T) {
 			},
 			expSvcName: "ep-name-multiport",
 		},
+		{
+			name: "single port, with template",
+			pod: func() *corev1.Pod {
+				pod1 := createServicePod("pod1", "1.2.3.4", true, true)
+				pod1.Labels[appsv1.DefaultDeploymentUniqueLabelKey] = "5d8f7c9b4"
+				pod1.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-5d8f7c9b4"}}
+				return pod1
+			},
+			endpoint: &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ep-name",
+					Namespace: "default",
+				},
+			},
+			template:   "{{ .Deployment }}-{{ .Namespace }}",
+			expSvcName: "web-default",
+		},
+		{
+			name: "single port, with annotation and template",
+			pod: func() *corev1.Pod {
+				pod1 := createServicePod("pod1", "1.2.3.4", true, true)
+				pod1.Annotations[constants.AnnotationService] = "web"
+				return pod1
+			},
+			endpoint: &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ep-name",
+					Namespace: "default",
+				},
+			},
+			template:   "{{ .Name }}-{{ .Namespace }}",
+			expSvcName: "web",
+		},
+		{
+			name: "single port, with template that renders empty",
+			pod: func() *corev1.Pod {
+				return createServicePod("pod1", "1.2.3.4", true, true)
+			},
+			endpoint: &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ep-name",
+					Namespace: "default",
+				},
+			},
+			template:   "{{ .Labels.team }}",
+			expSvcName: "ep-name",
+		},
+		{
+			name: "multi port, with template",
+			pod: func() *corev1.Pod {
+				pod1 := createServicePod("pod1", "1.2.3.4", true, true)
+				pod1.Annotations[constants.AnnotationService] = "web,web-admin"
+				return pod1
+			},
+			endpoint: &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ep-name-multiport",
+					Namespace: "default",
+				},
+			},
+			template:   "{{ .Name }}-{{ .Namespace }}",
+			expSvcName: "ep-name-multiport",
+		},
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
+			ep := &Controller{Log: logrtest.New(t)}
+			if tt.template != "" {
+				tmpl, err := ParseServiceNameTemplate(tt.template)
+				require.NoError(t, err)
+				ep.ServiceNameTemplate = tmpl
+			}
 
-			svcName := serviceName(*tt.pod(), *tt.endpoint)
+			svcName := ep.serviceName(*tt.pod(), *tt.endpoint)
 			require.Equal(t, tt.expSvcName, svcName)
 
 		})
@@ -2029,6 +2101,10 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 						metaKeySyntheticNode:     "true",
 					},
 					ServiceTags: []string{"abc,123", "pod1"},
+					ServiceLocality: &api.Locality{
+						Region: "us-west-1",
+						Zone:   "us-west-1a",
+					},
 				},
 			},
 			expectedHealthChecks: []*api.HealthCheck{
@@ -2169,6 +2245,7 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 				ReleaseName:           "consulServer",
 				ReleaseNamespace:      "default",
 				NodeMeta:              tt.nodeMeta,
+				EnableLocality:        true,
 			}
 			if tt.metricsEnabled {
 				ep.MetricsConfig = metrics.Config{
@@ -2222,6 +2299,7 @@ func TestReconcileCreateEndpoint(t *testing.T) {
 				require.Equal(t, tt.expectedProxySvcInstances[i].ServicePort, instance.ServicePort)
 				require.Equal(t, tt.expectedProxySvcInstances[i].ServiceMeta, instance.ServiceMeta)
 				require.Equal(t, tt.expectedProxySvcInstances[i].ServiceTags, instance.ServiceTags)
+				require.Equal(t, tt.expectedProxySvcInstances[i].ServiceLocality, instance.ServiceLocality)
 				if tt.nodeMeta != nil {
 					require.Equal(t, tt.expectedProxySvcInstances[i].NodeMeta, instance.NodeMeta)
 				}
@@ -2282,6 +2360,192 @@ func TestParseLocality(t *testing.T) {
 	})
 }
 
+func TestCreateServiceRegistrations_Locality(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		enableLocality bool
+		expLocality    *api.Locality
+	}{
+		"locality enabled": {
+			enableLocality: true,
+			expLocality:    &api.Locality{Region: "us-west-1", Zone: "us-west-1a"},
+		},
+		"locality disabled": {
+			enableLocality: false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createServicePod("pod1", "1.2.3.4", true, true)
+			pod.Spec.NodeName = "my-node"
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-node",
+					Labels: map[string]string{
+						corev1.LabelTopologyRegion: "us-west-1",
+						corev1.LabelTopologyZone:   "us-west-1a",
+					},
+				},
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "service-created",
+					Namespace: "default",
+				},
+			}
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := Controller{
+				Client:         fake.NewClientBuilder().WithRuntimeObjects(pod, node, endpoints, ns).Build(),
+				EnableLocality: c.enableLocality,
+				Log:            logrtest.New(t),
+			}
+
+			serviceRegistration, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(*pod, *endpoints, api.HealthPassing)
+			require.NoError(t, err)
+			require.Equal(t, c.expLocality, serviceRegistration.Service.Locality)
+			require.Equal(t, c.expLocality, proxyServiceRegistration.Service.Locality)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_Network(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		network             string
+		gatewayAddress      string
+		expMeta             string
+		expServiceAddresses map[string]api.ServiceAddress
+		expProxyAddresses   map[string]api.ServiceAddress
+	}{
+		"no network": {
+			expServiceAddresses: map[string]api.ServiceAddress{
+				clusterIPTaggedAddressName: {Address: "10.0.0.1", Port: 80},
+			},
+			expProxyAddresses: map[string]api.ServiceAddress{
+				clusterIPTaggedAddressName: {Address: "10.0.0.1", Port: 80},
+			},
+		},
+		"network without a gateway": {
+			network: "east",
+			expMeta: "east",
+			expServiceAddresses: map[string]api.ServiceAddress{
+				clusterIPTaggedAddressName: {Address: "10.0.0.1", Port: 80},
+			},
+			expProxyAddresses: map[string]api.ServiceAddress{
+				clusterIPTaggedAddressName: {Address: "10.0.0.1", Port: 80},
+			},
+		},
+		"network with a gateway": {
+			network:        "east",
+			gatewayAddress: "gateway.east.example.com",
+			expMeta:        "east",
+			expServiceAddresses: map[string]api.ServiceAddress{
+				clusterIPTaggedAddressName: {Address: "10.0.0.1", Port: 80},
+				lanTaggedAddressName:       {Address: "1.2.3.4", Port: 8080},
+				wanTaggedAddressName:       {Address: "gateway.east.example.com", Port: 8443},
+			},
+			expProxyAddresses: map[string]api.ServiceAddress{
+				clusterIPTaggedAddressName: {Address: "10.0.0.1", Port: 80},
+				lanTaggedAddressName:       {Address: "1.2.3.4", Port: 20000},
+				wanTaggedAddressName:       {Address: "gateway.east.example.com", Port: 8443},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createServicePod("pod1", "1.2.3.4", true, true)
+			pod.Annotations[constants.AnnotationPort] = "8080"
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "service-created",
+					Namespace: "default",
+				},
+			}
+			service := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "service-created",
+					Namespace: "default",
+				},
+				Spec: corev1.ServiceSpec{
+					ClusterIP: "10.0.0.1",
+					Ports: []corev1.ServicePort{
+						{Port: 80, TargetPort: intstr.FromInt(8080)},
+					},
+				},
+			}
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := Controller{
+				Client:                 fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, service, ns).Build(),
+				EnableTransparentProxy: true,
+				Network:                c.network,
+				NetworkGatewayAddress:  c.gatewayAddress,
+				NetworkGatewayPort:     8443,
+				Log:                    logrtest.New(t),
+				Context:                context.Background(),
+			}
+
+			serviceRegistration, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(*pod, *endpoints, api.HealthPassing)
+			require.NoError(t, err)
+			require.Equal(t, c.expMeta, serviceRegistration.Service.Meta[metaKeyNetwork])
+			require.Equal(t, c.expMeta, proxyServiceRegistration.Service.Meta[metaKeyNetwork])
+			require.Equal(t, c.expServiceAddresses, serviceRegistration.Service.TaggedAddresses)
+			require.Equal(t, c.expProxyAddresses, proxyServiceRegistration.Service.TaggedAddresses)
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_MeshGatewayMode(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		annotation string
+		expMode    api.MeshGatewayMode
+		expErr     string
+	}{
+		"not set": {
+			expMode: api.MeshGatewayModeDefault,
+		},
+		"local": {
+			annotation: "local",
+			expMode:    api.MeshGatewayModeLocal,
+		},
+		"none": {
+			annotation: "none",
+			expMode:    api.MeshGatewayModeNone,
+		},
+		"invalid": {
+			annotation: "foo",
+			expErr:     "consul.hashicorp.com/mesh-gateway-mode annotation value of foo was invalid: must be one of local, remote or none",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createServicePod("pod1", "1.2.3.4", true, true)
+			if c.annotation != "" {
+				pod.Annotations[constants.AnnotationMeshGatewayMode] = c.annotation
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "service-created",
+					Namespace: "default",
+				},
+			}
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := Controller{
+				Client: fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, ns).Build(),
+				Log:    logrtest.New(t),
+			}
+
+			_, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(*pod, *endpoints, api.HealthPassing)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expMode, proxyServiceRegistration.Service.Proxy.MeshGateway.Mode)
+		})
+	}
+}
+
 // Tests updating an Endpoints object.
 //   - Tests updates via the register codepath:
 //   - When an address in an Endpoint is updated, that the corresponding service instance in Consul is updated.
@@ -5454,6 +5718,86 @@ func TestCreateServiceRegistrations_withTransparentProxy(t *testing.T) {
 			},
 			expErr: "",
 		},
+		"grpc and tcp probes provided": {
+			tproxyGlobalEnabled: true,
+			overwriteProbes:     true,
+			podAnnotations: map[string]string{
+				constants.AnnotationOriginalPod: "{\"metadata\":{\"name\":\"test-pod-1\",\"namespace\":\"default\",\"creationTimestamp\":null,\"labels\":{\"consul.hashicorp.com/connect-inject-managed-by\":\"consul-k8s-endpoints-controller\",\"consul.hashicorp.com/connect-inject-status\":\"injected\"}},\"spec\":{\"containers\":[{\"name\":\"test\",\"ports\":[{\"name\":\"tcp\",\"containerPort\":8081},{\"name\":\"grpc\",\"containerPort\":9090}],\"resources\":{},\"livenessProbe\":{\"tcpSocket\":{\"port\":8081}},\"readinessProbe\":{\"grpc\":{\"port\":9090}},\"startupProbe\":{\"grpc\":{\"port\":9090,\"service\":\"startup\"}}}]},\"status\":{\"hostIP\":\"127.0.0.1\",\"podIP\":\"1.2.3.4\"}}\n",
+			},
+			podContainers: []corev1.Container{
+				{
+					Name: "test",
+					Ports: []corev1.ContainerPort{
+						{
+							Name:          "tcp",
+							ContainerPort: 8081,
+						},
+						{
+							Name:          "grpc",
+							ContainerPort: 9090,
+						},
+					},
+					LivenessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							TCPSocket: &corev1.TCPSocketAction{
+								Port: intstr.FromInt(8081),
+							},
+						},
+					},
+					ReadinessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							GRPC: &corev1.GRPCAction{
+								Port: 20400,
+							},
+						},
+					},
+					StartupProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							GRPC: &corev1.GRPCAction{
+								Port:    20500,
+								Service: pointer.String("startup"),
+							},
+						},
+					},
+				},
+			},
+			service: &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      serviceName,
+					Namespace: "default",
+				},
+				Spec: corev1.ServiceSpec{
+					ClusterIP: "10.0.0.1",
+					Ports: []corev1.ServicePort{
+						{
+							Port: 8081,
+						},
+					},
+				},
+			},
+			expProxyMode: api.ProxyModeTransparent,
+			expTaggedAddresses: map[string]api.ServiceAddress{
+				"virtual": {
+					Address: "10.0.0.1",
+					Port:    8081,
+				},
+			},
+			expExposePaths: []api.ExposePath{
+				{
+					ListenerPort:  20400,
+					LocalPathPort: 9090,
+					Path:          "/grpc.health.v1.Health/Check",
+					Protocol:      "http2",
+				},
+				{
+					ListenerPort:  20500,
+					LocalPathPort: 9090,
+					Path:          "/grpc.health.v1.Health/Check",
+					Protocol:      "http2",
+				},
+			},
+			expErr: "",
+		},
 		"all probes provided": {
 			tproxyGlobalEnabled: true,
 			overwriteProbes:     true,
@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// EventReasonRegistered is the reason of the event recorded on a pod when its service instance
+	// is registered in Consul.
+	EventReasonRegistered = "ConsulServiceRegistered"
+	// EventReasonRegistrationFailed is the reason of the event recorded on a pod when its service
+	// instance fails to be registered in Consul.
+	EventReasonRegistrationFailed = "ConsulServiceRegistrationFailed"
+	// EventReasonACLDenied is the reason of the event recorded on a pod when Consul denies the
+	// registration of its service instance because of ACLs.
+	EventReasonACLDenied = "ConsulACLDenied"
+	// EventReasonDeregistered is the reason of the event recorded on a service when one of its
+	// service instances is deregistered from Consul.
+	EventReasonDeregistered = "ConsulServiceDeregistered"
+)
+
+// recordRegistered records an event on the pod the first time its service instance is registered.
+// Instances are re-registered on every reconcile, so the instances that an event has been recorded
+// for are remembered to avoid recording the same event over and over.
+func (r *Controller) recordRegistered(pod corev1.Pod, serviceEndpoints corev1.Endpoints, registration *api.CatalogRegistration) {
+	if r.Recorder == nil {
+		return
+	}
+	k8sSvc := types.NamespacedName{Name: serviceEndpoints.Name, Namespace: serviceEndpoints.Namespace}
+	var recorded bool
+	r.registeredInstances.update(k8sSvc, registration.Service.ID, pod.Name, func(instance *registeredInstance) {
+		recorded, instance.eventRecorded = instance.eventRecorded, true
+	})
+	if recorded {
+		return
+	}
+	r.Recorder.AnnotatedEventf(&pod, injectionCorrelation(pod), corev1.EventTypeNormal, EventReasonRegistered,
+		"Registered service instance %s of service %s in Consul", registration.Service.ID, registration.Service.Service)
+}
+
+// recordRegistrationFailed records an event on the pod with the reason its service instance could not be registered.
+func (r *Controller) recordRegistrationFailed(pod corev1.Pod, err error) {
+	if r.Recorder == nil {
+		return
+	}
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) && statusErr.Code == http.StatusForbidden {
+		r.Recorder.AnnotatedEventf(&pod, injectionCorrelation(pod), corev1.EventTypeWarning, EventReasonACLDenied,
+			"Consul ACLs denied registering the service instance: %s", err)
+		return
+	}
+	r.Recorder.AnnotatedEventf(&pod, injectionCorrelation(pod), corev1.EventTypeWarning, EventReasonRegistrationFailed,
+		"Failed to register the service instance in Consul: %s", err)
+}
+
+// recordDeregistered records an event on the Kubernetes service when one of its service instances is
+// deregistered. Proxy service instances are deregistered along with the service instance of their pod,
+// so only the latter is recorded. No event is recorded if the Kubernetes service has been deleted.
+func (r *Controller) recordDeregistered(k8sSvcName, k8sSvcNamespace string, svc *api.AgentService) {
+	if svc.Kind == api.ServiceKindConnectProxy {
+		return
+	}
+	r.registeredInstances.remove(types.NamespacedName{Name: k8sSvcName, Namespace: k8sSvcNamespace}, svc.ID)
+	if r.Recorder == nil {
+		return
+	}
+	var service corev1.Service
+	if err := r.Client.Get(r.Context, types.NamespacedName{Name: k8sSvcName, Namespace: k8sSvcNamespace}, &service); err != nil {
+		return
+	}
+	message := fmt.Sprintf("Deregistered service instance %s from Consul", svc.ID)
+	if podName := svc.Meta[constants.MetaKeyPodName]; podName != "" {
+		message = fmt.Sprintf("Deregistered service instance %s of pod %s from Consul", svc.ID, podName)
+	}
+	r.Recorder.Event(&service, corev1.EventTypeNormal, EventReasonDeregistered, message)
+}
+
+// injectionCorrelation returns the annotations of the events recorded on the pod: the correlation ID of
+// the admission request that injected it, so that its events can be matched up with the webhook's logs.
+func injectionCorrelation(pod corev1.Pod) map[string]string {
+	id := pod.Annotations[constants.AnnotationInjectionCorrelationID]
+	if id == "" {
+		return nil
+	}
+	return map[string]string{constants.AnnotationInjectionCorrelationID: id}
+}
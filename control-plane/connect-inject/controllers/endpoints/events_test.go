@@ -0,0 +1,118 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRecordRegistered(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	ep := &Controller{
+		Client:   fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(),
+		Recorder: recorder,
+		Context:  context.Background(),
+	}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}}
+	serviceEndpoints := corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "service-created", Namespace: "default"}}
+	registration := &api.CatalogRegistration{Service: &api.AgentService{ID: "pod1-service-created", Service: "service-created"}}
+
+	ep.recordRegistered(pod, serviceEndpoints, registration)
+	ep.recordRegistered(pod, serviceEndpoints, registration)
+	require.Len(t, recorder.Events, 1)
+	require.Equal(t, "Normal ConsulServiceRegistered Registered service instance pod1-service-created of service service-created in Consul", <-recorder.Events)
+
+	// The event is recorded again once the instance has been deregistered.
+	ep.recordDeregistered("service-created", "default", registration.Service)
+	require.Equal(t, 0, ep.registeredInstances.count())
+	ep.recordRegistered(pod, serviceEndpoints, registration)
+	require.Len(t, recorder.Events, 1)
+}
+
+func TestRecordRegistrationFailed(t *testing.T) {
+	cases := map[string]struct {
+		err      error
+		expEvent string
+	}{
+		"ACL denied": {
+			err:      api.StatusError{Code: 403, Body: "Permission denied"},
+			expEvent: "Warning ConsulACLDenied Consul ACLs denied registering the service instance: Unexpected response code: 403 (Permission denied)",
+		},
+		"other error": {
+			err:      errors.New("connection refused"),
+			expEvent: "Warning ConsulServiceRegistrationFailed Failed to register the service instance in Consul: connection refused",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			ep := &Controller{Recorder: recorder}
+			ep.recordRegistrationFailed(corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}}, c.err)
+			require.Len(t, recorder.Events, 1)
+			require.Equal(t, c.expEvent, <-recorder.Events)
+		})
+	}
+}
+
+func TestInjectionCorrelation(t *testing.T) {
+	require.Nil(t, injectionCorrelation(corev1.Pod{}))
+
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{constants.AnnotationInjectionCorrelationID: "1234"},
+	}}
+	require.Equal(t, map[string]string{constants.AnnotationInjectionCorrelationID: "1234"}, injectionCorrelation(pod))
+}
+
+func TestRecordDeregistered(t *testing.T) {
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "service-created", Namespace: "default"}}
+	cases := map[string]struct {
+		svc       *api.AgentService
+		k8sObject bool
+		expEvents []string
+	}{
+		"service instance": {
+			svc:       &api.AgentService{ID: "pod1-service-created", Meta: map[string]string{constants.MetaKeyPodName: "pod1"}},
+			k8sObject: true,
+			expEvents: []string{"Normal ConsulServiceDeregistered Deregistered service instance pod1-service-created of pod pod1 from Consul"},
+		},
+		"proxy service instance": {
+			svc:       &api.AgentService{ID: "pod1-service-created-sidecar-proxy", Kind: api.ServiceKindConnectProxy},
+			k8sObject: true,
+		},
+		"deleted service": {
+			svc: &api.AgentService{ID: "pod1-service-created", Meta: map[string]string{constants.MetaKeyPodName: "pod1"}},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(scheme.Scheme)
+			if c.k8sObject {
+				builder = builder.WithObjects(service)
+			}
+			recorder := record.NewFakeRecorder(10)
+			ep := &Controller{
+				Client:   builder.Build(),
+				Recorder: recorder,
+				Context:  context.Background(),
+			}
+			ep.recordDeregistered("service-created", "default", c.svc)
+			var events []string
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			require.Equal(t, c.expEvents, events)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// FullSync reconciles every Endpoints object on an interval and whenever it's
+// triggered. Reconciles are otherwise only run when Kubernetes resources change,
+// so the registrations in Consul aren't repaired if they're lost or rolled back,
+// e.g. when Consul is restored from a snapshot.
+//
+// FullSync is a manager.Runnable. The controller watches its Source to run the
+// reconciles.
+type FullSync struct {
+	// Client lists the Endpoints to reconcile. With a PageSize it must read
+	// from the API server rather than the informer cache, which doesn't
+	// paginate lists.
+	Client client.Reader
+	// PageSize is the number of Endpoints listed per request. Zero lists all
+	// of them in one request.
+	PageSize int64
+	// Cache is reset before each full sync so that the reconciles read the
+	// service instances from Consul rather than the cached state.
+	Cache *ServiceInstanceCache
+	// Interval is the interval between full syncs. Zero disables them, so
+	// that full syncs only run when they're triggered.
+	Interval time.Duration
+	// Log is the logger for full syncs.
+	Log logr.Logger
+
+	once    sync.Once
+	events  chan event.GenericEvent
+	trigger chan struct{}
+}
+
+func (f *FullSync) init() {
+	f.events = make(chan event.GenericEvent)
+	f.trigger = make(chan struct{}, 1)
+}
+
+// Source returns the source of the reconcile events of full syncs.
+func (f *FullSync) Source() source.Source {
+	f.once.Do(f.init)
+	return &source.Channel{Source: f.events}
+}
+
+// Trigger requests a full sync without blocking. Requests are merged while
+// one is pending.
+func (f *FullSync) Trigger() {
+	f.once.Do(f.init)
+	select {
+	case f.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// ServeHTTP triggers a full sync on POST requests.
+func (f *FullSync) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	f.Log.Info("full sync requested", "remote-addr", req.RemoteAddr)
+	f.Trigger()
+	rw.WriteHeader(http.StatusAccepted)
+}
+
+// Start runs full syncs until the context is cancelled.
+func (f *FullSync) Start(ctx context.Context) error {
+	f.once.Do(f.init)
+
+	// tickCh is nil, and never ready, if periodic full syncs are disabled.
+	var tickCh <-chan time.Time
+	if f.Interval > 0 {
+		ticker := time.NewTicker(f.Interval)
+		defer ticker.Stop()
+		tickCh = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tickCh:
+		case <-f.trigger:
+		}
+		if err := f.sync(ctx); err != nil {
+			f.Log.Error(err, "failed to run full sync")
+		}
+	}
+}
+
+// sync queues a reconcile of every Endpoints object.
+func (f *FullSync) sync(ctx context.Context) error {
+	var items []corev1.Endpoints
+	opts := &client.ListOptions{Limit: f.PageSize}
+	for {
+		var list corev1.EndpointsList
+		if err := f.Client.List(ctx, &list, opts); err != nil {
+			return err
+		}
+		items = append(items, list.Items...)
+		if list.Continue == "" {
+			break
+		}
+		opts.Continue = list.Continue
+	}
+	if f.Cache != nil {
+		f.Cache.Reset()
+	}
+	f.Log.Info("starting full sync", "endpoints", len(items))
+	for i := range items {
+		select {
+		case <-ctx.Done():
+			return nil
+		case f.events <- event.GenericEvent{Object: &items[i]}:
+		}
+	}
+	return nil
+}
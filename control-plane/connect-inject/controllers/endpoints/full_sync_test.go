@@ -0,0 +1,124 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestFullSync(t *testing.T) {
+	t.Parallel()
+
+	fullSync := &FullSync{
+		Client: fake.NewClientBuilder().WithObjects(
+			&corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}},
+			&corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "team-a"}},
+		).Build(),
+		Cache: &ServiceInstanceCache{},
+		Log:   logrtest.New(t),
+	}
+	fullSync.Source()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- fullSync.Start(ctx) }()
+
+	// Only POST requests trigger a full sync.
+	rec := httptest.NewRecorder()
+	fullSync.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/full-sync", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	rec = httptest.NewRecorder()
+	fullSync.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/full-sync", nil))
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	var synced []string
+	for len(synced) < 2 {
+		select {
+		case e := <-fullSync.events:
+			synced = append(synced, e.Object.GetNamespace()+"/"+e.Object.GetName())
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for full sync")
+		}
+	}
+	require.ElementsMatch(t, []string{"default/web", "team-a/api"}, synced)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestFullSync_pagination(t *testing.T) {
+	t.Parallel()
+
+	reader := &pagingReader{
+		Reader: fake.NewClientBuilder().WithObjects(
+			&corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}},
+			&corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}},
+			&corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"}},
+		).Build(),
+	}
+	fullSync := &FullSync{
+		Client:   reader,
+		PageSize: 2,
+		Log:      logrtest.New(t),
+	}
+	fullSync.Source()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- fullSync.sync(ctx) }()
+
+	var synced []string
+	for len(synced) < 3 {
+		select {
+		case e := <-fullSync.events:
+			synced = append(synced, e.Object.GetName())
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for full sync")
+		}
+	}
+	require.NoError(t, <-done)
+	require.ElementsMatch(t, []string{"web", "api", "db"}, synced)
+	require.Equal(t, 2, reader.requests)
+}
+
+// pagingReader paginates Endpoints lists like the API server, with the index of
+// the next item as the continue token.
+type pagingReader struct {
+	client.Reader
+	requests int
+}
+
+func (p *pagingReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	p.requests++
+	listOpts := (&client.ListOptions{}).ApplyOptions(opts)
+	if err := p.Reader.List(ctx, list); err != nil {
+		return err
+	}
+	endpoints := list.(*corev1.EndpointsList)
+	start := 0
+	if listOpts.Continue != "" {
+		start, _ = strconv.Atoi(listOpts.Continue)
+	}
+	end := len(endpoints.Items)
+	if listOpts.Limit > 0 && start+int(listOpts.Limit) < end {
+		end = start + int(listOpts.Limit)
+		endpoints.Continue = strconv.Itoa(end)
+	}
+	endpoints.Items = endpoints.Items[start:end]
+	return nil
+}
@@ -0,0 +1,234 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// MutualTLSModes is a local view of the mutual TLS modes that the service-defaults and proxy-defaults
+// config entries set. It is kept up to date with a blocking query per config entry kind so that
+// reconciles can look up the mode of a service without reading its config entries from Consul.
+//
+// MutualTLSModes is a manager.Runnable.
+type MutualTLSModes struct {
+	// ConsulClientConfig is the config for the Consul API client.
+	ConsulClientConfig *consul.Config
+	// ConsulServerConnMgr is the watcher for the Consul server addresses.
+	ConsulServerConnMgr consul.ServerConnectionManager
+	// EnableConsulNamespaces indicates that service-defaults are written across
+	// Consul namespaces and must be queried with the wildcard namespace.
+	EnableConsulNamespaces bool
+	// WaitTime is the maximum duration of the blocking queries. Zero uses
+	// Consul's default of five minutes.
+	WaitTime time.Duration
+
+	Log logr.Logger
+
+	lock sync.RWMutex
+	// services is the mode set by the service-defaults of each service, keyed
+	// by Consul namespace and service name. It's nil until the first query for
+	// service-defaults has returned.
+	services map[serviceName]api.MutualTLSMode
+	// proxyDefaults is the mode set by the global proxy-defaults, and
+	// proxyDefaultsSynced is true once the first query for it has returned.
+	proxyDefaults       api.MutualTLSMode
+	proxyDefaultsSynced bool
+}
+
+// serviceName identifies a Consul service by namespace and name.
+type serviceName struct {
+	namespace string
+	name      string
+}
+
+// Start watches the service-defaults and proxy-defaults config entries until ctx is cancelled.
+func (m *MutualTLSModes) Start(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, kind := range []string{api.ServiceDefaults, api.ProxyDefaults} {
+		kind := kind
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.watch(ctx, kind)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// Mode returns the mutual TLS mode of the service in the Consul namespace, which is empty if neither its
+// service-defaults nor the global proxy-defaults set it. It returns false until both config entry kinds
+// have been read.
+func (m *MutualTLSModes) Mode(namespace, name string) (api.MutualTLSMode, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if m.services == nil || !m.proxyDefaultsSynced {
+		return "", false
+	}
+	if !m.EnableConsulNamespaces {
+		namespace = ""
+	}
+	if mode := m.services[serviceName{namespace: namespace, name: name}]; mode != api.MutualTLSModeDefault {
+		return mode, true
+	}
+	return m.proxyDefaults, true
+}
+
+// watch holds a blocking query for the config entries of the kind.
+func (m *MutualTLSModes) watch(ctx context.Context, kind string) {
+	var index uint64
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		consulClient, err := consul.NewClientFromConnMgr(m.ConsulClientConfig, m.ConsulServerConnMgr)
+		if err != nil {
+			m.Log.Error(err, "failed to create Consul API client; will retry", "kind", kind)
+			if !sleepWithContext(ctx, cacheRetryInterval) {
+				return
+			}
+			continue
+		}
+
+		opts := &api.QueryOptions{WaitIndex: index, WaitTime: m.WaitTime, AllowStale: m.ConsulClientConfig.AllowStale}
+		// proxy-defaults can only be created in the default namespace.
+		if m.EnableConsulNamespaces && kind == api.ServiceDefaults {
+			opts.Namespace = namespaces.WildcardNamespace
+		}
+		entries, meta, err := consulClient.ConfigEntries().List(kind, opts.WithContext(ctx))
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			m.Log.Error(err, "failed to query config entries; will retry", "kind", kind)
+			if !sleepWithContext(ctx, cacheRetryInterval) {
+				return
+			}
+			continue
+		}
+
+		// Reset the index if it goes backwards, e.g. after a snapshot restore,
+		// as recommended for blocking queries.
+		if meta.LastIndex < index {
+			index = 0
+		} else {
+			index = meta.LastIndex
+		}
+		m.replace(kind, entries)
+	}
+}
+
+// replace sets the modes of the config entries of the kind.
+func (m *MutualTLSModes) replace(kind string, entries []api.ConfigEntry) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	switch kind {
+	case api.ServiceDefaults:
+		m.services = make(map[serviceName]api.MutualTLSMode, len(entries))
+		for _, entry := range entries {
+			if serviceDefaults, ok := entry.(*api.ServiceConfigEntry); ok && serviceDefaults.MutualTLSMode != api.MutualTLSModeDefault {
+				namespace := serviceDefaults.Namespace
+				if !m.EnableConsulNamespaces {
+					namespace = ""
+				}
+				m.services[serviceName{namespace: namespace, name: serviceDefaults.Name}] = serviceDefaults.MutualTLSMode
+			}
+		}
+	case api.ProxyDefaults:
+		m.proxyDefaults = api.MutualTLSModeDefault
+		for _, entry := range entries {
+			if proxyDefaults, ok := entry.(*api.ProxyConfigEntry); ok && proxyDefaults.Name == api.ProxyConfigGlobal {
+				m.proxyDefaults = proxyDefaults.MutualTLSMode
+			}
+		}
+		m.proxyDefaultsSynced = true
+	}
+}
+
+// updateMutualTLSModeAnnotation annotates the pod with the mutual TLS mode of its Consul service so that
+// operators migrating to the service mesh can see which pods still accept plaintext traffic. The
+// annotation is removed if the mode isn't set. modes caches the mode of each service for the duration of
+// a reconcile when it's read from Consul rather than from MutualTLSModes.
+func (r *Controller) updateMutualTLSModeAnnotation(ctx context.Context, apiClient *api.Client, pod corev1.Pod, serviceEndpoints corev1.Endpoints, modes map[string]api.MutualTLSMode) error {
+	svcName := r.serviceName(pod, serviceEndpoints)
+	mode, ok := modes[svcName]
+	if !ok && r.MutualTLSModes != nil {
+		mode, ok = r.MutualTLSModes.Mode(r.consulNamespace(pod.Namespace), svcName)
+	}
+	if !ok {
+		var err error
+		mode, err = r.mutualTLSMode(apiClient, svcName, r.consulNamespace(pod.Namespace))
+		if err != nil {
+			return err
+		}
+		modes[svcName] = mode
+	}
+
+	current, annotated := pod.Annotations[constants.KeyMutualTLSMode]
+	if mode == api.MutualTLSModeDefault && !annotated || annotated && current == string(mode) {
+		return nil
+	}
+	r.Log.Info("updating mutual TLS mode annotation", "name", pod.Name, "ns", pod.Namespace, "mode", mode)
+	patch := client.MergeFrom(pod.DeepCopy())
+	if mode == api.MutualTLSModeDefault {
+		delete(pod.Annotations, constants.KeyMutualTLSMode)
+	} else {
+		if pod.Annotations == nil {
+			pod.Annotations = make(map[string]string)
+		}
+		pod.Annotations[constants.KeyMutualTLSMode] = string(mode)
+	}
+	return r.Client.Patch(ctx, &pod, patch)
+}
+
+// mutualTLSMode reads the mutual TLS mode of the service from Consul. It is set by the service's
+// service-defaults config entry, falling back to the global proxy-defaults config entry. It is empty if
+// neither sets it.
+func (r *Controller) mutualTLSMode(apiClient *api.Client, svcName, namespace string) (api.MutualTLSMode, error) {
+	entry, _, err := apiClient.ConfigEntries().Get(api.ServiceDefaults, svcName, &api.QueryOptions{
+		Namespace:  namespace,
+		Partition:  r.consulPartition(),
+		AllowStale: r.consulAllowStale(),
+	})
+	if err != nil && !isNotFoundErr(err) {
+		return "", err
+	}
+	if serviceDefaults, ok := entry.(*api.ServiceConfigEntry); ok && serviceDefaults.MutualTLSMode != api.MutualTLSModeDefault {
+		return serviceDefaults.MutualTLSMode, nil
+	}
+
+	// proxy-defaults can only be created in the default namespace.
+	entry, _, err = apiClient.ConfigEntries().Get(api.ProxyDefaults, api.ProxyConfigGlobal, &api.QueryOptions{
+		Partition:  r.consulPartition(),
+		AllowStale: r.consulAllowStale(),
+	})
+	if err != nil && !isNotFoundErr(err) {
+		return "", err
+	}
+	if proxyDefaults, ok := entry.(*api.ProxyConfigEntry); ok {
+		return proxyDefaults.MutualTLSMode, nil
+	}
+	return api.MutualTLSModeDefault, nil
+}
+
+// isNotFoundErr returns true if err is a 404 response from Consul.
+func isNotFoundErr(err error) bool {
+	var statusErr api.StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound
+}
@@ -0,0 +1,249 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/test"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestUpdateMutualTLSModeAnnotation(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		serviceDefaults *api.ServiceConfigEntry
+		proxyDefaults   *api.ProxyConfigEntry
+		existing        string
+		expMode         string
+	}{
+		"no config entries": {
+			expMode: "",
+		},
+		"service-defaults permissive": {
+			serviceDefaults: &api.ServiceConfigEntry{Kind: api.ServiceDefaults, Name: "web", MutualTLSMode: api.MutualTLSModePermissive},
+			expMode:         "permissive",
+		},
+		"proxy-defaults permissive": {
+			proxyDefaults: &api.ProxyConfigEntry{Kind: api.ProxyDefaults, Name: api.ProxyConfigGlobal, MutualTLSMode: api.MutualTLSModePermissive},
+			expMode:       "permissive",
+		},
+		"service-defaults takes precedence over proxy-defaults": {
+			serviceDefaults: &api.ServiceConfigEntry{Kind: api.ServiceDefaults, Name: "web", MutualTLSMode: api.MutualTLSModeStrict},
+			proxyDefaults:   &api.ProxyConfigEntry{Kind: api.ProxyDefaults, Name: api.ProxyConfigGlobal, MutualTLSMode: api.MutualTLSModePermissive},
+			expMode:         "strict",
+		},
+		"service-defaults without a mode falls back to proxy-defaults": {
+			serviceDefaults: &api.ServiceConfigEntry{Kind: api.ServiceDefaults, Name: "web", Protocol: "http"},
+			proxyDefaults:   &api.ProxyConfigEntry{Kind: api.ProxyDefaults, Name: api.ProxyConfigGlobal, MutualTLSMode: api.MutualTLSModePermissive},
+			expMode:         "permissive",
+		},
+		"annotation is updated when the mode changes": {
+			serviceDefaults: &api.ServiceConfigEntry{Kind: api.ServiceDefaults, Name: "web", MutualTLSMode: api.MutualTLSModeStrict},
+			existing:        "permissive",
+			expMode:         "strict",
+		},
+		"annotation is removed when the mode is unset": {
+			existing: "permissive",
+			expMode:  "",
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var entry api.ConfigEntry
+				switch r.URL.Path {
+				case "/v1/config/service-defaults/web":
+					if c.serviceDefaults != nil {
+						entry = c.serviceDefaults
+					}
+				case "/v1/config/proxy-defaults/global":
+					if c.proxyDefaults != nil {
+						entry = c.proxyDefaults
+					}
+				}
+				if entry == nil {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				require.NoError(t, json.NewEncoder(w).Encode(entry))
+			}))
+			t.Cleanup(consulServer.Close)
+
+			apiClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+			require.NoError(t, err)
+
+			pod := createServicePod("pod1", "1.2.3.4", true, true)
+			if c.existing != "" {
+				pod.Annotations[constants.KeyMutualTLSMode] = c.existing
+			}
+			fakeClient := fake.NewClientBuilder().WithRuntimeObjects(pod).Build()
+			ep := &Controller{
+				Client: fakeClient,
+				Log:    logrtest.New(t),
+			}
+			serviceEndpoints := corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+
+			modes := make(map[string]api.MutualTLSMode)
+			err = ep.updateMutualTLSModeAnnotation(context.Background(), apiClient, *pod, serviceEndpoints, modes)
+			require.NoError(t, err)
+			require.Equal(t, api.MutualTLSMode(c.expMode), modes["web"])
+
+			var updated corev1.Pod
+			err = fakeClient.Get(context.Background(), types.NamespacedName{Name: "pod1", Namespace: "default"}, &updated)
+			require.NoError(t, err)
+			mode, ok := updated.Annotations[constants.KeyMutualTLSMode]
+			require.Equal(t, c.expMode != "", ok)
+			require.Equal(t, c.expMode, mode)
+		})
+	}
+}
+
+func TestUpdateMutualTLSModeAnnotation_CachesMode(t *testing.T) {
+	t.Parallel()
+
+	requests := 0
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(consulServer.Close)
+	apiClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+
+	pod1 := createServicePod("pod1", "1.2.3.4", true, true)
+	pod2 := createServicePod("pod2", "2.2.3.4", true, true)
+	ep := &Controller{
+		Client: fake.NewClientBuilder().WithRuntimeObjects(pod1, pod2).Build(),
+		Log:    logrtest.New(t),
+	}
+	serviceEndpoints := corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+
+	modes := make(map[string]api.MutualTLSMode)
+	for _, pod := range []*corev1.Pod{pod1, pod2} {
+		err = ep.updateMutualTLSModeAnnotation(context.Background(), apiClient, *pod, serviceEndpoints, modes)
+		require.NoError(t, err)
+	}
+	// One request each for service-defaults and proxy-defaults.
+	require.Equal(t, 2, requests)
+}
+
+func TestUpdateMutualTLSModeAnnotation_ServerError(t *testing.T) {
+	t.Parallel()
+
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(consulServer.Close)
+	apiClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+
+	pod := createServicePod("pod1", "1.2.3.4", true, true)
+	pod.Annotations[constants.KeyMutualTLSMode] = "permissive"
+	ep := &Controller{
+		Client: fake.NewClientBuilder().WithRuntimeObjects(pod).Build(),
+		Log:    logrtest.New(t),
+	}
+	serviceEndpoints := corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+
+	// Errors other than not found aren't taken to mean that the mode is unset.
+	err = ep.updateMutualTLSModeAnnotation(context.Background(), apiClient, *pod, serviceEndpoints, make(map[string]api.MutualTLSMode))
+	require.Error(t, err)
+	var updated corev1.Pod
+	require.NoError(t, ep.Client.Get(context.Background(), types.NamespacedName{Name: "pod1", Namespace: "default"}, &updated))
+	require.Equal(t, "permissive", updated.Annotations[constants.KeyMutualTLSMode])
+}
+
+func TestMutualTLSModes(t *testing.T) {
+	t.Parallel()
+
+	// Serve the config entries once, then block like Consul would until
+	// the watch is cancelled.
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("index") != "" {
+			<-r.Context().Done()
+			return
+		}
+		var entries []api.ConfigEntry
+		switch r.URL.Path {
+		case "/v1/config/service-defaults":
+			entries = []api.ConfigEntry{
+				&api.ServiceConfigEntry{Kind: api.ServiceDefaults, Name: "web", MutualTLSMode: api.MutualTLSModeStrict},
+				&api.ServiceConfigEntry{Kind: api.ServiceDefaults, Name: "api", Protocol: "http"},
+			}
+		case "/v1/config/proxy-defaults":
+			entries = []api.ConfigEntry{
+				&api.ProxyConfigEntry{Kind: api.ProxyDefaults, Name: api.ProxyConfigGlobal, MutualTLSMode: api.MutualTLSModePermissive},
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("X-Consul-Index", "10")
+		require.NoError(t, json.NewEncoder(w).Encode(entries))
+	}))
+	t.Cleanup(consulServer.Close)
+
+	serverURL, err := url.Parse(consulServer.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(serverURL.Port())
+	require.NoError(t, err)
+
+	modes := &MutualTLSModes{
+		ConsulClientConfig: &consul.Config{
+			APIClientConfig: &api.Config{},
+			HTTPPort:        port,
+		},
+		ConsulServerConnMgr: test.MockConnMgrForIPAndPort(serverURL.Hostname(), 0),
+		Log:                 logrtest.New(t),
+	}
+
+	// Lookups fall back to Consul until both config entry kinds have been read.
+	_, ok := modes.Mode("", "web")
+	require.False(t, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go modes.Start(ctx)
+
+	retry.Run(t, func(r *retry.R) {
+		mode, ok := modes.Mode("", "web")
+		require.True(r, ok)
+		require.Equal(r, api.MutualTLSModeStrict, mode)
+	})
+	mode, ok := modes.Mode("", "api")
+	require.True(t, ok)
+	require.Equal(t, api.MutualTLSModePermissive, mode)
+
+	// Reconciles don't read the config entries from Consul once the modes are known.
+	pod := createServicePod("pod1", "1.2.3.4", true, true)
+	ep := &Controller{
+		Client:         fake.NewClientBuilder().WithRuntimeObjects(pod).Build(),
+		Log:            logrtest.New(t),
+		MutualTLSModes: modes,
+	}
+	serviceEndpoints := corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	err = ep.updateMutualTLSModeAnnotation(context.Background(), nil, *pod, serviceEndpoints, make(map[string]api.MutualTLSMode))
+	require.NoError(t, err)
+	var updated corev1.Pod
+	require.NoError(t, ep.Client.Get(context.Background(), types.NamespacedName{Name: "pod1", Namespace: "default"}, &updated))
+	require.Equal(t, "strict", updated.Annotations[constants.KeyMutualTLSMode])
+}
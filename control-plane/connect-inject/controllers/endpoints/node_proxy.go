@@ -0,0 +1,177 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/common"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// metaKeyNodeProxy is the meta key of proxy service instances that are listeners on the proxy of
+// their Kubernetes node rather than sidecars of their pod.
+const metaKeyNodeProxy = "node-proxy"
+
+// NodeProxyPorts assigns the proxy service instances of pods that use the proxy of their Kubernetes
+// node a listener port on that proxy. Ports are unique per node, are kept for as long as the proxy
+// service instance is registered, and are released when it's deregistered.
+//
+// This is part of the experimental node proxy mode.
+type NodeProxyPorts struct {
+	minPort int
+	maxPort int
+
+	mu sync.Mutex
+	// ports is the port of each proxy service ID, keyed by the Consul node name.
+	ports map[string]map[string]int
+	// reserved is the Consul node names whose registered ports have been reserved.
+	reserved map[string]bool
+}
+
+// NewNodeProxyPorts returns NodeProxyPorts that assigns ports from minPort to maxPort, inclusive.
+func NewNodeProxyPorts(minPort, maxPort int) *NodeProxyPorts {
+	return &NodeProxyPorts{
+		minPort:  minPort,
+		maxPort:  maxPort,
+		ports:    make(map[string]map[string]int),
+		reserved: make(map[string]bool),
+	}
+}
+
+// Port returns the port assigned to the proxy service ID on the node, if any.
+func (p *NodeProxyPorts) Port(node, proxyID string) (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	port, ok := p.ports[node][proxyID]
+	return port, ok
+}
+
+// Reserved returns true if the ports registered on the node have been reserved.
+func (p *NodeProxyPorts) Reserved(node string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.reserved[node]
+}
+
+// ReserveNode assigns the ports of the proxy service IDs on the node, unless the node's ports have
+// already been reserved. It's used to keep the ports of proxy service instances that were registered
+// before the controller started. Ports that were assigned since are kept.
+func (p *NodeProxyPorts) ReserveNode(node string, ports map[string]int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.reserved[node] {
+		return
+	}
+	p.reserved[node] = true
+	if len(ports) == 0 {
+		return
+	}
+	if p.ports[node] == nil {
+		p.ports[node] = make(map[string]int, len(ports))
+	}
+	for proxyID, port := range ports {
+		if _, ok := p.ports[node][proxyID]; !ok {
+			p.ports[node][proxyID] = port
+		}
+	}
+}
+
+// Assign returns the port assigned to the proxy service ID on the node, assigning it the lowest
+// free port if it doesn't have one yet. It returns an error if all ports of the node are in use.
+func (p *NodeProxyPorts) Assign(node, proxyID string) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if port, ok := p.ports[node][proxyID]; ok {
+		return port, nil
+	}
+
+	used := make(map[int]bool, len(p.ports[node]))
+	for _, port := range p.ports[node] {
+		used[port] = true
+	}
+	for port := p.minPort; port <= p.maxPort; port++ {
+		if !used[port] {
+			if p.ports[node] == nil {
+				p.ports[node] = make(map[string]int)
+			}
+			p.ports[node][proxyID] = port
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no free node proxy ports on node %s in range %d-%d", node, p.minPort, p.maxPort)
+}
+
+// Release frees the port assigned to the proxy service ID on the node.
+func (p *NodeProxyPorts) Release(node, proxyID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.ports[node], proxyID)
+	if len(p.ports[node]) == 0 {
+		delete(p.ports, node)
+	}
+}
+
+// usesNodeProxy returns true if the pod's proxy service instance should be registered as a listener
+// on the proxy of its node. Pods can only opt into it if the node proxy mode is enabled.
+func (r *Controller) usesNodeProxy(pod corev1.Pod) (bool, error) {
+	if r.NodeProxyPorts == nil {
+		return false, nil
+	}
+	return common.UsesNodeProxy(pod)
+}
+
+// reserveNodeProxyPorts keeps the ports of all proxy service instances already registered on the
+// proxy of the pod's node, so that their listeners don't move when the controller restarts and their
+// ports aren't assigned to other pods. The node's instances are read once, from every Consul namespace.
+// Consul nodes belong to a single partition, and the controller only registers in its own, so that's
+// the only partition the node's listeners can be registered in.
+func (r *Controller) reserveNodeProxyPorts(apiClient *api.Client, pod corev1.Pod) error {
+	node := common.ConsulNodeNameFromK8sNode(pod.Spec.NodeName)
+	if r.NodeProxyPorts.Reserved(node) {
+		return nil
+	}
+	// A stale read could miss a listener and hand its port out again, so this read is consistent.
+	opts := &api.QueryOptions{
+		Filter:            fmt.Sprintf("Meta[%q] == %q", metaKeyNodeProxy, "true"),
+		Partition:         r.consulPartition(),
+		RequireConsistent: true,
+	}
+	if r.EnableConsulNamespaces {
+		opts.Namespace = namespaces.WildcardNamespace
+	}
+	nodeServices, _, err := apiClient.Catalog().NodeServiceList(node, opts)
+	if err != nil {
+		return err
+	}
+	ports := make(map[string]int)
+	if nodeServices != nil {
+		for _, svc := range nodeServices.Services {
+			ports[r.nodeProxyKey(svc.Namespace, svc.ID)] = svc.Port
+		}
+	}
+	r.NodeProxyPorts.ReserveNode(node, ports)
+	return nil
+}
+
+// nodeProxyKey returns the key of a proxy service instance in NodeProxyPorts. Proxy service IDs are
+// only unique within a Consul namespace, so they're qualified with it when namespaces are enabled.
+func (r *Controller) nodeProxyKey(consulNamespace, proxySvcID string) string {
+	if !r.EnableConsulNamespaces {
+		return proxySvcID
+	}
+	return consulNamespace + "/" + proxySvcID
+}
+
+// instanceAddress returns the pod IP of a service instance. The instances of node proxy listeners
+// have the address of their node, so their pod IP is their local service address instead.
+func instanceAddress(svc *api.AgentService) string {
+	if svc.Meta[metaKeyNodeProxy] == "true" && svc.Proxy != nil {
+		return svc.Proxy.LocalServiceAddress
+	}
+	return svc.Address
+}
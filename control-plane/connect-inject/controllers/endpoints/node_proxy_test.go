@@ -0,0 +1,179 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNodeProxyPorts(t *testing.T) {
+	t.Parallel()
+	ports := NewNodeProxyPorts(22000, 22001)
+
+	port, err := ports.Assign("node1", "pod1-web-sidecar-proxy")
+	require.NoError(t, err)
+	require.Equal(t, 22000, port)
+
+	// The same proxy keeps its port.
+	port, err = ports.Assign("node1", "pod1-web-sidecar-proxy")
+	require.NoError(t, err)
+	require.Equal(t, 22000, port)
+
+	// Ports are assigned per node.
+	port, err = ports.Assign("node2", "pod2-web-sidecar-proxy")
+	require.NoError(t, err)
+	require.Equal(t, 22000, port)
+
+	// Reserved ports aren't assigned to other proxies, and assigned ports are kept.
+	require.False(t, ports.Reserved("node1"))
+	ports.ReserveNode("node1", map[string]int{"pod1-web-sidecar-proxy": 22001, "pod3-web-sidecar-proxy": 22001})
+	require.True(t, ports.Reserved("node1"))
+	port, _ = ports.Port("node1", "pod1-web-sidecar-proxy")
+	require.Equal(t, 22000, port)
+	_, err = ports.Assign("node1", "pod4-web-sidecar-proxy")
+	require.EqualError(t, err, "no free node proxy ports on node node1 in range 22000-22001")
+
+	// Released ports can be assigned again.
+	ports.Release("node1", "pod1-web-sidecar-proxy")
+	_, ok := ports.Port("node1", "pod1-web-sidecar-proxy")
+	require.False(t, ok)
+	port, err = ports.Assign("node1", "pod4-web-sidecar-proxy")
+	require.NoError(t, err)
+	require.Equal(t, 22000, port)
+}
+
+// Test that the ports of all node proxy listeners on the node are reserved,
+// across Consul namespaces, with a single query.
+func TestReserveNodeProxyPorts(t *testing.T) {
+	t.Parallel()
+	var queries []url.Values
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/catalog/node-services/node1-virtual", r.URL.Path)
+		queries = append(queries, r.URL.Query())
+		fmt.Fprint(w, `{"Node": {"Node": "node1-virtual"}, "Services": [
+			{"ID": "pod1-web-sidecar-proxy", "Namespace": "ns1", "Port": 22000, "Meta": {"node-proxy": "true"}},
+			{"ID": "pod1-web-sidecar-proxy", "Namespace": "ns2", "Port": 22001, "Meta": {"node-proxy": "true"}}
+		]}`)
+	}))
+	t.Cleanup(consulServer.Close)
+	apiClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+
+	pod := createServicePod("pod2", "1.2.3.4", true, true)
+	pod.Spec.NodeName = "node1"
+	epCtrl := Controller{
+		NodeProxyPorts:         NewNodeProxyPorts(22000, 22002),
+		EnableConsulNamespaces: true,
+		ConsulClientConfig:     &consul.Config{AllowStale: true},
+		Log:                    logrtest.New(t),
+	}
+	require.NoError(t, epCtrl.reserveNodeProxyPorts(apiClient, *pod))
+	require.NoError(t, epCtrl.reserveNodeProxyPorts(apiClient, *pod))
+	require.Len(t, queries, 1)
+	require.Equal(t, "*", queries[0].Get("ns"))
+	require.True(t, queries[0].Has("consistent"))
+	require.Equal(t, `Meta["node-proxy"] == "true"`, queries[0].Get("filter"))
+
+	port, err := epCtrl.NodeProxyPorts.Assign("node1-virtual", "ns3/pod2-web-sidecar-proxy")
+	require.NoError(t, err)
+	require.Equal(t, 22002, port)
+}
+
+func TestCreateServiceRegistrations_NodeProxy(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		nodeProxyPorts *NodeProxyPorts
+		annotation     string
+		expAddress     string
+		expPort        int
+		expLocalAddr   string
+		expMode        api.ProxyMode
+		expMeta        string
+	}{
+		"node proxy disabled": {
+			annotation:   "true",
+			expAddress:   "1.2.3.4",
+			expPort:      20000,
+			expLocalAddr: "127.0.0.1",
+			expMode:      api.ProxyModeTransparent,
+		},
+		"pod without the annotation": {
+			nodeProxyPorts: NewNodeProxyPorts(22000, 22999),
+			expAddress:     "1.2.3.4",
+			expPort:        20000,
+			expLocalAddr:   "127.0.0.1",
+			expMode:        api.ProxyModeTransparent,
+		},
+		"pod using the node proxy": {
+			nodeProxyPorts: NewNodeProxyPorts(22000, 22999),
+			annotation:     "true",
+			expAddress:     consulNodeAddress,
+			expPort:        22000,
+			expLocalAddr:   "1.2.3.4",
+			expMeta:        "true",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createServicePod("pod1", "1.2.3.4", true, true)
+			pod.Annotations[constants.AnnotationPort] = "8080"
+			if c.annotation != "" {
+				pod.Annotations[constants.AnnotationNodeProxy] = c.annotation
+			}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "service-created",
+					Namespace: "default",
+				},
+			}
+			service := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "service-created",
+					Namespace: "default",
+				},
+				Spec: corev1.ServiceSpec{
+					ClusterIP: "10.0.0.1",
+					Ports: []corev1.ServicePort{
+						{Port: 80, TargetPort: intstr.FromInt(8080)},
+					},
+				},
+			}
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := Controller{
+				Client:                 fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, service, ns).Build(),
+				EnableTransparentProxy: true,
+				NodeProxyPorts:         c.nodeProxyPorts,
+				Log:                    logrtest.New(t),
+				Context:                context.Background(),
+			}
+
+			serviceRegistration, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(*pod, *endpoints, api.HealthPassing)
+			require.NoError(t, err)
+			proxy := proxyServiceRegistration.Service
+			require.Equal(t, c.expAddress, proxy.Address)
+			require.Equal(t, c.expPort, proxy.Port)
+			require.Equal(t, c.expLocalAddr, proxy.Proxy.LocalServiceAddress)
+			require.Equal(t, 8080, proxy.Proxy.LocalServicePort)
+			require.Equal(t, c.expMode, proxy.Proxy.Mode)
+			require.Equal(t, c.expMeta, proxy.Meta[metaKeyNodeProxy])
+			require.Empty(t, serviceRegistration.Service.Meta[metaKeyNodeProxy])
+			require.Equal(t, "1.2.3.4", instanceAddress(proxy))
+		})
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// OrphanReaper deregisters the service instances whose pods no longer exist. Reconciles deregister the
+// instances of pods that are removed from the Endpoints of their service, but they never run for Endpoints
+// that were deleted while the controller wasn't running, and they only look up instances on the Consul
+// nodes of existing Kubernetes nodes, so the instances of pods that were force-deleted or lost along with
+// their node can stay registered.
+//
+// OrphanReaper is a manager.Runnable.
+type OrphanReaper struct {
+	// Controller is the endpoints controller whose service instances are reaped.
+	Controller *Controller
+	// Interval is the interval between looking up orphaned service instances.
+	Interval time.Duration
+	// TTL is how long the pod of a service instance must be missing before the instance is deregistered,
+	// so that instances of pods that were just created aren't deregistered before the informer cache has
+	// caught up.
+	TTL time.Duration
+	// Log is the logger for the reaper.
+	Log logr.Logger
+
+	// missingSince is when the pod of each orphaned service instance was first found missing, keyed by
+	// the instance's node, namespace and ID.
+	missingSince map[string]time.Time
+}
+
+// Start reaps orphaned service instances until the context is cancelled.
+func (o *OrphanReaper) Start(ctx context.Context) error {
+	ticker := time.NewTicker(o.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := o.reap(ctx, time.Now()); err != nil {
+			o.Log.Error(err, "failed to reap orphaned service instances")
+		}
+	}
+}
+
+// reap deregisters the service instances whose pods have been missing for longer than the TTL.
+func (o *OrphanReaper) reap(ctx context.Context, now time.Time) error {
+	r := o.Controller
+	serverState, err := r.ConsulServerConnMgr.State()
+	if err != nil {
+		return fmt.Errorf("failed to get Consul server state: %w", err)
+	}
+	apiClient, err := consul.NewClientFromConnMgrState(r.ConsulClientConfig, serverState)
+	if err != nil {
+		return fmt.Errorf("failed to create Consul API client: %w", err)
+	}
+
+	nodes, _, err := apiClient.Catalog().Nodes(&api.QueryOptions{
+		NodeMeta:          map[string]string{metaKeySyntheticNode: "true"},
+		RequireConsistent: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list Consul nodes: %w", err)
+	}
+
+	if o.missingSince == nil {
+		o.missingSince = make(map[string]time.Time)
+	}
+	missingSince := make(map[string]time.Time)
+	for _, node := range nodes {
+		opts := &api.QueryOptions{
+			Filter:            fmt.Sprintf(`Meta[%q] == %q`, metaKeyManagedBy, constants.ManagedByValue),
+			RequireConsistent: true,
+		}
+		if r.EnableConsulNamespaces {
+			opts.Namespace = namespaces.WildcardNamespace
+		}
+		nodeServices, _, err := apiClient.Catalog().NodeServiceList(node.Node, opts)
+		if err != nil {
+			return fmt.Errorf("failed to list service instances on node %s: %w", node.Node, err)
+		}
+		for _, svc := range nodeServices.Services {
+			k8sNamespace := svc.Meta[constants.MetaKeyKubeNS]
+			podName := svc.Meta[constants.MetaKeyPodName]
+			if podName == "" || shouldIgnore(k8sNamespace, r.DenyK8sNamespacesSet, r.AllowK8sNamespacesSet) {
+				continue
+			}
+			var pod corev1.Pod
+			err := r.Client.Get(ctx, types.NamespacedName{Name: podName, Namespace: k8sNamespace}, &pod)
+			if err == nil {
+				continue
+			}
+			if !k8serrors.IsNotFound(err) {
+				return err
+			}
+
+			key := fmt.Sprintf("%s/%s/%s", node.Node, svc.Namespace, svc.ID)
+			since, ok := o.missingSince[key]
+			if !ok {
+				since = now
+			}
+			if now.Sub(since) < o.TTL {
+				missingSince[key] = since
+				continue
+			}
+			if err := o.deregister(apiClient, node.Node, svc); err != nil {
+				// Try again on the next run.
+				missingSince[key] = since
+				o.Log.Error(err, "failed to deregister orphaned service instance", "svc", svc.ID)
+			}
+		}
+	}
+	// Instances that were deregistered or whose pods exist again are forgotten.
+	o.missingSince = missingSince
+	return nil
+}
+
+// deregister deregisters an orphaned service instance and deletes the ACL token of its pod.
+func (o *OrphanReaper) deregister(apiClient *api.Client, nodeName string, svc *api.AgentService) error {
+	r := o.Controller
+	k8sSvcName := svc.Meta[metaKeyKubeServiceName]
+	k8sNamespace := svc.Meta[constants.MetaKeyKubeNS]
+	podName := svc.Meta[constants.MetaKeyPodName]
+
+	o.Log.Info("deregistering orphaned service instance from consul", "svc", svc.ID, "pod", podName, "ns", k8sNamespace)
+	_, err := apiClient.Catalog().Deregister(&api.CatalogDeregistration{
+		Node:      nodeName,
+		ServiceID: svc.ID,
+		Namespace: svc.Namespace,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	r.cacheRemove(nodeName, svc)
+	r.recordDeregistered(k8sSvcName, k8sNamespace, svc)
+	if r.NodeProxyPorts != nil {
+		r.NodeProxyPorts.Release(nodeName, r.nodeProxyKey(svc.Namespace, svc.ID))
+	}
+	if r.AuthMethod != "" {
+		return r.deleteACLTokensForServiceInstance(apiClient, svc, k8sNamespace, podName)
+	}
+	return nil
+}
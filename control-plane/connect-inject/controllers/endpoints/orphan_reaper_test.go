@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/test"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestOrphanReaper_Reap(t *testing.T) {
+	instance := func(id, podName string) *api.AgentService {
+		return &api.AgentService{
+			ID:      id,
+			Service: "service-created",
+			Meta: map[string]string{
+				metaKeyKubeServiceName:   "service-created",
+				constants.MetaKeyKubeNS:  "default",
+				constants.MetaKeyPodName: podName,
+				metaKeyManagedBy:         constants.ManagedByValue,
+			},
+		}
+	}
+	var deregistered []string
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/catalog/nodes":
+			require.Equal(t, "synthetic-node:true", r.URL.Query().Get("node-meta"))
+			require.NoError(t, json.NewEncoder(w).Encode([]*api.Node{{Node: "lost-node-virtual"}}))
+		case "/v1/catalog/node-services/lost-node-virtual":
+			require.NoError(t, json.NewEncoder(w).Encode(&api.CatalogNodeServiceList{
+				Node: &api.Node{Node: "lost-node-virtual"},
+				Services: []*api.AgentService{
+					instance("pod1-service-created", "pod1"),
+					instance("pod2-service-created", "pod2"),
+				},
+			}))
+		case "/v1/catalog/deregister":
+			var deregistration api.CatalogDeregistration
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&deregistration))
+			require.Equal(t, "lost-node-virtual", deregistration.Node)
+			deregistered = append(deregistered, deregistration.ServiceID)
+			require.NoError(t, json.NewEncoder(w).Encode(true))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(consulServer.Close)
+	serverURL, err := url.Parse(consulServer.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(serverURL.Port())
+	require.NoError(t, err)
+
+	// Only pod2 still exists.
+	pod2 := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod2", Namespace: "default"}}
+	reaper := &OrphanReaper{
+		Controller: &Controller{
+			Client:                fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(pod2).Build(),
+			ConsulClientConfig:    &consul.Config{APIClientConfig: &api.Config{}, HTTPPort: port},
+			ConsulServerConnMgr:   test.MockConnMgrForIPAndPort(serverURL.Hostname(), 0),
+			AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+			DenyK8sNamespacesSet:  mapset.NewSetWith(),
+			Context:               context.Background(),
+		},
+		TTL: time.Minute,
+		Log: logrtest.New(t),
+	}
+
+	// The instance of the missing pod isn't deregistered until its pod has been missing for the TTL.
+	now := time.Now()
+	require.NoError(t, reaper.reap(context.Background(), now))
+	require.Empty(t, deregistered)
+	require.NoError(t, reaper.reap(context.Background(), now.Add(30*time.Second)))
+	require.Empty(t, deregistered)
+	require.NoError(t, reaper.reap(context.Background(), now.Add(time.Minute)))
+	require.Equal(t, []string{"pod1-service-created"}, deregistered)
+	require.Empty(t, reaper.missingSince)
+}
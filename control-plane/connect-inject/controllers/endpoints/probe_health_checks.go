@@ -0,0 +1,259 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/common"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	probeKindLiveness  = "liveness"
+	probeKindReadiness = "readiness"
+
+	// sidecarContainer is the name of the sidecar container injected by the mesh webhook. Multi-port pods
+	// have a sidecar container for each service, whose name has the service name as a suffix.
+	sidecarContainer = "consul-dataplane"
+)
+
+// probeHealthChecksEnabled returns whether the probes of the pod's application containers are mirrored into
+// Consul health checks. The consul.hashicorp.com/probe-health-checks annotation overrides the controller's default.
+func (r *Controller) probeHealthChecksEnabled(pod corev1.Pod) (bool, error) {
+	if raw, ok := pod.Annotations[constants.AnnotationProbeHealthChecks]; ok {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return false, fmt.Errorf("%s annotation value of %q is invalid: %w", constants.AnnotationProbeHealthChecks, raw, err)
+		}
+		return enabled, nil
+	}
+	return r.EnableProbeHealthChecks, nil
+}
+
+// probeHealthChecks returns Consul health checks that mirror the liveness and readiness probes of the pod's
+// application containers as HTTP, TCP or gRPC checks, so that consumers outside of Kubernetes see what the
+// health of the service instance is based on. Exec probes can't be mirrored and are skipped. Consul doesn't
+// run the checks since service instances are registered without client agents, so their status is the
+// status of the probes reported by the kubelet: readiness checks pass while the container is ready and
+// liveness checks pass while it's running.
+//
+// If any application container exposes the service port, only the probes of those containers are mirrored,
+// so that the service instances of multi-port pods only get the checks of their own containers.
+func probeHealthChecks(pod corev1.Pod, serviceID, consulNS string, servicePort int) api.HealthChecks {
+	var containers []corev1.Container
+	for _, c := range pod.Spec.Containers {
+		if isInjectedContainer(c.Name) {
+			continue
+		}
+		for _, p := range c.Ports {
+			if servicePort != 0 && int(p.ContainerPort) == servicePort {
+				containers = append(containers, c)
+				break
+			}
+		}
+	}
+	if len(containers) == 0 {
+		for _, c := range pod.Spec.Containers {
+			if !isInjectedContainer(c.Name) {
+				containers = append(containers, c)
+			}
+		}
+	}
+
+	var checks api.HealthChecks
+	for _, c := range containers {
+		var status *corev1.ContainerStatus
+		for i := range pod.Status.ContainerStatuses {
+			if pod.Status.ContainerStatuses[i].Name == c.Name {
+				status = &pod.Status.ContainerStatuses[i]
+			}
+		}
+		for _, probe := range []struct {
+			kind  string
+			probe *corev1.Probe
+		}{
+			{kind: probeKindLiveness, probe: c.LivenessProbe},
+			{kind: probeKindReadiness, probe: c.ReadinessProbe},
+		} {
+			if probe.probe == nil {
+				continue
+			}
+			checkType, definition, ok := probeCheckDefinition(pod, *probe.probe)
+			if !ok {
+				continue
+			}
+			healthStatus, output := probeHealthStatus(probe.kind, c.Name, status)
+			checks = append(checks, &api.HealthCheck{
+				CheckID:    fmt.Sprintf("%s/%s/%s-%s", pod.Namespace, serviceID, c.Name, probe.kind),
+				Name:       fmt.Sprintf("Kubernetes %s probe of container %s", probe.kind, c.Name),
+				Type:       checkType,
+				Status:     healthStatus,
+				Output:     output,
+				ServiceID:  serviceID,
+				Namespace:  consulNS,
+				Definition: definition,
+			})
+		}
+	}
+	return checks
+}
+
+// deregisterStaleProbeHealthChecks deregisters the checks mirroring probes that the service instance was
+// registered with before but that aren't part of its registration anymore, e.g. after the
+// consul.hashicorp.com/probe-health-checks annotation is set to false, since registering an instance in the
+// catalog doesn't remove its other checks. The checks of an instance are read from Consul the first time it's
+// registered by the controller, and remembered afterwards.
+func (r *Controller) deregisterStaleProbeHealthChecks(apiClient *api.Client, pod corev1.Pod, serviceEndpoints corev1.Endpoints, registration *api.CatalogRegistration) error {
+	k8sSvc := types.NamespacedName{Name: serviceEndpoints.Name, Namespace: serviceEndpoints.Namespace}
+	svc := registration.Service
+	var registered map[string]bool
+	r.registeredInstances.update(k8sSvc, svc.ID, pod.Name, func(instance *registeredInstance) {
+		registered = instance.probeCheckIDs
+	})
+	if registered == nil {
+		// The checks decide which checks to deregister, so they aren't read stale.
+		checks, _, err := apiClient.Health().Node(registration.Node, &api.QueryOptions{
+			Namespace: svc.Namespace,
+			Filter:    fmt.Sprintf("ServiceID == %q", svc.ID),
+		})
+		if err != nil {
+			return err
+		}
+		registered = make(map[string]bool)
+		prefix := consulHealthCheckID(pod.Namespace, svc.ID) + "/"
+		for _, check := range checks {
+			if strings.HasPrefix(check.CheckID, prefix) {
+				registered[check.CheckID] = true
+			}
+		}
+	}
+
+	current := make(map[string]bool)
+	for _, check := range registration.Checks {
+		current[check.CheckID] = true
+	}
+	for checkID := range registered {
+		if current[checkID] {
+			continue
+		}
+		r.Log.Info("deregistering probe health check from consul", "id", checkID)
+		_, err := apiClient.Catalog().Deregister(&api.CatalogDeregistration{
+			Node:      registration.Node,
+			CheckID:   checkID,
+			Namespace: svc.Namespace,
+		}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	r.registeredInstances.update(k8sSvc, svc.ID, pod.Name, func(instance *registeredInstance) {
+		instance.probeCheckIDs = current
+	})
+	return nil
+}
+
+// probeCheckDefinition returns the type and definition of the Consul health check equivalent to the probe, or
+// false if the probe has no equivalent.
+func probeCheckDefinition(pod corev1.Pod, probe corev1.Probe) (string, api.HealthCheckDefinition, bool) {
+	definition := api.HealthCheckDefinition{
+		IntervalDuration: probeSeconds(probe.PeriodSeconds, 10),
+		TimeoutDuration:  probeSeconds(probe.TimeoutSeconds, 1),
+	}
+	switch {
+	case probe.HTTPGet != nil:
+		port, ok := probePort(pod, probe.HTTPGet.Port)
+		if !ok {
+			return "", api.HealthCheckDefinition{}, false
+		}
+		host := probe.HTTPGet.Host
+		if host == "" {
+			host = pod.Status.PodIP
+		}
+		scheme := strings.ToLower(string(probe.HTTPGet.Scheme))
+		if scheme == "" {
+			scheme = "http"
+		}
+		path := probe.HTTPGet.Path
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		definition.HTTP = fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(host, port), path)
+		definition.Method = "GET"
+		// Like the kubelet, don't verify the certificates of HTTPS probes.
+		definition.TLSSkipVerify = scheme == "https"
+		for _, header := range probe.HTTPGet.HTTPHeaders {
+			if definition.Header == nil {
+				definition.Header = make(map[string][]string)
+			}
+			definition.Header[header.Name] = append(definition.Header[header.Name], header.Value)
+		}
+		return "http", definition, true
+	case probe.TCPSocket != nil:
+		port, ok := probePort(pod, probe.TCPSocket.Port)
+		if !ok {
+			return "", api.HealthCheckDefinition{}, false
+		}
+		host := probe.TCPSocket.Host
+		if host == "" {
+			host = pod.Status.PodIP
+		}
+		definition.TCP = net.JoinHostPort(host, port)
+		return "tcp", definition, true
+	case probe.GRPC != nil:
+		definition.GRPC = net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(probe.GRPC.Port)))
+		if probe.GRPC.Service != nil && *probe.GRPC.Service != "" {
+			definition.GRPC += "/" + *probe.GRPC.Service
+		}
+		return "grpc", definition, true
+	}
+	return "", api.HealthCheckDefinition{}, false
+}
+
+// probeHealthStatus returns the status and output of the check mirroring a probe from the status of its container.
+func probeHealthStatus(kind, containerName string, status *corev1.ContainerStatus) (string, string) {
+	if kind == probeKindReadiness {
+		if status != nil && status.Ready {
+			return api.HealthPassing, fmt.Sprintf("Container %q is ready", containerName)
+		}
+		return api.HealthCritical, fmt.Sprintf("Container %q is not ready", containerName)
+	}
+	if status != nil && status.State.Running != nil {
+		return api.HealthPassing, fmt.Sprintf("Container %q is running", containerName)
+	}
+	return api.HealthCritical, fmt.Sprintf("Container %q is not running", containerName)
+}
+
+// probePort returns the number of a probe's port, which may be the name of a container port.
+func probePort(pod corev1.Pod, port intstr.IntOrString) (string, bool) {
+	if port.Type == intstr.Int {
+		return strconv.Itoa(port.IntValue()), true
+	}
+	value, err := common.PortValue(pod, port.StrVal)
+	if err != nil || value <= 0 {
+		return "", false
+	}
+	return strconv.Itoa(int(value)), true
+}
+
+// probeSeconds returns the duration of a probe's seconds field, or its Kubernetes default if it's unset.
+func probeSeconds(seconds, defaultSeconds int32) time.Duration {
+	if seconds <= 0 {
+		seconds = defaultSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// isInjectedContainer returns true if name is the name of a sidecar container injected by the mesh webhook.
+func isInjectedContainer(name string) bool {
+	return name == sidecarContainer || strings.HasPrefix(name, sidecarContainer+"-")
+}
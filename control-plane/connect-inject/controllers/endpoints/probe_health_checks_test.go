@@ -0,0 +1,320 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestProbeHealthChecks(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		containers  []corev1.Container
+		statuses    []corev1.ContainerStatus
+		servicePort int
+		expChecks   api.HealthChecks
+	}{
+		"no probes": {
+			containers: []corev1.Container{{Name: "web"}},
+		},
+		"http probe with a named port and headers": {
+			containers: []corev1.Container{{
+				Name:  "web",
+				Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+				ReadinessProbe: &corev1.Probe{
+					ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{
+						Path:        "healthz",
+						Port:        intstr.FromString("http"),
+						HTTPHeaders: []corev1.HTTPHeader{{Name: "X-Probe", Value: "a"}, {Name: "X-Probe", Value: "b"}},
+					}},
+					PeriodSeconds:  5,
+					TimeoutSeconds: 2,
+				},
+			}},
+			statuses: []corev1.ContainerStatus{{Name: "web", Ready: true}},
+			expChecks: api.HealthChecks{{
+				CheckID:   "default/pod1-web/web-readiness",
+				Name:      "Kubernetes readiness probe of container web",
+				Type:      "http",
+				Status:    api.HealthPassing,
+				Output:    `Container "web" is ready`,
+				ServiceID: "pod1-web",
+				Namespace: "ns",
+				Definition: api.HealthCheckDefinition{
+					HTTP:             "http://1.2.3.4:8080/healthz",
+					Method:           "GET",
+					Header:           map[string][]string{"X-Probe": {"a", "b"}},
+					IntervalDuration: 5 * time.Second,
+					TimeoutDuration:  2 * time.Second,
+				},
+			}},
+		},
+		"https probe skips certificate verification": {
+			containers: []corev1.Container{{
+				Name: "web",
+				LivenessProbe: &corev1.Probe{
+					ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{
+						Path:   "/livez",
+						Port:   intstr.FromInt(8443),
+						Host:   "localhost",
+						Scheme: corev1.URISchemeHTTPS,
+					}},
+				},
+			}},
+			statuses: []corev1.ContainerStatus{{Name: "web", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}},
+			expChecks: api.HealthChecks{{
+				CheckID:   "default/pod1-web/web-liveness",
+				Name:      "Kubernetes liveness probe of container web",
+				Type:      "http",
+				Status:    api.HealthPassing,
+				Output:    `Container "web" is running`,
+				ServiceID: "pod1-web",
+				Namespace: "ns",
+				Definition: api.HealthCheckDefinition{
+					HTTP:             "https://localhost:8443/livez",
+					Method:           "GET",
+					TLSSkipVerify:    true,
+					IntervalDuration: 10 * time.Second,
+					TimeoutDuration:  time.Second,
+				},
+			}},
+		},
+		"tcp and grpc probes of a container that isn't running": {
+			containers: []corev1.Container{{
+				Name: "web",
+				LivenessProbe: &corev1.Probe{
+					ProbeHandler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(8080)}},
+				},
+				ReadinessProbe: &corev1.Probe{
+					ProbeHandler: corev1.ProbeHandler{GRPC: &corev1.GRPCAction{Port: 9090, Service: pointer.String("health")}},
+				},
+			}},
+			expChecks: api.HealthChecks{
+				{
+					CheckID:   "default/pod1-web/web-liveness",
+					Name:      "Kubernetes liveness probe of container web",
+					Type:      "tcp",
+					Status:    api.HealthCritical,
+					Output:    `Container "web" is not running`,
+					ServiceID: "pod1-web",
+					Namespace: "ns",
+					Definition: api.HealthCheckDefinition{
+						TCP:              "1.2.3.4:8080",
+						IntervalDuration: 10 * time.Second,
+						TimeoutDuration:  time.Second,
+					},
+				},
+				{
+					CheckID:   "default/pod1-web/web-readiness",
+					Name:      "Kubernetes readiness probe of container web",
+					Type:      "grpc",
+					Status:    api.HealthCritical,
+					Output:    `Container "web" is not ready`,
+					ServiceID: "pod1-web",
+					Namespace: "ns",
+					Definition: api.HealthCheckDefinition{
+						GRPC:             "1.2.3.4:9090/health",
+						IntervalDuration: 10 * time.Second,
+						TimeoutDuration:  time.Second,
+					},
+				},
+			},
+		},
+		"exec probes and probes with unknown named ports are skipped": {
+			containers: []corev1.Container{{
+				Name: "web",
+				LivenessProbe: &corev1.Probe{
+					ProbeHandler: corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"true"}}},
+				},
+				ReadinessProbe: &corev1.Probe{
+					ProbeHandler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromString("unknown")}},
+				},
+			}},
+		},
+		"only containers exposing the service port and no sidecars": {
+			servicePort: 8080,
+			containers: []corev1.Container{
+				{
+					Name:  "web",
+					Ports: []corev1.ContainerPort{{ContainerPort: 8080}},
+					ReadinessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(8080)}},
+					},
+				},
+				{
+					Name:  "admin",
+					Ports: []corev1.ContainerPort{{ContainerPort: 9090}},
+					ReadinessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(9090)}},
+					},
+				},
+				{
+					Name:  sidecarContainer,
+					Ports: []corev1.ContainerPort{{ContainerPort: 8080}},
+					ReadinessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(20000)}},
+					},
+				},
+			},
+			statuses: []corev1.ContainerStatus{{Name: "web", Ready: true}, {Name: "admin", Ready: true}},
+			expChecks: api.HealthChecks{{
+				CheckID:   "default/pod1-web/web-readiness",
+				Name:      "Kubernetes readiness probe of container web",
+				Type:      "tcp",
+				Status:    api.HealthPassing,
+				Output:    `Container "web" is ready`,
+				ServiceID: "pod1-web",
+				Namespace: "ns",
+				Definition: api.HealthCheckDefinition{
+					TCP:              "1.2.3.4:8080",
+					IntervalDuration: 10 * time.Second,
+					TimeoutDuration:  time.Second,
+				},
+			}},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createServicePod("pod1", "1.2.3.4", true, true)
+			pod.Spec.Containers = c.containers
+			pod.Status.ContainerStatuses = c.statuses
+			require.Equal(t, c.expChecks, probeHealthChecks(*pod, "pod1-web", "ns", c.servicePort))
+		})
+	}
+}
+
+func TestCreateServiceRegistrations_ProbeHealthChecks(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		enabled    bool
+		annotation string
+		expChecks  int
+		expErr     string
+	}{
+		"disabled": {},
+		"enabled": {
+			enabled:   true,
+			expChecks: 1,
+		},
+		"annotation enables": {
+			annotation: "true",
+			expChecks:  1,
+		},
+		"annotation disables": {
+			enabled:    true,
+			annotation: "false",
+		},
+		"invalid annotation": {
+			annotation: "yes",
+			expErr:     `consul.hashicorp.com/probe-health-checks annotation value of "yes" is invalid: strconv.ParseBool: parsing "yes": invalid syntax`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createServicePod("pod1", "1.2.3.4", true, true)
+			if c.annotation != "" {
+				pod.Annotations[constants.AnnotationProbeHealthChecks] = c.annotation
+			}
+			pod.Spec.Containers = []corev1.Container{{
+				Name: "web",
+				ReadinessProbe: &corev1.Probe{
+					ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromInt(8080)}},
+				},
+			}}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "service-created",
+					Namespace: "default",
+				},
+			}
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			epCtrl := Controller{
+				Client:                  fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, ns).Build(),
+				EnableProbeHealthChecks: c.enabled,
+				Log:                     logrtest.New(t),
+			}
+
+			serviceRegistration, _, err := epCtrl.createServiceRegistrations(*pod, *endpoints, api.HealthPassing)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, serviceRegistration.Checks, c.expChecks)
+			// The check of the pod's readiness is registered regardless.
+			require.NotNil(t, serviceRegistration.Check)
+			for _, check := range serviceRegistration.Checks {
+				require.Equal(t, serviceRegistration.Service.ID, check.ServiceID)
+				require.Equal(t, "http://1.2.3.4:8080/healthz", check.Definition.HTTP)
+			}
+		})
+	}
+}
+
+func TestDeregisterStaleProbeHealthChecks(t *testing.T) {
+	t.Parallel()
+
+	var healthReads int
+	var deregistered []string
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/health/node/k8s-node":
+			healthReads++
+			require.NoError(t, json.NewEncoder(w).Encode(api.HealthChecks{
+				{CheckID: "default/pod1-web", ServiceID: "pod1-web"},
+				{CheckID: "default/pod1-web/web-liveness", ServiceID: "pod1-web"},
+				{CheckID: "default/pod1-web/web-readiness", ServiceID: "pod1-web"},
+			}))
+		case "/v1/catalog/deregister":
+			var deregistration api.CatalogDeregistration
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&deregistration))
+			deregistered = append(deregistered, deregistration.CheckID)
+			require.NoError(t, json.NewEncoder(w).Encode(true))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(consulServer.Close)
+	apiClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+
+	ep := &Controller{Log: logrtest.New(t)}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}}
+	serviceEndpoints := corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	registration := &api.CatalogRegistration{
+		Node:    "k8s-node",
+		Service: &api.AgentService{ID: "pod1-web", Service: "web"},
+		Checks:  api.HealthChecks{{CheckID: "default/pod1-web/web-readiness", ServiceID: "pod1-web"}},
+	}
+
+	// The checks that the instance was registered with before the controller started are read from Consul.
+	require.NoError(t, ep.deregisterStaleProbeHealthChecks(apiClient, pod, serviceEndpoints, registration))
+	require.Equal(t, 1, healthReads)
+	require.Equal(t, []string{"default/pod1-web/web-liveness"}, deregistered)
+
+	// Afterwards, the checks that the controller registered are remembered.
+	deregistered = nil
+	require.NoError(t, ep.deregisterStaleProbeHealthChecks(apiClient, pod, serviceEndpoints, registration))
+	require.Empty(t, deregistered)
+
+	// Once probes are no longer mirrored, the remaining probe check is deregistered.
+	registration.Checks = nil
+	require.NoError(t, ep.deregisterStaleProbeHealthChecks(apiClient, pod, serviceEndpoints, registration))
+	require.Equal(t, []string{"default/pod1-web/web-readiness"}, deregistered)
+	require.Equal(t, 1, healthReads)
+}
@@ -0,0 +1,83 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"sync"
+
+	mapset "github.com/deckarep/golang-set"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// registeredInstances holds what the controller remembers about the service instances it registered, keyed by
+// their Kubernetes service. Instances can be deregistered without the controller seeing it, e.g. by the
+// OrphanReaper or along with their Consul namespace, so the instances of a Kubernetes service are also
+// forgotten once their pods are no longer in its Endpoints.
+type registeredInstances struct {
+	lock      sync.Mutex
+	instances map[types.NamespacedName]map[string]*registeredInstance
+}
+
+// registeredInstance is what the controller remembers about a service instance.
+type registeredInstance struct {
+	podName string
+	// eventRecorded is true once a registered event has been recorded for the instance.
+	eventRecorded bool
+	// probeCheckIDs are the IDs of the checks mirroring probes that the instance was last registered with.
+	// It's nil until they are known.
+	probeCheckIDs map[string]bool
+}
+
+// update calls f with the service instance of the pod, which is added if it isn't remembered yet.
+func (e *registeredInstances) update(k8sSvc types.NamespacedName, id, podName string, f func(instance *registeredInstance)) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.instances == nil {
+		e.instances = make(map[types.NamespacedName]map[string]*registeredInstance)
+	}
+	if e.instances[k8sSvc] == nil {
+		e.instances[k8sSvc] = make(map[string]*registeredInstance)
+	}
+	instance, ok := e.instances[k8sSvc][id]
+	if !ok || instance.podName != podName {
+		instance = &registeredInstance{podName: podName}
+		e.instances[k8sSvc][id] = instance
+	}
+	f(instance)
+}
+
+// remove forgets the service instance.
+func (e *registeredInstances) remove(k8sSvc types.NamespacedName, id string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	delete(e.instances[k8sSvc], id)
+	if len(e.instances[k8sSvc]) == 0 {
+		delete(e.instances, k8sSvc)
+	}
+}
+
+// retain forgets the service instances of the Kubernetes service whose pods aren't in pods.
+func (e *registeredInstances) retain(k8sSvc types.NamespacedName, pods mapset.Set) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for id, instance := range e.instances[k8sSvc] {
+		if !pods.Contains(instance.podName) {
+			delete(e.instances[k8sSvc], id)
+		}
+	}
+	if len(e.instances[k8sSvc]) == 0 {
+		delete(e.instances, k8sSvc)
+	}
+}
+
+// count returns the number of service instances that are remembered.
+func (e *registeredInstances) count() int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	n := 0
+	for _, ids := range e.instances {
+		n += len(ids)
+	}
+	return n
+}
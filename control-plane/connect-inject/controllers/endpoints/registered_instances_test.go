@@ -0,0 +1,45 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Test that the instances whose pods left the Endpoints of their service are forgotten, whichever
+// way they were deregistered.
+func TestRegisteredInstances_retain(t *testing.T) {
+	var instances registeredInstances
+	web := types.NamespacedName{Name: "web", Namespace: "default"}
+	apiSvc := types.NamespacedName{Name: "api", Namespace: "default"}
+	record := func(k8sSvc types.NamespacedName, id, podName string) bool {
+		var recorded bool
+		instances.update(k8sSvc, id, podName, func(instance *registeredInstance) {
+			recorded, instance.eventRecorded = instance.eventRecorded, true
+		})
+		return !recorded
+	}
+	require.True(t, record(web, "pod1-web", "pod1"))
+	require.True(t, record(web, "pod2-web", "pod2"))
+	require.True(t, record(apiSvc, "pod1-api", "pod1"))
+	require.False(t, record(web, "pod1-web", "pod1"))
+
+	instances.retain(web, mapset.NewSet("pod2"))
+	require.Equal(t, 2, instances.count())
+	require.True(t, record(web, "pod1-web", "pod1"))
+	require.False(t, record(web, "pod2-web", "pod2"))
+
+	// An instance ID that is reused by another pod is a new instance.
+	require.True(t, record(web, "pod2-web", "pod3"))
+
+	// Deleted Endpoints have no pods.
+	instances.retain(web, mapset.NewSet())
+	instances.retain(apiSvc, mapset.NewSet())
+	require.Equal(t, 0, instances.count())
+	require.Empty(t, instances.instances)
+}
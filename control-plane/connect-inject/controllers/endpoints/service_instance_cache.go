@@ -0,0 +1,344 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// defaultPartition is the name Consul uses for the default admin partition.
+	defaultPartition = "default"
+
+	// cacheRetryInterval is how long a node watch waits before retrying
+	// after a failed blocking query.
+	cacheRetryInterval = 5 * time.Second
+)
+
+// ServiceInstanceCache is a local view of the service instances registered
+// by the endpoints controller, indexed by Kubernetes service name, Kubernetes
+// namespace and Consul partition. It is kept up to date with a blocking query
+// per Consul node so that reconciles can find the instances of a Kubernetes
+// service without reading from Consul once per node.
+//
+// Registrations and deregistrations made by the controller are written
+// through to the cache so that it reflects the controller's own writes
+// before the blocking queries return. Each write is numbered, and a query
+// result only replaces the writes that were made before the query started,
+// so that a query that was in flight during a write can't undo it. The
+// queries are consistent whatever ConsulClientConfig.AllowStale is set to,
+// since a stale result could miss writes made before the query started, and
+// the controller decides which instances to deregister from the cache.
+type ServiceInstanceCache struct {
+	// ConsulClientConfig is the config for the Consul API client.
+	ConsulClientConfig *consul.Config
+	// ConsulServerConnMgr is the watcher for the Consul server addresses.
+	ConsulServerConnMgr consul.ServerConnectionManager
+	// EnableConsulNamespaces indicates that services are registered across
+	// Consul namespaces and must be queried with the wildcard namespace.
+	EnableConsulNamespaces bool
+	// WaitTime is the maximum duration of the blocking queries. Zero uses
+	// Consul's default of five minutes.
+	WaitTime time.Duration
+
+	Log logr.Logger
+
+	lock sync.RWMutex
+	// nodes is each watched Consul node name mapped to its cached state.
+	nodes map[string]*nodeWatch
+	// version is incremented on each write through Add or Remove.
+	version uint64
+}
+
+// nodeWatch is the cached state of a single Consul node.
+type nodeWatch struct {
+	cancel context.CancelFunc
+	// synced is true once the first blocking query for the node has returned.
+	// Until then lookups for the node fall back to reading from Consul.
+	synced bool
+	// instances is keyed by service and then service instance ID.
+	instances map[cacheKey]map[string]*api.AgentService
+	// writes are the writes made through Add and Remove that no query result
+	// has accounted for yet, keyed by service and service instance ID.
+	writes map[instanceKey]cachedWrite
+}
+
+// instanceKey identifies a single service instance on a node.
+type instanceKey struct {
+	cacheKey
+	id string
+}
+
+// cachedWrite is a write made through Add or Remove.
+type cachedWrite struct {
+	// svc is the registered service instance, or nil if it was deregistered.
+	svc *api.AgentService
+	// version is the cache version of the write.
+	version uint64
+}
+
+// cacheKey identifies the instances belonging to one Kubernetes service.
+type cacheKey struct {
+	k8sServiceName      string
+	k8sServiceNamespace string
+	partition           string
+}
+
+func newCacheKey(k8sServiceName, k8sServiceNamespace, partition string) cacheKey {
+	if partition == "" {
+		partition = defaultPartition
+	}
+	return cacheKey{
+		k8sServiceName:      k8sServiceName,
+		k8sServiceNamespace: k8sServiceNamespace,
+		partition:           partition,
+	}
+}
+
+// cacheKeyForService returns the key for svc and whether svc was registered
+// by the endpoints controller.
+func cacheKeyForService(svc *api.AgentService) (cacheKey, bool) {
+	if svc == nil || svc.Meta[metaKeyManagedBy] != constants.ManagedByValue {
+		return cacheKey{}, false
+	}
+	return newCacheKey(svc.Meta[metaKeyKubeServiceName], svc.Meta[constants.MetaKeyKubeNS], svc.Partition), true
+}
+
+// Watch starts a blocking query for each of the given Consul nodes that isn't
+// already being watched, and stops watching any node that is not in nodeNames.
+// Watches run until ctx is cancelled.
+func (c *ServiceInstanceCache) Watch(ctx context.Context, nodeNames []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.nodes == nil {
+		c.nodes = make(map[string]*nodeWatch)
+	}
+
+	current := make(map[string]struct{}, len(nodeNames))
+	for _, name := range nodeNames {
+		current[name] = struct{}{}
+		if _, ok := c.nodes[name]; ok {
+			continue
+		}
+		watchCtx, cancel := context.WithCancel(ctx)
+		c.nodes[name] = &nodeWatch{
+			cancel:    cancel,
+			instances: make(map[cacheKey]map[string]*api.AgentService),
+			writes:    make(map[instanceKey]cachedWrite),
+		}
+		go c.watchNode(watchCtx, name)
+	}
+
+	for name, w := range c.nodes {
+		if _, ok := current[name]; !ok {
+			w.cancel()
+			delete(c.nodes, name)
+		}
+	}
+}
+
+// Get returns the cached instances of the given Kubernetes service on a
+// Consul node. The second return value is false if the node's cache hasn't
+// been populated yet, in which case the caller should read from Consul.
+func (c *ServiceInstanceCache) Get(nodeName, k8sServiceName, k8sServiceNamespace, partition string) (*api.CatalogNodeServiceList, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	w, ok := c.nodes[nodeName]
+	if !ok || !w.synced {
+		return nil, false
+	}
+
+	list := &api.CatalogNodeServiceList{Node: &api.Node{Node: nodeName}}
+	for _, svc := range w.instances[newCacheKey(k8sServiceName, k8sServiceNamespace, partition)] {
+		list.Services = append(list.Services, svc)
+	}
+	return list, true
+}
+
+// Add records a service instance that was registered on a Consul node.
+func (c *ServiceInstanceCache) Add(nodeName string, svc *api.AgentService) {
+	key, ok := cacheKeyForService(svc)
+	if !ok {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	w, ok := c.nodes[nodeName]
+	if !ok {
+		return
+	}
+	c.version++
+	w.writes[instanceKey{key, svc.ID}] = cachedWrite{svc: svc, version: c.version}
+	w.apply(key, svc.ID, svc)
+}
+
+// Remove drops a service instance that was deregistered from a Consul node.
+func (c *ServiceInstanceCache) Remove(nodeName string, svc *api.AgentService) {
+	key, ok := cacheKeyForService(svc)
+	if !ok {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	w, ok := c.nodes[nodeName]
+	if !ok {
+		return
+	}
+	c.version++
+	w.writes[instanceKey{key, svc.ID}] = cachedWrite{version: c.version}
+	w.apply(key, svc.ID, nil)
+}
+
+// Reset stops watching all nodes and drops their cached state, so that
+// lookups read from Consul until the watches that the next calls to Watch
+// start have returned.
+func (c *ServiceInstanceCache) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for name, w := range c.nodes {
+		w.cancel()
+		delete(c.nodes, name)
+	}
+}
+
+// watchNode runs a blocking query for the services the endpoints controller
+// has registered on a Consul node and replaces the node's cached state each
+// time the query returns.
+func (c *ServiceInstanceCache) watchNode(ctx context.Context, nodeName string) {
+	filter := fmt.Sprintf(`Meta[%q] == %q`, metaKeyManagedBy, constants.ManagedByValue)
+
+	var index uint64
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		consulClient, err := consul.NewClientFromConnMgr(c.ConsulClientConfig, c.ConsulServerConnMgr)
+		if err != nil {
+			c.Log.Error(err, "failed to create Consul API client; will retry", "node", nodeName)
+			if !sleepWithContext(ctx, cacheRetryInterval) {
+				return
+			}
+			continue
+		}
+
+		// Writes made from here on may not be reflected in the query result.
+		startVersion := c.currentVersion()
+
+		opts := &api.QueryOptions{Filter: filter, WaitIndex: index, WaitTime: c.WaitTime, RequireConsistent: true}
+		if c.EnableConsulNamespaces {
+			opts.Namespace = namespaces.WildcardNamespace
+		}
+		serviceList, meta, err := consulClient.Catalog().NodeServiceList(nodeName, opts.WithContext(ctx))
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			c.Log.Error(err, "failed to query service instances; will retry", "node", nodeName)
+			if !sleepWithContext(ctx, cacheRetryInterval) {
+				return
+			}
+			continue
+		}
+
+		// Reset the index if it goes backwards, e.g. after a snapshot restore,
+		// as recommended for blocking queries.
+		if meta.LastIndex < index {
+			index = 0
+		} else {
+			index = meta.LastIndex
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		c.replace(nodeName, serviceList, startVersion)
+	}
+}
+
+// currentVersion returns the version of the latest write.
+func (c *ServiceInstanceCache) currentVersion() uint64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.version
+}
+
+// replace sets the cached state of a node to the result of a consistent query
+// that was started at startVersion. The result reflects the writes made before
+// the query started. Writes made after it started are applied on top of the
+// result, since the query may have been served before them.
+func (c *ServiceInstanceCache) replace(nodeName string, serviceList *api.CatalogNodeServiceList, startVersion uint64) {
+	instances := make(map[cacheKey]map[string]*api.AgentService)
+	if serviceList != nil {
+		for _, svc := range serviceList.Services {
+			key, ok := cacheKeyForService(svc)
+			if !ok {
+				continue
+			}
+			if instances[key] == nil {
+				instances[key] = make(map[string]*api.AgentService)
+			}
+			instances[key][svc.ID] = svc
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// The node may have stopped being watched while the query was in flight.
+	w, ok := c.nodes[nodeName]
+	if !ok {
+		return
+	}
+	w.instances = instances
+	for k, write := range w.writes {
+		if write.version <= startVersion {
+			delete(w.writes, k)
+			continue
+		}
+		w.apply(k.cacheKey, k.id, write.svc)
+	}
+	w.synced = true
+}
+
+// apply sets the cached instance with the given ID, or removes it if svc is nil.
+func (w *nodeWatch) apply(key cacheKey, id string, svc *api.AgentService) {
+	if svc == nil {
+		delete(w.instances[key], id)
+		if len(w.instances[key]) == 0 {
+			delete(w.instances, key)
+		}
+		return
+	}
+	if w.instances[key] == nil {
+		w.instances[key] = make(map[string]*api.AgentService)
+	}
+	w.instances[key][id] = svc
+}
+
+// sleepWithContext waits for d and returns false if ctx is cancelled first.
+func sleepWithContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
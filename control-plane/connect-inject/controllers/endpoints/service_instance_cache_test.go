@@ -0,0 +1,404 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/common"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/test"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/hashicorp/go-bexpr"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestServiceInstanceCache_Watch(t *testing.T) {
+	t.Parallel()
+
+	instance := cacheTestService("web-abc", "web", "default", "")
+
+	// Serve the node's services once, then block like Consul would until
+	// the watch is cancelled.
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/catalog/node-services/node-a" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("index") != "" {
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("X-Consul-Index", "10")
+		require.NoError(t, json.NewEncoder(w).Encode(api.CatalogNodeServiceList{
+			Node:     &api.Node{Node: "node-a"},
+			Services: []*api.AgentService{instance},
+		}))
+	}))
+	defer consulServer.Close()
+
+	serverURL, err := url.Parse(consulServer.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(serverURL.Port())
+	require.NoError(t, err)
+
+	cache := &ServiceInstanceCache{
+		ConsulClientConfig: &consul.Config{
+			APIClientConfig: &api.Config{},
+			HTTPPort:        port,
+		},
+		ConsulServerConnMgr: test.MockConnMgrForIPAndPort(serverURL.Hostname(), 0),
+		Log:                 logrtest.New(t),
+	}
+
+	// Lookups fall back to Consul until the first query has returned.
+	_, ok := cache.Get("node-a", "web", "default", "")
+	require.False(t, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache.Watch(ctx, []string{"node-a"})
+
+	retry.Run(t, func(r *retry.R) {
+		list, ok := cache.Get("node-a", "web", "default", "")
+		require.True(r, ok)
+		require.Len(r, list.Services, 1)
+		require.Equal(r, "web-abc", list.Services[0].ID)
+		require.Equal(r, "node-a", list.Node.Node)
+	})
+
+	// Nodes that are no longer present stop being watched.
+	cache.Watch(ctx, nil)
+	_, ok = cache.Get("node-a", "web", "default", "")
+	require.False(t, ok)
+
+	// Reset drops the cached state until the node is watched again.
+	cache.Watch(ctx, []string{"node-a"})
+	retry.Run(t, func(r *retry.R) {
+		_, ok := cache.Get("node-a", "web", "default", "")
+		require.True(r, ok)
+	})
+	cache.Reset()
+	_, ok = cache.Get("node-a", "web", "default", "")
+	require.False(t, ok)
+	cache.Watch(ctx, []string{"node-a"})
+	retry.Run(t, func(r *retry.R) {
+		_, ok := cache.Get("node-a", "web", "default", "")
+		require.True(r, ok)
+	})
+}
+
+func TestServiceInstanceCache_queryOptions(t *testing.T) {
+	t.Parallel()
+
+	queries := make(chan url.Values, 1)
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("index") != "" {
+			<-r.Context().Done()
+			return
+		}
+		queries <- r.URL.Query()
+		w.Header().Set("X-Consul-Index", "10")
+		require.NoError(t, json.NewEncoder(w).Encode(api.CatalogNodeServiceList{Node: &api.Node{Node: "node-a"}}))
+	}))
+	defer consulServer.Close()
+
+	serverURL, err := url.Parse(consulServer.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(serverURL.Port())
+	require.NoError(t, err)
+
+	cache := &ServiceInstanceCache{
+		ConsulClientConfig: &consul.Config{
+			APIClientConfig: &api.Config{},
+			HTTPPort:        port,
+			AllowStale:      true,
+		},
+		ConsulServerConnMgr: test.MockConnMgrForIPAndPort(serverURL.Hostname(), 0),
+		WaitTime:            30 * time.Second,
+		Log:                 logrtest.New(t),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache.Watch(ctx, []string{"node-a"})
+
+	select {
+	case query := <-queries:
+		require.Equal(t, "30000ms", query.Get("wait"))
+		// The cache's queries are consistent even if stale reads are allowed.
+		require.True(t, query.Has("consistent"))
+		require.False(t, query.Has("stale"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the blocking query")
+	}
+}
+
+func TestServiceInstanceCache_AddRemove(t *testing.T) {
+	t.Parallel()
+
+	cache := newSyncedCache("node-a")
+
+	web := cacheTestService("web-abc", "web", "default", "")
+	cache.Add("node-a", web)
+	// Services not managed by the endpoints controller are ignored.
+	unmanaged := cacheTestService("db-abc", "db", "default", "")
+	unmanaged.Meta[metaKeyManagedBy] = "other"
+	cache.Add("node-a", unmanaged)
+	// Services on nodes that aren't watched are ignored.
+	cache.Add("node-b", cacheTestService("web-def", "web", "default", ""))
+
+	list, ok := cache.Get("node-a", "web", "default", "")
+	require.True(t, ok)
+	require.Len(t, list.Services, 1)
+
+	list, ok = cache.Get("node-a", "db", "default", "")
+	require.True(t, ok)
+	require.Empty(t, list.Services)
+
+	_, ok = cache.Get("node-b", "web", "default", "")
+	require.False(t, ok)
+
+	cache.Remove("node-a", web)
+	list, ok = cache.Get("node-a", "web", "default", "")
+	require.True(t, ok)
+	require.Empty(t, list.Services)
+}
+
+func TestServiceInstanceCache_replaceKeepsLaterWrites(t *testing.T) {
+	t.Parallel()
+
+	cache := newSyncedCache("node-a")
+	stale := cacheTestService("web-stale", "web", "default", "")
+	cache.replace("node-a", &api.CatalogNodeServiceList{
+		Services: []*api.AgentService{stale},
+	}, 0)
+
+	// A query starts, and the controller registers one instance and
+	// deregisters another before the query returns without them.
+	startVersion := cache.currentVersion()
+	added := cacheTestService("web-added", "web", "default", "")
+	cache.Add("node-a", added)
+	cache.Remove("node-a", stale)
+	cache.replace("node-a", &api.CatalogNodeServiceList{
+		Services: []*api.AgentService{stale},
+	}, startVersion)
+
+	list, ok := cache.Get("node-a", "web", "default", "")
+	require.True(t, ok)
+	require.Len(t, list.Services, 1)
+	require.Equal(t, "web-added", list.Services[0].ID)
+
+	// A query started after the writes replaces them.
+	cache.replace("node-a", &api.CatalogNodeServiceList{
+		Services: []*api.AgentService{stale},
+	}, cache.currentVersion())
+
+	list, ok = cache.Get("node-a", "web", "default", "")
+	require.True(t, ok)
+	require.Len(t, list.Services, 1)
+	require.Equal(t, "web-stale", list.Services[0].ID)
+	require.Empty(t, cache.nodes["node-a"].writes)
+}
+
+func TestServiceInstanceCache_partitions(t *testing.T) {
+	t.Parallel()
+
+	cache := newSyncedCache("node-a")
+	cache.replace("node-a", &api.CatalogNodeServiceList{
+		Services: []*api.AgentService{
+			cacheTestService("web-default", "web", "default", "default"),
+			cacheTestService("web-foo", "web", "default", "foo"),
+		},
+	}, 0)
+
+	cases := map[string]struct {
+		partition string
+		expID     string
+	}{
+		"empty partition is the default partition": {partition: "", expID: "web-default"},
+		"default partition":                        {partition: "default", expID: "web-default"},
+		"non-default partition":                    {partition: "foo", expID: "web-foo"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			list, ok := cache.Get("node-a", "web", "default", c.partition)
+			require.True(t, ok)
+			require.Len(t, list.Services, 1)
+			require.Equal(t, c.expID, list.Services[0].ID)
+		})
+	}
+}
+
+// BenchmarkServiceInstanceCache_Get measures looking up one service's
+// instances on a node with 10k registered service instances.
+func BenchmarkServiceInstanceCache_Get(b *testing.B) {
+	cache := newSyncedCache("node-a")
+	list := &api.CatalogNodeServiceList{}
+	for i := 0; i < 10000; i++ {
+		name := fmt.Sprintf("service-%d", i)
+		list.Services = append(list.Services, cacheTestService(name+"-abc", name, "default", ""))
+	}
+	cache.replace("node-a", list, 0)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := cache.Get("node-a", "service-5000", "default", ""); !ok {
+			b.Fatal("expected cache to be synced")
+		}
+	}
+}
+
+// BenchmarkServiceInstancesForK8sNodes measures looking up the instances of one
+// Kubernetes service with 10,000 services registered across 50 nodes, with and
+// without the service instance cache. consul-reads/op is the number of requests
+// made to Consul for each lookup.
+func BenchmarkServiceInstancesForK8sNodes(b *testing.B) {
+	const numNodes, numServices = 50, 10000
+
+	var k8sObjects []runtime.Object
+	nodeServices := make(map[string][]*api.AgentService)
+	for i := 0; i < numNodes; i++ {
+		name := fmt.Sprintf("node-%d", i)
+		k8sObjects = append(k8sObjects, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	for i := 0; i < numServices; i++ {
+		name := fmt.Sprintf("service-%d", i)
+		nodeName := common.ConsulNodeNameFromK8sNode(fmt.Sprintf("node-%d", i%numNodes))
+		nodeServices[nodeName] = append(nodeServices[nodeName], cacheTestService(name+"-abc", name, "default", ""))
+	}
+
+	// Serve each node's services with the query's filter applied, and block
+	// blocking queries like Consul would until the watch is cancelled.
+	var reads atomic.Int64
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("index") != "" {
+			<-r.Context().Done()
+			return
+		}
+		reads.Add(1)
+		nodeName := strings.TrimPrefix(r.URL.Path, "/v1/catalog/node-services/")
+		list := api.CatalogNodeServiceList{Node: &api.Node{Node: nodeName}}
+		eval, err := bexpr.CreateEvaluator(r.URL.Query().Get("filter"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, svc := range nodeServices[nodeName] {
+			if match, err := eval.Evaluate(svc); err == nil && match {
+				list.Services = append(list.Services, svc)
+			}
+		}
+		w.Header().Set("X-Consul-Index", "10")
+		_ = json.NewEncoder(w).Encode(list)
+	}))
+	defer consulServer.Close()
+
+	serverURL, err := url.Parse(consulServer.URL)
+	require.NoError(b, err)
+	port, err := strconv.Atoi(serverURL.Port())
+	require.NoError(b, err)
+	consulClientConfig := &consul.Config{
+		APIClientConfig: &api.Config{},
+		HTTPPort:        port,
+	}
+	apiClient, err := api.NewClient(&api.Config{Address: serverURL.Host})
+	require.NoError(b, err)
+
+	for _, useCache := range []bool{false, true} {
+		name := "consul"
+		if useCache {
+			name = "cache"
+		}
+		b.Run(name, func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			r := &Controller{
+				Client:             fake.NewClientBuilder().WithRuntimeObjects(k8sObjects...).Build(),
+				Context:            ctx,
+				ConsulClientConfig: consulClientConfig,
+			}
+			if useCache {
+				r.ServiceInstanceCache = &ServiceInstanceCache{
+					ConsulClientConfig:  consulClientConfig,
+					ConsulServerConnMgr: test.MockConnMgrForIPAndPort(serverURL.Hostname(), 0),
+					Log:                 logr.Discard(),
+				}
+				// Wait for the watches to populate the cache.
+				_, err := r.serviceInstancesForK8sNodes(apiClient, "service-0", "default")
+				require.NoError(b, err)
+				retry.Run(b, func(rt *retry.R) {
+					for i := 0; i < numNodes; i++ {
+						_, ok := r.ServiceInstanceCache.Get(common.ConsulNodeNameFromK8sNode(fmt.Sprintf("node-%d", i)), "service-0", "default", "")
+						require.True(rt, ok)
+					}
+				})
+			}
+
+			reads.Store(0)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				serviceList, err := r.serviceInstancesForK8sNodes(apiClient, fmt.Sprintf("service-%d", i%numServices), "default")
+				if err != nil {
+					b.Fatal(err)
+				}
+				instances := 0
+				for _, nodeServices := range serviceList {
+					instances += len(nodeServices.Services)
+				}
+				if instances != 1 {
+					b.Fatalf("expected 1 instance, got %d", instances)
+				}
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(reads.Load())/float64(b.N), "consul-reads/op")
+		})
+	}
+}
+
+// newSyncedCache returns a cache that treats the given nodes as watched and
+// populated without starting any blocking queries.
+func newSyncedCache(nodeNames ...string) *ServiceInstanceCache {
+	cache := &ServiceInstanceCache{nodes: make(map[string]*nodeWatch)}
+	for _, name := range nodeNames {
+		cache.nodes[name] = &nodeWatch{
+			cancel:    func() {},
+			synced:    true,
+			instances: make(map[cacheKey]map[string]*api.AgentService),
+			writes:    make(map[instanceKey]cachedWrite),
+		}
+	}
+	return cache
+}
+
+func cacheTestService(id, k8sServiceName, k8sServiceNamespace, partition string) *api.AgentService {
+	return &api.AgentService{
+		ID:        id,
+		Service:   k8sServiceName,
+		Partition: partition,
+		Meta: map[string]string{
+			metaKeyManagedBy:        constants.ManagedByValue,
+			metaKeyKubeServiceName:  k8sServiceName,
+			constants.MetaKeyKubeNS: k8sServiceNamespace,
+		},
+	}
+}
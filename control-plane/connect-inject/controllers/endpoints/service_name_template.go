@@ -0,0 +1,69 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package endpoints
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// serviceNameTemplateData is the data that service name templates are rendered with.
+type serviceNameTemplateData struct {
+	// Name is the name of the Kubernetes service.
+	Name string
+	// Namespace is the Kubernetes namespace of the pod.
+	Namespace string
+	// Deployment is the name of the deployment that owns the pod, if any.
+	Deployment string
+	// ServiceAccount is the name of the service account of the pod.
+	ServiceAccount string
+	// Labels and Annotations are the labels and annotations of the pod.
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// ParseServiceNameTemplate parses a template for the Consul service names of pods,
+// e.g. "{{ .Deployment }}-{{ .Namespace }}". Labels and annotations that a pod
+// doesn't have render as empty strings.
+func ParseServiceNameTemplate(raw string) (*template.Template, error) {
+	return template.New("").Option("missingkey=zero").Parse(raw)
+}
+
+// renderServiceName renders the template with the pod and the Kubernetes service
+// of the endpoints.
+func renderServiceName(tmpl *template.Template, pod corev1.Pod, serviceEndpoints corev1.Endpoints) (string, error) {
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, serviceNameTemplateData{
+		Name:           serviceEndpoints.Name,
+		Namespace:      pod.Namespace,
+		Deployment:     deploymentName(pod),
+		ServiceAccount: pod.Spec.ServiceAccountName,
+		Labels:         pod.Labels,
+		Annotations:    pod.Annotations,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// deploymentName returns the name of the deployment that owns the pod through a replica set.
+// The replica set is named after the deployment with the pod template hash as a suffix, so
+// the deployment doesn't need to be looked up.
+func deploymentName(pod corev1.Pod) string {
+	hash := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+	if hash == "" {
+		return ""
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "ReplicaSet" && strings.HasSuffix(owner.Name, "-"+hash) {
+			return strings.TrimSuffix(owner.Name, "-"+hash)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,523 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package externalservices
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/terminatinggateway"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// externalNodeName is the Consul node that external services are registered on.
+	externalNodeName = "k8s-external-services"
+	// externalNodeAddress is the address of the external node. The address of each
+	// service is the external host it represents.
+	externalNodeAddress = "127.0.0.1"
+
+	metaKeyManagedBy = "managed-by"
+	managedByValue   = "consul-k8s-external-services-controller"
+
+	// defaultProtocol is the protocol of external services without the mesh-egress-protocol annotation.
+	defaultProtocol = "tcp"
+)
+
+// Controller lets applications in the mesh reach hosts outside of it through a terminating gateway.
+// Kubernetes services of type ExternalName with the consul.hashicorp.com/mesh-egress-gateway
+// annotation and at least one port are registered as Consul services on a node for external
+// services, with the external name as their address. Unless Consul namespaces are mirrored, the
+// Consul service is named <name>-<namespace>, so that services with the same name in different
+// Kubernetes namespaces don't merge. The controller also creates a ServiceDefaults resource with
+// the protocol of each service and links the service to the TerminatingGateway resource in the
+// annotation, which is created in the release namespace if it doesn't exist. With ACLs enabled, the
+// ACL role of the gateway's token is granted service:write on the service. Upstreams then dial the
+// service through the mesh with mTLS, and the gateway forwards the connections to the host.
+//
+// The linked services that the controller added are recorded in an annotation on the
+// TerminatingGateway resource, so that entries written by users and by other controllers are left
+// as they are. A ServiceDefaults resource that already exists and isn't owned by the Kubernetes
+// service is left as it is as well.
+type Controller struct {
+	client.Client
+	// ConsulClientConfig is the config for the Consul API client.
+	ConsulClientConfig *consul.Config
+	// ConsulServerConnMgr is the watcher for the Consul server addresses.
+	ConsulServerConnMgr consul.ServerConnectionManager
+	// ReleaseNamespace is the namespace that TerminatingGateway resources are created in.
+	ReleaseNamespace string
+	// EnableConsulNamespaces indicates that a user is running Consul Enterprise
+	// with version 1.7+ which supports namespaces.
+	EnableConsulNamespaces bool
+	// ConsulDestinationNamespace is the name of the Consul namespace to register all
+	// services into if mirroring is disabled.
+	ConsulDestinationNamespace string
+	// EnableNSMirroring causes Consul namespaces to be created to match the
+	// k8s namespace of any service being registered into Consul.
+	EnableNSMirroring bool
+	// NSMirroringPrefix works with EnableNSMirroring to add a prefix to the
+	// Consul namespace of the service.
+	NSMirroringPrefix string
+	// CrossNSACLPolicy is the name of the ACL policy to attach to
+	// any created Consul namespaces to allow cross namespace service discovery.
+	CrossNSACLPolicy string
+	// EnableACLs grants the ACL roles of the terminating gateways' tokens service:write on the
+	// services linked to them.
+	EnableACLs bool
+	// ResourcePrefix is the prefix of the ACL role names that server-acl-init creates for the
+	// terminating gateways.
+	ResourcePrefix string
+	// Log is the logger for this controller.
+	Log logr.Logger
+}
+
+// Reconcile registers or deregisters the external service of the Kubernetes service, and updates the
+// TerminatingGateway resources from the annotations of all services.
+func (r *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var svc corev1.Service
+	err := r.Client.Get(ctx, req.NamespacedName, &svc)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		r.Log.Error(err, "failed to get service", "name", req.Name, "ns", req.Namespace)
+		return ctrl.Result{}, err
+	}
+	egress := err == nil && isMeshEgress(svc)
+	if err == nil && !egress && hasNoPorts(svc) {
+		r.Log.Info("external service has no ports, not registering it", "name", svc.Name, "ns", svc.Namespace)
+	}
+
+	serverState, err := r.ConsulServerConnMgr.State()
+	if err != nil {
+		r.Log.Error(err, "failed to get Consul server state", "name", req.Name, "ns", req.Namespace)
+		return ctrl.Result{}, err
+	}
+	apiClient, err := consul.NewClientFromConnMgrState(r.ConsulClientConfig, serverState)
+	if err != nil {
+		r.Log.Error(err, "failed to create Consul API client", "name", req.Name, "ns", req.Namespace)
+		return ctrl.Result{}, err
+	}
+
+	if egress {
+		if err := r.registerExternalService(apiClient, svc); err != nil {
+			return ctrl.Result{}, err
+		}
+	} else if err := r.deregisterExternalService(apiClient, req.Name, req.Namespace); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.syncServiceDefaults(ctx, req.NamespacedName, svc, egress); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.syncTerminatingGateways(ctx, apiClient); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *Controller) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("external-services").
+		For(&corev1.Service{}).
+		Watches(
+			&source.Kind{Type: &v1alpha1.TerminatingGateway{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForTerminatingGateway),
+		).Complete(r)
+}
+
+// requestsForTerminatingGateway maps a TerminatingGateway resource to the services that are linked
+// to it through their annotation, so that entries that were removed from it are added back.
+func (r *Controller) requestsForTerminatingGateway(object client.Object) []ctrl.Request {
+	var serviceList corev1.ServiceList
+	if err := r.Client.List(context.Background(), &serviceList); err != nil {
+		r.Log.Error(err, "failed to list services")
+		return nil
+	}
+	var requests []ctrl.Request
+	for _, svc := range serviceList.Items {
+		if isMeshEgress(svc) && svc.Annotations[constants.AnnotationMeshEgressGateway] == object.GetName() {
+			requests = append(requests, ctrl.Request{NamespacedName: types.NamespacedName{Name: svc.Name, Namespace: svc.Namespace}})
+		}
+	}
+	return requests
+}
+
+// registerExternalService registers the external host of the service in Consul.
+func (r *Controller) registerExternalService(apiClient *api.Client, svc corev1.Service) error {
+	consulNS := r.consulNamespace(svc.Namespace)
+	if r.EnableConsulNamespaces {
+		if _, err := namespaces.EnsureExists(apiClient, consulNS, r.CrossNSACLPolicy); err != nil {
+			r.Log.Error(err, "failed to create Consul namespace", "name", consulNS)
+			return err
+		}
+	}
+
+	registration := &api.CatalogRegistration{
+		Node:     externalNodeName,
+		Address:  externalNodeAddress,
+		NodeMeta: map[string]string{"external-node": "true", "external-probe": "false"},
+		Service: &api.AgentService{
+			ID:      serviceID(svc.Name, svc.Namespace),
+			Service: r.consulServiceName(svc.Name, svc.Namespace),
+			Address: svc.Spec.ExternalName,
+			Port:    int(svc.Spec.Ports[0].Port),
+			Meta: map[string]string{
+				constants.MetaKeyKubeServiceName: svc.Name,
+				constants.MetaKeyKubeNS:          svc.Namespace,
+				metaKeyManagedBy:                 managedByValue,
+			},
+			Namespace: consulNS,
+		},
+	}
+	r.Log.Info("registering external service", "name", svc.Name, "ns", svc.Namespace, "address", svc.Spec.ExternalName)
+	if _, err := apiClient.Catalog().Register(registration, nil); err != nil {
+		r.Log.Error(err, "failed to register external service", "name", svc.Name, "ns", svc.Namespace)
+		return err
+	}
+	return nil
+}
+
+// deregisterExternalService deregisters the external services that were registered for the
+// Kubernetes service, if any.
+func (r *Controller) deregisterExternalService(apiClient *api.Client, k8sServiceName, k8sServiceNamespace string) error {
+	opts := &api.QueryOptions{
+		Filter: fmt.Sprintf(`Meta[%q] == %q and Meta[%q] == %q and Meta[%q] == %q`,
+			constants.MetaKeyKubeServiceName, k8sServiceName, constants.MetaKeyKubeNS, k8sServiceNamespace, metaKeyManagedBy, managedByValue),
+	}
+	if r.EnableConsulNamespaces {
+		opts.Namespace = namespaces.WildcardNamespace
+	}
+	serviceList, _, err := apiClient.Catalog().NodeServiceList(externalNodeName, opts)
+	if err != nil {
+		r.Log.Error(err, "failed to get external services", "name", k8sServiceName, "ns", k8sServiceNamespace)
+		return err
+	}
+	if serviceList == nil {
+		return nil
+	}
+	for _, svc := range serviceList.Services {
+		r.Log.Info("deregistering external service", "id", svc.ID)
+		_, err := apiClient.Catalog().Deregister(&api.CatalogDeregistration{
+			Node:      externalNodeName,
+			ServiceID: svc.ID,
+			Namespace: svc.Namespace,
+		}, nil)
+		if err != nil {
+			r.Log.Error(err, "failed to deregister external service", "id", svc.ID)
+			return err
+		}
+	}
+	return nil
+}
+
+// syncServiceDefaults creates or updates the ServiceDefaults resource of an external service with its
+// protocol, and deletes it once the service no longer egresses through the mesh. The resource has the
+// name of the Consul service and is owned by the Kubernetes service, so it's garbage collected when
+// the service is deleted.
+func (r *Controller) syncServiceDefaults(ctx context.Context, svcName types.NamespacedName, svc corev1.Service, egress bool) error {
+	name := types.NamespacedName{Namespace: svcName.Namespace, Name: r.consulServiceName(svcName.Name, svcName.Namespace)}
+	var serviceDefaults v1alpha1.ServiceDefaults
+	err := r.Client.Get(ctx, name, &serviceDefaults)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		r.Log.Error(err, "failed to get ServiceDefaults resource", "name", name.Name, "ns", name.Namespace)
+		return err
+	}
+	exists := err == nil
+	owned := exists && egress && isOwnedBy(serviceDefaults, svc)
+
+	switch {
+	case !egress:
+		if !exists || !hasOwnerNamed(serviceDefaults, svcName.Name) {
+			return nil
+		}
+		r.Log.Info("deleting ServiceDefaults resource of external service", "name", name.Name, "ns", name.Namespace)
+		err = r.Client.Delete(ctx, &serviceDefaults)
+	case !exists:
+		serviceDefaults = v1alpha1.ServiceDefaults{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name.Name,
+				Namespace: svc.Namespace,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "v1",
+					Kind:       "Service",
+					Name:       svc.Name,
+					UID:        svc.UID,
+				}},
+			},
+			Spec: v1alpha1.ServiceDefaultsSpec{Protocol: protocol(svc)},
+		}
+		r.Log.Info("creating ServiceDefaults resource of external service", "name", svc.Name, "ns", svc.Namespace)
+		err = r.Client.Create(ctx, &serviceDefaults)
+	case !owned:
+		r.Log.Info("ServiceDefaults resource already exists, ignoring the protocol of the external service", "name", svc.Name, "ns", svc.Namespace)
+		return nil
+	case serviceDefaults.Spec.Protocol != protocol(svc):
+		serviceDefaults.Spec.Protocol = protocol(svc)
+		r.Log.Info("updating ServiceDefaults resource of external service", "name", svc.Name, "ns", svc.Namespace)
+		err = r.Client.Update(ctx, &serviceDefaults)
+	}
+	if err != nil && !k8serrors.IsNotFound(err) {
+		r.Log.Error(err, "failed to save ServiceDefaults resource", "name", name.Name, "ns", name.Namespace)
+		return err
+	}
+	return nil
+}
+
+// syncTerminatingGateways updates the TerminatingGateway resources, and the ACL roles of their tokens
+// if ACLs are enabled, from the mesh-egress-gateway annotations of all services.
+func (r *Controller) syncTerminatingGateways(ctx context.Context, apiClient *api.Client) error {
+	var serviceList corev1.ServiceList
+	if err := r.Client.List(ctx, &serviceList); err != nil {
+		r.Log.Error(err, "failed to list services")
+		return err
+	}
+	desired := make(map[string]map[string]v1alpha1.LinkedService)
+	for _, svc := range serviceList.Items {
+		if !isMeshEgress(svc) {
+			continue
+		}
+		gateway := svc.Annotations[constants.AnnotationMeshEgressGateway]
+		linked := v1alpha1.LinkedService{
+			Name:      r.consulServiceName(svc.Name, svc.Namespace),
+			Namespace: r.consulNamespace(svc.Namespace),
+		}
+		if caFile := svc.Annotations[constants.AnnotationMeshEgressCAFile]; caFile != "" {
+			linked.CAFile = caFile
+			linked.SNI = svc.Spec.ExternalName
+		}
+		if desired[gateway] == nil {
+			desired[gateway] = make(map[string]v1alpha1.LinkedService)
+		}
+		desired[gateway][terminatinggateway.Key(linked)] = linked
+	}
+
+	linker := &terminatinggateway.Linker{
+		Client:            r.Client,
+		ManagedAnnotation: constants.AnnotationManagedLinkedServices,
+		Log:               r.Log,
+	}
+	names, err := linker.Sync(ctx, desired, func(string) string { return r.ReleaseNamespace })
+	if err != nil {
+		return err
+	}
+	if r.EnableACLs {
+		for _, name := range names {
+			if err := r.syncGatewayPolicies(apiClient, name, desired[name]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// syncGatewayPolicies grants the ACL role of the terminating gateway's token service:write on the
+// services that were linked to the gateway from annotations, which the gateway needs to serve them,
+// and revokes it for the services that were unlinked. Each service is granted with its own policy.
+// Gateways without a role, e.g. ones that weren't installed by the Helm chart, are skipped.
+func (r *Controller) syncGatewayPolicies(apiClient *api.Client, gateway string, desired map[string]v1alpha1.LinkedService) error {
+	role, err := r.gatewayRole(apiClient, gateway)
+	if err != nil {
+		r.Log.Error(err, "failed to read ACL role of terminating gateway", "gateway", gateway)
+		return err
+	}
+	if role == nil {
+		if len(desired) > 0 {
+			r.Log.Info("terminating gateway has no ACL role, not granting it access to external services", "gateway", gateway)
+		}
+		return nil
+	}
+
+	wanted := make(map[string]v1alpha1.LinkedService, len(desired))
+	for _, linked := range desired {
+		wanted[gatewayPolicyName(gateway, linked)] = linked
+	}
+	var links []*api.ACLRolePolicyLink
+	var revoked []string
+	granted := make(map[string]bool)
+	for _, link := range role.Policies {
+		if isGatewayPolicy(gateway, link.Name) {
+			if _, ok := wanted[link.Name]; !ok {
+				revoked = append(revoked, link.ID)
+				continue
+			}
+			granted[link.Name] = true
+		}
+		links = append(links, link)
+	}
+	var grants []string
+	for name := range wanted {
+		if !granted[name] {
+			grants = append(grants, name)
+		}
+	}
+	sort.Strings(grants)
+	if len(grants) == 0 && len(revoked) == 0 {
+		return nil
+	}
+
+	for _, name := range grants {
+		policy, err := r.ensureGatewayPolicy(apiClient, name, wanted[name])
+		if err != nil {
+			r.Log.Error(err, "failed to save ACL policy for external service", "policy", name)
+			return err
+		}
+		links = append(links, &api.ACLRolePolicyLink{ID: policy.ID, Name: policy.Name})
+	}
+	role.Policies = links
+	r.Log.Info("updating ACL role of terminating gateway for external services", "role", role.Name)
+	if _, _, err := apiClient.ACL().RoleUpdate(role, nil); err != nil {
+		r.Log.Error(err, "failed to update ACL role of terminating gateway", "role", role.Name)
+		return err
+	}
+	for _, id := range revoked {
+		if _, err := apiClient.ACL().PolicyDelete(id, nil); err != nil {
+			r.Log.Error(err, "failed to delete ACL policy for external service", "id", id)
+			return err
+		}
+	}
+	return nil
+}
+
+// gatewayRole returns the ACL role of the terminating gateway's token, or nil if it doesn't exist.
+// server-acl-init names it <resource prefix>-<gateway>-acl-role, with the datacenter appended in
+// secondary datacenters.
+func (r *Controller) gatewayRole(apiClient *api.Client, gateway string) (*api.ACLRole, error) {
+	name := fmt.Sprintf("%s-%s-acl-role", r.ResourcePrefix, gateway)
+	role, _, err := apiClient.ACL().RoleReadByName(name, nil)
+	if err != nil || role != nil {
+		return role, err
+	}
+	if dc := r.ConsulClientConfig.APIClientConfig.Datacenter; dc != "" {
+		role, _, err = apiClient.ACL().RoleReadByName(name+"-"+dc, nil)
+	}
+	return role, err
+}
+
+// ensureGatewayPolicy creates or updates the policy that grants service:write on the linked service.
+func (r *Controller) ensureGatewayPolicy(apiClient *api.Client, name string, linked v1alpha1.LinkedService) (*api.ACLPolicy, error) {
+	rules := r.gatewayPolicyRules(linked)
+	policy, _, err := apiClient.ACL().PolicyReadByName(name, nil)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		policy, _, err = apiClient.ACL().PolicyCreate(&api.ACLPolicy{
+			Name:        name,
+			Description: fmt.Sprintf("Allows a terminating gateway to serve the external service %s", terminatinggateway.Key(linked)),
+			Rules:       rules,
+		}, nil)
+		return policy, err
+	}
+	if policy.Rules != rules {
+		policy.Rules = rules
+		policy, _, err = apiClient.ACL().PolicyUpdate(policy, nil)
+	}
+	return policy, err
+}
+
+// gatewayPolicyRules returns the rules that grant service:write on the linked service.
+func (r *Controller) gatewayPolicyRules(linked v1alpha1.LinkedService) string {
+	rules := fmt.Sprintf("service %q {\n  policy = \"write\"\n}", linked.Name)
+	if r.EnableConsulNamespaces {
+		rules = fmt.Sprintf("namespace %q {\n%s\n}", linked.Namespace, rules)
+	}
+	if partition := r.ConsulClientConfig.APIClientConfig.Partition; partition != "" {
+		rules = fmt.Sprintf("partition %q {\n%s\n}", partition, rules)
+	}
+	return rules
+}
+
+// consulServiceName returns the name of the Consul service of an external service. Without
+// namespace mirroring, services from all Kubernetes namespaces share a Consul namespace, so the
+// Kubernetes namespace is appended to the name.
+func (r *Controller) consulServiceName(name, k8sNamespace string) string {
+	if r.EnableConsulNamespaces && r.EnableNSMirroring {
+		return name
+	}
+	return fmt.Sprintf("%s-%s", name, k8sNamespace)
+}
+
+func (r *Controller) consulNamespace(k8sNamespace string) string {
+	return namespaces.ConsulNamespace(k8sNamespace, r.EnableConsulNamespaces,
+		r.ConsulDestinationNamespace, r.EnableNSMirroring, r.NSMirroringPrefix)
+}
+
+// isMeshEgress returns true if the service is an ExternalName service with the mesh-egress-gateway
+// annotation and a port. The first port of the service is the port of the external host.
+func isMeshEgress(svc corev1.Service) bool {
+	return svc.Spec.Type == corev1.ServiceTypeExternalName &&
+		svc.Spec.ExternalName != "" &&
+		svc.Annotations[constants.AnnotationMeshEgressGateway] != "" &&
+		len(svc.Spec.Ports) > 0 &&
+		svc.DeletionTimestamp.IsZero()
+}
+
+// hasNoPorts returns true if the service would be a mesh egress service if it had a port.
+func hasNoPorts(svc corev1.Service) bool {
+	return svc.Spec.Type == corev1.ServiceTypeExternalName &&
+		svc.Annotations[constants.AnnotationMeshEgressGateway] != "" &&
+		len(svc.Spec.Ports) == 0
+}
+
+// isOwnedBy returns true if the ServiceDefaults resource was created for the service.
+func isOwnedBy(serviceDefaults v1alpha1.ServiceDefaults, svc corev1.Service) bool {
+	for _, owner := range serviceDefaults.OwnerReferences {
+		if owner.Kind == "Service" && owner.UID == svc.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOwnerNamed returns true if the ServiceDefaults resource was created for a service with the name.
+func hasOwnerNamed(serviceDefaults v1alpha1.ServiceDefaults, name string) bool {
+	for _, owner := range serviceDefaults.OwnerReferences {
+		if owner.Kind == "Service" && owner.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// protocol returns the protocol of the external service.
+func protocol(svc corev1.Service) string {
+	if p := svc.Annotations[constants.AnnotationMeshEgressProtocol]; p != "" {
+		return p
+	}
+	return defaultProtocol
+}
+
+// serviceID returns the ID of the external service of the Kubernetes service.
+func serviceID(name, k8sNamespace string) string {
+	return fmt.Sprintf("%s-%s", name, k8sNamespace)
+}
+
+// gatewayPolicyName returns the name of the ACL policy that grants the gateway service:write on the
+// linked service.
+func gatewayPolicyName(gateway string, linked v1alpha1.LinkedService) string {
+	name := linked.Name
+	if linked.Namespace != "" {
+		name = linked.Namespace + "-" + name
+	}
+	return fmt.Sprintf("%s-external-%s-write-policy", gateway, name)
+}
+
+// isGatewayPolicy returns true if the policy name is one of gatewayPolicyName for the gateway.
+func isGatewayPolicy(gateway, policyName string) bool {
+	return strings.HasPrefix(policyName, gateway+"-external-") && strings.HasSuffix(policyName, "-write-policy")
+}
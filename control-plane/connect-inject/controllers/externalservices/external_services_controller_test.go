@@ -0,0 +1,370 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package externalservices
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/test"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcile(t *testing.T) {
+	const releaseNamespace = "consul"
+	service := func(name string, annotations map[string]string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name + "-uid"), Annotations: annotations},
+			Spec: corev1.ServiceSpec{
+				Type:         corev1.ServiceTypeExternalName,
+				ExternalName: name + ".example.com",
+				Ports:        []corev1.ServicePort{{Port: 443}},
+			},
+		}
+	}
+	ownedDefaults := func(name, protocol string) *v1alpha1.ServiceDefaults {
+		return &v1alpha1.ServiceDefaults{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name + "-default",
+				Namespace:       "default",
+				OwnerReferences: []metav1.OwnerReference{{APIVersion: "v1", Kind: "Service", Name: name, UID: types.UID(name + "-uid")}},
+			},
+			Spec: v1alpha1.ServiceDefaultsSpec{Protocol: protocol},
+		}
+	}
+
+	cases := map[string]struct {
+		objects         []client.Object
+		registered      *api.CatalogNodeServiceList
+		expRegistration *api.AgentService
+		expDeregistered []string
+		expProtocol     string
+		expServices     []v1alpha1.LinkedService
+		expManaged      string
+		expNoGateway    bool
+		mirroring       bool
+	}{
+		"registers an annotated ExternalName service": {
+			objects: []client.Object{service("billing", map[string]string{
+				constants.AnnotationMeshEgressGateway:  "terminating-gateway",
+				constants.AnnotationMeshEgressProtocol: "http",
+				constants.AnnotationMeshEgressCAFile:   "/etc/ssl/cert.pem",
+			})},
+			expRegistration: &api.AgentService{
+				ID:      "billing-default",
+				Service: "billing-default",
+				Address: "billing.example.com",
+				Port:    443,
+				Meta: map[string]string{
+					constants.MetaKeyKubeServiceName: "billing",
+					constants.MetaKeyKubeNS:          "default",
+					metaKeyManagedBy:                 managedByValue,
+				},
+			},
+			expProtocol: "http",
+			expServices: []v1alpha1.LinkedService{
+				{Name: "billing-default", CAFile: "/etc/ssl/cert.pem", SNI: "billing.example.com"},
+			},
+			expManaged: "/billing-default",
+		},
+		"keeps the name of the service with namespace mirroring": {
+			objects: []client.Object{service("billing", map[string]string{
+				constants.AnnotationMeshEgressGateway: "terminating-gateway",
+			})},
+			mirroring: true,
+			expRegistration: &api.AgentService{
+				ID:      "billing-default",
+				Service: "billing",
+				Address: "billing.example.com",
+				Port:    443,
+				Meta: map[string]string{
+					constants.MetaKeyKubeServiceName: "billing",
+					constants.MetaKeyKubeNS:          "default",
+					metaKeyManagedBy:                 managedByValue,
+				},
+				Namespace: "default",
+			},
+			expProtocol: "tcp",
+			expServices: []v1alpha1.LinkedService{{Name: "billing", Namespace: "default"}},
+			expManaged:  "default/billing",
+		},
+		"ignores services without the annotation": {
+			objects:      []client.Object{service("billing", nil)},
+			expNoGateway: true,
+		},
+		"ignores services without ports": {
+			objects: []client.Object{func() *corev1.Service {
+				svc := service("billing", map[string]string{constants.AnnotationMeshEgressGateway: "terminating-gateway"})
+				svc.Spec.Ports = nil
+				return svc
+			}()},
+			expNoGateway: true,
+		},
+		"keeps the linked services of users and updates the protocol": {
+			objects: []client.Object{
+				service("billing", map[string]string{constants.AnnotationMeshEgressGateway: "terminating-gateway"}),
+				ownedDefaults("billing", "http"),
+				&v1alpha1.TerminatingGateway{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "terminating-gateway",
+						Namespace:   "apps",
+						Annotations: map[string]string{constants.AnnotationManagedLinkedServices: "/billing-default,/old"},
+					},
+					Spec: v1alpha1.TerminatingGatewaySpec{Services: []v1alpha1.LinkedService{
+						{Name: "legacy"},
+						{Name: "old"},
+						{Name: "billing-default"},
+					}},
+				},
+			},
+			expRegistration: &api.AgentService{
+				ID:      "billing-default",
+				Service: "billing-default",
+				Address: "billing.example.com",
+				Port:    443,
+				Meta: map[string]string{
+					constants.MetaKeyKubeServiceName: "billing",
+					constants.MetaKeyKubeNS:          "default",
+					metaKeyManagedBy:                 managedByValue,
+				},
+			},
+			expProtocol: "tcp",
+			expServices: []v1alpha1.LinkedService{{Name: "legacy"}, {Name: "billing-default"}},
+			expManaged:  "/billing-default",
+		},
+		"leaves the ServiceDefaults of users as they are": {
+			objects: []client.Object{
+				service("billing", map[string]string{
+					constants.AnnotationMeshEgressGateway:  "terminating-gateway",
+					constants.AnnotationMeshEgressProtocol: "http",
+				}),
+				&v1alpha1.ServiceDefaults{
+					ObjectMeta: metav1.ObjectMeta{Name: "billing-default", Namespace: "default"},
+					Spec:       v1alpha1.ServiceDefaultsSpec{Protocol: "grpc"},
+				},
+			},
+			expRegistration: &api.AgentService{
+				ID:      "billing-default",
+				Service: "billing-default",
+				Address: "billing.example.com",
+				Port:    443,
+				Meta: map[string]string{
+					constants.MetaKeyKubeServiceName: "billing",
+					constants.MetaKeyKubeNS:          "default",
+					metaKeyManagedBy:                 managedByValue,
+				},
+			},
+			expProtocol: "grpc",
+			expServices: []v1alpha1.LinkedService{{Name: "billing-default"}},
+			expManaged:  "/billing-default",
+		},
+		"cleans up once the annotation is removed": {
+			objects: []client.Object{
+				service("billing", nil),
+				ownedDefaults("billing", "http"),
+				&v1alpha1.TerminatingGateway{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "terminating-gateway",
+						Namespace:   releaseNamespace,
+						Annotations: map[string]string{constants.AnnotationManagedLinkedServices: "/billing-default"},
+					},
+					Spec: v1alpha1.TerminatingGatewaySpec{Services: []v1alpha1.LinkedService{{Name: "billing-default"}}},
+				},
+			},
+			registered: &api.CatalogNodeServiceList{
+				Services: []*api.AgentService{{ID: "billing-default", Service: "billing-default"}},
+			},
+			expDeregistered: []string{"billing-default"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var registration *api.CatalogRegistration
+			var deregistered []string
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/catalog/node-services/" + externalNodeName:
+					require.NoError(t, json.NewEncoder(w).Encode(c.registered))
+				case "/v1/catalog/register":
+					registration = &api.CatalogRegistration{}
+					require.NoError(t, json.NewDecoder(r.Body).Decode(registration))
+					require.NoError(t, json.NewEncoder(w).Encode(true))
+				case "/v1/catalog/deregister":
+					var deregistration api.CatalogDeregistration
+					require.NoError(t, json.NewDecoder(r.Body).Decode(&deregistration))
+					deregistered = append(deregistered, deregistration.ServiceID)
+					require.NoError(t, json.NewEncoder(w).Encode(true))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			t.Cleanup(consulServer.Close)
+			serverURL, err := url.Parse(consulServer.URL)
+			require.NoError(t, err)
+			port, err := strconv.Atoi(serverURL.Port())
+			require.NoError(t, err)
+
+			s := runtime.NewScheme()
+			require.NoError(t, clientgoscheme.AddToScheme(s))
+			s.AddKnownTypes(v1alpha1.GroupVersion,
+				&v1alpha1.TerminatingGateway{}, &v1alpha1.TerminatingGatewayList{},
+				&v1alpha1.ServiceDefaults{}, &v1alpha1.ServiceDefaultsList{})
+			fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(c.objects...).Build()
+
+			controller := &Controller{
+				Client:              fakeClient,
+				ConsulClientConfig:  &consul.Config{APIClientConfig: &api.Config{}, HTTPPort: port},
+				ConsulServerConnMgr: test.MockConnMgrForIPAndPort(serverURL.Hostname(), 0),
+				ReleaseNamespace:    releaseNamespace,
+				Log:                 logrtest.New(t),
+			}
+			if c.mirroring {
+				controller.EnableConsulNamespaces = true
+				controller.EnableNSMirroring = true
+			}
+			_, err = controller.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Namespace: "default", Name: "billing"},
+			})
+			require.NoError(t, err)
+
+			if c.expRegistration == nil {
+				require.Nil(t, registration)
+			} else {
+				require.NotNil(t, registration)
+				require.Equal(t, externalNodeName, registration.Node)
+				require.Equal(t, c.expRegistration, registration.Service)
+			}
+			require.Equal(t, c.expDeregistered, deregistered)
+
+			var serviceDefaults v1alpha1.ServiceDefaults
+			err = fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: controller.consulServiceName("billing", "default")}, &serviceDefaults)
+			if c.expProtocol == "" {
+				require.True(t, k8serrors.IsNotFound(err))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, c.expProtocol, serviceDefaults.Spec.Protocol)
+			}
+
+			var gatewayList v1alpha1.TerminatingGatewayList
+			require.NoError(t, fakeClient.List(context.Background(), &gatewayList))
+			if c.expNoGateway {
+				require.Empty(t, gatewayList.Items)
+				return
+			}
+			require.Len(t, gatewayList.Items, 1)
+			require.Equal(t, c.expServices, gatewayList.Items[0].Spec.Services)
+			require.Equal(t, c.expManaged, gatewayList.Items[0].Annotations[constants.AnnotationManagedLinkedServices])
+		})
+	}
+}
+
+// Test that with ACLs enabled the ACL role of the gateway's token is granted service:write on the
+// linked services and that the policies of services that were unlinked are revoked.
+func TestReconcile_GatewayACLs(t *testing.T) {
+	const (
+		roleName    = "consul-terminating-gateway-acl-role"
+		stalePolicy = "terminating-gateway-external-old-default-write-policy"
+		newPolicy   = "terminating-gateway-external-billing-default-write-policy"
+	)
+	var createdPolicy *api.ACLPolicy
+	var updatedRole *api.ACLRole
+	var deletedPolicies []string
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/acl/role/name/"+roleName:
+			require.NoError(t, json.NewEncoder(w).Encode(&api.ACLRole{
+				ID:   "role-id",
+				Name: roleName,
+				Policies: []*api.ACLRolePolicyLink{
+					{ID: "gateway-policy-id", Name: "terminating-gateway-policy"},
+					{ID: "stale-policy-id", Name: stalePolicy},
+				},
+			}))
+		case r.URL.Path == "/v1/acl/policy/name/"+newPolicy:
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/v1/acl/policy" && r.Method == http.MethodPut:
+			createdPolicy = &api.ACLPolicy{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(createdPolicy))
+			createdPolicy.ID = "new-policy-id"
+			require.NoError(t, json.NewEncoder(w).Encode(createdPolicy))
+		case r.URL.Path == "/v1/acl/role/role-id" && r.Method == http.MethodPut:
+			updatedRole = &api.ACLRole{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(updatedRole))
+			require.NoError(t, json.NewEncoder(w).Encode(updatedRole))
+		case r.URL.Path == "/v1/acl/policy/stale-policy-id" && r.Method == http.MethodDelete:
+			deletedPolicies = append(deletedPolicies, "stale-policy-id")
+			require.NoError(t, json.NewEncoder(w).Encode(true))
+		case r.URL.Path == "/v1/catalog/register":
+			require.NoError(t, json.NewEncoder(w).Encode(true))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(consulServer.Close)
+	serverURL, err := url.Parse(consulServer.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(serverURL.Port())
+	require.NoError(t, err)
+
+	s := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(s))
+	s.AddKnownTypes(v1alpha1.GroupVersion,
+		&v1alpha1.TerminatingGateway{}, &v1alpha1.TerminatingGatewayList{},
+		&v1alpha1.ServiceDefaults{}, &v1alpha1.ServiceDefaultsList{})
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "billing",
+			Namespace:   "default",
+			Annotations: map[string]string{constants.AnnotationMeshEgressGateway: "terminating-gateway"},
+		},
+		Spec: corev1.ServiceSpec{
+			Type:         corev1.ServiceTypeExternalName,
+			ExternalName: "billing.example.com",
+			Ports:        []corev1.ServicePort{{Port: 443}},
+		},
+	}).Build()
+
+	controller := &Controller{
+		Client:              fakeClient,
+		ConsulClientConfig:  &consul.Config{APIClientConfig: &api.Config{}, HTTPPort: port},
+		ConsulServerConnMgr: test.MockConnMgrForIPAndPort(serverURL.Hostname(), 0),
+		ReleaseNamespace:    "consul",
+		EnableACLs:          true,
+		ResourcePrefix:      "consul",
+		Log:                 logrtest.New(t),
+	}
+	_, err = controller.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Namespace: "default", Name: "billing"},
+	})
+	require.NoError(t, err)
+
+	require.NotNil(t, createdPolicy)
+	require.Equal(t, newPolicy, createdPolicy.Name)
+	require.Equal(t, "service \"billing-default\" {\n  policy = \"write\"\n}", createdPolicy.Rules)
+	require.NotNil(t, updatedRole)
+	require.Equal(t, []*api.ACLRolePolicyLink{
+		{ID: "gateway-policy-id", Name: "terminating-gateway-policy"},
+		{ID: "new-policy-id", Name: newPolicy},
+	}, updatedRole.Policies)
+	require.Equal(t, []string{"stale-policy-id"}, deletedPolicies)
+}
@@ -0,0 +1,226 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package license
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul/api"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// EventReasonLicenseUpdated is the reason of the event recorded when a new license is applied.
+	EventReasonLicenseUpdated = "ConsulLicenseUpdated"
+	// EventReasonLicenseExpiring is the reason of the event recorded when the license is about to expire.
+	EventReasonLicenseExpiring = "ConsulLicenseExpiring"
+	// EventReasonLicenseExpired is the reason of the event recorded when the license has expired.
+	EventReasonLicenseExpired = "ConsulLicenseExpired"
+
+	// defaultResyncPeriod is how often the license source is checked for a new license. The
+	// secret is read from the manager's cache, so checking it often is cheap.
+	defaultResyncPeriod = time.Minute
+)
+
+// warningThresholds are the days before the license expires at which a warning event is recorded.
+var warningThresholds = []int{30, 7, 1}
+
+// expirationTime is the time the license expires at. It is served on the manager's metrics endpoint.
+var expirationTime = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "consul_connect_inject",
+	Name:      "license_expiration_timestamp_seconds",
+	Help:      "Time at which the Consul Enterprise license expires, in seconds since the Unix epoch.",
+})
+
+func init() {
+	metrics.Registry.MustRegister(expirationTime)
+}
+
+// Controller applies the Consul Enterprise license from a Kubernetes secret, or from a file rendered
+// by the Vault agent, to the Consul servers through the license API, so that a renewed license takes
+// effect without restarting the servers. It exports the expiration time of the license as a metric
+// and records warning events when the license is about to expire.
+//
+// Consul 1.10 and later only load the license from the server config, so the license API can't update
+// it. For these servers Apply is false and the controller only watches the expiration of the license.
+//
+// Controller is a manager.Runnable that checks the license source on every resync.
+type Controller struct {
+	client.Client
+	// ConsulClientConfig is the config for the Consul API client.
+	ConsulClientConfig *consul.Config
+	// ConsulServerConnMgr is the watcher for the Consul server addresses.
+	ConsulServerConnMgr consul.ServerConnectionManager
+	// Namespace is the namespace of the license secret.
+	Namespace string
+	// SecretName is the name of the Kubernetes secret of the license.
+	SecretName string
+	// SecretKey is the key of the license in the secret.
+	SecretKey string
+	// LicensePath is the path of the license file rendered by the Vault agent. If it's set, the
+	// license is read from the file instead of the Kubernetes secret.
+	LicensePath string
+	// Apply is whether the license is put on the servers through the license API. If it's false,
+	// the controller only exports the expiration of the license that the servers loaded.
+	Apply bool
+	// EventObject is the object that events are recorded on.
+	EventObject *corev1.ObjectReference
+	// Recorder records the license events.
+	Recorder record.EventRecorder
+	// ResyncPeriod is how often the license source is checked. Defaults to one minute.
+	ResyncPeriod time.Duration
+	// Log is the logger for this controller.
+	Log logr.Logger
+
+	// applied is the license that was last applied, or found to be applied already.
+	applied string
+	// expiration is the expiration time of the applied license.
+	expiration time.Time
+	// warnedThreshold is the smallest threshold in days that a warning was recorded for.
+	warnedThreshold int
+}
+
+// Start applies the license until the context is cancelled.
+func (c *Controller) Start(ctx context.Context) error {
+	period := c.ResyncPeriod
+	if period == 0 {
+		period = defaultResyncPeriod
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		if err := c.sync(ctx); err != nil {
+			c.Log.Error(err, "failed to sync Consul license")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync applies the license if it changed, refreshes its expiration time and records events for
+// its expiration.
+func (c *Controller) sync(ctx context.Context) error {
+	serverState, err := c.ConsulServerConnMgr.State()
+	if err != nil {
+		return fmt.Errorf("failed to get Consul server state: %w", err)
+	}
+	apiClient, err := consul.NewClientFromConnMgrState(c.ConsulClientConfig, serverState)
+	if err != nil {
+		return fmt.Errorf("failed to create Consul API client: %w", err)
+	}
+
+	if c.Apply {
+		license, err := c.readLicense(ctx)
+		if err != nil {
+			return err
+		}
+		if license != "" && license != c.applied {
+			if err := c.apply(apiClient, license); err != nil {
+				return err
+			}
+		}
+	}
+
+	reply, err := apiClient.Operator().LicenseGet(nil)
+	if err != nil {
+		return fmt.Errorf("failed to get the Consul license: %w", err)
+	}
+	if reply.License != nil && !reply.License.ExpirationTime.Equal(c.expiration) {
+		c.expiration = reply.License.ExpirationTime
+		c.warnedThreshold = 0
+		expirationTime.Set(float64(c.expiration.Unix()))
+	}
+	c.warnExpiration(time.Now())
+	return nil
+}
+
+// readLicense returns the license from the Vault agent's file or from the Kubernetes secret. It
+// returns an empty string if the file or secret doesn't exist or the secret doesn't have the key.
+func (c *Controller) readLicense(ctx context.Context) (string, error) {
+	if c.LicensePath != "" {
+		license, err := os.ReadFile(c.LicensePath)
+		if os.IsNotExist(err) {
+			return "", nil
+		} else if err != nil {
+			return "", fmt.Errorf("failed to read license file: %w", err)
+		}
+		return strings.TrimSpace(string(license)), nil
+	}
+
+	var secret corev1.Secret
+	err := c.Client.Get(ctx, types.NamespacedName{Namespace: c.Namespace, Name: c.SecretName}, &secret)
+	if err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+	return strings.TrimSpace(string(secret.Data[c.SecretKey])), nil
+}
+
+// apply puts the license on the Consul servers unless it's already applied.
+func (c *Controller) apply(apiClient *api.Client, license string) error {
+	current, err := apiClient.Operator().LicenseGetSigned(nil)
+	if err != nil {
+		return fmt.Errorf("failed to get the Consul license: %w", err)
+	}
+	if strings.TrimSpace(current) != license {
+		// nolint:staticcheck // SA1019 the license API is only used for servers that support it.
+		reply, err := apiClient.Operator().LicensePut(license, nil)
+		if err != nil {
+			return fmt.Errorf("failed to apply the Consul license: %w", err)
+		}
+		if !reply.Valid {
+			return fmt.Errorf("the Consul license is invalid: %s", strings.Join(reply.Warnings, ", "))
+		}
+		c.Log.Info("applied Consul license")
+		message := "Applied Consul Enterprise license"
+		if reply.License != nil {
+			message += fmt.Sprintf(" that expires at %s", reply.License.ExpirationTime.Format(time.RFC3339))
+		}
+		c.Recorder.Event(c.EventObject, corev1.EventTypeNormal, EventReasonLicenseUpdated, message)
+	}
+	c.applied = license
+	return nil
+}
+
+// warnExpiration records a warning event when the license expires within one of the warning
+// thresholds. Each threshold is only warned about once per license.
+func (c *Controller) warnExpiration(now time.Time) {
+	if c.expiration.IsZero() {
+		return
+	}
+	remaining := c.expiration.Sub(now)
+	if remaining <= 0 {
+		if c.warnedThreshold != -1 {
+			c.warnedThreshold = -1
+			c.Recorder.Event(c.EventObject, corev1.EventTypeWarning, EventReasonLicenseExpired,
+				fmt.Sprintf("Consul Enterprise license expired at %s", c.expiration.Format(time.RFC3339)))
+		}
+		return
+	}
+	threshold := 0
+	for _, days := range warningThresholds {
+		if remaining <= time.Duration(days)*24*time.Hour {
+			threshold = days
+		}
+	}
+	if threshold == 0 || (c.warnedThreshold != 0 && c.warnedThreshold <= threshold) {
+		return
+	}
+	c.warnedThreshold = threshold
+	c.Recorder.Event(c.EventObject, corev1.EventTypeWarning, EventReasonLicenseExpiring,
+		fmt.Sprintf("Consul Enterprise license expires in less than %d days, at %s", threshold, c.expiration.Format(time.RFC3339)))
+}
@@ -0,0 +1,208 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package license
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/test"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSync(t *testing.T) {
+	const namespace = "consul"
+	expiration := time.Now().Add(90 * 24 * time.Hour).UTC().Truncate(time.Second)
+
+	cases := map[string]struct {
+		apply         bool
+		secretLicense string
+		fileLicense   string
+		serverLicense string
+		expPut        string
+		expEvents     int
+		expApplied    string
+		expExpiration time.Time
+	}{
+		"puts a new license from the secret": {
+			apply:         true,
+			secretLicense: "new-license",
+			serverLicense: "old-license",
+			expPut:        "new-license",
+			expEvents:     1,
+			expApplied:    "new-license",
+			expExpiration: expiration,
+		},
+		"puts a new license from the Vault agent's file": {
+			apply:         true,
+			fileLicense:   "new-license\n",
+			serverLicense: "old-license",
+			expPut:        "new-license",
+			expEvents:     1,
+			expApplied:    "new-license",
+			expExpiration: expiration,
+		},
+		"doesn't put a license that's already applied": {
+			apply:         true,
+			secretLicense: "license",
+			serverLicense: "license",
+			expApplied:    "license",
+			expExpiration: expiration,
+		},
+		"doesn't put a license when the secret doesn't exist": {
+			apply:         true,
+			serverLicense: "license",
+			expExpiration: expiration,
+		},
+		"only watches the expiration when not applying licenses": {
+			secretLicense: "new-license",
+			serverLicense: "old-license",
+			expExpiration: expiration,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var put string
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/v1/operator/license" && r.Method == http.MethodPut:
+					body, err := io.ReadAll(r.Body)
+					require.NoError(t, err)
+					put = string(body)
+					require.NoError(t, json.NewEncoder(w).Encode(api.LicenseReply{Valid: true, License: &api.License{ExpirationTime: expiration}}))
+				case r.URL.Path == "/v1/operator/license" && r.URL.Query().Get("signed") == "1":
+					w.Write([]byte(c.serverLicense))
+				case r.URL.Path == "/v1/operator/license":
+					require.NoError(t, json.NewEncoder(w).Encode(api.LicenseReply{Valid: true, License: &api.License{ExpirationTime: expiration}}))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			t.Cleanup(consulServer.Close)
+			serverURL, err := url.Parse(consulServer.URL)
+			require.NoError(t, err)
+			port, err := strconv.Atoi(serverURL.Port())
+			require.NoError(t, err)
+
+			var objects []client.Object
+			if c.secretLicense != "" {
+				objects = append(objects, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "consul-license", Namespace: namespace},
+					Data:       map[string][]byte{"key": []byte(c.secretLicense)},
+				})
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+			recorder := record.NewFakeRecorder(10)
+
+			controller := &Controller{
+				Client:              fakeClient,
+				ConsulClientConfig:  &consul.Config{APIClientConfig: &api.Config{}, HTTPPort: port},
+				ConsulServerConnMgr: test.MockConnMgrForIPAndPort(serverURL.Hostname(), 0),
+				Namespace:           namespace,
+				SecretName:          "consul-license",
+				SecretKey:           "key",
+				Apply:               c.apply,
+				EventObject:         &corev1.ObjectReference{Kind: "Secret", Namespace: namespace, Name: "consul-license"},
+				Recorder:            recorder,
+				Log:                 logrtest.New(t),
+			}
+			if c.fileLicense != "" {
+				controller.LicensePath = filepath.Join(t.TempDir(), "enterpriselicense.txt")
+				require.NoError(t, os.WriteFile(controller.LicensePath, []byte(c.fileLicense), 0600))
+			}
+
+			require.NoError(t, controller.sync(context.Background()))
+			require.Equal(t, c.expPut, put)
+			require.Len(t, recorder.Events, c.expEvents)
+			require.Equal(t, c.expApplied, controller.applied)
+			require.True(t, c.expExpiration.Equal(controller.expiration))
+		})
+	}
+}
+
+func TestWarnExpiration(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+
+	cases := map[string]struct {
+		expiration      time.Time
+		warnedThreshold int
+		expEvent        string
+		expThreshold    int
+	}{
+		"no warning before 30 days": {
+			expiration: now.Add(31 * day),
+		},
+		"warns at 30 days": {
+			expiration:   now.Add(29 * day),
+			expEvent:     "Warning ConsulLicenseExpiring Consul Enterprise license expires in less than 30 days",
+			expThreshold: 30,
+		},
+		"doesn't warn twice at 30 days": {
+			expiration:      now.Add(20 * day),
+			warnedThreshold: 30,
+			expThreshold:    30,
+		},
+		"warns at 7 days after warning at 30 days": {
+			expiration:      now.Add(6 * day),
+			warnedThreshold: 30,
+			expEvent:        "Warning ConsulLicenseExpiring Consul Enterprise license expires in less than 7 days",
+			expThreshold:    7,
+		},
+		"warns at 1 day": {
+			expiration:   now.Add(time.Hour),
+			expEvent:     "Warning ConsulLicenseExpiring Consul Enterprise license expires in less than 1 days",
+			expThreshold: 1,
+		},
+		"warns when expired": {
+			expiration:      now.Add(-time.Hour),
+			warnedThreshold: 1,
+			expEvent:        "Warning ConsulLicenseExpired Consul Enterprise license expired",
+			expThreshold:    -1,
+		},
+		"doesn't warn twice when expired": {
+			expiration:      now.Add(-time.Hour),
+			warnedThreshold: -1,
+			expThreshold:    -1,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			controller := &Controller{
+				EventObject:     &corev1.ObjectReference{Kind: "Secret", Name: "consul-license"},
+				Recorder:        recorder,
+				expiration:      c.expiration,
+				warnedThreshold: c.warnedThreshold,
+			}
+			controller.warnExpiration(now)
+
+			if c.expEvent == "" {
+				require.Empty(t, recorder.Events)
+			} else {
+				require.Len(t, recorder.Events, 1)
+				require.Contains(t, <-recorder.Events, c.expEvent)
+			}
+			require.Equal(t, c.expThreshold, controller.warnedThreshold)
+		})
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package meshready
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/common"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// ReasonProxyNotRegistered is the condition reason when the pod's proxy is not registered with Consul.
+	ReasonProxyNotRegistered = "ProxyNotRegistered"
+	// ReasonServiceNotRegistered is the condition reason when the service that the pod's proxy is for
+	// is not registered with Consul.
+	ReasonServiceNotRegistered = "ServiceNotRegistered"
+	// ReasonProxyUnhealthy is the condition reason when a health check of the pod's proxy is not passing.
+	ReasonProxyUnhealthy = "ProxyUnhealthy"
+	// ReasonUpstreamDenied is the condition reason when intentions deny the pod's service from
+	// connecting to one of its explicit upstreams.
+	ReasonUpstreamDenied = "UpstreamDenied"
+	// ReasonMeshReady is the condition reason when the pod is wired into the mesh.
+	ReasonMeshReady = "MeshReady"
+
+	// consulKubernetesCheckType is the type of the health check that the endpoints controller
+	// registers to mirror the pod's Kubernetes readiness.
+	consulKubernetesCheckType = "kubernetes-readiness"
+
+	// notReadyRequeueAfter is how often pods that are not mesh ready are checked again. Consul
+	// registrations and health checks are not watched, so they have to be polled.
+	notReadyRequeueAfter = 5 * time.Second
+)
+
+// Controller sets the consul.hashicorp.com/mesh-ready condition on injected pods. The condition is
+// true once the pod's service and sidecar proxy are registered with Consul, the proxy's health checks
+// are passing and intentions allow the service to connect to its explicit upstreams. The health check
+// that mirrors the pod's own Kubernetes readiness is excluded because it can't pass until the pod is
+// ready, which would deadlock pods that use the condition as a readiness gate.
+type Controller struct {
+	client.Client
+	// ConsulClientConfig is the config for the Consul API client.
+	ConsulClientConfig *consul.Config
+	// ConsulServerConnMgr is the watcher for the Consul server addresses.
+	ConsulServerConnMgr consul.ServerConnectionManager
+	// EnableConsulNamespaces indicates that a user is running Consul Enterprise
+	// with version 1.7+ which supports namespaces.
+	EnableConsulNamespaces bool
+	// Log is the logger for this controller.
+	Log logr.Logger
+}
+
+// Reconcile updates the mesh-ready condition of the pod from the Consul registration of its proxy.
+func (r *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var pod corev1.Pod
+	if err := r.Client.Get(ctx, req.NamespacedName, &pod); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		r.Log.Error(err, "failed to get pod", "name", req.Name, "ns", req.Namespace)
+		return ctrl.Result{}, err
+	}
+	if !shouldReconcile(&pod) {
+		return ctrl.Result{}, nil
+	}
+
+	serverState, err := r.ConsulServerConnMgr.State()
+	if err != nil {
+		r.Log.Error(err, "failed to get Consul server state", "name", req.Name, "ns", req.Namespace)
+		return ctrl.Result{}, err
+	}
+	apiClient, err := consul.NewClientFromConnMgrState(r.ConsulClientConfig, serverState)
+	if err != nil {
+		r.Log.Error(err, "failed to create Consul API client", "name", req.Name, "ns", req.Namespace)
+		return ctrl.Result{}, err
+	}
+
+	status, reason, message, err := r.meshReady(apiClient, &pod)
+	if err != nil {
+		r.Log.Error(err, "failed to get proxy health from Consul", "name", req.Name, "ns", req.Namespace)
+		return ctrl.Result{}, err
+	}
+
+	if err := r.updateCondition(ctx, &pod, status, reason, message); err != nil {
+		r.Log.Error(err, "failed to update pod condition", "name", req.Name, "ns", req.Namespace)
+		return ctrl.Result{}, err
+	}
+	if status != corev1.ConditionTrue {
+		return ctrl.Result{RequeueAfter: notReadyRequeueAfter}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *Controller) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("mesh-ready").
+		For(&corev1.Pod{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(object client.Object) bool {
+			pod, ok := object.(*corev1.Pod)
+			return ok && shouldReconcile(pod)
+		}))).
+		Complete(r)
+}
+
+// shouldReconcile returns true for injected pods that are scheduled and not being deleted.
+func shouldReconcile(pod *corev1.Pod) bool {
+	return pod.Annotations[constants.KeyInjectStatus] == constants.Injected &&
+		pod.Spec.NodeName != "" &&
+		pod.DeletionTimestamp.IsZero()
+}
+
+// meshReady returns the status of the mesh-ready condition for the pod along with its reason and message.
+func (r *Controller) meshReady(apiClient *api.Client, pod *corev1.Pod) (corev1.ConditionStatus, string, string, error) {
+	nodeName := common.ConsulNodeNameFromK8sNode(pod.Spec.NodeName)
+	opts := &api.QueryOptions{
+		Filter: fmt.Sprintf(`Meta[%q] == %q and Meta[%q] == %q`,
+			constants.MetaKeyPodName, pod.Name, constants.MetaKeyKubeNS, pod.Namespace),
+		AllowStale: r.ConsulClientConfig.AllowStale,
+	}
+	if r.EnableConsulNamespaces {
+		opts.Namespace = namespaces.WildcardNamespace
+	}
+	nodeServices, _, err := apiClient.Catalog().NodeServiceList(nodeName, opts)
+	if err != nil {
+		return "", "", "", err
+	}
+	var proxies []*api.AgentService
+	serviceIDs := make(map[string]bool)
+	if nodeServices != nil {
+		for _, svc := range nodeServices.Services {
+			if svc.Kind == api.ServiceKindConnectProxy {
+				proxies = append(proxies, svc)
+			} else {
+				serviceIDs[svc.ID] = true
+			}
+		}
+	}
+	if len(proxies) == 0 {
+		return corev1.ConditionFalse, ReasonProxyNotRegistered, "Sidecar proxy is not registered with Consul", nil
+	}
+	proxyIDs := make(map[string]bool)
+	for _, proxy := range proxies {
+		if proxy.Proxy == nil || !serviceIDs[proxy.Proxy.DestinationServiceID] {
+			return corev1.ConditionFalse, ReasonServiceNotRegistered,
+				fmt.Sprintf("Service of sidecar proxy %q is not registered with Consul", proxy.ID), nil
+		}
+		proxyIDs[proxy.ID] = true
+	}
+
+	checkOpts := &api.QueryOptions{AllowStale: r.ConsulClientConfig.AllowStale}
+	if r.EnableConsulNamespaces {
+		checkOpts.Namespace = namespaces.WildcardNamespace
+	}
+	checks, _, err := apiClient.Health().Node(nodeName, checkOpts)
+	if err != nil {
+		return "", "", "", err
+	}
+	var failing []string
+	for _, check := range checks {
+		if !proxyIDs[check.ServiceID] || check.Type == consulKubernetesCheckType {
+			continue
+		}
+		if check.Status != api.HealthPassing {
+			failing = append(failing, check.Name)
+		}
+	}
+	if len(failing) > 0 {
+		sort.Strings(failing)
+		return corev1.ConditionFalse, ReasonProxyUnhealthy,
+			fmt.Sprintf("Sidecar proxy health checks are not passing: %s", strings.Join(failing, ", ")), nil
+	}
+
+	denied, err := r.deniedUpstreams(apiClient, proxies)
+	if err != nil {
+		return "", "", "", err
+	}
+	if len(denied) > 0 {
+		return corev1.ConditionFalse, ReasonUpstreamDenied,
+			fmt.Sprintf("Intentions deny traffic to upstreams: %s", strings.Join(denied, ", ")), nil
+	}
+	return corev1.ConditionTrue, ReasonMeshReady, "Service and sidecar proxy are registered with Consul and ready", nil
+}
+
+// deniedUpstreams returns the sorted names of the explicit upstreams of the proxies that intentions
+// don't allow their service to connect to. Prepared query and peer upstreams can't be checked with
+// the intentions check endpoint so they are skipped.
+func (r *Controller) deniedUpstreams(apiClient *api.Client, proxies []*api.AgentService) ([]string, error) {
+	var denied []string
+	for _, proxy := range proxies {
+		source := intentionName(r.EnableConsulNamespaces, proxy.Namespace, proxy.Proxy.DestinationServiceName)
+		for _, upstream := range proxy.Proxy.Upstreams {
+			if upstream.DestinationType == api.UpstreamDestTypePreparedQuery || upstream.DestinationPeer != "" {
+				continue
+			}
+			destination := intentionName(r.EnableConsulNamespaces, upstream.DestinationNamespace, upstream.DestinationName)
+			allowed, _, err := apiClient.Connect().IntentionCheck(&api.IntentionCheck{
+				Source:      source,
+				Destination: destination,
+				SourceType:  api.IntentionSourceConsul,
+			}, nil)
+			if err != nil {
+				return nil, err
+			}
+			if !allowed {
+				denied = append(denied, destination)
+			}
+		}
+	}
+	sort.Strings(denied)
+	return denied, nil
+}
+
+// intentionName returns the service name in the "namespace/name" format that the intentions
+// check endpoint accepts when Consul namespaces are enabled.
+func intentionName(enableNamespaces bool, namespace, name string) string {
+	if enableNamespaces && namespace != "" {
+		return namespace + "/" + name
+	}
+	return name
+}
+
+// updateCondition sets the mesh-ready condition on the pod's status. The pod is only patched
+// if the condition changed so that the last transition time is kept.
+func (r *Controller) updateCondition(ctx context.Context, pod *corev1.Pod, status corev1.ConditionStatus, reason, message string) error {
+	condition := corev1.PodCondition{
+		Type:               constants.PodConditionMeshReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	}
+	updated := pod.DeepCopy()
+	found := false
+	for i, existing := range updated.Status.Conditions {
+		if existing.Type != constants.PodConditionMeshReady {
+			continue
+		}
+		if existing.Status == status && existing.Reason == reason && existing.Message == message {
+			return nil
+		}
+		if existing.Status == status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		}
+		updated.Status.Conditions[i] = condition
+		found = true
+	}
+	if !found {
+		updated.Status.Conditions = append(updated.Status.Conditions, condition)
+	}
+	return r.Client.Status().Patch(ctx, updated, client.StrategicMergeFrom(pod))
+}
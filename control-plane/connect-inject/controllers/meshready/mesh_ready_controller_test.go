@@ -0,0 +1,255 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package meshready
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/test"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcile(t *testing.T) {
+	service := &api.AgentService{ID: "pod1-web", Service: "web"}
+	proxy := &api.AgentService{
+		ID:      "pod1-web-sidecar-proxy",
+		Service: "web-sidecar-proxy",
+		Kind:    api.ServiceKindConnectProxy,
+		Proxy: &api.AgentServiceConnectProxyConfig{
+			DestinationServiceName: "web",
+			DestinationServiceID:   "pod1-web",
+			Upstreams: []api.Upstream{
+				{DestinationName: "api"},
+				{DestinationName: "db"},
+				{DestinationName: "query", DestinationType: api.UpstreamDestTypePreparedQuery},
+				{DestinationName: "remote", DestinationPeer: "peer1"},
+			},
+		},
+	}
+	readyMessage := "Service and sidecar proxy are registered with Consul and ready"
+
+	cases := map[string]struct {
+		services        []*api.AgentService
+		checks          api.HealthChecks
+		denied          []string
+		existing        *corev1.PodCondition
+		expStatus       corev1.ConditionStatus
+		expReason       string
+		expMessage      string
+		expRequeue      bool
+		expTransitioned bool
+	}{
+		"proxy not registered": {
+			expStatus:       corev1.ConditionFalse,
+			expReason:       ReasonProxyNotRegistered,
+			expMessage:      "Sidecar proxy is not registered with Consul",
+			expRequeue:      true,
+			expTransitioned: true,
+		},
+		"proxy registered with passing checks": {
+			services: []*api.AgentService{service, proxy},
+			checks: api.HealthChecks{
+				{ServiceID: proxy.ID, Name: "Proxy Public Listener", Status: api.HealthPassing},
+			},
+			expStatus:       corev1.ConditionTrue,
+			expReason:       ReasonMeshReady,
+			expMessage:      readyMessage,
+			expTransitioned: true,
+		},
+		"kubernetes readiness check is ignored": {
+			services: []*api.AgentService{service, proxy},
+			checks: api.HealthChecks{
+				{ServiceID: proxy.ID, Name: "Kubernetes Readiness Check", Type: consulKubernetesCheckType, Status: api.HealthCritical},
+			},
+			expStatus:       corev1.ConditionTrue,
+			expReason:       ReasonMeshReady,
+			expMessage:      readyMessage,
+			expTransitioned: true,
+		},
+		"checks of other services are ignored": {
+			services: []*api.AgentService{service, proxy},
+			checks: api.HealthChecks{
+				{ServiceID: "pod2-web-sidecar-proxy", Name: "Proxy Public Listener", Status: api.HealthCritical},
+			},
+			expStatus:       corev1.ConditionTrue,
+			expReason:       ReasonMeshReady,
+			expMessage:      readyMessage,
+			expTransitioned: true,
+		},
+		"proxy registered with failing checks": {
+			services: []*api.AgentService{service, proxy},
+			checks: api.HealthChecks{
+				{ServiceID: proxy.ID, Name: "Proxy Public Listener", Status: api.HealthCritical},
+				{ServiceID: proxy.ID, Name: "Destination Alias", Status: api.HealthWarning},
+			},
+			expStatus:       corev1.ConditionFalse,
+			expReason:       ReasonProxyUnhealthy,
+			expMessage:      "Sidecar proxy health checks are not passing: Destination Alias, Proxy Public Listener",
+			expRequeue:      true,
+			expTransitioned: true,
+		},
+		"service not registered": {
+			services:        []*api.AgentService{proxy},
+			expStatus:       corev1.ConditionFalse,
+			expReason:       ReasonServiceNotRegistered,
+			expMessage:      `Service of sidecar proxy "pod1-web-sidecar-proxy" is not registered with Consul`,
+			expRequeue:      true,
+			expTransitioned: true,
+		},
+		"intentions deny upstreams": {
+			services:        []*api.AgentService{service, proxy},
+			denied:          []string{"db", "api"},
+			expStatus:       corev1.ConditionFalse,
+			expReason:       ReasonUpstreamDenied,
+			expMessage:      "Intentions deny traffic to upstreams: api, db",
+			expRequeue:      true,
+			expTransitioned: true,
+		},
+		"unchanged condition keeps its transition time": {
+			services: []*api.AgentService{service, proxy},
+			existing: &corev1.PodCondition{
+				Type:    constants.PodConditionMeshReady,
+				Status:  corev1.ConditionTrue,
+				Reason:  ReasonMeshReady,
+				Message: readyMessage,
+			},
+			expStatus:  corev1.ConditionTrue,
+			expReason:  ReasonMeshReady,
+			expMessage: readyMessage,
+		},
+		"condition transitions to not ready": {
+			existing: &corev1.PodCondition{
+				Type:    constants.PodConditionMeshReady,
+				Status:  corev1.ConditionTrue,
+				Reason:  ReasonMeshReady,
+				Message: readyMessage,
+			},
+			expStatus:       corev1.ConditionFalse,
+			expReason:       ReasonProxyNotRegistered,
+			expMessage:      "Sidecar proxy is not registered with Consul",
+			expRequeue:      true,
+			expTransitioned: true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case strings.HasPrefix(r.URL.Path, "/v1/catalog/node-services/"):
+					require.Contains(t, r.URL.Query().Get("filter"), `Meta["pod-name"] == "pod1"`)
+					require.NoError(t, json.NewEncoder(w).Encode(api.CatalogNodeServiceList{Services: c.services}))
+				case strings.HasPrefix(r.URL.Path, "/v1/health/node/"):
+					require.NoError(t, json.NewEncoder(w).Encode(c.checks))
+				case r.URL.Path == "/v1/connect/intentions/check":
+					require.Equal(t, "web", r.URL.Query().Get("source"))
+					destination := r.URL.Query().Get("destination")
+					require.NotContains(t, []string{"query", "remote"}, destination)
+					allowed := true
+					for _, d := range c.denied {
+						if d == destination {
+							allowed = false
+						}
+					}
+					require.NoError(t, json.NewEncoder(w).Encode(map[string]bool{"Allowed": allowed}))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			t.Cleanup(consulServer.Close)
+			serverURL, err := url.Parse(consulServer.URL)
+			require.NoError(t, err)
+			port, err := strconv.Atoi(serverURL.Port())
+			require.NoError(t, err)
+
+			transitionTime := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "pod1",
+					Namespace:   "default",
+					Annotations: map[string]string{constants.KeyInjectStatus: constants.Injected},
+				},
+				Spec: corev1.PodSpec{NodeName: "node1"},
+				Status: corev1.PodStatus{
+					Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse}},
+				},
+			}
+			if c.existing != nil {
+				c.existing.LastTransitionTime = transitionTime
+				pod.Status.Conditions = append(pod.Status.Conditions, *c.existing)
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(pod).Build()
+
+			controller := &Controller{
+				Client:              fakeClient,
+				ConsulClientConfig:  &consul.Config{APIClientConfig: &api.Config{}, HTTPPort: port},
+				ConsulServerConnMgr: test.MockConnMgrForIPAndPort(serverURL.Hostname(), 0),
+				Log:                 logrtest.New(t),
+			}
+			resp, err := controller.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: "pod1", Namespace: "default"},
+			})
+			require.NoError(t, err)
+			require.Equal(t, c.expRequeue, resp.RequeueAfter > 0)
+
+			var updated corev1.Pod
+			require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "pod1", Namespace: "default"}, &updated))
+			require.Len(t, updated.Status.Conditions, 2)
+			require.Equal(t, corev1.PodReady, updated.Status.Conditions[0].Type)
+
+			condition := updated.Status.Conditions[1]
+			require.Equal(t, corev1.PodConditionType(constants.PodConditionMeshReady), condition.Type)
+			require.Equal(t, c.expStatus, condition.Status)
+			require.Equal(t, c.expReason, condition.Reason)
+			require.Equal(t, c.expMessage, condition.Message)
+			require.Equal(t, c.expTransitioned, !condition.LastTransitionTime.Equal(&transitionTime))
+		})
+	}
+}
+
+func TestReconcile_IgnoresPodsThatAreNotInjected(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: "node1"},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(pod).Build()
+
+	// The Consul server connection manager isn't set since Consul shouldn't be queried.
+	controller := &Controller{
+		Client: fakeClient,
+		Log:    logrtest.New(t),
+	}
+	resp, err := controller.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "pod1", Namespace: "default"},
+	})
+	require.NoError(t, err)
+	require.False(t, resp.Requeue)
+
+	var updated corev1.Pod
+	require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "pod1", Namespace: "default"}, &updated))
+	require.Empty(t, updated.Status.Conditions)
+}
+
+func TestIntentionName(t *testing.T) {
+	require.Equal(t, "web", intentionName(false, "ns1", "web"))
+	require.Equal(t, "web", intentionName(true, "", "web"))
+	require.Equal(t, "ns1/web", intentionName(true, "ns1", "web"))
+}
@@ -0,0 +1,116 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package nodemeta
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/common"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Controller syncs labels of Kubernetes nodes, such as their zone or instance type, into the meta
+// of the Consul nodes that services on them are registered on. The node meta can then be used to
+// select service instances by topology, e.g. in the NodeMeta filter of prepared queries.
+//
+// The endpoints controller sets the labels when it creates a Consul node. This controller keeps the
+// meta up to date as the labels change. Consul nodes that don't exist yet are skipped.
+type Controller struct {
+	client.Client
+	// ConsulClientConfig is the config for the Consul API client.
+	ConsulClientConfig *consul.Config
+	// ConsulServerConnMgr is the watcher for the Consul server addresses.
+	ConsulServerConnMgr consul.ServerConnectionManager
+	// Labels are the labels of the Kubernetes nodes to sync into node meta.
+	Labels []string
+	// Log is the logger for this controller.
+	Log logr.Logger
+}
+
+// Reconcile updates the meta of the Consul node for the Kubernetes node from its labels.
+func (r *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var node corev1.Node
+	if err := r.Client.Get(ctx, req.NamespacedName, &node); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		r.Log.Error(err, "failed to get node", "name", req.Name)
+		return ctrl.Result{}, err
+	}
+
+	serverState, err := r.ConsulServerConnMgr.State()
+	if err != nil {
+		r.Log.Error(err, "failed to get Consul server state", "name", req.Name)
+		return ctrl.Result{}, err
+	}
+	apiClient, err := consul.NewClientFromConnMgrState(r.ConsulClientConfig, serverState)
+	if err != nil {
+		r.Log.Error(err, "failed to create Consul API client", "name", req.Name)
+		return ctrl.Result{}, err
+	}
+
+	consulNodeName := common.ConsulNodeNameFromK8sNode(node.Name)
+	catalogNode, _, err := apiClient.Catalog().Node(consulNodeName, nil)
+	if err != nil {
+		r.Log.Error(err, "failed to get Consul node", "name", req.Name, "consul-node", consulNodeName)
+		return ctrl.Result{}, err
+	}
+	if catalogNode == nil || catalogNode.Node == nil {
+		// The endpoints controller syncs the labels when it creates the node.
+		return ctrl.Result{}, nil
+	}
+
+	consulNode := catalogNode.Node
+	meta := nodeMeta(consulNode.Meta, node, r.Labels)
+	if reflect.DeepEqual(meta, consulNode.Meta) {
+		return ctrl.Result{}, nil
+	}
+
+	r.Log.Info("updating Consul node meta", "name", req.Name, "consul-node", consulNodeName)
+	_, err = apiClient.Catalog().Register(&api.CatalogRegistration{
+		ID:              consulNode.ID,
+		Node:            consulNode.Node,
+		Address:         consulNode.Address,
+		Datacenter:      consulNode.Datacenter,
+		TaggedAddresses: consulNode.TaggedAddresses,
+		NodeMeta:        meta,
+		Partition:       consulNode.Partition,
+	}, nil)
+	if err != nil {
+		r.Log.Error(err, "failed to update Consul node meta", "name", req.Name, "consul-node", consulNodeName)
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *Controller) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("node-meta").
+		For(&corev1.Node{}).
+		Complete(r)
+}
+
+// nodeMeta returns the existing node meta with the values of the synced labels. Meta of
+// labels that were removed from the Kubernetes node is removed, other meta is kept.
+func nodeMeta(existing map[string]string, node corev1.Node, labels []string) map[string]string {
+	meta := make(map[string]string, len(existing))
+	for k, v := range existing {
+		meta[k] = v
+	}
+	for _, label := range labels {
+		delete(meta, common.NodeLabelMetaKey(label))
+	}
+	for k, v := range common.NodeLabelMeta(node, labels) {
+		meta[k] = v
+	}
+	return meta
+}
@@ -0,0 +1,131 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package nodemeta
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/test"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcile(t *testing.T) {
+	labels := []string{corev1.LabelTopologyZone, corev1.LabelInstanceTypeStable}
+
+	cases := map[string]struct {
+		nodeLabels map[string]string
+		consulNode *api.Node
+		expMeta    map[string]string
+	}{
+		"adds label meta": {
+			nodeLabels: map[string]string{corev1.LabelTopologyZone: "us-east-1a", corev1.LabelInstanceTypeStable: "m5.large"},
+			consulNode: &api.Node{
+				ID:      "node-id",
+				Node:    "node1-virtual",
+				Address: "10.0.0.1",
+				Meta:    map[string]string{"synthetic-node": "true"},
+			},
+			expMeta: map[string]string{
+				"synthetic-node":                   "true",
+				"topology-kubernetes-io-zone":      "us-east-1a",
+				"node-kubernetes-io-instance-type": "m5.large",
+			},
+		},
+		"updates and removes label meta": {
+			nodeLabels: map[string]string{corev1.LabelTopologyZone: "us-east-1b"},
+			consulNode: &api.Node{
+				ID:      "node-id",
+				Node:    "node1-virtual",
+				Address: "10.0.0.1",
+				Meta: map[string]string{
+					"synthetic-node":                   "true",
+					"topology-kubernetes-io-zone":      "us-east-1a",
+					"node-kubernetes-io-instance-type": "m5.large",
+				},
+			},
+			expMeta: map[string]string{
+				"synthetic-node":              "true",
+				"topology-kubernetes-io-zone": "us-east-1b",
+			},
+		},
+		"unchanged meta is not registered": {
+			nodeLabels: map[string]string{corev1.LabelTopologyZone: "us-east-1a"},
+			consulNode: &api.Node{
+				ID:      "node-id",
+				Node:    "node1-virtual",
+				Address: "10.0.0.1",
+				Meta:    map[string]string{"synthetic-node": "true", "topology-kubernetes-io-zone": "us-east-1a"},
+			},
+		},
+		"consul node does not exist": {
+			nodeLabels: map[string]string{corev1.LabelTopologyZone: "us-east-1a"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var registration *api.CatalogRegistration
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/v1/catalog/node/node1-virtual":
+					var node *api.CatalogNode
+					if c.consulNode != nil {
+						node = &api.CatalogNode{Node: c.consulNode}
+					}
+					require.NoError(t, json.NewEncoder(w).Encode(node))
+				case r.URL.Path == "/v1/catalog/register":
+					registration = &api.CatalogRegistration{}
+					require.NoError(t, json.NewDecoder(r.Body).Decode(registration))
+					require.NoError(t, json.NewEncoder(w).Encode(true))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			t.Cleanup(consulServer.Close)
+			serverURL, err := url.Parse(consulServer.URL)
+			require.NoError(t, err)
+			port, err := strconv.Atoi(serverURL.Port())
+			require.NoError(t, err)
+
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: c.nodeLabels}}
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(node).Build()
+
+			controller := &Controller{
+				Client:              fakeClient,
+				ConsulClientConfig:  &consul.Config{APIClientConfig: &api.Config{}, HTTPPort: port},
+				ConsulServerConnMgr: test.MockConnMgrForIPAndPort(serverURL.Hostname(), 0),
+				Labels:              labels,
+				Log:                 logrtest.New(t),
+			}
+			_, err = controller.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: "node1"},
+			})
+			require.NoError(t, err)
+
+			if c.expMeta == nil {
+				require.Nil(t, registration)
+				return
+			}
+			require.NotNil(t, registration)
+			require.Equal(t, c.consulNode.ID, registration.ID)
+			require.Equal(t, c.consulNode.Node, registration.Node)
+			require.Equal(t, c.consulNode.Address, registration.Address)
+			require.Equal(t, c.expMeta, registration.NodeMeta)
+		})
+	}
+}
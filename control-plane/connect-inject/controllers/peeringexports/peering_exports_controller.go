@@ -0,0 +1,227 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package peeringexports
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Controller exports the Consul services of Kubernetes services to the cluster peers in their
+// consul.hashicorp.com/export-to-peers annotation. The annotations of all services are aggregated
+// into the ExportedServices resource of the partition, which is created in the release namespace if
+// it doesn't exist. This lets application teams export their services without editing the resource.
+//
+// The services that the controller added are recorded in an annotation on the resource, so that
+// entries written by users are left as they are. If a user's entry already exports a service, the
+// service's annotation is ignored.
+type Controller struct {
+	client.Client
+	// ConsulPartition is the admin partition that services are exported from. The ExportedServices
+	// resource is named after it.
+	ConsulPartition string
+	// ReleaseNamespace is the namespace that the ExportedServices resource is created in.
+	ReleaseNamespace string
+	// EnableConsulNamespaces indicates that a user is running Consul Enterprise
+	// with version 1.7+ which supports namespaces.
+	EnableConsulNamespaces bool
+	// ConsulDestinationNamespace is the name of the Consul namespace to register all
+	// services into if mirroring is disabled.
+	ConsulDestinationNamespace string
+	// EnableNSMirroring causes Consul namespaces to be created to match the
+	// k8s namespace of any service being registered into Consul.
+	EnableNSMirroring bool
+	// NSMirroringPrefix works with EnableNSMirroring to add a prefix to the
+	// Consul namespace of the service.
+	NSMirroringPrefix string
+	// Log is the logger for this controller.
+	Log logr.Logger
+}
+
+// Reconcile updates the ExportedServices resource from the export-to-peers annotations of all services.
+func (r *Controller) Reconcile(ctx context.Context, _ ctrl.Request) (ctrl.Result, error) {
+	var serviceList corev1.ServiceList
+	if err := r.Client.List(ctx, &serviceList); err != nil {
+		r.Log.Error(err, "failed to list services")
+		return ctrl.Result{}, err
+	}
+	desired := make(map[string]v1alpha1.ExportedService)
+	for _, svc := range serviceList.Items {
+		peers := parsePeers(svc.Annotations[constants.AnnotationExportToPeers])
+		if len(peers) == 0 || !svc.DeletionTimestamp.IsZero() {
+			continue
+		}
+		exported := v1alpha1.ExportedService{
+			Name: svc.Name,
+			Namespace: namespaces.ConsulNamespace(svc.Namespace, r.EnableConsulNamespaces,
+				r.ConsulDestinationNamespace, r.EnableNSMirroring, r.NSMirroringPrefix),
+		}
+		// Services with the same name in Kubernetes namespaces that map to the same Consul
+		// namespace are the same Consul service, so their peers are merged.
+		key := exportKey(exported)
+		if existing, ok := desired[key]; ok {
+			exported.Consumers = existing.Consumers
+		}
+		for _, peer := range peers {
+			if !hasPeer(exported.Consumers, peer) {
+				exported.Consumers = append(exported.Consumers, v1alpha1.ServiceConsumer{Peer: peer})
+			}
+		}
+		desired[key] = exported
+	}
+
+	exportedServices, err := r.exportedServices(ctx)
+	if err != nil {
+		r.Log.Error(err, "failed to get ExportedServices resource", "name", r.resourceName())
+		return ctrl.Result{}, err
+	}
+	if exportedServices == nil {
+		if len(desired) == 0 {
+			return ctrl.Result{}, nil
+		}
+		exportedServices = &v1alpha1.ExportedServices{
+			ObjectMeta: metav1.ObjectMeta{Name: r.resourceName(), Namespace: r.ReleaseNamespace},
+		}
+	}
+
+	managed := make(map[string]bool)
+	for _, key := range strings.Split(exportedServices.Annotations[constants.AnnotationManagedExports], ",") {
+		if key != "" {
+			managed[key] = true
+		}
+	}
+
+	// Keep the entries written by users and replace the entries that were added from annotations.
+	var services []v1alpha1.ExportedService
+	userKeys := make(map[string]bool)
+	for _, exported := range exportedServices.Spec.Services {
+		key := exportKey(exported)
+		if managed[key] {
+			continue
+		}
+		services = append(services, exported)
+		userKeys[key] = true
+	}
+	var managedKeys []string
+	for key := range desired {
+		if userKeys[key] {
+			r.Log.Info("service is already exported by the ExportedServices resource, ignoring its annotation", "service", key)
+			continue
+		}
+		managedKeys = append(managedKeys, key)
+	}
+	sort.Strings(managedKeys)
+	for _, key := range managedKeys {
+		services = append(services, desired[key])
+	}
+
+	annotation := strings.Join(managedKeys, ",")
+	if exportedServices.ResourceVersion != "" &&
+		reflect.DeepEqual(services, exportedServices.Spec.Services) &&
+		annotation == exportedServices.Annotations[constants.AnnotationManagedExports] {
+		return ctrl.Result{}, nil
+	}
+
+	exportedServices.Spec.Services = services
+	if exportedServices.Annotations == nil {
+		exportedServices.Annotations = map[string]string{}
+	}
+	exportedServices.Annotations[constants.AnnotationManagedExports] = annotation
+	if exportedServices.ResourceVersion == "" {
+		r.Log.Info("creating ExportedServices resource for exports from annotations", "name", exportedServices.Name)
+		err = r.Client.Create(ctx, exportedServices)
+	} else {
+		r.Log.Info("updating ExportedServices resource for exports from annotations", "name", exportedServices.Name)
+		err = r.Client.Update(ctx, exportedServices)
+	}
+	if err != nil {
+		r.Log.Error(err, "failed to save ExportedServices resource", "name", exportedServices.Name)
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *Controller) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("peering-exports").
+		Watches(
+			&source.Kind{Type: &corev1.Service{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestForExportedServices),
+		).
+		Watches(
+			&source.Kind{Type: &v1alpha1.ExportedServices{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestForExportedServices),
+		).Complete(r)
+}
+
+// requestForExportedServices maps all services to a single request, so that the annotations of all
+// services are aggregated at once. Changes to the ExportedServices resource are mapped to the same
+// request so that entries that were removed from it are added back.
+func (r *Controller) requestForExportedServices(client.Object) []ctrl.Request {
+	return []ctrl.Request{{NamespacedName: types.NamespacedName{Namespace: r.ReleaseNamespace, Name: r.resourceName()}}}
+}
+
+// exportedServices returns the ExportedServices resource of the partition in any namespace, or nil
+// if it doesn't exist.
+func (r *Controller) exportedServices(ctx context.Context) (*v1alpha1.ExportedServices, error) {
+	var list v1alpha1.ExportedServicesList
+	if err := r.Client.List(ctx, &list); err != nil {
+		return nil, err
+	}
+	for i := range list.Items {
+		if list.Items[i].Name == r.resourceName() {
+			return &list.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// resourceName returns the name of the ExportedServices resource, which must be the name of the partition.
+func (r *Controller) resourceName() string {
+	if r.ConsulPartition == "" {
+		return "default"
+	}
+	return r.ConsulPartition
+}
+
+// exportKey returns the key of the exported service in the managed exports annotation.
+func exportKey(exported v1alpha1.ExportedService) string {
+	return exported.Namespace + "/" + exported.Name
+}
+
+// parsePeers parses the comma-separated peers of the export-to-peers annotation.
+func parsePeers(raw string) []string {
+	var peers []string
+	for _, peer := range strings.Split(raw, ",") {
+		peer = strings.TrimSpace(peer)
+		if peer != "" {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+func hasPeer(consumers []v1alpha1.ServiceConsumer, peer string) bool {
+	for _, consumer := range consumers {
+		if consumer.Peer == peer {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package peeringexports
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcile(t *testing.T) {
+	const releaseNamespace = "consul"
+	service := func(namespace, name, peers string) *corev1.Service {
+		svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+		if peers != "" {
+			svc.Annotations = map[string]string{constants.AnnotationExportToPeers: peers}
+		}
+		return svc
+	}
+
+	cases := map[string]struct {
+		services         []client.Object
+		existing         *v1alpha1.ExportedServices
+		enableNamespaces bool
+		expServices      []v1alpha1.ExportedService
+		expManaged       string
+		expNotFound      bool
+	}{
+		"doesn't create the resource without annotations": {
+			services:    []client.Object{service("default", "web", "")},
+			expNotFound: true,
+		},
+		"creates the resource from annotations": {
+			services: []client.Object{
+				service("default", "web", "peer1, peer2"),
+				service("default", "api", "peer1"),
+				service("default", "db", ""),
+			},
+			expServices: []v1alpha1.ExportedService{
+				{Name: "api", Consumers: []v1alpha1.ServiceConsumer{{Peer: "peer1"}}},
+				{Name: "web", Consumers: []v1alpha1.ServiceConsumer{{Peer: "peer1"}, {Peer: "peer2"}}},
+			},
+			expManaged: "/api,/web",
+		},
+		"maps services to their Consul namespace": {
+			services: []client.Object{
+				service("default", "web", "peer1"),
+				service("team-a", "web", "peer2"),
+			},
+			enableNamespaces: true,
+			expServices: []v1alpha1.ExportedService{
+				{Name: "web", Namespace: "k8s-default", Consumers: []v1alpha1.ServiceConsumer{{Peer: "peer1"}}},
+				{Name: "web", Namespace: "k8s-team-a", Consumers: []v1alpha1.ServiceConsumer{{Peer: "peer2"}}},
+			},
+			expManaged: "k8s-default/web,k8s-team-a/web",
+		},
+		"keeps the entries of users and replaces managed entries": {
+			services: []client.Object{service("default", "web", "peer2")},
+			existing: &v1alpha1.ExportedServices{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "default",
+					Namespace:   "apps",
+					Annotations: map[string]string{constants.AnnotationManagedExports: "/web,/api"},
+				},
+				Spec: v1alpha1.ExportedServicesSpec{Services: []v1alpha1.ExportedService{
+					{Name: "frontend", Consumers: []v1alpha1.ServiceConsumer{{Peer: "peer1"}}},
+					{Name: "web", Consumers: []v1alpha1.ServiceConsumer{{Peer: "peer1"}}},
+					{Name: "api", Consumers: []v1alpha1.ServiceConsumer{{Peer: "peer1"}}},
+				}},
+			},
+			expServices: []v1alpha1.ExportedService{
+				{Name: "frontend", Consumers: []v1alpha1.ServiceConsumer{{Peer: "peer1"}}},
+				{Name: "web", Consumers: []v1alpha1.ServiceConsumer{{Peer: "peer2"}}},
+			},
+			expManaged: "/web",
+		},
+		"ignores annotations of services that users export": {
+			services: []client.Object{service("default", "web", "peer2")},
+			existing: &v1alpha1.ExportedServices{
+				ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "apps"},
+				Spec: v1alpha1.ExportedServicesSpec{Services: []v1alpha1.ExportedService{
+					{Name: "web", Consumers: []v1alpha1.ServiceConsumer{{Peer: "peer1"}}},
+				}},
+			},
+			expServices: []v1alpha1.ExportedService{
+				{Name: "web", Consumers: []v1alpha1.ServiceConsumer{{Peer: "peer1"}}},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := runtime.NewScheme()
+			require.NoError(t, clientgoscheme.AddToScheme(s))
+			s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.ExportedServices{}, &v1alpha1.ExportedServicesList{})
+			objects := c.services
+			if c.existing != nil {
+				objects = append(objects, c.existing)
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build()
+
+			controller := &Controller{
+				Client:                     fakeClient,
+				ReleaseNamespace:           releaseNamespace,
+				EnableConsulNamespaces:     c.enableNamespaces,
+				ConsulDestinationNamespace: "default",
+				EnableNSMirroring:          c.enableNamespaces,
+				NSMirroringPrefix:          "k8s-",
+				Log:                        logrtest.New(t),
+			}
+			_, err := controller.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Namespace: releaseNamespace, Name: "default"},
+			})
+			require.NoError(t, err)
+
+			namespace := releaseNamespace
+			if c.existing != nil {
+				namespace = c.existing.Namespace
+			}
+			var exportedServices v1alpha1.ExportedServices
+			err = fakeClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: "default"}, &exportedServices)
+			if c.expNotFound {
+				require.True(t, k8serrors.IsNotFound(err))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expServices, exportedServices.Spec.Services)
+			require.Equal(t, c.expManaged, exportedServices.Annotations[constants.AnnotationManagedExports])
+		})
+	}
+}
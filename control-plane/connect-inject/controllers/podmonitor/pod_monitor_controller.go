@@ -0,0 +1,205 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package podmonitor
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PodMonitorGVK is the group, version and kind of the Prometheus Operator PodMonitor resource.
+var PodMonitorGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "PodMonitor"}
+
+const (
+	// defaultResyncPeriod is how often the PodMonitors are applied. The Prometheus Operator CRDs
+	// can't be watched before they are installed, so they are checked for periodically.
+	defaultResyncPeriod = time.Minute
+)
+
+// Controller creates Prometheus Operator PodMonitors that scrape the metrics of injected sidecar
+// proxies and of the Consul control plane, so that Prometheus doesn't need hand-written scrape
+// configs. The PodMonitors scrape the port and path of the prometheus.io annotations that the
+// injector and the Helm chart already set on pods.
+//
+// Controller is a manager.Runnable. If the PodMonitor CRD isn't installed, it tries again on every
+// resync so that the PodMonitors are created once the Prometheus Operator is installed.
+type Controller struct {
+	client.Client
+	// APIReader reads the connect injector deployment, which isn't cached by the manager.
+	APIReader client.Reader
+	// Namespace is the namespace that the PodMonitors are created in.
+	Namespace string
+	// ReleaseName is the name of the Helm release. Control plane pods are selected by its
+	// release label.
+	ReleaseName string
+	// ResourcePrefix is the prefix of the names of the Helm chart's resources.
+	ResourcePrefix string
+	// Labels are added to the PodMonitors, e.g. to match the podMonitorSelector of the
+	// Prometheus resource.
+	Labels map[string]string
+	// ResyncPeriod is how often the PodMonitors are applied. Defaults to one minute.
+	ResyncPeriod time.Duration
+	// Log is the logger for this controller.
+	Log logr.Logger
+}
+
+// Start applies the PodMonitors until the context is cancelled.
+func (c *Controller) Start(ctx context.Context) error {
+	period := c.ResyncPeriod
+	if period == 0 {
+		period = defaultResyncPeriod
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		if err := c.apply(ctx); err != nil {
+			if meta.IsNoMatchError(err) {
+				c.Log.V(1).Info("PodMonitor CRD is not installed, skipping PodMonitors")
+			} else {
+				c.Log.Error(err, "failed to apply PodMonitors")
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// apply creates or updates the PodMonitors. It returns a no match error if the PodMonitor CRD
+// isn't installed.
+func (c *Controller) apply(ctx context.Context) error {
+	if _, err := c.Client.RESTMapper().RESTMapping(PodMonitorGVK.GroupKind(), PodMonitorGVK.Version); err != nil {
+		return err
+	}
+	owner, err := c.owner(ctx)
+	if err != nil {
+		return err
+	}
+	for _, podMonitor := range c.podMonitors() {
+		if owner != nil {
+			podMonitor.SetOwnerReferences([]metav1.OwnerReference{*owner})
+		}
+		if err := c.applyPodMonitor(ctx, podMonitor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Controller) applyPodMonitor(ctx context.Context, podMonitor *unstructured.Unstructured) error {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(PodMonitorGVK)
+	err := c.Client.Get(ctx, client.ObjectKeyFromObject(podMonitor), existing)
+	switch {
+	case k8serrors.IsNotFound(err):
+		c.Log.Info("creating PodMonitor", "name", podMonitor.GetName())
+		return c.Client.Create(ctx, podMonitor)
+	case err != nil:
+		return err
+	case equality.Semantic.DeepEqual(existing.Object["spec"], podMonitor.Object["spec"]) &&
+		equality.Semantic.DeepEqual(existing.GetLabels(), podMonitor.GetLabels()):
+		return nil
+	default:
+		existing.Object["spec"] = podMonitor.Object["spec"]
+		existing.SetLabels(podMonitor.GetLabels())
+		existing.SetOwnerReferences(podMonitor.GetOwnerReferences())
+		c.Log.Info("updating PodMonitor", "name", podMonitor.GetName())
+		return c.Client.Update(ctx, existing)
+	}
+}
+
+// owner returns the owner reference to the connect injector deployment so that the PodMonitors are
+// deleted with it. It returns nil if the deployment doesn't exist.
+func (c *Controller) owner(ctx context.Context) (*metav1.OwnerReference, error) {
+	var deployment appsv1.Deployment
+	err := c.APIReader.Get(ctx, types.NamespacedName{Namespace: c.Namespace, Name: c.ResourcePrefix + "-connect-injector"}, &deployment)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return metav1.NewControllerRef(&deployment, appsv1.SchemeGroupVersion.WithKind("Deployment")), nil
+}
+
+// podMonitors returns the PodMonitors for injected pods in all namespaces and for the control plane
+// pods of the Helm release.
+func (c *Controller) podMonitors() []*unstructured.Unstructured {
+	return []*unstructured.Unstructured{
+		c.podMonitor(c.ResourcePrefix+"-connect-injected-pods",
+			map[string]interface{}{constants.KeyInjectStatus: constants.Injected},
+			map[string]interface{}{"any": true}),
+		c.podMonitor(c.ResourcePrefix+"-control-plane",
+			map[string]interface{}{"release": c.ReleaseName},
+			map[string]interface{}{"matchNames": []interface{}{c.Namespace}}),
+	}
+}
+
+func (c *Controller) podMonitor(name string, matchLabels, namespaceSelector map[string]interface{}) *unstructured.Unstructured {
+	podMonitor := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"selector":          map[string]interface{}{"matchLabels": matchLabels},
+				"namespaceSelector": namespaceSelector,
+				"podMetricsEndpoints": []interface{}{
+					map[string]interface{}{"relabelings": scrapeRelabelings()},
+				},
+			},
+		},
+	}
+	podMonitor.SetGroupVersionKind(PodMonitorGVK)
+	podMonitor.SetName(name)
+	podMonitor.SetNamespace(c.Namespace)
+	labels := map[string]string{"app": "consul", "release": c.ReleaseName, "component": "connect-injector"}
+	for k, v := range c.Labels {
+		labels[k] = v
+	}
+	podMonitor.SetLabels(labels)
+	return podMonitor
+}
+
+// scrapeRelabelings returns relabelings that only keep pods with the prometheus.io/scrape
+// annotation and scrape the port and path of their prometheus.io/port and prometheus.io/path
+// annotations. Prometheus creates a target for every container port of a pod, so the container
+// label is dropped to deduplicate them into a single target for each pod.
+func scrapeRelabelings() []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"action":       "keep",
+			"sourceLabels": []interface{}{"__meta_kubernetes_pod_annotation_prometheus_io_scrape"},
+			"regex":        "true",
+		},
+		map[string]interface{}{
+			"action":       "replace",
+			"sourceLabels": []interface{}{"__meta_kubernetes_pod_annotation_prometheus_io_path"},
+			"regex":        "(.+)",
+			"targetLabel":  "__metrics_path__",
+		},
+		map[string]interface{}{
+			"action":       "replace",
+			"sourceLabels": []interface{}{"__address__", "__meta_kubernetes_pod_annotation_prometheus_io_port"},
+			"regex":        `([^:]+)(?::\d+)?;(\d+)`,
+			"replacement":  "$1:$2",
+			"targetLabel":  "__address__",
+		},
+		map[string]interface{}{
+			"action": "labeldrop",
+			"regex":  "container",
+		},
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package podmonitor
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestApply(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-connect-injector", Namespace: "consul", UID: "deployment-uid"},
+	}
+
+	cases := map[string]struct {
+		existing []runtime.Object
+		expOwner bool
+	}{
+		"creates PodMonitors owned by the injector deployment": {
+			existing: []runtime.Object{deployment},
+			expOwner: true,
+		},
+		"creates PodMonitors without the injector deployment": {},
+		"updates existing PodMonitors": {
+			existing: []runtime.Object{deployment, func() *unstructured.Unstructured {
+				podMonitor := &unstructured.Unstructured{Object: map[string]interface{}{
+					"spec": map[string]interface{}{"selector": map[string]interface{}{}},
+				}}
+				podMonitor.SetGroupVersionKind(PodMonitorGVK)
+				podMonitor.SetName("consul-control-plane")
+				podMonitor.SetNamespace("consul")
+				return podMonitor
+			}()},
+			expOwner: true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			fakeClient := fake.NewClientBuilder().
+				WithScheme(scheme.Scheme).
+				WithRESTMapper(testRESTMapper(true)).
+				WithRuntimeObjects(c.existing...).
+				Build()
+			controller := &Controller{
+				Client:         fakeClient,
+				APIReader:      fakeClient,
+				Namespace:      "consul",
+				ReleaseName:    "consul",
+				ResourcePrefix: "consul",
+				Labels:         map[string]string{"prometheus": "main"},
+				Log:            logrtest.New(t),
+			}
+			require.NoError(t, controller.apply(context.Background()))
+
+			injected := getPodMonitor(t, fakeClient, "consul-connect-injected-pods")
+			selector, _, _ := unstructured.NestedStringMap(injected.Object, "spec", "selector", "matchLabels")
+			require.Equal(t, map[string]string{"consul.hashicorp.com/connect-inject-status": "injected"}, selector)
+			anyNamespace, _, _ := unstructured.NestedBool(injected.Object, "spec", "namespaceSelector", "any")
+			require.True(t, anyNamespace)
+
+			controlPlane := getPodMonitor(t, fakeClient, "consul-control-plane")
+			selector, _, _ = unstructured.NestedStringMap(controlPlane.Object, "spec", "selector", "matchLabels")
+			require.Equal(t, map[string]string{"release": "consul"}, selector)
+			namespaces, _, _ := unstructured.NestedStringSlice(controlPlane.Object, "spec", "namespaceSelector", "matchNames")
+			require.Equal(t, []string{"consul"}, namespaces)
+
+			for _, podMonitor := range []*unstructured.Unstructured{injected, controlPlane} {
+				require.Equal(t, "main", podMonitor.GetLabels()["prometheus"])
+				endpoints, _, _ := unstructured.NestedSlice(podMonitor.Object, "spec", "podMetricsEndpoints")
+				require.Len(t, endpoints, 1)
+				if c.expOwner {
+					require.Len(t, podMonitor.GetOwnerReferences(), 1)
+					require.Equal(t, deployment.UID, podMonitor.GetOwnerReferences()[0].UID)
+				} else {
+					require.Empty(t, podMonitor.GetOwnerReferences())
+				}
+			}
+		})
+	}
+}
+
+func TestApply_PodMonitorCRDNotInstalled(t *testing.T) {
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithRESTMapper(testRESTMapper(false)).
+		Build()
+	controller := &Controller{
+		Client:         fakeClient,
+		APIReader:      fakeClient,
+		Namespace:      "consul",
+		ReleaseName:    "consul",
+		ResourcePrefix: "consul",
+		Log:            logrtest.New(t),
+	}
+	err := controller.apply(context.Background())
+	require.True(t, meta.IsNoMatchError(err), err)
+}
+
+func testRESTMapper(withPodMonitor bool) meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+	if withPodMonitor {
+		mapper.Add(PodMonitorGVK, meta.RESTScopeNamespace)
+	}
+	return mapper
+}
+
+func getPodMonitor(t *testing.T, c client.Client, name string) *unstructured.Unstructured {
+	t.Helper()
+	podMonitor := &unstructured.Unstructured{}
+	podMonitor.SetGroupVersionKind(PodMonitorGVK)
+	require.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "consul", Name: name}, podMonitor))
+	return podMonitor
+}
@@ -0,0 +1,325 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rolloutrestart
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// KindDeployment and KindStatefulSet are the kinds of workloads that can be restarted.
+	KindDeployment  = "deployment"
+	KindStatefulSet = "statefulset"
+
+	// annotationRestartedFor is set on the pod template of restarted workloads to the hash of the
+	// secrets that they were restarted for.
+	annotationRestartedFor = "consul.hashicorp.com/restarted-for-secrets"
+	// annotationRestartedAt is set on the pod template of restarted workloads to the time of the restart.
+	annotationRestartedAt = "consul.hashicorp.com/restarted-at"
+
+	// rolloutPollInterval is how often the progress of a restart is checked.
+	rolloutPollInterval = 10 * time.Second
+)
+
+// Target is a workload in the release namespace that is restarted when the watched secrets change.
+type Target struct {
+	Kind string
+	Name string
+}
+
+// ParseTarget parses a target in the form <kind>/<name>, e.g. statefulset/consul-server.
+func ParseTarget(raw string) (Target, error) {
+	kind, name, ok := strings.Cut(raw, "/")
+	kind = strings.ToLower(kind)
+	if !ok || name == "" || (kind != KindDeployment && kind != KindStatefulSet) {
+		return Target{}, fmt.Errorf("%q must be in the form %s/<name> or %s/<name>", raw, KindDeployment, KindStatefulSet)
+	}
+	return Target{Kind: kind, Name: name}, nil
+}
+
+func (t Target) String() string {
+	return t.Kind + "/" + t.Name
+}
+
+// Controller restarts workloads that consume secrets, such as the server TLS certificates, the gossip
+// encryption key or the enterprise license, when the secrets change. The workloads are restarted one
+// at a time in the configured order. Each rollout must complete before the next workload is restarted.
+//
+// The hashes of the secrets that the workloads were last restarted for are kept in a config map. Secrets
+// that aren't in the config map yet, e.g. when the controller first starts, are recorded without a restart.
+type Controller struct {
+	client.Client
+	// Namespace is the namespace of the secrets, the targets and the state config map.
+	Namespace string
+	// Secrets are the names of the watched secrets.
+	Secrets []string
+	// Targets are the workloads to restart, in order.
+	Targets []Target
+	// Pacing is the minimum time between the restarts of consecutive targets.
+	Pacing time.Duration
+	// StateConfigMapName is the name of the config map that the secret hashes are kept in.
+	StateConfigMapName string
+	// Log is the logger for this controller.
+	Log logr.Logger
+}
+
+// Reconcile restarts the targets if any of the watched secrets changed since they were last recorded.
+func (r *Controller) Reconcile(ctx context.Context, _ ctrl.Request) (ctrl.Result, error) {
+	state, err := r.stateConfigMap(ctx)
+	if err != nil {
+		r.Log.Error(err, "failed to get state config map", "name", r.StateConfigMapName)
+		return ctrl.Result{}, err
+	}
+
+	hashes, err := r.secretHashes(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	changed := false
+	baseline := false
+	for name, hash := range hashes {
+		recorded, ok := state.Data[name]
+		if !ok {
+			state.Data[name] = hash
+			baseline = true
+		} else if recorded != hash {
+			changed = true
+		}
+	}
+	if !changed {
+		if baseline {
+			return ctrl.Result{}, r.saveState(ctx, state)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	token := restartToken(hashes)
+	for _, target := range r.Targets {
+		done, err := r.restart(ctx, target, token)
+		if err != nil {
+			r.Log.Error(err, "failed to restart target", "target", target)
+			return ctrl.Result{}, err
+		}
+		if !done {
+			return ctrl.Result{RequeueAfter: rolloutPollInterval}, nil
+		}
+	}
+
+	r.Log.Info("restarted all targets for changed secrets")
+	for name, hash := range hashes {
+		state.Data[name] = hash
+	}
+	return ctrl.Result{}, r.saveState(ctx, state)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *Controller) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("rollout-restart").
+		Watches(
+			&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForSecrets),
+			builder.WithPredicates(predicate.NewPredicateFuncs(r.filterSecrets)),
+		).Complete(r)
+}
+
+// requestsForSecrets maps all watched secrets to a single request, so that the targets are
+// restarted once for changes to several secrets.
+func (r *Controller) requestsForSecrets(client.Object) []ctrl.Request {
+	return []ctrl.Request{{NamespacedName: types.NamespacedName{Namespace: r.Namespace, Name: r.StateConfigMapName}}}
+}
+
+// filterSecrets returns true for the watched secrets.
+func (r *Controller) filterSecrets(object client.Object) bool {
+	if object.GetNamespace() != r.Namespace {
+		return false
+	}
+	for _, name := range r.Secrets {
+		if object.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// secretHashes returns the hashes of the data of the watched secrets. Secrets that don't exist are skipped.
+func (r *Controller) secretHashes(ctx context.Context) (map[string]string, error) {
+	hashes := make(map[string]string, len(r.Secrets))
+	for _, name := range r.Secrets {
+		var secret corev1.Secret
+		err := r.Client.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: name}, &secret)
+		if k8serrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			r.Log.Error(err, "failed to get secret", "name", name)
+			return nil, err
+		}
+		hashes[name] = secretHash(secret)
+	}
+	return hashes, nil
+}
+
+// stateConfigMap returns the state config map, or a new one if it doesn't exist yet.
+func (r *Controller) stateConfigMap(ctx context.Context) (*corev1.ConfigMap, error) {
+	var state corev1.ConfigMap
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: r.StateConfigMapName}, &state)
+	if k8serrors.IsNotFound(err) {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: r.StateConfigMapName, Namespace: r.Namespace},
+			Data:       map[string]string{},
+		}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if state.Data == nil {
+		state.Data = map[string]string{}
+	}
+	return &state, nil
+}
+
+func (r *Controller) saveState(ctx context.Context, state *corev1.ConfigMap) error {
+	var err error
+	if state.ResourceVersion == "" {
+		err = r.Client.Create(ctx, state)
+	} else {
+		err = r.Client.Update(ctx, state)
+	}
+	if err != nil {
+		r.Log.Error(err, "failed to save state config map", "name", r.StateConfigMapName)
+	}
+	return err
+}
+
+// restart restarts the target for the secrets with the token unless it's already been restarted
+// for them. It returns true once the target's rollout is complete and the pacing has elapsed.
+// Targets that don't exist are skipped.
+func (r *Controller) restart(ctx context.Context, target Target, token string) (bool, error) {
+	var object client.Object
+	switch target.Kind {
+	case KindStatefulSet:
+		object = &appsv1.StatefulSet{}
+	default:
+		object = &appsv1.Deployment{}
+	}
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: target.Name}, object)
+	if k8serrors.IsNotFound(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	template := podTemplate(object)
+	if template.Annotations[annotationRestartedFor] == token {
+		// A missing or invalid restart time is the zero time, so the pacing has elapsed.
+		restartedAt, _ := time.Parse(time.RFC3339, template.Annotations[annotationRestartedAt])
+		return rolledOut(object) && time.Since(restartedAt) >= r.Pacing, nil
+	}
+
+	r.Log.Info("restarting target for changed secrets", "target", target)
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[annotationRestartedFor] = token
+	template.Annotations[annotationRestartedAt] = time.Now().UTC().Format(time.RFC3339)
+	return false, r.Client.Update(ctx, object)
+}
+
+func podTemplate(object client.Object) *corev1.PodTemplateSpec {
+	switch o := object.(type) {
+	case *appsv1.StatefulSet:
+		return &o.Spec.Template
+	case *appsv1.Deployment:
+		return &o.Spec.Template
+	}
+	return nil
+}
+
+// rolledOut returns whether all replicas of the workload run its latest pod template. For a
+// StatefulSet with a rolling update partition, only the replicas with an ordinal at or above the
+// partition are updated, so the others aren't waited for.
+func rolledOut(object client.Object) bool {
+	switch o := object.(type) {
+	case *appsv1.StatefulSet:
+		replicas := int32(1)
+		if o.Spec.Replicas != nil {
+			replicas = *o.Spec.Replicas
+		}
+		var partition int32
+		if ru := o.Spec.UpdateStrategy.RollingUpdate; ru != nil && ru.Partition != nil && *ru.Partition > 0 {
+			partition = *ru.Partition
+		}
+		if partition > replicas {
+			partition = replicas
+		}
+		if partition == 0 && o.Status.UpdateRevision != o.Status.CurrentRevision {
+			return false
+		}
+		return o.Status.ObservedGeneration >= o.Generation &&
+			o.Status.UpdatedReplicas >= replicas-partition &&
+			o.Status.ReadyReplicas == replicas
+	case *appsv1.Deployment:
+		replicas := int32(1)
+		if o.Spec.Replicas != nil {
+			replicas = *o.Spec.Replicas
+		}
+		return o.Status.ObservedGeneration >= o.Generation &&
+			o.Status.UpdatedReplicas == replicas &&
+			o.Status.Replicas == replicas &&
+			o.Status.AvailableReplicas == replicas
+	}
+	return false
+}
+
+// secretHash returns a hash of the secret's data.
+func secretHash(secret corev1.Secret) string {
+	keys := make([]string, 0, len(secret.Data))
+	for k := range secret.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write(secret.Data[k])
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// restartToken returns a hash of the secret hashes that identifies a set of secret changes.
+func restartToken(hashes map[string]string) string {
+	names := make([]string, 0, len(hashes))
+	for name := range hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		h.Write([]byte(name + "=" + hashes[name] + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
@@ -0,0 +1,281 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rolloutrestart
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcile(t *testing.T) {
+	const (
+		namespace = "consul"
+		stateName = "consul-rollout-restart-state"
+	)
+	oldCA := corev1.Secret{Data: map[string][]byte{"tls.crt": []byte("old")}}
+	newCA := corev1.Secret{Data: map[string][]byte{"tls.crt": []byte("new")}}
+	gossip := corev1.Secret{Data: map[string][]byte{"key": []byte("gossip")}}
+	newHashes := map[string]string{"consul-ca-cert": secretHash(newCA), "consul-gossip-key": secretHash(gossip)}
+	token := restartToken(newHashes)
+	restarted := map[string]string{
+		annotationRestartedFor: token,
+		annotationRestartedAt:  time.Now().Add(-time.Minute).UTC().Format(time.RFC3339),
+	}
+
+	cases := map[string]struct {
+		state               map[string]string
+		serverAnnotations   map[string]string
+		serverRolledOut     bool
+		injectorExists      bool
+		injectorAnnotations map[string]string
+		injectorRolledOut   bool
+		pacing              time.Duration
+		expState            map[string]string
+		expServerToken      string
+		expInjectorToken    string
+		expRequeue          bool
+	}{
+		"records the secret hashes without a restart when there's no state": {
+			injectorExists: true,
+			expState:       newHashes,
+		},
+		"does nothing when the secrets are unchanged": {
+			state:          newHashes,
+			injectorExists: true,
+			expState:       newHashes,
+		},
+		"restarts the first target when a secret changed": {
+			state:          map[string]string{"consul-ca-cert": secretHash(oldCA), "consul-gossip-key": secretHash(gossip)},
+			injectorExists: true,
+			expState:       map[string]string{"consul-ca-cert": secretHash(oldCA), "consul-gossip-key": secretHash(gossip)},
+			expServerToken: token,
+			expRequeue:     true,
+		},
+		"waits for the rollout of the first target": {
+			state:             map[string]string{"consul-ca-cert": secretHash(oldCA), "consul-gossip-key": secretHash(gossip)},
+			serverAnnotations: restarted,
+			injectorExists:    true,
+			expState:          map[string]string{"consul-ca-cert": secretHash(oldCA), "consul-gossip-key": secretHash(gossip)},
+			expServerToken:    token,
+			expRequeue:        true,
+		},
+		"waits for the pacing after the first target": {
+			state:             map[string]string{"consul-ca-cert": secretHash(oldCA), "consul-gossip-key": secretHash(gossip)},
+			serverAnnotations: restarted,
+			serverRolledOut:   true,
+			injectorExists:    true,
+			pacing:            time.Hour,
+			expState:          map[string]string{"consul-ca-cert": secretHash(oldCA), "consul-gossip-key": secretHash(gossip)},
+			expServerToken:    token,
+			expRequeue:        true,
+		},
+		"restarts the next target once the first one rolled out": {
+			state:             map[string]string{"consul-ca-cert": secretHash(oldCA), "consul-gossip-key": secretHash(gossip)},
+			serverAnnotations: restarted,
+			serverRolledOut:   true,
+			injectorExists:    true,
+			pacing:            time.Second,
+			expState:          map[string]string{"consul-ca-cert": secretHash(oldCA), "consul-gossip-key": secretHash(gossip)},
+			expServerToken:    token,
+			expInjectorToken:  token,
+			expRequeue:        true,
+		},
+		"records the secret hashes once all targets rolled out": {
+			state:               map[string]string{"consul-ca-cert": secretHash(oldCA), "consul-gossip-key": secretHash(gossip)},
+			serverAnnotations:   restarted,
+			serverRolledOut:     true,
+			injectorExists:      true,
+			injectorAnnotations: restarted,
+			injectorRolledOut:   true,
+			expState:            newHashes,
+			expServerToken:      token,
+			expInjectorToken:    token,
+		},
+		"skips targets that don't exist": {
+			state:             map[string]string{"consul-ca-cert": secretHash(oldCA), "consul-gossip-key": secretHash(gossip)},
+			serverAnnotations: restarted,
+			serverRolledOut:   true,
+			expState:          newHashes,
+			expServerToken:    token,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "consul-server", Namespace: namespace},
+				Spec: appsv1.StatefulSetSpec{
+					Replicas: pointer.Int32(3),
+					Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Annotations: c.serverAnnotations}},
+				},
+				Status: appsv1.StatefulSetStatus{UpdatedReplicas: 3, ReadyReplicas: 3, CurrentRevision: "1", UpdateRevision: "2"},
+			}
+			if c.serverRolledOut {
+				server.Status.CurrentRevision = "2"
+			}
+			objects := []client.Object{
+				&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "consul-ca-cert", Namespace: namespace}, Data: newCA.Data},
+				&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "consul-gossip-key", Namespace: namespace}, Data: gossip.Data},
+				server,
+			}
+			if c.injectorExists {
+				injector := &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Name: "consul-connect-injector", Namespace: namespace},
+					Spec: appsv1.DeploymentSpec{
+						Replicas: pointer.Int32(2),
+						Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Annotations: c.injectorAnnotations}},
+					},
+				}
+				if c.injectorRolledOut {
+					injector.Status = appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}
+				}
+				objects = append(objects, injector)
+			}
+			if c.state != nil {
+				objects = append(objects, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: stateName, Namespace: namespace},
+					Data:       c.state,
+				})
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+
+			controller := &Controller{
+				Client:    fakeClient,
+				Namespace: namespace,
+				// The license secret doesn't exist and is skipped.
+				Secrets: []string{"consul-ca-cert", "consul-gossip-key", "consul-license"},
+				Targets: []Target{
+					{Kind: KindStatefulSet, Name: "consul-server"},
+					{Kind: KindDeployment, Name: "consul-connect-injector"},
+				},
+				Pacing:             c.pacing,
+				StateConfigMapName: stateName,
+				Log:                logrtest.New(t),
+			}
+			resp, err := controller.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Namespace: namespace, Name: stateName},
+			})
+			require.NoError(t, err)
+			require.Equal(t, c.expRequeue, resp.RequeueAfter > 0)
+
+			var state corev1.ConfigMap
+			require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: stateName}, &state))
+			require.Equal(t, c.expState, state.Data)
+
+			var updatedServer appsv1.StatefulSet
+			require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: "consul-server"}, &updatedServer))
+			require.Equal(t, c.expServerToken, updatedServer.Spec.Template.Annotations[annotationRestartedFor])
+
+			if c.injectorExists {
+				var updatedInjector appsv1.Deployment
+				require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: "consul-connect-injector"}, &updatedInjector))
+				require.Equal(t, c.expInjectorToken, updatedInjector.Spec.Template.Annotations[annotationRestartedFor])
+			}
+		})
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	cases := map[string]struct {
+		raw       string
+		expTarget Target
+		expErr    string
+	}{
+		"statefulset": {
+			raw:       "statefulset/consul-server",
+			expTarget: Target{Kind: KindStatefulSet, Name: "consul-server"},
+		},
+		"deployment": {
+			raw:       "Deployment/consul-connect-injector",
+			expTarget: Target{Kind: KindDeployment, Name: "consul-connect-injector"},
+		},
+		"unsupported kind": {
+			raw:    "daemonset/consul-client",
+			expErr: `"daemonset/consul-client" must be in the form deployment/<name> or statefulset/<name>`,
+		},
+		"missing name": {
+			raw:    "deployment/",
+			expErr: `"deployment/" must be in the form deployment/<name> or statefulset/<name>`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			target, err := ParseTarget(c.raw)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expTarget, target)
+		})
+	}
+}
+
+func TestRolledOut_StatefulSet(t *testing.T) {
+	statefulSet := func(partition *int32, status appsv1.StatefulSetStatus) *appsv1.StatefulSet {
+		sts := &appsv1.StatefulSet{
+			Spec: appsv1.StatefulSetSpec{Replicas: pointer.Int32(3)},
+		}
+		if partition != nil {
+			sts.Spec.UpdateStrategy.RollingUpdate = &appsv1.RollingUpdateStatefulSetStrategy{Partition: partition}
+		}
+		sts.Status = status
+		return sts
+	}
+	cases := map[string]struct {
+		sts *appsv1.StatefulSet
+		exp bool
+	}{
+		"all replicas updated": {
+			sts: statefulSet(nil, appsv1.StatefulSetStatus{
+				UpdatedReplicas: 3, ReadyReplicas: 3, CurrentRevision: "new", UpdateRevision: "new",
+			}),
+			exp: true,
+		},
+		"replicas still updating": {
+			sts: statefulSet(nil, appsv1.StatefulSetStatus{
+				UpdatedReplicas: 2, ReadyReplicas: 3, CurrentRevision: "old", UpdateRevision: "new",
+			}),
+		},
+		"replicas above the partition updated": {
+			sts: statefulSet(pointer.Int32(2), appsv1.StatefulSetStatus{
+				UpdatedReplicas: 1, ReadyReplicas: 3, CurrentRevision: "old", UpdateRevision: "new",
+			}),
+			exp: true,
+		},
+		"replicas above the partition still updating": {
+			sts: statefulSet(pointer.Int32(1), appsv1.StatefulSetStatus{
+				UpdatedReplicas: 1, ReadyReplicas: 3, CurrentRevision: "old", UpdateRevision: "new",
+			}),
+		},
+		"partition above the replicas": {
+			sts: statefulSet(pointer.Int32(5), appsv1.StatefulSetStatus{
+				ReadyReplicas: 3, CurrentRevision: "old", UpdateRevision: "new",
+			}),
+			exp: true,
+		},
+		"replicas not ready": {
+			sts: statefulSet(pointer.Int32(2), appsv1.StatefulSetStatus{
+				UpdatedReplicas: 1, ReadyReplicas: 2, CurrentRevision: "old", UpdateRevision: "new",
+			}),
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.exp, rolledOut(c.sts))
+		})
+	}
+}
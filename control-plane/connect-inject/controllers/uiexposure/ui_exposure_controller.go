@@ -0,0 +1,616 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package uiexposure
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	gatewaycommon "github.com/hashicorp/consul-k8s/control-plane/api-gateway/common"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	gwv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+const (
+	// TypeIngress exposes the UI with a Kubernetes Ingress.
+	TypeIngress = "ingress"
+	// TypeRoute exposes the UI with an OpenShift Route.
+	TypeRoute = "route"
+	// TypeGateway exposes the UI with an HTTPRoute that is attached to a Gateway. The Gateway
+	// can't be a Consul API gateway because those only route to services in the mesh.
+	TypeGateway = "gateway"
+
+	// DefaultOIDCProxyImage is the image of the OIDC auth proxy if none is set.
+	DefaultOIDCProxyImage = "quay.io/oauth2-proxy/oauth2-proxy:v7.4.0"
+
+	// labelUIExposure is added to the resources that are generated by the controller so that
+	// resources of other exposure types are only deleted if the controller created them.
+	labelUIExposure = "consul.hashicorp.com/ui-exposure"
+
+	// oidcProxyPort is the port that the OIDC auth proxy listens on.
+	oidcProxyPort = 4180
+
+	// defaultResyncPeriod is how often the resources are applied if the CRD of the exposure type
+	// isn't watched. The OpenShift Route and HTTPRoute CRDs can't be watched if they aren't
+	// installed when the controller starts, so they are checked for periodically.
+	defaultResyncPeriod = time.Minute
+)
+
+var (
+	// RouteGVK is the group, version and kind of the OpenShift Route resource.
+	RouteGVK = schema.GroupVersionKind{Group: "route.openshift.io", Version: "v1", Kind: "Route"}
+
+	httpRouteGVK = gwv1beta1.SchemeGroupVersion.WithKind("HTTPRoute")
+)
+
+// OIDC configures an OIDC auth proxy in front of the UI.
+type OIDC struct {
+	// IssuerURL is the URL of the OIDC issuer.
+	IssuerURL string
+	// SecretName is the name of the secret with the client-id, client-secret and cookie-secret keys
+	// of the proxy.
+	SecretName string
+	// Image is the image of the proxy. Defaults to DefaultOIDCProxyImage.
+	Image string
+}
+
+// Controller exposes the Consul UI service outside of the cluster with an Ingress, an OpenShift
+// Route or an HTTPRoute attached to a Gateway. If OIDC is set, the UI is exposed through an
+// oauth2-proxy deployment that only lets users that logged in with the OIDC provider through.
+//
+// The generated resources are owned by the UI service so that they are deleted with it. Resources
+// of the exposure types that aren't configured are deleted so that the exposure type can be
+// changed on upgrades.
+//
+// The controller watches the UI service and the generated resources. If the CRD of the exposure
+// type isn't installed, it tries again on every resync.
+type Controller struct {
+	client.Client
+	// Namespace is the namespace of the UI service and of the generated resources.
+	Namespace string
+	// ReleaseName is the name of the Helm release.
+	ReleaseName string
+	// ServiceName is the name of the UI service.
+	ServiceName string
+	// Type is how the UI is exposed. One of TypeIngress, TypeRoute or TypeGateway.
+	Type string
+	// Host is the host name that the UI is exposed on. If empty, requests for all hosts are routed
+	// to the UI.
+	Host string
+	// IngressClassName is the class of the Ingress.
+	IngressClassName string
+	// TLSSecretName is the name of the secret with the certificate of the Ingress.
+	TLSSecretName string
+	// GatewayName and GatewayNamespace are the Gateway that the HTTPRoute is attached to.
+	GatewayName      string
+	GatewayNamespace string
+	// OIDC configures an OIDC auth proxy in front of the UI. If nil, the UI is exposed directly.
+	OIDC *OIDC
+	// ResyncPeriod is how often the resources are applied if the CRD of the exposure type isn't
+	// watched. Defaults to one minute.
+	ResyncPeriod time.Duration
+	// Log is the logger for this controller.
+	Log logr.Logger
+
+	// gatewayReader reads the Gateway and GatewayClass of the HTTPRoute, which aren't in the
+	// release namespace. Defaults to Client.
+	gatewayReader client.Reader
+	// unwatchedKinds are the kinds whose CRDs weren't installed when the controller started.
+	unwatchedKinds []string
+}
+
+// SetupWithManager sets up the controller with the manager. The generated resources are only read
+// and watched in the release namespace, so the controller only needs a Role there.
+func (c *Controller) SetupWithManager(mgr ctrl.Manager) error {
+	namespaceCache, err := cache.New(mgr.GetConfig(), cache.Options{
+		Scheme:    mgr.GetScheme(),
+		Mapper:    mgr.GetRESTMapper(),
+		Namespace: c.Namespace,
+	})
+	if err != nil {
+		return err
+	}
+	if err := mgr.Add(namespaceCache); err != nil {
+		return err
+	}
+	apiClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+	if err != nil {
+		return err
+	}
+	c.Client, err = client.NewDelegatingClient(client.NewDelegatingClientInput{
+		CacheReader:       namespaceCache,
+		Client:            apiClient,
+		CacheUnstructured: true,
+	})
+	if err != nil {
+		return err
+	}
+	c.gatewayReader = mgr.GetClient()
+
+	watched := []client.Object{&corev1.Service{}, &appsv1.Deployment{}, &networkingv1.Ingress{}}
+	for _, obj := range []client.Object{c.route(), &gwv1beta1.HTTPRoute{}} {
+		gvk, err := apiutil.GVKForObject(obj, mgr.GetScheme())
+		if err != nil {
+			return err
+		}
+		if _, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			if !meta.IsNoMatchError(err) {
+				return err
+			}
+			// The CRD isn't installed, so changes to the resources of this type aren't watched and
+			// the resources are applied periodically instead once the CRD is installed.
+			c.unwatchedKinds = append(c.unwatchedKinds, gvk.Kind)
+			continue
+		}
+		watched = append(watched, obj)
+	}
+
+	builder := ctrl.NewControllerManagedBy(mgr).Named("ui-exposure")
+	for _, obj := range watched {
+		builder = builder.Watches(
+			source.NewKindWithCache(obj, namespaceCache),
+			handler.EnqueueRequestsFromMapFunc(c.requestsForUIResource),
+		)
+	}
+	return builder.Complete(c)
+}
+
+// Reconcile applies the resources that expose the UI. If the CRD of the exposure type isn't
+// installed or wasn't installed when the controller started, it's reconciled again after the resync
+// period.
+func (c *Controller) Reconcile(ctx context.Context, _ ctrl.Request) (ctrl.Result, error) {
+	err := c.apply(ctx)
+	if meta.IsNoMatchError(err) {
+		c.Log.Info("CRD for the UI exposure type is not installed, skipping", "type", c.Type)
+		return ctrl.Result{RequeueAfter: c.resyncPeriod()}, nil
+	}
+	if err != nil {
+		c.Log.Error(err, "failed to expose the UI", "type", c.Type)
+		return ctrl.Result{}, err
+	}
+	if len(c.unwatchedKinds) > 0 {
+		return ctrl.Result{RequeueAfter: c.resyncPeriod()}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// requestsForUIResource returns the request for the UI service if the object is the UI service or
+// one of the resources that are generated for it. All generated resources are named after the UI
+// service or the OIDC auth proxy.
+func (c *Controller) requestsForUIResource(obj client.Object) []ctrl.Request {
+	if obj.GetNamespace() != c.Namespace || (obj.GetName() != c.ServiceName && obj.GetName() != c.oidcProxyName()) {
+		return nil
+	}
+	return []ctrl.Request{{NamespacedName: types.NamespacedName{Namespace: c.Namespace, Name: c.ServiceName}}}
+}
+
+func (c *Controller) resyncPeriod() time.Duration {
+	if c.ResyncPeriod == 0 {
+		return defaultResyncPeriod
+	}
+	return c.ResyncPeriod
+}
+
+// apply creates or updates the resources that expose the UI and deletes the ones of other exposure
+// types. It returns a no match error if the CRD of the exposure type isn't installed.
+func (c *Controller) apply(ctx context.Context) error {
+	var service corev1.Service
+	err := c.Client.Get(ctx, types.NamespacedName{Namespace: c.Namespace, Name: c.ServiceName}, &service)
+	if k8serrors.IsNotFound(err) {
+		c.Log.V(1).Info("UI service does not exist, skipping", "name", c.ServiceName)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	owner := metav1.NewControllerRef(&service, corev1.SchemeGroupVersion.WithKind("Service"))
+
+	backendName, backendPort := service.Name, uiPort(&service)
+	if backendPort == nil {
+		return fmt.Errorf("UI service %s has no ports", service.Name)
+	}
+	if c.OIDC != nil {
+		if backendPort.Name != "http" {
+			return fmt.Errorf("UI service %s has no http port for the OIDC auth proxy", service.Name)
+		}
+		if err := c.applyOIDCProxy(ctx, owner, backendPort.Port); err != nil {
+			return err
+		}
+		backendName, backendPort = c.oidcProxyName(), &corev1.ServicePort{Name: "http", Port: oidcProxyPort}
+	} else if err := c.deleteOIDCProxy(ctx); err != nil {
+		return err
+	}
+
+	if err := c.applyExposure(ctx, owner, backendName, backendPort); err != nil {
+		return err
+	}
+	return c.deleteOtherExposures(ctx)
+}
+
+func (c *Controller) applyExposure(ctx context.Context, owner *metav1.OwnerReference, backendName string, backendPort *corev1.ServicePort) error {
+	switch c.Type {
+	case TypeIngress:
+		ingress := &networkingv1.Ingress{ObjectMeta: c.objectMeta(c.ServiceName)}
+		return c.createOrUpdate(ctx, ingress, owner, func() {
+			if spec := c.ingressSpec(backendName, backendPort); !equality.Semantic.DeepDerivative(spec, ingress.Spec) {
+				ingress.Spec = spec
+			}
+		})
+	case TypeRoute:
+		if _, err := c.Client.RESTMapper().RESTMapping(RouteGVK.GroupKind(), RouteGVK.Version); err != nil {
+			return err
+		}
+		route := c.route()
+		return c.createOrUpdate(ctx, route, owner, func() {
+			existing, _, _ := unstructured.NestedMap(route.Object, "spec")
+			if spec := c.routeSpec(backendName, backendPort); !equality.Semantic.DeepDerivative(spec, existing) {
+				route.Object["spec"] = spec
+			}
+		})
+	case TypeGateway:
+		if _, err := c.Client.RESTMapper().RESTMapping(httpRouteGVK.GroupKind(), httpRouteGVK.Version); err != nil {
+			return err
+		}
+		if err := c.checkGateway(ctx); err != nil {
+			return err
+		}
+		httpRoute := &gwv1beta1.HTTPRoute{ObjectMeta: c.objectMeta(c.ServiceName)}
+		return c.createOrUpdate(ctx, httpRoute, owner, func() {
+			if spec := c.httpRouteSpec(backendName, backendPort); !equality.Semantic.DeepDerivative(spec, httpRoute.Spec) {
+				httpRoute.Spec = spec
+			}
+		})
+	default:
+		return fmt.Errorf("unknown UI exposure type %q", c.Type)
+	}
+}
+
+// checkGateway returns an error if the Gateway that the HTTPRoute is attached to is a Consul API
+// gateway. Consul API gateways only route to services in the mesh and the UI service isn't.
+func (c *Controller) checkGateway(ctx context.Context) error {
+	reader := c.gatewayReader
+	if reader == nil {
+		reader = c.Client
+	}
+	namespace := c.GatewayNamespace
+	if namespace == "" {
+		namespace = c.Namespace
+	}
+	var gateway gwv1beta1.Gateway
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: c.GatewayName}, &gateway); err != nil {
+		return fmt.Errorf("failed to get gateway %s/%s: %w", namespace, c.GatewayName, err)
+	}
+	var gatewayClass gwv1beta1.GatewayClass
+	if err := reader.Get(ctx, types.NamespacedName{Name: string(gateway.Spec.GatewayClassName)}, &gatewayClass); err != nil {
+		return fmt.Errorf("failed to get gateway class %s: %w", gateway.Spec.GatewayClassName, err)
+	}
+	if gatewayClass.Spec.ControllerName == gatewaycommon.GatewayClassControllerName {
+		return fmt.Errorf("gateway %s/%s is a Consul API gateway, which only routes to services in the mesh: "+
+			"the UI must be exposed with a gateway of another controller", namespace, c.GatewayName)
+	}
+	return nil
+}
+
+// deleteOtherExposures deletes the resources of the exposure types that aren't configured if they
+// were generated by this controller. Types whose CRDs aren't installed are skipped.
+func (c *Controller) deleteOtherExposures(ctx context.Context) error {
+	if c.Type != TypeIngress {
+		if err := c.deleteGenerated(ctx, &networkingv1.Ingress{}); err != nil {
+			return err
+		}
+	}
+	if c.Type != TypeRoute {
+		if err := c.deleteGenerated(ctx, c.route()); err != nil && !meta.IsNoMatchError(err) {
+			return err
+		}
+	}
+	if c.Type != TypeGateway {
+		if err := c.deleteGenerated(ctx, &gwv1beta1.HTTPRoute{}); err != nil && !meta.IsNoMatchError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Controller) applyOIDCProxy(ctx context.Context, owner *metav1.OwnerReference, uiPort int32) error {
+	name := c.oidcProxyName()
+	deployment := &appsv1.Deployment{ObjectMeta: c.objectMeta(name)}
+	if err := c.createOrUpdate(ctx, deployment, owner, func() {
+		if spec := c.oidcProxyDeploymentSpec(uiPort); !equality.Semantic.DeepDerivative(spec, deployment.Spec) {
+			deployment.Spec = spec
+		}
+	}); err != nil {
+		return err
+	}
+	service := &corev1.Service{ObjectMeta: c.objectMeta(name)}
+	return c.createOrUpdate(ctx, service, owner, func() {
+		// Only the selector and ports are set so that the cluster IP that Kubernetes assigned
+		// isn't reset on updates.
+		ports := []corev1.ServicePort{{
+			Name:       "http",
+			Port:       oidcProxyPort,
+			TargetPort: intstr.FromString("http"),
+		}}
+		if !equality.Semantic.DeepDerivative(ports, service.Spec.Ports) {
+			service.Spec.Ports = ports
+		}
+		service.Spec.Selector = c.oidcProxyLabels()
+	})
+}
+
+func (c *Controller) deleteOIDCProxy(ctx context.Context) error {
+	if err := c.deleteGenerated(ctx, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: c.oidcProxyName()}}); err != nil {
+		return err
+	}
+	return c.deleteGenerated(ctx, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: c.oidcProxyName()}})
+}
+
+// createOrUpdate creates or updates the object with the labels and owner of the generated resources
+// and the spec that mutate sets. mutate only replaces the spec if it differs from the existing
+// spec in the fields that the controller sets, so that fields defaulted by the API server don't
+// cause an update on every resync.
+func (c *Controller) createOrUpdate(ctx context.Context, obj client.Object, owner *metav1.OwnerReference, mutate func()) error {
+	kind := fmt.Sprintf("%T", obj)
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		kind = u.GetKind()
+	}
+	result, err := controllerutil.CreateOrUpdate(ctx, c.Client, obj, func() error {
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		for k, v := range c.labels() {
+			labels[k] = v
+		}
+		obj.SetLabels(labels)
+		obj.SetOwnerReferences([]metav1.OwnerReference{*owner})
+		mutate()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if result != controllerutil.OperationResultNone {
+		c.Log.Info("applied UI exposure resource", "kind", kind, "name", obj.GetName(), "result", result)
+	}
+	return nil
+}
+
+// deleteGenerated deletes the object if it was generated by this controller. The object's name
+// defaults to the name of the UI service.
+func (c *Controller) deleteGenerated(ctx context.Context, obj client.Object) error {
+	if obj.GetName() == "" {
+		obj.SetName(c.ServiceName)
+	}
+	err := c.Client.Get(ctx, types.NamespacedName{Namespace: c.Namespace, Name: obj.GetName()}, obj)
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if obj.GetLabels()[labelUIExposure] != "true" {
+		return nil
+	}
+	c.Log.Info("deleting UI exposure resource", "name", obj.GetName())
+	if err := c.Client.Delete(ctx, obj); err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (c *Controller) ingressSpec(backendName string, backendPort *corev1.ServicePort) networkingv1.IngressSpec {
+	pathType := networkingv1.PathTypePrefix
+	spec := networkingv1.IngressSpec{
+		Rules: []networkingv1.IngressRule{{
+			Host: c.Host,
+			IngressRuleValue: networkingv1.IngressRuleValue{
+				HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     "/",
+						PathType: &pathType,
+						Backend: networkingv1.IngressBackend{
+							Service: &networkingv1.IngressServiceBackend{
+								Name: backendName,
+								Port: networkingv1.ServiceBackendPort{Number: backendPort.Port},
+							},
+						},
+					}},
+				},
+			},
+		}},
+	}
+	if c.IngressClassName != "" {
+		className := c.IngressClassName
+		spec.IngressClassName = &className
+	}
+	if c.TLSSecretName != "" {
+		tls := networkingv1.IngressTLS{SecretName: c.TLSSecretName}
+		if c.Host != "" {
+			tls.Hosts = []string{c.Host}
+		}
+		spec.TLS = []networkingv1.IngressTLS{tls}
+	}
+	return spec
+}
+
+func (c *Controller) route() *unstructured.Unstructured {
+	route := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	route.SetGroupVersionKind(RouteGVK)
+	route.SetName(c.ServiceName)
+	route.SetNamespace(c.Namespace)
+	return route
+}
+
+// routeSpec returns the spec of the OpenShift Route. The router terminates TLS with its default
+// certificate, unless the backend only serves HTTPS, in which case TLS is passed through to it.
+func (c *Controller) routeSpec(backendName string, backendPort *corev1.ServicePort) map[string]interface{} {
+	termination := map[string]interface{}{
+		"termination":                   "edge",
+		"insecureEdgeTerminationPolicy": "Redirect",
+	}
+	if backendPort.Name == "https" {
+		termination = map[string]interface{}{
+			"termination":                   "passthrough",
+			"insecureEdgeTerminationPolicy": "Redirect",
+		}
+	}
+	spec := map[string]interface{}{
+		"to": map[string]interface{}{
+			"kind":   "Service",
+			"name":   backendName,
+			"weight": int64(100),
+		},
+		"port": map[string]interface{}{"targetPort": backendPort.Name},
+		"tls":  termination,
+	}
+	if c.Host != "" {
+		spec["host"] = c.Host
+	}
+	return spec
+}
+
+func (c *Controller) httpRouteSpec(backendName string, backendPort *corev1.ServicePort) gwv1beta1.HTTPRouteSpec {
+	gatewayNamespace := gwv1beta1.Namespace(c.GatewayNamespace)
+	if gatewayNamespace == "" {
+		gatewayNamespace = gwv1beta1.Namespace(c.Namespace)
+	}
+	port := gwv1beta1.PortNumber(backendPort.Port)
+	spec := gwv1beta1.HTTPRouteSpec{
+		CommonRouteSpec: gwv1beta1.CommonRouteSpec{
+			ParentRefs: []gwv1beta1.ParentReference{{
+				Name:      gwv1beta1.ObjectName(c.GatewayName),
+				Namespace: &gatewayNamespace,
+			}},
+		},
+		Rules: []gwv1beta1.HTTPRouteRule{{
+			BackendRefs: []gwv1beta1.HTTPBackendRef{{
+				BackendRef: gwv1beta1.BackendRef{
+					BackendObjectReference: gwv1beta1.BackendObjectReference{
+						Name: gwv1beta1.ObjectName(backendName),
+						Port: &port,
+					},
+				},
+			}},
+		}},
+	}
+	if c.Host != "" {
+		spec.Hostnames = []gwv1beta1.Hostname{gwv1beta1.Hostname(c.Host)}
+	}
+	return spec
+}
+
+// oidcProxyDeploymentSpec returns the spec of the oauth2-proxy deployment that authenticates
+// requests with the OIDC provider and proxies them to the http port of the UI service.
+func (c *Controller) oidcProxyDeploymentSpec(uiPort int32) appsv1.DeploymentSpec {
+	image := c.OIDC.Image
+	if image == "" {
+		image = DefaultOIDCProxyImage
+	}
+	args := []string{
+		"--provider=oidc",
+		"--oidc-issuer-url=" + c.OIDC.IssuerURL,
+		fmt.Sprintf("--upstream=http://%s.%s.svc:%d", c.ServiceName, c.Namespace, uiPort),
+		fmt.Sprintf("--http-address=0.0.0.0:%d", oidcProxyPort),
+		"--email-domain=*",
+		"--reverse-proxy=true",
+		"--skip-provider-button=true",
+	}
+	if c.Host != "" {
+		scheme := "http"
+		if c.Type == TypeRoute || c.TLSSecretName != "" {
+			scheme = "https"
+		}
+		args = append(args, fmt.Sprintf("--redirect-url=%s://%s/oauth2/callback", scheme, c.Host))
+	}
+	secretEnv := func(name, key string) corev1.EnvVar {
+		return corev1.EnvVar{
+			Name: name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: c.OIDC.SecretName},
+					Key:                  key,
+				},
+			},
+		}
+	}
+	replicas := int32(1)
+	return appsv1.DeploymentSpec{
+		Replicas: &replicas,
+		Selector: &metav1.LabelSelector{MatchLabels: c.oidcProxyLabels()},
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: c.oidcProxyLabels()},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:  "oauth2-proxy",
+					Image: image,
+					Args:  args,
+					Env: []corev1.EnvVar{
+						secretEnv("OAUTH2_PROXY_CLIENT_ID", "client-id"),
+						secretEnv("OAUTH2_PROXY_CLIENT_SECRET", "client-secret"),
+						secretEnv("OAUTH2_PROXY_COOKIE_SECRET", "cookie-secret"),
+					},
+					Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: oidcProxyPort}},
+					ReadinessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							HTTPGet: &corev1.HTTPGetAction{Path: "/ping", Port: intstr.FromString("http")},
+						},
+					},
+				}},
+			},
+		},
+	}
+}
+
+func (c *Controller) oidcProxyName() string {
+	return c.ServiceName + "-auth-proxy"
+}
+
+func (c *Controller) oidcProxyLabels() map[string]string {
+	return map[string]string{"app": "consul", "release": c.ReleaseName, "component": "ui-auth-proxy"}
+}
+
+func (c *Controller) labels() map[string]string {
+	return map[string]string{"app": "consul", "release": c.ReleaseName, "component": "ui", labelUIExposure: "true"}
+}
+
+func (c *Controller) objectMeta(name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: name, Namespace: c.Namespace}
+}
+
+// uiPort returns the http port of the UI service, or its https port if HTTP is disabled.
+func uiPort(service *corev1.Service) *corev1.ServicePort {
+	var port *corev1.ServicePort
+	for i := range service.Spec.Ports {
+		switch service.Spec.Ports[i].Name {
+		case "http":
+			return &service.Spec.Ports[i]
+		case "https":
+			port = &service.Spec.Ports[i]
+		}
+	}
+	if port == nil && len(service.Spec.Ports) > 0 {
+		port = &service.Spec.Ports[0]
+	}
+	return port
+}
@@ -0,0 +1,392 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package uiexposure
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testr"
+	gatewaycommon "github.com/hashicorp/consul-k8s/control-plane/api-gateway/common"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gwv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+func TestApply_Ingress(t *testing.T) {
+	fakeClient := newFakeClient(t, true, uiService("http"))
+	controller := &Controller{
+		Client:           fakeClient,
+		Namespace:        "consul",
+		ReleaseName:      "consul",
+		ServiceName:      "consul-ui",
+		Type:             TypeIngress,
+		Host:             "consul.example.com",
+		IngressClassName: "nginx",
+		TLSSecretName:    "consul-ui-tls",
+		Log:              logrtest.New(t),
+	}
+	require.NoError(t, controller.apply(context.Background()))
+
+	var ingress networkingv1.Ingress
+	require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "consul", Name: "consul-ui"}, &ingress))
+	require.Equal(t, "true", ingress.Labels[labelUIExposure])
+	require.Len(t, ingress.OwnerReferences, 1)
+	require.Equal(t, types.UID("ui-uid"), ingress.OwnerReferences[0].UID)
+	require.Equal(t, "nginx", *ingress.Spec.IngressClassName)
+	require.Equal(t, []networkingv1.IngressTLS{{Hosts: []string{"consul.example.com"}, SecretName: "consul-ui-tls"}}, ingress.Spec.TLS)
+	require.Len(t, ingress.Spec.Rules, 1)
+	require.Equal(t, "consul.example.com", ingress.Spec.Rules[0].Host)
+	backend := ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service
+	require.Equal(t, "consul-ui", backend.Name)
+	require.Equal(t, int32(80), backend.Port.Number)
+
+	// Applying again doesn't update the Ingress.
+	require.NoError(t, controller.apply(context.Background()))
+	var unchanged networkingv1.Ingress
+	require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "consul", Name: "consul-ui"}, &unchanged))
+	require.Equal(t, ingress.ResourceVersion, unchanged.ResourceVersion)
+}
+
+func TestApply_Route(t *testing.T) {
+	cases := map[string]struct {
+		port           string
+		expTermination string
+	}{
+		"terminates TLS at the router": {
+			port:           "http",
+			expTermination: "edge",
+		},
+		"passes TLS through to an https only UI": {
+			port:           "https",
+			expTermination: "passthrough",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			fakeClient := newFakeClient(t, true, uiService(c.port))
+			controller := &Controller{
+				Client:      fakeClient,
+				Namespace:   "consul",
+				ReleaseName: "consul",
+				ServiceName: "consul-ui",
+				Type:        TypeRoute,
+				Host:        "consul.apps.example.com",
+				Log:         logrtest.New(t),
+			}
+			require.NoError(t, controller.apply(context.Background()))
+
+			route := &unstructured.Unstructured{}
+			route.SetGroupVersionKind(RouteGVK)
+			require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "consul", Name: "consul-ui"}, route))
+			require.Len(t, route.GetOwnerReferences(), 1)
+			host, _, _ := unstructured.NestedString(route.Object, "spec", "host")
+			require.Equal(t, "consul.apps.example.com", host)
+			to, _, _ := unstructured.NestedString(route.Object, "spec", "to", "name")
+			require.Equal(t, "consul-ui", to)
+			targetPort, _, _ := unstructured.NestedString(route.Object, "spec", "port", "targetPort")
+			require.Equal(t, c.port, targetPort)
+			termination, _, _ := unstructured.NestedString(route.Object, "spec", "tls", "termination")
+			require.Equal(t, c.expTermination, termination)
+		})
+	}
+}
+
+func TestApply_CRDNotInstalled(t *testing.T) {
+	for _, exposureType := range []string{TypeRoute, TypeGateway} {
+		t.Run(exposureType, func(t *testing.T) {
+			controller := &Controller{
+				Client:      newFakeClient(t, false, uiService("http")),
+				Namespace:   "consul",
+				ServiceName: "consul-ui",
+				Type:        exposureType,
+				GatewayName: "api-gateway",
+				Log:         logrtest.New(t),
+			}
+			err := controller.apply(context.Background())
+			require.True(t, meta.IsNoMatchError(err), err)
+		})
+	}
+}
+
+func TestApply_Gateway(t *testing.T) {
+	fakeClient := newFakeClient(t, true, append(gateway("envoy-gateway", "gateway.envoyproxy.io/gatewayclass-controller"), uiService("http"))...)
+	controller := &Controller{
+		Client:           fakeClient,
+		Namespace:        "consul",
+		ReleaseName:      "consul",
+		ServiceName:      "consul-ui",
+		Type:             TypeGateway,
+		Host:             "consul.example.com",
+		GatewayName:      "api-gateway",
+		GatewayNamespace: "gateways",
+		Log:              logrtest.New(t),
+	}
+	require.NoError(t, controller.apply(context.Background()))
+
+	var httpRoute gwv1beta1.HTTPRoute
+	require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "consul", Name: "consul-ui"}, &httpRoute))
+	require.Len(t, httpRoute.OwnerReferences, 1)
+	require.Equal(t, []gwv1beta1.Hostname{"consul.example.com"}, httpRoute.Spec.Hostnames)
+	require.Len(t, httpRoute.Spec.ParentRefs, 1)
+	require.Equal(t, gwv1beta1.ObjectName("api-gateway"), httpRoute.Spec.ParentRefs[0].Name)
+	require.Equal(t, gwv1beta1.Namespace("gateways"), *httpRoute.Spec.ParentRefs[0].Namespace)
+	backend := httpRoute.Spec.Rules[0].BackendRefs[0]
+	require.Equal(t, gwv1beta1.ObjectName("consul-ui"), backend.Name)
+	require.Equal(t, gwv1beta1.PortNumber(80), *backend.Port)
+}
+
+// Consul API gateways only route to services in the mesh, so the UI service can't be exposed with
+// them.
+func TestApply_ConsulAPIGateway(t *testing.T) {
+	fakeClient := newFakeClient(t, true, append(gateway("consul", gatewaycommon.GatewayClassControllerName), uiService("http"))...)
+	controller := &Controller{
+		Client:           fakeClient,
+		Namespace:        "consul",
+		ReleaseName:      "consul",
+		ServiceName:      "consul-ui",
+		Type:             TypeGateway,
+		GatewayName:      "api-gateway",
+		GatewayNamespace: "gateways",
+		Log:              logrtest.New(t),
+	}
+	require.EqualError(t, controller.apply(context.Background()),
+		"gateway gateways/api-gateway is a Consul API gateway, which only routes to services in the mesh: "+
+			"the UI must be exposed with a gateway of another controller")
+
+	var httpRoutes gwv1beta1.HTTPRouteList
+	require.NoError(t, fakeClient.List(context.Background(), &httpRoutes))
+	require.Empty(t, httpRoutes.Items)
+}
+
+func TestReconcile_Requeue(t *testing.T) {
+	cases := map[string]struct {
+		withCRDs       bool
+		unwatchedKinds []string
+		expRequeue     bool
+	}{
+		"CRD installed and watched": {
+			withCRDs: true,
+		},
+		"CRD not installed": {
+			withCRDs:       false,
+			unwatchedKinds: []string{"Route"},
+			expRequeue:     true,
+		},
+		"CRD installed after the controller started": {
+			withCRDs:       true,
+			unwatchedKinds: []string{"Route"},
+			expRequeue:     true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			controller := &Controller{
+				Client:         newFakeClient(t, c.withCRDs, uiService("http")),
+				Namespace:      "consul",
+				ServiceName:    "consul-ui",
+				Type:           TypeRoute,
+				ResyncPeriod:   time.Second,
+				Log:            logrtest.New(t),
+				unwatchedKinds: c.unwatchedKinds,
+			}
+			result, err := controller.Reconcile(context.Background(), ctrl.Request{})
+			require.NoError(t, err)
+			if c.expRequeue {
+				require.Equal(t, time.Second, result.RequeueAfter)
+			} else {
+				require.Zero(t, result.RequeueAfter)
+			}
+		})
+	}
+}
+
+func TestRequestsForUIResource(t *testing.T) {
+	controller := &Controller{Namespace: "consul", ServiceName: "consul-ui"}
+	request := []ctrl.Request{{NamespacedName: types.NamespacedName{Namespace: "consul", Name: "consul-ui"}}}
+
+	require.Equal(t, request, controller.requestsForUIResource(uiService("http")))
+	require.Equal(t, request, controller.requestsForUIResource(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-ui-auth-proxy", Namespace: "consul"},
+	}))
+	require.Empty(t, controller.requestsForUIResource(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-server", Namespace: "consul"},
+	}))
+	require.Empty(t, controller.requestsForUIResource(&networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-ui", Namespace: "default"},
+	}))
+}
+
+func TestApply_OIDC(t *testing.T) {
+	fakeClient := newFakeClient(t, true, uiService("http"))
+	controller := &Controller{
+		Client:        fakeClient,
+		Namespace:     "consul",
+		ReleaseName:   "consul",
+		ServiceName:   "consul-ui",
+		Type:          TypeIngress,
+		Host:          "consul.example.com",
+		TLSSecretName: "consul-ui-tls",
+		OIDC: &OIDC{
+			IssuerURL:  "https://issuer.example.com",
+			SecretName: "consul-ui-oidc",
+		},
+		Log: logrtest.New(t),
+	}
+	require.NoError(t, controller.apply(context.Background()))
+
+	var deployment appsv1.Deployment
+	require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "consul", Name: "consul-ui-auth-proxy"}, &deployment))
+	require.Len(t, deployment.OwnerReferences, 1)
+	container := deployment.Spec.Template.Spec.Containers[0]
+	require.Equal(t, DefaultOIDCProxyImage, container.Image)
+	require.Contains(t, container.Args, "--oidc-issuer-url=https://issuer.example.com")
+	require.Contains(t, container.Args, "--upstream=http://consul-ui.consul.svc:80")
+	require.Contains(t, container.Args, "--redirect-url=https://consul.example.com/oauth2/callback")
+	require.Len(t, container.Env, 3)
+	for _, env := range container.Env {
+		require.Equal(t, "consul-ui-oidc", env.ValueFrom.SecretKeyRef.Name)
+	}
+
+	var service corev1.Service
+	require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "consul", Name: "consul-ui-auth-proxy"}, &service))
+	require.Equal(t, deployment.Spec.Template.Labels, service.Spec.Selector)
+
+	var ingress networkingv1.Ingress
+	require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "consul", Name: "consul-ui"}, &ingress))
+	backend := ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service
+	require.Equal(t, "consul-ui-auth-proxy", backend.Name)
+	require.Equal(t, int32(oidcProxyPort), backend.Port.Number)
+
+	// Disabling OIDC deletes the proxy.
+	controller.OIDC = nil
+	require.NoError(t, controller.apply(context.Background()))
+	err := fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "consul", Name: "consul-ui-auth-proxy"}, &deployment)
+	require.True(t, k8serrors.IsNotFound(err), err)
+	err = fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "consul", Name: "consul-ui-auth-proxy"}, &service)
+	require.True(t, k8serrors.IsNotFound(err), err)
+}
+
+func TestApply_OIDCRequiresHTTPPort(t *testing.T) {
+	controller := &Controller{
+		Client:      newFakeClient(t, true, uiService("https")),
+		Namespace:   "consul",
+		ServiceName: "consul-ui",
+		Type:        TypeIngress,
+		OIDC:        &OIDC{IssuerURL: "https://issuer.example.com", SecretName: "consul-ui-oidc"},
+		Log:         logrtest.New(t),
+	}
+	require.EqualError(t, controller.apply(context.Background()), "UI service consul-ui has no http port for the OIDC auth proxy")
+}
+
+func TestApply_DeletesOtherExposureTypes(t *testing.T) {
+	cases := map[string]struct {
+		labels    map[string]string
+		expDelete bool
+	}{
+		"deletes generated Ingress": {
+			labels:    map[string]string{labelUIExposure: "true"},
+			expDelete: true,
+		},
+		"keeps Ingress that wasn't generated": {},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "consul-ui", Namespace: "consul", Labels: c.labels}}
+			fakeClient := newFakeClient(t, true, append(gateway("envoy-gateway", "gateway.envoyproxy.io/gatewayclass-controller"), uiService("http"), ingress)...)
+			controller := &Controller{
+				Client:           fakeClient,
+				Namespace:        "consul",
+				ServiceName:      "consul-ui",
+				Type:             TypeGateway,
+				GatewayName:      "api-gateway",
+				GatewayNamespace: "gateways",
+				Log:              logrtest.New(t),
+			}
+			require.NoError(t, controller.apply(context.Background()))
+
+			err := fakeClient.Get(context.Background(), client.ObjectKeyFromObject(ingress), &networkingv1.Ingress{})
+			if c.expDelete {
+				require.True(t, k8serrors.IsNotFound(err), err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestApply_NoUIService(t *testing.T) {
+	fakeClient := newFakeClient(t, true)
+	controller := &Controller{
+		Client:      fakeClient,
+		Namespace:   "consul",
+		ServiceName: "consul-ui",
+		Type:        TypeIngress,
+		Log:         logrtest.New(t),
+	}
+	require.NoError(t, controller.apply(context.Background()))
+
+	var ingresses networkingv1.IngressList
+	require.NoError(t, fakeClient.List(context.Background(), &ingresses))
+	require.Empty(t, ingresses.Items)
+}
+
+func newFakeClient(t *testing.T, withCRDs bool, objects ...runtime.Object) client.Client {
+	t.Helper()
+	s := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(s))
+	require.NoError(t, gwv1beta1.Install(s))
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Service"), meta.RESTScopeNamespace)
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+	mapper.Add(networkingv1.SchemeGroupVersion.WithKind("Ingress"), meta.RESTScopeNamespace)
+	if withCRDs {
+		mapper.Add(RouteGVK, meta.RESTScopeNamespace)
+		mapper.Add(httpRouteGVK, meta.RESTScopeNamespace)
+	}
+	return fake.NewClientBuilder().WithScheme(s).WithRESTMapper(mapper).WithRuntimeObjects(objects...).Build()
+}
+
+// gateway returns the api-gateway Gateway in the gateways namespace and its GatewayClass with the
+// controller name.
+func gateway(className, controllerName string) []runtime.Object {
+	return []runtime.Object{
+		&gwv1beta1.Gateway{
+			ObjectMeta: metav1.ObjectMeta{Name: "api-gateway", Namespace: "gateways"},
+			Spec:       gwv1beta1.GatewaySpec{GatewayClassName: gwv1beta1.ObjectName(className)},
+		},
+		&gwv1beta1.GatewayClass{
+			ObjectMeta: metav1.ObjectMeta{Name: className},
+			Spec:       gwv1beta1.GatewayClassSpec{ControllerName: gwv1beta1.GatewayController(controllerName)},
+		},
+	}
+}
+
+// uiService returns the UI service with the port, i.e. "http" or "https".
+func uiService(port string) *corev1.Service {
+	number := int32(80)
+	if port == "https" {
+		number = 443
+	}
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-ui", Namespace: "consul", UID: "ui-uid"},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{Name: port, Port: number}},
+		},
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/common"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
@@ -21,6 +22,13 @@ type Config struct {
 	DefaultMergedMetricsPort    string
 	DefaultPrometheusScrapePort string
 	DefaultPrometheusScrapePath string
+	// DefaultStatsdURL is the URL of a StatsD sink that Envoy sends metrics to.
+	DefaultStatsdURL string
+	// DefaultDogstatsdURL is the URL of a DogStatsD sink that Envoy sends metrics to.
+	DefaultDogstatsdURL string
+	// StatsTagPodLabels are the labels of pods that are added as tags to the metrics sent to
+	// StatsD and DogStatsD sinks.
+	StatsTagPodLabels []string
 }
 
 type metricsPorts struct {
@@ -180,3 +188,48 @@ func (mc Config) ShouldRunMergedMetricsServer(pod corev1.Pod) (bool, error) {
 	}
 	return false, nil
 }
+
+// StatsdURL returns the URL of the StatsD sink, either via the default value in the meshWebhook, or
+// if it's been overridden via the annotation. An empty URL means no sink is configured, so an empty
+// annotation disables the default sink for the pod.
+func (mc Config) StatsdURL(pod corev1.Pod) string {
+	if raw, ok := pod.Annotations[constants.AnnotationStatsdURL]; ok {
+		return raw
+	}
+	return mc.DefaultStatsdURL
+}
+
+// DogstatsdURL returns the URL of the DogStatsD sink, either via the default value in the meshWebhook,
+// or if it's been overridden via the annotation. An empty URL means no sink is configured, so an
+// empty annotation disables the default sink for the pod.
+func (mc Config) DogstatsdURL(pod corev1.Pod) string {
+	if raw, ok := pod.Annotations[constants.AnnotationDogstatsdURL]; ok {
+		return raw
+	}
+	return mc.DefaultDogstatsdURL
+}
+
+// StatsTags returns the tags, formatted as name=value, that are added to the metrics sent to
+// StatsD and DogStatsD sinks. They identify the pod by its namespace and name, and include the
+// values of the configured pod labels and the tags of the stats-tags annotation.
+func (mc Config) StatsTags(pod corev1.Pod) ([]string, error) {
+	tags := []string{
+		fmt.Sprintf("kube_namespace=%s", pod.Namespace),
+		fmt.Sprintf("pod_name=%s", pod.Name),
+	}
+	for _, label := range mc.StatsTagPodLabels {
+		if value, ok := pod.Labels[label]; ok {
+			tags = append(tags, fmt.Sprintf("%s=%s", label, value))
+		}
+	}
+	if raw, ok := pod.Annotations[constants.AnnotationStatsTags]; ok && raw != "" {
+		for _, tag := range strings.Split(raw, ",") {
+			tag = strings.TrimSpace(tag)
+			if name, _, ok := strings.Cut(tag, "="); !ok || name == "" {
+				return nil, fmt.Errorf("%s annotation value of %s was invalid: tags must be formatted as name=value", constants.AnnotationStatsTags, raw)
+			}
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
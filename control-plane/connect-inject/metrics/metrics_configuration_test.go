@@ -388,3 +388,124 @@ func minimal() *corev1.Pod {
 		},
 	}
 }
+
+func TestMetricsConfigStatsdURLs(t *testing.T) {
+	cases := []struct {
+		Name          string
+		Pod           func(*corev1.Pod) *corev1.Pod
+		MetricsConfig Config
+		ExpStatsd     string
+		ExpDogstatsd  string
+	}{
+		{
+			Name: "Defaults to the meshWebhook's values",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				return pod
+			},
+			MetricsConfig: Config{
+				DefaultStatsdURL:    "udp://statsd:8125",
+				DefaultDogstatsdURL: "unix:///var/run/datadog/dsd.socket",
+			},
+			ExpStatsd:    "udp://statsd:8125",
+			ExpDogstatsd: "unix:///var/run/datadog/dsd.socket",
+		},
+		{
+			Name: "Uses annotations when set",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[constants.AnnotationStatsdURL] = "udp://custom-statsd:8125"
+				pod.Annotations[constants.AnnotationDogstatsdURL] = "udp://custom-dogstatsd:8125"
+				return pod
+			},
+			MetricsConfig: Config{
+				DefaultStatsdURL:    "udp://statsd:8125",
+				DefaultDogstatsdURL: "unix:///var/run/datadog/dsd.socket",
+			},
+			ExpStatsd:    "udp://custom-statsd:8125",
+			ExpDogstatsd: "udp://custom-dogstatsd:8125",
+		},
+		{
+			Name: "Empty annotations disable the default sinks",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[constants.AnnotationStatsdURL] = ""
+				pod.Annotations[constants.AnnotationDogstatsdURL] = ""
+				return pod
+			},
+			MetricsConfig: Config{
+				DefaultStatsdURL:    "udp://statsd:8125",
+				DefaultDogstatsdURL: "unix:///var/run/datadog/dsd.socket",
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			require := require.New(t)
+			mc := tt.MetricsConfig
+			pod := *tt.Pod(minimal())
+
+			require.Equal(tt.ExpStatsd, mc.StatsdURL(pod))
+			require.Equal(tt.ExpDogstatsd, mc.DogstatsdURL(pod))
+		})
+	}
+}
+
+func TestMetricsConfigStatsTags(t *testing.T) {
+	cases := []struct {
+		Name          string
+		Pod           func(*corev1.Pod) *corev1.Pod
+		MetricsConfig Config
+		Expected      []string
+		Err           string
+	}{
+		{
+			Name: "Tags with the pod's namespace and name",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				return pod
+			},
+			Expected: []string{"kube_namespace=default", "pod_name=minimal"},
+		},
+		{
+			Name: "Tags with configured pod labels",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Labels = map[string]string{"app": "web", "version": "v2", "other": "ignored"}
+				return pod
+			},
+			MetricsConfig: Config{
+				StatsTagPodLabels: []string{"app", "version", "missing"},
+			},
+			Expected: []string{"kube_namespace=default", "pod_name=minimal", "app=web", "version=v2"},
+		},
+		{
+			Name: "Tags from annotation",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[constants.AnnotationStatsTags] = "team=payments, env=prod"
+				return pod
+			},
+			Expected: []string{"kube_namespace=default", "pod_name=minimal", "team=payments", "env=prod"},
+		},
+		{
+			Name: "Invalid annotation",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[constants.AnnotationStatsTags] = "team"
+				return pod
+			},
+			Err: "consul.hashicorp.com/stats-tags annotation value of team was invalid: tags must be formatted as name=value",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			require := require.New(t)
+			mc := tt.MetricsConfig
+
+			actual, err := mc.StatsTags(*tt.Pod(minimal()))
+
+			if tt.Err == "" {
+				require.NoError(err)
+				require.Equal(tt.Expected, actual)
+			} else {
+				require.EqualError(err, tt.Err)
+			}
+		})
+	}
+}
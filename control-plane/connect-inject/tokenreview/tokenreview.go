@@ -0,0 +1,295 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tokenreview
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	authnv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	authnv1client "k8s.io/client-go/kubernetes/typed/authentication/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// TokenReviewsPath is the path of the Kubernetes API that token reviews are created at.
+	TokenReviewsPath = "/apis/authentication.k8s.io/v1/tokenreviews"
+	// NamespacesPath is the prefix of the paths of the Kubernetes API that service accounts are
+	// read from.
+	NamespacesPath = "/api/v1/namespaces/"
+
+	// defaultMaxEntries is the number of reviews that are cached if MaxEntries isn't set.
+	defaultMaxEntries = 10000
+)
+
+// Handler serves the subset of the Kubernetes API that Consul's Kubernetes auth method uses to
+// validate the bearer tokens of logins: creating TokenReviews and reading service accounts.
+// Consul's auth method is pointed at the Handler instead of the Kubernetes API server so that
+// the results of token reviews are cached. When a lot of pods are restarted at once, each of
+// their proxies logs in with the same service account tokens and the API server only has to
+// review each token once. Service accounts are read from the manager's cache.
+//
+// Only successful reviews are cached, for at most TTL and never past the expiry of the token.
+// A token that is revoked within the TTL, e.g. because its pod was deleted, can still be used
+// to log in until its cached review expires, so TTL should be short.
+//
+// Requests must be authenticated with the bearer token of the Reviewer service account, i.e.
+// the ServiceAccountJWT of the auth method.
+type Handler struct {
+	// TokenReviews creates token reviews in the Kubernetes API.
+	TokenReviews authnv1client.TokenReviewInterface
+	// ServiceAccounts reads service accounts.
+	ServiceAccounts client.Reader
+	// Reviewer is the username of the service account that is allowed to make requests,
+	// e.g. system:serviceaccount:consul:consul-auth-method.
+	Reviewer string
+	// Audience is the audience of projected service account tokens that pods log in with. Tokens
+	// that have the audience are reviewed for it. Other tokens are reviewed for the audiences
+	// of the API server.
+	Audience string
+	// TTL is how long successful reviews are cached.
+	TTL time.Duration
+	// MaxEntries is the maximum number of cached reviews. Defaults to 10000.
+	MaxEntries int
+	// Log is the logger for this handler.
+	Log logr.Logger
+
+	// now returns the current time. It is overridden in tests.
+	now func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	status  authnv1.TokenReviewStatus
+	expires time.Time
+}
+
+// ServeHTTP serves token reviews and service accounts.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	status, err := h.review(r.Context(), bearer, nil)
+	if err != nil {
+		h.Log.Error(err, "failed to review the token of the request")
+		h.writeStatus(w, http.StatusInternalServerError, metav1.StatusReasonInternalError, "failed to review token")
+		return
+	}
+	if !status.Authenticated {
+		h.writeStatus(w, http.StatusUnauthorized, metav1.StatusReasonUnauthorized, "Unauthorized")
+		return
+	}
+	if status.User.Username != h.Reviewer {
+		h.writeStatus(w, http.StatusForbidden, metav1.StatusReasonForbidden,
+			fmt.Sprintf("%s is not allowed to review tokens", status.User.Username))
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == TokenReviewsPath:
+		h.serveTokenReview(w, r)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, NamespacesPath):
+		h.serveServiceAccount(w, r)
+	default:
+		h.writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, "not found")
+	}
+}
+
+func (h *Handler) serveTokenReview(w http.ResponseWriter, r *http.Request) {
+	var review authnv1.TokenReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		h.writeStatus(w, http.StatusBadRequest, metav1.StatusReasonBadRequest, fmt.Sprintf("invalid TokenReview: %s", err))
+		return
+	}
+	status, err := h.review(r.Context(), review.Spec.Token, review.Spec.Audiences)
+	if err != nil {
+		h.Log.Error(err, "failed to review token")
+		h.writeStatus(w, http.StatusInternalServerError, metav1.StatusReasonInternalError, "failed to review token")
+		return
+	}
+	review.APIVersion = authnv1.SchemeGroupVersion.String()
+	review.Kind = "TokenReview"
+	review.Status = status
+	h.writeJSON(w, http.StatusCreated, &review)
+}
+
+// serveServiceAccount serves /api/v1/namespaces/<namespace>/serviceaccounts/<name>.
+func (h *Handler) serveServiceAccount(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, NamespacesPath), "/")
+	if len(parts) != 3 || parts[1] != "serviceaccounts" {
+		h.writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, "not found")
+		return
+	}
+	var serviceAccount corev1.ServiceAccount
+	err := h.ServiceAccounts.Get(r.Context(), types.NamespacedName{Namespace: parts[0], Name: parts[2]}, &serviceAccount)
+	if k8serrors.IsNotFound(err) {
+		h.writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound,
+			fmt.Sprintf("serviceaccounts %q not found", parts[2]))
+		return
+	}
+	if err != nil {
+		h.Log.Error(err, "failed to read service account", "namespace", parts[0], "name", parts[2])
+		h.writeStatus(w, http.StatusInternalServerError, metav1.StatusReasonInternalError, "failed to read service account")
+		return
+	}
+	serviceAccount.APIVersion = corev1.SchemeGroupVersion.String()
+	serviceAccount.Kind = "ServiceAccount"
+	h.writeJSON(w, http.StatusOK, &serviceAccount)
+}
+
+// review returns the status of the review of the token, from the cache if the token was reviewed
+// before.
+func (h *Handler) review(ctx context.Context, token string, audiences []string) (authnv1.TokenReviewStatus, error) {
+	if token == "" {
+		return authnv1.TokenReviewStatus{}, nil
+	}
+	claims := parseClaims(token)
+	if len(audiences) == 0 && h.Audience != "" && claims.hasAudience(h.Audience) {
+		audiences = []string{h.Audience}
+	}
+	key := cacheKey(token, audiences)
+	now := h.currentTime()
+
+	h.mu.Lock()
+	entry, ok := h.cache[key]
+	h.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.status, nil
+	}
+
+	result, err := h.TokenReviews.Create(ctx, &authnv1.TokenReview{
+		Spec: authnv1.TokenReviewSpec{Token: token, Audiences: audiences},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return authnv1.TokenReviewStatus{}, err
+	}
+	if result.Status.Authenticated && h.TTL > 0 {
+		expires := now.Add(h.TTL)
+		if claims.Expiry > 0 && time.Unix(claims.Expiry, 0).Before(expires) {
+			expires = time.Unix(claims.Expiry, 0)
+		}
+		h.store(key, cacheEntry{status: result.Status, expires: expires}, now)
+	}
+	return result.Status, nil
+}
+
+// store caches the entry. Expired entries are evicted when the cache is full, and if it's still
+// full, the whole cache is cleared so that its size is bounded.
+func (h *Handler) store(key string, entry cacheEntry, now time.Time) {
+	maxEntries := h.MaxEntries
+	if maxEntries == 0 {
+		maxEntries = defaultMaxEntries
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cache == nil {
+		h.cache = make(map[string]cacheEntry)
+	}
+	if len(h.cache) >= maxEntries {
+		for k, e := range h.cache {
+			if !now.Before(e.expires) {
+				delete(h.cache, k)
+			}
+		}
+		if len(h.cache) >= maxEntries {
+			h.cache = make(map[string]cacheEntry)
+		}
+	}
+	h.cache[key] = entry
+}
+
+func (h *Handler) currentTime() time.Time {
+	if h.now != nil {
+		return h.now()
+	}
+	return time.Now()
+}
+
+func (h *Handler) writeStatus(w http.ResponseWriter, code int, reason metav1.StatusReason, message string) {
+	h.writeJSON(w, code, &metav1.Status{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"},
+		Status:   metav1.StatusFailure,
+		Message:  message,
+		Reason:   reason,
+		Code:     int32(code),
+	})
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.Log.Error(err, "failed to write response")
+	}
+}
+
+// cacheKey returns the key of the review of the token for the audiences. The token is hashed so
+// that the cache doesn't hold tokens.
+func cacheKey(token string, audiences []string) string {
+	sum := sha256.Sum256([]byte(token + "\x00" + strings.Join(audiences, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// claims are the claims of a service account token that are used to pick the audiences that it's
+// reviewed for and how long its review is cached. They aren't verified, the API server verifies
+// the token when it's reviewed.
+type claims struct {
+	Audience audience `json:"aud"`
+	Expiry   int64    `json:"exp"`
+}
+
+func (c claims) hasAudience(aud string) bool {
+	for _, a := range c.Audience {
+		if a == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// audience is the aud claim of a JWT, which is either a string or a list of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// parseClaims returns the claims of the JWT, or no claims if it isn't a JWT.
+func parseClaims(token string) claims {
+	var c claims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return c
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return c
+	}
+	_ = json.Unmarshal(payload, &c)
+	return c
+}
@@ -0,0 +1,235 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tokenreview
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/stretchr/testify/require"
+	authnv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+	ctrlfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const reviewer = "system:serviceaccount:consul:consul-auth-method"
+
+func TestHandler_TokenReview(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	podToken := jwt(t, []string{"https://kubernetes.default.svc"}, 0)
+	boundToken := jwt(t, []string{"consul"}, now.Add(10*time.Second).Unix())
+
+	cases := map[string]struct {
+		token        string
+		reviews      int
+		after        time.Duration
+		expAudiences []string
+		expReviews   int
+	}{
+		"reviews a token once within the TTL": {
+			token:      podToken,
+			reviews:    3,
+			after:      20 * time.Second,
+			expReviews: 1,
+		},
+		"reviews a token again after the TTL": {
+			token:      podToken,
+			reviews:    2,
+			after:      2 * time.Minute,
+			expReviews: 2,
+		},
+		"reviews a bound token for the audience": {
+			token:        boundToken,
+			reviews:      2,
+			after:        5 * time.Second,
+			expAudiences: []string{"consul"},
+			expReviews:   1,
+		},
+		"does not cache a review past the expiry of the token": {
+			token:        boundToken,
+			reviews:      2,
+			after:        20 * time.Second,
+			expAudiences: []string{"consul"},
+			expReviews:   2,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			handler, reviews := newHandler(t)
+			currentTime := now
+			handler.now = func() time.Time { return currentTime }
+
+			for i := 0; i < c.reviews; i++ {
+				resp := serve(t, handler, http.MethodPost, TokenReviewsPath, &authnv1.TokenReview{
+					Spec: authnv1.TokenReviewSpec{Token: c.token},
+				})
+				require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+				var review authnv1.TokenReview
+				require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &review))
+				require.True(t, review.Status.Authenticated)
+				require.Equal(t, "system:serviceaccount:default:web", review.Status.User.Username)
+				currentTime = currentTime.Add(c.after)
+			}
+
+			var tokenReviews []*authnv1.TokenReview
+			for _, review := range *reviews {
+				if review.Spec.Token == c.token {
+					tokenReviews = append(tokenReviews, review)
+				}
+			}
+			require.Len(t, tokenReviews, c.expReviews)
+			require.Equal(t, c.expAudiences, tokenReviews[0].Spec.Audiences)
+		})
+	}
+}
+
+func TestHandler_ServiceAccount(t *testing.T) {
+	handler, _ := newHandler(t)
+
+	resp := serve(t, handler, http.MethodGet, NamespacesPath+"default/serviceaccounts/web", nil)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var serviceAccount corev1.ServiceAccount
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &serviceAccount))
+	require.Equal(t, "ServiceAccount", serviceAccount.Kind)
+	require.Equal(t, "web-svc", serviceAccount.Annotations["consul.hashicorp.com/service-name"])
+
+	resp = serve(t, handler, http.MethodGet, NamespacesPath+"default/serviceaccounts/api", nil)
+	require.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = serve(t, handler, http.MethodGet, NamespacesPath+"default/secrets/web", nil)
+	require.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestHandler_Authorization(t *testing.T) {
+	cases := map[string]struct {
+		bearer  string
+		expCode int
+	}{
+		"no token": {
+			expCode: http.StatusUnauthorized,
+		},
+		"invalid token": {
+			bearer:  "invalid",
+			expCode: http.StatusUnauthorized,
+		},
+		"token of another service account": {
+			bearer:  jwt(t, nil, 0),
+			expCode: http.StatusForbidden,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			handler, _ := newHandler(t)
+			req := httptest.NewRequest(http.MethodGet, NamespacesPath+"default/serviceaccounts/web", nil)
+			if c.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+c.bearer)
+			}
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			require.Equal(t, c.expCode, resp.Code, resp.Body.String())
+		})
+	}
+}
+
+func TestHandler_MaxEntries(t *testing.T) {
+	handler, _ := newHandler(t)
+	handler.MaxEntries = 2
+	for i := 0; i < 5; i++ {
+		resp := serve(t, handler, http.MethodPost, TokenReviewsPath, &authnv1.TokenReview{
+			Spec: authnv1.TokenReviewSpec{Token: jwt(t, nil, int64(i+1)*1e10)},
+		})
+		require.Equal(t, http.StatusCreated, resp.Code)
+		require.LessOrEqual(t, len(handler.cache), 2)
+	}
+}
+
+// newHandler returns a handler whose token reviews authenticate the reviewer token and any other
+// JWT as the web service account. It also returns the token reviews that were created.
+func newHandler(t *testing.T) (*Handler, *[]*authnv1.TokenReview) {
+	t.Helper()
+	var reviews []*authnv1.TokenReview
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authnv1.TokenReview)
+		reviews = append(reviews, review)
+		result := review.DeepCopy()
+		switch {
+		case review.Spec.Token == reviewerToken:
+			result.Status = authnv1.TokenReviewStatus{Authenticated: true, User: authnv1.UserInfo{Username: reviewer}}
+		case len(parseClaimsForTest(review.Spec.Token)) > 0:
+			result.Status = authnv1.TokenReviewStatus{Authenticated: true, User: authnv1.UserInfo{Username: "system:serviceaccount:default:web"}}
+		default:
+			result.Status = authnv1.TokenReviewStatus{Error: "invalid token"}
+		}
+		return true, result, nil
+	})
+
+	serviceAccount := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+		Name:        "web",
+		Namespace:   "default",
+		Annotations: map[string]string{"consul.hashicorp.com/service-name": "web-svc"},
+	}}
+	return &Handler{
+		TokenReviews:    clientset.AuthenticationV1().TokenReviews(),
+		ServiceAccounts: ctrlfake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(serviceAccount).Build(),
+		Reviewer:        reviewer,
+		Audience:        "consul",
+		TTL:             time.Minute,
+		Log:             logrtest.New(t),
+	}, &reviews
+}
+
+const reviewerToken = "reviewer-token"
+
+func serve(t *testing.T, handler *Handler, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Authorization", "Bearer "+reviewerToken)
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	return resp
+}
+
+// jwt returns an unsigned JWT with the audiences and expiry.
+func jwt(t *testing.T, aud []string, exp int64) string {
+	t.Helper()
+	claims := map[string]interface{}{"sub": "system:serviceaccount:default:web", "aud": aud}
+	if exp > 0 {
+		claims["exp"] = exp
+	}
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	return fmt.Sprintf("e30.%s.sig", base64.RawURLEncoding.EncodeToString(payload))
+}
+
+func parseClaimsForTest(token string) map[string]interface{} {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil
+	}
+	var claims map[string]interface{}
+	_ = json.Unmarshal(payload, &claims)
+	return claims
+}
@@ -0,0 +1,194 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// ProviderZipkin sends spans to a Zipkin compatible collector over HTTP.
+	ProviderZipkin = "zipkin"
+	// ProviderOpenTelemetry sends spans to an OpenTelemetry collector over OTLP/gRPC.
+	ProviderOpenTelemetry = "opentelemetry"
+
+	// collectorClusterName is the name of the static Envoy cluster for the collector.
+	collectorClusterName = "consul_tracing_collector"
+	// zipkinCollectorEndpoint is the path of the Zipkin v2 spans API.
+	zipkinCollectorEndpoint = "/api/v2/spans"
+
+	// Proxy config keys that consul-dataplane uses to bootstrap Envoy.
+	envoyListenerTracingJSON     = "envoy_listener_tracing_json"
+	envoyExtraStaticClustersJSON = "envoy_extra_static_clusters_json"
+)
+
+// Config represents configuration common to connect-inject components related to tracing.
+type Config struct {
+	// Provider is the tracer that Envoy uses, either zipkin or opentelemetry.
+	// Tracing is disabled if it's empty.
+	Provider string
+	// CollectorAddress is the host:port of the collector that spans are sent to.
+	CollectorAddress string
+	// DefaultSamplingPercentage is the percentage of requests that are traced.
+	DefaultSamplingPercentage float64
+	// TagPodLabels are the labels of pods that are added as tags to spans.
+	TagPodLabels []string
+}
+
+// EnableTracing returns whether tracing is enabled either via the configured provider in the
+// meshWebhook, or if it's been disabled via the annotation.
+func (tc Config) EnableTracing(pod corev1.Pod) (bool, error) {
+	if tc.Provider == "" {
+		return false, nil
+	}
+	if raw, ok := pod.Annotations[constants.AnnotationEnableTracing]; ok && raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return false, fmt.Errorf("%s annotation value of %s was invalid: %s", constants.AnnotationEnableTracing, raw, err)
+		}
+		return enabled, nil
+	}
+	return true, nil
+}
+
+// SamplingPercentage returns the percentage of requests that are traced, either via the default
+// value in the meshWebhook, or if it's been overridden via the annotation.
+func (tc Config) SamplingPercentage(pod corev1.Pod) (float64, error) {
+	if raw, ok := pod.Annotations[constants.AnnotationTracingSamplingPercentage]; ok && raw != "" {
+		percentage, err := strconv.ParseFloat(raw, 64)
+		if err != nil || percentage < 0 || percentage > 100 {
+			return 0, fmt.Errorf("%s annotation value of %s was invalid: must be a number between 0 and 100", constants.AnnotationTracingSamplingPercentage, raw)
+		}
+		return percentage, nil
+	}
+	return tc.DefaultSamplingPercentage, nil
+}
+
+// ProxyConfig returns the proxy config that configures Envoy to trace requests of the pod and send
+// the spans to the collector. It returns nil if tracing isn't enabled for the pod.
+//
+// The spans are tagged with the pod's namespace and name, and the values of the configured pod labels.
+func (tc Config) ProxyConfig(pod corev1.Pod) (map[string]interface{}, error) {
+	enabled, err := tc.EnableTracing(pod)
+	if err != nil || !enabled {
+		return nil, err
+	}
+	samplingPercentage, err := tc.SamplingPercentage(pod)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := tc.provider()
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := tc.collectorCluster()
+	if err != nil {
+		return nil, err
+	}
+
+	customTags := []interface{}{
+		literalTag("k8s.namespace.name", pod.Namespace),
+		literalTag("k8s.pod.name", pod.Name),
+	}
+	for _, label := range tc.TagPodLabels {
+		if value, ok := pod.Labels[label]; ok {
+			customTags = append(customTags, literalTag(label, value))
+		}
+	}
+	tracingJSON, err := json.Marshal(map[string]interface{}{
+		"@type":           "type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager.Tracing",
+		"provider":        provider,
+		"random_sampling": map[string]interface{}{"value": samplingPercentage},
+		"custom_tags":     customTags,
+	})
+	if err != nil {
+		return nil, err
+	}
+	clusterJSON, err := json.Marshal(cluster)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		envoyListenerTracingJSON:     string(tracingJSON),
+		envoyExtraStaticClustersJSON: string(clusterJSON),
+	}, nil
+}
+
+// provider returns the Envoy tracer config for the provider.
+func (tc Config) provider() (map[string]interface{}, error) {
+	switch tc.Provider {
+	case ProviderZipkin:
+		return map[string]interface{}{
+			"name": "envoy.tracers.zipkin",
+			"typed_config": map[string]interface{}{
+				"@type":                      "type.googleapis.com/envoy.config.trace.v3.ZipkinConfig",
+				"collector_cluster":          collectorClusterName,
+				"collector_endpoint":         zipkinCollectorEndpoint,
+				"collector_endpoint_version": "HTTP_JSON",
+				"shared_span_context":        false,
+			},
+		}, nil
+	case ProviderOpenTelemetry:
+		return map[string]interface{}{
+			"name": "envoy.tracers.opentelemetry",
+			"typed_config": map[string]interface{}{
+				"@type": "type.googleapis.com/envoy.config.trace.v3.OpenTelemetryConfig",
+				"grpc_service": map[string]interface{}{
+					"envoy_grpc": map[string]interface{}{"cluster_name": collectorClusterName},
+				},
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("tracing provider %q is not supported: must be one of %q or %q", tc.Provider, ProviderZipkin, ProviderOpenTelemetry)
+	}
+}
+
+// collectorCluster returns the static Envoy cluster that resolves the collector address with DNS.
+// OpenTelemetry collectors receive spans over gRPC, so their cluster uses HTTP/2.
+func (tc Config) collectorCluster() (map[string]interface{}, error) {
+	host, rawPort, err := net.SplitHostPort(tc.CollectorAddress)
+	if err != nil {
+		return nil, fmt.Errorf("tracing collector address %q is invalid: %w", tc.CollectorAddress, err)
+	}
+	port, err := strconv.Atoi(rawPort)
+	if err != nil {
+		return nil, fmt.Errorf("tracing collector address %q is invalid: %w", tc.CollectorAddress, err)
+	}
+	cluster := map[string]interface{}{
+		"name":            collectorClusterName,
+		"type":            "STRICT_DNS",
+		"connect_timeout": "5s",
+		"load_assignment": map[string]interface{}{
+			"cluster_name": collectorClusterName,
+			"endpoints": []interface{}{map[string]interface{}{
+				"lb_endpoints": []interface{}{map[string]interface{}{
+					"endpoint": map[string]interface{}{
+						"address": map[string]interface{}{
+							"socket_address": map[string]interface{}{"address": host, "port_value": port},
+						},
+					},
+				}},
+			}},
+		},
+	}
+	if tc.Provider == ProviderOpenTelemetry {
+		cluster["typed_extension_protocol_options"] = map[string]interface{}{
+			"envoy.extensions.upstreams.http.v3.HttpProtocolOptions": map[string]interface{}{
+				"@type":                "type.googleapis.com/envoy.extensions.upstreams.http.v3.HttpProtocolOptions",
+				"explicit_http_config": map[string]interface{}{"http2_protocol_options": map[string]interface{}{}},
+			},
+		}
+	}
+	return cluster, nil
+}
+
+func literalTag(tag, value string) map[string]interface{} {
+	return map[string]interface{}{"tag": tag, "literal": map[string]interface{}{"value": value}}
+}
@@ -0,0 +1,219 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tracing
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTracingConfigEnableTracing(t *testing.T) {
+	cases := []struct {
+		Name          string
+		Pod           func(*corev1.Pod) *corev1.Pod
+		TracingConfig Config
+		Expected      bool
+		Err           string
+	}{
+		{
+			Name: "Tracing disabled without provider",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[constants.AnnotationEnableTracing] = "true"
+				return pod
+			},
+			Expected: false,
+		},
+		{
+			Name: "Tracing enabled via meshWebhook",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				return pod
+			},
+			TracingConfig: Config{Provider: ProviderZipkin},
+			Expected:      true,
+		},
+		{
+			Name: "Tracing disabled via annotation",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[constants.AnnotationEnableTracing] = "false"
+				return pod
+			},
+			TracingConfig: Config{Provider: ProviderZipkin},
+			Expected:      false,
+		},
+		{
+			Name: "Tracing configured via invalid annotation",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[constants.AnnotationEnableTracing] = "not-a-bool"
+				return pod
+			},
+			TracingConfig: Config{Provider: ProviderZipkin},
+			Err:           "consul.hashicorp.com/enable-tracing annotation value of not-a-bool was invalid: strconv.ParseBool: parsing \"not-a-bool\": invalid syntax",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			require := require.New(t)
+			tc := tt.TracingConfig
+
+			actual, err := tc.EnableTracing(*tt.Pod(minimal()))
+
+			if tt.Err == "" {
+				require.NoError(err)
+				require.Equal(tt.Expected, actual)
+			} else {
+				require.EqualError(err, tt.Err)
+			}
+		})
+	}
+}
+
+func TestTracingConfigSamplingPercentage(t *testing.T) {
+	cases := []struct {
+		Name          string
+		Pod           func(*corev1.Pod) *corev1.Pod
+		TracingConfig Config
+		Expected      float64
+		Err           string
+	}{
+		{
+			Name: "Defaults to the meshWebhook's value",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				return pod
+			},
+			TracingConfig: Config{DefaultSamplingPercentage: 10},
+			Expected:      10,
+		},
+		{
+			Name: "Uses annotation when set",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[constants.AnnotationTracingSamplingPercentage] = "0.5"
+				return pod
+			},
+			TracingConfig: Config{DefaultSamplingPercentage: 10},
+			Expected:      0.5,
+		},
+		{
+			Name: "Invalid annotation",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[constants.AnnotationTracingSamplingPercentage] = "150"
+				return pod
+			},
+			TracingConfig: Config{DefaultSamplingPercentage: 10},
+			Err:           "consul.hashicorp.com/tracing-sampling-percentage annotation value of 150 was invalid: must be a number between 0 and 100",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			require := require.New(t)
+			tc := tt.TracingConfig
+
+			actual, err := tc.SamplingPercentage(*tt.Pod(minimal()))
+
+			if tt.Err == "" {
+				require.NoError(err)
+				require.Equal(tt.Expected, actual)
+			} else {
+				require.EqualError(err, tt.Err)
+			}
+		})
+	}
+}
+
+func TestTracingConfigProxyConfig(t *testing.T) {
+	cases := []struct {
+		Name          string
+		TracingConfig Config
+		ExpProvider   string
+		ExpHTTP2      bool
+	}{
+		{
+			Name: "Zipkin",
+			TracingConfig: Config{
+				Provider:                  ProviderZipkin,
+				CollectorAddress:          "zipkin.tracing:9411",
+				DefaultSamplingPercentage: 25,
+				TagPodLabels:              []string{"app", "missing"},
+			},
+			ExpProvider: "envoy.tracers.zipkin",
+		},
+		{
+			Name: "OpenTelemetry",
+			TracingConfig: Config{
+				Provider:                  ProviderOpenTelemetry,
+				CollectorAddress:          "otel-collector.tracing:4317",
+				DefaultSamplingPercentage: 25,
+				TagPodLabels:              []string{"app", "missing"},
+			},
+			ExpProvider: "envoy.tracers.opentelemetry",
+			ExpHTTP2:    true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			require := require.New(t)
+			pod := minimal()
+			pod.Labels = map[string]string{"app": "web"}
+
+			proxyConfig, err := tt.TracingConfig.ProxyConfig(*pod)
+			require.NoError(err)
+
+			var tracingConfig struct {
+				Provider struct {
+					Name string `json:"name"`
+				} `json:"provider"`
+				RandomSampling struct {
+					Value float64 `json:"value"`
+				} `json:"random_sampling"`
+				CustomTags []struct {
+					Tag     string `json:"tag"`
+					Literal struct {
+						Value string `json:"value"`
+					} `json:"literal"`
+				} `json:"custom_tags"`
+			}
+			require.NoError(json.Unmarshal([]byte(proxyConfig[envoyListenerTracingJSON].(string)), &tracingConfig))
+			require.Equal(tt.ExpProvider, tracingConfig.Provider.Name)
+			require.Equal(25.0, tracingConfig.RandomSampling.Value)
+			tags := make(map[string]string)
+			for _, tag := range tracingConfig.CustomTags {
+				tags[tag.Tag] = tag.Literal.Value
+			}
+			require.Equal(map[string]string{"k8s.namespace.name": "default", "k8s.pod.name": "minimal", "app": "web"}, tags)
+
+			var cluster map[string]interface{}
+			require.NoError(json.Unmarshal([]byte(proxyConfig[envoyExtraStaticClustersJSON].(string)), &cluster))
+			require.Equal(collectorClusterName, cluster["name"])
+			_, http2 := cluster["typed_extension_protocol_options"]
+			require.Equal(tt.ExpHTTP2, http2)
+		})
+	}
+}
+
+func TestTracingConfigProxyConfig_Disabled(t *testing.T) {
+	pod := minimal()
+	pod.Annotations[constants.AnnotationEnableTracing] = "false"
+	proxyConfig, err := Config{Provider: ProviderZipkin, CollectorAddress: "zipkin:9411"}.ProxyConfig(*pod)
+	require.NoError(t, err)
+	require.Nil(t, proxyConfig)
+}
+
+func minimal() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "minimal",
+			Annotations: map[string]string{
+				constants.AnnotationService: "foo",
+			},
+		},
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultACLTokenSecretKey is the key of the ACL token in the pod's ACL token Secret if the pod isn't annotated
+// with another key.
+const defaultACLTokenSecretKey = "token"
+
+// aclTokenSecret returns the Secret key holding the ACL token that the pod is annotated with, or nil if the pod
+// isn't annotated with one.
+func aclTokenSecret(pod corev1.Pod) *corev1.SecretKeySelector {
+	name := pod.Annotations[constants.AnnotationACLTokenSecret]
+	if name == "" {
+		return nil
+	}
+	key := pod.Annotations[constants.AnnotationACLTokenSecretKey]
+	if key == "" {
+		key = defaultACLTokenSecretKey
+	}
+	return &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: name},
+		Key:                  key,
+	}
+}
+
+// loginWithAuthMethod returns true if the init container and consul-dataplane of the pod log in to Consul with
+// the Kubernetes auth method. Pods annotated with an ACL token Secret use that token instead.
+func (w *MeshWebhook) loginWithAuthMethod(pod corev1.Pod) bool {
+	return w.AuthMethod != "" && aclTokenSecret(pod) == nil
+}
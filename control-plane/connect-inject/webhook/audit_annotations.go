@@ -0,0 +1,88 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Keys of the audit annotations of admission responses. The API server prefixes them with the name of the
+// webhook, e.g. consul-connect-injector.consul.hashicorp.com/injected-images.
+const (
+	auditKeyInjectedInitContainers = "injected-init-containers"
+	auditKeyInjectedContainers     = "injected-containers"
+	auditKeyInjectedImages         = "injected-images"
+	auditKeyUpstreams              = "upstreams"
+	auditKeyRedirectTraffic        = "redirect-traffic"
+)
+
+// isInjectedContainer returns true if name is the name of an init or sidecar container that the webhook
+// injects.
+func isInjectedContainer(name string) bool {
+	return name == injectInitContainerName || strings.HasPrefix(name, injectInitContainerName+"-") ||
+		name == sidecarContainer || strings.HasPrefix(name, sidecarContainer+"-")
+}
+
+// auditAnnotations returns the audit annotations that describe what was injected into the pod, so that the
+// mutation is recorded in the cluster's audit log: the injected containers and their images, the upstreams
+// and, if its traffic is redirected, the iptables config that redirects it.
+func auditAnnotations(pod corev1.Pod) map[string]string {
+	var initContainers, containers, images []string
+	redirectTraffic := pod.Annotations[constants.AnnotationRedirectTraffic]
+	for _, c := range pod.Spec.InitContainers {
+		if !isInjectedContainer(c.Name) {
+			continue
+		}
+		initContainers = append(initContainers, c.Name)
+		images = append(images, c.Image)
+		for _, env := range c.Env {
+			if env.Name == "CONSUL_REDIRECT_TRAFFIC_CONFIG" {
+				redirectTraffic = env.Value
+			}
+		}
+	}
+	for _, c := range pod.Spec.Containers {
+		if !isInjectedContainer(c.Name) {
+			continue
+		}
+		containers = append(containers, c.Name)
+		images = append(images, c.Image)
+	}
+
+	annotations := map[string]string{
+		auditKeyInjectedInitContainers: strings.Join(initContainers, ","),
+		auditKeyInjectedContainers:     strings.Join(containers, ","),
+		auditKeyInjectedImages:         strings.Join(uniqueSorted(images), ","),
+	}
+	var upstreams []string
+	for _, upstream := range splitUpstreams(pod.Annotations[constants.AnnotationUpstreams]) {
+		destination, port := upstreamDestinationAndPort(upstream)
+		upstreams = append(upstreams, destination+":"+port)
+	}
+	if len(upstreams) > 0 {
+		annotations[auditKeyUpstreams] = strings.Join(upstreams, ",")
+	}
+	if redirectTraffic != "" {
+		annotations[auditKeyRedirectTraffic] = redirectTraffic
+	}
+	return annotations
+}
+
+// uniqueSorted returns the sorted unique values of s.
+func uniqueSorted(s []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,124 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestHandlerAuditAnnotations(t *testing.T) {
+	s := runtime.NewScheme()
+	s.AddKnownTypes(corev1.SchemeGroupVersion, &corev1.Pod{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(t, err)
+
+	cases := map[string]struct {
+		tproxy         bool
+		cni            bool
+		expAnnotations map[string]string
+	}{
+		"without transparent proxy": {
+			expAnnotations: map[string]string{
+				auditKeyInjectedInitContainers: injectInitContainerName,
+				auditKeyInjectedContainers:     sidecarContainer,
+				auditKeyInjectedImages:         "hashicorp/consul-dataplane:latest,hashicorp/consul-k8s-control-plane:latest",
+				auditKeyUpstreams:              "db:1234,prepared_query:search:1235",
+			},
+		},
+		"transparent proxy redirected by the init container": {
+			tproxy: true,
+			expAnnotations: map[string]string{
+				auditKeyInjectedInitContainers: injectInitContainerName,
+				auditKeyInjectedContainers:     sidecarContainer,
+				auditKeyInjectedImages:         "hashicorp/consul-dataplane:latest,hashicorp/consul-k8s-control-plane:latest",
+				auditKeyUpstreams:              "db:1234,prepared_query:search:1235",
+			},
+		},
+		"transparent proxy redirected by the CNI plugin": {
+			tproxy: true,
+			cni:    true,
+			expAnnotations: map[string]string{
+				auditKeyInjectedInitContainers: injectInitContainerName,
+				auditKeyInjectedContainers:     sidecarContainer,
+				auditKeyInjectedImages:         "hashicorp/consul-dataplane:latest,hashicorp/consul-k8s-control-plane:latest",
+				auditKeyUpstreams:              "db:1234,prepared_query:search:1235",
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				Log:                    logrtest.New(t),
+				AllowK8sNamespacesSet:  mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:   mapset.NewSet(),
+				EnableTransparentProxy: c.tproxy,
+				EnableCNI:              c.cni,
+				ImageConsulDataplane:   "hashicorp/consul-dataplane:latest",
+				ImageConsulK8S:         "hashicorp/consul-k8s-control-plane:latest",
+				ConsulConfig:           &consul.Config{HTTPPort: 8500},
+				decoder:                decoder,
+				Clientset:              defaultTestClientWithNamespace(),
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "default",
+					Annotations: map[string]string{
+						constants.AnnotationUpstreams: "db:1234, prepared_query:search:1235",
+					},
+				},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "web:latest"}}},
+			}
+			resp := w.Handle(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: "default",
+					Object:    encodeRaw(t, pod),
+				},
+			})
+			require.True(t, resp.Allowed)
+
+			redirectTraffic, ok := resp.AuditAnnotations[auditKeyRedirectTraffic]
+			require.Equal(t, c.tproxy, ok)
+			if c.tproxy {
+				require.Contains(t, redirectTraffic, `"ProxyOutboundPort":15001`)
+				require.Contains(t, redirectTraffic, `"ProxyInboundPort":20000`)
+			}
+			delete(resp.AuditAnnotations, auditKeyRedirectTraffic)
+			require.Equal(t, c.expAnnotations, resp.AuditAnnotations)
+		})
+	}
+}
+
+func TestAuditAnnotations_MultiPort(t *testing.T) {
+	pod := corev1.Pod{
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{
+				{Name: "app-init", Image: "app-init:latest"},
+				{Name: injectInitContainerName + "-web", Image: "hashicorp/consul-k8s-control-plane:latest"},
+				{Name: injectInitContainerName + "-web-admin", Image: "hashicorp/consul-k8s-control-plane:latest"},
+			},
+			Containers: []corev1.Container{
+				{Name: "web", Image: "web:latest"},
+				{Name: sidecarContainer + "-web", Image: "hashicorp/consul-dataplane:latest"},
+				{Name: sidecarContainer + "-web-admin", Image: "hashicorp/consul-dataplane:latest"},
+			},
+		},
+	}
+	require.Equal(t, map[string]string{
+		auditKeyInjectedInitContainers: "consul-connect-inject-init-web,consul-connect-inject-init-web-admin",
+		auditKeyInjectedContainers:     "consul-dataplane-web,consul-dataplane-web-admin",
+		auditKeyInjectedImages:         "hashicorp/consul-dataplane:latest,hashicorp/consul-k8s-control-plane:latest",
+	}, auditAnnotations(pod))
+}
@@ -6,6 +6,7 @@ package webhook
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 
@@ -19,7 +20,6 @@ import (
 )
 
 const (
-	consulDataplaneDNSBindHost = "127.0.0.1"
 	consulDataplaneDNSBindPort = 8600
 )
 
@@ -32,8 +32,8 @@ func (w *MeshWebhook) consulDataplaneSidecar(namespace corev1.Namespace, pod cor
 	// Extract the service account token's volume mount.
 	var bearerTokenFile string
 	var saTokenVolumeMount corev1.VolumeMount
-	if w.AuthMethod != "" {
-		saTokenVolumeMount, bearerTokenFile, err = findServiceAccountVolumeMount(pod, mpi.serviceName)
+	if w.loginWithAuthMethod(pod) {
+		saTokenVolumeMount, bearerTokenFile, err = w.serviceAccountVolumeMount(pod, mpi.serviceName)
 		if err != nil {
 			return corev1.Container{}, err
 		}
@@ -74,9 +74,13 @@ func (w *MeshWebhook) consulDataplaneSidecar(namespace corev1.Namespace, pod cor
 		}
 	}
 
+	image, err := w.imageConsulDataplane(namespace)
+	if err != nil {
+		return corev1.Container{}, err
+	}
 	container := corev1.Container{
 		Name:      containerName,
-		Image:     w.ImageConsulDataplane,
+		Image:     image,
 		Resources: resources,
 		// We need to set tmp dir to an ephemeral volume that we're mounting so that
 		// consul-dataplane can write files to it. Otherwise, it wouldn't be able to
@@ -109,9 +113,15 @@ func (w *MeshWebhook) consulDataplaneSidecar(namespace corev1.Namespace, pod cor
 		ReadinessProbe: probe,
 	}
 
-	if w.AuthMethod != "" {
+	if w.loginWithAuthMethod(pod) {
 		container.VolumeMounts = append(container.VolumeMounts, saTokenVolumeMount)
 	}
+	if secret := aclTokenSecret(pod); secret != nil {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:      "DP_CREDENTIAL_STATIC_TOKEN",
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: secret},
+		})
+	}
 
 	if useProxyHealthCheck(pod) {
 		// Configure the Readiness Address for the proxy's health check to be the Pod IP.
@@ -128,6 +138,28 @@ func (w *MeshWebhook) consulDataplaneSidecar(namespace corev1.Namespace, pod cor
 		})
 	}
 
+	// If application containers wait for the proxy to start, the kubelet calls the graceful startup endpoint
+	// as a postStart hook. The endpoint only returns once Envoy is ready or the startup grace period has
+	// passed, and the kubelet doesn't start the containers after the sidecar until the hook returns.
+	startupGracePeriodSeconds, err := w.LifecycleConfig.StartupGracePeriodSeconds(pod)
+	if err != nil {
+		return corev1.Container{}, fmt.Errorf("unable to determine proxy lifecycle startup grace period: %w", err)
+	}
+	if startupGracePeriodSeconds > 0 {
+		gracefulPort, err := w.gracefulPort(pod, mpi)
+		if err != nil {
+			return corev1.Container{}, err
+		}
+		container.Lifecycle = &corev1.Lifecycle{
+			PostStart: &corev1.LifecycleHandler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Path: w.LifecycleConfig.GracefulStartupPath(pod),
+					Port: intstr.FromInt(gracefulPort),
+				},
+			},
+		}
+	}
+
 	// Add any extra VolumeMounts.
 	if userVolMount, ok := pod.Annotations[constants.AnnotationConsulSidecarUserVolumeMount]; ok {
 		var volumeMounts []corev1.VolumeMount
@@ -158,7 +190,7 @@ func (w *MeshWebhook) consulDataplaneSidecar(namespace corev1.Namespace, pod cor
 		// has only injected init containers so all containers defined in pod.Spec.Containers are from the user.
 		for _, c := range pod.Spec.Containers {
 			// User container and consul-dataplane container cannot have the same UID.
-			if c.SecurityContext != nil && c.SecurityContext.RunAsUser != nil && *c.SecurityContext.RunAsUser == sidecarUserAndGroupID && c.Image != w.ImageConsulDataplane {
+			if c.SecurityContext != nil && c.SecurityContext.RunAsUser != nil && *c.SecurityContext.RunAsUser == sidecarUserAndGroupID && c.Image != container.Image {
 				return corev1.Container{}, fmt.Errorf("container %q has runAsUser set to the same UID \"%d\" as consul-dataplane which is not allowed", c.Name, sidecarUserAndGroupID)
 			}
 		}
@@ -170,6 +202,14 @@ func (w *MeshWebhook) consulDataplaneSidecar(namespace corev1.Namespace, pod cor
 		}
 	}
 
+	if w.EnableRestrictedPodSecurity {
+		w.restrictSecurityContext(&container, sidecarUserAndGroupID)
+	}
+
+	if err := w.setSecurityProfiles(pod, &container); err != nil {
+		return corev1.Container{}, err
+	}
+
 	return container, nil
 }
 
@@ -203,7 +243,10 @@ func (w *MeshWebhook) getContainerSidecarArgs(namespace corev1.Namespace, mpi mu
 		args = append(args, "-server-watch-disabled=true")
 	}
 
-	if w.AuthMethod != "" {
+	if aclTokenSecret(pod) != nil {
+		// The token is set with the DP_CREDENTIAL_STATIC_TOKEN environment variable from the Secret.
+		args = append(args, "-credential-type=static")
+	} else if w.AuthMethod != "" {
 		args = append(args,
 			"-credential-type=login",
 			"-login-auth-method="+w.AuthMethod,
@@ -249,14 +292,9 @@ func (w *MeshWebhook) getContainerSidecarArgs(namespace corev1.Namespace, mpi mu
 
 	// The consul-dataplane HTTP listener always starts for graceful shutdown. To avoid port conflicts, the
 	// graceful port always needs to be set
-	gracefulPort, err := w.LifecycleConfig.GracefulPort(pod)
+	gracefulPort, err := w.gracefulPort(pod, mpi)
 	if err != nil {
-		return nil, fmt.Errorf("unable to determine proxy lifecycle graceful port: %w", err)
-	}
-
-	// To avoid conflicts
-	if mpi.serviceName != "" {
-		gracefulPort = gracefulPort + mpi.serviceIndex
+		return nil, err
 	}
 	args = append(args, fmt.Sprintf("-graceful-port=%d", gracefulPort))
 
@@ -286,6 +324,15 @@ func (w *MeshWebhook) getContainerSidecarArgs(namespace corev1.Namespace, mpi mu
 		args = append(args, fmt.Sprintf("-graceful-shutdown-path=%s", gracefulShutdownPath))
 	}
 
+	startupGracePeriodSeconds, err := w.LifecycleConfig.StartupGracePeriodSeconds(pod)
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine proxy lifecycle startup grace period: %w", err)
+	}
+	if startupGracePeriodSeconds > 0 {
+		args = append(args, fmt.Sprintf("-startup-grace-period-seconds=%d", startupGracePeriodSeconds))
+		args = append(args, fmt.Sprintf("-graceful-startup-path=%s", w.LifecycleConfig.GracefulStartupPath(pod)))
+	}
+
 	// Set a default scrape path that can be overwritten by the annotation.
 	prometheusScrapePath := w.MetricsConfig.PrometheusScrapePath(pod)
 	args = append(args, "-telemetry-prom-scrape-path="+prometheusScrapePath)
@@ -308,7 +355,7 @@ func (w *MeshWebhook) getContainerSidecarArgs(namespace corev1.Namespace, mpi mu
 		}
 
 		if serviceMetricsPath != "" && serviceMetricsPort != "" {
-			args = append(args, "-telemetry-prom-service-metrics-url="+fmt.Sprintf("http://127.0.0.1:%s%s", serviceMetricsPort, serviceMetricsPath))
+			args = append(args, "-telemetry-prom-service-metrics-url="+fmt.Sprintf("http://%s%s", net.JoinHostPort(common.LocalhostAddress(w.EnableIPv6), serviceMetricsPort), serviceMetricsPath))
 		}
 
 		// Pull the TLS config from the relevant annotations.
@@ -358,6 +405,9 @@ func (w *MeshWebhook) getContainerSidecarArgs(namespace corev1.Namespace, mpi mu
 		return nil, err
 	}
 	if dnsEnabled {
+		if w.EnableIPv6 {
+			args = append(args, "-consul-dns-bind-addr="+common.LocalhostAddress(w.EnableIPv6))
+		}
 		args = append(args, "-consul-dns-bind-port="+strconv.Itoa(consulDataplaneDNSBindPort))
 	}
 
@@ -435,6 +485,8 @@ func (w *MeshWebhook) sidecarResources(pod corev1.Pod) (corev1.ResourceRequireme
 			return corev1.ResourceRequirements{}, fmt.Errorf("parsing annotation %s:%q: %s", constants.AnnotationSidecarProxyCPURequest, anno, err)
 		}
 		resources.Requests[corev1.ResourceCPU] = cpuRequest
+	} else if cpuRequest, ok := w.ProxyCPURequestProportion.request(pod, corev1.ResourceCPU, resources.Limits); ok {
+		resources.Requests[corev1.ResourceCPU] = cpuRequest
 	} else if w.DefaultProxyCPURequest != zeroQuantity {
 		resources.Requests[corev1.ResourceCPU] = w.DefaultProxyCPURequest
 	}
@@ -457,6 +509,8 @@ func (w *MeshWebhook) sidecarResources(pod corev1.Pod) (corev1.ResourceRequireme
 			return corev1.ResourceRequirements{}, fmt.Errorf("parsing annotation %s:%q: %s", constants.AnnotationSidecarProxyMemoryRequest, anno, err)
 		}
 		resources.Requests[corev1.ResourceMemory] = memoryRequest
+	} else if memoryRequest, ok := w.ProxyMemoryRequestProportion.request(pod, corev1.ResourceMemory, resources.Limits); ok {
+		resources.Requests[corev1.ResourceMemory] = memoryRequest
 	} else if w.DefaultProxyMemoryRequest != zeroQuantity {
 		resources.Requests[corev1.ResourceMemory] = w.DefaultProxyMemoryRequest
 	}
@@ -464,6 +518,52 @@ func (w *MeshWebhook) sidecarResources(pod corev1.Pod) (corev1.ResourceRequireme
 	return resources, nil
 }
 
+// ProportionalRequest sizes the request of a sidecar proxy as a percentage of the total request
+// of the application containers of its pod, clamped to Min and Max if they're set. It's disabled
+// if Percent is 0.
+type ProportionalRequest struct {
+	Percent  int
+	Min, Max resource.Quantity
+}
+
+// request returns the proxy's request of the resource, or false if it's disabled or the application
+// containers don't request the resource. The request is never greater than the proxy's limit.
+func (p ProportionalRequest) request(pod corev1.Pod, name corev1.ResourceName, limits corev1.ResourceList) (resource.Quantity, bool) {
+	if p.Percent <= 0 {
+		return resource.Quantity{}, false
+	}
+	var total resource.Quantity
+	for _, c := range pod.Spec.Containers {
+		// Multi port pods already have the sidecars of their previous services.
+		if c.Name == sidecarContainer || strings.HasPrefix(c.Name, sidecarContainer+"-") {
+			continue
+		}
+		if q, ok := c.Resources.Requests[name]; ok {
+			total.Add(q)
+		}
+	}
+	if total.IsZero() {
+		return resource.Quantity{}, false
+	}
+
+	var request resource.Quantity
+	if name == corev1.ResourceCPU {
+		request = *resource.NewMilliQuantity(total.MilliValue()*int64(p.Percent)/100, resource.DecimalSI)
+	} else {
+		request = *resource.NewQuantity(total.Value()*int64(p.Percent)/100, resource.BinarySI)
+	}
+	if !p.Min.IsZero() && request.Cmp(p.Min) < 0 {
+		request = p.Min
+	}
+	if !p.Max.IsZero() && request.Cmp(p.Max) > 0 {
+		request = p.Max
+	}
+	if limit, ok := limits[name]; ok && request.Cmp(limit) > 0 {
+		request = limit
+	}
+	return request, true
+}
+
 // useProxyHealthCheck returns true if the pod has the annotation 'consul.hashicorp.com/use-proxy-health-check'
 // set to truthy values.
 func useProxyHealthCheck(pod corev1.Pod) bool {
@@ -476,3 +576,18 @@ func useProxyHealthCheck(pod corev1.Pod) bool {
 	}
 	return false
 }
+
+// gracefulPort returns the port of the consul-dataplane HTTP listener for graceful startup and shutdown.
+// Multi port pods run a consul-dataplane for each service, so the port is offset by the service index.
+func (w *MeshWebhook) gracefulPort(pod corev1.Pod, mpi multiPortInfo) (int, error) {
+	gracefulPort, err := w.LifecycleConfig.GracefulPort(pod)
+	if err != nil {
+		return 0, fmt.Errorf("unable to determine proxy lifecycle graceful port: %w", err)
+	}
+
+	// To avoid conflicts
+	if mpi.serviceName != "" {
+		gracefulPort = gracefulPort + mpi.serviceIndex
+	}
+	return gracefulPort, nil
+}
@@ -228,6 +228,118 @@ func TestHandlerConsulDataplaneSidecar(t *testing.T) {
 	}
 }
 
+func TestHandlerConsulDataplaneSidecar_LoginAudience(t *testing.T) {
+	w := &MeshWebhook{
+		ConsulAddress:       "1.1.1.1",
+		ConsulConfig:        &consul.Config{GRPCPort: 8502},
+		AuthMethod:          "test-auth-method",
+		ConsulLoginAudience: "consul",
+	}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-pod",
+			Annotations: map[string]string{constants.AnnotationService: "foo"},
+		},
+		Spec: corev1.PodSpec{
+			Containers:         []corev1.Container{{Name: "web"}},
+			ServiceAccountName: "web",
+		},
+	}
+
+	container, err := w.consulDataplaneSidecar(testNS, pod, multiPortInfo{})
+	require.NoError(t, err)
+	require.Contains(t, container.Args, "-login-bearer-token-path=/consul/login/token")
+	require.Contains(t, container.VolumeMounts, corev1.VolumeMount{
+		Name:      loginTokenVolumeName,
+		ReadOnly:  true,
+		MountPath: loginTokenMountPath,
+	})
+
+	volume := w.loginTokenVolume()
+	require.Equal(t, loginTokenVolumeName, volume.Name)
+	projection := volume.Projected.Sources[0].ServiceAccountToken
+	require.Equal(t, "consul", projection.Audience)
+	require.Equal(t, "token", projection.Path)
+	require.Equal(t, int64(loginTokenExpirationSeconds), *projection.ExpirationSeconds)
+}
+
+func TestHandlerConsulDataplaneSidecar_ACLTokenSecret(t *testing.T) {
+	cases := map[string]struct {
+		annotations  map[string]string
+		expSecretKey *corev1.SecretKeySelector
+	}{
+		"no annotation": {},
+		"secret": {
+			annotations: map[string]string{constants.AnnotationACLTokenSecret: "web-token"},
+			expSecretKey: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "web-token"},
+				Key:                  "token",
+			},
+		},
+		"secret with key": {
+			annotations: map[string]string{
+				constants.AnnotationACLTokenSecret:    "web-token",
+				constants.AnnotationACLTokenSecretKey: "secret-id",
+			},
+			expSecretKey: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "web-token"},
+				Key:                  "secret-id",
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := &MeshWebhook{
+				AuthMethod:    "test-auth-method",
+				ConsulAddress: "1.1.1.1",
+				ConsulConfig:  &consul.Config{GRPCPort: 8502},
+			}
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-pod",
+					Annotations: map[string]string{constants.AnnotationService: "foo"},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "service-account-secret",
+									MountPath: "/var/run/secrets/kubernetes.io/serviceaccount",
+								},
+							},
+						},
+					},
+				},
+			}
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+
+			container, err := w.consulDataplaneSidecar(testNS, pod, multiPortInfo{})
+			require.NoError(t, err)
+
+			saMount := corev1.VolumeMount{Name: "service-account-secret", MountPath: "/var/run/secrets/kubernetes.io/serviceaccount"}
+			if c.expSecretKey == nil {
+				require.Contains(t, container.Args, "-credential-type=login")
+				require.Contains(t, container.VolumeMounts, saMount)
+				for _, env := range container.Env {
+					require.NotEqual(t, "DP_CREDENTIAL_STATIC_TOKEN", env.Name)
+				}
+				return
+			}
+			require.Contains(t, container.Args, "-credential-type=static")
+			require.NotContains(t, container.Args, "-credential-type=login")
+			require.NotContains(t, container.VolumeMounts, saMount)
+			require.Contains(t, container.Env, corev1.EnvVar{
+				Name:      "DP_CREDENTIAL_STATIC_TOKEN",
+				ValueFrom: &corev1.EnvVarSource{SecretKeyRef: c.expSecretKey},
+			})
+		})
+	}
+}
+
 func TestHandlerConsulDataplaneSidecar_Concurrency(t *testing.T) {
 	cases := map[string]struct {
 		annotations map[string]string
@@ -407,6 +519,34 @@ func TestHandlerConsulDataplaneSidecar_DNSProxy(t *testing.T) {
 	}
 }
 
+func TestHandlerConsulDataplaneSidecar_DNSProxyIPv6(t *testing.T) {
+	h := MeshWebhook{
+		ConsulConfig:           &consul.Config{HTTPPort: 8500, GRPCPort: 8502},
+		EnableTransparentProxy: true,
+		EnableConsulDNS:        true,
+		EnableIPv6:             true,
+	}
+	pod := corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "web",
+				},
+			},
+		},
+	}
+	ns := corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: k8sNamespace,
+		},
+	}
+
+	container, err := h.consulDataplaneSidecar(ns, pod, multiPortInfo{})
+	require.NoError(t, err)
+	require.Contains(t, container.Args, "-consul-dns-bind-addr=::1")
+	require.Contains(t, container.Args, "-consul-dns-bind-port=8600")
+}
+
 func TestHandlerConsulDataplaneSidecar_ProxyHealthCheck(t *testing.T) {
 	h := MeshWebhook{
 		ConsulConfig:  &consul.Config{HTTPPort: 8500, GRPCPort: 8502},
@@ -1176,6 +1316,108 @@ func TestHandlerConsulDataplaneSidecar_Resources(t *testing.T) {
 	}
 }
 
+func TestHandlerConsulDataplaneSidecar_ProportionalResources(t *testing.T) {
+	appRequests := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("500m"),
+		corev1.ResourceMemory: resource.MustParse("1Gi"),
+	}
+
+	cases := map[string]struct {
+		webhook     MeshWebhook
+		annotations map[string]string
+		requests    corev1.ResourceList
+		expCPU      string
+		expMemory   string
+	}{
+		"percentage of the application requests": {
+			webhook: MeshWebhook{
+				ProxyCPURequestProportion:    ProportionalRequest{Percent: 20},
+				ProxyMemoryRequestProportion: ProportionalRequest{Percent: 25},
+			},
+			requests:  appRequests,
+			expCPU:    "100m",
+			expMemory: "256Mi",
+		},
+		"clamped to min": {
+			webhook: MeshWebhook{
+				ProxyCPURequestProportion:    ProportionalRequest{Percent: 1, Min: resource.MustParse("50m")},
+				ProxyMemoryRequestProportion: ProportionalRequest{Percent: 1, Min: resource.MustParse("64Mi")},
+			},
+			requests:  appRequests,
+			expCPU:    "50m",
+			expMemory: "64Mi",
+		},
+		"clamped to max": {
+			webhook: MeshWebhook{
+				ProxyCPURequestProportion:    ProportionalRequest{Percent: 50, Max: resource.MustParse("200m")},
+				ProxyMemoryRequestProportion: ProportionalRequest{Percent: 50, Max: resource.MustParse("256Mi")},
+			},
+			requests:  appRequests,
+			expCPU:    "200m",
+			expMemory: "256Mi",
+		},
+		"clamped to limit": {
+			webhook: MeshWebhook{
+				DefaultProxyCPULimit:         resource.MustParse("100m"),
+				DefaultProxyMemoryLimit:      resource.MustParse("128Mi"),
+				ProxyCPURequestProportion:    ProportionalRequest{Percent: 50},
+				ProxyMemoryRequestProportion: ProportionalRequest{Percent: 50},
+			},
+			requests:  appRequests,
+			expCPU:    "100m",
+			expMemory: "128Mi",
+		},
+		"defaults when the application has no requests": {
+			webhook: MeshWebhook{
+				DefaultProxyCPURequest:       resource.MustParse("25m"),
+				DefaultProxyMemoryRequest:    resource.MustParse("32Mi"),
+				ProxyCPURequestProportion:    ProportionalRequest{Percent: 10},
+				ProxyMemoryRequestProportion: ProportionalRequest{Percent: 10},
+			},
+			expCPU:    "25m",
+			expMemory: "32Mi",
+		},
+		"annotations take precedence": {
+			webhook: MeshWebhook{
+				ProxyCPURequestProportion:    ProportionalRequest{Percent: 10},
+				ProxyMemoryRequestProportion: ProportionalRequest{Percent: 10},
+			},
+			annotations: map[string]string{
+				constants.AnnotationSidecarProxyCPURequest:    "300m",
+				constants.AnnotationSidecarProxyMemoryRequest: "300Mi",
+			},
+			requests:  appRequests,
+			expCPU:    "300m",
+			expMemory: "300Mi",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			c.webhook.ConsulConfig = &consul.Config{HTTPPort: 8500, GRPCPort: 8502}
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: c.annotations,
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:      "web",
+							Resources: corev1.ResourceRequirements{Requests: c.requests},
+						},
+					},
+				},
+			}
+			container, err := c.webhook.consulDataplaneSidecar(testNS, pod, multiPortInfo{})
+			require.NoError(t, err)
+			cpu := container.Resources.Requests[corev1.ResourceCPU]
+			memory := container.Resources.Requests[corev1.ResourceMemory]
+			require.Equal(t, c.expCPU, cpu.String())
+			require.Equal(t, c.expMemory, memory.String())
+		})
+	}
+}
+
 func TestHandlerConsulDataplaneSidecar_Metrics(t *testing.T) {
 	cases := []struct {
 		name       string
@@ -1300,6 +1542,100 @@ func TestHandlerConsulDataplaneSidecar_Metrics(t *testing.T) {
 	}
 }
 
+func TestHandlerConsulDataplaneSidecar_LifecycleStartup(t *testing.T) {
+	cases := []struct {
+		name         string
+		webhook      MeshWebhook
+		annotations  map[string]string
+		mpi          multiPortInfo
+		expCmdArgs   string
+		expPostStart *corev1.LifecycleHandler
+	}{
+		{
+			name:    "no defaults, no annotations",
+			webhook: MeshWebhook{},
+		},
+		{
+			name: "startup grace period set via meshWebhook",
+			webhook: MeshWebhook{
+				LifecycleConfig: lifecycle.Config{
+					DefaultStartupGracePeriodSeconds: 30,
+				},
+			},
+			expCmdArgs: "-startup-grace-period-seconds=30 -graceful-startup-path=/graceful_startup",
+			expPostStart: &corev1.LifecycleHandler{
+				HTTPGet: &corev1.HTTPGetAction{Path: "/graceful_startup", Port: intstr.FromInt(constants.DefaultGracefulPort)},
+			},
+		},
+		{
+			name:    "startup grace period set via annotations",
+			webhook: MeshWebhook{},
+			annotations: map[string]string{
+				constants.AnnotationSidecarProxyLifecycleStartupGracePeriodSeconds: "15",
+				constants.AnnotationSidecarProxyLifecycleGracefulStartupPath:       "/start",
+				constants.AnnotationSidecarProxyLifecycleGracefulPort:              "20307",
+			},
+			expCmdArgs: "-startup-grace-period-seconds=15 -graceful-startup-path=/start",
+			expPostStart: &corev1.LifecycleHandler{
+				HTTPGet: &corev1.HTTPGetAction{Path: "/start", Port: intstr.FromInt(20307)},
+			},
+		},
+		{
+			name: "annotation disables startup grace period",
+			webhook: MeshWebhook{
+				LifecycleConfig: lifecycle.Config{
+					DefaultStartupGracePeriodSeconds: 30,
+				},
+			},
+			annotations: map[string]string{
+				constants.AnnotationSidecarProxyLifecycleStartupGracePeriodSeconds: "0",
+			},
+		},
+		{
+			name: "multi port pods use the graceful port of the service",
+			webhook: MeshWebhook{
+				LifecycleConfig: lifecycle.Config{
+					DefaultStartupGracePeriodSeconds: 30,
+				},
+			},
+			mpi:        multiPortInfo{serviceIndex: 1, serviceName: "web-admin"},
+			expCmdArgs: "-startup-grace-period-seconds=30 -graceful-startup-path=/graceful_startup",
+			expPostStart: &corev1.LifecycleHandler{
+				HTTPGet: &corev1.HTTPGetAction{Path: "/graceful_startup", Port: intstr.FromInt(constants.DefaultGracefulPort + 1)},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.webhook.ConsulConfig = &consul.Config{HTTPPort: 8500, GRPCPort: 8502}
+			require := require.New(t)
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: c.annotations,
+				},
+
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+						},
+					},
+				},
+			}
+			container, err := c.webhook.consulDataplaneSidecar(testNS, pod, c.mpi)
+			require.NoError(err)
+			if c.expPostStart == nil {
+				require.NotContains(strings.Join(container.Args, " "), "-startup-grace-period-seconds")
+				require.Nil(container.Lifecycle)
+				return
+			}
+			require.Contains(strings.Join(container.Args, " "), c.expCmdArgs)
+			require.NotNil(container.Lifecycle)
+			require.Equal(c.expPostStart, container.Lifecycle.PostStart)
+		})
+	}
+}
+
 func TestHandlerConsulDataplaneSidecar_Lifecycle(t *testing.T) {
 	gracefulShutdownSeconds := 10
 	gracefulPort := "20307"
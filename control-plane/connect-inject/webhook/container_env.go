@@ -21,7 +21,11 @@ func (w *MeshWebhook) containerEnvVars(pod corev1.Pod) []corev1.EnvVar {
 
 	var result []corev1.EnvVar
 	for _, raw := range strings.Split(raw, ",") {
+		raw, _ = common.SplitUpstreamOptions(raw)
 		parts := strings.SplitN(raw, ":", 3)
+		if len(parts) < 2 {
+			continue
+		}
 		port, _ := common.PortValue(pod, strings.TrimSpace(parts[1]))
 		if port > 0 {
 			name := strings.TrimSpace(parts[0])
@@ -30,7 +34,7 @@ func (w *MeshWebhook) containerEnvVars(pod corev1.Pod) []corev1.EnvVar {
 
 			result = append(result, corev1.EnvVar{
 				Name:  fmt.Sprintf("%s_CONNECT_SERVICE_HOST", name),
-				Value: "127.0.0.1",
+				Value: common.LocalhostAddress(w.EnableIPv6),
 			}, corev1.EnvVar{
 				Name:  fmt.Sprintf("%s_CONNECT_SERVICE_PORT", name),
 				Value: portStr,
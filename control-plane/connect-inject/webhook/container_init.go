@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/common"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
@@ -36,6 +37,20 @@ type initContainerCommandData struct {
 	// Log settings for the connect-init command.
 	LogLevel string
 	LogJSON  bool
+
+	// EnableIPv6 configures connect-init to apply traffic redirection rules with ip6tables.
+	EnableIPv6 bool
+
+	// TerminationMessageFile is the file connect-init writes its progress and failure reason to,
+	// if set.
+	TerminationMessageFile string
+
+	// Settings for how long and how often connect-init retries connecting to the Consul servers.
+	// Connect-init's defaults are used for those that are zero.
+	ServerWaitTimeout         time.Duration
+	ServerWaitInitialInterval time.Duration
+	ServerWaitMaxInterval     time.Duration
+	ServerWaitJitter          float64
 }
 
 // containerInit returns the init container spec for connect-init that polls for the service and the connect proxy service to be registered
@@ -49,11 +64,31 @@ func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod,
 
 	multiPort := mpi.serviceName != ""
 
+	image, err := w.imageConsulK8S(namespace)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+
+	var authMethod string
+	if w.loginWithAuthMethod(pod) {
+		authMethod = w.AuthMethod
+	}
 	data := initContainerCommandData{
-		AuthMethod: w.AuthMethod,
+		AuthMethod: authMethod,
 		MultiPort:  multiPort,
 		LogLevel:   w.LogLevel,
 		LogJSON:    w.LogJSON,
+		EnableIPv6: w.EnableIPv6,
+
+		ServerWaitTimeout:         w.InitContainerServerWaitTimeout,
+		ServerWaitInitialInterval: w.InitContainerServerWaitInitialInterval,
+		ServerWaitMaxInterval:     w.InitContainerServerWaitMaxInterval,
+		ServerWaitJitter:          w.InitContainerServerWaitJitter,
+	}
+	// Images that namespaces override the injector's image with may be older builds of
+	// connect-init without the -termination-message-file flag.
+	if image == w.ImageConsulK8S {
+		data.TerminationMessageFile = corev1.TerminationMessagePathDefault
 	}
 
 	// Create expected volume mounts
@@ -70,7 +105,7 @@ func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod,
 		data.ServiceName = pod.Annotations[constants.AnnotationService]
 	}
 	var bearerTokenFile string
-	if w.AuthMethod != "" {
+	if authMethod != "" {
 		if multiPort {
 			// If multi port then we require that the service account name
 			// matches the service name.
@@ -80,7 +115,7 @@ func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod,
 		}
 		// Extract the service account token's volume mount
 		var saTokenVolumeMount corev1.VolumeMount
-		saTokenVolumeMount, bearerTokenFile, err = findServiceAccountVolumeMount(pod, mpi.serviceName)
+		saTokenVolumeMount, bearerTokenFile, err = w.serviceAccountVolumeMount(pod, mpi.serviceName)
 		if err != nil {
 			return corev1.Container{}, err
 		}
@@ -104,7 +139,7 @@ func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod,
 	}
 	container := corev1.Container{
 		Name:  initContainerName,
-		Image: w.ImageConsulK8S,
+		Image: image,
 		Env: []corev1.EnvVar{
 			{
 				Name: "POD_NAME",
@@ -168,7 +203,13 @@ func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod,
 			})
 	}
 
-	if w.AuthMethod != "" {
+	if secret := aclTokenSecret(pod); secret != nil {
+		container.Env = append(container.Env,
+			corev1.EnvVar{
+				Name:      "CONSUL_ACL_TOKEN",
+				ValueFrom: &corev1.EnvVarSource{SecretKeyRef: secret},
+			})
+	} else if w.AuthMethod != "" {
 		container.Env = append(container.Env,
 			corev1.EnvVar{
 				Name:  "CONSUL_LOGIN_AUTH_METHOD",
@@ -224,6 +265,9 @@ func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod,
 	}
 
 	if tproxyEnabled {
+		if !w.EnableCNI && w.EnableRestrictedPodSecurity {
+			return corev1.Container{}, errRestrictedTProxyRequiresCNI
+		}
 		if !w.EnableCNI {
 			// Set redirect traffic config for the container so that we can apply iptables rules.
 			redirectTrafficConfig, err := w.iptablesConfigJSON(pod, namespace)
@@ -261,6 +305,14 @@ func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod,
 		}
 	}
 
+	if w.EnableRestrictedPodSecurity {
+		w.restrictSecurityContext(&container, initContainersUserAndGroupID)
+	}
+
+	if err := w.setSecurityProfiles(pod, &container); err != nil {
+		return corev1.Container{}, err
+	}
+
 	return container, nil
 }
 
@@ -295,7 +347,11 @@ func consulDNSEnabled(namespace corev1.Namespace, pod corev1.Pod, globalDNSEnabl
 func splitCommaSeparatedItemsFromAnnotation(annotation string, pod corev1.Pod) []string {
 	var items []string
 	if raw, ok := pod.Annotations[annotation]; ok {
-		items = append(items, strings.Split(raw, ",")...)
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
 	}
 
 	return items
@@ -307,6 +363,24 @@ const initContainerCommandTpl = `
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -log-level={{ .LogLevel }} \
   -log-json={{ .LogJSON }} \
+  {{- if .TerminationMessageFile }}
+  -termination-message-file={{ .TerminationMessageFile }} \
+  {{- end }}
+  {{- if .ServerWaitTimeout }}
+  -server-wait-timeout={{ .ServerWaitTimeout }} \
+  {{- end }}
+  {{- if .ServerWaitInitialInterval }}
+  -server-wait-initial-interval={{ .ServerWaitInitialInterval }} \
+  {{- end }}
+  {{- if .ServerWaitMaxInterval }}
+  -server-wait-max-interval={{ .ServerWaitMaxInterval }} \
+  {{- end }}
+  {{- if .ServerWaitJitter }}
+  -server-wait-jitter={{ .ServerWaitJitter }} \
+  {{- end }}
+  {{- if .EnableIPv6 }}
+  -enable-ipv6=true \
+  {{- end }}
   {{- if .AuthMethod }}
   -service-account-name="{{ .ServiceAccountName }}" \
   -service-name="{{ .ServiceName }}" \
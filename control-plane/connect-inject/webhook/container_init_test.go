@@ -69,7 +69,8 @@ func TestHandlerContainerInit(t *testing.T) {
 			},
 			`/bin/sh -ec consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -log-level=info \
-  -log-json=false \`,
+  -log-json=false \
+  -termination-message-file=/dev/termination-log \`,
 			[]corev1.EnvVar{
 				{
 					Name:  "CONSUL_ADDRESSES",
@@ -117,6 +118,7 @@ func TestHandlerContainerInit(t *testing.T) {
 			`/bin/sh -ec consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -log-level=debug \
   -log-json=true \
+  -termination-message-file=/dev/termination-log \
   -service-account-name="a-service-account-name" \
   -service-name="web" \`,
 			[]corev1.EnvVar{
@@ -154,6 +156,104 @@ func TestHandlerContainerInit(t *testing.T) {
 				},
 			},
 		},
+
+		{
+			"with auth method and ACL token secret",
+			func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[constants.AnnotationService] = "web"
+				pod.Annotations[constants.AnnotationACLTokenSecret] = "web-token"
+				pod.Spec.ServiceAccountName = "a-service-account-name"
+				return pod
+			},
+			MeshWebhook{
+				AuthMethod:    "an-auth-method",
+				ConsulAddress: "10.0.0.0",
+				ConsulConfig:  &consul.Config{HTTPPort: 8500, GRPCPort: 8502},
+				LogLevel:      "info",
+			},
+			`/bin/sh -ec consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
+  -log-level=info \
+  -log-json=false \
+  -termination-message-file=/dev/termination-log \`,
+			[]corev1.EnvVar{
+				{
+					Name:  "CONSUL_ADDRESSES",
+					Value: "10.0.0.0",
+				},
+				{
+					Name:  "CONSUL_GRPC_PORT",
+					Value: "8502",
+				},
+				{
+					Name:  "CONSUL_HTTP_PORT",
+					Value: "8500",
+				},
+				{
+					Name:  "CONSUL_API_TIMEOUT",
+					Value: "0s",
+				},
+				{
+					Name:  "CONSUL_NODE_NAME",
+					Value: "$(NODE_NAME)-virtual",
+				},
+				{
+					Name: "CONSUL_ACL_TOKEN",
+					ValueFrom: &corev1.EnvVarSource{
+						SecretKeyRef: &corev1.SecretKeySelector{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "web-token"},
+							Key:                  "token",
+						},
+					},
+				},
+			},
+		},
+
+		{
+			"with server wait settings",
+			func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[constants.AnnotationService] = "web"
+				return pod
+			},
+			MeshWebhook{
+				ConsulAddress:                          "10.0.0.0",
+				ConsulConfig:                           &consul.Config{HTTPPort: 8500, GRPCPort: 8502},
+				LogLevel:                               "info",
+				InitContainerServerWaitTimeout:         5 * time.Minute,
+				InitContainerServerWaitInitialInterval: time.Second,
+				InitContainerServerWaitMaxInterval:     30 * time.Second,
+				InitContainerServerWaitJitter:          0.2,
+			},
+			`/bin/sh -ec consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
+  -log-level=info \
+  -log-json=false \
+  -termination-message-file=/dev/termination-log \
+  -server-wait-timeout=5m0s \
+  -server-wait-initial-interval=1s \
+  -server-wait-max-interval=30s \
+  -server-wait-jitter=0.2 \`,
+			[]corev1.EnvVar{
+				{
+					Name:  "CONSUL_ADDRESSES",
+					Value: "10.0.0.0",
+				},
+				{
+					Name:  "CONSUL_GRPC_PORT",
+					Value: "8502",
+				},
+				{
+					Name:  "CONSUL_HTTP_PORT",
+					Value: "8500",
+				},
+				{
+					Name:  "CONSUL_API_TIMEOUT",
+					Value: "0s",
+				},
+				{
+					Name:  "CONSUL_NODE_NAME",
+					Value: "$(NODE_NAME)-virtual",
+				},
+			},
+		},
 	}
 
 	for _, tt := range cases {
@@ -354,7 +454,8 @@ func TestHandlerContainerInit_namespacesAndPartitionsEnabled(t *testing.T) {
 			},
 			`/bin/sh -ec consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -log-level=info \
-  -log-json=false \`,
+  -log-json=false \
+  -termination-message-file=/dev/termination-log \`,
 			[]corev1.EnvVar{
 				{
 					Name:  "CONSUL_ADDRESSES",
@@ -397,7 +498,8 @@ func TestHandlerContainerInit_namespacesAndPartitionsEnabled(t *testing.T) {
 			},
 			`/bin/sh -ec consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -log-level=info \
-  -log-json=false \`,
+  -log-json=false \
+  -termination-message-file=/dev/termination-log \`,
 			[]corev1.EnvVar{
 				{
 					Name:  "CONSUL_ADDRESSES",
@@ -444,7 +546,8 @@ func TestHandlerContainerInit_namespacesAndPartitionsEnabled(t *testing.T) {
 			},
 			`/bin/sh -ec consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -log-level=info \
-  -log-json=false \`,
+  -log-json=false \
+  -termination-message-file=/dev/termination-log \`,
 			[]corev1.EnvVar{
 				{
 					Name:  "CONSUL_ADDRESSES",
@@ -487,7 +590,8 @@ func TestHandlerContainerInit_namespacesAndPartitionsEnabled(t *testing.T) {
 			},
 			`/bin/sh -ec consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -log-level=info \
-  -log-json=false \`,
+  -log-json=false \
+  -termination-message-file=/dev/termination-log \`,
 			[]corev1.EnvVar{
 				{
 					Name:  "CONSUL_ADDRESSES",
@@ -536,6 +640,7 @@ func TestHandlerContainerInit_namespacesAndPartitionsEnabled(t *testing.T) {
 			`/bin/sh -ec consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -log-level=info \
   -log-json=false \
+  -termination-message-file=/dev/termination-log \
   -service-account-name="web" \
   -service-name="" \`,
 			[]corev1.EnvVar{
@@ -607,6 +712,7 @@ func TestHandlerContainerInit_namespacesAndPartitionsEnabled(t *testing.T) {
 			`/bin/sh -ec consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -log-level=info \
   -log-json=false \
+  -termination-message-file=/dev/termination-log \
   -service-account-name="web" \
   -service-name="" \`,
 			[]corev1.EnvVar{
@@ -753,6 +859,7 @@ func TestHandlerContainerInit_Multiport(t *testing.T) {
 			[]string{`/bin/sh -ec consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -log-level=info \
   -log-json=false \
+  -termination-message-file=/dev/termination-log \
   -multiport=true \
   -proxy-id-file=/consul/connect-inject/proxyid-web \
   -service-name="web" \`,
@@ -760,6 +867,7 @@ func TestHandlerContainerInit_Multiport(t *testing.T) {
 				`/bin/sh -ec consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -log-level=info \
   -log-json=false \
+  -termination-message-file=/dev/termination-log \
   -multiport=true \
   -proxy-id-file=/consul/connect-inject/proxyid-web-admin \
   -service-name="web-admin" \`,
@@ -791,6 +899,7 @@ func TestHandlerContainerInit_Multiport(t *testing.T) {
 			[]string{`/bin/sh -ec consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -log-level=info \
   -log-json=false \
+  -termination-message-file=/dev/termination-log \
   -service-account-name="web" \
   -service-name="web" \
   -multiport=true \
@@ -799,6 +908,7 @@ func TestHandlerContainerInit_Multiport(t *testing.T) {
 				`/bin/sh -ec consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -log-level=info \
   -log-json=false \
+  -termination-message-file=/dev/termination-log \
   -service-account-name="web-admin" \
   -service-name="web-admin" \
   -multiport=true \
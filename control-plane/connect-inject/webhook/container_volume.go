@@ -7,9 +7,20 @@ import (
 	corev1 "k8s.io/api/core/v1"
 )
 
-// volumeName is the name of the volume that is created to store the
-// Consul Connect injection data.
-const volumeName = "consul-connect-inject-data"
+const (
+	// volumeName is the name of the volume that is created to store the
+	// Consul Connect injection data.
+	volumeName = "consul-connect-inject-data"
+
+	// loginTokenVolumeName is the name of the volume with the projected service
+	// account token that the pod logs in to Consul with.
+	loginTokenVolumeName = "consul-login-token"
+	// loginTokenMountPath is the path that the login token volume is mounted at.
+	loginTokenMountPath = "/consul/login"
+	// loginTokenExpirationSeconds is the lifetime of the login token. The kubelet
+	// refreshes the token before it expires.
+	loginTokenExpirationSeconds = 3600
+)
 
 // containerVolume returns the volume data to add to the pod. This volume
 // is used for shared data between containers.
@@ -21,3 +32,25 @@ func (w *MeshWebhook) containerVolume() corev1.Volume {
 		},
 	}
 }
+
+// loginTokenVolume returns the volume with a service account token that is bound
+// to the pod and has the ConsulLoginAudience as its audience. Unlike the default
+// service account token, it can only be used to log in to Consul and is invalid
+// once the pod is deleted.
+func (w *MeshWebhook) loginTokenVolume() corev1.Volume {
+	expirationSeconds := int64(loginTokenExpirationSeconds)
+	return corev1.Volume{
+		Name: loginTokenVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{{
+					ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+						Audience:          w.ConsulLoginAudience,
+						ExpirationSeconds: &expirationSeconds,
+						Path:              "token",
+					},
+				}},
+			},
+		},
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"gomodules.xyz/jsonpatch/v2"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// ephemeralContainersSubResource is the subresource that ephemeral containers, e.g. of kubectl debug,
+// are added to running pods through.
+const ephemeralContainersSubResource = "ephemeralcontainers"
+
+// defaultDebugContainerUID sets the debug container UID annotation on the pod unless it's already set.
+// The UID is excluded from traffic redirection when the pod's iptables rules are created, so ephemeral
+// containers that are added to the pod later bypass the mesh if they run as this UID.
+func (w *MeshWebhook) defaultDebugContainerUID(pod *corev1.Pod) error {
+	if raw, ok := pod.Annotations[constants.AnnotationDebugContainerUID]; ok {
+		_, err := debugContainerUID(raw)
+		return err
+	}
+	if w.DebugContainerUID > 0 {
+		pod.Annotations[constants.AnnotationDebugContainerUID] = strconv.FormatInt(w.DebugContainerUID, 10)
+	}
+	return nil
+}
+
+// handleEphemeralContainers sets the user of ephemeral containers that are added to an injected pod to
+// the pod's debug container UID, so that their traffic isn't redirected to the proxy. Ephemeral containers
+// that set their own user are left as they are.
+func (w *MeshWebhook) handleEphemeralContainers(pod corev1.Pod, log logr.Logger) admission.Response {
+	raw, ok := pod.Annotations[constants.AnnotationDebugContainerUID]
+	if !ok || pod.Annotations[constants.KeyInjectStatus] != constants.Injected {
+		return admission.Allowed(fmt.Sprintf("%s %s does not exclude ephemeral containers from the mesh", pod.Kind, pod.Name))
+	}
+	uid, err := debugContainerUID(raw)
+	if err != nil {
+		log.Error(err, "error determining debug container UID", "name", pod.Name)
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	origPodJson, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	for i := range pod.Spec.EphemeralContainers {
+		container := &pod.Spec.EphemeralContainers[i]
+		if container.SecurityContext == nil {
+			container.SecurityContext = &corev1.SecurityContext{}
+		}
+		if container.SecurityContext.RunAsUser == nil {
+			container.SecurityContext.RunAsUser = &uid
+		}
+	}
+	updatedPodJson, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	patches, err := jsonpatch.CreatePatch(origPodJson, updatedPodJson)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	return admission.Patched(fmt.Sprintf("valid %s ephemeral containers request", pod.Kind), patches...)
+}
+
+// debugContainerUID parses the value of the debug container UID annotation.
+func debugContainerUID(raw string) (int64, error) {
+	uid, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%s annotation value of %s was invalid: %s", constants.AnnotationDebugContainerUID, raw, err)
+	}
+	return int64(uid), nil
+}
@@ -0,0 +1,150 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/stretchr/testify/require"
+	"gomodules.xyz/jsonpatch/v2"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestHandleEphemeralContainers(t *testing.T) {
+	s := runtime.NewScheme()
+	s.AddKnownTypes(schema.GroupVersion{Group: "", Version: "v1"}, &corev1.Pod{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(t, err)
+
+	injectedAnnotations := map[string]string{
+		constants.KeyInjectStatus:             constants.Injected,
+		constants.AnnotationDebugContainerUID: "5997",
+	}
+	debugContainer := corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debugger"},
+	}
+
+	cases := map[string]struct {
+		annotations         map[string]string
+		ephemeralContainers []corev1.EphemeralContainer
+		expPatches          []jsonpatch.Operation
+		expErr              string
+	}{
+		"sets the user of ephemeral containers": {
+			annotations:         injectedAnnotations,
+			ephemeralContainers: []corev1.EphemeralContainer{debugContainer},
+			expPatches: []jsonpatch.Operation{{
+				Operation: "add",
+				Path:      "/spec/ephemeralContainers/0/securityContext",
+				Value:     map[string]interface{}{"runAsUser": float64(5997)},
+			}},
+		},
+		"keeps the user of ephemeral containers that set one": {
+			annotations: injectedAnnotations,
+			ephemeralContainers: []corev1.EphemeralContainer{{
+				EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+					Name:            "debugger",
+					SecurityContext: &corev1.SecurityContext{RunAsUser: pointer.Int64(1000)},
+				},
+			}},
+		},
+		"ignores pods without the debug container UID": {
+			annotations:         map[string]string{constants.KeyInjectStatus: constants.Injected},
+			ephemeralContainers: []corev1.EphemeralContainer{debugContainer},
+		},
+		"ignores pods that aren't injected": {
+			annotations:         map[string]string{constants.AnnotationDebugContainerUID: "5997"},
+			ephemeralContainers: []corev1.EphemeralContainer{debugContainer},
+		},
+		"invalid debug container UID": {
+			annotations: map[string]string{
+				constants.KeyInjectStatus:             constants.Injected,
+				constants.AnnotationDebugContainerUID: "root",
+			},
+			ephemeralContainers: []corev1.EphemeralContainer{debugContainer},
+			expErr:              "consul.hashicorp.com/debug-container-uid annotation value of root was invalid",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				Log:                   logrtest.New(t),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+			}
+			resp := w.Handle(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace:   "default",
+					Operation:   admissionv1.Update,
+					SubResource: ephemeralContainersSubResource,
+					Object: encodeRaw(t, &corev1.Pod{
+						ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: c.annotations},
+						Spec: corev1.PodSpec{
+							Containers:          []corev1.Container{{Name: "web"}},
+							EphemeralContainers: c.ephemeralContainers,
+						},
+					}),
+				},
+			})
+			if c.expErr != "" {
+				require.False(t, resp.Allowed)
+				require.Contains(t, resp.Result.Message, c.expErr)
+				return
+			}
+			require.True(t, resp.Allowed)
+			require.ElementsMatch(t, c.expPatches, resp.Patches)
+		})
+	}
+}
+
+func TestDefaultDebugContainerUID(t *testing.T) {
+	cases := map[string]struct {
+		uid         int64
+		annotations map[string]string
+		expUID      string
+		expErr      string
+	}{
+		"sets the annotation": {
+			uid:    5997,
+			expUID: "5997",
+		},
+		"does not set the annotation when disabled": {},
+		"keeps the annotation of the pod": {
+			uid:         5997,
+			annotations: map[string]string{constants.AnnotationDebugContainerUID: "6000"},
+			expUID:      "6000",
+		},
+		"invalid annotation": {
+			annotations: map[string]string{constants.AnnotationDebugContainerUID: "-1"},
+			expErr:      "consul.hashicorp.com/debug-container-uid annotation value of -1 was invalid",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := minimal()
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+			w := MeshWebhook{DebugContainerUID: c.uid}
+			err := w.defaultDebugContainerUID(pod)
+			if c.expErr != "" {
+				require.ErrorContains(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expUID, pod.Annotations[constants.AnnotationDebugContainerUID])
+		})
+	}
+}
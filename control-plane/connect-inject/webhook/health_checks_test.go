@@ -4,11 +4,15 @@
 package webhook
 
 import (
+	"errors"
+	"net/http"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
 )
 
 func TestReady(t *testing.T) {
@@ -40,7 +44,7 @@ func TestReady(t *testing.T) {
 				err := os.WriteFile(filepath.Join(tmpDir, "tls.key"), []byte(*tt.keyFileContents), 0666)
 				require.NoError(t, err)
 			}
-			rc := ReadinessCheck{tmpDir}
+			rc := ReadinessCheck{CertDir: tmpDir}
 			err = rc.Ready(nil)
 			if tt.expectError {
 				require.Error(t, err)
@@ -51,6 +55,58 @@ func TestReady(t *testing.T) {
 	}
 }
 
+func TestReady_FailurePolicy(t *testing.T) {
+	notStarted := func(*http.Request) error { return errors.New("webhook server has not been started yet") }
+	started := func(*http.Request) error { return nil }
+
+	var cases = []struct {
+		name          string
+		failurePolicy admissionv1.FailurePolicyType
+		started       func(*http.Request) error
+		expectError   bool
+	}{
+		{"Fail policy and server not started.", admissionv1.Fail, notStarted, true},
+		{"Fail policy and server started.", admissionv1.Fail, started, false},
+		{"Ignore policy and server not started.", admissionv1.Ignore, notStarted, false},
+		{"Ignore policy and server started.", admissionv1.Ignore, started, false},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "tls.crt"), []byte("test"), 0666))
+			require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "tls.key"), []byte("test"), 0666))
+			rc := ReadinessCheck{CertDir: tmpDir, FailurePolicy: tt.failurePolicy, Started: tt.started}
+			err := rc.Ready(nil)
+			if tt.expectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestReady_Draining(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "tls.crt"), []byte("test"), 0666))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "tls.key"), []byte("test"), 0666))
+	drainer := &Drainer{}
+	rc := ReadinessCheck{CertDir: tmpDir, Drainer: drainer}
+	require.NoError(t, rc.Ready(nil))
+
+	stopped := make(chan struct{})
+	go drainer.Drain(100*time.Millisecond, func() { close(stopped) })
+	require.Eventually(t, drainer.Draining, time.Second, 10*time.Millisecond)
+	require.EqualError(t, rc.Ready(nil), "webhook server is draining")
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("drain did not stop the server")
+	}
+}
+
 func ptrToString(s string) *string {
 	return &s
 }
@@ -8,13 +8,30 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 )
 
 type ReadinessCheck struct {
 	CertDir string
+
+	// FailurePolicy is the failure policy of the mutating webhook configuration. With the Fail
+	// policy, the API server rejects pods when it can't reach the webhook, so the pod only
+	// becomes ready once Started passes.
+	FailurePolicy admissionv1.FailurePolicyType
+	// Started checks that the webhook server is accepting connections.
+	Started healthz.Checker
+	// Drainer makes the pod unready once it starts shutting down.
+	Drainer *Drainer
 }
 
-func (r ReadinessCheck) Ready(_ *http.Request) error {
+func (r ReadinessCheck) Ready(req *http.Request) error {
+	if r.Drainer.Draining() {
+		return errors.New("webhook server is draining")
+	}
 	certFile, err := os.ReadFile(filepath.Join(r.CertDir, "tls.crt"))
 	if err != nil {
 		return err
@@ -26,5 +43,29 @@ func (r ReadinessCheck) Ready(_ *http.Request) error {
 	if len(certFile) == 0 || len(keyFile) == 0 {
 		return errors.New("certificate files have not been loaded")
 	}
+	if r.FailurePolicy == admissionv1.Fail && r.Started != nil {
+		if err := r.Started(req); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// Drainer drains the webhook server before it's stopped. Once draining, the readiness check
+// fails so that the pod is removed from the endpoints of the webhook service, and the server
+// keeps answering admission reviews until the API servers stop sending them to it.
+type Drainer struct {
+	draining atomic.Bool
+}
+
+// Draining returns true once Drain has been called.
+func (d *Drainer) Draining() bool {
+	return d != nil && d.draining.Load()
+}
+
+// Drain marks the webhook server as draining, waits for the duration, and then calls stop.
+func (d *Drainer) Drain(duration time.Duration, stop func()) {
+	d.draining.Store(true)
+	time.Sleep(duration)
+	stop()
+}
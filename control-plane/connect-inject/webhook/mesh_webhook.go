@@ -12,23 +12,29 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/common"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/controllers/cniversion"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/lifecycle"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/metrics"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/correlation"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	"github.com/hashicorp/consul-k8s/control-plane/quota"
 	"github.com/hashicorp/consul-k8s/control-plane/version"
 	"gomodules.xyz/jsonpatch/v2"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
@@ -56,6 +62,10 @@ var kubeSystemNamespaces = mapset.NewSetWith(metav1.NamespaceSystem, metav1.Name
 type MeshWebhook struct {
 	Clientset kubernetes.Interface
 
+	// Cache is the manager's cache that namespaces, and the services and pods counted against
+	// service quotas, are read from. If nil, they're read from the API server through Clientset.
+	Cache client.Reader
+
 	// ConsulClientConfig is the config to create a Consul API client.
 	ConsulConfig *consul.Config
 
@@ -73,6 +83,15 @@ type MeshWebhook struct {
 	// This image is used for the consul-sidecar container.
 	ImageConsulK8S string
 
+	// NamespaceImageOverrides allows namespaces to override ImageConsulDataplane and ImageConsulK8S
+	// for their pods with the consul.hashicorp.com/consul-dataplane-image and
+	// consul.hashicorp.com/consul-k8s-image annotations.
+	NamespaceImageOverrides bool
+
+	// EnableFIPS requires the images that namespaces override to be FIPS variants, like the
+	// images the webhook is configured with.
+	EnableFIPS bool
+
 	// Optional: set when you need extra options to be set when running envoy
 	// See a list of args here: https://www.envoyproxy.io/docs/envoy/latest/operations/cli
 	EnvoyExtraArgs string
@@ -85,6 +104,11 @@ type MeshWebhook struct {
 	// use for identity with connectInjection if ACLs are enabled.
 	AuthMethod string
 
+	// ConsulLoginAudience is the audience of the projected service account token
+	// that pods log in to Consul with. If empty, pods log in with the token of
+	// their service account volume.
+	ConsulLoginAudience string
+
 	// The PEM-encoded CA certificate string
 	// to use when communicating with Consul clients over HTTPS.
 	// If not set, will use HTTP.
@@ -152,6 +176,12 @@ type MeshWebhook struct {
 	DefaultProxyMemoryRequest resource.Quantity
 	DefaultProxyMemoryLimit   resource.Quantity
 
+	// ProxyCPURequestProportion and ProxyMemoryRequestProportion size the requests of sidecar proxies
+	// from the requests of the application containers of their pod. They take precedence over
+	// DefaultProxyCPURequest and DefaultProxyMemoryRequest for pods whose containers request the resource.
+	ProxyCPURequestProportion    ProportionalRequest
+	ProxyMemoryRequestProportion ProportionalRequest
+
 	// LifecycleConfig contains proxy lifecycle management configuration from the inject-connect command and has methods to determine whether
 	// configuration should come from the default flags or annotations. The meshWebhook uses this to configure container sidecar proxy args.
 	LifecycleConfig lifecycle.Config
@@ -168,6 +198,13 @@ type MeshWebhook struct {
 	// will be populated by the defaults provided in the initial flags.
 	InitContainerResources corev1.ResourceRequirements
 
+	// Settings for how long and how often the init container retries connecting to the Consul servers.
+	// The init container's defaults are used for those that are zero.
+	InitContainerServerWaitTimeout         time.Duration
+	InitContainerServerWaitInitialInterval time.Duration
+	InitContainerServerWaitMaxInterval     time.Duration
+	InitContainerServerWaitJitter          float64
+
 	// Resource settings for Consul sidecar. All of these fields
 	// will be populated by the defaults provided in the initial flags.
 	DefaultConsulSidecarResources corev1.ResourceRequirements
@@ -182,7 +219,21 @@ type MeshWebhook struct {
 	// redirection
 	EnableCNI bool
 
-	// TProxyOverwriteProbes controls whether the webhook should mutate pod's HTTP probes
+	// CNIVersionChecker is set when injection should be denied while the consul-cni plugin on the nodes
+	// is a different version than the webhook, e.g. during an upgrade. Only pods whose traffic is redirected
+	// by the CNI plugin are denied.
+	CNIVersionChecker *cniversion.Checker
+
+	// EnableMeshReadyGate adds the consul.hashicorp.com/mesh-ready readiness gate to injected pods so that
+	// they aren't ready until the mesh-ready controller has confirmed that they are wired into the mesh.
+	EnableMeshReadyGate bool
+
+	// DebugContainerUID is the user ID that ephemeral containers of injected pods run as unless they set
+	// their own. It's excluded from traffic redirection so that debug containers bypass the mesh. Ephemeral
+	// containers aren't changed if it's 0.
+	DebugContainerUID int64
+
+	// TProxyOverwriteProbes controls whether the webhook should mutate pod's HTTP and gRPC probes
 	// to point them to the Envoy proxy.
 	TProxyOverwriteProbes bool
 
@@ -195,6 +246,19 @@ type MeshWebhook struct {
 	// those containers to be created otherwise.
 	EnableOpenShift bool
 
+	// EnableRestrictedPodSecurity makes the injected containers comply with the "restricted" Pod Security
+	// Standard. Transparent proxy then requires the CNI plugin, since redirecting traffic from the init
+	// container requires the NET_ADMIN capability.
+	EnableRestrictedPodSecurity bool
+
+	// DefaultSeccompProfile, DefaultAppArmorProfile and DefaultSELinuxOptions are the security profiles of
+	// the injected init and sidecar containers of pods that don't set them with the
+	// consul.hashicorp.com/seccomp-profile, consul.hashicorp.com/apparmor-profile and
+	// consul.hashicorp.com/selinux-options annotations. They're not set if empty.
+	DefaultSeccompProfile  string
+	DefaultAppArmorProfile string
+	DefaultSELinuxOptions  string
+
 	// SkipServerWatch prevents consul-dataplane from consuming the server update stream. This is useful
 	// for situations where Consul servers are behind a load balancer.
 	SkipServerWatch bool
@@ -202,6 +266,27 @@ type MeshWebhook struct {
 	// ReleaseNamespace is the Kubernetes namespace where this webhook is running.
 	ReleaseNamespace string
 
+	// EnableIPv6 indicates that pods only have IPv6 addresses. Containers are configured
+	// to use the IPv6 loopback address and traffic redirection uses ip6tables.
+	EnableIPv6 bool
+
+	// EnableNodeProxy enables the experimental node proxy mode. Pods annotated with
+	// consul.hashicorp.com/node-proxy aren't injected with a sidecar proxy and use the
+	// proxy of their Kubernetes node instead.
+	EnableNodeProxy bool
+
+	// DryRun means that pods aren't injected. Instead, they're annotated with whether they would
+	// have been injected and why.
+	DryRun bool
+
+	// NamespaceUpstreamsConfigMap is the name of the ConfigMap whose upstreams are added
+	// to every injected pod in its namespace. Namespace upstreams are disabled if it's empty.
+	NamespaceUpstreamsConfigMap string
+
+	// QuotaPolicy limits the number of services that the pods of a namespace register in
+	// Consul. Quotas aren't enforced if it's nil.
+	QuotaPolicy *quota.Policy
+
 	// Log
 	Log logr.Logger
 	// Log settings for consul-dataplane and connect-init containers.
@@ -223,12 +308,26 @@ type multiPortInfo struct {
 func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
 	var pod corev1.Pod
 
+	// Use the admission request UID as the correlation ID so that log lines and
+	// Consul requests can be matched up with the API server's audit log.
+	correlationID := string(req.UID)
+	if correlationID == "" {
+		correlationID = correlation.NewID()
+	}
+	log := w.Log.WithValues(correlation.LogKey, correlationID)
+	ctx = logr.NewContext(ctx, log)
+
 	// Decode the pod from the request
 	if err := w.decoder.Decode(req, &pod); err != nil {
-		w.Log.Error(err, "could not unmarshal request to pod")
+		log.Error(err, "could not unmarshal request to pod")
 		return admission.Errored(http.StatusBadRequest, err)
 	}
 
+	// Ephemeral containers are added to running pods, which have already been injected.
+	if req.SubResource == ephemeralContainersSubResource {
+		return w.handleEphemeralContainers(pod, log)
+	}
+
 	// Marshall the contents of the pod that was received. This is compared with the
 	// marshalled contents of the pod after it has been updated to create the jsonpatch.
 	origPodJson, err := json.Marshal(pod)
@@ -240,24 +339,80 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 	// This MUST be done before shouldInject is called since that function
 	// uses these annotations.
 	if err := w.defaultAnnotations(&pod, string(origPodJson)); err != nil {
-		w.Log.Error(err, "error creating default annotations", "request name", req.Name)
+		log.Error(err, "error creating default annotations", "request name", req.Name)
 		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error creating default annotations: %s", err))
 	}
 
 	// Check if we should inject, for example we don't inject in the
 	// system namespaces.
-	if shouldInject, err := w.shouldInject(pod, req.Namespace); err != nil {
-		w.Log.Error(err, "error checking if should inject", "request name", req.Name)
+	shouldInject, reason, err := w.shouldInject(ctx, pod, req.Namespace)
+	if err != nil {
+		log.Error(err, "error checking if should inject", "request name", req.Name)
 		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error checking if should inject: %s", err))
-	} else if !shouldInject {
+	}
+	if w.DryRun {
+		var origPod corev1.Pod
+		if err := json.Unmarshal(origPodJson, &origPod); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		log.Info("dry run injection decision", "name", req.Name, "ns", req.Namespace, "inject", shouldInject, "reason", reason)
+		return w.dryRun(origPod, origPodJson, shouldInject, reason)
+	}
+	if !shouldInject {
 		return admission.Allowed(fmt.Sprintf("%s %s does not require injection", pod.Kind, pod.Name))
 	}
 
-	w.Log.Info("received pod", "name", req.Name, "ns", req.Namespace)
+	log.Info("received pod", "name", req.Name, "ns", req.Namespace)
+
+	// Record the correlation ID on the pod so that the endpoints controller can tag the logs and
+	// events of the pod's registration with it.
+	pod.Annotations[constants.AnnotationInjectionCorrelationID] = correlationID
+
+	// Deny pods that would register more services than the quota of their namespace allows.
+	if err := w.checkServiceQuota(ctx, req.Namespace, pod); err != nil {
+		log.Error(err, "error checking service quota", "request name", req.Name)
+		return admission.Errored(http.StatusForbidden, err)
+	}
+
+	// Pods that use the proxy of their node aren't injected with an init container or sidecar.
+	if nodeProxy, err := common.UsesNodeProxy(pod); err != nil {
+		log.Error(err, "error checking if pod uses the node proxy", "request name", req.Name)
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("%s annotation is invalid: %s", constants.AnnotationNodeProxy, err))
+	} else if nodeProxy {
+		if err := w.nodeProxyPod(&pod, req.Namespace); err != nil {
+			log.Error(err, "error configuring pod for the node proxy", "request name", req.Name)
+			return admission.Errored(http.StatusBadRequest, fmt.Errorf("error configuring pod for the node proxy: %s", err))
+		}
+		return w.patchPod(pod, origPodJson, req, correlationID, log)
+	}
+
+	// Add the upstreams shared by the pods of the namespace. This MUST be done before the upstreams
+	// are validated and added as environment variables.
+	if err := w.mergeNamespaceUpstreams(ctx, &pod, req.Namespace); err != nil {
+		log.Error(err, "error adding namespace upstreams", "request name", req.Name)
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error adding namespace upstreams: %s", err))
+	}
+
+	// Reject pods with upstreams that can't be parsed, since the endpoints controller wouldn't be able to
+	// register their proxies.
+	if _, err := common.ParseUpstreams(pod, w.EnableNamespaces, w.ConsulPartition != ""); err != nil {
+		log.Error(err, "error parsing upstreams", "request name", req.Name)
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("%s annotation is invalid: %s", constants.AnnotationUpstreams, err))
+	}
+
+	// This MUST be done before the traffic redirection config is created for the init container or
+	// CNI plugin, since the debug container UID is excluded from it.
+	if err := w.defaultDebugContainerUID(&pod); err != nil {
+		log.Error(err, "error configuring debug container UID", "request name", req.Name)
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("error configuring debug container UID: %s", err))
+	}
 
 	// Add our volume that will be shared by the init container and
 	// the sidecar for passing data in the pod.
 	pod.Spec.Volumes = append(pod.Spec.Volumes, w.containerVolume())
+	if w.loginWithAuthMethod(pod) && w.ConsulLoginAudience != "" {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, w.loginTokenVolume())
+	}
 
 	// Optionally mount data volume to other containers
 	w.injectVolumeMount(pod)
@@ -284,12 +439,18 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 	}
 
 	// A user can enable/disable tproxy for an entire namespace via a label.
-	ns, err := w.Clientset.CoreV1().Namespaces().Get(ctx, req.Namespace, metav1.GetOptions{})
+	ns, err := w.namespace(ctx, req.Namespace)
 	if err != nil {
-		w.Log.Error(err, "error fetching namespace metadata for container", "request name", req.Name)
+		log.Error(err, "error fetching namespace metadata for container", "request name", req.Name)
 		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error getting namespace metadata for container: %s", err))
 	}
 
+	// A user can set the default mesh gateway mode for an entire namespace via an annotation.
+	if err := defaultMeshGatewayMode(*ns, &pod); err != nil {
+		log.Error(err, "error configuring mesh gateway mode", "request name", req.Name)
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("error configuring mesh gateway mode: %s", err))
+	}
+
 	// Get service names from the annotation. If theres 0-1 service names, it's a single port pod, otherwise it's multi
 	// port.
 	annotatedSvcNames := w.annotatedServiceNames(pod)
@@ -300,7 +461,7 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 		// Add the init container that registers the service and sets up the Envoy configuration.
 		initContainer, err := w.containerInit(*ns, pod, multiPortInfo{})
 		if err != nil {
-			w.Log.Error(err, "error configuring injection init container", "request name", req.Name)
+			log.Error(err, "error configuring injection init container", "request name", req.Name)
 			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error configuring injection init container: %s", err))
 		}
 		pod.Spec.InitContainers = append(pod.Spec.InitContainers, initContainer)
@@ -308,11 +469,14 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 		// Add the Envoy sidecar.
 		envoySidecar, err := w.consulDataplaneSidecar(*ns, pod, multiPortInfo{})
 		if err != nil {
-			w.Log.Error(err, "error configuring injection sidecar container", "request name", req.Name)
+			log.Error(err, "error configuring injection sidecar container", "request name", req.Name)
+			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error configuring injection sidecar container: %s", err))
+		}
+		pod.Spec.Containers, err = w.addSidecar(pod, envoySidecar)
+		if err != nil {
+			log.Error(err, "error configuring injection sidecar container", "request name", req.Name)
 			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error configuring injection sidecar container: %s", err))
 		}
-		// TODO: invert to start the Envoy sidecar before the application container
-		pod.Spec.Containers = append(pod.Spec.Containers, envoySidecar)
 	} else {
 		// For multi port pods, check for unsupported cases, mount all relevant service account tokens, and mount an init
 		// container and envoy sidecar per port. Tproxy, metrics, and metrics merging are not supported for multi port pods.
@@ -321,36 +485,36 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 		// service account per service. So, this will look for service accounts whose name matches the service and mount
 		// those tokens if not already specified via the pod's serviceAccountName.
 
-		w.Log.Info("processing multiport pod")
+		log.Info("processing multiport pod")
 		err := w.checkUnsupportedMultiPortCases(*ns, pod)
 		if err != nil {
-			w.Log.Error(err, "checking unsupported cases for multi port pods")
+			log.Error(err, "checking unsupported cases for multi port pods")
 			return admission.Errored(http.StatusInternalServerError, err)
 		}
 		for i, svc := range annotatedSvcNames {
-			w.Log.Info(fmt.Sprintf("service: %s", svc))
-			if w.AuthMethod != "" {
+			log.Info(fmt.Sprintf("service: %s", svc))
+			if w.loginWithAuthMethod(pod) {
 				if svc != "" && pod.Spec.ServiceAccountName != svc {
 					secretName := ""
 					sa, err := w.Clientset.CoreV1().ServiceAccounts(req.Namespace).Get(ctx, svc, metav1.GetOptions{})
 					if err != nil {
-						w.Log.Error(err, "couldn't get service accounts")
+						log.Error(err, "couldn't get service accounts")
 						return admission.Errored(http.StatusInternalServerError, err)
 					}
 					if len(sa.Secrets) == 0 {
 						// Check to see if there is a secret with the same name as the ServiceAccount for Kube-1.24+.
-						w.Log.Info(fmt.Sprintf("service account %s has zero secrets exp at least 1", svc))
+						log.Info(fmt.Sprintf("service account %s has zero secrets exp at least 1", svc))
 						sec, err := w.Clientset.CoreV1().Secrets(req.Namespace).Get(ctx, svc, metav1.GetOptions{})
 						if err != nil {
-							w.Log.Error(err, "couldn't get Secret associated with Service Account")
+							log.Error(err, "couldn't get Secret associated with Service Account")
 							return admission.Errored(http.StatusInternalServerError, err)
 						}
 						secretName = sec.Name
-						w.Log.Info(fmt.Sprintf("fetched secret: %s", secretName))
+						log.Info(fmt.Sprintf("fetched secret: %s", secretName))
 					} else {
 						secretName = sa.Secrets[0].Name
 					}
-					w.Log.Info("found service account, mounting service account secret to Pod", "serviceAccountName", secretName)
+					log.Info("found service account, mounting service account secret to Pod", "serviceAccountName", secretName)
 					pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
 						Name: fmt.Sprintf("%s-service-account", svc),
 						VolumeSource: corev1.VolumeSource{
@@ -371,7 +535,7 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 			// Add the init container that registers the service and sets up the Envoy configuration.
 			initContainer, err := w.containerInit(*ns, pod, mpi)
 			if err != nil {
-				w.Log.Error(err, "error configuring injection init container", "request name", req.Name)
+				log.Error(err, "error configuring injection init container", "request name", req.Name)
 				return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error configuring injection init container: %s", err))
 			}
 			pod.Spec.InitContainers = append(pod.Spec.InitContainers, initContainer)
@@ -379,12 +543,14 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 			// Add the Envoy sidecar.
 			envoySidecar, err := w.consulDataplaneSidecar(*ns, pod, mpi)
 			if err != nil {
-				w.Log.Error(err, "error configuring injection sidecar container", "request name", req.Name)
+				log.Error(err, "error configuring injection sidecar container", "request name", req.Name)
+				return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error configuring injection sidecar container: %s", err))
+			}
+			pod.Spec.Containers, err = w.addSidecar(pod, envoySidecar)
+			if err != nil {
+				log.Error(err, "error configuring injection sidecar container", "request name", req.Name)
 				return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error configuring injection sidecar container: %s", err))
 			}
-			// TODO: invert to start the Envoy sidecar container before the
-			// application container
-			pod.Spec.Containers = append(pod.Spec.Containers, envoySidecar)
 		}
 	}
 
@@ -392,9 +558,14 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 	// and does not need to be checked for being a nil value.
 	pod.Annotations[constants.KeyInjectStatus] = constants.Injected
 
+	if err := w.appArmorAnnotations(&pod); err != nil {
+		log.Error(err, "error configuring AppArmor profiles", "request name", req.Name)
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("error configuring AppArmor profiles: %s", err))
+	}
+
 	tproxyEnabled, err := common.TransparentProxyEnabled(*ns, pod, w.EnableTransparentProxy)
 	if err != nil {
-		w.Log.Error(err, "error determining if transparent proxy is enabled", "request name", req.Name)
+		log.Error(err, "error determining if transparent proxy is enabled", "request name", req.Name)
 		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error determining if transparent proxy is enabled: %s", err))
 	}
 
@@ -407,19 +578,19 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 	// If DNS redirection is enabled, we want to configure dns on the pod.
 	dnsEnabled, err := consulDNSEnabled(*ns, pod, w.EnableConsulDNS, w.EnableTransparentProxy)
 	if err != nil {
-		w.Log.Error(err, "error determining if dns redirection is enabled", "request name", req.Name)
+		log.Error(err, "error determining if dns redirection is enabled", "request name", req.Name)
 		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error determining if dns redirection is enabled: %s", err))
 	}
 	if dnsEnabled {
 		if err = w.configureDNS(&pod, req.Namespace); err != nil {
-			w.Log.Error(err, "error configuring DNS on the pod", "request name", req.Name)
+			log.Error(err, "error configuring DNS on the pod", "request name", req.Name)
 			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error configuring DNS on the pod: %s", err))
 		}
 	}
 
 	// Add annotations for metrics.
 	if err = w.prometheusAnnotations(&pod); err != nil {
-		w.Log.Error(err, "error configuring prometheus annotations", "request name", req.Name)
+		log.Error(err, "error configuring prometheus annotations", "request name", req.Name)
 		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error configuring prometheus annotations: %s", err))
 	}
 
@@ -440,19 +611,48 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 	// Overwrite readiness/liveness probes if needed.
 	err = w.overwriteProbes(*ns, &pod)
 	if err != nil {
-		w.Log.Error(err, "error overwriting readiness or liveness probes", "request name", req.Name)
+		log.Error(err, "error overwriting readiness or liveness probes", "request name", req.Name)
 		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error overwriting readiness or liveness probes: %s", err))
 	}
 
+	if w.EnableMeshReadyGate {
+		addMeshReadyGate(&pod)
+	}
+
 	// When CNI and tproxy are enabled, we add an annotation to the pod that contains the iptables config so that the CNI
 	// plugin can apply redirect traffic rules on the pod.
 	if w.EnableCNI && tproxyEnabled {
+		if w.CNIVersionChecker != nil {
+			if err = w.checkCNIVersionSkew(ctx, pod); err != nil {
+				log.Error(err, "denying injection due to consul-cni version skew", "request name", req.Name)
+				return admission.Denied(err.Error())
+			}
+		}
 		if err = w.addRedirectTrafficConfigAnnotation(&pod, *ns); err != nil {
-			w.Log.Error(err, "error configuring annotation for CNI traffic redirection", "request name", req.Name)
+			log.Error(err, "error configuring annotation for CNI traffic redirection", "request name", req.Name)
 			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error configuring annotation for CNI traffic redirection: %s", err))
 		}
 	}
 
+	resp := w.patchPod(pod, origPodJson, req, correlationID, log)
+	if resp.Allowed {
+		resp.AuditAnnotations = auditAnnotations(pod)
+	}
+	return resp
+}
+
+// logger returns the logger of the admission request that ctx belongs to, which logs its correlation
+// ID, or the webhook's logger if there is none.
+func (w *MeshWebhook) logger(ctx context.Context) logr.Logger {
+	if log, err := logr.FromContext(ctx); err == nil {
+		return log
+	}
+	return w.Log
+}
+
+// patchPod returns a response that patches the pod received by the meshWebhook into the mutated pod, after
+// the Consul namespace of the pod has been created if needed.
+func (w *MeshWebhook) patchPod(pod corev1.Pod, origPodJson []byte, req admission.Request, correlationID string, log logr.Logger) admission.Response {
 	// Marshall the pod into JSON after it has the desired envs, annotations, labels,
 	// sidecars and initContainers appended to it.
 	updatedPodJson, err := json.Marshal(pod)
@@ -473,18 +673,19 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 	if w.EnableNamespaces {
 		serverState, err := w.ConsulServerConnMgr.State()
 		if err != nil {
-			w.Log.Error(err, "error checking or creating namespace",
+			log.Error(err, "error checking or creating namespace",
 				"ns", w.consulNamespace(req.Namespace), "request name", req.Name)
 			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error checking or creating namespace: %s", err))
 		}
 		apiClient, err := consul.NewClientFromConnMgrState(w.ConsulConfig, serverState)
 		if err != nil {
-			w.Log.Error(err, "error checking or creating namespace",
+			log.Error(err, "error checking or creating namespace",
 				"ns", w.consulNamespace(req.Namespace), "request name", req.Name)
 			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error checking or creating namespace: %s", err))
 		}
+		correlation.SetConsulHeader(apiClient, correlationID)
 		if _, err := namespaces.EnsureExists(apiClient, w.consulNamespace(req.Namespace), w.CrossNamespaceACLPolicy); err != nil {
-			w.Log.Error(err, "error checking or creating namespace",
+			log.Error(err, "error checking or creating namespace",
 				"ns", w.consulNamespace(req.Namespace), "request name", req.Name)
 			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error checking or creating namespace: %s", err))
 		}
@@ -495,7 +696,7 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 	return admission.Patched(fmt.Sprintf("valid %s request", pod.Kind), patches...)
 }
 
-// overwriteProbes overwrites readiness/liveness probes of this pod when
+// overwriteProbes overwrites the HTTP and gRPC readiness/liveness/startup probes of this pod when
 // both transparent proxy is enabled and overwrite probes is true for the pod.
 func (w *MeshWebhook) overwriteProbes(ns corev1.Namespace, pod *corev1.Pod) error {
 	tproxyEnabled, err := common.TransparentProxyEnabled(ns, *pod, w.EnableTransparentProxy)
@@ -514,20 +715,51 @@ func (w *MeshWebhook) overwriteProbes(ns corev1.Namespace, pod *corev1.Pod) erro
 			if container.Name == sidecarContainer {
 				continue
 			}
-			if container.LivenessProbe != nil && container.LivenessProbe.HTTPGet != nil {
-				container.LivenessProbe.HTTPGet.Port = intstr.FromInt(exposedPathsLivenessPortsRangeStart + i)
+			if exposableProbe(container.LivenessProbe) {
+				overwriteProbePort(container.LivenessProbe, exposedPathsLivenessPortsRangeStart+i)
 			}
-			if container.ReadinessProbe != nil && container.ReadinessProbe.HTTPGet != nil {
-				container.ReadinessProbe.HTTPGet.Port = intstr.FromInt(exposedPathsReadinessPortsRangeStart + i)
+			if exposableProbe(container.ReadinessProbe) {
+				overwriteProbePort(container.ReadinessProbe, exposedPathsReadinessPortsRangeStart+i)
 			}
-			if container.StartupProbe != nil && container.StartupProbe.HTTPGet != nil {
-				container.StartupProbe.HTTPGet.Port = intstr.FromInt(exposedPathsStartupPortsRangeStart + i)
+			if exposableProbe(container.StartupProbe) {
+				overwriteProbePort(container.StartupProbe, exposedPathsStartupPortsRangeStart+i)
 			}
 		}
 	}
 	return nil
 }
 
+// exposableProbe returns true if the probe can be served by an exposed path of the sidecar proxy, which
+// proxies HTTP and gRPC probes. TCP probes are accepted by the proxy's inbound listener so they don't
+// need to be overwritten.
+func exposableProbe(probe *corev1.Probe) bool {
+	return probe != nil && (probe.HTTPGet != nil || probe.GRPC != nil)
+}
+
+// overwriteProbePort points an HTTP or gRPC probe to port, the listener port of its exposed path.
+func overwriteProbePort(probe *corev1.Probe, port int) {
+	switch {
+	case probe.HTTPGet != nil:
+		probe.HTTPGet.Port = intstr.FromInt(port)
+	case probe.GRPC != nil:
+		probe.GRPC.Port = int32(port)
+	}
+}
+
+// addSidecar returns the pod's containers with the sidecar added. The sidecar is added before the application
+// containers if they wait for it to start, since the kubelet starts containers in order and waits for the
+// sidecar's postStart hook before starting the containers after it.
+func (w *MeshWebhook) addSidecar(pod corev1.Pod, sidecar corev1.Container) ([]corev1.Container, error) {
+	startupGracePeriodSeconds, err := w.LifecycleConfig.StartupGracePeriodSeconds(pod)
+	if err != nil {
+		return nil, err
+	}
+	if startupGracePeriodSeconds > 0 {
+		return append([]corev1.Container{sidecar}, pod.Spec.Containers...), nil
+	}
+	return append(pod.Spec.Containers, sidecar), nil
+}
+
 func (w *MeshWebhook) injectVolumeMount(pod corev1.Pod) {
 	containersToInject := splitCommaSeparatedItemsFromAnnotation(constants.AnnotationInjectMountVolumes, pod)
 
@@ -541,36 +773,110 @@ func (w *MeshWebhook) injectVolumeMount(pod corev1.Pod) {
 	}
 }
 
-func (w *MeshWebhook) shouldInject(pod corev1.Pod, namespace string) (bool, error) {
+// addMeshReadyGate adds the mesh-ready readiness gate to the pod unless it already has it.
+func addMeshReadyGate(pod *corev1.Pod) {
+	for _, gate := range pod.Spec.ReadinessGates {
+		if gate.ConditionType == constants.PodConditionMeshReady {
+			return
+		}
+	}
+	pod.Spec.ReadinessGates = append(pod.Spec.ReadinessGates, corev1.PodReadinessGate{
+		ConditionType: constants.PodConditionMeshReady,
+	})
+}
+
+// shouldInject returns whether the pod should be injected and the reason for the decision.
+// Once the pod passes the namespace allow and deny lists, the pod's annotation takes precedence
+// over its namespace's label, which takes precedence over the default of the webhook.
+func (w *MeshWebhook) shouldInject(ctx context.Context, pod corev1.Pod, namespace string) (bool, string, error) {
 	// Don't inject in the Kubernetes system namespaces
 	if kubeSystemNamespaces.Contains(namespace) {
-		return false, nil
+		return false, fmt.Sprintf("namespace %s is a Kubernetes system namespace", namespace), nil
 	}
 
 	// Namespace logic
 	// If in deny list, don't inject
 	if w.DenyK8sNamespacesSet.Contains(namespace) {
-		return false, nil
+		return false, fmt.Sprintf("namespace %s is denied", namespace), nil
 	}
 
 	// If not in allow list or allow list is not *, don't inject
 	if !w.AllowK8sNamespacesSet.Contains("*") && !w.AllowK8sNamespacesSet.Contains(namespace) {
-		return false, nil
+		return false, fmt.Sprintf("namespace %s is not allowed", namespace), nil
 	}
 
 	// If we already injected then don't inject again
 	if pod.Annotations[constants.KeyInjectStatus] != "" {
-		return false, nil
+		return false, "pod has already been injected", nil
 	}
 
 	// If the explicit true/false is on, then take that value. Note that
 	// this has to be the last check since it sets a default value after
 	// all other checks.
 	if raw, ok := pod.Annotations[constants.AnnotationInject]; ok {
-		return strconv.ParseBool(raw)
+		inject, err := strconv.ParseBool(raw)
+		return inject, fmt.Sprintf("pod annotation %s is %q", constants.AnnotationInject, raw), err
+	}
+
+	ns, err := w.namespace(ctx, namespace)
+	if err != nil {
+		return false, "", fmt.Errorf("error getting namespace %s: %s", namespace, err)
+	}
+	if raw, ok := ns.Labels[constants.LabelConnectInject]; ok {
+		reason := fmt.Sprintf("namespace label %s is %q", constants.LabelConnectInject, raw)
+		switch raw {
+		case constants.LabelValueEnabled:
+			return true, reason, nil
+		case constants.LabelValueDisabled:
+			return false, reason, nil
+		default:
+			// The label applies to every pod of the namespace, so an invalid value falls back to the
+			// default rather than failing the admission of every pod.
+			w.logger(ctx).Info("ignoring invalid namespace label", "label", constants.LabelConnectInject, "value", raw,
+				"ns", namespace, "expected", []string{constants.LabelValueEnabled, constants.LabelValueDisabled})
+		}
+	}
+
+	if w.RequireAnnotation {
+		return false, "injection is disabled by default", nil
 	}
+	return true, "injection is enabled by default", nil
+}
 
-	return !w.RequireAnnotation, nil
+// namespace returns the Kubernetes namespace from the namespace cache, or from the API server
+// if the webhook doesn't have one.
+func (w *MeshWebhook) namespace(ctx context.Context, name string) (*corev1.Namespace, error) {
+	if w.Cache == nil {
+		return w.Clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	}
+	var ns corev1.Namespace
+	if err := w.Cache.Get(ctx, types.NamespacedName{Name: name}, &ns); err != nil {
+		return nil, err
+	}
+	return &ns, nil
+}
+
+// dryRun returns a response that only annotates the pod with the injection decision and
+// its reason, without injecting it.
+func (w *MeshWebhook) dryRun(pod corev1.Pod, origPodJson []byte, inject bool, reason string) admission.Response {
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	pod.Annotations[constants.KeyInjectDecision] = "skip"
+	if inject {
+		pod.Annotations[constants.KeyInjectDecision] = "inject"
+	}
+	pod.Annotations[constants.KeyInjectDecisionReason] = reason
+
+	updatedPodJson, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	patches, err := jsonpatch.CreatePatch(origPodJson, updatedPodJson)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	return admission.Patched(fmt.Sprintf("dry run: %s", reason), patches...)
 }
 
 func (w *MeshWebhook) defaultAnnotations(pod *corev1.Pod, podJson string) error {
@@ -596,6 +902,44 @@ func (w *MeshWebhook) defaultAnnotations(pod *corev1.Pod, podJson string) error
 	return nil
 }
 
+// defaultMeshGatewayMode sets the mesh gateway mode annotation of the pod to the mode of its namespace's
+// annotation, unless the pod sets its own, and validates it.
+func defaultMeshGatewayMode(ns corev1.Namespace, pod *corev1.Pod) error {
+	if _, ok := pod.Annotations[constants.AnnotationMeshGatewayMode]; !ok {
+		if mode, ok := ns.Annotations[constants.AnnotationMeshGatewayMode]; ok {
+			pod.Annotations[constants.AnnotationMeshGatewayMode] = mode
+		}
+	}
+	_, err := common.MeshGatewayMode(*pod)
+	return err
+}
+
+// imageConsulDataplane returns the consul-dataplane image that pods in the namespace are injected with.
+func (w *MeshWebhook) imageConsulDataplane(ns corev1.Namespace) (string, error) {
+	return w.namespaceImage(ns, constants.AnnotationConsulDataplaneImage, w.ImageConsulDataplane)
+}
+
+// imageConsulK8S returns the consul-k8s-control-plane image that pods in the namespace are injected with.
+func (w *MeshWebhook) imageConsulK8S(ns corev1.Namespace) (string, error) {
+	return w.namespaceImage(ns, constants.AnnotationConsulK8sImage, w.ImageConsulK8S)
+}
+
+// namespaceImage returns the image in the annotation of the namespace if namespace image overrides
+// are enabled, or image otherwise. With FIPS enabled, an image in the annotation that isn't a FIPS
+// variant is an error, so that namespaces can't inject images that aren't FIPS compliant.
+func (w *MeshWebhook) namespaceImage(ns corev1.Namespace, annotation, image string) (string, error) {
+	override := ns.Annotations[annotation]
+	if !w.NamespaceImageOverrides || override == "" {
+		return image, nil
+	}
+	// FIPS variants of the images are published with "fips" in their name or tag,
+	// e.g. hashicorp/consul-dataplane-fips.
+	if w.EnableFIPS && !strings.Contains(override, "fips") {
+		return "", fmt.Errorf("%s annotation on namespace %s is invalid: %q must be a FIPS variant if FIPS is enabled", annotation, ns.Name, override)
+	}
+	return override, nil
+}
+
 // prometheusAnnotations sets the Prometheus scraping configuration
 // annotations on the Pod.
 func (w *MeshWebhook) prometheusAnnotations(pod *corev1.Pod) error {
@@ -624,6 +968,21 @@ func (w *MeshWebhook) consulNamespace(ns string) string {
 	return namespaces.ConsulNamespace(ns, w.EnableNamespaces, w.ConsulDestinationNamespace, w.EnableK8SNSMirroring, w.K8SNSMirroringPrefix)
 }
 
+// serviceAccountVolumeMount returns the volume mount and the path of the token that
+// the pod logs in to Consul with. If ConsulLoginAudience is set, pods log in with the
+// projected token of the login token volume, unless they are multi-port pods, which
+// log in with the tokens of their services' service accounts.
+func (w *MeshWebhook) serviceAccountVolumeMount(pod corev1.Pod, multiPortSvcName string) (corev1.VolumeMount, string, error) {
+	if w.ConsulLoginAudience != "" && multiPortSvcName == "" {
+		return corev1.VolumeMount{
+			Name:      loginTokenVolumeName,
+			ReadOnly:  true,
+			MountPath: loginTokenMountPath,
+		}, filepath.Join(loginTokenMountPath, "token"), nil
+	}
+	return findServiceAccountVolumeMount(pod, multiPortSvcName)
+}
+
 func findServiceAccountVolumeMount(pod corev1.Pod, multiPortSvcName string) (corev1.VolumeMount, string, error) {
 	// In the case of a multiPort pod, there may be another service account
 	// token mounted as a different volume. Its name must be <svc>-serviceaccount.
@@ -694,6 +1053,73 @@ func (w *MeshWebhook) checkUnsupportedMultiPortCases(ns corev1.Namespace, pod co
 	return nil
 }
 
+// checkServiceQuota returns an error if the pod would register a service in Consul that the
+// namespace doesn't have room for in its quota.
+func (w *MeshWebhook) checkServiceQuota(ctx context.Context, namespace string, pod corev1.Pod) error {
+	if w.QuotaPolicy == nil {
+		return nil
+	}
+	ns, err := w.namespace(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	limits, err := w.QuotaPolicy.Limits(ns)
+	if err != nil {
+		return err
+	}
+	if limits.MaxServices == 0 {
+		return nil
+	}
+
+	services, pods, err := w.servicesAndInjectedPods(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	registered := mapset.NewSet()
+	for _, p := range pods.Items {
+		if p.DeletionTimestamp != nil {
+			continue
+		}
+		for _, name := range quota.ServiceNames(p, constants.AnnotationService, services.Items) {
+			registered.Add(name)
+		}
+	}
+	previous := registered.Cardinality()
+	for _, name := range quota.ServiceNames(pod, constants.AnnotationService, services.Items) {
+		registered.Add(name)
+	}
+	return quota.Check(namespace, "services", limits.MaxServices, previous, registered.Cardinality())
+}
+
+// servicesAndInjectedPods returns the services and the injected pods in the namespace from the
+// cache, or from the API server if the webhook doesn't have one.
+func (w *MeshWebhook) servicesAndInjectedPods(ctx context.Context, namespace string) (*corev1.ServiceList, *corev1.PodList, error) {
+	services := &corev1.ServiceList{}
+	pods := &corev1.PodList{}
+	var err error
+	if w.Cache == nil {
+		services, err = w.Clientset.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, nil, err
+		}
+		pods, err = w.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("%s=%s", constants.KeyInjectStatus, constants.Injected),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return services, pods, nil
+	}
+	if err = w.Cache.List(ctx, services, client.InNamespace(namespace)); err != nil {
+		return nil, nil, err
+	}
+	if err = w.Cache.List(ctx, pods, client.InNamespace(namespace),
+		client.MatchingLabels{constants.KeyInjectStatus: constants.Injected}); err != nil {
+		return nil, nil, err
+	}
+	return services, pods, nil
+}
+
 func (w *MeshWebhook) InjectDecoder(d *admission.Decoder) error {
 	w.decoder = d
 	return nil
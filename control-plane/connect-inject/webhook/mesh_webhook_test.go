@@ -12,9 +12,11 @@ import (
 	mapset "github.com/deckarep/golang-set"
 	logrtest "github.com/go-logr/logr/testr"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/lifecycle"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/metrics"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	"github.com/hashicorp/consul-k8s/control-plane/quota"
 	"github.com/hashicorp/consul-k8s/control-plane/version"
 	"github.com/stretchr/testify/require"
 	"gomodules.xyz/jsonpatch/v2"
@@ -26,6 +28,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	ctrlfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
@@ -139,6 +142,53 @@ func TestHandlerHandle(t *testing.T) {
 			},
 		},
 
+		{
+			"empty pod with mesh ready gate enabled",
+			MeshWebhook{
+				Log:                   logrtest.New(t),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				EnableMeshReadyGate:   true,
+				decoder:               decoder,
+				Clientset:             defaultTestClientWithNamespace(),
+			},
+			admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: namespaces.DefaultNamespace,
+					Object: encodeRaw(t, &corev1.Pod{
+						Spec: basicSpec,
+					}),
+				},
+			},
+			"",
+			[]jsonpatch.Operation{
+				{
+					Operation: "add",
+					Path:      "/metadata/labels",
+				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/volumes",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/initContainers",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/containers/1",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/readinessGates",
+				},
+			},
+		},
+
 		{
 			"pod with upstreams specified",
 			MeshWebhook{
@@ -175,6 +225,10 @@ func TestHandlerHandle(t *testing.T) {
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationOriginalPod),
 				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationInjectionCorrelationID),
+				},
 				{
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationConsulK8sVersion),
@@ -268,6 +322,10 @@ func TestHandlerHandle(t *testing.T) {
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationOriginalPod),
 				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationInjectionCorrelationID),
+				},
 				{
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationConsulK8sVersion),
@@ -323,6 +381,10 @@ func TestHandlerHandle(t *testing.T) {
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationOriginalPod),
 				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationInjectionCorrelationID),
+				},
 				{
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationConsulK8sVersion),
@@ -397,6 +459,10 @@ func TestHandlerHandle(t *testing.T) {
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationOriginalPod),
 				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationInjectionCorrelationID),
+				},
 				{
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationConsulK8sVersion),
@@ -457,6 +523,10 @@ func TestHandlerHandle(t *testing.T) {
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationOriginalPod),
 				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationInjectionCorrelationID),
+				},
 				{
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationConsulK8sVersion),
@@ -542,6 +612,10 @@ func TestHandlerHandle(t *testing.T) {
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationOriginalPod),
 				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationInjectionCorrelationID),
+				},
 				{
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationConsulK8sVersion),
@@ -679,6 +753,10 @@ func TestHandlerHandle(t *testing.T) {
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationOriginalPod),
 				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationInjectionCorrelationID),
+				},
 				{
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationConsulK8sVersion),
@@ -742,6 +820,10 @@ func TestHandlerHandle(t *testing.T) {
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationOriginalPod),
 				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationInjectionCorrelationID),
+				},
 				{
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationConsulK8sVersion),
@@ -801,6 +883,10 @@ func TestHandlerHandle(t *testing.T) {
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationOriginalPod),
 				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationInjectionCorrelationID),
+				},
 				{
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationConsulK8sVersion),
@@ -871,6 +957,10 @@ func TestHandlerHandle(t *testing.T) {
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationOriginalPod),
 				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationInjectionCorrelationID),
+				},
 				{
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationConsulK8sVersion),
@@ -943,6 +1033,10 @@ func TestHandlerHandle(t *testing.T) {
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationOriginalPod),
 				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationInjectionCorrelationID),
+				},
 				{
 					Operation: "add",
 					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.AnnotationConsulK8sVersion),
@@ -950,6 +1044,31 @@ func TestHandlerHandle(t *testing.T) {
 				// Note: no DNS policy/config additions.
 			},
 		},
+		{
+			"pod with invalid upstreams is denied",
+			MeshWebhook{
+				Log:                   logrtest.New(t),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+				Clientset:             defaultTestClientWithNamespace(),
+			},
+			admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: namespaces.DefaultNamespace,
+					Object: encodeRaw(t, &corev1.Pod{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								constants.AnnotationUpstreams: "web:1234;mesh-gateway=sideways",
+							},
+						},
+						Spec: basicSpec,
+					}),
+				},
+			},
+			"consul.hashicorp.com/connect-service-upstreams annotation is invalid: upstream web:1234 is invalid: mesh-gateway option value of sideways must be one of local, remote or none",
+			nil,
+		},
 	}
 
 	for _, tt := range cases {
@@ -976,6 +1095,170 @@ func TestHandlerHandle(t *testing.T) {
 	}
 }
 
+// Test that the UID of the admission request is recorded on the pod as the correlation ID of its
+// injection so that the endpoints controller can tag the pod's registration with it.
+func TestHandlerHandle_InjectionCorrelationID(t *testing.T) {
+	s := runtime.NewScheme()
+	s.AddKnownTypes(schema.GroupVersion{Group: "", Version: "v1"}, &corev1.Pod{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(t, err)
+
+	w := MeshWebhook{
+		Log:                   logrtest.New(t),
+		AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:  mapset.NewSet(),
+		decoder:               decoder,
+		Clientset:             defaultTestClientWithNamespace(),
+		ConsulConfig:          &consul.Config{HTTPPort: 8500},
+	}
+	resp := w.Handle(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			UID:       "4c4a8f7d-0d3b-4a86-9b0c-4e1f0f1a2b3c",
+			Namespace: namespaces.DefaultNamespace,
+			Object: encodeRaw(t, &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{constants.AnnotationService: "web"}},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "web"}},
+				},
+			}),
+		},
+	})
+	require.True(t, resp.Allowed, resp.Result)
+
+	var value interface{}
+	for _, patch := range resp.Patches {
+		if patch.Path == "/metadata/annotations/"+escapeJSONPointer(constants.AnnotationInjectionCorrelationID) {
+			value = patch.Value
+		}
+	}
+	require.Equal(t, "4c4a8f7d-0d3b-4a86-9b0c-4e1f0f1a2b3c", value)
+}
+
+func TestHandlerCheckServiceQuota(t *testing.T) {
+	injectedPod := func(name, service string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "team-a",
+			Labels:      map[string]string{constants.KeyInjectStatus: constants.Injected, "app": name},
+			Annotations: map[string]string{constants.AnnotationService: service},
+		}}
+	}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "team-a",
+		Annotations: map[string]string{quota.AnnotationMaxServices: "2"},
+	}}
+
+	cases := map[string]struct {
+		policy *quota.Policy
+		pod    corev1.Pod
+		expErr string
+	}{
+		"quotas not enforced": {
+			pod: corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{constants.AnnotationService: "db"}}},
+		},
+		"pod of a registered service": {
+			policy: &quota.Policy{},
+			pod:    corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{constants.AnnotationService: "web"}}},
+		},
+		"pod of a new service": {
+			policy: &quota.Policy{},
+			pod:    corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{constants.AnnotationService: "db"}}},
+			expErr: "namespace team-a would have 3 services, which exceeds its quota of 2",
+		},
+		"pod of a new service selected by a Kubernetes service": {
+			policy: &quota.Policy{},
+			pod:    corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "db"}}},
+			expErr: "namespace team-a would have 3 services, which exceeds its quota of 2",
+		},
+	}
+	objects := []runtime.Object{
+		namespace,
+		injectedPod("web-1", "web"),
+		injectedPod("web-2", "web"),
+		injectedPod("api-1", "api"),
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "team-a"},
+			Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "db"}},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				Clientset:   fake.NewSimpleClientset(objects...),
+				QuotaPolicy: c.policy,
+			}
+			err := w.checkServiceQuota(context.Background(), "team-a", c.pod)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			// The same objects read from the cache give the same result, without
+			// any requests to the API server.
+			w.Clientset = fake.NewSimpleClientset()
+			w.Cache = ctrlfake.NewClientBuilder().WithRuntimeObjects(objects...).Build()
+			err = w.checkServiceQuota(context.Background(), "team-a", c.pod)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestAddMeshReadyGate(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			ReadinessGates: []corev1.PodReadinessGate{{ConditionType: "example.com/other"}},
+		},
+	}
+	addMeshReadyGate(pod)
+	addMeshReadyGate(pod)
+	require.Equal(t, []corev1.PodReadinessGate{
+		{ConditionType: "example.com/other"},
+		{ConditionType: constants.PodConditionMeshReady},
+	}, pod.Spec.ReadinessGates)
+}
+
+func TestAddSidecar(t *testing.T) {
+	sidecar := corev1.Container{Name: sidecarContainer}
+	cases := map[string]struct {
+		webhook     MeshWebhook
+		annotations map[string]string
+		expNames    []string
+	}{
+		"sidecar is added after application containers by default": {
+			expNames: []string{"web", "worker", sidecarContainer},
+		},
+		"sidecar is added before application containers that wait for it": {
+			webhook:  MeshWebhook{LifecycleConfig: lifecycle.Config{DefaultStartupGracePeriodSeconds: 30}},
+			expNames: []string{sidecarContainer, "web", "worker"},
+		},
+		"annotation disables waiting for the sidecar": {
+			webhook:     MeshWebhook{LifecycleConfig: lifecycle.Config{DefaultStartupGracePeriodSeconds: 30}},
+			annotations: map[string]string{constants.AnnotationSidecarProxyLifecycleStartupGracePeriodSeconds: "0"},
+			expNames:    []string{"web", "worker", sidecarContainer},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: c.annotations},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}, {Name: "worker"}}},
+			}
+			containers, err := c.webhook.addSidecar(pod, sidecar)
+			require.NoError(t, err)
+			var names []string
+			for _, container := range containers {
+				names = append(names, container.Name)
+			}
+			require.Equal(t, c.expNames, names)
+		})
+	}
+}
+
 func TestHandlerDefaultAnnotations(t *testing.T) {
 	cases := []struct {
 		Name     string
@@ -1171,6 +1454,57 @@ func TestHandlerPrometheusAnnotations(t *testing.T) {
 	}
 }
 
+func TestHandlerDefaultMeshGatewayMode(t *testing.T) {
+	cases := []struct {
+		Name                string
+		NamespaceAnnotation string
+		PodAnnotation       string
+		Expected            string
+		Err                 string
+	}{
+		{
+			Name: "Does not set the annotation if neither the namespace nor the pod set it",
+		},
+		{
+			Name:                "Defaults to the namespace annotation",
+			NamespaceAnnotation: "local",
+			Expected:            "local",
+		},
+		{
+			Name:                "The pod annotation overrides the namespace annotation",
+			NamespaceAnnotation: "local",
+			PodAnnotation:       "remote",
+			Expected:            "remote",
+		},
+		{
+			Name:                "Invalid namespace annotation",
+			NamespaceAnnotation: "foo",
+			Err:                 "consul.hashicorp.com/mesh-gateway-mode annotation value of foo was invalid: must be one of local, remote or none",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Annotations: map[string]string{}}}
+			if tt.NamespaceAnnotation != "" {
+				ns.Annotations[constants.AnnotationMeshGatewayMode] = tt.NamespaceAnnotation
+			}
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}}}
+			if tt.PodAnnotation != "" {
+				pod.Annotations[constants.AnnotationMeshGatewayMode] = tt.PodAnnotation
+			}
+
+			err := defaultMeshGatewayMode(ns, pod)
+			if tt.Err != "" {
+				require.EqualError(t, err, tt.Err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.Expected, pod.Annotations[constants.AnnotationMeshGatewayMode])
+		})
+	}
+}
+
 // Test consulNamespace function.
 func TestConsulNamespace(t *testing.T) {
 	cases := []struct {
@@ -1564,9 +1898,10 @@ func TestShouldInject(t *testing.T) {
 				EnableNamespaces:      tt.EnableNamespaces,
 				AllowK8sNamespacesSet: tt.AllowK8sNamespacesSet,
 				DenyK8sNamespacesSet:  tt.DenyK8sNamespacesSet,
+				Clientset:             clientWithNamespace(tt.K8sNamespace),
 			}
 
-			injected, err := w.shouldInject(*tt.Pod, tt.K8sNamespace)
+			injected, _, err := w.shouldInject(context.Background(), *tt.Pod, tt.K8sNamespace)
 
 			require.Equal(nil, err)
 			require.Equal(tt.Expected, injected)
@@ -1574,6 +1909,247 @@ func TestShouldInject(t *testing.T) {
 	}
 }
 
+func TestShouldInject_Precedence(t *testing.T) {
+	cases := map[string]struct {
+		podAnnotations    map[string]string
+		namespaceLabels   map[string]string
+		requireAnnotation bool
+		expInject         bool
+		expReason         string
+		expErr            string
+	}{
+		"default enabled": {
+			expInject: true,
+			expReason: "injection is enabled by default",
+		},
+		"default disabled": {
+			requireAnnotation: true,
+			expInject:         false,
+			expReason:         "injection is disabled by default",
+		},
+		"namespace label enabled overrides default": {
+			namespaceLabels:   map[string]string{constants.LabelConnectInject: constants.LabelValueEnabled},
+			requireAnnotation: true,
+			expInject:         true,
+			expReason:         `namespace label consul.hashicorp.com/connect-inject is "enabled"`,
+		},
+		"namespace label disabled overrides default": {
+			namespaceLabels: map[string]string{constants.LabelConnectInject: constants.LabelValueDisabled},
+			expInject:       false,
+			expReason:       `namespace label consul.hashicorp.com/connect-inject is "disabled"`,
+		},
+		"pod annotation overrides namespace label": {
+			podAnnotations:    map[string]string{constants.AnnotationInject: "true"},
+			namespaceLabels:   map[string]string{constants.LabelConnectInject: constants.LabelValueDisabled},
+			requireAnnotation: true,
+			expInject:         true,
+			expReason:         `pod annotation consul.hashicorp.com/connect-inject is "true"`,
+		},
+		"pod annotation false overrides namespace label": {
+			podAnnotations:  map[string]string{constants.AnnotationInject: "false"},
+			namespaceLabels: map[string]string{constants.LabelConnectInject: constants.LabelValueEnabled},
+			expInject:       false,
+			expReason:       `pod annotation consul.hashicorp.com/connect-inject is "false"`,
+		},
+		"invalid namespace label falls back to the default": {
+			namespaceLabels:   map[string]string{constants.LabelConnectInject: "true"},
+			requireAnnotation: true,
+			expInject:         false,
+			expReason:         "injection is disabled by default",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: c.namespaceLabels}}
+			w := MeshWebhook{
+				RequireAnnotation:     c.requireAnnotation,
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				Clientset:             fake.NewSimpleClientset(),
+				Cache:                 ctrlfake.NewClientBuilder().WithObjects(&ns).Build(),
+				Log:                   logrtest.New(t),
+			}
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: c.podAnnotations}}
+
+			inject, reason, err := w.shouldInject(context.Background(), pod, "default")
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expInject, inject)
+			require.Equal(t, c.expReason, reason)
+		})
+	}
+}
+
+func TestHandlerHandle_DryRun(t *testing.T) {
+	s := runtime.NewScheme()
+	s.AddKnownTypes(schema.GroupVersion{Group: "", Version: "v1"}, &corev1.Pod{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(t, err)
+
+	cases := map[string]struct {
+		namespace  string
+		pod        *corev1.Pod
+		expPatches []jsonpatch.Operation
+	}{
+		"pod that would be injected": {
+			namespace: "default",
+			pod: &corev1.Pod{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+			},
+			expPatches: []jsonpatch.Operation{
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations",
+					Value: map[string]interface{}{
+						constants.KeyInjectDecision:       "inject",
+						constants.KeyInjectDecisionReason: "injection is enabled by default",
+					},
+				},
+			},
+		},
+		"pod that would be skipped": {
+			namespace: "kube-system",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"foo": "bar"}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+			},
+			expPatches: []jsonpatch.Operation{
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.KeyInjectDecision),
+					Value:     "skip",
+				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations/" + escapeJSONPointer(constants.KeyInjectDecisionReason),
+					Value:     "namespace kube-system is a Kubernetes system namespace",
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				Log:                   logrtest.New(t),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				DryRun:                true,
+				decoder:               decoder,
+				Clientset:             clientWithNamespace(c.namespace),
+			}
+			resp := w.Handle(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: c.namespace,
+					Object:    encodeRaw(t, c.pod),
+				},
+			})
+			require.True(t, resp.Allowed)
+			require.ElementsMatch(t, c.expPatches, resp.Patches)
+		})
+	}
+}
+
+func TestNamespaceImageOverrides(t *testing.T) {
+	overridden := corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "canary",
+			Annotations: map[string]string{
+				constants.AnnotationConsulDataplaneImage: "hashicorp/consul-dataplane:canary",
+				constants.AnnotationConsulK8sImage:       "hashicorp/consul-k8s-control-plane:canary",
+			},
+		},
+	}
+	fipsOverridden := corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "canary",
+			Annotations: map[string]string{
+				constants.AnnotationConsulDataplaneImage: "hashicorp/consul-dataplane-fips:canary",
+				constants.AnnotationConsulK8sImage:       "hashicorp/consul-k8s-control-plane-fips:canary",
+			},
+		},
+	}
+	cases := map[string]struct {
+		ns           corev1.Namespace
+		enabled      bool
+		fips         bool
+		expDataplane string
+		expK8s       string
+		expErr       string
+	}{
+		"no annotations": {
+			ns:           corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+			enabled:      true,
+			expDataplane: "hashicorp/consul-dataplane:latest",
+			expK8s:       "hashicorp/consul-k8s-control-plane:latest",
+		},
+		"annotations with overrides disabled": {
+			ns:           overridden,
+			expDataplane: "hashicorp/consul-dataplane:latest",
+			expK8s:       "hashicorp/consul-k8s-control-plane:latest",
+		},
+		"annotations with overrides enabled": {
+			ns:           overridden,
+			enabled:      true,
+			expDataplane: "hashicorp/consul-dataplane:canary",
+			expK8s:       "hashicorp/consul-k8s-control-plane:canary",
+		},
+		"FIPS annotations with FIPS enabled": {
+			ns:           fipsOverridden,
+			enabled:      true,
+			fips:         true,
+			expDataplane: "hashicorp/consul-dataplane-fips:canary",
+			expK8s:       "hashicorp/consul-k8s-control-plane-fips:canary",
+		},
+		"non-FIPS annotations with FIPS enabled": {
+			ns:      overridden,
+			enabled: true,
+			fips:    true,
+			expErr:  "must be a FIPS variant if FIPS is enabled",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				ImageConsulDataplane:    "hashicorp/consul-dataplane:latest",
+				ImageConsulK8S:          "hashicorp/consul-k8s-control-plane:latest",
+				NamespaceImageOverrides: c.enabled,
+				EnableFIPS:              c.fips,
+				ConsulConfig:            &consul.Config{HTTPPort: 8500, GRPCPort: 8502},
+			}
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{constants.AnnotationService: "web"}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+			}
+
+			sidecar, err := w.consulDataplaneSidecar(c.ns, pod, multiPortInfo{})
+			if c.expErr != "" {
+				require.ErrorContains(t, err, c.expErr)
+				_, err = w.containerInit(c.ns, pod, multiPortInfo{})
+				require.ErrorContains(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expDataplane, sidecar.Image)
+
+			initContainer, err := w.containerInit(c.ns, pod, multiPortInfo{})
+			require.NoError(t, err)
+			require.Equal(t, c.expK8s, initContainer.Image)
+			// Overridden images may be older builds without the -termination-message-file flag.
+			if c.expK8s == w.ImageConsulK8S {
+				require.Contains(t, initContainer.Command[2], "-termination-message-file=")
+			} else {
+				require.NotContains(t, initContainer.Command[2], "-termination-message-file=")
+			}
+		})
+	}
+}
+
 func TestOverwriteProbes(t *testing.T) {
 	t.Parallel()
 
@@ -1848,6 +2424,46 @@ func TestOverwriteProbes(t *testing.T) {
 			expReadinessPort: []int{exposedPathsReadinessPortsRangeStart, exposedPathsReadinessPortsRangeStart + 1},
 			expStartupPort:   []int{exposedPathsStartupPortsRangeStart, exposedPathsStartupPortsRangeStart + 1},
 		},
+		"transparent proxy enabled; overwrite probes enabled; grpc and tcp probes": {
+			tproxyEnabled:   true,
+			overwriteProbes: true,
+			podContainers: []corev1.Container{
+				{
+					Name: "test",
+					Ports: []corev1.ContainerPort{
+						{
+							Name:          "grpc",
+							ContainerPort: 9090,
+						},
+					},
+					LivenessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							TCPSocket: &corev1.TCPSocketAction{
+								Port: intstr.FromInt(9090),
+							},
+						},
+					},
+					ReadinessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							GRPC: &corev1.GRPCAction{
+								Port: 9090,
+							},
+						},
+					},
+					StartupProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							GRPC: &corev1.GRPCAction{
+								Port: 9090,
+							},
+						},
+					},
+				},
+			},
+			// TCP probes aren't overwritten.
+			expLivenessPort:  []int{9090},
+			expReadinessPort: []int{exposedPathsReadinessPortsRangeStart},
+			expStartupPort:   []int{exposedPathsStartupPortsRangeStart},
+		},
 	}
 
 	for name, c := range cases {
@@ -1873,19 +2489,31 @@ func TestOverwriteProbes(t *testing.T) {
 			require.NoError(t, err)
 			for i, container := range pod.Spec.Containers {
 				if container.ReadinessProbe != nil {
-					require.Equal(t, c.expReadinessPort[i], container.ReadinessProbe.HTTPGet.Port.IntValue())
+					require.Equal(t, c.expReadinessPort[i], probePortValue(container.ReadinessProbe))
 				}
 				if container.LivenessProbe != nil {
-					require.Equal(t, c.expLivenessPort[i], container.LivenessProbe.HTTPGet.Port.IntValue())
+					require.Equal(t, c.expLivenessPort[i], probePortValue(container.LivenessProbe))
 				}
 				if container.StartupProbe != nil {
-					require.Equal(t, c.expStartupPort[i], container.StartupProbe.HTTPGet.Port.IntValue())
+					require.Equal(t, c.expStartupPort[i], probePortValue(container.StartupProbe))
 				}
 			}
 		})
 	}
 }
 
+func probePortValue(probe *corev1.Probe) int {
+	switch {
+	case probe.HTTPGet != nil:
+		return probe.HTTPGet.Port.IntValue()
+	case probe.GRPC != nil:
+		return int(probe.GRPC.Port)
+	case probe.TCPSocket != nil:
+		return probe.TCPSocket.Port.IntValue()
+	}
+	return 0
+}
+
 func TestHandler_checkUnsupportedMultiPortCases(t *testing.T) {
 	cases := []struct {
 		name        string
@@ -0,0 +1,93 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/common"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// namespaceUpstreamsKey is the key of the namespace upstreams ConfigMap that holds the upstreams.
+// Its value has the format of the consul.hashicorp.com/connect-service-upstreams annotation.
+const namespaceUpstreamsKey = "upstreams"
+
+// mergeNamespaceUpstreams adds the upstreams of the namespace upstreams ConfigMap in the pod's namespace
+// to the pod's upstreams annotation, so that the pods of a namespace can share upstreams without repeating
+// them. Upstreams of the pod take precedence over upstreams of the ConfigMap with the same destination
+// or local port. Nothing is added if the ConfigMap doesn't exist.
+func (w *MeshWebhook) mergeNamespaceUpstreams(ctx context.Context, pod *corev1.Pod, k8sNS string) error {
+	if w.NamespaceUpstreamsConfigMap == "" {
+		return nil
+	}
+	configMap, err := w.Clientset.CoreV1().ConfigMaps(k8sNS).Get(ctx, w.NamespaceUpstreamsConfigMap, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	upstreams := splitUpstreams(pod.Annotations[constants.AnnotationUpstreams])
+	destinations := make(map[string]bool)
+	ports := make(map[string]bool)
+	for _, upstream := range upstreams {
+		destination, port := upstreamDestinationAndPort(upstream)
+		destinations[destination] = true
+		ports[port] = true
+	}
+	added := false
+	for _, upstream := range splitUpstreams(configMap.Data[namespaceUpstreamsKey]) {
+		destination, port := upstreamDestinationAndPort(upstream)
+		if destinations[destination] || (port != "" && ports[port]) {
+			continue
+		}
+		upstreams = append(upstreams, upstream)
+		destinations[destination] = true
+		ports[port] = true
+		added = true
+	}
+	if !added {
+		return nil
+	}
+
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	pod.Annotations[constants.AnnotationUpstreams] = strings.Join(upstreams, ",")
+	return nil
+}
+
+// splitUpstreams splits a list of upstreams in the format of the upstreams annotation.
+func splitUpstreams(raw string) []string {
+	var upstreams []string
+	for _, upstream := range strings.Split(raw, ",") {
+		if upstream = strings.TrimSpace(upstream); upstream != "" {
+			upstreams = append(upstreams, upstream)
+		}
+	}
+	return upstreams
+}
+
+// upstreamDestinationAndPort returns the destination and local port of an upstream. Prepared
+// query upstreams are identified by their query name.
+func upstreamDestinationAndPort(upstream string) (string, string) {
+	upstream, _ = common.SplitUpstreamOptions(upstream)
+	parts := strings.SplitN(upstream, ":", 3)
+	if len(parts) < 2 {
+		return upstream, ""
+	}
+	if strings.TrimSpace(parts[0]) == "prepared_query" && len(parts) == 3 {
+		return "prepared_query:" + strings.TrimSpace(parts[1]), strings.TrimSpace(parts[2])
+	}
+	destination := strings.TrimSpace(parts[0])
+	if len(parts) == 3 {
+		destination += ":" + strings.TrimSpace(parts[2])
+	}
+	return destination, strings.TrimSpace(parts[1])
+}
@@ -0,0 +1,85 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMergeNamespaceUpstreams(t *testing.T) {
+	cases := map[string]struct {
+		configMapName      string
+		configMapUpstreams *string
+		podUpstreams       string
+		expUpstreams       string
+	}{
+		"disabled": {
+			configMapUpstreams: pointerTo("db:1234"),
+			podUpstreams:       "web:2234",
+			expUpstreams:       "web:2234",
+		},
+		"no config map": {
+			configMapName: "consul-upstreams",
+			podUpstreams:  "web:2234",
+			expUpstreams:  "web:2234",
+		},
+		"adds the namespace upstreams to pods without upstreams": {
+			configMapName:      "consul-upstreams",
+			configMapUpstreams: pointerTo("db:1234, prepared_query:cache:3234"),
+			expUpstreams:       "db:1234,prepared_query:cache:3234",
+		},
+		"appends the namespace upstreams to the pod's upstreams": {
+			configMapName:      "consul-upstreams",
+			configMapUpstreams: pointerTo("db:1234;mesh-gateway=local"),
+			podUpstreams:       "web:2234",
+			expUpstreams:       "web:2234,db:1234;mesh-gateway=local",
+		},
+		"pod upstreams take precedence for the same destination or port": {
+			configMapName:      "consul-upstreams",
+			configMapUpstreams: pointerTo("db:1234, api:2234"),
+			podUpstreams:       "db:5234, web:2234",
+			expUpstreams:       "db:5234, web:2234",
+		},
+		"upstreams in other datacenters are different destinations": {
+			configMapName:      "consul-upstreams",
+			configMapUpstreams: pointerTo("web:3234:dc2"),
+			podUpstreams:       "web:2234",
+			expUpstreams:       "web:2234,web:3234:dc2",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var objects []runtime.Object
+			if c.configMapUpstreams != nil {
+				objects = append(objects, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "consul-upstreams", Namespace: "default"},
+					Data:       map[string]string{namespaceUpstreamsKey: *c.configMapUpstreams},
+				})
+			}
+			w := MeshWebhook{
+				Clientset:                   fake.NewSimpleClientset(objects...),
+				NamespaceUpstreamsConfigMap: c.configMapName,
+			}
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}}}
+			if c.podUpstreams != "" {
+				pod.Annotations[constants.AnnotationUpstreams] = c.podUpstreams
+			}
+
+			require.NoError(t, w.mergeNamespaceUpstreams(context.Background(), pod, "default"))
+			require.Equal(t, c.expUpstreams, pod.Annotations[constants.AnnotationUpstreams])
+		})
+	}
+}
+
+func pointerTo(s string) *string {
+	return &s
+}
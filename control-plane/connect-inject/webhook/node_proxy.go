@@ -0,0 +1,44 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// nodeProxyPod configures a pod that opted into the experimental node proxy mode. The pod isn't
+// injected with an init container or sidecar proxy. It's only labeled as injected so that the endpoints
+// controller registers its service instance, and its proxy service instance as a listener on the
+// proxy of its Kubernetes node.
+func (w *MeshWebhook) nodeProxyPod(pod *corev1.Pod, k8sNS string) error {
+	if !w.EnableNodeProxy {
+		return errors.New("the node proxy mode is not enabled")
+	}
+	// The node proxy has a single listener for each pod and doesn't bind upstream
+	// listeners that the pod could reach.
+	if len(w.annotatedServiceNames(*pod)) > 1 {
+		return errors.New("multi-port pods can't use the node proxy")
+	}
+	if pod.Annotations[constants.AnnotationUpstreams] != "" {
+		return fmt.Errorf("pods that use the node proxy can't have the %s annotation", constants.AnnotationUpstreams)
+	}
+
+	// pod.Annotations has already been initialized by h.defaultAnnotations()
+	// and does not need to be checked for being a nil value.
+	pod.Annotations[constants.KeyInjectStatus] = constants.Injected
+	if w.EnableNamespaces {
+		pod.Annotations[constants.AnnotationConsulNamespace] = w.consulNamespace(k8sNS)
+	}
+
+	if pod.Labels == nil {
+		pod.Labels = make(map[string]string)
+	}
+	pod.Labels[constants.KeyInjectStatus] = constants.Injected
+	pod.Labels[constants.KeyManagedBy] = constants.ManagedByValue
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNodeProxyPod(t *testing.T) {
+	cases := map[string]struct {
+		enabled     bool
+		annotations map[string]string
+		expErr      string
+	}{
+		"node proxy disabled": {
+			expErr: "the node proxy mode is not enabled",
+		},
+		"pod with upstreams": {
+			enabled:     true,
+			annotations: map[string]string{constants.AnnotationUpstreams: "db:1234"},
+			expErr:      "pods that use the node proxy can't have the consul.hashicorp.com/connect-service-upstreams annotation",
+		},
+		"multi-port pod": {
+			enabled:     true,
+			annotations: map[string]string{constants.AnnotationService: "web,web-admin"},
+			expErr:      "multi-port pods can't use the node proxy",
+		},
+		"pod is labeled as injected": {
+			enabled:     true,
+			annotations: map[string]string{},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{EnableNodeProxy: c.enabled}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{constants.AnnotationNodeProxy: "true"},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "web"}},
+				},
+			}
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+
+			err := w.nodeProxyPod(pod, "default")
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, constants.Injected, pod.Annotations[constants.KeyInjectStatus])
+			require.Equal(t, constants.Injected, pod.Labels[constants.KeyInjectStatus])
+			require.Equal(t, constants.ManagedByValue, pod.Labels[constants.KeyManagedBy])
+			require.Len(t, pod.Spec.Containers, 1)
+			require.Empty(t, pod.Spec.InitContainers)
+		})
+	}
+}
@@ -4,9 +4,12 @@
 package webhook
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"strconv"
+	"strings"
 
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/common"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
@@ -23,8 +26,8 @@ import (
 //	ProxyOutboundPort: default transparent proxy outbound port or transparent proxy outbound listener port
 //	ExcludeInboundPorts: prometheus, envoy stats, expose paths, checks and excluded pod annotations
 //	ExcludeOutboundPorts: pod annotations
-//	ExcludeOutboundCIDRs: pod annotations
-//	ExcludeUIDs: pod annotations
+//	ExcludeOutboundCIDRs: pod annotations, or all addresses for inbound-only pods
+//	ExcludeUIDs: pod annotations and the debug container UID
 func (w *MeshWebhook) iptablesConfigJSON(pod corev1.Pod, ns corev1.Namespace) (string, error) {
 	cfg := iptables.Config{
 		ProxyUserID: strconv.Itoa(sidecarUserAndGroupID),
@@ -67,13 +70,13 @@ func (w *MeshWebhook) iptablesConfigJSON(pod corev1.Pod, ns corev1.Namespace) (s
 			if container.Name == sidecarContainer {
 				continue
 			}
-			if container.LivenessProbe != nil && container.LivenessProbe.HTTPGet != nil {
+			if exposableProbe(container.LivenessProbe) {
 				cfg.ExcludeInboundPorts = append(cfg.ExcludeInboundPorts, strconv.Itoa(exposedPathsLivenessPortsRangeStart+i))
 			}
-			if container.ReadinessProbe != nil && container.ReadinessProbe.HTTPGet != nil {
+			if exposableProbe(container.ReadinessProbe) {
 				cfg.ExcludeInboundPorts = append(cfg.ExcludeInboundPorts, strconv.Itoa(exposedPathsReadinessPortsRangeStart+i))
 			}
-			if container.StartupProbe != nil && container.StartupProbe.HTTPGet != nil {
+			if exposableProbe(container.StartupProbe) {
 				cfg.ExcludeInboundPorts = append(cfg.ExcludeInboundPorts, strconv.Itoa(exposedPathsStartupPortsRangeStart+i))
 			}
 		}
@@ -81,20 +84,51 @@ func (w *MeshWebhook) iptablesConfigJSON(pod corev1.Pod, ns corev1.Namespace) (s
 
 	// Inbound ports
 	excludeInboundPorts := splitCommaSeparatedItemsFromAnnotation(constants.AnnotationTProxyExcludeInboundPorts, pod)
+	if err := validateExcludedPorts(constants.AnnotationTProxyExcludeInboundPorts, excludeInboundPorts); err != nil {
+		return "", err
+	}
 	cfg.ExcludeInboundPorts = append(cfg.ExcludeInboundPorts, excludeInboundPorts...)
 
 	// Outbound ports
 	excludeOutboundPorts := splitCommaSeparatedItemsFromAnnotation(constants.AnnotationTProxyExcludeOutboundPorts, pod)
+	if err := validateExcludedPorts(constants.AnnotationTProxyExcludeOutboundPorts, excludeOutboundPorts); err != nil {
+		return "", err
+	}
 	cfg.ExcludeOutboundPorts = append(cfg.ExcludeOutboundPorts, excludeOutboundPorts...)
 
 	// Outbound CIDRs
 	excludeOutboundCIDRs := splitCommaSeparatedItemsFromAnnotation(constants.AnnotationTProxyExcludeOutboundCIDRs, pod)
+	if err := validateExcludedCIDRs(excludeOutboundCIDRs, w.EnableIPv6); err != nil {
+		return "", err
+	}
 	cfg.ExcludeOutboundCIDRs = append(cfg.ExcludeOutboundCIDRs, excludeOutboundCIDRs...)
 
 	// UIDs
 	excludeUIDs := splitCommaSeparatedItemsFromAnnotation(constants.AnnotationTProxyExcludeUIDs, pod)
+	if err := validateExcludedUIDs(excludeUIDs); err != nil {
+		return "", err
+	}
 	cfg.ExcludeUIDs = append(cfg.ExcludeUIDs, excludeUIDs...)
 
+	// Exclude the UID that ephemeral debug containers run as.
+	if raw, ok := pod.Annotations[constants.AnnotationDebugContainerUID]; ok {
+		if _, err := debugContainerUID(raw); err != nil {
+			return "", err
+		}
+		cfg.ExcludeUIDs = append(cfg.ExcludeUIDs, raw)
+	}
+
+	// In inbound-only mode, exclude all outbound traffic from redirection.
+	if raw, ok := pod.Annotations[constants.AnnotationTProxyInboundOnly]; ok {
+		inboundOnly, err := strconv.ParseBool(raw)
+		if err != nil {
+			return "", fmt.Errorf("%s annotation value of %s was invalid: %s", constants.AnnotationTProxyInboundOnly, raw, err)
+		}
+		if inboundOnly {
+			cfg.ExcludeOutboundCIDRs = append(cfg.ExcludeOutboundCIDRs, allAddressesCIDR(w.EnableIPv6))
+		}
+	}
+
 	// Add init container user ID to exclude from traffic redirection.
 	cfg.ExcludeUIDs = append(cfg.ExcludeUIDs, strconv.Itoa(initContainersUserAndGroupID))
 
@@ -107,7 +141,7 @@ func (w *MeshWebhook) iptablesConfigJSON(pod corev1.Pod, ns corev1.Namespace) (s
 		// If Consul DNS is enabled, we find the environment variable that has the value
 		// of the ClusterIP of the Consul DNS Service. constructDNSServiceHostName returns
 		// the name of the env variable whose value is the ClusterIP of the Consul DNS Service.
-		cfg.ConsulDNSIP = consulDataplaneDNSBindHost
+		cfg.ConsulDNSIP = common.LocalhostAddress(w.EnableIPv6)
 		cfg.ConsulDNSPort = consulDataplaneDNSBindPort
 	}
 
@@ -119,6 +153,78 @@ func (w *MeshWebhook) iptablesConfigJSON(pod corev1.Pod, ns corev1.Namespace) (s
 	return string(iptablesConfigJson), nil
 }
 
+// validateExcludedPorts returns an error if any of the ports from the given
+// annotation is not a valid port number or range of port numbers, e.g. 8000:9000.
+func validateExcludedPorts(annotation string, ports []string) error {
+	for _, port := range ports {
+		first, last, isRange := strings.Cut(port, ":")
+		if !isRange {
+			last = first
+		}
+		start, startOK := parseExcludedPort(first)
+		end, endOK := parseExcludedPort(last)
+		if !startOK || !endOK || end < start {
+			return fmt.Errorf("%s annotation value of %s was invalid: must be a port between 1 and 65535 or a range of ports such as 8000:9000", annotation, port)
+		}
+	}
+	return nil
+}
+
+// parseExcludedPort returns the port number and true if port is a number between 1 and 65535.
+func parseExcludedPort(port string) (int, bool) {
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > 65535 {
+		return 0, false
+	}
+	return p, true
+}
+
+// validateExcludedCIDRs returns an error if any of the outbound CIDRs to
+// exclude is not an IP address or CIDR block. Since traffic redirection uses
+// either iptables or ip6tables, addresses must also match the IP family of the
+// cluster.
+func validateExcludedCIDRs(cidrs []string, enableIPv6 bool) error {
+	for _, cidr := range cidrs {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			var err error
+			ip, _, err = net.ParseCIDR(cidr)
+			if err != nil {
+				return fmt.Errorf("%s annotation value of %s was invalid: %s",
+					constants.AnnotationTProxyExcludeOutboundCIDRs, cidr, err)
+			}
+		}
+		if isIPv4 := ip.To4() != nil; isIPv4 == enableIPv6 {
+			family := "IPv4"
+			if enableIPv6 {
+				family = "IPv6"
+			}
+			return fmt.Errorf("%s annotation value of %s was invalid: must be an %s address or CIDR",
+				constants.AnnotationTProxyExcludeOutboundCIDRs, cidr, family)
+		}
+	}
+	return nil
+}
+
+// allAddressesCIDR returns the CIDR that matches every address of the cluster's IP family.
+func allAddressesCIDR(enableIPv6 bool) string {
+	if enableIPv6 {
+		return "::/0"
+	}
+	return "0.0.0.0/0"
+}
+
+// validateExcludedUIDs returns an error if any of the UIDs to exclude is not
+// a valid user ID.
+func validateExcludedUIDs(uids []string) error {
+	for _, uid := range uids {
+		if _, err := strconv.ParseUint(uid, 10, 32); err != nil {
+			return fmt.Errorf("%s annotation value of %s was invalid: %s", constants.AnnotationTProxyExcludeUIDs, uid, err)
+		}
+	}
+	return nil
+}
+
 // addRedirectTrafficConfigAnnotation add the created iptables JSON config as an annotation on the provided pod.
 func (w *MeshWebhook) addRedirectTrafficConfigAnnotation(pod *corev1.Pod, ns corev1.Namespace) error {
 	iptablesConfig, err := w.iptablesConfigJSON(*pod, ns)
@@ -130,3 +236,30 @@ func (w *MeshWebhook) addRedirectTrafficConfigAnnotation(pod *corev1.Pod, ns cor
 
 	return nil
 }
+
+// checkCNIVersionSkew returns an error if the consul-cni plugin that will redirect the pod's traffic may be a
+// different version than the webhook. Pods are usually not scheduled yet when they are admitted, so any node
+// with a skewed plugin causes the pod to be denied unless the pod is already assigned to a node.
+func (w *MeshWebhook) checkCNIVersionSkew(ctx context.Context, pod corev1.Pod) error {
+	if pod.Spec.NodeName != "" {
+		skewed, err := w.CNIVersionChecker.NodeSkewed(ctx, pod.Spec.NodeName)
+		if err != nil {
+			return fmt.Errorf("unable to check consul-cni version on node %s: %s", pod.Spec.NodeName, err)
+		}
+		if skewed {
+			return fmt.Errorf("consul-cni version on node %s differs from connect-inject version %s",
+				pod.Spec.NodeName, w.CNIVersionChecker.Version)
+		}
+		return nil
+	}
+
+	skewed, err := w.CNIVersionChecker.SkewedNodes(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to check consul-cni versions: %s", err)
+	}
+	if len(skewed) > 0 {
+		return fmt.Errorf("consul-cni version on nodes %s differs from connect-inject version %s",
+			strings.Join(skewed, ", "), w.CNIVersionChecker.Version)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@
 package webhook
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -12,6 +13,7 @@ import (
 	mapset "github.com/deckarep/golang-set"
 	logrtest "github.com/go-logr/logr/testr"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/controllers/cniversion"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
 	"github.com/hashicorp/consul/sdk/iptables"
 	"github.com/stretchr/testify/require"
@@ -20,6 +22,8 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
@@ -218,6 +222,54 @@ func TestAddRedirectTrafficConfig(t *testing.T) {
 				ExcludeInboundPorts: []string{strconv.Itoa(exposedPathsLivenessPortsRangeStart)},
 			},
 		},
+		{
+			name: "overwrite grpc probes and not tcp probes",
+			webhook: MeshWebhook{
+				Log:                   logrtest.New(t),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+			},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      defaultPodName,
+					Annotations: map[string]string{
+						constants.AnnotationTransparentProxyOverwriteProbes: "true",
+						constants.KeyTransparentProxy:                       "true",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+							LivenessProbe: &corev1.Probe{
+								ProbeHandler: corev1.ProbeHandler{
+									TCPSocket: &corev1.TCPSocketAction{
+										Port: intstr.FromInt(8080),
+									},
+								},
+							},
+							ReadinessProbe: &corev1.Probe{
+								ProbeHandler: corev1.ProbeHandler{
+									GRPC: &corev1.GRPCAction{
+										Port: exposedPathsReadinessPortsRangeStart,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expCfg: iptables.Config{
+				ConsulDNSIP:         "",
+				ProxyUserID:         strconv.Itoa(sidecarUserAndGroupID),
+				ProxyInboundPort:    constants.ProxyDefaultInboundPort,
+				ProxyOutboundPort:   iptables.DefaultTProxyOutboundPort,
+				ExcludeUIDs:         []string{"5996"},
+				ExcludeInboundPorts: []string{strconv.Itoa(exposedPathsReadinessPortsRangeStart)},
+			},
+		},
 		{
 			name: "exclude inbound ports",
 			webhook: MeshWebhook{
@@ -284,6 +336,41 @@ func TestAddRedirectTrafficConfig(t *testing.T) {
 				ExcludeOutboundPorts: []string{"2222", "22222"},
 			},
 		},
+		{
+			name: "exclude port ranges",
+			webhook: MeshWebhook{
+				Log:                   logrtest.New(t),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+			},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      defaultPodName,
+					Annotations: map[string]string{
+						constants.AnnotationTProxyExcludeInboundPorts:  "1111:1120",
+						constants.AnnotationTProxyExcludeOutboundPorts: "2222,8000:9000",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+						},
+					},
+				},
+			},
+			expCfg: iptables.Config{
+				ConsulDNSIP:          "",
+				ProxyUserID:          strconv.Itoa(sidecarUserAndGroupID),
+				ProxyInboundPort:     constants.ProxyDefaultInboundPort,
+				ProxyOutboundPort:    iptables.DefaultTProxyOutboundPort,
+				ExcludeUIDs:          []string{"5996"},
+				ExcludeInboundPorts:  []string{"1111:1120"},
+				ExcludeOutboundPorts: []string{"2222", "8000:9000"},
+			},
+		},
 		{
 			name: "exclude outbound CIDRs",
 			webhook: MeshWebhook{
@@ -349,6 +436,39 @@ func TestAddRedirectTrafficConfig(t *testing.T) {
 				ExcludeUIDs:       []string{"4444", "44444", strconv.Itoa(initContainersUserAndGroupID)},
 			},
 		},
+		{
+			name: "exclude debug container UID",
+			webhook: MeshWebhook{
+				Log:                   logrtest.New(t),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+			},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      defaultPodName,
+					Annotations: map[string]string{
+						constants.AnnotationTProxyExcludeUIDs: "4444",
+						constants.AnnotationDebugContainerUID: "5997",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+						},
+					},
+				},
+			},
+			expCfg: iptables.Config{
+				ConsulDNSIP:       "",
+				ProxyUserID:       strconv.Itoa(sidecarUserAndGroupID),
+				ProxyInboundPort:  constants.ProxyDefaultInboundPort,
+				ProxyOutboundPort: iptables.DefaultTProxyOutboundPort,
+				ExcludeUIDs:       []string{"4444", "5997", strconv.Itoa(initContainersUserAndGroupID)},
+			},
+		},
 		{
 			name: "exclude inbound ports, outbound ports, outbound CIDRs, and UIDs",
 			webhook: MeshWebhook{
@@ -386,6 +506,289 @@ func TestAddRedirectTrafficConfig(t *testing.T) {
 				ExcludeUIDs:          []string{"4444", "44444", strconv.Itoa(initContainersUserAndGroupID)},
 			},
 		},
+		{
+			name: "exclude outbound CIDRs with whitespace",
+			webhook: MeshWebhook{
+				Log:                   logrtest.New(t),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+			},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      defaultPodName,
+					Annotations: map[string]string{
+						constants.AnnotationTProxyExcludeOutboundCIDRs: "169.254.169.254, 10.0.0.0/8",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+						},
+					},
+				},
+			},
+			expCfg: iptables.Config{
+				ProxyUserID:          strconv.Itoa(sidecarUserAndGroupID),
+				ProxyInboundPort:     constants.ProxyDefaultInboundPort,
+				ProxyOutboundPort:    iptables.DefaultTProxyOutboundPort,
+				ExcludeUIDs:          []string{strconv.Itoa(initContainersUserAndGroupID)},
+				ExcludeOutboundCIDRs: []string{"169.254.169.254", "10.0.0.0/8"},
+			},
+		},
+		{
+			name: "invalid exclude outbound CIDR",
+			webhook: MeshWebhook{
+				Log:                   logrtest.New(t),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+			},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      defaultPodName,
+					Annotations: map[string]string{
+						constants.AnnotationTProxyExcludeOutboundCIDRs: "10.0.0.0/33",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+						},
+					},
+				},
+			},
+			expErr: fmt.Errorf("%s annotation value of 10.0.0.0/33 was invalid: invalid CIDR address: 10.0.0.0/33", constants.AnnotationTProxyExcludeOutboundCIDRs),
+		},
+		{
+			name: "IPv4 exclude outbound CIDR on an IPv6 cluster",
+			webhook: MeshWebhook{
+				Log:                   logrtest.New(t),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+				EnableIPv6:            true,
+			},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      defaultPodName,
+					Annotations: map[string]string{
+						constants.AnnotationTProxyExcludeOutboundCIDRs: "169.254.169.254",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+						},
+					},
+				},
+			},
+			expErr: fmt.Errorf("%s annotation value of 169.254.169.254 was invalid: must be an IPv6 address or CIDR", constants.AnnotationTProxyExcludeOutboundCIDRs),
+		},
+		{
+			name: "IPv6 exclude outbound CIDR on an IPv4 cluster",
+			webhook: MeshWebhook{
+				Log:                   logrtest.New(t),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+			},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      defaultPodName,
+					Annotations: map[string]string{
+						constants.AnnotationTProxyExcludeOutboundCIDRs: "fd00::/8",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+						},
+					},
+				},
+			},
+			expErr: fmt.Errorf("%s annotation value of fd00::/8 was invalid: must be an IPv4 address or CIDR", constants.AnnotationTProxyExcludeOutboundCIDRs),
+		},
+		{
+			name: "invalid exclude outbound port",
+			webhook: MeshWebhook{
+				Log:                   logrtest.New(t),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+			},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      defaultPodName,
+					Annotations: map[string]string{
+						constants.AnnotationTProxyExcludeOutboundPorts: "2222,70000",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+						},
+					},
+				},
+			},
+			expErr: fmt.Errorf("%s annotation value of 70000 was invalid: must be a port between 1 and 65535 or a range of ports such as 8000:9000", constants.AnnotationTProxyExcludeOutboundPorts),
+		},
+		{
+			name: "invalid exclude inbound port",
+			webhook: MeshWebhook{
+				Log:                   logrtest.New(t),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+			},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      defaultPodName,
+					Annotations: map[string]string{
+						constants.AnnotationTProxyExcludeInboundPorts: "http",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+						},
+					},
+				},
+			},
+			expErr: fmt.Errorf("%s annotation value of http was invalid: must be a port between 1 and 65535 or a range of ports such as 8000:9000", constants.AnnotationTProxyExcludeInboundPorts),
+		},
+		{
+			name: "inbound only",
+			webhook: MeshWebhook{
+				Log:                   logrtest.New(t),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+			},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      defaultPodName,
+					Annotations: map[string]string{
+						constants.AnnotationTProxyInboundOnly:          "true",
+						constants.AnnotationTProxyExcludeOutboundCIDRs: "10.0.0.0/8",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+						},
+					},
+				},
+			},
+			expCfg: iptables.Config{
+				ConsulDNSIP:          "",
+				ProxyUserID:          strconv.Itoa(sidecarUserAndGroupID),
+				ProxyInboundPort:     constants.ProxyDefaultInboundPort,
+				ProxyOutboundPort:    iptables.DefaultTProxyOutboundPort,
+				ExcludeOutboundCIDRs: []string{"10.0.0.0/8", "0.0.0.0/0"},
+				ExcludeUIDs:          []string{"5996"},
+			},
+		},
+		{
+			name: "inbound only with IPv6",
+			webhook: MeshWebhook{
+				Log:                   logrtest.New(t),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				EnableIPv6:            true,
+				decoder:               decoder,
+			},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      defaultPodName,
+					Annotations: map[string]string{
+						constants.AnnotationTProxyInboundOnly: "true",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+						},
+					},
+				},
+			},
+			expCfg: iptables.Config{
+				ConsulDNSIP:          "",
+				ProxyUserID:          strconv.Itoa(sidecarUserAndGroupID),
+				ProxyInboundPort:     constants.ProxyDefaultInboundPort,
+				ProxyOutboundPort:    iptables.DefaultTProxyOutboundPort,
+				ExcludeOutboundCIDRs: []string{"::/0"},
+				ExcludeUIDs:          []string{"5996"},
+			},
+		},
+		{
+			name: "invalid inbound only",
+			webhook: MeshWebhook{
+				Log:                   logrtest.New(t),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+			},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      defaultPodName,
+					Annotations: map[string]string{
+						constants.AnnotationTProxyInboundOnly: "yes",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+						},
+					},
+				},
+			},
+			expErr: fmt.Errorf("%s annotation value of yes was invalid: strconv.ParseBool: parsing \"yes\": invalid syntax", constants.AnnotationTProxyInboundOnly),
+		},
+		{
+			name: "invalid exclude UID",
+			webhook: MeshWebhook{
+				Log:                   logrtest.New(t),
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+			},
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      defaultPodName,
+					Annotations: map[string]string{
+						constants.AnnotationTProxyExcludeUIDs: "-1",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "test",
+						},
+					},
+				},
+			},
+			expErr: fmt.Errorf("%s annotation value of -1 was invalid: strconv.ParseUint: parsing \"-1\": invalid syntax", constants.AnnotationTProxyExcludeUIDs),
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
@@ -478,3 +881,55 @@ func TestRedirectTraffic_consulDNS(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckCNIVersionSkew(t *testing.T) {
+	node := func(name, version string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{constants.LabelConsulCNIVersion: version},
+		}}
+	}
+	cases := map[string]struct {
+		nodes    []runtime.Object
+		nodeName string
+		expErr   string
+	}{
+		"no skew": {
+			nodes: []runtime.Object{node("node-a", "1.2.0"), node("node-b", "1.2.0")},
+		},
+		"unscheduled pod with skewed nodes": {
+			nodes:  []runtime.Object{node("node-a", "1.2.0"), node("node-c", "1.1.0"), node("node-b", "1.1.0")},
+			expErr: "consul-cni version on nodes node-b, node-c differs from connect-inject version 1.2.0",
+		},
+		"scheduled pod on a node without skew": {
+			nodes:    []runtime.Object{node("node-a", "1.2.0"), node("node-b", "1.1.0")},
+			nodeName: "node-a",
+		},
+		"scheduled pod on a skewed node": {
+			nodes:    []runtime.Object{node("node-a", "1.2.0"), node("node-b", "1.1.0")},
+			nodeName: "node-b",
+			expErr:   "consul-cni version on node node-b differs from connect-inject version 1.2.0",
+		},
+		"scheduled pod on an unknown node": {
+			nodeName: "node-a",
+			expErr:   `unable to check consul-cni version on node node-a: nodes "node-a" not found`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(c.nodes...).Build()
+			w := MeshWebhook{
+				CNIVersionChecker: &cniversion.Checker{Client: fakeClient, Version: "1.2.0"},
+			}
+			pod := minimal()
+			pod.Spec.NodeName = c.nodeName
+
+			err := w.checkCNIVersionSkew(context.Background(), *pod)
+			if c.expErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, c.expErr)
+			}
+		})
+	}
+}
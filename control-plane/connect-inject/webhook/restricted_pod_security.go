@@ -0,0 +1,83 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/pointer"
+)
+
+// errRestrictedTProxyRequiresCNI is returned when transparent proxy is enabled for a pod without the CNI plugin
+// while injected containers must comply with the "restricted" Pod Security Standard. Redirecting traffic from
+// the init container requires running it as root with the NET_ADMIN capability, which the standard forbids.
+var errRestrictedTProxyRequiresCNI = errors.New("transparent proxy requires the CNI plugin when injected containers " +
+	"must comply with the restricted Pod Security Standard")
+
+// restrictSecurityContext sets the security context of an injected container so that it complies with the
+// "restricted" Pod Security Standard: it runs as the given non-root user, unless OpenShift assigns the user,
+// with a read-only root filesystem and the runtime's default seccomp profile, can't escalate its privileges
+// and drops all capabilities.
+func (w *MeshWebhook) restrictSecurityContext(container *corev1.Container, userAndGroupID int64) {
+	if container.SecurityContext == nil {
+		container.SecurityContext = &corev1.SecurityContext{}
+	}
+	sc := container.SecurityContext
+	if sc.RunAsUser == nil && !w.EnableOpenShift {
+		sc.RunAsUser = pointer.Int64(userAndGroupID)
+		sc.RunAsGroup = pointer.Int64(userAndGroupID)
+	}
+	sc.RunAsNonRoot = pointer.Bool(true)
+	sc.Privileged = pointer.Bool(false)
+	sc.AllowPrivilegeEscalation = pointer.Bool(false)
+	sc.ReadOnlyRootFilesystem = pointer.Bool(true)
+	sc.Capabilities = &corev1.Capabilities{
+		Drop: []corev1.Capability{"ALL"},
+	}
+	sc.SeccompProfile = &corev1.SeccompProfile{
+		Type: corev1.SeccompProfileTypeRuntimeDefault,
+	}
+}
+
+// restrictedSELinuxTypes are the SELinux types that the restricted Pod Security Standard allows.
+var restrictedSELinuxTypes = map[string]bool{"": true, "container_t": true, "container_init_t": true, "container_kvm_t": true}
+
+// checkRestrictedSeccompProfile returns an error if the seccomp profile is forbidden by the "restricted" Pod
+// Security Standard, which requires the runtime's default or a localhost profile.
+func checkRestrictedSeccompProfile(profile *corev1.SeccompProfile) error {
+	if profile.Type == corev1.SeccompProfileTypeUnconfined {
+		return errNotRestricted("seccomp profile", profileUnconfined)
+	}
+	return nil
+}
+
+// checkRestrictedSELinuxOptions returns an error if the SELinux options are forbidden by the "restricted" Pod
+// Security Standard, which doesn't allow setting the user or role, or custom types.
+func checkRestrictedSELinuxOptions(options *corev1.SELinuxOptions) error {
+	if options.User != "" {
+		return errNotRestricted("SELinux user", options.User)
+	}
+	if options.Role != "" {
+		return errNotRestricted("SELinux role", options.Role)
+	}
+	if !restrictedSELinuxTypes[options.Type] {
+		return errNotRestricted("SELinux type", options.Type)
+	}
+	return nil
+}
+
+// checkRestrictedAppArmorProfile returns an error if the AppArmor profile is forbidden by the "restricted" Pod
+// Security Standard, which doesn't allow disabling AppArmor.
+func checkRestrictedAppArmorProfile(profile string) error {
+	if profile == profileUnconfined {
+		return errNotRestricted("AppArmor profile", profile)
+	}
+	return nil
+}
+
+func errNotRestricted(setting, value string) error {
+	return fmt.Errorf("%s %q is not allowed when injected containers must comply with the restricted Pod Security Standard", setting, value)
+}
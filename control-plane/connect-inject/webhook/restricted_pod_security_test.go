@@ -0,0 +1,88 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestHandlerRestrictedPodSecurity(t *testing.T) {
+	restricted := func(userAndGroupID *int64) *corev1.SecurityContext {
+		return &corev1.SecurityContext{
+			RunAsUser:                userAndGroupID,
+			RunAsGroup:               userAndGroupID,
+			RunAsNonRoot:             pointer.Bool(true),
+			Privileged:               pointer.Bool(false),
+			AllowPrivilegeEscalation: pointer.Bool(false),
+			ReadOnlyRootFilesystem:   pointer.Bool(true),
+			Capabilities: &corev1.Capabilities{
+				Drop: []corev1.Capability{"ALL"},
+			},
+			SeccompProfile: &corev1.SeccompProfile{
+				Type: corev1.SeccompProfileTypeRuntimeDefault,
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		tproxyEnabled     bool
+		cniEnabled        bool
+		openShiftEnabled  bool
+		expInitContext    *corev1.SecurityContext
+		expSidecarContext *corev1.SecurityContext
+		expInitErr        error
+	}{
+		"tproxy disabled": {
+			expInitContext:    restricted(pointer.Int64(initContainersUserAndGroupID)),
+			expSidecarContext: restricted(pointer.Int64(sidecarUserAndGroupID)),
+		},
+		"tproxy with cni": {
+			tproxyEnabled:     true,
+			cniEnabled:        true,
+			expInitContext:    restricted(pointer.Int64(initContainersUserAndGroupID)),
+			expSidecarContext: restricted(pointer.Int64(sidecarUserAndGroupID)),
+		},
+		"tproxy disabled on OpenShift leaves the user to OpenShift": {
+			openShiftEnabled:  true,
+			expInitContext:    restricted(nil),
+			expSidecarContext: restricted(nil),
+		},
+		"tproxy without cni": {
+			tproxyEnabled: true,
+			expInitErr:    errRestrictedTProxyRequiresCNI,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				EnableTransparentProxy:      c.tproxyEnabled,
+				EnableCNI:                   c.cniEnabled,
+				EnableOpenShift:             c.openShiftEnabled,
+				EnableRestrictedPodSecurity: true,
+				ConsulAddress:               "1.1.1.1",
+				ConsulConfig:                &consul.Config{HTTPPort: 8500, GRPCPort: 8502},
+			}
+			pod := minimal()
+			pod.Annotations = map[string]string{constants.AnnotationService: "foo"}
+
+			initContainer, err := w.containerInit(testNS, *pod, multiPortInfo{})
+			if c.expInitErr != nil {
+				require.ErrorIs(t, err, c.expInitErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expInitContext, initContainer.SecurityContext)
+
+			sidecar, err := w.consulDataplaneSidecar(testNS, *pod, multiPortInfo{})
+			require.NoError(t, err)
+			require.Equal(t, c.expSidecarContext, sidecar.SecurityContext)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// appArmorAnnotationPrefix is the prefix of the pod annotation that sets the AppArmor profile of a
+	// container. The name of the container follows the prefix.
+	appArmorAnnotationPrefix = "container.apparmor.security.beta.kubernetes.io/"
+
+	profileRuntimeDefault = "runtime/default"
+	profileUnconfined     = "unconfined"
+	profileLocalhost      = "localhost/"
+)
+
+// ParseSeccompProfile parses a seccomp profile in the format of the consul.hashicorp.com/seccomp-profile
+// annotation: runtime/default, unconfined or localhost/<path of the profile relative to the kubelet's
+// seccomp profile directory>.
+func ParseSeccompProfile(profile string) (*corev1.SeccompProfile, error) {
+	switch {
+	case profile == profileRuntimeDefault:
+		return &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}, nil
+	case profile == profileUnconfined:
+		return &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined}, nil
+	case strings.HasPrefix(profile, profileLocalhost) && len(profile) > len(profileLocalhost):
+		path := strings.TrimPrefix(profile, profileLocalhost)
+		return &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeLocalhost, LocalhostProfile: &path}, nil
+	}
+	return nil, fmt.Errorf("invalid seccomp profile %q: must be %s, %s or %s<profile>", profile, profileRuntimeDefault, profileUnconfined, profileLocalhost)
+}
+
+// ValidateAppArmorProfile returns an error unless profile is in the format of the
+// consul.hashicorp.com/apparmor-profile annotation: runtime/default, unconfined or localhost/<name of a
+// profile loaded on the node>.
+func ValidateAppArmorProfile(profile string) error {
+	if profile == profileRuntimeDefault || profile == profileUnconfined ||
+		(strings.HasPrefix(profile, profileLocalhost) && len(profile) > len(profileLocalhost)) {
+		return nil
+	}
+	return fmt.Errorf("invalid AppArmor profile %q: must be %s, %s or %s<profile>", profile, profileRuntimeDefault, profileUnconfined, profileLocalhost)
+}
+
+// ParseSELinuxOptions parses SELinux options in the format of the consul.hashicorp.com/selinux-options
+// annotation: a comma-separated list of user=<user>, role=<role>, type=<type> and level=<level>, e.g.
+// type=spc_t,level=s0:c123,c456. Since levels can contain commas, a list item without a key belongs to the
+// value of the previous item.
+func ParseSELinuxOptions(options string) (*corev1.SELinuxOptions, error) {
+	var keys []string
+	values := make(map[string]string)
+	for _, item := range strings.Split(options, ",") {
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			if len(keys) == 0 {
+				return nil, fmt.Errorf("invalid SELinux options %q: %q must be in the format <key>=<value>", options, item)
+			}
+			values[keys[len(keys)-1]] += "," + item
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if _, ok := values[key]; ok {
+			return nil, fmt.Errorf("invalid SELinux options %q: %s is set more than once", options, key)
+		}
+		keys = append(keys, key)
+		values[key] = strings.TrimSpace(value)
+	}
+
+	seLinuxOptions := &corev1.SELinuxOptions{}
+	for _, key := range keys {
+		switch key {
+		case "user":
+			seLinuxOptions.User = values[key]
+		case "role":
+			seLinuxOptions.Role = values[key]
+		case "type":
+			seLinuxOptions.Type = values[key]
+		case "level":
+			seLinuxOptions.Level = values[key]
+		default:
+			return nil, fmt.Errorf("invalid SELinux options %q: unknown option %q, must be one of user, role, type or level", options, key)
+		}
+	}
+	return seLinuxOptions, nil
+}
+
+// setSecurityProfiles sets the seccomp profile and SELinux options of an injected container from the pod's
+// annotations, or the webhook's defaults if the pod doesn't set them. Hardened node images may require them,
+// e.g. when their default profiles block iptables in the init container or Envoy's hot restart in the sidecar.
+// Profiles that the restricted Pod Security Standard forbids are rejected if EnableRestrictedPodSecurity is set,
+// since they would override the restricted security context.
+func (w *MeshWebhook) setSecurityProfiles(pod corev1.Pod, container *corev1.Container) error {
+	seccompProfile := w.DefaultSeccompProfile
+	if raw, ok := pod.Annotations[constants.AnnotationSeccompProfile]; ok {
+		seccompProfile = raw
+	}
+	if seccompProfile != "" {
+		profile, err := ParseSeccompProfile(seccompProfile)
+		if err != nil {
+			return fmt.Errorf("parsing annotation %s: %w", constants.AnnotationSeccompProfile, err)
+		}
+		if w.EnableRestrictedPodSecurity {
+			if err := checkRestrictedSeccompProfile(profile); err != nil {
+				return fmt.Errorf("parsing annotation %s: %w", constants.AnnotationSeccompProfile, err)
+			}
+		}
+		if container.SecurityContext == nil {
+			container.SecurityContext = &corev1.SecurityContext{}
+		}
+		container.SecurityContext.SeccompProfile = profile
+	}
+
+	seLinuxOptions := w.DefaultSELinuxOptions
+	if raw, ok := pod.Annotations[constants.AnnotationSELinuxOptions]; ok {
+		seLinuxOptions = raw
+	}
+	if seLinuxOptions != "" {
+		options, err := ParseSELinuxOptions(seLinuxOptions)
+		if err != nil {
+			return fmt.Errorf("parsing annotation %s: %w", constants.AnnotationSELinuxOptions, err)
+		}
+		if w.EnableRestrictedPodSecurity {
+			if err := checkRestrictedSELinuxOptions(options); err != nil {
+				return fmt.Errorf("parsing annotation %s: %w", constants.AnnotationSELinuxOptions, err)
+			}
+		}
+		if container.SecurityContext == nil {
+			container.SecurityContext = &corev1.SecurityContext{}
+		}
+		container.SecurityContext.SELinuxOptions = options
+	}
+	return nil
+}
+
+// appArmorAnnotations sets the AppArmor profile of the injected init and sidecar containers from the pod's
+// consul.hashicorp.com/apparmor-profile annotation, or the webhook's default if the pod doesn't set it.
+// AppArmor profiles can only be set with pod annotations in the Kubernetes versions we support.
+func (w *MeshWebhook) appArmorAnnotations(pod *corev1.Pod) error {
+	profile := w.DefaultAppArmorProfile
+	if raw, ok := pod.Annotations[constants.AnnotationAppArmorProfile]; ok {
+		profile = raw
+	}
+	if profile == "" {
+		return nil
+	}
+	if err := ValidateAppArmorProfile(profile); err != nil {
+		return fmt.Errorf("parsing annotation %s: %w", constants.AnnotationAppArmorProfile, err)
+	}
+	if w.EnableRestrictedPodSecurity {
+		if err := checkRestrictedAppArmorProfile(profile); err != nil {
+			return fmt.Errorf("parsing annotation %s: %w", constants.AnnotationAppArmorProfile, err)
+		}
+	}
+
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, c := range containers {
+			if isInjectedContainer(c.Name) {
+				pod.Annotations[appArmorAnnotationPrefix+c.Name] = profile
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestParseSeccompProfile(t *testing.T) {
+	cases := map[string]struct {
+		profile string
+		exp     *corev1.SeccompProfile
+		expErr  string
+	}{
+		"runtime default": {
+			profile: "runtime/default",
+			exp:     &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+		},
+		"unconfined": {
+			profile: "unconfined",
+			exp:     &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined},
+		},
+		"localhost": {
+			profile: "localhost/profiles/envoy.json",
+			exp:     &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeLocalhost, LocalhostProfile: pointer.String("profiles/envoy.json")},
+		},
+		"localhost without a profile": {
+			profile: "localhost/",
+			expErr:  `invalid seccomp profile "localhost/": must be runtime/default, unconfined or localhost/<profile>`,
+		},
+		"security context type": {
+			profile: "RuntimeDefault",
+			expErr:  `invalid seccomp profile "RuntimeDefault": must be runtime/default, unconfined or localhost/<profile>`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			profile, err := ParseSeccompProfile(c.profile)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, profile)
+		})
+	}
+}
+
+func TestParseSELinuxOptions(t *testing.T) {
+	cases := map[string]struct {
+		options string
+		exp     *corev1.SELinuxOptions
+		expErr  string
+	}{
+		"type": {
+			options: "type=spc_t",
+			exp:     &corev1.SELinuxOptions{Type: "spc_t"},
+		},
+		"all options": {
+			options: "user=system_u, role=system_r, type=container_t, level=s0:c123",
+			exp:     &corev1.SELinuxOptions{User: "system_u", Role: "system_r", Type: "container_t", Level: "s0:c123"},
+		},
+		"level with multiple categories": {
+			options: "level=s0:c123,c456,type=container_t",
+			exp:     &corev1.SELinuxOptions{Type: "container_t", Level: "s0:c123,c456"},
+		},
+		"unknown option": {
+			options: "kind=spc_t",
+			expErr:  `invalid SELinux options "kind=spc_t": unknown option "kind", must be one of user, role, type or level`,
+		},
+		"no key": {
+			options: "spc_t",
+			expErr:  `invalid SELinux options "spc_t": "spc_t" must be in the format <key>=<value>`,
+		},
+		"duplicate option": {
+			options: "type=spc_t,type=container_t",
+			expErr:  `invalid SELinux options "type=spc_t,type=container_t": type is set more than once`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			options, err := ParseSELinuxOptions(c.options)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, options)
+		})
+	}
+}
+
+func TestHandlerSecurityProfiles(t *testing.T) {
+	cases := map[string]struct {
+		defaultSeccomp   string
+		defaultSELinux   string
+		annotations      map[string]string
+		expSeccomp       *corev1.SeccompProfile
+		expSELinux       *corev1.SELinuxOptions
+		expErr           string
+		restrictedPodSec bool
+	}{
+		"not set": {},
+		"defaults": {
+			defaultSeccomp: "runtime/default",
+			defaultSELinux: "type=spc_t",
+			expSeccomp:     &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			expSELinux:     &corev1.SELinuxOptions{Type: "spc_t"},
+		},
+		"annotations override defaults": {
+			defaultSeccomp: "runtime/default",
+			defaultSELinux: "type=spc_t",
+			annotations: map[string]string{
+				constants.AnnotationSeccompProfile: "localhost/envoy.json",
+				constants.AnnotationSELinuxOptions: "type=container_t,level=s0:c1",
+			},
+			expSeccomp: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeLocalhost, LocalhostProfile: pointer.String("envoy.json")},
+			expSELinux: &corev1.SELinuxOptions{Type: "container_t", Level: "s0:c1"},
+		},
+		"annotation overrides the seccomp profile of restricted pod security": {
+			restrictedPodSec: true,
+			annotations: map[string]string{
+				constants.AnnotationSeccompProfile: "localhost/envoy.json",
+			},
+			expSeccomp: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeLocalhost, LocalhostProfile: pointer.String("envoy.json")},
+		},
+		"unconfined seccomp profile with restricted pod security": {
+			restrictedPodSec: true,
+			annotations: map[string]string{
+				constants.AnnotationSeccompProfile: "unconfined",
+			},
+			expErr: `parsing annotation consul.hashicorp.com/seccomp-profile: seccomp profile "unconfined" is not allowed when injected containers must comply with the restricted Pod Security Standard`,
+		},
+		"default SELinux type with restricted pod security": {
+			restrictedPodSec: true,
+			defaultSELinux:   "type=spc_t",
+			expErr:           `parsing annotation consul.hashicorp.com/selinux-options: SELinux type "spc_t" is not allowed when injected containers must comply with the restricted Pod Security Standard`,
+		},
+		"SELinux user with restricted pod security": {
+			restrictedPodSec: true,
+			annotations: map[string]string{
+				constants.AnnotationSELinuxOptions: "user=system_u,type=container_t",
+			},
+			expErr: `parsing annotation consul.hashicorp.com/selinux-options: SELinux user "system_u" is not allowed when injected containers must comply with the restricted Pod Security Standard`,
+		},
+		"SELinux options allowed by restricted pod security": {
+			restrictedPodSec: true,
+			annotations: map[string]string{
+				constants.AnnotationSELinuxOptions: "type=container_t,level=s0:c1",
+			},
+			expSeccomp: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			expSELinux: &corev1.SELinuxOptions{Type: "container_t", Level: "s0:c1"},
+		},
+		"invalid annotation": {
+			annotations: map[string]string{
+				constants.AnnotationSeccompProfile: "default",
+			},
+			expErr: `parsing annotation consul.hashicorp.com/seccomp-profile: invalid seccomp profile "default": must be runtime/default, unconfined or localhost/<profile>`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				DefaultSeccompProfile:       c.defaultSeccomp,
+				DefaultSELinuxOptions:       c.defaultSELinux,
+				EnableRestrictedPodSecurity: c.restrictedPodSec,
+				ConsulAddress:               "1.1.1.1",
+				ConsulConfig:                &consul.Config{HTTPPort: 8500, GRPCPort: 8502},
+			}
+			pod := minimal()
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+
+			initContainer, err := w.containerInit(testNS, *pod, multiPortInfo{})
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			sidecar, err := w.consulDataplaneSidecar(testNS, *pod, multiPortInfo{})
+			require.NoError(t, err)
+
+			for _, container := range []corev1.Container{initContainer, sidecar} {
+				if container.SecurityContext == nil {
+					require.Nil(t, c.expSeccomp)
+					require.Nil(t, c.expSELinux)
+					continue
+				}
+				require.Equal(t, c.expSeccomp, container.SecurityContext.SeccompProfile)
+				require.Equal(t, c.expSELinux, container.SecurityContext.SELinuxOptions)
+			}
+		})
+	}
+}
+
+func TestHandlerAppArmorAnnotations(t *testing.T) {
+	cases := map[string]struct {
+		defaultProfile string
+		annotation     string
+		restricted     bool
+		expProfile     string
+		expErr         string
+	}{
+		"not set": {},
+		"default": {
+			defaultProfile: "runtime/default",
+			expProfile:     "runtime/default",
+		},
+		"annotation overrides default": {
+			defaultProfile: "runtime/default",
+			annotation:     "localhost/consul-dataplane",
+			expProfile:     "localhost/consul-dataplane",
+		},
+		"unconfined with restricted pod security": {
+			restricted: true,
+			annotation: "unconfined",
+			expErr:     `parsing annotation consul.hashicorp.com/apparmor-profile: AppArmor profile "unconfined" is not allowed when injected containers must comply with the restricted Pod Security Standard`,
+		},
+		"runtime default with restricted pod security": {
+			restricted: true,
+			annotation: "runtime/default",
+			expProfile: "runtime/default",
+		},
+		"invalid annotation": {
+			annotation: "default",
+			expErr:     `parsing annotation consul.hashicorp.com/apparmor-profile: invalid AppArmor profile "default": must be runtime/default, unconfined or localhost/<profile>`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{DefaultAppArmorProfile: c.defaultProfile, EnableRestrictedPodSecurity: c.restricted}
+			pod := minimal()
+			if c.annotation != "" {
+				pod.Annotations[constants.AnnotationAppArmorProfile] = c.annotation
+			}
+			pod.Spec.InitContainers = []corev1.Container{{Name: "app-init"}, {Name: injectInitContainerName + "-web"}}
+			pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: sidecarContainer + "-web"})
+
+			err := w.appArmorAnnotations(pod)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+
+			for _, name := range []string{injectInitContainerName + "-web", sidecarContainer + "-web"} {
+				profile, ok := pod.Annotations[appArmorAnnotationPrefix+name]
+				require.Equal(t, c.expProfile != "", ok)
+				require.Equal(t, c.expProfile, profile)
+			}
+			// The application's containers aren't changed.
+			for _, container := range append(pod.Spec.Containers[:len(pod.Spec.Containers)-1], pod.Spec.InitContainers[0]) {
+				require.NotContains(t, pod.Annotations, appArmorAnnotationPrefix+container.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// serverConfigKey is the key of the server config map that holds the config the Helm chart manages.
+	serverConfigKey = "server.json"
+	// serverExtraConfigKey is the key of the server config map that holds the server.extraConfig Helm value.
+	serverExtraConfigKey = "extra-from-values.json"
+)
+
+// serverManagedConfig are the paths of server config settings that the Helm chart manages. Other
+// components and the server statefulset itself rely on them, so extra config must not override them.
+var serverManagedConfig = [][]string{
+	{"server"},
+	{"datacenter"},
+	{"data_dir"},
+	{"domain"},
+	{"ports", "grpc"},
+	{"ports", "grpc_tls"},
+	{"ports", "serf_lan"},
+}
+
+// ServerConfigWebhook validates the extra config of the Consul servers before it's written to the
+// server config map. Servers load the config map when they start, so a config that Consul can't load
+// would only surface when the servers are next restarted and would then stop all of them from starting.
+type ServerConfigWebhook struct {
+	Log logr.Logger
+
+	// ConfigMapName is the name of the server config map. Other config maps are allowed as they are.
+	ConfigMapName string
+
+	decoder *admission.Decoder
+}
+
+func (w *ServerConfigWebhook) Handle(_ context.Context, req admission.Request) admission.Response {
+	var configMap corev1.ConfigMap
+	if err := w.decoder.Decode(req, &configMap); err != nil {
+		w.Log.Error(err, "could not unmarshal request to config map")
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if configMap.Name != w.ConfigMapName {
+		return admission.Allowed(fmt.Sprintf("%s is not the server config map", configMap.Name))
+	}
+
+	extraConfig, ok := configMap.Data[serverExtraConfigKey]
+	if !ok {
+		return admission.Allowed("server config map has no extra config")
+	}
+	if err := validateServerExtraConfig(configMap.Data[serverConfigKey], extraConfig); err != nil {
+		w.Log.Info("denying server config map", "name", configMap.Name, "err", err.Error())
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	return admission.Allowed("valid server extra config")
+}
+
+func (w *ServerConfigWebhook) InjectDecoder(d *admission.Decoder) error {
+	w.decoder = d
+	return nil
+}
+
+// validateServerExtraConfig returns an error if the extra config isn't a JSON object, or if it sets
+// settings that conflict with the server config the Helm chart manages.
+func validateServerExtraConfig(serverConfig, extraConfig string) error {
+	var extra map[string]interface{}
+	if err := json.Unmarshal([]byte(extraConfig), &extra); err != nil {
+		return fmt.Errorf("server.extraConfig must be a JSON object: %s", err)
+	}
+
+	// The server config is rendered by the chart, so if it can't be parsed there's nothing the
+	// extra config can be checked against.
+	var managed map[string]interface{}
+	if err := json.Unmarshal([]byte(serverConfig), &managed); err != nil {
+		return nil
+	}
+
+	var errs []string
+	for _, path := range serverManagedConfig {
+		extraValue, ok := lookupConfig(extra, path)
+		if !ok {
+			continue
+		}
+		managedValue, ok := lookupConfig(managed, path)
+		if !ok || reflect.DeepEqual(extraValue, managedValue) {
+			continue
+		}
+		errs = append(errs, fmt.Sprintf("%q is managed by the Helm chart and must not be overridden (chart value %v, extra config value %v)",
+			strings.Join(path, "."), managedValue, extraValue))
+	}
+	if bootstrap, ok := extra["bootstrap"]; ok && bootstrap == true {
+		if _, ok := managed["bootstrap_expect"]; ok {
+			errs = append(errs, `"bootstrap" can't be set together with "bootstrap_expect", which is set by the Helm chart`)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("server.extraConfig is invalid: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// lookupConfig returns the value at the path of nested config objects.
+func lookupConfig(config map[string]interface{}, path []string) (interface{}, bool) {
+	var value interface{} = config
+	for _, key := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
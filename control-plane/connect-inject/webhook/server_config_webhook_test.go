@@ -0,0 +1,120 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestServerConfigWebhook_Handle(t *testing.T) {
+	s := runtime.NewScheme()
+	s.AddKnownTypes(schema.GroupVersion{Group: "", Version: "v1"}, &corev1.ConfigMap{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(t, err)
+
+	const serverConfig = `{
+  "bootstrap_expect": 3,
+  "datacenter": "dc1",
+  "data_dir": "/consul/data",
+  "domain": "consul",
+  "ports": {
+    "grpc": -1,
+    "grpc_tls": 8502,
+    "serf_lan": 8301
+  },
+  "server": true
+}`
+
+	cases := map[string]struct {
+		name        string
+		extraConfig *string
+		expErr      string
+	}{
+		"allows other config maps": {
+			name:        "other-config",
+			extraConfig: pointer.String("{"),
+		},
+		"allows a config map without extra config": {
+			name: "consul-server-config",
+		},
+		"allows empty extra config": {
+			name:        "consul-server-config",
+			extraConfig: pointer.String("{}"),
+		},
+		"allows extra config that doesn't override managed settings": {
+			name:        "consul-server-config",
+			extraConfig: pointer.String(`{"log_level": "DEBUG", "ports": {"https": 8501}, "bootstrap_expect": 5}`),
+		},
+		"allows extra config that sets managed settings to the chart values": {
+			name:        "consul-server-config",
+			extraConfig: pointer.String(`{"datacenter": "dc1", "ports": {"serf_lan": 8301}, "server": true}`),
+		},
+		"denies invalid JSON": {
+			name:        "consul-server-config",
+			extraConfig: pointer.String(`{"log_level": "DEBUG",}`),
+			expErr:      "server.extraConfig must be a JSON object: invalid character '}'",
+		},
+		"denies extra config that isn't an object": {
+			name:        "consul-server-config",
+			extraConfig: pointer.String(`["log_level"]`),
+			expErr:      "server.extraConfig must be a JSON object",
+		},
+		"denies extra config that overrides managed settings": {
+			name:        "consul-server-config",
+			extraConfig: pointer.String(`{"datacenter": "dc2", "ports": {"grpc_tls": 8503}}`),
+			expErr: `server.extraConfig is invalid: "datacenter" is managed by the Helm chart and must not be overridden (chart value dc1, extra config value dc2); ` +
+				`"ports.grpc_tls" is managed by the Helm chart and must not be overridden (chart value 8502, extra config value 8503)`,
+		},
+		"denies extra config that turns servers into clients": {
+			name:        "consul-server-config",
+			extraConfig: pointer.String(`{"server": false}`),
+			expErr:      `"server" is managed by the Helm chart and must not be overridden`,
+		},
+		"denies bootstrap mode": {
+			name:        "consul-server-config",
+			extraConfig: pointer.String(`{"bootstrap": true}`),
+			expErr:      `"bootstrap" can't be set together with "bootstrap_expect"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: c.name, Namespace: "consul"},
+				Data:       map[string]string{serverConfigKey: serverConfig},
+			}
+			if c.extraConfig != nil {
+				configMap.Data[serverExtraConfigKey] = *c.extraConfig
+			}
+			w := ServerConfigWebhook{
+				Log:           logrtest.New(t),
+				ConfigMapName: "consul-server-config",
+				decoder:       decoder,
+			}
+			resp := w.Handle(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: "consul",
+					Operation: admissionv1.Update,
+					Object:    encodeRaw(t, configMap),
+				},
+			})
+			if c.expErr != "" {
+				require.False(t, resp.Allowed)
+				require.Contains(t, resp.Result.Message, c.expErr)
+				return
+			}
+			require.True(t, resp.Allowed, resp.Result.Message)
+		})
+	}
+}
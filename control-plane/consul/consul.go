@@ -5,7 +5,9 @@ package consul
 
 import (
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/hashicorp/consul-k8s/control-plane/version"
@@ -66,6 +68,10 @@ type Config struct {
 	HTTPPort        int
 	GRPCPort        int
 	APITimeout      time.Duration
+	// AllowStale allows reads, e.g. of the catalog, config entries and health
+	// checks, to be answered by any Consul server rather than only the leader.
+	// Writes are always made on the leader.
+	AllowStale bool
 }
 
 // todo (ishustava): replace all usages of this one.
@@ -73,7 +79,7 @@ type Config struct {
 // of the consul-server-connection-manager.
 func NewClientFromConnMgrState(config *Config, state discovery.State) (*capi.Client, error) {
 	ipAddress := state.Address.IP
-	config.APIClientConfig.Address = fmt.Sprintf("%s:%d", ipAddress.String(), config.HTTPPort)
+	config.APIClientConfig.Address = net.JoinHostPort(ipAddress.String(), strconv.Itoa(config.HTTPPort))
 	if state.Token != "" {
 		config.APIClientConfig.Token = state.Token
 	}
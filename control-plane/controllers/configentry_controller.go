@@ -13,6 +13,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/correlation"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	capi "github.com/hashicorp/consul/api"
 	"golang.org/x/time/rate"
@@ -32,6 +33,7 @@ const (
 	ConsulAgentError             = "ConsulAgentError"
 	ExternallyManagedConfigError = "ExternallyManagedConfigError"
 	MigrationFailedError         = "MigrationFailedError"
+	DestinationHasInstancesError = "DestinationHasInstancesError"
 )
 
 // Controller is implemented by CRD-specific controllers. It is used by
@@ -87,6 +89,23 @@ type ConfigEntryController struct {
 	// any created Consul namespaces to allow cross namespace service discovery.
 	// Only necessary if ACLs are enabled.
 	CrossNSACLPolicy string
+
+	// MaxConcurrentReconciles is the number of resources of each kind that are
+	// reconciled at once, keyed by the kind, e.g. serviceintentions. Kinds that
+	// aren't set use DefaultMaxConcurrentReconciles.
+	MaxConcurrentReconciles map[string]int
+
+	// DefaultMaxConcurrentReconciles is the number of resources of each kind
+	// that are reconciled at once unless it's overridden for the kind. If it's
+	// zero, resources are reconciled one at a time.
+	DefaultMaxConcurrentReconciles int
+
+	// FinalizerTimeout is how long after the deletion of a resource is requested
+	// its finalizer is removed even if the config entry couldn't be deleted from
+	// Consul, so that an unreachable Consul doesn't block the deletion of the
+	// resource and its namespace. If it's zero, the finalizer is only removed
+	// once the config entry is deleted from Consul.
+	FinalizerTimeout time.Duration
 }
 
 // ReconcileEntry reconciles an update to a resource. CRD-specific controller's
@@ -96,7 +115,8 @@ type ConfigEntryController struct {
 // need to call back into their own update methods to ensure they update their
 // internal state.
 func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Controller, req ctrl.Request, configEntry common.ConfigEntryResource) (ctrl.Result, error) {
-	logger := crdCtrl.Logger(req.NamespacedName)
+	correlationID := correlation.FromContext(ctx)
+	logger := crdCtrl.Logger(req.NamespacedName).WithValues(correlation.LogKey, correlationID)
 	err := crdCtrl.Get(ctx, req.NamespacedName, configEntry)
 	if k8serr.IsNotFound(err) {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
@@ -105,13 +125,18 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 		return ctrl.Result{}, err
 	}
 
-	// Create Consul client for this reconcile.
-	serverState, err := r.ConsulServerConnMgr.State()
-	if err != nil {
-		logger.Error(err, "failed to get Consul server state", "name", req.Name, "ns", req.Namespace)
-		return ctrl.Result{}, err
+	if !configEntry.GetDeletionTimestamp().IsZero() {
+		// The object is being deleted
+		if containsString(configEntry.GetFinalizers(), FinalizerName) {
+			return r.finalize(ctx, logger, crdCtrl, configEntry, correlationID)
+		}
+
+		// Stop reconciliation as the item is being deleted
+		return ctrl.Result{}, nil
 	}
-	consulClient, err := consul.NewClientFromConnMgrState(r.ConsulClientConfig, serverState)
+
+	// Create Consul client for this reconcile.
+	consulClient, err := r.consulClient(correlationID)
 	if err != nil {
 		logger.Error(err, "failed to create Consul API client", "name", req.Name, "ns", req.Namespace)
 		return ctrl.Result{}, err
@@ -119,59 +144,27 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 
 	consulEntry := configEntry.ToConsul(r.DatacenterName)
 
-	if configEntry.GetDeletionTimestamp().IsZero() {
-		// The object is not being deleted, so if it does not have our finalizer,
-		// then let's add the finalizer and update the object. This is equivalent
-		// registering our finalizer.
-		if !containsString(configEntry.GetFinalizers(), FinalizerName) {
-			configEntry.AddFinalizer(FinalizerName)
-			if err := r.syncUnknown(ctx, crdCtrl, configEntry); err != nil {
-				return ctrl.Result{}, err
-			}
-		}
-	} else {
-		// The object is being deleted
-		if containsString(configEntry.GetFinalizers(), FinalizerName) {
-			logger.Info("deletion event")
-			// Check to see if consul has config entry with the same name
-			entry, _, err := consulClient.ConfigEntries().Get(configEntry.ConsulKind(), configEntry.ConsulName(), &capi.QueryOptions{
-				Namespace: r.consulNamespace(consulEntry, configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource()),
-			})
-
-			// Ignore the error where the config entry isn't found in Consul.
-			// It is indicative of desired state.
-			if err != nil && !isNotFoundErr(err) {
-				return ctrl.Result{}, fmt.Errorf("getting config entry from consul: %w", err)
-			} else if err == nil {
-				// Only delete the resource from Consul if it is owned by our datacenter.
-				if entry.GetMeta()[common.DatacenterKey] == r.DatacenterName {
-					_, err := consulClient.ConfigEntries().Delete(configEntry.ConsulKind(), configEntry.ConsulName(), &capi.WriteOptions{
-						Namespace: r.consulNamespace(consulEntry, configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource()),
-					})
-					if err != nil {
-						return r.syncFailed(ctx, logger, crdCtrl, configEntry, ConsulAgentError,
-							fmt.Errorf("deleting config entry from consul: %w", err))
-					}
-					logger.Info("deletion from Consul successful")
-				} else {
-					logger.Info("config entry in Consul was created in another datacenter - skipping delete from Consul", "external-datacenter", entry.GetMeta()[common.DatacenterKey])
-				}
-			}
-			// remove our finalizer from the list and update it.
-			configEntry.RemoveFinalizer(FinalizerName)
-			if err := crdCtrl.Update(ctx, configEntry); err != nil {
-				return ctrl.Result{}, err
-			}
-			logger.Info("finalizer removed")
+	// The object is not being deleted, so if it does not have our finalizer,
+	// then let's add the finalizer and update the object. This is equivalent
+	// registering our finalizer.
+	if !containsString(configEntry.GetFinalizers(), FinalizerName) {
+		configEntry.AddFinalizer(FinalizerName)
+		if err := r.syncUnknown(ctx, crdCtrl, configEntry); err != nil {
+			return ctrl.Result{}, err
 		}
+	}
 
-		// Stop reconciliation as the item is being deleted
-		return ctrl.Result{}, nil
+	// Destinations live outside of Consul's catalog, so a service that
+	// defines a destination must not also have registered instances.
+	if err := validateDestinationHasNoInstances(consulClient, consulEntry, r.consulNamespace(consulEntry, configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource()), r.ConsulClientConfig.APIClientConfig.Partition); err != nil {
+		return r.syncFailed(ctx, logger, crdCtrl, configEntry, DestinationHasInstancesError, err)
 	}
 
-	// Check to see if consul has config entry with the same name
+	// Check to see if consul has config entry with the same name. The read
+	// decides whether the entry is created or updated, so it must be consistent.
 	entry, _, err := consulClient.ConfigEntries().Get(configEntry.ConsulKind(), configEntry.ConsulName(), &capi.QueryOptions{
-		Namespace: r.consulNamespace(consulEntry, configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource()),
+		Namespace:         r.consulNamespace(consulEntry, configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource()),
+		RequireConsistent: true,
 	})
 	// If a config entry with this name does not exist
 	if isNotFoundErr(err) {
@@ -280,10 +273,99 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 	return ctrl.Result{}, nil
 }
 
+// consulClient creates a Consul API client for a reconcile.
+func (r *ConfigEntryController) consulClient(correlationID string) (*capi.Client, error) {
+	serverState, err := r.ConsulServerConnMgr.State()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Consul server state: %w", err)
+	}
+	consulClient, err := consul.NewClientFromConnMgrState(r.ConsulClientConfig, serverState)
+	if err != nil {
+		return nil, err
+	}
+	correlation.SetConsulHeader(consulClient, correlationID)
+	return consulClient, nil
+}
+
+// finalize deletes the config entry from Consul, unless the resource's deletion policy is abandon,
+// and then removes our finalizer. If the config entry can't be deleted from Consul for FinalizerTimeout
+// after the deletion of the resource was requested, e.g. because Consul is unreachable, the finalizer
+// is removed anyway so that the resource, and its namespace, can still be deleted.
+func (r *ConfigEntryController) finalize(ctx context.Context, logger logr.Logger, crdCtrl Controller, configEntry common.ConfigEntryResource, correlationID string) (ctrl.Result, error) {
+	logger.Info("deletion event")
+	if configEntry.GetObjectMeta().Annotations[common.DeletionPolicyKey] == common.DeletionPolicyAbandon {
+		logger.Info("deletion policy is abandon - skipping delete from Consul")
+	} else if err := r.deleteFromConsul(logger, configEntry, correlationID); err != nil {
+		if r.FinalizerTimeout <= 0 || time.Since(configEntry.GetDeletionTimestamp().Time) < r.FinalizerTimeout {
+			return r.syncFailed(ctx, logger, crdCtrl, configEntry, ConsulAgentError, err)
+		}
+		logger.Error(err, "unable to delete config entry from Consul within the finalizer timeout - removing finalizer", "finalizer-timeout", r.FinalizerTimeout)
+	}
+
+	// remove our finalizer from the list and update it.
+	configEntry.RemoveFinalizer(FinalizerName)
+	if err := crdCtrl.Update(ctx, configEntry); err != nil {
+		return ctrl.Result{}, err
+	}
+	logger.Info("finalizer removed")
+	return ctrl.Result{}, nil
+}
+
+// deleteFromConsul deletes the config entry from Consul if it's owned by our datacenter.
+func (r *ConfigEntryController) deleteFromConsul(logger logr.Logger, configEntry common.ConfigEntryResource, correlationID string) error {
+	consulClient, err := r.consulClient(correlationID)
+	if err != nil {
+		return err
+	}
+	consulEntry := configEntry.ToConsul(r.DatacenterName)
+	consulNS := r.consulNamespace(consulEntry, configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource())
+
+	// Check to see if consul has config entry with the same name. A stale
+	// not-found would skip the delete and remove the finalizer, leaving the
+	// config entry in Consul, so this read is consistent.
+	entry, _, err := consulClient.ConfigEntries().Get(configEntry.ConsulKind(), configEntry.ConsulName(), &capi.QueryOptions{
+		Namespace:         consulNS,
+		RequireConsistent: true,
+	})
+	// Ignore the error where the config entry isn't found in Consul.
+	// It is indicative of desired state.
+	if isNotFoundErr(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("getting config entry from consul: %w", err)
+	}
+
+	// Only delete the resource from Consul if it is owned by our datacenter.
+	if entry.GetMeta()[common.DatacenterKey] != r.DatacenterName {
+		logger.Info("config entry in Consul was created in another datacenter - skipping delete from Consul", "external-datacenter", entry.GetMeta()[common.DatacenterKey])
+		return nil
+	}
+	if _, err := consulClient.ConfigEntries().Delete(configEntry.ConsulKind(), configEntry.ConsulName(), &capi.WriteOptions{
+		Namespace: consulNS,
+	}); err != nil {
+		return fmt.Errorf("deleting config entry from consul: %w", err)
+	}
+	logger.Info("deletion from Consul successful")
+	return nil
+}
+
+// maxConcurrentReconciles returns the number of resources of the kind that are
+// reconciled at once.
+func (r *ConfigEntryController) maxConcurrentReconciles(kind string) int {
+	if n, ok := r.MaxConcurrentReconciles[kind]; ok {
+		return n
+	}
+	if r.DefaultMaxConcurrentReconciles > 0 {
+		return r.DefaultMaxConcurrentReconciles
+	}
+	return 1
+}
+
 // setupWithManager sets up the controller manager for the given resource
 // with our default options.
-func setupWithManager(mgr ctrl.Manager, resource client.Object, reconciler reconcile.Reconciler) error {
+func setupWithManager(mgr ctrl.Manager, resource client.Object, reconciler reconcile.Reconciler, maxConcurrentReconciles int) error {
 	options := controller.Options{
+		MaxConcurrentReconciles: maxConcurrentReconciles,
 		// Taken from https://github.com/kubernetes/client-go/blob/master/util/workqueue/default_rate_limiters.go#L39
 		// and modified from a starting backoff of 5ms and max of 1000s to a
 		// starting backoff of 200ms and a max of 5s to better fit our most
@@ -417,6 +499,29 @@ func needsVirtualIPAssignment(datacenterName string, configEntry common.ConfigEn
 	return false
 }
 
+// validateDestinationHasNoInstances returns an error if the config entry is a
+// service-defaults with a destination and the service has instances registered
+// in the Consul catalog of the namespace and partition. The catalog is read
+// consistently, since the result decides whether the config entry is written.
+func validateDestinationHasNoInstances(consulClient *capi.Client, consulEntry capi.ConfigEntry, consulNS, partition string) error {
+	svcDefaults, ok := consulEntry.(*capi.ServiceConfigEntry)
+	if !ok || svcDefaults.Destination == nil {
+		return nil
+	}
+	instances, _, err := consulClient.Catalog().Service(svcDefaults.Name, "", &capi.QueryOptions{
+		Namespace:         consulNS,
+		Partition:         partition,
+		RequireConsistent: true,
+	})
+	if err != nil {
+		return fmt.Errorf("checking for registered instances of service %q: %w", svcDefaults.Name, err)
+	}
+	if len(instances) > 0 {
+		return fmt.Errorf("service %q has %d registered instance(s) and cannot also define a destination", svcDefaults.Name, len(instances))
+	}
+	return nil
+}
+
 // assignServiceVirtualIPs manually sends the ClusterIP for a matching service for ServiceRouter or ServiceSplitter
 // CRDs to Consul so that it can be added to the virtual IP table. The assignment is skipped if the matching service
 // does not exist or if an older version of Consul is being used. Endpoints Controller, on service registration, also
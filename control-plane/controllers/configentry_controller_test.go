@@ -5,7 +5,11 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -17,6 +21,7 @@ import (
 	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/test"
+	"github.com/hashicorp/consul-server-connection-manager/discovery"
 	capi "github.com/hashicorp/consul/api"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
@@ -1549,6 +1554,103 @@ func TestConfigEntryControllers_errorUpdatesSyncStatus(t *testing.T) {
 	req.Contains(errMsg, expErr)
 }
 
+// Test that a service-defaults with a destination is not written to Consul
+// when the service has registered instances.
+func TestConfigEntryControllers_destinationWithRegisteredInstances(t *testing.T) {
+	t.Parallel()
+	kubeNS := "default"
+
+	req := require.New(t)
+	ctx := context.Background()
+	svcDefaults := &v1alpha1.ServiceDefaults{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: kubeNS,
+		},
+		Spec: v1alpha1.ServiceDefaultsSpec{
+			Destination: &v1alpha1.ServiceDefaultsDestination{
+				Addresses: []string{"api.google.com"},
+				Port:      443,
+			},
+		},
+	}
+
+	s := runtime.NewScheme()
+	s.AddKnownTypes(v1alpha1.GroupVersion, svcDefaults)
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(svcDefaults).Build()
+
+	testClient := test.TestServerWithMockConnMgrWatcher(t, nil)
+	testClient.TestServer.WaitForServiceIntentions(t)
+	consulClient := testClient.APIClient
+
+	// Register an instance of the service in Consul.
+	_, err := consulClient.Catalog().Register(&capi.CatalogRegistration{
+		Node:    "test-node",
+		Address: "127.0.0.1",
+		Service: &capi.AgentService{
+			ID:      "foo-1",
+			Service: "foo",
+			Port:    8080,
+		},
+	}, nil)
+	req.NoError(err)
+
+	reconciler := &ServiceDefaultsController{
+		Client: fakeClient,
+		Log:    logrtest.New(t),
+		ConfigEntryController: &ConfigEntryController{
+			ConsulClientConfig:  testClient.Cfg,
+			ConsulServerConnMgr: testClient.Watcher,
+			DatacenterName:      datacenterName,
+		},
+	}
+
+	namespacedName := types.NamespacedName{
+		Namespace: kubeNS,
+		Name:      svcDefaults.KubernetesName(),
+	}
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{
+		NamespacedName: namespacedName,
+	})
+	req.EqualError(err, `service "foo" has 1 registered instance(s) and cannot also define a destination`)
+
+	// The config entry should not have been written to Consul.
+	_, _, err = consulClient.ConfigEntries().Get(capi.ServiceDefaults, "foo", nil)
+	req.Error(err)
+	req.Contains(err.Error(), "404")
+
+	// Check that the status is "synced=false".
+	err = fakeClient.Get(ctx, namespacedName, svcDefaults)
+	req.NoError(err)
+	status, reason, _ := svcDefaults.SyncedCondition()
+	req.Equal(corev1.ConditionFalse, status)
+	req.Equal(DestinationHasInstancesError, reason)
+}
+
+// Test that the instances of a service with a destination are looked up in
+// the namespace and partition of the config entry.
+func TestValidateDestinationHasNoInstances_NamespaceAndPartition(t *testing.T) {
+	t.Parallel()
+	var query url.Values
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/catalog/service/foo", r.URL.Path)
+		query = r.URL.Query()
+		fmt.Fprint(w, "[]")
+	}))
+	t.Cleanup(consulServer.Close)
+	consulClient, err := capi.NewClient(&capi.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+
+	entry := &capi.ServiceConfigEntry{
+		Kind:        capi.ServiceDefaults,
+		Name:        "foo",
+		Destination: &capi.DestinationConfig{Addresses: []string{"api.google.com"}, Port: 443},
+	}
+	require.NoError(t, validateDestinationHasNoInstances(consulClient, entry, "ns1", "ap1"))
+	require.Equal(t, "ns1", query.Get("ns"))
+	require.Equal(t, "ap1", query.Get("partition"))
+}
+
 // Test that if the config entry hasn't changed in Consul but our resource
 // synced status isn't set to true then we update its status.
 func TestConfigEntryControllers_setsSyncedToTrue(t *testing.T) {
@@ -1783,6 +1885,89 @@ func TestConfigEntryControllers_doesNotDeleteUnownedConfig(t *testing.T) {
 	}
 }
 
+// Test that the finalizer is removed without deleting the config entry from Consul if the
+// deletion policy is abandon, and that it's removed once the finalizer timeout has passed if
+// the config entry can't be deleted from Consul.
+func TestConfigEntryControllers_finalizerWithoutConsul(t *testing.T) {
+	t.Parallel()
+	kubeNS := "default"
+
+	cases := map[string]struct {
+		annotations      map[string]string
+		finalizerTimeout time.Duration
+		deletionAge      time.Duration
+		expErr           string
+	}{
+		"abandon deletion policy": {
+			annotations: map[string]string{common.DeletionPolicyKey: common.DeletionPolicyAbandon},
+		},
+		"consul unreachable without finalizer timeout": {
+			deletionAge: time.Hour,
+			expErr:      "failed to get Consul server state: unreachable",
+		},
+		"consul unreachable within finalizer timeout": {
+			finalizerTimeout: time.Minute,
+			deletionAge:      time.Second,
+			expErr:           "failed to get Consul server state: unreachable",
+		},
+		"consul unreachable after finalizer timeout": {
+			finalizerTimeout: time.Minute,
+			deletionAge:      time.Hour,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			s := runtime.NewScheme()
+			svcDefaults := &v1alpha1.ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "foo",
+					Namespace:         kubeNS,
+					Annotations:       c.annotations,
+					DeletionTimestamp: &metav1.Time{Time: time.Now().Add(-c.deletionAge)},
+					Finalizers:        []string{FinalizerName},
+				},
+				Spec: v1alpha1.ServiceDefaultsSpec{
+					Protocol: "http",
+				},
+			}
+			s.AddKnownTypes(v1alpha1.GroupVersion, svcDefaults)
+			fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(svcDefaults).Build()
+
+			// Consul is unreachable, and must not be called at all with the abandon deletion policy.
+			connMgr := consul.NewMockServerConnectionManager(t)
+			if c.annotations == nil {
+				connMgr.On("State").Return(discovery.State{}, errors.New("unreachable"))
+			}
+			reconciler := &ServiceDefaultsController{
+				Client: fakeClient,
+				Log:    logrtest.New(t),
+				ConfigEntryController: &ConfigEntryController{
+					ConsulClientConfig:  &consul.Config{APIClientConfig: &capi.Config{}},
+					ConsulServerConnMgr: connMgr,
+					DatacenterName:      datacenterName,
+					FinalizerTimeout:    c.finalizerTimeout,
+				},
+			}
+
+			namespacedName := types.NamespacedName{Namespace: kubeNS, Name: svcDefaults.KubernetesName()}
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: namespacedName})
+			// The resource is deleted once the finalizer is removed.
+			updated := &v1alpha1.ServiceDefaults{}
+			_ = fakeClient.Get(ctx, namespacedName, updated)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				require.Equal(t, []string{FinalizerName}, updated.Finalizers())
+				return
+			}
+			require.NoError(t, err)
+			require.Empty(t, updated.Finalizers())
+		})
+	}
+}
+
 func TestConfigEntryControllers_updatesStatusWhenDeleteFails(t *testing.T) {
 	ctx := context.Background()
 	kubeNS := "default"
@@ -2249,3 +2434,43 @@ func TestConfigEntryControllers_assignServiceVirtualIP(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigEntryController_maxConcurrentReconciles(t *testing.T) {
+	cases := map[string]struct {
+		controller *ConfigEntryController
+		kind       string
+		exp        int
+	}{
+		"defaults to one": {
+			controller: &ConfigEntryController{},
+			kind:       common.ServiceIntentions,
+			exp:        1,
+		},
+		"default": {
+			controller: &ConfigEntryController{DefaultMaxConcurrentReconciles: 4},
+			kind:       common.ServiceIntentions,
+			exp:        4,
+		},
+		"overridden for the kind": {
+			controller: &ConfigEntryController{
+				DefaultMaxConcurrentReconciles: 4,
+				MaxConcurrentReconciles:        map[string]int{common.ServiceIntentions: 16},
+			},
+			kind: common.ServiceIntentions,
+			exp:  16,
+		},
+		"overridden for another kind": {
+			controller: &ConfigEntryController{
+				DefaultMaxConcurrentReconciles: 4,
+				MaxConcurrentReconciles:        map[string]int{common.ServiceIntentions: 16},
+			},
+			kind: common.ServiceDefaults,
+			exp:  4,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.exp, c.controller.maxConcurrentReconciles(c.kind))
+		})
+	}
+}
@@ -12,6 +12,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
 	consulv1alpha1 "github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
 )
 
@@ -39,5 +40,5 @@ func (r *ControlPlaneRequestLimitController) UpdateStatus(ctx context.Context, o
 }
 
 func (r *ControlPlaneRequestLimitController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.ControlPlaneRequestLimit{}, r)
+	return setupWithManager(mgr, &consulv1alpha1.ControlPlaneRequestLimit{}, r, r.ConfigEntryController.maxConcurrentReconciles(common.ControlPlaneRequestLimit))
 }
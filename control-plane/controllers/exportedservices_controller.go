@@ -12,6 +12,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
 	consulv1alpha1 "github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
 )
 
@@ -39,5 +40,5 @@ func (r *ExportedServicesController) UpdateStatus(ctx context.Context, obj clien
 }
 
 func (r *ExportedServicesController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.ExportedServices{}, r)
+	return setupWithManager(mgr, &consulv1alpha1.ExportedServices{}, r, r.ConfigEntryController.maxConcurrentReconciles(common.ExportedServices))
 }
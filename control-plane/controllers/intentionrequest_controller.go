@@ -0,0 +1,379 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	consulv1alpha1 "github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+)
+
+const (
+	DestinationNotOwnedError = "DestinationNotOwnedError"
+	ConflictingSourceError   = "ConflictingSourceError"
+)
+
+// serviceIntentionsDestinationIndex is the field index of ServiceIntentions by the name of their
+// destination, so that the ServiceIntentions of a destination are found without listing them all.
+const serviceIntentionsDestinationIndex = "__serviceintentions_destination"
+
+// IntentionRequestController is the controller for IntentionRequest resources. It merges the
+// sources of the IntentionRequests for a destination into the ServiceIntentions of the destination,
+// which is created in the namespace of the requests if it doesn't exist. The ServiceIntentions are
+// then synced to Consul by their own controller.
+//
+// A destination is owned by a namespace if it has a Kubernetes service with the destination's name.
+// When Kubernetes namespaces aren't mirrored to Consul namespaces, services of the same name in
+// different namespaces are the same Consul service, so a destination that has a service in another
+// namespace isn't owned by either of them.
+//
+// The sources that were added from IntentionRequests are recorded in an annotation on the
+// ServiceIntentions, so that the sources written by users are never changed. Requested sources that
+// users already granted or denied are reported in the request's Synced condition instead.
+//
+// Requests are reconciled per destination. The name of a reconcile request is the name of the
+// destination, not the name of an IntentionRequest.
+type IntentionRequestController struct {
+	client.Client
+	// ConsulMeta is the Consul namespace and partition config that requests are validated with and
+	// that destinations are mapped to Consul namespaces with.
+	ConsulMeta common.ConsulMeta
+	Log        logr.Logger
+	Scheme     *runtime.Scheme
+	Context    context.Context
+}
+
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=intentionrequests,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=intentionrequests/status,verbs=get;update;patch
+
+func (r *IntentionRequestController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Log.WithValues("namespace", req.Namespace, "destination", req.Name)
+
+	var list consulv1alpha1.IntentionRequestList
+	if err := r.Client.List(ctx, &list, client.InNamespace(req.Namespace)); err != nil {
+		logger.Error(err, "failed to list IntentionRequests")
+		return ctrl.Result{}, err
+	}
+	var requests []*consulv1alpha1.IntentionRequest
+	for i, request := range list.Items {
+		if request.Spec.Destination == req.Name && request.ObjectMeta.DeletionTimestamp.IsZero() {
+			requests = append(requests, &list.Items[i])
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].Name < requests[j].Name })
+
+	owned, notOwnedMessage, err := r.destinationOwned(ctx, req.Namespace, req.Name)
+	if err != nil {
+		logger.Error(err, "failed to check the owner of the destination")
+		return ctrl.Result{}, err
+	}
+
+	// The sources of requests that are sorted first take precedence over the same sources of
+	// later requests.
+	var sources consulv1alpha1.SourceIntentions
+	requestedBy := make(map[string]string)
+	conditions := make(map[string]intentionRequestCondition)
+	conflicts := make(map[string][]string)
+	for _, request := range requests {
+		if err := request.Validate(r.ConsulMeta); err != nil {
+			conditions[request.Name] = intentionRequestCondition{corev1.ConditionFalse, InvalidSpecError, err.Error()}
+			continue
+		}
+		if !owned {
+			conditions[request.Name] = intentionRequestCondition{corev1.ConditionFalse, DestinationNotOwnedError, notOwnedMessage}
+			continue
+		}
+		for _, src := range request.Spec.Sources {
+			key := consulv1alpha1.IntentionSourceKey(src)
+			if other, ok := requestedBy[key]; ok {
+				conflicts[request.Name] = append(conflicts[request.Name], fmt.Sprintf("%s is requested by IntentionRequest %s", key, other))
+				continue
+			}
+			requestedBy[key] = request.Name
+			sources = append(sources, src.DeepCopy())
+		}
+	}
+
+	userKeys, err := r.applyServiceIntentions(ctx, req.Namespace, req.Name, sources)
+	if err != nil {
+		logger.Error(err, "failed to apply ServiceIntentions")
+		return ctrl.Result{}, err
+	}
+	for key, name := range requestedBy {
+		if userKeys[key] {
+			conflicts[name] = append(conflicts[name], fmt.Sprintf("%s is already a source of the ServiceIntentions", key))
+		}
+	}
+
+	for _, request := range requests {
+		cond, ok := conditions[request.Name]
+		if !ok {
+			cond = intentionRequestCondition{status: corev1.ConditionTrue}
+			if len(conflicts[request.Name]) > 0 {
+				sort.Strings(conflicts[request.Name])
+				cond = intentionRequestCondition{corev1.ConditionFalse, ConflictingSourceError,
+					fmt.Sprintf("sources were not added: %s", strings.Join(conflicts[request.Name], ", "))}
+			}
+		}
+		if err := r.updateStatus(ctx, request, cond); err != nil {
+			logger.Error(err, "failed to update status", "name", request.Name)
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *IntentionRequestController) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(r.Context, &consulv1alpha1.ServiceIntentions{}, serviceIntentionsDestinationIndex, serviceIntentionsDestination); err != nil {
+		return err
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("intention-request").
+		Watches(
+			&source.Kind{Type: &consulv1alpha1.IntentionRequest{}},
+			handler.Funcs{
+				CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+					enqueueDestination(q, e.Object)
+				},
+				UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+					// The sources must be removed from the old destination if it changed.
+					enqueueDestination(q, e.ObjectOld)
+					enqueueDestination(q, e.ObjectNew)
+				},
+				DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+					enqueueDestination(q, e.Object)
+				},
+				GenericFunc: func(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+					enqueueDestination(q, e.Object)
+				},
+			},
+		).
+		Watches(
+			&source.Kind{Type: &consulv1alpha1.ServiceIntentions{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForServiceIntentions),
+		).
+		Watches(
+			&source.Kind{Type: &corev1.Service{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForService),
+		).Complete(r)
+}
+
+// serviceIntentionsDestination is the indexer func of serviceIntentionsDestinationIndex.
+func serviceIntentionsDestination(object client.Object) []string {
+	serviceIntentions, ok := object.(*consulv1alpha1.ServiceIntentions)
+	if !ok {
+		return nil
+	}
+	return []string{serviceIntentions.Spec.Destination.Name}
+}
+
+func enqueueDestination(q workqueue.RateLimitingInterface, object client.Object) {
+	request, ok := object.(*consulv1alpha1.IntentionRequest)
+	if !ok || request.Spec.Destination == "" {
+		return
+	}
+	q.Add(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: request.Namespace, Name: request.Spec.Destination}})
+}
+
+// requestsForServiceIntentions enqueues the destinations of the IntentionRequests that were merged
+// into the ServiceIntentions so that sources that users removed from it are added back.
+func (r *IntentionRequestController) requestsForServiceIntentions(object client.Object) []reconcile.Request {
+	serviceIntentions, ok := object.(*consulv1alpha1.ServiceIntentions)
+	if !ok || serviceIntentions.Annotations[consulv1alpha1.AnnotationIntentionRequestSources] == "" {
+		return nil
+	}
+	return r.requestsForDestination(serviceIntentions.Spec.Destination.Name)
+}
+
+// requestsForService enqueues the destination of the service's name in its namespace, since the
+// destination's owner can change when services are created or deleted.
+func (r *IntentionRequestController) requestsForService(object client.Object) []reconcile.Request {
+	return r.requestsForDestination(object.GetName())
+}
+
+// requestsForDestination returns a request for each namespace that has IntentionRequests for the destination.
+func (r *IntentionRequestController) requestsForDestination(destination string) []reconcile.Request {
+	var list consulv1alpha1.IntentionRequestList
+	if err := r.Client.List(r.Context, &list); err != nil {
+		r.Log.Error(err, "failed to list IntentionRequests")
+		return nil
+	}
+	seen := make(map[string]bool)
+	var requests []reconcile.Request
+	for _, request := range list.Items {
+		if request.Spec.Destination != destination || seen[request.Namespace] {
+			continue
+		}
+		seen[request.Namespace] = true
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: request.Namespace, Name: destination},
+		})
+	}
+	return requests
+}
+
+// destinationOwned returns whether the destination is owned by the namespace, and a message that
+// explains why if it isn't.
+func (r *IntentionRequestController) destinationOwned(ctx context.Context, namespace, destination string) (bool, string, error) {
+	var svc corev1.Service
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: destination}, &svc)
+	if k8serrors.IsNotFound(err) {
+		return false, fmt.Sprintf("destination %q is not a service in namespace %q", destination, namespace), nil
+	} else if err != nil {
+		return false, "", err
+	}
+	if r.ConsulMeta.NamespacesEnabled && r.ConsulMeta.Mirroring {
+		return true, "", nil
+	}
+
+	var services corev1.ServiceList
+	if err := r.Client.List(ctx, &services); err != nil {
+		return false, "", err
+	}
+	for _, other := range services.Items {
+		if other.Name == destination && other.Namespace != namespace {
+			return false, fmt.Sprintf("destination %q is also a service in namespace %q", destination, other.Namespace), nil
+		}
+	}
+	return true, "", nil
+}
+
+// applyServiceIntentions replaces the sources that were added from IntentionRequests to the
+// ServiceIntentions of the destination with the sources. The ServiceIntentions is created if it
+// doesn't exist, and deleted if it has no sources left. Sources that users already added to the
+// ServiceIntentions are skipped. It returns the keys of the sources that users added.
+func (r *IntentionRequestController) applyServiceIntentions(ctx context.Context, namespace, destination string, sources consulv1alpha1.SourceIntentions) (map[string]bool, error) {
+	existing, err := r.serviceIntentions(ctx, namespace, destination)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		if len(sources) == 0 {
+			return nil, nil
+		}
+		serviceIntentions := &consulv1alpha1.ServiceIntentions{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        destination,
+				Namespace:   namespace,
+				Annotations: map[string]string{consulv1alpha1.AnnotationIntentionRequestSources: sourceKeys(sources)},
+			},
+			Spec: consulv1alpha1.ServiceIntentionsSpec{
+				Destination: consulv1alpha1.IntentionDestination{Name: destination},
+				Sources:     sources,
+			},
+		}
+		return nil, r.Client.Create(ctx, serviceIntentions)
+	}
+
+	managed := make(map[string]bool)
+	for _, key := range strings.Split(existing.Annotations[consulv1alpha1.AnnotationIntentionRequestSources], ",") {
+		if key != "" {
+			managed[key] = true
+		}
+	}
+	var merged consulv1alpha1.SourceIntentions
+	userKeys := make(map[string]bool)
+	for _, src := range existing.Spec.Sources {
+		key := consulv1alpha1.IntentionSourceKey(src)
+		if !managed[key] {
+			merged = append(merged, src)
+			userKeys[key] = true
+		}
+	}
+	var added consulv1alpha1.SourceIntentions
+	for _, src := range sources {
+		if !userKeys[consulv1alpha1.IntentionSourceKey(src)] {
+			added = append(added, src)
+		}
+	}
+	merged = append(merged, added...)
+
+	if len(merged) == 0 {
+		r.Log.Info("deleting ServiceIntentions without sources", "name", existing.Name, "namespace", existing.Namespace)
+		return userKeys, client.IgnoreNotFound(r.Client.Delete(ctx, existing))
+	}
+	annotation := sourceKeys(added)
+	if equality.Semantic.DeepEqual(existing.Spec.Sources, merged) &&
+		existing.Annotations[consulv1alpha1.AnnotationIntentionRequestSources] == annotation {
+		return userKeys, nil
+	}
+	existing.Spec.Sources = merged
+	if existing.Annotations == nil {
+		existing.Annotations = map[string]string{}
+	}
+	existing.Annotations[consulv1alpha1.AnnotationIntentionRequestSources] = annotation
+	return userKeys, r.Client.Update(ctx, existing)
+}
+
+// serviceIntentions returns the ServiceIntentions in any namespace whose destination is the
+// destination in the Consul namespace of the Kubernetes namespace, or nil if there is none.
+func (r *IntentionRequestController) serviceIntentions(ctx context.Context, namespace, destination string) (*consulv1alpha1.ServiceIntentions, error) {
+	consulNS := r.consulNamespace(namespace)
+	// ServiceIntentions in other Kubernetes namespaces can have the same Consul namespace, so they
+	// can't be listed in the namespace only.
+	var list consulv1alpha1.ServiceIntentionsList
+	if err := r.Client.List(ctx, &list, client.MatchingFields{serviceIntentionsDestinationIndex: destination}); err != nil {
+		return nil, err
+	}
+	for i, serviceIntentions := range list.Items {
+		destinationNS := serviceIntentions.Spec.Destination.Namespace
+		if destinationNS == "" {
+			destinationNS = r.consulNamespace(serviceIntentions.Namespace)
+		}
+		if serviceIntentions.Spec.Destination.Name == destination && destinationNS == consulNS {
+			return &list.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *IntentionRequestController) consulNamespace(namespace string) string {
+	return namespaces.ConsulNamespace(namespace, r.ConsulMeta.NamespacesEnabled, r.ConsulMeta.DestinationNamespace,
+		r.ConsulMeta.Mirroring, r.ConsulMeta.Prefix)
+}
+
+// updateStatus updates the Synced condition of the request unless it's unchanged, since status
+// updates would otherwise trigger another reconcile.
+func (r *IntentionRequestController) updateStatus(ctx context.Context, request *consulv1alpha1.IntentionRequest, cond intentionRequestCondition) error {
+	status, reason, message := request.SyncedCondition()
+	if status == cond.status && reason == cond.reason && message == cond.message {
+		return nil
+	}
+	request.SetSyncedCondition(cond.status, cond.reason, cond.message)
+	return r.Client.Status().Update(ctx, request)
+}
+
+type intentionRequestCondition struct {
+	status  corev1.ConditionStatus
+	reason  string
+	message string
+}
+
+func sourceKeys(sources consulv1alpha1.SourceIntentions) string {
+	keys := make([]string, 0, len(sources))
+	for _, src := range sources {
+		keys = append(keys, consulv1alpha1.IntentionSourceKey(src))
+	}
+	return strings.Join(keys, ",")
+}
@@ -0,0 +1,244 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+)
+
+func TestIntentionRequestController_Reconcile(t *testing.T) {
+	allow := func(name string) *v1alpha1.SourceIntention {
+		return &v1alpha1.SourceIntention{Name: name, Action: "allow"}
+	}
+
+	cases := map[string]struct {
+		existing      []runtime.Object
+		mirroring     bool
+		expSources    v1alpha1.SourceIntentions
+		expAnnotation string
+		expNotFound   bool
+		expStatuses   map[string]corev1.ConditionStatus
+		expReasons    map[string]string
+	}{
+		"creates the ServiceIntentions from requests": {
+			existing: []runtime.Object{
+				testIntentionService("apps", "api"),
+				testIntentionRequest("frontend", "api", allow("frontend")),
+				testIntentionRequest("batch", "api", allow("batch")),
+			},
+			expSources:    v1alpha1.SourceIntentions{allow("batch"), allow("frontend")},
+			expAnnotation: "////batch,////frontend",
+			expStatuses:   map[string]corev1.ConditionStatus{"frontend": corev1.ConditionTrue, "batch": corev1.ConditionTrue},
+			expReasons:    map[string]string{"frontend": "", "batch": ""},
+		},
+		"merges requests into a user's ServiceIntentions": {
+			existing: []runtime.Object{
+				testIntentionService("apps", "api"),
+				testIntentionRequest("frontend", "api", allow("frontend")),
+				&v1alpha1.ServiceIntentions{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "api-intentions",
+						Namespace:   "apps",
+						Annotations: map[string]string{v1alpha1.AnnotationIntentionRequestSources: "////old"},
+					},
+					Spec: v1alpha1.ServiceIntentionsSpec{
+						Destination: v1alpha1.IntentionDestination{Name: "api"},
+						Sources:     v1alpha1.SourceIntentions{allow("admin"), allow("old")},
+					},
+				},
+			},
+			expSources:    v1alpha1.SourceIntentions{allow("admin"), allow("frontend")},
+			expAnnotation: "////frontend",
+			expStatuses:   map[string]corev1.ConditionStatus{"frontend": corev1.ConditionTrue},
+			expReasons:    map[string]string{"frontend": ""},
+		},
+		"doesn't change sources that users added": {
+			existing: []runtime.Object{
+				testIntentionService("apps", "api"),
+				testIntentionRequest("frontend", "api", allow("frontend"), allow("admin")),
+				&v1alpha1.ServiceIntentions{
+					ObjectMeta: metav1.ObjectMeta{Name: "api-intentions", Namespace: "apps"},
+					Spec: v1alpha1.ServiceIntentionsSpec{
+						Destination: v1alpha1.IntentionDestination{Name: "api"},
+						Sources:     v1alpha1.SourceIntentions{{Name: "admin", Action: "deny"}},
+					},
+				},
+			},
+			expSources:    v1alpha1.SourceIntentions{{Name: "admin", Action: "deny"}, allow("frontend")},
+			expAnnotation: "////frontend",
+			expStatuses:   map[string]corev1.ConditionStatus{"frontend": corev1.ConditionFalse},
+			expReasons:    map[string]string{"frontend": ConflictingSourceError},
+		},
+		"the first request takes precedence for the same source": {
+			existing: []runtime.Object{
+				testIntentionService("apps", "api"),
+				testIntentionRequest("a", "api", allow("frontend")),
+				testIntentionRequest("b", "api", &v1alpha1.SourceIntention{Name: "frontend", Action: "deny"}),
+			},
+			expSources:    v1alpha1.SourceIntentions{allow("frontend")},
+			expAnnotation: "////frontend",
+			expStatuses:   map[string]corev1.ConditionStatus{"a": corev1.ConditionTrue, "b": corev1.ConditionFalse},
+			expReasons:    map[string]string{"a": "", "b": ConflictingSourceError},
+		},
+		"rejects destinations that aren't services in the namespace": {
+			existing: []runtime.Object{
+				testIntentionService("other", "api"),
+				testIntentionRequest("frontend", "api", allow("frontend")),
+			},
+			expNotFound: true,
+			expStatuses: map[string]corev1.ConditionStatus{"frontend": corev1.ConditionFalse},
+			expReasons:  map[string]string{"frontend": DestinationNotOwnedError},
+		},
+		"rejects destinations that are also services in other namespaces without mirroring": {
+			existing: []runtime.Object{
+				testIntentionService("apps", "api"),
+				testIntentionService("other", "api"),
+				testIntentionRequest("frontend", "api", allow("frontend")),
+			},
+			expNotFound: true,
+			expStatuses: map[string]corev1.ConditionStatus{"frontend": corev1.ConditionFalse},
+			expReasons:  map[string]string{"frontend": DestinationNotOwnedError},
+		},
+		"allows destinations that are also services in other namespaces with mirroring": {
+			existing: []runtime.Object{
+				testIntentionService("apps", "api"),
+				testIntentionService("other", "api"),
+				testIntentionRequest("frontend", "api", allow("frontend")),
+			},
+			mirroring:     true,
+			expSources:    v1alpha1.SourceIntentions{allow("frontend")},
+			expAnnotation: "////frontend",
+			expStatuses:   map[string]corev1.ConditionStatus{"frontend": corev1.ConditionTrue},
+			expReasons:    map[string]string{"frontend": ""},
+		},
+		"rejects invalid requests": {
+			existing: []runtime.Object{
+				testIntentionService("apps", "api"),
+				testIntentionRequest("frontend", "api", &v1alpha1.SourceIntention{Name: "frontend", Action: "maybe"}),
+			},
+			expNotFound: true,
+			expStatuses: map[string]corev1.ConditionStatus{"frontend": corev1.ConditionFalse},
+			expReasons:  map[string]string{"frontend": InvalidSpecError},
+		},
+		"deletes the ServiceIntentions when all requests are removed": {
+			existing: []runtime.Object{
+				testIntentionService("apps", "api"),
+				&v1alpha1.ServiceIntentions{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "api",
+						Namespace:   "apps",
+						Annotations: map[string]string{v1alpha1.AnnotationIntentionRequestSources: "////frontend"},
+					},
+					Spec: v1alpha1.ServiceIntentionsSpec{
+						Destination: v1alpha1.IntentionDestination{Name: "api"},
+						Sources:     v1alpha1.SourceIntentions{allow("frontend")},
+					},
+				},
+			},
+			expNotFound: true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := runtime.NewScheme()
+			require.NoError(t, clientgoscheme.AddToScheme(s))
+			require.NoError(t, v1alpha1.AddToScheme(s))
+			fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(c.existing...).
+				WithIndex(&v1alpha1.ServiceIntentions{}, serviceIntentionsDestinationIndex, serviceIntentionsDestination).Build()
+
+			controller := &IntentionRequestController{
+				Client: fakeClient,
+				ConsulMeta: common.ConsulMeta{
+					NamespacesEnabled:    c.mirroring,
+					DestinationNamespace: "default",
+					Mirroring:            c.mirroring,
+				},
+				Log:     logrtest.New(t),
+				Scheme:  s,
+				Context: context.Background(),
+			}
+			_, err := controller.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: "api", Namespace: "apps"},
+			})
+			require.NoError(t, err)
+
+			var list v1alpha1.ServiceIntentionsList
+			require.NoError(t, fakeClient.List(context.Background(), &list))
+			if c.expNotFound {
+				require.Empty(t, list.Items)
+			} else {
+				require.Len(t, list.Items, 1)
+				require.Equal(t, "api", list.Items[0].Spec.Destination.Name)
+				require.Equal(t, c.expSources, list.Items[0].Spec.Sources)
+				require.Equal(t, c.expAnnotation, list.Items[0].Annotations[v1alpha1.AnnotationIntentionRequestSources])
+			}
+
+			for requestName, expStatus := range c.expStatuses {
+				var updated v1alpha1.IntentionRequest
+				require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: requestName, Namespace: "apps"}, &updated))
+				synced := updated.Status.GetCondition(v1alpha1.ConditionSynced)
+				require.NotNil(t, synced)
+				require.Equal(t, expStatus, synced.Status)
+				require.Equal(t, c.expReasons[requestName], synced.Reason)
+			}
+		})
+	}
+}
+
+func TestIntentionRequestController_ReconcileUnchangedStatus(t *testing.T) {
+	s := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(s))
+	require.NoError(t, v1alpha1.AddToScheme(s))
+	request := testIntentionRequest("frontend", "api", &v1alpha1.SourceIntention{Name: "frontend", Action: "allow"})
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(testIntentionService("apps", "api"), request).
+		WithIndex(&v1alpha1.ServiceIntentions{}, serviceIntentionsDestinationIndex, serviceIntentionsDestination).Build()
+
+	controller := &IntentionRequestController{
+		Client:  fakeClient,
+		Log:     logrtest.New(t),
+		Scheme:  s,
+		Context: context.Background(),
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "api", Namespace: "apps"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	var first v1alpha1.IntentionRequest
+	require.NoError(t, fakeClient.Get(context.Background(), client.ObjectKeyFromObject(request), &first))
+
+	// A second reconcile must not update the status, which would trigger another reconcile.
+	_, err = controller.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	var second v1alpha1.IntentionRequest
+	require.NoError(t, fakeClient.Get(context.Background(), client.ObjectKeyFromObject(request), &second))
+	require.Equal(t, first.ResourceVersion, second.ResourceVersion)
+}
+
+func testIntentionService(namespace, name string) *corev1.Service {
+	return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+}
+
+func testIntentionRequest(name, destination string, sources ...*v1alpha1.SourceIntention) *v1alpha1.IntentionRequest {
+	return &v1alpha1.IntentionRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "apps"},
+		Spec: v1alpha1.IntentionRequestSpec{
+			Destination: destination,
+			Sources:     sources,
+		},
+	}
+}
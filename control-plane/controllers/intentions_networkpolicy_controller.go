@@ -0,0 +1,433 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	consulv1alpha1 "github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+)
+
+const (
+	// networkPolicyPrefix is the prefix of the names of the NetworkPolicies created for ServiceIntentions.
+	networkPolicyPrefix = "consul-intentions-"
+
+	// meshGatewayComponent is the value of the component label of mesh gateway pods. Traffic from
+	// peers, other partitions and sameness groups reaches services through the mesh gateways.
+	meshGatewayComponent = "mesh-gateway"
+
+	// prometheusScrapePort is the port that Envoy serves its Prometheus metrics on. Prometheus isn't in
+	// the mesh, so the port is open to all sources.
+	prometheusScrapePort = 20200
+)
+
+// IntentionsNetworkPolicyController renders a NetworkPolicy for each ServiceIntentions resource
+// that mirrors its intentions at L3/L4. The policy selects the pods of the destination service and
+// only allows ingress from the pods of the sources that intentions allow, so that traffic which
+// bypasses the mesh is denied by the CNI as well. Consul services are mapped to pods through the
+// selector of the Kubernetes Service with the same name. L7 permissions can't be expressed in a
+// NetworkPolicy, so sources with any allow permission are allowed at L3/L4 and the permissions are
+// left to the sidecar proxies to enforce. NetworkPolicies can only allow traffic, so policies are
+// only rendered when intentions deny by default.
+type IntentionsNetworkPolicyController struct {
+	client.Client
+	Log     logr.Logger
+	Scheme  *runtime.Scheme
+	Context context.Context
+
+	// ConsulPartition is the Admin Partition that the services of this cluster are registered in.
+	ConsulPartition string
+	// EnableConsulNamespaces indicates that a user is running Consul Enterprise
+	// with version 1.7+ which supports namespaces.
+	EnableConsulNamespaces bool
+	// EnableNSMirroring causes Consul namespaces to be created to match the
+	// k8s namespace of any config entry custom resource.
+	EnableNSMirroring bool
+	// NSMirroringPrefix is an optional prefix that can be added to the Consul
+	// namespaces created while mirroring.
+	NSMirroringPrefix string
+	// DefaultDeny indicates that intentions deny connections by default, i.e. that Consul's ACL
+	// default policy is deny. If false, sources that no intention denies are allowed, which a
+	// NetworkPolicy can't express, so no policies are rendered.
+	DefaultDeny bool
+}
+
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;delete
+
+func (r *IntentionsNetworkPolicyController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Log.WithValues("request", req.NamespacedName)
+
+	var intentions consulv1alpha1.ServiceIntentions
+	if err := r.Client.Get(ctx, req.NamespacedName, &intentions); err != nil {
+		if k8serrors.IsNotFound(err) {
+			// The NetworkPolicy is garbage collected through its owner reference.
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "failed to get ServiceIntentions")
+		return ctrl.Result{}, err
+	}
+	if !intentions.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	policy, err := r.networkPolicy(ctx, &intentions)
+	if err != nil {
+		logger.Error(err, "failed to render NetworkPolicy")
+		return ctrl.Result{}, err
+	}
+
+	var existing networkingv1.NetworkPolicy
+	err = r.Client.Get(ctx, types.NamespacedName{Namespace: intentions.Namespace, Name: networkPolicyName(&intentions)}, &existing)
+	switch {
+	case k8serrors.IsNotFound(err):
+		if policy == nil {
+			return ctrl.Result{}, nil
+		}
+		if err := controllerutil.SetControllerReference(&intentions, policy, r.Scheme); err != nil {
+			return ctrl.Result{}, err
+		}
+		logger.Info("creating NetworkPolicy", "name", policy.Name)
+		return ctrl.Result{}, r.Client.Create(ctx, policy)
+	case err != nil:
+		logger.Error(err, "failed to get NetworkPolicy")
+		return ctrl.Result{}, err
+	case !metav1.IsControlledBy(&existing, &intentions):
+		logger.Info("not managing NetworkPolicy that was not created for the ServiceIntentions", "name", existing.Name)
+		return ctrl.Result{}, nil
+	case policy == nil:
+		logger.Info("deleting NetworkPolicy", "name", existing.Name)
+		return ctrl.Result{}, client.IgnoreNotFound(r.Client.Delete(ctx, &existing))
+	case equality.Semantic.DeepEqual(existing.Spec, policy.Spec):
+		return ctrl.Result{}, nil
+	default:
+		existing.Spec = policy.Spec
+		logger.Info("updating NetworkPolicy", "name", existing.Name)
+		return ctrl.Result{}, r.Client.Update(ctx, &existing)
+	}
+}
+
+func (r *IntentionsNetworkPolicyController) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("intentions-network-policy").
+		For(&consulv1alpha1.ServiceIntentions{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Watches(
+			&source.Kind{Type: &corev1.Service{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForService),
+		).
+		Watches(
+			&source.Kind{Type: &consulv1alpha1.ServiceIntentions{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForWildcardIntentions),
+		).Complete(r)
+}
+
+// requestsForWildcardIntentions enqueues every ServiceIntentions in the namespace of intentions for
+// the wildcard destination, since their policies include the sources that it allows.
+func (r *IntentionsNetworkPolicyController) requestsForWildcardIntentions(object client.Object) []reconcile.Request {
+	intentions, ok := object.(*consulv1alpha1.ServiceIntentions)
+	if !ok || intentions.Spec.Destination.Name != namespaces.WildcardNamespace {
+		return []reconcile.Request{}
+	}
+	var intentionsList consulv1alpha1.ServiceIntentionsList
+	if err := r.Client.List(r.Context, &intentionsList, client.InNamespace(intentions.Namespace)); err != nil {
+		r.Log.Error(err, "failed to list ServiceIntentions")
+		return []reconcile.Request{}
+	}
+	var requests []reconcile.Request
+	for _, other := range intentionsList.Items {
+		if other.Spec.Destination.Name != namespaces.WildcardNamespace {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: other.Namespace, Name: other.Name},
+			})
+		}
+	}
+	return requests
+}
+
+// requestsForService enqueues every ServiceIntentions that refers to the service by name, either
+// as its destination or as a source, so that policies follow changes to the service's selector.
+func (r *IntentionsNetworkPolicyController) requestsForService(object client.Object) []reconcile.Request {
+	var intentionsList consulv1alpha1.ServiceIntentionsList
+	if err := r.Client.List(r.Context, &intentionsList); err != nil {
+		r.Log.Error(err, "failed to list ServiceIntentions")
+		return []reconcile.Request{}
+	}
+	var requests []reconcile.Request
+	for _, intentions := range intentionsList.Items {
+		if intentions.Spec.Destination.Name == object.GetName() && intentions.Namespace == object.GetNamespace() ||
+			refersToSource(&intentions, object.GetName()) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: intentions.Namespace, Name: intentions.Name},
+			})
+		}
+	}
+	return requests
+}
+
+func refersToSource(intentions *consulv1alpha1.ServiceIntentions, name string) bool {
+	for _, src := range intentions.Spec.Sources {
+		if src.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// networkPolicy returns the NetworkPolicy for the intentions, or nil if no policy should exist
+// because intentions allow by default, the destination can't be mapped to pods, or no source is
+// allowed. In the last case the mesh denies all sources anyway, and a policy without peers would also
+// deny traffic that doesn't go through the mesh.
+func (r *IntentionsNetworkPolicyController) networkPolicy(ctx context.Context, intentions *consulv1alpha1.ServiceIntentions) (*networkingv1.NetworkPolicy, error) {
+	if !r.DefaultDeny {
+		return nil, nil
+	}
+	// Intentions for the wildcard destination apply to every service without intentions of its
+	// own. Selecting all pods in the namespace would also deny traffic to pods outside the mesh.
+	destination := intentions.Spec.Destination.Name
+	if destination == "" || destination == namespaces.WildcardNamespace {
+		return nil, nil
+	}
+	selector, err := r.serviceSelector(ctx, intentions.Namespace, destination)
+	if err != nil || selector == nil {
+		return nil, err
+	}
+
+	sources, err := r.sources(ctx, intentions)
+	if err != nil {
+		return nil, err
+	}
+	var peers []networkingv1.NetworkPolicyPeer
+	viaMeshGateway := false
+	for _, src := range sources {
+		if !allows(src) {
+			continue
+		}
+		if src.Peer != "" || src.SamenessGroup != "" || r.isRemotePartition(src.Partition) {
+			viaMeshGateway = true
+			continue
+		}
+		sourcePeers, err := r.sourcePeers(ctx, intentions.Namespace, src)
+		if err != nil {
+			return nil, err
+		}
+		peers = append(peers, sourcePeers...)
+	}
+	if viaMeshGateway {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"component": meshGatewayComponent}},
+			NamespaceSelector: &metav1.LabelSelector{},
+		})
+	}
+	if len(peers) == 0 {
+		return nil, nil
+	}
+
+	tcp := corev1.ProtocolTCP
+	prometheusPort := intstr.FromInt(prometheusScrapePort)
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      networkPolicyName(intentions),
+			Namespace: intentions.Namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: selector},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{From: peers},
+				{Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &prometheusPort}}},
+			},
+		},
+	}, nil
+}
+
+// sources returns the sources of the intentions followed by the sources of the intentions for the
+// wildcard destination in the same namespace that they don't override. Sources of the wildcard
+// destination only apply to sources that the intentions don't list, and not at all if they have a
+// wildcard source.
+func (r *IntentionsNetworkPolicyController) sources(ctx context.Context, intentions *consulv1alpha1.ServiceIntentions) ([]*consulv1alpha1.SourceIntention, error) {
+	sources := append([]*consulv1alpha1.SourceIntention{}, intentions.Spec.Sources...)
+	listed := make(map[sourceID]bool)
+	for _, src := range intentions.Spec.Sources {
+		if src.Name == namespaces.WildcardNamespace && src.Peer == "" && src.SamenessGroup == "" && !r.isRemotePartition(src.Partition) {
+			return sources, nil
+		}
+		listed[sourceKey(src)] = true
+	}
+
+	var intentionsList consulv1alpha1.ServiceIntentionsList
+	if err := r.Client.List(ctx, &intentionsList, client.InNamespace(intentions.Namespace)); err != nil {
+		return nil, err
+	}
+	sort.Slice(intentionsList.Items, func(i, j int) bool { return intentionsList.Items[i].Name < intentionsList.Items[j].Name })
+	for _, wildcard := range intentionsList.Items {
+		if wildcard.Spec.Destination.Name != namespaces.WildcardNamespace || !wildcard.ObjectMeta.DeletionTimestamp.IsZero() {
+			continue
+		}
+		for _, src := range wildcard.Spec.Sources {
+			if !listed[sourceKey(src)] {
+				sources = append(sources, src)
+			}
+		}
+	}
+	return sources, nil
+}
+
+// sourceID is the fields of a source that identify it.
+type sourceID struct {
+	Name, Namespace, Partition, Peer, SamenessGroup string
+}
+
+func sourceKey(src *consulv1alpha1.SourceIntention) sourceID {
+	return sourceID{
+		Name:          src.Name,
+		Namespace:     src.Namespace,
+		Partition:     src.Partition,
+		Peer:          src.Peer,
+		SamenessGroup: src.SamenessGroup,
+	}
+}
+
+// sourcePeers returns the peers that select the pods of the source. Sources that can't be mapped
+// to pods return no peers.
+func (r *IntentionsNetworkPolicyController) sourcePeers(ctx context.Context, namespace string, src *consulv1alpha1.SourceIntention) ([]networkingv1.NetworkPolicyPeer, error) {
+	k8sNamespace, ok := r.k8sNamespace(namespace, src.Namespace)
+	if !ok {
+		return nil, nil
+	}
+	if src.Name == namespaces.WildcardNamespace {
+		return []networkingv1.NetworkPolicyPeer{{
+			PodSelector:       &metav1.LabelSelector{},
+			NamespaceSelector: namespaceSelector(k8sNamespace),
+		}}, nil
+	}
+
+	var services []corev1.Service
+	if k8sNamespace == "" {
+		var serviceList corev1.ServiceList
+		if err := r.Client.List(ctx, &serviceList); err != nil {
+			return nil, err
+		}
+		for _, svc := range serviceList.Items {
+			if svc.Name == src.Name {
+				services = append(services, svc)
+			}
+		}
+	} else {
+		var svc corev1.Service
+		err := r.Client.Get(ctx, types.NamespacedName{Namespace: k8sNamespace, Name: src.Name}, &svc)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return nil, err
+		}
+		if err == nil {
+			services = append(services, svc)
+		}
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Namespace < services[j].Namespace })
+
+	var peers []networkingv1.NetworkPolicyPeer
+	for _, svc := range services {
+		if len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			PodSelector:       &metav1.LabelSelector{MatchLabels: svc.Spec.Selector},
+			NamespaceSelector: namespaceSelector(svc.Namespace),
+		})
+	}
+	return peers, nil
+}
+
+// serviceSelector returns the pod selector of the Kubernetes service, or nil if the service
+// doesn't exist or doesn't select pods.
+func (r *IntentionsNetworkPolicyController) serviceSelector(ctx context.Context, namespace, name string) (map[string]string, error) {
+	var svc corev1.Service
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &svc); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	if len(svc.Spec.Selector) == 0 {
+		return nil, nil
+	}
+	return svc.Spec.Selector, nil
+}
+
+// k8sNamespace returns the Kubernetes namespace of a source in the Consul namespace. An empty
+// namespace is returned if the source may be in any Kubernetes namespace. It returns false if the
+// Consul namespace can't contain services of this cluster.
+func (r *IntentionsNetworkPolicyController) k8sNamespace(namespace, consulNamespace string) (string, bool) {
+	switch {
+	case !r.EnableConsulNamespaces:
+		// Without Consul namespaces, the services of every Kubernetes namespace are registered in the
+		// default namespace.
+		return "", true
+	case consulNamespace == "" && r.EnableNSMirroring:
+		// Sources without a namespace are in the namespace of the config entry, which is where
+		// the services of the ServiceIntentions' namespace are registered.
+		return namespace, true
+	case consulNamespace == "" || consulNamespace == namespaces.WildcardNamespace || !r.EnableNSMirroring:
+		// Without mirroring, services of every Kubernetes namespace may be registered in the
+		// Consul namespace.
+		return "", true
+	case strings.HasPrefix(consulNamespace, r.NSMirroringPrefix):
+		return strings.TrimPrefix(consulNamespace, r.NSMirroringPrefix), true
+	default:
+		return "", false
+	}
+}
+
+// isRemotePartition returns true if the partition is not the partition of this cluster.
+func (r *IntentionsNetworkPolicyController) isRemotePartition(partition string) bool {
+	if partition == "" {
+		return false
+	}
+	local := r.ConsulPartition
+	if local == "" {
+		local = common.DefaultConsulPartition
+	}
+	return partition != local
+}
+
+// allows returns true if the source is allowed to connect for at least some requests.
+func allows(src *consulv1alpha1.SourceIntention) bool {
+	if src.Action == "allow" {
+		return true
+	}
+	for _, permission := range src.Permissions {
+		if permission.Action == "allow" {
+			return true
+		}
+	}
+	return false
+}
+
+// namespaceSelector selects the Kubernetes namespace, or all namespaces if it's empty.
+func namespaceSelector(namespace string) *metav1.LabelSelector {
+	if namespace == "" {
+		return &metav1.LabelSelector{}
+	}
+	return &metav1.LabelSelector{MatchLabels: map[string]string{corev1.LabelMetadataName: namespace}}
+}
+
+func networkPolicyName(intentions *consulv1alpha1.ServiceIntentions) string {
+	return networkPolicyPrefix + intentions.Name
+}
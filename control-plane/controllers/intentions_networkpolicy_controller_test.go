@@ -0,0 +1,345 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+)
+
+func TestIntentionsNetworkPolicyController_Reconcile(t *testing.T) {
+	intentions := &v1alpha1.ServiceIntentions{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "intentions-uid"},
+		Spec: v1alpha1.ServiceIntentionsSpec{
+			Destination: v1alpha1.IntentionDestination{Name: "web"},
+			Sources: v1alpha1.SourceIntentions{
+				{Name: "frontend", Action: "allow"},
+				{Name: "blocked", Action: "deny"},
+				{
+					Name: "api",
+					Permissions: v1alpha1.IntentionPermissions{
+						{Action: "deny", HTTP: &v1alpha1.IntentionHTTPPermission{PathPrefix: "/admin"}},
+						{Action: "allow", HTTP: &v1alpha1.IntentionHTTPPermission{PathPrefix: "/"}},
+					},
+				},
+			},
+		},
+	}
+	services := []runtime.Object{
+		testSelectorService("default", "web"),
+		testSelectorService("default", "frontend"),
+		testSelectorService("default", "blocked"),
+		testSelectorService("default", "api"),
+	}
+	webSelector := metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	defaultNS := &metav1.LabelSelector{MatchLabels: map[string]string{corev1.LabelMetadataName: "default"}}
+
+	cases := map[string]struct {
+		intentions       *v1alpha1.ServiceIntentions
+		existing         []runtime.Object
+		mirroring        bool
+		defaultAllow     bool
+		expNoPolicy      bool
+		expPodSelector   metav1.LabelSelector
+		expIngress       []networkingv1.NetworkPolicyIngressRule
+		expUnchangedSpec bool
+	}{
+		"allows sources with allow actions or permissions": {
+			intentions:     intentions.DeepCopy(),
+			existing:       services,
+			expPodSelector: webSelector,
+			expIngress: ingressWithPrometheus([]networkingv1.NetworkPolicyPeer{
+				{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}}, NamespaceSelector: defaultNS},
+				{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}, NamespaceSelector: defaultNS},
+			}),
+		},
+		"does not create policy without allowed sources": {
+			intentions: func() *v1alpha1.ServiceIntentions {
+				i := intentions.DeepCopy()
+				i.Spec.Sources = v1alpha1.SourceIntentions{{Name: "blocked", Action: "deny"}}
+				return i
+			}(),
+			existing:    services,
+			expNoPolicy: true,
+		},
+		"does not create policy when intentions allow by default": {
+			intentions:   intentions.DeepCopy(),
+			existing:     services,
+			defaultAllow: true,
+			expNoPolicy:  true,
+		},
+		"allows sources of the wildcard destination that the intentions don't list": {
+			intentions: func() *v1alpha1.ServiceIntentions {
+				i := intentions.DeepCopy()
+				i.Spec.Sources = v1alpha1.SourceIntentions{{Name: "blocked", Action: "deny"}}
+				return i
+			}(),
+			existing: append([]runtime.Object{
+				&v1alpha1.ServiceIntentions{
+					ObjectMeta: metav1.ObjectMeta{Name: "wildcard", Namespace: "default"},
+					Spec: v1alpha1.ServiceIntentionsSpec{
+						Destination: v1alpha1.IntentionDestination{Name: "*"},
+						Sources: v1alpha1.SourceIntentions{
+							{Name: "blocked", Action: "allow"},
+							{Name: "frontend", Action: "allow"},
+						},
+					},
+				},
+			}, services...),
+			expPodSelector: webSelector,
+			expIngress: ingressWithPrometheus([]networkingv1.NetworkPolicyPeer{
+				{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}}, NamespaceSelector: defaultNS},
+			}),
+		},
+		"sources may be in any Kubernetes namespace without Consul namespaces": {
+			intentions: func() *v1alpha1.ServiceIntentions {
+				i := intentions.DeepCopy()
+				i.Spec.Sources = v1alpha1.SourceIntentions{{Name: "frontend", Action: "allow"}}
+				return i
+			}(),
+			existing:       append([]runtime.Object{testSelectorService("other", "frontend")}, services...),
+			expPodSelector: webSelector,
+			expIngress: ingressWithPrometheus([]networkingv1.NetworkPolicyPeer{
+				{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}}, NamespaceSelector: defaultNS},
+				{
+					PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}},
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{corev1.LabelMetadataName: "other"}},
+				},
+			}),
+		},
+		"wildcard source allows all pods without Consul namespaces": {
+			intentions: func() *v1alpha1.ServiceIntentions {
+				i := intentions.DeepCopy()
+				i.Spec.Sources = v1alpha1.SourceIntentions{{Name: "*", Action: "allow"}}
+				return i
+			}(),
+			existing:       services,
+			expPodSelector: webSelector,
+			expIngress:     ingressWithPrometheus([]networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}, NamespaceSelector: &metav1.LabelSelector{}}}),
+		},
+		"sources from peers are allowed through mesh gateways": {
+			intentions: func() *v1alpha1.ServiceIntentions {
+				i := intentions.DeepCopy()
+				i.Spec.Sources = v1alpha1.SourceIntentions{
+					{Name: "frontend", Peer: "dc2", Action: "allow"},
+					{Name: "frontend", Partition: "default", Action: "allow"},
+				}
+				return i
+			}(),
+			existing:       services,
+			expPodSelector: webSelector,
+			expIngress: ingressWithPrometheus([]networkingv1.NetworkPolicyPeer{
+				{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}}, NamespaceSelector: defaultNS},
+				{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"component": "mesh-gateway"}}, NamespaceSelector: &metav1.LabelSelector{}},
+			}),
+		},
+		"maps mirrored Consul namespaces to Kubernetes namespaces": {
+			intentions: func() *v1alpha1.ServiceIntentions {
+				i := intentions.DeepCopy()
+				i.Spec.Sources = v1alpha1.SourceIntentions{
+					{Name: "frontend", Namespace: "k8s-other", Action: "allow"},
+					{Name: "frontend", Namespace: "not-mirrored", Action: "allow"},
+				}
+				return i
+			}(),
+			existing:       append([]runtime.Object{testSelectorService("other", "frontend")}, services...),
+			mirroring:      true,
+			expPodSelector: webSelector,
+			expIngress: ingressWithPrometheus([]networkingv1.NetworkPolicyPeer{{
+				PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}},
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{corev1.LabelMetadataName: "other"}},
+			}}),
+		},
+		"updates existing policy": {
+			intentions: intentions.DeepCopy(),
+			existing: append([]runtime.Object{
+				ownedNetworkPolicy(intentions, networkingv1.NetworkPolicySpec{PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "old"}}}),
+			}, services...),
+			expPodSelector: webSelector,
+			expIngress: ingressWithPrometheus([]networkingv1.NetworkPolicyPeer{
+				{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}}, NamespaceSelector: defaultNS},
+				{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}, NamespaceSelector: defaultNS},
+			}),
+		},
+		"deletes policy when destination service is removed": {
+			intentions: intentions.DeepCopy(),
+			existing: []runtime.Object{
+				ownedNetworkPolicy(intentions, networkingv1.NetworkPolicySpec{PodSelector: webSelector}),
+			},
+			expNoPolicy: true,
+		},
+		"does not create policy for wildcard destination": {
+			intentions: func() *v1alpha1.ServiceIntentions {
+				i := intentions.DeepCopy()
+				i.Spec.Destination.Name = "*"
+				return i
+			}(),
+			existing:    services,
+			expNoPolicy: true,
+		},
+		"does not overwrite existing policy": {
+			intentions: intentions.DeepCopy(),
+			existing: append([]runtime.Object{
+				&networkingv1.NetworkPolicy{
+					ObjectMeta: metav1.ObjectMeta{Name: "consul-intentions-web", Namespace: "default"},
+					Spec:       networkingv1.NetworkPolicySpec{PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "other"}}},
+				},
+			}, services...),
+			expPodSelector:   metav1.LabelSelector{MatchLabels: map[string]string{"app": "other"}},
+			expUnchangedSpec: true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := runtime.NewScheme()
+			require.NoError(t, clientgoscheme.AddToScheme(s))
+			require.NoError(t, v1alpha1.AddToScheme(s))
+			fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(append(c.existing, c.intentions)...).Build()
+
+			controller := &IntentionsNetworkPolicyController{
+				Client:                 fakeClient,
+				Log:                    logrtest.New(t),
+				Scheme:                 s,
+				Context:                context.Background(),
+				EnableConsulNamespaces: c.mirroring,
+				EnableNSMirroring:      c.mirroring,
+				NSMirroringPrefix:      "k8s-",
+				DefaultDeny:            !c.defaultAllow,
+			}
+			_, err := controller.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: c.intentions.Name, Namespace: c.intentions.Namespace},
+			})
+			require.NoError(t, err)
+
+			var policy networkingv1.NetworkPolicy
+			err = fakeClient.Get(context.Background(), types.NamespacedName{Name: "consul-intentions-web", Namespace: "default"}, &policy)
+			if c.expNoPolicy {
+				require.True(t, k8serrors.IsNotFound(err))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expPodSelector, policy.Spec.PodSelector)
+			if c.expUnchangedSpec {
+				require.Empty(t, policy.OwnerReferences)
+				return
+			}
+			require.True(t, metav1.IsControlledBy(&policy, c.intentions))
+			require.Equal(t, []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}, policy.Spec.PolicyTypes)
+			require.Equal(t, c.expIngress, policy.Spec.Ingress)
+		})
+	}
+}
+
+func TestIntentionsNetworkPolicyController_requestsForService(t *testing.T) {
+	s := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(s))
+	require.NoError(t, v1alpha1.AddToScheme(s))
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(
+		&v1alpha1.ServiceIntentions{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec:       v1alpha1.ServiceIntentionsSpec{Destination: v1alpha1.IntentionDestination{Name: "web"}},
+		},
+		&v1alpha1.ServiceIntentions{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "other"},
+			Spec: v1alpha1.ServiceIntentionsSpec{
+				Destination: v1alpha1.IntentionDestination{Name: "api"},
+				Sources:     v1alpha1.SourceIntentions{{Name: "web", Action: "allow"}},
+			},
+		},
+		&v1alpha1.ServiceIntentions{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+			Spec:       v1alpha1.ServiceIntentionsSpec{Destination: v1alpha1.IntentionDestination{Name: "db"}},
+		},
+	).Build()
+
+	controller := &IntentionsNetworkPolicyController{
+		Client:  fakeClient,
+		Log:     logrtest.New(t),
+		Context: context.Background(),
+	}
+	requests := controller.requestsForService(testSelectorService("default", "web"))
+	var names []string
+	for _, request := range requests {
+		names = append(names, request.NamespacedName.String())
+	}
+	require.ElementsMatch(t, []string{"default/web", "other/api"}, names)
+}
+
+func TestIntentionsNetworkPolicyController_requestsForWildcardIntentions(t *testing.T) {
+	s := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(s))
+	require.NoError(t, v1alpha1.AddToScheme(s))
+	wildcard := &v1alpha1.ServiceIntentions{
+		ObjectMeta: metav1.ObjectMeta{Name: "wildcard", Namespace: "default"},
+		Spec:       v1alpha1.ServiceIntentionsSpec{Destination: v1alpha1.IntentionDestination{Name: "*"}},
+	}
+	web := &v1alpha1.ServiceIntentions{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       v1alpha1.ServiceIntentionsSpec{Destination: v1alpha1.IntentionDestination{Name: "web"}},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(
+		wildcard,
+		web,
+		&v1alpha1.ServiceIntentions{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "other"},
+			Spec:       v1alpha1.ServiceIntentionsSpec{Destination: v1alpha1.IntentionDestination{Name: "api"}},
+		},
+	).Build()
+
+	controller := &IntentionsNetworkPolicyController{
+		Client:  fakeClient,
+		Log:     logrtest.New(t),
+		Context: context.Background(),
+	}
+	var names []string
+	for _, request := range controller.requestsForWildcardIntentions(wildcard) {
+		names = append(names, request.NamespacedName.String())
+	}
+	require.Equal(t, []string{"default/web"}, names)
+	require.Empty(t, controller.requestsForWildcardIntentions(web))
+}
+
+// ingressWithPrometheus returns the ingress rules of a policy that allows the peers.
+func ingressWithPrometheus(peers []networkingv1.NetworkPolicyPeer) []networkingv1.NetworkPolicyIngressRule {
+	tcp := corev1.ProtocolTCP
+	port := intstr.FromInt(20200)
+	return []networkingv1.NetworkPolicyIngressRule{
+		{From: peers},
+		{Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port}}},
+	}
+}
+
+func testSelectorService(namespace, name string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": name}},
+	}
+}
+
+func ownedNetworkPolicy(intentions *v1alpha1.ServiceIntentions, spec networkingv1.NetworkPolicySpec) *networkingv1.NetworkPolicy {
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-intentions-" + intentions.Name, Namespace: intentions.Namespace},
+		Spec:       spec,
+	}
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = controllerutil.SetControllerReference(intentions, policy, s)
+	return policy
+}
@@ -0,0 +1,308 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	consulv1alpha1 "github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/terminatinggateway"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+)
+
+// MeshEgressPolicyController is the controller for MeshEgressPolicy resources. Each destination of
+// a policy is expanded into a ServiceDefaults resource with the destination's addresses, which is
+// linked to the policy's TerminatingGateway resource so that transparent proxies can reach it
+// through the gateway. All other outbound traffic is denied by the Mesh resource, which is created
+// with transparentProxy.meshDestinationsOnly set if it doesn't exist. The ServiceDefaults, Mesh and
+// TerminatingGateway resources are then synced to Consul by their own controllers.
+type MeshEgressPolicyController struct {
+	client.Client
+	Log     logr.Logger
+	Scheme  *runtime.Scheme
+	Context context.Context
+
+	// EnableConsulNamespaces indicates that a user is running Consul Enterprise
+	// with version 1.7+ which supports namespaces.
+	EnableConsulNamespaces bool
+	// ConsulDestinationNamespace is the name of the Consul namespace that the
+	// ServiceDefaults are synced into if mirroring is disabled.
+	ConsulDestinationNamespace string
+	// EnableNSMirroring causes the ServiceDefaults to be synced into a Consul
+	// namespace that matches their Kubernetes namespace.
+	EnableNSMirroring bool
+	// NSMirroringPrefix works with EnableNSMirroring to add a prefix to the
+	// Consul namespace.
+	NSMirroringPrefix string
+}
+
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=meshegresspolicies,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=meshegresspolicies/status,verbs=get;update;patch
+
+func (r *MeshEgressPolicyController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Log.WithValues("request", req.NamespacedName)
+
+	var policy consulv1alpha1.MeshEgressPolicy
+	if err := r.Client.Get(ctx, req.NamespacedName, &policy); err != nil {
+		if k8serrors.IsNotFound(err) {
+			// The resources that were created for it are garbage collected through their owner
+			// references. Its destinations are removed from the terminating gateways.
+			return ctrl.Result{}, r.syncTerminatingGateways(ctx)
+		}
+		logger.Error(err, "failed to get MeshEgressPolicy")
+		return ctrl.Result{}, err
+	}
+	if !policy.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	if err := policy.Validate(); err != nil {
+		logger.Error(err, "invalid MeshEgressPolicy")
+		return ctrl.Result{}, r.updateStatus(ctx, &policy, corev1.ConditionFalse, InvalidSpecError, err.Error())
+	}
+
+	var conflicts []string
+	for _, destination := range policy.Spec.Destinations {
+		owned, err := r.applyDestination(ctx, &policy, destination)
+		if err != nil {
+			logger.Error(err, "failed to apply ServiceDefaults", "destination", destination.Name)
+			return ctrl.Result{}, r.updateStatusWithError(ctx, &policy, err)
+		}
+		if !owned {
+			conflicts = append(conflicts, fmt.Sprintf("ServiceDefaults %s", destination.Name))
+		}
+	}
+	if err := r.deleteStaleDestinations(ctx, &policy); err != nil {
+		logger.Error(err, "failed to delete ServiceDefaults for removed destinations")
+		return ctrl.Result{}, err
+	}
+
+	denied, err := r.applyDefaultDeny(ctx, &policy)
+	if err != nil {
+		logger.Error(err, "failed to apply Mesh")
+		return ctrl.Result{}, r.updateStatusWithError(ctx, &policy, err)
+	}
+	if !denied {
+		conflicts = append(conflicts, fmt.Sprintf("Mesh %s doesn't set transparentProxy.meshDestinationsOnly", common.Mesh))
+	}
+
+	if err := r.syncTerminatingGateways(ctx); err != nil {
+		logger.Error(err, "failed to update TerminatingGateway resources")
+		return ctrl.Result{}, r.updateStatusWithError(ctx, &policy, err)
+	}
+
+	if len(conflicts) > 0 {
+		return ctrl.Result{}, r.updateStatus(ctx, &policy, corev1.ConditionFalse, ExistingResourceError,
+			fmt.Sprintf("policy was not applied to existing resources: %s", strings.Join(conflicts, ", ")))
+	}
+	return ctrl.Result{}, r.updateStatus(ctx, &policy, corev1.ConditionTrue, "", "")
+}
+
+func (r *MeshEgressPolicyController) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&consulv1alpha1.MeshEgressPolicy{}).
+		Owns(&consulv1alpha1.ServiceDefaults{}).
+		Watches(
+			&source.Kind{Type: &consulv1alpha1.Mesh{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForAllPolicies),
+		).
+		Watches(
+			&source.Kind{Type: &consulv1alpha1.TerminatingGateway{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForAllPolicies),
+		).Complete(r)
+}
+
+// requestsForAllPolicies enqueues every MeshEgressPolicy so that the Mesh and TerminatingGateway
+// resources are restored when they're changed or deleted.
+func (r *MeshEgressPolicyController) requestsForAllPolicies(client.Object) []reconcile.Request {
+	var policies consulv1alpha1.MeshEgressPolicyList
+	if err := r.Client.List(r.Context, &policies); err != nil {
+		r.Log.Error(err, "failed to list MeshEgressPolicies")
+		return []reconcile.Request{}
+	}
+	var requests []reconcile.Request
+	for _, policy := range policies.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: policy.Namespace, Name: policy.Name},
+		})
+	}
+	return requests
+}
+
+// applyDestination creates or updates the ServiceDefaults for the destination. It returns false
+// without changing it if a ServiceDefaults already exists that is not owned by the policy.
+func (r *MeshEgressPolicyController) applyDestination(ctx context.Context, policy *consulv1alpha1.MeshEgressPolicy, destination consulv1alpha1.MeshEgressDestination) (bool, error) {
+	spec := destination.ServiceDefaultsSpec()
+	var existing consulv1alpha1.ServiceDefaults
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: policy.Namespace, Name: destination.Name}, &existing)
+	switch {
+	case k8serrors.IsNotFound(err):
+		serviceDefaults := &consulv1alpha1.ServiceDefaults{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      destination.Name,
+				Namespace: policy.Namespace,
+				Labels:    map[string]string{consulv1alpha1.LabelMeshEgressPolicy: policy.Name},
+			},
+			Spec: spec,
+		}
+		if err := controllerutil.SetControllerReference(policy, serviceDefaults, r.Scheme); err != nil {
+			return false, err
+		}
+		return true, r.Client.Create(ctx, serviceDefaults)
+	case err != nil:
+		return false, err
+	case !metav1.IsControlledBy(&existing, policy):
+		return false, nil
+	case equality.Semantic.DeepEqual(existing.Spec, spec):
+		return true, nil
+	default:
+		existing.Spec = spec
+		return true, r.Client.Update(ctx, &existing)
+	}
+}
+
+// deleteStaleDestinations deletes the ServiceDefaults owned by the policy for destinations that
+// were removed from it.
+func (r *MeshEgressPolicyController) deleteStaleDestinations(ctx context.Context, policy *consulv1alpha1.MeshEgressPolicy) error {
+	current := make(map[string]bool)
+	for _, destination := range policy.Spec.Destinations {
+		current[destination.Name] = true
+	}
+	var serviceDefaults consulv1alpha1.ServiceDefaultsList
+	if err := r.Client.List(ctx, &serviceDefaults,
+		client.InNamespace(policy.Namespace),
+		client.MatchingLabels{consulv1alpha1.LabelMeshEgressPolicy: policy.Name}); err != nil {
+		return err
+	}
+	for i, sd := range serviceDefaults.Items {
+		if !current[sd.Name] && metav1.IsControlledBy(&sd, policy) {
+			if err := r.Client.Delete(ctx, &serviceDefaults.Items[i]); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyDefaultDeny makes sure that the Mesh resource denies outbound traffic to destinations
+// outside of the mesh. If it doesn't exist, it's created in the namespace of the policy and owned
+// by all policies in that namespace, so that it's deleted with the last of them. It returns false
+// without changing it if a Mesh resource exists that allows the traffic.
+func (r *MeshEgressPolicyController) applyDefaultDeny(ctx context.Context, policy *consulv1alpha1.MeshEgressPolicy) (bool, error) {
+	var meshList consulv1alpha1.MeshList
+	if err := r.Client.List(ctx, &meshList); err != nil {
+		return false, err
+	}
+	if len(meshList.Items) == 0 {
+		mesh := &consulv1alpha1.Mesh{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      common.Mesh,
+				Namespace: policy.Namespace,
+				Labels:    map[string]string{consulv1alpha1.LabelMeshEgressPolicy: policy.Name},
+			},
+			Spec: consulv1alpha1.MeshSpec{
+				TransparentProxy: consulv1alpha1.TransparentProxyMeshConfig{MeshDestinationsOnly: true},
+			},
+		}
+		if err := controllerutil.SetOwnerReference(policy, mesh, r.Scheme); err != nil {
+			return false, err
+		}
+		return true, r.Client.Create(ctx, mesh)
+	}
+
+	mesh := &meshList.Items[0]
+	if !mesh.Spec.TransparentProxy.MeshDestinationsOnly {
+		return false, nil
+	}
+	if _, created := mesh.Labels[consulv1alpha1.LabelMeshEgressPolicy]; !created || mesh.Namespace != policy.Namespace {
+		return true, nil
+	}
+	for _, owner := range mesh.OwnerReferences {
+		if owner.UID == policy.UID {
+			return true, nil
+		}
+	}
+	if err := controllerutil.SetOwnerReference(policy, mesh, r.Scheme); err != nil {
+		return false, err
+	}
+	return true, r.Client.Update(ctx, mesh)
+}
+
+// syncTerminatingGateways links the destinations of all policies to their TerminatingGateway
+// resources. A TerminatingGateway that doesn't exist is created in the namespace of the first
+// policy that uses it. The destinations that were added are recorded in an annotation on the
+// resource, so that the linked services written by users and by other controllers are left as they
+// are.
+func (r *MeshEgressPolicyController) syncTerminatingGateways(ctx context.Context) error {
+	var policies consulv1alpha1.MeshEgressPolicyList
+	if err := r.Client.List(ctx, &policies); err != nil {
+		return err
+	}
+	sort.Slice(policies.Items, func(i, j int) bool {
+		if policies.Items[i].Namespace != policies.Items[j].Namespace {
+			return policies.Items[i].Namespace < policies.Items[j].Namespace
+		}
+		return policies.Items[i].Name < policies.Items[j].Name
+	})
+	desired := make(map[string]map[string]consulv1alpha1.LinkedService)
+	namespaceOf := make(map[string]string)
+	for _, policy := range policies.Items {
+		if !policy.DeletionTimestamp.IsZero() || policy.Validate() != nil {
+			continue
+		}
+		gateway := policy.TerminatingGatewayName()
+		if desired[gateway] == nil {
+			desired[gateway] = make(map[string]consulv1alpha1.LinkedService)
+			namespaceOf[gateway] = policy.Namespace
+		}
+		for _, destination := range policy.Spec.Destinations {
+			linked := consulv1alpha1.LinkedService{
+				Name: destination.Name,
+				Namespace: namespaces.ConsulNamespace(policy.Namespace, r.EnableConsulNamespaces,
+					r.ConsulDestinationNamespace, r.EnableNSMirroring, r.NSMirroringPrefix),
+			}
+			desired[gateway][terminatinggateway.Key(linked)] = linked
+		}
+	}
+
+	linker := &terminatinggateway.Linker{
+		Client:            r.Client,
+		ManagedAnnotation: consulv1alpha1.AnnotationManagedEgressDestinations,
+		Log:               r.Log,
+	}
+	_, err := linker.Sync(ctx, desired, func(gateway string) string { return namespaceOf[gateway] })
+	return err
+}
+
+// updateStatusWithError sets the Synced condition to false and returns err so that the
+// request is retried.
+func (r *MeshEgressPolicyController) updateStatusWithError(ctx context.Context, policy *consulv1alpha1.MeshEgressPolicy, err error) error {
+	if statusErr := r.updateStatus(ctx, policy, corev1.ConditionFalse, ApplyDefaultsError, err.Error()); statusErr != nil {
+		r.Log.Error(statusErr, "failed to update status", "request", client.ObjectKeyFromObject(policy))
+	}
+	return err
+}
+
+func (r *MeshEgressPolicyController) updateStatus(ctx context.Context, policy *consulv1alpha1.MeshEgressPolicy, status corev1.ConditionStatus, reason, message string) error {
+	policy.SetSyncedCondition(status, reason, message)
+	return r.Client.Status().Update(ctx, policy)
+}
@@ -0,0 +1,228 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+)
+
+func TestMeshEgressPolicyController_Reconcile(t *testing.T) {
+	policy := &v1alpha1.MeshEgressPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "egress", Namespace: "default", UID: "policy-uid"},
+		Spec: v1alpha1.MeshEgressPolicySpec{
+			Destinations: []v1alpha1.MeshEgressDestination{
+				{Name: "payments", Addresses: []string{"api.payments.example.com"}, Port: 443},
+				{Name: "partners", Addresses: []string{"10.0.0.0/30"}, Port: 8080, Protocol: "http"},
+			},
+		},
+	}
+	meshOfOtherPolicy := &v1alpha1.Mesh{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mesh",
+			Namespace: "default",
+			Labels:    map[string]string{v1alpha1.LabelMeshEgressPolicy: "other"},
+		},
+		Spec: v1alpha1.MeshSpec{TransparentProxy: v1alpha1.TransparentProxyMeshConfig{MeshDestinationsOnly: true}},
+	}
+
+	cases := map[string]struct {
+		policy           *v1alpha1.MeshEgressPolicy
+		existing         []runtime.Object
+		expDestinations  []string
+		expMeshOwned     bool
+		expMeshDenies    bool
+		expLinked        []v1alpha1.LinkedService
+		expManaged       string
+		expGatewayExists bool
+		expSyncedStatus  corev1.ConditionStatus
+		expSyncedReason  string
+	}{
+		"creates resources for destinations": {
+			policy:          policy.DeepCopy(),
+			expDestinations: []string{"partners", "payments"},
+			expMeshOwned:    true,
+			expMeshDenies:   true,
+			expLinked: []v1alpha1.LinkedService{
+				{Name: "partners"},
+				{Name: "payments"},
+			},
+			expManaged:       "/partners,/payments",
+			expGatewayExists: true,
+			expSyncedStatus:  corev1.ConditionTrue,
+		},
+		"keeps user entries and removes stale destinations": {
+			policy: policy.DeepCopy(),
+			existing: []runtime.Object{
+				ownedEgressServiceDefaults(policy, "old"),
+				meshOfOtherPolicy,
+				&v1alpha1.TerminatingGateway{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "terminating-gateway",
+						Namespace:   "consul",
+						Annotations: map[string]string{v1alpha1.AnnotationManagedEgressDestinations: "/old,/payments"},
+					},
+					Spec: v1alpha1.TerminatingGatewaySpec{Services: []v1alpha1.LinkedService{
+						{Name: "legacy", CAFile: "/ca.pem"},
+						{Name: "old"},
+						{Name: "payments"},
+					}},
+				},
+			},
+			expDestinations: []string{"partners", "payments"},
+			expMeshOwned:    true,
+			expMeshDenies:   true,
+			expLinked: []v1alpha1.LinkedService{
+				{Name: "legacy", CAFile: "/ca.pem"},
+				{Name: "partners"},
+				{Name: "payments"},
+			},
+			expManaged:       "/partners,/payments",
+			expGatewayExists: true,
+			expSyncedStatus:  corev1.ConditionTrue,
+		},
+		"does not change a Mesh that allows outbound traffic": {
+			policy: policy.DeepCopy(),
+			existing: []runtime.Object{
+				&v1alpha1.Mesh{ObjectMeta: metav1.ObjectMeta{Name: "mesh", Namespace: "consul"}},
+			},
+			expDestinations: []string{"partners", "payments"},
+			expLinked: []v1alpha1.LinkedService{
+				{Name: "partners"},
+				{Name: "payments"},
+			},
+			expManaged:       "/partners,/payments",
+			expGatewayExists: true,
+			expSyncedStatus:  corev1.ConditionFalse,
+			expSyncedReason:  ExistingResourceError,
+		},
+		"invalid spec": {
+			policy: func() *v1alpha1.MeshEgressPolicy {
+				p := policy.DeepCopy()
+				p.Spec.Destinations[1].Addresses = []string{"10.0.0.0/16"}
+				return p
+			}(),
+			expSyncedStatus: corev1.ConditionFalse,
+			expSyncedReason: InvalidSpecError,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := runtime.NewScheme()
+			require.NoError(t, clientgoscheme.AddToScheme(s))
+			require.NoError(t, v1alpha1.AddToScheme(s))
+			fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(append(c.existing, c.policy)...).Build()
+
+			controller := &MeshEgressPolicyController{
+				Client:  fakeClient,
+				Log:     logrtest.New(t),
+				Scheme:  s,
+				Context: context.Background(),
+			}
+			_, err := controller.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: c.policy.Name, Namespace: c.policy.Namespace},
+			})
+			require.NoError(t, err)
+
+			var serviceDefaults v1alpha1.ServiceDefaultsList
+			require.NoError(t, fakeClient.List(context.Background(), &serviceDefaults))
+			var sdNames []string
+			for _, sd := range serviceDefaults.Items {
+				sdNames = append(sdNames, sd.Name)
+				require.True(t, metav1.IsControlledBy(&sd, c.policy))
+				require.Equal(t, c.policy.Name, sd.Labels[v1alpha1.LabelMeshEgressPolicy])
+				require.NotNil(t, sd.Spec.Destination)
+			}
+			require.Equal(t, c.expDestinations, sdNames)
+
+			var meshList v1alpha1.MeshList
+			require.NoError(t, fakeClient.List(context.Background(), &meshList))
+			if c.expMeshOwned || c.expMeshDenies {
+				require.Len(t, meshList.Items, 1)
+				require.Equal(t, c.expMeshDenies, meshList.Items[0].Spec.TransparentProxy.MeshDestinationsOnly)
+				owned := false
+				for _, owner := range meshList.Items[0].OwnerReferences {
+					owned = owned || owner.UID == c.policy.UID
+				}
+				require.Equal(t, c.expMeshOwned, owned)
+			}
+
+			var gateways v1alpha1.TerminatingGatewayList
+			require.NoError(t, fakeClient.List(context.Background(), &gateways))
+			if !c.expGatewayExists {
+				require.Empty(t, gateways.Items)
+			} else {
+				require.Len(t, gateways.Items, 1)
+				require.Equal(t, c.expLinked, gateways.Items[0].Spec.Services)
+				require.Equal(t, c.expManaged, gateways.Items[0].Annotations[v1alpha1.AnnotationManagedEgressDestinations])
+			}
+
+			var updated v1alpha1.MeshEgressPolicy
+			require.NoError(t, fakeClient.Get(context.Background(), client.ObjectKeyFromObject(c.policy), &updated))
+			synced := updated.Status.GetCondition(v1alpha1.ConditionSynced)
+			require.NotNil(t, synced)
+			require.Equal(t, c.expSyncedStatus, synced.Status)
+			require.Equal(t, c.expSyncedReason, synced.Reason)
+		})
+	}
+}
+
+func TestMeshEgressPolicyController_ReconcileDeleted(t *testing.T) {
+	s := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(s))
+	require.NoError(t, v1alpha1.AddToScheme(s))
+	gateway := &v1alpha1.TerminatingGateway{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "terminating-gateway",
+			Namespace:   "default",
+			Annotations: map[string]string{v1alpha1.AnnotationManagedEgressDestinations: "/payments"},
+		},
+		Spec: v1alpha1.TerminatingGatewaySpec{Services: []v1alpha1.LinkedService{{Name: "legacy"}, {Name: "payments"}}},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(gateway).Build()
+
+	controller := &MeshEgressPolicyController{
+		Client:  fakeClient,
+		Log:     logrtest.New(t),
+		Scheme:  s,
+		Context: context.Background(),
+	}
+	_, err := controller.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "egress", Namespace: "default"},
+	})
+	require.NoError(t, err)
+
+	var updated v1alpha1.TerminatingGateway
+	require.NoError(t, fakeClient.Get(context.Background(), client.ObjectKeyFromObject(gateway), &updated))
+	require.Equal(t, []v1alpha1.LinkedService{{Name: "legacy"}}, updated.Spec.Services)
+	require.Empty(t, updated.Annotations[v1alpha1.AnnotationManagedEgressDestinations])
+}
+
+func ownedEgressServiceDefaults(policy *v1alpha1.MeshEgressPolicy, name string) *v1alpha1.ServiceDefaults {
+	sd := &v1alpha1.ServiceDefaults{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: policy.Namespace,
+			Labels:    map[string]string{v1alpha1.LabelMeshEgressPolicy: policy.Name},
+		},
+	}
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = controllerutil.SetControllerReference(policy, sd, s)
+	return sd
+}
@@ -0,0 +1,306 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	consulv1alpha1 "github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+)
+
+const (
+	InvalidSpecError      = "InvalidSpecError"
+	ExistingResourceError = "ExistingResourceError"
+	ApplyDefaultsError    = "ApplyDefaultsError"
+)
+
+// MeshPolicyDefaultsController is the controller for MeshPolicyDefaults resources. It expands
+// each MeshPolicyDefaults into a ServiceDefaults and ServiceRouter resource for every mesh service
+// in its namespace. Those resources are then synced to Consul by their own controllers.
+type MeshPolicyDefaultsController struct {
+	client.Client
+	Log     logr.Logger
+	Scheme  *runtime.Scheme
+	Context context.Context
+}
+
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=meshpolicydefaults,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=meshpolicydefaults/status,verbs=get;update;patch
+
+func (r *MeshPolicyDefaultsController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Log.WithValues("request", req.NamespacedName)
+
+	var policy consulv1alpha1.MeshPolicyDefaults
+	if err := r.Client.Get(ctx, req.NamespacedName, &policy); err != nil {
+		if k8serrors.IsNotFound(err) {
+			// The resources that were created for it are garbage collected through their owner references.
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "failed to get MeshPolicyDefaults")
+		return ctrl.Result{}, err
+	}
+	if !policy.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	if err := policy.Validate(); err != nil {
+		logger.Error(err, "invalid MeshPolicyDefaults")
+		return ctrl.Result{}, r.updateStatus(ctx, &policy, corev1.ConditionFalse, InvalidSpecError, err.Error())
+	}
+
+	services, err := r.meshServices(ctx, policy.Namespace)
+	if err != nil {
+		logger.Error(err, "failed to list mesh services")
+		return ctrl.Result{}, err
+	}
+
+	var conflicts []string
+	for _, svc := range services {
+		owned, err := r.applyServiceDefaults(ctx, &policy, svc)
+		if err != nil {
+			logger.Error(err, "failed to apply ServiceDefaults", "service", svc)
+			return ctrl.Result{}, r.updateStatusWithError(ctx, &policy, err)
+		}
+		if !owned {
+			conflicts = append(conflicts, fmt.Sprintf("ServiceDefaults %s", svc))
+		}
+
+		if policy.HasRoutes() {
+			owned, err = r.applyServiceRouter(ctx, &policy, svc)
+			if err != nil {
+				logger.Error(err, "failed to apply ServiceRouter", "service", svc)
+				return ctrl.Result{}, r.updateStatusWithError(ctx, &policy, err)
+			}
+			if !owned {
+				conflicts = append(conflicts, fmt.Sprintf("ServiceRouter %s", svc))
+			}
+		}
+	}
+
+	if err := r.deleteStaleResources(ctx, &policy, services); err != nil {
+		logger.Error(err, "failed to delete resources for removed services")
+		return ctrl.Result{}, err
+	}
+
+	if len(conflicts) > 0 {
+		// Resources created by users for a specific service take precedence over the namespace defaults.
+		return ctrl.Result{}, r.updateStatus(ctx, &policy, corev1.ConditionFalse, ExistingResourceError,
+			fmt.Sprintf("defaults were not applied to existing resources: %s", strings.Join(conflicts, ", ")))
+	}
+	return ctrl.Result{}, r.updateStatus(ctx, &policy, corev1.ConditionTrue, "", "")
+}
+
+func (r *MeshPolicyDefaultsController) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&consulv1alpha1.MeshPolicyDefaults{}).
+		Owns(&consulv1alpha1.ServiceDefaults{}).
+		Owns(&consulv1alpha1.ServiceRouter{}).
+		Watches(
+			&source.Kind{Type: &corev1.Endpoints{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForEndpoints),
+		).Complete(r)
+}
+
+// requestsForEndpoints enqueues every MeshPolicyDefaults in the namespace of the Endpoints so that
+// services are picked up as they are added to or removed from the mesh.
+func (r *MeshPolicyDefaultsController) requestsForEndpoints(object client.Object) []reconcile.Request {
+	var policies consulv1alpha1.MeshPolicyDefaultsList
+	if err := r.Client.List(r.Context, &policies, client.InNamespace(object.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list MeshPolicyDefaults", "namespace", object.GetNamespace())
+		return []reconcile.Request{}
+	}
+	var requests []reconcile.Request
+	for _, policy := range policies.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: policy.Namespace, Name: policy.Name},
+		})
+	}
+	return requests
+}
+
+// meshServices returns the sorted names of the services in the namespace that have at least one
+// injected pod. Gateways are skipped since they are not configured through service-defaults.
+func (r *MeshPolicyDefaultsController) meshServices(ctx context.Context, namespace string) ([]string, error) {
+	var pods corev1.PodList
+	if err := r.Client.List(ctx, &pods, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	injected := make(map[string]corev1.Pod)
+	for _, pod := range pods.Items {
+		if pod.Annotations[constants.KeyInjectStatus] == constants.Injected && pod.Annotations[constants.AnnotationGatewayKind] == "" {
+			injected[pod.Name] = pod
+		}
+	}
+
+	var endpointsList corev1.EndpointsList
+	if err := r.Client.List(ctx, &endpointsList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	services := make(map[string]struct{})
+	for _, endpoints := range endpointsList.Items {
+		for _, subset := range endpoints.Subsets {
+			for _, addresses := range [][]corev1.EndpointAddress{subset.Addresses, subset.NotReadyAddresses} {
+				for _, address := range addresses {
+					if address.TargetRef == nil || address.TargetRef.Kind != "Pod" {
+						continue
+					}
+					if pod, ok := injected[address.TargetRef.Name]; ok {
+						services[meshServiceName(pod, endpoints)] = struct{}{}
+					}
+				}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// meshServiceName returns the Consul service name of the pod, matching the name that
+// the endpoints controller registers it with.
+func meshServiceName(pod corev1.Pod, endpoints corev1.Endpoints) string {
+	// If the annotation has a comma, it is a multi port Pod. In that case we always use the name of the endpoint.
+	if name := pod.Annotations[constants.AnnotationService]; name != "" && !strings.Contains(name, ",") {
+		return name
+	}
+	return endpoints.Name
+}
+
+// applyServiceDefaults creates or updates the ServiceDefaults for the service. It returns false
+// without changing it if a ServiceDefaults already exists that is not owned by the policy.
+func (r *MeshPolicyDefaultsController) applyServiceDefaults(ctx context.Context, policy *consulv1alpha1.MeshPolicyDefaults, svc string) (bool, error) {
+	spec := policy.ServiceDefaultsSpec()
+	var existing consulv1alpha1.ServiceDefaults
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: policy.Namespace, Name: svc}, &existing)
+	switch {
+	case k8serrors.IsNotFound(err):
+		serviceDefaults := &consulv1alpha1.ServiceDefaults{
+			ObjectMeta: r.objectMeta(policy, svc),
+			Spec:       spec,
+		}
+		if err := controllerutil.SetControllerReference(policy, serviceDefaults, r.Scheme); err != nil {
+			return false, err
+		}
+		return true, r.Client.Create(ctx, serviceDefaults)
+	case err != nil:
+		return false, err
+	case !metav1.IsControlledBy(&existing, policy):
+		return false, nil
+	case equality.Semantic.DeepEqual(existing.Spec, spec):
+		return true, nil
+	default:
+		existing.Spec = spec
+		return true, r.Client.Update(ctx, &existing)
+	}
+}
+
+// applyServiceRouter creates or updates the ServiceRouter for the service. It returns false
+// without changing it if a ServiceRouter already exists that is not owned by the policy.
+func (r *MeshPolicyDefaultsController) applyServiceRouter(ctx context.Context, policy *consulv1alpha1.MeshPolicyDefaults, svc string) (bool, error) {
+	spec := policy.ServiceRouterSpec()
+	var existing consulv1alpha1.ServiceRouter
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: policy.Namespace, Name: svc}, &existing)
+	switch {
+	case k8serrors.IsNotFound(err):
+		serviceRouter := &consulv1alpha1.ServiceRouter{
+			ObjectMeta: r.objectMeta(policy, svc),
+			Spec:       spec,
+		}
+		if err := controllerutil.SetControllerReference(policy, serviceRouter, r.Scheme); err != nil {
+			return false, err
+		}
+		return true, r.Client.Create(ctx, serviceRouter)
+	case err != nil:
+		return false, err
+	case !metav1.IsControlledBy(&existing, policy):
+		return false, nil
+	case equality.Semantic.DeepEqual(existing.Spec, spec):
+		return true, nil
+	default:
+		existing.Spec = spec
+		return true, r.Client.Update(ctx, &existing)
+	}
+}
+
+// deleteStaleResources deletes the resources owned by the policy for services that are no longer in
+// the mesh, and the ServiceRouters if the policy no longer sets a timeout or retries.
+func (r *MeshPolicyDefaultsController) deleteStaleResources(ctx context.Context, policy *consulv1alpha1.MeshPolicyDefaults, services []string) error {
+	current := make(map[string]bool)
+	for _, svc := range services {
+		current[svc] = true
+	}
+	listOpts := []client.ListOption{
+		client.InNamespace(policy.Namespace),
+		client.MatchingLabels{consulv1alpha1.LabelMeshPolicyDefaults: policy.Name},
+	}
+
+	var serviceDefaults consulv1alpha1.ServiceDefaultsList
+	if err := r.Client.List(ctx, &serviceDefaults, listOpts...); err != nil {
+		return err
+	}
+	for i, sd := range serviceDefaults.Items {
+		if !current[sd.Name] && metav1.IsControlledBy(&sd, policy) {
+			if err := r.Client.Delete(ctx, &serviceDefaults.Items[i]); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+
+	var serviceRouters consulv1alpha1.ServiceRouterList
+	if err := r.Client.List(ctx, &serviceRouters, listOpts...); err != nil {
+		return err
+	}
+	for i, sr := range serviceRouters.Items {
+		if (!current[sr.Name] || !policy.HasRoutes()) && metav1.IsControlledBy(&sr, policy) {
+			if err := r.Client.Delete(ctx, &serviceRouters.Items[i]); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *MeshPolicyDefaultsController) objectMeta(policy *consulv1alpha1.MeshPolicyDefaults, svc string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      svc,
+		Namespace: policy.Namespace,
+		Labels:    map[string]string{consulv1alpha1.LabelMeshPolicyDefaults: policy.Name},
+	}
+}
+
+// updateStatusWithError sets the Synced condition to false and returns err so that the
+// request is retried.
+func (r *MeshPolicyDefaultsController) updateStatusWithError(ctx context.Context, policy *consulv1alpha1.MeshPolicyDefaults, err error) error {
+	if statusErr := r.updateStatus(ctx, policy, corev1.ConditionFalse, ApplyDefaultsError, err.Error()); statusErr != nil {
+		r.Log.Error(statusErr, "failed to update status", "request", client.ObjectKeyFromObject(policy))
+	}
+	return err
+}
+
+func (r *MeshPolicyDefaultsController) updateStatus(ctx context.Context, policy *consulv1alpha1.MeshPolicyDefaults, status corev1.ConditionStatus, reason, message string) error {
+	policy.SetSyncedCondition(status, reason, message)
+	return r.Client.Status().Update(ctx, policy)
+}
@@ -0,0 +1,252 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+)
+
+func TestMeshPolicyDefaultsController_Reconcile(t *testing.T) {
+	policy := &v1alpha1.MeshPolicyDefaults{
+		ObjectMeta: metav1.ObjectMeta{Name: "defaults", Namespace: "default", UID: "policy-uid"},
+		Spec: v1alpha1.MeshPolicyDefaultsSpec{
+			Protocol:           "http",
+			RequestTimeout:     metav1.Duration{Duration: 10 * time.Second},
+			Retries:            &v1alpha1.MeshPolicyRetries{NumRetries: 3},
+			PassiveHealthCheck: &v1alpha1.PassiveHealthCheck{MaxFailures: 5},
+		},
+	}
+
+	cases := map[string]struct {
+		policy              *v1alpha1.MeshPolicyDefaults
+		existing            []runtime.Object
+		expServiceDefaults  []string
+		expServiceRouters   []string
+		expSyncedStatus     corev1.ConditionStatus
+		expSyncedReason     string
+		expUnchangedDefault string
+	}{
+		"creates resources for injected services": {
+			policy: policy.DeepCopy(),
+			existing: []runtime.Object{
+				testMeshPod("web-pod", "", true),
+				testMeshEndpoints("web", "web-pod"),
+				testMeshPod("api-pod", "api-v2", true),
+				testMeshEndpoints("api", "api-pod"),
+				testMeshPod("legacy-pod", "", false),
+				testMeshEndpoints("legacy", "legacy-pod"),
+			},
+			expServiceDefaults: []string{"api-v2", "web"},
+			expServiceRouters:  []string{"api-v2", "web"},
+			expSyncedStatus:    corev1.ConditionTrue,
+		},
+		"does not create routers without a timeout or retries": {
+			policy: func() *v1alpha1.MeshPolicyDefaults {
+				p := policy.DeepCopy()
+				p.Spec.RequestTimeout = metav1.Duration{}
+				p.Spec.Retries = nil
+				return p
+			}(),
+			existing: []runtime.Object{
+				testMeshPod("web-pod", "", true),
+				testMeshEndpoints("web", "web-pod"),
+				ownedServiceRouter(policy, "web"),
+			},
+			expServiceDefaults: []string{"web"},
+			expSyncedStatus:    corev1.ConditionTrue,
+		},
+		"deletes resources for services that left the mesh": {
+			policy: policy.DeepCopy(),
+			existing: []runtime.Object{
+				testMeshPod("web-pod", "", true),
+				testMeshEndpoints("web", "web-pod"),
+				ownedServiceDefaults(policy, "old"),
+				ownedServiceRouter(policy, "old"),
+			},
+			expServiceDefaults: []string{"web"},
+			expServiceRouters:  []string{"web"},
+			expSyncedStatus:    corev1.ConditionTrue,
+		},
+		"does not overwrite existing resources": {
+			policy: policy.DeepCopy(),
+			existing: []runtime.Object{
+				testMeshPod("web-pod", "", true),
+				testMeshEndpoints("web", "web-pod"),
+				&v1alpha1.ServiceDefaults{
+					ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+					Spec:       v1alpha1.ServiceDefaultsSpec{Protocol: "grpc"},
+				},
+			},
+			expServiceDefaults:  []string{"web"},
+			expServiceRouters:   []string{"web"},
+			expSyncedStatus:     corev1.ConditionFalse,
+			expSyncedReason:     ExistingResourceError,
+			expUnchangedDefault: "web",
+		},
+		"invalid spec": {
+			policy: func() *v1alpha1.MeshPolicyDefaults {
+				p := policy.DeepCopy()
+				p.Spec.Protocol = "tcp"
+				return p
+			}(),
+			existing: []runtime.Object{
+				testMeshPod("web-pod", "", true),
+				testMeshEndpoints("web", "web-pod"),
+			},
+			expSyncedStatus: corev1.ConditionFalse,
+			expSyncedReason: InvalidSpecError,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := runtime.NewScheme()
+			require.NoError(t, clientgoscheme.AddToScheme(s))
+			require.NoError(t, v1alpha1.AddToScheme(s))
+			fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(append(c.existing, c.policy)...).Build()
+
+			controller := &MeshPolicyDefaultsController{
+				Client:  fakeClient,
+				Log:     logrtest.New(t),
+				Scheme:  s,
+				Context: context.Background(),
+			}
+			_, err := controller.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: c.policy.Name, Namespace: c.policy.Namespace},
+			})
+			require.NoError(t, err)
+
+			var serviceDefaults v1alpha1.ServiceDefaultsList
+			require.NoError(t, fakeClient.List(context.Background(), &serviceDefaults))
+			var sdNames []string
+			for _, sd := range serviceDefaults.Items {
+				sdNames = append(sdNames, sd.Name)
+				if sd.Name == c.expUnchangedDefault {
+					require.Equal(t, "grpc", sd.Spec.Protocol)
+					continue
+				}
+				require.True(t, metav1.IsControlledBy(&sd, c.policy))
+				require.Equal(t, c.policy.ServiceDefaultsSpec(), sd.Spec)
+				require.Equal(t, c.policy.Name, sd.Labels[v1alpha1.LabelMeshPolicyDefaults])
+			}
+			require.Equal(t, c.expServiceDefaults, sdNames)
+
+			var serviceRouters v1alpha1.ServiceRouterList
+			require.NoError(t, fakeClient.List(context.Background(), &serviceRouters))
+			var srNames []string
+			for _, sr := range serviceRouters.Items {
+				srNames = append(srNames, sr.Name)
+				require.True(t, metav1.IsControlledBy(&sr, c.policy))
+				require.Equal(t, c.policy.ServiceRouterSpec(), sr.Spec)
+			}
+			require.Equal(t, c.expServiceRouters, srNames)
+
+			var updated v1alpha1.MeshPolicyDefaults
+			require.NoError(t, fakeClient.Get(context.Background(), client.ObjectKeyFromObject(c.policy), &updated))
+			synced := updated.Status.GetCondition(v1alpha1.ConditionSynced)
+			require.NotNil(t, synced)
+			require.Equal(t, c.expSyncedStatus, synced.Status)
+			require.Equal(t, c.expSyncedReason, synced.Reason)
+		})
+	}
+}
+
+func TestMeshPolicyDefaultsController_ReconcileDeleted(t *testing.T) {
+	s := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(s))
+	require.NoError(t, v1alpha1.AddToScheme(s))
+	fakeClient := fake.NewClientBuilder().WithScheme(s).Build()
+
+	controller := &MeshPolicyDefaultsController{
+		Client:  fakeClient,
+		Log:     logrtest.New(t),
+		Scheme:  s,
+		Context: context.Background(),
+	}
+	resp, err := controller.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "defaults", Namespace: "default"},
+	})
+	require.NoError(t, err)
+	require.False(t, resp.Requeue)
+
+	err = fakeClient.Get(context.Background(), types.NamespacedName{Name: "defaults", Namespace: "default"}, &v1alpha1.MeshPolicyDefaults{})
+	require.True(t, k8serrors.IsNotFound(err))
+}
+
+func testMeshPod(name, serviceAnnotation string, injected bool) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Annotations: map[string]string{},
+		},
+	}
+	if injected {
+		pod.Annotations[constants.KeyInjectStatus] = constants.Injected
+	}
+	if serviceAnnotation != "" {
+		pod.Annotations[constants.AnnotationService] = serviceAnnotation
+	}
+	return pod
+}
+
+func testMeshEndpoints(name, podName string) *corev1.Endpoints {
+	return &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{
+					{IP: "1.2.3.4", TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: podName, Namespace: "default"}},
+				},
+			},
+		},
+	}
+}
+
+func ownedServiceDefaults(policy *v1alpha1.MeshPolicyDefaults, name string) *v1alpha1.ServiceDefaults {
+	sd := &v1alpha1.ServiceDefaults{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: policy.Namespace,
+			Labels:    map[string]string{v1alpha1.LabelMeshPolicyDefaults: policy.Name},
+		},
+	}
+	setTestOwner(policy, sd)
+	return sd
+}
+
+func ownedServiceRouter(policy *v1alpha1.MeshPolicyDefaults, name string) *v1alpha1.ServiceRouter {
+	sr := &v1alpha1.ServiceRouter{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: policy.Namespace,
+			Labels:    map[string]string{v1alpha1.LabelMeshPolicyDefaults: policy.Name},
+		},
+	}
+	setTestOwner(policy, sr)
+	return sr
+}
+
+func setTestOwner(policy *v1alpha1.MeshPolicyDefaults, obj client.Object) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = controllerutil.SetControllerReference(policy, obj, s)
+}
@@ -13,6 +13,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
 	consulv1alpha1 "github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
 )
 
@@ -41,5 +42,5 @@ func (r *SamenessGroupController) UpdateStatus(ctx context.Context, obj client.O
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *SamenessGroupController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.SamenessGroup{}, r)
+	return setupWithManager(mgr, &consulv1alpha1.SamenessGroup{}, r, r.ConfigEntryController.maxConcurrentReconciles(common.SamenessGroup))
 }
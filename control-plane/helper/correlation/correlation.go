@@ -0,0 +1,60 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package correlation provides correlation IDs that tie together the log lines
+// and Consul API requests made while handling a single admission request,
+// reconcile or sync pass, so that a service's lifecycle can be traced across
+// the injector, the endpoints controller and catalog sync.
+package correlation
+
+import (
+	"context"
+
+	"github.com/hashicorp/consul/api"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+)
+
+const (
+	// LogKey is the key correlation IDs are logged under.
+	LogKey = "correlation-id"
+
+	// HeaderName is the HTTP header correlation IDs are sent to Consul in so
+	// that requests can be matched up with Consul's own logs.
+	HeaderName = "X-Consul-K8s-Correlation-Id"
+)
+
+type contextKey struct{}
+
+// NewID returns a new random correlation ID.
+func NewID() string {
+	return string(uuid.NewUUID())
+}
+
+// WithID returns a copy of ctx that carries the given correlation ID.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the correlation ID carried by ctx. If none was set with
+// WithID, the controller-runtime reconcile ID is used so that reconcilers get
+// an ID for free. If neither is present a new ID is generated.
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(contextKey{}).(string); ok && id != "" {
+		return id
+	}
+	if id := controller.ReconcileIDFromContext(ctx); id != "" {
+		return string(id)
+	}
+	return NewID()
+}
+
+// SetConsulHeader configures client to send id with every request it makes.
+// Clients are expected to be scoped to a single request or reconcile since the
+// header is set for the lifetime of the client.
+func SetConsulHeader(client *api.Client, id string) {
+	if client == nil || id == "" {
+		return
+	}
+	client.AddHeader(HeaderName, id)
+}
@@ -0,0 +1,41 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package correlation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromContext(t *testing.T) {
+	require.Equal(t, "abc", FromContext(WithID(context.Background(), "abc")))
+
+	// Without an ID in the context a fresh one is generated each time.
+	first := FromContext(context.Background())
+	second := FromContext(context.Background())
+	require.NotEmpty(t, first)
+	require.NotEqual(t, first, second)
+}
+
+func TestSetConsulHeader(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(HeaderName)
+		w.Write([]byte("\"leader\""))
+	}))
+	defer server.Close()
+
+	client, err := api.NewClient(&api.Config{Address: server.URL})
+	require.NoError(t, err)
+	SetConsulHeader(client, "abc")
+
+	_, err = client.Status().Leader()
+	require.NoError(t, err)
+	require.Equal(t, "abc", got)
+}
@@ -0,0 +1,168 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package terminatinggateway
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+)
+
+// Linker links services to TerminatingGateway resources on behalf of a controller. Several
+// controllers link services to the same resources, so the services that a controller added are
+// recorded in its ManagedAnnotation, and linked services that aren't in it, whether written by users
+// or by other controllers, are left as they are.
+//
+// Each resource is updated with the resourceVersion it was read at, so that an update made by
+// another controller in the meantime makes the write fail with a conflict instead of being
+// overwritten. The resource is then read again and the update is retried.
+type Linker struct {
+	Client client.Client
+	// ManagedAnnotation is the annotation that records the keys of the linked services that the
+	// controller added to a TerminatingGateway resource.
+	ManagedAnnotation string
+	Log               logr.Logger
+}
+
+// Key returns the key of the linked service in the managed annotation.
+func Key(linked v1alpha1.LinkedService) string {
+	return linked.Namespace + "/" + linked.Name
+}
+
+// Sync sets the services that the controller links to each TerminatingGateway resource. desired maps
+// the names of the gateways to their linked services, keyed by Key. The services that the controller
+// linked to gateways that aren't in desired are unlinked. A gateway in desired that doesn't exist is
+// created in the namespace returned by namespaceOf. Sync returns the sorted names of the gateways it
+// synced.
+func (l *Linker) Sync(ctx context.Context, desired map[string]map[string]v1alpha1.LinkedService, namespaceOf func(gateway string) string) ([]string, error) {
+	var gatewayList v1alpha1.TerminatingGatewayList
+	if err := l.Client.List(ctx, &gatewayList); err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range desired {
+		names = append(names, name)
+	}
+	seen := make(map[string]bool)
+	for _, gateway := range gatewayList.Items {
+		if _, ok := desired[gateway.Name]; ok || seen[gateway.Name] {
+			continue
+		}
+		seen[gateway.Name] = true
+		if gateway.Annotations[l.ManagedAnnotation] != "" {
+			names = append(names, gateway.Name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := l.update(ctx, name, namespaceOf(name), desired[name]); err != nil {
+			l.Log.Error(err, "failed to save TerminatingGateway resource", "name", name)
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// update reads the TerminatingGateway resource with the name, replaces the linked services that the
+// controller added with desired and writes it back, retrying if the resource changed since it was
+// read or was created by another controller in the meantime.
+func (l *Linker) update(ctx context.Context, name, namespace string, desired map[string]v1alpha1.LinkedService) error {
+	isConflict := func(err error) bool {
+		return k8serrors.IsConflict(err) || k8serrors.IsAlreadyExists(err)
+	}
+	return retry.OnError(retry.DefaultBackoff, isConflict, func() error {
+		gateway, err := l.get(ctx, name)
+		if err != nil {
+			return err
+		}
+		if gateway == nil {
+			gateway = &v1alpha1.TerminatingGateway{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			}
+		}
+		if !l.link(gateway, desired) {
+			return nil
+		}
+		if gateway.ResourceVersion == "" {
+			l.Log.Info("creating TerminatingGateway resource", "name", name)
+			return l.Client.Create(ctx, gateway)
+		}
+		l.Log.Info("updating TerminatingGateway resource", "name", name)
+		return l.Client.Update(ctx, gateway)
+	})
+}
+
+// get returns the first TerminatingGateway resource with the name, or nil if there isn't one.
+func (l *Linker) get(ctx context.Context, name string) (*v1alpha1.TerminatingGateway, error) {
+	var gatewayList v1alpha1.TerminatingGatewayList
+	if err := l.Client.List(ctx, &gatewayList); err != nil {
+		return nil, err
+	}
+	for i := range gatewayList.Items {
+		if gatewayList.Items[i].Name == name {
+			return &gatewayList.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// link keeps the linked services of the gateway that the controller didn't add and replaces the
+// ones that it did with desired. It returns false if the gateway doesn't need to be written.
+func (l *Linker) link(gateway *v1alpha1.TerminatingGateway, desired map[string]v1alpha1.LinkedService) bool {
+	managed := make(map[string]bool)
+	for _, key := range strings.Split(gateway.Annotations[l.ManagedAnnotation], ",") {
+		if key != "" {
+			managed[key] = true
+		}
+	}
+
+	var services []v1alpha1.LinkedService
+	otherKeys := make(map[string]bool)
+	for _, linked := range gateway.Spec.Services {
+		key := Key(linked)
+		if managed[key] {
+			continue
+		}
+		services = append(services, linked)
+		otherKeys[key] = true
+	}
+	var managedKeys []string
+	for key := range desired {
+		if otherKeys[key] {
+			l.Log.Info("service is already linked to the TerminatingGateway resource, not managing it", "service", key, "gateway", gateway.Name)
+			continue
+		}
+		managedKeys = append(managedKeys, key)
+	}
+	sort.Strings(managedKeys)
+	for _, key := range managedKeys {
+		services = append(services, desired[key])
+	}
+
+	annotation := strings.Join(managedKeys, ",")
+	if gateway.ResourceVersion == "" && len(services) == 0 {
+		return false
+	}
+	if gateway.ResourceVersion != "" &&
+		reflect.DeepEqual(services, gateway.Spec.Services) &&
+		annotation == gateway.Annotations[l.ManagedAnnotation] {
+		return false
+	}
+	gateway.Spec.Services = services
+	if gateway.Annotations == nil {
+		gateway.Annotations = map[string]string{}
+	}
+	gateway.Annotations[l.ManagedAnnotation] = annotation
+	return true
+}
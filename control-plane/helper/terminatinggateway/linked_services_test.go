@@ -0,0 +1,118 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package terminatinggateway
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testr"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+)
+
+func TestLinker_Sync(t *testing.T) {
+	gateway := &v1alpha1.TerminatingGateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "terminating-gateway", Namespace: "default"},
+		Spec: v1alpha1.TerminatingGatewaySpec{
+			Services: []v1alpha1.LinkedService{{Name: "user", Namespace: "default"}},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme(t)).WithRuntimeObjects(gateway).Build()
+	egress := &Linker{Client: c, ManagedAnnotation: "egress", Log: logrtest.New(t)}
+	external := &Linker{Client: c, ManagedAnnotation: "external", Log: logrtest.New(t)}
+
+	names, err := egress.Sync(context.Background(), desired("terminating-gateway", "payments"), inNamespace("default"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"terminating-gateway"}, names)
+	_, err = external.Sync(context.Background(), desired("terminating-gateway", "billing", "user"), inNamespace("default"))
+	require.NoError(t, err)
+	requireLinked(t, c, "default", "user", "payments", "billing")
+
+	// Unlinking the services of one controller keeps the ones of the other and of users.
+	names, err = egress.Sync(context.Background(), nil, inNamespace("default"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"terminating-gateway"}, names)
+	requireLinked(t, c, "default", "user", "billing")
+
+	// A gateway that doesn't exist is created in the namespace for it.
+	_, err = external.Sync(context.Background(), desired("other-gateway", "billing"), inNamespace("consul"))
+	require.NoError(t, err)
+	var other v1alpha1.TerminatingGateway
+	require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "other-gateway", Namespace: "consul"}, &other))
+	require.Equal(t, "default/billing", other.Annotations["external"])
+}
+
+// Test that an update that conflicts with an update by another controller is retried on the
+// latest version of the resource instead of overwriting it.
+func TestLinker_SyncRetriesConflicts(t *testing.T) {
+	gateway := &v1alpha1.TerminatingGateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "terminating-gateway", Namespace: "default"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme(t)).WithRuntimeObjects(gateway).Build()
+	other := &Linker{Client: c, ManagedAnnotation: "egress", Log: logrtest.New(t)}
+	racing := &racingClient{Client: c, race: func() {
+		_, err := other.Sync(context.Background(), desired("terminating-gateway", "payments"), inNamespace("default"))
+		require.NoError(t, err)
+	}}
+	linker := &Linker{Client: racing, ManagedAnnotation: "external", Log: logrtest.New(t)}
+
+	_, err := linker.Sync(context.Background(), desired("terminating-gateway", "billing"), inNamespace("default"))
+	require.NoError(t, err)
+	require.Equal(t, 2, racing.updates)
+	requireLinked(t, c, "default", "payments", "billing")
+}
+
+// racingClient runs race before the first update, after the resource was read.
+type racingClient struct {
+	client.Client
+	race    func()
+	updates int
+}
+
+func (c *racingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.updates++
+	if c.updates == 1 {
+		c.race()
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func desired(gateway string, names ...string) map[string]map[string]v1alpha1.LinkedService {
+	services := make(map[string]v1alpha1.LinkedService)
+	for _, name := range names {
+		linked := v1alpha1.LinkedService{Name: name, Namespace: "default"}
+		services[Key(linked)] = linked
+	}
+	return map[string]map[string]v1alpha1.LinkedService{gateway: services}
+}
+
+func inNamespace(namespace string) func(string) string {
+	return func(string) string { return namespace }
+}
+
+func requireLinked(t *testing.T, c client.Client, namespace string, names ...string) {
+	t.Helper()
+	var gateway v1alpha1.TerminatingGateway
+	require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "terminating-gateway", Namespace: namespace}, &gateway))
+	var linked []string
+	for _, service := range gateway.Spec.Services {
+		linked = append(linked, service.Name)
+	}
+	require.Equal(t, names, linked)
+}
+
+func scheme(t *testing.T) *runtime.Scheme {
+	s := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(s))
+	require.NoError(t, v1alpha1.AddToScheme(s))
+	return s
+}
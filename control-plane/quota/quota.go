@@ -0,0 +1,101 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package quota enforces per-namespace quotas on the resources that Kubernetes
+// namespaces create in Consul, so that one team can't exhaust the capacity of
+// the Consul servers in a shared cluster.
+package quota
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// AnnotationMaxServices is the annotation on a namespace that limits the number of
+	// services that its pods register in Consul.
+	AnnotationMaxServices = "consul.hashicorp.com/quota-max-services"
+	// AnnotationMaxIntentions is the annotation on a namespace that limits the number of
+	// intentions that its ServiceIntentions resources configure.
+	AnnotationMaxIntentions = "consul.hashicorp.com/quota-max-intentions"
+	// AnnotationMaxExportedServices is the annotation on a namespace that limits the number
+	// of its services that are exported. It's only enforced with namespace mirroring, where
+	// the services of the namespace are registered in their own Consul namespace.
+	AnnotationMaxExportedServices = "consul.hashicorp.com/quota-max-exported-services"
+)
+
+// Limits are the quotas of a namespace. Zero means unlimited.
+type Limits struct {
+	MaxServices         int
+	MaxIntentions       int
+	MaxExportedServices int
+}
+
+// Policy is the quota policy that the webhooks enforce. Namespaces can override
+// the default limits with annotations.
+type Policy struct {
+	// Defaults are the limits of namespaces that don't have quota annotations.
+	Defaults Limits
+}
+
+// Limits returns the limits of the namespace. A nil namespace has the default limits.
+func (p *Policy) Limits(namespace *corev1.Namespace) (Limits, error) {
+	limits := p.Defaults
+	if namespace == nil {
+		return limits, nil
+	}
+	for annotation, limit := range map[string]*int{
+		AnnotationMaxServices:         &limits.MaxServices,
+		AnnotationMaxIntentions:       &limits.MaxIntentions,
+		AnnotationMaxExportedServices: &limits.MaxExportedServices,
+	} {
+		raw, ok := namespace.Annotations[annotation]
+		if !ok {
+			continue
+		}
+		val, err := strconv.Atoi(raw)
+		if err != nil || val < 0 {
+			return Limits{}, fmt.Errorf("invalid annotation %q on namespace %s: %q must be a non-negative integer", annotation, namespace.Name, raw)
+		}
+		*limit = val
+	}
+	return limits, nil
+}
+
+// Check returns an error if a namespace with the limit would have count resources of the
+// kind, and the request adds some of them, i.e. count is more than previous. Requests that
+// don't add resources are allowed even if the namespace is over its quota, e.g. because
+// the quota was lowered, so that resources can still be updated and cleaned up.
+func Check(namespace, kind string, limit, previous, count int) error {
+	if limit == 0 || count <= limit || count <= previous {
+		return nil
+	}
+	return fmt.Errorf("namespace %s would have %d %s, which exceeds its quota of %d", namespace, count, kind, limit)
+}
+
+// ServiceNames returns the names of the Consul services that the pod registers. Like the
+// endpoints controller, they are the services of the pod's annotation if it's set, or else
+// the Kubernetes services that select the pod.
+func ServiceNames(pod corev1.Pod, annotation string, services []corev1.Service) []string {
+	if raw := pod.Annotations[annotation]; raw != "" {
+		var names []string
+		for _, name := range strings.Split(raw, ",") {
+			names = append(names, strings.TrimSpace(name))
+		}
+		return names
+	}
+	var names []string
+	for _, svc := range services {
+		if len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		if labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			names = append(names, svc.Name)
+		}
+	}
+	return names
+}
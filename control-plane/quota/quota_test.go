@@ -0,0 +1,105 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package quota
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPolicy_Limits(t *testing.T) {
+	policy := &Policy{Defaults: Limits{MaxServices: 10, MaxIntentions: 20}}
+
+	cases := map[string]struct {
+		annotations map[string]string
+		expLimits   Limits
+		expErr      string
+	}{
+		"defaults": {
+			expLimits: Limits{MaxServices: 10, MaxIntentions: 20},
+		},
+		"annotations override the defaults": {
+			annotations: map[string]string{
+				AnnotationMaxServices:         "0",
+				AnnotationMaxExportedServices: "5",
+			},
+			expLimits: Limits{MaxIntentions: 20, MaxExportedServices: 5},
+		},
+		"invalid annotation": {
+			annotations: map[string]string{AnnotationMaxIntentions: "-1"},
+			expErr:      `invalid annotation "consul.hashicorp.com/quota-max-intentions" on namespace team-a: "-1" must be a non-negative integer`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			limits, err := policy.Limits(&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "team-a", Annotations: c.annotations},
+			})
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expLimits, limits)
+		})
+	}
+
+	limits, err := policy.Limits(nil)
+	require.NoError(t, err)
+	require.Equal(t, policy.Defaults, limits)
+}
+
+func TestCheck(t *testing.T) {
+	cases := map[string]struct {
+		limit    int
+		previous int
+		count    int
+		expErr   string
+	}{
+		"unlimited": {
+			limit: 0, previous: 100, count: 101,
+		},
+		"within the quota": {
+			limit: 3, previous: 2, count: 3,
+		},
+		"exceeds the quota": {
+			limit: 3, previous: 3, count: 4,
+			expErr: "namespace team-a would have 4 services, which exceeds its quota of 3",
+		},
+		"over the quota without adding": {
+			limit: 3, previous: 5, count: 4,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := Check("team-a", "services", c.limit, c.previous, c.count)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestServiceNames(t *testing.T) {
+	services := []corev1.Service{
+		{ObjectMeta: metav1.ObjectMeta{Name: "web"}, Spec: corev1.ServiceSpec{Selector: map[string]string{"app": "web"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-canary"}, Spec: corev1.ServiceSpec{Selector: map[string]string{"app": "web", "track": "canary"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "external"}},
+	}
+	const annotation = "consul.hashicorp.com/connect-service"
+
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}}}
+	require.Equal(t, []string{"web"}, ServiceNames(pod, annotation, services))
+
+	pod.Labels["track"] = "canary"
+	require.Equal(t, []string{"web", "web-canary"}, ServiceNames(pod, annotation, services))
+
+	pod.Annotations = map[string]string{annotation: "web-admin, web-api"}
+	require.Equal(t, []string{"web-admin", "web-api"}, ServiceNames(pod, annotation, services))
+}
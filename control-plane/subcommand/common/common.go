@@ -6,6 +6,8 @@ package common
 
 import (
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
@@ -27,6 +29,11 @@ const (
 	// create-federation-secret commands and so lives in this common package.
 	ACLReplicationTokenName = "acl-replication"
 
+	// ACLReplicationPreviousTokenAnnotation is set on the ACL replication token secret by server-acl-init
+	// to the accessor ID of the token replaced by a rotation. create-federation-secret deletes that token
+	// and removes the annotation once the secondary datacenters have switched to the new token.
+	ACLReplicationPreviousTokenAnnotation = "consul.hashicorp.com/previous-token-accessor-id"
+
 	// ACLTokenSecretKey is the key that we store the ACL tokens in when we
 	// create Kubernetes secrets.
 	ACLTokenSecretKey = "token"
@@ -92,6 +99,21 @@ func ValidateUnprivilegedPort(flagName, flagValue string) error {
 	return nil
 }
 
+// LocalOnlyHandler serves requests with h only if they come from the loopback interface, and
+// rejects them with 403 Forbidden otherwise. It protects endpoints that trigger actions when they
+// are served on an address that other pods can reach, e.g. the metrics address. Such endpoints can
+// still be called with kubectl exec or kubectl port-forward, which connect from inside the pod.
+func LocalOnlyHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(rw, req)
+	})
+}
+
 // LoginParams are parameters used to log in to consul.
 type LoginParams struct {
 	// AuthMethod is the name of the auth method.
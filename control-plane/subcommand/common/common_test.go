@@ -53,6 +53,30 @@ func TestValidateUnprivilegedPort(t *testing.T) {
 }
 
 // TestConsulLogin ensures that our implementation of consul login hits `/v1/acl/login`.
+func TestLocalOnlyHandler(t *testing.T) {
+	cases := map[string]struct {
+		remoteAddr string
+		expStatus  int
+	}{
+		"IPv4 loopback":  {remoteAddr: "127.0.0.1:52000", expStatus: http.StatusAccepted},
+		"IPv6 loopback":  {remoteAddr: "[::1]:52000", expStatus: http.StatusAccepted},
+		"pod IP":         {remoteAddr: "10.0.0.5:52000", expStatus: http.StatusForbidden},
+		"invalid remote": {remoteAddr: "pod", expStatus: http.StatusForbidden},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			handler := LocalOnlyHandler(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				rw.WriteHeader(http.StatusAccepted)
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/full-sync", nil)
+			req.RemoteAddr = c.remoteAddr
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			require.Equal(t, c.expStatus, rec.Code)
+		})
+	}
+}
+
 func TestConsulLogin(t *testing.T) {
 	t.Parallel()
 
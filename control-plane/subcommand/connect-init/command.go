@@ -9,6 +9,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"os/signal"
@@ -51,10 +52,19 @@ type Command struct {
 	flagRedirectTrafficConfig string
 	flagLogLevel              string
 	flagLogJSON               bool
+	flagEnableIPv6            bool
 
 	flagProxyIDFile string // Location to write the output proxyID. Default is defaultProxyIDFile.
 	flagMultiPort   bool
 
+	// Flags to configure how long and how often connect-init retries connecting to the Consul servers.
+	flagServerWaitTimeout         time.Duration
+	flagServerWaitInitialInterval time.Duration
+	flagServerWaitMaxInterval     time.Duration
+	flagServerWaitJitter          float64
+
+	flagTerminationMessageFile string
+
 	serviceRegistrationPollingAttempts uint64 // Number of times to poll for this service to be registered.
 
 	flagSet *flag.FlagSet
@@ -89,6 +99,21 @@ func (c *Command) init() {
 			"\"debug\", \"info\", \"warn\", and \"error\".")
 	c.flagSet.BoolVar(&c.flagLogJSON, "log-json", false,
 		"Enable or disable JSON output format for logging.")
+	c.flagSet.BoolVar(&c.flagEnableIPv6, "enable-ipv6", false,
+		"Apply traffic redirection rules with ip6tables for IPv6-only pods.")
+	c.flagSet.DurationVar(&c.flagServerWaitTimeout, "server-wait-timeout", 0,
+		"Maximum time to wait to connect and, if ACLs are enabled, log in to the Consul servers. "+
+			"If 0, connect-init waits indefinitely.")
+	c.flagSet.DurationVar(&c.flagServerWaitInitialInterval, "server-wait-initial-interval", discovery.DefaultBackOffInitialInterval,
+		"Time to wait before retrying to connect to the Consul servers the first time. It grows exponentially on each retry.")
+	c.flagSet.DurationVar(&c.flagServerWaitMaxInterval, "server-wait-max-interval", discovery.DefaultBackOffMaxInterval,
+		"Maximum time to wait between retries to connect to the Consul servers.")
+	c.flagSet.Float64Var(&c.flagServerWaitJitter, "server-wait-jitter", discovery.DefaultBackOffRandomizationFactor,
+		"Fraction by which each time to wait between retries to connect to the Consul servers is randomized. "+
+			"Must be greater than 0 and at most 1.")
+	c.flagSet.StringVar(&c.flagTerminationMessageFile, "termination-message-file", "",
+		"File to write the progress and failure reason of connect-init to so that they're shown in the pod's status. "+
+			"This should be the container's terminationMessagePath.")
 
 	if c.serviceRegistrationPollingAttempts == 0 {
 		c.serviceRegistrationPollingAttempts = defaultServicePollingRetries
@@ -132,12 +157,22 @@ func (c *Command) Run(args []string) int {
 	serverConnMgrCfg, err := c.consul.ConsulServerConnMgrConfig()
 	// Disable server watch because we only need to get server IPs once.
 	serverConnMgrCfg.ServerWatchDisabled = true
+	serverConnMgrCfg.BackOff = c.serverWaitBackOff()
 	if err != nil {
 		c.UI.Error(fmt.Sprintf("unable to create config for consul-server-connection-manager: %s", err))
 		return 1
 	}
+	progress := newServerWaitProgress(c.logger, c.writeTerminationMessage)
 	if c.watcher == nil {
-		c.watcher, err = discovery.NewWatcher(ctx, serverConnMgrCfg, c.logger.Named("consul-server-connection-manager"))
+		// The connection manager only reports why it can't connect in its logs, so they're intercepted
+		// to track its progress.
+		watcherLogger := hclog.NewInterceptLogger(&hclog.LoggerOptions{
+			Name:   "consul-server-connection-manager",
+			Level:  c.logger.GetLevel(),
+			Output: io.Discard,
+		})
+		watcherLogger.RegisterSink(progress)
+		c.watcher, err = discovery.NewWatcher(ctx, serverConnMgrCfg, watcherLogger)
 		if err != nil {
 			c.UI.Error(fmt.Sprintf("unable to create Consul server watcher: %s", err))
 			return 1
@@ -146,9 +181,10 @@ func (c *Command) Run(args []string) int {
 		defer c.watcher.Stop()
 	}
 
-	state, err := c.watcher.State()
+	state, err := c.waitForConsulServers(progress)
 	if err != nil {
 		c.logger.Error("Unable to get state from consul-server-connection-manager", "error", err)
+		c.writeTerminationMessage(err.Error())
 		return 1
 	}
 
@@ -179,20 +215,24 @@ func (c *Command) Run(args []string) int {
 		err = backoff.Retry(c.getGatewayRegistration(consulClient), backoff.WithMaxRetries(backoff.NewConstantBackOff(1*time.Second), c.serviceRegistrationPollingAttempts))
 		if err != nil {
 			c.logger.Error("Timed out waiting for gateway registration", "error", err)
+			c.writeTerminationMessage("Timed out waiting for gateway registration, last error: " + failureDescription(err))
 			return 1
 		}
 		if c.nonRetryableError != nil {
 			c.logger.Error("Error processing gateway registration", "error", c.nonRetryableError)
+			c.writeTerminationMessage(fmt.Sprintf("Error processing gateway registration: %s", c.nonRetryableError))
 			return 1
 		}
 	} else {
 		var err = backoff.Retry(c.getConnectServiceRegistrations(consulClient, proxyService), backoff.WithMaxRetries(backoff.NewConstantBackOff(1*time.Second), c.serviceRegistrationPollingAttempts))
 		if err != nil {
 			c.logger.Error("Timed out waiting for service registration", "error", err)
+			c.writeTerminationMessage("Timed out waiting for service registration, last error: " + failureDescription(err))
 			return 1
 		}
 		if c.nonRetryableError != nil {
 			c.logger.Error("Error processing service registration", "error", c.nonRetryableError)
+			c.writeTerminationMessage(fmt.Sprintf("Error processing service registration: %s", c.nonRetryableError))
 			return 1
 		}
 	}
@@ -214,6 +254,7 @@ func (c *Command) Run(args []string) int {
 	}
 
 	c.logger.Info("Connect initialization completed")
+	c.writeTerminationMessage("Connect initialization completed")
 	return 0
 }
 
@@ -365,6 +406,18 @@ func (c *Command) validateFlags() error {
 	if c.flagConsulNodeName == "" {
 		return errors.New("-consul-node-name must be set")
 	}
+	if c.flagServerWaitTimeout < 0 {
+		return errors.New("-server-wait-timeout must not be negative")
+	}
+	if c.flagServerWaitInitialInterval <= 0 {
+		return errors.New("-server-wait-initial-interval must be greater than 0")
+	}
+	if c.flagServerWaitMaxInterval < c.flagServerWaitInitialInterval {
+		return errors.New("-server-wait-max-interval must be at least -server-wait-initial-interval")
+	}
+	if c.flagServerWaitJitter <= 0 || c.flagServerWaitJitter > 1 {
+		return errors.New("-server-wait-jitter must be greater than 0 and at most 1")
+	}
 
 	return nil
 }
@@ -393,6 +446,8 @@ func (c *Command) applyTrafficRedirectionRules(svc *api.AgentService) error {
 	}
 	if c.iptablesProvider != nil {
 		c.iptablesConfig.IptablesProvider = c.iptablesProvider
+	} else if c.flagEnableIPv6 {
+		c.iptablesConfig.IptablesProvider = &ip6tablesProvider{dnsPort: c.iptablesConfig.ConsulDNSPort}
 	}
 
 	if svc.Proxy.TransparentProxy != nil && svc.Proxy.TransparentProxy.OutboundListenerPort != 0 {
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"net"
 	"os"
 	"strconv"
 	"strings"
@@ -63,6 +64,42 @@ func TestRun_FlagValidation(t *testing.T) {
 			},
 			expErr: "unknown log level: invalid",
 		},
+		{
+			flags: []string{
+				"-pod-name", testPodName,
+				"-pod-namespace", testPodNamespace,
+				"-consul-node-name", "bar",
+				"-server-wait-timeout", "-1s",
+			},
+			expErr: "-server-wait-timeout must not be negative",
+		},
+		{
+			flags: []string{
+				"-pod-name", testPodName,
+				"-pod-namespace", testPodNamespace,
+				"-consul-node-name", "bar",
+				"-server-wait-initial-interval", "0s",
+			},
+			expErr: "-server-wait-initial-interval must be greater than 0",
+		},
+		{
+			flags: []string{
+				"-pod-name", testPodName,
+				"-pod-namespace", testPodNamespace,
+				"-consul-node-name", "bar",
+				"-server-wait-initial-interval", "2s", "-server-wait-max-interval", "1s",
+			},
+			expErr: "-server-wait-max-interval must be at least -server-wait-initial-interval",
+		},
+		{
+			flags: []string{
+				"-pod-name", testPodName,
+				"-pod-namespace", testPodNamespace,
+				"-consul-node-name", "bar",
+				"-server-wait-jitter", "1.5",
+			},
+			expErr: "-server-wait-jitter must be greater than 0 and at most 1",
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.expErr, func(t *testing.T) {
@@ -737,6 +774,41 @@ func TestRun_InvalidProxyFile(t *testing.T) {
 	require.Error(t, err)
 }
 
+// TestRun_ServerWaitTimeout tests that the command gives up waiting for Consul servers that it can't connect to
+// after -server-wait-timeout and writes why to the termination message file.
+func TestRun_ServerWaitTimeout(t *testing.T) {
+	t.Parallel()
+	// Get a port that nothing is listening on.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	terminationMessageFile := common.WriteTempFile(t, "")
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI: ui,
+	}
+	flags := []string{
+		"-pod-name", testPodName,
+		"-pod-namespace", testPodNamespace,
+		"-addresses", "127.0.0.1",
+		"-grpc-port", strconv.Itoa(port),
+		"-consul-node-name", nodeName,
+		"-server-wait-timeout", "2s",
+		"-server-wait-initial-interval", "100ms",
+		"-server-wait-max-interval", "200ms",
+		"-termination-message-file", terminationMessageFile,
+	}
+	code := cmd.Run(flags)
+	require.Equal(t, 1, code)
+
+	message, err := os.ReadFile(terminationMessageFile)
+	require.NoError(t, err)
+	require.Contains(t, string(message), "Timed out after 2s waiting for Consul servers")
+	require.Contains(t, string(message), "last error: connection error")
+}
+
 func TestRun_TrafficRedirection(t *testing.T) {
 	cases := map[string]struct {
 		proxyConfig           map[string]interface{}
@@ -0,0 +1,93 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package connectinit
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	ipv4Localhost     = "127.0.0.1"
+	ipv4LocalhostCIDR = "127.0.0.1/32"
+	ipv6Localhost     = "::1"
+	ipv6LocalhostCIDR = "::1/128"
+)
+
+// ip6tablesProvider implements iptables.Provider for IPv6-only pods. The
+// iptables SDK only knows how to write IPv4 rules, so each rule it adds is
+// translated to its ip6tables equivalent: the IPv4 loopback address is
+// swapped for the IPv6 one and DNAT destinations are written as [host]:port.
+type ip6tablesProvider struct {
+	// dnsPort is the port of the Consul DNS server, if DNS traffic is being
+	// redirected to the local Consul dataplane. The SDK appends it to the DNAT
+	// destination, which is ambiguous for IPv6 addresses without brackets.
+	dnsPort  int
+	commands []*exec.Cmd
+}
+
+func (p *ip6tablesProvider) AddRule(_ string, args ...string) {
+	p.commands = append(p.commands, exec.Command("ip6tables", translateIPv6RuleArgs(args, p.dnsPort)...))
+}
+
+func (p *ip6tablesProvider) ApplyRules() error {
+	if _, err := exec.LookPath("ip6tables"); err != nil {
+		return err
+	}
+
+	for _, cmd := range p.commands {
+		var cmdOutput bytes.Buffer
+		cmd.Stdout = &cmdOutput
+		cmd.Stderr = &cmdOutput
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to run command: %s, err: %v, output: %s", cmd.String(), err, cmdOutput.String())
+		}
+	}
+	return nil
+}
+
+func (p *ip6tablesProvider) Rules() []string {
+	var rules []string
+	for _, cmd := range p.commands {
+		rules = append(rules, cmd.String())
+	}
+	return rules
+}
+
+// translateIPv6RuleArgs returns a copy of the arguments of an iptables rule
+// that is valid for ip6tables.
+func translateIPv6RuleArgs(args []string, dnsPort int) []string {
+	translated := make([]string, len(args))
+	for i, arg := range args {
+		switch {
+		case arg == ipv4LocalhostCIDR:
+			arg = ipv6LocalhostCIDR
+		case arg == ipv4Localhost:
+			arg = ipv6Localhost
+		case i > 0 && args[i-1] == "--to-destination":
+			arg = ipv6Destination(arg, dnsPort)
+		}
+		translated[i] = arg
+	}
+	return translated
+}
+
+// ipv6Destination formats a DNAT destination such as "::1:8600" as
+// "[::1]:8600" when it ends with the Consul DNS port. Other destinations are
+// addresses without a port and are returned unchanged.
+func ipv6Destination(dest string, dnsPort int) string {
+	suffix := ":" + strconv.Itoa(dnsPort)
+	if dnsPort == 0 || strings.HasPrefix(dest, "[") || !strings.HasSuffix(dest, suffix) {
+		return dest
+	}
+	host := strings.TrimSuffix(dest, suffix)
+	if host == ipv4Localhost {
+		host = ipv6Localhost
+	}
+	return net.JoinHostPort(host, strconv.Itoa(dnsPort))
+}
@@ -0,0 +1,50 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package connectinit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIP6tablesProvider_AddRule(t *testing.T) {
+	cases := map[string]struct {
+		args    []string
+		dnsPort int
+		expRule string
+	}{
+		"rule without addresses is unchanged": {
+			args:    []string{"-t", "nat", "-A", "CONSUL_PROXY_OUTPUT", "-p", "tcp", "-j", "REDIRECT", "--to-port", "15001"},
+			expRule: "ip6tables -t nat -A CONSUL_PROXY_OUTPUT -p tcp -j REDIRECT --to-port 15001",
+		},
+		"loopback CIDR": {
+			args:    []string{"-t", "nat", "-A", "CONSUL_PROXY_OUTPUT", "-d", "127.0.0.1/32", "-j", "RETURN"},
+			expRule: "ip6tables -t nat -A CONSUL_PROXY_OUTPUT -d ::1/128 -j RETURN",
+		},
+		"loopback DNS destination": {
+			args:    []string{"-t", "nat", "-A", "CONSUL_DNS_REDIRECT", "-p", "udp", "-d", "127.0.0.1", "--dport", "53", "-j", "DNAT", "--to-destination", "127.0.0.1:8600"},
+			dnsPort: 8600,
+			expRule: "ip6tables -t nat -A CONSUL_DNS_REDIRECT -p udp -d ::1 --dport 53 -j DNAT --to-destination [::1]:8600",
+		},
+		"IPv6 DNS destination with port": {
+			args:    []string{"-t", "nat", "-A", "CONSUL_DNS_REDIRECT", "-p", "tcp", "-d", "::1", "--dport", "53", "-j", "DNAT", "--to-destination", "::1:8600"},
+			dnsPort: 8600,
+			expRule: "ip6tables -t nat -A CONSUL_DNS_REDIRECT -p tcp -d ::1 --dport 53 -j DNAT --to-destination [::1]:8600",
+		},
+		"Consul DNS service IP without port": {
+			args:    []string{"-t", "nat", "-A", "CONSUL_DNS_REDIRECT", "-p", "udp", "--dport", "53", "-j", "DNAT", "--to-destination", "fd00::10"},
+			expRule: "ip6tables -t nat -A CONSUL_DNS_REDIRECT -p udp --dport 53 -j DNAT --to-destination fd00::10",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			provider := &ip6tablesProvider{dnsPort: c.dnsPort}
+			provider.AddRule("iptables", c.args...)
+			rules := provider.Rules()
+			require.Len(t, rules, 1)
+			require.Contains(t, rules[0], c.expRule)
+		})
+	}
+}
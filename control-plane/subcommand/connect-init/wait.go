@@ -0,0 +1,204 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package connectinit
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul-server-connection-manager/discovery"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxTerminationMessageLength is the maximum length of a container's termination message.
+const maxTerminationMessageLength = 4096
+
+// failureReason classifies why connect-init is unable to reach Consul.
+type failureReason string
+
+const (
+	failureReasonDNS        failureReason = "DNS"
+	failureReasonTLS        failureReason = "TLS"
+	failureReasonACL        failureReason = "ACL"
+	failureReasonConnection failureReason = "connection"
+	failureReasonUnknown    failureReason = "unknown"
+)
+
+// classifyFailure returns whether err is caused by resolving the Consul server addresses, by the TLS
+// handshake with the servers, by ACLs, or by connecting to the servers.
+func classifyFailure(err error) failureReason {
+	if err == nil {
+		return failureReasonUnknown
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return failureReasonDNS
+	}
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certInvalidErr x509.CertificateInvalidError
+	if errors.As(err, &unknownAuthorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &certInvalidErr) {
+		return failureReasonTLS
+	}
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) && statusErr.Code == http.StatusForbidden {
+		return failureReasonACL
+	}
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.PermissionDenied, codes.Unauthenticated:
+			return failureReasonACL
+		}
+	}
+
+	// gRPC errors only keep the message of their cause.
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "no such host"):
+		return failureReasonDNS
+	case strings.Contains(msg, "x509:"), strings.Contains(msg, "tls:"), strings.Contains(msg, "authentication handshake failed"):
+		return failureReasonTLS
+	case strings.Contains(msg, "ACL not found"), strings.Contains(msg, "Permission denied"):
+		return failureReasonACL
+	case strings.Contains(msg, "connection refused"), strings.Contains(msg, "i/o timeout"),
+		strings.Contains(msg, "no route to host"), strings.Contains(msg, "unable to connect to a Consul server"):
+		return failureReasonConnection
+	}
+	return failureReasonUnknown
+}
+
+// failureDescription describes err along with its failure reason, if it's known.
+func failureDescription(err error) string {
+	if reason := classifyFailure(err); reason != failureReasonUnknown {
+		return fmt.Sprintf("%s error: %s", reason, err)
+	}
+	return err.Error()
+}
+
+// serverWaitProgress tracks the attempts of the Consul server connection manager to connect and log in
+// to the Consul servers. It's registered as a sink of the connection manager's logger, which is the only
+// way the connection manager reports its errors, and forwards its logs to the command's logger.
+type serverWaitProgress struct {
+	log hclog.Logger
+	// report is called with a description of the progress after each failed attempt.
+	report func(string)
+
+	mu       sync.Mutex
+	start    time.Time
+	attempts int
+	lastErr  error
+}
+
+func newServerWaitProgress(log hclog.Logger, report func(string)) *serverWaitProgress {
+	return &serverWaitProgress{log: log, report: report, start: time.Now()}
+}
+
+// Accept implements hclog.SinkAdapter.
+func (p *serverWaitProgress) Accept(name string, level hclog.Level, msg string, args ...interface{}) {
+	p.log.ResetNamed(name).Log(level, msg, args...)
+	if level < hclog.Error {
+		return
+	}
+
+	var err error
+	for i := 0; i+1 < len(args); i += 2 {
+		if key, ok := args[i].(string); ok && key == "error" {
+			err, _ = args[i+1].(error)
+		}
+	}
+	if err == nil {
+		return
+	}
+	// A failed login is logged before it's returned as a connection error, so it's only counted once.
+	if msg == "ACL auth method login failed" {
+		p.mu.Lock()
+		p.lastErr = err
+		p.mu.Unlock()
+		return
+	}
+
+	p.mu.Lock()
+	p.attempts++
+	p.lastErr = err
+	message := p.message("Waiting for Consul servers")
+	p.mu.Unlock()
+	p.report(message)
+}
+
+// timeoutError returns the error that connect-init exits with when the connection manager doesn't
+// connect to a Consul server within timeout.
+func (p *serverWaitProgress) timeoutError(timeout time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return errors.New(p.message(fmt.Sprintf("Timed out after %s waiting for Consul servers", timeout)))
+}
+
+func (p *serverWaitProgress) message(prefix string) string {
+	message := fmt.Sprintf("%s: %d failed attempts in %s", prefix, p.attempts, time.Since(p.start).Round(time.Second))
+	if p.lastErr != nil {
+		message += ", last error: " + failureDescription(p.lastErr)
+	}
+	return message
+}
+
+// serverWaitBackOff returns the backoff that the connection manager retries connecting to the Consul servers with.
+func (c *Command) serverWaitBackOff() discovery.BackOffConfig {
+	return discovery.BackOffConfig{
+		InitialInterval:     c.flagServerWaitInitialInterval,
+		MaxInterval:         c.flagServerWaitMaxInterval,
+		RandomizationFactor: c.flagServerWaitJitter,
+	}
+}
+
+// waitForConsulServers waits until the connection manager is connected and, if ACLs are enabled, logged in to a
+// Consul server, or until -server-wait-timeout has passed.
+func (c *Command) waitForConsulServers(progress *serverWaitProgress) (discovery.State, error) {
+	type result struct {
+		state discovery.State
+		err   error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		state, err := c.watcher.State()
+		ch <- result{state: state, err: err}
+	}()
+
+	var timeout <-chan time.Time
+	if c.flagServerWaitTimeout > 0 {
+		timer := time.NewTimer(c.flagServerWaitTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case r := <-ch:
+		return r.state, r.err
+	case <-timeout:
+		return discovery.State{}, progress.timeoutError(c.flagServerWaitTimeout)
+	}
+}
+
+// writeTerminationMessage writes message to -termination-message-file so that the progress of connect-init
+// is shown in the pod's status if it's stuck or fails.
+func (c *Command) writeTerminationMessage(message string) {
+	if c.flagTerminationMessageFile == "" {
+		return
+	}
+	if len(message) > maxTerminationMessageLength {
+		message = message[:maxTerminationMessageLength]
+	}
+	if err := os.WriteFile(c.flagTerminationMessageFile, []byte(message), 0644); err != nil {
+		c.logger.Debug("Unable to write termination message", "error", err)
+	}
+}
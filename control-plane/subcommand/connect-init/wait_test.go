@@ -0,0 +1,66 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package connectinit
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	cases := map[string]struct {
+		err       error
+		expReason failureReason
+	}{
+		"dns error": {
+			err:       fmt.Errorf("failed to discover Consul server addresses: %w", &net.DNSError{Err: "no such host", Name: "consul-server"}),
+			expReason: failureReasonDNS,
+		},
+		"dns error in gRPC error": {
+			err:       status.Error(codes.Unavailable, `dial tcp: lookup consul-server on 10.0.0.10:53: no such host`),
+			expReason: failureReasonDNS,
+		},
+		"certificate error": {
+			err:       fmt.Errorf("failed to switch to Consul server: %w", x509.UnknownAuthorityError{}),
+			expReason: failureReasonTLS,
+		},
+		"tls error in gRPC error": {
+			err:       status.Error(codes.Unavailable, `authentication handshake failed: x509: certificate signed by unknown authority`),
+			expReason: failureReasonTLS,
+		},
+		"permission denied gRPC error": {
+			err:       status.Error(codes.PermissionDenied, "Permission denied"),
+			expReason: failureReasonACL,
+		},
+		"unauthenticated gRPC error": {
+			err:       status.Error(codes.Unauthenticated, "lookup failed: bearer token is invalid"),
+			expReason: failureReasonACL,
+		},
+		"forbidden HTTP error": {
+			err:       api.StatusError{Code: 403, Body: "ACL not found"},
+			expReason: failureReasonACL,
+		},
+		"connection refused": {
+			err:       status.Error(codes.Unavailable, `dial tcp 127.0.0.1:8502: connect: connection refused`),
+			expReason: failureReasonConnection,
+		},
+		"other error": {
+			err:       errors.New("did not find correct number of services, found: 0"),
+			expReason: failureReasonUnknown,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.expReason, classifyFailure(c.err))
+		})
+	}
+}
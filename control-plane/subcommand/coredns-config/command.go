@@ -0,0 +1,232 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package corednsconfig
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/mitchellh/cli"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// corefileKey is the key of the CoreDNS ConfigMap that holds the Corefile.
+	corefileKey = "Corefile"
+
+	// blockStart and blockEnd delimit the server block that this command
+	// manages in the Corefile so that it can be updated and removed without
+	// touching the rest of the Corefile.
+	blockStart = "# BEGIN consul-k8s managed block. Do not edit."
+	blockEnd   = "# END consul-k8s managed block."
+)
+
+type Command struct {
+	UI cli.Ui
+
+	flags *flag.FlagSet
+	k8s   *flags.K8SFlags
+
+	flagDNSServiceName      string
+	flagDNSServiceNamespace string
+	flagConfigMapName       string
+	flagConfigMapNamespace  string
+	flagDomain              string
+	flagCleanup             bool
+
+	clientset kubernetes.Interface
+
+	once sync.Once
+	help string
+
+	ctx context.Context
+}
+
+func (c *Command) init() {
+	c.flags = flag.NewFlagSet("", flag.ContinueOnError)
+
+	c.flags.StringVar(&c.flagDNSServiceName, "dns-service-name", "",
+		"Name of the Kubernetes service that exposes Consul DNS.")
+	c.flags.StringVar(&c.flagDNSServiceNamespace, "dns-service-namespace", "",
+		"Namespace of the Kubernetes service that exposes Consul DNS.")
+	c.flags.StringVar(&c.flagConfigMapName, "coredns-configmap-name", "coredns",
+		"Name of the ConfigMap that holds the CoreDNS Corefile.")
+	c.flags.StringVar(&c.flagConfigMapNamespace, "coredns-configmap-namespace", "kube-system",
+		"Namespace of the ConfigMap that holds the CoreDNS Corefile.")
+	c.flags.StringVar(&c.flagDomain, "domain", "consul",
+		"Consul DNS domain that CoreDNS forwards to the Consul DNS service.")
+	c.flags.BoolVar(&c.flagCleanup, "cleanup", false,
+		"Remove the Consul DNS forwarding from the Corefile instead of adding it.")
+
+	c.k8s = &flags.K8SFlags{}
+	flags.Merge(c.flags, c.k8s.Flags())
+	c.help = flags.Usage(help, c.flags)
+}
+
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	if err := c.flags.Parse(args); err != nil {
+		return 1
+	}
+	if err := c.validateFlags(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if c.ctx == nil {
+		c.ctx = context.Background()
+	}
+
+	// Create the Kubernetes clientset
+	if c.clientset == nil {
+		config, err := subcommand.K8SConfig(c.k8s.KubeConfig())
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error retrieving Kubernetes auth: %s", err))
+			return 1
+		}
+		c.clientset, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error initializing Kubernetes client: %s", err))
+			return 1
+		}
+	}
+
+	if c.flagCleanup {
+		if err := c.updateCorefile(removeConsulBlock); err != nil {
+			// Don't block uninstallation if the Corefile can't be cleaned up.
+			c.UI.Error(fmt.Sprintf("Error removing Consul DNS forwarding from the Corefile: %s", err))
+			return 0
+		}
+		c.UI.Info("Removed Consul DNS forwarding from the Corefile")
+		return 0
+	}
+
+	svc, err := c.clientset.CoreV1().Services(c.flagDNSServiceNamespace).Get(c.ctx, c.flagDNSServiceName, metav1.GetOptions{})
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error getting Consul DNS service: %s", err))
+		return 1
+	}
+	if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == corev1.ClusterIPNone {
+		c.UI.Error(fmt.Sprintf("Consul DNS service %q does not have a cluster IP", c.flagDNSServiceName))
+		return 1
+	}
+
+	err = c.updateCorefile(func(corefile string) string {
+		return addConsulBlock(corefile, c.flagDomain, svc.Spec.ClusterIP)
+	})
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error adding Consul DNS forwarding to the Corefile: %s", err))
+		return 1
+	}
+	c.UI.Info(fmt.Sprintf("Configured CoreDNS to forward %q to %s", c.flagDomain, svc.Spec.ClusterIP))
+	return 0
+}
+
+// updateCorefile applies update to the Corefile in the CoreDNS ConfigMap. The
+// ConfigMap is only written if the Corefile changed.
+func (c *Command) updateCorefile(update func(string) string) error {
+	configMaps := c.clientset.CoreV1().ConfigMaps(c.flagConfigMapNamespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := configMaps.Get(c.ctx, c.flagConfigMapName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		corefile, ok := configMap.Data[corefileKey]
+		if !ok {
+			return fmt.Errorf("ConfigMap %q does not contain a %s", c.flagConfigMapName, corefileKey)
+		}
+		updated := update(corefile)
+		if updated == corefile {
+			return nil
+		}
+		configMap.Data[corefileKey] = updated
+		_, err = configMaps.Update(c.ctx, configMap, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+func (c *Command) validateFlags() error {
+	if c.flagConfigMapName == "" {
+		return errors.New("-coredns-configmap-name must be set")
+	}
+	if c.flagConfigMapNamespace == "" {
+		return errors.New("-coredns-configmap-namespace must be set")
+	}
+	if c.flagCleanup {
+		return nil
+	}
+	if c.flagDNSServiceName == "" {
+		return errors.New("-dns-service-name must be set")
+	}
+	if c.flagDNSServiceNamespace == "" {
+		return errors.New("-dns-service-namespace must be set")
+	}
+	if c.flagDomain == "" {
+		return errors.New("-domain must be set")
+	}
+	return nil
+}
+
+// addConsulBlock returns the Corefile with a server block that forwards the
+// domain to the Consul DNS service IP. A previously added block is replaced.
+func addConsulBlock(corefile, domain, ip string) string {
+	block := fmt.Sprintf(`%s
+%s:53 {
+    errors
+    cache 30
+    forward . %s
+}
+%s
+`, blockStart, domain, ip, blockEnd)
+
+	corefile = removeConsulBlock(corefile)
+	if corefile != "" && !strings.HasSuffix(corefile, "\n") {
+		corefile += "\n"
+	}
+	return corefile + block
+}
+
+// removeConsulBlock returns the Corefile without the server block added by addConsulBlock.
+func removeConsulBlock(corefile string) string {
+	start := strings.Index(corefile, blockStart)
+	if start == -1 {
+		return corefile
+	}
+	end := strings.Index(corefile[start:], blockEnd)
+	if end == -1 {
+		return corefile
+	}
+	end = start + end + len(blockEnd)
+	if end < len(corefile) && corefile[end] == '\n' {
+		end++
+	}
+	return corefile[:start] + corefile[end:]
+}
+
+func (c *Command) Synopsis() string { return synopsis }
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+const synopsis = "Configure CoreDNS to forward Consul DNS queries."
+const help = `
+Usage: consul-k8s-control-plane coredns-config [options]
+
+  Adds a server block to the CoreDNS Corefile that forwards queries for the
+  Consul domain to the Consul DNS service so that pods can resolve .consul
+  names without changes to their DNS config. With -cleanup, the server block
+  is removed again. Cleanup is best effort and always exits successfully so
+  that it doesn't block uninstallation.
+
+`
@@ -0,0 +1,156 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package corednsconfig
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const corefile = `.:53 {
+    errors
+    kubernetes cluster.local in-addr.arpa ip6.arpa
+    forward . /etc/resolv.conf
+    reload
+}
+`
+
+const consulBlock = `# BEGIN consul-k8s managed block. Do not edit.
+consul:53 {
+    errors
+    cache 30
+    forward . 10.0.0.53
+}
+# END consul-k8s managed block.
+`
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		flags  []string
+		expErr string
+	}{
+		{
+			flags:  []string{},
+			expErr: "-dns-service-name must be set",
+		},
+		{
+			flags:  []string{"-dns-service-name=consul-dns"},
+			expErr: "-dns-service-namespace must be set",
+		},
+		{
+			flags:  []string{"-dns-service-name=consul-dns", "-dns-service-namespace=consul", "-domain="},
+			expErr: "-domain must be set",
+		},
+		{
+			flags:  []string{"-cleanup", "-coredns-configmap-name="},
+			expErr: "-coredns-configmap-name must be set",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.expErr, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui}
+			code := cmd.Run(c.flags)
+			require.Equal(t, 1, code)
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		corefile    string
+		cleanup     bool
+		expCorefile string
+	}{
+		"adds consul block": {
+			corefile:    corefile,
+			expCorefile: corefile + consulBlock,
+		},
+		"replaces existing consul block": {
+			corefile:    corefile + "# BEGIN consul-k8s managed block. Do not edit.\nconsul:53 {\n    forward . 10.0.0.1\n}\n# END consul-k8s managed block.\n",
+			expCorefile: corefile + consulBlock,
+		},
+		"cleanup removes consul block": {
+			corefile:    corefile + consulBlock,
+			cleanup:     true,
+			expCorefile: corefile,
+		},
+		"cleanup without consul block": {
+			corefile:    corefile,
+			cleanup:     true,
+			expCorefile: corefile,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			clientset := fake.NewSimpleClientset(
+				&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{Name: "consul-dns", Namespace: "consul"},
+					Spec:       corev1.ServiceSpec{ClusterIP: "10.0.0.53"},
+				},
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+					Data:       map[string]string{corefileKey: c.corefile},
+				},
+			)
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui, clientset: clientset}
+			args := []string{"-dns-service-name=consul-dns", "-dns-service-namespace=consul"}
+			if c.cleanup {
+				args = append(args, "-cleanup")
+			}
+			code := cmd.Run(args)
+			require.Equal(t, 0, code, ui.ErrorWriter.String())
+
+			configMap, err := clientset.CoreV1().ConfigMaps("kube-system").Get(context.Background(), "coredns", metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, c.expCorefile, configMap.Data[corefileKey])
+		})
+	}
+}
+
+func TestRun_ServiceWithoutClusterIP(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "consul-dns", Namespace: "consul"},
+			Spec:       corev1.ServiceSpec{ClusterIP: corev1.ClusterIPNone},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+			Data:       map[string]string{corefileKey: corefile},
+		},
+	)
+	ui := cli.NewMockUi()
+	cmd := Command{UI: ui, clientset: clientset}
+	code := cmd.Run([]string{"-dns-service-name=consul-dns", "-dns-service-namespace=consul"})
+	require.Equal(t, 1, code)
+	require.Contains(t, ui.ErrorWriter.String(), `Consul DNS service "consul-dns" does not have a cluster IP`)
+}
+
+func TestRun_CleanupDoesNotFailWithoutConfigMap(t *testing.T) {
+	t.Parallel()
+
+	ui := cli.NewMockUi()
+	cmd := Command{UI: ui, clientset: fake.NewSimpleClientset()}
+	code := cmd.Run([]string{"-cleanup"})
+	require.Equal(t, 0, code)
+	require.Contains(t, ui.ErrorWriter.String(), "Error removing Consul DNS forwarding from the Corefile")
+}
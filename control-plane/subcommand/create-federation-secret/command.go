@@ -65,8 +65,16 @@ type Command struct {
 	flagLogJSON                bool
 	flagMeshGatewayServiceName string
 
+	// flagSecondaryKubeconfigSecret is the name of a secret with kubeconfigs for
+	// the Kubernetes clusters of secondary datacenters. If set, the federation secret
+	// is also created in each of these clusters.
+	flagSecondaryKubeconfigSecret string
+
 	k8sClient    kubernetes.Interface
 	consulClient *api.Client
+	// secondaryK8sClients are the Kubernetes clients of secondary datacenters,
+	// keyed by datacenter. Only set in tests.
+	secondaryK8sClients map[string]kubernetes.Interface
 
 	once sync.Once
 	help string
@@ -91,6 +99,11 @@ func (c *Command) init() {
 		"Name of Kubernetes namespace where Consul is deployed.")
 	c.flags.StringVar(&c.flagMeshGatewayServiceName, "mesh-gateway-service-name", "",
 		"Name of the mesh gateway service registered into Consul.")
+	c.flags.StringVar(&c.flagSecondaryKubeconfigSecret, "secondary-kubeconfig-secret", "",
+		"Name of a Kubernetes secret in -k8s-namespace whose keys are the names of secondary datacenters "+
+			"and whose values are kubeconfigs for their Kubernetes clusters. If set, the federation secret "+
+			"is also created or updated in the namespace of each kubeconfig's current context, or in "+
+			"-k8s-namespace if the context doesn't set a namespace.")
 	c.flags.StringVar(&c.flagLogLevel, "log-level", "info",
 		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
 			"\"debug\", \"info\", \"warn\", and \"error\".")
@@ -258,6 +271,31 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 	logger.Info("Successfully created/updated federation secret", "name", federationSecret.ObjectMeta.Name, "ns", c.flagK8sNamespace)
+
+	if c.flagSecondaryKubeconfigSecret != "" {
+		// If server-acl-init rotated the replication token, the servers of the secondary datacenters are
+		// restarted with the new token and the replaced token is deleted once they all run with it. Since
+		// restarts take a while, that's usually on the next run.
+		var previousToken, currentToken string
+		if c.flagExportReplicationToken {
+			previousToken, currentToken, err = c.pendingReplicationTokenRotation()
+			if err != nil {
+				logger.Error("Error checking for a rotated replication token", "err", err)
+				return 1
+			}
+		}
+		rolled, err := c.copyToSecondaries(logger, federationSecret, currentToken)
+		if err != nil {
+			logger.Error("Error creating/updating federation secret in secondary datacenters", "err", err)
+			return 1
+		}
+		if previousToken != "" && rolled {
+			if err := c.deletePreviousReplicationToken(logger, previousToken); err != nil {
+				logger.Error("Error deleting previous replication token", "err", err)
+				return 1
+			}
+		}
+	}
 	return 0
 }
 
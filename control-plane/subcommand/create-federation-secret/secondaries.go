@@ -0,0 +1,207 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package createfederationsecret
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-multierror"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// replicationTokenAnnotation is set on the pod template of the server StatefulSets of secondary datacenters
+// to the accessor ID of the replication token their servers are started with.
+const replicationTokenAnnotation = "consul.hashicorp.com/replication-token-accessor-id"
+
+// copyToSecondaries creates or updates the federation secret in the Kubernetes clusters of the secondary
+// datacenters. Each key of the -secondary-kubeconfig-secret secret is the name of a secondary datacenter
+// and its value is a kubeconfig for that datacenter's cluster. The federation secret is written to the
+// namespace of the kubeconfig's current context, or to -k8s-namespace if the context has no namespace.
+// Since the federation secret holds the replication token, this also distributes rotated replication
+// tokens to the secondary datacenters. Consul servers only read the replication token on startup, so if
+// rollTo is set to the accessor ID of a rotated token, the servers of each secondary datacenter are also
+// restarted. It returns true if the servers of every secondary datacenter are running with that token.
+func (c *Command) copyToSecondaries(logger hclog.Logger, federationSecret *corev1.Secret, rollTo string) (bool, error) {
+	kubeconfigs, err := c.k8sClient.CoreV1().Secrets(c.flagK8sNamespace).Get(c.ctx, c.flagSecondaryKubeconfigSecret, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("getting secondary kubeconfig secret %q: %w", c.flagSecondaryKubeconfigSecret, err)
+	}
+
+	var datacenters []string
+	for dc := range kubeconfigs.Data {
+		datacenters = append(datacenters, dc)
+	}
+	sort.Strings(datacenters)
+
+	var result error
+	rolled := true
+	for _, dc := range datacenters {
+		client, namespace, err := c.secondaryK8sClient(dc, kubeconfigs.Data[dc])
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("datacenter %q: %w", dc, err))
+			continue
+		}
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      federationSecret.Name,
+				Namespace: namespace,
+				Labels:    federationSecret.Labels,
+			},
+			Type: federationSecret.Type,
+			Data: federationSecret.Data,
+		}
+		logger.Info("Creating/updating federation secret in secondary datacenter", "datacenter", dc, "name", secret.Name, "ns", namespace)
+		_, err = client.CoreV1().Secrets(namespace).Create(c.ctx, secret, metav1.CreateOptions{})
+		if k8serrors.IsAlreadyExists(err) {
+			_, err = client.CoreV1().Secrets(namespace).Update(c.ctx, secret, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("datacenter %q: creating/updating federation secret: %w", dc, err))
+			continue
+		}
+		logger.Info("Successfully created/updated federation secret in secondary datacenter", "datacenter", dc)
+
+		if rollTo == "" {
+			continue
+		}
+		dcRolled, err := c.rollServers(logger, client, namespace, rollTo)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("datacenter %q: restarting servers: %w", dc, err))
+		}
+		if !dcRolled {
+			logger.Info("Servers of secondary datacenter aren't running with the rotated replication token yet", "datacenter", dc)
+		}
+		rolled = rolled && dcRolled
+	}
+	return rolled && result == nil, result
+}
+
+// rollServers restarts the servers of the server StatefulSets in namespace that weren't started with the
+// replication token with accessor ID accessorID. It returns true if all of them have finished rolling out
+// with that token.
+func (c *Command) rollServers(logger hclog.Logger, client kubernetes.Interface, namespace, accessorID string) (bool, error) {
+	statefulSets, err := client.AppsV1().StatefulSets(namespace).List(c.ctx, metav1.ListOptions{LabelSelector: "component=server"})
+	if err != nil {
+		return false, err
+	}
+	if len(statefulSets.Items) == 0 {
+		return false, fmt.Errorf("no StatefulSet with label component=server in namespace %q", namespace)
+	}
+
+	rolled := true
+	for _, sts := range statefulSets.Items {
+		if sts.Spec.Template.Annotations[replicationTokenAnnotation] == accessorID {
+			rolled = rolled && rolledOut(sts)
+			continue
+		}
+		patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`, replicationTokenAnnotation, accessorID)
+		logger.Info("Restarting servers to pick up the rotated replication token", "statefulset", sts.Name, "ns", namespace)
+		_, err := client.AppsV1().StatefulSets(namespace).Patch(c.ctx, sts.Name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+		if err != nil {
+			return false, fmt.Errorf("patching StatefulSet %q: %w", sts.Name, err)
+		}
+		rolled = false
+	}
+	return rolled, nil
+}
+
+// rolledOut returns true if all the pods of the StatefulSet are ready and running its current template.
+func rolledOut(sts appsv1.StatefulSet) bool {
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+	return sts.Status.ObservedGeneration >= sts.Generation &&
+		sts.Status.UpdatedReplicas == replicas &&
+		sts.Status.ReadyReplicas == replicas &&
+		sts.Status.CurrentRevision == sts.Status.UpdateRevision
+}
+
+// pendingReplicationTokenRotation returns the accessor IDs of the replication token replaced by the last
+// rotation and of the current replication token, or empty strings if the replaced token has already been
+// deleted.
+func (c *Command) pendingReplicationTokenRotation() (string, string, error) {
+	secretName := fmt.Sprintf("%s-%s-acl-token", c.flagResourcePrefix, common.ACLReplicationTokenName)
+	secret, err := c.k8sClient.CoreV1().Secrets(c.flagK8sNamespace).Get(c.ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("getting secret %q: %w", secretName, err)
+	}
+	previous := secret.Annotations[common.ACLReplicationPreviousTokenAnnotation]
+	if previous == "" {
+		return "", "", nil
+	}
+	// The Consul client uses the replication token.
+	token, _, err := c.consulClient.ACL().TokenReadSelf(nil)
+	if err != nil {
+		return "", "", fmt.Errorf("reading replication token: %w", err)
+	}
+	return previous, token.AccessorID, nil
+}
+
+// deletePreviousReplicationToken deletes the replication token replaced by the last rotation once no
+// secondary datacenter uses it anymore, and removes its accessor ID from the replication token secret
+// so that server-acl-init can rotate the token again.
+func (c *Command) deletePreviousReplicationToken(logger hclog.Logger, accessorID string) error {
+	_, err := c.consulClient.ACL().TokenDelete(accessorID, nil)
+	if err != nil && !isACLNotFoundErr(err) {
+		return fmt.Errorf("deleting replication token %q: %w", accessorID, err)
+	}
+
+	secretName := fmt.Sprintf("%s-%s-acl-token", c.flagResourcePrefix, common.ACLReplicationTokenName)
+	secret, err := c.k8sClient.CoreV1().Secrets(c.flagK8sNamespace).Get(c.ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("getting secret %q: %w", secretName, err)
+	}
+	delete(secret.Annotations, common.ACLReplicationPreviousTokenAnnotation)
+	if _, err := c.k8sClient.CoreV1().Secrets(c.flagK8sNamespace).Update(c.ctx, secret, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("updating secret %q: %w", secretName, err)
+	}
+	logger.Info("Deleted previous replication token", "accessor-id", accessorID)
+	return nil
+}
+
+// isACLNotFoundErr returns true if err is due to a token that doesn't exist.
+func isACLNotFoundErr(err error) bool {
+	var statusErr api.StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == 404 || strings.Contains(err.Error(), "ACL not found")
+}
+
+// secondaryK8sClient returns a Kubernetes client and the namespace to write the federation secret to
+// for the secondary datacenter dc.
+func (c *Command) secondaryK8sClient(dc string, kubeconfig []byte) (kubernetes.Interface, string, error) {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing kubeconfig: %w", err)
+	}
+	namespace := c.flagK8sNamespace
+	if context, ok := config.Contexts[config.CurrentContext]; ok && context.Namespace != "" {
+		namespace = context.Namespace
+	}
+
+	if client, ok := c.secondaryK8sClients[dc]; ok {
+		return client, namespace, nil
+	}
+	restConfig, err := clientcmd.NewDefaultClientConfig(*config, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing kubeconfig: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, "", fmt.Errorf("initializing Kubernetes client: %w", err)
+	}
+	return client, namespace, nil
+}
@@ -0,0 +1,255 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package createfederationsecret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/pointer"
+)
+
+func TestCopyToSecondaries(t *testing.T) {
+	kubeconfig := func(namespace string) []byte {
+		return []byte(fmt.Sprintf(`
+apiVersion: v1
+kind: Config
+clusters:
+- name: secondary
+  cluster:
+    server: https://secondary.example.com
+contexts:
+- name: secondary
+  context:
+    cluster: secondary
+    user: secondary
+    namespace: %q
+current-context: secondary
+users:
+- name: secondary
+  user:
+    token: token
+`, namespace))
+	}
+
+	primary := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "secondary-kubeconfigs", Namespace: "default"},
+		Data: map[string][]byte{
+			"dc2": kubeconfig("consul"),
+			"dc3": kubeconfig(""),
+		},
+	})
+	dc2 := fake.NewSimpleClientset()
+	dc3 := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-federation", Namespace: "default"},
+		Data:       map[string][]byte{fedSecretReplicationTokenKey: []byte("old-token")},
+	})
+
+	cmd := Command{
+		k8sClient:                     primary,
+		secondaryK8sClients:           map[string]kubernetes.Interface{"dc2": dc2, "dc3": dc3},
+		flagK8sNamespace:              "default",
+		flagSecondaryKubeconfigSecret: "secondary-kubeconfigs",
+		ctx:                           context.Background(),
+	}
+	federationSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-federation", Namespace: "default"},
+		Type:       "Opaque",
+		Data:       map[string][]byte{fedSecretReplicationTokenKey: []byte("new-token")},
+	}
+	rolled, err := cmd.copyToSecondaries(hclog.NewNullLogger(), federationSecret, "")
+	require.NoError(t, err)
+	require.True(t, rolled)
+
+	// The namespace of the kubeconfig's context is used if it's set.
+	secret, err := dc2.CoreV1().Secrets("consul").Get(context.Background(), "consul-federation", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "new-token", string(secret.Data[fedSecretReplicationTokenKey]))
+
+	// Existing secrets are updated.
+	secret, err = dc3.CoreV1().Secrets("default").Get(context.Background(), "consul-federation", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "new-token", string(secret.Data[fedSecretReplicationTokenKey]))
+}
+
+func TestCopyToSecondaries_KubeconfigSecretMissing(t *testing.T) {
+	cmd := Command{
+		k8sClient:                     fake.NewSimpleClientset(),
+		flagK8sNamespace:              "default",
+		flagSecondaryKubeconfigSecret: "secondary-kubeconfigs",
+		ctx:                           context.Background(),
+	}
+	_, err := cmd.copyToSecondaries(hclog.NewNullLogger(), &corev1.Secret{}, "")
+	require.EqualError(t, err, `getting secondary kubeconfig secret "secondary-kubeconfigs": secrets "secondary-kubeconfigs" not found`)
+}
+
+func TestRollServers(t *testing.T) {
+	serverStatefulSet := func(accessorID string, rolledOut bool) *appsv1.StatefulSet {
+		sts := &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "consul-server",
+				Namespace:  "default",
+				Labels:     map[string]string{"component": "server"},
+				Generation: 2,
+			},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas: pointer.Int32(3),
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}},
+				},
+			},
+			Status: appsv1.StatefulSetStatus{
+				ObservedGeneration: 2,
+				UpdatedReplicas:    3,
+				ReadyReplicas:      3,
+				CurrentRevision:    "rev-2",
+				UpdateRevision:     "rev-2",
+			},
+		}
+		if accessorID != "" {
+			sts.Spec.Template.Annotations[replicationTokenAnnotation] = accessorID
+		}
+		if !rolledOut {
+			sts.Status.UpdatedReplicas = 1
+			sts.Status.CurrentRevision = "rev-1"
+		}
+		return sts
+	}
+
+	cases := map[string]struct {
+		statefulSet   *appsv1.StatefulSet
+		expRolled     bool
+		expAnnotation string
+		expErr        string
+	}{
+		"servers started with an older token are restarted": {
+			statefulSet:   serverStatefulSet("previous-accessor-id", true),
+			expAnnotation: "current-accessor-id",
+		},
+		"servers without the annotation are restarted": {
+			statefulSet:   serverStatefulSet("", true),
+			expAnnotation: "current-accessor-id",
+		},
+		"restart in progress": {
+			statefulSet:   serverStatefulSet("current-accessor-id", false),
+			expAnnotation: "current-accessor-id",
+		},
+		"restart complete": {
+			statefulSet:   serverStatefulSet("current-accessor-id", true),
+			expRolled:     true,
+			expAnnotation: "current-accessor-id",
+		},
+		"no servers": {
+			expErr: `no StatefulSet with label component=server in namespace "default"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			if c.statefulSet != nil {
+				client = fake.NewSimpleClientset(c.statefulSet)
+			}
+			cmd := Command{ctx: context.Background()}
+
+			rolled, err := cmd.rollServers(hclog.NewNullLogger(), client, "default", "current-accessor-id")
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expRolled, rolled)
+
+			sts, err := client.AppsV1().StatefulSets("default").Get(context.Background(), "consul-server", metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, c.expAnnotation, sts.Spec.Template.Annotations[replicationTokenAnnotation])
+		})
+	}
+}
+
+func TestDeletePreviousReplicationToken(t *testing.T) {
+	cases := map[string]struct {
+		deleteStatus int
+		expErr       string
+	}{
+		"deleted": {
+			deleteStatus: http.StatusOK,
+		},
+		"already deleted": {
+			deleteStatus: http.StatusNotFound,
+		},
+		"error": {
+			deleteStatus: http.StatusInternalServerError,
+			expErr:       `deleting replication token "previous-accessor-id": Unexpected response code: 500 (error)`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/v1/acl/token/self":
+					_ = json.NewEncoder(w).Encode(api.ACLToken{AccessorID: "current-accessor-id"})
+				case r.Method == http.MethodDelete && r.URL.Path == "/v1/acl/token/previous-accessor-id":
+					w.WriteHeader(c.deleteStatus)
+					if c.deleteStatus == http.StatusOK {
+						_, _ = w.Write([]byte("true"))
+					} else {
+						_, _ = w.Write([]byte("error"))
+					}
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}))
+			defer consulServer.Close()
+			consulClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+			require.NoError(t, err)
+
+			k8s := fake.NewSimpleClientset(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "consul-acl-replication-acl-token",
+					Namespace:   "default",
+					Annotations: map[string]string{common.ACLReplicationPreviousTokenAnnotation: "previous-accessor-id"},
+				},
+			})
+			cmd := Command{
+				k8sClient:          k8s,
+				consulClient:       consulClient,
+				flagResourcePrefix: "consul",
+				flagK8sNamespace:   "default",
+				ctx:                context.Background(),
+			}
+
+			previous, current, err := cmd.pendingReplicationTokenRotation()
+			require.NoError(t, err)
+			require.Equal(t, "previous-accessor-id", previous)
+			require.Equal(t, "current-accessor-id", current)
+
+			err = cmd.deletePreviousReplicationToken(hclog.NewNullLogger(), previous)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+
+			// Once the annotation is removed, there's no pending rotation.
+			previous, current, err = cmd.pendingReplicationTokenRotation()
+			require.NoError(t, err)
+			require.Empty(t, previous)
+			require.Empty(t, current)
+		})
+	}
+}
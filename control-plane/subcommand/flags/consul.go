@@ -6,6 +6,7 @@ package flags
 import (
 	"crypto/tls"
 	"flag"
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
@@ -44,6 +45,8 @@ const (
 	SkipServerWatchEnvVar = "CONSUL_SKIP_SERVER_WATCH"
 
 	APITimeoutEnvVar = "CONSUL_API_TIMEOUT"
+
+	AllowStaleEnvVar = "CONSUL_ALLOW_STALE"
 )
 
 // ConsulFlags is a set of flags used to connect to Consul (servers).
@@ -52,6 +55,7 @@ type ConsulFlags struct {
 	GRPCPort   int
 	HTTPPort   int
 	APITimeout time.Duration
+	AllowStale bool
 
 	Namespace  string
 	Partition  string
@@ -116,6 +120,13 @@ func (f *ConsulFlags) Flags() *flag.FlagSet {
 		defaultConsulLoginBearerTokenFile = bearerTokenFileEnvVar
 	}
 
+	// An invalid value is reported by Validate rather than ignored, since
+	// falling back to either default would silently change the consistency of reads.
+	allowStale := true
+	if allowStaleEnv, err := strconv.ParseBool(os.Getenv(AllowStaleEnvVar)); err == nil {
+		allowStale = allowStaleEnv
+	}
+
 	defaultAPITimeout := 5 * time.Second
 	if apiTimeoutEnv := os.Getenv(APITimeoutEnvVar); apiTimeoutEnv != "" {
 		parsedAPITimeout, _ := time.ParseDuration(apiTimeoutEnv)
@@ -176,11 +187,28 @@ func (f *ConsulFlags) Flags() *flag.FlagSet {
 			"may be specified multiple times to set multiple meta fields.")
 	fs.DurationVar(&f.APITimeout, "api-timeout", defaultAPITimeout,
 		"The time in seconds that the consul API client will wait for a response from the API before cancelling the request.")
+	fs.BoolVar(&f.AllowStale, "allow-stale", allowStale,
+		"If true, reads from Consul, e.g. of the catalog, config entries and health checks, can be answered by any "+
+			"Consul server rather than only the leader, which reduces the load on the leader. Writes are always made on the leader. "+
+			"The controllers' reads that decide whether to create, update or deregister are always consistent, while catalog sync "+
+			"corrects a decision made on a stale read at its next sync. Defaults to true. "+
+			"This can also be specified via the CONSUL_ALLOW_STALE environment variable.")
 	fs.BoolVar(&f.SkipServerWatch, "skip-server-watch", skipServerWatch, "If true, skip watching server upstream."+
 		"This can also be specified via the CONSUL_SKIP_SERVER_WATCH environment variable.")
 	return fs
 }
 
+// Validate returns an error if an environment variable that the flags read can't be parsed
+// and ignoring it would change how Consul is read.
+func (f *ConsulFlags) Validate() error {
+	if allowStaleEnv := os.Getenv(AllowStaleEnvVar); allowStaleEnv != "" {
+		if _, err := strconv.ParseBool(allowStaleEnv); err != nil {
+			return fmt.Errorf("%s=%q is invalid: must be true or false", AllowStaleEnvVar, allowStaleEnv)
+		}
+	}
+	return nil
+}
+
 func (f *ConsulFlags) ConsulServerConnMgrConfig() (discovery.Config, error) {
 	cfg := discovery.Config{
 		Addresses: f.Addresses,
@@ -275,5 +303,6 @@ func (f *ConsulFlags) ConsulClientConfig() *consul.Config {
 		HTTPPort:        f.HTTPPort,
 		GRPCPort:        f.GRPCPort,
 		APITimeout:      f.APITimeout,
+		AllowStale:      f.AllowStale,
 	}
 }
@@ -28,6 +28,7 @@ func TestConsulFlags_Flags(t *testing.T) {
 				PartitionEnvVar:  "test-partition",
 				DatacenterEnvVar: "test-dc",
 				APITimeoutEnvVar: "10s",
+				AllowStaleEnvVar: "false",
 
 				UseTLSEnvVar:        "true",
 				CACertFileEnvVar:    "path/to/ca.pem",
@@ -76,6 +77,7 @@ func TestConsulFlags_Flags(t *testing.T) {
 		"defaults": {
 			expFlags: &ConsulFlags{
 				APITimeout: 5 * time.Second,
+				AllowStale: true,
 				ConsulACLFlags: ConsulACLFlags{
 					ConsulLogin: ConsulLoginFlags{
 						BearerTokenFile: "/var/run/secrets/kubernetes.io/serviceaccount/token",
@@ -95,6 +97,7 @@ func TestConsulFlags_Flags(t *testing.T) {
 			},
 			expFlags: &ConsulFlags{
 				APITimeout: 5 * time.Second,
+				AllowStale: true,
 				ConsulACLFlags: ConsulACLFlags{
 					ConsulLogin: ConsulLoginFlags{
 						BearerTokenFile: "/var/run/secrets/kubernetes.io/serviceaccount/token",
@@ -125,6 +128,31 @@ func TestConsulFlags_Flags(t *testing.T) {
 	}
 }
 
+func TestConsulFlags_Validate(t *testing.T) {
+	cases := map[string]struct {
+		allowStale string
+		expErr     string
+	}{
+		"unset":         {},
+		"valid":         {allowStale: "true"},
+		"invalid value": {allowStale: "yes please", expErr: `CONSUL_ALLOW_STALE="yes please" is invalid: must be true or false`},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(AllowStaleEnvVar, c.allowStale)
+			cf := &ConsulFlags{}
+			require.NoError(t, cf.Flags().Parse(nil))
+			err := cf.Validate()
+			if c.expErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, c.expErr)
+			}
+		})
+	}
+}
+
 func TestConsulFlags_ConsulServerConnMgrConfig(t *testing.T) {
 	cases := map[string]struct {
 		flags     ConsulFlags
@@ -25,6 +25,7 @@ import (
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	gwv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+	k8syaml "sigs.k8s.io/yaml"
 )
 
 // this dupes the Kubernetes tolerations
@@ -63,22 +64,34 @@ type Command struct {
 	flagGatewayClassConfigName string
 
 	flagServiceType                string
+	flagServiceExtraAnnotations    string // this is a yaml multiline string map
+	flagServiceLoadBalancerClass   string
+	flagServiceExternalTraffic     string
 	flagDeploymentDefaultInstances int
 	flagDeploymentMaxInstances     int
 	flagDeploymentMinInstances     int
 
-	flagNodeSelector       string // this is a yaml multiline string map
-	flagTolerations        string // this is a multiline yaml string matching the tolerations array
-	flagServiceAnnotations string // this is a multiline yaml string array of annotations to allow
+	flagAutoscalingTargetDownstreamConnections int
+	flagAutoscalingTargetRequestsPerSecond     int
+
+	flagNodeSelector              string // this is a yaml multiline string map
+	flagTolerations               string // this is a multiline yaml string matching the tolerations array
+	flagServiceAnnotations        string // this is a multiline yaml string array of annotations to allow
+	flagTopologySpreadConstraints string // this is a multiline yaml string matching the topologySpreadConstraints array
+	flagAffinity                  string // this is a multiline yaml string matching the affinity object
+	flagPriorityClassName         string
 
 	k8sClient client.Client
 
 	once sync.Once
 	help string
 
-	nodeSelector       map[string]string
-	tolerations        []corev1.Toleration
-	serviceAnnotations []string
+	nodeSelector              map[string]string
+	tolerations               []corev1.Toleration
+	serviceAnnotations        []string
+	serviceExtraAnnotations   map[string]string
+	topologySpreadConstraints []corev1.TopologySpreadConstraint
+	affinity                  *corev1.Affinity
 
 	ctx context.Context
 }
@@ -105,6 +118,15 @@ func (c *Command) init() {
 	c.flags.StringVar(&c.flagServiceType, "service-type", "",
 		"The service type to use for a gateway deployment.",
 	)
+	c.flags.StringVar(&c.flagServiceExtraAnnotations, "service-extra-annotations", "",
+		"The annotations to add to the service of a gateway deployment.",
+	)
+	c.flags.StringVar(&c.flagServiceLoadBalancerClass, "service-load-balancer-class", "",
+		"The load balancer class to use for the LoadBalancer service of a gateway deployment.",
+	)
+	c.flags.StringVar(&c.flagServiceExternalTraffic, "service-external-traffic-policy", "",
+		"The external traffic policy to use for the service of a gateway deployment: Cluster or Local.",
+	)
 	c.flags.IntVar(&c.flagDeploymentDefaultInstances, "deployment-default-instances", 0,
 		"The number of instances to deploy for each gateway by default.",
 	)
@@ -114,6 +136,12 @@ func (c *Command) init() {
 	c.flags.IntVar(&c.flagDeploymentMinInstances, "deployment-min-instances", 0,
 		"The minimum number of instances to deploy for each gateway.",
 	)
+	c.flags.IntVar(&c.flagAutoscalingTargetDownstreamConnections, "autoscaling-target-downstream-connections", 0,
+		"The average number of active downstream connections per instance that gateways are autoscaled on.",
+	)
+	c.flags.IntVar(&c.flagAutoscalingTargetRequestsPerSecond, "autoscaling-target-requests-per-second", 0,
+		"The average number of downstream requests per second per instance that gateways are autoscaled on.",
+	)
 	c.flags.StringVar(&c.flagNodeSelector, "node-selector", "",
 		"The node selector to use in scheduling a gateway.",
 	)
@@ -123,6 +151,15 @@ func (c *Command) init() {
 	c.flags.StringVar(&c.flagServiceAnnotations, "service-annotations", "",
 		"The annotations to copy over from a gateway to its service.",
 	)
+	c.flags.StringVar(&c.flagTopologySpreadConstraints, "topology-spread-constraints", "",
+		"The topology spread constraints to use in a deployed gateway.",
+	)
+	c.flags.StringVar(&c.flagAffinity, "affinity", "",
+		"The affinity to use in scheduling a gateway.",
+	)
+	c.flags.StringVar(&c.flagPriorityClassName, "priority-class-name", "",
+		"The priority class name to use in a deployed gateway.",
+	)
 
 	c.k8s = &flags.K8SFlags{}
 	flags.Merge(c.flags, c.k8s.Flags())
@@ -186,16 +223,25 @@ func (c *Command) Run(args []string) int {
 	classConfig := &v1alpha1.GatewayClassConfig{
 		ObjectMeta: metav1.ObjectMeta{Name: c.flagGatewayClassConfigName, Labels: labels},
 		Spec: v1alpha1.GatewayClassConfigSpec{
-			ServiceType:  serviceTypeIfSet(c.flagServiceType),
+			ServiceType: serviceTypeIfSet(c.flagServiceType),
+			Service: v1alpha1.ServiceSpec{
+				Annotations:           c.serviceExtraAnnotations,
+				LoadBalancerClass:     stringOrNil(c.flagServiceLoadBalancerClass),
+				ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyType(c.flagServiceExternalTraffic),
+			},
 			NodeSelector: c.nodeSelector,
 			CopyAnnotations: v1alpha1.CopyAnnotationsSpec{
 				Service: c.serviceAnnotations,
 			},
-			Tolerations: c.tolerations,
+			Tolerations:               c.tolerations,
+			TopologySpreadConstraints: c.topologySpreadConstraints,
+			Affinity:                  c.affinity,
+			PriorityClassName:         c.flagPriorityClassName,
 			DeploymentSpec: v1alpha1.DeploymentSpec{
 				DefaultInstances: nonZeroOrNil(c.flagDeploymentDefaultInstances),
 				MaxInstances:     nonZeroOrNil(c.flagDeploymentMaxInstances),
 				MinInstances:     nonZeroOrNil(c.flagDeploymentMinInstances),
+				Autoscaling:      c.autoscaling(),
 			},
 		},
 	}
@@ -249,6 +295,12 @@ func (c *Command) validateFlags() error {
 	if c.flagControllerName == "" {
 		return errors.New("-controller-name must be set")
 	}
+	if c.flagAutoscalingTargetDownstreamConnections < 0 {
+		return errors.New("-autoscaling-target-downstream-connections must not be negative")
+	}
+	if c.flagAutoscalingTargetRequestsPerSecond < 0 {
+		return errors.New("-autoscaling-target-requests-per-second must not be negative")
+	}
 	if c.flagTolerations != "" {
 		var tolerations []toleration
 		if err := yaml.Unmarshal([]byte(c.flagTolerations), &tolerations); err != nil {
@@ -271,10 +323,42 @@ func (c *Command) validateFlags() error {
 			return fmt.Errorf("error decoding service annotations: %w", err)
 		}
 	}
+	if c.flagServiceExtraAnnotations != "" {
+		if err := yaml.Unmarshal([]byte(c.flagServiceExtraAnnotations), &c.serviceExtraAnnotations); err != nil {
+			return fmt.Errorf("error decoding service extra annotations: %w", err)
+		}
+	}
+	switch corev1.ServiceExternalTrafficPolicyType(c.flagServiceExternalTraffic) {
+	case "", corev1.ServiceExternalTrafficPolicyTypeCluster, corev1.ServiceExternalTrafficPolicyTypeLocal:
+	default:
+		return fmt.Errorf("-service-external-traffic-policy must be one of %q or %q", corev1.ServiceExternalTrafficPolicyTypeCluster, corev1.ServiceExternalTrafficPolicyTypeLocal)
+	}
+	if c.flagTopologySpreadConstraints != "" {
+		if err := k8syaml.UnmarshalStrict([]byte(c.flagTopologySpreadConstraints), &c.topologySpreadConstraints); err != nil {
+			return fmt.Errorf("error decoding topology spread constraints: %w", err)
+		}
+	}
+	if c.flagAffinity != "" {
+		if err := k8syaml.UnmarshalStrict([]byte(c.flagAffinity), &c.affinity); err != nil {
+			return fmt.Errorf("error decoding affinity: %w", err)
+		}
+	}
 
 	return nil
 }
 
+// autoscaling returns the autoscaling spec of the GatewayClassConfig, or nil if no
+// autoscaling target is set.
+func (c *Command) autoscaling() *v1alpha1.AutoscalingSpec {
+	if c.flagAutoscalingTargetDownstreamConnections == 0 && c.flagAutoscalingTargetRequestsPerSecond == 0 {
+		return nil
+	}
+	return &v1alpha1.AutoscalingSpec{
+		TargetDownstreamConnections: nonZeroOrNil(c.flagAutoscalingTargetDownstreamConnections),
+		TargetRequestsPerSecond:     nonZeroOrNil(c.flagAutoscalingTargetRequestsPerSecond),
+	}
+}
+
 func (c *Command) Synopsis() string { return synopsis }
 func (c *Command) Help() string {
 	c.once.Do(c.init)
@@ -344,6 +428,13 @@ func nonZeroOrNil(v int) *int32 {
 	return common.PointerTo(int32(v))
 }
 
+func stringOrNil(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return common.PointerTo(v)
+}
+
 func serviceTypeIfSet(v string) *corev1.ServiceType {
 	if v == "" {
 		return nil
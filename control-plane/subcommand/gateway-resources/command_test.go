@@ -132,6 +132,76 @@ func TestRun_flagValidation(t *testing.T) {
 			},
 			expectedErr: "error decoding service annotations: yaml: unmarshal errors:\n  line 1: cannot unmarshal !!str `foo` into []string",
 		},
+		"required valid service extra annotations": {
+			cmd: &Command{
+				flagGatewayClassConfigName:  "test",
+				flagGatewayClassName:        "test",
+				flagHeritage:                "test",
+				flagChart:                   "test",
+				flagApp:                     "test",
+				flagRelease:                 "test",
+				flagComponent:               "test",
+				flagControllerName:          "test",
+				flagServiceExtraAnnotations: "foo",
+			},
+			expectedErr: "error decoding service extra annotations: yaml: unmarshal errors:\n  line 1: cannot unmarshal !!str `foo` into map[string]string",
+		},
+		"required valid service external traffic policy": {
+			cmd: &Command{
+				flagGatewayClassConfigName: "test",
+				flagGatewayClassName:       "test",
+				flagHeritage:               "test",
+				flagChart:                  "test",
+				flagApp:                    "test",
+				flagRelease:                "test",
+				flagComponent:              "test",
+				flagControllerName:         "test",
+				flagServiceExternalTraffic: "foo",
+			},
+			expectedErr: "-service-external-traffic-policy must be one of \"Cluster\" or \"Local\"",
+		},
+		"required valid topology spread constraints": {
+			cmd: &Command{
+				flagGatewayClassConfigName:    "test",
+				flagGatewayClassName:          "test",
+				flagHeritage:                  "test",
+				flagChart:                     "test",
+				flagApp:                       "test",
+				flagRelease:                   "test",
+				flagComponent:                 "test",
+				flagControllerName:            "test",
+				flagTopologySpreadConstraints: "foo",
+			},
+			expectedErr: "error decoding topology spread constraints: error unmarshaling JSON: while decoding JSON: json: cannot unmarshal string into Go value of type []v1.TopologySpreadConstraint",
+		},
+		"required valid affinity": {
+			cmd: &Command{
+				flagGatewayClassConfigName: "test",
+				flagGatewayClassName:       "test",
+				flagHeritage:               "test",
+				flagChart:                  "test",
+				flagApp:                    "test",
+				flagRelease:                "test",
+				flagComponent:              "test",
+				flagControllerName:         "test",
+				flagAffinity:               "nodeAffinty: {}",
+			},
+			expectedErr: "error decoding affinity: error unmarshaling JSON: while decoding JSON: json: unknown field \"nodeAffinty\"",
+		},
+		"autoscaling target must not be negative": {
+			cmd: &Command{
+				flagGatewayClassConfigName: "test",
+				flagGatewayClassName:       "test",
+				flagHeritage:               "test",
+				flagChart:                  "test",
+				flagApp:                    "test",
+				flagRelease:                "test",
+				flagComponent:              "test",
+				flagControllerName:         "test",
+				flagAutoscalingTargetDownstreamConnections: -1,
+			},
+			expectedErr: "-autoscaling-target-downstream-connections must not be negative",
+		},
 		"valid without optional flags": {
 			cmd: &Command{
 				flagGatewayClassConfigName: "test",
@@ -163,6 +233,25 @@ bar: 2`,
 				flagServiceAnnotations: `
 - foo
 - bar`,
+				flagAutoscalingTargetDownstreamConnections: 100,
+				flagAutoscalingTargetRequestsPerSecond:     50,
+				flagTopologySpreadConstraints: `
+- maxSkew: 1
+  topologyKey: topology.kubernetes.io/zone
+  whenUnsatisfiable: ScheduleAnyway`,
+				flagAffinity: `
+nodeAffinity:
+  requiredDuringSchedulingIgnoredDuringExecution:
+    nodeSelectorTerms:
+    - matchExpressions:
+      - key: node-pool
+        operator: In
+        values: [edge]`,
+				flagPriorityClassName: "gateway-critical",
+				flagServiceExtraAnnotations: `
+service.beta.kubernetes.io/aws-load-balancer-scheme: internal`,
+				flagServiceLoadBalancerClass: "service.k8s.aws/nlb",
+				flagServiceExternalTraffic:   "Local",
 			},
 		},
 	} {
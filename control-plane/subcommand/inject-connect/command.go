@@ -8,30 +8,51 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"math"
+	"net"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
+	"text/template"
+	"time"
 
 	gatewaycommon "github.com/hashicorp/consul-k8s/control-plane/api-gateway/common"
 	gatewaycontrollers "github.com/hashicorp/consul-k8s/control-plane/api-gateway/controllers"
 	apicommon "github.com/hashicorp/consul-k8s/control-plane/api/common"
 	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/controllers/argorollouts"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/controllers/authmethod"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/controllers/certmetrics"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/controllers/cniversion"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/controllers/endpoints"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/controllers/externalservices"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/controllers/license"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/controllers/meshready"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/controllers/nodemeta"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/controllers/peering"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/controllers/peeringexports"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/controllers/podmonitor"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/controllers/rolloutrestart"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/controllers/uiexposure"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/lifecycle"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/metrics"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/tokenreview"
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/tracing"
 	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/webhook"
 	"github.com/hashicorp/consul-k8s/control-plane/controllers"
 	mutatingwebhookconfiguration "github.com/hashicorp/consul-k8s/control-plane/helper/mutating-webhook-configuration"
+	"github.com/hashicorp/consul-k8s/control-plane/quota"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/hashicorp/consul-k8s/control-plane/version"
 	"github.com/hashicorp/consul-server-connection-manager/discovery"
 	"github.com/mitchellh/cli"
 	"go.uber.org/zap/zapcore"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -42,6 +63,7 @@ import (
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	ctrlRuntimeWebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
 	gwv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 	gwv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 )
@@ -56,12 +78,15 @@ type Command struct {
 	flagListen                string
 	flagCertDir               string // Directory with TLS certs for listening (PEM)
 	flagDefaultInject         bool   // True to inject by default
+	flagInjectDryRun          bool   // True to annotate pods with the injection decision instead of injecting them
 	flagConsulImage           string // Docker image for Consul
 	flagConsulDataplaneImage  string // Docker image for Envoy
 	flagConsulK8sImage        string // Docker image for consul-k8s
+	flagEnableFIPS            bool   // Require FIPS builds and images
 	flagACLAuthMethod         string // Auth Method to use for ACLs, if enabled
 	flagEnvoyExtraArgs        string // Extra envoy args when starting envoy
 	flagEnableWebhookCAUpdate bool
+	flagServerConfigMapName   string // Name of the Consul server config map whose extra config is validated
 	flagLogLevel              string
 	flagLogJSON               bool
 
@@ -87,6 +112,13 @@ type Command struct {
 	flagDefaultSidecarProxyMemoryLimit   string
 	flagDefaultSidecarProxyMemoryRequest string
 	flagDefaultEnvoyProxyConcurrency     int
+	// Proxy requests sized as a percentage of the application containers' requests.
+	flagDefaultSidecarProxyCPURequestPercent    int
+	flagDefaultSidecarProxyCPURequestMin        string
+	flagDefaultSidecarProxyCPURequestMax        string
+	flagDefaultSidecarProxyMemoryRequestPercent int
+	flagDefaultSidecarProxyMemoryRequestMin     string
+	flagDefaultSidecarProxyMemoryRequestMax     string
 
 	// Proxy lifecycle settings.
 	flagDefaultEnableSidecarProxyLifecycle                       bool
@@ -94,6 +126,8 @@ type Command struct {
 	flagDefaultSidecarProxyLifecycleShutdownGracePeriodSeconds   int
 	flagDefaultSidecarProxyLifecycleGracefulPort                 string
 	flagDefaultSidecarProxyLifecycleGracefulShutdownPath         string
+	flagDefaultSidecarProxyLifecycleStartupGracePeriodSeconds    int
+	flagDefaultSidecarProxyLifecycleGracefulStartupPath          string
 
 	// Metrics settings.
 	flagDefaultEnableMetrics        bool
@@ -102,6 +136,15 @@ type Command struct {
 	flagDefaultMergedMetricsPort    string
 	flagDefaultPrometheusScrapePort string
 	flagDefaultPrometheusScrapePath string
+	flagDefaultStatsdURL            string
+	flagDefaultDogstatsdURL         string
+	flagStatsTagPodLabels           []string
+
+	// Tracing settings.
+	flagTracingProvider                  string
+	flagTracingCollectorAddress          string
+	flagDefaultTracingSamplingPercentage float64
+	flagTracingTagPodLabels              []string
 
 	// Init container resource settings.
 	flagInitContainerCPULimit      string
@@ -109,15 +152,59 @@ type Command struct {
 	flagInitContainerMemoryLimit   string
 	flagInitContainerMemoryRequest string
 
+	// Init container server wait settings.
+	flagInitContainerServerWaitTimeout         time.Duration
+	flagInitContainerServerWaitInitialInterval time.Duration
+	flagInitContainerServerWaitMaxInterval     time.Duration
+	flagInitContainerServerWaitJitter          float64
+
 	// Transparent proxy flags.
 	flagDefaultEnableTransparentProxy          bool
 	flagTransparentProxyDefaultOverwriteProbes bool
 
-	// CNI flag.
-	flagEnableCNI bool
+	// CNI flags.
+	flagEnableCNI             bool
+	flagCNIBlockOnVersionSkew bool
+
+	// IPv6 flag.
+	flagEnableIPv6 bool
+
+	// Name of the ConfigMap in each namespace whose upstreams are added to the namespace's pods.
+	flagNamespaceUpstreamsConfigMap string
 
 	// Additional metadata to get applied to nodes.
 	flagNodeMeta map[string]string
+	// Labels of Kubernetes nodes to sync into the meta of Consul nodes.
+	flagSyncNodeLabels []string
+	// Register service instances with the locality of their Kubernetes node.
+	flagEnableLocality bool
+	// Mirror the liveness and readiness probes of pods into Consul health checks.
+	flagEnableProbeHealthChecks bool
+	// Network of the cluster's pods and the address of the gateway that other networks reach them through.
+	flagNetwork               string
+	flagNetworkGatewayAddress string
+	// Template for the Consul service names of pods and its parsed template.
+	flagServiceNameTemplate string
+	serviceNameTemplate     *template.Template
+	// How long the service instances of terminating pods are drained for before they're deregistered.
+	flagTerminatingPodDrainWindow time.Duration
+	// Interval between full reconciles of all Endpoints.
+	flagFullSyncInterval time.Duration
+	// Interval between looking up service instances whose pods no longer exist, and how long
+	// their pods must be missing before they're deregistered.
+	flagOrphanReaperInterval time.Duration
+	flagOrphanReaperTTL      time.Duration
+	// Allow namespaces to override the images their pods are injected with.
+	flagNamespaceImageOverrides bool
+	// Experimental node proxy mode and the range of listener ports on each node's proxy.
+	flagEnableNodeProxy    bool
+	flagNodeProxyPortRange string
+	nodeProxyMinPort       int
+	nodeProxyMaxPort       int
+	// Failure policy of the mutating webhook configuration and how long the webhook server drains for
+	// before it's stopped.
+	flagWebhookFailurePolicy  string
+	flagShutdownDrainDuration time.Duration
 
 	// Peering flags.
 	flagEnablePeering bool
@@ -130,12 +217,95 @@ type Command struct {
 	// Consul telemetry collector
 	flagEnableTelemetryCollector bool
 
+	// Mesh ready pod condition flags.
+	flagEnableMeshReadyCondition bool
+	flagEnableMeshReadyGate      bool
+
+	// Argo Rollouts flags.
+	flagEnableArgoRollouts bool
+
+	// Ephemeral debug container flags.
+	flagDebugContainerUID int64
+
+	// Intentions NetworkPolicy flags.
+	flagEnableIntentionsNetworkPolicies bool
+	flagIntentionsDefaultDeny           bool
+
+	// ExternalName service flags.
+	flagEnableExternalNameServices bool
+
+	// Prometheus Operator PodMonitor flags.
+	flagEnablePodMonitors bool
+	flagPodMonitorLabels  map[string]string
+
+	// Certificate metrics flags.
+	flagEnableCertMetrics bool
+
+	// Config entry controller flags.
+	flagConfigEntryMaxConcurrentReconciles       int
+	flagConfigEntryMaxConcurrentReconcilesByKind map[string]string
+	flagConfigEntryFinalizerTimeout              time.Duration
+	configEntryMaxConcurrentReconcilesByKind     map[string]int
+
+	// Kubernetes and Consul query tuning flags.
+	flagK8sResyncPeriod time.Duration
+	flagK8sListPageSize int64
+	flagConsulQueryWait time.Duration
+
+	flagEnableServiceInstanceCache bool
+
+	// Leader election flags.
+	flagLeaderElectionLeaseDuration time.Duration
+	flagLeaderElectionRenewDeadline time.Duration
+	flagLeaderElectionRetryPeriod   time.Duration
+
+	// Namespace quota flags.
+	flagEnableNamespaceQuotas           bool
+	flagQuotaDefaultMaxServices         int
+	flagQuotaDefaultMaxIntentions       int
+	flagQuotaDefaultMaxExportedServices int
+
+	// UI exposure flags.
+	flagUIExposeType             string
+	flagUIExposeHost             string
+	flagUIExposeIngressClass     string
+	flagUIExposeTLSSecret        string
+	flagUIExposeGatewayName      string
+	flagUIExposeGatewayNamespace string
+	flagUIExposeOIDCIssuerURL    string
+	flagUIExposeOIDCSecretName   string
+	flagUIExposeOIDCProxyImage   string
+
+	// Consul login flags.
+	flagConsulLoginAudience   string
+	flagAuthMethodReviewerTTL time.Duration
+	flagTokenReviewCacheTTL   time.Duration
+
 	// Consul DNS flags.
 	flagEnableConsulDNS bool
 	flagResourcePrefix  string
 
 	flagEnableOpenShift bool
 
+	flagEnableRestrictedPodSecurity bool
+
+	// Security profile flags.
+	flagDefaultSeccompProfile  string
+	flagDefaultAppArmorProfile string
+	flagDefaultSELinuxOptions  string
+
+	// Rollout restart flags.
+	flagRolloutRestartSecrets []string
+	flagRolloutRestartTargets []string
+	flagRolloutRestartPacing  time.Duration
+	rolloutRestartTargets     []rolloutrestart.Target
+
+	// Enterprise license flags.
+	flagEnterpriseLicenseSecretName string
+	flagEnterpriseLicenseSecretKey  string
+	flagEnterpriseLicensePath       string
+	flagEnterpriseLicenseApply      bool
+
 	flagSet *flag.FlagSet
 	consul  *flags.ConsulFlags
 
@@ -164,7 +334,33 @@ func (c *Command) init() {
 	c.flagSet.StringVar(&c.flagListen, "listen", ":8080", "Address to bind listener to.")
 	c.flagSet.Var((*flags.FlagMapValue)(&c.flagNodeMeta), "node-meta",
 		"Metadata to set on the node, formatted as key=value. This flag may be specified multiple times to set multiple meta fields.")
+	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagSyncNodeLabels), "sync-node-label",
+		"Label of the Kubernetes node to sync into the meta of the Consul node that services on it are registered on. "+
+			"Characters that aren't allowed in node meta keys are replaced with dashes, e.g. topology.kubernetes.io/zone "+
+			"is synced to topology-kubernetes-io-zone. This flag may be specified multiple times to sync multiple labels.")
+	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagRolloutRestartSecrets), "rollout-restart-secret",
+		"Name of a secret in the release namespace whose changes restart the -rollout-restart-target workloads. "+
+			"This flag may be specified multiple times to watch multiple secrets.")
+	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagRolloutRestartTargets), "rollout-restart-target",
+		"Workload in the release namespace to restart when a -rollout-restart-secret changes, formatted as "+
+			"deployment/<name> or statefulset/<name>. This flag may be specified multiple times; the workloads "+
+			"are restarted one at a time in the order of the flags.")
+	c.flagSet.DurationVar(&c.flagRolloutRestartPacing, "rollout-restart-pacing", 30*time.Second,
+		"Minimum time between the restarts of consecutive -rollout-restart-target workloads.")
+	c.flagSet.StringVar(&c.flagEnterpriseLicenseSecretName, "enterprise-license-secret-name", "",
+		"Name of the secret in the release namespace that holds the Consul Enterprise license. If set, the "+
+			"expiration of the license is exported as a metric and warning events are recorded before it expires.")
+	c.flagSet.StringVar(&c.flagEnterpriseLicenseSecretKey, "enterprise-license-secret-key", "",
+		"Key of the Consul Enterprise license in the -enterprise-license-secret-name secret.")
+	c.flagSet.StringVar(&c.flagEnterpriseLicensePath, "enterprise-license-path", "",
+		"Path of the Consul Enterprise license file rendered by the Vault agent. If set, the license is read "+
+			"from the file instead of the -enterprise-license-secret-name secret.")
+	c.flagSet.BoolVar(&c.flagEnterpriseLicenseApply, "enterprise-license-apply", false,
+		"Apply changes to the Consul Enterprise license through the license API. Only supported by servers "+
+			"that don't autoload their license, i.e. Consul versions before 1.10.")
 	c.flagSet.BoolVar(&c.flagDefaultInject, "default-inject", true, "Inject by default.")
+	c.flagSet.BoolVar(&c.flagInjectDryRun, "inject-dry-run", false,
+		"Don't inject pods. Instead, annotate them with whether they would have been injected and why.")
 	c.flagSet.StringVar(&c.flagCertDir, "tls-cert-dir", "",
 		"Directory with PEM-encoded TLS certificate and key to serve.")
 	c.flagSet.StringVar(&c.flagConsulImage, "consul-image", "",
@@ -173,12 +369,24 @@ func (c *Command) init() {
 		"Docker image for Consul Dataplane.")
 	c.flagSet.StringVar(&c.flagConsulK8sImage, "consul-k8s-image", "",
 		"Docker image for consul-k8s. Used for the connect sidecar.")
+	c.flagSet.BoolVar(&c.flagEnableFIPS, "enable-fips", false,
+		"Require this to be a FIPS build of consul-k8s and -consul-image, -consul-dataplane-image and -consul-k8s-image "+
+			"to be FIPS variants, and reject config entries that use TLS versions or cipher suites not allowed in FIPS mode.")
 	c.flagSet.BoolVar(&c.flagEnablePeering, "enable-peering", false, "Enable cluster peering controllers.")
 	c.flagSet.BoolVar(&c.flagEnableFederation, "enable-federation", false, "Enable Consul WAN Federation.")
 	c.flagSet.StringVar(&c.flagEnvoyExtraArgs, "envoy-extra-args", "",
 		"Extra envoy command line args to be set when starting envoy (e.g \"--log-level debug --disable-hot-restart\").")
 	c.flagSet.StringVar(&c.flagACLAuthMethod, "acl-auth-method", "",
 		"The name of the Kubernetes Auth Method to use for connectInjection if ACLs are enabled.")
+	c.flagSet.StringVar(&c.flagConsulLoginAudience, "consul-login-audience", "",
+		"Audience of the projected service account token that injected pods log in to Consul with. "+
+			"If empty, pods log in with the token of their service account volume.")
+	c.flagSet.DurationVar(&c.flagAuthMethodReviewerTTL, "auth-method-reviewer-token-ttl", 0,
+		"Lifetime of the tokens that the auth method's reviewer token is rotated with. It's rotated when half "+
+			"of its lifetime has passed. The minimum is 10m. Disabled if set to 0.")
+	c.flagSet.DurationVar(&c.flagTokenReviewCacheTTL, "token-review-cache-ttl", 0,
+		"How long the results of token reviews for Consul logins are cached. If set, the auth method is pointed "+
+			"at a token review cache that the connect injector serves. Disabled if set to 0.")
 	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagAllowK8sNamespacesList), "allow-k8s-namespace",
 		"K8s namespaces to explicitly allow. May be specified multiple times.")
 	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagDenyK8sNamespacesList), "deny-k8s-namespace",
@@ -203,6 +411,66 @@ func (c *Command) init() {
 		"Enable transparent proxy mode for all Consul service mesh applications by default.")
 	c.flagSet.BoolVar(&c.flagEnableCNI, "enable-cni", false,
 		"Enable CNI traffic redirection for all Consul service mesh applications.")
+	c.flagSet.BoolVar(&c.flagCNIBlockOnVersionSkew, "cni-block-on-version-skew", false,
+		"Deny injection of pods using CNI traffic redirection while the consul-cni plugin on any node is a "+
+			"different version than connect-inject, e.g. during an upgrade. Only used when -enable-cni is set.")
+	c.flagSet.BoolVar(&c.flagEnableIPv6, "enable-ipv6", false,
+		"Configure Consul service mesh applications for an IPv6-only cluster. Proxies use IPv6 loopback and "+
+			"bind addresses, and transparent proxy traffic redirection is applied with ip6tables.")
+	c.flagSet.StringVar(&c.flagNamespaceUpstreamsConfigMap, "namespace-upstreams-configmap", "",
+		"Name of a ConfigMap whose \"upstreams\" key lists upstreams, in the format of the "+
+			"consul.hashicorp.com/connect-service-upstreams annotation, that are added to every injected pod in "+
+			"the ConfigMap's namespace. Upstreams of the pod take precedence. If empty, no ConfigMap is read.")
+	c.flagSet.BoolVar(&c.flagEnableLocality, "enable-locality", true,
+		"Register service instances and their sidecar proxies with the locality of their Kubernetes node, read "+
+			"from its topology.kubernetes.io/region and topology.kubernetes.io/zone labels.")
+	c.flagSet.BoolVar(&c.flagEnableProbeHealthChecks, "enable-probe-health-checks", false,
+		"Mirror the HTTP, TCP and gRPC liveness and readiness probes of the application containers of injected "+
+			"pods into Consul health checks of their service instances. Pods can override this with the "+
+			"consul.hashicorp.com/probe-health-checks annotation.")
+	c.flagSet.StringVar(&c.flagNetwork, "network", "",
+		"Name of the network that pods in this cluster are on. It is added to the meta of service instances.")
+	c.flagSet.StringVar(&c.flagNetworkGatewayAddress, "network-gateway-address", "",
+		"Address, formatted as host:port, of the mesh gateway that other networks reach this cluster's services "+
+			"through. If set, service instances are registered with it as their wan tagged address, for clusters "+
+			"whose pod IPs aren't routable from other networks.")
+	c.flagSet.StringVar(&c.flagServiceNameTemplate, "service-name-template", "",
+		"Template for the Consul service names of pods without the consul.hashicorp.com/connect-service "+
+			"annotation, e.g. \"{{ .Deployment }}-{{ .Namespace }}\". Templates are rendered with the .Name of the "+
+			"Kubernetes service and the .Namespace, .Deployment, .ServiceAccount, .Labels and .Annotations of the pod. "+
+			"If it renders empty, the Kubernetes service name is used.")
+	c.flagSet.DurationVar(&c.flagTerminatingPodDrainWindow, "terminating-pod-drain-window", 0,
+		"How long to keep the service instances of terminating pods registered with a warning health check "+
+			"and a warning weight of 0 before deregistering them, so that upstreams stop sending them new "+
+			"requests while in-flight requests complete. If 0, they're deregistered right away.")
+	c.flagSet.DurationVar(&c.flagFullSyncInterval, "full-sync-interval", 0,
+		"The interval to reconcile all Endpoints, re-registering their service instances in Consul even if "+
+			"nothing changed in Kubernetes, e.g. to recover from a Consul snapshot restore. Full reconciles can "+
+			"also be triggered with SIGUSR1 or a POST to /full-sync on the metrics port from inside the pod. If 0, "+
+			"they're only run when triggered.")
+	c.flagSet.DurationVar(&c.flagOrphanReaperInterval, "orphan-reaper-interval", 0,
+		"The interval to look up service instances registered in Consul whose pods no longer exist, e.g. "+
+			"because they were force-deleted or their node was lost, and deregister them once their pods have "+
+			"been missing for -orphan-reaper-ttl. If 0, orphaned service instances aren't looked up.")
+	c.flagSet.DurationVar(&c.flagOrphanReaperTTL, "orphan-reaper-ttl", 5*time.Minute,
+		"How long the pod of a service instance must be missing before the orphan reaper deregisters the instance.")
+	c.flagSet.BoolVar(&c.flagNamespaceImageOverrides, "enable-namespace-image-overrides", false,
+		"Allow namespaces to override the consul-dataplane and consul-k8s-control-plane images of their pods "+
+			"with the consul.hashicorp.com/consul-dataplane-image and consul.hashicorp.com/consul-k8s-image annotations. "+
+			"If -enable-fips is set, the images must be FIPS variants.")
+	c.flagSet.BoolVar(&c.flagEnableNodeProxy, "enable-node-proxy", false,
+		"[Experimental] Allow pods annotated with consul.hashicorp.com/node-proxy to use the proxy of their "+
+			"Kubernetes node instead of a sidecar proxy. Their proxy service instances are registered as listeners "+
+			"on the node proxy.")
+	c.flagSet.StringVar(&c.flagNodeProxyPortRange, "node-proxy-port-range", "22000-22999",
+		"[Experimental] Range of ports, formatted as min-max, of the listeners on each node's proxy. "+
+			"Only used when -enable-node-proxy is set.")
+	c.flagSet.StringVar(&c.flagWebhookFailurePolicy, "webhook-failure-policy", string(admissionv1.Fail),
+		"Failure policy of the mutating webhook configuration, either Fail or Ignore. With Fail, the webhook "+
+			"only becomes ready once it's accepting connections.")
+	c.flagSet.DurationVar(&c.flagShutdownDrainDuration, "shutdown-drain-duration", 5*time.Second,
+		"How long to keep answering admission reviews after receiving a shutdown signal, while failing "+
+			"readiness so that the pod is removed from the endpoints of the webhook service.")
 	c.flagSet.BoolVar(&c.flagTransparentProxyDefaultOverwriteProbes, "transparent-proxy-default-overwrite-probes", true,
 		"Overwrite Kubernetes probes to point to Envoy by default when in Transparent Proxy mode.")
 	c.flagSet.BoolVar(&c.flagEnableConsulDNS, "enable-consul-dns", false,
@@ -211,12 +479,112 @@ func (c *Command) init() {
 		"Release prefix of the Consul installation used to determine Consul DNS Service name.")
 	c.flagSet.BoolVar(&c.flagEnableOpenShift, "enable-openshift", false,
 		"Indicates that the command runs in an OpenShift cluster.")
+	c.flagSet.BoolVar(&c.flagEnableRestrictedPodSecurity, "enable-restricted-pod-security", false,
+		"Make injected containers comply with the restricted Pod Security Standard. Transparent proxy then requires -enable-cni.")
+	c.flagSet.StringVar(&c.flagDefaultSeccompProfile, "default-seccomp-profile", "",
+		"Default seccomp profile of injected containers: runtime/default, unconfined or localhost/<profile>. "+
+			"Can be overridden with the consul.hashicorp.com/seccomp-profile annotation.")
+	c.flagSet.StringVar(&c.flagDefaultAppArmorProfile, "default-apparmor-profile", "",
+		"Default AppArmor profile of injected containers: runtime/default, unconfined or localhost/<profile>. "+
+			"Can be overridden with the consul.hashicorp.com/apparmor-profile annotation.")
+	c.flagSet.StringVar(&c.flagDefaultSELinuxOptions, "default-selinux-options", "",
+		"Default SELinux options of injected containers as a comma-separated list of user=<user>, role=<role>, "+
+			"type=<type> and level=<level>. Can be overridden with the consul.hashicorp.com/selinux-options annotation.")
 	c.flagSet.BoolVar(&c.flagEnableWebhookCAUpdate, "enable-webhook-ca-update", false,
 		"Enables updating the CABundle on the webhook within this controller rather than using the web cert manager.")
+	c.flagSet.StringVar(&c.flagServerConfigMapName, "server-config-map-name", "",
+		"Name of the Consul server config map. If set, changes to the server extra config are validated before they're applied.")
 	c.flagSet.BoolVar(&c.flagEnableAutoEncrypt, "enable-auto-encrypt", false,
 		"Indicates whether TLS with auto-encrypt should be used when talking to Consul clients.")
 	c.flagSet.BoolVar(&c.flagEnableTelemetryCollector, "enable-telemetry-collector", false,
 		"Indicates whether proxies should be registered with configuration to enable forwarding metrics to consul-telemetry-collector")
+	c.flagSet.BoolVar(&c.flagEnableMeshReadyCondition, "enable-mesh-ready-condition", false,
+		fmt.Sprintf("Set the %q condition on injected pods once their sidecar proxy is registered with Consul and "+
+			"healthy. Pods can use it as a readiness gate.", constants.PodConditionMeshReady))
+	c.flagSet.BoolVar(&c.flagEnableMeshReadyGate, "enable-mesh-ready-gate", false,
+		fmt.Sprintf("Add the %q readiness gate to injected pods so that they aren't ready until they are wired into "+
+			"the mesh. Requires -enable-mesh-ready-condition.", constants.PodConditionMeshReady))
+	c.flagSet.BoolVar(&c.flagEnableArgoRollouts, "enable-argo-rollouts", false,
+		fmt.Sprintf("Shift traffic between the stable and canary services of Argo Rollouts by setting the weights of "+
+			"the ServiceSplitter named in their %q annotation.", argorollouts.AnnotationServiceSplitter))
+	c.flagSet.Int64Var(&c.flagDebugContainerUID, "debug-container-uid", 0,
+		"User ID that ephemeral containers of injected pods, e.g. of kubectl debug, run as unless they set their own. "+
+			"It's excluded from traffic redirection so that debug containers bypass the mesh. Disabled if set to 0.")
+	c.flagSet.BoolVar(&c.flagEnableIntentionsNetworkPolicies, "enable-intentions-network-policies", false,
+		"Render a NetworkPolicy for each ServiceIntentions resource that only allows ingress to the pods of "+
+			"the destination service from the pods of the sources that intentions allow.")
+	c.flagSet.BoolVar(&c.flagIntentionsDefaultDeny, "intentions-default-deny", false,
+		"Whether intentions deny connections by default, i.e. whether Consul's ACL default policy is deny. "+
+			"NetworkPolicies for intentions are only rendered if true, since they can't express a default allow.")
+	c.flagSet.BoolVar(&c.flagEnableExternalNameServices, "enable-external-name-services", false,
+		fmt.Sprintf("Register ExternalName services with the %q annotation in Consul and link them to the "+
+			"terminating gateway in the annotation so that they can be reached through the mesh.", constants.AnnotationMeshEgressGateway))
+	c.flagSet.BoolVar(&c.flagEnablePodMonitors, "enable-pod-monitors", false,
+		"Create Prometheus Operator PodMonitors that scrape injected pods and the control plane pods of the "+
+			"release once the PodMonitor CRD is installed.")
+	c.flagSet.Var((*flags.FlagMapValue)(&c.flagPodMonitorLabels), "pod-monitor-label",
+		"Label to add to the PodMonitors, formatted as key=value. This flag may be specified multiple times to set multiple labels.")
+	c.flagSet.BoolVar(&c.flagEnableNamespaceQuotas, "enable-namespace-quotas", false,
+		fmt.Sprintf("Deny pods, ServiceIntentions and ExportedServices that would exceed the quotas of their namespace. "+
+			"Namespaces can override the default quotas with the %q, %q and %q annotations.",
+			quota.AnnotationMaxServices, quota.AnnotationMaxIntentions, quota.AnnotationMaxExportedServices))
+	c.flagSet.IntVar(&c.flagQuotaDefaultMaxServices, "quota-default-max-services", 0,
+		"Default maximum number of services that the pods of a namespace register in Consul. Unlimited if set to 0.")
+	c.flagSet.IntVar(&c.flagQuotaDefaultMaxIntentions, "quota-default-max-intentions", 0,
+		"Default maximum number of intentions that the ServiceIntentions of a namespace configure. Unlimited if set to 0.")
+	c.flagSet.IntVar(&c.flagQuotaDefaultMaxExportedServices, "quota-default-max-exported-services", 0,
+		"Default maximum number of exported services of a namespace. Unlimited if set to 0.")
+	c.flagSet.BoolVar(&c.flagEnableCertMetrics, "enable-cert-metrics", false,
+		"Serve metrics with the certificate expiration of the proxies of each service, aggregated from the "+
+			"metrics of injected pods and gateways.")
+	c.flagSet.IntVar(&c.flagConfigEntryMaxConcurrentReconciles, "config-entry-max-concurrent-reconciles", 1,
+		"Number of custom resources of each config entry kind that are reconciled at once.")
+	c.flagSet.Var((*flags.FlagMapValue)(&c.flagConfigEntryMaxConcurrentReconcilesByKind), "config-entry-max-concurrent-reconciles-by-kind",
+		"Number of custom resources of a config entry kind that are reconciled at once, formatted as kind=count, "+
+			"e.g. serviceintentions=8. Overrides -config-entry-max-concurrent-reconciles for the kind. "+
+			"This flag may be specified multiple times to set multiple kinds.")
+	c.flagSet.DurationVar(&c.flagConfigEntryFinalizerTimeout, "config-entry-finalizer-timeout", 0,
+		"How long after the deletion of a config entry custom resource is requested its finalizer is removed even "+
+			"if the config entry couldn't be deleted from Consul, e.g. because Consul is unreachable, so that the "+
+			"resource and its namespace can still be deleted. If 0, the finalizer is only removed once the config "+
+			"entry is deleted from Consul.")
+	c.flagSet.DurationVar(&c.flagK8sResyncPeriod, "k8s-resync-period", 0,
+		"How often the informers of the controllers resync, which reconciles every watched object again. "+
+			"If 0, the controller-runtime default of 10 hours is used.")
+	c.flagSet.Int64Var(&c.flagK8sListPageSize, "k8s-list-page-size", 500,
+		"Number of Endpoints listed per request to the Kubernetes API server during full syncs. "+
+			"If 0, they're listed in a single request.")
+	c.flagSet.BoolVar(&c.flagEnableServiceInstanceCache, "enable-service-instance-cache", false,
+		"Enables watching the service instances registered by the endpoints controller with a blocking query "+
+			"per Consul node, so that reconciles look them up in a cache instead of reading them from Consul.")
+	c.flagSet.DurationVar(&c.flagConsulQueryWait, "consul-query-wait-time", 0,
+		"Maximum duration of the blocking queries of the service instance cache. "+
+			"If 0, Consul's default of 5 minutes is used.")
+	c.flagSet.DurationVar(&c.flagLeaderElectionLeaseDuration, "leader-election-lease-duration", 15*time.Second,
+		"How long standby replicas wait after the leader last renewed the leader election lease before they take it over.")
+	c.flagSet.DurationVar(&c.flagLeaderElectionRenewDeadline, "leader-election-renew-deadline", 10*time.Second,
+		"How long the leader retries renewing the leader election lease before it gives up leadership.")
+	c.flagSet.DurationVar(&c.flagLeaderElectionRetryPeriod, "leader-election-retry-period", 2*time.Second,
+		"How long replicas wait between attempts to acquire or renew the leader election lease.")
+	c.flagSet.StringVar(&c.flagUIExposeType, "ui-expose-type", "",
+		fmt.Sprintf("Expose the Consul UI service with a generated resource. One of %q, %q or %q. Disabled if empty.",
+			uiexposure.TypeIngress, uiexposure.TypeRoute, uiexposure.TypeGateway))
+	c.flagSet.StringVar(&c.flagUIExposeHost, "ui-expose-host", "",
+		"Host name that the Consul UI is exposed on. If empty, requests for all hosts are routed to the UI.")
+	c.flagSet.StringVar(&c.flagUIExposeIngressClass, "ui-expose-ingress-class", "",
+		"Class of the Ingress that exposes the Consul UI.")
+	c.flagSet.StringVar(&c.flagUIExposeTLSSecret, "ui-expose-tls-secret", "",
+		"Name of the secret with the TLS certificate of the Ingress that exposes the Consul UI.")
+	c.flagSet.StringVar(&c.flagUIExposeGatewayName, "ui-expose-gateway-name", "",
+		"Name of the Gateway that the HTTPRoute exposing the Consul UI is attached to.")
+	c.flagSet.StringVar(&c.flagUIExposeGatewayNamespace, "ui-expose-gateway-namespace", "",
+		"Namespace of the Gateway that the HTTPRoute exposing the Consul UI is attached to. Defaults to the release namespace.")
+	c.flagSet.StringVar(&c.flagUIExposeOIDCIssuerURL, "ui-expose-oidc-issuer-url", "",
+		"URL of the OIDC issuer. If set, the Consul UI is exposed through an OIDC auth proxy.")
+	c.flagSet.StringVar(&c.flagUIExposeOIDCSecretName, "ui-expose-oidc-secret-name", "",
+		"Name of the secret with the client-id, client-secret and cookie-secret keys of the OIDC auth proxy.")
+	c.flagSet.StringVar(&c.flagUIExposeOIDCProxyImage, "ui-expose-oidc-proxy-image", uiexposure.DefaultOIDCProxyImage,
+		"Image of the OIDC auth proxy.")
 	c.flagSet.StringVar(&c.flagLogLevel, "log-level", zapcore.InfoLevel.String(),
 		fmt.Sprintf("Log verbosity level. Supported values (in order of detail) are "+
 			"%q, %q, %q, and %q.", zapcore.DebugLevel.String(), zapcore.InfoLevel.String(), zapcore.WarnLevel.String(), zapcore.ErrorLevel.String()))
@@ -228,6 +596,20 @@ func (c *Command) init() {
 	c.flagSet.StringVar(&c.flagDefaultSidecarProxyCPULimit, "default-sidecar-proxy-cpu-limit", "", "Default sidecar proxy CPU limit.")
 	c.flagSet.StringVar(&c.flagDefaultSidecarProxyMemoryRequest, "default-sidecar-proxy-memory-request", "", "Default sidecar proxy memory request.")
 	c.flagSet.StringVar(&c.flagDefaultSidecarProxyMemoryLimit, "default-sidecar-proxy-memory-limit", "", "Default sidecar proxy memory limit.")
+	c.flagSet.IntVar(&c.flagDefaultSidecarProxyCPURequestPercent, "default-sidecar-proxy-cpu-request-percent", 0,
+		"Percentage of the total CPU request of a pod's containers to set as the sidecar proxy CPU request. "+
+			"If the containers don't request CPU, -default-sidecar-proxy-cpu-request is used.")
+	c.flagSet.StringVar(&c.flagDefaultSidecarProxyCPURequestMin, "default-sidecar-proxy-cpu-request-min", "",
+		"Minimum sidecar proxy CPU request when it's set with -default-sidecar-proxy-cpu-request-percent.")
+	c.flagSet.StringVar(&c.flagDefaultSidecarProxyCPURequestMax, "default-sidecar-proxy-cpu-request-max", "",
+		"Maximum sidecar proxy CPU request when it's set with -default-sidecar-proxy-cpu-request-percent.")
+	c.flagSet.IntVar(&c.flagDefaultSidecarProxyMemoryRequestPercent, "default-sidecar-proxy-memory-request-percent", 0,
+		"Percentage of the total memory request of a pod's containers to set as the sidecar proxy memory request. "+
+			"If the containers don't request memory, -default-sidecar-proxy-memory-request is used.")
+	c.flagSet.StringVar(&c.flagDefaultSidecarProxyMemoryRequestMin, "default-sidecar-proxy-memory-request-min", "",
+		"Minimum sidecar proxy memory request when it's set with -default-sidecar-proxy-memory-request-percent.")
+	c.flagSet.StringVar(&c.flagDefaultSidecarProxyMemoryRequestMax, "default-sidecar-proxy-memory-request-max", "",
+		"Maximum sidecar proxy memory request when it's set with -default-sidecar-proxy-memory-request-percent.")
 
 	// Proxy lifecycle setting flags.
 	c.flagSet.BoolVar(&c.flagDefaultEnableSidecarProxyLifecycle, "default-enable-sidecar-proxy-lifecycle", false, "Default for enabling sidecar proxy lifecycle management.")
@@ -235,6 +617,10 @@ func (c *Command) init() {
 	c.flagSet.IntVar(&c.flagDefaultSidecarProxyLifecycleShutdownGracePeriodSeconds, "default-sidecar-proxy-lifecycle-shutdown-grace-period-seconds", 0, "Default sidecar proxy shutdown grace period in seconds.")
 	c.flagSet.StringVar(&c.flagDefaultSidecarProxyLifecycleGracefulPort, "default-sidecar-proxy-lifecycle-graceful-port", strconv.Itoa(constants.DefaultGracefulPort), "Default port for sidecar proxy lifecycle management HTTP endpoints.")
 	c.flagSet.StringVar(&c.flagDefaultSidecarProxyLifecycleGracefulShutdownPath, "default-sidecar-proxy-lifecycle-graceful-shutdown-path", "/graceful_shutdown", "Default sidecar proxy lifecycle management graceful shutdown path.")
+	c.flagSet.IntVar(&c.flagDefaultSidecarProxyLifecycleStartupGracePeriodSeconds, "default-sidecar-proxy-lifecycle-startup-grace-period-seconds", 0,
+		"Default number of seconds that application containers wait for the sidecar proxy to be ready before they start. "+
+			"Application containers don't wait for the sidecar proxy if set to 0.")
+	c.flagSet.StringVar(&c.flagDefaultSidecarProxyLifecycleGracefulStartupPath, "default-sidecar-proxy-lifecycle-graceful-startup-path", constants.DefaultGracefulStartupPath, "Default sidecar proxy lifecycle management graceful startup path.")
 
 	// Metrics setting flags.
 	c.flagSet.BoolVar(&c.flagDefaultEnableMetrics, "default-enable-metrics", false, "Default for enabling connect service metrics.")
@@ -243,6 +629,22 @@ func (c *Command) init() {
 	c.flagSet.StringVar(&c.flagDefaultMergedMetricsPort, "default-merged-metrics-port", "20100", "Default port for merged metrics endpoint on the consul-sidecar.")
 	c.flagSet.StringVar(&c.flagDefaultPrometheusScrapePort, "default-prometheus-scrape-port", "20200", "Default port where Prometheus scrapes connect metrics from.")
 	c.flagSet.StringVar(&c.flagDefaultPrometheusScrapePath, "default-prometheus-scrape-path", "/metrics", "Default path where Prometheus scrapes connect metrics from.")
+	c.flagSet.StringVar(&c.flagDefaultStatsdURL, "default-statsd-url", "", "Default URL of a StatsD sink that Envoy sends metrics to.")
+	c.flagSet.StringVar(&c.flagDefaultDogstatsdURL, "default-dogstatsd-url", "", "Default URL of a DogStatsD sink that Envoy sends metrics to.")
+	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagStatsTagPodLabels), "stats-tag-pod-label",
+		"Label of pods to add as a tag to the metrics sent to StatsD and DogStatsD sinks. "+
+			"This flag may be specified multiple times to add multiple labels.")
+
+	// Tracing setting flags.
+	c.flagSet.StringVar(&c.flagTracingProvider, "tracing-provider", "",
+		fmt.Sprintf("Tracer that Envoy sidecars use to trace requests, either %q or %q. Tracing is disabled if not set.",
+			tracing.ProviderZipkin, tracing.ProviderOpenTelemetry))
+	c.flagSet.StringVar(&c.flagTracingCollectorAddress, "tracing-collector-address", "",
+		"Address, formatted as host:port, of the collector that Envoy sidecars send spans to.")
+	c.flagSet.Float64Var(&c.flagDefaultTracingSamplingPercentage, "default-tracing-sampling-percentage", 100,
+		"Default percentage of requests that Envoy sidecars trace.")
+	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagTracingTagPodLabels), "tracing-tag-pod-label",
+		"Label of pods to add as a tag to spans. This flag may be specified multiple times to add multiple labels.")
 
 	// Init container resource setting flags.
 	c.flagSet.StringVar(&c.flagInitContainerCPURequest, "init-container-cpu-request", "50m", "Init container CPU request.")
@@ -250,6 +652,19 @@ func (c *Command) init() {
 	c.flagSet.StringVar(&c.flagInitContainerMemoryRequest, "init-container-memory-request", "25Mi", "Init container memory request.")
 	c.flagSet.StringVar(&c.flagInitContainerMemoryLimit, "init-container-memory-limit", "150Mi", "Init container memory limit.")
 
+	// Init container server wait flags.
+	c.flagSet.DurationVar(&c.flagInitContainerServerWaitTimeout, "init-container-server-wait-timeout", 0,
+		"Maximum time the init container waits to connect and log in to the Consul servers. If 0, it waits indefinitely.")
+	c.flagSet.DurationVar(&c.flagInitContainerServerWaitInitialInterval, "init-container-server-wait-initial-interval", 0,
+		"Time the init container waits before retrying to connect to the Consul servers the first time. "+
+			"It grows exponentially on each retry. If 0, the init container's default of 500ms is used.")
+	c.flagSet.DurationVar(&c.flagInitContainerServerWaitMaxInterval, "init-container-server-wait-max-interval", 0,
+		"Maximum time the init container waits between retries to connect to the Consul servers. "+
+			"If 0, the init container's default of 1m is used.")
+	c.flagSet.Float64Var(&c.flagInitContainerServerWaitJitter, "init-container-server-wait-jitter", 0,
+		"Fraction by which the init container randomizes each time it waits between retries to connect to the Consul servers. "+
+			"If 0, the init container's default of 0.5 is used.")
+
 	c.flagSet.IntVar(&c.flagDefaultEnvoyProxyConcurrency, "default-envoy-proxy-concurrency", 2, "Default Envoy proxy concurrency.")
 
 	c.consul = &flags.ConsulFlags{}
@@ -320,6 +735,19 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
+	cpuRequestProportion, err := parseProportionalRequest("cpu", c.flagDefaultSidecarProxyCPURequestPercent,
+		c.flagDefaultSidecarProxyCPURequestMin, c.flagDefaultSidecarProxyCPURequestMax)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	memoryRequestProportion, err := parseProportionalRequest("memory", c.flagDefaultSidecarProxyMemoryRequestPercent,
+		c.flagDefaultSidecarProxyMemoryRequestMin, c.flagDefaultSidecarProxyMemoryRequestMax)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
 	// Validate ports in metrics flags.
 	err = common.ValidateUnprivilegedPort("-default-merged-metrics-port", c.flagDefaultMergedMetricsPort)
 	if err != nil {
@@ -396,9 +824,22 @@ func (c *Command) Run(args []string) int {
 		}
 	}
 
-	// Create a context to be used by the processes started in this command.
-	ctx, cancelFunc := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	// Create a context to be used by the processes started in this command. On a shutdown signal, the
+	// webhook server is drained before the context is cancelled so that in-flight and newly routed
+	// admission reviews are still answered.
+	ctx, cancelFunc := context.WithCancel(context.Background())
 	defer cancelFunc()
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	drainer := &webhook.Drainer{}
+	go func() {
+		select {
+		case <-signalCtx.Done():
+			setupLog.Info("draining webhook server", "duration", c.flagShutdownDrainDuration)
+			drainer.Drain(c.flagShutdownDrainDuration, cancelFunc)
+		case <-ctx.Done():
+		}
+	}()
 
 	// Start Consul server Connection manager.
 	serverConnMgrCfg, err := c.consul.ConsulServerConnMgrConfig()
@@ -423,10 +864,18 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
+	var syncPeriod *time.Duration
+	if c.flagK8sResyncPeriod > 0 {
+		syncPeriod = &c.flagK8sResyncPeriod
+	}
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		LeaderElection:         true,
 		LeaderElectionID:       "consul-controller-lock",
+		LeaseDuration:          &c.flagLeaderElectionLeaseDuration,
+		RenewDeadline:          &c.flagLeaderElectionRenewDeadline,
+		RetryPeriod:            &c.flagLeaderElectionRetryPeriod,
+		SyncPeriod:             syncPeriod,
 		Host:                   listenSplits[0],
 		Port:                   port,
 		Logger:                 zapLogger,
@@ -444,6 +893,8 @@ func (c *Command) Run(args []string) int {
 		DefaultShutdownGracePeriodSeconds:   c.flagDefaultSidecarProxyLifecycleShutdownGracePeriodSeconds,
 		DefaultGracefulPort:                 c.flagDefaultSidecarProxyLifecycleGracefulPort,
 		DefaultGracefulShutdownPath:         c.flagDefaultSidecarProxyLifecycleGracefulShutdownPath,
+		DefaultStartupGracePeriodSeconds:    c.flagDefaultSidecarProxyLifecycleStartupGracePeriodSeconds,
+		DefaultGracefulStartupPath:          c.flagDefaultSidecarProxyLifecycleGracefulStartupPath,
 	}
 
 	metricsConfig := metrics.Config{
@@ -453,15 +904,90 @@ func (c *Command) Run(args []string) int {
 		DefaultMergedMetricsPort:    c.flagDefaultMergedMetricsPort,
 		DefaultPrometheusScrapePort: c.flagDefaultPrometheusScrapePort,
 		DefaultPrometheusScrapePath: c.flagDefaultPrometheusScrapePath,
+		DefaultStatsdURL:            c.flagDefaultStatsdURL,
+		DefaultDogstatsdURL:         c.flagDefaultDogstatsdURL,
+		StatsTagPodLabels:           c.flagStatsTagPodLabels,
+	}
+
+	tracingConfig := tracing.Config{
+		Provider:                  c.flagTracingProvider,
+		CollectorAddress:          c.flagTracingCollectorAddress,
+		DefaultSamplingPercentage: c.flagDefaultTracingSamplingPercentage,
+		TagPodLabels:              c.flagTracingTagPodLabels,
+	}
+
+	var networkGatewayAddress string
+	var networkGatewayPort int
+	if c.flagNetworkGatewayAddress != "" {
+		// The address was validated with the other flags.
+		networkGatewayAddress, networkGatewayPort, _ = parseHostPort(c.flagNetworkGatewayAddress)
+	}
+
+	var nodeProxyPorts *endpoints.NodeProxyPorts
+	if c.flagEnableNodeProxy {
+		nodeProxyPorts = endpoints.NewNodeProxyPorts(c.nodeProxyMinPort, c.nodeProxyMaxPort)
 	}
 
-	if err = (&endpoints.Controller{
+	var serviceInstanceCache *endpoints.ServiceInstanceCache
+	if c.flagEnableServiceInstanceCache {
+		serviceInstanceCache = &endpoints.ServiceInstanceCache{
+			ConsulClientConfig:     consulConfig,
+			ConsulServerConnMgr:    watcher,
+			EnableConsulNamespaces: c.flagEnableNamespaces,
+			WaitTime:               c.flagConsulQueryWait,
+			Log:                    ctrl.Log.WithName("controller").WithName("endpoints").WithName("cache"),
+		}
+	}
+	mutualTLSModes := &endpoints.MutualTLSModes{
+		ConsulClientConfig:     consulConfig,
+		ConsulServerConnMgr:    watcher,
+		EnableConsulNamespaces: c.flagEnableNamespaces,
+		WaitTime:               c.flagConsulQueryWait,
+		Log:                    ctrl.Log.WithName("controller").WithName("endpoints").WithName("mutual-tls-modes"),
+	}
+	if err := mgr.Add(mutualTLSModes); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "endpoints-mutual-tls-modes")
+		return 1
+	}
+	// Full syncs list from the API server since the informer cache doesn't paginate.
+	fullSync := &endpoints.FullSync{
+		Client:   mgr.GetAPIReader(),
+		PageSize: c.flagK8sListPageSize,
+		Cache:    serviceInstanceCache,
+		Interval: c.flagFullSyncInterval,
+		Log:      ctrl.Log.WithName("controller").WithName("endpoints").WithName("full-sync"),
+	}
+	if err := mgr.Add(fullSync); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "endpoints-full-sync")
+		return 1
+	}
+	if err := mgr.AddMetricsExtraHandler("/full-sync", common.LocalOnlyHandler(fullSync)); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "endpoints-full-sync")
+		return 1
+	}
+	usr1Ch := make(chan os.Signal, 1)
+	signal.Notify(usr1Ch, syscall.SIGUSR1)
+	defer signal.Stop(usr1Ch)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-usr1Ch:
+				setupLog.Info("SIGUSR1 received, requesting full sync of endpoints")
+				fullSync.Trigger()
+			}
+		}
+	}()
+
+	endpointsController := &endpoints.Controller{
 		Client:                     mgr.GetClient(),
 		ConsulClientConfig:         consulConfig,
 		ConsulServerConnMgr:        watcher,
 		AllowK8sNamespacesSet:      allowK8sNamespaces,
 		DenyK8sNamespacesSet:       denyK8sNamespaces,
 		MetricsConfig:              metricsConfig,
+		TracingConfig:              tracingConfig,
 		EnableConsulPartitions:     c.flagEnablePartitions,
 		EnableConsulNamespaces:     c.flagEnableNamespaces,
 		ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
@@ -473,18 +999,225 @@ func (c *Command) Run(args []string) int {
 		TProxyOverwriteProbes:      c.flagTransparentProxyDefaultOverwriteProbes,
 		AuthMethod:                 c.flagACLAuthMethod,
 		NodeMeta:                   c.flagNodeMeta,
+		NodeLabels:                 c.flagSyncNodeLabels,
 		Log:                        ctrl.Log.WithName("controller").WithName("endpoints"),
 		Scheme:                     mgr.GetScheme(),
 		ReleaseName:                c.flagReleaseName,
 		ReleaseNamespace:           c.flagReleaseNamespace,
 		EnableAutoEncrypt:          c.flagEnableAutoEncrypt,
 		EnableTelemetryCollector:   c.flagEnableTelemetryCollector,
+		EnableIPv6:                 c.flagEnableIPv6,
+		EnableLocality:             c.flagEnableLocality,
+		EnableProbeHealthChecks:    c.flagEnableProbeHealthChecks,
+		Network:                    c.flagNetwork,
+		NetworkGatewayAddress:      networkGatewayAddress,
+		NetworkGatewayPort:         networkGatewayPort,
+		ServiceNameTemplate:        c.serviceNameTemplate,
+		NodeProxyPorts:             nodeProxyPorts,
+		TerminatingPodDrainWindow:  c.flagTerminatingPodDrainWindow,
+		ServiceInstanceCache:       serviceInstanceCache,
+		MutualTLSModes:             mutualTLSModes,
+		FullSync:                   fullSync,
+		Recorder:                   mgr.GetEventRecorderFor("consul-connect-injector"),
 		Context:                    ctx,
-	}).SetupWithManager(mgr); err != nil {
+	}
+	if err = endpointsController.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", endpoints.Controller{})
 		return 1
 	}
 
+	if c.flagOrphanReaperInterval > 0 {
+		if err = mgr.Add(&endpoints.OrphanReaper{
+			Controller: endpointsController,
+			Interval:   c.flagOrphanReaperInterval,
+			TTL:        c.flagOrphanReaperTTL,
+			Log:        ctrl.Log.WithName("controller").WithName("endpoints").WithName("orphan-reaper"),
+		}); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "endpoints-orphan-reaper")
+			return 1
+		}
+	}
+
+	if len(c.flagSyncNodeLabels) > 0 {
+		if err = (&nodemeta.Controller{
+			Client:              mgr.GetClient(),
+			ConsulClientConfig:  consulConfig,
+			ConsulServerConnMgr: watcher,
+			Labels:              c.flagSyncNodeLabels,
+			Log:                 ctrl.Log.WithName("controller").WithName("node-meta"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "node-meta")
+			return 1
+		}
+	}
+
+	if len(c.flagRolloutRestartSecrets) > 0 && len(c.rolloutRestartTargets) > 0 {
+		if err = (&rolloutrestart.Controller{
+			Client:             mgr.GetClient(),
+			Namespace:          c.flagReleaseNamespace,
+			Secrets:            c.flagRolloutRestartSecrets,
+			Targets:            c.rolloutRestartTargets,
+			Pacing:             c.flagRolloutRestartPacing,
+			StateConfigMapName: c.flagResourcePrefix + "-rollout-restart-state",
+			Log:                ctrl.Log.WithName("controller").WithName("rollout-restart"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "rollout-restart")
+			return 1
+		}
+	}
+
+	if c.flagEnterpriseLicenseSecretName != "" || c.flagEnterpriseLicensePath != "" {
+		// Events are recorded on the license secret, or on the injector deployment when the license
+		// comes from Vault.
+		eventObject := &corev1.ObjectReference{
+			Kind:       "Secret",
+			APIVersion: "v1",
+			Namespace:  c.flagReleaseNamespace,
+			Name:       c.flagEnterpriseLicenseSecretName,
+		}
+		if c.flagEnterpriseLicensePath != "" {
+			eventObject = &corev1.ObjectReference{
+				Kind:       "Deployment",
+				APIVersion: "apps/v1",
+				Namespace:  c.flagReleaseNamespace,
+				Name:       c.flagResourcePrefix + "-connect-injector",
+			}
+		}
+		if err = mgr.Add(&license.Controller{
+			Client:              mgr.GetClient(),
+			ConsulClientConfig:  consulConfig,
+			ConsulServerConnMgr: watcher,
+			Namespace:           c.flagReleaseNamespace,
+			SecretName:          c.flagEnterpriseLicenseSecretName,
+			SecretKey:           c.flagEnterpriseLicenseSecretKey,
+			LicensePath:         c.flagEnterpriseLicensePath,
+			Apply:               c.flagEnterpriseLicenseApply,
+			EventObject:         eventObject,
+			Recorder:            mgr.GetEventRecorderFor("consul-connect-injector"),
+			Log:                 ctrl.Log.WithName("controller").WithName("license"),
+		}); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "license")
+			return 1
+		}
+	}
+
+	if c.flagEnablePodMonitors {
+		if err = mgr.Add(&podmonitor.Controller{
+			Client:         mgr.GetClient(),
+			APIReader:      mgr.GetAPIReader(),
+			Namespace:      c.flagReleaseNamespace,
+			ReleaseName:    c.flagReleaseName,
+			ResourcePrefix: c.flagResourcePrefix,
+			Labels:         c.flagPodMonitorLabels,
+			Log:            ctrl.Log.WithName("controller").WithName("pod-monitor"),
+		}); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "pod-monitor")
+			return 1
+		}
+	}
+
+	if c.flagEnableCertMetrics {
+		if err = mgr.Add(&certmetrics.Controller{
+			Client:    mgr.GetClient(),
+			Partition: c.consul.Partition,
+			Log:       ctrl.Log.WithName("controller").WithName("cert-metrics"),
+		}); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "cert-metrics")
+			return 1
+		}
+	}
+
+	if c.flagUIExposeType != "" {
+		var oidc *uiexposure.OIDC
+		if c.flagUIExposeOIDCIssuerURL != "" {
+			oidc = &uiexposure.OIDC{
+				IssuerURL:  c.flagUIExposeOIDCIssuerURL,
+				SecretName: c.flagUIExposeOIDCSecretName,
+				Image:      c.flagUIExposeOIDCProxyImage,
+			}
+		}
+		if err = (&uiexposure.Controller{
+			Namespace:        c.flagReleaseNamespace,
+			ReleaseName:      c.flagReleaseName,
+			ServiceName:      c.flagResourcePrefix + "-ui",
+			Type:             c.flagUIExposeType,
+			Host:             c.flagUIExposeHost,
+			IngressClassName: c.flagUIExposeIngressClass,
+			TLSSecretName:    c.flagUIExposeTLSSecret,
+			GatewayName:      c.flagUIExposeGatewayName,
+			GatewayNamespace: c.flagUIExposeGatewayNamespace,
+			OIDC:             oidc,
+			Log:              ctrl.Log.WithName("controller").WithName("ui-exposure"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ui-exposure")
+			return 1
+		}
+	}
+
+	if c.flagACLAuthMethod != "" && (c.flagAuthMethodReviewerTTL > 0 || c.flagTokenReviewCacheTTL > 0) {
+		authMethodNamespace := ""
+		if c.flagEnableNamespaces && !c.flagEnableK8SNSMirroring {
+			authMethodNamespace = c.flagConsulDestinationNamespace
+		}
+		reviewHost, apiServerCACert := "", ""
+		if c.flagTokenReviewCacheTTL > 0 {
+			reviewHost = fmt.Sprintf("https://%s-connect-injector.%s.svc", c.flagResourcePrefix, c.flagReleaseNamespace)
+			// The auth method is pointed back at the Kubernetes API server if the token review
+			// cache isn't available, with the CA that the injector trusts the API server with.
+			caData := mgr.GetConfig().CAData
+			if len(caData) == 0 && mgr.GetConfig().CAFile != "" {
+				caData, err = os.ReadFile(mgr.GetConfig().CAFile)
+				if err != nil {
+					setupLog.Error(err, "unable to read the CA of the Kubernetes API server")
+					return 1
+				}
+			}
+			apiServerCACert = string(caData)
+		}
+		if err = mgr.Add(&authmethod.Controller{
+			Clientset:              c.clientset,
+			ConsulClientConfig:     consulConfig,
+			ConsulServerConnMgr:    watcher,
+			AuthMethodName:         c.flagACLAuthMethod,
+			AuthMethodNamespace:    authMethodNamespace,
+			Namespace:              c.flagReleaseNamespace,
+			ReviewerServiceAccount: c.flagResourcePrefix + "-auth-method",
+			TokenTTL:               c.flagAuthMethodReviewerTTL,
+			ReviewHost:             reviewHost,
+			ReviewServiceName:      c.flagResourcePrefix + "-connect-injector",
+			APIServerHost:          "https://kubernetes.default.svc",
+			APIServerCACert:        apiServerCACert,
+			WebhookConfigName:      c.flagResourcePrefix + "-connect-injector",
+			Log:                    ctrl.Log.WithName("controller").WithName("auth-method"),
+		}); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "auth-method")
+			return 1
+		}
+	}
+
+	if c.flagEnableMeshReadyCondition {
+		if err = (&meshready.Controller{
+			Client:                 mgr.GetClient(),
+			ConsulClientConfig:     consulConfig,
+			ConsulServerConnMgr:    watcher,
+			EnableConsulNamespaces: c.flagEnableNamespaces,
+			Log:                    ctrl.Log.WithName("controller").WithName("mesh-ready"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "mesh-ready")
+			return 1
+		}
+	}
+
+	if c.flagEnableArgoRollouts {
+		if err = (&argorollouts.Controller{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("controller").WithName("argo-rollouts"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "argo-rollouts")
+			return 1
+		}
+	}
+
 	// API Gateway Controllers
 	if err := gatewaycontrollers.RegisterFieldIndexes(ctx, mgr); err != nil {
 		setupLog.Error(err, "unable to register field indexes")
@@ -563,6 +1296,10 @@ func (c *Command) Run(args []string) int {
 		EnableNSMirroring:          c.flagEnableK8SNSMirroring,
 		NSMirroringPrefix:          c.flagK8SNSMirroringPrefix,
 		CrossNSACLPolicy:           c.flagCrossNamespaceACLPolicy,
+
+		MaxConcurrentReconciles:        c.configEntryMaxConcurrentReconcilesByKind,
+		DefaultMaxConcurrentReconciles: c.flagConfigEntryMaxConcurrentReconciles,
+		FinalizerTimeout:               c.flagConfigEntryFinalizerTimeout,
 	}
 	if err = (&controllers.ServiceDefaultsController{
 		ConfigEntryController: configEntryReconciler,
@@ -681,8 +1418,90 @@ func (c *Command) Run(args []string) int {
 		setupLog.Error(err, "unable to create controller", "controller", apicommon.ControlPlaneRequestLimit)
 		return 1
 	}
+	if err = (&controllers.MeshPolicyDefaultsController{
+		Client:  mgr.GetClient(),
+		Log:     ctrl.Log.WithName("controller").WithName("mesh-policy-defaults"),
+		Scheme:  mgr.GetScheme(),
+		Context: ctx,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "mesh-policy-defaults")
+		return 1
+	}
+	if err = (&controllers.MeshEgressPolicyController{
+		Client:                     mgr.GetClient(),
+		Log:                        ctrl.Log.WithName("controller").WithName("mesh-egress-policy"),
+		Scheme:                     mgr.GetScheme(),
+		Context:                    ctx,
+		EnableConsulNamespaces:     c.flagEnableNamespaces,
+		ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
+		EnableNSMirroring:          c.flagEnableK8SNSMirroring,
+		NSMirroringPrefix:          c.flagK8SNSMirroringPrefix,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "mesh-egress-policy")
+		return 1
+	}
+	consulMeta := apicommon.ConsulMeta{
+		PartitionsEnabled:    c.flagEnablePartitions,
+		Partition:            c.consul.Partition,
+		NamespacesEnabled:    c.flagEnableNamespaces,
+		DestinationNamespace: c.flagConsulDestinationNamespace,
+		Mirroring:            c.flagEnableK8SNSMirroring,
+		Prefix:               c.flagK8SNSMirroringPrefix,
+		FIPSEnabled:          c.flagEnableFIPS || version.IsFIPS(),
+	}
+	if err = (&controllers.IntentionRequestController{
+		Client:     mgr.GetClient(),
+		ConsulMeta: consulMeta,
+		Log:        ctrl.Log.WithName("controller").WithName("intention-request"),
+		Scheme:     mgr.GetScheme(),
+		Context:    ctx,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "intention-request")
+		return 1
+	}
+	if c.flagEnableIntentionsNetworkPolicies {
+		if err = (&controllers.IntentionsNetworkPolicyController{
+			Client:                 mgr.GetClient(),
+			Log:                    ctrl.Log.WithName("controller").WithName("intentions-network-policy"),
+			Scheme:                 mgr.GetScheme(),
+			Context:                ctx,
+			ConsulPartition:        c.consul.Partition,
+			EnableConsulNamespaces: c.flagEnableNamespaces,
+			EnableNSMirroring:      c.flagEnableK8SNSMirroring,
+			NSMirroringPrefix:      c.flagK8SNSMirroringPrefix,
+			DefaultDeny:            c.flagIntentionsDefaultDeny,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "intentions-network-policy")
+			return 1
+		}
+	}
+	if c.flagEnableExternalNameServices {
+		if err = (&externalservices.Controller{
+			Client:                     mgr.GetClient(),
+			ConsulClientConfig:         consulConfig,
+			ConsulServerConnMgr:        watcher,
+			ReleaseNamespace:           c.flagReleaseNamespace,
+			EnableConsulNamespaces:     c.flagEnableNamespaces,
+			ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
+			EnableNSMirroring:          c.flagEnableK8SNSMirroring,
+			NSMirroringPrefix:          c.flagK8SNSMirroringPrefix,
+			CrossNSACLPolicy:           c.flagCrossNamespaceACLPolicy,
+			EnableACLs:                 c.flagACLAuthMethod != "",
+			ResourcePrefix:             c.flagResourcePrefix,
+			Log:                        ctrl.Log.WithName("controller").WithName("external-services"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "external-services")
+			return 1
+		}
+	}
 
-	if err = mgr.AddReadyzCheck("ready", webhook.ReadinessCheck{CertDir: c.flagCertDir}.Ready); err != nil {
+	readinessCheck := webhook.ReadinessCheck{
+		CertDir:       c.flagCertDir,
+		FailurePolicy: admissionv1.FailurePolicyType(c.flagWebhookFailurePolicy),
+		Started:       mgr.GetWebhookServer().StartedChecker(),
+		Drainer:       drainer,
+	}
+	if err = mgr.AddReadyzCheck("ready", readinessCheck.Ready); err != nil {
 		setupLog.Error(err, "unable to create readiness check", "controller", endpoints.Controller{})
 		return 1
 	}
@@ -713,6 +1532,20 @@ func (c *Command) Run(args []string) int {
 			return 1
 		}
 
+		if err = (&peeringexports.Controller{
+			Client:                     mgr.GetClient(),
+			ConsulPartition:            c.consul.Partition,
+			ReleaseNamespace:           c.flagReleaseNamespace,
+			EnableConsulNamespaces:     c.flagEnableNamespaces,
+			ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
+			EnableNSMirroring:          c.flagEnableK8SNSMirroring,
+			NSMirroringPrefix:          c.flagK8SNSMirroringPrefix,
+			Log:                        ctrl.Log.WithName("controller").WithName("peering-exports"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "peering-exports")
+			return 1
+		}
+
 		mgr.GetWebhookServer().Register("/mutate-v1alpha1-peeringacceptors",
 			&ctrlRuntimeWebhook.Admission{Handler: &v1alpha1.PeeringAcceptorWebhook{
 				Client: mgr.GetClient(),
@@ -725,60 +1558,115 @@ func (c *Command) Run(args []string) int {
 			}})
 	}
 
+	var cniVersionChecker *cniversion.Checker
+	if c.flagEnableCNI {
+		checker := &cniversion.Checker{
+			Client:  mgr.GetClient(),
+			Version: version.GetLabelVersion(),
+		}
+		if err = (&cniversion.Controller{
+			Client:   mgr.GetClient(),
+			Checker:  checker,
+			Recorder: mgr.GetEventRecorderFor("consul-connect-injector"),
+			Log:      ctrl.Log.WithName("controller").WithName("cni-version"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "cni-version")
+			return 1
+		}
+		if c.flagCNIBlockOnVersionSkew {
+			cniVersionChecker = checker
+		}
+	}
+
 	mgr.GetWebhookServer().CertDir = c.flagCertDir
 
+	if c.flagACLAuthMethod != "" && c.flagTokenReviewCacheTTL > 0 {
+		tokenReviewCache := &tokenreview.Handler{
+			TokenReviews:    c.clientset.AuthenticationV1().TokenReviews(),
+			ServiceAccounts: mgr.GetClient(),
+			Reviewer:        fmt.Sprintf("system:serviceaccount:%s:%s-auth-method", c.flagReleaseNamespace, c.flagResourcePrefix),
+			Audience:        c.flagConsulLoginAudience,
+			TTL:             c.flagTokenReviewCacheTTL,
+			Log:             ctrl.Log.WithName("handler").WithName("token-review-cache"),
+		}
+		mgr.GetWebhookServer().Register(tokenreview.TokenReviewsPath, tokenReviewCache)
+		mgr.GetWebhookServer().Register(tokenreview.NamespacesPath, tokenReviewCache)
+	}
+
+	var quotaPolicy *quota.Policy
+	if c.flagEnableNamespaceQuotas {
+		quotaPolicy = &quota.Policy{Defaults: quota.Limits{
+			MaxServices:         c.flagQuotaDefaultMaxServices,
+			MaxIntentions:       c.flagQuotaDefaultMaxIntentions,
+			MaxExportedServices: c.flagQuotaDefaultMaxExportedServices,
+		}}
+	}
+
 	mgr.GetWebhookServer().Register("/mutate",
 		&ctrlRuntimeWebhook.Admission{Handler: &webhook.MeshWebhook{
-			Clientset:                    c.clientset,
-			ReleaseNamespace:             c.flagReleaseNamespace,
-			ConsulConfig:                 consulConfig,
-			ConsulServerConnMgr:          watcher,
-			ImageConsul:                  c.flagConsulImage,
-			ImageConsulDataplane:         c.flagConsulDataplaneImage,
-			EnvoyExtraArgs:               c.flagEnvoyExtraArgs,
-			ImageConsulK8S:               c.flagConsulK8sImage,
-			RequireAnnotation:            !c.flagDefaultInject,
-			AuthMethod:                   c.flagACLAuthMethod,
-			ConsulCACert:                 string(caCertPem),
-			TLSEnabled:                   c.consul.UseTLS,
-			ConsulAddress:                c.consul.Addresses,
-			SkipServerWatch:              c.consul.SkipServerWatch,
-			ConsulTLSServerName:          c.consul.TLSServerName,
-			DefaultProxyCPURequest:       sidecarProxyCPURequest,
-			DefaultProxyCPULimit:         sidecarProxyCPULimit,
-			DefaultProxyMemoryRequest:    sidecarProxyMemoryRequest,
-			DefaultProxyMemoryLimit:      sidecarProxyMemoryLimit,
-			DefaultEnvoyProxyConcurrency: c.flagDefaultEnvoyProxyConcurrency,
-			LifecycleConfig:              lifecycleConfig,
-			MetricsConfig:                metricsConfig,
-			InitContainerResources:       initResources,
-			ConsulPartition:              c.consul.Partition,
-			AllowK8sNamespacesSet:        allowK8sNamespaces,
-			DenyK8sNamespacesSet:         denyK8sNamespaces,
-			EnableNamespaces:             c.flagEnableNamespaces,
-			ConsulDestinationNamespace:   c.flagConsulDestinationNamespace,
-			EnableK8SNSMirroring:         c.flagEnableK8SNSMirroring,
-			K8SNSMirroringPrefix:         c.flagK8SNSMirroringPrefix,
-			CrossNamespaceACLPolicy:      c.flagCrossNamespaceACLPolicy,
-			EnableTransparentProxy:       c.flagDefaultEnableTransparentProxy,
-			EnableCNI:                    c.flagEnableCNI,
-			TProxyOverwriteProbes:        c.flagTransparentProxyDefaultOverwriteProbes,
-			EnableConsulDNS:              c.flagEnableConsulDNS,
-			EnableOpenShift:              c.flagEnableOpenShift,
-			Log:                          ctrl.Log.WithName("handler").WithName("connect"),
-			LogLevel:                     c.flagLogLevel,
-			LogJSON:                      c.flagLogJSON,
+			Clientset:                              c.clientset,
+			Cache:                                  mgr.GetClient(),
+			ReleaseNamespace:                       c.flagReleaseNamespace,
+			ConsulConfig:                           consulConfig,
+			ConsulServerConnMgr:                    watcher,
+			ImageConsul:                            c.flagConsulImage,
+			ImageConsulDataplane:                   c.flagConsulDataplaneImage,
+			EnvoyExtraArgs:                         c.flagEnvoyExtraArgs,
+			ImageConsulK8S:                         c.flagConsulK8sImage,
+			NamespaceImageOverrides:                c.flagNamespaceImageOverrides,
+			EnableFIPS:                             c.flagEnableFIPS,
+			RequireAnnotation:                      !c.flagDefaultInject,
+			DryRun:                                 c.flagInjectDryRun,
+			AuthMethod:                             c.flagACLAuthMethod,
+			ConsulLoginAudience:                    c.flagConsulLoginAudience,
+			ConsulCACert:                           string(caCertPem),
+			TLSEnabled:                             c.consul.UseTLS,
+			ConsulAddress:                          c.consul.Addresses,
+			SkipServerWatch:                        c.consul.SkipServerWatch,
+			ConsulTLSServerName:                    c.consul.TLSServerName,
+			DefaultProxyCPURequest:                 sidecarProxyCPURequest,
+			DefaultProxyCPULimit:                   sidecarProxyCPULimit,
+			DefaultProxyMemoryRequest:              sidecarProxyMemoryRequest,
+			DefaultProxyMemoryLimit:                sidecarProxyMemoryLimit,
+			ProxyCPURequestProportion:              cpuRequestProportion,
+			ProxyMemoryRequestProportion:           memoryRequestProportion,
+			DefaultEnvoyProxyConcurrency:           c.flagDefaultEnvoyProxyConcurrency,
+			LifecycleConfig:                        lifecycleConfig,
+			MetricsConfig:                          metricsConfig,
+			InitContainerResources:                 initResources,
+			InitContainerServerWaitTimeout:         c.flagInitContainerServerWaitTimeout,
+			InitContainerServerWaitInitialInterval: c.flagInitContainerServerWaitInitialInterval,
+			InitContainerServerWaitMaxInterval:     c.flagInitContainerServerWaitMaxInterval,
+			InitContainerServerWaitJitter:          c.flagInitContainerServerWaitJitter,
+			ConsulPartition:                        c.consul.Partition,
+			AllowK8sNamespacesSet:                  allowK8sNamespaces,
+			DenyK8sNamespacesSet:                   denyK8sNamespaces,
+			EnableNamespaces:                       c.flagEnableNamespaces,
+			ConsulDestinationNamespace:             c.flagConsulDestinationNamespace,
+			EnableK8SNSMirroring:                   c.flagEnableK8SNSMirroring,
+			K8SNSMirroringPrefix:                   c.flagK8SNSMirroringPrefix,
+			CrossNamespaceACLPolicy:                c.flagCrossNamespaceACLPolicy,
+			EnableTransparentProxy:                 c.flagDefaultEnableTransparentProxy,
+			EnableCNI:                              c.flagEnableCNI,
+			CNIVersionChecker:                      cniVersionChecker,
+			EnableMeshReadyGate:                    c.flagEnableMeshReadyGate,
+			DebugContainerUID:                      c.flagDebugContainerUID,
+			TProxyOverwriteProbes:                  c.flagTransparentProxyDefaultOverwriteProbes,
+			EnableConsulDNS:                        c.flagEnableConsulDNS,
+			EnableOpenShift:                        c.flagEnableOpenShift,
+			EnableRestrictedPodSecurity:            c.flagEnableRestrictedPodSecurity,
+			DefaultSeccompProfile:                  c.flagDefaultSeccompProfile,
+			DefaultAppArmorProfile:                 c.flagDefaultAppArmorProfile,
+			DefaultSELinuxOptions:                  c.flagDefaultSELinuxOptions,
+			EnableIPv6:                             c.flagEnableIPv6,
+			NamespaceUpstreamsConfigMap:            c.flagNamespaceUpstreamsConfigMap,
+			EnableNodeProxy:                        c.flagEnableNodeProxy,
+			QuotaPolicy:                            quotaPolicy,
+			Log:                                    ctrl.Log.WithName("handler").WithName("connect"),
+			LogLevel:                               c.flagLogLevel,
+			LogJSON:                                c.flagLogJSON,
 		}})
 
-	consulMeta := apicommon.ConsulMeta{
-		PartitionsEnabled:    c.flagEnablePartitions,
-		Partition:            c.consul.Partition,
-		NamespacesEnabled:    c.flagEnableNamespaces,
-		DestinationNamespace: c.flagConsulDestinationNamespace,
-		Mirroring:            c.flagEnableK8SNSMirroring,
-		Prefix:               c.flagK8SNSMirroringPrefix,
-	}
-
 	// Note: The path here should be identical to the one on the kubebuilder
 	// annotation in each webhook file.
 	mgr.GetWebhookServer().Register("/mutate-v1alpha1-servicedefaults",
@@ -807,9 +1695,10 @@ func (c *Command) Run(args []string) int {
 		}})
 	mgr.GetWebhookServer().Register("/mutate-v1alpha1-exportedservices",
 		&ctrlRuntimeWebhook.Admission{Handler: &v1alpha1.ExportedServicesWebhook{
-			Client:     mgr.GetClient(),
-			Logger:     ctrl.Log.WithName("webhooks").WithName(apicommon.ExportedServices),
-			ConsulMeta: consulMeta,
+			Client:      mgr.GetClient(),
+			Logger:      ctrl.Log.WithName("webhooks").WithName(apicommon.ExportedServices),
+			ConsulMeta:  consulMeta,
+			QuotaPolicy: quotaPolicy,
 		}})
 	mgr.GetWebhookServer().Register("/mutate-v1alpha1-servicerouter",
 		&ctrlRuntimeWebhook.Admission{Handler: &v1alpha1.ServiceRouterWebhook{
@@ -825,9 +1714,10 @@ func (c *Command) Run(args []string) int {
 		}})
 	mgr.GetWebhookServer().Register("/mutate-v1alpha1-serviceintentions",
 		&ctrlRuntimeWebhook.Admission{Handler: &v1alpha1.ServiceIntentionsWebhook{
-			Client:     mgr.GetClient(),
-			Logger:     ctrl.Log.WithName("webhooks").WithName(apicommon.ServiceIntentions),
-			ConsulMeta: consulMeta,
+			Client:      mgr.GetClient(),
+			Logger:      ctrl.Log.WithName("webhooks").WithName(apicommon.ServiceIntentions),
+			ConsulMeta:  consulMeta,
+			QuotaPolicy: quotaPolicy,
 		}})
 	mgr.GetWebhookServer().Register("/mutate-v1alpha1-ingressgateway",
 		&ctrlRuntimeWebhook.Admission{Handler: &v1alpha1.IngressGatewayWebhook{
@@ -860,6 +1750,19 @@ func (c *Command) Run(args []string) int {
 			ConsulMeta: consulMeta,
 		}})
 
+	// The conversion webhook converts config entry CRDs between API versions
+	// using the v1alpha1 types as the hub. CRDs only call it once they are
+	// configured with the Webhook conversion strategy.
+	mgr.GetWebhookServer().Register("/convert", &conversion.Webhook{})
+
+	if c.flagServerConfigMapName != "" {
+		mgr.GetWebhookServer().Register("/validate-server-config",
+			&ctrlRuntimeWebhook.Admission{Handler: &webhook.ServerConfigWebhook{
+				Log:           ctrl.Log.WithName("handler").WithName("server-config"),
+				ConfigMapName: c.flagServerConfigMapName,
+			}})
+	}
+
 	if c.flagEnableWebhookCAUpdate {
 		err = c.updateWebhookCABundle(ctx)
 		if err != nil {
@@ -891,6 +1794,9 @@ func (c *Command) updateWebhookCABundle(ctx context.Context) error {
 }
 
 func (c *Command) validateFlags() error {
+	if err := c.consul.Validate(); err != nil {
+		return err
+	}
 	if c.flagConsulK8sImage == "" {
 		return errors.New("-consul-k8s-image must be set")
 	}
@@ -900,6 +1806,23 @@ func (c *Command) validateFlags() error {
 	if c.flagConsulDataplaneImage == "" {
 		return errors.New("-consul-dataplane-image must be set")
 	}
+	if c.flagEnableFIPS {
+		images := []struct{ flag, image string }{
+			{"consul-image", c.flagConsulImage},
+			{"consul-dataplane-image", c.flagConsulDataplaneImage},
+			{"consul-k8s-image", c.flagConsulK8sImage},
+		}
+		for _, i := range images {
+			// FIPS variants of the images are published with "fips" in their name or tag,
+			// e.g. hashicorp/consul-dataplane-fips.
+			if !strings.Contains(i.image, "fips") {
+				return fmt.Errorf("-%s=%s is invalid: must be a FIPS variant if -enable-fips is set", i.flag, i.image)
+			}
+		}
+		if !version.IsFIPS() {
+			return errors.New("-enable-fips is set but this is not a FIPS build of consul-k8s")
+		}
+	}
 
 	if c.flagEnablePartitions && c.consul.Partition == "" {
 		return errors.New("-partition must set if -enable-partitions is set to 'true'")
@@ -913,9 +1836,244 @@ func (c *Command) validateFlags() error {
 		return errors.New("-default-envoy-proxy-concurrency must be >= 0 if set")
 	}
 
+	// Redirecting traffic from the init container requires NET_ADMIN, which the restricted standard forbids.
+	if c.flagEnableRestrictedPodSecurity && c.flagDefaultEnableTransparentProxy && !c.flagEnableCNI {
+		return errors.New("-enable-cni must be set to 'true' if -enable-restricted-pod-security and -default-enable-transparent-proxy are set")
+	}
+
+	if c.flagDefaultSeccompProfile != "" {
+		if _, err := webhook.ParseSeccompProfile(c.flagDefaultSeccompProfile); err != nil {
+			return fmt.Errorf("-default-seccomp-profile is invalid: %w", err)
+		}
+	}
+	if c.flagDefaultAppArmorProfile != "" {
+		if err := webhook.ValidateAppArmorProfile(c.flagDefaultAppArmorProfile); err != nil {
+			return fmt.Errorf("-default-apparmor-profile is invalid: %w", err)
+		}
+	}
+	if c.flagDefaultSELinuxOptions != "" {
+		if _, err := webhook.ParseSELinuxOptions(c.flagDefaultSELinuxOptions); err != nil {
+			return fmt.Errorf("-default-selinux-options is invalid: %w", err)
+		}
+	}
+
+	// Pods with the readiness gate would never become ready without the controller that sets the condition.
+	if c.flagEnableMeshReadyGate && !c.flagEnableMeshReadyCondition {
+		return errors.New("-enable-mesh-ready-condition must be set to 'true' if -enable-mesh-ready-gate is set")
+	}
+
+	switch c.flagUIExposeType {
+	case "", uiexposure.TypeIngress, uiexposure.TypeRoute:
+	case uiexposure.TypeGateway:
+		if c.flagUIExposeGatewayName == "" {
+			return fmt.Errorf("-ui-expose-gateway-name must be set if -ui-expose-type=%s", uiexposure.TypeGateway)
+		}
+	default:
+		return fmt.Errorf("-ui-expose-type=%s is invalid: must be one of %q, %q or %q",
+			c.flagUIExposeType, uiexposure.TypeIngress, uiexposure.TypeRoute, uiexposure.TypeGateway)
+	}
+	if c.flagUIExposeOIDCIssuerURL != "" && c.flagUIExposeOIDCSecretName == "" {
+		return errors.New("-ui-expose-oidc-secret-name must be set if -ui-expose-oidc-issuer-url is set")
+	}
+	if c.flagACLAuthMethod == "" && (c.flagAuthMethodReviewerTTL > 0 || c.flagTokenReviewCacheTTL > 0) {
+		return errors.New("-acl-auth-method must be set if -auth-method-reviewer-token-ttl or -token-review-cache-ttl is set")
+	}
+	if c.flagQuotaDefaultMaxServices < 0 || c.flagQuotaDefaultMaxIntentions < 0 || c.flagQuotaDefaultMaxExportedServices < 0 {
+		return errors.New("-quota-default-max-services, -quota-default-max-intentions and -quota-default-max-exported-services must not be negative")
+	}
+
+	if c.flagTracingProvider != "" {
+		if c.flagTracingProvider != tracing.ProviderZipkin && c.flagTracingProvider != tracing.ProviderOpenTelemetry {
+			return fmt.Errorf("-tracing-provider=%s is invalid: must be one of %q or %q",
+				c.flagTracingProvider, tracing.ProviderZipkin, tracing.ProviderOpenTelemetry)
+		}
+		if _, _, err := net.SplitHostPort(c.flagTracingCollectorAddress); err != nil {
+			return fmt.Errorf("-tracing-collector-address=%s is invalid: must be formatted as host:port", c.flagTracingCollectorAddress)
+		}
+	}
+	if c.flagNetworkGatewayAddress != "" {
+		if _, _, err := parseHostPort(c.flagNetworkGatewayAddress); err != nil {
+			return fmt.Errorf("-network-gateway-address=%s is invalid: must be formatted as host:port", c.flagNetworkGatewayAddress)
+		}
+	}
+	c.serviceNameTemplate = nil
+	if c.flagServiceNameTemplate != "" {
+		tmpl, err := endpoints.ParseServiceNameTemplate(c.flagServiceNameTemplate)
+		if err != nil {
+			return fmt.Errorf("-service-name-template=%s is invalid: %s", c.flagServiceNameTemplate, err)
+		}
+		c.serviceNameTemplate = tmpl
+	}
+	if c.flagEnableNodeProxy {
+		minPort, maxPort, err := parsePortRange(c.flagNodeProxyPortRange)
+		if err != nil {
+			return fmt.Errorf("-node-proxy-port-range=%s is invalid: %s", c.flagNodeProxyPortRange, err)
+		}
+		c.nodeProxyMinPort, c.nodeProxyMaxPort = minPort, maxPort
+	}
+	if c.flagWebhookFailurePolicy != string(admissionv1.Fail) && c.flagWebhookFailurePolicy != string(admissionv1.Ignore) {
+		return fmt.Errorf("-webhook-failure-policy=%s is invalid: must be one of %q or %q",
+			c.flagWebhookFailurePolicy, admissionv1.Fail, admissionv1.Ignore)
+	}
+	if c.flagTerminatingPodDrainWindow < 0 {
+		return fmt.Errorf("-terminating-pod-drain-window=%s is invalid: must not be negative", c.flagTerminatingPodDrainWindow)
+	}
+	if c.flagFullSyncInterval < 0 {
+		return fmt.Errorf("-full-sync-interval=%s is invalid: must not be negative", c.flagFullSyncInterval)
+	}
+	if c.flagOrphanReaperInterval < 0 {
+		return fmt.Errorf("-orphan-reaper-interval=%s is invalid: must not be negative", c.flagOrphanReaperInterval)
+	}
+	if c.flagOrphanReaperTTL < 0 {
+		return fmt.Errorf("-orphan-reaper-ttl=%s is invalid: must not be negative", c.flagOrphanReaperTTL)
+	}
+	if c.flagInitContainerServerWaitTimeout < 0 || c.flagInitContainerServerWaitInitialInterval < 0 || c.flagInitContainerServerWaitMaxInterval < 0 {
+		return errors.New("-init-container-server-wait-timeout, -init-container-server-wait-initial-interval and -init-container-server-wait-max-interval must not be negative")
+	}
+	initialInterval := c.flagInitContainerServerWaitInitialInterval
+	if initialInterval == 0 {
+		initialInterval = discovery.DefaultBackOffInitialInterval
+	}
+	if c.flagInitContainerServerWaitMaxInterval != 0 && c.flagInitContainerServerWaitMaxInterval < initialInterval {
+		return fmt.Errorf("-init-container-server-wait-max-interval=%s is invalid: must be at least the initial interval of %s",
+			c.flagInitContainerServerWaitMaxInterval, initialInterval)
+	}
+	if c.flagInitContainerServerWaitJitter < 0 || c.flagInitContainerServerWaitJitter > 1 {
+		return fmt.Errorf("-init-container-server-wait-jitter=%v is invalid: must be between 0 and 1", c.flagInitContainerServerWaitJitter)
+	}
+	if c.flagConfigEntryMaxConcurrentReconciles < 1 {
+		return fmt.Errorf("-config-entry-max-concurrent-reconciles=%d is invalid: must be at least 1", c.flagConfigEntryMaxConcurrentReconciles)
+	}
+	c.configEntryMaxConcurrentReconcilesByKind = make(map[string]int)
+	for kind, raw := range c.flagConfigEntryMaxConcurrentReconcilesByKind {
+		if !configEntryKinds[kind] {
+			return fmt.Errorf("-config-entry-max-concurrent-reconciles-by-kind=%s=%s is invalid: unknown kind %q", kind, raw, kind)
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return fmt.Errorf("-config-entry-max-concurrent-reconciles-by-kind=%s=%s is invalid: count must be an integer of at least 1", kind, raw)
+		}
+		c.configEntryMaxConcurrentReconcilesByKind[kind] = n
+	}
+	if c.flagConfigEntryFinalizerTimeout < 0 {
+		return fmt.Errorf("-config-entry-finalizer-timeout=%s is invalid: must not be negative", c.flagConfigEntryFinalizerTimeout)
+	}
+	if c.flagK8sResyncPeriod < 0 {
+		return fmt.Errorf("-k8s-resync-period=%s is invalid: must not be negative", c.flagK8sResyncPeriod)
+	}
+	if c.flagK8sListPageSize < 0 {
+		return fmt.Errorf("-k8s-list-page-size=%d is invalid: must not be negative", c.flagK8sListPageSize)
+	}
+	if c.flagConsulQueryWait < 0 {
+		return fmt.Errorf("-consul-query-wait-time=%s is invalid: must not be negative", c.flagConsulQueryWait)
+	}
+	if c.flagLeaderElectionRetryPeriod <= 0 || c.flagLeaderElectionRenewDeadline <= c.flagLeaderElectionRetryPeriod ||
+		c.flagLeaderElectionLeaseDuration <= c.flagLeaderElectionRenewDeadline {
+		return errors.New("-leader-election-lease-duration must be greater than -leader-election-renew-deadline, " +
+			"which must be greater than -leader-election-retry-period, which must be greater than 0")
+	}
+	if c.flagShutdownDrainDuration < 0 {
+		return fmt.Errorf("-shutdown-drain-duration=%s is invalid: must not be negative", c.flagShutdownDrainDuration)
+	}
+	if c.flagDebugContainerUID < 0 || c.flagDebugContainerUID > math.MaxUint32 {
+		return fmt.Errorf("-debug-container-uid=%d is invalid: must be a valid user ID", c.flagDebugContainerUID)
+	}
+	if c.flagDefaultTracingSamplingPercentage < 0 || c.flagDefaultTracingSamplingPercentage > 100 {
+		return errors.New("-default-tracing-sampling-percentage must be between 0 and 100")
+	}
+	c.rolloutRestartTargets = nil
+	for _, raw := range c.flagRolloutRestartTargets {
+		target, err := rolloutrestart.ParseTarget(raw)
+		if err != nil {
+			return fmt.Errorf("-rollout-restart-target is invalid: %w", err)
+		}
+		c.rolloutRestartTargets = append(c.rolloutRestartTargets, target)
+	}
+	if c.flagEnterpriseLicenseSecretName != "" && c.flagEnterpriseLicenseSecretKey == "" {
+		return errors.New("-enterprise-license-secret-key must be set if -enterprise-license-secret-name is set")
+	}
+	if c.flagRolloutRestartPacing < 0 {
+		return errors.New("-rollout-restart-pacing must be >= 0")
+	}
+
 	return nil
 }
 
+// configEntryKinds are the kinds of config entry custom resources, which are the keys of
+// -config-entry-max-concurrent-reconciles-by-kind.
+var configEntryKinds = map[string]bool{
+	apicommon.ServiceDefaults:          true,
+	apicommon.ProxyDefaults:            true,
+	apicommon.ServiceResolver:          true,
+	apicommon.ServiceRouter:            true,
+	apicommon.ServiceSplitter:          true,
+	apicommon.ServiceIntentions:        true,
+	apicommon.ExportedServices:         true,
+	apicommon.IngressGateway:           true,
+	apicommon.TerminatingGateway:       true,
+	apicommon.SamenessGroup:            true,
+	apicommon.JWTProvider:              true,
+	apicommon.ControlPlaneRequestLimit: true,
+	apicommon.Mesh:                     true,
+}
+
+// parseHostPort splits an address formatted as host:port into its host and numeric port.
+func parseHostPort(addr string) (string, int, error) {
+	host, rawPort, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.Atoi(rawPort)
+	if err != nil {
+		return "", 0, err
+	}
+	return host, port, nil
+}
+
+// parseProportionalRequest parses the -default-sidecar-proxy-<resource>-request-percent, -min and -max flags.
+func parseProportionalRequest(resourceName string, percent int, min, max string) (webhook.ProportionalRequest, error) {
+	flagPrefix := fmt.Sprintf("-default-sidecar-proxy-%s-request", resourceName)
+	if percent < 0 || percent > 100 {
+		return webhook.ProportionalRequest{}, fmt.Errorf("%s-percent=%d is invalid: must be between 0 and 100", flagPrefix, percent)
+	}
+	proportion := webhook.ProportionalRequest{Percent: percent}
+	var err error
+	if min != "" {
+		if proportion.Min, err = resource.ParseQuantity(min); err != nil {
+			return webhook.ProportionalRequest{}, fmt.Errorf("%s-min=%s is invalid: %s", flagPrefix, min, err)
+		}
+	}
+	if max != "" {
+		if proportion.Max, err = resource.ParseQuantity(max); err != nil {
+			return webhook.ProportionalRequest{}, fmt.Errorf("%s-max=%s is invalid: %s", flagPrefix, max, err)
+		}
+	}
+	if min != "" && max != "" && proportion.Min.Cmp(proportion.Max) > 0 {
+		return webhook.ProportionalRequest{}, fmt.Errorf("%s-min=%s is invalid: must be <= %s-max", flagPrefix, min, flagPrefix)
+	}
+	return proportion, nil
+}
+
+// parsePortRange parses a range of ports formatted as min-max.
+func parsePortRange(raw string) (int, int, error) {
+	rawMin, rawMax, ok := strings.Cut(raw, "-")
+	if !ok {
+		return 0, 0, errors.New("must be formatted as min-max")
+	}
+	minPort, err := strconv.Atoi(rawMin)
+	if err != nil || minPort < 1 || minPort > 65535 {
+		return 0, 0, fmt.Errorf("%s is not a valid port", rawMin)
+	}
+	maxPort, err := strconv.Atoi(rawMax)
+	if err != nil || maxPort < 1 || maxPort > 65535 {
+		return 0, 0, fmt.Errorf("%s is not a valid port", rawMax)
+	}
+	if minPort > maxPort {
+		return 0, 0, errors.New("min port must not be greater than max port")
+	}
+	return minPort, maxPort, nil
+}
+
 func (c *Command) parseAndValidateResourceFlags() (corev1.ResourceRequirements, error) {
 	// Init container
 	var initContainerCPULimit, initContainerCPURequest, initContainerMemoryLimit, initContainerMemoryRequest resource.Quantity
@@ -6,6 +6,7 @@ package connectinject
 import (
 	"testing"
 
+	"github.com/hashicorp/consul-k8s/control-plane/version"
 	"github.com/mitchellh/cli"
 	"github.com/stretchr/testify/require"
 	"k8s.io/client-go/kubernetes/fake"
@@ -28,6 +29,11 @@ func TestRun_FlagValidation(t *testing.T) {
 			flags:  []string{"-consul-k8s-image", "foo", "-consul-image", "foo"},
 			expErr: "-consul-dataplane-image must be set",
 		},
+		{
+			flags: []string{"-consul-k8s-image", "consul-k8s-control-plane-fips:1.2.0", "-consul-image", "consul-enterprise-fips:1.16.0-ent",
+				"-consul-dataplane-image", "consul-dataplane:1.2.0", "-enable-fips"},
+			expErr: "-consul-dataplane-image=consul-dataplane:1.2.0 is invalid: must be a FIPS variant if -enable-fips is set",
+		},
 		{
 			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
 				"-log-level", "invalid"},
@@ -82,6 +88,21 @@ func TestRun_FlagValidation(t *testing.T) {
 			},
 			expErr: "request must be <= limit: -default-sidecar-proxy-cpu-request value of \"50m\" is greater than the -default-sidecar-proxy-cpu-limit value of \"25m\"",
 		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-default-sidecar-proxy-cpu-request-percent=101"},
+			expErr: "-default-sidecar-proxy-cpu-request-percent=101 is invalid: must be between 0 and 100",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-default-sidecar-proxy-memory-request-min=unparseable"},
+			expErr: "-default-sidecar-proxy-memory-request-min=unparseable is invalid",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-default-sidecar-proxy-memory-request-min=1Gi", "-default-sidecar-proxy-memory-request-max=512Mi"},
+			expErr: "-default-sidecar-proxy-memory-request-min=1Gi is invalid: must be <= -default-sidecar-proxy-memory-request-max",
+		},
 		{
 			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
 				"-init-container-cpu-limit=unparseable"},
@@ -132,6 +153,232 @@ func TestRun_FlagValidation(t *testing.T) {
 			},
 			expErr: "-default-envoy-proxy-concurrency must be >= 0 if set",
 		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-enable-restricted-pod-security", "-default-enable-transparent-proxy=true",
+			},
+			expErr: "-enable-cni must be set to 'true' if -enable-restricted-pod-security and -default-enable-transparent-proxy are set",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-default-seccomp-profile", "localhost",
+			},
+			expErr: `-default-seccomp-profile is invalid: invalid seccomp profile "localhost": must be runtime/default, unconfined or localhost/<profile>`,
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-default-apparmor-profile", "RuntimeDefault",
+			},
+			expErr: `-default-apparmor-profile is invalid: invalid AppArmor profile "RuntimeDefault": must be runtime/default, unconfined or localhost/<profile>`,
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-default-selinux-options", "kind=spc_t",
+			},
+			expErr: `-default-selinux-options is invalid: invalid SELinux options "kind=spc_t": unknown option "kind", must be one of user, role, type or level`,
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-enable-mesh-ready-gate",
+			},
+			expErr: "-enable-mesh-ready-condition must be set to 'true' if -enable-mesh-ready-gate is set",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-tracing-provider=jaeger", "-tracing-collector-address=collector:9411",
+			},
+			expErr: "-tracing-provider=jaeger is invalid: must be one of \"zipkin\" or \"opentelemetry\"",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-tracing-provider=zipkin", "-tracing-collector-address=collector",
+			},
+			expErr: "-tracing-collector-address=collector is invalid: must be formatted as host:port",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-network-gateway-address=gateway.example.com",
+			},
+			expErr: "-network-gateway-address=gateway.example.com is invalid: must be formatted as host:port",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-service-name-template={{ .Deployment",
+			},
+			expErr: "-service-name-template={{ .Deployment is invalid: template: :1: unclosed action",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-enable-node-proxy", "-node-proxy-port-range=22999-22000",
+			},
+			expErr: "-node-proxy-port-range=22999-22000 is invalid: min port must not be greater than max port",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-enable-node-proxy", "-node-proxy-port-range=22000",
+			},
+			expErr: "-node-proxy-port-range=22000 is invalid: must be formatted as min-max",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-webhook-failure-policy=Retry",
+			},
+			expErr: "-webhook-failure-policy=Retry is invalid: must be one of \"Fail\" or \"Ignore\"",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-ui-expose-type=loadbalancer",
+			},
+			expErr: "-ui-expose-type=loadbalancer is invalid: must be one of \"ingress\", \"route\" or \"gateway\"",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-ui-expose-type=gateway",
+			},
+			expErr: "-ui-expose-gateway-name must be set if -ui-expose-type=gateway",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-ui-expose-type=ingress", "-ui-expose-oidc-issuer-url=https://issuer.example.com",
+			},
+			expErr: "-ui-expose-oidc-secret-name must be set if -ui-expose-oidc-issuer-url is set",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-token-review-cache-ttl=30s",
+			},
+			expErr: "-acl-auth-method must be set if -auth-method-reviewer-token-ttl or -token-review-cache-ttl is set",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-enable-namespace-quotas", "-quota-default-max-intentions=-1",
+			},
+			expErr: "-quota-default-max-services, -quota-default-max-intentions and -quota-default-max-exported-services must not be negative",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-shutdown-drain-duration=-1s",
+			},
+			expErr: "-shutdown-drain-duration=-1s is invalid: must not be negative",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-terminating-pod-drain-window=-1s",
+			},
+			expErr: "-terminating-pod-drain-window=-1s is invalid: must not be negative",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-full-sync-interval=-1s",
+			},
+			expErr: "-full-sync-interval=-1s is invalid: must not be negative",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-orphan-reaper-interval=-1s",
+			},
+			expErr: "-orphan-reaper-interval=-1s is invalid: must not be negative",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-orphan-reaper-ttl=-1s",
+			},
+			expErr: "-orphan-reaper-ttl=-1s is invalid: must not be negative",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-config-entry-finalizer-timeout=-1s",
+			},
+			expErr: "-config-entry-finalizer-timeout=-1s is invalid: must not be negative",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-k8s-resync-period=-1m",
+			},
+			expErr: "-k8s-resync-period=-1m0s is invalid: must not be negative",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-k8s-list-page-size=-1",
+			},
+			expErr: "-k8s-list-page-size=-1 is invalid: must not be negative",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-consul-query-wait-time=-1s",
+			},
+			expErr: "-consul-query-wait-time=-1s is invalid: must not be negative",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-config-entry-max-concurrent-reconciles=0",
+			},
+			expErr: "-config-entry-max-concurrent-reconciles=0 is invalid: must be at least 1",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-config-entry-max-concurrent-reconciles-by-kind=servicedefault=2",
+			},
+			expErr: `-config-entry-max-concurrent-reconciles-by-kind=servicedefault=2 is invalid: unknown kind "servicedefault"`,
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-config-entry-max-concurrent-reconciles-by-kind=serviceintentions=many",
+			},
+			expErr: "-config-entry-max-concurrent-reconciles-by-kind=serviceintentions=many is invalid: count must be an integer of at least 1",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-leader-election-lease-duration=10s", "-leader-election-renew-deadline=10s",
+			},
+			expErr: "-leader-election-lease-duration must be greater than -leader-election-renew-deadline",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-default-tracing-sampling-percentage=101",
+			},
+			expErr: "-default-tracing-sampling-percentage must be between 0 and 100",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-rollout-restart-target=daemonset/consul-client",
+			},
+			expErr: `-rollout-restart-target is invalid: "daemonset/consul-client" must be in the form deployment/<name> or statefulset/<name>`,
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-enterprise-license-secret-name=consul-license",
+			},
+			expErr: "-enterprise-license-secret-key must be set if -enterprise-license-secret-name is set",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-init-container-server-wait-timeout=-1s",
+			},
+			expErr: "-init-container-server-wait-timeout, -init-container-server-wait-initial-interval and -init-container-server-wait-max-interval must not be negative",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-init-container-server-wait-max-interval=100ms",
+			},
+			expErr: "-init-container-server-wait-max-interval=100ms is invalid: must be at least the initial interval of 500ms",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-consul-dataplane-image", "consul-dataplane:1.14.0",
+				"-init-container-server-wait-jitter=2",
+			},
+			expErr: "-init-container-server-wait-jitter=2 is invalid: must be between 0 and 1",
+		},
+	}
+	if !version.IsFIPS() {
+		cases = append(cases, struct {
+			flags  []string
+			expErr string
+		}{
+			flags: []string{"-consul-k8s-image", "consul-k8s-control-plane-fips:1.2.0", "-consul-image", "consul-enterprise-fips:1.16.0-ent",
+				"-consul-dataplane-image", "consul-dataplane-fips:1.2.0", "-enable-fips"},
+			expErr: "-enable-fips is set but this is not a FIPS build of consul-k8s",
+		})
 	}
 
 	for _, c := range cases {
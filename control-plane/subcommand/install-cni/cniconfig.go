@@ -16,9 +16,11 @@ import (
 	"github.com/mitchellh/mapstructure"
 )
 
-// defaultCNIConfigFile gets the the correct config file from the cni net dir.
+// defaultCNIConfigFile gets the the correct config file from the cni net dir. If pluginType is set, only config files
+// that contain a plugin of that type are considered so that consul-cni is chained after a specific primary plugin,
+// e.g. Cilium or Calico, when there are config files for several plugins on the node.
 // Adapted from kubelet: https://github.com/kubernetes/kubernetes/blob/954996e231074dc7429f7be1256a579bedd8344c/pkg/kubelet/dockershim/network/cni/cni.go#L134.
-func defaultCNIConfigFile(dir string) (string, error) {
+func defaultCNIConfigFile(dir, pluginType string) (string, error) {
 	files, err := libcni.ConfFiles(dir, []string{".conf", ".conflist"})
 	if err != nil {
 		return "", fmt.Errorf("error while trying to find files in %s: %w", dir, err)
@@ -61,12 +63,30 @@ func defaultCNIConfigFile(dir string) (string, error) {
 			// CNI config list has no networks, skipping".
 			continue
 		}
+		if pluginType != "" && !hasPluginType(confList, pluginType) {
+			// The config file is for a different primary plugin.
+			continue
+		}
 		return confFile, nil
 	}
+	// The primary plugin may not have written its config file yet and it is ok to run this function again.
+	if pluginType != "" {
+		return "", nil
+	}
 	// There were files but none of them were valid
 	return "", fmt.Errorf("no valid config files found in %s", dir)
 }
 
+// hasPluginType returns true if the config list contains a plugin of the given type.
+func hasPluginType(confList *libcni.NetworkConfigList, pluginType string) bool {
+	for _, plugin := range confList.Plugins {
+		if plugin.Network.Type == pluginType {
+			return true
+		}
+	}
+	return false
+}
+
 // confListFileFromConfFile converts a .conf file into a .conflist file. Chained plugins use .conflist files.
 func confListFileFromConfFile(cfgFile string) (string, error) {
 	if !strings.HasSuffix(cfgFile, ".conf") {
@@ -21,11 +21,57 @@ func TestDefaultCNIConfigFile_NoFiles(t *testing.T) {
 	cfgFile := ""
 	tempDir := t.TempDir()
 
-	actual, err := defaultCNIConfigFile(tempDir)
+	actual, err := defaultCNIConfigFile(tempDir, "")
 	require.Equal(t, cfgFile, actual)
 	require.Equal(t, nil, err)
 }
 
+// TestDefaultCNIConfigFile_ChainedPluginType tests that the config file of the given primary plugin is chosen when
+// there are config files for several plugins in the directory.
+func TestDefaultCNIConfigFile_ChainedPluginType(t *testing.T) {
+	cases := []struct {
+		name         string
+		pluginType   string
+		expectedFile string
+	}{
+		{
+			name:         "no plugin type chooses first file",
+			pluginType:   "",
+			expectedFile: "05-cilium.conflist",
+		},
+		{
+			name:         "cilium",
+			pluginType:   "cilium-cni",
+			expectedFile: "05-cilium.conflist",
+		},
+		{
+			name:         "calico",
+			pluginType:   "calico",
+			expectedFile: "10-calico.conflist",
+		},
+		{
+			name:         "plugin not installed yet",
+			pluginType:   "aws-cni",
+			expectedFile: "",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			require.NoError(t, copyFile("testdata/05-cilium.conflist", tempDir))
+			require.NoError(t, copyFile("testdata/10-calico.conflist", tempDir))
+
+			actual, err := defaultCNIConfigFile(tempDir, c.pluginType)
+			require.NoError(t, err)
+			if c.expectedFile == "" {
+				require.Equal(t, "", actual)
+			} else {
+				require.Equal(t, filepath.Join(tempDir, c.expectedFile), actual)
+			}
+		})
+	}
+}
+
 // TestDefaultCNIConfigFile tests finding the correct config file in the cniNetDir directory.
 func TestDefaultCNIConfigFile(t *testing.T) {
 	cases := []struct {
@@ -72,7 +118,7 @@ func TestDefaultCNIConfigFile(t *testing.T) {
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			tempDir := c.dir(c.cfgFile)
-			actual, err := defaultCNIConfigFile(tempDir)
+			actual, err := defaultCNIConfigFile(tempDir, "")
 
 			filepath := filepath.Join(tempDir, c.expectedFile)
 			require.Equal(t, filepath, actual)
@@ -18,8 +18,10 @@ import (
 	"github.com/hashicorp/consul-k8s/control-plane/cni/config"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/hashicorp/consul-k8s/control-plane/version"
 	"github.com/hashicorp/go-hclog"
 	"github.com/mitchellh/cli"
+	"k8s.io/client-go/kubernetes"
 )
 
 const (
@@ -47,9 +49,18 @@ type Command struct {
 	flagLogJSON bool
 	// flagMultus is a boolean flag for multus support.
 	flagMultus bool
+	// flagChainedPluginType is the type of the primary CNI plugin that consul-cni is chained after. When set,
+	// the config file containing a plugin of this type is used rather than the first config file in cniNetDir.
+	flagChainedPluginType string
+	// flagNodeName is the name of the node the installer is running on. When set, the node is labeled with the
+	// version of the installed consul-cni plugin.
+	flagNodeName string
 
 	flagSet *flag.FlagSet
 
+	// clientset is used to label the node. It is only set directly in tests.
+	clientset kubernetes.Interface
+
 	once   sync.Once
 	help   string
 	logger hclog.Logger
@@ -67,6 +78,12 @@ func (c *Command) init() {
 			"\"debug\", \"info\", \"warn\", and \"error\".")
 	c.flagSet.BoolVar(&c.flagLogJSON, "log-json", defaultLogJSON, "Enable or disable JSON output format for logging.")
 	c.flagSet.BoolVar(&c.flagMultus, "multus", config.DefaultMultus, "If the plugin is a multus plugin (default = false)")
+	c.flagSet.StringVar(&c.flagChainedPluginType, "chained-plugin-type", "",
+		"Type of the primary CNI plugin to chain consul-cni after, e.g. cilium-cni or calico. If not set, "+
+			"consul-cni is chained after the first plugin configuration in the CNI net dir.")
+	c.flagSet.StringVar(&c.flagNodeName, "node-name", "",
+		"Name of the node the installer is running on. If set, the node is labeled with the version of the "+
+			"installed consul-cni plugin so that version skew with the connect-inject webhook can be detected.")
 
 	c.help = flags.Usage(help, c.flagSet)
 
@@ -115,7 +132,9 @@ func (c *Command) Run(args []string) int {
 		"cni_net_dir", cfg.CNINetDir,
 		"multus", cfg.Multus,
 		"kubeconfig", cfg.Kubeconfig,
-		"log_level", cfg.LogLevel)
+		"log_level", cfg.LogLevel,
+		"chained_plugin_type", c.flagChainedPluginType,
+		"node_name", c.flagNodeName)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -139,7 +158,7 @@ func (c *Command) Run(args []string) int {
 
 	// Get the config file that is on the host.
 	c.logger.Info("Getting default config file from", "destination", cfg.CNINetDir)
-	cfgFile, err := defaultCNIConfigFile(cfg.CNINetDir)
+	cfgFile, err := defaultCNIConfigFile(cfg.CNINetDir, c.flagChainedPluginType)
 	if err != nil {
 		c.logger.Error("could not get default CNI config file", "error", err)
 		return 1
@@ -160,7 +179,8 @@ func (c *Command) Run(args []string) int {
 		// before other cni plugins on the node. We will add a directory watcher and wait for another plugin to
 		// be installed.
 		if cfgFile == "" {
-			c.logger.Info("CNI config file not found. Consul-cni is a chained plugin and another plugin must be installed first. Waiting...", "directory", cfg.CNINetDir)
+			c.logger.Info("CNI config file not found. Consul-cni is a chained plugin and another plugin must be installed first. Waiting...",
+				"directory", cfg.CNINetDir, "chained_plugin_type", c.flagChainedPluginType)
 		} else {
 			// Check if there is valid config in the config file. It is invalid if no consul-cni config exists,
 			// the consul-cni config is not the last in the plugin chain or the consul-cni config is different from
@@ -184,6 +204,22 @@ func (c *Command) Run(args []string) int {
 		c.logger.Info("Multus enabled, using multus NetworkAttachementDefinition for configuration")
 	}
 
+	// Label the node with the version of the installed plugin. This is not critical to the installation, the
+	// webhook treats nodes without the label as having an unknown version.
+	if c.flagNodeName != "" {
+		if c.clientset == nil {
+			c.clientset, err = newClientset()
+			if err != nil {
+				c.logger.Error("could not create kubernetes client", "error", err)
+				return 1
+			}
+		}
+		c.logger.Info("Labeling node with consul-cni version", "node", c.flagNodeName, "version", version.GetLabelVersion())
+		if err := labelNode(ctx, c.clientset, c.flagNodeName, version.GetLabelVersion()); err != nil {
+			c.logger.Error("Unable to label node", "error", err)
+		}
+	}
+
 	// Watch for changes in the cniNetDir directory and fix/install the config file if need be.
 	err = c.directoryWatcher(ctx, cfg, cfg.CNINetDir, cfgFile)
 	if err != nil {
@@ -209,6 +245,14 @@ func (c *Command) cleanup(cfg *config.CNIConfig, cfgFile string) {
 	if err != nil {
 		c.logger.Error("Unable to remove %s file: %w", kubeconfig, err)
 	}
+
+	if c.flagNodeName != "" && c.clientset != nil {
+		// The context passed to the directory watcher may already be cancelled.
+		err = unlabelNode(context.Background(), c.clientset, c.flagNodeName)
+		if err != nil {
+			c.logger.Error("Unable to remove consul-cni version label from node", "error", err)
+		}
+	}
 }
 
 // directoryWatcher watches for changes in the cniNetDir forever. We watch the directory because there is a case where
@@ -248,7 +292,7 @@ func (c *Command) directoryWatcher(ctx context.Context, cfg *config.CNIConfig, d
 					c.logger.Info("Modified event", "event", event)
 					// Always get the config file that is on the host as we do not know if it was deleted
 					// or not.
-					cfgFile, err = defaultCNIConfigFile(dir)
+					cfgFile, err = defaultCNIConfigFile(dir, c.flagChainedPluginType)
 					if err != nil {
 						c.logger.Error("Unable get default config file", "error", err)
 						break
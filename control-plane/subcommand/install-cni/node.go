@@ -0,0 +1,55 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package installcni
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// newClientset creates a kubernetes client from the installer's service account.
+func newClientset() (kubernetes.Interface, error) {
+	restCfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(restCfg)
+}
+
+// labelNode sets the consul-cni version label on the node so that the connect-inject webhook can detect when the
+// plugin on the node is a different version than the webhook, e.g. in the middle of an upgrade.
+func labelNode(ctx context.Context, client kubernetes.Interface, nodeName, version string) error {
+	return patchNodeVersionLabel(ctx, client, nodeName, &version)
+}
+
+// unlabelNode removes the consul-cni version label from the node once the plugin has been uninstalled.
+func unlabelNode(ctx context.Context, client kubernetes.Interface, nodeName string) error {
+	return patchNodeVersionLabel(ctx, client, nodeName, nil)
+}
+
+// patchNodeVersionLabel sets the consul-cni version label to version, or removes it if version is nil.
+func patchNodeVersionLabel(ctx context.Context, client kubernetes.Interface, nodeName string, version *string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]*string{
+				constants.LabelConsulCNIVersion: version,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.CoreV1().Nodes().Patch(ctx, nodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("could not patch %s label on node %s: %w", constants.LabelConsulCNIVersion, nodeName, err)
+	}
+	return nil
+}
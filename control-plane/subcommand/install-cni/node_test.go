@@ -0,0 +1,46 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package installcni
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/control-plane/connect-inject/constants"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLabelNode(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node-1",
+			Labels: map[string]string{"kubernetes.io/os": "linux"},
+		},
+	})
+
+	err := labelNode(ctx, client, "node-1", "1.2.0-dev")
+	require.NoError(t, err)
+	node, err := client.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"kubernetes.io/os":              "linux",
+		constants.LabelConsulCNIVersion: "1.2.0-dev",
+	}, node.Labels)
+
+	// Removing the label leaves the other labels in place.
+	err = unlabelNode(ctx, client, "node-1")
+	require.NoError(t, err)
+	node, err = client.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"kubernetes.io/os": "linux"}, node.Labels)
+}
+
+func TestLabelNode_NodeNotFound(t *testing.T) {
+	err := labelNode(context.Background(), fake.NewSimpleClientset(), "node-1", "1.2.0-dev")
+	require.EqualError(t, err, `could not patch consul.hashicorp.com/consul-cni-version label on node node-1: nodes "node-1" not found`)
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -30,6 +31,13 @@ type Command struct {
 	flagLogJSON  bool
 	flagTimeout  time.Duration
 
+	// flagAdditionalPartitions are partitions that are created in addition to -partition,
+	// in the form <name> or <name>=<description>.
+	flagAdditionalPartitions flags.AppendSliceValue
+	// flagEnableCrossNamespacePolicy creates the cross namespace policy in each partition
+	// and sets it as a policy default of the partition's default namespace.
+	flagEnableCrossNamespacePolicy bool
+
 	// ctx is cancelled when the command timeout is reached.
 	ctx           context.Context
 	retryDuration time.Duration
@@ -51,6 +59,13 @@ func (c *Command) init() {
 			"\"debug\", \"info\", \"warn\", and \"error\".")
 	c.flags.BoolVar(&c.flagLogJSON, "log-json", false,
 		"Enable or disable JSON output format for logging.")
+	c.flags.Var(&c.flagAdditionalPartitions, "additional-partition",
+		"An Admin Partition to create in addition to -partition, in the form <name> or <name>=<description>. "+
+			"If the partition already exists, its description is updated. May be specified multiple times.")
+	c.flags.BoolVar(&c.flagEnableCrossNamespacePolicy, "enable-cross-namespace-policy", false,
+		"Create the cross namespace ACL policy in each partition and add it to the policy defaults of the "+
+			"partition's default namespace, so that services can discover services in other namespaces of "+
+			"the partition. Requires a token that can write ACLs.")
 
 	c.consul = &flags.ConsulFlags{}
 	flags.Merge(c.flags, c.consul.Flags())
@@ -126,26 +141,123 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
-	for {
-		partition, _, err := consulClient.Partitions().Read(c.ctx, c.consul.Partition, nil)
-		// The API does not return an error if the Partition does not exist. It returns a nil Partition.
+	for _, partition := range c.partitions() {
+		err = c.untilSucceeds(fmt.Sprintf("creating Admin Partition %q", partition.Name), func() error {
+			return c.ensurePartition(consulClient, partition)
+		})
 		if err != nil {
-			c.log.Error("Error reading Partition from Consul", "name", c.consul.Partition, "error", err.Error())
-		} else if partition == nil {
-			// Retry Admin Partition creation until it succeeds, or we reach the command timeout.
-			_, _, err = consulClient.Partitions().Create(c.ctx, &api.Partition{
-				Name:        c.consul.Partition,
-				Description: "Created by Helm installation",
-			}, nil)
-			if err == nil {
-				c.log.Info("Successfully created Admin Partition", "name", c.consul.Partition)
-				return 0
+			c.log.Error("Timed out attempting to create partition", "name", partition.Name)
+			return 1
+		}
+		if c.flagEnableCrossNamespacePolicy {
+			err = c.untilSucceeds(fmt.Sprintf("creating cross namespace policy in Admin Partition %q", partition.Name), func() error {
+				return c.ensureCrossNamespacePolicy(consulClient, partition.Name)
+			})
+			if err != nil {
+				c.log.Error("Timed out attempting to create cross namespace policy", "partition", partition.Name)
+				return 1
 			}
-			c.log.Error("Error creating partition", "name", c.consul.Partition, "error", err.Error())
-		} else {
-			c.log.Info("Admin Partition already exists", "name", c.consul.Partition)
-			return 0
 		}
+	}
+	return 0
+}
+
+// partitions returns the partition of the -partition flag followed by the additional partitions.
+// The description of the -partition flag's partition is only set when it's created.
+func (c *Command) partitions() []api.Partition {
+	partitions := []api.Partition{{Name: c.consul.Partition}}
+	for _, raw := range c.flagAdditionalPartitions {
+		name, description, _ := strings.Cut(raw, "=")
+		partitions = append(partitions, api.Partition{Name: name, Description: description})
+	}
+	return partitions
+}
+
+// ensurePartition creates the partition if it doesn't exist. If it exists and a description is set,
+// the description of the existing partition is updated.
+func (c *Command) ensurePartition(consulClient *api.Client, partition api.Partition) error {
+	existing, _, err := consulClient.Partitions().Read(c.ctx, partition.Name, nil)
+	// The API does not return an error if the Partition does not exist. It returns a nil Partition.
+	if err != nil {
+		return fmt.Errorf("reading partition: %w", err)
+	}
+	if existing == nil {
+		if partition.Description == "" {
+			partition.Description = "Created by Helm installation"
+		}
+		if _, _, err = consulClient.Partitions().Create(c.ctx, &partition, nil); err != nil {
+			return fmt.Errorf("creating partition: %w", err)
+		}
+		c.log.Info("Successfully created Admin Partition", "name", partition.Name)
+		return nil
+	}
+	if partition.Description == "" || partition.Description == existing.Description {
+		c.log.Info("Admin Partition already exists", "name", partition.Name)
+		return nil
+	}
+	existing.Description = partition.Description
+	if _, _, err = consulClient.Partitions().Update(c.ctx, existing, nil); err != nil {
+		return fmt.Errorf("updating partition: %w", err)
+	}
+	c.log.Info("Updated description of existing Admin Partition", "name", partition.Name)
+	return nil
+}
+
+// ensureCrossNamespacePolicy creates or updates the cross namespace policy in the partition and
+// adds it to the policy defaults of the partition's default namespace. This matches the policy that
+// server-acl-init creates, which namespaces created by consul-k8s components reference.
+func (c *Command) ensureCrossNamespacePolicy(consulClient *api.Client, partitionName string) error {
+	policy := api.ACLPolicy{
+		Name:        crossNamespacePolicyName,
+		Description: "Policy to allow permissions to cross Consul namespaces for k8s services",
+		Rules:       fmt.Sprintf(crossNamespaceRulesTpl, partitionName),
+		Partition:   partitionName,
+	}
+	existing, _, err := consulClient.ACL().PolicyReadByName(policy.Name, &api.QueryOptions{Partition: partitionName})
+	if err != nil {
+		return fmt.Errorf("reading policy: %w", err)
+	}
+	if existing == nil {
+		if _, _, err = consulClient.ACL().PolicyCreate(&policy, &api.WriteOptions{Partition: partitionName}); err != nil {
+			return fmt.Errorf("creating policy: %w", err)
+		}
+	} else if existing.Rules != policy.Rules {
+		policy.ID = existing.ID
+		if _, _, err = consulClient.ACL().PolicyUpdate(&policy, &api.WriteOptions{Partition: partitionName}); err != nil {
+			return fmt.Errorf("updating policy: %w", err)
+		}
+	}
+
+	namespace, _, err := consulClient.Namespaces().Read(defaultNamespace, &api.QueryOptions{Partition: partitionName})
+	if err != nil {
+		return fmt.Errorf("reading default namespace: %w", err)
+	}
+	if namespace == nil {
+		return fmt.Errorf("default namespace of partition %q not found", partitionName)
+	}
+	if namespace.ACLs == nil {
+		namespace.ACLs = &api.NamespaceACLConfig{}
+	}
+	for _, link := range namespace.ACLs.PolicyDefaults {
+		if link.Name == policy.Name {
+			return nil
+		}
+	}
+	namespace.ACLs.PolicyDefaults = append(namespace.ACLs.PolicyDefaults, api.ACLLink{Name: policy.Name})
+	if _, _, err = consulClient.Namespaces().Update(namespace, &api.WriteOptions{Partition: partitionName}); err != nil {
+		return fmt.Errorf("updating default namespace: %w", err)
+	}
+	return nil
+}
+
+// untilSucceeds runs op until it returns no error or the command timeout is reached.
+func (c *Command) untilSucceeds(opName string, op func() error) error {
+	for {
+		err := op()
+		if err == nil {
+			return nil
+		}
+		c.log.Error(fmt.Sprintf("Failure: %s", opName), "error", err.Error())
 		// Wait on either the retry duration (in which case we continue) or the
 		// overall command timeout.
 		c.log.Info("Retrying in " + c.retryDuration.String())
@@ -153,8 +265,7 @@ func (c *Command) Run(args []string) int {
 		case <-time.After(c.retryDuration):
 			continue
 		case <-c.ctx.Done():
-			c.log.Error("Timed out attempting to create partition", "name", c.consul.Partition)
-			return 1
+			return errors.New("reached command timeout")
 		}
 	}
 }
@@ -171,15 +282,36 @@ func (c *Command) validateFlags() error {
 	if c.consul.APITimeout <= 0 {
 		return errors.New("-api-timeout must be set to a value greater than 0")
 	}
+
+	for _, raw := range c.flagAdditionalPartitions {
+		if name, _, _ := strings.Cut(raw, "="); name == "" {
+			return fmt.Errorf("-additional-partition %q must have a name", raw)
+		}
+	}
 	return nil
 }
 
+const (
+	defaultNamespace         = "default"
+	crossNamespacePolicyName = "cross-namespace-policy"
+	crossNamespaceRulesTpl   = `partition %q {
+  namespace_prefix "" {
+    service_prefix "" {
+      policy = "read"
+    }
+    node_prefix "" {
+      policy = "read"
+    }
+  }
+}`
+)
+
 const synopsis = "Initialize an Admin Partition in Consul."
 const help = `
 Usage: consul-k8s-control-plane partition-init [options]
 
   Bootstraps Consul with non-default Admin Partitions.
-  It will run until the partition has been created or the operation times out. It is idempotent
+  It will run until the partitions have been created or the operation times out. It is idempotent
   and safe to run multiple times.
 
 `
@@ -45,6 +45,14 @@ func TestRun_FlagValidation(t *testing.T) {
 			},
 			expErr: "unknown log level: invalid",
 		},
+		{
+			flags: []string{
+				"-addresses", "foo",
+				"-partition", "bar",
+				"-additional-partition", "=description",
+			},
+			expErr: `-additional-partition "=description" must have a name`,
+		},
 	}
 
 	for _, c := range cases {
@@ -133,6 +141,104 @@ func TestRun_PartitionExists(t *testing.T) {
 	require.Equal(t, "Created before test", partition.Description)
 }
 
+func TestRun_AdditionalPartitions(t *testing.T) {
+	server, err := testutil.NewTestServerConfigT(t, nil)
+	require.NoError(t, err)
+	server.WaitForLeader(t)
+	defer server.Stop()
+
+	consul, err := api.NewClient(&api.Config{
+		Address: server.HTTPAddr,
+	})
+	require.NoError(t, err)
+
+	// Create one of the additional partitions before the test runs.
+	_, _, err = consul.Partitions().Create(context.Background(), &api.Partition{Name: "existing", Description: "Created before test"}, nil)
+	require.NoError(t, err)
+
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI: ui,
+	}
+	cmd.init()
+	args := []string{
+		"-addresses=" + "127.0.0.1",
+		"-http-port=" + strings.Split(server.HTTPAddr, ":")[1],
+		"-grpc-port=" + strings.Split(server.GRPCAddr, ":")[1],
+		"-partition", "test-partition",
+		"-additional-partition", "team-a=Partition of team A",
+		"-additional-partition", "team-b",
+		"-additional-partition", "existing=Updated description",
+	}
+	require.Equal(t, 0, cmd.Run(args), ui.ErrorWriter.String())
+
+	expDescriptions := map[string]string{
+		"test-partition": "Created by Helm installation",
+		"team-a":         "Partition of team A",
+		"team-b":         "Created by Helm installation",
+		"existing":       "Updated description",
+	}
+	for name, expDescription := range expDescriptions {
+		partition, _, err := consul.Partitions().Read(context.Background(), name, nil)
+		require.NoError(t, err)
+		require.NotNil(t, partition, name)
+		require.Equal(t, expDescription, partition.Description)
+	}
+
+	// Running the command again is a no-op.
+	cmd = Command{UI: ui}
+	cmd.init()
+	require.Equal(t, 0, cmd.Run(args), ui.ErrorWriter.String())
+}
+
+func TestRun_CrossNamespacePolicy(t *testing.T) {
+	bootToken := "b1gs33cr3t"
+	server, err := testutil.NewTestServerConfigT(t, func(c *testutil.TestServerConfig) {
+		c.ACL.Enabled = true
+		c.ACL.Tokens.InitialManagement = bootToken
+	})
+	require.NoError(t, err)
+	server.WaitForLeader(t)
+	defer server.Stop()
+
+	consul, err := api.NewClient(&api.Config{
+		Address: server.HTTPAddr,
+		Token:   bootToken,
+	})
+	require.NoError(t, err)
+
+	// Run the command twice to check that it's idempotent.
+	for i := 0; i < 2; i++ {
+		ui := cli.NewMockUi()
+		cmd := Command{
+			UI: ui,
+		}
+		cmd.init()
+		args := []string{
+			"-addresses=" + "127.0.0.1",
+			"-http-port=" + strings.Split(server.HTTPAddr, ":")[1],
+			"-grpc-port=" + strings.Split(server.GRPCAddr, ":")[1],
+			"-token", bootToken,
+			"-partition", "test-partition",
+			"-additional-partition", "team-a",
+			"-enable-cross-namespace-policy",
+		}
+		require.Equal(t, 0, cmd.Run(args), ui.ErrorWriter.String())
+	}
+
+	for _, name := range []string{"test-partition", "team-a"} {
+		policy, _, err := consul.ACL().PolicyReadByName("cross-namespace-policy", &api.QueryOptions{Partition: name})
+		require.NoError(t, err)
+		require.NotNil(t, policy, name)
+		require.Contains(t, policy.Rules, `partition "`+name+`"`)
+
+		namespace, _, err := consul.Namespaces().Read("default", &api.QueryOptions{Partition: name})
+		require.NoError(t, err)
+		require.NotNil(t, namespace)
+		require.Equal(t, []api.ACLLink{{ID: policy.ID, Name: policy.Name}}, namespace.ACLs.PolicyDefaults)
+	}
+}
+
 func TestRun_ExitsAfterTimeout(t *testing.T) {
 	partitionName := "test-partition"
 
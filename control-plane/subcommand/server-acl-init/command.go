@@ -49,8 +49,9 @@ type Command struct {
 
 	flagClient bool
 
-	flagSyncCatalog        bool
-	flagSyncConsulNodeName string
+	flagSyncCatalog          bool
+	flagSyncConsulNodeName   string
+	flagSyncConsulNodeShards int
 
 	flagConnectInject       bool
 	flagAuthMethodHost      string
@@ -70,8 +71,9 @@ type Command struct {
 	flagServerPort uint
 
 	// Flags for ACL replication.
-	flagCreateACLReplicationToken bool
-	flagACLReplicationTokenFile   string
+	flagCreateACLReplicationToken         bool
+	flagACLReplicationTokenFile           string
+	flagACLReplicationTokenRotationPeriod time.Duration
 
 	// Flags to support partitions.
 	flagPartitionTokenFile string
@@ -140,6 +142,9 @@ func (c *Command) init() {
 	c.flags.StringVar(&c.flagSyncConsulNodeName, "sync-consul-node-name", "k8s-sync",
 		"The Consul node name to register for catalog sync. Defaults to k8s-sync. To be discoverable "+
 			"via DNS, the name should only contain alpha-numerics and dashes.")
+	c.flags.IntVar(&c.flagSyncConsulNodeShards, "sync-consul-node-shards", 1,
+		"The number of Consul nodes that catalog sync registers services with. If more than one, the "+
+			"catalog sync token can also write the nodes prefixed with the node name and a dash.")
 
 	c.flags.BoolVar(&c.flagConnectInject, "connect-inject", false,
 		"Toggle for configuring ACL login for Connect inject.")
@@ -197,6 +202,11 @@ func (c *Command) init() {
 		"Toggle for creating a token for ACL replication between datacenters.")
 	c.flags.StringVar(&c.flagACLReplicationTokenFile, "acl-replication-token-file", "",
 		"Path to file containing ACL token to be used for ACL replication. If set, ACL replication is enabled.")
+	c.flags.DurationVar(&c.flagACLReplicationTokenRotationPeriod, "acl-replication-token-rotation-period", 0,
+		"If set, the ACL replication token created by -create-acl-replication-token is replaced by a new token once it "+
+			"is older than this period. The replaced token is deleted by create-federation-secret once the servers of secondary "+
+			"datacenters have restarted with the new token, and the token isn't rotated again until then. Defaults to 0, "+
+			"which disables rotation.")
 
 	c.flags.BoolVar(&c.flagFederation, "federation", false, "Toggle for when federation has been enabled.")
 
@@ -656,6 +666,9 @@ func (c *Command) Run(args []string) int {
 			err = c.createACLWithSecretID(common.ACLReplicationTokenName, rules, consulDC, primary, consulClient, aclReplicationToken, false)
 		} else {
 			err = c.createGlobalACL(common.ACLReplicationTokenName, rules, consulDC, primary, consulClient)
+			if err == nil && c.flagACLReplicationTokenRotationPeriod > 0 {
+				err = c.rotateACLReplicationToken(consulClient)
+			}
 		}
 		if err != nil {
 			c.log.Error(err.Error())
@@ -985,6 +998,13 @@ func (c *Command) validateFlags() error {
 		return errors.New("-consul-api-timeout must be set to a value greater than 0")
 	}
 
+	if c.flagACLReplicationTokenRotationPeriod < 0 {
+		return errors.New("-acl-replication-token-rotation-period must not be negative")
+	}
+	if c.flagACLReplicationTokenRotationPeriod > 0 && !c.flagCreateACLReplicationToken {
+		return errors.New("-acl-replication-token-rotation-period requires -create-acl-replication-token")
+	}
+
 	//if c.flagVaultNamespace != "" && c.flagSecretsBackend != SecretsBackendTypeVault {
 	//	return fmt.Errorf("-vault-namespace not supported for -secrets-backend=%q", c.flagSecretsBackend)
 	//}
@@ -81,6 +81,22 @@ func TestRun_FlagValidation(t *testing.T) {
 			ExpErr: "-sync-consul-node-name=5r9OPGfSRXUdGzNjBdAwmhCBrzHDNYs4XjZVR4wp7lSLIzqwS0ta51nBLIN0TMPV-too-long is invalid: node name will not be discoverable " +
 				"via DNS due to it being too long. Valid lengths are between 1 and 63 bytes",
 		},
+		{
+			Flags: []string{
+				"-addresses=localhost",
+				"-resource-prefix=prefix",
+				"-acl-replication-token-rotation-period=-1h",
+			},
+			ExpErr: "-acl-replication-token-rotation-period must not be negative",
+		},
+		{
+			Flags: []string{
+				"-addresses=localhost",
+				"-resource-prefix=prefix",
+				"-acl-replication-token-rotation-period=720h",
+			},
+			ExpErr: "-acl-replication-token-rotation-period requires -create-acl-replication-token",
+		},
 	}
 
 	for _, c := range cases {
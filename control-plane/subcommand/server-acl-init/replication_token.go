@@ -0,0 +1,82 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package serveraclinit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul/api"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rotateACLReplicationToken replaces the ACL replication token stored in its Kubernetes secret with a
+// clone of the token once it's older than -acl-replication-token-rotation-period. Secondary datacenters
+// still use the replaced token until their servers restart with the new one from the federation secret,
+// so the replaced token is left for create-federation-secret to delete once they have. The token isn't
+// rotated again until then.
+func (c *Command) rotateACLReplicationToken(consulClient *api.Client) error {
+	secretName := c.withPrefix(common.ACLReplicationTokenName + "-acl-token")
+
+	var secret *apiv1.Secret
+	err := c.untilSucceeds(fmt.Sprintf("getting Secret %s", secretName),
+		func() error {
+			var err error
+			secret, err = c.clientset.CoreV1().Secrets(c.flagK8sNamespace).Get(c.ctx, secretName, metav1.GetOptions{})
+			return err
+		})
+	if err != nil {
+		return err
+	}
+
+	var token *api.ACLToken
+	err = c.untilSucceeds("reading ACL replication token",
+		func() error {
+			var err error
+			token, _, err = consulClient.ACL().TokenReadSelf(&api.QueryOptions{Token: string(secret.Data[common.ACLTokenSecretKey])})
+			return err
+		})
+	if err != nil {
+		return err
+	}
+	if age := time.Since(token.CreateTime); age < c.flagACLReplicationTokenRotationPeriod {
+		c.log.Info("ACL replication token is not due for rotation", "age", age.Round(time.Second))
+		return nil
+	}
+
+	if previous := secret.Annotations[common.ACLReplicationPreviousTokenAnnotation]; previous != "" {
+		c.log.Info("Not rotating ACL replication token because secondary datacenters haven't switched from the previous token yet",
+			"previous-accessor-id", previous)
+		return nil
+	}
+
+	var rotated *api.ACLToken
+	err = c.untilSucceeds("creating new ACL replication token",
+		func() error {
+			var err error
+			rotated, _, err = consulClient.ACL().TokenClone(token.AccessorID, token.Description, nil)
+			return err
+		})
+	if err != nil {
+		return err
+	}
+
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	secret.Annotations[common.ACLReplicationPreviousTokenAnnotation] = token.AccessorID
+	secret.Data[common.ACLTokenSecretKey] = []byte(rotated.SecretID)
+	err = c.untilSucceeds(fmt.Sprintf("updating Secret %s", secretName),
+		func() error {
+			_, err := c.clientset.CoreV1().Secrets(c.flagK8sNamespace).Update(c.ctx, secret, metav1.UpdateOptions{})
+			return err
+		})
+	if err != nil {
+		return err
+	}
+	c.log.Info("Rotated ACL replication token", "previous-accessor-id", token.AccessorID, "accessor-id", rotated.AccessorID)
+	return nil
+}
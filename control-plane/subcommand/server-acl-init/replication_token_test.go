@@ -0,0 +1,100 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package serveraclinit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRotateACLReplicationToken(t *testing.T) {
+	cases := map[string]struct {
+		tokenAge           time.Duration
+		previousAccessorID string
+		expRotated         bool
+	}{
+		"token is not due for rotation": {
+			tokenAge:   time.Hour,
+			expRotated: false,
+		},
+		"first rotation": {
+			tokenAge:   48 * time.Hour,
+			expRotated: true,
+		},
+		"previous token hasn't been deleted yet": {
+			tokenAge:           48 * time.Hour,
+			previousAccessorID: "previous-accessor-id",
+			expRotated:         false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/v1/acl/token/self":
+					require.Equal(t, "current-secret-id", r.Header.Get("X-Consul-Token"))
+					_ = json.NewEncoder(w).Encode(api.ACLToken{
+						AccessorID:  "current-accessor-id",
+						SecretID:    "current-secret-id",
+						Description: "acl-replication-token Token",
+						CreateTime:  time.Now().Add(-c.tokenAge),
+					})
+				case r.Method == http.MethodPut && r.URL.Path == "/v1/acl/token/current-accessor-id/clone":
+					_ = json.NewEncoder(w).Encode(api.ACLToken{
+						AccessorID: "new-accessor-id",
+						SecretID:   "new-secret-id",
+					})
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}))
+			defer consulServer.Close()
+			consulClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+			require.NoError(t, err)
+
+			secretName := resourcePrefix + "-acl-replication-acl-token"
+			secret := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: ns},
+				Data:       map[string][]byte{common.ACLTokenSecretKey: []byte("current-secret-id")},
+			}
+			if c.previousAccessorID != "" {
+				secret.Annotations = map[string]string{common.ACLReplicationPreviousTokenAnnotation: c.previousAccessorID}
+			}
+			k8s := fake.NewSimpleClientset(secret)
+
+			cmd := Command{
+				clientset:                             k8s,
+				log:                                   hclog.NewNullLogger(),
+				ctx:                                   context.Background(),
+				flagResourcePrefix:                    resourcePrefix,
+				flagK8sNamespace:                      ns,
+				flagACLReplicationTokenRotationPeriod: 24 * time.Hour,
+			}
+			require.NoError(t, cmd.rotateACLReplicationToken(consulClient))
+
+			updated, err := k8s.CoreV1().Secrets(ns).Get(context.Background(), secretName, metav1.GetOptions{})
+			require.NoError(t, err)
+			if c.expRotated {
+				require.Equal(t, "new-secret-id", string(updated.Data[common.ACLTokenSecretKey]))
+				require.Equal(t, "current-accessor-id", updated.Annotations[common.ACLReplicationPreviousTokenAnnotation])
+			} else {
+				require.Equal(t, "current-secret-id", string(updated.Data[common.ACLTokenSecretKey]))
+				require.Equal(t, c.previousAccessorID, updated.Annotations[common.ACLReplicationPreviousTokenAnnotation])
+			}
+		})
+	}
+}
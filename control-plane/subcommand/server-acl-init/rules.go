@@ -21,6 +21,7 @@ type rulesData struct {
 	InjectEnableNSMirroring bool
 	InjectNSMirroringPrefix string
 	SyncConsulNodeName      string
+	SyncConsulNodeShards    int
 }
 
 type gatewayRulesData struct {
@@ -283,6 +284,11 @@ func (c *Command) syncRules() (string, error) {
   node "{{ .SyncConsulNodeName }}" {
     policy = "write"
   }
+{{- if gt .SyncConsulNodeShards 1 }}
+  node_prefix "{{ .SyncConsulNodeName }}-" {
+    policy = "write"
+  }
+{{- end }}
 {{- if .EnableNamespaces }}
 {{- if .EnablePartitions }}
 partition "{{ .PartitionName }}" {
@@ -409,6 +415,7 @@ func (c *Command) rulesData() rulesData {
 		InjectEnableNSMirroring: c.flagEnableInjectK8SNSMirroring,
 		InjectNSMirroringPrefix: c.flagInjectK8SNSMirroringPrefix,
 		SyncConsulNodeName:      c.flagSyncConsulNodeName,
+		SyncConsulNodeShards:    c.flagSyncConsulNodeShards,
 	}
 }
 
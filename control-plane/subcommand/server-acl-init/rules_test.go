@@ -584,6 +584,7 @@ func TestSyncRules(t *testing.T) {
 		EnableSyncK8SNSMirroring       bool
 		SyncK8SNSMirroringPrefix       string
 		SyncConsulNodeName             string
+		SyncConsulNodeShards           int
 		Expected                       string
 	}{
 		{
@@ -918,6 +919,23 @@ partition "foo" {
   }
 }`,
 		},
+		{
+			Name:                 "Namespaces are disabled, node shards",
+			SyncConsulNodeName:   "k8s-sync",
+			SyncConsulNodeShards: 3,
+			Expected: `node "k8s-sync" {
+    policy = "write"
+  }
+  node_prefix "k8s-sync-" {
+    policy = "write"
+  }
+    node_prefix "" {
+      policy = "read"
+    }
+    service_prefix "" {
+      policy = "write"
+    }`,
+		},
 	}
 
 	for _, tt := range cases {
@@ -929,6 +947,7 @@ partition "foo" {
 				flagEnableSyncK8SNSMirroring:       tt.EnableSyncK8SNSMirroring,
 				flagSyncK8SNSMirroringPrefix:       tt.SyncK8SNSMirroringPrefix,
 				flagSyncConsulNodeName:             tt.SyncConsulNodeName,
+				flagSyncConsulNodeShards:           tt.SyncConsulNodeShards,
 			}
 
 			syncRules, err := cmd.syncRules()
@@ -0,0 +1,155 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package serverprobe
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+)
+
+const (
+	// probeStartup and probeLiveness pass as long as the server's HTTP API responds, whatever its
+	// Raft state. Servers don't serve their HTTP API while they restore a Raft snapshot on start,
+	// so the startup probe keeps the liveness probe from killing servers during long restores.
+	probeStartup  = "startup"
+	probeLiveness = "liveness"
+	// probeReadiness passes once the server knows the leader of the cluster.
+	probeReadiness = "readiness"
+)
+
+// state is the Raft state of a server as seen through its HTTP API.
+type state string
+
+const (
+	stateLeader      state = "leader"
+	stateFollower    state = "follower"
+	stateNeedsPeers  state = "needs peers"
+	stateNoLeader    state = "no leader"
+	stateUnavailable state = "unavailable"
+)
+
+// The server-probe command probes the local Consul server. It's run as the exec
+// startup, liveness and readiness probe of the Consul server containers.
+type Command struct {
+	UI cli.Ui
+
+	flagProbe           string
+	flagAdvertiseIP     string
+	flagBootstrapExpect int
+
+	flagSet *flag.FlagSet
+	http    *flags.HTTPFlags
+
+	once sync.Once
+	help string
+}
+
+func (c *Command) init() {
+	c.flagSet = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flagSet.StringVar(&c.flagProbe, "probe", probeReadiness,
+		fmt.Sprintf("The probe to run. Supported values are %q, %q and %q.", probeStartup, probeLiveness, probeReadiness))
+	c.flagSet.StringVar(&c.flagAdvertiseIP, "advertise-ip", "",
+		"The IP address the server advertises to its peers. It's used to tell if the server is the leader.")
+	c.flagSet.IntVar(&c.flagBootstrapExpect, "bootstrap-expect", 0,
+		"The number of servers the cluster expects before it elects a leader.")
+
+	c.http = &flags.HTTPFlags{}
+	flags.Merge(c.flagSet, c.http.Flags())
+	c.help = flags.Usage(help, c.flagSet)
+}
+
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+
+	if err := c.flagSet.Parse(args); err != nil {
+		return 1
+	}
+	if c.flagProbe != probeStartup && c.flagProbe != probeLiveness && c.flagProbe != probeReadiness {
+		c.UI.Error(fmt.Sprintf("-probe must be one of %q, %q or %q", probeStartup, probeLiveness, probeReadiness))
+		return 1
+	}
+	if c.http.ConsulAPITimeout() <= 0 {
+		c.UI.Error("-consul-api-timeout must be set to a value greater than 0")
+		return 1
+	}
+
+	cfg := api.DefaultConfig()
+	c.http.MergeOntoConfig(cfg)
+	consulClient, err := consul.NewClient(cfg, c.http.ConsulAPITimeout())
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Unable to get client connection: %s", err))
+		return 1
+	}
+
+	s, detail := c.serverState(consulClient)
+	if c.passes(s) {
+		c.UI.Output(fmt.Sprintf("Server state: %s%s", s, detail))
+		return 0
+	}
+	c.UI.Error(fmt.Sprintf("Server state: %s%s", s, detail))
+	return 1
+}
+
+// serverState returns the Raft state of the server, and details about it for the probe output.
+func (c *Command) serverState(client *api.Client) (state, string) {
+	leader, err := client.Status().Leader()
+	if err != nil {
+		return stateUnavailable, fmt.Sprintf(" (%s)", err)
+	}
+	if leader == "" {
+		peers, err := client.Status().Peers()
+		if err != nil {
+			return stateUnavailable, fmt.Sprintf(" (%s)", err)
+		}
+		if len(peers) < c.flagBootstrapExpect {
+			return stateNeedsPeers, fmt.Sprintf(" (%d of %d servers have joined)", len(peers), c.flagBootstrapExpect)
+		}
+		return stateNoLeader, " (an election is in progress)"
+	}
+
+	leaderIP, _, err := net.SplitHostPort(leader)
+	if err != nil {
+		leaderIP = leader
+	}
+	if c.flagAdvertiseIP != "" && net.ParseIP(leaderIP).Equal(net.ParseIP(c.flagAdvertiseIP)) {
+		return stateLeader, ""
+	}
+	return stateFollower, fmt.Sprintf(" of leader %s", leader)
+}
+
+// passes returns whether the probe passes for the server state. Only the readiness probe depends
+// on the Raft state, so that servers that are waiting for their peers or for an election aren't
+// restarted.
+func (c *Command) passes(s state) bool {
+	switch c.flagProbe {
+	case probeReadiness:
+		return s == stateLeader || s == stateFollower
+	default:
+		return s != stateUnavailable
+	}
+}
+
+func (c *Command) Synopsis() string { return synopsis }
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+const synopsis = "Probe the Raft state of the local Consul server."
+const help = `
+Usage: consul-k8s-control-plane server-probe [options]
+
+  Probes the local Consul server and exits 0 if the probe passes. The startup
+  and liveness probes pass as long as the server's HTTP API responds. The
+  readiness probe passes once the server is the leader or a follower of a
+  known leader.
+  Not intended for stand-alone use.
+`
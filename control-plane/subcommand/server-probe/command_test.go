@@ -0,0 +1,124 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package serverprobe
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		flags  []string
+		expErr string
+	}{
+		{
+			flags:  []string{"-probe=foo"},
+			expErr: `-probe must be one of "startup", "liveness" or "readiness"`,
+		},
+		{
+			flags:  []string{},
+			expErr: "-consul-api-timeout must be set to a value greater than 0",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui}
+			exitCode := cmd.Run(c.flags)
+			require.Equal(t, 1, exitCode, ui.ErrorWriter.String())
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+		})
+	}
+}
+
+func TestRun_Probes(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		leader       string
+		peers        []string
+		unavailable  bool
+		expOutput    string
+		expLiveness  int
+		expReadiness int
+	}{
+		"leader": {
+			leader:       "10.0.0.1:8300",
+			peers:        []string{"10.0.0.1:8300", "10.0.0.2:8300", "10.0.0.3:8300"},
+			expOutput:    "Server state: leader",
+			expLiveness:  0,
+			expReadiness: 0,
+		},
+		"follower": {
+			leader:       "10.0.0.2:8300",
+			peers:        []string{"10.0.0.1:8300", "10.0.0.2:8300", "10.0.0.3:8300"},
+			expOutput:    "Server state: follower of leader 10.0.0.2:8300",
+			expLiveness:  0,
+			expReadiness: 0,
+		},
+		"needs peers": {
+			peers:        []string{"10.0.0.1:8300"},
+			expOutput:    "Server state: needs peers (1 of 3 servers have joined)",
+			expLiveness:  0,
+			expReadiness: 1,
+		},
+		"no leader": {
+			peers:        []string{"10.0.0.1:8300", "10.0.0.2:8300", "10.0.0.3:8300"},
+			expOutput:    "Server state: no leader (an election is in progress)",
+			expLiveness:  0,
+			expReadiness: 1,
+		},
+		"unavailable": {
+			unavailable:  true,
+			expOutput:    "Server state: unavailable",
+			expLiveness:  1,
+			expReadiness: 1,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case c.unavailable:
+					w.WriteHeader(http.StatusInternalServerError)
+				case r.URL.Path == "/v1/status/leader":
+					json.NewEncoder(w).Encode(c.leader)
+				case r.URL.Path == "/v1/status/peers":
+					json.NewEncoder(w).Encode(c.peers)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			t.Cleanup(consulServer.Close)
+
+			for probe, expCode := range map[string]int{
+				probeStartup:   c.expLiveness,
+				probeLiveness:  c.expLiveness,
+				probeReadiness: c.expReadiness,
+			} {
+				ui := cli.NewMockUi()
+				cmd := Command{UI: ui}
+				exitCode := cmd.Run([]string{
+					"-probe", probe,
+					"-http-addr", consulServer.URL,
+					"-consul-api-timeout", "5s",
+					"-advertise-ip", "10.0.0.1",
+					"-bootstrap-expect", "3",
+				})
+				require.Equal(t, expCode, exitCode, probe)
+				require.Contains(t, ui.OutputWriter.String()+ui.ErrorWriter.String(), c.expOutput, probe)
+			}
+		})
+	}
+}
@@ -5,17 +5,23 @@ package synccatalog
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"regexp"
+	"sort"
+	"strings"
 	"sync"
 	"syscall"
+	"text/template"
 	"time"
 
 	mapset "github.com/deckarep/golang-set"
+	apicommon "github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/catalog/metrics"
 	catalogtoconsul "github.com/hashicorp/consul-k8s/control-plane/catalog/to-consul"
 	catalogtok8s "github.com/hashicorp/consul-k8s/control-plane/catalog/to-k8s"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
@@ -26,9 +32,12 @@ import (
 	"github.com/hashicorp/consul-server-connection-manager/discovery"
 	"github.com/hashicorp/go-hclog"
 	"github.com/mitchellh/cli"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 )
 
 // Command is the command for syncing the K8S and Consul service
@@ -45,18 +54,31 @@ type Command struct {
 	flagConsulDomain          string
 	flagConsulK8STag          string
 	flagConsulNodeName        string
+	flagConsulNodeShards      int
+	flagConsulNodeMeta        map[string]string
 	flagK8SDefault            bool
 	flagK8SServicePrefix      string
 	flagConsulServicePrefix   string
 	flagK8SSourceNamespace    string
 	flagK8SWriteNamespace     string
 	flagConsulWritePeriod     time.Duration
+	flagFullSyncInterval      time.Duration
 	flagSyncClusterIPServices bool
 	flagSyncLBEndpoints       bool
+	flagLBAddressType         string
+	flagLBTaggedAddresses     bool
 	flagNodePortSyncType      string
+	flagMinReadyEndpoints     int
 	flagAddK8SNamespaceSuffix bool
+	flagK8SLineage            bool
 	flagLogLevel              string
 	flagLogJSON               bool
+	flagEnableMetrics         bool
+
+	// Flags to support running multiple replicas
+	flagEnableLeaderElection    bool   // Only sync from the replica holding the leader election lease
+	flagLeaderElectionID        string // Name of the Lease used for leader election
+	flagLeaderElectionNamespace string // Namespace of the Lease used for leader election
 
 	// Flags to support namespaces
 	flagEnableNamespaces           bool     // Use namespacing on all components
@@ -67,16 +89,38 @@ type Command struct {
 	flagK8SNSMirroringPrefix       string   // Prefix added to Consul namespaces created when mirroring
 	flagCrossNamespaceACLPolicy    string   // The name of the ACL policy to add to every created namespace if ACLs are enabled
 
+	// Flags to support syncing the services of Consul namespaces to K8s namespaces
+	flagToK8SNamespaceMapping  map[string]string // Maps Consul namespaces to the K8s namespaces their services are synced to
+	flagEnableToK8SNSMirroring bool              // Syncs Consul namespaces to the K8s namespaces with the same name
+
 	// Flags to support Kubernetes Ingress resources
 	flagEnableIngress   bool // Register services using the hostname from an ingress resource
 	flagLoadBalancerIPs bool // Use the load balancer IP of an ingress resource instead of the hostname
 
+	// Flags to support syncing to multiple Consul datacenters or partitions
+	flagAdditionalDestinations []string // Additional Consul datacenters or partitions to register K8s services in
+
+	// destinations are the parsed -additional-consul-destination flags.
+	destinations []syncDestination
+
+	// Flags to template the tags and meta of synced services
+	flagServiceTagTemplates  []string
+	flagServiceMetaTemplates []string
+
+	// serviceTagTemplates and serviceMetaTemplates are the parsed template flags.
+	serviceTagTemplates  []*template.Template
+	serviceMetaTemplates []catalogtoconsul.ServiceMetaTemplate
+
 	clientset kubernetes.Interface
 
 	// ready indicates whether this controller is ready to sync services. This will be changed to true once the
 	// consul-server-connection-manager has finished initial initialization.
 	ready bool
 
+	// fullSyncCh holds a pending full reconcile that was requested with SIGUSR1
+	// or the /full-sync endpoint until the syncers are started.
+	fullSyncCh chan struct{}
+
 	once    sync.Once
 	sigCh   chan os.Signal
 	help    string
@@ -112,22 +156,54 @@ func (c *Command) init() {
 			"Kubernetes. Defaults to consul.")
 	c.flags.StringVar(&c.flagConsulK8STag, "consul-k8s-tag", "k8s",
 		"Tag value for K8S services registered in Consul")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagServiceTagTemplates), "consul-service-tag-template",
+		"Template for an additional tag of K8S services registered in Consul, e.g. \"team-{{ .Labels.team }}\". "+
+			"Templates are rendered with the .Name, .Namespace, .Labels and .Annotations of the K8S service. "+
+			"Tags that render empty are skipped. May be specified multiple times.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagServiceMetaTemplates), "consul-service-meta-template",
+		"Template for a meta key of K8S services registered in Consul, formatted as key=template, e.g. "+
+			"\"team={{ .Labels.team }}\". Templates are rendered like -consul-service-tag-template. Values that "+
+			"render empty are skipped and the service meta annotations take precedence. May be specified multiple times.")
 	c.flags.StringVar(&c.flagConsulNodeName, "consul-node-name", "k8s-sync",
 		"The Consul node name to register for catalog sync. Defaults to k8s-sync. To be discoverable "+
 			"via DNS, the name should only contain alpha-numerics and dashes.")
+	c.flags.IntVar(&c.flagConsulNodeShards, "consul-node-shards", 1,
+		"The number of Consul nodes to register K8S services with, so that very large syncs aren't "+
+			"concentrated on a single node. Services are spread across the nodes by their K8S namespace and "+
+			"the nodes are named after -consul-node-name with the index of the node as a suffix, e.g. k8s-sync-0.")
+	c.flags.Var((*flags.FlagMapValue)(&c.flagConsulNodeMeta), "consul-node-meta",
+		"Meta of the Consul nodes that K8S services are registered with, formatted as key=value. "+
+			"May be specified multiple times.")
 	c.flags.DurationVar(&c.flagConsulWritePeriod, "consul-write-interval", 30*time.Second,
 		"The interval to perform syncing operations creating Consul services, formatted "+
 			"as a time.Duration. All changes are merged and write calls are only made "+
 			"on this interval. Defaults to 30 seconds (30s).")
+	c.flags.DurationVar(&c.flagFullSyncInterval, "full-sync-interval", 0,
+		"The interval to perform full reconciles of the services synced to Consul, formatted as a "+
+			"time.Duration. Full reconciles read the services of the Consul sync nodes instead of relying on "+
+			"blocking queries, e.g. to recover from a Consul snapshot restore. They can also be triggered with "+
+			"SIGUSR1 or a POST to /full-sync on the -listen address from inside the pod, which also rewrites the "+
+			"services synced to Kubernetes. Zero disables periodic full reconciles.")
 	c.flags.BoolVar(&c.flagSyncClusterIPServices, "sync-clusterip-services", true,
 		"If true, all valid ClusterIP services in K8S are synced by default. If false, "+
 			"ClusterIP services are not synced to Consul.")
 	c.flags.BoolVar(&c.flagSyncLBEndpoints, "sync-lb-services-endpoints", false,
 		"If true, LoadBalancer service endpoints instead of ingress addresses will be synced to Consul. If false, "+
 			"LoadBalancer endpoints are not synced to Consul.")
+	c.flags.StringVar(&c.flagLBAddressType, "lb-address-type", string(catalogtoconsul.IPFirst),
+		"Defines whether the IP or the hostname of LoadBalancer ingress entries that have both is synced. "+
+			"Valid options are IPFirst and HostnameFirst.")
+	c.flags.BoolVar(&c.flagLBTaggedAddresses, "lb-tagged-addresses", false,
+		"If true, a single instance of each LoadBalancer service is synced with the address of its first "+
+			"ingress entry, and the IPs and hostnames of all its ingress entries as tagged addresses. If false, "+
+			"an instance is synced for each ingress entry.")
 	c.flags.StringVar(&c.flagNodePortSyncType, "node-port-sync-type", "ExternalOnly",
 		"Defines the type of sync for NodePort services. Valid options are ExternalOnly, "+
 			"InternalOnly and ExternalFirst.")
+	c.flags.IntVar(&c.flagMinReadyEndpoints, "min-ready-endpoints", 0,
+		"Minimum number of ready endpoints a K8S service must have before it is synced to Consul. "+
+			"Services are removed from Consul again when they fall below it. Can be overridden per service "+
+			"with the consul.hashicorp.com/service-sync-min-ready-endpoints annotation. Defaults to 0 (disabled).")
 	c.flags.BoolVar(&c.flagAddK8SNamespaceSuffix, "add-k8s-namespace-suffix", false,
 		"If true, Kubernetes namespace will be appended to service names synced to Consul separated by a dash. "+
 			"If false, no suffix will be appended to the service names in Consul. "+
@@ -137,6 +213,15 @@ func (c *Command) init() {
 			"\"debug\", \"info\", \"warn\", and \"error\".")
 	c.flags.BoolVar(&c.flagLogJSON, "log-json", false,
 		"Enable or disable JSON output format for logging.")
+	c.flags.BoolVar(&c.flagEnableMetrics, "enable-metrics", false,
+		"If true, Prometheus metrics for the catalog sync are served at the /metrics path of the -listen address.")
+	c.flags.BoolVar(&c.flagEnableLeaderElection, "enable-leader-election", false,
+		"If true, only the replica holding the leader election lease will sync services. "+
+			"Other replicas wait on standby and continue to serve health checks and metrics.")
+	c.flags.StringVar(&c.flagLeaderElectionID, "leader-election-id", "consul-sync-catalog-lock",
+		"The name of the Lease used for leader election.")
+	c.flags.StringVar(&c.flagLeaderElectionNamespace, "leader-election-namespace", "",
+		"The Kubernetes namespace of the Lease used for leader election. Required if -enable-leader-election is true.")
 
 	c.flags.Var((*flags.AppendSliceValue)(&c.flagAllowK8sNamespacesList), "allow-k8s-namespace",
 		"K8s namespaces to explicitly allow. May be specified multiple times.")
@@ -154,12 +239,29 @@ func (c *Command) init() {
 	c.flags.StringVar(&c.flagCrossNamespaceACLPolicy, "consul-cross-namespace-acl-policy", "",
 		"[Enterprise Only] Name of the ACL policy to attach to all created Consul namespaces to allow service "+
 			"discovery across Consul namespaces. Only necessary if ACLs are enabled.")
+	c.flags.Var((*flags.FlagMapValue)(&c.flagToK8SNamespaceMapping), "to-k8s-namespace-mapping",
+		"[Enterprise Only] Maps a Consul namespace to the K8s namespace that its services are synced to, formatted "+
+			"as <consul-namespace>=<k8s-namespace>. Services in the default Consul namespace are synced to "+
+			"-k8s-write-namespace unless it's mapped. Requires -enable-namespaces. May be specified multiple times.")
+	c.flags.BoolVar(&c.flagEnableToK8SNSMirroring, "enable-to-k8s-namespace-mirroring", false,
+		"[Enterprise Only] Syncs the services of Consul namespaces that aren't mapped with -to-k8s-namespace-mapping "+
+			"to the K8s namespace with the same name. Requires -enable-namespaces.")
+	c.flags.BoolVar(&c.flagK8SLineage, "add-k8s-lineage-annotations", false,
+		"If true, services synced from Consul to K8s are annotated with the Consul datacenter, namespace and "+
+			"partition that they came from and the Consul nodes of their instances.")
 
 	c.flags.BoolVar(&c.flagEnableIngress, "enable-ingress", false,
 		"[Enterprise Only] Enables namespaces, in either a single Consul namespace or mirrored.")
 	c.flags.BoolVar(&c.flagLoadBalancerIPs, "loadBalancer-ips", false,
 		"[Enterprise Only] Enables namespaces, in either a single Consul namespace or mirrored.")
 
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagAdditionalDestinations), "additional-consul-destination",
+		"An additional Consul datacenter or admin partition to register K8s services in, formatted as "+
+			"comma separated key=value pairs with the keys datacenter, partition and namespace, e.g. "+
+			"\"datacenter=dc2,namespace=k8s\". Keys that are not set default to the primary destination. "+
+			"The namespace key maps all services into that Consul namespace and requires -enable-namespaces. "+
+			"May be specified multiple times.")
+
 	c.consul = &flags.ConsulFlags{}
 	c.k8s = &flags.K8SFlags{}
 	flags.Merge(c.flags, c.consul.Flags())
@@ -174,6 +276,7 @@ func (c *Command) init() {
 		c.sigCh = make(chan os.Signal, 1)
 		signal.Notify(c.sigCh, syscall.SIGINT, syscall.SIGTERM)
 	}
+	c.fullSyncCh = make(chan struct{}, 1)
 }
 
 func (c *Command) Run(args []string) int {
@@ -261,14 +364,72 @@ func (c *Command) Run(args []string) int {
 	c.logger.Info("K8s namespace syncing configuration", "k8s namespaces allowed to be synced", allowSet,
 		"k8s namespaces denied from syncing", denySet)
 
+	// Start healthcheck handler. This is started before leader election so that
+	// standby replicas continue to report health and serve metrics.
+	go func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/health/ready", c.handleReady)
+		mux.Handle("/full-sync", common.LocalOnlyHandler(http.HandlerFunc(c.handleFullSync)))
+		if c.flagEnableMetrics {
+			mux.Handle("/metrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+		}
+		var handler http.Handler = mux
+
+		c.UI.Info(fmt.Sprintf("Listening on %q...", c.flagListen))
+		if err := http.ListenAndServe(c.flagListen, handler); err != nil {
+			c.UI.Error(fmt.Sprintf("Error listening: %s", err))
+		}
+	}()
+
+	// Request a full reconcile on SIGUSR1.
+	usr1Ch := make(chan os.Signal, 1)
+	signal.Notify(usr1Ch, syscall.SIGUSR1)
+	defer signal.Stop(usr1Ch)
+	go func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-usr1Ch:
+				c.logger.Info("SIGUSR1 received, requesting full reconcile")
+				c.requestFullSync()
+			}
+		}
+	}(ctx)
+
 	// Create the context we'll use to cancel everything
 	ctx, cancelF := context.WithCancel(context.Background())
 
+	// If leader election is enabled, block until this replica becomes the
+	// leader before starting any syncing.
+	var leaderElectionDoneCh <-chan struct{}
+	var lostLeadershipCh chan struct{}
+	if c.flagEnableLeaderElection {
+		electedCh := make(chan struct{})
+		lostLeadershipCh = make(chan struct{})
+		leaderElectionDoneCh, err = c.startLeaderElection(ctx, electedCh, lostLeadershipCh)
+		if err != nil {
+			cancelF()
+			c.UI.Error(fmt.Sprintf("Error starting leader election: %s", err))
+			return 1
+		}
+
+		select {
+		case <-electedCh:
+		case sig := <-c.sigCh:
+			c.logger.Info(fmt.Sprintf("%s received, shutting down", sig))
+			cancelF()
+			<-leaderElectionDoneCh
+			return 0
+		}
+	}
+
 	// Start the K8S-to-Consul syncer
+	var fullSyncers []fullSyncer
 	var toConsulCh chan struct{}
 	if c.flagToConsul {
 		// Build the Consul sync and start it
-		syncer := &catalogtoconsul.ConsulSyncer{
+		consulSyncer := &catalogtoconsul.ConsulSyncer{
 			ConsulClientConfig:      consulConfig,
 			ConsulServerConnMgr:     c.connMgr,
 			Log:                     c.logger.Named("to-consul/sink"),
@@ -278,33 +439,72 @@ func (c *Command) Run(args []string) int {
 			ServicePollPeriod:       c.flagConsulWritePeriod * 2,
 			ConsulK8STag:            c.flagConsulK8STag,
 			ConsulNodeName:          c.flagConsulNodeName,
+			ConsulNodeShards:        c.flagConsulNodeShards,
+			FullSyncPeriod:          c.flagFullSyncInterval,
+		}
+		go consulSyncer.Run(ctx)
+		fullSyncers = append(fullSyncers, consulSyncer)
+
+		// Every additional destination gets its own syncer so that services are
+		// registered, watched and reaped independently in each of them. Requests
+		// are forwarded to the destination by the Consul servers that this
+		// syncer is connected to.
+		var syncer catalogtoconsul.Syncer = consulSyncer
+		if len(c.destinations) > 0 {
+			syncers := catalogtoconsul.MultiSyncer{consulSyncer}
+			for _, d := range c.destinations {
+				destinationSyncer := &catalogtoconsul.ConsulSyncer{
+					ConsulClientConfig:      d.consulConfig(consulConfig),
+					ConsulServerConnMgr:     c.connMgr,
+					Log:                     c.logger.Named("to-consul/sink").With("datacenter", d.datacenter, "partition", d.partition),
+					EnableNamespaces:        c.flagEnableNamespaces,
+					CrossNamespaceACLPolicy: c.flagCrossNamespaceACLPolicy,
+					DestinationNamespace:    d.namespace,
+					SyncPeriod:              c.flagConsulWritePeriod,
+					ServicePollPeriod:       c.flagConsulWritePeriod * 2,
+					ConsulK8STag:            c.flagConsulK8STag,
+					ConsulNodeName:          c.flagConsulNodeName,
+					ConsulNodeShards:        c.flagConsulNodeShards,
+					FullSyncPeriod:          c.flagFullSyncInterval,
+				}
+				go destinationSyncer.Run(ctx)
+				syncers = append(syncers, destinationSyncer)
+				fullSyncers = append(fullSyncers, destinationSyncer)
+			}
+			syncer = syncers
 		}
-		go syncer.Run(ctx)
 
 		// Build the controller and start it
 		ctl := &controller.Controller{
 			Log: c.logger.Named("to-consul/controller"),
 			Resource: &catalogtoconsul.ServiceResource{
-				Log:                        c.logger.Named("to-consul/source"),
-				Client:                     c.clientset,
-				Syncer:                     syncer,
-				Ctx:                        ctx,
-				AllowK8sNamespacesSet:      allowSet,
-				DenyK8sNamespacesSet:       denySet,
-				ExplicitEnable:             !c.flagK8SDefault,
-				ClusterIPSync:              c.flagSyncClusterIPServices,
-				LoadBalancerEndpointsSync:  c.flagSyncLBEndpoints,
-				NodePortSync:               catalogtoconsul.NodePortSyncType(c.flagNodePortSyncType),
-				ConsulK8STag:               c.flagConsulK8STag,
-				ConsulServicePrefix:        c.flagConsulServicePrefix,
-				AddK8SNamespaceSuffix:      c.flagAddK8SNamespaceSuffix,
-				EnableNamespaces:           c.flagEnableNamespaces,
-				ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
-				EnableK8SNSMirroring:       c.flagEnableK8SNSMirroring,
-				K8SNSMirroringPrefix:       c.flagK8SNSMirroringPrefix,
-				ConsulNodeName:             c.flagConsulNodeName,
-				EnableIngress:              c.flagEnableIngress,
-				SyncLoadBalancerIPs:        c.flagLoadBalancerIPs,
+				Log:                         c.logger.Named("to-consul/source"),
+				Client:                      c.clientset,
+				Syncer:                      syncer,
+				Ctx:                         ctx,
+				AllowK8sNamespacesSet:       allowSet,
+				DenyK8sNamespacesSet:        denySet,
+				ExplicitEnable:              !c.flagK8SDefault,
+				ClusterIPSync:               c.flagSyncClusterIPServices,
+				LoadBalancerEndpointsSync:   c.flagSyncLBEndpoints,
+				LoadBalancerAddressType:     catalogtoconsul.LoadBalancerAddressType(c.flagLBAddressType),
+				LoadBalancerTaggedAddresses: c.flagLBTaggedAddresses,
+				NodePortSync:                catalogtoconsul.NodePortSyncType(c.flagNodePortSyncType),
+				ConsulK8STag:                c.flagConsulK8STag,
+				ServiceTagTemplates:         c.serviceTagTemplates,
+				ServiceMetaTemplates:        c.serviceMetaTemplates,
+				ConsulServicePrefix:         c.flagConsulServicePrefix,
+				AddK8SNamespaceSuffix:       c.flagAddK8SNamespaceSuffix,
+				EnableNamespaces:            c.flagEnableNamespaces,
+				ConsulDestinationNamespace:  c.flagConsulDestinationNamespace,
+				EnableK8SNSMirroring:        c.flagEnableK8SNSMirroring,
+				K8SNSMirroringPrefix:        c.flagK8SNSMirroringPrefix,
+				ConsulNodeName:              c.flagConsulNodeName,
+				ConsulNodeShards:            c.flagConsulNodeShards,
+				ConsulNodeMeta:              c.flagConsulNodeMeta,
+				EnableIngress:               c.flagEnableIngress,
+				SyncLoadBalancerIPs:         c.flagLoadBalancerIPs,
+				MinReadyEndpoints:           c.flagMinReadyEndpoints,
 			},
 		}
 
@@ -321,8 +521,11 @@ func (c *Command) Run(args []string) int {
 		sink := &catalogtok8s.K8SSink{
 			Client:    c.clientset,
 			Namespace: c.flagK8SWriteNamespace,
-			Log:       c.logger.Named("to-k8s/sink"),
-			Ctx:       ctx,
+			// Services are written to other namespaces than the write
+			// namespace when Consul namespaces are mapped or mirrored.
+			AllNamespaces: len(c.flagToK8SNamespaceMapping) > 0 || c.flagEnableToK8SNSMirroring,
+			Log:           c.logger.Named("to-k8s/sink"),
+			Ctx:           ctx,
 		}
 
 		source := &catalogtok8s.Source{
@@ -333,6 +536,10 @@ func (c *Command) Run(args []string) int {
 			Prefix:              c.flagK8SServicePrefix,
 			Log:                 c.logger.Named("to-k8s/source"),
 			ConsulK8STag:        c.flagConsulK8STag,
+			EnableNamespaces:    len(c.flagToK8SNamespaceMapping) > 0 || c.flagEnableToK8SNSMirroring,
+			NamespaceMapping:    c.flagToK8SNamespaceMapping,
+			EnableNSMirroring:   c.flagEnableToK8SNSMirroring,
+			EnableLineage:       c.flagK8SLineage,
 		}
 		go source.Run(ctx)
 
@@ -342,6 +549,8 @@ func (c *Command) Run(args []string) int {
 			Resource: sink,
 		}
 
+		fullSyncers = append(fullSyncers, sink)
+
 		toK8SCh = make(chan struct{})
 		go func() {
 			defer close(toK8SCh)
@@ -349,15 +558,17 @@ func (c *Command) Run(args []string) int {
 		}()
 	}
 
-	// Start healthcheck handler
+	// Pass requested full reconciles on to the syncers.
 	go func() {
-		mux := http.NewServeMux()
-		mux.HandleFunc("/health/ready", c.handleReady)
-		var handler http.Handler = mux
-
-		c.UI.Info(fmt.Sprintf("Listening on %q...", c.flagListen))
-		if err := http.ListenAndServe(c.flagListen, handler); err != nil {
-			c.UI.Error(fmt.Sprintf("Error listening: %s", err))
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.fullSyncCh:
+				for _, s := range fullSyncers {
+					s.TriggerFullSync()
+				}
+			}
 		}
 	}()
 
@@ -378,6 +589,19 @@ func (c *Command) Run(args []string) int {
 		}
 		return 1
 
+	// Lost the leader election lease, stop syncing so the new leader
+	// is the only replica writing.
+	case <-lostLeadershipCh:
+		c.logger.Error("lost leader election lease, shutting down")
+		cancelF()
+		if toConsulCh != nil {
+			<-toConsulCh
+		}
+		if toK8SCh != nil {
+			<-toK8SCh
+		}
+		return 1
+
 	// Interrupted/terminated, gracefully exit
 	case sig := <-c.sigCh:
 		c.logger.Info(fmt.Sprintf("%s received, shutting down", sig))
@@ -388,10 +612,71 @@ func (c *Command) Run(args []string) int {
 		if toK8SCh != nil {
 			<-toK8SCh
 		}
+		if leaderElectionDoneCh != nil {
+			// Wait for the lease to be released so another replica can take over immediately.
+			<-leaderElectionDoneCh
+		}
 		return 0
 	}
 }
 
+// startLeaderElection runs Lease based leader election in the background.
+// electedCh is closed once this replica becomes the leader and lostCh is
+// closed when it stops leading. The returned channel is closed once the
+// election has stopped and the lease has been released, which happens
+// when ctx is cancelled.
+func (c *Command) startLeaderElection(ctx context.Context, electedCh, lostCh chan struct{}) (<-chan struct{}, error) {
+	identity, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      c.flagLeaderElectionID,
+			Namespace: c.flagLeaderElectionNamespace,
+		},
+		Client: c.clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		Name:            c.flagLeaderElectionID,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {
+				c.logger.Info("acquired leader election lease", "identity", identity)
+				close(electedCh)
+			},
+			OnStoppedLeading: func() {
+				c.logger.Info("stopped leader election", "identity", identity)
+				close(lostCh)
+			},
+			OnNewLeader: func(leader string) {
+				if leader != identity {
+					c.logger.Info("waiting on standby for leader election lease", "leader", leader)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		elector.Run(ctx)
+	}()
+	return doneCh, nil
+}
+
 func (c *Command) handleReady(rw http.ResponseWriter, _ *http.Request) {
 	if !c.ready {
 		c.UI.Error("[GET /health/ready] sync catalog controller is not yet ready")
@@ -401,6 +686,32 @@ func (c *Command) handleReady(rw http.ResponseWriter, _ *http.Request) {
 	rw.WriteHeader(204)
 }
 
+// fullSyncer is a syncer that runs full reconciles when they're requested.
+type fullSyncer interface {
+	TriggerFullSync()
+}
+
+// handleFullSync requests a full reconcile of the synced services.
+func (c *Command) handleFullSync(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	c.logger.Info("full reconcile requested", "remote-addr", req.RemoteAddr)
+	c.requestFullSync()
+	rw.WriteHeader(http.StatusAccepted)
+}
+
+// requestFullSync requests a full reconcile without blocking. Requests are
+// merged while one is pending.
+func (c *Command) requestFullSync() {
+	select {
+	case c.fullSyncCh <- struct{}{}:
+	default:
+	}
+}
+
 func (c *Command) Synopsis() string { return synopsis }
 func (c *Command) Help() string {
 	c.once.Do(c.init)
@@ -418,6 +729,10 @@ func (c *Command) sendSignal(sig os.Signal) {
 }
 
 func (c *Command) validateFlags() error {
+	if err := c.consul.Validate(); err != nil {
+		return err
+	}
+
 	// For the Consul node name to be discoverable via DNS, it must contain only
 	// dashes and alphanumeric characters. Length is also constrained.
 	// These restrictions match those defined in Consul's agent definition.
@@ -436,10 +751,167 @@ func (c *Command) validateFlags() error {
 			c.flagConsulNodeName,
 		)
 	}
+	if c.flagConsulNodeShards < 1 {
+		return fmt.Errorf("-consul-node-shards=%d is invalid: must be at least 1", c.flagConsulNodeShards)
+	}
+	nodeNames := catalogtoconsul.ConsulNodeNames(c.flagConsulNodeName, c.flagConsulNodeShards)
+	if lastNode := nodeNames[len(nodeNames)-1]; len(lastNode) > maxDNSLabelLength {
+		return fmt.Errorf("-consul-node-shards=%d is invalid: node name %s will not be discoverable "+
+			"via DNS due to it being too long. Valid lengths are between 1 and 63 bytes",
+			c.flagConsulNodeShards, lastNode,
+		)
+	}
+	if _, ok := c.flagConsulNodeMeta[catalogtoconsul.ConsulSourceKey]; ok {
+		return fmt.Errorf("-consul-node-meta must not set %q, it's set by the sync", catalogtoconsul.ConsulSourceKey)
+	}
+
+	if c.flagFullSyncInterval < 0 {
+		return fmt.Errorf("-full-sync-interval=%s is invalid: must not be negative", c.flagFullSyncInterval)
+	}
+
+	switch catalogtoconsul.LoadBalancerAddressType(c.flagLBAddressType) {
+	case catalogtoconsul.IPFirst, catalogtoconsul.HostnameFirst:
+	default:
+		return fmt.Errorf("-lb-address-type=%s is invalid: must be one of %s or %s",
+			c.flagLBAddressType, catalogtoconsul.IPFirst, catalogtoconsul.HostnameFirst)
+	}
+
+	if c.flagMinReadyEndpoints < 0 {
+		return fmt.Errorf("-min-ready-endpoints=%d is invalid: must not be negative", c.flagMinReadyEndpoints)
+	}
+
+	if c.flagEnableLeaderElection && c.flagLeaderElectionNamespace == "" {
+		return fmt.Errorf("-leader-election-namespace must be set when -enable-leader-election is true")
+	}
+
+	if len(c.flagToK8SNamespaceMapping) > 0 && !c.flagEnableNamespaces {
+		return fmt.Errorf("-to-k8s-namespace-mapping requires -enable-namespaces")
+	}
+	if c.flagEnableToK8SNSMirroring && !c.flagEnableNamespaces {
+		return fmt.Errorf("-enable-to-k8s-namespace-mirroring requires -enable-namespaces")
+	}
+	consulNamespaces := make([]string, 0, len(c.flagToK8SNamespaceMapping))
+	for consulNS := range c.flagToK8SNamespaceMapping {
+		consulNamespaces = append(consulNamespaces, consulNS)
+	}
+	sort.Strings(consulNamespaces)
+	mappedFrom := make(map[string]string)
+	for _, consulNS := range consulNamespaces {
+		k8sNS := c.flagToK8SNamespaceMapping[consulNS]
+		if other, ok := mappedFrom[k8sNS]; ok {
+			return fmt.Errorf("-to-k8s-namespace-mapping is invalid: Consul namespaces %q and %q both map to K8s namespace %q", other, consulNS, k8sNS)
+		}
+		mappedFrom[k8sNS] = consulNS
+	}
+
+	c.serviceTagTemplates = nil
+	for _, raw := range c.flagServiceTagTemplates {
+		tmpl, err := catalogtoconsul.ParseServiceTagTemplate(raw)
+		if err != nil {
+			return fmt.Errorf("-consul-service-tag-template=%s is invalid: %s", raw, err)
+		}
+		c.serviceTagTemplates = append(c.serviceTagTemplates, tmpl)
+	}
+	c.serviceMetaTemplates = nil
+	for _, raw := range c.flagServiceMetaTemplates {
+		meta, err := catalogtoconsul.ParseServiceMetaTemplate(raw)
+		if err != nil {
+			return fmt.Errorf("-consul-service-meta-template=%s is invalid: %s", raw, err)
+		}
+		c.serviceMetaTemplates = append(c.serviceMetaTemplates, meta)
+	}
+
+	c.destinations = nil
+	primary := syncDestination{}.key(c.consul.Datacenter, c.consul.Partition)
+	seen := make(map[string]bool)
+	for _, value := range c.flagAdditionalDestinations {
+		d, err := parseSyncDestination(value)
+		if err != nil {
+			return fmt.Errorf("-additional-consul-destination=%s is invalid: %s", value, err)
+		}
+		if d.namespace != "" && !c.flagEnableNamespaces {
+			return fmt.Errorf("-additional-consul-destination=%s is invalid: namespace requires -enable-namespaces", value)
+		}
+		key := d.key(c.consul.Datacenter, c.consul.Partition)
+		if key == primary {
+			return fmt.Errorf("-additional-consul-destination=%s is invalid: must be a different datacenter or partition "+
+				"than the one services are synced to by default", value)
+		}
+		if seen[key] {
+			return fmt.Errorf("-additional-consul-destination=%s is invalid: datacenter and partition are already a destination", value)
+		}
+		seen[key] = true
+		c.destinations = append(c.destinations, d)
+	}
 
 	return nil
 }
 
+// syncDestination is an additional Consul datacenter or admin partition that
+// K8s services are registered in. Fields that aren't set default to the
+// datacenter and partition of the primary destination.
+type syncDestination struct {
+	datacenter string
+	partition  string
+	namespace  string
+}
+
+// parseSyncDestination parses a destination formatted as comma separated
+// key=value pairs, e.g. "datacenter=dc2,partition=ap1,namespace=k8s".
+func parseSyncDestination(value string) (syncDestination, error) {
+	var d syncDestination
+	for _, pair := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || v == "" {
+			return d, fmt.Errorf("%q must be formatted as key=value", pair)
+		}
+		switch k {
+		case "datacenter":
+			d.datacenter = v
+		case "partition":
+			d.partition = v
+		case "namespace":
+			d.namespace = v
+		default:
+			return d, fmt.Errorf("unknown key %q, must be one of datacenter, partition or namespace", k)
+		}
+	}
+	if d.datacenter == "" && d.partition == "" {
+		return d, errors.New("datacenter or partition must be set")
+	}
+	return d, nil
+}
+
+// key identifies the datacenter and partition of the destination.
+func (d syncDestination) key(defaultDatacenter, defaultPartition string) string {
+	dc, partition := d.datacenter, d.partition
+	if dc == "" {
+		dc = defaultDatacenter
+	}
+	if partition == "" {
+		partition = defaultPartition
+	}
+	if partition == "" {
+		partition = apicommon.DefaultConsulPartition
+	}
+	return dc + "/" + partition
+}
+
+// consulConfig returns a copy of the Consul client config that sends requests
+// to the destination's datacenter and partition.
+func (d syncDestination) consulConfig(cfg *consul.Config) *consul.Config {
+	apiConfig := *cfg.APIClientConfig
+	if d.datacenter != "" {
+		apiConfig.Datacenter = d.datacenter
+	}
+	if d.partition != "" {
+		apiConfig.Partition = d.partition
+	}
+	destinationConfig := *cfg
+	destinationConfig.APIClientConfig = &apiConfig
+	return &destinationConfig
+}
+
 const synopsis = "Sync Kubernetes services and Consul services."
 const help = `
 Usage: consul-k8s-control-plane sync-catalog [options]
@@ -5,13 +5,17 @@ package synccatalog
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strconv"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/test"
+	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/hashicorp/go-hclog"
 	"github.com/mitchellh/cli"
@@ -39,6 +43,80 @@ func TestRun_FlagValidation(t *testing.T) {
 			ExpErr: "-consul-node-name=5r9OPGfSRXUdGzNjBdAwmhCBrzHDNYs4XjZVR4wp7lSLIzqwS0ta51nBLIN0TMPV-too-long is invalid: node name will not be discoverable " +
 				"via DNS due to it being too long. Valid lengths are between 1 and 63 bytes",
 		},
+		{
+			Flags:  []string{"-consul-node-shards=0"},
+			ExpErr: "-consul-node-shards=0 is invalid: must be at least 1",
+		},
+		{
+			Flags: []string{"-consul-node-name=5r9OPGfSRXUdGzNjBdAwmhCBrzHDNYs4XjZVR4wp7lSLIzqwS0ta51nBLIN0TM", "-consul-node-shards=10"},
+			ExpErr: "-consul-node-shards=10 is invalid: node name 5r9OPGfSRXUdGzNjBdAwmhCBrzHDNYs4XjZVR4wp7lSLIzqwS0ta51nBLIN0TM-9 will not be discoverable " +
+				"via DNS due to it being too long. Valid lengths are between 1 and 63 bytes",
+		},
+		{
+			Flags:  []string{"-consul-node-meta=external-source=other"},
+			ExpErr: `-consul-node-meta must not set "external-source", it's set by the sync`,
+		},
+		{
+			Flags:  []string{"-full-sync-interval=-1s"},
+			ExpErr: "-full-sync-interval=-1s is invalid: must not be negative",
+		},
+		{
+			Flags:  []string{"-min-ready-endpoints=-1"},
+			ExpErr: "-min-ready-endpoints=-1 is invalid: must not be negative",
+		},
+		{
+			Flags:  []string{"-lb-address-type=Hostname"},
+			ExpErr: "-lb-address-type=Hostname is invalid: must be one of IPFirst or HostnameFirst",
+		},
+		{
+			Flags:  []string{"-enable-leader-election"},
+			ExpErr: "-leader-election-namespace must be set when -enable-leader-election is true",
+		},
+		{
+			Flags:  []string{"-to-k8s-namespace-mapping=team-a=apps"},
+			ExpErr: "-to-k8s-namespace-mapping requires -enable-namespaces",
+		},
+		{
+			Flags:  []string{"-enable-to-k8s-namespace-mirroring"},
+			ExpErr: "-enable-to-k8s-namespace-mirroring requires -enable-namespaces",
+		},
+		{
+			Flags:  []string{"-enable-namespaces", "-to-k8s-namespace-mapping=team-a=apps", "-to-k8s-namespace-mapping=team-b=apps"},
+			ExpErr: `-to-k8s-namespace-mapping is invalid: Consul namespaces "team-a" and "team-b" both map to K8s namespace "apps"`,
+		},
+		{
+			Flags:  []string{"-consul-service-tag-template={{ .Labels.team"},
+			ExpErr: "-consul-service-tag-template={{ .Labels.team is invalid: template: :1: unclosed action",
+		},
+		{
+			Flags:  []string{"-consul-service-meta-template={{ .Labels.team }}"},
+			ExpErr: `-consul-service-meta-template={{ .Labels.team }} is invalid: "{{ .Labels.team }}" must be formatted as key=template`,
+		},
+		{
+			Flags:  []string{"-additional-consul-destination=namespace=k8s"},
+			ExpErr: "-additional-consul-destination=namespace=k8s is invalid: datacenter or partition must be set",
+		},
+		{
+			Flags:  []string{"-additional-consul-destination=dc=dc2"},
+			ExpErr: `-additional-consul-destination=dc=dc2 is invalid: unknown key "dc", must be one of datacenter, partition or namespace`,
+		},
+		{
+			Flags:  []string{"-additional-consul-destination=datacenter"},
+			ExpErr: `-additional-consul-destination=datacenter is invalid: "datacenter" must be formatted as key=value`,
+		},
+		{
+			Flags:  []string{"-additional-consul-destination=datacenter=dc2,namespace=k8s"},
+			ExpErr: "-additional-consul-destination=datacenter=dc2,namespace=k8s is invalid: namespace requires -enable-namespaces",
+		},
+		{
+			Flags: []string{"-datacenter=dc1", "-additional-consul-destination=datacenter=dc1"},
+			ExpErr: "-additional-consul-destination=datacenter=dc1 is invalid: must be a different datacenter or partition " +
+				"than the one services are synced to by default",
+		},
+		{
+			Flags:  []string{"-additional-consul-destination=datacenter=dc2", "-additional-consul-destination=datacenter=dc2,partition=default"},
+			ExpErr: "-additional-consul-destination=datacenter=dc2,partition=default is invalid: datacenter and partition are already a destination",
+		},
 	}
 
 	for _, c := range cases {
@@ -54,6 +132,107 @@ func TestRun_FlagValidation(t *testing.T) {
 	}
 }
 
+func TestParseSyncDestination(t *testing.T) {
+	t.Parallel()
+
+	d, err := parseSyncDestination("datacenter=dc2, partition=ap1,namespace=k8s")
+	require.NoError(t, err)
+	require.Equal(t, syncDestination{datacenter: "dc2", partition: "ap1", namespace: "k8s"}, d)
+
+	cfg := &consul.Config{
+		APIClientConfig: &api.Config{Datacenter: "dc1", Partition: "default", Token: "token"},
+		HTTPPort:        8500,
+	}
+	destinationConfig := d.consulConfig(cfg)
+	require.Equal(t, "dc2", destinationConfig.APIClientConfig.Datacenter)
+	require.Equal(t, "ap1", destinationConfig.APIClientConfig.Partition)
+	require.Equal(t, "token", destinationConfig.APIClientConfig.Token)
+	require.Equal(t, 8500, destinationConfig.HTTPPort)
+	// The primary config must not be modified.
+	require.Equal(t, "dc1", cfg.APIClientConfig.Datacenter)
+	require.Equal(t, "default", cfg.APIClientConfig.Partition)
+
+	d, err = parseSyncDestination("partition=ap2")
+	require.NoError(t, err)
+	destinationConfig = d.consulConfig(cfg)
+	require.Equal(t, "dc1", destinationConfig.APIClientConfig.Datacenter)
+	require.Equal(t, "ap2", destinationConfig.APIClientConfig.Partition)
+}
+
+// Test that a replica acquires the leader election lease and that a second
+// replica waits on standby while the lease is held.
+func TestCommand_startLeaderElection(t *testing.T) {
+	t.Parallel()
+
+	k8s := fake.NewSimpleClientset()
+	newCommand := func() *Command {
+		return &Command{
+			clientset:                   k8s,
+			logger:                      hclog.New(&hclog.LoggerOptions{Name: t.Name(), Level: hclog.Debug}),
+			flagLeaderElectionID:        "consul-sync-catalog-lock",
+			flagLeaderElectionNamespace: "default",
+		}
+	}
+
+	// The first replica becomes the leader.
+	leaderCtx, leaderCancel := context.WithCancel(context.Background())
+	defer leaderCancel()
+	leaderElected, leaderLost := make(chan struct{}), make(chan struct{})
+	leaderDone, err := newCommand().startLeaderElection(leaderCtx, leaderElected, leaderLost)
+	require.NoError(t, err)
+	select {
+	case <-leaderElected:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for leader election")
+	}
+
+	// Both replicas share the same hostname in this test, so change the
+	// holder identity to simulate the lease being held by another pod.
+	lease, err := k8s.CoordinationV1().Leases("default").Get(context.Background(), "consul-sync-catalog-lock", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, lease.Spec.HolderIdentity)
+	other := "other-replica"
+	lease.Spec.HolderIdentity = &other
+	_, err = k8s.CoordinationV1().Leases("default").Update(context.Background(), lease, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// The second replica waits on standby while the lease is held.
+	standbyCtx, standbyCancel := context.WithCancel(context.Background())
+	standbyElected, standbyLost := make(chan struct{}), make(chan struct{})
+	standbyDone, err := newCommand().startLeaderElection(standbyCtx, standbyElected, standbyLost)
+	require.NoError(t, err)
+	select {
+	case <-standbyElected:
+		t.Fatal("standby replica should not acquire the lease while it is held")
+	case <-time.After(3 * time.Second):
+	}
+
+	standbyCancel()
+	<-standbyDone
+	leaderCancel()
+	<-leaderDone
+}
+
+// Test that full reconciles can only be requested with a POST, and that
+// requests are merged while one is pending.
+func TestHandleFullSync(t *testing.T) {
+	t.Parallel()
+	cmd := Command{logger: hclog.NewNullLogger()}
+	cmd.once.Do(cmd.init)
+
+	rec := httptest.NewRecorder()
+	cmd.handleFullSync(rec, httptest.NewRequest(http.MethodGet, "/full-sync", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	require.Len(t, cmd.fullSyncCh, 0)
+
+	for i := 0; i < 2; i++ {
+		rec = httptest.NewRecorder()
+		cmd.handleFullSync(rec, httptest.NewRequest(http.MethodPost, "/full-sync", nil))
+		require.Equal(t, http.StatusAccepted, rec.Code)
+	}
+	require.Len(t, cmd.fullSyncCh, 1)
+}
+
 // Test that the default consul service is synced to k8s.
 func TestRun_Defaults_SyncsConsulServiceToK8s(t *testing.T) {
 	t.Parallel()
@@ -56,3 +56,16 @@ func GetHumanVersion() string {
 	// Strip off any single quotes added by the git information.
 	return strings.Replace(version, "'", "", -1)
 }
+
+// GetLabelVersion returns the version without the git commit so that it can be
+// used as a Kubernetes label value, e.g. "1.2.0-dev".
+func GetLabelVersion() string {
+	version := Version
+	if IsFIPS() {
+		version += ".fips1402"
+	}
+	if VersionPrerelease != "" {
+		version += "-" + VersionPrerelease
+	}
+	return version
+}